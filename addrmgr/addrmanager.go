@@ -17,6 +17,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -25,6 +26,7 @@ import (
 
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
 	"github.com/bourbaki-czz/classzz/wire"
+	"golang.org/x/crypto/sha3"
 )
 
 // AddrManager provides a concurrency safe address manager for caching potential
@@ -32,6 +34,7 @@ import (
 type AddrManager struct {
 	mtx            sync.Mutex
 	peersFile      string
+	anchorsFile    string
 	lookupFunc     func(string) ([]net.IP, error)
 	rand           *rand.Rand
 	key            [32]byte
@@ -47,6 +50,7 @@ type AddrManager struct {
 	lamtx          sync.Mutex
 	localAddresses map[string]*localAddress
 	version        int
+	asmap          *ASMap
 }
 
 type serializedKnownAddress struct {
@@ -58,6 +62,8 @@ type serializedKnownAddress struct {
 	LastSuccess int64
 	Services    wire.ServiceFlag
 	SrcServices wire.ServiceFlag
+	Failures    int
+	Latency     int64 // microseconds
 	// no refcount or tried, that is available from context.
 }
 
@@ -158,7 +164,7 @@ const (
 	getAddrPercent = 23
 
 	// serialisationVersion is the current version of the on-disk format.
-	serialisationVersion = 2
+	serialisationVersion = 3
 )
 
 // updateAddress is a helper function to either update an address already known
@@ -294,14 +300,34 @@ func (a *AddrManager) pickTried(bucket int) *list.Element {
 	return oldestElem
 }
 
+// SetASMap sets the map used to group addresses by the autonomous system
+// announcing them instead of by network prefix.  It must be called before the
+// address manager is started.
+func (a *AddrManager) SetASMap(m *ASMap) {
+	a.asmap = m
+}
+
+// GroupKey returns a string representing the network group an address is part
+// of.  When an ASMap is set, IP addresses it covers are grouped by the
+// autonomous system announcing them, in the form "as:number".  Any other
+// address is grouped as described by the package level GroupKey.
+func (a *AddrManager) GroupKey(na *wire.NetAddress) string {
+	if a.asmap != nil && IsRoutable(na) && !IsTor(na) && !IsI2P(na) {
+		if asn := a.asmap.Lookup(na.IP); asn != 0 {
+			return fmt.Sprintf("as:%d", asn)
+		}
+	}
+	return GroupKey(na)
+}
+
 func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddress) int {
 	// bitcoind:
 	// doublesha256(key + sourcegroup + int64(doublesha256(key + group + sourcegroup))%bucket_per_source_group) % num_new_buckets
 
 	data1 := []byte{}
 	data1 = append(data1, a.key[:]...)
-	data1 = append(data1, []byte(GroupKey(netAddr))...)
-	data1 = append(data1, []byte(GroupKey(srcAddr))...)
+	data1 = append(data1, []byte(a.GroupKey(netAddr))...)
+	data1 = append(data1, []byte(a.GroupKey(srcAddr))...)
 	hash1 := chainhash.DoubleHashB(data1)
 	hash64 := binary.LittleEndian.Uint64(hash1)
 	hash64 %= newBucketsPerGroup
@@ -309,7 +335,7 @@ func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddress) int {
 	binary.LittleEndian.PutUint64(hashbuf[:], hash64)
 	data2 := []byte{}
 	data2 = append(data2, a.key[:]...)
-	data2 = append(data2, GroupKey(srcAddr)...)
+	data2 = append(data2, a.GroupKey(srcAddr)...)
 	data2 = append(data2, hashbuf[:]...)
 
 	hash2 := chainhash.DoubleHashB(data2)
@@ -329,7 +355,7 @@ func (a *AddrManager) getTriedBucket(netAddr *wire.NetAddress) int {
 	binary.LittleEndian.PutUint64(hashbuf[:], hash64)
 	data2 := []byte{}
 	data2 = append(data2, a.key[:]...)
-	data2 = append(data2, GroupKey(netAddr)...)
+	data2 = append(data2, a.GroupKey(netAddr)...)
 	data2 = append(data2, hashbuf[:]...)
 
 	hash2 := chainhash.DoubleHashB(data2)
@@ -382,6 +408,10 @@ func (a *AddrManager) savePeers() {
 			ska.Services = v.na.Services
 			ska.SrcServices = v.srcAddr.Services
 		}
+		if a.version > 2 {
+			ska.Failures = v.failures
+			ska.Latency = int64(v.latency / time.Microsecond)
+		}
 		// Tried and refs are implicit in the rest of the structure
 		// and will be worked out from context on unserialisation.
 		sam.Addresses[i] = ska
@@ -498,6 +528,16 @@ func (a *AddrManager) deserializePeers(filePath string) error {
 		ka.attempts = v.Attempts
 		ka.lastattempt = time.Unix(v.LastAttempt, 0)
 		ka.lastsuccess = time.Unix(v.LastSuccess, 0)
+
+		// The versions before the third did not track the failed
+		// connections, but the attempts are reset on success, so they
+		// are the failure streak of the address short of the attempt
+		// in progress when the file was saved.
+		if sam.Version < 3 {
+			v.Failures = v.Attempts
+		}
+		ka.failures = v.Failures
+		ka.latency = time.Duration(v.Latency) * time.Microsecond
 		a.addrIndex[NetAddressKey(ka.na)] = ka
 	}
 
@@ -562,6 +602,61 @@ func (a *AddrManager) DeserializeNetAddress(addr string,
 	return a.HostToNetAddress(host, uint16(port), services)
 }
 
+// SaveAnchors writes the addresses of the outbound peers to reconnect to first
+// on the next start to the anchors file.  Connecting to known good peers first
+// makes it harder for an attacker to eclipse the node by making it restart
+// while the address manager is filled with attacker addresses.
+func (a *AddrManager) SaveAnchors(anchors []*wire.NetAddress) error {
+	addrs := make([]string, 0, len(anchors))
+	for _, na := range anchors {
+		addrs = append(addrs, NetAddressKey(na))
+	}
+
+	w, err := os.Create(a.anchorsFile)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return json.NewEncoder(w).Encode(addrs)
+}
+
+// LoadAnchors returns the addresses saved by SaveAnchors and removes the
+// anchors file, so that anchors which are no longer reachable are not tried
+// again after another restart.  It returns nil when there are no anchors.
+func (a *AddrManager) LoadAnchors() []*wire.NetAddress {
+	r, err := os.Open(a.anchorsFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Failed to open anchors file %s: %v",
+				a.anchorsFile, err)
+		}
+		return nil
+	}
+	var addrs []string
+	err = json.NewDecoder(r).Decode(&addrs)
+	r.Close()
+	if rmErr := os.Remove(a.anchorsFile); rmErr != nil {
+		log.Warnf("Failed to remove anchors file %s: %v", a.anchorsFile,
+			rmErr)
+	}
+	if err != nil {
+		log.Errorf("Failed to parse anchors file %s: %v", a.anchorsFile,
+			err)
+		return nil
+	}
+
+	anchors := make([]*wire.NetAddress, 0, len(addrs))
+	for _, addr := range addrs {
+		na, err := a.DeserializeNetAddress(addr, wire.SFNodeNetwork)
+		if err != nil {
+			log.Warnf("Ignoring invalid anchor %s: %v", addr, err)
+			continue
+		}
+		anchors = append(anchors, na)
+	}
+	return anchors
+}
+
 // Start begins the core address handler which manages a pool of known
 // addresses, timeouts, and interval based writes.
 func (a *AddrManager) Start() {
@@ -717,10 +812,82 @@ func (a *AddrManager) reset() {
 	}
 }
 
+const (
+	// torV3Version is the version byte of Tor v3 onion addresses.
+	torV3Version = 0x03
+
+	// torV3HostLen is the length of a Tor v3 host: 56 base32 characters
+	// encoding the public key, checksum and version followed by ".onion".
+	torV3HostLen = 62
+
+	// i2pHostLen is the length of an I2P host: 52 base32 characters
+	// encoding the destination hash followed by ".b32.i2p".
+	i2pHostLen = 60
+)
+
+// i2pEncoding is the unpadded base32 encoding used by I2P addresses.
+var i2pEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// torV3Checksum returns the checksum of a Tor v3 onion address as defined by
+// the Tor rend-spec-v3.
+func torV3Checksum(pubKey []byte) []byte {
+	data := make([]byte, 0, 15+len(pubKey)+1)
+	data = append(data, ".onion checksum"...)
+	data = append(data, pubKey...)
+	data = append(data, torV3Version)
+	sum := sha3.Sum256(data)
+	return sum[:2]
+}
+
+// decodeTorV3 returns the public key encoded by the passed Tor v3 host
+// without the ".onion" suffix.
+func decodeTorV3(host string) ([]byte, error) {
+	data, err := base32.StdEncoding.DecodeString(strings.ToUpper(host))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != wire.TorV3AddrSize+3 {
+		return nil, fmt.Errorf("invalid tor v3 address length %d",
+			len(data))
+	}
+	pubKey := data[:wire.TorV3AddrSize]
+	if data[wire.TorV3AddrSize+2] != torV3Version {
+		return nil, fmt.Errorf("unsupported tor address version %d",
+			data[wire.TorV3AddrSize+2])
+	}
+	checksum := torV3Checksum(pubKey)
+	if data[wire.TorV3AddrSize] != checksum[0] ||
+		data[wire.TorV3AddrSize+1] != checksum[1] {
+
+		return nil, fmt.Errorf("invalid tor v3 address checksum")
+	}
+	return pubKey, nil
+}
+
 // HostToNetAddress returns a netaddress given a host address.  If the address
-// is a Tor .onion address this will be taken care of.  Else if the host is
-// not an IP address it will be resolved (via Tor if required).
+// is a Tor .onion or I2P .b32.i2p address this will be taken care of.  Else if
+// the host is not an IP address it will be resolved (via Tor if required).
 func (a *AddrManager) HostToNetAddress(host string, port uint16, services wire.ServiceFlag) (*wire.NetAddress, error) {
+	// Tor v3 address is 56 char base32 + ".onion"
+	if len(host) == torV3HostLen && host[56:] == ".onion" {
+		pubKey, err := decodeTorV3(host[:56])
+		if err != nil {
+			return nil, err
+		}
+		return wire.NewNetAddressV2(wire.NetworkTorV3, pubKey, port,
+			services), nil
+	}
+
+	// I2P address is 52 char base32 + ".b32.i2p"
+	if len(host) == i2pHostLen && host[52:] == ".b32.i2p" {
+		hash, err := i2pEncoding.DecodeString(strings.ToUpper(host[:52]))
+		if err != nil {
+			return nil, err
+		}
+		return wire.NewNetAddressV2(wire.NetworkI2P, hash, port,
+			services), nil
+	}
+
 	// Tor address is 16 char base32 + ".onion"
 	var ip net.IP
 	if len(host) == 22 && host[16:] == ".onion" {
@@ -750,8 +917,21 @@ func (a *AddrManager) HostToNetAddress(host string, port uint16, services wire.S
 
 // ipString returns a string for the ip from the provided NetAddress. If the
 // ip is in the range used for Tor addresses then it will be transformed into
-// the relevant .onion address.
+// the relevant .onion address.  Tor v3 and I2P addresses are returned as
+// their .onion and .b32.i2p hosts.
 func ipString(na *wire.NetAddress) string {
+	if IsTorV3(na) {
+		data := make([]byte, 0, wire.TorV3AddrSize+3)
+		data = append(data, na.Addr...)
+		data = append(data, torV3Checksum(na.Addr)...)
+		data = append(data, torV3Version)
+		base32str := base32.StdEncoding.EncodeToString(data)
+		return strings.ToLower(base32str) + ".onion"
+	}
+	if IsI2P(na) {
+		return strings.ToLower(i2pEncoding.EncodeToString(na.Addr)) +
+			".b32.i2p"
+	}
 	if IsOnionCatTor(na) {
 		// We know now that na.IP is long enough.
 		base32str := base32.StdEncoding.EncodeToString(na.IP[6:])
@@ -861,6 +1041,39 @@ func (a *AddrManager) Attempt(addr *wire.NetAddress) {
 	ka.lastattempt = time.Now()
 }
 
+// Failed marks the last attempt to connect to the given address as failed,
+// extending its failure streak until a connection succeeds.  The address must
+// already be known to AddrManager else it will be ignored.
+func (a *AddrManager) Failed(addr *wire.NetAddress) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	ka := a.find(addr)
+	if ka == nil {
+		return
+	}
+	ka.failures++
+}
+
+// SetLatency records the passed ping latency of the peer with the given
+// address.  The latency is smoothed over the connections to the peer so a
+// single slow connection does not outweigh the ones before it.  The address
+// must already be known to AddrManager else it will be ignored.
+func (a *AddrManager) SetLatency(addr *wire.NetAddress, latency time.Duration) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	ka := a.find(addr)
+	if ka == nil || latency <= 0 {
+		return
+	}
+	if ka.latency == 0 {
+		ka.latency = latency
+		return
+	}
+	ka.latency = (3*ka.latency + latency) / 4
+}
+
 // Connected Marks the given address as currently connected and working at the
 // current time.  The address must already be known to AddrManager else it will
 // be ignored.
@@ -902,6 +1115,7 @@ func (a *AddrManager) Good(addr *wire.NetAddress) {
 	ka.lastsuccess = now
 	ka.lastattempt = now
 	ka.attempts = 0
+	ka.failures = 0
 
 	// move to tried set, optionally evicting other addresses if neeed.
 	if ka.tried {
@@ -996,7 +1210,7 @@ func (a *AddrManager) SetServices(addr *wire.NetAddress, services wire.ServiceFl
 // with the given priority.
 func (a *AddrManager) AddLocalAddress(na *wire.NetAddress, priority AddressPriority) error {
 	if !IsRoutable(na) {
-		return fmt.Errorf("address %s is not routable", na.IP)
+		return fmt.Errorf("address %s is not routable", ipString(na))
 	}
 
 	a.lamtx.Lock()
@@ -1017,6 +1231,35 @@ func (a *AddrManager) AddLocalAddress(na *wire.NetAddress, priority AddressPrior
 	return nil
 }
 
+// LocalAddress describes a known local address advertised to peers.
+type LocalAddress struct {
+	// NetAddress is the local address.
+	NetAddress *wire.NetAddress
+
+	// Score is the priority of the address.  Addresses with a higher
+	// score are preferred.
+	Score AddressPriority
+}
+
+// LocalAddresses returns the known local addresses ordered by address.
+func (a *AddrManager) LocalAddresses() []LocalAddress {
+	a.lamtx.Lock()
+	addrs := make([]LocalAddress, 0, len(a.localAddresses))
+	for _, la := range a.localAddresses {
+		addrs = append(addrs, LocalAddress{
+			NetAddress: la.na,
+			Score:      la.score,
+		})
+	}
+	a.lamtx.Unlock()
+
+	sort.Slice(addrs, func(i, j int) bool {
+		return NetAddressKey(addrs[i].NetAddress) <
+			NetAddressKey(addrs[j].NetAddress)
+	})
+	return addrs
+}
+
 // getReachabilityFrom returns the relative reachability of the provided local
 // address to the provided remote address.
 func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
@@ -1034,8 +1277,16 @@ func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
 		return Unreachable
 	}
 
-	if IsOnionCatTor(remoteAddr) {
-		if IsOnionCatTor(localAddr) {
+	if IsI2P(remoteAddr) {
+		if IsI2P(localAddr) {
+			return Private
+		}
+
+		return Default
+	}
+
+	if IsTor(remoteAddr) {
+		if IsTor(localAddr) {
 			return Private
 		}
 
@@ -1115,15 +1366,14 @@ func (a *AddrManager) GetBestLocalAddress(remoteAddr *wire.NetAddress) *wire.Net
 		}
 	}
 	if bestAddress != nil {
-		log.Debugf("Suggesting address %s:%d for %s:%d", bestAddress.IP,
-			bestAddress.Port, remoteAddr.IP, remoteAddr.Port)
+		log.Debugf("Suggesting address %s for %s",
+			NetAddressKey(bestAddress), NetAddressKey(remoteAddr))
 	} else {
-		log.Debugf("No worthy address for %s:%d", remoteAddr.IP,
-			remoteAddr.Port)
+		log.Debugf("No worthy address for %s", NetAddressKey(remoteAddr))
 
 		// Send something unroutable if nothing suitable.
 		var ip net.IP
-		if !IsIPv4(remoteAddr) && !IsOnionCatTor(remoteAddr) {
+		if !IsIPv4(remoteAddr) && !IsTor(remoteAddr) && !IsI2P(remoteAddr) {
 			ip = net.IPv6zero
 		} else {
 			ip = net.IPv4zero
@@ -1140,6 +1390,7 @@ func (a *AddrManager) GetBestLocalAddress(remoteAddr *wire.NetAddress) *wire.Net
 func New(dataDir string, lookupFunc func(string) ([]net.IP, error)) *AddrManager {
 	am := AddrManager{
 		peersFile:      filepath.Join(dataDir, "peers.json"),
+		anchorsFile:    filepath.Join(dataDir, "anchors.json"),
 		lookupFunc:     lookupFunc,
 		rand:           rand.New(rand.NewSource(time.Now().UnixNano())),
 		quit:           make(chan struct{}),
@@ -6,6 +6,7 @@ import (
 	"net"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/bourbaki-czz/classzz/wire"
 )
@@ -192,3 +193,90 @@ func TestAddrManagerV1ToV2(t *testing.T) {
 	addrMgr.loadPeers()
 	assertAddrs(t, addrMgr, expectedAddrs)
 }
+
+// TestAddrManagerV2ToV3 ensures that the quality metrics of the addresses are
+// persisted from v3 on, and derived from the attempts when upgrading from v2.
+func TestAddrManagerV2ToV3(t *testing.T) {
+	t.Parallel()
+
+	tempDir, err := ioutil.TempDir("", "addrmgr")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Save a v2 file with an address that failed twice and was pinged.
+	addrMgr := New(tempDir, nil)
+	addrMgr.version = 2
+	addr := randAddr(t)
+	addrMgr.AddAddress(addr, randAddr(t))
+	for i := 0; i < 2; i++ {
+		addrMgr.Attempt(addr)
+		addrMgr.Failed(addr)
+	}
+	addrMgr.SetLatency(addr, 150*time.Millisecond)
+	addrMgr.savePeers()
+
+	// The failure streak is derived from the attempts while the latency,
+	// which v2 did not store, is unknown.
+	addrMgr = New(tempDir, nil)
+	addrMgr.loadPeers()
+	ka := addrMgr.find(addr)
+	if ka == nil {
+		t.Fatalf("expected to find address %v", NetAddressKey(addr))
+	}
+	if ka.failures != 2 || ka.latency != 0 {
+		t.Fatalf("expected 2 failures and no latency after the "+
+			"upgrade, got %d and %v", ka.failures, ka.latency)
+	}
+
+	// Saving with the current version persists the metrics.
+	addrMgr.SetLatency(addr, 150*time.Millisecond)
+	addrMgr.savePeers()
+	addrMgr = New(tempDir, nil)
+	addrMgr.loadPeers()
+	ka = addrMgr.find(addr)
+	if ka == nil {
+		t.Fatalf("expected to find address %v", NetAddressKey(addr))
+	}
+	if ka.failures != 2 || ka.latency != 150*time.Millisecond {
+		t.Fatalf("expected 2 failures and a latency of 150ms, got %d "+
+			"and %v", ka.failures, ka.latency)
+	}
+	assertAddr(t, ka.na, addr)
+}
+
+// TestAnchors ensures the anchors survive a restart of the address manager and
+// are only returned once.
+func TestAnchors(t *testing.T) {
+	t.Parallel()
+
+	tempDir, err := ioutil.TempDir("", "addrmgr")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	anchors := []*wire.NetAddress{randAddr(t), randAddr(t)}
+	if err := New(tempDir, nil).SaveAnchors(anchors); err != nil {
+		t.Fatalf("SaveAnchors: unexpected error: %v", err)
+	}
+
+	addrMgr := New(tempDir, nil)
+	loaded := addrMgr.LoadAnchors()
+	if len(loaded) != len(anchors) {
+		t.Fatalf("LoadAnchors: got %d anchors, want %d", len(loaded),
+			len(anchors))
+	}
+	for i, na := range loaded {
+		if NetAddressKey(na) != NetAddressKey(anchors[i]) {
+			t.Fatalf("LoadAnchors: got anchor %s, want %s",
+				NetAddressKey(na), NetAddressKey(anchors[i]))
+		}
+	}
+
+	if loaded := addrMgr.LoadAnchors(); len(loaded) != 0 {
+		t.Fatalf("LoadAnchors: got %d anchors after reload, want 0",
+			len(loaded))
+	}
+}
@@ -230,6 +230,45 @@ func TestAttempt(t *testing.T) {
 	}
 }
 
+func TestFailedAndLatency(t *testing.T) {
+	n := addrmgr.New("testfailed", lookupFunc)
+
+	// Add a new address and get it
+	err := n.AddAddressByIP(someIP + ":8333")
+	if err != nil {
+		t.Fatalf("Adding address failed: %v", err)
+	}
+	ka := n.GetAddress()
+	na := ka.NetAddress()
+
+	n.Attempt(na)
+	n.Failed(na)
+	n.Attempt(na)
+	n.Failed(na)
+	if ka.Failures() != 2 {
+		t.Errorf("Address should have 2 failures, but has %d",
+			ka.Failures())
+	}
+
+	// The latency is smoothed over the connections.
+	n.SetLatency(na, 100*time.Millisecond)
+	n.SetLatency(na, 500*time.Millisecond)
+	if ka.Latency() != 200*time.Millisecond {
+		t.Errorf("Address should have a latency of 200ms, but has %v",
+			ka.Latency())
+	}
+
+	// A successful connection ends the failure streak.
+	n.Good(na)
+	if ka.Failures() != 0 {
+		t.Errorf("Address should have no failures, but has %d",
+			ka.Failures())
+	}
+	if ka.LastSuccess().IsZero() {
+		t.Errorf("Address should have a success, but does not")
+	}
+}
+
 func TestConnected(t *testing.T) {
 	n := addrmgr.New("testconnected", lookupFunc)
 
@@ -470,3 +509,53 @@ func TestNetAddressKey(t *testing.T) {
 	}
 
 }
+
+// TestHostToNetAddressV2 ensures Tor v3 and I2P hosts are converted to
+// addresses that round trip through NetAddressKey and that malformed hosts
+// are rejected.
+func TestHostToNetAddressV2(t *testing.T) {
+	const (
+		torV3Host = "pg6mmjiyjmcrsslvykfwnntlaru7p5svn6y2ymmju6nubxndf4pscryd.onion"
+		i2pHost   = "ukeu3k5oycgaauneqgtnvselmt4yemvoilkln7jpvamvfx7dnkdq.b32.i2p"
+	)
+
+	amgr := addrmgr.New("testhosttonetaddressv2", lookupFunc)
+	tests := []struct {
+		host    string
+		network wire.NetworkID
+	}{
+		{torV3Host, wire.NetworkTorV3},
+		{i2pHost, wire.NetworkI2P},
+	}
+	for _, test := range tests {
+		na, err := amgr.HostToNetAddress(test.host, 8333, wire.SFNodeNetwork)
+		if err != nil {
+			t.Errorf("HostToNetAddress %s: unexpected error: %v",
+				test.host, err)
+			continue
+		}
+		if na.NetworkID() != test.network || na.AddrV1Compatible() {
+			t.Errorf("HostToNetAddress %s: got network %v, want %v",
+				test.host, na.NetworkID(), test.network)
+		}
+		if !addrmgr.IsRoutable(na) {
+			t.Errorf("HostToNetAddress %s: address is not routable",
+				test.host)
+		}
+		want := net.JoinHostPort(test.host, "8333")
+		if key := addrmgr.NetAddressKey(na); key != want {
+			t.Errorf("NetAddressKey: got %s, want %s", key, want)
+		}
+	}
+
+	// Corrupt the checksum and version of the Tor v3 host.
+	bad := []string{
+		"ag6mmjiyjmcrsslvykfwnntlaru7p5svn6y2ymmju6nubxndf4pscryd.onion",
+		"pg6mmjiyjmcrsslvykfwnntlaru7p5svn6y2ymmju6nubxndf4pscrya.onion",
+	}
+	for _, host := range bad {
+		if _, err := amgr.HostToNetAddress(host, 8333, 0); err == nil {
+			t.Errorf("HostToNetAddress %s: expected error", host)
+		}
+	}
+}
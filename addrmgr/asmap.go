@@ -0,0 +1,108 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ASMap maps IP networks to the number of the autonomous system (AS) which
+// announces them.  Grouping addresses by AS instead of by /16 better reflects
+// which addresses are controlled by the same entity, since a single AS often
+// announces many unrelated /16s.
+//
+// An ASMap is read-only once created and is safe for concurrent access.
+type ASMap struct {
+	// prefixes maps the masked 16 byte form of a network to its AS for
+	// every prefix length, IPv4 networks being stored as IPv4-mapped IPv6
+	// networks.
+	prefixes [net.IPv6len*8 + 1]map[string]uint32
+}
+
+// ParseASMap reads an ASMap from r.  Every line holds a network in CIDR
+// notation followed by the AS announcing it, optionally prefixed with "AS",
+// such as:
+//
+//	1.0.0.0/24 AS13335
+//	2001:200::/32 2500
+//
+// Empty lines and lines starting with '#' are ignored.  Overlapping networks
+// are allowed, the most specific one applies.
+func ParseASMap(r io.Reader) (*ASMap, error) {
+	var m ASMap
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("asmap line %d: want network and "+
+				"AS number", lineNum)
+		}
+		_, ipnet, err := net.ParseCIDR(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("asmap line %d: %v", lineNum, err)
+		}
+		asn, err := strconv.ParseUint(strings.TrimPrefix(
+			strings.ToUpper(fields[1]), "AS"), 10, 32)
+		if err != nil || asn == 0 {
+			return nil, fmt.Errorf("asmap line %d: invalid AS number "+
+				"%q", lineNum, fields[1])
+		}
+
+		ones, bits := ipnet.Mask.Size()
+		if bits == net.IPv4len*8 {
+			ones += (net.IPv6len - net.IPv4len) * 8
+		}
+		if m.prefixes[ones] == nil {
+			m.prefixes[ones] = make(map[string]uint32)
+		}
+		m.prefixes[ones][string(ipnet.IP.To16())] = uint32(asn)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// LoadASMap reads an ASMap from the file at path.  See ParseASMap for the
+// format of the file.
+func LoadASMap(path string) (*ASMap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseASMap(f)
+}
+
+// Lookup returns the AS announcing ip, or 0 when ip is not covered by the
+// map.
+func (m *ASMap) Lookup(ip net.IP) uint32 {
+	ip = ip.To16()
+	if ip == nil {
+		return 0
+	}
+	for ones := len(m.prefixes) - 1; ones >= 0; ones-- {
+		if m.prefixes[ones] == nil {
+			continue
+		}
+		masked := ip.Mask(net.CIDRMask(ones, net.IPv6len*8))
+		if asn, ok := m.prefixes[ones][string(masked)]; ok {
+			return asn
+		}
+	}
+	return 0
+}
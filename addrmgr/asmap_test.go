@@ -0,0 +1,79 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/addrmgr"
+	"github.com/bourbaki-czz/classzz/wire"
+)
+
+// TestASMap ensures networks are mapped to the AS of their most specific
+// prefix and that the address manager groups mapped addresses by AS.
+func TestASMap(t *testing.T) {
+	const asmapText = `# test asmap
+1.0.0.0/8 AS100
+1.2.0.0/16 as200
+1.2.3.0/24 300
+2001:db8::/32 AS400
+`
+	m, err := addrmgr.ParseASMap(strings.NewReader(asmapText))
+	if err != nil {
+		t.Fatalf("ParseASMap: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		ip  string
+		asn uint32
+	}{
+		{"1.1.1.1", 100},
+		{"1.2.1.1", 200},
+		{"1.2.3.4", 300},
+		{"2.0.0.1", 0},
+		{"2001:db8:1::1", 400},
+		{"2001:db9::1", 0},
+	}
+	for _, test := range tests {
+		if asn := m.Lookup(net.ParseIP(test.ip)); asn != test.asn {
+			t.Errorf("Lookup(%s): got AS%d, want AS%d", test.ip, asn,
+				test.asn)
+		}
+	}
+
+	amgr := addrmgr.New("testasmap", nil)
+	amgr.SetASMap(m)
+	groupKey := func(ip string) string {
+		na := wire.NewNetAddressIPPort(net.ParseIP(ip), 8333, 0)
+		return amgr.GroupKey(na)
+	}
+	if key := groupKey("1.3.0.1"); key != "as:100" {
+		t.Errorf("GroupKey: got %s, want as:100", key)
+	}
+	if groupKey("1.3.0.1") != groupKey("1.4.0.1") {
+		t.Errorf("GroupKey: addresses of the same AS in different /16s " +
+			"are in different groups")
+	}
+	if key := groupKey("2.0.0.1"); key != "2.0.0.0" {
+		t.Errorf("GroupKey: unmapped address got %s, want 2.0.0.0", key)
+	}
+	if key := groupKey("10.0.0.1"); key != "unroutable" {
+		t.Errorf("GroupKey: unroutable address got %s", key)
+	}
+
+	invalid := []string{
+		"1.0.0.0/8",
+		"1.0.0.0/33 AS1",
+		"1.0.0.0/8 ASX",
+		"1.0.0.0/8 AS0",
+	}
+	for _, line := range invalid {
+		if _, err := addrmgr.ParseASMap(strings.NewReader(line)); err == nil {
+			t.Errorf("ParseASMap(%q): unexpected success", line)
+		}
+	}
+}
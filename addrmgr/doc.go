@@ -34,5 +34,10 @@ provided by the caller about connected, known good, and attempted addresses to
 periodically purge peers which no longer appear to be good peers as well as
 bias the selection toward known good peers.  The general idea is to make a best
 effort at only providing usable addresses.
+
+The caller may also report the connections which failed and the ping latency of
+the peers, which deprioritise the addresses failing in a row and the slow peers.
+These quality metrics are saved along with the addresses, so the selection
+after a restart keeps favoring the peers that worked well before it.
 */
 package addrmgr
@@ -23,3 +23,9 @@ func TstNewKnownAddress(na *wire.NetAddress, attempts int,
 	return &KnownAddress{na: na, attempts: attempts, lastattempt: lastattempt,
 		lastsuccess: lastsuccess, tried: tried, refs: refs}
 }
+
+func TstSetKnownAddressQuality(ka *KnownAddress, failures int,
+	latency time.Duration) {
+	ka.failures = failures
+	ka.latency = latency
+}
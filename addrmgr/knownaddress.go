@@ -20,6 +20,8 @@ type KnownAddress struct {
 	lastsuccess time.Time
 	tried       bool
 	refs        int // reference count of new buckets
+	failures    int // consecutive failed connections since the last success
+	latency     time.Duration
 }
 
 // NetAddress returns the underlying wire.NetAddress associated with the
@@ -33,14 +35,33 @@ func (ka *KnownAddress) LastAttempt() time.Time {
 	return ka.lastattempt
 }
 
+// LastSuccess returns the last time a connection to the known address
+// completed the version exchange.
+func (ka *KnownAddress) LastSuccess() time.Time {
+	return ka.lastsuccess
+}
+
 // Services returns the services supported by the peer with the known address.
 func (ka *KnownAddress) Services() wire.ServiceFlag {
 	return ka.na.Services
 }
 
+// Failures returns the number of consecutive failed connections to the known
+// address since the last successful one.
+func (ka *KnownAddress) Failures() int {
+	return ka.failures
+}
+
+// Latency returns the smoothed ping latency of the peer with the known
+// address, or zero when it was never measured.
+func (ka *KnownAddress) Latency() time.Duration {
+	return ka.latency
+}
+
 // chance returns the selection probability for a known address.  The priority
 // depends upon how recently the address has been seen, how recently it was last
-// attempted and how often attempts to connect to it have failed.
+// attempted, how often attempts to connect to it have failed in a row and how
+// fast the peer answered pings.
 func (ka *KnownAddress) chance() float64 {
 	now := time.Now()
 	lastAttempt := now.Sub(ka.lastattempt)
@@ -56,11 +77,18 @@ func (ka *KnownAddress) chance() float64 {
 		c *= 0.01
 	}
 
-	// Failed attempts deprioritise.
-	for i := ka.attempts; i > 0; i-- {
+	// Failed attempts deprioritise, and connections failing in a row even
+	// more so since the address is likely gone.
+	for i := ka.attempts + ka.failures; i > 0; i-- {
 		c /= 1.5
 	}
 
+	// Slow peers deprioritise, down to half the chance once their latency
+	// reaches a second.
+	if ka.latency > 0 {
+		c *= float64(time.Second) / float64(time.Second+ka.latency)
+	}
+
 	return c
 }
 
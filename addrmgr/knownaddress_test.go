@@ -47,6 +47,21 @@ func TestChance(t *testing.T) {
 		},
 	}
 
+	// Test case with a failure streak and a slow peer.
+	streak := addrmgr.TstNewKnownAddress(&wire.NetAddress{Timestamp: now.Add(-35 * time.Second)},
+		1, time.Now().Add(-30*time.Minute), time.Now(), false, 0)
+	addrmgr.TstSetKnownAddressQuality(streak, 1, 0)
+	slow := addrmgr.TstNewKnownAddress(&wire.NetAddress{Timestamp: now.Add(-35 * time.Second)},
+		0, time.Now().Add(-30*time.Minute), time.Now(), false, 0)
+	addrmgr.TstSetKnownAddressQuality(slow, 0, time.Second)
+	tests = append(tests, []struct {
+		addr     *addrmgr.KnownAddress
+		expected float64
+	}{
+		{streak, 1 / 1.5 / 1.5},
+		{slow, 0.5},
+	}...)
+
 	err := .0001
 	for i, test := range tests {
 		chance := addrmgr.TstKnownAddressChance(test.addr)
@@ -117,6 +117,25 @@ func IsOnionCatTor(na *wire.NetAddress) bool {
 	return onionCatNet.Contains(na.IP)
 }
 
+// IsTorV3 returns whether or not the passed address is a Tor v3 onion
+// address.  These can only be relayed with the addrv2 message.
+func IsTorV3(na *wire.NetAddress) bool {
+	return na.Network == wire.NetworkTorV3 &&
+		len(na.Addr) == wire.TorV3AddrSize
+}
+
+// IsTor returns whether or not the passed address is a Tor v2 or v3 onion
+// address.
+func IsTor(na *wire.NetAddress) bool {
+	return IsOnionCatTor(na) || IsTorV3(na)
+}
+
+// IsI2P returns whether or not the passed address is an I2P address.  These
+// can only be relayed with the addrv2 message.
+func IsI2P(na *wire.NetAddress) bool {
+	return na.Network == wire.NetworkI2P && len(na.Addr) == wire.I2PAddrSize
+}
+
 // IsRFC1918 returns whether or not the passed address is part of the IPv4
 // private network address space as defined by RFC1918 (10.0.0.0/8,
 // 172.16.0.0/12, or 192.168.0.0/16).
@@ -212,7 +231,12 @@ func IsRFC6598(na *wire.NetAddress) bool {
 // considered invalid under the following circumstances:
 // IPv4: It is either a zero or all bits set address.
 // IPv6: It is either a zero or RFC3849 documentation address.
+// Other: It is not a Tor v3 or I2P address.
 func IsValid(na *wire.NetAddress) bool {
+	if !na.AddrV1Compatible() {
+		return IsTorV3(na) || IsI2P(na)
+	}
+
 	// IsUnspecified returns if address is 0, so only all bits set, and
 	// RFC3849 need to be explicitly checked.
 	return na.IP != nil && !(na.IP.IsUnspecified() ||
@@ -232,8 +256,9 @@ func IsRoutable(na *wire.NetAddress) bool {
 // GroupKey returns a string representing the network group an address is part
 // of.  This is the /16 for IPv4, the /32 (/36 for he.net) for IPv6, the string
 // "local" for a local address, the string "tor:key" where key is the /4 of the
-// onion address for Tor address, and the string "unroutable" for an unroutable
-// address.
+// onion address for Tor address, the string "i2p:key" where key is the /4 of
+// the I2P address for I2P addresses, and the string "unroutable" for an
+// unroutable address.
 func GroupKey(na *wire.NetAddress) string {
 	if IsLocal(na) {
 		return "local"
@@ -241,6 +266,12 @@ func GroupKey(na *wire.NetAddress) string {
 	if !IsRoutable(na) {
 		return "unroutable"
 	}
+	if IsTorV3(na) {
+		return fmt.Sprintf("tor:%d", na.Addr[0]&((1<<4)-1))
+	}
+	if IsI2P(na) {
+		return fmt.Sprintf("i2p:%d", na.Addr[0]&((1<<4)-1))
+	}
 	if IsIPv4(na) {
 		return na.IP.Mask(net.CIDRMask(16, 32)).String()
 	}
@@ -199,4 +199,26 @@ func TestGroupKey(t *testing.T) {
 				key, test.expected)
 		}
 	}
+
+	// Tor v3 and I2P addresses are grouped by the first 4 bits of their
+	// key.
+	addr := make([]byte, wire.TorV3AddrSize)
+	addr[0] = 0x35
+	v2Tests := []struct {
+		na       *wire.NetAddress
+		expected string
+	}{
+		{wire.NewNetAddressV2(wire.NetworkTorV3, addr, 8333, 0), "tor:5"},
+		{wire.NewNetAddressV2(wire.NetworkI2P, addr, 8333, 0), "i2p:5"},
+		{wire.NewNetAddressV2(wire.NetworkTorV3, addr[:8], 8333, 0),
+			"unroutable"},
+		{wire.NewNetAddressV2(wire.NetworkCJDNS, addr[:16], 8333, 0),
+			"unroutable"},
+	}
+	for i, test := range v2Tests {
+		if key := addrmgr.GroupKey(test.na); key != test.expected {
+			t.Errorf("TestGroupKey v2 #%d: unexpected group key "+
+				"- got '%s', want '%s'", i, key, test.expected)
+		}
+	}
 }
@@ -0,0 +1,18 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package bip39 implements the mnemonic sentences the seeds of hierarchical
+deterministic key chains are commonly backed up as (BIP0039).
+
+Instead of a raw seed, wallets usually show their users a mnemonic sentence of
+12 to 24 words to back up and recover the master node with.  The NewEntropy
+function creates random entropy which the NewMnemonic function encodes as such a
+sentence.  The NewSeedFromMnemonic function validates a sentence and derives the
+seed for the hdkeychain.NewMaster function from it and an optional password.
+The EntropyFromMnemonic function recovers the entropy a sentence encodes.
+
+Only the English word list is supported.
+*/
+package bip39
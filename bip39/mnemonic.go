@@ -0,0 +1,210 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bip39
+
+// References:
+//   [BIP39]: BIP0039 - Mnemonic code for generating deterministic keys
+//   https://github.com/bitcoin/bips/blob/master/bip-0039.mediawiki
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// MinEntropyBits is the minimum number of bits of entropy a mnemonic
+	// can encode.
+	MinEntropyBits = 128
+
+	// MaxEntropyBits is the maximum number of bits of entropy a mnemonic
+	// can encode.
+	MaxEntropyBits = 256
+
+	// RecommendedEntropyBits is the recommended number of bits of entropy
+	// for a new mnemonic, which is encoded as 24 words.
+	RecommendedEntropyBits = 256
+
+	// bitsPerWord is the number of bits each word of a mnemonic encodes.
+	bitsPerWord = 11
+
+	// seedIterations is the number of PBKDF2 iterations used to derive a
+	// seed from a mnemonic.
+	seedIterations = 2048
+)
+
+var (
+	// ErrInvalidEntropyLen describes an error in which the provided
+	// entropy is not a multiple of 32 bits in the range
+	// [MinEntropyBits, MaxEntropyBits].
+	ErrInvalidEntropyLen = errors.New("entropy must be a multiple of 32 " +
+		"bits between 128 and 256 bits")
+
+	// ErrInvalidMnemonic describes an error in which the provided
+	// mnemonic has an invalid number of words or contains a word which is
+	// not in the word list.
+	ErrInvalidMnemonic = errors.New("the provided mnemonic is invalid")
+
+	// ErrMnemonicChecksum describes an error in which the checksum
+	// encoded in the provided mnemonic does not match its entropy.
+	ErrMnemonicChecksum = errors.New("the provided mnemonic has an " +
+		"invalid checksum")
+)
+
+// validEntropyBits returns whether the passed number of bits of entropy can be
+// encoded as a mnemonic.
+func validEntropyBits(bits int) bool {
+	return bits >= MinEntropyBits && bits <= MaxEntropyBits && bits%32 == 0
+}
+
+// NewEntropy returns the passed number of bits of cryptographically secure
+// random entropy that can be used as the input for the NewMnemonic function.
+//
+// The number of bits must be a multiple of 32 between 128 and 256.  The
+// recommended number is 256 as defined by the RecommendedEntropyBits constant.
+func NewEntropy(bits int) ([]byte, error) {
+	if !validEntropyBits(bits) {
+		return nil, ErrInvalidEntropyLen
+	}
+
+	entropy := make([]byte, bits/8)
+	_, err := rand.Read(entropy)
+	if err != nil {
+		return nil, err
+	}
+
+	return entropy, nil
+}
+
+// NewMnemonic encodes the passed entropy as a mnemonic sentence of space
+// separated words from the [BIP39] English word list.  Every 32 bits of
+// entropy add 3 words, so 128 bits are encoded as 12 words and 256 bits as 24
+// words.
+func NewMnemonic(entropy []byte) (string, error) {
+	entropyBits := len(entropy) * 8
+	if !validEntropyBits(entropyBits) {
+		return "", ErrInvalidEntropyLen
+	}
+
+	// Per [BIP39], the checksum is made up of the first ENT / 32 bits of
+	// the SHA256 of the entropy and appended to it.  The checksum is at
+	// most 8 bits, so the first byte of the hash is all that is needed.
+	checksum := sha256.Sum256(entropy)
+	data := make([]byte, len(entropy)+1)
+	copy(data, entropy)
+	data[len(entropy)] = checksum[0]
+
+	// Split the bits into groups of 11 bits, each of which is the index of
+	// a word in the word list.
+	numWords := (entropyBits + entropyBits/32) / bitsPerWord
+	words := make([]string, numWords)
+	for i := range words {
+		var index int
+		for j := 0; j < bitsPerWord; j++ {
+			bit := i*bitsPerWord + j
+			index <<= 1
+			index |= int(data[bit/8]>>(7-uint(bit%8))) & 1
+		}
+		words[i] = wordList[index]
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+// EntropyFromMnemonic decodes the entropy encoded in the passed mnemonic
+// sentence and ensures its checksum matches.  This allows a mnemonic to be
+// validated and the entropy it was created from to be recovered.
+func EntropyFromMnemonic(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	totalBits := len(words) * bitsPerWord
+	entropyBits := totalBits * 32 / 33
+	if totalBits%33 != 0 || !validEntropyBits(entropyBits) {
+		return nil, ErrInvalidMnemonic
+	}
+
+	// Reassemble the bits of the entropy and the checksum from the
+	// indexes of the words.
+	data := make([]byte, (totalBits+7)/8)
+	for i, word := range words {
+		index, ok := wordIndex[word]
+		if !ok {
+			return nil, ErrInvalidMnemonic
+		}
+		for j := 0; j < bitsPerWord; j++ {
+			if index&(1<<uint(bitsPerWord-1-j)) == 0 {
+				continue
+			}
+			bit := i*bitsPerWord + j
+			data[bit/8] |= 1 << (7 - uint(bit%8))
+		}
+	}
+
+	// Ensure the checksum bits match the bits of the SHA256 of the entropy.
+	entropy := data[:entropyBits/8]
+	checksumBits := uint(entropyBits / 32)
+	checksum := sha256.Sum256(entropy)
+	mask := byte(0xff) << (8 - checksumBits)
+	if data[len(entropy)]&mask != checksum[0]&mask {
+		return nil, ErrMnemonicChecksum
+	}
+
+	return entropy, nil
+}
+
+// NewSeedFromMnemonic ensures the passed mnemonic sentence is valid and
+// derives the seed for the hdkeychain.NewMaster function from it and the passed
+// optional password as defined by [BIP39].  A different password results in a
+// different seed, and thus in a different key chain.
+func NewSeedFromMnemonic(mnemonic, password string) ([]byte, error) {
+	if _, err := EntropyFromMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+
+	// Per [BIP39], the seed is the PBKDF2-HMAC-SHA512 of the normalized
+	// mnemonic using "mnemonic" followed by the normalized password as the
+	// salt.  The words are joined with single spaces so the seed does not
+	// depend on how they were separated.
+	normalized := norm.NFKD.String(strings.Join(strings.Fields(mnemonic), " "))
+	salt := norm.NFKD.String("mnemonic" + password)
+	return pbkdf2SHA512([]byte(normalized), []byte(salt), seedIterations,
+		sha512.Size), nil
+}
+
+// pbkdf2SHA512 derives a key of the passed length from the password and salt
+// using PBKDF2 with HMAC-SHA512 as the pseudorandom function.
+func pbkdf2SHA512(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha512.New, password)
+	key := make([]byte, 0, keyLen+sha512.Size)
+	var blockNum [4]byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		// U1 = PRF(password, salt || INT(block))
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(blockNum[:], block)
+		prf.Write(blockNum[:])
+		u := prf.Sum(nil)
+
+		// T = U1 ^ U2 ^ ... ^ Uc where Ui = PRF(password, Ui-1)
+		t := make([]byte, len(u))
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+
+	return key[:keyLen]
+}
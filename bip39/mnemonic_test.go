@@ -0,0 +1,230 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bip39
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// hexToBytes converts the passed hex string into bytes and will panic if there
+// is an error.  This is only provided for the hard-coded constants so errors in
+// the source code can be detected.  It will only (and must only) be called with
+// hard-coded values.
+func hexToBytes(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic("invalid hex in source file: " + s)
+	}
+	return b
+}
+
+// TestMnemonicVectors ensures the entropy, mnemonics and seeds of the official
+// BIP0039 test vectors, which all use the password TREZOR, are produced.
+func TestMnemonicVectors(t *testing.T) {
+	tests := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{{
+		entropy:  "00000000000000000000000000000000",
+		mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		seed:     "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+	}, {
+		entropy:  "7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		mnemonic: "legal winner thank year wave sausage worth useful legal winner thank yellow",
+		seed:     "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+	}, {
+		entropy:  "80808080808080808080808080808080",
+		mnemonic: "letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+		seed:     "d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed5511a30fa280f12eb2e47ed2ac03b5c462a0358d18d69fe4f985ec81778c1b370b652a8",
+	}, {
+		entropy:  "ffffffffffffffffffffffffffffffff",
+		mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+		seed:     "ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+	}, {
+		entropy:  "000000000000000000000000000000000000000000000000",
+		mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon agent",
+		seed:     "035895f2f481b1b0f01fcf8c289c794660b289981a78f8106447707fdd9666ca06da5a9a565181599b79f53b844d8a71dd9f439c52a3d7b3e8a79c906ac845fa",
+	}, {
+		entropy:  "0000000000000000000000000000000000000000000000000000000000000000",
+		mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+		seed:     "bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8",
+	}, {
+		entropy:  "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+		seed:     "dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad",
+	}, {
+		entropy:  "9e885d952ad362caeb4efe34a8e91bd2",
+		mnemonic: "ozone drill grab fiber curtain grace pudding thank cruise elder eight picnic",
+		seed:     "274ddc525802f7c828d8ef7ddbcdc5304e87ac3535913611fbbfa986d0c9e5476c91689f9c8a54fd55bd38606aa6a8595ad213d4c9c9f9aca3fb217069a41028",
+	}, {
+		entropy:  "68a79eaca2324873eacc50cb9c6eca8cc68ea5d936f98787c60c7ebc74e6ce7c",
+		mnemonic: "hamster diagram private dutch cause delay private meat slide toddler razor book happy fancy gospel tennis maple dilemma loan word shrug inflict delay length",
+		seed:     "64c87cde7e12ecf6704ab95bb1408bef047c22db4cc7491c4271d170a1b213d20b385bc1588d9c7b38f1b39d415665b8a9030c9ec653d75e65f847d8fc1fc440",
+	}}
+
+	for _, test := range tests {
+		entropy := hexToBytes(test.entropy)
+		mnemonic, err := NewMnemonic(entropy)
+		if err != nil {
+			t.Errorf("NewMnemonic(%s): unexpected error: %v",
+				test.entropy, err)
+			continue
+		}
+		if mnemonic != test.mnemonic {
+			t.Errorf("NewMnemonic(%s): got %q, want %q",
+				test.entropy, mnemonic, test.mnemonic)
+			continue
+		}
+
+		gotEntropy, err := EntropyFromMnemonic(test.mnemonic)
+		if err != nil {
+			t.Errorf("EntropyFromMnemonic(%q): unexpected error: %v",
+				test.mnemonic, err)
+			continue
+		}
+		if !bytes.Equal(gotEntropy, entropy) {
+			t.Errorf("EntropyFromMnemonic(%q): got %x, want %s",
+				test.mnemonic, gotEntropy, test.entropy)
+			continue
+		}
+
+		seed, err := NewSeedFromMnemonic(test.mnemonic, "TREZOR")
+		if err != nil {
+			t.Errorf("NewSeedFromMnemonic(%q): unexpected error: %v",
+				test.mnemonic, err)
+			continue
+		}
+		if hex.EncodeToString(seed) != test.seed {
+			t.Errorf("NewSeedFromMnemonic(%q): got %x, want %s",
+				test.mnemonic, seed, test.seed)
+		}
+	}
+}
+
+// TestSeedFromMnemonic ensures the seed depends on the password but not on how
+// the words of the mnemonic are separated.
+func TestSeedFromMnemonic(t *testing.T) {
+	const mnemonic = "abandon abandon abandon abandon abandon abandon " +
+		"abandon abandon abandon abandon abandon about"
+	const want = "5eb00bbddcf069084889a8ab9155568165f5c453ccb85e70811aaed6f6da5fc19a5ac40b389cd370d086206dec8aa6c43daea6690f20ad3d8d48b2d2ce9e38e4"
+
+	seed, err := NewSeedFromMnemonic(mnemonic, "")
+	if err != nil {
+		t.Fatalf("NewSeedFromMnemonic: unexpected error: %v", err)
+	}
+	if hex.EncodeToString(seed) != want {
+		t.Fatalf("NewSeedFromMnemonic: got %x, want %s", seed, want)
+	}
+
+	spaced := "  " + strings.Replace(mnemonic, " ", " \t ", -1) + "\n"
+	seed, err = NewSeedFromMnemonic(spaced, "")
+	if err != nil {
+		t.Fatalf("NewSeedFromMnemonic: unexpected error: %v", err)
+	}
+	if hex.EncodeToString(seed) != want {
+		t.Fatalf("NewSeedFromMnemonic with extra whitespace: got %x, "+
+			"want %s", seed, want)
+	}
+}
+
+// TestInvalidMnemonics ensures malformed entropy and mnemonics are rejected
+// with the expected errors.
+func TestInvalidMnemonics(t *testing.T) {
+	entropyTests := []struct {
+		name string
+		bits int
+	}{
+		{name: "too short", bits: 96},
+		{name: "too long", bits: 288},
+		{name: "not a multiple of 32 bits", bits: 136},
+	}
+	for _, test := range entropyTests {
+		if _, err := NewEntropy(test.bits); err != ErrInvalidEntropyLen {
+			t.Errorf("NewEntropy %s: got %v, want %v", test.name, err,
+				ErrInvalidEntropyLen)
+		}
+		entropy := make([]byte, test.bits/8)
+		if _, err := NewMnemonic(entropy); err != ErrInvalidEntropyLen {
+			t.Errorf("NewMnemonic %s: got %v, want %v", test.name,
+				err, ErrInvalidEntropyLen)
+		}
+	}
+
+	mnemonicTests := []struct {
+		name     string
+		mnemonic string
+		err      error
+	}{{
+		name:     "empty",
+		mnemonic: "",
+		err:      ErrInvalidMnemonic,
+	}, {
+		name:     "eleven words",
+		mnemonic: "legal winner thank year wave sausage worth useful legal winner thank",
+		err:      ErrInvalidMnemonic,
+	}, {
+		name:     "unknown word",
+		mnemonic: "legal winner thank year wave sausage worth useful legal winner thank yellowish",
+		err:      ErrInvalidMnemonic,
+	}, {
+		name:     "upper case word",
+		mnemonic: "Legal winner thank year wave sausage worth useful legal winner thank yellow",
+		err:      ErrInvalidMnemonic,
+	}, {
+		name:     "bad checksum",
+		mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		err:      ErrMnemonicChecksum,
+	}, {
+		name:     "swapped words",
+		mnemonic: "winner legal thank year wave sausage worth useful legal winner thank yellow",
+		err:      ErrMnemonicChecksum,
+	}}
+	for _, test := range mnemonicTests {
+		if _, err := EntropyFromMnemonic(test.mnemonic); err != test.err {
+			t.Errorf("EntropyFromMnemonic %s: got %v, want %v",
+				test.name, err, test.err)
+		}
+		_, err := NewSeedFromMnemonic(test.mnemonic, "TREZOR")
+		if err != test.err {
+			t.Errorf("NewSeedFromMnemonic %s: got %v, want %v",
+				test.name, err, test.err)
+		}
+	}
+}
+
+// TestNewEntropy ensures new entropy has the requested size, differs between
+// calls and is encoded as the expected number of words.
+func TestNewEntropy(t *testing.T) {
+	for bits := MinEntropyBits; bits <= MaxEntropyBits; bits += 32 {
+		entropy, err := NewEntropy(bits)
+		if err != nil {
+			t.Fatalf("NewEntropy(%d): unexpected error: %v", bits, err)
+		}
+		if len(entropy) != bits/8 {
+			t.Fatalf("NewEntropy(%d): got %d bytes, want %d", bits,
+				len(entropy), bits/8)
+		}
+		other, err := NewEntropy(bits)
+		if err != nil {
+			t.Fatalf("NewEntropy(%d): unexpected error: %v", bits, err)
+		}
+		if bytes.Equal(entropy, other) {
+			t.Fatalf("NewEntropy(%d): got the same entropy twice", bits)
+		}
+
+		mnemonic, err := NewMnemonic(entropy)
+		if err != nil {
+			t.Fatalf("NewMnemonic: unexpected error: %v", err)
+		}
+		if got, want := len(strings.Fields(mnemonic)), bits*3/32; got != want {
+			t.Fatalf("NewMnemonic of %d bits: got %d words, want %d",
+				bits, got, want)
+		}
+	}
+}
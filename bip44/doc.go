@@ -0,0 +1,16 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package bip44 implements the derivation paths of hierarchical deterministic
+extended keys and the multi-account key chain layout (BIP0044).
+
+Keys deeper in the tree are commonly referred to by their derivation path, such
+as m/44'/706'/0'/0/0, where ' marks a hardened child.  The ParsePath function
+parses such a path into the indexes of the children and the DerivePath function
+derives the hdkeychain.ExtendedKey they lead to.  The AccountPath and Path
+functions return the paths of the BIP0044 layout, which uses the HDCoinType of
+the network as the coin type.  The main network coin type is 706.
+*/
+package bip44
@@ -0,0 +1,135 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bip44
+
+// References:
+//   [BIP44]: BIP0044 - Multi-Account Hierarchy for Deterministic Wallets
+//   https://github.com/bitcoin/bips/blob/master/bip-0044.mediawiki
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/czzutil/hdkeychain"
+)
+
+const (
+	// Purpose is the purpose of the [BIP44] key chain layout.  It is the
+	// first, hardened, level of its paths.
+	Purpose = 44
+
+	// ExternalBranch is the [BIP44] branch of an account whose keys are
+	// used for addresses given out to receive payments.
+	ExternalBranch = 0
+
+	// InternalBranch is the [BIP44] branch of an account whose keys are
+	// used for change addresses.
+	InternalBranch = 1
+)
+
+// ErrInvalidPath describes an error in which a derivation path is malformed or
+// contains an index which is out of range.
+var ErrInvalidPath = errors.New("the provided derivation path is invalid")
+
+// ParsePath parses a derivation path of the form m/44'/706'/0'/0/0 into the
+// indexes of its children.  Hardened indexes are marked with a trailing ' or h
+// and returned with hdkeychain.HardenedKeyStart added.  The path m refers to
+// the master node itself and results in no indexes.
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if parts[0] != "m" && parts[0] != "M" {
+		return nil, ErrInvalidPath
+	}
+
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		var offset uint32
+		if strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h") ||
+			strings.HasSuffix(part, "H") {
+
+			offset = hdkeychain.HardenedKeyStart
+			part = part[:len(part)-1]
+		}
+
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || index >= hdkeychain.HardenedKeyStart {
+			return nil, ErrInvalidPath
+		}
+		indexes = append(indexes, offset+uint32(index))
+	}
+
+	return indexes, nil
+}
+
+// FormatPath returns the passed child indexes as a derivation path of the form
+// accepted by ParsePath.  Hardened indexes are marked with a trailing '.
+func FormatPath(indexes []uint32) string {
+	parts := make([]string, 0, len(indexes)+1)
+	parts = append(parts, "m")
+	for _, index := range indexes {
+		if index >= hdkeychain.HardenedKeyStart {
+			parts = append(parts, fmt.Sprintf("%d'",
+				index-hdkeychain.HardenedKeyStart))
+			continue
+		}
+		parts = append(parts, strconv.FormatUint(uint64(index), 10))
+	}
+	return strings.Join(parts, "/")
+}
+
+// AccountPath returns the child indexes of the passed account in the
+// [BIP44] key chain layout of the passed network, which are
+// m/44'/coin_type'/account'.  The coin type is the HDCoinType of the network,
+// which is 706 for the main network.
+func AccountPath(net *chaincfg.Params, account uint32) ([]uint32, error) {
+	if account >= hdkeychain.HardenedKeyStart ||
+		net.HDCoinType >= hdkeychain.HardenedKeyStart {
+
+		return nil, ErrInvalidPath
+	}
+	return []uint32{
+		hdkeychain.HardenedKeyStart + Purpose,
+		hdkeychain.HardenedKeyStart + net.HDCoinType,
+		hdkeychain.HardenedKeyStart + account,
+	}, nil
+}
+
+// Path returns the child indexes of the key with the passed index on the
+// passed branch of an account in the [BIP44] key chain layout of the passed
+// network, which are m/44'/coin_type'/account'/branch/index.  The branch is
+// either ExternalBranch or InternalBranch.
+func Path(net *chaincfg.Params, account, branch, index uint32) ([]uint32, error) {
+	if branch != ExternalBranch && branch != InternalBranch ||
+		index >= hdkeychain.HardenedKeyStart {
+
+		return nil, ErrInvalidPath
+	}
+	path, err := AccountPath(net, account)
+	if err != nil {
+		return nil, err
+	}
+	return append(path, branch, index), nil
+}
+
+// DerivePath returns the extended key reached by successively deriving the
+// children with the passed indexes, starting at the passed extended key.
+// Deriving hardened children requires a private extended key.
+//
+// NOTE: There is an extremely small chance (< 1 in 2^127) deriving any of the
+// children is not possible, in which case the hdkeychain.ErrInvalidChild error
+// is returned and the caller must move on to the next index of that level.
+func DerivePath(key *hdkeychain.ExtendedKey, indexes []uint32) (*hdkeychain.ExtendedKey, error) {
+	for _, index := range indexes {
+		var err error
+		key, err = key.Child(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bip44
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/bip39"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/czzutil/hdkeychain"
+)
+
+const h = hdkeychain.HardenedKeyStart
+
+// TestParsePath ensures derivation paths are parsed into the expected child
+// indexes, formatted back and that malformed paths are rejected.
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path      string
+		indexes   []uint32
+		formatted string
+	}{
+		{path: "m", indexes: []uint32{}, formatted: "m"},
+		{path: "M", indexes: []uint32{}, formatted: "m"},
+		{path: "m/0", indexes: []uint32{0}, formatted: "m/0"},
+		{
+			path:      "m/44'/706'/0'/0/0",
+			indexes:   []uint32{h + 44, h + 706, h, 0, 0},
+			formatted: "m/44'/706'/0'/0/0",
+		},
+		{
+			path:      "m/44h/706H/1h/1/7",
+			indexes:   []uint32{h + 44, h + 706, h + 1, 1, 7},
+			formatted: "m/44'/706'/1'/1/7",
+		},
+		{
+			path:      " m/2147483647'/2147483647 ",
+			indexes:   []uint32{h + h - 1, h - 1},
+			formatted: "m/2147483647'/2147483647",
+		},
+	}
+	for _, test := range tests {
+		indexes, err := ParsePath(test.path)
+		if err != nil {
+			t.Errorf("ParsePath(%q): unexpected error: %v", test.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(indexes, test.indexes) {
+			t.Errorf("ParsePath(%q): got %v, want %v", test.path,
+				indexes, test.indexes)
+			continue
+		}
+		if got := FormatPath(indexes); got != test.formatted {
+			t.Errorf("FormatPath(%v): got %q, want %q", indexes, got,
+				test.formatted)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"44'/706'",
+		"n/44'",
+		"m/",
+		"m//0",
+		"m/44''",
+		"m/-1",
+		"m/x",
+		"m/2147483648",
+		"m/2147483648'",
+		"m/4294967296",
+	}
+	for _, path := range invalid {
+		if _, err := ParsePath(path); err != ErrInvalidPath {
+			t.Errorf("ParsePath(%q): got %v, want %v", path, err,
+				ErrInvalidPath)
+		}
+	}
+}
+
+// TestPath ensures the account and key paths of the main network use its coin
+// type and that out of range accounts, branches and indexes are rejected.
+func TestPath(t *testing.T) {
+	net := &chaincfg.MainNetParams
+
+	path, err := AccountPath(net, 3)
+	if err != nil {
+		t.Fatalf("AccountPath: unexpected error: %v", err)
+	}
+	if got, want := FormatPath(path), "m/44'/706'/3'"; got != want {
+		t.Fatalf("AccountPath: got %s, want %s", got, want)
+	}
+
+	path, err = Path(net, 0, InternalBranch, 5)
+	if err != nil {
+		t.Fatalf("Path: unexpected error: %v", err)
+	}
+	if got, want := FormatPath(path), "m/44'/706'/0'/1/5"; got != want {
+		t.Fatalf("Path: got %s, want %s", got, want)
+	}
+
+	if _, err := AccountPath(net, h); err != ErrInvalidPath {
+		t.Errorf("AccountPath hardened account: got %v, want %v", err,
+			ErrInvalidPath)
+	}
+	tests := []struct {
+		name                   string
+		account, branch, index uint32
+	}{
+		{name: "hardened account", account: h},
+		{name: "unknown branch", branch: 2},
+		{name: "hardened index", index: h},
+	}
+	for _, test := range tests {
+		_, err := Path(net, test.account, test.branch, test.index)
+		if err != ErrInvalidPath {
+			t.Errorf("Path %s: got %v, want %v", test.name, err,
+				ErrInvalidPath)
+		}
+	}
+}
+
+// TestDerivePath ensures the keys of the BIP0044 account and first receiving
+// address of the coin type 0 derived from the well known mnemonic of the
+// BIP0039 test vectors match the ones published for that mnemonic.
+func TestDerivePath(t *testing.T) {
+	seed, err := bip39.NewSeedFromMnemonic("abandon abandon abandon "+
+		"abandon abandon abandon abandon abandon abandon abandon "+
+		"abandon about", "")
+	if err != nil {
+		t.Fatalf("NewSeedFromMnemonic: unexpected error: %v", err)
+	}
+
+	// The main network shares the extended key versions of bitcoin, so
+	// only the coin type has to be changed for the bitcoin vectors.
+	net := chaincfg.MainNetParams
+	net.HDCoinType = 0
+
+	master, err := hdkeychain.NewMaster(seed, &net)
+	if err != nil {
+		t.Fatalf("NewMaster: unexpected error: %v", err)
+	}
+
+	path, err := AccountPath(&net, 0)
+	if err != nil {
+		t.Fatalf("AccountPath: unexpected error: %v", err)
+	}
+	account, err := DerivePath(master, path)
+	if err != nil {
+		t.Fatalf("DerivePath: unexpected error: %v", err)
+	}
+	const wantPriv = "xprv9xpXFhFpqdQK3TmytPBqXtGSwS3DLjojFhTGht8gwAAii8py5X6pxeBnQ6ehJiyJ6nDjWGJfZ95WxByFXVkDxHXrqu53WCRGypk2ttuqncb"
+	if got := account.String(); got != wantPriv {
+		t.Fatalf("account private key: got %s, want %s", got, wantPriv)
+	}
+	accountPub, err := account.Neuter()
+	if err != nil {
+		t.Fatalf("Neuter: unexpected error: %v", err)
+	}
+	const wantPub = "xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj"
+	if got := accountPub.String(); got != wantPub {
+		t.Fatalf("account public key: got %s, want %s", got, wantPub)
+	}
+
+	// The non-hardened levels below the account can be derived from both
+	// the private and the public account key.
+	const wantKey = "03aaeb52dd7494c361049de67cc680e83ebcbbbdbeb13637d92cd845f70308af5e"
+	for _, key := range []*hdkeychain.ExtendedKey{account, accountPub} {
+		child, err := DerivePath(key, []uint32{ExternalBranch, 0})
+		if err != nil {
+			t.Fatalf("DerivePath: unexpected error: %v", err)
+		}
+		pubKey, err := child.ECPubKey()
+		if err != nil {
+			t.Fatalf("ECPubKey: unexpected error: %v", err)
+		}
+		got := hex.EncodeToString(pubKey.SerializeCompressed())
+		if got != wantKey {
+			t.Fatalf("m/44'/0'/0'/0/0: got public key %s, want %s",
+				got, wantKey)
+		}
+	}
+
+	// Hardened children can not be derived from a public key.
+	_, err = DerivePath(accountPub, []uint32{h})
+	if err != hdkeychain.ErrDeriveHardFromPublic {
+		t.Fatalf("DerivePath hardened from public: got %v, want %v", err,
+			hdkeychain.ErrDeriveHardFromPublic)
+	}
+}
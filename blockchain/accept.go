@@ -60,14 +60,24 @@ func (b *BlockChain) maybeAcceptBlock(block *czzutil.Block, flags BehaviorFlags)
 		return false, err
 	}
 
-	// Create a new block node for the block and add it to the node index. Even
-	// if the block ultimately gets connected to the main chain, it starts out
-	// on a side chain.
-	blockHeader := &block.MsgBlock().Header
-	newNode := newBlockNode(blockHeader, prevNode)
-	newNode.status = statusDataStored
+	// Keep the block deserialized since it is about to be connected and
+	// requested by the peers.
+	b.blockCache.Add(block)
 
-	b.index.AddNode(newNode)
+	// Create a new block node for the block and add it to the node index,
+	// unless its header was already processed by ProcessHeaders, in which
+	// case its node only lacked the block data. Even if the block
+	// ultimately gets connected to the main chain, it starts out on a side
+	// chain.  The block passed the sanity checks before it was accepted.
+	newNode := b.index.LookupNode(block.Hash())
+	if newNode == nil {
+		blockHeader := &block.MsgBlock().Header
+		newNode = newBlockNode(blockHeader, prevNode)
+		newNode.status = statusDataStored | statusSanityChecked
+		b.index.AddNode(newNode)
+	} else {
+		b.index.SetStatusFlags(newNode, statusDataStored|statusSanityChecked)
+	}
 	err = b.index.flushToDB()
 	if err != nil {
 		return false, err
@@ -0,0 +1,261 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"container/list"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/cross"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// MaxActivityWindow is the largest number of blocks ChainActivity sums the
+// activity of.  The activity of that many blocks is kept in memory, so the
+// windows ending near the best block are summed without reading the blocks.
+const MaxActivityWindow = 20160
+
+// EntangleVolume is the number and the total amount of the entangle outputs
+// of a chain the coins are entangled from.
+type EntangleVolume struct {
+	Count  int64
+	Amount *big.Int
+}
+
+// blockActivity is the transaction and entangle activity of a block.
+type blockActivity struct {
+	hash      chainhash.Hash
+	numTxns   int64
+	size      int64
+	fees      int64
+	entangled map[cross.ExpandedTxType]EntangleVolume
+}
+
+// computeBlockActivity returns the activity of the passed block from the
+// passed outputs it spends, which must be in the order of the spend journal.
+// The coinbase transaction is not counted as a transaction since it pays no
+// fee.
+func computeBlockActivity(block *czzutil.Block, stxos []SpentTxOut) (*blockActivity, error) {
+	if len(stxos) != countSpentOutputs(block) {
+		return nil, AssertError(fmt.Sprintf("computeBlockActivity called "+
+			"with %d spent outputs for a block spending %d", len(stxos),
+			countSpentOutputs(block)))
+	}
+
+	activity := &blockActivity{
+		hash:      *block.Hash(),
+		entangled: make(map[cross.ExpandedTxType]EntangleVolume),
+	}
+	stxoIdx := 0
+	for _, tx := range block.Transactions()[1:] {
+		msgTx := tx.MsgTx()
+		activity.numTxns++
+		activity.size += int64(msgTx.SerializeSize())
+		for range msgTx.TxIn {
+			activity.fees += stxos[stxoIdx].Amount
+			stxoIdx++
+		}
+		for _, txOut := range msgTx.TxOut {
+			activity.fees -= txOut.Value
+		}
+
+		infos, err := cross.IsEntangleTx(msgTx)
+		if err != nil {
+			continue
+		}
+		for _, info := range infos {
+			volume := activity.entangled[info.ExTxType]
+			if volume.Amount == nil {
+				volume.Amount = new(big.Int)
+			}
+			volume.Count++
+			volume.Amount.Add(volume.Amount, info.Amount)
+			activity.entangled[info.ExTxType] = volume
+		}
+	}
+	return activity, nil
+}
+
+// activityCache is a concurrency safe cache of the activity of the most
+// recently connected or summed blocks, limited to a number of blocks with
+// eviction of the least recently used one.
+type activityCache struct {
+	mtx    sync.Mutex
+	blocks map[chainhash.Hash]*list.Element // nearly O(1) lookups
+	lru    *list.List                       // O(1) insert, update, delete
+	limit  int
+}
+
+// newActivityCache returns a new activity cache limited to the passed number
+// of blocks.
+func newActivityCache(limit int) *activityCache {
+	return &activityCache{
+		blocks: make(map[chainhash.Hash]*list.Element),
+		lru:    list.New(),
+		limit:  limit,
+	}
+}
+
+// Lookup returns the cached activity of the block with the passed hash, or nil
+// when it is not cached.
+//
+// This function is safe for concurrent access.
+func (c *activityCache) Lookup(hash *chainhash.Hash) *blockActivity {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	elem, ok := c.blocks[*hash]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*blockActivity)
+}
+
+// Add adds the passed block activity to the cache as the most recently used
+// one, evicting the least recently used one when the limit is exceeded.
+//
+// This function is safe for concurrent access.
+func (c *activityCache) Add(activity *blockActivity) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if elem, ok := c.blocks[activity.hash]; ok {
+		c.lru.MoveToFront(elem)
+		return
+	}
+	if c.lru.Len() >= c.limit {
+		elem := c.lru.Back()
+		delete(c.blocks, elem.Value.(*blockActivity).hash)
+		c.lru.Remove(elem)
+	}
+	c.blocks[activity.hash] = c.lru.PushFront(activity)
+}
+
+// ChainActivity is the transaction and entangle activity of a window of blocks
+// of the main chain.
+type ChainActivity struct {
+	// EndHash and EndHeight identify the last block of the window.
+	EndHash   chainhash.Hash
+	EndHeight int32
+
+	// NumBlocks is the number of blocks of the window.
+	NumBlocks int32
+
+	// Interval is the time between the timestamps of the last block of the
+	// window and of the block before its first one.
+	Interval time.Duration
+
+	// NumTxns, Size and Fees are the number, the total size in bytes and
+	// the total fee of the transactions of the window, excluding the
+	// coinbase transactions.
+	NumTxns int64
+	Size    int64
+	Fees    int64
+
+	// Entangled is the volume of the entangle outputs of the window for
+	// each chain the coins were entangled from.
+	Entangled map[cross.ExpandedTxType]EntangleVolume
+}
+
+// blockActivityByNode returns the activity of the block of the passed node
+// from the activity cache, or computes it from the block and its spend journal
+// using an existing database transaction and adds it to the cache.
+func (b *BlockChain) blockActivityByNode(dbTx database.Tx, node *blockNode) (*blockActivity, error) {
+	if activity := b.activityCache.Lookup(&node.hash); activity != nil {
+		return activity, nil
+	}
+
+	// The block is not added to the block cache since a long window would
+	// evict the recent blocks the peers are requesting.
+	block, err := dbFetchBlockByNode(dbTx, node)
+	if err != nil {
+		return nil, err
+	}
+	stxos, err := dbFetchSpendJournalEntry(dbTx, block)
+	if err != nil {
+		return nil, err
+	}
+	activity, err := computeBlockActivity(block, stxos)
+	if err != nil {
+		return nil, err
+	}
+	b.activityCache.Add(activity)
+	return activity, nil
+}
+
+// ChainActivity returns the activity of the window of the passed number of
+// blocks of the main chain ending with the block of the passed hash, or with
+// the best block when the hash is nil.  The window stops at the genesis block,
+// whose activity is not counted since its outputs can not be spent.
+//
+// The activity of the blocks is recorded as they are connected, so only the
+// windows reaching further back than the most recent MaxActivityWindow blocks
+// read blocks from the database.  The blocks of a pruned chain must not have
+// been pruned.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ChainActivity(endHash *chainhash.Hash, numBlocks int32) (*ChainActivity, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	endNode := b.bestChain.Tip()
+	if endHash != nil {
+		endNode = b.index.LookupNode(endHash)
+		if endNode == nil || !b.bestChain.Contains(endNode) {
+			str := fmt.Sprintf("block %s is not in the main chain",
+				endHash)
+			return nil, errNotInMainChain(str)
+		}
+	}
+	if numBlocks < 0 || numBlocks > MaxActivityWindow {
+		return nil, fmt.Errorf("the window must be between 0 and %d "+
+			"blocks", MaxActivityWindow)
+	}
+	if numBlocks > endNode.height {
+		numBlocks = endNode.height
+	}
+
+	result := &ChainActivity{
+		EndHash:   endNode.hash,
+		EndHeight: endNode.height,
+		NumBlocks: numBlocks,
+		Entangled: make(map[cross.ExpandedTxType]EntangleVolume),
+	}
+	startNode := endNode.Ancestor(endNode.height - numBlocks)
+	result.Interval = time.Duration(endNode.timestamp-startNode.timestamp) *
+		time.Second
+
+	err := b.db.View(func(dbTx database.Tx) error {
+		for node := endNode; node != startNode; node = node.parent {
+			activity, err := b.blockActivityByNode(dbTx, node)
+			if err != nil {
+				return err
+			}
+			result.NumTxns += activity.numTxns
+			result.Size += activity.size
+			result.Fees += activity.fees
+			for exTxType, volume := range activity.entangled {
+				total := result.Entangled[exTxType]
+				if total.Amount == nil {
+					total.Amount = new(big.Int)
+				}
+				total.Count += volume.Count
+				total.Amount.Add(total.Amount, volume.Amount)
+				result.Entangled[exTxType] = total
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
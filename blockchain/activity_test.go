@@ -0,0 +1,98 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/cross"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// TestBlockActivity ensures the activity of a block counts the fees and the
+// entangle outputs of its transactions but not its coinbase, and that the
+// activity cache evicts the least recently used block.
+func TestBlockActivity(t *testing.T) {
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+	})
+	coinbase.AddTxOut(wire.NewTxOut(5000, opTrueScript))
+
+	entangleScript, err := txscript.NewEntangleScript(&txscript.EntangleData{
+		ExTxType:  txscript.EntangleTypeLtc,
+		Amount:    big.NewInt(300),
+		ExtTxHash: []byte(strings.Repeat("ab", 32)),
+	})
+	if err != nil {
+		t.Fatalf("NewEntangleScript: unexpected error: %v", err)
+	}
+	entangle := wire.NewMsgTx(1)
+	entangle.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{0x01}},
+	})
+	entangle.AddTxOut(wire.NewTxOut(0, entangleScript))
+	entangle.AddTxOut(wire.NewTxOut(900, opTrueScript))
+
+	spend := wire.NewMsgTx(1)
+	spend.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{0x02}},
+	})
+	spend.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{0x03}},
+	})
+	spend.AddTxOut(wire.NewTxOut(1500, opTrueScript))
+
+	block := czzutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase, entangle, spend},
+	})
+	stxos := []SpentTxOut{{Amount: 1000}, {Amount: 1000}, {Amount: 520}}
+
+	activity, err := computeBlockActivity(block, stxos)
+	if err != nil {
+		t.Fatalf("computeBlockActivity: unexpected error: %v", err)
+	}
+	if activity.numTxns != 2 || activity.fees != 120 ||
+		activity.size != int64(entangle.SerializeSize()+spend.SerializeSize()) {
+
+		t.Errorf("computeBlockActivity: got %d transactions of %d "+
+			"bytes paying %d", activity.numTxns, activity.size,
+			activity.fees)
+	}
+	volume := activity.entangled[cross.ExpandedTxEntangle_Ltc]
+	if len(activity.entangled) != 1 || volume.Count != 1 ||
+		volume.Amount.Int64() != 300 {
+
+		t.Errorf("computeBlockActivity: unexpected entangle volume %v",
+			activity.entangled)
+	}
+
+	if _, err := computeBlockActivity(block, stxos[:2]); err == nil {
+		t.Errorf("computeBlockActivity: no error for missing outputs")
+	}
+
+	cache := newActivityCache(2)
+	for i := byte(1); i <= 3; i++ {
+		if i == 3 {
+			// Using the first block makes the second one the least
+			// recently used.
+			cache.Lookup(&chainhash.Hash{1})
+		}
+		cache.Add(&blockActivity{hash: chainhash.Hash{i}})
+	}
+	for i, want := range []bool{true, false, true} {
+		cached := cache.Lookup(&chainhash.Hash{byte(i + 1)}) != nil
+		if cached != want {
+			t.Errorf("block %d: got cached %v, want %v", i+1, cached,
+				want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// DefaultBlockCacheSize is the default number of the most recent blocks kept
+// deserialized in memory.
+const DefaultBlockCacheSize = 10
+
+// blockCache is a concurrency safe cache of the most recently connected or
+// requested blocks, limited to a number of blocks with eviction of the least
+// recently used one.  It spares the database reads and deserializations of the
+// blocks requested the most, such as the new tip requested by every peer or the
+// blocks disconnected by a reorganization.
+//
+// The cached blocks are shared by all the callers, so they must not be
+// modified.  The lazily computed hashes, wrapped transactions and
+// serialization of a block are all generated before it is added, so that
+// reading it from several goroutines does not race.
+type blockCache struct {
+	mtx    sync.Mutex
+	blocks map[chainhash.Hash]*list.Element // nearly O(1) lookups
+	lru    *list.List                       // O(1) insert, update, delete
+	limit  int
+}
+
+// newBlockCache returns a new block cache limited to the passed number of
+// blocks.  A cache with a limit of zero holds no block.
+func newBlockCache(limit int) *blockCache {
+	return &blockCache{
+		blocks: make(map[chainhash.Hash]*list.Element),
+		lru:    list.New(),
+		limit:  limit,
+	}
+}
+
+// Lookup returns the cached block with the passed hash, or nil when it is not
+// cached.  A found block becomes the most recently used one.
+//
+// This function is safe for concurrent access.
+func (c *blockCache) Lookup(hash *chainhash.Hash) *czzutil.Block {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	elem, ok := c.blocks[*hash]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*czzutil.Block)
+}
+
+// Add adds the passed block with its height set to the cache as the most
+// recently used one, evicting the least recently used block when the limit is
+// exceeded.
+//
+// This function is safe for concurrent access.
+func (c *blockCache) Add(block *czzutil.Block) {
+	if c.limit <= 0 {
+		return
+	}
+
+	// Generate the cached values of the block outside of the lock.
+	block.Hash()
+	for _, tx := range block.Transactions() {
+		tx.Hash()
+	}
+	if _, err := block.Bytes(); err != nil {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if elem, ok := c.blocks[*block.Hash()]; ok {
+		c.lru.MoveToFront(elem)
+		return
+	}
+	if c.lru.Len() >= c.limit {
+		elem := c.lru.Back()
+		delete(c.blocks, *elem.Value.(*czzutil.Block).Hash())
+		c.lru.Remove(elem)
+	}
+	c.blocks[*block.Hash()] = c.lru.PushFront(block)
+}
+
+// Remove removes the block with the passed hash from the cache, if present.
+//
+// This function is safe for concurrent access.
+func (c *blockCache) Remove(hash *chainhash.Hash) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if elem, ok := c.blocks[*hash]; ok {
+		c.lru.Remove(elem)
+		delete(c.blocks, *hash)
+	}
+}
+
+// fetchBlockByNode returns the block of the passed node from the block cache,
+// or uses an existing database transaction to retrieve it and adds it to the
+// cache.
+func (b *BlockChain) fetchBlockByNode(dbTx database.Tx, node *blockNode) (*czzutil.Block, error) {
+	if block := b.blockCache.Lookup(&node.hash); block != nil {
+		return block, nil
+	}
+	block, err := dbFetchBlockByNode(dbTx, node)
+	if err != nil {
+		return nil, err
+	}
+	b.blockCache.Add(block)
+	return block, nil
+}
+
+// FetchBlock returns the stored block with the passed hash, whether it is in
+// the main chain or not, from the cache of the most recent blocks or from the
+// database.  The returned block is shared with the other callers and must not
+// be modified.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) FetchBlock(hash *chainhash.Hash) (*czzutil.Block, error) {
+	if block := b.blockCache.Lookup(hash); block != nil {
+		return block, nil
+	}
+
+	// Blocks which are not in the block index are not cached since their
+	// height is unknown.
+	node := b.index.LookupNode(hash)
+	var block *czzutil.Block
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		if node != nil {
+			block, err = b.fetchBlockByNode(dbTx, node)
+			return err
+		}
+		blockBytes, err := dbTx.FetchBlock(hash)
+		if err != nil {
+			return err
+		}
+		block, err = czzutil.NewBlockFromBytes(blockBytes)
+		return err
+	})
+	return block, err
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// TestBlockCache ensures the block cache evicts the least recently used block
+// once it holds its limit of blocks.
+func TestBlockCache(t *testing.T) {
+	blocks := make([]*czzutil.Block, 4)
+	for i := range blocks {
+		blocks[i] = czzutil.NewBlock(&wire.MsgBlock{
+			Header: wire.BlockHeader{Nonce: uint64(i)},
+		})
+		blocks[i].SetHeight(int32(i))
+	}
+
+	// A cache without a limit holds no block.
+	c := newBlockCache(0)
+	c.Add(blocks[0])
+	if c.Lookup(blocks[0].Hash()) != nil {
+		t.Fatal("Lookup: found a block in a cache without a limit")
+	}
+
+	c = newBlockCache(3)
+	for _, block := range blocks[:3] {
+		c.Add(block)
+	}
+	for _, block := range blocks[:3] {
+		if got := c.Lookup(block.Hash()); got != block {
+			t.Fatalf("Lookup: block %d is not cached", block.Height())
+		}
+	}
+
+	// Looking block 0 up again makes block 1 the least recently used one,
+	// which is evicted to make room for block 3.
+	c.Lookup(blocks[0].Hash())
+	c.Add(blocks[3])
+	if c.Lookup(blocks[1].Hash()) != nil {
+		t.Fatal("Lookup: the least recently used block was not evicted")
+	}
+	for _, i := range []int{0, 2, 3} {
+		if c.Lookup(blocks[i].Hash()) == nil {
+			t.Fatalf("Lookup: block %d was evicted", i)
+		}
+	}
+
+	// Adding a cached block again keeps a single entry for it.
+	c.Add(blocks[3])
+	if c.lru.Len() != 3 || len(c.blocks) != 3 {
+		t.Fatalf("Add: got %d blocks in the list and %d in the map, "+
+			"want 3", c.lru.Len(), len(c.blocks))
+	}
+
+	c.Remove(blocks[2].Hash())
+	if c.Lookup(blocks[2].Hash()) != nil {
+		t.Fatal("Lookup: found a removed block")
+	}
+	if c.lru.Len() != 2 || len(c.blocks) != 2 {
+		t.Fatalf("Remove: got %d blocks in the list and %d in the map, "+
+			"want 2", c.lru.Len(), len(c.blocks))
+	}
+}
+
+// TestBlockCacheConcurrentReaders ensures a block created from serialized
+// bytes, which deserializes its transactions lazily, can be read from several
+// goroutines at the same time once it is cached.  It is meant to be run with
+// the race detector.
+func TestBlockCacheConcurrentReaders(t *testing.T) {
+	msgBlock := wire.MsgBlock{
+		Header: chaincfg.MainNetParams.GenesisBlock.Header,
+	}
+	coinbase := chaincfg.MainNetParams.GenesisBlock.Transactions[0]
+	for i := 0; i < 8; i++ {
+		tx := coinbase.Copy()
+		tx.LockTime = uint32(i)
+		msgBlock.AddTransaction(tx)
+	}
+	var buf bytes.Buffer
+	if err := msgBlock.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	block, err := czzutil.NewBlockFromBytes(buf.Bytes())
+	if err != nil {
+		t.Fatalf("NewBlockFromBytes: unexpected error: %v", err)
+	}
+
+	c := newBlockCache(1)
+	c.Add(block)
+
+	const readers = 8
+	var wg sync.WaitGroup
+	errs := make(chan string, readers)
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			block := c.Lookup(block.Hash())
+			if block == nil {
+				errs <- "Lookup: block is not cached"
+				return
+			}
+			if block.Height() != czzutil.BlockHeightUnknown {
+				errs <- "Height: the height of the block changed"
+				return
+			}
+			txns := block.Transactions()
+			msgTxns := block.MsgBlock().Transactions
+			if len(txns) != len(msgBlock.Transactions) ||
+				len(msgTxns) != len(msgBlock.Transactions) {
+
+				errs <- "Transactions: wrong number of transactions"
+				return
+			}
+			for i, tx := range txns {
+				want := msgBlock.Transactions[i].TxHash()
+				if *tx.Hash() != want || msgTxns[i] != tx.MsgTx() ||
+					tx.MsgTx().LockTime != uint32(i) {
+
+					errs <- "Transactions: wrong transaction"
+					return
+				}
+			}
+			serialized, err := block.Bytes()
+			if err != nil || !bytes.Equal(serialized, buf.Bytes()) {
+				errs <- "Bytes: wrong serialized block"
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
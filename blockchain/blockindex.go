@@ -33,6 +33,12 @@ const (
 	// has failed validation, thus the block is also invalid.
 	statusInvalidAncestor
 
+	// statusSanityChecked indicates that the block passed the sanity checks
+	// when it was stored.  The blocks stored by the versions which did not
+	// record it are checked again before they are connected, since their
+	// sanity checks may have been different.
+	statusSanityChecked
+
 	// statusNone indicates that the block has no validation state flags set.
 	//
 	// NOTE: This must be defined last in order to avoid influencing iota.
@@ -60,6 +66,12 @@ func (status blockStatus) KnownInvalid() bool {
 	return status&(statusValidateFailed|statusInvalidAncestor) != 0
 }
 
+// SanityChecked returns whether the block passed the sanity checks of the
+// current version when it was stored.
+func (status blockStatus) SanityChecked() bool {
+	return status&statusSanityChecked != 0
+}
+
 // blockNode represents a block within the block chain and is primarily used to
 // aid in selecting the best chain to be the main chain.  The main chain is
 // stored into the block database.
@@ -247,11 +259,14 @@ func newBlockIndex(db database.DB, chainParams *chaincfg.Params) *blockIndex {
 }
 
 // HaveBlock returns whether or not the block index contains the provided hash.
+// The nodes of the headers processed by ProcessHeaders ahead of their blocks,
+// which have no status yet, do not count.
 //
 // This function is safe for concurrent access.
 func (bi *blockIndex) HaveBlock(hash *chainhash.Hash) bool {
 	bi.RLock()
-	_, hasBlock := bi.index[*hash]
+	node, hasBlock := bi.index[*hash]
+	hasBlock = hasBlock && node.status != statusNone
 	bi.RUnlock()
 	return hasBlock
 }
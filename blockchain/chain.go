@@ -10,6 +10,7 @@ import (
 	"container/list"
 	"fmt"
 	"github.com/bourbaki-czz/classzz/cross"
+	"github.com/bourbaki-czz/classzz/czzec"
 	"github.com/bourbaki-czz/classzz/rpcclient"
 	"math"
 	"sync"
@@ -18,6 +19,7 @@ import (
 	"github.com/bourbaki-czz/classzz/chaincfg"
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
 	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/lockstat"
 	"github.com/bourbaki-czz/classzz/txscript"
 	"github.com/bourbaki-czz/classzz/wire"
 	"github.com/bourbaki-czz/czzutil"
@@ -69,12 +71,18 @@ type BestState struct {
 	BlockSize  uint64         // The size of the block.
 	NumTxns    uint64         // The number of txns in the block.
 	TotalTxns  uint64         // The total number of txns in the chain.
+	TotalSize  uint64         // The total size of the stored blocks of the chain.
 	MedianTime time.Time      // Median time as per CalcPastMedianTime.
+
+	// Sequence is incremented each time the best block changes, so caches of
+	// chain state can cheaply tell whether they are stale.  It is not
+	// stored and starts from zero each time the chain is loaded.
+	Sequence uint64
 }
 
 // newBestState returns a new best stats instance for the given parameters.
 func newBestState(node *blockNode, blockSize, numTxns,
-	totalTxns uint64, medianTime time.Time) *BestState {
+	totalTxns, totalSize uint64, medianTime time.Time) *BestState {
 
 	return &BestState{
 		Hash:       node.hash,
@@ -83,6 +91,7 @@ func newBestState(node *blockNode, blockSize, numTxns,
 		BlockSize:  blockSize,
 		NumTxns:    numTxns,
 		TotalTxns:  totalTxns,
+		TotalSize:  totalSize,
 		MedianTime: medianTime,
 	}
 }
@@ -121,6 +130,7 @@ type BlockChain struct {
 	sigCache            *txscript.SigCache
 	indexManager        IndexManager
 	hashCache           *txscript.HashCache
+	scriptCache         *scriptCache
 	excessiveBlockSize  uint32
 
 	// The following fields are calculated based upon the provided chain
@@ -130,10 +140,11 @@ type BlockChain struct {
 	minRetargetTimespan int64 // target timespan / adjustment factor
 	maxRetargetTimespan int64 // target timespan * adjustment factor
 	blocksPerRetarget   int32 // target timespan / target time per block
+	scriptFlagSchedule  []scriptFlagActivation
 
 	// chainLock protects concurrent access to the vast majority of the
 	// fields in this struct below this point.
-	chainLock sync.RWMutex
+	chainLock lockstat.RWMutex
 
 	// These fields are related to the memory block index.  They both have
 	// their own locks, however they are often also protected by the chain
@@ -153,6 +164,18 @@ type BlockChain struct {
 	// to help prevent logic races when blocks are being processed.
 	utxoCache *utxoCache
 
+	// blockCache holds the most recently connected or requested blocks.  It
+	// has its own lock.
+	blockCache *blockCache
+
+	// activityCache holds the transaction and entangle activity of the most
+	// recently connected or summed blocks.  It has its own lock.
+	activityCache *activityCache
+
+	// utxoPrefetch is the prefetch of the utxos spent by the block being
+	// processed, if any.  It is protected by the chain lock.
+	utxoPrefetch *utxoPrefetch
+
 	// orphanLock protects the fields related to handling of orphan blocks.
 	// They are protected by a combination of the chain lock and the orphan lock.
 	orphanLock   sync.RWMutex
@@ -224,10 +247,29 @@ type BlockChain struct {
 	// fastSyncDataDir is the directory used to download the UTXO set.
 	fastSyncDataDir string
 
+	// persistTxMeta is set to store the transaction metadata of each block
+	// when it is connected.
+	persistTxMeta bool
+
+	// fetchUtxoSnapshot fetches the UTXO set from peers in fast sync mode.
+	fetchUtxoSnapshot func(checkpoint *chaincfg.Checkpoint, dir string) (string, error)
+
 	// fastSyncDone chan is used to signal that the UTXO set download has
 	// finished.
 	fastSyncDone chan struct{}
 
+	// utxoSet is the multiset of the utxo set as of the end of the main
+	// chain, which is used to check the utxo commitments of the blocks.  It
+	// is only kept once the utxo commitment deployment is locked in, see
+	// trackUtxoSet, and is nil before then and while the utxo set is
+	// unknown.  It is protected by the chain lock.
+	utxoSet *czzec.Multiset
+
+	// utxoSetUnknown is set while the utxo set is being fast synced, in
+	// which case the multiset of the utxo set can't be computed from the
+	// database.  It is protected by the chain lock.
+	utxoSetUnknown bool
+
 	//
 	entangleVerify *cross.EntangleVerify
 }
@@ -281,6 +323,20 @@ func (b *BlockChain) IsPruned() bool {
 	return b.isPruned
 }
 
+// PruneHeight returns the height of the lowest block in the main chain whose
+// data is guaranteed to be available.  The data of the blocks below it may have
+// been deleted by pruning.  It is zero when the chain was never pruned.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) PruneHeight() int32 {
+	var height uint32
+	b.db.View(func(dbTx database.Tx) error {
+		height = dbFetchPruneHeight(dbTx)
+		return nil
+	})
+	return int32(height)
+}
+
 // FastSyncDoneChan returns a channel which signals that the fastsync UTXO download
 // has finished.
 func (b *BlockChain) FastSyncDoneChan() <-chan struct{} {
@@ -300,12 +356,18 @@ func (b *BlockChain) AddHeader(header *wire.BlockHeader) error {
 	b.index.AddNode(node)
 	b.index.SetStatusFlags(node, statusValid)
 	b.bestChain.SetTip(node)
-	b.stateSnapshot = newBestState(node, 0, 0, 0, node.CalcPastMedianTime())
+	// No transactions are added to the chain by a header.
+	state := newBestState(node, 0, 0, b.stateSnapshot.TotalTxns,
+		b.stateSnapshot.TotalSize, node.CalcPastMedianTime())
+	state.Sequence = b.stateSnapshot.Sequence + 1
+	b.stateSnapshot = state
 
 	// Atomically insert info into the database.
 	err := b.db.Update(func(dbTx database.Tx) error {
 		// Update best block state.
-		if err := dbPutBestState(dbTx, b.stateSnapshot, node.workSum); err != nil {
+		err := dbPutBestState(dbTx, b.stateSnapshot, node.workSum,
+			b.utxoSet)
+		if err != nil {
 			return err
 		}
 
@@ -711,16 +773,32 @@ func (b *BlockChain) connectBlock(node *blockNode, block *czzutil.Block,
 	// database and later memory if all database updates are successful.
 	b.stateLock.RLock()
 	curTotalTxns := b.stateSnapshot.TotalTxns
+	curTotalSize := b.stateSnapshot.TotalSize
 	b.stateLock.RUnlock()
 	numTxns := uint64(len(block.MsgBlock().Transactions))
 	blockSize := uint64(block.MsgBlock().SerializeSize())
 	state := newBestState(node, blockSize, numTxns,
-		curTotalTxns+numTxns, node.CalcPastMedianTime())
+		curTotalTxns+numTxns, curTotalSize+blockSize,
+		node.CalcPastMedianTime())
+
+	// The multiset of the utxo set is only kept once the utxo commitment
+	// deployment is locked in.  It is computed from the database below when
+	// this block locks the deployment in.
+	trackUtxoSet, err := b.trackUtxoSet(node)
+	if err != nil {
+		return err
+	}
+	var utxoSet *czzec.Multiset
+	if trackUtxoSet && b.utxoSet != nil {
+		utxoSet = copyMultiset(b.utxoSet)
+		connectUtxoSet(utxoSet, block, stxos)
+	}
+	computeUtxoSet := trackUtxoSet && utxoSet == nil && !b.utxoSetUnknown
 
 	// Atomically insert info into the database.
 	err = b.db.Update(func(dbTx database.Tx) error {
 		// Update best block state.
-		err := dbPutBestState(dbTx, state, node.workSum)
+		err := dbPutBestState(dbTx, state, node.workSum, utxoSet)
 		if err != nil {
 			return err
 		}
@@ -739,6 +817,19 @@ func (b *BlockChain) connectBlock(node *blockNode, block *czzutil.Block,
 			return err
 		}
 
+		// Store the transaction metadata of the block for the block
+		// explorers, if configured.
+		if b.persistTxMeta {
+			metas, err := computeBlockTxMeta(block, stxos)
+			if err != nil {
+				return err
+			}
+			err = dbPutBlockTxMeta(dbTx, block.Hash(), metas)
+			if err != nil {
+				return err
+			}
+		}
+
 		// Allow the index manager to call each of the currently active
 		// optional indexes with the block being connected so they can
 		// update themselves accordingly.
@@ -755,6 +846,13 @@ func (b *BlockChain) connectBlock(node *blockNode, block *czzutil.Block,
 		return err
 	}
 
+	// Record the activity of the block while its spent outputs are at hand
+	// so the activity of the recent windows is summed without reading the
+	// blocks back.
+	if activity, err := computeBlockActivity(block, stxos); err == nil {
+		b.activityCache.Add(activity)
+	}
+
 	// Commit all modifications made to the view into the utxo state.  This also
 	// prunes these changes from the view.
 	b.stateLock.Lock()
@@ -765,6 +863,7 @@ func (b *BlockChain) connectBlock(node *blockNode, block *czzutil.Block,
 
 	// This node is now the end of the best chain.
 	b.bestChain.SetTip(node)
+	b.utxoSet = utxoSet
 
 	// Update the state for the best block.  Notice how this replaces the
 	// entire struct instead of updating the existing one.  This effectively
@@ -772,6 +871,7 @@ func (b *BlockChain) connectBlock(node *blockNode, block *czzutil.Block,
 	// freely without needing to hold a lock for the duration.  See the
 	// comments on the state variable for more details.
 	b.stateLock.Lock()
+	state.Sequence = b.stateSnapshot.Sequence + 1
 	b.stateSnapshot = state
 	b.stateLock.Unlock()
 
@@ -803,6 +903,10 @@ func (b *BlockChain) connectBlock(node *blockNode, block *czzutil.Block,
 			flushMode = FlushRequired
 		}
 	}
+	if computeUtxoSet {
+		b.utxoSet, err = b.calcUtxoSet(state, nil)
+		return err
+	}
 	return b.utxoCache.Flush(flushMode, state)
 }
 
@@ -822,7 +926,7 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *czzutil.Block, view
 	var prevBlock *czzutil.Block
 	err := b.db.View(func(dbTx database.Tx) error {
 		var err error
-		prevBlock, err = dbFetchBlockByNode(dbTx, prevNode)
+		prevBlock, err = b.fetchBlockByNode(dbTx, prevNode)
 		return err
 	})
 	if err != nil {
@@ -839,16 +943,40 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *czzutil.Block, view
 	// database and later memory if all database updates are successful.
 	b.stateLock.RLock()
 	curTotalTxns := b.stateSnapshot.TotalTxns
+	curTotalSize := b.stateSnapshot.TotalSize
 	b.stateLock.RUnlock()
 	numTxns := uint64(len(prevBlock.MsgBlock().Transactions))
 	blockSize := uint64(prevBlock.MsgBlock().SerializeSize())
 	newTotalTxns := curTotalTxns - uint64(len(block.MsgBlock().Transactions))
+	newTotalSize := curTotalSize - uint64(block.MsgBlock().SerializeSize())
 	state := newBestState(prevNode, blockSize, numTxns,
-		newTotalTxns, prevNode.CalcPastMedianTime())
+		newTotalTxns, newTotalSize, prevNode.CalcPastMedianTime())
+
+	// The multiset of the utxo set is dropped when the parent is before the
+	// utxo commitment deployment locked in.
+	trackUtxoSet, err := b.trackUtxoSet(prevNode)
+	if err != nil {
+		return err
+	}
+	var utxoSet *czzec.Multiset
+	if trackUtxoSet {
+		utxoSet = copyMultiset(b.utxoSet)
+	}
 
 	err = b.db.Update(func(dbTx database.Tx) error {
+		// Before we delete the spend journal entry for this back,
+		// we'll fetch it as is so the indexers and the multiset of the
+		// utxo set can utilize if needed.
+		stxos, err := dbFetchSpendJournalEntry(dbTx, block)
+		if err != nil {
+			return err
+		}
+		if utxoSet != nil {
+			disconnectUtxoSet(utxoSet, block, stxos)
+		}
+
 		// Update best block state.
-		err := dbPutBestState(dbTx, state, node.workSum)
+		err = dbPutBestState(dbTx, state, node.workSum, utxoSet)
 		if err != nil {
 			return err
 		}
@@ -860,16 +988,15 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *czzutil.Block, view
 			return err
 		}
 
-		// Before we delete the spend journal entry for this back,
-		// we'll fetch it as is so the indexers can utilize if needed.
-		stxos, err := dbFetchSpendJournalEntry(dbTx, block)
+		// Update the transaction spend journal by removing the record
+		// that contains all txos spent by the block.
+		err = dbRemoveSpendJournalEntry(dbTx, block.Hash())
 		if err != nil {
 			return err
 		}
 
-		// Update the transaction spend journal by removing the record
-		// that contains all txos spent by the block.
-		err = dbRemoveSpendJournalEntry(dbTx, block.Hash())
+		// Remove the transaction metadata of the block, if any.
+		err = dbRemoveBlockTxMeta(dbTx, block.Hash())
 		if err != nil {
 			return err
 		}
@@ -900,6 +1027,7 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *czzutil.Block, view
 
 	// This node's parent is now the end of the best chain.
 	b.bestChain.SetTip(node.parent)
+	b.utxoSet = utxoSet
 
 	// Update the state for the best block.  Notice how this replaces the
 	// entire struct instead of updating the existing one.  This effectively
@@ -907,6 +1035,7 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *czzutil.Block, view
 	// freely without needing to hold a lock for the duration.  See the
 	// comments on the state variable for more details.
 	b.stateLock.Lock()
+	state.Sequence = b.stateSnapshot.Sequence + 1
 	b.stateSnapshot = state
 	b.stateLock.Unlock()
 
@@ -934,6 +1063,20 @@ func countSpentOutputs(block *czzutil.Block) int {
 	return numSpent
 }
 
+// storedBlockSanityFlags returns the behavior flags the sanity of the stored
+// block of the passed node is checked again with.  The magnetic anomaly rules
+// are active for every block, as in ProcessBlock.  A block whose header does
+// not satisfy its proof of work was accepted without checking it, such as a
+// block downloaded with BFNoPoWCheck, so it is not checked again either.
+func (b *BlockChain) storedBlockSanityFlags(node *blockNode) BehaviorFlags {
+	flags := BFMagneticAnomaly
+	header := node.Header()
+	if checkProofOfWork(&header, b.chainParams.PowLimit, BFNone) != nil {
+		flags |= BFNoPoWCheck
+	}
+	return flags
+}
+
 // reorganizeChain reorganizes the block chain by disconnecting the nodes in the
 // detachNodes list and connecting the nodes in the attach list.  It expects
 // that the lists are already in the correct order and are in sync with the
@@ -994,14 +1137,20 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 	// Disconnect all of the blocks back to the point of the fork.  This
 	// entails loading the blocks and their associated spent txos from the
 	// database and using that information to unspend all of the spent txos
-	// and remove the utxos created by the blocks.
+	// and remove the utxos created by the blocks.  The multiset of the utxo
+	// set follows the view so the utxo commitments of the blocks to attach
+	// can be checked.
 	view := NewUtxoViewpoint()
+	utxoSet, err := b.reorganizeUtxoSet(attachNodes)
+	if err != nil {
+		return err
+	}
 	for e := detachNodes.Front(); e != nil; e = e.Next() {
 		n := e.Value.(*blockNode)
 		var block *czzutil.Block
 		err := b.db.View(func(dbTx database.Tx) error {
 			var err error
-			block, err = dbFetchBlockByNode(dbTx, n)
+			block, err = b.fetchBlockByNode(dbTx, n)
 			return err
 		})
 		if err != nil {
@@ -1039,6 +1188,9 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		if err != nil {
 			return err
 		}
+		if utxoSet != nil {
+			disconnectUtxoSet(utxoSet, block, stxos)
+		}
 
 		newBest = n.parent
 	}
@@ -1068,7 +1220,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		var block *czzutil.Block
 		err := b.db.View(func(dbTx database.Tx) error {
 			var err error
-			block, err = dbFetchBlockByNode(dbTx, n)
+			block, err = b.fetchBlockByNode(dbTx, n)
 			return err
 		})
 		if err != nil {
@@ -1078,32 +1230,56 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		// Store the loaded block for later.
 		attachBlocks = append(attachBlocks, block)
 
+		// The blocks of the side chains which were stored by a previous
+		// version and not validated yet might not pass the current
+		// sanity checks, so they are checked again.
+		status := b.index.NodeStatus(n)
+		if !status.KnownValid() && !status.SanityChecked() {
+			block.SetHeight(n.height)
+			err = checkBlockSanity(b, block, b.chainParams.PowLimit,
+				b.timeSource, b.storedBlockSanityFlags(n))
+			if err != nil {
+				if _, ok := err.(RuleError); ok {
+					b.index.SetStatusFlags(n, statusValidateFailed)
+					for de := e.Next(); de != nil; de = de.Next() {
+						dn := de.Value.(*blockNode)
+						b.index.SetStatusFlags(dn, statusInvalidAncestor)
+					}
+				}
+				return err
+			}
+			b.index.SetStatusFlags(n, statusSanityChecked)
+		}
+
 		// Skip checks if node has already been fully validated. Although
 		// checkConnectBlock gets skipped, we still need to update the UTXO
 		// view.
+		stxos := make([]SpentTxOut, 0, countSpentOutputs(block))
 		if b.index.NodeStatus(n).KnownValid() {
 			err = view.addInputUtxos(b.utxoCache, block)
 			if err != nil {
 				return err
 			}
-			err = connectTransactions(view, block, nil, true)
+			err = connectTransactions(view, block, &stxos, true)
 			if err != nil {
 				return err
 			}
+			if utxoSet != nil {
+				connectUtxoSet(utxoSet, block, stxos)
+			}
 
 			newBest = n
 			continue
 		}
 
-		// Notice the spent txout details are not requested here and
-		// thus will not be generated.  This is done because the state
-		// is not being immediately written to the database, so it is
-		// not needed.
+		// Notice the spent txout details are only generated to update
+		// the multiset of the utxo set, since the state is not being
+		// immediately written to the database.
 		//
 		// In the case the block is determined to be invalid due to a
 		// rule violation, mark it as invalid and mark all of its
 		// descendants as having an invalid ancestor.
-		err = b.checkConnectBlock(n, block, view, nil)
+		err = b.checkConnectBlock(n, block, view, &stxos, utxoSet)
 		if err != nil {
 			if _, ok := err.(RuleError); ok {
 				b.index.SetStatusFlags(n, statusValidateFailed)
@@ -1115,6 +1291,9 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 			return err
 		}
 		b.index.SetStatusFlags(n, statusValid)
+		if utxoSet != nil {
+			connectUtxoSet(utxoSet, block, stxos)
+		}
 
 		newBest = n
 	}
@@ -1192,6 +1371,28 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 	log.Infof("REORGANIZE: New best chain head is %v (height %v)",
 		newBest.hash, newBest.height)
 
+	// Notify the caller the chain was reorganized so it can reconcile the
+	// transactions of the disconnected blocks with the new best chain, as
+	// a whole once all the blocks were disconnected and connected.
+	//
+	// NOTE: The chain lock is released for the notification like it is
+	// for the ones of the blocks.
+	if detachNodes.Len() > 0 {
+		fork := detachNodes.Back().Value.(*blockNode).parent
+		b.chainLock.Unlock()
+		b.sendNotification(NTChainReorganized, &ReorganizationData{
+			OldHash:    oldBest.hash,
+			OldHeight:  oldBest.height,
+			NewHash:    newBest.hash,
+			NewHeight:  newBest.height,
+			ForkHash:   fork.hash,
+			ForkHeight: fork.height,
+			Detached:   detachBlocks,
+			Attached:   attachBlocks,
+		})
+		b.chainLock.Lock()
+	}
+
 	return nil
 }
 
@@ -1235,8 +1436,10 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *czzutil.Block, fla
 		// actually connecting the block.
 		view := NewUtxoViewpoint()
 		stxos := make([]SpentTxOut, 0, countSpentOutputs(block))
+		b.takeUtxoPrefetch(block)
 		if !fastAdd {
-			err := b.checkConnectBlock(node, block, view, &stxos)
+			err := b.checkConnectBlock(node, block, view, &stxos,
+				b.utxoSet)
 			if err == nil {
 				b.index.SetStatusFlags(node, statusValid)
 			} else if _, ok := err.(RuleError); ok {
@@ -1756,14 +1959,23 @@ func (b *BlockChain) locateHeaders(locator BlockLocator, hashStop *chainhash.Has
 	return headers
 }
 
-// InvalidateBlock takes a block hash and invalidates it.
+// InvalidateBlock marks the block with the passed hash and all of its
+// descendants as invalid and reorganizes the chain to the valid block with the
+// most cumulative work when the block is part of the main chain.  The block
+// status flags are persisted so the block stays invalid across restarts until
+// it is reconsidered.
 //
 // This function is safe for concurrent access.
 func (b *BlockChain) InvalidateBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
 	return b.invalidateBlock(hash)
 }
 
-// invalidateBlock takes a block hash and invalidates it.
+// invalidateBlock marks the block with the passed hash and all of its
+// descendants as invalid and reorganizes the chain to the best valid tip.
+//
+// This function MUST be called with the chain state lock held (for writes).
 func (b *BlockChain) invalidateBlock(hash *chainhash.Hash) error {
 	node := b.index.LookupNode(hash)
 	if node == nil {
@@ -1772,7 +1984,7 @@ func (b *BlockChain) invalidateBlock(hash *chainhash.Hash) error {
 	}
 
 	// No need to invalidate if its already invalid.
-	if node.status.KnownInvalid() {
+	if b.index.NodeStatus(node).KnownInvalid() {
 		err := fmt.Errorf("block %s is already invalid", hash)
 		return err
 	}
@@ -1784,38 +1996,35 @@ func (b *BlockChain) invalidateBlock(hash *chainhash.Hash) error {
 
 	b.index.SetStatusFlags(node, statusValidateFailed)
 	b.index.UnsetStatusFlags(node, statusValid)
-
-	b.chainLock.Lock()
-	defer b.chainLock.Unlock()
-	detachNodes, attachNodes := b.getReorganizeNodes(node.parent)
-
-	err := b.reorganizeChain(detachNodes, attachNodes)
-	if err != nil {
-		return err
-	}
-
-	for i, e := 0, detachNodes.Front(); e != nil; i, e = i+1, e.Next() {
-		n := e.Value.(*blockNode)
-
+	for _, n := range b.descendants(node) {
 		b.index.SetStatusFlags(n, statusInvalidAncestor)
 		b.index.UnsetStatusFlags(n, statusValid)
 	}
 
-	if writeErr := b.index.flushToDB(); writeErr != nil {
-		log.Warnf("Error flushing block index changes to disk: %v", writeErr)
+	err := b.activateBestValidChain()
+	if writeErr := b.index.flushToDB(); writeErr != nil && err == nil {
+		err = writeErr
 	}
-
-	return nil
+	return err
 }
 
-// ReconsiderBlock takes a block hash and allows it to be revalidated.
+// ReconsiderBlock clears the invalid status of the block with the passed hash,
+// of its ancestors and of its descendants, and reorganizes the chain to the
+// valid block with the most cumulative work, which revalidates the blocks.
+// The block status flags are persisted.
 //
 // This function is safe for concurrent access.
 func (b *BlockChain) ReconsiderBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
 	return b.reconsiderBlock(hash)
 }
 
-// reconsiderBlock takes a block hash and allows it to be revalidated.
+// reconsiderBlock clears the invalid status of the block with the passed hash,
+// of its ancestors and of its descendants and reorganizes the chain to the best
+// valid tip.
+//
+// This function MUST be called with the chain state lock held (for writes).
 func (b *BlockChain) reconsiderBlock(hash *chainhash.Hash) error {
 	node := b.index.LookupNode(hash)
 	if node == nil {
@@ -1823,46 +2032,134 @@ func (b *BlockChain) reconsiderBlock(hash *chainhash.Hash) error {
 		return err
 	}
 
-	// No need to reconsider, it is already valid.
-	if node.status.KnownValid() {
-		err := fmt.Errorf("block %s is already valid", hash)
+	// No need to reconsider, it is not invalid.
+	if !b.index.NodeStatus(node).KnownInvalid() {
+		err := fmt.Errorf("block %s is not invalid", hash)
 		return err
 	}
 
-	// Keep a reference to the first node in the chain of invalid
-	// blocks so we can reprocess after status flags are updated.
-	firstNode := node
-
-	// Find previous node to the point where the blocks are valid again.
-	for n := node; n.status.KnownInvalid(); n = n.parent {
-		b.index.UnsetStatusFlags(n, statusInvalidAncestor)
-		b.index.UnsetStatusFlags(n, statusValidateFailed)
+	// The block can only become valid once its ancestors are, and its
+	// descendants only had an invalid ancestor because of it or of its
+	// ancestors.
+	const invalidFlags = statusValidateFailed | statusInvalidAncestor
+	for n := node; n != nil; n = n.parent {
+		if b.index.NodeStatus(n).KnownInvalid() {
+			b.index.UnsetStatusFlags(n, invalidFlags)
+		}
+	}
+	for _, n := range b.descendants(node) {
+		b.index.UnsetStatusFlags(n, invalidFlags)
+	}
 
-		firstNode = n
+	err := b.activateBestValidChain()
+	if writeErr := b.index.flushToDB(); writeErr != nil && err == nil {
+		err = writeErr
 	}
+	return err
+}
 
-	var blk *czzutil.Block
-	err := b.db.View(func(dbTx database.Tx) error {
-		var err error
-		blk, err = dbFetchBlockByNode(dbTx, firstNode)
-		return err
-	})
-	if err != nil {
-		return err
+// descendants returns all of the blocks of the block index building on the
+// passed block, whether they are part of the main chain or of a side chain.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) descendants(node *blockNode) []*blockNode {
+	b.index.RLock()
+	children := make(map[*blockNode][]*blockNode)
+	for _, n := range b.index.index {
+		if n.height > node.height {
+			children[n.parent] = append(children[n.parent], n)
+		}
 	}
+	b.index.RUnlock()
 
-	// Process it all again. This will take care of the
-	// orphans as well.
-	_, _, err = b.ProcessBlock(blk, BFNoDupBlockCheck)
-	if err != nil {
-		return err
+	var descendants []*blockNode
+	queue := children[node]
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		descendants = append(descendants, n)
+		queue = append(queue, children[n]...)
 	}
+	return descendants
+}
 
-	if writeErr := b.index.flushToDB(); writeErr != nil {
-		log.Warnf("Error flushing block index changes to disk: %v", writeErr)
+// bestValidTip returns the block with the most cumulative work which is not
+// known to be invalid and whose blocks back to the main chain are all stored
+// and not known to be invalid either, so the chain can be reorganized to it.
+// The current tip is kept on equal work.  It returns nil when every block of
+// the main chain is known to be invalid.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) bestValidTip() *blockNode {
+	b.index.RLock()
+	defer b.index.RUnlock()
+
+	var best *blockNode
+	for _, n := range b.index.index {
+		if n.status.KnownInvalid() || !n.status.HaveData() {
+			continue
+		}
+		if best != nil {
+			cmp := n.workSum.Cmp(best.workSum)
+			if cmp < 0 || cmp == 0 && !b.bestChain.Contains(n) {
+				continue
+			}
+		}
+
+		connectable := true
+		for a := n.parent; a != nil && !b.bestChain.Contains(a); a = a.parent {
+			if a.status.KnownInvalid() || !a.status.HaveData() {
+				connectable = false
+				break
+			}
+		}
+		if connectable {
+			best = n
+		}
 	}
+	return best
+}
 
-	return nil
+// activateBestValidChain reorganizes the chain to the best valid tip.  The
+// blocks of a side chain which fail to connect are marked invalid by the
+// reorganization, in which case the next best valid tip is tried.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) activateBestValidChain() error {
+	for {
+		tip := b.bestValidTip()
+		if tip == nil {
+			return AssertError("no valid block to reorganize the chain to")
+		}
+		if tip == b.bestChain.Tip() {
+			return nil
+		}
+
+		// The blocks of the main chain after a block of the main chain
+		// are only disconnected.
+		var detachNodes, attachNodes *list.List
+		if b.bestChain.Contains(tip) {
+			detachNodes, attachNodes = list.New(), list.New()
+			for n := b.bestChain.Tip(); n != tip; n = n.parent {
+				detachNodes.PushBack(n)
+			}
+		} else {
+			detachNodes, attachNodes = b.getReorganizeNodes(tip)
+		}
+		if detachNodes.Len() == 0 && attachNodes.Len() == 0 {
+			return AssertError(fmt.Sprintf("no blocks to reorganize "+
+				"the chain to %v", tip.hash))
+		}
+		err := b.reorganizeChain(detachNodes, attachNodes)
+		if err == nil {
+			continue
+		}
+		if _, ok := err.(RuleError); !ok {
+			return err
+		}
+		log.Warnf("Failed to reorganize the chain to %v (height %d): %v",
+			tip.hash, tip.height, err)
+	}
 }
 
 // Prune deletes the block data and spend journals for all blocks deeper than
@@ -1875,6 +2172,66 @@ func (b *BlockChain) Prune() error {
 	return b.prune()
 }
 
+// RollbackToHeight disconnects the blocks of the main chain above the passed
+// height, restoring the utxos they spent from their spend journals, so the
+// passed height becomes the height of the tip.  The blocks are disconnected the
+// way a reorganization disconnects them, so the indexes and the subscribers of
+// the notifications follow.  The first disconnected block is then marked
+// invalid the way InvalidateBlock marks it, so the chain neither reorganizes
+// back to the disconnected blocks when a block extending them is processed nor
+// when it is loaded again.  ReconsiderBlock undoes the rollback.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) RollbackToHeight(height int32) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+	return b.rollbackToHeight(height)
+}
+
+// rollbackToHeight disconnects the blocks of the main chain above the passed
+// height.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) rollbackToHeight(height int32) error {
+	tip := b.bestChain.Tip()
+	if height < 0 || height > tip.height {
+		return fmt.Errorf("rollback height %d is out of the range of the "+
+			"main chain [0, %d]", height, tip.height)
+	}
+	if height == tip.height {
+		return nil
+	}
+
+	// The blocks to disconnect and their spend journals must still be
+	// stored.
+	if pruneHeight := b.PruneHeight(); height+1 < pruneHeight {
+		return fmt.Errorf("cannot roll back to height %d since the "+
+			"blocks below height %d are pruned", height, pruneHeight)
+	}
+
+	log.Infof("Rolling back %d blocks to height %d", tip.height-height,
+		height)
+
+	detachNodes := list.New()
+	for n := tip; n.height > height; n = n.parent {
+		detachNodes.PushBack(n)
+	}
+	if err := b.reorganizeChain(detachNodes, list.New()); err != nil {
+		return err
+	}
+
+	// The block status flags are persisted so the rollback survives a
+	// restart.
+	node := detachNodes.Back().Value.(*blockNode)
+	b.index.SetStatusFlags(node, statusValidateFailed)
+	b.index.UnsetStatusFlags(node, statusValid)
+	for _, n := range b.descendants(node) {
+		b.index.SetStatusFlags(n, statusInvalidAncestor)
+		b.index.UnsetStatusFlags(n, statusValid)
+	}
+	return b.index.flushToDB()
+}
+
 // RollbackUtxoSet will perform an in-memory rollback of the Utxo set to the
 // given block height and return a UtxoViewpoint containing the diff between the
 // current Utxo set and the Utxo set at the provided height. Calling this function
@@ -1965,6 +2322,7 @@ func (b *BlockChain) prune() error {
 
 		// Loop backwards through the chain and delete the spend journals
 		for ; node.height >= int32(pruneHeight); node = node.parent {
+			b.blockCache.Remove(&node.hash)
 			hdr := node.Header()
 			blockHash := hdr.BlockHash()
 			if err := dbRemoveSpendJournalEntry(tx, &blockHash); err != nil {
@@ -2041,7 +2399,7 @@ func (b *BlockChain) ReIndexChainState() error {
 		if err = b.utxoCache.Commit(view); err != nil {
 			return err
 		}
-		state = newBestState(node, 0, 0, 0, time.Time{})
+		state = newBestState(node, 0, 0, 0, 0, time.Time{})
 		if err = b.utxoCache.Flush(FlushIfNeeded, state); err != nil {
 			return err
 		}
@@ -2106,6 +2464,11 @@ type Config struct {
 	// This field is required.
 	UtxoCacheMaxSize uint64
 
+	// BlockCacheSize is the number of the most recent blocks to keep
+	// deserialized in memory to serve them without reading the database.
+	// No block is cached when it is zero.
+	BlockCacheSize int
+
 	// Interrupt specifies a channel the caller can close to signal that
 	// long running operations, such as catching up indexes or performing
 	// database migrations, should be interrupted.
@@ -2161,6 +2524,13 @@ type Config struct {
 	// signature cache.
 	HashCache *txscript.HashCache
 
+	// ScriptCacheSize is the number of transactions the successful
+	// validations of the scripts are remembered for, so the transactions
+	// already validated by CheckTransactionScripts, such as those of the
+	// memory pool, are not validated again once in a block.  Nothing is
+	// cached when it is zero.
+	ScriptCacheSize uint
+
 	// ExcessiveBlockSize is the user-configurable max block size
 	ExcessiveBlockSize uint32
 
@@ -2173,6 +2543,11 @@ type Config struct {
 	// whenever we connect a new block.
 	PruneDepth uint32
 
+	// PersistTxMeta stores the transaction metadata returned by
+	// BlockTxMeta when each block is connected, so it does not need to be
+	// computed again and remains available once the block is pruned.
+	PersistTxMeta bool
+
 	// ReIndexChainState will delete the UTXO db bucket and rebuild the
 	// UTXO set from blocks on disk on startup.
 	ReIndexChainState bool
@@ -2189,6 +2564,14 @@ type Config struct {
 	// the UTXO set in fast sync mode.
 	Proxy string
 
+	// FetchUtxoSnapshot, if set, is called in fast sync mode to fetch the
+	// serialized UTXO set at the passed checkpoint from peers into a file
+	// in the passed directory, before falling back to the download sources
+	// of the checkpoint.  It returns the name of the file.  The file is
+	// verified against the UTXO set hash of the checkpoint, so the
+	// function does not need to.
+	FetchUtxoSnapshot func(checkpoint *chaincfg.Checkpoint, dir string) (string, error)
+
 	//
 	DogeCoinRPC []string
 
@@ -2219,6 +2602,19 @@ func New(config *Config) (*BlockChain, error) {
 	if config.TimeSource == nil {
 		return nil, AssertError("blockchain.New timesource is nil")
 	}
+	if err := config.ChainParams.CheckTimePolicy(); err != nil {
+		return nil, AssertError("blockchain.New chain parameters " +
+			"have an invalid time policy: " + err.Error())
+	}
+	if err := checkDifficultySchedule(config.ChainParams); err != nil {
+		return nil, AssertError("blockchain.New chain parameters " +
+			"have an invalid difficulty schedule: " + err.Error())
+	}
+	scriptFlagSchedule, err := resolveScriptFlagSchedule(config.ChainParams)
+	if err != nil {
+		return nil, AssertError("blockchain.New chain parameters " +
+			"have an invalid script flag schedule: " + err.Error())
+	}
 	if config.ExcessiveBlockSize < LegacyMaxBlockSize {
 		return nil, AssertError("blockchain.New excessive block size set lower than LegacyBlockSize")
 	}
@@ -2241,54 +2637,15 @@ func New(config *Config) (*BlockChain, error) {
 		}
 	}
 
-	var dogeclients []*rpcclient.Client
-
-	for _, dogerpc := range config.DogeCoinRPC {
-		// Connect to local bitcoin core RPC server using HTTP POST mode.
-		connCfg := &rpcclient.ConnConfig{
-			Host:         dogerpc,
-			Endpoint:     "ws",
-			User:         config.DogeCoinRPCUser,
-			Pass:         config.DogeCoinRPCPass,
-			HTTPPostMode: true, // Bitcoin core only supports HTTP POST mode
-			DisableTLS:   true, // Bitcoin core does not provide TLS by default
-		}
-		if err := rpcclient.HttpClientTest(connCfg); err != nil {
-			log.Info(err)
-		}
-		// Notice the notification parameter is nil since notifications are
-		// not supported in HTTP POST mode.
-		client, err := rpcclient.New(connCfg, nil)
-		if err != nil {
-			return nil, err
-		}
-
-		dogeclients = append(dogeclients, client)
+	dogeclients, err := newEntangleClients(config.DogeCoinRPC,
+		config.DogeCoinRPCUser, config.DogeCoinRPCPass)
+	if err != nil {
+		return nil, err
 	}
-
-	var ltcclients []*rpcclient.Client
-
-	for _, ltcrpc := range config.LtcCoinRPC {
-		// Connect to local bitcoin core RPC server using HTTP POST mode.
-		connCfg := &rpcclient.ConnConfig{
-			Host:         ltcrpc,
-			Endpoint:     "ws",
-			User:         config.LtcCoinRPCUser,
-			Pass:         config.LtcCoinRPCPass,
-			HTTPPostMode: true, // Bitcoin core only supports HTTP POST mode
-			DisableTLS:   true, // Bitcoin core does not provide TLS by default
-		}
-		if err := rpcclient.HttpClientTest(connCfg); err != nil {
-			log.Info(err)
-		}
-		// Notice the notification parameter is nil since notifications are
-		// not supported in HTTP POST mode.
-		client, err := rpcclient.New(connCfg, nil)
-		if err != nil {
-			return nil, err
-		}
-
-		ltcclients = append(ltcclients, client)
+	ltcclients, err := newEntangleClients(config.LtcCoinRPC,
+		config.LtcCoinRPCUser, config.LtcCoinRPCPass)
+	if err != nil {
+		return nil, err
 	}
 
 	cacheEntangleInfo := &cross.CacheEntangleInfo{
@@ -2314,9 +2671,13 @@ func New(config *Config) (*BlockChain, error) {
 		excessiveBlockSize:  config.ExcessiveBlockSize,
 		indexManager:        config.IndexManager,
 		maxRetargetTimespan: targetTimespan * adjustmentFactor,
+		scriptFlagSchedule:  scriptFlagSchedule,
 		index:               newBlockIndex(config.DB, params),
 		utxoCache:           newUtxoCache(config.DB, config.UtxoCacheMaxSize),
+		blockCache:          newBlockCache(config.BlockCacheSize),
+		activityCache:       newActivityCache(MaxActivityWindow),
 		hashCache:           config.HashCache,
+		scriptCache:         newScriptCache(config.ScriptCacheSize),
 		bestChain:           newChainView(nil),
 		orphans:             make(map[chainhash.Hash]*orphanBlock),
 		prevOrphans:         make(map[chainhash.Hash][]*orphanBlock),
@@ -2325,9 +2686,12 @@ func New(config *Config) (*BlockChain, error) {
 		pruneMode:           config.Prune,
 		pruneDepth:          config.PruneDepth,
 		fastSyncDataDir:     config.FastSyncDataDir,
+		persistTxMeta:       config.PersistTxMeta,
+		fetchUtxoSnapshot:   config.FetchUtxoSnapshot,
 		fastSyncDone:        make(chan struct{}),
 		entangleVerify:      entangleVerify,
 	}
+	b.chainLock.SetName("chain")
 
 	// Initialize the chain state from the passed database.  When the db
 	// does not yet contain any chain state, both it and the chain state
@@ -2400,16 +2764,66 @@ func New(config *Config) (*BlockChain, error) {
 		log.Info("Re-indexing complete")
 	}
 
+	// The utxo set is unknown until a fast sync downloads it.  Otherwise
+	// the multiset of the utxo set is computed once from the consistent
+	// utxo set when the utxo commitment deployment is locked in and the
+	// chain state was stored without it.
+	trackUtxoSet, err := b.trackUtxoSet(b.bestChain.Tip())
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case config.FastSync:
+		b.utxoSet = nil
+		b.utxoSetUnknown = true
+	case !trackUtxoSet:
+		b.utxoSet = nil
+	case b.utxoSet == nil:
+		b.utxoSet, err = b.calcUtxoSet(b.stateSnapshot, config.Interrupt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The node might have stopped before it reorganized the chain to a
+	// stored side chain with more work, such as when it crashed during a
+	// reorganization, in which case the chain is reorganized to it now.  A
+	// side chain which can't be connected leaves the main chain as is.
+	if !config.FastSync {
+		b.chainLock.Lock()
+		best := b.bestValidTip()
+		reorganize := best != nil &&
+			best.workSum.Cmp(b.bestChain.Tip().workSum) > 0
+		if reorganize {
+			log.Infof("Reorganizing the chain to the stored block %v "+
+				"(height %d) with more work", best.hash, best.height)
+			err := b.activateBestValidChain()
+			if err != nil {
+				log.Warnf("Unable to reorganize the chain to the "+
+					"best stored chain: %v", err)
+			}
+		}
+		b.chainLock.Unlock()
+		if reorganize {
+			if err := b.index.flushToDB(); err != nil {
+				return nil, err
+			}
+			bestNode = b.bestChain.Tip()
+		}
+	}
+
 	if config.FastSync {
-		if lastCheckpoint.UtxoSetHash == nil || len(lastCheckpoint.UtxoSetSources) == 0 || lastCheckpoint.UtxoSetSize == 0 {
+		noSources := len(lastCheckpoint.UtxoSetSources) == 0 && config.FetchUtxoSnapshot == nil
+		if lastCheckpoint.UtxoSetHash == nil || noSources || lastCheckpoint.UtxoSetSize == 0 {
 			errStr := fmt.Sprintf("chain with %s params does not support fastsync mode", b.chainParams.Name)
 			return nil, AssertError(errStr)
 		}
 		go b.fastSyncUtxoSet(lastCheckpoint, config.Proxy)
 	}
 
-	log.Infof("Chain state (height %d, hash %v, totaltx %d, work %v)",
-		bestNode.height, bestNode.hash, b.stateSnapshot.TotalTxns,
+	log.Infof("Chain state (height %d, hash %v, totaltx %d, totalsize %d, "+
+		"work %v)", bestNode.height, bestNode.hash,
+		b.stateSnapshot.TotalTxns, b.stateSnapshot.TotalSize,
 		bestNode.workSum)
 
 	return &b, nil
@@ -2434,3 +2848,58 @@ func (b *BlockChain) FlushCachedState(mode FlushMode) error {
 func (b *BlockChain) GetEntangleVerify() *cross.EntangleVerify {
 	return b.entangleVerify
 }
+
+// newEntangleClients returns clients of the RPC servers of the nodes of a
+// chain coins are entangled from at the passed hosts.
+func newEntangleClients(hosts []string, user, pass string) ([]*rpcclient.Client, error) {
+	clients := make([]*rpcclient.Client, 0, len(hosts))
+	for _, host := range hosts {
+		// Connect to local bitcoin core RPC server using HTTP POST mode.
+		connCfg := &rpcclient.ConnConfig{
+			Host:         host,
+			Endpoint:     "ws",
+			User:         user,
+			Pass:         pass,
+			HTTPPostMode: true, // Bitcoin core only supports HTTP POST mode
+			DisableTLS:   true, // Bitcoin core does not provide TLS by default
+		}
+		if err := rpcclient.HttpClientTest(connCfg); err != nil {
+			log.Info(err)
+		}
+		// Notice the notification parameter is nil since notifications are
+		// not supported in HTTP POST mode.
+		client, err := rpcclient.New(connCfg, nil)
+		if err != nil {
+			for _, c := range clients {
+				c.Shutdown()
+			}
+			return nil, err
+		}
+
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+// SetEntangleRPC replaces the RPC servers of the dogecoin and litecoin nodes
+// the entangled outputs are verified against.  The current servers are kept
+// when the clients of the new ones can not be created.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) SetEntangleRPC(dogeHosts []string, dogeUser, dogePass string,
+	ltcHosts []string, ltcUser, ltcPass string) error {
+
+	dogeClients, err := newEntangleClients(dogeHosts, dogeUser, dogePass)
+	if err != nil {
+		return err
+	}
+	ltcClients, err := newEntangleClients(ltcHosts, ltcUser, ltcPass)
+	if err != nil {
+		for _, client := range dogeClients {
+			client.Shutdown()
+		}
+		return err
+	}
+	b.entangleVerify.SetClients(dogeClients, ltcClients)
+	return nil
+}
@@ -970,3 +970,87 @@ func TestIntervalBlockHashes(t *testing.T) {
 		}
 	}
 }
+
+// TestInvalidBlockCandidates ensures the descendants of an invalidated block
+// are found on every chain and the chain is only reorganized to the stored
+// blocks which are not known to be invalid.
+func TestInvalidBlockCandidates(t *testing.T) {
+	// Construct a synthetic block chain with a block index consisting of
+	// the following structure.
+	// 	genesis -> 1 -> 2 -> ... -> 15 -> 16  -> 17  -> 18
+	// 	                              \-> 16a -> 17a -> 18a -> 19a -> 20a (headers only)
+	params := chaincfg.RegressionNetParams
+	chain := newFakeChain(&params)
+	extend := func(node *blockNode, numBlocks int, spacing time.Duration, status blockStatus) []*blockNode {
+		nodes := make([]*blockNode, numBlocks)
+		for i := range nodes {
+			node = newFakeNode(node, 1, node.bits,
+				time.Unix(node.timestamp, 0).Add(spacing))
+			node.status = status
+			chain.index.AddNode(node)
+			nodes[i] = node
+		}
+		return nodes
+	}
+	branch0Nodes := extend(chain.bestChain.Genesis(), 18, time.Minute,
+		statusDataStored|statusValid)
+	chain.bestChain.SetTip(branch0Nodes[17])
+	branch1Nodes := extend(branch0Nodes[14], 4, 2*time.Minute,
+		statusDataStored)
+	branch1Nodes = append(branch1Nodes, extend(branch1Nodes[3], 1,
+		2*time.Minute, statusNone)...)
+
+	// The descendants of block 15 are the blocks of both branches above it.
+	descendants := make(map[*blockNode]struct{})
+	for _, n := range chain.descendants(branch0Nodes[14]) {
+		descendants[n] = struct{}{}
+	}
+	want := append(append([]*blockNode{}, branch0Nodes[15:]...),
+		branch1Nodes...)
+	if len(descendants) != len(want) {
+		t.Errorf("got %d descendants, want %d", len(descendants), len(want))
+	}
+	for _, n := range want {
+		if _, ok := descendants[n]; !ok {
+			t.Errorf("block %d not found as a descendant", n.height)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		invalid []*blockNode
+		want    *blockNode
+	}{{
+		// The branch with the most work only counts up to the last
+		// stored block.
+		name: "stored side chain",
+		want: branch1Nodes[3],
+	}, {
+		// The current tip is kept on equal work.
+		name:    "invalid side chain",
+		invalid: []*blockNode{branch1Nodes[1]},
+		want:    branch0Nodes[17],
+	}, {
+		name:    "invalid main chain",
+		invalid: []*blockNode{branch1Nodes[1], branch0Nodes[16], branch0Nodes[17]},
+		want:    branch0Nodes[15],
+	}, {
+		// The blocks of a side chain are not connectable above an
+		// invalid block even if they are not marked yet.
+		name:    "invalid side chain ancestor",
+		invalid: []*blockNode{branch0Nodes[15], branch0Nodes[16], branch0Nodes[17], branch1Nodes[1]},
+		want:    branch1Nodes[0],
+	}}
+	for _, test := range tests {
+		for _, n := range append(branch0Nodes, branch1Nodes...) {
+			chain.index.UnsetStatusFlags(n, statusValidateFailed)
+		}
+		for _, n := range test.invalid {
+			chain.index.SetStatusFlags(n, statusValidateFailed)
+		}
+		if got := chain.bestValidTip(); got != test.want {
+			t.Errorf("%s: got best valid tip %v, want %v", test.name,
+				got, test.want)
+		}
+	}
+}
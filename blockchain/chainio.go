@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/czzec"
 	"github.com/bourbaki-czz/classzz/database"
 	"github.com/bourbaki-czz/classzz/wire"
 	"github.com/bourbaki-czz/czzutil"
@@ -84,6 +85,10 @@ var (
 	// height at which the blockchain is pruned.
 	pruneHeightKeyName = []byte("pruneheight")
 
+	// txMetaBucketName is the name of the db bucket used to house the
+	// transaction metadata of each block when it is persisted.
+	txMetaBucketName = []byte("blocktxmeta")
+
 	// byteOrder is the preferred byte order used for serializing numeric
 	// fields for storage in the database.
 	byteOrder = binary.LittleEndian
@@ -128,6 +133,13 @@ func isDbBucketNotFoundErr(err error) bool {
 	return ok && dbErr.ErrorCode == database.ErrBucketNotFound
 }
 
+// isDbBlockNotFoundErr returns whether or not the passed error is a
+// database.Error with an error code of database.ErrBlockNotFound.
+func isDbBlockNotFoundErr(err error) bool {
+	dbErr, ok := err.(database.Error)
+	return ok && dbErr.ErrorCode == database.ErrBlockNotFound
+}
+
 // dbFetchVersion fetches an individual version with the given key from the
 // metadata bucket.  It is primarily used to track versions on entities such as
 // buckets.  It returns zero if the provided key does not exist.
@@ -717,6 +729,26 @@ func DeserializeUtxoEntry(serialized []byte) (*UtxoEntry, error) {
 	return entry, nil
 }
 
+// serializeUtxoCommitmentFormat returns the unspent output of the passed
+// outpoint serialized in the commitment format, which the ECMH hash of the utxo
+// set is calculated over.  The coinbase flag is stored in the lowest bit of the
+// last byte of the height, which heights never reach.
+func serializeUtxoCommitmentFormat(outpoint *wire.OutPoint, amount int64,
+	pkScript []byte, blockHeight int32, isCoinBase bool) []byte {
+
+	serialized := make([]byte, 52+len(pkScript))
+	copy(serialized[:32], outpoint.Hash[:])
+	binary.LittleEndian.PutUint32(serialized[32:36], outpoint.Index)
+	binary.LittleEndian.PutUint32(serialized[36:40], uint32(blockHeight))
+	if isCoinBase {
+		serialized[39] |= 0x01
+	}
+	binary.LittleEndian.PutUint64(serialized[40:48], uint64(amount))
+	binary.LittleEndian.PutUint32(serialized[48:52], uint32(len(pkScript)))
+	copy(serialized[52:], pkScript)
+	return serialized
+}
+
 // deserializeUtxoCommitmentFormat takes a Utxo serialized in the commitment format and
 // deserializes it into an OutPoint and UtxoEntry.
 func deserializeUtxoCommitmentFormat(serialized []byte) (*wire.OutPoint, *UtxoEntry, error) {
@@ -956,12 +988,15 @@ func dbFetchHashByHeight(dbTx database.Tx, height int32) (*chainhash.Hash, error
 
 // -----------------------------------------------------------------------------
 // The best chain state consists of the best block hash and height, the total
-// number of transactions up to and including those in the best block, and the
-// accumulated work sum up to and including the best block.
+// number of transactions up to and including those in the best block, the
+// accumulated work sum up to and including the best block, the total size of
+// the stored blocks of the chain and the multiset of the utxo set as of the
+// best block.
 //
 // The serialized format is:
 //
 //   <block hash><block height><total txns><work sum length><work sum>
+//   <total size><utxo set x><utxo set y>
 //
 //   Field             Type             Size
 //   block hash        chainhash.Hash   chainhash.HashSize
@@ -969,15 +1004,29 @@ func dbFetchHashByHeight(dbTx database.Tx, height int32) (*chainhash.Hash, error
 //   total txns        uint64           8 bytes
 //   work sum length   uint32           4 bytes
 //   work sum          big.Int          work sum length
+//   total size        uint64           8 bytes
+//   utxo set x        big.Int          32 bytes
+//   utxo set y        big.Int          32 bytes
+//
+// The total size is missing from the states stored by older versions, in which
+// case it is computed from the stored blocks when the chain state is loaded.
+// The coordinates of the point of the utxo set multiset are missing as well
+// from those states and from the states stored while the utxo set is unknown,
+// such as during a fast sync, in which case it is computed from the utxo set
+// once it is known.
 // -----------------------------------------------------------------------------
 
 // bestChainState represents the data to be stored the database for the current
 // best chain state.
 type bestChainState struct {
-	hash      chainhash.Hash
-	height    uint32
-	totalTxns uint64
-	workSum   *big.Int
+	hash           chainhash.Hash
+	height         uint32
+	totalTxns      uint64
+	workSum        *big.Int
+	totalSize      uint64
+	totalSizeKnown bool
+	utxoSetX       *big.Int
+	utxoSetY       *big.Int
 }
 
 // serializeBestChainState returns the serialization of the passed block best
@@ -986,7 +1035,10 @@ func serializeBestChainState(state bestChainState) []byte {
 	// Calculate the full size needed to serialize the chain state.
 	workSumBytes := state.workSum.Bytes()
 	workSumBytesLen := uint32(len(workSumBytes))
-	serializedLen := chainhash.HashSize + 4 + 8 + 4 + workSumBytesLen
+	serializedLen := chainhash.HashSize + 4 + 8 + 4 + workSumBytesLen + 8
+	if state.utxoSetX != nil {
+		serializedLen += 64
+	}
 
 	// Serialize the chain state.
 	serializedData := make([]byte, serializedLen)
@@ -999,6 +1051,13 @@ func serializeBestChainState(state bestChainState) []byte {
 	byteOrder.PutUint32(serializedData[offset:], workSumBytesLen)
 	offset += 4
 	copy(serializedData[offset:], workSumBytes)
+	offset += workSumBytesLen
+	byteOrder.PutUint64(serializedData[offset:], state.totalSize)
+	offset += 8
+	if state.utxoSetX != nil {
+		state.utxoSetX.FillBytes(serializedData[offset : offset+32])
+		state.utxoSetY.FillBytes(serializedData[offset+32 : offset+64])
+	}
 	return serializedData[:]
 }
 
@@ -1036,20 +1095,62 @@ func deserializeBestChainState(serializedData []byte) (bestChainState, error) {
 	}
 	workSumBytes := serializedData[offset : offset+workSumBytesLen]
 	state.workSum = new(big.Int).SetBytes(workSumBytes)
+	offset += workSumBytesLen
+
+	// The total size is only stored by newer versions.
+	if uint32(len(serializedData[offset:])) >= 8 {
+		state.totalSize = byteOrder.Uint64(serializedData[offset : offset+8])
+		state.totalSizeKnown = true
+		offset += 8
+	}
+
+	// The multiset of the utxo set is only stored by newer versions while
+	// the utxo set is known.
+	if uint32(len(serializedData[offset:])) >= 64 {
+		state.utxoSetX = new(big.Int).SetBytes(serializedData[offset : offset+32])
+		state.utxoSetY = new(big.Int).SetBytes(serializedData[offset+32 : offset+64])
+	}
 
 	return state, nil
 }
 
+// dbFetchChainSize uses an existing database transaction to compute the total
+// size of the stored blocks of the chain ending with the passed node.  Blocks
+// which are not stored, such as those before the checkpoint a fast sync started
+// from, do not count.
+func dbFetchChainSize(dbTx database.Tx, tip *blockNode) (uint64, error) {
+	var totalSize uint64
+	for node := tip; node != nil; node = node.parent {
+		blockBytes, err := dbTx.FetchBlock(&node.hash)
+		if isDbBlockNotFoundErr(err) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		totalSize += uint64(len(blockBytes))
+	}
+	return totalSize, nil
+}
+
 // dbPutBestState uses an existing database transaction to update the best chain
-// state with the given parameters.
-func dbPutBestState(dbTx database.Tx, snapshot *BestState, workSum *big.Int) error {
+// state with the given parameters.  The multiset of the utxo set is nil when the
+// utxo set is unknown.
+func dbPutBestState(dbTx database.Tx, snapshot *BestState, workSum *big.Int,
+	utxoSet *czzec.Multiset) error {
+
 	// Serialize the current best chain state.
-	serializedData := serializeBestChainState(bestChainState{
+	state := bestChainState{
 		hash:      snapshot.Hash,
 		height:    uint32(snapshot.Height),
 		totalTxns: snapshot.TotalTxns,
 		workSum:   workSum,
-	})
+		totalSize: snapshot.TotalSize,
+	}
+	if utxoSet != nil {
+		state.utxoSetX, state.utxoSetY = utxoSet.Point()
+	}
+	serializedData := serializeBestChainState(state)
 
 	// Store the current best chain state into the database.
 	return dbTx.Metadata().Put(chainStateKeyName, serializedData)
@@ -1198,7 +1299,7 @@ func (b *BlockChain) createChainState() error {
 	numTxns := uint64(len(genesisBlock.MsgBlock().Transactions))
 	blockSize := uint64(genesisBlock.MsgBlock().SerializeSize())
 	b.stateSnapshot = newBestState(node, blockSize, numTxns,
-		numTxns, time.Unix(node.timestamp, 0))
+		numTxns, blockSize, time.Unix(node.timestamp, 0))
 
 	// Create the initial the database chain state including creating the
 	// necessary index buckets and inserting the genesis block.
@@ -1264,8 +1365,12 @@ func (b *BlockChain) createChainState() error {
 			return err
 		}
 
-		// Store the current best chain state into the database.
-		err = dbPutBestState(dbTx, b.stateSnapshot, node.workSum)
+		// Store the current best chain state into the database.  The
+		// outputs of the genesis block are not spendable, so the utxo
+		// set starts empty.
+		b.utxoSet = czzec.NewMultiset(czzec.S256())
+		err = dbPutBestState(dbTx, b.stateSnapshot, node.workSum,
+			b.utxoSet)
 		if err != nil {
 			return err
 		}
@@ -1395,12 +1500,23 @@ func (b *BlockChain) initChainState(fastSync bool) error {
 		lastCheckpoint := b.LatestCheckpoint()
 		if !fastSync || (lastCheckpoint != nil && tip.height > lastCheckpoint.Height) {
 			blockBytes, err = dbTx.FetchBlock(&state.hash)
-			if err != nil {
-				return err
-			}
-			err = block.Deserialize(bytes.NewReader(blockBytes))
-			if err != nil {
+			switch {
+			// The block data of the tip might have been removed
+			// when the database recovered from corruption, in
+			// which case the stats of the best block are unknown.
+			case isDbBlockNotFoundErr(err):
+				log.Warnf("Unable to load chain tip %s: %v",
+					state.hash, err)
+				blockBytes = nil
+
+			case err != nil:
 				return err
+
+			default:
+				err = block.Deserialize(bytes.NewReader(blockBytes))
+				if err != nil {
+					return err
+				}
 			}
 		}
 
@@ -1423,11 +1539,29 @@ func (b *BlockChain) initChainState(fastSync bool) error {
 			}
 		}
 
+		// The total size of the blocks is computed once for the chain
+		// states stored before it was tracked.
+		if !state.totalSizeKnown {
+			log.Info("Computing the total size of the stored blocks of " +
+				"the main chain.  This might take a while...")
+			state.totalSize, err = dbFetchChainSize(dbTx, tip)
+			if err != nil {
+				return err
+			}
+		}
+
+		// The multiset of the utxo set is computed from the utxo set
+		// once it is consistent when it was not stored.
+		if state.utxoSetX != nil {
+			b.utxoSet = czzec.NewMultisetFromPoint(czzec.S256(),
+				state.utxoSetX, state.utxoSetY)
+		}
+
 		// Initialize the state related to the best block.
 		blockSize := uint64(len(blockBytes))
 		numTxns := uint64(len(block.Transactions))
 		b.stateSnapshot = newBestState(tip, blockSize, numTxns,
-			state.totalTxns, tip.CalcPastMedianTime())
+			state.totalTxns, state.totalSize, tip.CalcPastMedianTime())
 
 		return nil
 	})
@@ -1564,11 +1698,11 @@ func (b *BlockChain) BlockByHeight(blockHeight int32) (*czzutil.Block, error) {
 		return nil, errNotInMainChain(str)
 	}
 
-	// Load the block from the database and return it.
+	// Load the block from the cache or the database and return it.
 	var block *czzutil.Block
 	err := b.db.View(func(dbTx database.Tx) error {
 		var err error
-		block, err = dbFetchBlockByNode(dbTx, node)
+		block, err = b.fetchBlockByNode(dbTx, node)
 		return err
 	})
 	return block, err
@@ -1587,11 +1721,11 @@ func (b *BlockChain) BlockByHash(hash *chainhash.Hash) (*czzutil.Block, error) {
 		return nil, errNotInMainChain(str)
 	}
 
-	// Load the block from the database and return it.
+	// Load the block from the cache or the database and return it.
 	var block *czzutil.Block
 	err := b.db.View(func(dbTx database.Tx) error {
 		var err error
-		block, err = dbFetchBlockByNode(dbTx, node)
+		block, err = b.fetchBlockByNode(dbTx, node)
 		return err
 	})
 	return block, err
@@ -718,8 +718,10 @@ func TestBestChainStateSerialization(t *testing.T) {
 					workSum.Add(workSum, CalcWork(486604799))
 					return new(big.Int).Set(workSum)
 				}(), // 0x0100010001
+				totalSize:      285,
+				totalSizeKnown: true,
 			},
-			serialized: hexToBytes("6fe28c0ab6f1b372c1a6a246ae63f74f931e8365e15a089c68d6190000000000000000000100000000000000050000000100010001"),
+			serialized: hexToBytes("6fe28c0ab6f1b372c1a6a246ae63f74f931e8365e15a089c68d61900000000000000000001000000000000000500000001000100011d01000000000000"),
 		},
 		{
 			name: "block 1",
@@ -731,8 +733,26 @@ func TestBestChainStateSerialization(t *testing.T) {
 					workSum.Add(workSum, CalcWork(486604799))
 					return new(big.Int).Set(workSum)
 				}(), // 0x0200020002
+				totalSize:      500,
+				totalSizeKnown: true,
 			},
-			serialized: hexToBytes("4860eb18bf1b1620e37e9490fc8a427514416fd75159ab86688e9a8300000000010000000200000000000000050000000200020002"),
+			serialized: hexToBytes("4860eb18bf1b1620e37e9490fc8a427514416fd75159ab86688e9a8300000000010000000200000000000000050000000200020002f401000000000000"),
+		},
+		{
+			name: "block 1 with utxo set",
+			state: bestChainState{
+				hash:           *newHashFromStr("00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048"),
+				height:         1,
+				totalTxns:      2,
+				workSum:        new(big.Int).Set(workSum),
+				totalSize:      500,
+				totalSizeKnown: true,
+				utxoSetX:       big.NewInt(1),
+				utxoSetY:       big.NewInt(2),
+			},
+			serialized: hexToBytes("4860eb18bf1b1620e37e9490fc8a427514416fd75159ab86688e9a8300000000010000000200000000000000050000000200020002f401000000000000" +
+				"0000000000000000000000000000000000000000000000000000000000000001" +
+				"0000000000000000000000000000000000000000000000000000000000000002"),
 		},
 	}
 
@@ -762,6 +782,18 @@ func TestBestChainStateSerialization(t *testing.T) {
 
 		}
 	}
+
+	// The states stored before the total size was tracked are decoded
+	// with an unknown total size.
+	state, err := deserializeBestChainState(hexToBytes("6fe28c0ab6f1b372c1a6a246ae63f74f931e8365e15a089c68d6190000000000000000000100000000000000050000000100010001"))
+	if err != nil {
+		t.Fatalf("deserializeBestChainState (legacy) unexpected "+
+			"error: %v", err)
+	}
+	if state.totalTxns != 1 || state.totalSizeKnown {
+		t.Fatalf("deserializeBestChainState (legacy) mismatched "+
+			"state - got %v", state)
+	}
 }
 
 // TestBestChainStateDeserializeErrors performs negative tests against
@@ -10,6 +10,7 @@ import (
 
 	"github.com/bourbaki-czz/classzz/chaincfg"
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/database"
 	"github.com/bourbaki-czz/classzz/txscript"
 	"github.com/bourbaki-czz/czzutil"
 )
@@ -180,6 +181,16 @@ func isNonstandardTransaction(tx *czzutil.Tx) bool {
 	return false
 }
 
+// hasOrderedTimestamps returns whether the timestamp of the passed block is
+// neither before the one of its parent nor after the one of the passed next
+// block.
+func hasOrderedTimestamps(node, nextNode *blockNode) bool {
+	prevTime := time.Unix(node.parent.timestamp, 0)
+	curTime := time.Unix(node.timestamp, 0)
+	nextTime := time.Unix(nextNode.timestamp, 0)
+	return !prevTime.After(curTime) && !nextTime.Before(curTime)
+}
+
 // IsCheckpointCandidate returns whether or not the passed block is a good
 // checkpoint candidate.
 //
@@ -241,10 +252,7 @@ func (b *BlockChain) IsCheckpointCandidate(block *czzutil.Block) (bool, error) {
 	// A checkpoint must have timestamps for the block and the blocks on
 	// either side of it in order (due to the median time allowance this is
 	// not always the case).
-	prevTime := time.Unix(node.parent.timestamp, 0)
-	curTime := block.MsgBlock().Header.Timestamp
-	nextTime := time.Unix(nextNode.timestamp, 0)
-	if prevTime.After(curTime) || nextTime.Before(curTime) {
+	if !hasOrderedTimestamps(node, nextNode) {
 		return false, nil
 	}
 
@@ -259,3 +267,97 @@ func (b *BlockChain) IsCheckpointCandidate(block *czzutil.Block) (bool, error) {
 	// All of the checks passed, so the block is a candidate.
 	return true, nil
 }
+
+// GenerateCheckpointCandidates returns checkpoints for the blocks of the main
+// chain after the latest checkpoint, at least interval blocks apart, which
+// meet the criteria of IsCheckpointCandidate with the passed depth instead of
+// CheckpointConfirmations and which have no known fork, that is no other block
+// at their height in the block index, including the side chains only known by
+// their headers.  Each candidate is the first block meeting the criteria at
+// least interval blocks after the previous candidate or checkpoint.
+//
+// The transactions of the blocks which were pruned can't be checked, so the
+// blocks below the prune height only need to meet the other criteria.
+//
+// The intent is that candidates are reviewed by a developer to make the final
+// decision and then added to the list of checkpoints for a network.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) GenerateCheckpointCandidates(depth, interval int32) ([]chaincfg.Checkpoint, error) {
+	if depth < 1 || interval < 1 {
+		return nil, fmt.Errorf("the depth of %d and the interval of %d "+
+			"must be positive", depth, interval)
+	}
+
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	pruneHeight := b.PruneHeight()
+	return b.checkpointCandidates(depth, interval, func(node *blockNode) (bool, error) {
+		if node.height < pruneHeight {
+			return true, nil
+		}
+		var block *czzutil.Block
+		err := b.db.View(func(dbTx database.Tx) error {
+			var err error
+			block, err = b.fetchBlockByNode(dbTx, node)
+			return err
+		})
+		if err != nil {
+			return false, err
+		}
+		for _, tx := range block.Transactions() {
+			if isNonstandardTransaction(tx) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+// checkpointCandidates returns the checkpoint candidates as described by
+// GenerateCheckpointCandidates.  The passed function reports whether the
+// transactions of a block are all standard.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) checkpointCandidates(depth, interval int32, isStandard func(*blockNode) (bool, error)) ([]chaincfg.Checkpoint, error) {
+	// Collect the heights of the known forks.
+	b.index.RLock()
+	forkHeights := make(map[int32]struct{})
+	for _, n := range b.index.index {
+		if !b.bestChain.Contains(n) {
+			forkHeights[n.height] = struct{}{}
+		}
+	}
+	b.index.RUnlock()
+
+	height := int32(0)
+	if checkpoint := b.LatestCheckpoint(); checkpoint != nil {
+		height = checkpoint.Height
+	}
+	var candidates []chaincfg.Checkpoint
+	maxHeight := b.bestChain.Tip().height - depth
+	for height += interval; height <= maxHeight; height++ {
+		if _, ok := forkHeights[height]; ok {
+			continue
+		}
+		node := b.bestChain.NodeByHeight(height)
+		if !hasOrderedTimestamps(node, b.bestChain.NodeByHeight(height+1)) {
+			continue
+		}
+		standard, err := isStandard(node)
+		if err != nil {
+			return nil, err
+		}
+		if !standard {
+			continue
+		}
+
+		candidates = append(candidates, chaincfg.Checkpoint{
+			Height: node.height,
+			Hash:   &node.hash,
+		})
+		height += interval - 1
+	}
+	return candidates, nil
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+)
+
+// TestCheckpointCandidates ensures the checkpoint candidates are the first
+// blocks of the main chain buried deep enough after each interval which have
+// no known fork, ordered timestamps and only standard transactions.
+func TestCheckpointCandidates(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	chain := newFakeChain(&params)
+
+	// Build a main chain of 30 blocks with a side block at height 15 and a
+	// block at height 27 with a timestamp before the one of its parent.
+	nodes := make([]*blockNode, 31)
+	nodes[0] = chain.bestChain.Genesis()
+	for i := 1; i < len(nodes); i++ {
+		parent := nodes[i-1]
+		timestamp := time.Unix(parent.timestamp, 0).Add(time.Minute)
+		if i == 27 {
+			timestamp = time.Unix(parent.timestamp, 0).Add(-time.Second)
+		}
+		nodes[i] = newFakeNode(parent, 1, parent.bits, timestamp)
+		nodes[i].status = statusDataStored | statusValid
+		chain.index.AddNode(nodes[i])
+	}
+	chain.bestChain.SetTip(nodes[30])
+	side := newFakeNode(nodes[14], 1, nodes[14].bits,
+		time.Unix(nodes[14].timestamp, 0).Add(2*time.Minute))
+	chain.index.AddNode(side)
+
+	// The block at height 21 has a nonstandard transaction.
+	candidates, err := chain.checkpointCandidates(3, 5, func(node *blockNode) (bool, error) {
+		return node.height != 21, nil
+	})
+	if err != nil {
+		t.Fatalf("checkpointCandidates: unexpected error: %v", err)
+	}
+	wantHeights := []int32{5, 10, 16, 22}
+	if len(candidates) != len(wantHeights) {
+		t.Fatalf("got %d candidates, want %d", len(candidates),
+			len(wantHeights))
+	}
+	for i, candidate := range candidates {
+		want := nodes[wantHeights[i]]
+		if candidate.Height != want.height || *candidate.Hash != want.hash {
+			t.Errorf("got candidate %d at height %d, want height %d",
+				i, candidate.Height, want.height)
+		}
+	}
+
+	// The candidates start after the latest checkpoint.
+	chain.checkpoints = []chaincfg.Checkpoint{{Height: 12, Hash: &nodes[12].hash}}
+	candidates, err = chain.checkpointCandidates(3, 5, func(node *blockNode) (bool, error) {
+		return true, nil
+	})
+	if err != nil {
+		t.Fatalf("checkpointCandidates: unexpected error: %v", err)
+	}
+	if len(candidates) != 2 || candidates[0].Height != 17 ||
+		candidates[1].Height != 22 {
+		t.Errorf("got candidates %v, want the blocks at heights 17 and 22",
+			candidates)
+	}
+}
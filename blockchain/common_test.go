@@ -358,14 +358,11 @@ func newFakeChain(params *chaincfg.Params) *BlockChain {
 	index.AddNode(node)
 
 	targetTimespan := int64(params.TargetTimespan / time.Second)
-	targetTimePerBlock := int64(params.TargetTimePerBlock / time.Second)
 	adjustmentFactor := params.RetargetAdjustmentFactor
 	return &BlockChain{
 		chainParams:         params,
 		timeSource:          NewMedianTime(),
-		minRetargetTimespan: targetTimespan / adjustmentFactor,
 		maxRetargetTimespan: targetTimespan * adjustmentFactor,
-		blocksPerRetarget:   int32(targetTimespan / targetTimePerBlock),
 		index:               index,
 		bestChain:           newChainView(node),
 		warningCaches:       newThresholdCaches(vbNumBits),
@@ -0,0 +1,115 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+)
+
+// DifficultyEntry is the difficulty of a block of the main chain along with the
+// hashrate estimated from the blocks before it.  The difficulty is adjusted at
+// every block, so the hashrate is estimated from the work of the blocks instead
+// of from the difficulty at some retarget interval.
+type DifficultyEntry struct {
+	// Height and Hash identify the block.
+	Height int32
+	Hash   chainhash.Hash
+
+	// Timestamp is the time of the block as set by its miner.
+	Timestamp time.Time
+
+	// Bits is the difficulty target of the block in compact form.
+	Bits uint32
+
+	// Work is the expected number of hashes needed to find the block and
+	// ChainWork the total of the main chain up to and including it.
+	Work      *big.Int
+	ChainWork *big.Int
+
+	// HashesPerSec is the hashrate estimated from the window of blocks
+	// ending with the block.
+	HashesPerSec float64
+}
+
+// estimateHashRate returns the number of hashes per second estimated from the
+// work of the window of blocks of the passed size ending with the passed node,
+// excluding the work of the first block of the window, over the time between
+// the earliest and the latest timestamps of the window.  Zero is returned when
+// the window has no time span.
+func estimateHashRate(node *blockNode, window int32) float64 {
+	start := node.Ancestor(node.height - window)
+	if start == nil {
+		start = node.Ancestor(0)
+	}
+
+	minTimestamp, maxTimestamp := node.timestamp, node.timestamp
+	for n := node.parent; n != nil && n.height >= start.height; n = n.parent {
+		if n.timestamp < minTimestamp {
+			minTimestamp = n.timestamp
+		}
+		if n.timestamp > maxTimestamp {
+			maxTimestamp = n.timestamp
+		}
+	}
+	timeDiff := maxTimestamp - minTimestamp
+	if timeDiff <= 0 {
+		return 0
+	}
+
+	work := new(big.Int).Sub(node.workSum, start.workSum)
+	hashesPerSec, _ := new(big.Float).Quo(new(big.Float).SetInt(work),
+		new(big.Float).SetInt64(timeDiff)).Float64()
+	return hashesPerSec
+}
+
+// EstimateHashRate returns the number of hashes per second estimated from the
+// window of blocks of the passed size ending at the passed height of the main
+// chain.  The window stops at the genesis block.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) EstimateHashRate(height, window int32) (float64, error) {
+	node := b.bestChain.NodeByHeight(height)
+	if node == nil {
+		return 0, fmt.Errorf("no block at height %d exists", height)
+	}
+	return estimateHashRate(node, window), nil
+}
+
+// DifficultyHistory returns the difficulties of at most the passed number of
+// blocks of the main chain from the passed height, stopping at the best block.
+// The hashrates are estimated from windows of the passed size.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) DifficultyHistory(startHeight, count, window int32) ([]DifficultyEntry, error) {
+	tip := b.bestChain.Tip()
+	if startHeight < 0 || startHeight > tip.height {
+		return nil, fmt.Errorf("no block at height %d exists", startHeight)
+	}
+	if count <= 0 {
+		return nil, nil
+	}
+	endNode := tip
+	if count < tip.height-startHeight+1 {
+		endNode = tip.Ancestor(startHeight + count - 1)
+	}
+
+	entries := make([]DifficultyEntry, endNode.height-startHeight+1)
+	for node := endNode; node != nil && node.height >= startHeight; node = node.parent {
+		entries[node.height-startHeight] = DifficultyEntry{
+			Height:       node.height,
+			Hash:         node.hash,
+			Timestamp:    time.Unix(node.timestamp, 0),
+			Bits:         node.bits,
+			Work:         CalcWork(node.bits),
+			ChainWork:    new(big.Int).Set(node.workSum),
+			HashesPerSec: estimateHashRate(node, window),
+		}
+	}
+	return entries, nil
+}
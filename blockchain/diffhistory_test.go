@@ -0,0 +1,85 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+)
+
+// TestDifficultyHistory ensures the difficulties of the main chain are
+// reported per height and the hashrate is estimated from the work of the
+// window of blocks ending with each block.
+func TestDifficultyHistory(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	chain := newFakeChain(&params)
+
+	// Extend the genesis block with blocks a minute apart.
+	tip := chain.bestChain.Tip()
+	bits := tip.bits
+	for i := 0; i < 10; i++ {
+		node := newFakeNode(tip, 1, bits, time.Unix(tip.timestamp, 0).
+			Add(time.Minute))
+		chain.index.AddNode(node)
+		tip = node
+	}
+	chain.bestChain.SetTip(tip)
+
+	work := CalcWork(bits)
+	wantRate, _ := new(big.Float).Quo(new(big.Float).SetInt(work),
+		big.NewFloat(60)).Float64()
+
+	hashRate, err := chain.EstimateHashRate(10, 5)
+	if err != nil {
+		t.Fatalf("EstimateHashRate: unexpected error: %v", err)
+	}
+	if hashRate != wantRate {
+		t.Errorf("EstimateHashRate: got %v, want %v", hashRate, wantRate)
+	}
+	if _, err := chain.EstimateHashRate(11, 5); err == nil {
+		t.Errorf("EstimateHashRate: no error for a height past the tip")
+	}
+
+	// The window stops at the genesis block and the count at the tip.
+	entries, err := chain.DifficultyHistory(0, 20, 120)
+	if err != nil {
+		t.Fatalf("DifficultyHistory: unexpected error: %v", err)
+	}
+	if len(entries) != 11 {
+		t.Fatalf("DifficultyHistory: got %d entries, want 11", len(entries))
+	}
+	if entries[0].HashesPerSec != 0 {
+		t.Errorf("genesis: got hashrate %v, want 0", entries[0].HashesPerSec)
+	}
+	for i, entry := range entries {
+		node := chain.bestChain.NodeByHeight(int32(i))
+		if entry.Height != int32(i) || entry.Hash != node.hash {
+			t.Fatalf("entry %d: got block %d (%v)", i, entry.Height,
+				entry.Hash)
+		}
+		if entry.ChainWork.Cmp(node.workSum) != 0 {
+			t.Errorf("entry %d: got chainwork %v, want %v", i,
+				entry.ChainWork, node.workSum)
+		}
+		if i > 0 && entry.HashesPerSec != wantRate {
+			t.Errorf("entry %d: got hashrate %v, want %v", i,
+				entry.HashesPerSec, wantRate)
+		}
+	}
+
+	entries, err = chain.DifficultyHistory(8, 2, 1)
+	if err != nil {
+		t.Fatalf("DifficultyHistory: unexpected error: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Height != 8 || entries[1].Height != 9 {
+		t.Errorf("DifficultyHistory: unexpected entries %v", entries)
+	}
+	if _, err := chain.DifficultyHistory(11, 1, 1); err == nil {
+		t.Errorf("DifficultyHistory: no error for a height past the tip")
+	}
+}
@@ -5,9 +5,11 @@
 package blockchain
 
 import (
+	"fmt"
 	"math/big"
 	"time"
 
+	"github.com/bourbaki-czz/classzz/chaincfg"
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
 )
 
@@ -36,23 +38,58 @@ var (
 // to fetch the previous two.
 const DifficultyAdjustmentWindow = 144
 
-// The DifficultyAlgorithm specifies which algorithm to use and is passed into
-// the calcNextRequiredDifficulty function.
-//
-// Bitcoin Cash has had three different difficulty adjustment algorithms during
-// its life. What this means for us is our node needs to select which algorithm
-// to use when calculating difficulty based on where it is in the chain.
-type DifficultyAlgorithm uint32
+// DifficultyAlgorithm specifies which algorithm calcNextRequiredDifficulty uses.
+// The network parameters select the algorithm of each block with their
+// difficulty schedule, so the node computes the difficulty of the blocks of
+// the whole chain with the algorithms which were in effect when they were
+// mined.
+type DifficultyAlgorithm = chaincfg.DifficultyAlgorithm
 
 const (
-	// DifficultyLegacy was in effect from genesis through August 1st, 2017.
-	DifficultyLegacy DifficultyAlgorithm = 0
+	// DifficultyLegacy is in effect from genesis unless the difficulty
+	// schedule of the network activates another algorithm.
+	DifficultyLegacy = chaincfg.DifficultyLegacy
+
+	// DifficultyASERT is the aserti3-2d algorithm.
+	DifficultyASERT = chaincfg.DifficultyASERT
+
+	// DifficultyRollingWindow is the cw-144 algorithm.
+	DifficultyRollingWindow = chaincfg.DifficultyRollingWindow
 )
 
+// difficultyFunc calculates the required difficulty for the block after the
+// passed previous block node with the passed timestamp.  The activation height
+// is the height of the first block the algorithm computes the difficulty of.
+type difficultyFunc func(b *BlockChain, lastNode *blockNode, newBlockTime time.Time, activationHeight int32) (uint32, error)
+
+// difficultyAlgorithms are the implementations of the difficulty adjustment
+// algorithms.
+var difficultyAlgorithms = map[DifficultyAlgorithm]difficultyFunc{
+	DifficultyLegacy:        (*BlockChain).calcLegacyDifficulty,
+	DifficultyASERT:         (*BlockChain).calcASERTDifficulty,
+	DifficultyRollingWindow: (*BlockChain).calcRollingWindowDifficulty,
+}
+
+// checkDifficultySchedule returns an error when the difficulty schedule of the
+// passed network parameters can not be followed or activates an algorithm
+// which is not implemented.
+func checkDifficultySchedule(params *chaincfg.Params) error {
+	if err := params.CheckDifficultySchedule(); err != nil {
+		return err
+	}
+	for _, activation := range params.DifficultySchedule {
+		if _, ok := difficultyAlgorithms[activation.Algorithm]; !ok {
+			return fmt.Errorf("difficulty algorithm %v is not "+
+				"implemented", activation.Algorithm)
+		}
+	}
+	return nil
+}
+
 // SelectDifficultyAdjustmentAlgorithm returns the difficulty adjustment algorithm that
 // should be used when validating a block at the given height.
 func (b *BlockChain) SelectDifficultyAdjustmentAlgorithm(height int32) DifficultyAlgorithm {
-	return DifficultyLegacy
+	return b.chainParams.DifficultyActivation(height).Algorithm
 }
 
 // HashToBig converts a chainhash.Hash into a big.Int that can be used to
@@ -250,6 +287,22 @@ func (b *BlockChain) calcNextRequiredDifficulty(lastNode *blockNode, newBlockTim
 		return lastNode.bits, nil
 	}
 
+	activation := b.chainParams.DifficultyActivation(lastNode.height + 1)
+	calcDifficulty, ok := difficultyAlgorithms[activation.Algorithm]
+	if !ok {
+		return 0, AssertError(fmt.Sprintf("difficulty algorithm %v is "+
+			"not implemented", activation.Algorithm))
+	}
+	return calcDifficulty(b, lastNode, newBlockTime, activation.Height)
+}
+
+// calcLegacyDifficulty calculates the required difficulty for the block after
+// the passed previous block node with the DifficultyLegacy algorithm.  The
+// difficulty of the parent block is raised by a 1/128th step when the block
+// comes less than 30 seconds after its parent, kept when it comes less than 60
+// seconds after it, and lowered by a step for every further 30 seconds, up to
+// 99 steps.
+func (b *BlockChain) calcLegacyDifficulty(lastNode *blockNode, newBlockTime time.Time, activationHeight int32) (uint32, error) {
 	bigTime := new(big.Int).SetInt64(newBlockTime.Unix())
 	bigParentTime := new(big.Int).SetInt64(lastNode.timestamp)
 
@@ -291,6 +344,147 @@ func (b *BlockChain) calcNextRequiredDifficulty(lastNode *blockNode, newBlockTim
 	return BigToCompact(newTarget), nil
 }
 
+// minDifficultyAllowed returns whether the block after the passed previous
+// block node with the passed timestamp is allowed to use the minimum
+// difficulty.  Networks with ReduceMinDifficulty allow it once no block was
+// found for MinDiffReductionTime, so a test network whose hash rate left can
+// still move on.  DifficultyLegacy does not follow this rule.
+func (b *BlockChain) minDifficultyAllowed(lastNode *blockNode, newBlockTime time.Time) bool {
+	if !b.chainParams.ReduceMinDifficulty {
+		return false
+	}
+	reductionTime := int64(b.chainParams.MinDiffReductionTime / time.Second)
+	return newBlockTime.Unix() > lastNode.timestamp+reductionTime
+}
+
+// asertRadix is the fixed point precision of the exponent of ASERT.
+const asertRadix = 1 << 16
+
+// calcASERTTarget returns the ASERT target of a block from the target of the
+// anchor block, the time between the timestamp of the parent of the anchor
+// block and the timestamp of the parent of the block, and the difference of
+// the height of the parent of the block and of the anchor block.  The target
+// time per block and the half life are in seconds.  The result is clipped to
+// the passed proof of work limit.
+//
+// The exponential is approximated with a cubic polynomial in fixed point
+// arithmetic so every node computes the same target.
+func calcASERTTarget(anchorTarget, powLimit *big.Int, targetSpacing, halfLife, timeDelta, heightDelta int64) *big.Int {
+	// The exponent is truncated toward zero, as aserti3-2d does, so a chain
+	// less than a unit of the exponent ahead of its schedule keeps the
+	// target.
+	exponent := (timeDelta - targetSpacing*(heightDelta+1)) * asertRadix /
+		halfLife
+
+	// Split the exponent into an integer number of shifts, which is
+	// floored, and a remainder in [0, 1) the polynomial approximates 2^x - 1
+	// of.  The polynomial of the largest remainder fits in 64 bits.
+	numShifts := exponent >> 16
+	frac := uint64(exponent - numShifts*asertRadix)
+	factor := ((195766423245049*frac+971821376*frac*frac+
+		5127*frac*frac*frac+1<<47)>>48 + asertRadix)
+
+	target := new(big.Int).Mul(anchorTarget, new(big.Int).SetUint64(factor))
+	if numShifts < 0 {
+		target.Rsh(target, uint(-numShifts))
+	} else {
+		target.Lsh(target, uint(numShifts))
+	}
+	target.Rsh(target, 16)
+
+	if target.Sign() == 0 {
+		return big.NewInt(1)
+	}
+	if target.Cmp(powLimit) > 0 {
+		return new(big.Int).Set(powLimit)
+	}
+	return target
+}
+
+// calcASERTDifficulty calculates the required difficulty for the block after
+// the passed previous block node with the DifficultyASERT algorithm.  The
+// anchor block is the parent of the first block of the algorithm.
+func (b *BlockChain) calcASERTDifficulty(lastNode *blockNode, newBlockTime time.Time, activationHeight int32) (uint32, error) {
+	if b.minDifficultyAllowed(lastNode, newBlockTime) {
+		return b.chainParams.PowLimitBits, nil
+	}
+
+	anchor := lastNode.Ancestor(activationHeight - 1)
+	if anchor == nil {
+		return 0, AssertError("unable to obtain the ASERT anchor block")
+	}
+
+	// The genesis block has no parent, so its parent is considered on
+	// schedule.
+	targetSpacing := int64(b.chainParams.TargetTimePerBlock / time.Second)
+	anchorParentTime := anchor.timestamp - targetSpacing
+	if anchor.parent != nil {
+		anchorParentTime = anchor.parent.timestamp
+	}
+
+	target := calcASERTTarget(CompactToBig(anchor.bits),
+		b.chainParams.PowLimit, targetSpacing,
+		int64(b.chainParams.ASERTHalfLife/time.Second),
+		lastNode.timestamp-anchorParentTime,
+		int64(lastNode.height-anchor.height))
+	return BigToCompact(target), nil
+}
+
+// calcRollingWindowDifficulty calculates the required difficulty for the
+// block after the passed previous block node with the DifficultyRollingWindow
+// algorithm.  The target is the one which would have taken the work done
+// during the most recent DifficultyAdjustmentWindow blocks to be done in the
+// target time per block, the time span of the window being clamped between
+// half and twice the one expected so a few blocks can not move the difficulty
+// too far.  The window reaches before the activation height, so the blocks
+// near the genesis block, which have a window too short, use the minimum
+// difficulty.
+func (b *BlockChain) calcRollingWindowDifficulty(lastNode *blockNode, newBlockTime time.Time, activationHeight int32) (uint32, error) {
+	if b.minDifficultyAllowed(lastNode, newBlockTime) {
+		return b.chainParams.PowLimitBits, nil
+	}
+
+	// The first block of the window needs two parents to pick the median
+	// of.
+	if lastNode.height < DifficultyAdjustmentWindow+2 {
+		return b.chainParams.PowLimitBits, nil
+	}
+	lastSuitable, err := b.getSuitableBlock(lastNode)
+	if err != nil {
+		return 0, err
+	}
+	firstSuitable, err := b.getSuitableBlock(
+		lastNode.RelativeAncestor(DifficultyAdjustmentWindow))
+	if err != nil {
+		return 0, err
+	}
+
+	targetSpacing := int64(b.chainParams.TargetTimePerBlock / time.Second)
+	timespan := lastSuitable.timestamp - firstSuitable.timestamp
+	if minTimespan := DifficultyAdjustmentWindow / 2 * targetSpacing; timespan < minTimespan {
+		timespan = minTimespan
+	}
+	if maxTimespan := DifficultyAdjustmentWindow * 2 * targetSpacing; timespan > maxTimespan {
+		timespan = maxTimespan
+	}
+
+	// work = (lastWorkSum - firstWorkSum) * targetSpacing / timespan
+	// target = (2^256 - work) / work
+	work := new(big.Int).Sub(lastSuitable.workSum, firstSuitable.workSum)
+	work.Mul(work, big.NewInt(targetSpacing))
+	work.Div(work, big.NewInt(timespan))
+	if work.Sign() <= 0 {
+		return b.chainParams.PowLimitBits, nil
+	}
+	target := new(big.Int).Sub(oneLsh256, work)
+	target.Div(target, work)
+
+	if target.Cmp(b.chainParams.PowLimit) > 0 {
+		target.Set(b.chainParams.PowLimit)
+	}
+	return BigToCompact(target), nil
+}
+
 // CalcNextRequiredDifficulty calculates the required difficulty for the block
 // after the end of the current best chain based on the difficulty retarget
 // rules.
@@ -7,6 +7,9 @@ package blockchain
 import (
 	"math/big"
 	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
 )
 
 // TestBigToCompact ensures BigToCompact converts big integers to the expected
@@ -73,3 +76,318 @@ func TestCalcWork(t *testing.T) {
 		}
 	}
 }
+
+// extendFakeChain returns the last of the passed number of fake block nodes
+// extending the passed node with the passed bits, each one the passed number
+// of seconds after its parent.
+func extendFakeChain(parent *blockNode, numNodes int, bits uint32, spacing int64) *blockNode {
+	node := parent
+	for i := 0; i < numNodes; i++ {
+		timestamp := time.Unix(node.timestamp+spacing, 0)
+		node = newFakeNode(node, 1, bits, timestamp)
+	}
+	return node
+}
+
+// closeTargets returns whether the passed targets differ by less than the
+// precision of the compact representation and of the approximations of the
+// algorithms.
+func closeTargets(got, want *big.Int) bool {
+	diff := new(big.Int).Sub(got, want)
+	diff.Abs(diff).Lsh(diff, 12)
+	return diff.Cmp(want) <= 0
+}
+
+// TestCalcASERTTarget ensures the ASERT target doubles or halves for every half
+// life the chain is behind or ahead of its schedule and is clipped to the
+// proof of work limit.
+func TestCalcASERTTarget(t *testing.T) {
+	const spacing, halfLife = 600, 2 * 24 * 3600
+	anchor := CompactToBig(0x1c0ffff0)
+	powLimit := chaincfg.TestNet3Params.PowLimit
+
+	tests := []struct {
+		name        string
+		timeDelta   int64
+		heightDelta int64
+		want        *big.Int
+	}{
+		{"anchor", spacing, 0, anchor},
+		{"on schedule", 101 * spacing, 100, anchor},
+		{"one half life behind", 101*spacing + halfLife, 100,
+			new(big.Int).Lsh(anchor, 1)},
+		{"one half life ahead", 101*spacing - halfLife, 100,
+			new(big.Int).Rsh(anchor, 1)},
+		{"ten half lives ahead", 101*spacing - 10*halfLife, 100,
+			new(big.Int).Rsh(anchor, 10)},
+		{"half a half life behind", 101*spacing + halfLife/2, 100,
+			new(big.Int).Div(new(big.Int).Mul(anchor,
+				big.NewInt(1414214)), big.NewInt(1000000))},
+		{"far behind", 101*spacing + 100*halfLife, 100, powLimit},
+		{"far ahead", 101*spacing - 300*halfLife, 100, big.NewInt(1)},
+	}
+	for _, test := range tests {
+		got := calcASERTTarget(anchor, powLimit, spacing, halfLife,
+			test.timeDelta, test.heightDelta)
+		if !closeTargets(got, test.want) {
+			t.Errorf("%s: got target %x, want %x", test.name, got,
+				test.want)
+		}
+	}
+}
+
+// TestCalcASERTTargetVectors ensures the ASERT target of the block after the
+// block with the given height and timestamp matches the aserti3-2d one for the
+// given anchor block, in particular that the exponent is truncated toward zero
+// when the chain is ahead of its schedule.  The expected targets are exact
+// multiples of the target of the anchor block.
+func TestCalcASERTTargetVectors(t *testing.T) {
+	const spacing, halfLife = 600, 2 * 24 * 3600
+	powLimit := chaincfg.TestNet3Params.PowLimit
+
+	tests := []struct {
+		anchorBits       uint32
+		anchorHeight     int64
+		anchorParentTime int64
+		height           int64
+		time             int64
+		bits             uint32
+	}{
+		{0x1c0ffff0, 1, 0, 1, 600, 0x1c0ffff0},
+		{0x1c0ffff0, 1, 0, 101, 60600, 0x1c0ffff0},
+		{0x1c0ffff0, 1, 0, 101, 60599, 0x1c0ffff0},
+		{0x1c0ffff0, 1, 0, 101, 60601, 0x1c0ffff0},
+		{0x1c0ffff0, 1, 0, 101, 60600 - halfLife, 0x1c07fff8},
+		{0x1c0ffff0, 1, 0, 101, 60600 - halfLife - 1, 0x1c07fff8},
+		{0x1c0ffff0, 1, 0, 101, 60600 - 2*halfLife, 0x1c03fffc},
+		{0x1c0ffff0, 1, 0, 101, 60600 - 2*halfLife - 2, 0x1c03fffc},
+		{0x1c0ffff0, 1, 0, 101, 60600 + halfLife, 0x1c1fffe0},
+		{0x1c0ffff0, 1, 0, 101, 60600 + halfLife + 1, 0x1c1fffe0},
+		{0x1b0404cb, 1000, 1500000000, 1288, 1500173400, 0x1b0404cb},
+		{0x1b0404cb, 1000, 1500000000, 1288, 1500000600, 0x1b020265},
+		{0x1b0404cb, 1000, 1500000000, 1288, 1500000599, 0x1b020265},
+	}
+	for i, test := range tests {
+		got := calcASERTTarget(CompactToBig(test.anchorBits), powLimit,
+			spacing, halfLife, test.time-test.anchorParentTime,
+			test.height-test.anchorHeight)
+		if bits := BigToCompact(got); bits != test.bits {
+			t.Errorf("#%d: got bits %08x, want %08x", i, bits,
+				test.bits)
+		}
+	}
+}
+
+// TestDifficultySchedule ensures the difficulty of each block is calculated by
+// the algorithm the difficulty schedule selects at its height, including at
+// the activation heights.
+func TestDifficultySchedule(t *testing.T) {
+	const bits = 0x1c0ffff0
+	params := chaincfg.TestNet3Params
+	params.ASERTHalfLife = 2 * 24 * time.Hour
+	params.DifficultySchedule = []chaincfg.DifficultyActivation{
+		{Height: 150, Algorithm: DifficultyRollingWindow},
+		{Height: 300, Algorithm: DifficultyASERT},
+	}
+	if err := checkDifficultySchedule(&params); err != nil {
+		t.Fatalf("checkDifficultySchedule: unexpected error: %v", err)
+	}
+	chain := newFakeChain(&params)
+	spacing := int64(params.TargetTimePerBlock / time.Second)
+
+	for height, want := range map[int32]DifficultyAlgorithm{
+		0:   DifficultyLegacy,
+		149: DifficultyLegacy,
+		150: DifficultyRollingWindow,
+		299: DifficultyRollingWindow,
+		300: DifficultyASERT,
+	} {
+		if got := chain.SelectDifficultyAdjustmentAlgorithm(height); got != want {
+			t.Errorf("SelectDifficultyAdjustmentAlgorithm(%d): got %v, "+
+				"want %v", height, got, want)
+		}
+	}
+
+	// The blocks come slower than expected so the algorithms all lower the
+	// difficulty but by different amounts.
+	nodes := []*blockNode{chain.bestChain.Tip()}
+	for i := 1; i < 300; i++ {
+		nodes = append(nodes, extendFakeChain(nodes[i-1], 1, bits,
+			3*spacing/2))
+	}
+	tests := []struct {
+		height int32
+		calc   difficultyFunc
+	}{
+		{149, (*BlockChain).calcLegacyDifficulty},
+		{150, (*BlockChain).calcRollingWindowDifficulty},
+		{299, (*BlockChain).calcRollingWindowDifficulty},
+		{300, (*BlockChain).calcASERTDifficulty},
+	}
+	for _, test := range tests {
+		lastNode := nodes[test.height-1]
+		timestamp := time.Unix(lastNode.timestamp+spacing, 0)
+		got, err := chain.calcNextRequiredDifficulty(lastNode, timestamp)
+		if err != nil {
+			t.Fatalf("block %d: unexpected error: %v", test.height, err)
+		}
+		activation := params.DifficultyActivation(test.height)
+		want, err := test.calc(chain, lastNode, timestamp,
+			activation.Height)
+		if err != nil {
+			t.Fatalf("block %d: unexpected error: %v", test.height, err)
+		}
+		if got != want {
+			t.Errorf("block %d: got bits %08x, want %08x", test.height,
+				got, want)
+		}
+		if CompactToBig(got).Cmp(CompactToBig(bits)) <= 0 {
+			t.Errorf("block %d: bits %08x did not lower the "+
+				"difficulty", test.height, got)
+		}
+	}
+
+	params.DifficultySchedule = []chaincfg.DifficultyActivation{
+		{Height: 10, Algorithm: 100},
+	}
+	if err := checkDifficultySchedule(&params); err == nil {
+		t.Errorf("checkDifficultySchedule: no error for an unknown " +
+			"algorithm")
+	}
+	if _, err := chain.calcNextRequiredDifficulty(nodes[20],
+		time.Unix(nodes[20].timestamp, 0)); err == nil {
+
+		t.Errorf("calcNextRequiredDifficulty: no error for an unknown " +
+			"algorithm")
+	}
+}
+
+// TestRollingWindowDifficulty ensures the rolling window target follows the
+// time span of the window within its bounds, uses the minimum difficulty until
+// the window is complete, and that the test network minimum difficulty rule
+// applies to it.
+func TestRollingWindowDifficulty(t *testing.T) {
+	const bits = 0x1b0ffff0
+	params := chaincfg.TestNet3Params
+	chain := newFakeChain(&params)
+	genesis := chain.bestChain.Tip()
+	spacing := int64(params.TargetTimePerBlock / time.Second)
+	target := CompactToBig(bits)
+
+	tests := []struct {
+		name      string
+		numBlocks int
+		spacing   int64
+		want      *big.Int
+	}{
+		{"on schedule", 200, spacing, target},
+		{"slower", 200, 3 * spacing / 2, new(big.Int).Div(
+			new(big.Int).Mul(target, big.NewInt(3)), big.NewInt(2))},
+		{"twice as fast", 200, spacing / 2, new(big.Int).Rsh(target, 1)},
+		{"four times as fast", 200, spacing / 4, new(big.Int).Rsh(target, 1)},
+		{"four times as slow", 200, 4 * spacing, new(big.Int).Lsh(target, 1)},
+		{"complete window", DifficultyAdjustmentWindow + 2, spacing, target},
+		{"incomplete window", DifficultyAdjustmentWindow + 1, spacing,
+			params.PowLimit},
+	}
+	for _, test := range tests {
+		lastNode := extendFakeChain(genesis, test.numBlocks, bits,
+			test.spacing)
+		timestamp := time.Unix(lastNode.timestamp+spacing, 0)
+		got, err := chain.calcRollingWindowDifficulty(lastNode, timestamp, 0)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if !closeTargets(CompactToBig(got), test.want) {
+			t.Errorf("%s: got target %x, want %x", test.name,
+				CompactToBig(got), test.want)
+		}
+	}
+
+	// The minimum difficulty is allowed once no block was found for the
+	// reduction time.
+	lastNode := extendFakeChain(genesis, 200, bits, spacing)
+	reductionTime := int64(params.MinDiffReductionTime / time.Second)
+	for _, test := range []struct {
+		delay      int64
+		reduceDiff bool
+	}{
+		{reductionTime, false},
+		{reductionTime + 1, true},
+	} {
+		for _, reduceDiff := range []bool{true, false} {
+			params.ReduceMinDifficulty = reduceDiff
+			got, err := chain.calcRollingWindowDifficulty(lastNode,
+				time.Unix(lastNode.timestamp+test.delay, 0), 0)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			wantMin := reduceDiff && test.reduceDiff
+			if (got == params.PowLimitBits) != wantMin {
+				t.Errorf("delay %d with ReduceMinDifficulty %v: "+
+					"got bits %08x", test.delay, reduceDiff, got)
+			}
+		}
+	}
+}
+
+// TestASERTDifficulty ensures the ASERT target is anchored to the parent of the
+// first block of the algorithm and that the test network minimum difficulty
+// rule applies to it.
+func TestASERTDifficulty(t *testing.T) {
+	const bits = 0x1c0ffff0
+	params := chaincfg.TestNet3Params
+	params.ASERTHalfLife = time.Hour
+	chain := newFakeChain(&params)
+	spacing := int64(params.TargetTimePerBlock / time.Second)
+	halfLife := int64(params.ASERTHalfLife / time.Second)
+
+	// The anchor is followed by blocks with other bits on schedule, so
+	// the target stays the one of the anchor.
+	anchor := extendFakeChain(chain.bestChain.Tip(), 9, bits, spacing)
+	lastNode := extendFakeChain(anchor, 20, 0x1d00ffff, spacing)
+	for _, node := range []*blockNode{anchor, lastNode} {
+		got, err := chain.calcASERTDifficulty(node,
+			time.Unix(node.timestamp+spacing, 0), 10)
+		if err != nil {
+			t.Fatalf("height %d: unexpected error: %v", node.height, err)
+		}
+		if got != bits {
+			t.Errorf("height %d: got bits %08x, want %08x",
+				node.height, got, uint32(bits))
+		}
+	}
+
+	// A chain one half life behind its schedule doubles the target.
+	lastNode = extendFakeChain(lastNode, 1, bits, spacing+halfLife)
+	got, err := chain.calcASERTDifficulty(lastNode,
+		time.Unix(lastNode.timestamp+spacing, 0), 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := new(big.Int).Lsh(CompactToBig(bits), 1)
+	if !closeTargets(CompactToBig(got), want) {
+		t.Errorf("one half life behind: got target %x, want %x",
+			CompactToBig(got), want)
+	}
+
+	// The timestamp of the block itself only matters to the minimum
+	// difficulty rule.
+	params.ReduceMinDifficulty = true
+	reductionTime := int64(params.MinDiffReductionTime / time.Second)
+	got, err = chain.calcASERTDifficulty(lastNode,
+		time.Unix(lastNode.timestamp+reductionTime+1, 0), 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != params.PowLimitBits {
+		t.Errorf("minimum difficulty: got bits %08x, want %08x", got,
+			params.PowLimitBits)
+	}
+
+	if _, err := chain.calcASERTDifficulty(anchor.parent,
+		time.Unix(anchor.timestamp, 0), 10); err == nil {
+
+		t.Errorf("calcASERTDifficulty: no error without an anchor block")
+	}
+}
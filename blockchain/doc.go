@@ -48,6 +48,9 @@ is by no means exhaustive:
    difficulty retarget rules, timestamps are after the median of the last
    several blocks, all transactions are finalized, checkpoint blocks match, and
    block versions are in line with the previous blocks
+ - Store the block and its status in the block database, whether it extends
+   the main chain or a side chain, so the side chains survive restarts and a
+   reorganization does not need to download their blocks again
  - Determine how the block fits into the chain and perform different actions
    accordingly in order to ensure any side chains which have higher difficulty
    than the main chain become the new main chain
@@ -59,7 +62,7 @@ is by no means exhaustive:
    transaction values
  - Run the transaction scripts to verify the spender is allowed to spend the
    coins
- - Insert the block into the block database
+ - Update the status of the block in the block database
 
 Errors
 
@@ -223,6 +223,40 @@ const (
 	// ErrInvalidTxOrder indicates the order of the transactions in the block
 	// does not follow the active transaction ordering consensus rule.
 	ErrInvalidTxOrder
+
+	// ErrTxTooManySigChecks indicates a transaction executes more signature
+	// checks than the maximum allowed once signature check accounting is
+	// active.
+	ErrTxTooManySigChecks
+
+	// ErrTooManySigChecks indicates the total number of signature checks
+	// executed by the transactions in a block exceeds the maximum allowed
+	// for its size once signature check accounting is active.
+	ErrTooManySigChecks
+
+	// ErrBadSignetSolution indicates the coinbase of a block on a network
+	// with a block signature challenge does not carry a solution, or the
+	// solution does not satisfy the challenge.
+	ErrBadSignetSolution
+
+	// ErrBadUtxoCommitment indicates the coinbase of a block does not
+	// commit to the hash of the utxo set the block is built on once the
+	// utxo commitment deployment is active.
+	ErrBadUtxoCommitment
+
+	// ErrBadPoolOutput indicates the coinbase of a block does not pay the
+	// expected balances to the coin pools in its second and third outputs
+	// once the entangle height is reached.
+	ErrBadPoolOutput
+
+	// ErrBadEntangleAmount indicates the amounts the coinbase of a block
+	// pays out for the entangle transactions of the block do not match the
+	// converted amounts of their entangle outputs.
+	ErrBadEntangleAmount
+
+	// ErrHeadersNotContiguous indicates a header of a batch of headers
+	// does not extend the header before it.
+	ErrHeadersNotContiguous
 )
 
 // Map of ErrorCode values back to their constant names for pretty printing.
@@ -270,6 +304,13 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrInvalidAncestorBlock:  "ErrInvalidAncestorBlock",
 	ErrPrevBlockNotBest:      "ErrPrevBlockNotBest",
 	ErrInvalidTxOrder:        "ErrInvalidTxOrder",
+	ErrTxTooManySigChecks:    "ErrTxTooManySigChecks",
+	ErrTooManySigChecks:      "ErrTooManySigChecks",
+	ErrBadSignetSolution:     "ErrBadSignetSolution",
+	ErrBadUtxoCommitment:     "ErrBadUtxoCommitment",
+	ErrBadPoolOutput:         "ErrBadPoolOutput",
+	ErrBadEntangleAmount:     "ErrBadEntangleAmount",
+	ErrHeadersNotContiguous:  "ErrHeadersNotContiguous",
 }
 
 // String returns the ErrorCode as a human-readable name.
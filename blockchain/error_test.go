@@ -55,6 +55,14 @@ func TestErrorCodeStringer(t *testing.T) {
 		{ErrPreviousBlockUnknown, "ErrPreviousBlockUnknown"},
 		{ErrInvalidAncestorBlock, "ErrInvalidAncestorBlock"},
 		{ErrPrevBlockNotBest, "ErrPrevBlockNotBest"},
+		{ErrInvalidTxOrder, "ErrInvalidTxOrder"},
+		{ErrTxTooManySigChecks, "ErrTxTooManySigChecks"},
+		{ErrTooManySigChecks, "ErrTooManySigChecks"},
+		{ErrBadSignetSolution, "ErrBadSignetSolution"},
+		{ErrBadUtxoCommitment, "ErrBadUtxoCommitment"},
+		{ErrBadPoolOutput, "ErrBadPoolOutput"},
+		{ErrBadEntangleAmount, "ErrBadEntangleAmount"},
+		{ErrHeadersNotContiguous, "ErrHeadersNotContiguous"},
 		{0xffff, "Unknown ErrorCode (65535)"},
 	}
 
@@ -0,0 +1,50 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+This test file is part of the blockchain package rather than than the
+blockchain_test package so it can bridge access to the internals to properly
+test cases which are either not possible or can't reliably be tested via the
+public interface.  The functions, constants, and variables are only exported
+while the tests are being run.
+*/
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// TstStoreBlock stores the passed block and adds its node to the block index
+// without connecting it, as if the node stopped right after it stored the
+// block.  The block is recorded as checked by a previous version, which did not
+// record the sanity checks, when oldVersion is set.
+func (b *BlockChain) TstStoreBlock(block *czzutil.Block, oldVersion bool) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	prevHash := &block.MsgBlock().Header.PrevBlock
+	prevNode := b.index.LookupNode(prevHash)
+	if prevNode == nil {
+		return fmt.Errorf("previous block %s is unknown", prevHash)
+	}
+	block.SetHeight(prevNode.height + 1)
+	err := b.db.Update(func(dbTx database.Tx) error {
+		return dbStoreBlock(dbTx, block)
+	})
+	if err != nil {
+		return err
+	}
+
+	node := newBlockNode(&block.MsgBlock().Header, prevNode)
+	node.status = statusDataStored
+	if !oldVersion {
+		node.status |= statusSanityChecked
+	}
+	b.index.AddNode(node)
+	return b.index.flushToDB()
+}
@@ -22,9 +22,10 @@ import (
 
 const numWorkers = 8
 
-// fastSyncUtxoSet will download the UTXO set from the sources provided in the checkpoint. Each
-// UTXO will be saved to the database and the ECMH hash of the UTXO set will be validated against
-// the checkpoint. If a proxyAddr is provided it will use that proxy for the HTTP connection.
+// fastSyncUtxoSet will fetch the UTXO set from peers or download it from the sources provided in
+// the checkpoint. Each UTXO will be saved to the database and the ECMH hash of the UTXO set will be
+// validated against the checkpoint. If a proxyAddr is provided it will use that proxy for the HTTP
+// connection.
 func (b *BlockChain) fastSyncUtxoSet(checkpoint *chaincfg.Checkpoint, proxyAddr string) error {
 	// If the UTXO set is already caught up with the last checkpoint then
 	// we can just close the done chan and exit.
@@ -36,7 +37,7 @@ func (b *BlockChain) fastSyncUtxoSet(checkpoint *chaincfg.Checkpoint, proxyAddr
 	if checkpoint.UtxoSetHash == nil {
 		return AssertError("cannot perform fast sync with nil UTXO set hash")
 	}
-	if len(checkpoint.UtxoSetSources) == 0 {
+	if len(checkpoint.UtxoSetSources) == 0 && b.fetchUtxoSnapshot == nil {
 		return AssertError("no UTXO download sources provided")
 	}
 	if checkpoint.UtxoSetSize == 0 {
@@ -47,7 +48,7 @@ func (b *BlockChain) fastSyncUtxoSet(checkpoint *chaincfg.Checkpoint, proxyAddr
 		proxy = &socks.Proxy{Addr: proxyAddr}
 	}
 
-	fileName, err := downloadUtxoSet(checkpoint.UtxoSetSources, proxy, b.fastSyncDataDir)
+	fileName, err := b.fetchUtxoSet(checkpoint, proxy)
 	if err != nil {
 		log.Errorf("Error downloading UTXO set: %s", err.Error())
 		return err
@@ -64,13 +65,8 @@ func (b *BlockChain) fastSyncUtxoSet(checkpoint *chaincfg.Checkpoint, proxyAddr
 	}()
 
 	var (
-		maxScriptLen   = uint32(1000000)
-		buf52          = make([]byte, 52)
-		pkScript       []byte
 		serializedUtxo []byte
-		n              int
 		totalRead      int
-		scriptLen      uint32
 		progress       float64
 		progressStr    string
 	)
@@ -95,34 +91,14 @@ func (b *BlockChain) fastSyncUtxoSet(checkpoint *chaincfg.Checkpoint, proxyAddr
 	// pass it off to a worker to deserialize, calculate the ECMH hash, and save
 	// to the UTXO cache.
 	for {
-		// Read the first 52 bytes of the utxo
-		n, err = file.Read(buf52)
+		serializedUtxo, err = readSerializedUtxo(file)
 		if err == io.EOF { // We've hit the end
 			break
 		} else if err != nil {
 			log.Errorf("Error reading UTXO set: %s", err.Error())
 			return err
 		}
-		totalRead += n
-
-		// The last four bytes that we read is the length of the script
-		scriptLen = binary.LittleEndian.Uint32(buf52[48:])
-		if scriptLen > maxScriptLen {
-			log.Error("Read invalid UTXO script length", totalRead)
-			return errors.New("invalid script length")
-		}
-
-		// Read the script
-		pkScript = make([]byte, scriptLen)
-		n, err = file.Read(pkScript)
-		if err != nil {
-			log.Errorf("Error reading UTXO set: %s", err.Error())
-			return err
-		}
-		totalRead += n
-
-		serializedUtxo = make([]byte, 52+scriptLen)
-		serializedUtxo = append(buf52, pkScript...)
+		totalRead += len(serializedUtxo)
 
 		jobsChan <- serializedUtxo
 	}
@@ -162,12 +138,130 @@ func (b *BlockChain) fastSyncUtxoSet(checkpoint *chaincfg.Checkpoint, proxyAddr
 
 	log.Infof("Verification complete. UTXO hash %s.", m.Hash().String())
 
+	// The multiset of the downloaded set is the one of the utxo set as of
+	// the checkpoint, which the main chain ends with until the blocks after
+	// it are downloaded.
+	b.chainLock.Lock()
+	if b.bestChain.Tip().hash == *checkpoint.Hash {
+		b.utxoSet = m
+		b.utxoSetUnknown = false
+	}
+	b.chainLock.Unlock()
+
 	// Signal fastsync complete
 	close(b.fastSyncDone)
 
 	return nil
 }
 
+// maxUtxoScriptLen is the maximum script length of a serialized UTXO.
+const maxUtxoScriptLen = 1000000
+
+// readSerializedUtxo reads the next UTXO of a serialized UTXO set from r.  It
+// returns io.EOF once the end of the set is reached.
+func readSerializedUtxo(r io.Reader) ([]byte, error) {
+	// Read the first 52 bytes of the utxo
+	buf52 := make([]byte, 52)
+	if _, err := io.ReadFull(r, buf52); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("truncated UTXO")
+		}
+		return nil, err
+	}
+
+	// The last four bytes that we read is the length of the script
+	scriptLen := binary.LittleEndian.Uint32(buf52[48:])
+	if scriptLen > maxUtxoScriptLen {
+		return nil, errors.New("invalid script length")
+	}
+
+	// Read the script
+	serializedUtxo := make([]byte, 52+scriptLen)
+	copy(serializedUtxo, buf52)
+	if _, err := io.ReadFull(r, serializedUtxo[52:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errors.New("truncated UTXO script")
+		}
+		return nil, err
+	}
+	return serializedUtxo, nil
+}
+
+// HashUtxoSetFile returns the ECMH hash of the serialized UTXO set in the
+// passed file, in the format served by the checkpoint download sources.
+func HashUtxoSetFile(fileName string) (*chainhash.Hash, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	jobs := make(chan []byte, numWorkers)
+	results := make(chan *czzec.Multiset)
+	for i := 0; i < numWorkers; i++ {
+		go func() {
+			m := czzec.NewMultiset(czzec.S256())
+			for serializedUtxo := range jobs {
+				m.Add(serializedUtxo)
+			}
+			results <- m
+		}()
+	}
+
+	var readErr error
+	for {
+		serializedUtxo, err := readSerializedUtxo(file)
+		if err != nil {
+			if err != io.EOF {
+				readErr = err
+			}
+			break
+		}
+		jobs <- serializedUtxo
+	}
+	close(jobs)
+
+	m := czzec.NewMultiset(czzec.S256())
+	for i := 0; i < numWorkers; i++ {
+		m.Merge(<-results)
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+	hash := m.Hash()
+	return &hash, nil
+}
+
+// fetchUtxoSet fetches the UTXO set at the checkpoint from peers, when a
+// FetchUtxoSnapshot function is configured, and otherwise or if that fails
+// downloads it from the sources provided in the checkpoint.  The set fetched
+// from peers is verified against the checkpoint before it is returned, since
+// unlike the download sources the peers are not trusted.  It returns the name
+// of the file holding the set.
+func (b *BlockChain) fetchUtxoSet(checkpoint *chaincfg.Checkpoint, proxy *socks.Proxy) (string, error) {
+	if b.fetchUtxoSnapshot != nil {
+		fileName, err := b.fetchUtxoSnapshot(checkpoint, b.fastSyncDataDir)
+		if err == nil {
+			log.Info("FastSync: UTXO snapshot fetched from peers. Verifying integrity...")
+			var utxoHash *chainhash.Hash
+			utxoHash, err = HashUtxoSetFile(fileName)
+			if err == nil && !checkpoint.UtxoSetHash.IsEqual(utxoHash) {
+				err = fmt.Errorf("UTXO snapshot hash %v does not match the "+
+					"checkpoint", utxoHash)
+			}
+			if err == nil {
+				return fileName, nil
+			}
+			os.Remove(fileName)
+		}
+		log.Warnf("FastSync: Unable to fetch the UTXO set from peers: %v", err)
+		if len(checkpoint.UtxoSetSources) == 0 {
+			return "", err
+		}
+	}
+	return downloadUtxoSet(checkpoint.UtxoSetSources, proxy, b.fastSyncDataDir)
+}
+
 // result holds a multiset with a hash of all the UTXOs read by
 // this worker and a possible error.
 type result struct {
@@ -0,0 +1,57 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/czzec"
+)
+
+// TestHashUtxoSetFile ensures the hash of a serialized UTXO set file is the
+// ECMH hash of its UTXOs and truncated files are rejected.
+func TestHashUtxoSetFile(t *testing.T) {
+	var set bytes.Buffer
+	m := czzec.NewMultiset(czzec.S256())
+	for i := 0; i < 20; i++ {
+		script := bytes.Repeat([]byte{byte(i)}, i)
+		utxo := make([]byte, 52+len(script))
+		utxo[0] = byte(i)
+		binary.LittleEndian.PutUint32(utxo[48:], uint32(len(script)))
+		copy(utxo[52:], script)
+		m.Add(utxo)
+		set.Write(utxo)
+	}
+	want := m.Hash()
+
+	f, err := ioutil.TempFile("", "utxoset")
+	if err != nil {
+		t.Fatalf("unable to create temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.Write(set.Bytes())
+	f.Close()
+
+	got, err := HashUtxoSetFile(f.Name())
+	if err != nil {
+		t.Fatalf("HashUtxoSetFile: unexpected error: %v", err)
+	}
+	if *got != want {
+		t.Fatalf("HashUtxoSetFile: got %v, want %v", got, want)
+	}
+
+	// Cut the file in the middle of the script of the last UTXO.
+	err = ioutil.WriteFile(f.Name(), set.Bytes()[:set.Len()-1], 0644)
+	if err != nil {
+		t.Fatalf("unable to write temp file: %v", err)
+	}
+	if _, err := HashUtxoSetFile(f.Name()); err == nil {
+		t.Fatal("HashUtxoSetFile: accepted a truncated file")
+	}
+}
@@ -110,6 +110,17 @@ var regressionNetParams = &chaincfg.Params{
 	ReduceMinDifficulty:      true,
 	MinDiffReductionTime:     time.Minute * 20, // TargetTimePerBlock * 2
 	GenerateSupported:        true,
+	AllowedFutureBlockTime:   time.Second * 10,
+	MaxTimeOffset:            time.Hour * 2,
+
+	// Script flags enforced from the genesis block.
+	ScriptFlagSchedule: []chaincfg.ScriptFlagActivation{{
+		Height: 0,
+		Flags: []string{"P2SH", "DERSIG", "CHECKLOCKTIMEVERIFY",
+			"STRICTENC", "SIGHASH_FORKID", "LOW_S", "NULLFAIL",
+			"SIGPUSHONLY", "CLEANSTACK", "CHECKDATASIG", "SCHNORR",
+			"ALLOW_SEGWIT_RECOVERY"},
+	}},
 
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints: nil,
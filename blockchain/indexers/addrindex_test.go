@@ -9,7 +9,10 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/txscript"
 	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
 )
 
 // addrIndexBucket provides a mock address index database bucket by implementing
@@ -274,3 +277,37 @@ nextTest:
 		}
 	}
 }
+
+// TestAddrToKeyLegacy ensures legacy base58 addresses map to the same index
+// key as their cashaddr equivalents.
+func TestAddrToKeyLegacy(t *testing.T) {
+	hash := bytes.Repeat([]byte{0x42}, 20)
+	params := &chaincfg.MainNetParams
+
+	pkh, _ := czzutil.NewAddressPubKeyHash(hash, params)
+	sh, _ := czzutil.NewAddressScriptHashFromHash(hash, params)
+	tests := []struct {
+		name   string
+		cash   czzutil.Address
+		legacy czzutil.Address
+	}{
+		{"pubkeyhash", pkh, txscript.ToLegacyAddress(pkh, params)},
+		{"scripthash", sh, txscript.ToLegacyAddress(sh, params)},
+	}
+	for _, test := range tests {
+		cashKey, err := addrToKey(test.cash)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		legacyKey, err := addrToKey(test.legacy)
+		if err != nil {
+			t.Errorf("%s: unexpected legacy error: %v", test.name, err)
+			continue
+		}
+		if cashKey != legacyKey {
+			t.Errorf("%s: legacy key %x does not match cashaddr "+
+				"key %x", test.name, legacyKey, cashKey)
+		}
+	}
+}
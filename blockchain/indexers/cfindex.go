@@ -5,8 +5,10 @@
 package indexers
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 
 	"github.com/bourbaki-czz/classzz/blockchain"
 	"github.com/bourbaki-czz/classzz/chaincfg"
@@ -107,6 +109,9 @@ type CfIndex struct {
 // Ensure the CfIndex type implements the Indexer interface.
 var _ Indexer = (*CfIndex)(nil)
 
+// Ensure the CfIndex type implements the Verifier interface.
+var _ Verifier = (*CfIndex)(nil)
+
 // Ensure the CfIndex type implements the NeedsInputser interface.
 var _ NeedsInputser = (*CfIndex)(nil)
 
@@ -282,6 +287,78 @@ func (idx *CfIndex) ConnectBlock(dbTx database.Tx, block *czzutil.Block,
 	return storeFilter(dbTx, block, f, wire.GCSFilterRegular)
 }
 
+// VerifyBlock rebuilds the filter of the passed block and cross-checks it along
+// with its hash and header against the stored ones.  The filter, its hash and
+// its header count as a single divergent entry.  This is part of the Verifier
+// interface.
+func (idx *CfIndex) VerifyBlock(dbTx database.Tx, block *czzutil.Block,
+	stxos []blockchain.SpentTxOut, repair bool) (int, error) {
+
+	f, err := builder.BuildBasicFilter(block.MsgBlock())
+	if err != nil {
+		return 0, err
+	}
+	filterBytes, err := f.NBytes()
+	if err != nil {
+		return 0, err
+	}
+	filterHash, err := builder.GetFilterHash(f)
+	if err != nil {
+		return 0, err
+	}
+
+	// The header commits to the header of the previous block, which is
+	// taken from the index.
+	prevHeader := &zeroHash
+	ph := &block.MsgBlock().Header.PrevBlock
+	if !ph.IsEqual(&zeroHash) {
+		pfh, err := dbFetchFilterIdxEntry(dbTx, cfHeaderKeys[0], ph)
+		if err != nil {
+			return 0, err
+		}
+		if pfh == nil {
+			return 0, fmt.Errorf("no filter header for previous "+
+				"block %v", ph)
+		}
+		prevHeader, err = chainhash.NewHash(pfh)
+		if err != nil {
+			return 0, err
+		}
+	}
+	fh, err := builder.MakeHeaderForFilter(f, *prevHeader)
+	if err != nil {
+		return 0, err
+	}
+
+	h := block.Hash()
+	entries := []struct {
+		key      []byte
+		expected []byte
+	}{
+		{cfIndexKeys[0], filterBytes},
+		{cfHashKeys[0], filterHash[:]},
+		{cfHeaderKeys[0], fh[:]},
+	}
+	for _, entry := range entries {
+		stored, err := dbFetchFilterIdxEntry(dbTx, entry.key, h)
+		if err != nil {
+			return 0, err
+		}
+		if bytes.Equal(stored, entry.expected) {
+			continue
+		}
+
+		if repair {
+			err := storeFilter(dbTx, block, f, wire.GCSFilterRegular)
+			if err != nil {
+				return 0, err
+			}
+		}
+		return 1, nil
+	}
+	return 0, nil
+}
+
 // DisconnectBlock is invoked by the index manager when a block has been
 // disconnected from the main chain.  This indexer removes the hash-to-cf
 // mapping for every passed block. This is part of the Indexer interface.
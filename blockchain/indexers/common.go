@@ -32,6 +32,25 @@ type NeedsInputser interface {
 	NeedsInputs() bool
 }
 
+// PruneTolerant provides a generic interface for an indexer to specify it does
+// not depend on the blocks before the ones it contains, so it can be built
+// starting from the lowest block which was not pruned.
+type PruneTolerant interface {
+	ToleratesPruning() bool
+}
+
+// Verifier provides a generic interface for an indexer which is able to
+// cross-check its entries against the blocks of the main chain and to repair
+// divergent entries.
+type Verifier interface {
+	// VerifyBlock cross-checks the index entries of the passed block,
+	// which is connected to the index, against the block itself and
+	// returns the number of divergent entries.  Divergent entries are
+	// replaced by the expected ones when repair is set.
+	VerifyBlock(dbTx database.Tx, block *czzutil.Block,
+		stxos []blockchain.SpentTxOut, repair bool) (int, error)
+}
+
 // Indexer provides a generic interface for an indexer that is managed by an
 // index manager such as the Manager type provided by this package.
 type Indexer interface {
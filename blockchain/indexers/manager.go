@@ -7,6 +7,7 @@ package indexers
 import (
 	"bytes"
 	"fmt"
+	"sync"
 
 	"github.com/bourbaki-czz/classzz/blockchain"
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
@@ -129,9 +130,54 @@ func dbIndexDisconnectBlock(dbTx database.Tx, indexer Indexer, block *czzutil.Bl
 // Manager defines an index manager that manages multiple optional indexes and
 // implements the blockchain.IndexManager interface so it can be seamlessly
 // plugged into normal chain processing.
+//
+// Indexes which are behind the main chain when the manager is initialized are
+// caught up in the background once the manager is started.  Until an index
+// reaches the tip of the main chain it is skipped when blocks are connected to
+// or disconnected from the main chain, so it is only partially available while
+// it is being built.
 type Manager struct {
 	db             database.DB
 	enabledIndexes []Indexer
+	chain          *blockchain.BlockChain
+
+	// caughtUp tracks which of the enabled indexes are maintained along
+	// with the main chain.  All other indexes are being caught up in the
+	// background.  It is protected by mtx, which is only ever acquired
+	// while holding the database write lock or no database lock at all.
+	mtx      sync.Mutex
+	caughtUp []bool
+
+	newBlock chan struct{}
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// VerifyResult describes the outcome of verifying an index.
+type VerifyResult struct {
+	// Name is the human-readable name of the index.
+	Name string
+
+	// BlocksChecked is the number of blocks whose entries were checked.
+	BlocksChecked int
+
+	// Divergent is the number of entries which did not match the blocks.
+	Divergent int
+
+	// Repaired is the number of divergent entries which were repaired.
+	Repaired int
+}
+
+// IndexInfo describes the state of an index.
+type IndexInfo struct {
+	// Name is the human-readable name of the index.
+	Name string
+
+	// Height is the height of the last block in the index.
+	Height int32
+
+	// Synced indicates whether the index caught up to the main chain.
+	Synced bool
 }
 
 // Ensure the Manager type implements the blockchain.IndexManager interface.
@@ -226,14 +272,16 @@ func (m *Manager) maybeCreateIndexes(dbTx database.Tx) error {
 }
 
 // Init initializes the enabled indexes.  This is called during chain
-// initialization and primarily consists of catching up all indexes to the
-// current best chain tip.  This is necessary since each index can be disabled
-// and re-enabled at any time and attempting to catch-up indexes at the same
-// time new blocks are being downloaded would lead to an overall longer time to
-// catch up due to the I/O contention.
+// initialization and consists of finishing interrupted drops, creating and
+// migrating the indexes as needed and removing blocks which are no longer part
+// of the main chain from them.  Indexes which are behind the current best chain
+// tip are caught up in the background once the manager is started, so an index
+// can be enabled on a synced node without delaying its startup.
 //
 // This is part of the blockchain.IndexManager interface.
 func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{}) error {
+	m.chain = chain
+
 	// Nothing to do when no indexes are enabled.
 	if len(m.enabledIndexes) == 0 {
 		return nil
@@ -304,57 +352,15 @@ func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{})
 		// Loop until the tip is a block that exists in the main chain.
 		initialHeight := height
 		for !chain.MainChainHasBlock(hash) {
-			// At this point the index tip is orphaned, so load the
-			// orphaned block from the database directly and
-			// disconnect it from the index.  The block has to be
-			// loaded directly since it is no longer in the main
-			// chain and thus the chain.BlockByHash function would
-			// error.
-			var block *czzutil.Block
-			err := m.db.View(func(dbTx database.Tx) error {
-				blockBytes, err := dbTx.FetchBlock(hash)
-				if err != nil {
-					return err
-				}
-				block, err = czzutil.NewBlockFromBytes(blockBytes)
-				if err != nil {
-					return err
-				}
-				block.SetHeight(height)
-				return err
-			})
-			if err != nil {
-				return err
-			}
-
-			// We'll also grab the set of outputs spent by this
-			// block so we can remove them from the index.
-			spentTxos, err := chain.FetchSpendJournal(block)
+			block, err := m.disconnectOrphanedTip(indexer, hash,
+				height)
 			if err != nil {
 				return err
 			}
 
-			// With the block and stxo set for that block retrieved,
-			// we can now update the index itself.
-			err = m.db.Update(func(dbTx database.Tx) error {
-				// Remove all of the index entries associated
-				// with the block and update the indexer tip.
-				err = dbIndexDisconnectBlock(
-					dbTx, indexer, block, spentTxos,
-				)
-				if err != nil {
-					return err
-				}
-
-				// Update the tip to the previous block.
-				hash = &block.MsgBlock().Header.PrevBlock
-				height--
-
-				return nil
-			})
-			if err != nil {
-				return err
-			}
+			// Update the tip to the previous block.
+			hash = &block.MsgBlock().Header.PrevBlock
+			height--
 
 			if interruptRequested(interrupt) {
 				return errInterruptRequested
@@ -368,13 +374,11 @@ func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{})
 		}
 	}
 
-	// Fetch the current tip heights for each index along with tracking the
-	// lowest one so the catchup code only needs to start at the earliest
-	// block and is able to skip connecting the block for the indexes that
-	// don't need it.
+	// Fetch the current tip heights for each index to determine which of
+	// them are caught up to the current best chain tip.  The remaining ones
+	// are caught up in the background once the manager is started.
 	bestHeight := chain.BestSnapshot().Height
 	lowestHeight := bestHeight
-	indexerHeights := make([]int32, len(m.enabledIndexes))
 	err = m.db.View(func(dbTx database.Tx) error {
 		for i, indexer := range m.enabledIndexes {
 			idxKey := indexer.Key()
@@ -385,7 +389,7 @@ func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{})
 
 			log.Debugf("Current %s tip (height %d, hash %v)",
 				indexer.Name(), height, hash)
-			indexerHeights[i] = height
+			m.caughtUp[i] = height == bestHeight
 			if height < lowestHeight {
 				lowestHeight = height
 			}
@@ -396,71 +400,449 @@ func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{})
 		return err
 	}
 
-	// Nothing to index if all of the indexes are caught up.
-	if lowestHeight == bestHeight {
-		return nil
+	if lowestHeight != bestHeight {
+		log.Infof("Indexes will be caught up from height %d to %d in "+
+			"the background", lowestHeight, bestHeight)
 	}
+	return nil
+}
 
+// disconnectOrphanedTip removes the passed block, which is the tip of the
+// index and no longer part of the main chain, from the index.  The index is
+// left untouched when its tip changed in the mean time.  The removed block is
+// returned.
+func (m *Manager) disconnectOrphanedTip(indexer Indexer, hash *chainhash.Hash,
+	height int32) (*czzutil.Block, error) {
+
+	// Load the orphaned block from the database directly since it is no
+	// longer in the main chain and thus the chain.BlockByHash function
+	// would error.
+	var block *czzutil.Block
+	err := m.db.View(func(dbTx database.Tx) error {
+		blockBytes, err := dbTx.FetchBlock(hash)
+		if err != nil {
+			return err
+		}
+		block, err = czzutil.NewBlockFromBytes(blockBytes)
+		if err != nil {
+			return err
+		}
+		block.SetHeight(height)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// We'll also grab the set of outputs spent by this block so we can
+	// remove them from the index.
+	spentTxos, err := m.chain.FetchSpendJournal(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// With the block and stxo set for that block retrieved, we can now
+	// remove all of the index entries associated with the block and update
+	// the indexer tip.
+	err = m.db.Update(func(dbTx database.Tx) error {
+		tipHash, _, err := dbFetchIndexerTip(dbTx, indexer.Key())
+		if err != nil {
+			return err
+		}
+		if !tipHash.IsEqual(hash) {
+			return nil
+		}
+
+		return dbIndexDisconnectBlock(dbTx, indexer, block, spentTxos)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// catchUp connects the blocks of the main chain to the indexes which are behind
+// it until all of them are caught up.  Once the tip of an index is the parent
+// of a block being connected to the main chain, the index is maintained along
+// with the chain and no longer handled here.
+func (m *Manager) catchUp() error {
 	// Create a progress logger for the indexing process below.
 	progressLogger := newBlockProgressLogger("Indexed", log)
 
-	// At this point, one or more indexes are behind the current best chain
-	// tip and need to be caught up, so log the details and loop through
-	// each block that needs to be indexed.
-	log.Infof("Catching up indexes from height %d to %d", lowestHeight,
-		bestHeight)
-	for height := lowestHeight + 1; height <= bestHeight; height++ {
-		// Load the block for the height since it is required to index
-		// it.
-		block, err := chain.BlockByHeight(height)
+	indexerHashes := make([]*chainhash.Hash, len(m.enabledIndexes))
+	indexerHeights := make([]int32, len(m.enabledIndexes))
+	for {
+		// Fetch the current tips of the indexes which are not caught up
+		// yet along with tracking the lowest one, so the block for it
+		// can be connected next.
+		var behind bool
+		lowestHeight := int32(-1)
+		err := m.db.View(func(dbTx database.Tx) error {
+			m.mtx.Lock()
+			defer m.mtx.Unlock()
+
+			for i, indexer := range m.enabledIndexes {
+				if m.caughtUp[i] {
+					continue
+				}
+
+				hash, height, err := dbFetchIndexerTip(dbTx,
+					indexer.Key())
+				if err != nil {
+					return err
+				}
+				indexerHashes[i] = hash
+				indexerHeights[i] = height
+				if !behind || height < lowestHeight {
+					lowestHeight = height
+				}
+				behind = true
+			}
+			return nil
+		})
 		if err != nil {
 			return err
 		}
+		if !behind {
+			log.Infof("Indexes caught up to the main chain")
+			return nil
+		}
 
-		if interruptRequested(interrupt) {
-			return errInterruptRequested
+		// Remove blocks which were disconnected from the main chain in
+		// the mean time from the indexes before going on.
+		var disconnected bool
+		for i, indexer := range m.enabledIndexes {
+			hash := indexerHashes[i]
+			if hash == nil || indexerHeights[i] == -1 ||
+				m.chain.MainChainHasBlock(hash) {
+
+				continue
+			}
+
+			_, err := m.disconnectOrphanedTip(indexer, hash,
+				indexerHeights[i])
+			if err != nil {
+				return err
+			}
+			disconnected = true
+		}
+		if disconnected {
+			continue
+		}
+
+		// Wait for the next block when the lagging indexes are at the
+		// tip of the main chain since they are caught up along with it.
+		bestHeight := m.chain.BestSnapshot().Height
+		if lowestHeight >= bestHeight {
+			select {
+			case <-m.newBlock:
+			case <-m.quit:
+				return errInterruptRequested
+			}
+			continue
+		}
+
+		// The data of pruned blocks is not available to index them.
+		// Indexes which tolerate pruning start with the lowest block
+		// which was not pruned instead.
+		height := lowestHeight + 1
+		if pruneHeight := m.chain.PruneHeight(); height < pruneHeight {
+			err := m.skipPrunedBlocks(pruneHeight, indexerHashes,
+				indexerHeights)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Load the next block since it is required to index it.  The
+		// block is not available when the chain was reorganized in the
+		// mean time, which is handled with the next iteration.
+		block, err := m.chain.BlockByHeight(height)
+		if err != nil {
+			continue
 		}
 
-		// Connect the block for all indexes that need it.
+		// When an index which requires all of the referenced txouts is
+		// connected to the block, they need to be retrieved from the
+		// spend journal.
 		var spentTxos []blockchain.SpentTxOut
 		for i, indexer := range m.enabledIndexes {
-			// Skip indexes that don't need to be updated with this
-			// block.
-			if indexerHeights[i] >= height {
-				continue
+			if indexerHashes[i] != nil && indexerHeights[i] < height &&
+				indexNeedsInputs(indexer) {
+
+				spentTxos, err = m.chain.FetchSpendJournal(block)
+				if err != nil {
+					return err
+				}
+				break
 			}
+		}
+
+		// Connect the block for all indexes that need it.  Indexes
+		// which were caught up in the mean time or whose tip changed
+		// are skipped, the latter are handled with the next iteration.
+		err = m.db.Update(func(dbTx database.Tx) error {
+			m.mtx.Lock()
+			defer m.mtx.Unlock()
+
+			prevHash := &block.MsgBlock().Header.PrevBlock
+			for i, indexer := range m.enabledIndexes {
+				if m.caughtUp[i] {
+					continue
+				}
 
-			// When the index requires all of the referenced txouts
-			// and they haven't been loaded yet, they need to be
-			// retrieved from the spend journal.
-			if spentTxos == nil && indexNeedsInputs(indexer) {
-				spentTxos, err = chain.FetchSpendJournal(block)
+				tipHash, _, err := dbFetchIndexerTip(dbTx,
+					indexer.Key())
 				if err != nil {
 					return err
 				}
+				if !tipHash.IsEqual(prevHash) {
+					continue
+				}
+
+				err = dbIndexConnectBlock(dbTx, indexer, block,
+					spentTxos)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// Log indexing progress.
+		progressLogger.LogBlockHeight(block, uint64(bestHeight))
+
+		if interruptRequested(m.quit) {
+			return errInterruptRequested
+		}
+	}
+}
+
+// skipPrunedBlocks moves the tips of the indexes which are being caught up and
+// behind the passed prune height to the block right below it, so they continue
+// with the lowest block which was not pruned.  An error is returned when one of
+// them does not tolerate pruning since it can not be built anymore.
+func (m *Manager) skipPrunedBlocks(pruneHeight int32, indexerHashes []*chainhash.Hash,
+	indexerHeights []int32) error {
+
+	hash, err := m.chain.BlockHashByHeight(pruneHeight - 1)
+	if err != nil {
+		return err
+	}
+
+	return m.db.Update(func(dbTx database.Tx) error {
+		m.mtx.Lock()
+		defer m.mtx.Unlock()
+
+		for i, indexer := range m.enabledIndexes {
+			if m.caughtUp[i] || indexerHashes[i] == nil ||
+				indexerHeights[i] >= pruneHeight-1 {
+
+				continue
 			}
 
-			err := m.db.Update(func(dbTx database.Tx) error {
-				return dbIndexConnectBlock(
-					dbTx, indexer, block, spentTxos,
-				)
-			})
+			if !indexToleratesPruning(indexer) {
+				return fmt.Errorf("the %s can not be built since "+
+					"the blocks below height %d were pruned -- "+
+					"drop it or resync without pruning",
+					indexer.Name(), pruneHeight)
+			}
+
+			// Leave the index alone when its tip changed in the
+			// mean time.
+			tipHash, _, err := dbFetchIndexerTip(dbTx, indexer.Key())
+			if err != nil {
+				return err
+			}
+			if !tipHash.IsEqual(indexerHashes[i]) {
+				continue
+			}
+
+			log.Infof("Building %s from height %d since the blocks "+
+				"below it were pruned", indexer.Name(), pruneHeight)
+			err = dbPutIndexerTip(dbTx, indexer.Key(), hash,
+				pruneHeight-1)
 			if err != nil {
 				return err
 			}
-			indexerHeights[i] = height
 		}
+		return nil
+	})
+}
 
-		// Log indexing progress.
-		progressLogger.LogBlockHeight(block, uint64(bestHeight))
+// Start begins catching up the indexes which are behind the main chain in the
+// background.  It must only be called after Init.
+func (m *Manager) Start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		err := m.catchUp()
+		if err != nil && err != errInterruptRequested {
+			log.Errorf("Unable to catch up indexes: %v", err)
+		}
+	}()
+}
+
+// Stop stops catching up the indexes and waits for it to finish.  Indexes which
+// are not caught up yet resume from their current tip the next time the
+// manager is started.
+func (m *Manager) Stop() {
+	close(m.quit)
+	m.wg.Wait()
+}
+
+// IndexInfo returns the state of each of the enabled indexes.
+//
+// This function is safe for concurrent access.
+func (m *Manager) IndexInfo() ([]IndexInfo, error) {
+	infos := make([]IndexInfo, 0, len(m.enabledIndexes))
+	err := m.db.View(func(dbTx database.Tx) error {
+		for _, indexer := range m.enabledIndexes {
+			_, height, err := dbFetchIndexerTip(dbTx, indexer.Key())
+			if err != nil {
+				return err
+			}
+			infos = append(infos, IndexInfo{
+				Name:   indexer.Name(),
+				Height: height,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bestHeight := m.chain.BestSnapshot().Height
+	m.mtx.Lock()
+	for i := range infos {
+		infos[i].Synced = m.caughtUp[i] || infos[i].Height >= bestHeight
+	}
+	m.mtx.Unlock()
+	return infos, nil
+}
+
+// VerifyIndexes cross-checks the entries of the enabled indexes which implement
+// the Verifier interface against the blocks of the main chain from startHeight
+// to endHeight.  Only every step-th block is checked, so a step of one results
+// in a full verification and larger steps in a sampled one.  A negative
+// endHeight verifies up to the current best chain tip and blocks which are not
+// part of an index yet are skipped.  Divergent entries are repaired when repair
+// is set.
+//
+// This function is safe for concurrent access.
+func (m *Manager) VerifyIndexes(startHeight, endHeight, step int32, repair bool,
+	interrupt <-chan struct{}) ([]VerifyResult, error) {
+
+	bestHeight := m.chain.BestSnapshot().Height
+	if endHeight < 0 || endHeight > bestHeight {
+		endHeight = bestHeight
+	}
+	if startHeight < 0 {
+		startHeight = 0
+	}
+	if step < 1 {
+		step = 1
+	}
+
+	var verifiers []Indexer
+	for _, indexer := range m.enabledIndexes {
+		if _, ok := indexer.(Verifier); ok {
+			verifiers = append(verifiers, indexer)
+		}
+	}
+	results := make([]VerifyResult, len(verifiers))
+	for i, indexer := range verifiers {
+		results[i].Name = indexer.Name()
+	}
+
+	for height := startHeight; height <= endHeight; height += step {
+		block, err := m.chain.BlockByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+
+		var spentTxos []blockchain.SpentTxOut
+		for _, indexer := range verifiers {
+			if indexNeedsInputs(indexer) {
+				spentTxos, err = m.chain.FetchSpendJournal(block)
+				if err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+
+		verify := func(dbTx database.Tx) error {
+			m.mtx.Lock()
+			defer m.mtx.Unlock()
+
+			// Entries of blocks which were disconnected in the mean
+			// time must not be written back.
+			if repair && !m.chain.MainChainHasBlock(block.Hash()) {
+				return nil
+			}
+
+			for i, indexer := range verifiers {
+				_, tipHeight, err := dbFetchIndexerTip(dbTx,
+					indexer.Key())
+				if err != nil {
+					return err
+				}
+				if tipHeight < height {
+					continue
+				}
+
+				divergent, err := indexer.(Verifier).VerifyBlock(dbTx,
+					block, spentTxos, repair)
+				if err != nil {
+					return err
+				}
+				results[i].BlocksChecked++
+				if divergent == 0 {
+					continue
+				}
+
+				log.Warnf("Found %d divergent %s entries for block "+
+					"%v (height %d)", divergent, indexer.Name(),
+					block.Hash(), height)
+				results[i].Divergent += divergent
+				if repair {
+					results[i].Repaired += divergent
+				}
+			}
+			return nil
+		}
+		if repair {
+			err = m.db.Update(verify)
+		} else {
+			err = m.db.View(verify)
+		}
+		if err != nil {
+			return nil, err
+		}
 
 		if interruptRequested(interrupt) {
-			return errInterruptRequested
+			return nil, errInterruptRequested
 		}
 	}
 
-	log.Infof("Indexes caught up to height %d", bestHeight)
-	return nil
+	return results, nil
+}
+
+// indexToleratesPruning returns whether or not the index can be built starting
+// from the lowest block which was not pruned.
+func indexToleratesPruning(index Indexer) bool {
+	if idx, ok := index.(PruneTolerant); ok {
+		return idx.ToleratesPruning()
+	}
+
+	return false
 }
 
 // indexNeedsInputs returns whether or not the index needs access to the txouts
@@ -509,14 +891,42 @@ func dbFetchTx(dbTx database.Tx, hash *chainhash.Hash) (*wire.MsgTx, error) {
 func (m *Manager) ConnectBlock(dbTx database.Tx, block *czzutil.Block,
 	stxos []blockchain.SpentTxOut) error {
 
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	// Call each of the currently active optional indexes with the block
 	// being connected so they can update accordingly.
-	for _, index := range m.enabledIndexes {
+	for i, index := range m.enabledIndexes {
+		// Indexes which are being caught up in the background are
+		// skipped until their tip is the parent of the block, at which
+		// point they are maintained along with the chain from now on.
+		if !m.caughtUp[i] {
+			tipHash, tipHeight, err := dbFetchIndexerTip(dbTx,
+				index.Key())
+			if err != nil {
+				return err
+			}
+			if !tipHash.IsEqual(&block.MsgBlock().Header.PrevBlock) {
+				continue
+			}
+
+			m.caughtUp[i] = true
+			log.Infof("Caught up %s to height %d", index.Name(),
+				tipHeight)
+		}
+
 		err := dbIndexConnectBlock(dbTx, index, block, stxos)
 		if err != nil {
 			return err
 		}
 	}
+
+	// Wake up the background catch up in case it is waiting for the next
+	// block.
+	select {
+	case m.newBlock <- struct{}{}:
+	default:
+	}
 	return nil
 }
 
@@ -529,9 +939,24 @@ func (m *Manager) ConnectBlock(dbTx database.Tx, block *czzutil.Block,
 func (m *Manager) DisconnectBlock(dbTx database.Tx, block *czzutil.Block,
 	stxo []blockchain.SpentTxOut) error {
 
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	// Call each of the currently active optional indexes with the block
 	// being disconnected so they can update accordingly.
-	for _, index := range m.enabledIndexes {
+	for i, index := range m.enabledIndexes {
+		// Indexes which are being caught up in the background only
+		// need to be updated when they already contain the block.
+		if !m.caughtUp[i] {
+			tipHash, _, err := dbFetchIndexerTip(dbTx, index.Key())
+			if err != nil {
+				return err
+			}
+			if !tipHash.IsEqual(block.Hash()) {
+				continue
+			}
+		}
+
 		err := dbIndexDisconnectBlock(dbTx, index, block, stxo)
 		if err != nil {
 			return err
@@ -548,9 +973,19 @@ func NewManager(db database.DB, enabledIndexes []Indexer) *Manager {
 	return &Manager{
 		db:             db,
 		enabledIndexes: enabledIndexes,
+		caughtUp:       make([]bool, len(enabledIndexes)),
+		newBlock:       make(chan struct{}, 1),
+		quit:           make(chan struct{}),
 	}
 }
 
+// DropIndex drops the index with the passed key and human-readable name from
+// the provided database if it exists.  It allows indexes implemented outside of
+// this package to be dropped the same way as the indexes provided by it.
+func DropIndex(db database.DB, idxKey []byte, idxName string, interrupt <-chan struct{}) error {
+	return dropIndex(db, idxKey, idxName, interrupt)
+}
+
 // dropIndex drops the passed index from the database.  Since indexes can be
 // massive, it deletes the index in multiple database transactions in order to
 // keep memory usage to reasonable levels.  It also marks the drop in progress
@@ -0,0 +1,467 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+const (
+	// scriptTypeIndexName is the human-readable name for the index.
+	scriptTypeIndexName = "script type index"
+
+	// scriptTypeKeySize is the size of the height used as key in the
+	// script type index.
+	scriptTypeKeySize = 4
+)
+
+var (
+	// scriptTypeIndexKey is the key of the script type index and the db
+	// bucket used to house it.
+	scriptTypeIndexKey = []byte("scripttypebyheightidx")
+)
+
+// ScriptType is the type of an output script counted by the script type
+// index.  The values are part of the serialized index entries so they must
+// not change.
+type ScriptType uint8
+
+// These constants are the types of the output scripts counted by the script
+// type index.
+const (
+	ScriptTypeNonStandard ScriptType = iota
+	ScriptTypePubKey
+	ScriptTypePubKeyHash
+	ScriptTypeScriptHash
+	ScriptTypeMultiSig
+	ScriptTypeNullData
+	ScriptTypeEntangle
+	ScriptTypeKeepedAmount
+	ScriptTypeVault
+	ScriptTypePool
+
+	// NumScriptTypes is the number of script types.
+	NumScriptTypes
+)
+
+// scriptTypeNames are the human-readable names of the script types.
+var scriptTypeNames = [NumScriptTypes]string{
+	ScriptTypeNonStandard:  "nonstandard",
+	ScriptTypePubKey:       "pubkey",
+	ScriptTypePubKeyHash:   "pubkeyhash",
+	ScriptTypeScriptHash:   "scripthash",
+	ScriptTypeMultiSig:     "multisig",
+	ScriptTypeNullData:     "nulldata",
+	ScriptTypeEntangle:     "entangle",
+	ScriptTypeKeepedAmount: "keepedamount",
+	ScriptTypeVault:        "vault",
+	ScriptTypePool:         "pool",
+}
+
+// String returns the human-readable name of the script type.
+func (t ScriptType) String() string {
+	if t < NumScriptTypes {
+		return scriptTypeNames[t]
+	}
+	return fmt.Sprintf("Unknown ScriptType (%d)", uint8(t))
+}
+
+// ScriptTypeCounts are the numbers and total values of the outputs of a script
+// type created and spent by a range of blocks.
+type ScriptTypeCounts struct {
+	Created      uint64
+	CreatedValue int64
+	Spent        uint64
+	SpentValue   int64
+}
+
+// add adds the passed counts to the counts.
+func (c *ScriptTypeCounts) add(other *ScriptTypeCounts) {
+	c.Created += other.Created
+	c.CreatedValue += other.CreatedValue
+	c.Spent += other.Spent
+	c.SpentValue += other.SpentValue
+}
+
+// -----------------------------------------------------------------------------
+// The script type index consists of an entry for every block in the main
+// chain.  It maps the height of the block to the number and the total value of
+// the outputs of every script type the block created and spent, so the usage
+// of the script types over a range of blocks is summed without reading the
+// blocks.
+//
+// The serialized format for keys and values in the script type index bucket
+// is:
+//   <height> = <num types><type><created><created value><spent><spent value>...
+//
+//   Field           Type              Size
+//   height          uint32            4 bytes (big endian)
+//   -----
+//   Total: 4 bytes
+//
+//   Field           Type              Size
+//   num types       uvarint           variable
+//   type            uint8             1 byte
+//   created         uvarint           variable
+//   created value   uvarint           variable
+//   spent           uvarint           variable
+//   spent value     uvarint           variable
+//
+// The type and the counts are repeated for each script type with any output
+// created or spent by the block.  Every block has an entry, even when it has
+// no counts, so the blocks which were not indexed are told apart.
+// -----------------------------------------------------------------------------
+
+// scriptTypeKey returns the key of the script type index entry for the block
+// at the passed height.
+func scriptTypeKey(height int32) []byte {
+	key := make([]byte, scriptTypeKeySize)
+	binary.BigEndian.PutUint32(key, uint32(height))
+	return key
+}
+
+// serializeScriptTypeCounts returns the serialized script type index entry for
+// the passed counts of a block.
+func serializeScriptTypeCounts(counts *[NumScriptTypes]ScriptTypeCounts) []byte {
+	var numTypes int
+	for i := range counts {
+		if counts[i] != (ScriptTypeCounts{}) {
+			numTypes++
+		}
+	}
+
+	serialized := make([]byte, binary.MaxVarintLen64*(4*numTypes+1)+
+		numTypes)
+	offset := binary.PutUvarint(serialized, uint64(numTypes))
+	for i := range counts {
+		c := &counts[i]
+		if *c == (ScriptTypeCounts{}) {
+			continue
+		}
+		serialized[offset] = byte(i)
+		offset++
+		offset += binary.PutUvarint(serialized[offset:], c.Created)
+		offset += binary.PutUvarint(serialized[offset:],
+			uint64(c.CreatedValue))
+		offset += binary.PutUvarint(serialized[offset:], c.Spent)
+		offset += binary.PutUvarint(serialized[offset:],
+			uint64(c.SpentValue))
+	}
+	return serialized[:offset]
+}
+
+// deserializeScriptTypeCounts decodes the passed serialized script type index
+// entry.
+func deserializeScriptTypeCounts(serialized []byte) (*[NumScriptTypes]ScriptTypeCounts, error) {
+	reader := bytes.NewReader(serialized)
+	numTypes, err := binary.ReadUvarint(reader)
+	if err != nil || numTypes > uint64(NumScriptTypes) {
+		return nil, errDeserialize("invalid number of script types")
+	}
+
+	var counts [NumScriptTypes]ScriptTypeCounts
+	for i := uint64(0); i < numTypes; i++ {
+		scriptType, err := reader.ReadByte()
+		if err != nil {
+			return nil, errDeserialize("unexpected end of data")
+		}
+		if ScriptType(scriptType) >= NumScriptTypes {
+			return nil, errDeserialize(fmt.Sprintf("unknown script "+
+				"type %d", scriptType))
+		}
+
+		var fields [4]uint64
+		for j := range fields {
+			fields[j], err = binary.ReadUvarint(reader)
+			if err != nil {
+				return nil, errDeserialize("unexpected end of data")
+			}
+		}
+		counts[scriptType] = ScriptTypeCounts{
+			Created:      fields[0],
+			CreatedValue: int64(fields[1]),
+			Spent:        fields[2],
+			SpentValue:   int64(fields[3]),
+		}
+	}
+	if reader.Len() != 0 {
+		return nil, errDeserialize("trailing data")
+	}
+	return &counts, nil
+}
+
+// ScriptTypeIndex implements a script type index.  That is to say, it supports
+// querying the number and the value of the outputs of every script type
+// created and spent by a range of blocks.
+type ScriptTypeIndex struct {
+	db database.DB
+
+	// poolScripts are the scripts paying the coin pools, whose outputs are
+	// counted as ScriptTypePool instead of ScriptTypePubKeyHash.
+	poolScripts [][]byte
+}
+
+// Ensure the ScriptTypeIndex type implements the Indexer interface.
+var _ Indexer = (*ScriptTypeIndex)(nil)
+
+// Ensure the ScriptTypeIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*ScriptTypeIndex)(nil)
+
+// Ensure the ScriptTypeIndex type implements the PruneTolerant interface.
+var _ PruneTolerant = (*ScriptTypeIndex)(nil)
+
+// Ensure the ScriptTypeIndex type implements the Verifier interface.
+var _ Verifier = (*ScriptTypeIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to count the spent outputs by script type.
+//
+// This implements the NeedsInputser interface.
+func (idx *ScriptTypeIndex) NeedsInputs() bool {
+	return true
+}
+
+// ToleratesPruning returns true since the entry of a block does not depend on
+// earlier blocks, so the index can be built on a pruned chain and sums the
+// blocks which were not pruned.
+//
+// This implements the PruneTolerant interface.
+func (idx *ScriptTypeIndex) ToleratesPruning() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptTypeIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Migrate is only provided to satisfy the Indexer interface as there is nothing to
+// migrate this index.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptTypeIndex) Migrate(db database.DB, interrupt <-chan struct{}) error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptTypeIndex) Key() []byte {
+	return scriptTypeIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptTypeIndex) Name() string {
+	return scriptTypeIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the script
+// type index.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptTypeIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(scriptTypeIndexKey)
+	return err
+}
+
+// scriptType returns the script type of the passed output script.
+func (idx *ScriptTypeIndex) scriptType(pkScript []byte) ScriptType {
+	switch txscript.GetScriptClass(pkScript) {
+	case txscript.PubKeyTy:
+		return ScriptTypePubKey
+	case txscript.PubKeyHashTy:
+		for _, poolScript := range idx.poolScripts {
+			if bytes.Equal(pkScript, poolScript) {
+				return ScriptTypePool
+			}
+		}
+		return ScriptTypePubKeyHash
+	case txscript.ScriptHashTy:
+		return ScriptTypeScriptHash
+	case txscript.MultiSigTy:
+		return ScriptTypeMultiSig
+	case txscript.NullDataTy:
+		return ScriptTypeNullData
+	case txscript.EntangleTy:
+		return ScriptTypeEntangle
+	case txscript.VaultTy:
+		return ScriptTypeVault
+	}
+	if txscript.IsKeepedAmountScript(pkScript) {
+		return ScriptTypeKeepedAmount
+	}
+	return ScriptTypeNonStandard
+}
+
+// blockCounts returns the counts of the outputs created and spent by the
+// passed block by script type.  The passed spent outputs must be those of the
+// spend journal of the block.
+func (idx *ScriptTypeIndex) blockCounts(block *czzutil.Block,
+	stxos []blockchain.SpentTxOut) (*[NumScriptTypes]ScriptTypeCounts, error) {
+
+	// The inputs of the coinbase transaction spend nothing from the
+	// unspent output set, so the spend journal has no entry for them.
+	var counts [NumScriptTypes]ScriptTypeCounts
+	stxoIdx := 0
+	for txIdx, tx := range block.Transactions() {
+		if txIdx != 0 {
+			for range tx.MsgTx().TxIn {
+				if stxoIdx >= len(stxos) {
+					return nil, fmt.Errorf("missing spent " +
+						"outputs to count the inputs of " +
+						"the block")
+				}
+				stxo := &stxos[stxoIdx]
+				stxoIdx++
+
+				c := &counts[idx.scriptType(stxo.PkScript)]
+				c.Spent++
+				c.SpentValue += stxo.Amount
+			}
+		}
+
+		for _, txOut := range tx.MsgTx().TxOut {
+			c := &counts[idx.scriptType(txOut.PkScript)]
+			c.Created++
+			c.CreatedValue += txOut.Value
+		}
+	}
+	return &counts, nil
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds the counts of the outputs
+// created and spent by the block by script type.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptTypeIndex) ConnectBlock(dbTx database.Tx, block *czzutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	counts, err := idx.blockCounts(block, stxos)
+	if err != nil {
+		return err
+	}
+	return dbTx.Metadata().Bucket(scriptTypeIndexKey).Put(
+		scriptTypeKey(block.Height()), serializeScriptTypeCounts(counts))
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the counts of the
+// block.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptTypeIndex) DisconnectBlock(dbTx database.Tx, block *czzutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	return dbTx.Metadata().Bucket(scriptTypeIndexKey).Delete(
+		scriptTypeKey(block.Height()))
+}
+
+// VerifyBlock cross-checks the script type index entry of the passed block
+// against its transactions and the outputs they spend, and returns 1 when the
+// entry diverges.
+//
+// This is part of the Verifier interface.
+func (idx *ScriptTypeIndex) VerifyBlock(dbTx database.Tx, block *czzutil.Block,
+	stxos []blockchain.SpentTxOut, repair bool) (int, error) {
+
+	counts, err := idx.blockCounts(block, stxos)
+	if err != nil {
+		return 0, err
+	}
+	bucket := dbTx.Metadata().Bucket(scriptTypeIndexKey)
+	key := scriptTypeKey(block.Height())
+	expected := serializeScriptTypeCounts(counts)
+	if bytes.Equal(bucket.Get(key), expected) {
+		return 0, nil
+	}
+	if repair {
+		if err := bucket.Put(key, expected); err != nil {
+			return 0, err
+		}
+	}
+	return 1, nil
+}
+
+// Counts returns the counts of the outputs created and spent by the blocks of
+// the main chain from the passed start height to the passed end height, both
+// included, by script type.  An error is returned when one of the blocks is not
+// indexed, such as when it was pruned before the index was built or the index
+// has not caught up with it yet.
+//
+// This function is safe for concurrent access.
+func (idx *ScriptTypeIndex) Counts(startHeight, endHeight int32) (*[NumScriptTypes]ScriptTypeCounts, error) {
+	var totals [NumScriptTypes]ScriptTypeCounts
+	err := idx.db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(scriptTypeIndexKey)
+		for height := startHeight; height <= endHeight; height++ {
+			serialized := bucket.Get(scriptTypeKey(height))
+			if serialized == nil {
+				return fmt.Errorf("the block at height %d is not "+
+					"indexed", height)
+			}
+
+			counts, err := deserializeScriptTypeCounts(serialized)
+			if err != nil {
+				return database.Error{
+					ErrorCode: database.ErrCorruption,
+					Description: fmt.Sprintf("corrupt script "+
+						"type index entry for height %d: %v",
+						height, err),
+				}
+			}
+			for i := range totals {
+				totals[i].add(&counts[i])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &totals, nil
+}
+
+// NewScriptTypeIndex returns a new instance of an indexer that is used to
+// count the outputs created and spent by every block in the blockchain by
+// script type.  The outputs paying the coin pools of the passed network are
+// counted apart.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewScriptTypeIndex(db database.DB, chainParams *chaincfg.Params) *ScriptTypeIndex {
+	idx := &ScriptTypeIndex{db: db}
+	for _, poolHash := range chainParams.CoinPoolHashes {
+		pkScript, err := txscript.PayToPubKeyHashScript(poolHash[:])
+		if err != nil {
+			continue
+		}
+		idx.poolScripts = append(idx.poolScripts, pkScript)
+	}
+	return idx
+}
+
+// DropScriptTypeIndex drops the script type index from the provided database if
+// it exists.
+func DropScriptTypeIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, scriptTypeIndexKey, scriptTypeIndexName, interrupt)
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// TestScriptTypeCountsSerialization ensures script type index entries are
+// serialized and deserialized as expected.
+func TestScriptTypeCountsSerialization(t *testing.T) {
+	var counts [NumScriptTypes]ScriptTypeCounts
+	counts[ScriptTypePubKeyHash] = ScriptTypeCounts{
+		Created:      3,
+		CreatedValue: 5e8,
+		Spent:        1,
+		SpentValue:   2e8,
+	}
+	counts[ScriptTypePool] = ScriptTypeCounts{Created: 1, CreatedValue: 1e12}
+
+	serialized := serializeScriptTypeCounts(&counts)
+	got, err := deserializeScriptTypeCounts(serialized)
+	if err != nil {
+		t.Fatalf("deserializeScriptTypeCounts: unexpected error: %v", err)
+	}
+	if *got != counts {
+		t.Fatalf("deserialized entry %+v, want %+v", *got, counts)
+	}
+
+	var empty [NumScriptTypes]ScriptTypeCounts
+	if serialized := serializeScriptTypeCounts(&empty); !bytes.Equal(serialized, []byte{0}) {
+		t.Fatalf("serialized empty entry %x, want 00", serialized)
+	}
+
+	for _, bad := range [][]byte{nil, serialized[:len(serialized)-1],
+		append(serialized, 0), {1, byte(NumScriptTypes), 0, 0, 0, 0}} {
+
+		if _, err := deserializeScriptTypeCounts(bad); !isDeserializeErr(err) {
+			t.Errorf("deserializeScriptTypeCounts(%x): got %v, want "+
+				"deserialize error", bad, err)
+		}
+	}
+}
+
+// TestScriptTypeBlockCounts ensures the outputs created and spent by a block
+// are counted by script type, with the outputs paying the coin pools counted
+// apart.
+func TestScriptTypeBlockCounts(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	idx := NewScriptTypeIndex(nil, params)
+
+	poolScript, err := txscript.PayToPubKeyHashScript(params.CoinPoolHashes[0][:])
+	if err != nil {
+		t.Fatalf("PayToPubKeyHashScript: unexpected error: %v", err)
+	}
+	p2pkhScript, err := txscript.PayToPubKeyHashScript(make([]byte, 20))
+	if err != nil {
+		t.Fatalf("PayToPubKeyHashScript: unexpected error: %v", err)
+	}
+	entangleScript, err := txscript.NewEntangleScript(&txscript.EntangleData{
+		ExTxType:  txscript.EntangleTypeLtc,
+		Amount:    big.NewInt(300),
+		ExtTxHash: []byte(strings.Repeat("ab", 32)),
+	})
+	if err != nil {
+		t.Fatalf("NewEntangleScript: unexpected error: %v", err)
+	}
+
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+	})
+	coinbase.AddTxOut(wire.NewTxOut(5000, p2pkhScript))
+	coinbase.AddTxOut(wire.NewTxOut(1000, poolScript))
+
+	spend := wire.NewMsgTx(1)
+	spend.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{0x01}},
+	})
+	spend.AddTxOut(wire.NewTxOut(0, entangleScript))
+	spend.AddTxOut(wire.NewTxOut(700, p2pkhScript))
+
+	block := czzutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase, spend},
+	})
+	stxos := []blockchain.SpentTxOut{{Amount: 800, PkScript: poolScript}}
+	counts, err := idx.blockCounts(block, stxos)
+	if err != nil {
+		t.Fatalf("blockCounts: unexpected error: %v", err)
+	}
+
+	var want [NumScriptTypes]ScriptTypeCounts
+	want[ScriptTypePubKeyHash] = ScriptTypeCounts{Created: 2, CreatedValue: 5700}
+	want[ScriptTypePool] = ScriptTypeCounts{
+		Created:      1,
+		CreatedValue: 1000,
+		Spent:        1,
+		SpentValue:   800,
+	}
+	want[ScriptTypeEntangle] = ScriptTypeCounts{Created: 1}
+	if *counts != want {
+		t.Fatalf("blockCounts: got %+v, want %+v", *counts, want)
+	}
+
+	if _, err := idx.blockCounts(block, nil); err == nil {
+		t.Fatal("blockCounts: no error for missing spent outputs")
+	}
+}
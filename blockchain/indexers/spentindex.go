@@ -0,0 +1,294 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+const (
+	// spentIndexName is the human-readable name for the index.
+	spentIndexName = "spent output index"
+
+	// outpointKeySize is the size of a serialized outpoint used as key in
+	// the spent output index.
+	outpointKeySize = chainhash.HashSize + 4
+
+	// spentEntrySize is the size of a serialized spent output index entry.
+	spentEntrySize = chainhash.HashSize + 4 + 4
+)
+
+var (
+	// spentIndexKey is the key of the spent output index and the db bucket
+	// used to house it.
+	spentIndexKey = []byte("spentbyoutpointidx")
+)
+
+// -----------------------------------------------------------------------------
+// The spent output index consists of an entry for every output spent by a
+// transaction in the main chain.  It maps the outpoint to the input spending
+// it and the height of the block containing the spending transaction.
+//
+// The serialized format for keys and values in the spent output index bucket
+// is:
+//   <outpoint> = <txid><input index><height>
+//
+//   Field           Type              Size
+//   outpoint hash   chainhash.Hash    32 bytes
+//   outpoint index  uint32            4 bytes
+//   -----
+//   Total: 36 bytes
+//
+//   Field           Type              Size
+//   txid            chainhash.Hash    32 bytes
+//   input index     uint32            4 bytes
+//   height          uint32            4 bytes
+//   -----
+//   Total: 40 bytes
+// -----------------------------------------------------------------------------
+
+// SpentInfo describes the input spending an output.
+type SpentInfo struct {
+	// TxHash is the hash of the spending transaction.
+	TxHash chainhash.Hash
+
+	// Index is the index of the spending input in the transaction.
+	Index uint32
+
+	// Height is the height of the block containing the spending
+	// transaction.
+	Height int32
+}
+
+// outpointKey returns the key of the spent output index entry for the passed
+// outpoint.
+func outpointKey(op *wire.OutPoint) []byte {
+	key := make([]byte, outpointKeySize)
+	copy(key, op.Hash[:])
+	byteOrder.PutUint32(key[chainhash.HashSize:], op.Index)
+	return key
+}
+
+// serializeSpentInfo returns the serialized spent output index entry for the
+// passed spending input.
+func serializeSpentInfo(info *SpentInfo) []byte {
+	serialized := make([]byte, spentEntrySize)
+	copy(serialized, info.TxHash[:])
+	byteOrder.PutUint32(serialized[chainhash.HashSize:], info.Index)
+	byteOrder.PutUint32(serialized[chainhash.HashSize+4:],
+		uint32(info.Height))
+	return serialized
+}
+
+// deserializeSpentInfo decodes the passed serialized spent output index entry.
+func deserializeSpentInfo(serialized []byte) (*SpentInfo, error) {
+	if len(serialized) != spentEntrySize {
+		return nil, errDeserialize("unexpected end of data")
+	}
+
+	var info SpentInfo
+	copy(info.TxHash[:], serialized[:chainhash.HashSize])
+	info.Index = byteOrder.Uint32(serialized[chainhash.HashSize:])
+	info.Height = int32(byteOrder.Uint32(serialized[chainhash.HashSize+4:]))
+	return &info, nil
+}
+
+// SpentIndex implements a spent output index.  That is to say, it supports
+// querying which input spent an output.
+type SpentIndex struct {
+	db database.DB
+}
+
+// Ensure the SpentIndex type implements the Indexer interface.
+var _ Indexer = (*SpentIndex)(nil)
+
+// Ensure the SpentIndex type implements the Verifier interface.
+var _ Verifier = (*SpentIndex)(nil)
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Migrate is only provided to satisfy the Indexer interface as there is nothing to
+// migrate this index.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Migrate(db database.DB, interrupt <-chan struct{}) error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Key() []byte {
+	return spentIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Name() string {
+	return spentIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the spent
+// output index.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(spentIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an outpoint-to-input mapping
+// for every output spent by the transactions in the passed block.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) ConnectBlock(dbTx database.Tx, block *czzutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	spentIndex := dbTx.Metadata().Bucket(spentIndexKey)
+	for _, tx := range block.Transactions() {
+		if blockchain.IsCoinBase(tx) {
+			continue
+		}
+
+		for i, txIn := range tx.MsgTx().TxIn {
+			info := SpentInfo{
+				TxHash: *tx.Hash(),
+				Index:  uint32(i),
+				Height: block.Height(),
+			}
+			err := spentIndex.Put(outpointKey(&txIn.PreviousOutPoint),
+				serializeSpentInfo(&info))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the
+// outpoint-to-input mapping for every output spent by the transactions in the
+// block.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) DisconnectBlock(dbTx database.Tx, block *czzutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	spentIndex := dbTx.Metadata().Bucket(spentIndexKey)
+	for _, tx := range block.Transactions() {
+		if blockchain.IsCoinBase(tx) {
+			continue
+		}
+
+		for _, txIn := range tx.MsgTx().TxIn {
+			err := spentIndex.Delete(outpointKey(&txIn.PreviousOutPoint))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// VerifyBlock cross-checks the spent output index entries of the passed block
+// against the inputs of its transactions and returns the number of divergent
+// entries.
+//
+// This is part of the Verifier interface.
+func (idx *SpentIndex) VerifyBlock(dbTx database.Tx, block *czzutil.Block,
+	stxos []blockchain.SpentTxOut, repair bool) (int, error) {
+
+	var divergent int
+	spentIndex := dbTx.Metadata().Bucket(spentIndexKey)
+	for _, tx := range block.Transactions() {
+		if blockchain.IsCoinBase(tx) {
+			continue
+		}
+
+		for i, txIn := range tx.MsgTx().TxIn {
+			info := SpentInfo{
+				TxHash: *tx.Hash(),
+				Index:  uint32(i),
+				Height: block.Height(),
+			}
+			key := outpointKey(&txIn.PreviousOutPoint)
+			expected := serializeSpentInfo(&info)
+			if bytes.Equal(spentIndex.Get(key), expected) {
+				continue
+			}
+
+			divergent++
+			if repair {
+				if err := spentIndex.Put(key, expected); err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+	return divergent, nil
+}
+
+// SpentInfo returns the input in the main chain which spent the passed
+// outpoint.  When the output is not spent, nil will be returned for both the
+// entry and the error.
+//
+// This function is safe for concurrent access.
+func (idx *SpentIndex) SpentInfo(op *wire.OutPoint) (*SpentInfo, error) {
+	var info *SpentInfo
+	err := idx.db.View(func(dbTx database.Tx) error {
+		serialized := dbTx.Metadata().Bucket(spentIndexKey).Get(
+			outpointKey(op))
+		if serialized == nil {
+			return nil
+		}
+
+		var err error
+		info, err = deserializeSpentInfo(serialized)
+		if err != nil {
+			return database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt spent output "+
+					"index entry for %v: %v", op, err),
+			}
+		}
+		return nil
+	})
+	return info, err
+}
+
+// NewSpentIndex returns a new instance of an indexer that is used to create a
+// mapping of all outputs spent in the blockchain to the input spending them.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewSpentIndex(db database.DB) *SpentIndex {
+	return &SpentIndex{db: db}
+}
+
+// DropSpentIndex drops the spent output index from the provided database if it
+// exists.
+func DropSpentIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, spentIndexKey, spentIndexName, interrupt)
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/wire"
+)
+
+// TestSpentInfoSerialization ensures spent output index entries and keys are
+// serialized and deserialized as expected.
+func TestSpentInfoSerialization(t *testing.T) {
+	hash := chainhash.DoubleHashH([]byte("spending tx"))
+	info := SpentInfo{TxHash: hash, Index: 3, Height: 123456}
+
+	serialized := serializeSpentInfo(&info)
+	if len(serialized) != spentEntrySize {
+		t.Fatalf("serialized entry has length %d, want %d",
+			len(serialized), spentEntrySize)
+	}
+	got, err := deserializeSpentInfo(serialized)
+	if err != nil {
+		t.Fatalf("deserializeSpentInfo: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(*got, info) {
+		t.Fatalf("deserialized entry %+v, want %+v", *got, info)
+	}
+
+	if _, err := deserializeSpentInfo(serialized[1:]); !isDeserializeErr(err) {
+		t.Fatalf("deserializeSpentInfo of truncated entry: got %v, want "+
+			"deserialize error", err)
+	}
+
+	// Keys of different outputs of the same transaction must differ.
+	key0 := outpointKey(wire.NewOutPoint(&hash, 0))
+	key1 := outpointKey(wire.NewOutPoint(&hash, 1))
+	if len(key0) != outpointKeySize {
+		t.Fatalf("key has length %d, want %d", len(key0),
+			outpointKeySize)
+	}
+	if bytes.Equal(key0, key1) {
+		t.Fatal("keys of different outpoints are equal")
+	}
+}
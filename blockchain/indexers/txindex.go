@@ -5,6 +5,7 @@
 package indexers
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/bourbaki-czz/classzz/cross"
@@ -347,6 +348,22 @@ type TxIndex struct {
 // Ensure the TxIndex type implements the Indexer interface.
 var _ Indexer = (*TxIndex)(nil)
 
+// Ensure the TxIndex type implements the Verifier interface.
+var _ Verifier = (*TxIndex)(nil)
+
+// Ensure the TxIndex type implements the PruneTolerant interface.
+var _ PruneTolerant = (*TxIndex)(nil)
+
+// ToleratesPruning returns true since the entries of a block do not depend on
+// earlier blocks, so the index can be built on a pruned chain and keeps
+// serving the transactions of the blocks which were not pruned.  The entries of
+// pruned blocks are kept to tell them apart from unknown transactions.
+//
+// This implements the PruneTolerant interface.
+func (idx *TxIndex) ToleratesPruning() bool {
+	return true
+}
+
 // Init initializes the hash-based transaction index.  In particular, it finds
 // the highest used block ID and stores it for later use when connecting or
 // disconnecting blocks.
@@ -506,6 +523,88 @@ func (idx *TxIndex) DisconnectBlock(dbTx database.Tx, block *czzutil.Block,
 	return nil
 }
 
+// VerifyBlock cross-checks the block ID and transaction index entries of the
+// passed block against the block and returns the number of divergent entries.
+// A missing block ID entry renders every transaction entry of the block unusable
+// as well, so they are counted as divergent too.
+//
+// This is part of the Verifier interface.
+func (idx *TxIndex) VerifyBlock(dbTx database.Tx, block *czzutil.Block,
+	stxos []blockchain.SpentTxOut, repair bool) (int, error) {
+
+	// Ensure the block has a block ID which maps back to the block.  A new
+	// block ID is assigned when the entry is missing.
+	var divergent int
+	blockID, err := dbFetchBlockIDByHash(dbTx, block.Hash())
+	switch {
+	case err == errNoBlockIDEntry:
+		if !repair {
+			return 1 + len(block.Transactions()), nil
+		}
+		blockID = idx.curBlockID + 1
+		idx.curBlockID = blockID
+		fallthrough
+
+	case err == nil:
+		hash, err := dbFetchBlockHashByID(dbTx, blockID)
+		if err != nil || !hash.IsEqual(block.Hash()) {
+			divergent++
+			if repair {
+				err := dbPutBlockIDIndexEntry(dbTx, block.Hash(),
+					blockID)
+				if err != nil {
+					return 0, err
+				}
+			}
+		}
+
+	default:
+		return 0, err
+	}
+
+	// The offset and length of the transactions within the serialized
+	// block.
+	txLocs, err := block.TxLoc()
+	if err != nil {
+		return 0, err
+	}
+
+	txIndex := dbTx.Metadata().Bucket(txIndexKey)
+	entangleIndex := dbTx.Metadata().Bucket(cross.BucketKey)
+	for i, tx := range block.Transactions() {
+		expected := make([]byte, txEntrySize)
+		putTxIndexEntry(expected, blockID, txLocs[i])
+		if !bytes.Equal(txIndex.Get(tx.Hash()[:]), expected) {
+			divergent++
+			if repair {
+				err := dbPutTxIndexEntry(dbTx, tx.Hash(), expected)
+				if err != nil {
+					return 0, err
+				}
+			}
+		}
+
+		// Check the entries of the entangle transactions as well.
+		einfos, _ := cross.IsEntangleTx(tx.MsgTx())
+		for _, v := range einfos {
+			key := append(v.ExtTxHash, byte(v.ExTxType))
+			if bytes.Equal(entangleIndex.Get(key), v.Serialize()) {
+				continue
+			}
+
+			divergent++
+			if repair {
+				err := entangleIndex.Put(key, v.Serialize())
+				if err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+
+	return divergent, nil
+}
+
 // TxBlockRegion returns the block region for the provided transaction hash
 // from the transaction index.  The block region can in turn be used to load the
 // raw transaction bytes.  When there is no entry for the provided hash, nil
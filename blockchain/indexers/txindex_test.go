@@ -0,0 +1,190 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/database"
+	_ "github.com/bourbaki-czz/classzz/database/ffldb"
+	"github.com/bourbaki-czz/classzz/mining"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// emptyTxSource is a mining transaction source without transactions, so the
+// generated blocks only contain their coinbase.
+type emptyTxSource struct{}
+
+func (emptyTxSource) LastUpdated() time.Time               { return time.Time{} }
+func (emptyTxSource) Sequence() uint64                     { return 0 }
+func (emptyTxSource) MiningDescs() []*mining.TxDesc        { return nil }
+func (emptyTxSource) HaveTransaction(*chainhash.Hash) bool { return false }
+
+// prunedChainSetup returns a chain on the regression test network pruning the
+// blocks deeper than the passed depth along with its database and a function
+// to clean them up.
+func prunedChainSetup(t *testing.T, pruneDepth uint32) (*blockchain.BlockChain, database.DB, func()) {
+	dir, err := ioutil.TempDir("", "txindex")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	params := &chaincfg.RegressionNetParams
+	db, err := database.Create("ffldb", filepath.Join(dir, "db"), params.Net)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("database.Create: unexpected error: %v", err)
+	}
+	teardown := func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:                 db,
+		ChainParams:        params,
+		TimeSource:         blockchain.NewMedianTime(),
+		ExcessiveBlockSize: 1000000,
+		Prune:              true,
+		PruneDepth:         pruneDepth,
+	})
+	if err != nil {
+		teardown()
+		t.Fatalf("blockchain.New: unexpected error: %v", err)
+	}
+	return chain, db, teardown
+}
+
+// generateBlocks extends the main chain of the passed chain with the passed
+// number of blocks and returns them.
+func generateBlocks(t *testing.T, chain *blockchain.BlockChain, n int) []*czzutil.Block {
+	params := &chaincfg.RegressionNetParams
+	policy := mining.Policy{BlockMaxSize: 1000000}
+	g := mining.NewBlkTmplGenerator(&policy, params, emptyTxSource{}, chain,
+		blockchain.NewMedianTime(), txscript.NewSigCache(100),
+		txscript.NewHashCache(100))
+
+	blocks := make([]*czzutil.Block, 0, n)
+	for i := 0; i < n; i++ {
+		template, err := g.NewBlockTemplate(nil)
+		if err != nil {
+			t.Fatalf("NewBlockTemplate: unexpected error: %v", err)
+		}
+		block := czzutil.NewBlock(template.Block)
+		_, _, err = chain.ProcessBlock(block, blockchain.BFNoPoWCheck)
+		if err != nil {
+			t.Fatalf("ProcessBlock: unexpected error: %v", err)
+		}
+		block.SetHeight(template.Height)
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// TestTxIndexPruned ensures the transaction index is built on a pruned chain
+// starting with the lowest block which was not pruned, serves the
+// transactions of the blocks which were not pruned, and that an index which
+// depends on the pruned blocks is not built.
+func TestTxIndexPruned(t *testing.T) {
+	const pruneDepth = 3
+	chain, db, teardown := prunedChainSetup(t, pruneDepth)
+	defer teardown()
+
+	blocks := generateBlocks(t, chain, 10)
+	pruneHeight := chain.PruneHeight()
+	if want := int32(len(blocks) - pruneDepth); pruneHeight != want {
+		t.Fatalf("got prune height %d, want %d", pruneHeight, want)
+	}
+
+	// The address index needs the pruned blocks.
+	addrIndex := NewAddrIndex(db, &chaincfg.RegressionNetParams)
+	m := NewManager(db, []Indexer{addrIndex})
+	if err := m.Init(chain, nil); err != nil {
+		t.Fatalf("Init: unexpected error: %v", err)
+	}
+	if err := m.catchUp(); err == nil {
+		t.Fatal("catchUp: built the address index on a pruned chain")
+	}
+
+	txIndex := NewTxIndex(db)
+	m = NewManager(db, []Indexer{txIndex})
+	if err := m.Init(chain, nil); err != nil {
+		t.Fatalf("Init: unexpected error: %v", err)
+	}
+	m.Start()
+	bestHeight := chain.BestSnapshot().Height
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		infos, err := m.IndexInfo()
+		if err != nil {
+			t.Fatalf("IndexInfo: unexpected error: %v", err)
+		}
+		if infos[0].Height == bestHeight {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("index did not catch up: got height %d, want %d",
+				infos[0].Height, bestHeight)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	m.Stop()
+
+	for _, block := range blocks {
+		for _, tx := range block.Transactions() {
+			region, err := txIndex.TxBlockRegion(tx.Hash())
+			if err != nil {
+				t.Fatalf("TxBlockRegion: unexpected error: %v", err)
+			}
+
+			// The transactions of pruned blocks are not indexed.
+			if block.Height() < pruneHeight {
+				if region != nil {
+					t.Errorf("height %d: transaction %v of a "+
+						"pruned block is indexed", block.Height(),
+						tx.Hash())
+				}
+				continue
+			}
+
+			if region == nil {
+				t.Errorf("height %d: transaction %v is not indexed",
+					block.Height(), tx.Hash())
+				continue
+			}
+			if !region.Hash.IsEqual(block.Hash()) {
+				t.Errorf("height %d: got block %v, want %v",
+					block.Height(), region.Hash, block.Hash())
+				continue
+			}
+			var txBytes []byte
+			err = db.View(func(dbTx database.Tx) error {
+				var err error
+				txBytes, err = dbTx.FetchBlockRegion(region)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("FetchBlockRegion: unexpected error: %v", err)
+			}
+			var msgTx wire.MsgTx
+			if err := msgTx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+				t.Fatalf("Deserialize: unexpected error: %v", err)
+			}
+			if msgTx.TxHash() != *tx.Hash() {
+				t.Errorf("height %d: got transaction %v, want %v",
+					block.Height(), msgTx.TxHash(), tx.Hash())
+			}
+		}
+	}
+}
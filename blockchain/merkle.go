@@ -6,11 +6,18 @@ package blockchain
 
 import (
 	"math"
+	"runtime"
+	"sync"
 
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
 	"github.com/bourbaki-czz/czzutil"
 )
 
+// parallelMerkleThreshold is the number of hashes of a merkle tree level from
+// which it is hashed by several goroutines.  Below it, the cost of starting
+// them outweighs the hashing.
+const parallelMerkleThreshold = 2048
+
 // nextPowerOfTwo returns the next highest power of two from a given number if
 // it is not already a power of two.  This is a helper function used during the
 // calculation of a merkle tree.
@@ -38,6 +45,85 @@ func HashMerkleBranches(left *chainhash.Hash, right *chainhash.Hash) *chainhash.
 	return &newHash
 }
 
+// hashMerkleBranchesTo sets the passed destination to the hash of the
+// concatenation of the passed left and right tree nodes.  Unlike
+// HashMerkleBranches it allocates no hash.
+func hashMerkleBranchesTo(dst, left, right *chainhash.Hash) {
+	var hash [chainhash.HashSize * 2]byte
+	copy(hash[:chainhash.HashSize], left[:])
+	copy(hash[chainhash.HashSize:], right[:])
+	*dst = chainhash.DoubleHashH(hash[:])
+}
+
+// parallelFor calls the passed function with consecutive ranges of the indexes
+// up to n, covering them all.  The ranges are handled by one goroutine per
+// processor once n reaches parallelMerkleThreshold, and by the caller before.
+func parallelFor(n int, f func(start, end int)) {
+	workers := runtime.NumCPU()
+	if n < parallelMerkleThreshold || workers < 2 {
+		f(0, n)
+		return
+	}
+
+	var wg sync.WaitGroup
+	chunk := (n + workers - 1) / workers
+	for start := 0; start < n; start += chunk {
+		end := start + chunk
+		if end > n {
+			end = n
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			f(start, end)
+			wg.Done()
+		}(start, end)
+	}
+	wg.Wait()
+}
+
+// CalcMerkleRoot returns the merkle root of the passed transactions, which is
+// the last element of the tree returned by BuildMerkleTreeStore.  It is meant
+// for the callers which only need the root, such as when checking the sanity
+// of a block.
+//
+// Rather than storing the whole tree, only two levels of it are kept at a time
+// in buffers allocated once, and the levels of the large blocks are hashed in
+// parallel.  The transaction hashes are computed in parallel as well unless
+// the transactions already cached them.  The merkle root of no transactions is
+// the zero hash.
+func CalcMerkleRoot(transactions []*czzutil.Tx) chainhash.Hash {
+	if len(transactions) == 0 {
+		return chainhash.Hash{}
+	}
+
+	level := make([]chainhash.Hash, len(transactions))
+	parallelFor(len(level), func(start, end int) {
+		for i := start; i < end; i++ {
+			level[i] = *transactions[i].Hash()
+		}
+	})
+
+	// Each level is hashed from the one before it into the buffer not
+	// holding it.  A level with an odd number of nodes has its last node
+	// hashed with itself.
+	next := make([]chainhash.Hash, (len(level)+1)/2)
+	for len(level) > 1 {
+		parents := next[:(len(level)+1)/2]
+		parallelFor(len(parents), func(start, end int) {
+			for i := start; i < end; i++ {
+				left := &level[2*i]
+				right := left
+				if 2*i+1 < len(level) {
+					right = &level[2*i+1]
+				}
+				hashMerkleBranchesTo(&parents[i], left, right)
+			}
+		})
+		level, next = parents, level
+	}
+	return level[0]
+}
+
 // BuildMerkleTreeStore creates a merkle tree from a slice of transactions,
 // stores it using a linear array, and returns a slice of the backing array.  A
 // linear array was chosen as opposed to an actual tree structure since it uses
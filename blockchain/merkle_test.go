@@ -5,8 +5,10 @@
 package blockchain
 
 import (
-	"github.com/bourbaki-czz/czzutil"
 	"testing"
+
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
 )
 
 // TestMerkle tests the BuildMerkleTreeStore API.
@@ -20,3 +22,30 @@ func TestMerkle(t *testing.T) {
 			"got %v, want %v", calculatedMerkleRoot, wantMerkle)
 	}
 }
+
+// TestCalcMerkleRoot ensures CalcMerkleRoot returns the root of the tree built
+// by BuildMerkleTreeStore, including for the numbers of transactions with odd
+// levels and those hashed in parallel.
+func TestCalcMerkleRoot(t *testing.T) {
+	block := czzutil.NewBlock(&Block100000)
+	root := CalcMerkleRoot(block.Transactions())
+	if !Block100000.Header.MerkleRoot.IsEqual(&root) {
+		t.Errorf("CalcMerkleRoot: merkle root mismatch - got %v, want %v",
+			root, Block100000.Header.MerkleRoot)
+	}
+
+	for _, numTxns := range []int{1, 2, 3, 5, 7, parallelMerkleThreshold + 1, 5000} {
+		txns := make([]*czzutil.Tx, numTxns)
+		for i := range txns {
+			msgTx := wire.NewMsgTx(1)
+			msgTx.LockTime = uint32(i)
+			txns[i] = czzutil.NewTx(msgTx)
+		}
+		merkles := BuildMerkleTreeStore(txns)
+		want := merkles[len(merkles)-1]
+		if got := CalcMerkleRoot(txns); !want.IsEqual(&got) {
+			t.Errorf("CalcMerkleRoot: merkle root mismatch for %d "+
+				"transactions - got %v, want %v", numTxns, got, want)
+		}
+	}
+}
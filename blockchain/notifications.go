@@ -6,6 +6,9 @@ package blockchain
 
 import (
 	"fmt"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/czzutil"
 )
 
 // NotificationType represents the type of a notification message.
@@ -29,6 +32,11 @@ const (
 	// NTBlockDisconnected indicates the associated block was disconnected
 	// from the main chain.
 	NTBlockDisconnected
+
+	// NTChainReorganized indicates the main chain was reorganized to a new
+	// best chain.  It is sent once all the blocks of the reorganization were
+	// disconnected and connected.
+	NTChainReorganized
 )
 
 // notificationTypeStrings is a map of notification types back to their constant
@@ -37,6 +45,7 @@ var notificationTypeStrings = map[NotificationType]string{
 	NTBlockAccepted:     "NTBlockAccepted",
 	NTBlockConnected:    "NTBlockConnected",
 	NTBlockDisconnected: "NTBlockDisconnected",
+	NTChainReorganized:  "NTChainReorganized",
 }
 
 // String returns the NotificationType in human-readable form.
@@ -53,11 +62,27 @@ func (n NotificationType) String() string {
 // 	- NTBlockAccepted:     *czzutil.Block
 // 	- NTBlockConnected:    *czzutil.Block
 // 	- NTBlockDisconnected: *czzutil.Block
+// 	- NTChainReorganized:  *ReorganizationData
 type Notification struct {
 	Type NotificationType
 	Data interface{}
 }
 
+// ReorganizationData is the data of a NTChainReorganized notification.  The
+// detached blocks are listed in the order they were disconnected, from the old
+// tip down to the fork point, and the attached blocks in the order they were
+// connected, from the fork point up to the new tip.
+type ReorganizationData struct {
+	OldHash    chainhash.Hash
+	OldHeight  int32
+	NewHash    chainhash.Hash
+	NewHeight  int32
+	ForkHash   chainhash.Hash
+	ForkHeight int32
+	Detached   []*czzutil.Block
+	Attached   []*czzutil.Block
+}
+
 // Subscribe to block chain notifications. Registers a callback to be executed
 // when various events take place. See the documentation on Notification and
 // NotificationType for details on the types and contents of notifications.
@@ -9,6 +9,7 @@ import (
 
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
 	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/wire"
 	"github.com/bourbaki-czz/czzutil"
 )
 
@@ -65,15 +66,11 @@ func (b *BlockChain) blockExists(hash *chainhash.Hash) (bool, error) {
 			return err
 		}
 
-		// Ignore side chain blocks in the database.  This is necessary
-		// because there is not currently any record of the associated
-		// block index data such as its block height, so it's not yet
-		// possible to efficiently load the block and do anything useful
-		// with it.
-		//
-		// Ultimately the entire block index should be serialized
-		// instead of only the current main chain so it can be consulted
-		// directly.
+		// Ignore side chain blocks in the database.  The stored side
+		// chain blocks are part of the block index checked above, so
+		// those which are not were stored right before the node stopped
+		// without recording their block index entry, and are processed
+		// again to add it.
 		_, err = dbFetchHeightByHash(dbTx, hash)
 		if isNotInMainChainErr(err) {
 			exists = false
@@ -187,6 +184,17 @@ func (b *BlockChain) ProcessBlock(block *czzutil.Block, flags BehaviorFlags) (bo
 	if err != nil {
 		return false, false, err
 	}
+
+	// Start fetching the utxos spent by a block extending the main chain
+	// from the database while the block is checked, since they are looked
+	// up from the utxo cache to connect it.  The cheap proof of work check
+	// keeps blocks which took no work from causing the lookups.
+	if prevHashExists && prevHash.IsEqual(&b.bestChain.Tip().hash) &&
+		checkProofOfWork(blockHeader, b.chainParams.PowLimit, flags) == nil {
+
+		b.utxoPrefetch = b.utxoCache.prefetchInputUtxos(block)
+		defer b.cancelUtxoPrefetch()
+	}
 	// Perform preliminary sanity checks on the block and its transactions.
 	err = checkBlockSanity(b, block, b.chainParams.PowLimit, b.timeSource, flags)
 	if err != nil {
@@ -224,3 +232,83 @@ func (b *BlockChain) ProcessBlock(block *czzutil.Block, flags BehaviorFlags) (bo
 
 	return isMainChain, false, nil
 }
+
+// ProcessHeaders validates a contiguous batch of block headers, the first one
+// extending a known block or header, and stores the ones not known yet in the
+// block index so they can be served and their blocks requested before the
+// blocks arrive.  The proof of work, difficulty, timestamps and checkpoints of
+// every header are checked like those of blocks, while the checks involving
+// the transactions are left to ProcessBlock once the blocks arrive.
+//
+// The batch is processed with a single acquisition of the chain lock and the
+// new headers are written to the database in a single transaction.  No header
+// is stored when any of them is invalid.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ProcessHeaders(headers []wire.BlockHeader) error {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	prevHash := &headers[0].PrevBlock
+	prevNode := b.index.LookupNode(prevHash)
+	if prevNode == nil {
+		str := fmt.Sprintf("previous block %s is unknown", prevHash)
+		return ruleError(ErrPreviousBlockUnknown, str)
+	}
+
+	newNodes := make([]*blockNode, 0, len(headers))
+	for i := range headers {
+		header := &headers[i]
+		if header.PrevBlock != prevNode.hash {
+			str := fmt.Sprintf("header %d of the batch does not "+
+				"extend the header %v before it", i, prevNode.hash)
+			return ruleError(ErrHeadersNotContiguous, str)
+		}
+		if b.index.NodeStatus(prevNode).KnownInvalid() {
+			str := fmt.Sprintf("previous block %s is known to be "+
+				"invalid", prevNode.hash)
+			return ruleError(ErrInvalidAncestorBlock, str)
+		}
+
+		// Headers which are already known only need to be linked to.
+		blockHash := header.BlockHash()
+		if node := b.index.LookupNode(&blockHash); node != nil {
+			prevNode = node
+			continue
+		}
+
+		err := checkBlockHeaderSanity(b, header, b.chainParams.PowLimit,
+			b.timeSource, BFNone)
+		if err != nil {
+			return err
+		}
+		err = b.checkBlockHeaderContext(header, prevNode, BFNone)
+		if err != nil {
+			return err
+		}
+
+		// The node is linked to its parent right away since the context
+		// of the next header depends on it, but it is only added to the
+		// index once the whole batch passed.
+		prevNode = newBlockNode(header, prevNode)
+		newNodes = append(newNodes, prevNode)
+	}
+
+	for _, node := range newNodes {
+		b.index.AddNode(node)
+	}
+	err := b.index.flushToDB()
+	if err != nil {
+		return err
+	}
+
+	if len(newNodes) > 0 {
+		log.Debugf("Accepted %d headers up to %v (height %d)",
+			len(newNodes), prevNode.hash, prevNode.height)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/wire"
+)
+
+// chainedHeaders returns the passed number of headers extending the passed
+// node, each one the passed duration after the one before it, with the
+// difficulty expected by the chain.
+func chainedHeaders(t *testing.T, chain *BlockChain, parent *blockNode,
+	spacing time.Duration, numHeaders int) []wire.BlockHeader {
+
+	headers := make([]wire.BlockHeader, 0, numHeaders)
+	prevNode := parent
+	for i := 0; i < numHeaders; i++ {
+		timestamp := time.Unix(prevNode.timestamp, 0).Add(spacing)
+		bits, err := chain.calcNextRequiredDifficulty(prevNode, timestamp)
+		if err != nil {
+			t.Fatalf("calcNextRequiredDifficulty: unexpected error: %v",
+				err)
+		}
+		header := wire.BlockHeader{
+			Version:   1,
+			PrevBlock: prevNode.hash,
+			Timestamp: timestamp,
+			Bits:      bits,
+			Nonce:     uint64(i),
+		}
+		headers = append(headers, header)
+		prevNode = newBlockNode(&header, prevNode)
+	}
+	return headers
+}
+
+// TestProcessHeaders ensures batches of headers are validated and stored ahead
+// of their blocks, and that no header of a batch is stored when one of them is
+// invalid.
+func TestProcessHeaders(t *testing.T) {
+	chain, teardownFunc, err := chainSetup("processheaders",
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	tip := chain.bestChain.Tip()
+	headers := chainedHeaders(t, chain, tip, time.Minute, 5)
+
+	// Overlapping batches only store the headers not known yet.
+	if err := chain.ProcessHeaders(headers[:3]); err != nil {
+		t.Fatalf("ProcessHeaders: unexpected error: %v", err)
+	}
+	if err := chain.ProcessHeaders(headers[1:]); err != nil {
+		t.Fatalf("ProcessHeaders: unexpected error: %v", err)
+	}
+	for i := range headers {
+		hash := headers[i].BlockHash()
+		node := chain.index.LookupNode(&hash)
+		if node == nil {
+			t.Fatalf("header %d was not stored", i)
+		}
+		if node.height != tip.height+int32(i)+1 {
+			t.Fatalf("header %d: got height %d, want %d", i,
+				node.height, tip.height+int32(i)+1)
+		}
+		if chain.index.NodeStatus(node).HaveData() {
+			t.Fatalf("header %d has block data", i)
+		}
+
+		// The blocks are still to be requested.
+		haveBlock, err := chain.HaveBlock(&hash)
+		if err != nil || haveBlock {
+			t.Fatalf("header %d: HaveBlock returned %v (%v)", i,
+				haveBlock, err)
+		}
+	}
+	if chain.bestChain.Tip() != tip {
+		t.Fatalf("the best chain moved to %v", chain.bestChain.Tip().hash)
+	}
+
+	checkRejected := func(name string, batch []wire.BlockHeader,
+		code ErrorCode) {
+
+		err := chain.ProcessHeaders(batch)
+		rerr, ok := err.(RuleError)
+		if !ok || rerr.ErrorCode != code {
+			t.Errorf("%s: got error %v, want %v", name, err, code)
+		}
+		for i := range batch {
+			hash := batch[i].BlockHash()
+			if chain.index.LookupNode(&hash) != nil &&
+				!isProcessedHeader(headers, &hash) {
+
+				t.Errorf("%s: header %d was stored", name, i)
+			}
+		}
+	}
+
+	checkRejected("not contiguous", []wire.BlockHeader{headers[0],
+		headers[2]}, ErrHeadersNotContiguous)
+
+	orphan := headers[0]
+	orphan.PrevBlock = chainhash.Hash{0x01}
+	checkRejected("unknown previous block", []wire.BlockHeader{orphan},
+		ErrPreviousBlockUnknown)
+
+	// A fork whose last header is older than the median time of the
+	// headers before it.
+	fork := chainedHeaders(t, chain, tip, 2*time.Minute, 3)
+	forkTip := chain.index.LookupNode(&tip.hash)
+	for i := 0; i < 2; i++ {
+		forkTip = newBlockNode(&fork[i], forkTip)
+	}
+	fork[2].Timestamp = time.Unix(tip.timestamp, 0)
+	fork[2].Bits, err = chain.calcNextRequiredDifficulty(forkTip,
+		fork[2].Timestamp)
+	if err != nil {
+		t.Fatalf("calcNextRequiredDifficulty: unexpected error: %v", err)
+	}
+	checkRejected("time too old", fork, ErrTimeTooOld)
+
+	if err := chain.ProcessHeaders(nil); err != nil {
+		t.Errorf("ProcessHeaders: unexpected error for no headers: %v",
+			err)
+	}
+}
+
+// isProcessedHeader returns whether the passed hash is the one of one of the
+// passed headers.
+func isProcessedHeader(headers []wire.BlockHeader, hash *chainhash.Hash) bool {
+	for i := range headers {
+		if headers[i].BlockHash() == *hash {
+			return true
+		}
+	}
+	return false
+}
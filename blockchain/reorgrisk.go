@@ -0,0 +1,149 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+)
+
+// MaxReorgRiskDepth is the largest number of blocks below a block that
+// ReorgRisk looks for competing forks at.
+const MaxReorgRiskDepth = 2016
+
+// CompetingFork is a side chain which does not contain a block of the main
+// chain, so the block would be disconnected if the side chain became the main
+// chain.
+type CompetingFork struct {
+	// TipHash and TipHeight identify the last block of the side chain.
+	TipHash   chainhash.Hash
+	TipHeight int32
+
+	// ForkHeight is the height of the last block the side chain has in
+	// common with the main chain.
+	ForkHeight int32
+
+	// Work is the total work of the blocks of the side chain after the fork
+	// and WorkBehind how much more work the main chain has.
+	Work       *big.Int
+	WorkBehind *big.Int
+
+	// HeadersOnly is whether only the header of the tip is known, such as
+	// for the headers announced ahead of their blocks.
+	HeadersOnly bool
+}
+
+// ReorgRisk describes how well a block of the main chain is confirmed, so
+// that the number of confirmations required for a transaction can depend on
+// the work confirming it and on the competing forks rather than on a fixed
+// number of blocks.
+type ReorgRisk struct {
+	// Hash and Height identify the block.
+	Hash   chainhash.Hash
+	Height int32
+
+	// Confirmations is the number of blocks of the main chain from the
+	// block to the best block, both included.
+	Confirmations int32
+
+	// WorkOnTop is the total work of the blocks of the main chain after
+	// the block.
+	WorkOnTop *big.Int
+
+	// Depth is the number of blocks below the block the competing forks
+	// were looked for at.
+	Depth int32
+
+	// Forks are the competing forks forking off the main chain at most
+	// Depth blocks below the block, the closest to overtaking the main
+	// chain first.  The side chains containing a block known to be
+	// invalid are left out, since they can not become the main chain.
+	Forks []CompetingFork
+}
+
+// ReorgRisk returns the confirmations of the block of the main chain with the
+// passed hash, the work on top of it and the competing forks forking off the
+// main chain at most the passed number of blocks below it.
+//
+// All of the block index is scanned for the side chains, so the cost grows
+// with the number of known blocks.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ReorgRisk(hash *chainhash.Hash, depth int32) (*ReorgRisk, error) {
+	if depth < 0 || depth > MaxReorgRiskDepth {
+		return nil, fmt.Errorf("the depth must be between 0 and %d "+
+			"blocks", MaxReorgRiskDepth)
+	}
+
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	node := b.index.LookupNode(hash)
+	if node == nil || !b.bestChain.Contains(node) {
+		str := fmt.Sprintf("block %s is not in the main chain", hash)
+		return nil, errNotInMainChain(str)
+	}
+	tip := b.bestChain.Tip()
+
+	risk := &ReorgRisk{
+		Hash:          node.hash,
+		Height:        node.height,
+		Confirmations: tip.height - node.height + 1,
+		WorkOnTop:     new(big.Int).Sub(tip.workSum, node.workSum),
+		Depth:         depth,
+	}
+	minForkHeight := node.height - depth
+	if minForkHeight < 0 {
+		minForkHeight = 0
+	}
+
+	// Collect the blocks of the side chains forking off in range.  Only
+	// the blocks above the lowest fork height can be part of such a side
+	// chain.
+	b.index.RLock()
+	sideNodes := make(map[*blockNode]*blockNode)
+	parents := make(map[*blockNode]struct{})
+	for _, n := range b.index.index {
+		if n.height <= minForkHeight || n.status.KnownInvalid() ||
+			b.bestChain.Contains(n) {
+			continue
+		}
+		fork := b.bestChain.FindFork(n)
+		if fork == nil || fork.height < minForkHeight ||
+			fork.height >= node.height {
+			continue
+		}
+		sideNodes[n] = fork
+		parents[n.parent] = struct{}{}
+	}
+
+	// The tips of the side chains are the blocks no other block of a side
+	// chain builds on.
+	for n, fork := range sideNodes {
+		if _, ok := parents[n]; ok {
+			continue
+		}
+		risk.Forks = append(risk.Forks, CompetingFork{
+			TipHash:     n.hash,
+			TipHeight:   n.height,
+			ForkHeight:  fork.height,
+			Work:        new(big.Int).Sub(n.workSum, fork.workSum),
+			WorkBehind:  new(big.Int).Sub(tip.workSum, n.workSum),
+			HeadersOnly: !n.status.HaveData(),
+		})
+	}
+	b.index.RUnlock()
+
+	sort.Slice(risk.Forks, func(i, j int) bool {
+		if c := risk.Forks[i].WorkBehind.Cmp(risk.Forks[j].WorkBehind); c != 0 {
+			return c < 0
+		}
+		return risk.Forks[i].TipHeight > risk.Forks[j].TipHeight
+	})
+	return risk, nil
+}
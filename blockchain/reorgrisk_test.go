@@ -0,0 +1,101 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+)
+
+// TestReorgRisk ensures the confirmations and the work on top of a block are
+// reported along with the competing forks forking off the main chain within
+// the depth below the block.
+func TestReorgRisk(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	chain := newFakeChain(&params)
+
+	// extend adds the passed number of blocks with the passed status on top
+	// of the passed node and returns the last one.  The blocks of each
+	// chain are spaced differently so their hashes differ.
+	extend := func(node *blockNode, numBlocks int, spacing time.Duration, status blockStatus) *blockNode {
+		for i := 0; i < numBlocks; i++ {
+			node = newFakeNode(node, 1, node.bits,
+				time.Unix(node.timestamp, 0).Add(spacing))
+			node.status = status
+			chain.index.AddNode(node)
+		}
+		return node
+	}
+
+	// Build a main chain of 10 blocks with a side chain of 2 blocks forking
+	// off at height 7, a headers only side chain of 1 block forking off at
+	// height 3, an invalid side chain forking off at height 6 and a side
+	// chain forking off above the block at height 9.
+	tip := extend(chain.bestChain.Tip(), 10, time.Minute,
+		statusDataStored|statusValid)
+	chain.bestChain.SetTip(tip)
+	near := extend(tip.Ancestor(7), 2, 2*time.Minute, statusDataStored)
+	headers := extend(tip.Ancestor(3), 1, 3*time.Minute, statusNone)
+	extend(tip.Ancestor(6), 3, 4*time.Minute,
+		statusDataStored|statusValidateFailed)
+	extend(tip.Ancestor(9), 1, 5*time.Minute, statusDataStored)
+
+	block := tip.Ancestor(8)
+	risk, err := chain.ReorgRisk(&block.hash, 5)
+	if err != nil {
+		t.Fatalf("ReorgRisk: unexpected error: %v", err)
+	}
+	if risk.Height != 8 || risk.Confirmations != 3 {
+		t.Errorf("got height %d and %d confirmations, want 8 and 3",
+			risk.Height, risk.Confirmations)
+	}
+	work := CalcWork(tip.bits)
+	wantWorkOnTop := new(big.Int).Mul(work, big.NewInt(2))
+	if risk.WorkOnTop.Cmp(wantWorkOnTop) != 0 {
+		t.Errorf("got work on top %v, want %v", risk.WorkOnTop,
+			wantWorkOnTop)
+	}
+	if len(risk.Forks) != 2 {
+		t.Fatalf("got %d forks, want 2", len(risk.Forks))
+	}
+
+	// The side chain with the most work comes first.
+	fork := risk.Forks[0]
+	if fork.TipHash != near.hash || fork.ForkHeight != 7 ||
+		fork.TipHeight != 9 || fork.HeadersOnly {
+		t.Errorf("got first fork %+v, want the fork at height 7", fork)
+	}
+	if fork.Work.Cmp(wantWorkOnTop) != 0 || fork.WorkBehind.Cmp(work) != 0 {
+		t.Errorf("got fork work %v and %v behind, want %v and %v",
+			fork.Work, fork.WorkBehind, wantWorkOnTop, work)
+	}
+	fork = risk.Forks[1]
+	if fork.TipHash != headers.hash || fork.ForkHeight != 3 ||
+		!fork.HeadersOnly {
+		t.Errorf("got second fork %+v, want the headers at height 3", fork)
+	}
+
+	// A smaller depth leaves out the fork at height 3.
+	risk, err = chain.ReorgRisk(&block.hash, 4)
+	if err != nil {
+		t.Fatalf("ReorgRisk: unexpected error: %v", err)
+	}
+	if len(risk.Forks) != 1 || risk.Forks[0].TipHash != near.hash {
+		t.Errorf("got forks %+v, want only the fork at height 7",
+			risk.Forks)
+	}
+
+	// Blocks which are not in the main chain and depths out of range are
+	// rejected.
+	if _, err := chain.ReorgRisk(&near.hash, 5); err == nil {
+		t.Errorf("ReorgRisk: no error for a block of a side chain")
+	}
+	if _, err := chain.ReorgRisk(&block.hash, MaxReorgRiskDepth+1); err == nil {
+		t.Errorf("ReorgRisk: no error for a depth out of range")
+	}
+}
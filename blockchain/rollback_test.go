@@ -0,0 +1,70 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/wire"
+)
+
+// TestRollbackToHeight ensures rolling the chain back disconnects the blocks
+// above the height, restores the outputs they spent and removes the ones they
+// created, and that the chain stays rolled back after it is loaded again.
+func TestRollbackToHeight(t *testing.T) {
+	tc := newTestChain(t)
+	defer func() { tc.teardown() }()
+
+	maturity := int(tc.params.CoinbaseMaturity)
+	blocks := tc.generate(maturity)
+	spend := tc.spend(blocks[0])
+	detached := tc.generate(2)
+	spentOut := spend.MsgTx().TxIn[0].PreviousOutPoint
+	spendOut := wire.OutPoint{Hash: *spend.Hash()}
+	coinbaseOut := wire.OutPoint{Hash: *detached[1].Transactions()[0].Hash()}
+	tc.checkUtxo("before rollback", spentOut, false)
+	tc.checkUtxo("before rollback", spendOut, true)
+
+	for _, height := range []int32{-1, int32(maturity) + 3} {
+		if err := tc.chain.RollbackToHeight(height); err == nil {
+			t.Fatalf("RollbackToHeight(%d): rollback out of the main "+
+				"chain accepted", height)
+		}
+	}
+	height := int32(maturity)
+	if err := tc.chain.RollbackToHeight(height); err != nil {
+		t.Fatalf("RollbackToHeight(%d): unexpected error: %v", height, err)
+	}
+
+	for _, reopened := range []bool{false, true} {
+		name := "after rollback"
+		if reopened {
+			name = "after reopening"
+			tc.reopen()
+		}
+		best := tc.chain.BestSnapshot()
+		if best.Height != height || best.Hash != *blocks[maturity-1].Hash() {
+			t.Fatalf("%s: got tip %v (height %d), want %v (height %d)",
+				name, best.Hash, best.Height, blocks[maturity-1].Hash(),
+				height)
+		}
+		for _, block := range detached {
+			if tc.chain.MainChainHasBlock(block.Hash()) {
+				t.Fatalf("%s: block %v is in the main chain", name,
+					block.Hash())
+			}
+		}
+		tc.checkUtxo(name, spentOut, true)
+		tc.checkUtxo(name, spendOut, false)
+		tc.checkUtxo(name, coinbaseOut, false)
+	}
+
+	// The chain grows again from the height it was rolled back to.
+	tip := tc.generate(1)[0]
+	if best := tc.chain.BestSnapshot(); best.Hash != *tip.Hash() {
+		t.Fatalf("got tip %v, want %v", best.Hash, tip.Hash())
+	}
+	tc.checkUtxo("new tip", spentOut, true)
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"sync"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/txscript"
+)
+
+// DefaultScriptCacheSize is the default number of transactions the successful
+// script validations are remembered for.
+const DefaultScriptCacheSize = 100000
+
+// scriptCacheKey identifies the validation of the scripts of a transaction with
+// a set of script flags.
+type scriptCacheKey struct {
+	txHash chainhash.Hash
+	flags  txscript.ScriptFlags
+}
+
+// scriptCache is a concurrency safe cache of the transactions whose scripts
+// passed validation with a set of script flags, along with the number of
+// signature checks they executed, with a randomized eviction policy like the
+// one of the signature cache.  It spares validating again the scripts of the
+// transactions accepted into the memory pool once their block arrives, which
+// otherwise is most of the work of connecting a block.
+//
+// The results are keyed by transaction hash, which commits to the outputs the
+// inputs spend and therefore to all the scripts and amounts the validation
+// depends on, and by the flags, since a transaction passing with some flags
+// may not pass with others.  Only successful validations are cached.
+type scriptCache struct {
+	mtx        sync.RWMutex
+	validTxs   map[scriptCacheKey]int
+	maxEntries uint
+}
+
+// newScriptCache returns a new script cache holding up to the passed number of
+// transactions.  A cache with a limit of zero holds no transaction.
+func newScriptCache(maxEntries uint) *scriptCache {
+	return &scriptCache{
+		validTxs:   make(map[scriptCacheKey]int),
+		maxEntries: maxEntries,
+	}
+}
+
+// Lookup returns the number of signature checks executed by the scripts of the
+// transaction with the passed hash and whether they are known to pass
+// validation with the passed flags.
+//
+// This function is safe for concurrent access.
+func (c *scriptCache) Lookup(txHash *chainhash.Hash, flags txscript.ScriptFlags) (int, bool) {
+	c.mtx.RLock()
+	sigChecks, ok := c.validTxs[scriptCacheKey{*txHash, flags}]
+	c.mtx.RUnlock()
+	return sigChecks, ok
+}
+
+// Add records the scripts of the transaction with the passed hash passed
+// validation with the passed flags, executing the passed number of signature
+// checks.  A random entry is evicted when the cache is full.
+//
+// This function is safe for concurrent access.
+func (c *scriptCache) Add(txHash *chainhash.Hash, flags txscript.ScriptFlags, sigChecks int) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.maxEntries == 0 {
+		return
+	}
+	key := scriptCacheKey{*txHash, flags}
+	if _, ok := c.validTxs[key]; !ok && uint(len(c.validTxs)) >= c.maxEntries {
+		// Relying on the random starting point of the map iteration, as
+		// the signature cache does.
+		for entry := range c.validTxs {
+			delete(c.validTxs, entry)
+			break
+		}
+	}
+	c.validTxs[key] = sigChecks
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/txscript"
+)
+
+// TestScriptCache ensures the script cache remembers validations per
+// transaction and flags and holds no more than its limit.
+func TestScriptCache(t *testing.T) {
+	hashes := make([]chainhash.Hash, 4)
+	for i := range hashes {
+		hashes[i][0] = byte(i + 1)
+	}
+	flags := txscript.ScriptBip16 | txscript.ScriptVerifySigChecks
+
+	cache := newScriptCache(3)
+	cache.Add(&hashes[0], flags, 7)
+	if sigChecks, ok := cache.Lookup(&hashes[0], flags); !ok || sigChecks != 7 {
+		t.Fatalf("Lookup: got (%d, %v), want (7, true)", sigChecks, ok)
+	}
+	if _, ok := cache.Lookup(&hashes[0], txscript.ScriptBip16); ok {
+		t.Fatalf("Lookup: validation found for other flags")
+	}
+	if _, ok := cache.Lookup(&hashes[1], flags); ok {
+		t.Fatalf("Lookup: validation found for another transaction")
+	}
+
+	// Adding an entry again does not evict another one.
+	cache.Add(&hashes[1], flags, 1)
+	cache.Add(&hashes[2], flags, 2)
+	cache.Add(&hashes[2], flags, 2)
+	if len(cache.validTxs) != 3 {
+		t.Fatalf("got %d entries, want 3", len(cache.validTxs))
+	}
+	cache.Add(&hashes[3], flags, 3)
+	if len(cache.validTxs) != 3 {
+		t.Fatalf("got %d entries past the limit, want 3",
+			len(cache.validTxs))
+	}
+	if _, ok := cache.Lookup(&hashes[3], flags); !ok {
+		t.Fatalf("Lookup: newest validation evicted")
+	}
+
+	// A cache without a limit holds nothing.
+	cache = newScriptCache(0)
+	cache.Add(&hashes[0], flags, 7)
+	if _, ok := cache.Lookup(&hashes[0], flags); ok {
+		t.Fatalf("Lookup: validation found in a disabled cache")
+	}
+}
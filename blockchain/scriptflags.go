@@ -0,0 +1,58 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/txscript"
+)
+
+// scriptFlagActivation is an activation of the script flag schedule of the
+// chain parameters with the names of the flags resolved.  The flags include
+// those of all the previous activations.
+type scriptFlagActivation struct {
+	height int32
+	flags  txscript.ScriptFlags
+}
+
+// resolveScriptFlagSchedule returns the script flag schedule of the passed
+// network parameters with the flags of every activation resolved and
+// accumulated, or an error when the schedule is not valid or names an unknown
+// flag.
+func resolveScriptFlagSchedule(params *chaincfg.Params) ([]scriptFlagActivation, error) {
+	if err := params.CheckScriptFlagSchedule(); err != nil {
+		return nil, err
+	}
+
+	schedule := make([]scriptFlagActivation, 0, len(params.ScriptFlagSchedule))
+	var flags txscript.ScriptFlags
+	for _, activation := range params.ScriptFlagSchedule {
+		for _, name := range activation.Flags {
+			flag, err := txscript.ParseScriptFlag(name)
+			if err != nil {
+				return nil, err
+			}
+			flags |= flag
+		}
+		schedule = append(schedule, scriptFlagActivation{
+			height: activation.Height,
+			flags:  flags,
+		})
+	}
+	return schedule, nil
+}
+
+// scheduledScriptFlags returns the script flags the script flag schedule
+// enables for the block at the passed height.
+func (b *BlockChain) scheduledScriptFlags(height int32) txscript.ScriptFlags {
+	var flags txscript.ScriptFlags
+	for _, activation := range b.scriptFlagSchedule {
+		if activation.height > height {
+			break
+		}
+		flags = activation.flags
+	}
+	return flags
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/txscript"
+)
+
+// TestScriptFlagSchedule ensures the blocks are validated with the script
+// flags of all the activations of the schedule at or below their height and
+// that schedules naming unknown flags or missing the genesis block are
+// rejected.
+func TestScriptFlagSchedule(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	schedule, err := resolveScriptFlagSchedule(&params)
+	if err != nil {
+		t.Fatalf("resolveScriptFlagSchedule: unexpected error: %v", err)
+	}
+	chain := newFakeChain(&params)
+	chain.scriptFlagSchedule = schedule
+	flags, err := chain.blockScriptFlags(chain.bestChain.Tip())
+	if err != nil {
+		t.Fatalf("blockScriptFlags: unexpected error: %v", err)
+	}
+	want := txscript.ScriptBip16 | txscript.ScriptVerifyDERSignatures |
+		txscript.ScriptVerifyCheckLockTimeVerify |
+		txscript.ScriptVerifyStrictEncoding |
+		txscript.ScriptVerifyBip143SigHash | txscript.ScriptVerifyLowS |
+		txscript.ScriptVerifyNullFail | txscript.ScriptVerifySigPushOnly |
+		txscript.ScriptVerifyCleanStack |
+		txscript.ScriptVerifyCheckDataSig | txscript.ScriptVerifySchnorr |
+		txscript.ScriptVerifyAllowSegwitRecovery
+	if flags != want {
+		t.Errorf("blockScriptFlags: got %#x, want %#x", flags, want)
+	}
+
+	params.ScriptFlagSchedule = []chaincfg.ScriptFlagActivation{
+		{Height: 0, Flags: []string{"DERSIG"}},
+		{Height: 10, Flags: []string{"P2SH"}},
+		{Height: 20, Flags: []string{"STRICTENC", "LOW_S"}},
+	}
+	schedule, err = resolveScriptFlagSchedule(&params)
+	if err != nil {
+		t.Fatalf("resolveScriptFlagSchedule: unexpected error: %v", err)
+	}
+	chain.scriptFlagSchedule = schedule
+	der := txscript.ScriptVerifyDERSignatures
+	for height, want := range map[int32]txscript.ScriptFlags{
+		0:  der,
+		9:  der,
+		10: der | txscript.ScriptBip16,
+		19: der | txscript.ScriptBip16,
+		20: der | txscript.ScriptBip16 | txscript.ScriptVerifyStrictEncoding |
+			txscript.ScriptVerifyLowS,
+		100: der | txscript.ScriptBip16 | txscript.ScriptVerifyStrictEncoding |
+			txscript.ScriptVerifyLowS,
+	} {
+		if got := chain.scheduledScriptFlags(height); got != want {
+			t.Errorf("scheduledScriptFlags(%d): got %#x, want %#x",
+				height, got, want)
+		}
+	}
+
+	params.ScriptFlagSchedule = []chaincfg.ScriptFlagActivation{
+		{Height: 0, Flags: []string{"P2SH", "UNKNOWN"}},
+	}
+	if _, err := resolveScriptFlagSchedule(&params); err == nil {
+		t.Errorf("resolveScriptFlagSchedule: unexpected success for an " +
+			"unknown flag")
+	}
+
+	// Schedules without script flags for the genesis block are rejected,
+	// since its scripts would be validated without any flag.
+	for _, schedule := range [][]chaincfg.ScriptFlagActivation{
+		nil,
+		{{Height: 10, Flags: []string{"P2SH"}}},
+	} {
+		params.ScriptFlagSchedule = schedule
+		if _, err := resolveScriptFlagSchedule(&params); err == nil {
+			t.Errorf("resolveScriptFlagSchedule: unexpected success "+
+				"for the schedule %v", schedule)
+		}
+	}
+}
@@ -17,10 +17,12 @@ import (
 
 // txValidateItem holds a transaction along with which input to validate.
 type txValidateItem struct {
+	txIndex   int
 	txInIndex int
 	txIn      *wire.TxIn
 	tx        *czzutil.Tx
 	sigHashes *txscript.TxSigHashes
+	sigChecks int
 }
 
 // txValidator provides a type which asynchronously validates transaction
@@ -103,7 +105,10 @@ out:
 				break out
 			}
 
-			// Validation succeeded.
+			// Validation succeeded.  Record the number of
+			// signature checks executed so the caller can apply
+			// the sigchecks limits once all inputs are validated.
+			txVI.sigChecks = vm.SigChecks()
 			v.sendResult(nil)
 
 		case <-v.quitChan:
@@ -191,6 +196,18 @@ func ValidateTransactionScripts(tx *czzutil.Tx, utxoView *UtxoViewpoint,
 	flags txscript.ScriptFlags, sigCache *txscript.SigCache,
 	hashCache *txscript.HashCache) error {
 
+	_, err := ValidateTransactionScriptsSigChecks(tx, utxoView, flags,
+		sigCache, hashCache)
+	return err
+}
+
+// ValidateTransactionScriptsSigChecks validates the scripts for the passed
+// transaction the same way as ValidateTransactionScripts and returns the
+// number of signature checks they executed.
+func ValidateTransactionScriptsSigChecks(tx *czzutil.Tx, utxoView *UtxoViewpoint,
+	flags txscript.ScriptFlags, sigCache *txscript.SigCache,
+	hashCache *txscript.HashCache) (int, error) {
+
 	// If the HashCache is present, and it doesn't yet contain the
 	// partial sighashes for this transaction, then we add the
 	// sighashes for the transaction. This allows us to take
@@ -231,30 +248,67 @@ func ValidateTransactionScripts(tx *czzutil.Tx, utxoView *UtxoViewpoint,
 
 	// Validate all of the inputs.
 	validator := newTxValidator(utxoView, flags, sigCache, hashCache)
-	return validator.Validate(txValItems)
+	if err := validator.Validate(txValItems); err != nil {
+		return 0, err
+	}
+
+	// Enforce the per-transaction signature check limit when signature
+	// check accounting is active.
+	sigChecks := 0
+	for _, txVI := range txValItems {
+		sigChecks += txVI.sigChecks
+	}
+	if flags.HasFlag(txscript.ScriptVerifySigChecks) &&
+		sigChecks > MaxTransactionSigChecks {
+
+		str := fmt.Sprintf("transaction %s has too many sigchecks - "+
+			"got %d, max %d", tx.Hash(), sigChecks,
+			MaxTransactionSigChecks)
+		return 0, ruleError(ErrTxTooManySigChecks, str)
+	}
+	return sigChecks, nil
 }
 
 // checkBlockScripts executes and validates the scripts for all transactions in
-// the passed block using multiple goroutines.
+// the passed block using multiple goroutines.  The transactions the script
+// cache knows to pass validation with the passed flags are skipped, and the
+// others are added to it once the whole block passed.
 func checkBlockScripts(block *czzutil.Block, utxoView *UtxoViewpoint,
 	scriptFlags txscript.ScriptFlags, sigCache *txscript.SigCache,
-	hashCache *txscript.HashCache) error {
+	hashCache *txscript.HashCache, scriptCache *scriptCache) error {
 
 	// Collect all of the transaction inputs and required information for
 	// validation for all transactions in the block into a single slice.
+	// The signature checks of the cached transactions are known already.
+	transactions := block.Transactions()
+	txSigChecks := make([]int, len(transactions))
+	cached := make([]bool, len(transactions))
 	numInputs := 0
-	for _, tx := range block.Transactions() {
+	for _, tx := range transactions {
 		numInputs += len(tx.MsgTx().TxIn)
 	}
 	txValItems := make([]*txValidateItem, 0, numInputs)
-	for i, tx := range block.Transactions() {
+	for i, tx := range transactions {
+		// Skip coinbases.
+		if i == 0 {
+			continue
+		}
+
+		hash := tx.Hash()
+		if scriptCache != nil {
+			sigChecks, ok := scriptCache.Lookup(hash, scriptFlags)
+			if ok {
+				txSigChecks[i] = sigChecks
+				cached[i] = true
+				continue
+			}
+		}
 
 		// If the HashCache is present, and it doesn't yet contain the
 		// partial sighashes for this transaction, then we add the
 		// sighashes for the transaction. This allows us to take
 		// advantage of the potential speed savings due to the new
 		// digest algorithm (BIP0143).
-		hash := tx.Hash()
 		if scriptFlags.HasFlag(txscript.ScriptVerifyBip143SigHash) && hashCache != nil &&
 			!hashCache.ContainsHashes(hash) {
 
@@ -270,11 +324,12 @@ func checkBlockScripts(block *czzutil.Block, utxoView *UtxoViewpoint,
 
 		for txInIdx, txIn := range tx.MsgTx().TxIn {
 			// Skip coinbases.
-			if txIn.PreviousOutPoint.Index == math.MaxUint32 || i == 0 {
+			if txIn.PreviousOutPoint.Index == math.MaxUint32 {
 				continue
 			}
 
 			txVI := &txValidateItem{
+				txIndex:   i,
 				txInIndex: txInIdx,
 				txIn:      txIn,
 				tx:        tx,
@@ -292,16 +347,66 @@ func checkBlockScripts(block *czzutil.Block, utxoView *UtxoViewpoint,
 	}
 	elapsed := time.Since(start)
 
-	log.Tracef("block %v took %v to verify", block.Hash(), elapsed)
+	log.Tracef("block %v took %v to verify %d inputs", block.Hash(),
+		elapsed, len(txValItems))
+
+	// Enforce the per-transaction and per-block signature check limits
+	// when signature check accounting is active.
+	for _, txVI := range txValItems {
+		txSigChecks[txVI.txIndex] += txVI.sigChecks
+	}
+	if scriptFlags.HasFlag(txscript.ScriptVerifySigChecks) {
+		err := checkBlockSigChecks(block, txSigChecks)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Remember the transactions which passed validation, so validating the
+	// block again, such as once a proposal is mined or when the block is
+	// reorganized back in, skips them.
+	if scriptCache != nil {
+		for i, tx := range transactions {
+			if i > 0 && !cached[i] {
+				scriptCache.Add(tx.Hash(), scriptFlags, txSigChecks[i])
+			}
+		}
+	}
 
 	// If the HashCache is present, once we have validated the block, we no
 	// longer need the cached hashes for these transactions, so we purge
 	// them from the cache.
 	if hashCache != nil {
-		for _, tx := range block.Transactions() {
+		for _, tx := range transactions {
 			hashCache.PurgeSigHashes(tx.Hash())
 		}
 	}
 
 	return nil
 }
+
+// checkBlockSigChecks ensures the signature checks executed by the scripts of
+// the transactions of the passed block, indexed like the transactions, are
+// within the per-transaction and per-block limits.
+func checkBlockSigChecks(block *czzutil.Block, txSigChecks []int) error {
+	maxSigChecks := MaxBlockSigChecks(uint32(block.MsgBlock().SerializeSize()))
+
+	totalSigChecks := 0
+	for i, sigChecks := range txSigChecks {
+		if sigChecks > MaxTransactionSigChecks {
+			str := fmt.Sprintf("transaction %s has too many "+
+				"sigchecks - got %d, max %d",
+				block.Transactions()[i].Hash(), sigChecks,
+				MaxTransactionSigChecks)
+			return ruleError(ErrTxTooManySigChecks, str)
+		}
+
+		totalSigChecks += sigChecks
+		if totalSigChecks > maxSigChecks {
+			str := fmt.Sprintf("block contains too many sigchecks "+
+				"- got %d, max %d", totalSigChecks, maxSigChecks)
+			return ruleError(ErrTooManySigChecks, str)
+		}
+	}
+	return nil
+}
@@ -10,6 +10,8 @@ import (
 	"testing"
 
 	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
 )
 
 // TestCheckBlockScripts ensures that validating the all of the scripts in a
@@ -41,9 +43,72 @@ func TestCheckBlockScripts(t *testing.T) {
 	}
 
 	scriptFlags := txscript.ScriptBip16
-	err = checkBlockScripts(blocks[0], view, scriptFlags, nil, nil)
+	err = checkBlockScripts(blocks[0], view, scriptFlags, nil, nil, nil)
 	if err != nil {
 		t.Errorf("Transaction script validation failed: %v\n", err)
 		return
 	}
 }
+
+// TestCheckBlockScriptsCache ensures the transactions the script cache knows to
+// pass validation with the flags of a block are not validated again, and the
+// others are added to the cache once the block passed.
+func TestCheckBlockScriptsCache(t *testing.T) {
+	// The spent output is anyone can spend, which is valid without flags.
+	fundingTx := czzutil.NewTx(&wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Index: 1},
+			SignatureScript:  []byte{txscript.OP_TRUE},
+		}},
+		TxOut: []*wire.TxOut{{Value: 10, PkScript: []byte{txscript.OP_TRUE}}},
+	})
+	spendTx := czzutil.NewTx(&wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Hash: *fundingTx.Hash()},
+		}},
+		TxOut: []*wire.TxOut{{Value: 9, PkScript: []byte{txscript.OP_TRUE}}},
+	})
+	coinbase := &wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+			SignatureScript:  []byte{txscript.OP_0},
+		}},
+		TxOut: []*wire.TxOut{{Value: 10}},
+	}
+	block := czzutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase, spendTx.MsgTx()},
+	})
+
+	view := NewUtxoViewpoint()
+	view.AddTxOut(fundingTx, 0, 1)
+	cache := newScriptCache(10)
+	err := checkBlockScripts(block, view, 0, nil, nil, cache)
+	if err != nil {
+		t.Fatalf("checkBlockScripts: unexpected error: %v", err)
+	}
+	if _, ok := cache.Lookup(spendTx.Hash(), 0); !ok {
+		t.Fatalf("checkBlockScripts: validated transaction not cached")
+	}
+	if _, ok := cache.Lookup(block.Transactions()[0].Hash(), 0); ok {
+		t.Fatalf("checkBlockScripts: coinbase cached")
+	}
+
+	// The spent output is only needed to validate the scripts, which the
+	// cache spares.
+	err = checkBlockScripts(block, NewUtxoViewpoint(), 0, nil, nil, cache)
+	if err != nil {
+		t.Fatalf("checkBlockScripts: unexpected error for cached "+
+			"transactions: %v", err)
+	}
+
+	// The cached validations do not apply to other flags.
+	err = checkBlockScripts(block, NewUtxoViewpoint(), txscript.ScriptBip16,
+		nil, nil, cache)
+	if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != ErrMissingTxOut {
+		t.Fatalf("checkBlockScripts: got %v, want %v", err,
+			ErrMissingTxOut)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// newSideChain returns a test chain generating blocks which differ from the
+// ones of the passed test chain, so its blocks form a side chain of it.
+func newSideChain(t *testing.T) *testChain {
+	side := newTestChain(t)
+	side.extraNonce = 1
+	return side
+}
+
+// checkMainChain ensures the tip of the passed test chain is the last of the
+// passed blocks and that the others are not in its main chain.
+func checkMainChain(tc *testChain, name string, main, others []*czzutil.Block) {
+	tc.t.Helper()
+	tip := main[len(main)-1]
+	if best := tc.chain.BestSnapshot(); best.Hash != *tip.Hash() {
+		tc.t.Fatalf("%s: got tip %v (height %d), want %v (height %d)",
+			name, best.Hash, best.Height, tip.Hash(), tip.Height())
+	}
+	for _, block := range main {
+		if !tc.chain.MainChainHasBlock(block.Hash()) {
+			tc.t.Fatalf("%s: block %v is not in the main chain", name,
+				block.Hash())
+		}
+	}
+	for _, block := range others {
+		if tc.chain.MainChainHasBlock(block.Hash()) {
+			tc.t.Fatalf("%s: block %v is in the main chain", name,
+				block.Hash())
+		}
+	}
+}
+
+// TestSideChainAcrossRestart ensures the blocks of a side chain are kept when
+// the chain is loaded again, so the chain reorganizes to the side chain once it
+// has more work without downloading its blocks again.
+func TestSideChainAcrossRestart(t *testing.T) {
+	tc := newTestChain(t)
+	defer func() { tc.teardown() }()
+	side := newSideChain(t)
+	defer side.teardown()
+
+	main := tc.generate(2)
+	fork := side.generate(3)
+	for _, block := range fork[:2] {
+		isMainChain, _, err := tc.chain.ProcessBlock(block, 0)
+		if err != nil {
+			t.Fatalf("ProcessBlock: unexpected error: %v", err)
+		}
+		if isMainChain {
+			t.Fatalf("ProcessBlock: side chain block %v extends the "+
+				"main chain", block.Hash())
+		}
+	}
+	checkMainChain(tc, "side chain", main, fork)
+
+	// The side chain blocks are still known once the chain is loaded
+	// again, and the chain with the same work is kept.
+	tc.reopen()
+	checkMainChain(tc, "after reopening", main, fork)
+	for _, block := range fork[:2] {
+		have, err := tc.chain.HaveBlock(block.Hash())
+		if err != nil {
+			t.Fatalf("HaveBlock: unexpected error: %v", err)
+		}
+		if !have {
+			t.Fatalf("after reopening: side chain block %v is unknown",
+				block.Hash())
+		}
+	}
+
+	// The block extending the stored side chain reorganizes the chain to
+	// it, connecting the stored blocks.
+	isMainChain, _, err := tc.chain.ProcessBlock(fork[2], 0)
+	if err != nil {
+		t.Fatalf("ProcessBlock: unexpected error: %v", err)
+	}
+	if !isMainChain {
+		t.Fatalf("ProcessBlock: block %v did not reorganize the chain",
+			fork[2].Hash())
+	}
+	checkMainChain(tc, "after reorganizing", fork, main)
+	tc.checkUtxo("after reorganizing", wire.OutPoint{
+		Hash: *main[0].Transactions()[0].Hash()}, false)
+	tc.checkUtxo("after reorganizing", wire.OutPoint{
+		Hash: *fork[0].Transactions()[0].Hash()}, true)
+}
+
+// TestStoredSideChainAtStartup ensures a stored side chain with more work than
+// the main chain, as left by a node which stopped before connecting it, becomes
+// the main chain when the chain is loaded, and that only the blocks stored by a
+// previous version are checked again.
+func TestStoredSideChainAtStartup(t *testing.T) {
+	tests := []struct {
+		name       string
+		oldVersion bool
+	}{
+		{name: "current version", oldVersion: false},
+		{name: "previous version", oldVersion: true},
+	}
+
+	for _, test := range tests {
+		tc := newTestChain(t)
+		side := newSideChain(t)
+
+		// The last side chain block does not pass the sanity checks,
+		// which is only found when a previous version stored it.
+		main := tc.generate(2)
+		fork := side.generate(3)
+		insane := side.newBlock()
+		insane.MsgBlock().Header.MerkleRoot = chainhash.Hash{}
+		insane = czzutil.NewBlock(insane.MsgBlock())
+		for _, block := range append(fork, insane) {
+			err := tc.chain.TstStoreBlock(block, test.oldVersion)
+			if err != nil {
+				t.Fatalf("%s: TstStoreBlock: unexpected error: %v",
+					test.name, err)
+			}
+		}
+		checkMainChain(tc, test.name+" before reopening", main, fork)
+
+		tc.reopen()
+		if test.oldVersion {
+			checkMainChain(tc, test.name, fork, append(main, insane))
+		} else {
+			checkMainChain(tc, test.name, append(fork, insane), main)
+		}
+
+		// The chain is not reorganized again when it is loaded with
+		// the best chain as the main chain.
+		tip := tc.chain.BestSnapshot().Hash
+		tc.reopen()
+		if best := tc.chain.BestSnapshot(); best.Hash != tip {
+			t.Fatalf("%s: got tip %v after reopening again, want %v",
+				test.name, best.Hash, tip)
+		}
+
+		side.teardown()
+		tc.teardown()
+	}
+}
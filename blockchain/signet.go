@@ -0,0 +1,282 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/czzec"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// SignetHeader is the prefix of the data pushed by the coinbase output which
+// carries the block signature on networks with a signet challenge.  The output
+// is a zero value null data output of the form:
+//
+//	OP_RETURN <SignetHeader || solution>
+//
+// where the solution is the signature script satisfying the challenge of the
+// network.
+var SignetHeader = [4]byte{0xec, 0xc7, 0xda, 0xa2}
+
+// signetSolution returns the index of the output of the passed coinbase
+// transaction which carries the block signature along with the solution it
+// holds.  The index is -1 when the transaction carries no block signature.
+func signetSolution(coinbase *wire.MsgTx) (int, []byte) {
+	for i := len(coinbase.TxOut) - 1; i >= 0; i-- {
+		script := coinbase.TxOut[i].PkScript
+		if len(script) == 0 || script[0] != txscript.OP_RETURN {
+			continue
+		}
+		pushes, err := txscript.PushedData(script[1:])
+		if err != nil || len(pushes) != 1 ||
+			!bytes.HasPrefix(pushes[0], SignetHeader[:]) {
+
+			continue
+		}
+		return i, pushes[0][len(SignetHeader):]
+	}
+	return -1, nil
+}
+
+// signetCommitmentScript returns the script of the coinbase output carrying
+// the passed block signature solution.
+func signetCommitmentScript(solution []byte) ([]byte, error) {
+	data := make([]byte, 0, len(SignetHeader)+len(solution))
+	data = append(data, SignetHeader[:]...)
+	data = append(data, solution...)
+	return txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+		AddData(data).Script()
+}
+
+// signetSigHash returns the hash the block signature of a block with the
+// passed header and transactions commits to.  It is the hash of the header
+// without the nonce, since the proof of work is searched for after signing,
+// where the merkle root is calculated with the solution removed from the
+// coinbase output at the passed index.
+func signetSigHash(header *wire.BlockHeader, transactions []*czzutil.Tx,
+	index int) (chainhash.Hash, error) {
+
+	script, err := signetCommitmentScript(nil)
+	if err != nil {
+		return chainhash.Hash{}, err
+	}
+	coinbase := transactions[0].MsgTx().Copy()
+	coinbase.TxOut[index].PkScript = script
+
+	txs := make([]*czzutil.Tx, len(transactions))
+	copy(txs, transactions)
+	txs[0] = czzutil.NewTx(coinbase)
+	sigHeader := *header
+	sigHeader.MerkleRoot = CalcMerkleRoot(txs)
+	return sigHeader.BlockHashNoNonce(), nil
+}
+
+// signetSpendingTx returns the virtual transaction whose only input spends the
+// signet challenge with the passed solution as proposed by BIP0325.  The input
+// spends the output of another virtual transaction which commits to the passed
+// signature hash of a block, so the solution is a signature of the block.
+func signetSpendingTx(sigHash *chainhash.Hash, challenge, solution []byte) *wire.MsgTx {
+	toSpend := wire.NewMsgTx(0)
+	toSpend.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: append([]byte{txscript.OP_0,
+			txscript.OP_DATA_32}, sigHash[:]...),
+	})
+	toSpend.AddTxOut(&wire.TxOut{PkScript: challenge})
+	toSpendHash := toSpend.TxHash()
+
+	toSign := wire.NewMsgTx(0)
+	toSign.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&toSpendHash, 0),
+		SignatureScript:  solution,
+	})
+	toSign.AddTxOut(&wire.TxOut{PkScript: []byte{txscript.OP_RETURN}})
+	return toSign
+}
+
+// checkSignetSolution ensures the coinbase transaction of the passed block
+// carries a block signature satisfying the passed signet challenge.
+func checkSignetSolution(block *czzutil.Block, challenge []byte) error {
+	msgBlock := block.MsgBlock()
+	transactions := block.Transactions()
+	index, solution := signetSolution(msgBlock.Transactions[0])
+	if index < 0 {
+		return ruleError(ErrBadSignetSolution, "coinbase transaction "+
+			"does not carry a block signature")
+	}
+
+	sigHash, err := signetSigHash(&msgBlock.Header, transactions, index)
+	if err != nil {
+		return ruleError(ErrBadSignetSolution, err.Error())
+	}
+	tx := signetSpendingTx(&sigHash, challenge, solution)
+	vm, err := txscript.NewEngine(challenge, tx, 0,
+		txscript.StandardVerifyFlags, nil, nil, 0)
+	if err == nil {
+		err = vm.Execute()
+	}
+	if err != nil {
+		str := fmt.Sprintf("block signature does not satisfy the "+
+			"signet challenge: %v", err)
+		return ruleError(ErrBadSignetSolution, str)
+	}
+	return nil
+}
+
+// BlockSigner signs the blocks of a network with a signet challenge.  It allows
+// the signing key to be held outside of the node, such as by a remote signer,
+// in which case the node only knows the public key.
+type BlockSigner interface {
+	// PubKey returns the public key the blocks are signed with.
+	PubKey() *czzec.PublicKey
+
+	// Sign returns the serialized signature of the passed hash, a Schnorr
+	// signature when schnorr is set and a DER encoded ECDSA signature
+	// otherwise.
+	Sign(hash []byte, schnorr bool) ([]byte, error)
+}
+
+// privKeySigner is a BlockSigner signing with a private key held in memory.
+type privKeySigner struct {
+	key *czzec.PrivateKey
+}
+
+// PubKey returns the public key of the private key.  This is part of the
+// BlockSigner interface implementation.
+func (s privKeySigner) PubKey() *czzec.PublicKey {
+	return s.key.PubKey()
+}
+
+// Sign signs the passed hash with the private key.  This is part of the
+// BlockSigner interface implementation.
+func (s privKeySigner) Sign(hash []byte, schnorr bool) ([]byte, error) {
+	var sig *czzec.Signature
+	var err error
+	if schnorr {
+		sig, err = s.key.SignSchnorr(hash)
+	} else {
+		sig, err = s.key.SignECDSA(hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sig.Serialize(), nil
+}
+
+// NewPrivKeySigner returns a BlockSigner signing with the passed private key.
+func NewPrivKeySigner(key *czzec.PrivateKey) BlockSigner {
+	return privKeySigner{key: key}
+}
+
+// signetSignatureScript returns the signature script of the passed virtual
+// spending transaction which satisfies the passed signet challenge with a
+// signature of the signer.  The signatures are made like txscript.SignTxOutput
+// does, Schnorr signatures for pay-to-pubkey and pay-to-pubkey-hash challenges
+// and an ECDSA signature for multisig challenges.
+func signetSignatureScript(params *chaincfg.Params, tx *wire.MsgTx,
+	challenge []byte, signer BlockSigner) ([]byte, error) {
+
+	const hashType = txscript.SigHashAll | txscript.SigHashForkID
+	class, _, _, err := txscript.ExtractPkScriptAddrs(challenge, params)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := txscript.CalcSignatureHash(challenge,
+		txscript.NewTxSigHashes(tx), hashType, tx, 0, 0, true)
+	if err != nil {
+		return nil, err
+	}
+	sign := func(schnorr bool) ([]byte, error) {
+		sig, err := signer.Sign(hash, schnorr)
+		if err != nil {
+			return nil, err
+		}
+		return append(sig, byte(hashType)), nil
+	}
+
+	builder := txscript.NewScriptBuilder()
+	switch class {
+	case txscript.PubKeyTy, txscript.PubKeyHashTy:
+		sig, err := sign(true)
+		if err != nil {
+			return nil, err
+		}
+		builder.AddData(sig)
+		if class == txscript.PubKeyHashTy {
+			builder.AddData(signer.PubKey().SerializeCompressed())
+		}
+
+	case txscript.MultiSigTy:
+		// The extra OP_FALSE works around the spurious pop of
+		// OP_CHECKMULTISIG.
+		sig, err := sign(false)
+		if err != nil {
+			return nil, err
+		}
+		builder.AddOp(txscript.OP_FALSE).AddData(sig)
+
+	default:
+		return nil, fmt.Errorf("can not sign a signet challenge of "+
+			"class %v", class)
+	}
+	return builder.Script()
+}
+
+// SignBlock adds the block signature made by the passed signer to the coinbase
+// transaction of the passed block and updates its merkle root, replacing any
+// block signature the coinbase already carries.  The signet challenge of the
+// passed network must be a pay-to-pubkey, pay-to-pubkey-hash or 1-of-n
+// multisig script of the compressed public key of the signer, so a block must
+// be signed again whenever its transactions or header other than the nonce
+// change.
+func SignBlock(msgBlock *wire.MsgBlock, params *chaincfg.Params,
+	signer BlockSigner) error {
+
+	challenge := params.SignetChallenge
+	if challenge == nil {
+		return errors.New("the network does not have a signet challenge")
+	}
+
+	// Sign the block with an empty solution in place, so the coinbase
+	// output carrying it exists when the signature hash is calculated.
+	coinbase := msgBlock.Transactions[0]
+	index, _ := signetSolution(coinbase)
+	if index < 0 {
+		index = len(coinbase.TxOut)
+		coinbase.AddTxOut(&wire.TxOut{})
+	}
+	block := czzutil.NewBlock(msgBlock)
+	sigHash, err := signetSigHash(&msgBlock.Header, block.Transactions(),
+		index)
+	if err != nil {
+		return err
+	}
+
+	tx := signetSpendingTx(&sigHash, challenge, nil)
+	solution, err := signetSignatureScript(params, tx, challenge, signer)
+	if err != nil {
+		return err
+	}
+	script, err := signetCommitmentScript(solution)
+	if err != nil {
+		return err
+	}
+	coinbase.TxOut[index].PkScript = script
+
+	block = czzutil.NewBlock(msgBlock)
+	msgBlock.Header.MerkleRoot = CalcMerkleRoot(block.Transactions())
+
+	// Make sure the key satisfies the challenge, since the signature
+	// script does not when the key is not the one of the challenge.
+	return checkSignetSolution(block, challenge)
+}
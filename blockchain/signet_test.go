@@ -0,0 +1,166 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/czzec"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// TestSignBlock ensures blocks signed with the key of a signet challenge
+// satisfy the challenge, and that blocks without a signature, with a signature
+// of another key or changed after signing do not.
+func TestSignBlock(t *testing.T) {
+	key, err := czzec.NewPrivateKey(czzec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	otherKey, err := czzec.NewPrivateKey(czzec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	challenge, err := txscript.NewScriptBuilder().AddOp(txscript.OP_1).
+		AddData(key.PubKey().SerializeCompressed()).
+		AddOp(txscript.OP_1).AddOp(txscript.OP_CHECKMULTISIG).Script()
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+	params := chaincfg.SigNetParams
+	params.SignetChallenge = challenge
+
+	newBlock := func() *wire.MsgBlock {
+		coinbase := wire.NewMsgTx(wire.TxVersion)
+		coinbase.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+				wire.MaxPrevOutIndex),
+			SignatureScript: []byte{txscript.OP_1, txscript.OP_1},
+			Sequence:        wire.MaxTxInSequenceNum,
+		})
+		coinbase.AddTxOut(&wire.TxOut{
+			Value:    5000000000,
+			PkScript: []byte{txscript.OP_TRUE},
+		})
+		block := &wire.MsgBlock{
+			Header: wire.BlockHeader{
+				Version:   1,
+				PrevBlock: *params.GenesisHash,
+				Timestamp: time.Unix(1577836800, 0),
+				Bits:      params.PowLimitBits,
+			},
+			Transactions: []*wire.MsgTx{coinbase},
+		}
+		return block
+	}
+
+	// A block without a signature does not satisfy the challenge.
+	block := newBlock()
+	err = checkSignetSolution(czzutil.NewBlock(block), challenge)
+	if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != ErrBadSignetSolution {
+		t.Fatalf("checkSignetSolution: unexpected error of unsigned "+
+			"block: %v", err)
+	}
+
+	// A signed block satisfies the challenge regardless of its nonce.
+	if err := SignBlock(block, &params, NewPrivKeySigner(key)); err != nil {
+		t.Fatalf("SignBlock: unexpected error: %v", err)
+	}
+	block.Header.Nonce = 12345
+	if err := checkSignetSolution(czzutil.NewBlock(block), challenge); err != nil {
+		t.Fatalf("checkSignetSolution: unexpected error: %v", err)
+	}
+	merkles := BuildMerkleTreeStore(czzutil.NewBlock(block).Transactions())
+	if block.Header.MerkleRoot != *merkles[len(merkles)-1] {
+		t.Fatal("SignBlock: merkle root was not updated")
+	}
+
+	// Signing again replaces the signature instead of adding another.
+	numOutputs := len(block.Transactions[0].TxOut)
+	if err := SignBlock(block, &params, NewPrivKeySigner(key)); err != nil {
+		t.Fatalf("SignBlock: unexpected error: %v", err)
+	}
+	if len(block.Transactions[0].TxOut) != numOutputs {
+		t.Fatalf("SignBlock: got %d coinbase outputs, want %d",
+			len(block.Transactions[0].TxOut), numOutputs)
+	}
+
+	// The signature commits to the header other than the nonce.
+	block.Header.Timestamp = block.Header.Timestamp.Add(time.Second)
+	err = checkSignetSolution(czzutil.NewBlock(block), challenge)
+	if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != ErrBadSignetSolution {
+		t.Fatalf("checkSignetSolution: unexpected error of changed "+
+			"block: %v", err)
+	}
+
+	// Another key does not satisfy the challenge.
+	if err := SignBlock(newBlock(), &params, NewPrivKeySigner(otherKey)); err == nil {
+		t.Fatal("SignBlock: unexpected success signing with another key")
+	}
+
+	// Blocks can only be signed on a signed network.
+	if err := SignBlock(newBlock(), &chaincfg.RegressionNetParams, NewPrivKeySigner(key)); err == nil {
+		t.Fatal("SignBlock: unexpected success on an unsigned network")
+	}
+}
+
+// TestSignBlockChallenges ensures blocks can be signed for pay-to-pubkey and
+// pay-to-pubkey-hash signet challenges too.
+func TestSignBlockChallenges(t *testing.T) {
+	key, err := czzec.NewPrivateKey(czzec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	pubKey := key.PubKey().SerializeCompressed()
+	p2pk, err := txscript.NewScriptBuilder().AddData(pubKey).
+		AddOp(txscript.OP_CHECKSIG).Script()
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+	p2pkh, err := txscript.NewScriptBuilder().AddOp(txscript.OP_DUP).
+		AddOp(txscript.OP_HASH160).AddData(czzutil.Hash160(pubKey)).
+		AddOp(txscript.OP_EQUALVERIFY).AddOp(txscript.OP_CHECKSIG).Script()
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+
+	for _, challenge := range [][]byte{p2pk, p2pkh} {
+		params := chaincfg.SigNetParams
+		params.SignetChallenge = challenge
+		coinbase := wire.NewMsgTx(wire.TxVersion)
+		coinbase.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+				wire.MaxPrevOutIndex),
+			SignatureScript: []byte{txscript.OP_1, txscript.OP_1},
+			Sequence:        wire.MaxTxInSequenceNum,
+		})
+		coinbase.AddTxOut(&wire.TxOut{
+			Value:    5000000000,
+			PkScript: []byte{txscript.OP_TRUE},
+		})
+		block := &wire.MsgBlock{
+			Header: wire.BlockHeader{
+				Version:   1,
+				PrevBlock: *params.GenesisHash,
+				Timestamp: time.Unix(1577836800, 0),
+				Bits:      params.PowLimitBits,
+			},
+			Transactions: []*wire.MsgTx{coinbase},
+		}
+
+		if err := SignBlock(block, &params, NewPrivKeySigner(key)); err != nil {
+			t.Fatalf("SignBlock: unexpected error: %v", err)
+		}
+		err := checkSignetSolution(czzutil.NewBlock(block), challenge)
+		if err != nil {
+			t.Fatalf("checkSignetSolution: unexpected error: %v", err)
+		}
+	}
+}
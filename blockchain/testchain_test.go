@@ -0,0 +1,217 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/database"
+	_ "github.com/bourbaki-czz/classzz/database/ffldb"
+	"github.com/bourbaki-czz/classzz/mining"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// testTxSource is a mining transaction source offering the transactions set
+// to the generated blocks.
+type testTxSource struct {
+	descs []*mining.TxDesc
+}
+
+func (s *testTxSource) LastUpdated() time.Time        { return time.Time{} }
+func (s *testTxSource) Sequence() uint64              { return 0 }
+func (s *testTxSource) MiningDescs() []*mining.TxDesc { return s.descs }
+func (s *testTxSource) HaveTransaction(hash *chainhash.Hash) bool {
+	for _, desc := range s.descs {
+		if desc.Tx.Hash().IsEqual(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// testChain is a chain on the regression test network stored in a database
+// which can be closed and opened again.
+type testChain struct {
+	t      *testing.T
+	params *chaincfg.Params
+	dir    string
+	db     database.DB
+	chain  *blockchain.BlockChain
+	source testTxSource
+
+	// extraNonce is the extra nonce of the coinbases of the generated
+	// blocks, so they differ from the blocks and coinbases another test
+	// chain generates.
+	extraNonce uint64
+}
+
+// newTestChain returns a new chain on the regression test network stored in a
+// temporary database.  The returned chain must be torn down.
+func newTestChain(t *testing.T) *testChain {
+	dir, err := ioutil.TempDir("", "blockchain")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	// The genesis hash of the network does not match its genesis block,
+	// which keeps the chain from being loaded again.
+	// The blocks are mined faster than one a second, so their times run
+	// ahead of the local clock by more than the future block time window of
+	// the network.
+	params := chaincfg.RegressionNetParams
+	genesisHash := params.GenesisBlock.BlockHash()
+	params.GenesisHash = &genesisHash
+	params.AllowedFutureBlockTime = params.MaxTimeOffset
+
+	db, err := database.Create("ffldb", filepath.Join(dir, "db"), params.Net)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("database.Create: unexpected error: %v", err)
+	}
+	tc := &testChain{t: t, params: &params, dir: dir, db: db}
+	tc.chain = tc.newChain()
+	return tc
+}
+
+// newChain returns a chain loaded from the database of the test chain.
+func (tc *testChain) newChain() *blockchain.BlockChain {
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:                 tc.db,
+		ChainParams:        tc.params,
+		TimeSource:         blockchain.NewMedianTime(),
+		ExcessiveBlockSize: 1000000,
+	})
+	if err != nil {
+		tc.teardown()
+		tc.t.Fatalf("blockchain.New: unexpected error: %v", err)
+	}
+	return chain
+}
+
+// reopen flushes the chain state, closes the database and loads the chain
+// from it again.
+func (tc *testChain) reopen() {
+	if err := tc.chain.FlushCachedState(blockchain.FlushRequired); err != nil {
+		tc.t.Fatalf("FlushCachedState: unexpected error: %v", err)
+	}
+	if err := tc.db.Close(); err != nil {
+		tc.t.Fatalf("Close: unexpected error: %v", err)
+	}
+	db, err := database.Open("ffldb", filepath.Join(tc.dir, "db"),
+		tc.params.Net)
+	if err != nil {
+		os.RemoveAll(tc.dir)
+		tc.t.Fatalf("database.Open: unexpected error: %v", err)
+	}
+	tc.db = db
+	tc.chain = tc.newChain()
+}
+
+// teardown closes the database and removes it.
+func (tc *testChain) teardown() {
+	tc.db.Close()
+	os.RemoveAll(tc.dir)
+}
+
+// newBlock returns a block extending the main chain of the test chain which
+// includes the transactions of the source.
+func (tc *testChain) newBlock() *czzutil.Block {
+	policy := mining.Policy{BlockMaxSize: 1000000}
+	g := mining.NewBlkTmplGenerator(&policy, tc.params, &tc.source, tc.chain,
+		blockchain.NewMedianTime(), txscript.NewSigCache(100),
+		txscript.NewHashCache(100))
+	template, err := g.NewBlockTemplate(nil)
+	if err != nil {
+		tc.t.Fatalf("NewBlockTemplate: unexpected error: %v", err)
+	}
+	tc.source.descs = nil
+
+	err = g.UpdateExtraNonce(template.Block, template.Height, tc.extraNonce)
+	if err != nil {
+		tc.t.Fatalf("UpdateExtraNonce: unexpected error: %v", err)
+	}
+	block := czzutil.NewBlock(template.Block)
+	block.SetHeight(template.Height)
+	return block
+}
+
+// generate extends the main chain with the passed number of blocks, the first
+// of which includes the transactions of the source, and returns them.
+func (tc *testChain) generate(n int) []*czzutil.Block {
+	blocks := make([]*czzutil.Block, 0, n)
+	for i := 0; i < n; i++ {
+		block := tc.newBlock()
+		_, _, err := tc.chain.ProcessBlock(block, blockchain.BFNoPoWCheck)
+		if err != nil {
+			tc.t.Fatalf("ProcessBlock: unexpected error: %v", err)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// spend offers a transaction spending the anyone can spend output of the
+// coinbase of the passed block to the next generated block and returns it.
+func (tc *testChain) spend(block *czzutil.Block) *czzutil.Tx {
+	opTrue := []byte{txscript.OP_TRUE}
+	coinbase := block.Transactions()[0]
+	for i, txOut := range coinbase.MsgTx().TxOut {
+		if !bytes.Equal(txOut.PkScript, opTrue) {
+			continue
+		}
+		const fee = 1000
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Hash: *coinbase.Hash(),
+				Index: uint32(i)},
+			Sequence: wire.MaxTxInSequenceNum,
+		})
+		tx.AddTxOut(&wire.TxOut{
+			PkScript: opTrue,
+			Value:    txOut.Value - fee,
+		})
+
+		// The padding lets the transaction reach the minimum size.
+		padding, err := txscript.NullDataScript(make([]byte, 40))
+		if err != nil {
+			tc.t.Fatalf("NullDataScript: unexpected error: %v", err)
+		}
+		tx.AddTxOut(&wire.TxOut{PkScript: padding})
+		spend := czzutil.NewTx(tx)
+		tc.source.descs = append(tc.source.descs, &mining.TxDesc{
+			Tx:       spend,
+			Added:    time.Now(),
+			Height:   block.Height(),
+			Fee:      fee,
+			FeePerKB: fee * 1000 / int64(tx.SerializeSize()),
+		})
+		return spend
+	}
+	tc.t.Fatalf("block %v has no anyone can spend output", block.Hash())
+	return nil
+}
+
+// checkUtxo ensures whether the passed output is unspent in the utxo set of
+// the test chain.
+func (tc *testChain) checkUtxo(name string, outpoint wire.OutPoint, unspent bool) {
+	tc.t.Helper()
+	entry, err := tc.chain.FetchUtxoEntry(outpoint)
+	if err != nil {
+		tc.t.Fatalf("%s: FetchUtxoEntry: unexpected error: %v", name, err)
+	}
+	if got := entry != nil && !entry.IsSpent(); got != unspent {
+		tc.t.Errorf("%s: output %v unspent: got %v, want %v", name,
+			outpoint, got, unspent)
+	}
+}
@@ -0,0 +1,335 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// TxMeta is the metadata of a transaction of a block in the main chain, as
+// displayed by block explorers.
+type TxMeta struct {
+	// Hash is the hash of the transaction.
+	Hash chainhash.Hash
+
+	// Size is the serialized size of the transaction in bytes.
+	Size int
+
+	// Fee is the fee paid by the transaction, which is zero for the
+	// coinbase.
+	Fee int64
+
+	// Depends are the indexes in the block of the transactions whose
+	// outputs the transaction spends, in ascending order.
+	Depends []int
+
+	// Depth is zero for the transactions which spend no outputs created in
+	// the block, and otherwise one more than the greatest depth of the
+	// transactions they depend on.  Sorting the transactions by depth gives
+	// an order in which they can be applied, since the block itself may be
+	// in canonical rather than topological order.
+	Depth int
+}
+
+// FeeRate returns the fee rate of the transaction in satoshi per kilobyte.
+func (m *TxMeta) FeeRate() int64 {
+	if m.Size == 0 {
+		return 0
+	}
+	return m.Fee * 1000 / int64(m.Size)
+}
+
+// computeBlockTxMeta returns the metadata of the transactions of the passed
+// block from the passed outputs it spends, which must be in the order of the
+// spend journal.
+func computeBlockTxMeta(block *czzutil.Block, stxos []SpentTxOut) ([]TxMeta, error) {
+	if len(stxos) != countSpentOutputs(block) {
+		return nil, AssertError(fmt.Sprintf("computeBlockTxMeta called "+
+			"with %d spent outputs for a block spending %d", len(stxos),
+			countSpentOutputs(block)))
+	}
+
+	transactions := block.Transactions()
+	txIndexes := make(map[chainhash.Hash]int, len(transactions))
+	for i, tx := range transactions {
+		txIndexes[*tx.Hash()] = i
+	}
+
+	metas := make([]TxMeta, len(transactions))
+	stxoIdx := 0
+	for i, tx := range transactions {
+		msgTx := tx.MsgTx()
+		meta := &metas[i]
+		meta.Hash = *tx.Hash()
+		meta.Size = msgTx.SerializeSize()
+		if i == 0 {
+			continue
+		}
+
+		var totalIn, totalOut int64
+		for _, txIn := range msgTx.TxIn {
+			totalIn += stxos[stxoIdx].Amount
+			stxoIdx++
+
+			depIdx, ok := txIndexes[txIn.PreviousOutPoint.Hash]
+			if !ok {
+				continue
+			}
+			meta.Depends = insertDepend(meta.Depends, depIdx)
+		}
+		for _, txOut := range msgTx.TxOut {
+			totalOut += txOut.Value
+		}
+		meta.Fee = totalIn - totalOut
+	}
+
+	// The depths are computed as longest paths, visiting the dependencies
+	// of each transaction first since they can come later in the block.
+	// There are no cycles in a valid block.
+	var depth func(i int, visiting map[int]struct{}) (int, error)
+	computed := make([]bool, len(metas))
+	depth = func(i int, visiting map[int]struct{}) (int, error) {
+		if computed[i] {
+			return metas[i].Depth, nil
+		}
+		if _, ok := visiting[i]; ok {
+			return 0, AssertError(fmt.Sprintf("transaction %v of block "+
+				"%v depends on itself", metas[i].Hash, block.Hash()))
+		}
+		visiting[i] = struct{}{}
+		for _, depIdx := range metas[i].Depends {
+			d, err := depth(depIdx, visiting)
+			if err != nil {
+				return 0, err
+			}
+			if d+1 > metas[i].Depth {
+				metas[i].Depth = d + 1
+			}
+		}
+		delete(visiting, i)
+		computed[i] = true
+		return metas[i].Depth, nil
+	}
+	for i := range metas {
+		if _, err := depth(i, make(map[int]struct{})); err != nil {
+			return nil, err
+		}
+	}
+
+	return metas, nil
+}
+
+// insertDepend inserts the passed index into the passed ascending indexes
+// unless it is already there.
+func insertDepend(depends []int, idx int) []int {
+	i := 0
+	for i < len(depends) && depends[i] < idx {
+		i++
+	}
+	if i < len(depends) && depends[i] == idx {
+		return depends
+	}
+	depends = append(depends, 0)
+	copy(depends[i+1:], depends[i:])
+	depends[i] = idx
+	return depends
+}
+
+// serializeBlockTxMeta returns the serialization of the passed transaction
+// metadata of a block.  The serialized format is the VLQ encoded number of
+// transactions followed by, for each transaction:
+//
+//	<hash><size><fee><depth><num depends><depends>
+//
+// where the hash is 32 bytes and the other fields are VLQ encoded.
+func serializeBlockTxMeta(metas []TxMeta) []byte {
+	size := serializeSizeVLQ(uint64(len(metas)))
+	for i := range metas {
+		meta := &metas[i]
+		size += chainhash.HashSize +
+			serializeSizeVLQ(uint64(meta.Size)) +
+			serializeSizeVLQ(uint64(meta.Fee)) +
+			serializeSizeVLQ(uint64(meta.Depth)) +
+			serializeSizeVLQ(uint64(len(meta.Depends)))
+		for _, depIdx := range meta.Depends {
+			size += serializeSizeVLQ(uint64(depIdx))
+		}
+	}
+
+	serialized := make([]byte, size)
+	offset := putVLQ(serialized, uint64(len(metas)))
+	for i := range metas {
+		meta := &metas[i]
+		offset += copy(serialized[offset:], meta.Hash[:])
+		offset += putVLQ(serialized[offset:], uint64(meta.Size))
+		offset += putVLQ(serialized[offset:], uint64(meta.Fee))
+		offset += putVLQ(serialized[offset:], uint64(meta.Depth))
+		offset += putVLQ(serialized[offset:], uint64(len(meta.Depends)))
+		for _, depIdx := range meta.Depends {
+			offset += putVLQ(serialized[offset:], uint64(depIdx))
+		}
+	}
+	return serialized
+}
+
+// deserializeBlockTxMeta decodes the passed serialized transaction metadata of
+// a block.
+func deserializeBlockTxMeta(serialized []byte) ([]TxMeta, error) {
+	offset := 0
+	readVLQ := func(field string) (uint64, error) {
+		if offset >= len(serialized) {
+			return 0, errDeserialize(fmt.Sprintf("unexpected end of "+
+				"data reading %s", field))
+		}
+		n, bytesRead := deserializeVLQ(serialized[offset:])
+		offset += bytesRead
+		return n, nil
+	}
+
+	numTxns, err := readVLQ("number of transactions")
+	if err != nil {
+		return nil, err
+	}
+	// Each transaction takes at least the hash and four bytes.
+	if numTxns > uint64(len(serialized)/(chainhash.HashSize+4)) {
+		return nil, errDeserialize(fmt.Sprintf("%d transactions do not "+
+			"fit in %d bytes", numTxns, len(serialized)))
+	}
+	metas := make([]TxMeta, numTxns)
+	for i := range metas {
+		meta := &metas[i]
+		if offset+chainhash.HashSize > len(serialized) {
+			return nil, errDeserialize("unexpected end of data " +
+				"reading transaction hash")
+		}
+		copy(meta.Hash[:], serialized[offset:])
+		offset += chainhash.HashSize
+
+		size, err := readVLQ("transaction size")
+		if err != nil {
+			return nil, err
+		}
+		fee, err := readVLQ("transaction fee")
+		if err != nil {
+			return nil, err
+		}
+		depth, err := readVLQ("transaction depth")
+		if err != nil {
+			return nil, err
+		}
+		numDepends, err := readVLQ("number of dependencies")
+		if err != nil {
+			return nil, err
+		}
+		if numDepends > numTxns {
+			return nil, errDeserialize(fmt.Sprintf("%d dependencies "+
+				"in a block of %d transactions", numDepends,
+				numTxns))
+		}
+		meta.Size = int(size)
+		meta.Fee = int64(fee)
+		meta.Depth = int(depth)
+		if numDepends > 0 {
+			meta.Depends = make([]int, numDepends)
+		}
+		for j := range meta.Depends {
+			depIdx, err := readVLQ("dependency")
+			if err != nil {
+				return nil, err
+			}
+			meta.Depends[j] = int(depIdx)
+		}
+	}
+	return metas, nil
+}
+
+// dbPutBlockTxMeta uses an existing database transaction to store the passed
+// transaction metadata of the block with the passed hash.
+func dbPutBlockTxMeta(dbTx database.Tx, blockHash *chainhash.Hash, metas []TxMeta) error {
+	bucket, err := dbTx.Metadata().CreateBucketIfNotExists(txMetaBucketName)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(blockHash[:], serializeBlockTxMeta(metas))
+}
+
+// dbFetchBlockTxMeta uses an existing database transaction to fetch the stored
+// transaction metadata of the block with the passed hash.  It returns nil when
+// none was stored.
+func dbFetchBlockTxMeta(dbTx database.Tx, blockHash *chainhash.Hash) ([]TxMeta, error) {
+	bucket := dbTx.Metadata().Bucket(txMetaBucketName)
+	if bucket == nil {
+		return nil, nil
+	}
+	serialized := bucket.Get(blockHash[:])
+	if serialized == nil {
+		return nil, nil
+	}
+	metas, err := deserializeBlockTxMeta(serialized)
+	if err != nil {
+		if isDeserializeErr(err) {
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt transaction "+
+					"metadata for %v: %v", blockHash, err),
+			}
+		}
+		return nil, err
+	}
+	return metas, nil
+}
+
+// dbRemoveBlockTxMeta uses an existing database transaction to remove the
+// stored transaction metadata of the block with the passed hash, if any.
+func dbRemoveBlockTxMeta(dbTx database.Tx, blockHash *chainhash.Hash) error {
+	bucket := dbTx.Metadata().Bucket(txMetaBucketName)
+	if bucket == nil {
+		return nil
+	}
+	return bucket.Delete(blockHash[:])
+}
+
+// BlockTxMeta returns the metadata of the transactions of the main chain block
+// with the passed hash, in the order of the block.  It is read from the
+// metadata stored when the block was connected with the PersistTxMeta option,
+// which outlives the pruning of the block, and otherwise computed from the
+// block and its spend journal.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) BlockTxMeta(hash *chainhash.Hash) ([]TxMeta, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	node := b.index.LookupNode(hash)
+	if node == nil || !b.bestChain.Contains(node) {
+		str := fmt.Sprintf("block %s is not in the main chain", hash)
+		return nil, errNotInMainChain(str)
+	}
+
+	var metas []TxMeta
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		metas, err = dbFetchBlockTxMeta(dbTx, hash)
+		if err != nil || metas != nil {
+			return err
+		}
+
+		block, err := dbFetchBlockByNode(dbTx, node)
+		if err != nil {
+			return err
+		}
+		stxos, err := dbFetchSpendJournalEntry(dbTx, block)
+		if err != nil {
+			return err
+		}
+		metas, err = computeBlockTxMeta(block, stxos)
+		return err
+	})
+	return metas, err
+}
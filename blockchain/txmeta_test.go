@@ -0,0 +1,103 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// TestBlockTxMeta ensures the transaction metadata of a block is computed from
+// the outputs it spends, including the dependencies on later transactions, and
+// that it survives a serialization round trip.
+func TestBlockTxMeta(t *testing.T) {
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+	})
+	coinbase.AddTxOut(wire.NewTxOut(5000, opTrueScript))
+
+	// The parent spends an output from a previous block and comes after its
+	// child in the block, as allowed by the canonical transaction order.
+	parent := wire.NewMsgTx(1)
+	parent.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{0x01}},
+	})
+	parent.AddTxOut(wire.NewTxOut(900, opTrueScript))
+	parent.AddTxOut(wire.NewTxOut(50, opTrueScript))
+	parentHash := parent.TxHash()
+
+	child := wire.NewMsgTx(1)
+	child.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: parentHash, Index: 0},
+	})
+	child.AddTxOut(wire.NewTxOut(800, opTrueScript))
+	childHash := child.TxHash()
+
+	grandchild := wire.NewMsgTx(1)
+	grandchild.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: childHash, Index: 0},
+	})
+	grandchild.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: parentHash, Index: 1},
+	})
+	grandchild.AddTxOut(wire.NewTxOut(840, opTrueScript))
+
+	block := czzutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase, child, parent, grandchild},
+	})
+	stxos := []SpentTxOut{
+		{Amount: 900},               // child
+		{Amount: 1000},              // parent
+		{Amount: 800}, {Amount: 50}, // grandchild
+	}
+
+	metas, err := computeBlockTxMeta(block, stxos)
+	if err != nil {
+		t.Fatalf("computeBlockTxMeta: unexpected error: %v", err)
+	}
+	want := []TxMeta{
+		{Hash: coinbase.TxHash(), Size: coinbase.SerializeSize()},
+		{Hash: childHash, Size: child.SerializeSize(), Fee: 100,
+			Depends: []int{2}, Depth: 1},
+		{Hash: parentHash, Size: parent.SerializeSize(), Fee: 50},
+		{Hash: grandchild.TxHash(), Size: grandchild.SerializeSize(),
+			Fee: 10, Depends: []int{1, 2}, Depth: 2},
+	}
+	if !reflect.DeepEqual(metas, want) {
+		t.Fatalf("computeBlockTxMeta: got %+v, want %+v", metas, want)
+	}
+	if got, want := metas[1].FeeRate(), int64(100*1000/child.SerializeSize()); got != want {
+		t.Fatalf("FeeRate: got %d, want %d", got, want)
+	}
+
+	decoded, err := deserializeBlockTxMeta(serializeBlockTxMeta(metas))
+	if err != nil {
+		t.Fatalf("deserializeBlockTxMeta: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, metas) {
+		t.Fatalf("deserializeBlockTxMeta: got %+v, want %+v", decoded,
+			metas)
+	}
+
+	// Truncated data is reported as a deserialization error.
+	serialized := serializeBlockTxMeta(metas)
+	_, err = deserializeBlockTxMeta(serialized[:len(serialized)-1])
+	if !isDeserializeErr(err) {
+		t.Fatalf("deserializeBlockTxMeta: unexpected error for truncated "+
+			"data: %v", err)
+	}
+
+	// The spent outputs must match the inputs of the block.
+	if _, err := computeBlockTxMeta(block, stxos[1:]); err == nil {
+		t.Fatal("computeBlockTxMeta: unexpected success with missing " +
+			"spent outputs")
+	}
+}
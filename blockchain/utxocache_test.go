@@ -130,8 +130,8 @@ func makeSpendableOutForTx(tx *wire.MsgTx, txOutIndex uint32) spendableOut {
 // all the provided spendable outputs.  The new block is returned, together with
 // the new spendable outputs created in the block.
 //
-// Panics on errors.
-func addBlock(chain *BlockChain, prev *czzutil.Block, spends []*spendableOut) (*czzutil.Block, []*spendableOut) {
+// Fails the test on errors.
+func addBlock(t *testing.T, chain *BlockChain, prev *czzutil.Block, spends []*spendableOut) (*czzutil.Block, []*spendableOut) {
 	blockHeight := prev.Height() + 1
 	txns := make([]*wire.MsgTx, 0, 1+len(spends))
 
@@ -140,7 +140,7 @@ func addBlock(chain *BlockChain, prev *czzutil.Block, spends []*spendableOut) (*
 		AddInt64(int64(blockHeight)).
 		AddInt64(int64(0)).Script()
 	if err != nil {
-		panic(err)
+		t.Fatalf("addBlock: %v", err)
 	}
 	cb := wire.NewMsgTx(1)
 	cb.AddTxIn(&wire.TxIn{
@@ -212,12 +212,12 @@ func addBlock(chain *BlockChain, prev *czzutil.Block, spends []*spendableOut) (*
 
 	// Solve the block.
 	if !solveBlock(&block.MsgBlock().Header) {
-		panic(fmt.Sprintf("Unable to solve block at height %d", blockHeight))
+		t.Fatalf("Unable to solve block at height %d", blockHeight)
 	}
 
 	_, _, err = chain.ProcessBlock(block, BFNone)
 	if err != nil {
-		panic(err)
+		t.Fatalf("addBlock: %v", err)
 	}
 
 	return block, outs
@@ -316,7 +316,7 @@ func TestUtxoCache_SimpleFlush(t *testing.T) {
 
 	// First, add 10 utxos without flushing.
 	for i := 0; i < 10; i++ {
-		tip, _ = addBlock(chain, tip, nil)
+		tip, _ = addBlock(t, chain, tip, nil)
 	}
 	if len(cache.cachedEntries) != 10 {
 		t.Fatalf("Expected 10 entries, has %d instead", len(cache.cachedEntries))
@@ -363,7 +363,7 @@ func TestUtxoCache_ThresholdPeriodicFlush(t *testing.T) {
 	// Add 10 elems and let it exceed the threshold.
 	var flushedAt *chainhash.Hash
 	for i := 0; i < 10; i++ {
-		tip, _ = addBlock(chain, tip, nil)
+		tip, _ = addBlock(t, chain, tip, nil)
 		if len(cache.cachedEntries) == 0 {
 			flushedAt = tip.Hash()
 		}
@@ -399,8 +399,8 @@ func TestUtxoCache_Reorg(t *testing.T) {
 	// Create base blocks 1 and 2 that will not be reorged.
 	// Spend the outputs of block 1.
 	var emptySpendableOuts []*spendableOut
-	b1, spendableOuts1 := addBlock(chain, tip, emptySpendableOuts)
-	b2, spendableOuts2 := addBlock(chain, b1, spendableOuts1)
+	b1, spendableOuts1 := addBlock(t, chain, tip, emptySpendableOuts)
+	b2, spendableOuts2 := addBlock(t, chain, b1, spendableOuts1)
 	t.Log(spew.Sdump(spendableOuts2))
 	//                 db       cache
 	// block 1:                  stxo
@@ -418,8 +418,8 @@ func TestUtxoCache_Reorg(t *testing.T) {
 
 	// Add blocks 3 and 4 that will be orphaned.
 	// Spend the outputs of block 2 and 3a.
-	b3a, spendableOuts3 := addBlock(chain, b2, spendableOuts2)
-	addBlock(chain, b3a, spendableOuts3)
+	b3a, spendableOuts3 := addBlock(t, chain, b2, spendableOuts2)
+	addBlock(t, chain, b3a, spendableOuts3)
 	//                 db       cache
 	// block 1:       stxo
 	// block 2:       utxo       stxo      << these are left spent without flush
@@ -428,9 +428,9 @@ func TestUtxoCache_Reorg(t *testing.T) {
 	// block 4a:                 utxo
 
 	// Build an alternative chain of blocks 3 and 4 + new 5th, spending none of the outputs
-	b3b, altSpendableOuts3 := addBlock(chain, b2, nil)
-	b4b, altSpendableOuts4 := addBlock(chain, b3b, nil)
-	b5b, altSpendableOuts5 := addBlock(chain, b4b, nil)
+	b3b, altSpendableOuts3 := addBlock(t, chain, b2, nil)
+	b4b, altSpendableOuts4 := addBlock(t, chain, b3b, nil)
+	b5b, altSpendableOuts5 := addBlock(t, chain, b4b, nil)
 	totalSpendableOuts := spendableOuts2[:]
 	totalSpendableOuts = append(totalSpendableOuts, altSpendableOuts3...)
 	totalSpendableOuts = append(totalSpendableOuts, altSpendableOuts4...)
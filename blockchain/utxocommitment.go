@@ -0,0 +1,241 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/czzec"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// UtxoCommitmentHeader is the prefix of the data pushed by the coinbase output
+// which commits to the hash of the utxo set once the utxo commitment deployment
+// is active.  The output is a zero value null data output of the form:
+//
+//	OP_RETURN <UtxoCommitmentHeader || utxo set hash>
+//
+// where the utxo set hash is the ECMH hash of the utxo set the block is built
+// on, that is as of its parent, calculated over the outputs serialized in the
+// commitment format like the utxo set hashes of the checkpoints.  A snapshot of
+// the utxo set at a block can thus be verified against the proof of work of the
+// blocks built on it.
+var UtxoCommitmentHeader = [4]byte{0x55, 0x54, 0x58, 0x4f}
+
+// utxoCommitment returns the utxo set hash the passed coinbase transaction
+// commits to, or nil when it carries no commitment.  The last commitment is
+// used when there are several.
+func utxoCommitment(coinbase *wire.MsgTx) *chainhash.Hash {
+	for i := len(coinbase.TxOut) - 1; i >= 0; i-- {
+		script := coinbase.TxOut[i].PkScript
+		if len(script) == 0 || script[0] != txscript.OP_RETURN {
+			continue
+		}
+		pushes, err := txscript.PushedData(script[1:])
+		if err != nil || len(pushes) != 1 ||
+			len(pushes[0]) != len(UtxoCommitmentHeader)+chainhash.HashSize ||
+			!bytes.HasPrefix(pushes[0], UtxoCommitmentHeader[:]) {
+
+			continue
+		}
+		hash, _ := chainhash.NewHash(pushes[0][len(UtxoCommitmentHeader):])
+		return hash
+	}
+	return nil
+}
+
+// UtxoCommitmentScript returns the script of the coinbase output committing to
+// the passed utxo set hash.
+func UtxoCommitmentScript(utxoSetHash *chainhash.Hash) ([]byte, error) {
+	data := make([]byte, 0, len(UtxoCommitmentHeader)+chainhash.HashSize)
+	data = append(data, UtxoCommitmentHeader[:]...)
+	data = append(data, utxoSetHash[:]...)
+	return txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+		AddData(data).Script()
+}
+
+// checkUtxoCommitment ensures the coinbase transaction of the passed block
+// commits to the hash of the passed multiset of the utxo set it is built on.
+func checkUtxoCommitment(block *czzutil.Block, utxoSet *czzec.Multiset) error {
+	hash := utxoCommitment(block.MsgBlock().Transactions[0])
+	if hash == nil {
+		return ruleError(ErrBadUtxoCommitment, "coinbase transaction "+
+			"does not commit to the utxo set")
+	}
+	if want := utxoSet.Hash(); *hash != want {
+		str := fmt.Sprintf("coinbase transaction commits to utxo set "+
+			"hash %v, want %v", hash, want)
+		return ruleError(ErrBadUtxoCommitment, str)
+	}
+	return nil
+}
+
+// copyMultiset returns a copy of the passed multiset, which is nil when the
+// passed one is.
+func copyMultiset(m *czzec.Multiset) *czzec.Multiset {
+	if m == nil {
+		return nil
+	}
+	x, y := m.Point()
+	return czzec.NewMultisetFromPoint(czzec.S256(), x, y)
+}
+
+// connectUtxoSet updates the passed multiset of the utxo set with the outputs
+// created and spent by the passed block, where the passed spent outputs are
+// those of the spend journal of the block.  Outputs which are created and spent
+// within the block cancel out.
+func connectUtxoSet(utxoSet *czzec.Multiset, block *czzutil.Block, stxos []SpentTxOut) {
+	// The outputs are added before the spent ones are removed, since
+	// removing from an empty multiset has no effect.
+	forEachUtxoOfBlock(block, utxoSet.Add)
+	forEachSpentUtxoOfBlock(block, stxos, utxoSet.Remove)
+}
+
+// disconnectUtxoSet undoes connectUtxoSet for the passed block and spent
+// outputs.
+func disconnectUtxoSet(utxoSet *czzec.Multiset, block *czzutil.Block, stxos []SpentTxOut) {
+	forEachSpentUtxoOfBlock(block, stxos, utxoSet.Add)
+	forEachUtxoOfBlock(block, utxoSet.Remove)
+}
+
+// forEachUtxoOfBlock calls the passed function with the outputs created by the
+// passed block which are added to the utxo set, serialized in the commitment
+// format.
+func forEachUtxoOfBlock(block *czzutil.Block, fn func([]byte)) {
+	for _, tx := range block.Transactions() {
+		isCoinBase := IsCoinBase(tx)
+		outpoint := wire.OutPoint{Hash: *tx.Hash()}
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			// Provably unspendable outputs are not added to the
+			// utxo set.
+			if txscript.IsUnspendable(txOut.PkScript) {
+				continue
+			}
+			outpoint.Index = uint32(txOutIdx)
+			fn(serializeUtxoCommitmentFormat(&outpoint, txOut.Value,
+				txOut.PkScript, block.Height(), isCoinBase))
+		}
+	}
+}
+
+// forEachSpentUtxoOfBlock calls the passed function with the outputs spent by
+// the passed block as recorded by the passed spend journal entries, serialized
+// in the commitment format.
+func forEachSpentUtxoOfBlock(block *czzutil.Block, stxos []SpentTxOut, fn func([]byte)) {
+	var stxoIdx int
+	for _, tx := range block.Transactions()[1:] {
+		for _, txIn := range tx.MsgTx().TxIn {
+			stxo := &stxos[stxoIdx]
+			stxoIdx++
+			fn(serializeUtxoCommitmentFormat(&txIn.PreviousOutPoint,
+				stxo.Amount, stxo.PkScript, stxo.Height,
+				stxo.IsCoinBase))
+		}
+	}
+}
+
+// dbCalcUtxoSet uses an existing database transaction to compute the multiset
+// of the utxo set stored in the database.  The utxo cache must be flushed so
+// that the database holds the whole utxo set.
+func dbCalcUtxoSet(dbTx database.Tx, interrupt <-chan struct{}) (*czzec.Multiset, error) {
+	utxoSet := czzec.NewMultiset(czzec.S256())
+	cursor := dbTx.Metadata().Bucket(utxoSetBucketName).Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		if interruptRequested(interrupt) {
+			return nil, errInterruptRequested
+		}
+
+		outpoint := DeserializeOutpointKey(cursor.Key())
+		entry, err := DeserializeUtxoEntry(cursor.Value())
+		if err != nil {
+			return nil, err
+		}
+		utxoSet.Add(serializeUtxoCommitmentFormat(outpoint,
+			entry.Amount(), entry.PkScript(), entry.BlockHeight(),
+			entry.IsCoinBase()))
+	}
+	return utxoSet, nil
+}
+
+// trackUtxoSet returns whether the multiset of the utxo set is kept as of the
+// passed node, which is the case once the utxo commitment deployment is locked
+// in or active for the block after it.  Keeping the multiset costs elliptic
+// curve operations for every output created and spent, so it is not kept
+// before the blocks are about to commit to the utxo set.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) trackUtxoSet(node *blockNode) (bool, error) {
+	state, err := b.deploymentState(node, chaincfg.DeploymentUtxoCommitment)
+	if err != nil {
+		return false, err
+	}
+	return state == ThresholdLockedIn || state == ThresholdActive, nil
+}
+
+// calcUtxoSet flushes the utxo cache with the passed best state and computes
+// the multiset of the utxo set from the database.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) calcUtxoSet(state *BestState, interrupt <-chan struct{}) (*czzec.Multiset, error) {
+	log.Info("Computing the hash of the UTXO set.  This might take a " +
+		"while...")
+	if err := b.utxoCache.Flush(FlushRequired, state); err != nil {
+		return nil, err
+	}
+	var utxoSet *czzec.Multiset
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		utxoSet, err = dbCalcUtxoSet(dbTx, interrupt)
+		return err
+	})
+	return utxoSet, err
+}
+
+// reorganizeUtxoSet returns a copy of the multiset of the utxo set for the
+// reorganization check phase to update while it attaches the passed nodes.  The
+// multiset is computed from the database first when the utxo commitment
+// deployment is locked in as of the last of them and it isn't kept yet.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) reorganizeUtxoSet(attachNodes *list.List) (*czzec.Multiset, error) {
+	if b.utxoSet == nil && !b.utxoSetUnknown && attachNodes.Len() != 0 {
+		trackUtxoSet, err := b.trackUtxoSet(attachNodes.Back().Value.(*blockNode))
+		if err != nil {
+			return nil, err
+		}
+		if trackUtxoSet {
+			b.utxoSet, err = b.calcUtxoSet(b.BestSnapshot(), nil)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return copyMultiset(b.utxoSet), nil
+}
+
+// UtxoSetHash returns the ECMH hash of the utxo set as of the end of the main
+// chain, which the coinbase of the next block commits to once the utxo
+// commitment deployment is active.  It returns nil before the deployment is
+// locked in and while the utxo set is unknown, which is only the case while it
+// is being fast synced.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) UtxoSetHash() *chainhash.Hash {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	if b.utxoSet == nil {
+		return nil
+	}
+	hash := b.utxoSet.Hash()
+	return &hash
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/czzec"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// newUtxoCommitmentBlock returns a block at height 2 with a coinbase, a
+// transaction spending the passed outpoint and a transaction spending the first
+// output of the previous one.
+func newUtxoCommitmentBlock(spent wire.OutPoint) *czzutil.Block {
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: []byte{txscript.OP_2, txscript.OP_1},
+		Sequence:        wire.MaxTxInSequenceNum,
+	})
+	coinbase.AddTxOut(wire.NewTxOut(5000000000, []byte{txscript.OP_TRUE}))
+	coinbase.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_RETURN}))
+
+	tx1 := wire.NewMsgTx(wire.TxVersion)
+	tx1.AddTxIn(wire.NewTxIn(&spent, nil))
+	tx1.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}))
+	tx1.AddTxOut(wire.NewTxOut(2000, []byte{txscript.OP_TRUE, txscript.OP_1}))
+
+	tx1Hash := tx1.TxHash()
+	tx2 := wire.NewMsgTx(wire.TxVersion)
+	tx2.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&tx1Hash, 0), nil))
+	tx2.AddTxOut(wire.NewTxOut(900, []byte{txscript.OP_TRUE}))
+
+	block := czzutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase, tx1, tx2},
+	})
+	block.SetHeight(2)
+	return block
+}
+
+// TestUtxoCommitment ensures the utxo set hash committed to by a coinbase is
+// found back and checked against the multiset of the utxo set.
+func TestUtxoCommitment(t *testing.T) {
+	utxoSet := czzec.NewMultiset(czzec.S256())
+	utxoSet.Add([]byte{0x01})
+	utxoSetHash := utxoSet.Hash()
+
+	block := newUtxoCommitmentBlock(wire.OutPoint{})
+	coinbase := block.MsgBlock().Transactions[0]
+	if hash := utxoCommitment(coinbase); hash != nil {
+		t.Fatalf("utxoCommitment: unexpected commitment %v", hash)
+	}
+	err := checkUtxoCommitment(block, utxoSet)
+	if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != ErrBadUtxoCommitment {
+		t.Fatalf("checkUtxoCommitment: unexpected error of block "+
+			"without commitment: %v", err)
+	}
+
+	// Null data outputs which do not carry the header are not commitments.
+	script, err := txscript.NullDataScript(utxoSetHash[:])
+	if err != nil {
+		t.Fatalf("NullDataScript: unexpected error: %v", err)
+	}
+	coinbase.AddTxOut(wire.NewTxOut(0, script))
+	if hash := utxoCommitment(coinbase); hash != nil {
+		t.Fatalf("utxoCommitment: unexpected commitment %v", hash)
+	}
+
+	// A commitment to another utxo set is rejected.
+	otherHash := chainhash.Hash{0x01}
+	script, err = UtxoCommitmentScript(&otherHash)
+	if err != nil {
+		t.Fatalf("UtxoCommitmentScript: unexpected error: %v", err)
+	}
+	coinbase.AddTxOut(wire.NewTxOut(0, script))
+	if hash := utxoCommitment(coinbase); hash == nil || *hash != otherHash {
+		t.Fatalf("utxoCommitment: got commitment %v, want %v", hash,
+			otherHash)
+	}
+	err = checkUtxoCommitment(block, utxoSet)
+	if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != ErrBadUtxoCommitment {
+		t.Fatalf("checkUtxoCommitment: unexpected error of block "+
+			"with wrong commitment: %v", err)
+	}
+
+	// The last commitment is the one which counts.
+	script, err = UtxoCommitmentScript(&utxoSetHash)
+	if err != nil {
+		t.Fatalf("UtxoCommitmentScript: unexpected error: %v", err)
+	}
+	coinbase.AddTxOut(wire.NewTxOut(0, script))
+	if err := checkUtxoCommitment(block, utxoSet); err != nil {
+		t.Fatalf("checkUtxoCommitment: unexpected error: %v", err)
+	}
+}
+
+// TestConnectUtxoSet ensures connecting a block updates the multiset of the
+// utxo set to the one of its outputs which remain unspent, and that
+// disconnecting it restores the previous one.
+func TestConnectUtxoSet(t *testing.T) {
+	spent := wire.OutPoint{Hash: chainhash.Hash{0x02}, Index: 1}
+	stxo := SpentTxOut{
+		Amount:     3000,
+		PkScript:   []byte{txscript.OP_TRUE},
+		Height:     1,
+		IsCoinBase: true,
+	}
+	unspent := wire.OutPoint{Hash: chainhash.Hash{0x03}}
+
+	before := czzec.NewMultiset(czzec.S256())
+	before.Add(serializeUtxoCommitmentFormat(&spent, stxo.Amount,
+		stxo.PkScript, stxo.Height, stxo.IsCoinBase))
+	before.Add(serializeUtxoCommitmentFormat(&unspent, 4000,
+		[]byte{txscript.OP_TRUE}, 1, false))
+	beforeHash := before.Hash()
+
+	// The output of the first transaction spent by the second one and the
+	// null data output of the coinbase are not part of the utxo set.
+	block := newUtxoCommitmentBlock(spent)
+	txns := block.MsgBlock().Transactions
+	tx1Out := txns[1].TxOut[0]
+	stxos := []SpentTxOut{stxo, {
+		Amount:   tx1Out.Value,
+		PkScript: tx1Out.PkScript,
+		Height:   block.Height(),
+	}}
+	want := czzec.NewMultiset(czzec.S256())
+	want.Add(serializeUtxoCommitmentFormat(&unspent, 4000,
+		[]byte{txscript.OP_TRUE}, 1, false))
+	want.Add(serializeUtxoCommitmentFormat(
+		wire.NewOutPoint(block.Transactions()[0].Hash(), 0),
+		txns[0].TxOut[0].Value, txns[0].TxOut[0].PkScript, 2, true))
+	want.Add(serializeUtxoCommitmentFormat(
+		wire.NewOutPoint(block.Transactions()[1].Hash(), 1),
+		txns[1].TxOut[1].Value, txns[1].TxOut[1].PkScript, 2, false))
+	want.Add(serializeUtxoCommitmentFormat(
+		wire.NewOutPoint(block.Transactions()[2].Hash(), 0),
+		txns[2].TxOut[0].Value, txns[2].TxOut[0].PkScript, 2, false))
+
+	utxoSet := copyMultiset(before)
+	connectUtxoSet(utxoSet, block, stxos)
+	if got := utxoSet.Hash(); got != want.Hash() {
+		t.Fatalf("connectUtxoSet: got utxo set hash %v, want %v", got,
+			want.Hash())
+	}
+	if before.Hash() != beforeHash {
+		t.Fatal("connectUtxoSet: the copied multiset was modified")
+	}
+
+	disconnectUtxoSet(utxoSet, block, stxos)
+	if got := utxoSet.Hash(); got != beforeHash {
+		t.Fatalf("disconnectUtxoSet: got utxo set hash %v, want %v", got,
+			beforeHash)
+	}
+}
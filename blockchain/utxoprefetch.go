@@ -0,0 +1,191 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"sync"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+const (
+	// utxoPrefetchWorkers is the maximum number of concurrent database
+	// lookups of a utxo prefetch.
+	utxoPrefetchWorkers = 16
+
+	// minUtxoPrefetchOutpoints is the minimum number of utxos missing from
+	// the cache for a block to prefetch them.  Fewer are looked up serially
+	// when the block is connected.
+	minUtxoPrefetchOutpoints = 8
+)
+
+// utxoPrefetch is a concurrent fetch from the database of the utxos referenced
+// by the inputs of a block which are not in the utxo cache.  The database
+// lookups run while the block is checked, and the fetched entries are only
+// added to the cache once the chain needs them, so the cache itself is never
+// accessed concurrently.
+type utxoPrefetch struct {
+	blockHash chainhash.Hash
+	outpoints []wire.OutPoint
+	entries   []*UtxoEntry
+	fetched   []bool
+	quit      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// prefetchInputUtxos starts fetching the utxos referenced by the inputs of the
+// passed block which are neither in the cache nor created earlier in the block.
+// It returns nil when there are too few of them to be worth fetching
+// concurrently.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (s *utxoCache) prefetchInputUtxos(block *czzutil.Block) *utxoPrefetch {
+	transactions := block.Transactions()
+	txInFlight := make(map[chainhash.Hash]struct{}, len(transactions))
+	for _, tx := range transactions {
+		txInFlight[*tx.Hash()] = struct{}{}
+	}
+
+	s.mtx.Lock()
+	seen := make(map[wire.OutPoint]struct{})
+	var outpoints []wire.OutPoint
+	for i, tx := range transactions {
+		for index, txIn := range tx.MsgTx().TxIn {
+			// The first input of the coinbase spends nothing, unlike
+			// the inputs spending the pool outputs.
+			if index == 0 && i == 0 {
+				continue
+			}
+			prevOut := txIn.PreviousOutPoint
+			if _, ok := txInFlight[prevOut.Hash]; ok {
+				continue
+			}
+			if _, ok := s.cachedEntries[prevOut]; ok {
+				continue
+			}
+			if _, ok := seen[prevOut]; ok {
+				continue
+			}
+			seen[prevOut] = struct{}{}
+			outpoints = append(outpoints, prevOut)
+		}
+	}
+	s.mtx.Unlock()
+	if len(outpoints) < minUtxoPrefetchOutpoints {
+		return nil
+	}
+
+	p := &utxoPrefetch{
+		blockHash: *block.Hash(),
+		outpoints: outpoints,
+		entries:   make([]*UtxoEntry, len(outpoints)),
+		fetched:   make([]bool, len(outpoints)),
+		quit:      make(chan struct{}),
+	}
+	work := make(chan int, len(outpoints))
+	for i := range outpoints {
+		work <- i
+	}
+	close(work)
+
+	numWorkers := utxoPrefetchWorkers
+	if numWorkers > len(outpoints) {
+		numWorkers = len(outpoints)
+	}
+	p.wg.Add(numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		go p.fetchWorker(s.db, work)
+	}
+	return p
+}
+
+// fetchWorker looks up the utxos of the indexes received from the passed
+// channel until it is closed or the prefetch is cancelled.  It must be run as
+// a goroutine.
+func (p *utxoPrefetch) fetchWorker(db database.DB, work <-chan int) {
+	defer p.wg.Done()
+
+	for i := range work {
+		select {
+		case <-p.quit:
+			return
+		default:
+		}
+
+		// Lookup errors are not fatal since the utxo is only missing
+		// from the cache and looked up again when the block is
+		// connected.
+		var entry *UtxoEntry
+		err := db.View(func(dbTx database.Tx) error {
+			var err error
+			entry, err = dbFetchUtxoEntry(dbTx, p.outpoints[i])
+			return err
+		})
+		if err != nil {
+			log.Debugf("Unable to prefetch utxo %v: %v",
+				p.outpoints[i], err)
+			continue
+		}
+		p.entries[i] = entry
+		p.fetched[i] = true
+	}
+}
+
+// cancel stops the prefetch and waits for the lookups in progress to end.
+func (p *utxoPrefetch) cancel() {
+	close(p.quit)
+	p.wg.Wait()
+}
+
+// commitPrefetch waits for the passed prefetch to complete and adds the fetched
+// entries which are still missing from the cache to it.  Like for the entries
+// fetched one by one, the utxos the database does not contain are cached as
+// explicit misses.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (s *utxoCache) commitPrefetch(p *utxoPrefetch) {
+	p.wg.Wait()
+
+	s.mtx.Lock()
+	for i, outpoint := range p.outpoints {
+		if !p.fetched[i] {
+			continue
+		}
+		if _, ok := s.cachedEntries[outpoint]; ok {
+			continue
+		}
+		entry := p.entries[i]
+		s.cachedEntries[outpoint] = entry
+		s.totalEntryMemory += entry.memoryUsage()
+	}
+	s.mtx.Unlock()
+}
+
+// takeUtxoPrefetch adds the utxos prefetched for the passed block, if any, to
+// the utxo cache.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) takeUtxoPrefetch(block *czzutil.Block) {
+	p := b.utxoPrefetch
+	if p == nil || p.blockHash != *block.Hash() {
+		return
+	}
+	b.utxoPrefetch = nil
+	b.utxoCache.commitPrefetch(p)
+}
+
+// cancelUtxoPrefetch stops the prefetch of the block being processed, if it
+// was not taken, so its lookups do not outlive the processing of the block.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) cancelUtxoPrefetch() {
+	if b.utxoPrefetch != nil {
+		b.utxoPrefetch.cancel()
+		b.utxoPrefetch = nil
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// TestUtxoPrefetch ensures the utxos spent by a block are prefetched from the
+// database into the cache, including the misses, and that the prefetch is only
+// taken for its block.
+func TestUtxoPrefetch(t *testing.T) {
+	chain, _, tearDown := utxoCacheTestChain("TestUtxoPrefetch")
+	defer tearDown()
+
+	// Create enough utxos for a prefetch in the database.
+	view := NewUtxoViewpoint()
+	var spends []*spendableOut
+	for i := 0; i < minUtxoPrefetchOutpoints; i++ {
+		spend := &spendableOut{
+			prevOut: wire.OutPoint{Hash: chainhash.Hash{0x02, byte(i)}},
+			amount:  czzutil.Amount(1000 + i),
+		}
+		view.addTxOut(spend.prevOut, wire.NewTxOut(int64(spend.amount),
+			opTrueScript), false, false, 1)
+		spends = append(spends, spend)
+	}
+	err := chain.db.Update(func(dbTx database.Tx) error {
+		return dbPutUtxoEntries(dbTx, view.Entries())
+	})
+	if err != nil {
+		t.Fatalf("dbPutUtxoEntries: unexpected error: %v", err)
+	}
+	resetCache := func() {
+		chain.utxoCache.cachedEntries = make(map[wire.OutPoint]*UtxoEntry)
+		chain.utxoCache.totalEntryMemory = 0
+	}
+	resetCache()
+
+	// Build a block spending the outputs and an unknown output.
+	unknown := wire.OutPoint{Hash: chainhash.Hash{0x01}, Index: 0}
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+	})
+	txns := []*wire.MsgTx{coinbase}
+	for _, prevOut := range append(spends, &spendableOut{prevOut: unknown}) {
+		tx := wire.NewMsgTx(1)
+		tx.AddTxIn(&wire.TxIn{PreviousOutPoint: prevOut.prevOut})
+		tx.AddTxOut(wire.NewTxOut(0, opTrueScript))
+		txns = append(txns, tx)
+	}
+	block := czzutil.NewBlock(&wire.MsgBlock{Transactions: txns})
+
+	p := chain.utxoCache.prefetchInputUtxos(block)
+	if p == nil {
+		t.Fatal("prefetchInputUtxos: no prefetch started")
+	}
+	if len(p.outpoints) != len(spends)+1 {
+		t.Fatalf("prefetchInputUtxos: got %d outpoints, want %d",
+			len(p.outpoints), len(spends)+1)
+	}
+	chain.utxoCache.commitPrefetch(p)
+	for _, spend := range spends {
+		entry, ok := chain.utxoCache.cachedEntries[spend.prevOut]
+		if !ok || entry == nil {
+			t.Fatalf("utxo %v was not prefetched", spend.prevOut)
+		}
+		if entry.Amount() != int64(spend.amount) {
+			t.Fatalf("utxo %v: got amount %d, want %d", spend.prevOut,
+				entry.Amount(), spend.amount)
+		}
+	}
+	if entry, ok := chain.utxoCache.cachedEntries[unknown]; !ok || entry != nil {
+		t.Fatalf("unknown utxo was not cached as a miss: %v", entry)
+	}
+
+	// Cached utxos are not prefetched again.
+	if p := chain.utxoCache.prefetchInputUtxos(block); p != nil {
+		t.Fatalf("prefetchInputUtxos: unexpected prefetch of %d cached "+
+			"utxos", len(p.outpoints))
+	}
+
+	// The prefetch of a block is not taken for another block, and is
+	// cancelled when the block was not connected.
+	resetCache()
+	chain.utxoPrefetch = chain.utxoCache.prefetchInputUtxos(block)
+	otherBlock := czzutil.NewBlock(&wire.MsgBlock{
+		Header:       wire.BlockHeader{Nonce: 1},
+		Transactions: []*wire.MsgTx{coinbase},
+	})
+	chain.takeUtxoPrefetch(otherBlock)
+	if chain.utxoPrefetch == nil || len(chain.utxoCache.cachedEntries) != 0 {
+		t.Fatal("takeUtxoPrefetch: prefetch taken for another block")
+	}
+	chain.cancelUtxoPrefetch()
+	if chain.utxoPrefetch != nil || len(chain.utxoCache.cachedEntries) != 0 {
+		t.Fatal("cancelUtxoPrefetch: prefetch not cancelled")
+	}
+
+	chain.utxoPrefetch = chain.utxoCache.prefetchInputUtxos(block)
+	chain.takeUtxoPrefetch(block)
+	if chain.utxoPrefetch != nil {
+		t.Fatal("takeUtxoPrefetch: prefetch not taken")
+	}
+	if len(chain.utxoCache.cachedEntries) != len(spends)+1 {
+		t.Fatalf("takeUtxoPrefetch: got %d cached utxos, want %d",
+			len(chain.utxoCache.cachedEntries), len(spends)+1)
+	}
+}
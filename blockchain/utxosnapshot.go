@@ -0,0 +1,99 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/wire"
+)
+
+// UtxoSnapshot is a consistent read-only view of the utxo set as of the block
+// it was taken at.  It is backed by a read-only database transaction, so
+// reading it does not block blocks from being connected or disconnected in the
+// mean time, which are simply not visible to it.  This makes it suitable for
+// long running scans of the utxo set.
+//
+// The snapshot must be closed when it is no longer needed since the database
+// has to retain the data it refers to until then and can not be closed.
+type UtxoSnapshot struct {
+	dbTx   database.Tx
+	hash   chainhash.Hash
+	height int32
+}
+
+// UtxoSnapshot flushes the utxo cache and returns a snapshot of the utxo set as
+// of the current best block.  Blocks can only be connected while the utxo cache
+// is flushed.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) UtxoSnapshot() (*UtxoSnapshot, error) {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	// The database only contains the whole utxo set once the cache is
+	// flushed.
+	state := b.BestSnapshot()
+	if err := b.utxoCache.Flush(FlushRequired, state); err != nil {
+		return nil, err
+	}
+
+	dbTx, err := b.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	return &UtxoSnapshot{
+		dbTx:   dbTx,
+		hash:   state.Hash,
+		height: state.Height,
+	}, nil
+}
+
+// Hash returns the hash of the block the snapshot was taken at.
+func (s *UtxoSnapshot) Hash() *chainhash.Hash {
+	return &s.hash
+}
+
+// Height returns the height of the block the snapshot was taken at.
+func (s *UtxoSnapshot) Height() int32 {
+	return s.height
+}
+
+// FetchEntry returns the unspent transaction output of the passed outpoint as
+// of the block the snapshot was taken at.  When the output does not exist or
+// was spent, nil will be returned for both the entry and the error.
+func (s *UtxoSnapshot) FetchEntry(outpoint wire.OutPoint) (*UtxoEntry, error) {
+	return dbFetchUtxoEntry(s.dbTx, outpoint)
+}
+
+// ForEach calls the passed function with every unspent transaction output in
+// the snapshot in the order of their outpoints.  Iteration stops when the
+// function returns an error, which is returned, or when the interrupt channel
+// is closed.
+func (s *UtxoSnapshot) ForEach(fn func(outpoint *wire.OutPoint, entry *UtxoEntry) error,
+	interrupt <-chan struct{}) error {
+
+	cursor := s.dbTx.Metadata().Bucket(utxoSetBucketName).Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		if interruptRequested(interrupt) {
+			return errInterruptRequested
+		}
+
+		outpoint := DeserializeOutpointKey(cursor.Key())
+		entry, err := DeserializeUtxoEntry(cursor.Value())
+		if err != nil {
+			return err
+		}
+		if err := fn(outpoint, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close releases the snapshot.  It must not be used afterwards.
+func (s *UtxoSnapshot) Close() error {
+	return s.dbTx.Rollback()
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
 	"github.com/bourbaki-czz/classzz/consensus"
 	"github.com/bourbaki-czz/classzz/cross"
+	"github.com/bourbaki-czz/classzz/czzec"
 	"github.com/bourbaki-czz/classzz/txscript"
 	"github.com/bourbaki-czz/classzz/wire"
 	"github.com/bourbaki-czz/czzutil"
@@ -26,8 +27,10 @@ const (
 	oneMegabyte = 1000000
 
 	// MaxTimeOffsetSeconds is the maximum number of seconds a block time
-	// is allowed to be ahead of the current time.  This is currently 2
-	// hours.
+	// is allowed to be ahead of the current time on mainnet.  This is
+	// currently 2 hours.
+	//
+	// Deprecated: Use the MaxTimeOffset of the chain parameters instead.
 	MaxTimeOffsetSeconds = 2 * 60 * 60
 
 	// MinCoinbaseScriptLen is the minimum length a coinbase script can be.
@@ -64,7 +67,15 @@ const (
 	// transaction after the UAHF hard fork
 	MaxTransactionSigOps = 20000
 
-	allowedFutureBlockTime = 10 * time.Second
+	// MaxTransactionSigChecks is the maximum allowable number of signature
+	// checks executed by the scripts of a transaction once the sigchecks
+	// deployment is active.
+	MaxTransactionSigChecks = 3000
+
+	// BlockMaxBytesPerSigCheck is the number of serialized block bytes
+	// required for each signature check executed by the transactions in a
+	// block once the sigchecks deployment is active.
+	BlockMaxBytesPerSigCheck = 141
 )
 
 var (
@@ -88,6 +99,13 @@ func MaxBlockSigOps(nBlockBytes uint32) int {
 	return nBlockMBytesRoundedUp * MaxBlockSigOpsPerMB
 }
 
+// MaxBlockSigChecks returns the maximum allowable number of signature checks
+// executed by the transactions in a block once the sigchecks deployment is
+// active.  The value is a function of the serialized block size in bytes.
+func MaxBlockSigChecks(nBlockBytes uint32) int {
+	return int(nBlockBytes) / BlockMaxBytesPerSigCheck
+}
+
 // isNullOutpoint determines whether or not a previous transaction output point
 // is set.
 func isNullOutpoint(outpoint *wire.OutPoint) bool {
@@ -103,23 +121,21 @@ func isNullOutpoint(outpoint *wire.OutPoint) bool {
 // a previous output transaction index set to the maximum value along with a
 // zero hash.
 //
+// After the entangle height, a coinbase has two more inputs holding the coin
+// pool.  Since the entangle height depends on the network, this function
+// accepts either number of inputs and isCoinBaseInParam checks the number
+// matches the height of the coinbase.
+//
 // This function only differs from IsCoinBase in that it works with a raw wire
 // transaction as opposed to a higher level util transaction.
 func IsCoinBaseTx(msgTx *wire.MsgTx) bool {
-	// A coin base must only have one transaction input.
-	height, err := ExtractCoinbaseHeight(czzutil.NewTx(msgTx))
-	if err != nil {
+	// A coin base must only have one transaction input, or three after
+	// the entangle height.
+	if len(msgTx.TxIn) != 1 && len(msgTx.TxIn) != 3 {
 		return false
 	}
-
-	if height >= chaincfg.MainNetParams.EntangleHeight {
-		if len(msgTx.TxIn) != 3 {
-			return false
-		}
-	} else {
-		if len(msgTx.TxIn) != 1 {
-			return false
-		}
+	if _, err := ExtractCoinbaseHeight(czzutil.NewTx(msgTx)); err != nil {
+		return false
 	}
 
 	// The previous output of a coin base must have a max value index and
@@ -282,10 +298,15 @@ func CheckTransactionSanity(tx *czzutil.Tx, magneticAnomalyActive bool, scriptFl
 		return ruleError(ErrTxTooSmall, str)
 	}
 
+	// The signature operation limit of a transaction is enforced whether or
+	// not the sigchecks deployment is active, since the sanity checks of a
+	// block do not know the state of the deployment.  Only the limit of a
+	// block is replaced by the signature check limit, which is enforced
+	// when the scripts are executed.
 	sigOps := CountSigOps(tx, scriptFlags)
 	if sigOps > MaxTransactionSigOps {
-		str := fmt.Sprintf("transaction has too many sigops - got "+
-			"%d, max %d", sigOps, MaxTransactionSigOps)
+		str := fmt.Sprintf("transaction has too many sigops - "+
+			"got %d, max %d", sigOps, MaxTransactionSigOps)
 		return ruleError(ErrTxTooManySigOps, str)
 	}
 
@@ -586,7 +607,9 @@ func checkBlockHeaderSanity(bc *BlockChain, header *wire.BlockHeader, powLimit *
 		return ruleError(ErrInvalidTime, str)
 	}
 
-	if header.Timestamp.After(time.Now().Add(allowedFutureBlockTime)) {
+	// Ensure the block time is not too far ahead of the local clock, in
+	// the window of the network.
+	if header.Timestamp.After(time.Now().Add(bc.chainParams.AllowedFutureBlockTime)) {
 		str := fmt.Sprintf("block timestamp of %v > time.Now()", header.Timestamp)
 		return ruleError(ErrInvalidTime, str)
 	}
@@ -598,7 +621,7 @@ func checkBlockHeaderSanity(bc *BlockChain, header *wire.BlockHeader, powLimit *
 	}
 
 	// Ensure the block time is not too far in the future.
-	maxTimestamp := timeSource.AdjustedTime().Add(time.Second * MaxTimeOffsetSeconds)
+	maxTimestamp := timeSource.AdjustedTime().Add(bc.chainParams.MaxTimeOffset)
 	if header.Timestamp.After(maxTimestamp) {
 		str := fmt.Sprintf("block timestamp of %v is too far in the "+
 			"future", header.Timestamp)
@@ -611,8 +634,9 @@ func checkBlockHeaderSanity(bc *BlockChain, header *wire.BlockHeader, powLimit *
 // checkBlockSanity performs some preliminary checks on a block to ensure it is
 // sane before continuing with block processing.  These checks are context free.
 //
-// The flags do not modify the behavior of this function directly, however they
-// are needed to pass along to checkBlockHeaderSanity.
+// The flags modify the behavior of this function as follows:
+//  - BFNoPoWCheck: The block signature required on a signed network is not
+//    checked, and neither is the proof of work by checkBlockHeaderSanity.
 func checkBlockSanity(b *BlockChain, block *czzutil.Block, powLimit *big.Int, timeSource MedianTimeSource, flags BehaviorFlags) error {
 	msgBlock := block.MsgBlock()
 	header := &msgBlock.Header
@@ -629,9 +653,10 @@ func checkBlockSanity(b *BlockChain, block *czzutil.Block, powLimit *big.Int, ti
 			"any transactions")
 	}
 
-	// The first transaction in a block must be a coinbase.
+	// The first transaction in a block must be a coinbase with the number
+	// of inputs required at its height.
 	transactions := block.Transactions()
-	if !IsCoinBase(transactions[0]) {
+	if !isCoinBaseInParam(transactions[0], b.chainParams) {
 		return ruleError(ErrFirstTxNotCoinbase, "first transaction in "+
 			"block is not a coinbase")
 	}
@@ -678,15 +703,25 @@ func checkBlockSanity(b *BlockChain, block *czzutil.Block, powLimit *big.Int, ti
 	// checks.  Bitcoind builds the tree here and checks the merkle root
 	// after the following checks, but there is no reason not to check the
 	// merkle root matches here.
-	merkles := BuildMerkleTreeStore(block.Transactions())
-	calculatedMerkleRoot := merkles[len(merkles)-1]
-	if !header.MerkleRoot.IsEqual(calculatedMerkleRoot) {
+	calculatedMerkleRoot := CalcMerkleRoot(block.Transactions())
+	if !header.MerkleRoot.IsEqual(&calculatedMerkleRoot) {
 		str := fmt.Sprintf("block merkle root is invalid - block "+
 			"header indicates %v, but calculated value is %v",
 			header.MerkleRoot, calculatedMerkleRoot)
 		return ruleError(ErrBadMerkleRoot, str)
 	}
 
+	// On a signed network the coinbase must carry a block signature
+	// satisfying the challenge of the network.  It is checked along with
+	// the proof of work, since block templates are signed once they are
+	// solved.
+	challenge := b.chainParams.SignetChallenge
+	if challenge != nil && !flags.HasFlag(BFNoPoWCheck) {
+		if err := checkSignetSolution(block, challenge); err != nil {
+			return err
+		}
+	}
+
 	// Check for duplicate transactions.  This check will be fairly quick
 	// since the transaction hashes are already cached due to building the
 	// merkle tree above.
@@ -1013,17 +1048,20 @@ func checkBlockSubsidy(block, preBlock *czzutil.Block, txHeight int32, utxoView
 	// check pool1 reward
 	expPool1Amount := summay.lastpool1Amount + originIncome1 - summay.EntangleAmount
 	if summay.pool1Amount != expPool1Amount {
-		return errors.New(fmt.Sprintf("BlockSubsidy:the pool1 address's reward was wrong[%v,expected:%v] height:%d ",
-			summay.pool1Amount, expPool1Amount, txHeight))
+		str := fmt.Sprintf("BlockSubsidy:the pool1 address's reward was wrong[%v,expected:%v] height:%d ",
+			summay.pool1Amount, expPool1Amount, txHeight)
+		return ruleError(ErrBadPoolOutput, str)
 	}
 	// check pool2 reward
 	if originIncome2+summay.lastpool2Amount != summay.pool2Amount {
-		return errors.New(fmt.Sprintf("BlockSubsidy:the pool2 address's reward was wrong[%v,expected:%v] height:%d ",
-			summay.pool2Amount, originIncome2+summay.lastpool2Amount, txHeight))
+		str := fmt.Sprintf("BlockSubsidy:the pool2 address's reward was wrong[%v,expected:%v] height:%d ",
+			summay.pool2Amount, originIncome2+summay.lastpool2Amount, txHeight)
+		return ruleError(ErrBadPoolOutput, str)
 	}
 	if summay.TotalOut > summay.TotalIn {
-		return errors.New(fmt.Sprintf("BlockSubsidy:wrong,the totalOut > totalIn,[totalOut:%v,totalIn:%v] height:%d",
-			summay.TotalOut, summay.TotalIn, txHeight))
+		str := fmt.Sprintf("BlockSubsidy:wrong,the totalOut > totalIn,[totalOut:%v,totalIn:%v] height:%d",
+			summay.TotalOut, summay.TotalIn, txHeight)
+		return ruleError(ErrBadCoinbaseValue, str)
 	}
 	return nil
 }
@@ -1141,6 +1179,43 @@ func CheckTransactionInputs(tx *czzutil.Tx, txHeight int32, utxoView *UtxoViewpo
 	return txFeeInSatoshi, nil
 }
 
+// blockScriptFlags returns the flags the scripts of the transactions of the
+// block after the passed node are validated with.  They are the flags of the
+// script flag schedule at the height of the block and those of the active rule
+// change deployments.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) blockScriptFlags(prevNode *blockNode) (txscript.ScriptFlags, error) {
+	// The flags of the block height are enabled by the script flag schedule
+	// of the network.
+	scriptFlags := b.scheduledScriptFlags(prevNode.height + 1)
+
+	// Once the sigchecks deployment is active, transactions and blocks are
+	// limited by the number of signature checks their scripts execute,
+	// which is enforced while running the scripts, instead of by their
+	// signature operation count.
+	sigChecksState, err := b.deploymentState(prevNode,
+		chaincfg.DeploymentSigChecks)
+	if err != nil {
+		return 0, err
+	}
+	if sigChecksState == ThresholdActive {
+		scriptFlags |= txscript.ScriptVerifySigChecks
+	}
+
+	// Enforce CHECKSEQUENCEVERIFY once the soft-fork deployment is fully
+	// active.
+	csvState, err := b.deploymentState(prevNode, chaincfg.DeploymentCSV)
+	if err != nil {
+		return 0, err
+	}
+	if csvState == ThresholdActive {
+		scriptFlags |= txscript.ScriptVerifyCheckSequenceVerify
+	}
+
+	return scriptFlags, nil
+}
+
 // checkConnectBlock performs several checks to confirm connecting the passed
 // block to the chain represented by the passed view does not violate any rules.
 // In addition, the passed view is updated to spend all of the referenced
@@ -1161,13 +1236,17 @@ func CheckTransactionInputs(tx *czzutil.Tx, txHeight int32, utxoView *UtxoViewpo
 // connects to the end of the current main chain and then calls this function
 // with that node.
 //
+// The passed multiset is the one of the utxo set as of the parent of the node,
+// which the coinbase commits to once the utxo commitment deployment is active.
+// It is nil when the utxo set is unknown, in which case the commitment is not
+// checked.
+//
 // This function MUST be called with the chain state lock held (for writes).
-func (b *BlockChain) checkConnectBlock(node *blockNode, block *czzutil.Block, view *UtxoViewpoint, stxos *[]SpentTxOut) error {
-	// If the side chain blocks end up in the database, a call to
-	// CheckBlockSanity should be done here in case a previous version
-	// allowed a block that is no longer valid.  However, since the
-	// implementation only currently uses memory for the side chain blocks,
-	// it isn't currently necessary.
+func (b *BlockChain) checkConnectBlock(node *blockNode, block *czzutil.Block, view *UtxoViewpoint, stxos *[]SpentTxOut, utxoSet *czzec.Multiset) error {
+	// The blocks of the side chains are stored in the database and only
+	// validated once they are connected, so reorganizeChain checks their
+	// sanity again in case a previous version stored a block which is no
+	// longer valid.
 
 	// The coinbase for the Genesis block is not spendable, so just return
 	// an error now.
@@ -1185,63 +1264,57 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *czzutil.Block, vi
 		return err
 	}
 
-	// Blocks need to have the pay-to-script-hash checks enabled.
-	var scriptFlags txscript.ScriptFlags
-
-	scriptFlags |= txscript.ScriptBip16
-	// Enforce DER signatures
-	scriptFlags |= txscript.ScriptVerifyDERSignatures
-
-	// Enforce CHECKLOCKTIMEVERIFY
-	scriptFlags |= txscript.ScriptVerifyCheckLockTimeVerify
-
-	// we must enforce strict encoding on all signatures and enforce
-	// the replay protected sighash.
-	scriptFlags |= txscript.ScriptVerifyStrictEncoding | txscript.ScriptVerifyBip143SigHash
-
-	// If Daa is active enforce Low S and Nullfail script validation rules.
-	scriptFlags |= txscript.ScriptVerifyLowS | txscript.ScriptVerifyNullFail
-
-	// If MagneticAnomaly hardfork is active we must enforce PushOnly and CleanStack
-	// and enable OP_CHECKDATASIG and OP_CHECKDATASIGVERIFY.
-	scriptFlags |= txscript.ScriptVerifySigPushOnly |
-		txscript.ScriptVerifyCleanStack |
-		txscript.ScriptVerifyCheckDataSig
-
-	// If GreatWall is enforce Schnorr and AllowSegwitRecovery script flags.
-	scriptFlags |= txscript.ScriptVerifySchnorr | txscript.ScriptVerifyAllowSegwitRecovery
+	scriptFlags, err := b.blockScriptFlags(node.parent)
+	if err != nil {
+		return err
+	}
 
-	// The number of signature operations must be less than the maximum
-	// allowed per block.  Note that the preliminary sanity checks on a
-	// block also include a check similar to this one, but this check
-	// expands the count to include a precise count of pay-to-script-hash
-	// signature operations in each of the input transaction public key
-	// scripts.
-	transactions := block.Transactions()
-	totalSigOpCost := 0
-	nBlockBytes := block.MsgBlock().SerializeSize()
-	maxSigOps := MaxBlockSigOps(uint32(nBlockBytes))
-	for i, tx := range transactions {
-		// Since the first (and only the first) transaction has
-		// already been verified to be a coinbase transaction,
-		// use i == 0 as an optimization for the flag to
-		// countP2SHSigOps for whether or not the transaction is
-		// a coinbase transaction rather than having to do a
-		// full coinbase check again.
-		sigOpCost, err := GetSigOps(tx, i == 0, view, scriptFlags)
-		if err != nil {
+	// Once the utxo commitment deployment is active, the coinbase must
+	// commit to the hash of the utxo set the block is built on.
+	utxoCommitmentState, err := b.deploymentState(node.parent,
+		chaincfg.DeploymentUtxoCommitment)
+	if err != nil {
+		return err
+	}
+	if utxoCommitmentState == ThresholdActive && utxoSet != nil {
+		if err := checkUtxoCommitment(block, utxoSet); err != nil {
 			return err
 		}
+	}
+
+	// Prior to the sigchecks deployment, the number of signature operations
+	// must be less than the maximum allowed per block.  Note that the
+	// preliminary sanity checks on a block also include a check similar to
+	// this one, but this check expands the count to include a precise count
+	// of pay-to-script-hash signature operations in each of the input
+	// transaction public key scripts.
+	transactions := block.Transactions()
+	if !scriptFlags.HasFlag(txscript.ScriptVerifySigChecks) {
+		totalSigOpCost := 0
+		nBlockBytes := block.MsgBlock().SerializeSize()
+		maxSigOps := MaxBlockSigOps(uint32(nBlockBytes))
+		for i, tx := range transactions {
+			// Since the first (and only the first) transaction has
+			// already been verified to be a coinbase transaction,
+			// use i == 0 as an optimization for the flag to
+			// countP2SHSigOps for whether or not the transaction is
+			// a coinbase transaction rather than having to do a
+			// full coinbase check again.
+			sigOpCost, err := GetSigOps(tx, i == 0, view, scriptFlags)
+			if err != nil {
+				return err
+			}
 
-		// Check for overflow or going over the limits.  We have to do
-		// this on every loop iteration to avoid overflow.
-		lastSigOpCost := totalSigOpCost
-		totalSigOpCost += sigOpCost
-		if totalSigOpCost < lastSigOpCost || totalSigOpCost > maxSigOps {
-			str := fmt.Sprintf("block contains too many "+
-				"signature operations - got %v, max %v",
-				totalSigOpCost, maxSigOps)
-			return ruleError(ErrTooManySigOps, str)
+			// Check for overflow or going over the limits.  We have to do
+			// this on every loop iteration to avoid overflow.
+			lastSigOpCost := totalSigOpCost
+			totalSigOpCost += sigOpCost
+			if totalSigOpCost < lastSigOpCost || totalSigOpCost > maxSigOps {
+				str := fmt.Sprintf("block contains too many "+
+					"signature operations - got %v, max %v",
+					totalSigOpCost, maxSigOps)
+				return ruleError(ErrTooManySigOps, str)
+			}
 		}
 	}
 
@@ -1344,11 +1417,6 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *czzutil.Block, vi
 		return err
 	}
 	if csvState == ThresholdActive {
-		// If the CSV soft-fork is now active, then modify the
-		// scriptFlags to ensure that the CSV op code is properly
-		// validated during the script checks bleow.
-		scriptFlags |= txscript.ScriptVerifyCheckSequenceVerify
-
 		// We obtain the MTP of the *previous* block in order to
 		// determine if transactions in the current block are final.
 		medianTime := node.parent.CalcPastMedianTime()
@@ -1382,7 +1450,7 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *czzutil.Block, vi
 	// prevent CPU exhaustion attacks.
 	if runScripts {
 		err := checkBlockScripts(block, view, scriptFlags, b.sigCache,
-			b.hashCache)
+			b.hashCache, b.scriptCache)
 		if err != nil {
 			return err
 		}
@@ -1431,7 +1499,33 @@ func (b *BlockChain) CheckConnectBlockTemplate(block *czzutil.Block) error {
 	// is not needed and thus extra work can be avoided.
 	view := NewUtxoViewpoint()
 	newNode := newBlockNode(&header, tip)
-	return b.checkConnectBlock(newNode, block, view, nil)
+	return b.checkConnectBlock(newNode, block, view, nil, b.utxoSet)
+}
+
+// CheckTransactionScripts validates the scripts of the passed transaction with
+// the flags of the next block and records their success in the script cache,
+// so they are not validated again once the transaction is in a block.  The
+// passed view must contain the outputs the transaction spends.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) CheckTransactionScripts(tx *czzutil.Tx, utxoView *UtxoViewpoint) error {
+	b.chainLock.Lock()
+	scriptFlags, err := b.blockScriptFlags(b.bestChain.Tip())
+	b.chainLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := b.scriptCache.Lookup(tx.Hash(), scriptFlags); ok {
+		return nil
+	}
+	sigChecks, err := ValidateTransactionScriptsSigChecks(tx, utxoView,
+		scriptFlags, b.sigCache, b.hashCache)
+	if err != nil {
+		return err
+	}
+	b.scriptCache.Add(tx.Hash(), scriptFlags, sigChecks)
+	return nil
 }
 
 type KeepedInfoSummay struct {
@@ -1539,7 +1633,8 @@ func summayOfTxsAndCheck(preblock, block *czzutil.Block, utxoView *UtxoViewpoint
 	}
 	// check entangle amount
 	if amount1 != summay.EntangleAmount {
-		return nil, errors.New(fmt.Sprintf("not match the entangle amount.[%v,%v]", amount1, summay.EntangleAmount))
+		str := fmt.Sprintf("not match the entangle amount.[%v,%v]", amount1, summay.EntangleAmount)
+		return nil, ruleError(ErrBadEntangleAmount, str)
 	}
 	summay.TotalIn, summay.TotalOut = totalIn, totalOut
 	return summay, nil
@@ -1550,8 +1645,8 @@ func getPoolAddress(pk []byte, chainParams *chaincfg.Params) (czzutil.Address, e
 	return addr, err
 }
 func matchPoolFromUtxo(utxo *UtxoEntry, index int, chainParams *chaincfg.Params) error {
-	CoinPool1 := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
-	CoinPool2 := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2}
+	CoinPool1 := chainParams.CoinPoolHashes[0][:]
+	CoinPool2 := chainParams.CoinPoolHashes[1][:]
 	var pool []byte
 	if index == 1 {
 		pool = CoinPool1[:]
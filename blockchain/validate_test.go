@@ -146,6 +146,15 @@ func TestMaxBlockSigOps(t *testing.T) {
 	}
 }
 
+// TestMaxTimeOffsetSeconds ensures the deprecated maximum time offset of block
+// times matches the one of mainnet.
+func TestMaxTimeOffsetSeconds(t *testing.T) {
+	got := time.Second * MaxTimeOffsetSeconds
+	if want := chaincfg.MainNetParams.MaxTimeOffset; got != want {
+		t.Fatalf("MaxTimeOffsetSeconds: got %v, want %v", got, want)
+	}
+}
+
 // TestCheckConnectBlockTemplate tests the CheckConnectBlockTemplate function to
 // ensure it fails.
 func TestCheckConnectBlockTemplate(t *testing.T) {
@@ -452,22 +461,60 @@ func repeatScript(n int, newBytes ...byte) []byte {
 // TestCheckBlockSanity tests the CheckBlockSanity function to ensure it works
 // as expected.
 func TestCheckBlockSanity(t *testing.T) {
-	powLimit := chaincfg.MainNetParams.PowLimit
-	block := czzutil.NewBlock(&Block100000)
+	params := &chaincfg.RegressionNetParams
+	powLimit := params.PowLimit
+	block := czzutil.NewBlock(&regressionBlock3)
 	timeSource := NewMedianTime()
-	err := CheckBlockSanity(block, powLimit, timeSource, false)
+	chain := newFakeChain(params)
+	err := CheckBlockSanity(chain, block, powLimit, timeSource, true)
 	if err != nil {
 		t.Errorf("CheckBlockSanity: %v", err)
 	}
 
 	// Ensure a block that has a timestamp with a precision higher than one
 	// second fails.
-	timestamp := block.MsgBlock().Header.Timestamp
-	block.MsgBlock().Header.Timestamp = timestamp.Add(time.Nanosecond)
-	err = CheckBlockSanity(block, powLimit, timeSource, true)
+	msgBlock := regressionBlock3
+	msgBlock.Header.Timestamp = msgBlock.Header.Timestamp.Add(time.Nanosecond)
+	err = CheckBlockSanity(chain, czzutil.NewBlock(&msgBlock), powLimit,
+		timeSource, true)
 	if err == nil {
 		t.Errorf("CheckBlockSanity: error is nil when it shouldn't be")
 	}
+
+	// Ensure a block whose coinbase does not spend the coin pools past the
+	// entangle height fails.
+	coinbase := *regressionBlock3.Transactions[0]
+	coinbase.TxIn = coinbase.TxIn[:1]
+	msgBlock = regressionBlock3
+	msgBlock.Transactions = append([]*wire.MsgTx{&coinbase},
+		regressionBlock3.Transactions[1:]...)
+	msgBlock.Header.MerkleRoot = CalcMerkleRoot(
+		czzutil.NewBlock(&msgBlock).Transactions())
+	err = CheckBlockSanity(chain, czzutil.NewBlock(&msgBlock), powLimit,
+		timeSource, true)
+	if rerr, ok := err.(RuleError); !ok ||
+		rerr.ErrorCode != ErrFirstTxNotCoinbase {
+
+		t.Errorf("CheckBlockSanity: got %v, want %v", err,
+			ErrFirstTxNotCoinbase)
+	}
+
+	// Ensure a block whose transactions are not in canonical order fails
+	// once the magnetic anomaly rules apply.
+	msgBlock = regressionBlock3
+	msgBlock.Transactions = []*wire.MsgTx{
+		regressionBlock3.Transactions[0],
+		regressionBlock3.Transactions[2],
+		regressionBlock3.Transactions[1],
+	}
+	err = CheckBlockSanity(chain, czzutil.NewBlock(&msgBlock), powLimit,
+		timeSource, true)
+	if rerr, ok := err.(RuleError); !ok ||
+		rerr.ErrorCode != ErrInvalidTxOrder {
+
+		t.Errorf("CheckBlockSanity: got %v, want %v", err,
+			ErrInvalidTxOrder)
+	}
 }
 
 // TestCheckSerializedHeight tests the checkSerializedHeight function with
@@ -786,3 +833,190 @@ var Block100000 = wire.MsgBlock{
 		},
 	},
 }
+
+// regressionBlock3 defines block 3 of a regression test chain, which spends the
+// coin pools in its coinbase and the coinbases of blocks 1 and 2 in its other
+// transactions.  It is used to test the sanity checks of czz blocks.
+var regressionBlock3 = wire.MsgBlock{
+	Header: wire.BlockHeader{
+		Version: 0x20000000,
+		PrevBlock: chainhash.Hash([32]byte{ // Make go vet happy.
+			0x3c, 0xeb, 0xb4, 0x6f, 0x82, 0x82, 0xb6, 0x50,
+			0x8f, 0x51, 0x4e, 0x91, 0xc7, 0x6f, 0xee, 0x67,
+			0xdb, 0x2d, 0xe3, 0x54, 0x84, 0x6c, 0xb3, 0x7f,
+			0xee, 0x7a, 0x13, 0xd2, 0x3d, 0xfa, 0x04, 0xed,
+		}), // 3cebb46f8282b6508f514e91c76fee67db2de354846cb37fee7a13d23dfa04ed
+		MerkleRoot: chainhash.Hash([32]byte{ // Make go vet happy.
+			0x3b, 0x5a, 0x28, 0x90, 0x6f, 0x15, 0xc1, 0xda,
+			0x99, 0x6c, 0x28, 0x29, 0x1d, 0xf2, 0xb7, 0xb1,
+			0x98, 0xd2, 0x3a, 0xc6, 0xf6, 0x8b, 0xb8, 0xa1,
+			0x9e, 0x21, 0x0b, 0xf1, 0x78, 0xa1, 0x6f, 0x43,
+		}), // 3b5a28906f15c1da996c28291df2b7b198d23ac6f68bb8a19e210bf178a16f43
+		Timestamp: time.Unix(1792024239, 0), // 2026-10-15 00:30:39 +0000 UTC
+		Bits:      0x207fffff,
+		Nonce:     0,
+	},
+	Transactions: []*wire.MsgTx{
+		{
+			Version: 1,
+			TxIn: []*wire.TxIn{
+				{
+					PreviousOutPoint: wire.OutPoint{
+						Hash:  chainhash.Hash{},
+						Index: 0xffffffff,
+					},
+					SignatureScript: []byte{
+						0x53, 0x00, 0x09, 0x2f, 0x63, 0x6c, 0x61, 0x73,
+						0x73, 0x7a, 0x7a, 0x2f,
+					},
+					Sequence: 0xffffffff,
+				},
+				{
+					PreviousOutPoint: wire.OutPoint{
+						Hash: chainhash.Hash([32]byte{ // Make go vet happy.
+							0x66, 0x4d, 0x4d, 0x61, 0xdb, 0x26, 0x75, 0xd3,
+							0x3f, 0xc0, 0xf7, 0xe4, 0xe8, 0x73, 0x24, 0xd4,
+							0xea, 0x87, 0xe6, 0x34, 0x33, 0x4a, 0x5d, 0x2f,
+							0xb7, 0x54, 0x0e, 0xa4, 0x9b, 0x67, 0x8f, 0x56,
+						}), // 664d4d61db2675d33fc0f7e4e87324d4ea87e634334a5d2fb7540ea49b678f56
+						Index: 0x1,
+					},
+					SignatureScript: []byte{
+						0x76, 0xa9, 0x14, 0x00, 0x00, 0x00, 0x00, 0x00,
+						0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+						0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x88,
+						0xac,
+					},
+					Sequence: 0xffffffff,
+				},
+				{
+					PreviousOutPoint: wire.OutPoint{
+						Hash: chainhash.Hash([32]byte{ // Make go vet happy.
+							0x66, 0x4d, 0x4d, 0x61, 0xdb, 0x26, 0x75, 0xd3,
+							0x3f, 0xc0, 0xf7, 0xe4, 0xe8, 0x73, 0x24, 0xd4,
+							0xea, 0x87, 0xe6, 0x34, 0x33, 0x4a, 0x5d, 0x2f,
+							0xb7, 0x54, 0x0e, 0xa4, 0x9b, 0x67, 0x8f, 0x56,
+						}), // 664d4d61db2675d33fc0f7e4e87324d4ea87e634334a5d2fb7540ea49b678f56
+						Index: 0x2,
+					},
+					SignatureScript: []byte{
+						0x76, 0xa9, 0x14, 0x00, 0x00, 0x00, 0x00, 0x00,
+						0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+						0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x88,
+						0xac,
+					},
+					Sequence: 0xffffffff,
+				},
+			},
+			TxOut: []*wire.TxOut{
+				{
+					Value: 80000020000,
+					PkScript: []byte{
+						0x51,
+					},
+				},
+				{
+					Value: 38000000000,
+					PkScript: []byte{
+						0x76, 0xa9, 0x14, 0x00, 0x00, 0x00, 0x00, 0x00,
+						0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+						0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x88,
+						0xac,
+					},
+				},
+				{
+					Value: 2000000000,
+					PkScript: []byte{
+						0x76, 0xa9, 0x14, 0x00, 0x00, 0x00, 0x00, 0x00,
+						0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+						0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x88,
+						0xac,
+					},
+				},
+				{
+					Value: 0,
+					PkScript: []byte{
+						0x6a, 0xc2, 0x00,
+					},
+				},
+			},
+			LockTime: 0,
+		},
+		{
+			Version: 1,
+			TxIn: []*wire.TxIn{
+				{
+					PreviousOutPoint: wire.OutPoint{
+						Hash: chainhash.Hash([32]byte{ // Make go vet happy.
+							0x66, 0x4d, 0x4d, 0x61, 0xdb, 0x26, 0x75, 0xd3,
+							0x3f, 0xc0, 0xf7, 0xe4, 0xe8, 0x73, 0x24, 0xd4,
+							0xea, 0x87, 0xe6, 0x34, 0x33, 0x4a, 0x5d, 0x2f,
+							0xb7, 0x54, 0x0e, 0xa4, 0x9b, 0x67, 0x8f, 0x56,
+						}), // 664d4d61db2675d33fc0f7e4e87324d4ea87e634334a5d2fb7540ea49b678f56
+						Index: 0x0,
+					},
+					Sequence: 0xffffffff,
+				},
+			},
+			TxOut: []*wire.TxOut{
+				{
+					Value: 39999995000,
+					PkScript: []byte{
+						0x76, 0xa9, 0x14, 0x00, 0x00, 0x00, 0x00, 0x00,
+						0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+						0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x88,
+						0xac,
+					},
+				},
+				{
+					Value: 39999994999,
+					PkScript: []byte{
+						0x76, 0xa9, 0x14, 0x00, 0x00, 0x00, 0x00, 0x00,
+						0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+						0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x88,
+						0xac,
+					},
+				},
+			},
+			LockTime: 0,
+		},
+		{
+			Version: 1,
+			TxIn: []*wire.TxIn{
+				{
+					PreviousOutPoint: wire.OutPoint{
+						Hash: chainhash.Hash([32]byte{ // Make go vet happy.
+							0xd0, 0xff, 0xe2, 0x13, 0xf9, 0x81, 0xe2, 0x45,
+							0x5f, 0x3d, 0x88, 0xf4, 0xaf, 0xda, 0x8f, 0x72,
+							0x62, 0xfa, 0x6d, 0x2e, 0x4f, 0xbc, 0x4d, 0xd8,
+							0x1c, 0xe9, 0xab, 0x1e, 0xcb, 0x7d, 0xed, 0xf7,
+						}), // d0ffe213f981e2455f3d88f4afda8f7262fa6d2e4fbc4dd81ce9ab1ecb7dedf7
+						Index: 0x0,
+					},
+					Sequence: 0xffffffff,
+				},
+			},
+			TxOut: []*wire.TxOut{
+				{
+					Value: 39999995000,
+					PkScript: []byte{
+						0x76, 0xa9, 0x14, 0x00, 0x00, 0x00, 0x00, 0x00,
+						0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+						0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x88,
+						0xac,
+					},
+				},
+				{
+					Value: 39999995000,
+					PkScript: []byte{
+						0x76, 0xa9, 0x14, 0x00, 0x00, 0x00, 0x00, 0x00,
+						0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+						0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x88,
+						0xac,
+					},
+				},
+			},
+			LockTime: 0,
+		},
+	},
+}
@@ -0,0 +1,487 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// updateVectors rewrites the validation vector fixtures from the scenarios
+// generated by validationScenarios instead of checking they are up to date.
+var updateVectors = flag.Bool("updatevectors", false, "rewrite the validation "+
+	"vector fixtures in testdata/vectors")
+
+// vectorsDir is the directory of the validation vector fixtures.
+var vectorsDir = filepath.Join("testdata", "vectors")
+
+// vectorUtxo is an unspent output of the utxo set a validation vector is
+// checked against.
+type vectorUtxo struct {
+	Txid     string `json:"txid"`
+	Vout     uint32 `json:"vout"`
+	Amount   int64  `json:"amount"`
+	PkScript string `json:"pkscript"`
+	Height   int32  `json:"height"`
+	Coinbase bool   `json:"coinbase"`
+}
+
+// validationVector is a consensus validation scenario, serialized as a JSON
+// fixture so it can be replayed by other implementations.  The block is
+// checked for sanity and then connected at the given height on top of the
+// genesis block of the network, with the utxos as the utxo set.  Between them,
+// the ancestors of the block are the headers of version 1 with the compact
+// proof of work limit as bits, the zero merkle root and nonce, and the
+// timestamp of their parent plus the target time per block.  The expected
+// errors are the names of the rule error codes, or empty when the check
+// succeeds.  The connect error is not checked when a sanity error is expected.
+type validationVector struct {
+	Description  string       `json:"description"`
+	Network      string       `json:"network"`
+	Height       int32        `json:"height"`
+	Utxos        []vectorUtxo `json:"utxos"`
+	Block        string       `json:"block"`
+	SanityError  string       `json:"sanityerror"`
+	ConnectError string       `json:"connecterror"`
+}
+
+// vectorNetworks are the networks validation vectors can use.
+var vectorNetworks = []*chaincfg.Params{
+	&chaincfg.MainNetParams,
+	&chaincfg.RegressionNetParams,
+	&chaincfg.TestNet3Params,
+	&chaincfg.SimNetParams,
+	&chaincfg.SigNetParams,
+}
+
+// vectorParams returns the parameters of the network with the passed name.
+func vectorParams(network string) (*chaincfg.Params, error) {
+	for _, params := range vectorNetworks {
+		if params.Name == network {
+			return params, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown network %q", network)
+}
+
+// vectorBits returns the difficulty bits of the blocks of the validation
+// vectors of the network with the passed parameters.
+func vectorBits(params *chaincfg.Params) uint32 {
+	return BigToCompact(params.PowLimit)
+}
+
+// vectorAncestor returns the synthetic ancestor of a validation vector block
+// whose parent is the passed node.
+func vectorAncestor(parent *blockNode, params *chaincfg.Params) *blockNode {
+	timestamp := time.Unix(parent.timestamp, 0).Add(params.TargetTimePerBlock)
+	return newFakeNode(parent, 1, vectorBits(params), timestamp)
+}
+
+// vectorErrorString returns the representation of the passed validation error
+// in a validation vector.
+func vectorErrorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	if rerr, ok := err.(RuleError); ok {
+		return rerr.ErrorCode.String()
+	}
+	return err.Error()
+}
+
+// replayValidationVector checks the block of the passed validation vector and
+// returns the sanity and connect errors as represented in the vectors.
+func replayValidationVector(name string, v *validationVector) (string, string, error) {
+	params, err := vectorParams(v.Network)
+	if err != nil {
+		return "", "", err
+	}
+	if v.Height < 1 {
+		return "", "", fmt.Errorf("invalid height %d", v.Height)
+	}
+	serialized, err := hex.DecodeString(v.Block)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid block: %v", err)
+	}
+	block, err := czzutil.NewBlockFromBytes(serialized)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid block: %v", err)
+	}
+	block.SetHeight(v.Height)
+
+	view := NewUtxoViewpoint()
+	for _, utxo := range v.Utxos {
+		hash, err := chainhash.NewHashFromStr(utxo.Txid)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid utxo txid: %v", err)
+		}
+		pkScript, err := hex.DecodeString(utxo.PkScript)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid utxo script: %v", err)
+		}
+		view.addTxOut(wire.OutPoint{Hash: *hash, Index: utxo.Vout},
+			wire.NewTxOut(utxo.Amount, pkScript), utxo.Coinbase, false,
+			utxo.Height)
+	}
+
+	chain, teardown, err := chainSetup("vector"+name, params)
+	if err != nil {
+		return "", "", err
+	}
+	defer teardown()
+
+	err = checkBlockSanity(chain, block, chain.chainParams.PowLimit,
+		chain.timeSource, BFNoPoWCheck|BFMagneticAnomaly)
+	if err != nil {
+		return vectorErrorString(err), "", nil
+	}
+
+	parent := chain.bestChain.Tip()
+	for parent.height < v.Height-1 {
+		parent = vectorAncestor(parent, chain.chainParams)
+	}
+	node := newBlockNode(&block.MsgBlock().Header, parent)
+	var stxos []SpentTxOut
+	err = chain.checkConnectBlock(node, block, view, &stxos, nil)
+	return "", vectorErrorString(err), nil
+}
+
+// vectorBuilder deterministically builds the blocks and utxos of the
+// validation scenarios.
+type vectorBuilder struct {
+	name   string
+	params *chaincfg.Params
+	height int32
+	utxos  []vectorUtxo
+	nonce  uint32
+}
+
+// newVectorBuilder returns a builder of the scenario with the passed name for
+// a block at the passed height.
+func newVectorBuilder(name string, params *chaincfg.Params, height int32) *vectorBuilder {
+	return &vectorBuilder{name: name, params: params, height: height}
+}
+
+// uniqueScript returns a distinct provably-pruneable script, which also pads
+// the transactions of the scenarios to their minimum size.
+func (vb *vectorBuilder) uniqueScript() []byte {
+	vb.nonce++
+	data := chainhash.DoubleHashB([]byte(fmt.Sprintf("%s/%d", vb.name,
+		vb.nonce)))
+	script, err := txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+		AddData(data).AddData(data).Script()
+	if err != nil {
+		panic(err)
+	}
+	return script
+}
+
+// addUtxo adds an unspent output to the utxo set of the scenario and returns
+// its outpoint.
+func (vb *vectorBuilder) addUtxo(amount int64, pkScript []byte, height int32, coinbase bool) wire.OutPoint {
+	hash := chainhash.DoubleHashH(vb.uniqueScript())
+	vb.utxos = append(vb.utxos, vectorUtxo{
+		Txid:     hash.String(),
+		Amount:   amount,
+		PkScript: hex.EncodeToString(pkScript),
+		Height:   height,
+		Coinbase: coinbase,
+	})
+	return wire.OutPoint{Hash: hash}
+}
+
+// coinbase returns a coinbase of the block of the scenario paying the subsidy
+// plus the passed fees.
+func (vb *vectorBuilder) coinbase(fees int64) *wire.MsgTx {
+	sigScript, err := txscript.NewScriptBuilder().
+		AddInt64(int64(vb.height)).AddInt64(0).Script()
+	if err != nil {
+		panic(err)
+	}
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: sigScript,
+		Sequence:        wire.MaxTxInSequenceNum,
+	})
+	subsidy := CalcBlockSubsidy(vb.height, vb.params)
+	tx.AddTxOut(wire.NewTxOut(subsidy+fees, opTrueScript))
+	tx.AddTxOut(wire.NewTxOut(0, vb.uniqueScript()))
+	return tx
+}
+
+// spend returns a transaction spending the passed outpoint with an empty
+// signature script to an output of the passed amount.
+func (vb *vectorBuilder) spend(prevOut wire.OutPoint, amount int64) *wire.MsgTx {
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: prevOut,
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	tx.AddTxOut(wire.NewTxOut(amount, opTrueScript))
+	tx.AddTxOut(wire.NewTxOut(0, vb.uniqueScript()))
+	return tx
+}
+
+// block returns the block of the scenario with the passed transactions.  The
+// transactions after the coinbase are sorted in canonical order unless
+// noSort is set.
+func (vb *vectorBuilder) block(txns []*wire.MsgTx, noSort bool) *wire.MsgBlock {
+	if !noSort {
+		sort.Slice(txns[1:], func(i, j int) bool {
+			hi, hj := txns[i+1].TxHash(), txns[j+1].TxHash()
+			return hi.Compare(&hj) < 0
+		})
+	}
+
+	parent := newBlockNode(&vb.params.GenesisBlock.Header, nil)
+	for parent.height < vb.height-1 {
+		parent = vectorAncestor(parent, vb.params)
+	}
+	msgBlock := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   1,
+			PrevBlock: parent.hash,
+			Timestamp: time.Unix(parent.timestamp, 0).
+				Add(vb.params.TargetTimePerBlock),
+			Bits: vectorBits(vb.params),
+		},
+		Transactions: txns,
+	}
+	utilTxns := make([]*czzutil.Tx, 0, len(txns))
+	for _, tx := range txns {
+		utilTxns = append(utilTxns, czzutil.NewTx(tx))
+	}
+	merkles := BuildMerkleTreeStore(utilTxns)
+	msgBlock.Header.MerkleRoot = *merkles[len(merkles)-1]
+	return msgBlock
+}
+
+// vector returns the validation vector of the scenario with the passed block
+// and expected errors.
+func (vb *vectorBuilder) vector(description string, block *wire.MsgBlock,
+	sanityErr, connectErr ErrorCode) *validationVector {
+
+	var buf bytes.Buffer
+	if err := block.Serialize(&buf); err != nil {
+		panic(err)
+	}
+	v := &validationVector{
+		Description: description,
+		Network:     vb.params.Name,
+		Height:      vb.height,
+		Utxos:       vb.utxos,
+		Block:       hex.EncodeToString(buf.Bytes()),
+	}
+	if v.Utxos == nil {
+		v.Utxos = []vectorUtxo{}
+	}
+	if sanityErr != noVectorError {
+		v.SanityError = sanityErr.String()
+	}
+	if connectErr != noVectorError {
+		v.ConnectError = connectErr.String()
+	}
+	return v
+}
+
+// noVectorError is the error code of the checks expected to succeed.
+const noVectorError = ErrorCode(-1)
+
+// validationScenarios returns the validation vectors generated by the
+// scenarios, by fixture name.  A regression scenario should be added here for
+// every consensus bug found.
+func validationScenarios() map[string]*validationVector {
+	params := &chaincfg.MainNetParams
+	vectors := make(map[string]*validationVector)
+	const amount = 100000
+	const fee = 1000
+
+	vb := newVectorBuilder("valid-spend", params, 1)
+	prevOut := vb.addUtxo(amount, opTrueScript, 0, false)
+	vectors[vb.name] = vb.vector("A block spending a utxo and claiming "+
+		"its fee is valid",
+		vb.block([]*wire.MsgTx{vb.coinbase(fee),
+			vb.spend(prevOut, amount-fee)}, false),
+		noVectorError, noVectorError)
+
+	vb = newVectorBuilder("valid-chained-spends", params, 1)
+	prevOut = vb.addUtxo(amount, opTrueScript, 0, false)
+	parentTx := vb.spend(prevOut, amount-fee)
+	childTx := vb.spend(wire.OutPoint{Hash: parentTx.TxHash()}, amount-2*fee)
+	vectors[vb.name] = vb.vector("A transaction may spend an output of "+
+		"another transaction of the block regardless of their order",
+		vb.block([]*wire.MsgTx{vb.coinbase(2 * fee), parentTx, childTx},
+			false),
+		noVectorError, noVectorError)
+
+	vb = newVectorBuilder("bad-merkle-root", params, 1)
+	block := vb.block([]*wire.MsgTx{vb.coinbase(0)}, false)
+	block.Header.MerkleRoot[0] ^= 0x01
+	vectors[vb.name] = vb.vector("The merkle root of the header must "+
+		"commit to the transactions", block, ErrBadMerkleRoot,
+		noVectorError)
+
+	vb = newVectorBuilder("first-tx-not-coinbase", params, 1)
+	prevOut = vb.addUtxo(amount, opTrueScript, 0, false)
+	vectors[vb.name] = vb.vector("The first transaction of a block must "+
+		"be a coinbase",
+		vb.block([]*wire.MsgTx{vb.spend(prevOut, amount)}, true),
+		ErrFirstTxNotCoinbase, noVectorError)
+
+	vb = newVectorBuilder("noncanonical-order", params, 1)
+	prevOut = vb.addUtxo(amount, opTrueScript, 0, false)
+	prevOut2 := vb.addUtxo(amount, opTrueScript, 0, false)
+	txns := vb.block([]*wire.MsgTx{vb.coinbase(0),
+		vb.spend(prevOut, amount), vb.spend(prevOut2, amount)},
+		false).Transactions
+	txns[1], txns[2] = txns[2], txns[1]
+	vectors[vb.name] = vb.vector("The transactions after the coinbase "+
+		"must be in canonical order", vb.block(txns, true),
+		ErrInvalidTxOrder, noVectorError)
+
+	vb = newVectorBuilder("missing-utxo", params, 1)
+	vectors[vb.name] = vb.vector("A transaction may not spend an output "+
+		"missing from the utxo set",
+		vb.block([]*wire.MsgTx{vb.coinbase(0), vb.spend(wire.OutPoint{
+			Hash: chainhash.DoubleHashH([]byte(vb.name))}, amount)},
+			false),
+		noVectorError, ErrMissingTxOut)
+
+	vb = newVectorBuilder("spend-too-high", params, 1)
+	prevOut = vb.addUtxo(amount, opTrueScript, 0, false)
+	vectors[vb.name] = vb.vector("A transaction may not pay more than "+
+		"the outputs it spends",
+		vb.block([]*wire.MsgTx{vb.coinbase(0),
+			vb.spend(prevOut, amount+1)}, false),
+		noVectorError, ErrSpendTooHigh)
+
+	vb = newVectorBuilder("coinbase-overpays", params, 1)
+	prevOut = vb.addUtxo(amount, opTrueScript, 0, false)
+	vectors[vb.name] = vb.vector("The coinbase may not pay more than the "+
+		"subsidy and the fees of the block",
+		vb.block([]*wire.MsgTx{vb.coinbase(fee + 1),
+			vb.spend(prevOut, amount-fee)}, false),
+		noVectorError, ErrBadCoinbaseValue)
+
+	maturity := int32(params.CoinbaseMaturity)
+	vb = newVectorBuilder("immature-coinbase-spend", params, maturity-1)
+	prevOut = vb.addUtxo(amount, opTrueScript, 0, true)
+	vectors[vb.name] = vb.vector("A coinbase output may not be spent "+
+		"before the coinbase maturity",
+		vb.block([]*wire.MsgTx{vb.coinbase(0),
+			vb.spend(prevOut, amount)}, false),
+		noVectorError, ErrImmatureSpend)
+
+	vb = newVectorBuilder("mature-coinbase-spend", params, maturity)
+	prevOut = vb.addUtxo(amount, opTrueScript, 0, true)
+	vectors[vb.name] = vb.vector("A coinbase output may be spent once "+
+		"the coinbase maturity is reached",
+		vb.block([]*wire.MsgTx{vb.coinbase(0),
+			vb.spend(prevOut, amount)}, false),
+		noVectorError, noVectorError)
+
+	vb = newVectorBuilder("script-failure", params, 1)
+	prevOut = vb.addUtxo(amount, []byte{txscript.OP_FALSE}, 0, false)
+	vectors[vb.name] = vb.vector("A transaction must satisfy the scripts "+
+		"of the outputs it spends",
+		vb.block([]*wire.MsgTx{vb.coinbase(0),
+			vb.spend(prevOut, amount)}, false),
+		noVectorError, ErrScriptValidation)
+
+	return vectors
+}
+
+// marshalVector returns the JSON fixture of the passed validation vector.
+func marshalVector(v *validationVector) ([]byte, error) {
+	serialized, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(serialized, '\n'), nil
+}
+
+// TestValidationVectorsUpToDate ensures the fixtures of the generated validation
+// scenarios match the scenarios, which also ensures they are generated
+// deterministically.  Run the test with -updatevectors to rewrite them.
+func TestValidationVectorsUpToDate(t *testing.T) {
+	for name, v := range validationScenarios() {
+		serialized, err := marshalVector(v)
+		if err != nil {
+			t.Fatalf("%s: unable to marshal vector: %v", name, err)
+		}
+		path := filepath.Join(vectorsDir, name+".json")
+		if *updateVectors {
+			if err := ioutil.WriteFile(path, serialized, 0644); err != nil {
+				t.Fatalf("%s: unable to write vector: %v", name, err)
+			}
+			continue
+		}
+		fixture, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Errorf("%s: unable to read vector (run the test with "+
+				"-updatevectors): %v", name, err)
+			continue
+		}
+		if !bytes.Equal(fixture, serialized) {
+			t.Errorf("%s: vector is out of date (run the test with "+
+				"-updatevectors)", name)
+		}
+	}
+}
+
+// TestValidationVectors replays the validation vector fixtures, including the
+// ones not generated by the scenarios.
+func TestValidationVectors(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join(vectorsDir, "*.json"))
+	if err != nil {
+		t.Fatalf("unable to list vectors: %v", err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no validation vectors")
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		serialized, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: unable to read vector: %v", name, err)
+		}
+		var v validationVector
+		if err := json.Unmarshal(serialized, &v); err != nil {
+			t.Fatalf("%s: unable to decode vector: %v", name, err)
+		}
+
+		sanityErr, connectErr, err := replayValidationVector(name, &v)
+		if err != nil {
+			t.Errorf("%s: unable to replay vector: %v", name, err)
+			continue
+		}
+		if sanityErr != v.SanityError {
+			t.Errorf("%s (%s): got sanity error %q, want %q", name,
+				v.Description, sanityErr, v.SanityError)
+			continue
+		}
+		if v.SanityError == "" && connectErr != v.ConnectError {
+			t.Errorf("%s (%s): got connect error %q, want %q", name,
+				v.Description, connectErr, v.ConnectError)
+		}
+	}
+}
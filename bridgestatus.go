@@ -0,0 +1,309 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/btcjson"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/cross"
+)
+
+const (
+	// bridgeRecentBlocks is the number of most recent blocks the recent
+	// entangles are listed from.
+	bridgeRecentBlocks = 144
+
+	// maxBridgeEntangles is the maximum number of recent entangles served.
+	maxBridgeEntangles = 50
+
+	// bridgeHealthInterval is the interval the states of the external nodes
+	// are cached for, so the nodes are not queried on every request.
+	bridgeHealthInterval = 30 * time.Second
+)
+
+// bridgeChains are the entangle types of the chains served by the bridge
+// status, in the order they are served.
+var bridgeChains = []cross.ExpandedTxType{
+	cross.ExpandedTxEntangle_Doge,
+	cross.ExpandedTxEntangle_Ltc,
+}
+
+// bridgeNode is the state of an external node the entangled outputs of a chain
+// are verified against.
+type bridgeNode struct {
+	Height        int64  `json:"height"`
+	LatencyMillis int64  `json:"latencyms"`
+	Error         string `json:"error,omitempty"`
+}
+
+// bridgeChain is the state of the bridge to a chain coins are entangled from.
+type bridgeChain struct {
+	Chain     string       `json:"chain"`
+	Entangled int64        `json:"entangled"`
+	Rate      float64      `json:"rate"`
+	Verified  uint64       `json:"verified"`
+	Failed    uint64       `json:"failed"`
+	Healthy   bool         `json:"healthy"`
+	Nodes     []bridgeNode `json:"nodes"`
+}
+
+// bridgeStatus is the state of the entangle bridge served as JSON at
+// /api/status and rendered by the status page.
+type bridgeStatus struct {
+	Height          int32                      `json:"height"`
+	Hash            string                     `json:"hash"`
+	Pools           []btcjson.PoolBalance      `json:"pools"`
+	Chains          []bridgeChain              `json:"chains"`
+	RecentEntangles []btcjson.EntangleTxResult `json:"recententangles"`
+}
+
+// bridgeServer serves the state of the entangle bridge as a status page at /
+// and as JSON at /api/status for the dashboards which don't speak JSON-RPC.
+type bridgeServer struct {
+	server     *server
+	listeners  []net.Listener
+	httpServer http.Server
+
+	// mtx protects the states cached between the requests.
+	mtx           sync.Mutex
+	entanglesHash chainhash.Hash
+	entangles     []btcjson.EntangleTxResult
+	clients       []cross.ClientStatus
+	clientsTime   time.Time
+}
+
+// newBridgeServer returns a bridge status server serving the state of the
+// bridge of the passed server on the passed listeners.
+func newBridgeServer(s *server, listeners []net.Listener) *bridgeServer {
+	b := &bridgeServer{
+		server:    s,
+		listeners: listeners,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", b.handlePage)
+	mux.HandleFunc("/api/status", b.handleStatus)
+	b.httpServer.Handler = mux
+	return b
+}
+
+// Start begins serving the bridge status on the listeners of the server.
+func (b *bridgeServer) Start() {
+	for _, listener := range b.listeners {
+		srvrLog.Infof("Bridge status server listening on %s", listener.Addr())
+		go func(listener net.Listener) {
+			err := b.httpServer.Serve(listener)
+			if err != http.ErrServerClosed {
+				srvrLog.Errorf("Bridge status server stopped serving "+
+					"on %s: %v", listener.Addr(), err)
+			}
+		}(listener)
+	}
+}
+
+// Stop closes the listeners and the connections of the server.
+func (b *bridgeServer) Stop() {
+	b.httpServer.Close()
+}
+
+// handleStatus serves the state of the bridge as JSON.
+func (b *bridgeServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := b.status()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(status)
+}
+
+// handlePage serves the status page of the bridge.
+func (b *bridgeServer) handlePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	status := b.status()
+	var buf bytes.Buffer
+	if err := bridgePageTemplate.Execute(&buf, status); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// status returns the current state of the bridge.  The parts which can't be
+// read from the best block, such as the pools of a chain without the pool
+// outputs yet, are served empty so the rest remains available.
+func (b *bridgeServer) status() *bridgeStatus {
+	chain := b.server.chain
+	best := chain.BestSnapshot()
+	_, pools, err := poolBalances(chain, b.server.chainParams)
+	if err != nil {
+		srvrLog.Debugf("Unable to compute the pool balances: %v", err)
+		pools = make([]btcjson.PoolBalance, 0)
+	}
+
+	// The entangled amounts are only committed in the coinbase from the
+	// entangle height, nothing was entangled before.
+	keepedAmount, err := bestKeepedAmount(chain)
+	if err != nil {
+		srvrLog.Debugf("Unable to read the entangled amounts: %v", err)
+		keepedAmount = &cross.KeepedAmount{}
+	}
+
+	ev := chain.GetEntangleVerify()
+	var stats map[cross.ExpandedTxType]cross.VerifyStats
+	var clients []cross.ClientStatus
+	if ev != nil {
+		stats = ev.Stats()
+		clients = b.clientStatuses(ev)
+	}
+
+	return &bridgeStatus{
+		Height:          best.Height,
+		Hash:            best.Hash.String(),
+		Pools:           pools,
+		Chains:          bridgeChainStates(keepedAmount, stats, clients),
+		RecentEntangles: b.recentEntangles(),
+	}
+}
+
+// clientStatuses returns the states of the external nodes, querying them again
+// once the cached states are older than bridgeHealthInterval.
+func (b *bridgeServer) clientStatuses(ev *cross.EntangleVerify) []cross.ClientStatus {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if time.Since(b.clientsTime) >= bridgeHealthInterval {
+		b.clients = ev.ClientStatuses()
+		b.clientsTime = time.Now()
+	}
+	return b.clients
+}
+
+// recentEntangles returns the most recent entangles of the main chain, newest
+// first, listing them again once the best block changed.
+func (b *bridgeServer) recentEntangles() []btcjson.EntangleTxResult {
+	chain := b.server.chain
+	best := chain.BestSnapshot()
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.entangles != nil && b.entanglesHash == best.Hash {
+		return b.entangles
+	}
+	entangles := make([]btcjson.EntangleTxResult, 0)
+	for height := best.Height; height > 0 &&
+		height > best.Height-bridgeRecentBlocks; height-- {
+
+		block, err := chain.BlockByHeight(height)
+		if err != nil {
+			srvrLog.Debugf("Unable to fetch block %d: %v", height, err)
+			break
+		}
+		txs := blockEntangleTxs(block, height)
+		for i := len(txs) - 1; i >= 0; i-- {
+			entangles = append(entangles, txs[i])
+		}
+		if len(entangles) >= maxBridgeEntangles {
+			entangles = entangles[:maxBridgeEntangles]
+			break
+		}
+	}
+	b.entangles = entangles
+	b.entanglesHash = best.Hash
+	return entangles
+}
+
+// bridgeChainStates returns the states of the bridges to the chains coins are
+// entangled from given the entangled amounts, the statistics of the
+// verifications and the states of the external nodes.
+func bridgeChainStates(keepedAmount *cross.KeepedAmount,
+	stats map[cross.ExpandedTxType]cross.VerifyStats,
+	clients []cross.ClientStatus) []bridgeChain {
+
+	chains := make([]bridgeChain, 0, len(bridgeChains))
+	for _, exTxType := range bridgeChains {
+		entangled := keepedAmount.GetValue(exTxType)
+		rate, _ := cross.ConversionRate(exTxType, entangled)
+		c := bridgeChain{
+			Chain:    exTxType.String(),
+			Rate:     rate,
+			Verified: stats[exTxType].Verified,
+			Failed:   stats[exTxType].Failed,
+			Nodes:    make([]bridgeNode, 0),
+		}
+		if entangled != nil {
+			c.Entangled = entangled.Int64()
+		}
+		for _, client := range clients {
+			if client.ExTxType != exTxType {
+				continue
+			}
+			node := bridgeNode{
+				Height:        client.Height,
+				LatencyMillis: int64(client.Latency / time.Millisecond),
+			}
+			if client.Err != nil {
+				node.Error = client.Err.Error()
+			} else {
+				c.Healthy = true
+			}
+			c.Nodes = append(c.Nodes, node)
+		}
+		chains = append(chains, c)
+	}
+	return chains
+}
+
+// bridgePageTemplate is the status page of the bridge.
+var bridgePageTemplate = template.Must(template.New("bridge").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<title>Entangle bridge status</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; }
+.ok { color: #080; }
+.down { color: #c00; }
+</style>
+</head>
+<body>
+<h1>Entangle bridge status</h1>
+<p>Best block {{.Height}} ({{.Hash}})</p>
+
+<h2>Coin pools</h2>
+<table>
+<tr><th>Address</th><th>Balance (CZZ)</th></tr>
+{{range .Pools}}<tr><td>{{.Address}}</td><td>{{.Amount}}</td></tr>
+{{end}}</table>
+
+<h2>Chains</h2>
+<table>
+<tr><th>Chain</th><th>Entangled</th><th>Rate (per CZZ)</th><th>Verified</th><th>Failed</th><th>Nodes</th></tr>
+{{range .Chains}}<tr><td>{{.Chain}}</td><td>{{.Entangled}}</td><td>{{.Rate}}</td><td>{{.Verified}}</td><td>{{.Failed}}</td><td>
+{{range .Nodes}}{{if .Error}}<span class="down">{{.Error}}</span>{{else}}<span class="ok">height {{.Height}} ({{.LatencyMillis}} ms)</span>{{end}}<br>
+{{else}}none{{end}}</td></tr>
+{{end}}</table>
+
+<h2>Recent entangles</h2>
+<table>
+<tr><th>Height</th><th>Transaction</th><th>Chain</th><th>External transaction</th><th>Amount</th></tr>
+{{range .RecentEntangles}}<tr><td>{{.Height}}</td><td>{{.TxID}}:{{.Vout}}</td><td>{{.ExTxType}}</td><td>{{.ExtTxHash}}:{{.ExtTxIndex}}</td><td>{{.Amount}}</td></tr>
+{{else}}<tr><td colspan="5">No entangles in the last blocks</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,96 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/btcjson"
+	"github.com/bourbaki-czz/classzz/cross"
+)
+
+// TestBridgeChainStates ensures the states of the bridges to every chain are
+// built from the entangled amounts, the verification statistics and the states
+// of the external nodes of that chain.
+func TestBridgeChainStates(t *testing.T) {
+	keepedAmount := &cross.KeepedAmount{
+		Items: []cross.KeepedItem{{
+			ExTxType: cross.ExpandedTxEntangle_Doge,
+			Amount:   big.NewInt(25e15),
+		}},
+	}
+	stats := map[cross.ExpandedTxType]cross.VerifyStats{
+		cross.ExpandedTxEntangle_Doge: {Verified: 3, Failed: 1},
+	}
+	clients := []cross.ClientStatus{
+		{ExTxType: cross.ExpandedTxEntangle_Doge, Height: 100,
+			Latency: 15 * time.Millisecond},
+		{ExTxType: cross.ExpandedTxEntangle_Doge,
+			Err: errors.New("connection refused")},
+		{ExTxType: cross.ExpandedTxEntangle_Ltc,
+			Err: errors.New("connection refused")},
+	}
+
+	want := []bridgeChain{{
+		Chain:     "doge",
+		Entangled: 25e15,
+		Rate:      27,
+		Verified:  3,
+		Failed:    1,
+		Healthy:   true,
+		Nodes: []bridgeNode{
+			{Height: 100, LatencyMillis: 15},
+			{Error: "connection refused"},
+		},
+	}, {
+		Chain: "ltc",
+		Rate:  0.0008,
+		Nodes: []bridgeNode{{Error: "connection refused"}},
+	}}
+	got := bridgeChainStates(keepedAmount, stats, clients)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected chain states -- got %+v, want %+v", got, want)
+	}
+}
+
+// TestBridgePage ensures the status page renders the state of the bridge with
+// the values supplied by the transactions escaped.
+func TestBridgePage(t *testing.T) {
+	status := &bridgeStatus{
+		Height: 10,
+		Hash:   "00ff",
+		Pools:  []btcjson.PoolBalance{{Address: "cpool", Amount: 1.5}},
+		Chains: bridgeChainStates(&cross.KeepedAmount{}, nil, nil),
+		RecentEntangles: []btcjson.EntangleTxResult{{
+			TxID:      "abcd",
+			Height:    9,
+			ExTxType:  "doge",
+			ExtTxHash: "<script>",
+			Amount:    42,
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := bridgePageTemplate.Execute(&buf, status); err != nil {
+		t.Fatalf("unable to render the status page: %v", err)
+	}
+	page := buf.String()
+	for _, want := range []string{"Best block 10 (00ff)", "cpool", "abcd:0",
+		"&lt;script&gt;", "<td>ltc</td>"} {
+
+		if !strings.Contains(page, want) {
+			t.Errorf("status page does not contain %q", want)
+		}
+	}
+	if strings.Contains(page, "<script>") {
+		t.Errorf("status page contains unescaped transaction data")
+	}
+}
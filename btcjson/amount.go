@@ -0,0 +1,196 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcjson
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/bourbaki-czz/czzutil"
+)
+
+var (
+	// ErrAmountSyntax describes an error in which a string is not a
+	// plain decimal number.
+	ErrAmountSyntax = errors.New("invalid amount syntax")
+
+	// ErrAmountPrecision describes an error in which a string has more
+	// decimal places than the unit it is denominated in allows, so it
+	// can not be represented as an amount without rounding.
+	ErrAmountPrecision = errors.New("amount has too many decimal places")
+
+	// ErrAmountRange describes an error in which a string denotes an
+	// amount which does not fit in an amount.
+	ErrAmountRange = errors.New("amount out of range")
+)
+
+// Amount is a czzutil.Amount which marshals to and unmarshals from a JSON
+// number denominated in CZZ.  Unlike marshaling the result of ToCZZ and
+// converting unmarshaled floating point numbers with czzutil.NewAmount, the
+// number is formatted and parsed exactly, so no precision is lost.
+type Amount czzutil.Amount
+
+// MarshalJSON marshals the amount as a JSON number denominated in CZZ with
+// eight decimal places.  The number never uses an exponent.
+//
+// This is part of the json.Marshaler interface implementation.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	v := int64(a)
+	var sign string
+	var abs uint64
+	if v < 0 {
+		sign = "-"
+		abs = uint64(-(v + 1)) + 1
+	} else {
+		abs = uint64(v)
+	}
+	frac := strconv.FormatUint(abs%czzutil.SatoshiPerBitcoin, 10)
+	return []byte(sign + strconv.FormatUint(abs/czzutil.SatoshiPerBitcoin, 10) +
+		"." + strings.Repeat("0", 8-len(frac)) + frac), nil
+}
+
+// UnmarshalJSON unmarshals a JSON number denominated in CZZ into the amount.
+// The number is parsed exactly like ParseAmount does, except that exponents
+// are accepted since they are part of the JSON number syntax.
+//
+// This is part of the json.Unmarshaler interface implementation.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	// JSON null leaves the amount unchanged by convention.
+	if string(data) == "null" {
+		return nil
+	}
+	v, err := parseDecimal(string(data), 8, true)
+	if err != nil {
+		return err
+	}
+	*a = Amount(v)
+	return nil
+}
+
+// ParseAmountUnit returns the unit described by the passed string, which is
+// the string returned by String for the recognized units.  Since "μ" is hard
+// to type, "uCZZ" is accepted for czzutil.AmountMicroCZZ as well, and
+// "satoshi" for czzutil.AmountSatoshi.
+func ParseAmountUnit(s string) (czzutil.AmountUnit, error) {
+	switch s {
+	case "MCZZ":
+		return czzutil.AmountMegaCZZ, nil
+	case "kCZZ":
+		return czzutil.AmountKiloCZZ, nil
+	case "CZZ":
+		return czzutil.AmountCZZ, nil
+	case "mCZZ":
+		return czzutil.AmountMilliCZZ, nil
+	case "μCZZ", "uCZZ":
+		return czzutil.AmountMicroCZZ, nil
+	case "Satoshi", "satoshi":
+		return czzutil.AmountSatoshi, nil
+	}
+	return 0, errors.New("unknown amount unit " + strconv.Quote(s))
+}
+
+// ParseAmount parses the passed decimal number denominated in the passed unit
+// into an amount.  Unlike converting the result of strconv.ParseFloat with
+// czzutil.NewAmount, the number is parsed exactly and strictly: it must
+// consist of an optional minus sign, at least one digit and an optional
+// fractional part, so exponents, digit grouping, decimal commas and
+// surrounding white space are rejected regardless of the locale.  Numbers with
+// more decimal places than the unit allows or which do not fit in an amount
+// are rejected as well.
+func ParseAmount(s string, u czzutil.AmountUnit) (czzutil.Amount, error) {
+	decimals := int(u) + 8
+	if decimals < 0 {
+		return 0, errors.New("amounts can not be denominated in units " +
+			"smaller than a satoshi")
+	}
+	v, err := parseDecimal(s, decimals, false)
+	if err != nil {
+		return 0, err
+	}
+	return czzutil.Amount(v), nil
+}
+
+// isDigits returns whether the passed string only consists of decimal digits.
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseDecimal parses the passed decimal number into an integer counting units
+// of 10^-decimals without going through a floating point value.  The exponent
+// of the JSON number syntax is only accepted when exponent is set.
+func parseDecimal(s string, decimals int, exponent bool) (int64, error) {
+	negative := strings.HasPrefix(s, "-")
+	if negative {
+		s = s[1:]
+	}
+
+	// Split off the exponent.  Exponents far beyond the range of an
+	// amount are rejected before they are applied.
+	var exp int
+	if i := strings.IndexAny(s, "eE"); exponent && i >= 0 {
+		var err error
+		exp, err = strconv.Atoi(s[i+1:])
+		if err != nil {
+			return 0, ErrAmountSyntax
+		}
+		if exp < -100 || exp > 100 {
+			return 0, ErrAmountRange
+		}
+		s = s[:i]
+	}
+
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+		if fracPart == "" {
+			return 0, ErrAmountSyntax
+		}
+	}
+	if intPart == "" || !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, ErrAmountSyntax
+	}
+
+	// The number is the digits times 10^scale units.  Digits below the
+	// unit may only be zeros.
+	digits := intPart + fracPart
+	scale := decimals + exp - len(fracPart)
+	if scale < 0 {
+		cut := len(digits) + scale
+		if cut < 0 {
+			cut = 0
+		}
+		if strings.TrimRight(digits[cut:], "0") != "" {
+			return 0, ErrAmountPrecision
+		}
+		digits, scale = digits[:cut], 0
+	}
+
+	var v int64
+	for i := 0; i < len(digits); i++ {
+		d := int64(digits[i] - '0')
+		if v > (math.MaxInt64-d)/10 {
+			return 0, ErrAmountRange
+		}
+		v = v*10 + d
+	}
+	for i := 0; i < scale && v != 0; i++ {
+		if v > math.MaxInt64/10 {
+			return 0, ErrAmountRange
+		}
+		v *= 10
+	}
+
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
@@ -0,0 +1,295 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcjson_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/btcjson"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// TestParseAmount ensures decimal numbers are parsed exactly in all units and
+// that numbers which are malformed, too precise or out of range are rejected.
+func TestParseAmount(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		s    string
+		unit czzutil.AmountUnit
+		want czzutil.Amount
+		err  error
+	}{
+		// Exact values.
+		{name: "one", s: "1", unit: czzutil.AmountCZZ, want: 1e8},
+		{name: "zero", s: "0", unit: czzutil.AmountCZZ, want: 0},
+		{name: "satoshi", s: "0.00000001", unit: czzutil.AmountCZZ, want: 1},
+		{name: "tenth", s: "0.1", unit: czzutil.AmountCZZ, want: 1e7},
+		{name: "max satoshi", s: "21000000", unit: czzutil.AmountCZZ,
+			want: czzutil.MaxSatoshi},
+		{name: "leading zeros", s: "007.5", unit: czzutil.AmountCZZ,
+			want: 75e7},
+		{name: "trailing zeros", s: "1.10000000", unit: czzutil.AmountCZZ,
+			want: 11e7},
+		{name: "trailing zeros below a satoshi", s: "0.0000000100",
+			unit: czzutil.AmountCZZ, want: 1},
+		{name: "MCZZ", s: "1.5", unit: czzutil.AmountMegaCZZ, want: 15e13},
+		{name: "kCZZ", s: "0.001", unit: czzutil.AmountKiloCZZ, want: 1e8},
+		{name: "mCZZ", s: "12.5", unit: czzutil.AmountMilliCZZ,
+			want: 125e4},
+		{name: "μCZZ", s: "0.01", unit: czzutil.AmountMicroCZZ, want: 1},
+		{name: "satoshi unit", s: "42", unit: czzutil.AmountSatoshi,
+			want: 42},
+		{name: "satoshi unit with zero fraction", s: "42.0",
+			unit: czzutil.AmountSatoshi, want: 42},
+		{name: "max amount", s: "92233.72036854775807",
+			unit: czzutil.AmountMegaCZZ, want: math.MaxInt64},
+
+		// Negative values.
+		{name: "negative", s: "-1.5", unit: czzutil.AmountCZZ,
+			want: -15e7},
+		{name: "negative satoshi", s: "-0.00000001",
+			unit: czzutil.AmountCZZ, want: -1},
+		{name: "negative zero", s: "-0", unit: czzutil.AmountCZZ, want: 0},
+		{name: "min amount", s: "-92233720368.54775807",
+			unit: czzutil.AmountCZZ, want: -math.MaxInt64},
+
+		// Precision and range.
+		{name: "below a satoshi", s: "0.000000001",
+			unit: czzutil.AmountCZZ, err: btcjson.ErrAmountPrecision},
+		{name: "nine decimals", s: "1.123456789",
+			unit: czzutil.AmountCZZ, err: btcjson.ErrAmountPrecision},
+		{name: "fraction of a satoshi", s: "1.5",
+			unit: czzutil.AmountSatoshi, err: btcjson.ErrAmountPrecision},
+		{name: "negative below a satoshi", s: "-0.000000015",
+			unit: czzutil.AmountCZZ, err: btcjson.ErrAmountPrecision},
+		{name: "above max amount", s: "92233.72036854775808",
+			unit: czzutil.AmountMegaCZZ, err: btcjson.ErrAmountRange},
+		{name: "far above max amount", s: "100000000000",
+			unit: czzutil.AmountCZZ, err: btcjson.ErrAmountRange},
+		{name: "below min amount", s: "-92233720368.54775808",
+			unit: czzutil.AmountCZZ, err: btcjson.ErrAmountRange},
+
+		// Malformed strings.
+		{name: "empty", s: "", unit: czzutil.AmountCZZ,
+			err: btcjson.ErrAmountSyntax},
+		{name: "sign only", s: "-", unit: czzutil.AmountCZZ,
+			err: btcjson.ErrAmountSyntax},
+		{name: "plus sign", s: "+1", unit: czzutil.AmountCZZ,
+			err: btcjson.ErrAmountSyntax},
+		{name: "double minus", s: "--1", unit: czzutil.AmountCZZ,
+			err: btcjson.ErrAmountSyntax},
+		{name: "leading space", s: " 1", unit: czzutil.AmountCZZ,
+			err: btcjson.ErrAmountSyntax},
+		{name: "trailing newline", s: "1\n", unit: czzutil.AmountCZZ,
+			err: btcjson.ErrAmountSyntax},
+		{name: "decimal comma", s: "1,5", unit: czzutil.AmountCZZ,
+			err: btcjson.ErrAmountSyntax},
+		{name: "digit grouping", s: "1,000.5", unit: czzutil.AmountCZZ,
+			err: btcjson.ErrAmountSyntax},
+		{name: "trailing point", s: "1.", unit: czzutil.AmountCZZ,
+			err: btcjson.ErrAmountSyntax},
+		{name: "leading point", s: ".5", unit: czzutil.AmountCZZ,
+			err: btcjson.ErrAmountSyntax},
+		{name: "two points", s: "1.2.3", unit: czzutil.AmountCZZ,
+			err: btcjson.ErrAmountSyntax},
+		{name: "exponent", s: "1e8", unit: czzutil.AmountCZZ,
+			err: btcjson.ErrAmountSyntax},
+		{name: "hex", s: "0x10", unit: czzutil.AmountCZZ,
+			err: btcjson.ErrAmountSyntax},
+		{name: "NaN", s: "NaN", unit: czzutil.AmountCZZ,
+			err: btcjson.ErrAmountSyntax},
+		{name: "infinity", s: "Inf", unit: czzutil.AmountCZZ,
+			err: btcjson.ErrAmountSyntax},
+		{name: "non-ASCII digit", s: "١", unit: czzutil.AmountCZZ,
+			err: btcjson.ErrAmountSyntax},
+	}
+
+	for _, test := range tests {
+		got, err := btcjson.ParseAmount(test.s, test.unit)
+		if err != test.err {
+			t.Errorf("%s: got error %v, want %v", test.name, err,
+				test.err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, got, test.want)
+		}
+	}
+
+	// Units smaller than a satoshi can not be parsed.
+	_, err := btcjson.ParseAmount("1", czzutil.AmountSatoshi-1)
+	if err == nil {
+		t.Error("ParseAmount: accepted a unit smaller than a satoshi")
+	}
+}
+
+// TestParseAmountUnit ensures the strings of the recognized units and their
+// aliases are parsed and that unknown units are rejected.
+func TestParseAmountUnit(t *testing.T) {
+	t.Parallel()
+
+	units := []czzutil.AmountUnit{
+		czzutil.AmountMegaCZZ,
+		czzutil.AmountKiloCZZ,
+		czzutil.AmountCZZ,
+		czzutil.AmountMilliCZZ,
+		czzutil.AmountMicroCZZ,
+		czzutil.AmountSatoshi,
+	}
+	for _, unit := range units {
+		got, err := btcjson.ParseAmountUnit(unit.String())
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", unit, err)
+			continue
+		}
+		if got != unit {
+			t.Errorf("%s: got unit %s", unit, got)
+		}
+	}
+
+	aliases := map[string]czzutil.AmountUnit{
+		"uCZZ":    czzutil.AmountMicroCZZ,
+		"satoshi": czzutil.AmountSatoshi,
+	}
+	for s, unit := range aliases {
+		got, err := btcjson.ParseAmountUnit(s)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", s, err)
+			continue
+		}
+		if got != unit {
+			t.Errorf("%s: got unit %s, want %s", s, got, unit)
+		}
+	}
+
+	for _, s := range []string{"", "czz", "BTC", "1e-2 CZZ", " CZZ"} {
+		if _, err := btcjson.ParseAmountUnit(s); err == nil {
+			t.Errorf("%q: parsed an unknown unit", s)
+		}
+	}
+}
+
+// TestAmountMarshalJSON ensures amounts are formatted as exact JSON numbers in
+// CZZ with eight decimal places and unmarshal back to the same amount.
+func TestAmountMarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		amount czzutil.Amount
+		want   string
+	}{
+		{amount: 0, want: "0.00000000"},
+		{amount: 1, want: "0.00000001"},
+		{amount: -1, want: "-0.00000001"},
+		{amount: 1e8, want: "1.00000000"},
+		{amount: 123456789, want: "1.23456789"},
+		{amount: -150000000, want: "-1.50000000"},
+		{amount: czzutil.MaxSatoshi, want: "21000000.00000000"},
+
+		// Amounts which are not exactly representable as a float64 in
+		// CZZ are formatted exactly.
+		{amount: 2099999999999999, want: "20999999.99999999"},
+		{amount: math.MaxInt64, want: "92233720368.54775807"},
+		{amount: math.MinInt64, want: "-92233720368.54775808"},
+	}
+
+	for _, test := range tests {
+		got, err := json.Marshal(btcjson.Amount(test.amount))
+		if err != nil {
+			t.Errorf("%d: unexpected error: %v", test.amount, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%d: got %s, want %s", test.amount, got, test.want)
+			continue
+		}
+
+		// The minimum amount can not be negated, so it can't be
+		// parsed back.
+		if test.amount == math.MinInt64 {
+			continue
+		}
+		var amount btcjson.Amount
+		if err := json.Unmarshal(got, &amount); err != nil {
+			t.Errorf("%d: unexpected error: %v", test.amount, err)
+			continue
+		}
+		if czzutil.Amount(amount) != test.amount {
+			t.Errorf("%s: got %d, want %d", got, amount, test.amount)
+		}
+	}
+
+	// Amounts nested in other values are marshaled the same way.
+	got, err := json.Marshal(map[string]btcjson.Amount{"a": 1})
+	if err != nil {
+		t.Fatalf("Marshal: unexpected error: %v", err)
+	}
+	if want := `{"a":0.00000001}`; string(got) != want {
+		t.Fatalf("Marshal: got %s, want %s", got, want)
+	}
+}
+
+// TestAmountUnmarshalJSON ensures JSON numbers in CZZ, including the ones
+// using an exponent, are parsed exactly and that JSON values which are not
+// numbers, too precise or out of range are rejected.
+func TestAmountUnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		data string
+		want czzutil.Amount
+		err  bool
+	}{
+		{name: "integer", data: "2", want: 2e8},
+		{name: "fraction", data: "0.1", want: 1e7},
+		{name: "negative", data: "-0.5", want: -5e7},
+		{name: "exponent", data: "1.5E2", want: 15e9},
+		{name: "negative exponent", data: "1e-8", want: 1},
+		{name: "explicit positive exponent", data: "1e+2", want: 1e10},
+		{name: "exponent below a satoshi", data: "1e-9", err: true},
+		{name: "below a satoshi", data: "0.000000015", err: true},
+		{name: "out of range", data: "1e12", err: true},
+		{name: "huge exponent", data: "1e400", err: true},
+		{name: "exponent out of range", data: "0e-400", err: true},
+		{name: "missing exponent", data: "1e", err: true},
+		{name: "string", data: `"1"`, err: true},
+		{name: "boolean", data: "true", err: true},
+		{name: "object", data: "{}", err: true},
+	}
+
+	for _, test := range tests {
+		var amount btcjson.Amount
+		err := json.Unmarshal([]byte(test.data), &amount)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: parsed invalid amount %s as %d",
+					test.name, test.data, amount)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if czzutil.Amount(amount) != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, amount,
+				test.want)
+		}
+	}
+
+	// JSON null leaves the amount unchanged.
+	amount := btcjson.Amount(42)
+	if err := json.Unmarshal([]byte("null"), &amount); err != nil {
+		t.Fatalf("null: unexpected error: %v", err)
+	}
+	if amount != 42 {
+		t.Fatalf("null: got %d, want 42", amount)
+	}
+}
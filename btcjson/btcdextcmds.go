@@ -24,6 +24,26 @@ const (
 	NDisconnect NodeSubCmd = "disconnect"
 )
 
+// CaptureProfileCmd defines the captureprofile JSON-RPC command.  This command
+// is not a standard Bitcoin command.  It is an extension for classzz.
+type CaptureProfileCmd struct {
+	Type    string `jsonrpcusage:"\"cpu|trace|heap|allocs|goroutine|threadcreate\""`
+	Seconds *int   `jsonrpcdefault:"30"`
+}
+
+// NewCaptureProfileCmd returns a new CaptureProfileCmd which can be used to
+// issue a captureprofile JSON-RPC command.  The seconds are the duration of
+// the capture of cpu profiles and execution traces.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewCaptureProfileCmd(profileType string, seconds *int) *CaptureProfileCmd {
+	return &CaptureProfileCmd{
+		Type:    profileType,
+		Seconds: seconds,
+	}
+}
+
 // NodeCmd defines the dropnode JSON-RPC command.
 type NodeCmd struct {
 	SubCmd        NodeSubCmd `jsonrpcusage:"\"connect|remove|disconnect\""`
@@ -72,6 +92,21 @@ func NewGenerateCmd(numBlocks uint32) *GenerateCmd {
 	}
 }
 
+// GenerateToAddressCmd defines the generatetoaddress JSON-RPC command.
+type GenerateToAddressCmd struct {
+	NumBlocks uint32
+	Address   string
+}
+
+// NewGenerateToAddressCmd returns a new instance which can be used to issue a
+// generatetoaddress JSON-RPC command.
+func NewGenerateToAddressCmd(numBlocks uint32, address string) *GenerateToAddressCmd {
+	return &GenerateToAddressCmd{
+		NumBlocks: numBlocks,
+		Address:   address,
+	}
+}
+
 // GetBestBlockCmd defines the getbestblock JSON-RPC command.
 type GetBestBlockCmd struct{}
 
@@ -111,6 +146,33 @@ func NewGetHeadersCmd(blockLocators []string, hashStop string) *GetHeadersCmd {
 	}
 }
 
+// ReloadConfigCmd defines the reloadconfig JSON-RPC command.  This command is
+// not a standard Bitcoin command.  It is an extension for classzz.
+type ReloadConfigCmd struct{}
+
+// NewReloadConfigCmd returns a new ReloadConfigCmd which can be used to issue
+// a reloadconfig JSON-RPC command.
+func NewReloadConfigCmd() *ReloadConfigCmd {
+	return &ReloadConfigCmd{}
+}
+
+// SetLogLevelCmd defines the setloglevel JSON-RPC command.  This command is
+// not a standard Bitcoin command.  It is an extension for classzz.
+type SetLogLevelCmd struct {
+	Level     string
+	Subsystem *string
+}
+
+// NewSetLogLevelCmd returns a new SetLogLevelCmd which can be used to issue a
+// setloglevel JSON-RPC command.  The level of all subsystems is changed when
+// the subsystem is nil.
+func NewSetLogLevelCmd(level string, subsystem *string) *SetLogLevelCmd {
+	return &SetLogLevelCmd{
+		Level:     level,
+		Subsystem: subsystem,
+	}
+}
+
 // VersionCmd defines the version JSON-RPC command.
 //
 // NOTE: This is a btcsuite extension ported from
@@ -128,11 +190,15 @@ func init() {
 	// No special flags for commands in this file.
 	flags := UsageFlag(0)
 
+	MustRegisterCmd("captureprofile", (*CaptureProfileCmd)(nil), flags)
 	MustRegisterCmd("debuglevel", (*DebugLevelCmd)(nil), flags)
 	MustRegisterCmd("node", (*NodeCmd)(nil), flags)
 	MustRegisterCmd("generate", (*GenerateCmd)(nil), flags)
+	MustRegisterCmd("generatetoaddress", (*GenerateToAddressCmd)(nil), flags)
 	MustRegisterCmd("getbestblock", (*GetBestBlockCmd)(nil), flags)
 	MustRegisterCmd("getcurrentnet", (*GetCurrentNetCmd)(nil), flags)
 	MustRegisterCmd("getheaders", (*GetHeadersCmd)(nil), flags)
+	MustRegisterCmd("reloadconfig", (*ReloadConfigCmd)(nil), flags)
+	MustRegisterCmd("setloglevel", (*SetLogLevelCmd)(nil), flags)
 	MustRegisterCmd("version", (*VersionCmd)(nil), flags)
 }
@@ -30,6 +30,34 @@ func TestBtcdExtCmds(t *testing.T) {
 		marshalled   string
 		unmarshalled interface{}
 	}{
+		{
+			name: "captureprofile",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("captureprofile", "heap")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCaptureProfileCmd("heap", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"captureprofile","params":["heap"],"id":1}`,
+			unmarshalled: &btcjson.CaptureProfileCmd{
+				Type:    "heap",
+				Seconds: btcjson.Int(30),
+			},
+		},
+		{
+			name: "captureprofile seconds",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("captureprofile", "cpu", 10)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCaptureProfileCmd("cpu", btcjson.Int(10))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"captureprofile","params":["cpu",10],"id":1}`,
+			unmarshalled: &btcjson.CaptureProfileCmd{
+				Type:    "cpu",
+				Seconds: btcjson.Int(10),
+			},
+		},
 		{
 			name: "debuglevel",
 			newCmd: func() (interface{}, error) {
@@ -114,6 +142,20 @@ func TestBtcdExtCmds(t *testing.T) {
 				NumBlocks: 1,
 			},
 		},
+		{
+			name: "generatetoaddress",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generatetoaddress", 1, "1Address")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGenerateToAddressCmd(1, "1Address")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generatetoaddress","params":[1,"1Address"],"id":1}`,
+			unmarshalled: &btcjson.GenerateToAddressCmd{
+				NumBlocks: 1,
+				Address:   "1Address",
+			},
+		},
 		{
 			name: "getbestblock",
 			newCmd: func() (interface{}, error) {
@@ -176,6 +218,44 @@ func TestBtcdExtCmds(t *testing.T) {
 				HashStop: "000000000000000000ba33b33e1fad70b69e234fc24414dd47113bff38f523f7",
 			},
 		},
+		{
+			name: "reloadconfig",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("reloadconfig")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewReloadConfigCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"reloadconfig","params":[],"id":1}`,
+			unmarshalled: &btcjson.ReloadConfigCmd{},
+		},
+		{
+			name: "setloglevel",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setloglevel", "debug")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetLogLevelCmd("debug", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setloglevel","params":["debug"],"id":1}`,
+			unmarshalled: &btcjson.SetLogLevelCmd{
+				Level: "debug",
+			},
+		},
+		{
+			name: "setloglevel subsystem",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setloglevel", "trace", "PEER")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetLogLevelCmd("trace", btcjson.String("PEER"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setloglevel","params":["trace","PEER"],"id":1}`,
+			unmarshalled: &btcjson.SetLogLevelCmd{
+				Level:     "trace",
+				Subsystem: btcjson.String("PEER"),
+			},
+		},
 		{
 			name: "version",
 			newCmd: func() (interface{}, error) {
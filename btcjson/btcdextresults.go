@@ -18,3 +18,11 @@ type VersionResult struct {
 	Prerelease    string `json:"prerelease"`
 	BuildMetadata string `json:"buildmetadata"`
 }
+
+// CaptureProfileResult models the data from the captureprofile command.
+type CaptureProfileResult struct {
+	Type    string `json:"type"`
+	Seconds int    `json:"seconds,omitempty"`
+	Size    int    `json:"size"`
+	Data    string `json:"data"`
+}
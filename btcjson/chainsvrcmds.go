@@ -48,6 +48,24 @@ func NewAddNodeCmd(addr string, subCmd AddNodeSubCmd) *AddNodeCmd {
 	}
 }
 
+// ClearBannedCmd defines the clearbanned JSON-RPC command.
+type ClearBannedCmd struct{}
+
+// NewClearBannedCmd returns a new instance which can be used to issue a
+// clearbanned JSON-RPC command.
+func NewClearBannedCmd() *ClearBannedCmd {
+	return &ClearBannedCmd{}
+}
+
+// CompactDBCmd defines the compactdb JSON-RPC command.
+type CompactDBCmd struct{}
+
+// NewCompactDBCmd returns a new instance which can be used to issue a
+// compactdb JSON-RPC command.
+func NewCompactDBCmd() *CompactDBCmd {
+	return &CompactDBCmd{}
+}
+
 // TransactionInput represents the inputs to a transaction.  Specifically a
 // transaction hash and output number pair.
 type TransactionInput struct {
@@ -120,6 +138,23 @@ func NewCreateRawEntangleTransactionCmd(inputs []TransactionInput, entangleOuts
 	}
 }
 
+// CreateVaultCmd defines the createvault JSON-RPC command.
+type CreateVaultCmd struct {
+	OwnerKey    string
+	RecoveryKey string
+	LockTime    int64
+}
+
+// NewCreateVaultCmd returns a new instance which can be used to issue a
+// createvault JSON-RPC command.
+func NewCreateVaultCmd(ownerKey, recoveryKey string, lockTime int64) *CreateVaultCmd {
+	return &CreateVaultCmd{
+		OwnerKey:    ownerKey,
+		RecoveryKey: recoveryKey,
+		LockTime:    lockTime,
+	}
+}
+
 // DecodeRawTransactionCmd defines the decoderawtransaction JSON-RPC command.
 type DecodeRawTransactionCmd struct {
 	HexTx string
@@ -164,6 +199,28 @@ func NewGetAddedNodeInfoCmd(dns bool, node *string) *GetAddedNodeInfoCmd {
 	}
 }
 
+// GetAddressTxIDsCmd defines the getaddresstxids JSON-RPC command.
+type GetAddressTxIDsCmd struct {
+	Address string
+	Skip    *int  `jsonrpcdefault:"0"`
+	Count   *int  `jsonrpcdefault:"100"`
+	Reverse *bool `jsonrpcdefault:"false"`
+}
+
+// NewGetAddressTxIDsCmd returns a new instance which can be used to issue a
+// getaddresstxids JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetAddressTxIDsCmd(address string, skip, count *int, reverse *bool) *GetAddressTxIDsCmd {
+	return &GetAddressTxIDsCmd{
+		Address: address,
+		Skip:    skip,
+		Count:   count,
+		Reverse: reverse,
+	}
+}
+
 // GetBestBlockHashCmd defines the getbestblockhash JSON-RPC command.
 type GetBestBlockHashCmd struct{}
 
@@ -260,6 +317,10 @@ type TemplateRequest struct {
 	// "proposal".
 	Data   string `json:"data,omitempty"`
 	WorkID string `json:"workid,omitempty"`
+
+	// Verbose proposal results.  When set, a proposal is answered with a
+	// BlockProposalResult instead of the BIP 0023 string.
+	Verbose bool `json:"verbose,omitempty"`
 }
 
 // convertTemplateRequestField potentially converts the provided value as
@@ -325,6 +386,28 @@ func NewGetBlockTemplateCmd(request *TemplateRequest) *GetBlockTemplateCmd {
 	}
 }
 
+// GetBlockTimingsCmd defines the getblocktimings JSON-RPC command.
+type GetBlockTimingsCmd struct{}
+
+// NewGetBlockTimingsCmd returns a new instance which can be used to issue a
+// getblocktimings JSON-RPC command.
+func NewGetBlockTimingsCmd() *GetBlockTimingsCmd {
+	return &GetBlockTimingsCmd{}
+}
+
+// GetBlockTxMetaCmd defines the getblocktxmeta JSON-RPC command.
+type GetBlockTxMetaCmd struct {
+	Hash string
+}
+
+// NewGetBlockTxMetaCmd returns a new instance which can be used to issue a
+// getblocktxmeta JSON-RPC command.
+func NewGetBlockTxMetaCmd(hash string) *GetBlockTxMetaCmd {
+	return &GetBlockTxMetaCmd{
+		Hash: hash,
+	}
+}
+
 // GetCFilterCmd defines the getcfilter JSON-RPC command.
 type GetCFilterCmd struct {
 	Hash       string
@@ -365,6 +448,43 @@ func NewGetChainTipsCmd() *GetChainTipsCmd {
 	return &GetChainTipsCmd{}
 }
 
+// GetChainTxStatsCmd defines the getchaintxstats JSON-RPC command.
+type GetChainTxStatsCmd struct {
+	NBlocks   *int32 `jsonrpcdefault:"2880"`
+	BlockHash *string
+}
+
+// NewGetChainTxStatsCmd returns a new instance which can be used to issue a
+// getchaintxstats JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetChainTxStatsCmd(nBlocks *int32, blockHash *string) *GetChainTxStatsCmd {
+	return &GetChainTxStatsCmd{
+		NBlocks:   nBlocks,
+		BlockHash: blockHash,
+	}
+}
+
+// GetCheckpointCandidatesCmd defines the getcheckpointcandidates JSON-RPC
+// command.
+type GetCheckpointCandidatesCmd struct {
+	Depth    *int32 `jsonrpcdefault:"2016"`
+	Interval *int32 `jsonrpcdefault:"10000"`
+}
+
+// NewGetCheckpointCandidatesCmd returns a new instance which can be used to
+// issue a getcheckpointcandidates JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetCheckpointCandidatesCmd(depth, interval *int32) *GetCheckpointCandidatesCmd {
+	return &GetCheckpointCandidatesCmd{
+		Depth:    depth,
+		Interval: interval,
+	}
+}
+
 // GetConnectionCountCmd defines the getconnectioncount JSON-RPC command.
 type GetConnectionCountCmd struct{}
 
@@ -383,6 +503,35 @@ func NewGetDifficultyCmd() *GetDifficultyCmd {
 	return &GetDifficultyCmd{}
 }
 
+// GetDifficultyHistoryCmd defines the getdifficultyhistory JSON-RPC command.
+type GetDifficultyHistoryCmd struct {
+	StartHeight int32
+	Count       *int32 `jsonrpcdefault:"100"`
+	Window      *int32 `jsonrpcdefault:"120"`
+}
+
+// NewGetDifficultyHistoryCmd returns a new instance which can be used to issue
+// a getdifficultyhistory JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetDifficultyHistoryCmd(startHeight int32, count, window *int32) *GetDifficultyHistoryCmd {
+	return &GetDifficultyHistoryCmd{
+		StartHeight: startHeight,
+		Count:       count,
+		Window:      window,
+	}
+}
+
+// GetDBStatsCmd defines the getdbstats JSON-RPC command.
+type GetDBStatsCmd struct{}
+
+// NewGetDBStatsCmd returns a new instance which can be used to issue a
+// getdbstats JSON-RPC command.
+func NewGetDBStatsCmd() *GetDBStatsCmd {
+	return &GetDBStatsCmd{}
+}
+
 // GetGenerateCmd defines the getgenerate JSON-RPC command.
 type GetGenerateCmd struct{}
 
@@ -401,6 +550,22 @@ func NewGetHashesPerSecCmd() *GetHashesPerSecCmd {
 	return &GetHashesPerSecCmd{}
 }
 
+// GetIndexInfoCmd defines the getindexinfo JSON-RPC command.
+type GetIndexInfoCmd struct {
+	IndexName *string
+}
+
+// NewGetIndexInfoCmd returns a new instance which can be used to issue a
+// getindexinfo JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetIndexInfoCmd(indexName *string) *GetIndexInfoCmd {
+	return &GetIndexInfoCmd{
+		IndexName: indexName,
+	}
+}
+
 // GetInfoCmd defines the getinfo JSON-RPC command.
 type GetInfoCmd struct{}
 
@@ -495,6 +660,15 @@ func NewGetEntangleInfoCmd() *GetEntangleInfoCmd {
 	return &GetEntangleInfoCmd{}
 }
 
+// GetPoolBalanceCmd defines the getpoolbalance JSON-RPC command.
+type GetPoolBalanceCmd struct{}
+
+// NewGetPoolBalanceCmd returns a new instance which can be used to issue a
+// getpoolbalance JSON-RPC command.
+func NewGetPoolBalanceCmd() *GetPoolBalanceCmd {
+	return &GetPoolBalanceCmd{}
+}
+
 // GetRawMempoolCmd defines the getmempool JSON-RPC command.
 type GetRawMempoolCmd struct {
 	Verbose *bool `jsonrpcdefault:"false"`
@@ -532,6 +706,78 @@ func NewGetRawTransactionCmd(txHash string, verbose *int) *GetRawTransactionCmd
 	}
 }
 
+// GetRawTransactionsCmd defines the getrawtransactions JSON-RPC command.
+type GetRawTransactionsCmd struct {
+	Txids []string
+}
+
+// NewGetRawTransactionsCmd returns a new instance which can be used to issue a
+// getrawtransactions JSON-RPC command.
+func NewGetRawTransactionsCmd(txHashes []string) *GetRawTransactionsCmd {
+	return &GetRawTransactionsCmd{
+		Txids: txHashes,
+	}
+}
+
+// GetReserveAttestationsCmd defines the getreserveattestations JSON-RPC
+// command.
+type GetReserveAttestationsCmd struct {
+	Verify *bool `jsonrpcdefault:"false"`
+}
+
+// NewGetReserveAttestationsCmd returns a new instance which can be used to
+// issue a getreserveattestations JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetReserveAttestationsCmd(verify *bool) *GetReserveAttestationsCmd {
+	return &GetReserveAttestationsCmd{
+		Verify: verify,
+	}
+}
+
+// GetRPCInfoCmd defines the getrpcinfo JSON-RPC command.
+type GetRPCInfoCmd struct{}
+
+// NewGetRPCInfoCmd returns a new instance which can be used to issue a
+// getrpcinfo JSON-RPC command.
+func NewGetRPCInfoCmd() *GetRPCInfoCmd {
+	return &GetRPCInfoCmd{}
+}
+
+// GetScriptTypeStatsCmd defines the getscripttypestats JSON-RPC command.
+type GetScriptTypeStatsCmd struct {
+	StartHeight int32
+	EndHeight   *int32
+}
+
+// NewGetScriptTypeStatsCmd returns a new instance which can be used to issue
+// a getscripttypestats JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetScriptTypeStatsCmd(startHeight int32, endHeight *int32) *GetScriptTypeStatsCmd {
+	return &GetScriptTypeStatsCmd{
+		StartHeight: startHeight,
+		EndHeight:   endHeight,
+	}
+}
+
+// GetSpentInfoCmd defines the getspentinfo JSON-RPC command.
+type GetSpentInfoCmd struct {
+	Txid  string
+	Index uint32
+}
+
+// NewGetSpentInfoCmd returns a new instance which can be used to issue a
+// getspentinfo JSON-RPC command.
+func NewGetSpentInfoCmd(txHash string, index uint32) *GetSpentInfoCmd {
+	return &GetSpentInfoCmd{
+		Txid:  txHash,
+		Index: index,
+	}
+}
+
 // GetTxOutCmd defines the gettxout JSON-RPC command.
 type GetTxOutCmd struct {
 	Txid           string
@@ -579,6 +825,43 @@ func NewGetTxOutSetInfoCmd() *GetTxOutSetInfoCmd {
 	return &GetTxOutSetInfoCmd{}
 }
 
+// GetTxReorgRiskCmd defines the gettxreorgrisk JSON-RPC command.
+type GetTxReorgRiskCmd struct {
+	Txid  string
+	Depth *int32 `jsonrpcdefault:"10"`
+}
+
+// NewGetTxReorgRiskCmd returns a new instance which can be used to issue a
+// gettxreorgrisk JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetTxReorgRiskCmd(txHash string, depth *int32) *GetTxReorgRiskCmd {
+	return &GetTxReorgRiskCmd{
+		Txid:  txHash,
+		Depth: depth,
+	}
+}
+
+// GetUtxoReportCmd defines the getutxoreport JSON-RPC command.
+type GetUtxoReportCmd struct {
+	MinReuse *int `jsonrpcdefault:"10"`
+	Count    *int `jsonrpcdefault:"20"`
+}
+
+// NewGetUtxoReportCmd returns a new instance which can be used to issue a
+// getutxoreport JSON-RPC command.  The addresses holding at least minReuse
+// unspent outputs are reported as reused, up to count of them.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetUtxoReportCmd(minReuse, count *int) *GetUtxoReportCmd {
+	return &GetUtxoReportCmd{
+		MinReuse: minReuse,
+		Count:    count,
+	}
+}
+
 // GetWorkCmd defines the getwork JSON-RPC command.
 type GetWorkCmd struct {
 	Data *string
@@ -624,6 +907,33 @@ func NewInvalidateBlockCmd(blockHash string) *InvalidateBlockCmd {
 	}
 }
 
+// ListBannedCmd defines the listbanned JSON-RPC command.
+type ListBannedCmd struct{}
+
+// NewListBannedCmd returns a new instance which can be used to issue a
+// listbanned JSON-RPC command.
+func NewListBannedCmd() *ListBannedCmd {
+	return &ListBannedCmd{}
+}
+
+// ListEntangleTxsCmd defines the listentangletxs JSON-RPC command.
+type ListEntangleTxsCmd struct {
+	StartHeight int32
+	Count       *int32 `jsonrpcdefault:"100"`
+}
+
+// NewListEntangleTxsCmd returns a new instance which can be used to issue a
+// listentangletxs JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewListEntangleTxsCmd(startHeight int32, count *int32) *ListEntangleTxsCmd {
+	return &ListEntangleTxsCmd{
+		StartHeight: startHeight,
+		Count:       count,
+	}
+}
+
 // PingCmd defines the ping JSON-RPC command.
 type PingCmd struct{}
 
@@ -659,6 +969,25 @@ func NewReconsiderBlockCmd(blockHash string) *ReconsiderBlockCmd {
 	}
 }
 
+// ScanTxOutSetCmd defines the scantxoutset JSON-RPC command.
+type ScanTxOutSetCmd struct {
+	Action      string
+	ScanObjects *[]string
+}
+
+// NewScanTxOutSetCmd returns a new instance which can be used to issue a
+// scantxoutset JSON-RPC command.  The action is one of start, abort or status
+// and the scan objects are only used by the start action.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewScanTxOutSetCmd(action string, scanObjects *[]string) *ScanTxOutSetCmd {
+	return &ScanTxOutSetCmd{
+		Action:      action,
+		ScanObjects: scanObjects,
+	}
+}
+
 // SearchRawTransactionsCmd defines the searchrawtransactions JSON-RPC command.
 type SearchRawTransactionsCmd struct {
 	Address     string
@@ -705,6 +1034,43 @@ func NewSendRawTransactionCmd(hexTx string, allowHighFees *bool) *SendRawTransac
 	}
 }
 
+// SetBanSubCmd defines the type used in the setban JSON-RPC command for the
+// sub command field.
+type SetBanSubCmd string
+
+const (
+	// SBAdd indicates the specified subnet should be banned.
+	SBAdd SetBanSubCmd = "add"
+
+	// SBRemove indicates the ban of the specified subnet should be
+	// removed.
+	SBRemove SetBanSubCmd = "remove"
+)
+
+// SetBanCmd defines the setban JSON-RPC command.
+type SetBanCmd struct {
+	SubNet   string
+	SubCmd   SetBanSubCmd `jsonrpcusage:"\"add|remove\""`
+	BanTime  *int64       `jsonrpcdefault:"0"`
+	Absolute *bool        `jsonrpcdefault:"false"`
+}
+
+// NewSetBanCmd returns a new instance which can be used to issue a setban
+// JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewSetBanCmd(subNet string, subCmd SetBanSubCmd, banTime *int64,
+	absolute *bool) *SetBanCmd {
+
+	return &SetBanCmd{
+		SubNet:   subNet,
+		SubCmd:   subCmd,
+		BanTime:  banTime,
+		Absolute: absolute,
+	}
+}
+
 // SetGenerateCmd defines the setgenerate JSON-RPC command.
 type SetGenerateCmd struct {
 	Generate     bool
@@ -772,6 +1138,39 @@ func NewSubmitWorkCmd(Hash string, Nonce uint64) *SubmitWorkCmd {
 	}
 }
 
+// ReserveSignature represents the signature of the reserve attestation
+// message by the custodian of an address provided with a
+// SubmitReserveAttestationCmd command.
+type ReserveSignature struct {
+	Address   string `json:"address"`
+	Signature string `json:"signature"`
+}
+
+// SubmitReserveAttestationCmd defines the submitreserveattestation JSON-RPC
+// command.
+type SubmitReserveAttestationCmd struct {
+	ExTxType    string
+	BlockHeight int64
+	BlockHash   string
+	Signatures  []ReserveSignature
+	OutPoints   []OutPoint
+}
+
+// NewSubmitReserveAttestationCmd returns a new instance which can be used to
+// issue a submitreserveattestation JSON-RPC command.
+func NewSubmitReserveAttestationCmd(exTxType string, blockHeight int64,
+	blockHash string, signatures []ReserveSignature,
+	outPoints []OutPoint) *SubmitReserveAttestationCmd {
+
+	return &SubmitReserveAttestationCmd{
+		ExTxType:    exTxType,
+		BlockHeight: blockHeight,
+		BlockHash:   blockHash,
+		Signatures:  signatures,
+		OutPoints:   outPoints,
+	}
+}
+
 // UptimeCmd defines the uptime JSON-RPC command.
 type UptimeCmd struct{}
 
@@ -811,6 +1210,28 @@ func NewVerifyChainCmd(checkLevel, checkDepth *int32) *VerifyChainCmd {
 	}
 }
 
+// VerifyIndexesCmd defines the verifyindexes JSON-RPC command.
+type VerifyIndexesCmd struct {
+	StartHeight *int32 `jsonrpcdefault:"0"`
+	EndHeight   *int32 `jsonrpcdefault:"-1"` // -1 = best chain tip
+	Step        *int32 `jsonrpcdefault:"1"`
+	Repair      *bool  `jsonrpcdefault:"false"`
+}
+
+// NewVerifyIndexesCmd returns a new instance which can be used to issue a
+// verifyindexes JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewVerifyIndexesCmd(startHeight, endHeight, step *int32, repair *bool) *VerifyIndexesCmd {
+	return &VerifyIndexesCmd{
+		StartHeight: startHeight,
+		EndHeight:   endHeight,
+		Step:        step,
+		Repair:      repair,
+	}
+}
+
 // VerifyMessageCmd defines the verifymessage JSON-RPC command.
 type VerifyMessageCmd struct {
 	Address   string
@@ -846,11 +1267,15 @@ func init() {
 	flags := UsageFlag(0)
 
 	MustRegisterCmd("addnode", (*AddNodeCmd)(nil), flags)
+	MustRegisterCmd("clearbanned", (*ClearBannedCmd)(nil), flags)
+	MustRegisterCmd("compactdb", (*CompactDBCmd)(nil), flags)
 	MustRegisterCmd("createrawtransaction", (*CreateRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("createrawentangletransaction", (*CreateRawEntangleTransactionCmd)(nil), flags)
+	MustRegisterCmd("createvault", (*CreateVaultCmd)(nil), flags)
 	MustRegisterCmd("decoderawtransaction", (*DecodeRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("decodescript", (*DecodeScriptCmd)(nil), flags)
 	MustRegisterCmd("getaddednodeinfo", (*GetAddedNodeInfoCmd)(nil), flags)
+	MustRegisterCmd("getaddresstxids", (*GetAddressTxIDsCmd)(nil), flags)
 	MustRegisterCmd("getbestblockhash", (*GetBestBlockHashCmd)(nil), flags)
 	MustRegisterCmd("getblock", (*GetBlockCmd)(nil), flags)
 	MustRegisterCmd("getblockchaininfo", (*GetBlockChainInfoCmd)(nil), flags)
@@ -858,13 +1283,20 @@ func init() {
 	MustRegisterCmd("getblockhash", (*GetBlockHashCmd)(nil), flags)
 	MustRegisterCmd("getblockheader", (*GetBlockHeaderCmd)(nil), flags)
 	MustRegisterCmd("getblocktemplate", (*GetBlockTemplateCmd)(nil), flags)
+	MustRegisterCmd("getblocktimings", (*GetBlockTimingsCmd)(nil), flags)
+	MustRegisterCmd("getblocktxmeta", (*GetBlockTxMetaCmd)(nil), flags)
 	MustRegisterCmd("getcfilter", (*GetCFilterCmd)(nil), flags)
 	MustRegisterCmd("getcfilterheader", (*GetCFilterHeaderCmd)(nil), flags)
 	MustRegisterCmd("getchaintips", (*GetChainTipsCmd)(nil), flags)
+	MustRegisterCmd("getchaintxstats", (*GetChainTxStatsCmd)(nil), flags)
+	MustRegisterCmd("getcheckpointcandidates", (*GetCheckpointCandidatesCmd)(nil), flags)
 	MustRegisterCmd("getconnectioncount", (*GetConnectionCountCmd)(nil), flags)
+	MustRegisterCmd("getdbstats", (*GetDBStatsCmd)(nil), flags)
 	MustRegisterCmd("getdifficulty", (*GetDifficultyCmd)(nil), flags)
+	MustRegisterCmd("getdifficultyhistory", (*GetDifficultyHistoryCmd)(nil), flags)
 	MustRegisterCmd("getgenerate", (*GetGenerateCmd)(nil), flags)
 	MustRegisterCmd("gethashespersec", (*GetHashesPerSecCmd)(nil), flags)
+	MustRegisterCmd("getindexinfo", (*GetIndexInfoCmd)(nil), flags)
 	MustRegisterCmd("getinfo", (*GetInfoCmd)(nil), flags)
 	MustRegisterCmd("getentangleinfo", (*GetEntangleInfoCmd)(nil), flags)
 	MustRegisterCmd("getmempoolentry", (*GetMempoolEntryCmd)(nil), flags)
@@ -874,26 +1306,40 @@ func init() {
 	MustRegisterCmd("getnettotals", (*GetNetTotalsCmd)(nil), flags)
 	MustRegisterCmd("getnetworkhashps", (*GetNetworkHashPSCmd)(nil), flags)
 	MustRegisterCmd("getpeerinfo", (*GetPeerInfoCmd)(nil), flags)
+	MustRegisterCmd("getpoolbalance", (*GetPoolBalanceCmd)(nil), flags)
 	MustRegisterCmd("getrawmempool", (*GetRawMempoolCmd)(nil), flags)
 	MustRegisterCmd("getrawtransaction", (*GetRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("getrawtransactions", (*GetRawTransactionsCmd)(nil), flags)
+	MustRegisterCmd("getreserveattestations", (*GetReserveAttestationsCmd)(nil), flags)
+	MustRegisterCmd("getrpcinfo", (*GetRPCInfoCmd)(nil), flags)
+	MustRegisterCmd("getscripttypestats", (*GetScriptTypeStatsCmd)(nil), flags)
+	MustRegisterCmd("getspentinfo", (*GetSpentInfoCmd)(nil), flags)
 	MustRegisterCmd("gettxout", (*GetTxOutCmd)(nil), flags)
 	MustRegisterCmd("gettxoutproof", (*GetTxOutProofCmd)(nil), flags)
 	MustRegisterCmd("gettxoutsetinfo", (*GetTxOutSetInfoCmd)(nil), flags)
+	MustRegisterCmd("gettxreorgrisk", (*GetTxReorgRiskCmd)(nil), flags)
+	MustRegisterCmd("getutxoreport", (*GetUtxoReportCmd)(nil), flags)
 	MustRegisterCmd("getwork", (*GetWorkCmd)(nil), flags)
 	MustRegisterCmd("help", (*HelpCmd)(nil), flags)
 	MustRegisterCmd("invalidateblock", (*InvalidateBlockCmd)(nil), flags)
+	MustRegisterCmd("listbanned", (*ListBannedCmd)(nil), flags)
+	MustRegisterCmd("listentangletxs", (*ListEntangleTxsCmd)(nil), flags)
 	MustRegisterCmd("ping", (*PingCmd)(nil), flags)
 	MustRegisterCmd("preciousblock", (*PreciousBlockCmd)(nil), flags)
 	MustRegisterCmd("reconsiderblock", (*ReconsiderBlockCmd)(nil), flags)
+	MustRegisterCmd("scantxoutset", (*ScanTxOutSetCmd)(nil), flags)
 	MustRegisterCmd("searchrawtransactions", (*SearchRawTransactionsCmd)(nil), flags)
 	MustRegisterCmd("sendrawtransaction", (*SendRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("setban", (*SetBanCmd)(nil), flags)
 	MustRegisterCmd("setgenerate", (*SetGenerateCmd)(nil), flags)
 	MustRegisterCmd("stop", (*StopCmd)(nil), flags)
 	MustRegisterCmd("submitblock", (*SubmitBlockCmd)(nil), flags)
+	MustRegisterCmd("submitreserveattestation", (*SubmitReserveAttestationCmd)(nil), flags)
 	MustRegisterCmd("submitwork", (*SubmitWorkCmd)(nil), flags)
 	MustRegisterCmd("uptime", (*UptimeCmd)(nil), flags)
 	MustRegisterCmd("validateaddress", (*ValidateAddressCmd)(nil), flags)
 	MustRegisterCmd("verifychain", (*VerifyChainCmd)(nil), flags)
+	MustRegisterCmd("verifyindexes", (*VerifyIndexesCmd)(nil), flags)
 	MustRegisterCmd("verifymessage", (*VerifyMessageCmd)(nil), flags)
 	MustRegisterCmd("verifytxoutproof", (*VerifyTxOutProofCmd)(nil), flags)
 }
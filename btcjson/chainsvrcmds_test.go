@@ -41,6 +41,28 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"addnode","params":["127.0.0.1","remove"],"id":1}`,
 			unmarshalled: &btcjson.AddNodeCmd{Addr: "127.0.0.1", SubCmd: btcjson.ANRemove},
 		},
+		{
+			name: "clearbanned",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("clearbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewClearBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"clearbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ClearBannedCmd{},
+		},
+		{
+			name: "compactdb",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("compactdb")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCompactDBCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"compactdb","params":[],"id":1}`,
+			unmarshalled: &btcjson.CompactDBCmd{},
+		},
 		{
 			name: "createrawtransaction",
 			newCmd: func() (interface{}, error) {
@@ -81,6 +103,21 @@ func TestChainSvrCmds(t *testing.T) {
 			},
 		},
 
+		{
+			name: "createvault",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("createvault", "02ab", "03cd", 500000)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCreateVaultCmd("02ab", "03cd", 500000)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"createvault","params":["02ab","03cd",500000],"id":1}`,
+			unmarshalled: &btcjson.CreateVaultCmd{
+				OwnerKey:    "02ab",
+				RecoveryKey: "03cd",
+				LockTime:    500000,
+			},
+		},
 		{
 			name: "decoderawtransaction",
 			newCmd: func() (interface{}, error) {
@@ -128,6 +165,39 @@ func TestChainSvrCmds(t *testing.T) {
 				Node: btcjson.String("127.0.0.1"),
 			},
 		},
+		{
+			name: "getaddresstxids",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddresstxids", "1Address")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressTxIDsCmd("1Address", nil, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddresstxids","params":["1Address"],"id":1}`,
+			unmarshalled: &btcjson.GetAddressTxIDsCmd{
+				Address: "1Address",
+				Skip:    btcjson.Int(0),
+				Count:   btcjson.Int(100),
+				Reverse: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "getaddresstxids optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddresstxids", "1Address", 5, 10, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressTxIDsCmd("1Address",
+					btcjson.Int(5), btcjson.Int(10), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddresstxids","params":["1Address",5,10,true],"id":1}`,
+			unmarshalled: &btcjson.GetAddressTxIDsCmd{
+				Address: "1Address",
+				Skip:    btcjson.Int(5),
+				Count:   btcjson.Int(10),
+				Reverse: btcjson.Bool(true),
+			},
+		},
 		{
 			name: "getbestblockhash",
 			newCmd: func() (interface{}, error) {
@@ -329,6 +399,52 @@ func TestChainSvrCmds(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "getblocktemplate optional - verbose proposal",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblocktemplate", `{"mode":"proposal","data":"00","verbose":true}`)
+			},
+			staticCmd: func() interface{} {
+				template := btcjson.TemplateRequest{
+					Mode:    "proposal",
+					Data:    "00",
+					Verbose: true,
+				}
+				return btcjson.NewGetBlockTemplateCmd(&template)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblocktemplate","params":[{"mode":"proposal","data":"00","verbose":true}],"id":1}`,
+			unmarshalled: &btcjson.GetBlockTemplateCmd{
+				Request: &btcjson.TemplateRequest{
+					Mode:    "proposal",
+					Data:    "00",
+					Verbose: true,
+				},
+			},
+		},
+		{
+			name: "getblocktimings",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblocktimings")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockTimingsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getblocktimings","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetBlockTimingsCmd{},
+		},
+		{
+			name: "getblocktxmeta",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblocktxmeta", "123")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockTxMetaCmd("123")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblocktxmeta","params":["123"],"id":1}`,
+			unmarshalled: &btcjson.GetBlockTxMetaCmd{
+				Hash: "123",
+			},
+		},
 		{
 			name: "getcfilter",
 			newCmd: func() (interface{}, error) {
@@ -372,6 +488,64 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getchaintips","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetChainTipsCmd{},
 		},
+		{
+			name: "getchaintxstats",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getchaintxstats")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetChainTxStatsCmd(nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getchaintxstats","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetChainTxStatsCmd{
+				NBlocks:   btcjson.Int32(2880),
+				BlockHash: nil,
+			},
+		},
+		{
+			name: "getchaintxstats optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getchaintxstats", 100, "123")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetChainTxStatsCmd(btcjson.Int32(100),
+					btcjson.String("123"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getchaintxstats","params":[100,"123"],"id":1}`,
+			unmarshalled: &btcjson.GetChainTxStatsCmd{
+				NBlocks:   btcjson.Int32(100),
+				BlockHash: btcjson.String("123"),
+			},
+		},
+		{
+			name: "getcheckpointcandidates",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getcheckpointcandidates")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetCheckpointCandidatesCmd(nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getcheckpointcandidates","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetCheckpointCandidatesCmd{
+				Depth:    btcjson.Int32(2016),
+				Interval: btcjson.Int32(10000),
+			},
+		},
+		{
+			name: "getcheckpointcandidates optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getcheckpointcandidates", 100, 500)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetCheckpointCandidatesCmd(btcjson.Int32(100),
+					btcjson.Int32(500))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getcheckpointcandidates","params":[100,500],"id":1}`,
+			unmarshalled: &btcjson.GetCheckpointCandidatesCmd{
+				Depth:    btcjson.Int32(100),
+				Interval: btcjson.Int32(500),
+			},
+		},
 		{
 			name: "getconnectioncount",
 			newCmd: func() (interface{}, error) {
@@ -394,6 +568,48 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getdifficulty","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetDifficultyCmd{},
 		},
+		{
+			name: "getdifficultyhistory",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getdifficultyhistory", 1000)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetDifficultyHistoryCmd(1000, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getdifficultyhistory","params":[1000],"id":1}`,
+			unmarshalled: &btcjson.GetDifficultyHistoryCmd{
+				StartHeight: 1000,
+				Count:       btcjson.Int32(100),
+				Window:      btcjson.Int32(120),
+			},
+		},
+		{
+			name: "getdifficultyhistory optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getdifficultyhistory", 1000, 10, 30)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetDifficultyHistoryCmd(1000,
+					btcjson.Int32(10), btcjson.Int32(30))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getdifficultyhistory","params":[1000,10,30],"id":1}`,
+			unmarshalled: &btcjson.GetDifficultyHistoryCmd{
+				StartHeight: 1000,
+				Count:       btcjson.Int32(10),
+				Window:      btcjson.Int32(30),
+			},
+		},
+		{
+			name: "getdbstats",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getdbstats")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetDBStatsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getdbstats","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetDBStatsCmd{},
+		},
 		{
 			name: "getgenerate",
 			newCmd: func() (interface{}, error) {
@@ -416,6 +632,32 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"gethashespersec","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetHashesPerSecCmd{},
 		},
+		{
+			name: "getindexinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getindexinfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetIndexInfoCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getindexinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetIndexInfoCmd{
+				IndexName: nil,
+			},
+		},
+		{
+			name: "getindexinfo optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getindexinfo", "transaction index")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetIndexInfoCmd(btcjson.String("transaction index"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getindexinfo","params":["transaction index"],"id":1}`,
+			unmarshalled: &btcjson.GetIndexInfoCmd{
+				IndexName: btcjson.String("transaction index"),
+			},
+		},
 		{
 			name: "getinfo",
 			newCmd: func() (interface{}, error) {
@@ -537,6 +779,17 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getpeerinfo","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetPeerInfoCmd{},
 		},
+		{
+			name: "getpoolbalance",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getpoolbalance")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetPoolBalanceCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getpoolbalance","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetPoolBalanceCmd{},
+		},
 		{
 			name: "getrawmempool",
 			newCmd: func() (interface{}, error) {
@@ -591,6 +844,97 @@ func TestChainSvrCmds(t *testing.T) {
 				Verbose: btcjson.Int(1),
 			},
 		},
+		{
+			name: "getrawtransactions",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getrawtransactions", []string{"123", "456"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetRawTransactionsCmd([]string{"123", "456"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getrawtransactions","params":[["123","456"]],"id":1}`,
+			unmarshalled: &btcjson.GetRawTransactionsCmd{
+				Txids: []string{"123", "456"},
+			},
+		},
+		{
+			name: "getreserveattestations",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getreserveattestations")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetReserveAttestationsCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getreserveattestations","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetReserveAttestationsCmd{
+				Verify: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "getrpcinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getrpcinfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetRPCInfoCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getrpcinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetRPCInfoCmd{},
+		},
+		{
+			name: "getscripttypestats",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getscripttypestats", 100)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetScriptTypeStatsCmd(100, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getscripttypestats","params":[100],"id":1}`,
+			unmarshalled: &btcjson.GetScriptTypeStatsCmd{
+				StartHeight: 100,
+			},
+		},
+		{
+			name: "getscripttypestats optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getscripttypestats", 100, 200)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetScriptTypeStatsCmd(100, btcjson.Int32(200))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getscripttypestats","params":[100,200],"id":1}`,
+			unmarshalled: &btcjson.GetScriptTypeStatsCmd{
+				StartHeight: 100,
+				EndHeight:   btcjson.Int32(200),
+			},
+		},
+		{
+			name: "getreserveattestations optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getreserveattestations", true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetReserveAttestationsCmd(btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getreserveattestations","params":[true],"id":1}`,
+			unmarshalled: &btcjson.GetReserveAttestationsCmd{
+				Verify: btcjson.Bool(true),
+			},
+		},
+		{
+			name: "getspentinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getspentinfo", "123", 1)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetSpentInfoCmd("123", 1)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getspentinfo","params":["123",1],"id":1}`,
+			unmarshalled: &btcjson.GetSpentInfoCmd{
+				Txid:  "123",
+				Index: 1,
+			},
+		},
 		{
 			name: "gettxout",
 			newCmd: func() (interface{}, error) {
@@ -662,6 +1006,62 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"gettxoutsetinfo","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetTxOutSetInfoCmd{},
 		},
+		{
+			name: "gettxreorgrisk",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("gettxreorgrisk", "123")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetTxReorgRiskCmd("123", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"gettxreorgrisk","params":["123"],"id":1}`,
+			unmarshalled: &btcjson.GetTxReorgRiskCmd{
+				Txid:  "123",
+				Depth: btcjson.Int32(10),
+			},
+		},
+		{
+			name: "gettxreorgrisk optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("gettxreorgrisk", "123", 100)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetTxReorgRiskCmd("123", btcjson.Int32(100))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"gettxreorgrisk","params":["123",100],"id":1}`,
+			unmarshalled: &btcjson.GetTxReorgRiskCmd{
+				Txid:  "123",
+				Depth: btcjson.Int32(100),
+			},
+		},
+		{
+			name: "getutxoreport",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getutxoreport")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetUtxoReportCmd(nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getutxoreport","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetUtxoReportCmd{
+				MinReuse: btcjson.Int(10),
+				Count:    btcjson.Int(20),
+			},
+		},
+		{
+			name: "getutxoreport optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getutxoreport", 2, 5)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetUtxoReportCmd(btcjson.Int(2), btcjson.Int(5))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getutxoreport","params":[2,5],"id":1}`,
+			unmarshalled: &btcjson.GetUtxoReportCmd{
+				MinReuse: btcjson.Int(2),
+				Count:    btcjson.Int(5),
+			},
+		},
 		{
 			name: "getwork",
 			newCmd: func() (interface{}, error) {
@@ -727,6 +1127,45 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "listbanned",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"listbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ListBannedCmd{},
+		},
+		{
+			name: "listentangletxs",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listentangletxs", 120000)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListEntangleTxsCmd(120000, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"listentangletxs","params":[120000],"id":1}`,
+			unmarshalled: &btcjson.ListEntangleTxsCmd{
+				StartHeight: 120000,
+				Count:       btcjson.Int32(100),
+			},
+		},
+		{
+			name: "listentangletxs optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listentangletxs", 120000, 10)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListEntangleTxsCmd(120000, btcjson.Int32(10))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"listentangletxs","params":[120000,10],"id":1}`,
+			unmarshalled: &btcjson.ListEntangleTxsCmd{
+				StartHeight: 120000,
+				Count:       btcjson.Int32(10),
+			},
+		},
 		{
 			name: "ping",
 			newCmd: func() (interface{}, error) {
@@ -764,6 +1203,36 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "scantxoutset",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("scantxoutset", "status")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewScanTxOutSetCmd("status", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"scantxoutset","params":["status"],"id":1}`,
+			unmarshalled: &btcjson.ScanTxOutSetCmd{
+				Action:      "status",
+				ScanObjects: nil,
+			},
+		},
+		{
+			name: "scantxoutset optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("scantxoutset", "start",
+					`["addr(1Address)","raw(76a914)"]`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewScanTxOutSetCmd("start",
+					&[]string{"addr(1Address)", "raw(76a914)"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"scantxoutset","params":["start",["addr(1Address)","raw(76a914)"]],"id":1}`,
+			unmarshalled: &btcjson.ScanTxOutSetCmd{
+				Action:      "start",
+				ScanObjects: &[]string{"addr(1Address)", "raw(76a914)"},
+			},
+		},
 		{
 			name: "searchrawtransactions",
 			newCmd: func() (interface{}, error) {
@@ -931,6 +1400,39 @@ func TestChainSvrCmds(t *testing.T) {
 				AllowHighFees: btcjson.Bool(false),
 			},
 		},
+		{
+			name: "setban",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setban", "10.0.0.0/8", btcjson.SBAdd)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("10.0.0.0/8", btcjson.SBAdd, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["10.0.0.0/8","add"],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				SubNet:   "10.0.0.0/8",
+				SubCmd:   btcjson.SBAdd,
+				BanTime:  btcjson.Int64(0),
+				Absolute: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "setban optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setban", "1.2.3.4", btcjson.SBAdd, 1600000000, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("1.2.3.4", btcjson.SBAdd,
+					btcjson.Int64(1600000000), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["1.2.3.4","add",1600000000,true],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				SubNet:   "1.2.3.4",
+				SubCmd:   btcjson.SBAdd,
+				BanTime:  btcjson.Int64(1600000000),
+				Absolute: btcjson.Bool(true),
+			},
+		},
 		{
 			name: "setgenerate",
 			newCmd: func() (interface{}, error) {
@@ -1003,6 +1505,33 @@ func TestChainSvrCmds(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "submitreserveattestation",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("submitreserveattestation", "doge",
+					3000000, "123",
+					`[{"address":"1Address","signature":"c2ln"}]`,
+					`[{"hash":"456","index":1}]`)
+			},
+			staticCmd: func() interface{} {
+				signatures := []btcjson.ReserveSignature{
+					{Address: "1Address", Signature: "c2ln"},
+				}
+				outPoints := []btcjson.OutPoint{{Hash: "456", Index: 1}}
+				return btcjson.NewSubmitReserveAttestationCmd("doge",
+					3000000, "123", signatures, outPoints)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"submitreserveattestation","params":["doge",3000000,"123",[{"address":"1Address","signature":"c2ln"}],[{"hash":"456","index":1}]],"id":1}`,
+			unmarshalled: &btcjson.SubmitReserveAttestationCmd{
+				ExTxType:    "doge",
+				BlockHeight: 3000000,
+				BlockHash:   "123",
+				Signatures: []btcjson.ReserveSignature{
+					{Address: "1Address", Signature: "c2ln"},
+				},
+				OutPoints: []btcjson.OutPoint{{Hash: "456", Index: 1}},
+			},
+		},
 		{
 			name: "uptime",
 			newCmd: func() (interface{}, error) {
@@ -1069,6 +1598,39 @@ func TestChainSvrCmds(t *testing.T) {
 				CheckDepth: btcjson.Int32(500),
 			},
 		},
+		{
+			name: "verifyindexes",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("verifyindexes")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewVerifyIndexesCmd(nil, nil, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"verifyindexes","params":[],"id":1}`,
+			unmarshalled: &btcjson.VerifyIndexesCmd{
+				StartHeight: btcjson.Int32(0),
+				EndHeight:   btcjson.Int32(-1),
+				Step:        btcjson.Int32(1),
+				Repair:      btcjson.Bool(false),
+			},
+		},
+		{
+			name: "verifyindexes optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("verifyindexes", 100, 200, 10, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewVerifyIndexesCmd(btcjson.Int32(100),
+					btcjson.Int32(200), btcjson.Int32(10), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"verifyindexes","params":[100,200,10,true],"id":1}`,
+			unmarshalled: &btcjson.VerifyIndexesCmd{
+				StartHeight: btcjson.Int32(100),
+				EndHeight:   btcjson.Int32(200),
+				Step:        btcjson.Int32(10),
+				Repair:      btcjson.Bool(true),
+			},
+		},
 		{
 			name: "verifymessage",
 			newCmd: func() (interface{}, error) {
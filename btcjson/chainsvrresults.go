@@ -73,12 +73,20 @@ type CreateMultiSigResult struct {
 	RedeemScript string `json:"redeemScript"`
 }
 
+// CreateVaultResult models the data returned from the createvault command.
+type CreateVaultResult struct {
+	Address      string `json:"address"`
+	RedeemScript string `json:"redeemScript"`
+	LockTime     int64  `json:"locktime"`
+}
+
 // DecodeScriptResult models the data returned from the decodescript command.
 type DecodeScriptResult struct {
 	Asm       string   `json:"asm"`
 	ReqSigs   int32    `json:"reqSigs,omitempty"`
 	Type      string   `json:"type"`
 	Addresses []string `json:"addresses,omitempty"`
+	LockTime  int64    `json:"locktime,omitempty"`
 	P2sh      string   `json:"p2sh,omitempty"`
 }
 
@@ -89,6 +97,14 @@ type GetAddedNodeInfoResultAddr struct {
 	Connected string `json:"connected"`
 }
 
+// ListBannedResult models a banned subnet returned by the listbanned command.
+type ListBannedResult struct {
+	Address     string `json:"address"`
+	BanCreated  int64  `json:"ban_created"`
+	BannedUntil int64  `json:"banned_until"`
+	BanReason   string `json:"ban_reason"`
+}
+
 // GetAddedNodeInfoResult models the data from the getaddednodeinfo command.
 type GetAddedNodeInfoResult struct {
 	AddedNode string                        `json:"addednode"`
@@ -96,6 +112,16 @@ type GetAddedNodeInfoResult struct {
 	Addresses *[]GetAddedNodeInfoResultAddr `json:"addresses,omitempty"`
 }
 
+// GetAddressTxIDsResult models a transaction involving an address returned by
+// the getaddresstxids command.
+type GetAddressTxIDsResult struct {
+	TxID      string `json:"txid"`
+	BlockHash string `json:"blockhash,omitempty"`
+	Height    int32  `json:"height"`
+	Funding   bool   `json:"funding"`
+	Spending  bool   `json:"spending"`
+}
+
 // SoftForkDescription describes the current state of a soft-fork which was
 // deployed using a super-majority block signalling.
 type SoftForkDescription struct {
@@ -127,11 +153,13 @@ type GetBlockChainInfoResult struct {
 	MedianTime           int64                               `json:"mediantime"`
 	VerificationProgress float64                             `json:"verificationprogress,omitempty"`
 	SyncHeight           uint64                              `json:"syncheight,omitempty"`
+	SizeOnDisk           uint64                              `json:"size_on_disk"`
 	Pruned               bool                                `json:"pruned"`
 	PruneHeight          int32                               `json:"pruneheight,omitempty"`
 	ChainWork            string                              `json:"chainwork,omitempty"`
 	SoftForks            []*SoftForkDescription              `json:"softforks"`
 	Bip9SoftForks        map[string]*Bip9SoftForkDescription `json:"bip9_softforks"`
+	Sequence             uint64                              `json:"sequence"`
 }
 
 // GetBlockTemplateResultTx models the transactions field of the
@@ -145,6 +173,86 @@ type GetBlockTemplateResultTx struct {
 	Size    int64   `json:"size"`
 }
 
+// BlockTimingsResult models the propagation timings of a block returned as
+// part of the getblocktimings command.  The times are in milliseconds since the
+// unix epoch and are 0 for the stages which were not observed.
+type BlockTimingsResult struct {
+	Hash           string `json:"hash"`
+	Height         int32  `json:"height"`
+	Peer           string `json:"peer,omitempty"`
+	FirstInv       int64  `json:"firstinv"`
+	HeaderReceived int64  `json:"headerreceived"`
+	BlockReceived  int64  `json:"blockreceived"`
+	Validated      int64  `json:"validated"`
+	Announced      int64  `json:"announced"`
+}
+
+// BlockLatencyResult models the cumulated latencies between two stages of the
+// propagation of the blocks returned as part of the getblocktimings command.
+type BlockLatencyResult struct {
+	Name      string  `json:"name"`
+	Count     uint64  `json:"count"`
+	AverageMs float64 `json:"averagems"`
+}
+
+// GetBlockTimingsResult models the data returned from the getblocktimings
+// command.
+type GetBlockTimingsResult struct {
+	Blocks    []BlockTimingsResult `json:"blocks"`
+	Latencies []BlockLatencyResult `json:"latencies"`
+}
+
+// GetBlockTxMetaResultTx models the metadata of a transaction returned by the
+// getblocktxmeta command.
+type GetBlockTxMetaResultTx struct {
+	TxID    string  `json:"txid"`
+	Size    int64   `json:"size"`
+	Fee     int64   `json:"fee"`
+	FeeRate int64   `json:"feerate"`
+	Depends []int64 `json:"depends"`
+	Depth   int64   `json:"depth"`
+}
+
+// GetBlockTxMetaResult models the data returned from the getblocktxmeta
+// command.
+type GetBlockTxMetaResult struct {
+	Hash   string                   `json:"hash"`
+	Height int32                    `json:"height"`
+	Tx     []GetBlockTxMetaResultTx `json:"tx"`
+}
+
+// ChainTxStatsEntangleResult models the entangle volume of a chain the coins
+// are entangled from returned by the getchaintxstats command.
+type ChainTxStatsEntangleResult struct {
+	ExTxType string `json:"extxtype"`
+	Count    int64  `json:"count"`
+	Amount   int64  `json:"amount"`
+}
+
+// GetChainTxStatsResult models the data returned from the getchaintxstats
+// command.
+type GetChainTxStatsResult struct {
+	Time                   int64                        `json:"time"`
+	WindowFinalBlockHash   string                       `json:"window_final_block_hash"`
+	WindowFinalBlockHeight int32                        `json:"window_final_block_height"`
+	WindowBlockCount       int32                        `json:"window_block_count"`
+	WindowTxCount          int64                        `json:"window_tx_count"`
+	WindowInterval         int64                        `json:"window_interval"`
+	TxRate                 float64                      `json:"txrate"`
+	WindowTxSize           int64                        `json:"window_tx_size"`
+	WindowFees             int64                        `json:"window_fees"`
+	WindowFeeRate          int64                        `json:"window_feerate"`
+	WindowEntangle         []ChainTxStatsEntangleResult `json:"window_entangle"`
+}
+
+// CheckpointCandidateResult models a checkpoint candidate returned by the
+// getcheckpointcandidates command.
+type CheckpointCandidateResult struct {
+	Height   int32  `json:"height"`
+	Hash     string `json:"hash"`
+	GoSyntax string `json:"gosyntax"`
+}
+
 // GetBlockTemplateResultAux models the coinbaseaux field of the
 // getblocktemplate command.
 type GetBlockTemplateResultAux struct {
@@ -189,6 +297,48 @@ type GetBlockTemplateResult struct {
 	RejectReasion string   `json:"reject-reason,omitempty"`
 }
 
+// BlockProposalResult models the data returned from the getblocktemplate
+// command for a block proposal when the verbose flag of the request is set.
+type BlockProposalResult struct {
+	Accepted bool   `json:"accepted"`
+	Reason   string `json:"reason,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// DBLevelStats models a level of a database store returned as part of the
+// getdbstats command.
+type DBLevelStats struct {
+	Level  int   `json:"level"`
+	Tables int   `json:"tables"`
+	Size   int64 `json:"size"`
+}
+
+// DBStoreStats models a store backing the database returned as part of the
+// getdbstats command.
+type DBStoreStats struct {
+	Name              string         `json:"name"`
+	Engine            string         `json:"engine"`
+	Files             int            `json:"files"`
+	Size              int64          `json:"size"`
+	Tombstones        uint64         `json:"tombstones"`
+	SizeAmplification float64        `json:"sizeamplification"`
+	Levels            []DBLevelStats `json:"levels,omitempty"`
+}
+
+// GetDBStatsResult models the data returned from the getdbstats command.
+type GetDBStatsResult struct {
+	Type   string         `json:"type"`
+	Stores []DBStoreStats `json:"stores"`
+}
+
+// GetIndexInfoResult models the state of an index returned by the getindexinfo
+// command.
+type GetIndexInfoResult struct {
+	Synced          bool  `json:"synced"`
+	BestBlockHeight int32 `json:"best_block_height"`
+}
+
 // GetMempoolEntryResult models the data returned from the getmempoolentry
 // command.
 type GetMempoolEntryResult struct {
@@ -211,8 +361,9 @@ type GetMempoolEntryResult struct {
 // GetMempoolInfoResult models the data returned from the getmempoolinfo
 // command.
 type GetMempoolInfoResult struct {
-	Size  int64 `json:"size"`
-	Bytes int64 `json:"bytes"`
+	Size     int64  `json:"size"`
+	Bytes    int64  `json:"bytes"`
+	Sequence uint64 `json:"sequence"`
 }
 
 // NetworksResult models the networks data from the getnetworkinfo command.
@@ -252,29 +403,63 @@ type GetNetworkInfoResult struct {
 
 // GetPeerInfoResult models the data returned from the getpeerinfo command.
 type GetPeerInfoResult struct {
-	ID             int32   `json:"id"`
-	Addr           string  `json:"addr"`
-	AddrLocal      string  `json:"addrlocal,omitempty"`
-	Services       string  `json:"services"`
-	ServicesStr    string  `json:"servicesStr"`
-	RelayTxes      bool    `json:"relaytxes"`
-	LastSend       int64   `json:"lastsend"`
-	LastRecv       int64   `json:"lastrecv"`
-	BytesSent      uint64  `json:"bytessent"`
-	BytesRecv      uint64  `json:"bytesrecv"`
-	ConnTime       int64   `json:"conntime"`
-	TimeOffset     int64   `json:"timeoffset"`
-	PingTime       float64 `json:"pingtime"`
-	PingWait       float64 `json:"pingwait,omitempty"`
-	Version        uint32  `json:"version"`
-	SubVer         string  `json:"subver"`
-	Inbound        bool    `json:"inbound"`
-	StartingHeight int32   `json:"startingheight"`
-	CurrentHeight  int32   `json:"currentheight,omitempty"`
-	BanScore       int32   `json:"banscore"`
-	Whitelisted    bool    `json:"whitelisted"`
-	FeeFilter      int64   `json:"feefilter"`
-	SyncNode       bool    `json:"syncnode"`
+	ID              int32             `json:"id"`
+	Addr            string            `json:"addr"`
+	AddrLocal       string            `json:"addrlocal,omitempty"`
+	Services        string            `json:"services"`
+	ServicesStr     string            `json:"servicesStr"`
+	RelayTxes       bool              `json:"relaytxes"`
+	LastSend        int64             `json:"lastsend"`
+	LastRecv        int64             `json:"lastrecv"`
+	BytesSent       uint64            `json:"bytessent"`
+	BytesRecv       uint64            `json:"bytesrecv"`
+	BytesSentPerMsg map[string]uint64 `json:"bytessent_per_msg"`
+	BytesRecvPerMsg map[string]uint64 `json:"bytesrecv_per_msg"`
+	ConnTime        int64             `json:"conntime"`
+	TimeOffset      int64             `json:"timeoffset"`
+	PingTime        float64           `json:"pingtime"`
+	PingWait        float64           `json:"pingwait,omitempty"`
+	Version         uint32            `json:"version"`
+	SubVer          string            `json:"subver"`
+	Inbound         bool              `json:"inbound"`
+	StartingHeight  int32             `json:"startingheight"`
+	CurrentHeight   int32             `json:"currentheight,omitempty"`
+	BanScore        int32             `json:"banscore"`
+	Whitelisted     bool              `json:"whitelisted"`
+	FeeFilter       int64             `json:"feefilter"`
+	SyncNode        bool              `json:"syncnode"`
+	SyncCandidate   bool              `json:"synccandidate"`
+	CommonHeight    int32             `json:"commonheight"`
+	InFlight        []string          `json:"inflight"`
+	SendHeaders     bool              `json:"sendheaders"`
+	CompactBlocks   bool              `json:"compactblocks"`
+	Bip152HBTo      bool              `json:"bip152_hb_to"`
+	Bip152HBFrom    bool              `json:"bip152_hb_from"`
+	BytesSentPerSec float64           `json:"bytessent_per_sec"`
+	BytesRecvPerSec float64           `json:"bytesrecv_per_sec"`
+	Permissions     []string          `json:"permissions"`
+	Features        []string          `json:"features"`
+}
+
+// RPCMethodInfo models the statistics of the requests of an RPC method
+// returned as part of the getrpcinfo command.  The durations are in
+// milliseconds.
+type RPCMethodInfo struct {
+	Method    string  `json:"method"`
+	Requests  uint64  `json:"requests"`
+	Errors    uint64  `json:"errors"`
+	ErrorRate float64 `json:"errorrate"`
+	TotalTime float64 `json:"totaltime"`
+	AvgTime   float64 `json:"avgtime"`
+	P50Time   float64 `json:"p50time"`
+	P90Time   float64 `json:"p90time"`
+	P99Time   float64 `json:"p99time"`
+}
+
+// GetRPCInfoResult models the data returned from the getrpcinfo command.
+type GetRPCInfoResult struct {
+	SlowLog int64           `json:"slowlog"`
+	Methods []RPCMethodInfo `json:"methods"`
 }
 
 // GetRawMempoolVerboseResult models the data returned from the getrawmempool
@@ -300,6 +485,32 @@ type ScriptPubKeyResult struct {
 	Addresses []string `json:"addresses,omitempty"`
 }
 
+// ScriptTypeStats models the outputs of a script type created and spent by a
+// range of blocks returned as part of the getscripttypestats command.
+type ScriptTypeStats struct {
+	Type         string  `json:"type"`
+	Created      uint64  `json:"created"`
+	CreatedValue float64 `json:"createdvalue"`
+	Spent        uint64  `json:"spent"`
+	SpentValue   float64 `json:"spentvalue"`
+}
+
+// GetScriptTypeStatsResult models the data returned from the
+// getscripttypestats command.
+type GetScriptTypeStatsResult struct {
+	StartHeight int32             `json:"startheight"`
+	EndHeight   int32             `json:"endheight"`
+	Types       []ScriptTypeStats `json:"types"`
+}
+
+// GetSpentInfoResult models the input spending an output returned by the
+// getspentinfo command.
+type GetSpentInfoResult struct {
+	TxID   string `json:"txid"`
+	Index  uint32 `json:"index"`
+	Height int32  `json:"height"`
+}
+
 // GetTxOutResult models the data from the gettxout command.
 type GetTxOutResult struct {
 	BestBlock     string             `json:"bestblock"`
@@ -309,6 +520,29 @@ type GetTxOutResult struct {
 	Coinbase      bool               `json:"coinbase"`
 }
 
+// CompetingForkResult models a side chain which does not contain the block of
+// the transaction returned as part of the gettxreorgrisk command.
+type CompetingForkResult struct {
+	TipHash     string `json:"tiphash"`
+	TipHeight   int32  `json:"tipheight"`
+	ForkHeight  int32  `json:"forkheight"`
+	Work        string `json:"work"`
+	WorkBehind  string `json:"workbehind"`
+	HeadersOnly bool   `json:"headersonly"`
+}
+
+// GetTxReorgRiskResult models the data from the gettxreorgrisk command.
+type GetTxReorgRiskResult struct {
+	TxID             string                `json:"txid"`
+	BlockHash        string                `json:"blockhash,omitempty"`
+	BlockHeight      int32                 `json:"blockheight"`
+	Confirmations    int32                 `json:"confirmations"`
+	WorkOnTop        string                `json:"workontop"`
+	Depth            int32                 `json:"depth"`
+	HasCompetingFork bool                  `json:"hascompetingfork"`
+	Forks            []CompetingForkResult `json:"forks"`
+}
+
 // GetNetTotalsResult models the data returned from the getnettotals command.
 type GetNetTotalsResult struct {
 	TotalBytesRecv uint64 `json:"totalbytesrecv"`
@@ -465,6 +699,62 @@ type EntangleInfoChainResult struct {
 	Amount   int64  `json:"amount"`
 }
 
+// DifficultyHistoryResult models the difficulty of a block returned by the
+// getdifficultyhistory command.
+type DifficultyHistoryResult struct {
+	Height       int32   `json:"height"`
+	Hash         string  `json:"hash"`
+	Time         int64   `json:"time"`
+	Bits         string  `json:"bits"`
+	Target       string  `json:"target"`
+	Difficulty   float64 `json:"difficulty"`
+	Work         string  `json:"work"`
+	ChainWork    string  `json:"chainwork"`
+	HashesPerSec float64 `json:"hashespersec"`
+}
+
+// PoolBalance models the balance of a coin pool returned by the
+// getpoolbalance command.
+type PoolBalance struct {
+	Address string  `json:"address"`
+	Amount  float64 `json:"amount"`
+}
+
+// GetPoolBalanceResult models the data from the getpoolbalance command.
+type GetPoolBalanceResult struct {
+	Height int32         `json:"height"`
+	Pools  []PoolBalance `json:"pools"`
+}
+
+// EntangleTxResult models an entangle output returned by the listentangletxs
+// command.
+type EntangleTxResult struct {
+	TxID       string `json:"txid"`
+	Vout       uint32 `json:"vout"`
+	Height     int32  `json:"height"`
+	ExTxType   string `json:"extxtype"`
+	ExtTxHash  string `json:"exttxhash"`
+	ExtTxIndex uint32 `json:"exttxindex"`
+	ExtHeight  uint64 `json:"extheight"`
+	Amount     int64  `json:"amount"`
+}
+
+// ReserveAttestationResult models a reserve attestation returned by the
+// submitreserveattestation and getreserveattestations commands.
+type ReserveAttestationResult struct {
+	ExTxType      string     `json:"extxtype"`
+	BlockHeight   int64      `json:"blockheight"`
+	BlockHash     string     `json:"blockhash"`
+	Confirmations int64      `json:"confirmations"`
+	Addresses     []string   `json:"addresses"`
+	OutPoints     []OutPoint `json:"outpoints"`
+	Reserve       int64      `json:"reserve"`
+	Entangled     int64      `json:"entangled"`
+	Backed        bool       `json:"backed"`
+	VerifiedTime  int64      `json:"verifiedtime"`
+	Error         string     `json:"error,omitempty"`
+}
+
 // TxRawResult models the data from the getrawtransaction command.
 type TxRawResult struct {
 	Hex           string `json:"hex,omitempty"`
@@ -481,6 +771,60 @@ type TxRawResult struct {
 	Blocktime     int64  `json:"blocktime,omitempty"`
 }
 
+// ScanTxOutSetUnspent models an unspent transaction output found by the
+// scantxoutset command.
+type ScanTxOutSetUnspent struct {
+	TxID         string  `json:"txid"`
+	Vout         uint32  `json:"vout"`
+	ScriptPubKey string  `json:"scriptPubKey"`
+	Desc         string  `json:"desc"`
+	Amount       float64 `json:"amount"`
+	Height       int32   `json:"height"`
+}
+
+// ScanTxOutSetResult models the data returned from the scantxoutset command
+// when starting a scan.
+type ScanTxOutSetResult struct {
+	Success     bool                  `json:"success"`
+	TxOuts      int64                 `json:"txouts"`
+	Height      int32                 `json:"height"`
+	BestBlock   string                `json:"bestblock"`
+	Unspents    []ScanTxOutSetUnspent `json:"unspents"`
+	TotalAmount float64               `json:"total_amount"`
+}
+
+// UtxoReportScript models the unspent outputs of an output script returned as
+// part of the getutxoreport command.
+type UtxoReportScript struct {
+	Address    string  `json:"address"`
+	TxOuts     int64   `json:"txouts"`
+	Amount     float64 `json:"amount"`
+	DustTxOuts int64   `json:"dust_txouts"`
+	DustAmount float64 `json:"dust_amount"`
+}
+
+// GetUtxoReportResult models the data returned from the getutxoreport command.
+type GetUtxoReportResult struct {
+	Success       bool               `json:"success"`
+	Height        int32              `json:"height"`
+	BestBlock     string             `json:"bestblock"`
+	TxOuts        int64              `json:"txouts"`
+	TotalAmount   float64            `json:"total_amount"`
+	Scripts       int64              `json:"scripts"`
+	DustTxOuts    int64              `json:"dust_txouts"`
+	DustAmount    float64            `json:"dust_amount"`
+	ReusedScripts int64              `json:"reused_scripts"`
+	ReusedTxOuts  int64              `json:"reused_txouts"`
+	Reused        []UtxoReportScript `json:"reused"`
+	Pools         []UtxoReportScript `json:"pools"`
+}
+
+// ScanTxOutSetStatusResult models the data returned from the scantxoutset
+// command when querying the status of a running scan.
+type ScanTxOutSetStatusResult struct {
+	Progress float64 `json:"progress"`
+}
+
 // SearchRawTransactionsResult models the data from the searchrawtransaction
 // command.
 type SearchRawTransactionsResult struct {
@@ -499,6 +843,13 @@ type SearchRawTransactionsResult struct {
 	Blocktime     int64        `json:"blocktime,omitempty"`
 }
 
+// GetRawTransactionsResult models the data from the getrawtransactions
+// command.
+type GetRawTransactionsResult struct {
+	Transactions []SearchRawTransactionsResult `json:"transactions"`
+	Missing      []string                      `json:"missing"`
+}
+
 // TxRawDecodeResult models the data from the decoderawtransaction command.
 type TxRawDecodeResult struct {
 	Txid     string `json:"txid"`
@@ -511,6 +862,17 @@ type TxRawDecodeResult struct {
 // ValidateAddressChainResult models the data returned by the chain server
 // validateaddress command.
 type ValidateAddressChainResult struct {
-	IsValid bool   `json:"isvalid"`
-	Address string `json:"address,omitempty"`
+	IsValid       bool   `json:"isvalid"`
+	Address       string `json:"address,omitempty"`
+	CashAddress   string `json:"cashaddress,omitempty"`
+	LegacyAddress string `json:"legacyaddress,omitempty"`
+}
+
+// VerifyIndexesResult models the outcome of verifying an index returned by the
+// verifyindexes command.
+type VerifyIndexesResult struct {
+	Name          string `json:"name"`
+	BlocksChecked int    `json:"blockschecked"`
+	Divergent     int    `json:"divergent"`
+	Repaired      int    `json:"repaired"`
 }
@@ -68,6 +68,28 @@ func TestChainSvrCustomResults(t *testing.T) {
 			},
 			expected: `{"txid":"123","vout":1,"scriptSig":{"asm":"0","hex":"00"},"prevOut":{"addresses":["addr1"],"value":0},"sequence":4294967295}`,
 		},
+		{
+			name: "getpeerinfo sync and relay state",
+			result: &btcjson.GetPeerInfoResult{
+				ID:              3,
+				Addr:            "127.0.0.1:8333",
+				Services:        "00000001",
+				ServicesStr:     "SFNodeNetwork",
+				Version:         70013,
+				SyncNode:        true,
+				SyncCandidate:   true,
+				CommonHeight:    -1,
+				InFlight:        []string{"123"},
+				SendHeaders:     true,
+				CompactBlocks:   true,
+				Bip152HBTo:      true,
+				Bip152HBFrom:    false,
+				BytesSentPerSec: 12.5,
+				BytesRecvPerSec: 0,
+				Permissions:     []string{"noban", "relay"},
+			},
+			expected: `{"id":3,"addr":"127.0.0.1:8333","services":"00000001","servicesStr":"SFNodeNetwork","relaytxes":false,"lastsend":0,"lastrecv":0,"bytessent":0,"bytesrecv":0,"bytessent_per_msg":null,"bytesrecv_per_msg":null,"conntime":0,"timeoffset":0,"pingtime":0,"version":70013,"subver":"","inbound":false,"startingheight":0,"banscore":0,"whitelisted":false,"feefilter":0,"syncnode":true,"synccandidate":true,"commonheight":-1,"inflight":["123"],"sendheaders":true,"compactblocks":true,"bip152_hb_to":true,"bip152_hb_from":false,"bytessent_per_sec":12.5,"bytesrecv_per_sec":0,"permissions":["noban","relay"],"features":null}`,
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
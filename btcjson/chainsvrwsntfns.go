@@ -75,6 +75,11 @@ const (
 	// from the chain server that inform a client that a transaction that
 	// matches the loaded filter was accepted by the mempool.
 	RelevantTxAcceptedNtfnMethod = "relevanttxaccepted"
+
+	// ChainReorganizedNtfnMethod is the method used for notifications from
+	// the chain server that the best chain has been reorganized, detailing
+	// what became of the transactions of the disconnected blocks.
+	ChainReorganizedNtfnMethod = "chainreorganized"
 )
 
 // BlockConnectedNtfn defines the blockconnected JSON-RPC notification.
@@ -120,36 +125,42 @@ func NewBlockDisconnectedNtfn(hash string, height int32, time int64) *BlockDisco
 }
 
 // FilteredBlockConnectedNtfn defines the filteredblockconnected JSON-RPC
-// notification.
+// notification.  Sequence is the chain state sequence number reported by
+// getblockchaininfo once the block was connected.
 type FilteredBlockConnectedNtfn struct {
 	Height        int32
 	Header        string
 	SubscribedTxs []string
+	Sequence      *uint64
 }
 
 // NewFilteredBlockConnectedNtfn returns a new instance which can be used to
 // issue a filteredblockconnected JSON-RPC notification.
-func NewFilteredBlockConnectedNtfn(height int32, header string, subscribedTxs []string) *FilteredBlockConnectedNtfn {
+func NewFilteredBlockConnectedNtfn(height int32, header string, subscribedTxs []string, sequence *uint64) *FilteredBlockConnectedNtfn {
 	return &FilteredBlockConnectedNtfn{
 		Height:        height,
 		Header:        header,
 		SubscribedTxs: subscribedTxs,
+		Sequence:      sequence,
 	}
 }
 
 // FilteredBlockDisconnectedNtfn defines the filteredblockdisconnected JSON-RPC
-// notification.
+// notification.  Sequence is the chain state sequence number reported by
+// getblockchaininfo once the block was disconnected.
 type FilteredBlockDisconnectedNtfn struct {
-	Height int32
-	Header string
+	Height   int32
+	Header   string
+	Sequence *uint64
 }
 
 // NewFilteredBlockDisconnectedNtfn returns a new instance which can be used to
 // issue a filteredblockdisconnected JSON-RPC notification.
-func NewFilteredBlockDisconnectedNtfn(height int32, header string) *FilteredBlockDisconnectedNtfn {
+func NewFilteredBlockDisconnectedNtfn(height int32, header string, sequence *uint64) *FilteredBlockDisconnectedNtfn {
 	return &FilteredBlockDisconnectedNtfn{
-		Height: height,
-		Header: header,
+		Height:   height,
+		Header:   header,
+		Sequence: sequence,
 	}
 }
 
@@ -245,31 +256,38 @@ func NewRescanProgressNtfn(hash string, height int32, time int64) *RescanProgres
 	}
 }
 
-// TxAcceptedNtfn defines the txaccepted JSON-RPC notification.
+// TxAcceptedNtfn defines the txaccepted JSON-RPC notification.  Sequence is
+// the mempool sequence number reported by getmempoolinfo once the transaction
+// was accepted.
 type TxAcceptedNtfn struct {
-	TxID   string
-	Amount float64
+	TxID     string
+	Amount   float64
+	Sequence *uint64
 }
 
 // NewTxAcceptedNtfn returns a new instance which can be used to issue a
 // txaccepted JSON-RPC notification.
-func NewTxAcceptedNtfn(txHash string, amount float64) *TxAcceptedNtfn {
+func NewTxAcceptedNtfn(txHash string, amount float64, sequence *uint64) *TxAcceptedNtfn {
 	return &TxAcceptedNtfn{
-		TxID:   txHash,
-		Amount: amount,
+		TxID:     txHash,
+		Amount:   amount,
+		Sequence: sequence,
 	}
 }
 
 // TxAcceptedVerboseNtfn defines the txacceptedverbose JSON-RPC notification.
+// Sequence is the same as for the txaccepted notification.
 type TxAcceptedVerboseNtfn struct {
-	RawTx TxRawResult
+	RawTx    TxRawResult
+	Sequence *uint64
 }
 
 // NewTxAcceptedVerboseNtfn returns a new instance which can be used to issue a
 // txacceptedverbose JSON-RPC notification.
-func NewTxAcceptedVerboseNtfn(rawTx TxRawResult) *TxAcceptedVerboseNtfn {
+func NewTxAcceptedVerboseNtfn(rawTx TxRawResult, sequence *uint64) *TxAcceptedVerboseNtfn {
 	return &TxAcceptedVerboseNtfn{
-		RawTx: rawTx,
+		RawTx:    rawTx,
+		Sequence: sequence,
 	}
 }
 
@@ -285,6 +303,19 @@ func NewRelevantTxAcceptedNtfn(txHex string) *RelevantTxAcceptedNtfn {
 	return &RelevantTxAcceptedNtfn{Transaction: txHex}
 }
 
+// ChainReorganizedNtfn defines the chainreorganized JSON-RPC notification.
+type ChainReorganizedNtfn struct {
+	Reorganization ChainReorganization
+}
+
+// NewChainReorganizedNtfn returns a new instance which can be used to issue a
+// chainreorganized JSON-RPC notification.
+func NewChainReorganizedNtfn(reorg ChainReorganization) *ChainReorganizedNtfn {
+	return &ChainReorganizedNtfn{
+		Reorganization: reorg,
+	}
+}
+
 func init() {
 	// The commands in this file are only usable by websockets and are
 	// notifications.
@@ -301,4 +332,5 @@ func init() {
 	MustRegisterCmd(TxAcceptedNtfnMethod, (*TxAcceptedNtfn)(nil), flags)
 	MustRegisterCmd(TxAcceptedVerboseNtfnMethod, (*TxAcceptedVerboseNtfn)(nil), flags)
 	MustRegisterCmd(RelevantTxAcceptedNtfnMethod, (*RelevantTxAcceptedNtfn)(nil), flags)
+	MustRegisterCmd(ChainReorganizedNtfnMethod, (*ChainReorganizedNtfn)(nil), flags)
 }
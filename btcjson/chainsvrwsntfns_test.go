@@ -65,7 +65,7 @@ func TestChainSvrWsNtfns(t *testing.T) {
 				return btcjson.NewCmd("filteredblockconnected", 100000, "header", []string{"tx0", "tx1"})
 			},
 			staticNtfn: func() interface{} {
-				return btcjson.NewFilteredBlockConnectedNtfn(100000, "header", []string{"tx0", "tx1"})
+				return btcjson.NewFilteredBlockConnectedNtfn(100000, "header", []string{"tx0", "tx1"}, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"filteredblockconnected","params":[100000,"header",["tx0","tx1"]],"id":null}`,
 			unmarshalled: &btcjson.FilteredBlockConnectedNtfn{
@@ -74,13 +74,29 @@ func TestChainSvrWsNtfns(t *testing.T) {
 				SubscribedTxs: []string{"tx0", "tx1"},
 			},
 		},
+		{
+			name: "filteredblockconnected with sequence",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("filteredblockconnected", 100000, "header", []string{"tx0", "tx1"}, 12)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewFilteredBlockConnectedNtfn(100000, "header", []string{"tx0", "tx1"}, btcjson.Uint64(12))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"filteredblockconnected","params":[100000,"header",["tx0","tx1"],12],"id":null}`,
+			unmarshalled: &btcjson.FilteredBlockConnectedNtfn{
+				Height:        100000,
+				Header:        "header",
+				SubscribedTxs: []string{"tx0", "tx1"},
+				Sequence:      btcjson.Uint64(12),
+			},
+		},
 		{
 			name: "filteredblockdisconnected",
 			newNtfn: func() (interface{}, error) {
 				return btcjson.NewCmd("filteredblockdisconnected", 100000, "header")
 			},
 			staticNtfn: func() interface{} {
-				return btcjson.NewFilteredBlockDisconnectedNtfn(100000, "header")
+				return btcjson.NewFilteredBlockDisconnectedNtfn(100000, "header", nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"filteredblockdisconnected","params":[100000,"header"],"id":null}`,
 			unmarshalled: &btcjson.FilteredBlockDisconnectedNtfn{
@@ -174,7 +190,7 @@ func TestChainSvrWsNtfns(t *testing.T) {
 				return btcjson.NewCmd("txaccepted", "123", 1.5)
 			},
 			staticNtfn: func() interface{} {
-				return btcjson.NewTxAcceptedNtfn("123", 1.5)
+				return btcjson.NewTxAcceptedNtfn("123", 1.5, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"txaccepted","params":["123",1.5],"id":null}`,
 			unmarshalled: &btcjson.TxAcceptedNtfn{
@@ -182,6 +198,21 @@ func TestChainSvrWsNtfns(t *testing.T) {
 				Amount: 1.5,
 			},
 		},
+		{
+			name: "txaccepted with sequence",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("txaccepted", "123", 1.5, 7)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewTxAcceptedNtfn("123", 1.5, btcjson.Uint64(7))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"txaccepted","params":["123",1.5,7],"id":null}`,
+			unmarshalled: &btcjson.TxAcceptedNtfn{
+				TxID:     "123",
+				Amount:   1.5,
+				Sequence: btcjson.Uint64(7),
+			},
+		},
 		{
 			name: "txacceptedverbose",
 			newNtfn: func() (interface{}, error) {
@@ -197,7 +228,7 @@ func TestChainSvrWsNtfns(t *testing.T) {
 					Vout:          nil,
 					Confirmations: 0,
 				}
-				return btcjson.NewTxAcceptedVerboseNtfn(txResult)
+				return btcjson.NewTxAcceptedVerboseNtfn(txResult, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"txacceptedverbose","params":[{"hex":"001122","txid":"123","version":1,"locktime":4294967295,"vin":null,"vout":null}],"id":null}`,
 			unmarshalled: &btcjson.TxAcceptedVerboseNtfn{
@@ -225,6 +256,53 @@ func TestChainSvrWsNtfns(t *testing.T) {
 				Transaction: "001122",
 			},
 		},
+		{
+			name: "chainreorganized",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("chainreorganized", `{"oldhash":"old","oldheight":101,"newhash":"new","newheight":102,"forkhash":"fork","forkheight":100,"disconnected":[{"hash":"old","height":101,"transactions":[{"txid":"123","fate":"confirmed","blockhash":"new"},{"txid":"456","fate":"invalid","reason":"spent"}]}],"connected":["a","new"],"sequence":5}`)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewChainReorganizedNtfn(btcjson.ChainReorganization{
+					OldHash:    "old",
+					OldHeight:  101,
+					NewHash:    "new",
+					NewHeight:  102,
+					ForkHash:   "fork",
+					ForkHeight: 100,
+					Disconnected: []btcjson.ReorganizedBlock{{
+						Hash:   "old",
+						Height: 101,
+						Transactions: []btcjson.ReorganizedTx{
+							{TxID: "123", Fate: "confirmed", BlockHash: "new"},
+							{TxID: "456", Fate: "invalid", Reason: "spent"},
+						},
+					}},
+					Connected: []string{"a", "new"},
+					Sequence:  5,
+				})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"chainreorganized","params":[{"oldhash":"old","oldheight":101,"newhash":"new","newheight":102,"forkhash":"fork","forkheight":100,"disconnected":[{"hash":"old","height":101,"transactions":[{"txid":"123","fate":"confirmed","blockhash":"new"},{"txid":"456","fate":"invalid","reason":"spent"}]}],"connected":["a","new"],"sequence":5}],"id":null}`,
+			unmarshalled: &btcjson.ChainReorganizedNtfn{
+				Reorganization: btcjson.ChainReorganization{
+					OldHash:    "old",
+					OldHeight:  101,
+					NewHash:    "new",
+					NewHeight:  102,
+					ForkHash:   "fork",
+					ForkHeight: 100,
+					Disconnected: []btcjson.ReorganizedBlock{{
+						Hash:   "old",
+						Height: 101,
+						Transactions: []btcjson.ReorganizedTx{
+							{TxID: "123", Fate: "confirmed", BlockHash: "new"},
+							{TxID: "456", Fate: "invalid", Reason: "spent"},
+						},
+					}},
+					Connected: []string{"a", "new"},
+					Sequence:  5,
+				},
+			},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -19,3 +19,38 @@ type RescannedBlock struct {
 	Hash         string   `json:"hash"`
 	Transactions []string `json:"transactions"`
 }
+
+// ReorganizedTx describes what became of a transaction of a block disconnected
+// by a reorganization of the chain.  The fate is either "confirmed" with the
+// hash of the block of the new best chain confirming it, "mempool" when it
+// re-entered the memory pool or "invalid" with the reason it can't be
+// confirmed anymore.
+type ReorganizedTx struct {
+	TxID      string `json:"txid"`
+	Fate      string `json:"fate"`
+	BlockHash string `json:"blockhash,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// ReorganizedBlock contains the hash, height and the fates of the transactions,
+// except the coinbase, of a block disconnected by a reorganization.
+type ReorganizedBlock struct {
+	Hash         string          `json:"hash"`
+	Height       int32           `json:"height"`
+	Transactions []ReorganizedTx `json:"transactions"`
+}
+
+// ChainReorganization models the data of the chainreorganized notification.
+// The disconnected blocks are listed from the old tip down to the fork point
+// and the connected ones from the fork point up to the new tip.
+type ChainReorganization struct {
+	OldHash      string             `json:"oldhash"`
+	OldHeight    int32              `json:"oldheight"`
+	NewHash      string             `json:"newhash"`
+	NewHeight    int32              `json:"newheight"`
+	ForkHash     string             `json:"forkhash"`
+	ForkHeight   int32              `json:"forkheight"`
+	Disconnected []ReorganizedBlock `json:"disconnected"`
+	Connected    []string           `json:"connected"`
+	Sequence     uint64             `json:"sequence"`
+}
@@ -246,6 +246,24 @@ func NewGetWalletInfoCmd() *GetWalletInfoCmd {
 	return &GetWalletInfoCmd{}
 }
 
+// ImportDescriptorCmd defines the importdescriptor JSON-RPC command.
+type ImportDescriptorCmd struct {
+	Descriptor string
+	Rescan     *bool `jsonrpcdefault:"true"`
+}
+
+// NewImportDescriptorCmd returns a new instance which can be used to issue an
+// importdescriptor JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewImportDescriptorCmd(descriptor string, rescan *bool) *ImportDescriptorCmd {
+	return &ImportDescriptorCmd{
+		Descriptor: descriptor,
+		Rescan:     rescan,
+	}
+}
+
 // ImportPrivKeyCmd defines the importprivkey JSON-RPC command.
 type ImportPrivKeyCmd struct {
 	PrivKey string
@@ -663,6 +681,7 @@ func init() {
 	MustRegisterCmd("getreceivedbyaddress", (*GetReceivedByAddressCmd)(nil), flags)
 	MustRegisterCmd("gettransaction", (*GetTransactionCmd)(nil), flags)
 	MustRegisterCmd("getwalletinfo", (*GetWalletInfoCmd)(nil), flags)
+	MustRegisterCmd("importdescriptor", (*ImportDescriptorCmd)(nil), flags)
 	MustRegisterCmd("importprivkey", (*ImportPrivKeyCmd)(nil), flags)
 	MustRegisterCmd("keypoolrefill", (*KeyPoolRefillCmd)(nil), flags)
 	MustRegisterCmd("listaccounts", (*ListAccountsCmd)(nil), flags)
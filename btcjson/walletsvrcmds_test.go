@@ -356,6 +356,34 @@ func TestWalletSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getwalletinfo","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetWalletInfoCmd{},
 		},
+		{
+			name: "importdescriptor",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("importdescriptor", "raw(deadbeef)")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewImportDescriptorCmd("raw(deadbeef)", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"importdescriptor","params":["raw(deadbeef)"],"id":1}`,
+			unmarshalled: &btcjson.ImportDescriptorCmd{
+				Descriptor: "raw(deadbeef)",
+				Rescan:     btcjson.Bool(true),
+			},
+		},
+		{
+			name: "importdescriptor optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("importdescriptor", "raw(deadbeef)", false)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewImportDescriptorCmd("raw(deadbeef)", btcjson.Bool(false))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"importdescriptor","params":["raw(deadbeef)",false],"id":1}`,
+			unmarshalled: &btcjson.ImportDescriptorCmd{
+				Descriptor: "raw(deadbeef)",
+				Rescan:     btcjson.Bool(false),
+			},
+		},
 		{
 			name: "importprivkey",
 			newCmd: func() (interface{}, error) {
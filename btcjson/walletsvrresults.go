@@ -35,6 +35,20 @@ type GetTransactionResult struct {
 	Hex             string                        `json:"hex"`
 }
 
+// GetWalletInfoResult models the data returned by the getwalletinfo command.
+// The entangled balances are the parts of the confirmed and immature balances
+// paid out by entangle transactions.
+type GetWalletInfoResult struct {
+	Balance                  float64 `json:"balance"`
+	UnconfirmedBalance       float64 `json:"unconfirmed_balance"`
+	ImmatureBalance          float64 `json:"immature_balance"`
+	EntangledBalance         float64 `json:"entangled_balance"`
+	ImmatureEntangledBalance float64 `json:"immature_entangled_balance"`
+	SyncedHeight             int32   `json:"syncedheight"`
+	UnlockedUntil            int64   `json:"unlocked_until"`
+	PaytxFee                 float64 `json:"paytxfee"`
+}
+
 // InfoWalletResult models the data returned by the wallet server getinfo
 // command.
 type InfoWalletResult struct {
@@ -117,6 +131,7 @@ type ListUnspentResult struct {
 	Amount        float64 `json:"amount"`
 	Confirmations int64   `json:"confirmations"`
 	Spendable     bool    `json:"spendable"`
+	Entangled     bool    `json:"entangled,omitempty"`
 }
 
 // SignRawTransactionError models the data that contains script verification
@@ -0,0 +1,432 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/wire"
+)
+
+// ChainDefinition describes a private network in terms of one of the default
+// networks.  It allows the magic bytes, default ports and protocol version
+// thresholds of a network to be chosen at runtime, so private networks do not
+// need to recompile this package nor the wire package to avoid talking to
+// peers of the public networks.  Consortium chains can additionally start from
+// their own genesis block and choose their coin pool addresses, entangle height,
+// rule change deployments and the challenge of a signed network, and networks
+// of miners with synced clocks can tighten the time windows of block
+// timestamps.
+//
+// A chain definition is usually read from a JSON file such as:
+//
+//	{
+//	  "name": "privnet",
+//	  "base": "regtest",
+//	  "net": "0xd9b4bef9",
+//	  "defaultport": "19444",
+//	  "rpcport": "19334",
+//	  "dnsseeds": ["seed.privnet.example.com"],
+//	  "minprotocolversion": 70015,
+//	  "genesis": {
+//	    "timestamp": 1577836800,
+//	    "bits": "0x207fffff",
+//	    "coinbase": "privnet genesis"
+//	  },
+//	  "entangleheight": 1000,
+//	  "coinpoolhashes": [
+//	    "0000000000000000000000000000000000000011",
+//	    "0000000000000000000000000000000000000012"
+//	  ],
+//	  "deployments": {
+//	    "csv": {"bit": 0, "starttime": 0, "expiretime": 18446744073709551615}
+//	  },
+//	  "signetchallenge": "5121<hex public key>51ae",
+//	  "allowedfutureblocktime": 5,
+//	  "maxtimeoffset": 600
+//	}
+type ChainDefinition struct {
+	// Name is the name of the network.  It is also used as the name of
+	// the data and log directories, so it can not be the name of one of
+	// the default networks.
+	Name string `json:"name"`
+
+	// Base is the name of the default network the remaining parameters,
+	// including the genesis block and consensus rules, are copied from.
+	Base string `json:"base"`
+
+	// Net is the network magic as a decimal or 0x prefixed hexadecimal
+	// number.  It must differ from the magic of every registered network.
+	Net string `json:"net"`
+
+	// DefaultPort is the default peer-to-peer port.  The port of the base
+	// network is used when empty.
+	DefaultPort string `json:"defaultport"`
+
+	// RPCPort and GRPCPort are the default RPC and gRPC ports.  They are
+	// not used by this package and are provided for applications which
+	// define their own defaults for the base networks.
+	RPCPort  string `json:"rpcport"`
+	GRPCPort string `json:"grpcport"`
+
+	// DNSSeeds are the DNS seeds of the network.  The seeds of the base
+	// network are never used.
+	DNSSeeds []string `json:"dnsseeds"`
+
+	// ProtocolVersion and MinProtocolVersion set the protocol version
+	// thresholds of the network.  See the fields of the same name in
+	// Params.
+	ProtocolVersion    uint32 `json:"protocolversion"`
+	MinProtocolVersion uint32 `json:"minprotocolversion"`
+
+	// CashAddressPrefix is the cashaddress prefix of the network.  The
+	// prefix of the base network is used when empty.
+	CashAddressPrefix string `json:"cashaddressprefix"`
+
+	// Genesis describes the genesis block of the network.  The genesis
+	// block of the base network is used when it is not set.
+	Genesis *GenesisDefinition `json:"genesis"`
+
+	// EntangleHeight is the height entangle transactions are accepted
+	// from.  The entangle height of the base network is used when it is
+	// not set.
+	EntangleHeight *int32 `json:"entangleheight"`
+
+	// CoinPoolHashes are the two hex-encoded hashes of the
+	// pay-to-pubkey-hash addresses the coin pool outputs of coinbase
+	// transactions pay to.  The coin pool addresses of the base network
+	// are used when empty.
+	CoinPoolHashes []string `json:"coinpoolhashes"`
+
+	// Deployments replace the rule change deployments of the base network
+	// by their names, which are dummy, csv, seq, sigchecks and
+	// utxocommitment.  The remaining deployments are copied from the base
+	// network.
+	Deployments map[string]DeploymentDefinition `json:"deployments"`
+
+	// SignetChallenge is the hex-encoded script the block signatures of
+	// the network must satisfy.  See the field of the same name in Params.
+	// The challenge of the base network, if any, is used when empty.
+	SignetChallenge string `json:"signetchallenge"`
+
+	// AllowedFutureBlockTime and MaxTimeOffset are the number of seconds
+	// a block timestamp may be ahead of the local clock and of the network
+	// adjusted time respectively.  See the fields of the same name in
+	// Params.  The windows of the base network are used when they are not
+	// set.
+	AllowedFutureBlockTime *int64 `json:"allowedfutureblocktime"`
+	MaxTimeOffset          *int64 `json:"maxtimeoffset"`
+}
+
+// GenesisDefinition describes the genesis block of a private network, which
+// holds a single coinbase transaction without outputs like the genesis blocks
+// of the default networks.
+type GenesisDefinition struct {
+	// Version is the block version.  It defaults to 1.
+	Version int32 `json:"version"`
+
+	// Timestamp is the unix time of the block.
+	Timestamp int64 `json:"timestamp"`
+
+	// Bits is the difficulty target of the block in compact form as a
+	// decimal or 0x prefixed hexadecimal number.  It defaults to the
+	// proof of work limit of the base network.
+	Bits string `json:"bits"`
+
+	// Nonce is the nonce of the block.
+	Nonce uint64 `json:"nonce"`
+
+	// Coinbase is the signature script of the coinbase transaction, which
+	// serves to tell the genesis blocks of networks apart.
+	Coinbase string `json:"coinbase"`
+}
+
+// DeploymentDefinition describes a rule change deployment of a private
+// network.  See ConsensusDeployment for the fields.
+type DeploymentDefinition struct {
+	BitNumber  uint8  `json:"bit"`
+	StartTime  uint64 `json:"starttime"`
+	ExpireTime uint64 `json:"expiretime"`
+}
+
+// deploymentNames maps the names of the rule change deployments in chain
+// definitions to their deployment IDs.  They match the names reported by the
+// getblockchaininfo RPC.
+var deploymentNames = map[string]int{
+	"dummy":          DeploymentTestDummy,
+	"csv":            DeploymentCSV,
+	"seq":            DeploymentSEQ,
+	"sigchecks":      DeploymentSigChecks,
+	"utxocommitment": DeploymentUtxoCommitment,
+}
+
+// OverrideDeployment replaces the start and expire times of the rule change
+// deployment with the passed name, which is one of the names used by chain
+// definitions, while keeping its bit number.  It allows the activation of a
+// deployment to be rehearsed on a test network without defining a new network.
+func (p *Params) OverrideDeployment(name string, startTime, expireTime uint64) error {
+	id, ok := deploymentNames[name]
+	if !ok {
+		return fmt.Errorf("unknown deployment %q", name)
+	}
+	if startTime > expireTime {
+		return fmt.Errorf("deployment %s expires before it starts",
+			name)
+	}
+	p.Deployments[id].StartTime = startTime
+	p.Deployments[id].ExpireTime = expireTime
+	return nil
+}
+
+// defaultNets are the default networks a chain definition may be based on.
+var defaultNets = []*Params{
+	&MainNetParams,
+	&TestNet3Params,
+	&RegressionNetParams,
+	&SimNetParams,
+	&SigNetParams,
+}
+
+// ReadChainDefinition decodes a JSON chain definition from r.
+func ReadChainDefinition(r io.Reader) (*ChainDefinition, error) {
+	var def ChainDefinition
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&def); err != nil {
+		return nil, fmt.Errorf("invalid chain definition: %v", err)
+	}
+	return &def, nil
+}
+
+// LoadChainDefinition reads a JSON chain definition from the file at path.
+func LoadChainDefinition(path string) (*ChainDefinition, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadChainDefinition(f)
+}
+
+// LoadParamsFromFile reads the JSON chain definition from the file at path and
+// registers the network parameters it describes, so library packages can look
+// the network up like the default networks.
+func LoadParamsFromFile(path string) (*Params, error) {
+	def, err := LoadChainDefinition(path)
+	if err != nil {
+		return nil, err
+	}
+	params, err := def.Params()
+	if err != nil {
+		return nil, err
+	}
+	if err := Register(params); err != nil {
+		return nil, fmt.Errorf("unable to register network %s: %v",
+			params.Name, err)
+	}
+	return params, nil
+}
+
+// block returns the genesis block described by the genesis definition of a
+// network based on the passed network.
+func (g *GenesisDefinition) block(base *Params) (*wire.MsgBlock, error) {
+	version := g.Version
+	if version == 0 {
+		version = 1
+	}
+	bits := base.PowLimitBits
+	if g.Bits != "" {
+		b, err := strconv.ParseUint(g.Bits, 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid genesis bits %q: %v",
+				g.Bits, err)
+		}
+		bits = uint32(b)
+	}
+	if g.Timestamp <= 0 || g.Timestamp > int64(^uint32(0)) {
+		return nil, fmt.Errorf("invalid genesis timestamp %d",
+			g.Timestamp)
+	}
+	if g.Coinbase == "" {
+		return nil, fmt.Errorf("genesis block has no coinbase")
+	}
+
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		SignatureScript:  []byte(g.Coinbase),
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	return &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:    version,
+			MerkleRoot: coinbase.TxHash(),
+			Timestamp:  time.Unix(g.Timestamp, 0),
+			Bits:       bits,
+			Nonce:      g.Nonce,
+		},
+		Transactions: []*wire.MsgTx{coinbase},
+	}, nil
+}
+
+// Params returns the network parameters described by the chain definition.
+// The returned parameters are not registered.  Callers should pass them to
+// Register, which fails with ErrDuplicateNet when the magic is already used by
+// another network.
+func (d *ChainDefinition) Params() (*Params, error) {
+	if d.Name == "" {
+		return nil, fmt.Errorf("chain definition has no name")
+	}
+
+	var base *Params
+	for _, params := range defaultNets {
+		if d.Name == params.Name {
+			return nil, fmt.Errorf("chain definition name %q is "+
+				"the name of a default network", d.Name)
+		}
+		if d.Base == params.Name {
+			base = params
+		}
+	}
+	if base == nil {
+		return nil, fmt.Errorf("unknown base network %q", d.Base)
+	}
+
+	magic, err := strconv.ParseUint(d.Net, 0, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid network magic %q: %v", d.Net,
+			err)
+	}
+	if _, ok := registeredNets[wire.BitcoinNet(magic)]; ok {
+		return nil, fmt.Errorf("network magic %#08x is already used "+
+			"by %v", magic, wire.BitcoinNet(magic))
+	}
+
+	if d.DefaultPort != "" {
+		port, err := strconv.ParseUint(d.DefaultPort, 10, 16)
+		if err != nil || port == 0 {
+			return nil, fmt.Errorf("invalid default port %q",
+				d.DefaultPort)
+		}
+	}
+	if d.MinProtocolVersion != 0 && d.ProtocolVersion != 0 &&
+		d.MinProtocolVersion > d.ProtocolVersion {
+
+		return nil, fmt.Errorf("minimum protocol version %d is "+
+			"greater than protocol version %d", d.MinProtocolVersion,
+			d.ProtocolVersion)
+	}
+	if d.EntangleHeight != nil && *d.EntangleHeight < 0 {
+		return nil, fmt.Errorf("invalid entangle height %d",
+			*d.EntangleHeight)
+	}
+
+	var genesis *wire.MsgBlock
+	if d.Genesis != nil {
+		var err error
+		genesis, err = d.Genesis.block(base)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	coinPoolHashes := base.CoinPoolHashes
+	if len(d.CoinPoolHashes) != 0 {
+		if len(d.CoinPoolHashes) != len(coinPoolHashes) {
+			return nil, fmt.Errorf("%d coin pool hashes are given "+
+				"instead of %d", len(d.CoinPoolHashes),
+				len(coinPoolHashes))
+		}
+		for i, s := range d.CoinPoolHashes {
+			hash, err := hex.DecodeString(s)
+			if err != nil || len(hash) != len(coinPoolHashes[i]) {
+				return nil, fmt.Errorf("invalid coin pool hash "+
+					"%q", s)
+			}
+			copy(coinPoolHashes[i][:], hash)
+		}
+		if coinPoolHashes[0] == coinPoolHashes[1] {
+			return nil, fmt.Errorf("the coin pool hashes are equal")
+		}
+	}
+
+	signetChallenge := base.SignetChallenge
+	if d.SignetChallenge != "" {
+		var err error
+		signetChallenge, err = hex.DecodeString(d.SignetChallenge)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signet challenge %q",
+				d.SignetChallenge)
+		}
+	}
+
+	deployments := base.Deployments
+	for name, deployment := range d.Deployments {
+		id, ok := deploymentNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown deployment %q", name)
+		}
+		if deployment.BitNumber >= 29 {
+			return nil, fmt.Errorf("invalid bit number %d of "+
+				"deployment %s", deployment.BitNumber, name)
+		}
+		if deployment.StartTime > deployment.ExpireTime {
+			return nil, fmt.Errorf("deployment %s expires before "+
+				"it starts", name)
+		}
+		deployments[id] = ConsensusDeployment(deployment)
+	}
+
+	params := *base
+	params.Name = d.Name
+	params.Net = wire.BitcoinNet(magic)
+	if d.DefaultPort != "" {
+		params.DefaultPort = d.DefaultPort
+	}
+	params.DNSSeeds = make([]DNSSeed, 0, len(d.DNSSeeds))
+	for _, host := range d.DNSSeeds {
+		params.DNSSeeds = append(params.DNSSeeds, DNSSeed{Host: host})
+	}
+	params.ProtocolVersion = d.ProtocolVersion
+	params.MinProtocolVersion = d.MinProtocolVersion
+	if d.CashAddressPrefix != "" {
+		params.CashAddressPrefix = d.CashAddressPrefix
+	}
+
+	if genesis != nil {
+		genesisHash := genesis.BlockHash()
+		params.GenesisBlock = genesis
+		params.GenesisHash = &genesisHash
+	}
+	if d.EntangleHeight != nil {
+		params.EntangleHeight = *d.EntangleHeight
+	}
+	params.CoinPoolHashes = coinPoolHashes
+	params.Deployments = deployments
+	params.SignetChallenge = signetChallenge
+	if d.AllowedFutureBlockTime != nil {
+		params.AllowedFutureBlockTime =
+			time.Duration(*d.AllowedFutureBlockTime) * time.Second
+	}
+	if d.MaxTimeOffset != nil {
+		params.MaxTimeOffset = time.Duration(*d.MaxTimeOffset) * time.Second
+	}
+	if err := params.CheckTimePolicy(); err != nil {
+		return nil, err
+	}
+
+	// Checkpoints of the base network do not apply to a private network
+	// which forks from its genesis block.
+	params.Checkpoints = nil
+
+	return &params, nil
+}
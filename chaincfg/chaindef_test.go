@@ -0,0 +1,195 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/wire"
+)
+
+// TestChainDefinition ensures chain definitions are decoded into parameters
+// derived from their base network and that invalid definitions are rejected.
+func TestChainDefinition(t *testing.T) {
+	const valid = `{
+		"name": "privnet",
+		"base": "regtest",
+		"net": "0x0badcafe",
+		"defaultport": "19444",
+		"dnsseeds": ["seed.privnet.example.com"],
+		"protocolversion": 70015,
+		"minprotocolversion": 70013
+	}`
+
+	def, err := ReadChainDefinition(strings.NewReader(valid))
+	if err != nil {
+		t.Fatalf("ReadChainDefinition: unexpected error: %v", err)
+	}
+	params, err := def.Params()
+	if err != nil {
+		t.Fatalf("Params: unexpected error: %v", err)
+	}
+	if params.Name != "privnet" || params.Net != wire.BitcoinNet(0x0badcafe) ||
+		params.DefaultPort != "19444" || params.ProtocolVersion != 70015 ||
+		params.MinProtocolVersion != 70013 {
+
+		t.Fatalf("Params: got %+v", params)
+	}
+	if len(params.DNSSeeds) != 1 ||
+		params.DNSSeeds[0].Host != "seed.privnet.example.com" {
+
+		t.Fatalf("Params: got DNS seeds %v", params.DNSSeeds)
+	}
+	if params.GenesisHash != RegressionNetParams.GenesisHash ||
+		params.CashAddressPrefix != RegressionNetParams.CashAddressPrefix {
+
+		t.Fatalf("Params: parameters not copied from base network")
+	}
+	if params.AllowedFutureBlockTime != RegressionNetParams.AllowedFutureBlockTime ||
+		params.MaxTimeOffset != RegressionNetParams.MaxTimeOffset {
+
+		t.Fatalf("Params: time windows not copied from base network")
+	}
+	if RegressionNetParams.Name != "regtest" {
+		t.Fatalf("Params: base network was modified")
+	}
+
+	tests := []struct {
+		name string
+		def  string
+	}{
+		{"unknown field", `{"name": "x", "base": "regtest", "net": "1",
+			"magic": "2"}`},
+		{"no name", `{"base": "regtest", "net": "1"}`},
+		{"default network name", `{"name": "mainnet", "base": "regtest",
+			"net": "1"}`},
+		{"unknown base", `{"name": "x", "base": "foo", "net": "1"}`},
+		{"invalid magic", `{"name": "x", "base": "regtest",
+			"net": "0x1ffffffff"}`},
+		{"duplicate magic", `{"name": "x", "base": "regtest",
+			"net": "0xe8f3e1e3"}`},
+		{"invalid port", `{"name": "x", "base": "regtest", "net": "1",
+			"defaultport": "70000"}`},
+		{"inverted versions", `{"name": "x", "base": "regtest",
+			"net": "1", "protocolversion": 70012,
+			"minprotocolversion": 70013}`},
+		{"genesis without coinbase", `{"name": "x", "base": "regtest",
+			"net": "1", "genesis": {"timestamp": 1}}`},
+		{"invalid genesis timestamp", `{"name": "x", "base": "regtest",
+			"net": "1", "genesis": {"coinbase": "x"}}`},
+		{"negative entangle height", `{"name": "x", "base": "regtest",
+			"net": "1", "entangleheight": -1}`},
+		{"single coin pool hash", `{"name": "x", "base": "regtest",
+			"net": "1", "coinpoolhashes": [
+			"0000000000000000000000000000000000000011"]}`},
+		{"short coin pool hash", `{"name": "x", "base": "regtest",
+			"net": "1", "coinpoolhashes": ["11", "12"]}`},
+		{"unknown deployment", `{"name": "x", "base": "regtest",
+			"net": "1", "deployments": {"foo": {"bit": 1}}}`},
+		{"expired deployment", `{"name": "x", "base": "regtest",
+			"net": "1", "deployments": {"csv": {"starttime": 2,
+			"expiretime": 1}}}`},
+		{"invalid signet challenge", `{"name": "x", "base": "signet",
+			"net": "1", "signetchallenge": "5121zz"}`},
+		{"zero future block time", `{"name": "x", "base": "regtest",
+			"net": "1", "allowedfutureblocktime": 0}`},
+		{"future block time past offset", `{"name": "x",
+			"base": "mainnet", "net": "1",
+			"allowedfutureblocktime": 600, "maxtimeoffset": 300}`},
+	}
+	for _, test := range tests {
+		def, err := ReadChainDefinition(strings.NewReader(test.def))
+		if err == nil {
+			_, err = def.Params()
+		}
+		if err == nil {
+			t.Errorf("%s: unexpected success", test.name)
+		}
+	}
+}
+
+// TestLoadParamsFromFile ensures the parameters of a consortium chain with its
+// own genesis block, coin pool addresses, entangle height, deployments and time
+// windows are loaded and registered.
+func TestLoadParamsFromFile(t *testing.T) {
+	const def = `{
+		"name": "consortium",
+		"base": "regtest",
+		"net": "0x0badf00d",
+		"genesis": {
+			"timestamp": 1577836800,
+			"bits": "0x207fffff",
+			"coinbase": "consortium genesis"
+		},
+		"entangleheight": 1000,
+		"coinpoolhashes": [
+			"0000000000000000000000000000000000000011",
+			"0000000000000000000000000000000000000012"
+		],
+		"deployments": {
+			"csv": {"bit": 3, "starttime": 0, "expiretime": 1}
+		},
+		"signetchallenge": "51",
+		"allowedfutureblocktime": 5,
+		"maxtimeoffset": 600
+	}`
+
+	f, err := ioutil.TempFile("", "chaindef")
+	if err != nil {
+		t.Fatalf("TempFile: unexpected error: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(def); err != nil {
+		t.Fatalf("WriteString: unexpected error: %v", err)
+	}
+	f.Close()
+
+	params, err := LoadParamsFromFile(f.Name())
+	if err != nil {
+		t.Fatalf("LoadParamsFromFile: unexpected error: %v", err)
+	}
+	genesisHash := params.GenesisBlock.BlockHash()
+	if *params.GenesisHash != genesisHash ||
+		genesisHash == *RegressionNetParams.GenesisHash ||
+		params.GenesisBlock.Header.Bits != 0x207fffff {
+
+		t.Fatalf("LoadParamsFromFile: got genesis block %v",
+			params.GenesisBlock.Header)
+	}
+	if params.EntangleHeight != 1000 ||
+		params.CoinPoolHashes[0][19] != 0x11 ||
+		params.CoinPoolHashes[1][19] != 0x12 ||
+		!bytes.Equal(params.SignetChallenge, []byte{0x51}) ||
+		params.AllowedFutureBlockTime != 5*time.Second ||
+		params.MaxTimeOffset != 10*time.Minute {
+
+		t.Fatalf("LoadParamsFromFile: got %+v", params)
+	}
+	want := ConsensusDeployment{BitNumber: 3, StartTime: 0, ExpireTime: 1}
+	if params.Deployments[DeploymentCSV] != want ||
+		params.Deployments[DeploymentSigChecks] !=
+			RegressionNetParams.Deployments[DeploymentSigChecks] {
+
+		t.Fatalf("LoadParamsFromFile: got deployments %v",
+			params.Deployments)
+	}
+	if RegressionNetParams.CoinPoolHashes[0][19] != 1 ||
+		RegressionNetParams.Deployments[DeploymentCSV] == want {
+
+		t.Fatalf("LoadParamsFromFile: base network was modified")
+	}
+
+	// The network is registered, so it can not be loaded twice.
+	if _, err := LoadParamsFromFile(f.Name()); err == nil {
+		t.Fatal("LoadParamsFromFile: unexpected success loading a " +
+			"registered network")
+	}
+}
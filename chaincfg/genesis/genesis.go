@@ -0,0 +1,353 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package genesis builds and mines the genesis blocks of custom networks and
+// renders the parameters to embed them, either as Go source in the style of
+// the chaincfg package or as the genesis of a chain definition.
+package genesis
+
+import (
+	"errors"
+	"fmt"
+	"go/format"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/consensus"
+	"github.com/bourbaki-czz/classzz/cross"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+)
+
+// nonceBatch is the number of nonces searched between checks for a request to
+// stop mining.
+const nonceBatch = 1 << 12
+
+// ErrAborted describes an error in which mining a genesis block was stopped
+// before a solution was found.
+var ErrAborted = errors.New("genesis block mining aborted")
+
+// Config describes the genesis block of a custom network.
+type Config struct {
+	// Params are the parameters of the network the genesis block is built
+	// for.  Its proof of work limit bits bound the target of the block, and
+	// its coin pool hashes are paid by the pool outputs.
+	Params *chaincfg.Params
+
+	// Version is the block version.  It defaults to 1.
+	Version int32
+
+	// Timestamp is the time of the block.
+	Timestamp time.Time
+
+	// Bits is the difficulty target of the block in compact form.  It
+	// defaults to the proof of work limit of the network.
+	Bits uint32
+
+	// Message is the signature script of the coinbase transaction, which
+	// serves to tell the genesis blocks of networks apart.
+	Message []byte
+
+	// PoolOutputs adds zero value outputs to the coinbase transaction in
+	// the layout of the coinbase transactions of blocks after the entangle
+	// height: the miner reward, the two coin pools and the keeped amounts
+	// of the entangled chains.  The genesis blocks of the default networks
+	// have no outputs, so such blocks can not be described by a chain
+	// definition.
+	PoolOutputs bool
+}
+
+// Genesis is a solved genesis block.
+type Genesis struct {
+	// Block is the genesis block.
+	Block *wire.MsgBlock
+
+	// Hash is the hash of the genesis block.
+	Hash chainhash.Hash
+}
+
+// poolOutputs returns the zero value outputs of the coinbase transaction of a
+// genesis block with the pool output structure of the passed network.
+func poolOutputs(params *chaincfg.Params) ([]*wire.TxOut, error) {
+	reward, err := txscript.NewScriptBuilder().AddOp(txscript.OP_TRUE).Script()
+	if err != nil {
+		return nil, err
+	}
+	pool1, err := txscript.PayToPubKeyHashScript(params.CoinPoolHashes[0][:])
+	if err != nil {
+		return nil, err
+	}
+	pool2, err := txscript.PayToPubKeyHashScript(params.CoinPoolHashes[1][:])
+	if err != nil {
+		return nil, err
+	}
+	keepInfo := cross.KeepedAmount{Items: []cross.KeepedItem{}}
+	keepInfo.Add(cross.KeepedItem{
+		ExTxType: cross.ExpandedTxEntangle_Doge,
+		Amount:   big.NewInt(0),
+	})
+	keepInfo.Add(cross.KeepedItem{
+		ExTxType: cross.ExpandedTxEntangle_Ltc,
+		Amount:   big.NewInt(0),
+	})
+	keeped, err := txscript.KeepedAmountScript(keepInfo.Serialize())
+	if err != nil {
+		return nil, err
+	}
+	return []*wire.TxOut{
+		{PkScript: reward},
+		{PkScript: pool1},
+		{PkScript: pool2},
+		{PkScript: keeped},
+	}, nil
+}
+
+// Block returns the genesis block described by the config with a zero nonce,
+// which usually does not satisfy its target.  See Solve.
+func (c *Config) Block() (*wire.MsgBlock, error) {
+	if c.Params == nil {
+		return nil, errors.New("genesis config has no network parameters")
+	}
+	version := c.Version
+	if version == 0 {
+		version = 1
+	}
+	bits := c.Bits
+	if bits == 0 {
+		bits = c.Params.PowLimitBits
+	}
+	// The targets are compared in compact form, since the proof of work
+	// limit bits of some networks round up past their proof of work limit.
+	target := consensus.CompactToBig(bits)
+	limit := consensus.CompactToBig(c.Params.PowLimitBits)
+	if target.Sign() <= 0 || target.Cmp(limit) > 0 {
+		return nil, fmt.Errorf("genesis bits 0x%08x are not within the "+
+			"proof of work limit of the network", bits)
+	}
+	timestamp := c.Timestamp.Unix()
+	if timestamp <= 0 || timestamp > int64(^uint32(0)) {
+		return nil, fmt.Errorf("invalid genesis timestamp %v", c.Timestamp)
+	}
+	if len(c.Message) == 0 {
+		return nil, errors.New("genesis config has no coinbase message")
+	}
+
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		SignatureScript:  c.Message,
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	if c.PoolOutputs {
+		outputs, err := poolOutputs(c.Params)
+		if err != nil {
+			return nil, err
+		}
+		coinbase.TxOut = outputs
+	}
+	return &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:    version,
+			MerkleRoot: coinbase.TxHash(),
+			Timestamp:  time.Unix(timestamp, 0),
+			Bits:       bits,
+		},
+		Transactions: []*wire.MsgTx{coinbase},
+	}, nil
+}
+
+// Solve searches for the nonce of the passed block satisfying its target,
+// starting from its current nonce, and sets it.  ErrAborted is returned when
+// the quit channel is closed before a solution is found.  A nil channel never
+// stops the search.
+func Solve(block *wire.MsgBlock, quit chan struct{}) error {
+	header := &block.Header
+	param := consensus.MiningParam{
+		Info: &consensus.CzzConsensusParam{
+			HeadHash: header.BlockHashNoNonce(),
+			Target:   consensus.CompactToBig(header.Bits),
+		},
+		Begin: header.Nonce,
+		Loops: nonceBatch,
+		Abort: quit,
+	}
+	for {
+		if nonce, found := consensus.MineBlock(&param); found {
+			header.Nonce = nonce
+			return nil
+		}
+		select {
+		case <-quit:
+			return ErrAborted
+		default:
+		}
+		if param.Begin > ^uint64(0)-nonceBatch {
+			return errors.New("no nonce satisfies the genesis target")
+		}
+		param.Begin += nonceBatch
+	}
+}
+
+// Mine builds the genesis block described by the passed config and solves it.
+// See Solve for the quit channel.
+func Mine(c *Config, quit chan struct{}) (*Genesis, error) {
+	block, err := c.Block()
+	if err != nil {
+		return nil, err
+	}
+	if err := Solve(block, quit); err != nil {
+		return nil, err
+	}
+	return &Genesis{Block: block, Hash: block.BlockHash()}, nil
+}
+
+// Definition returns the genesis of a chain definition describing the genesis
+// block.  Chain definitions can only describe genesis blocks without coinbase
+// outputs.
+func (g *Genesis) Definition() (*chaincfg.GenesisDefinition, error) {
+	coinbase := g.Block.Transactions[0]
+	if len(g.Block.Transactions) != 1 || len(coinbase.TxOut) != 0 {
+		return nil, errors.New("chain definitions can only describe " +
+			"genesis blocks without coinbase outputs")
+	}
+	header := &g.Block.Header
+	return &chaincfg.GenesisDefinition{
+		Version:   header.Version,
+		Timestamp: header.Timestamp.Unix(),
+		Bits:      fmt.Sprintf("0x%08x", header.Bits),
+		Nonce:     header.Nonce,
+		Coinbase:  string(coinbase.TxIn[0].SignatureScript),
+	}, nil
+}
+
+// writeBytes writes the passed bytes as the elements of a Go byte slice or
+// array literal, eight to a line, indented by the passed prefix.
+func writeBytes(b *strings.Builder, data []byte, indent string) {
+	for i := 0; i < len(data); i += 8 {
+		end := i + 8
+		if end > len(data) {
+			end = len(data)
+		}
+		b.WriteString(indent)
+		for j, c := range data[i:end] {
+			if j > 0 {
+				b.WriteString(" ")
+			}
+			fmt.Fprintf(b, "0x%02x,", c)
+		}
+		b.WriteString("\n")
+	}
+}
+
+// writeHash writes the declaration of a hash variable with the passed name.
+func writeHash(b *strings.Builder, name string, hash *chainhash.Hash) {
+	fmt.Fprintf(b, "var %s = chainhash.Hash([chainhash.HashSize]byte{ "+
+		"// Make go vet happy.\n", name)
+	writeBytes(b, hash[:], "\t")
+	b.WriteString("})\n")
+}
+
+// GoSource returns the Go source declaring the genesis block, its coinbase
+// transaction, merkle root and hash in the style of the chaincfg package,
+// named after the passed prefix such as privNet, followed by the fields of the
+// network parameters referring to them.  The source is formatted by gofmt.
+func (g *Genesis) GoSource(prefix string) (string, error) {
+	header := &g.Block.Header
+	coinbase := g.Block.Transactions[0]
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "// %sGenesisCoinbaseTx is the coinbase transaction "+
+		"for the genesis block.\n", prefix)
+	fmt.Fprintf(&b, "var %sGenesisCoinbaseTx = wire.MsgTx{\n", prefix)
+	fmt.Fprintf(&b, "\tVersion: %d,\n", coinbase.Version)
+	b.WriteString("\tTxIn: []*wire.TxIn{\n")
+	for _, txIn := range coinbase.TxIn {
+		b.WriteString("\t\t{\n")
+		b.WriteString("\t\t\tPreviousOutPoint: wire.OutPoint{\n")
+		b.WriteString("\t\t\t\tHash:  chainhash.Hash{},\n")
+		fmt.Fprintf(&b, "\t\t\t\tIndex: 0x%08x,\n",
+			txIn.PreviousOutPoint.Index)
+		b.WriteString("\t\t\t},\n")
+		b.WriteString("\t\t\tSignatureScript: []byte{\n")
+		writeBytes(&b, txIn.SignatureScript, "\t\t\t\t")
+		b.WriteString("\t\t\t},\n")
+		fmt.Fprintf(&b, "\t\t\tSequence: 0x%08x,\n", txIn.Sequence)
+		b.WriteString("\t\t},\n")
+	}
+	b.WriteString("\t},\n")
+	b.WriteString("\tTxOut: []*wire.TxOut{\n")
+	for _, txOut := range coinbase.TxOut {
+		b.WriteString("\t\t{\n")
+		fmt.Fprintf(&b, "\t\t\tValue: %d,\n", txOut.Value)
+		b.WriteString("\t\t\tPkScript: []byte{\n")
+		writeBytes(&b, txOut.PkScript, "\t\t\t\t")
+		b.WriteString("\t\t\t},\n")
+		b.WriteString("\t\t},\n")
+	}
+	b.WriteString("\t},\n")
+	fmt.Fprintf(&b, "\tLockTime: %d,\n", coinbase.LockTime)
+	b.WriteString("}\n\n")
+
+	fmt.Fprintf(&b, "// %sGenesisHash is the hash of the genesis block.\n",
+		prefix)
+	writeHash(&b, prefix+"GenesisHash", &g.Hash)
+	b.WriteString("\n")
+
+	fmt.Fprintf(&b, "// %sGenesisMerkleRoot is the merkle root of the "+
+		"genesis block.\n", prefix)
+	writeHash(&b, prefix+"GenesisMerkleRoot", &header.MerkleRoot)
+	b.WriteString("\n")
+
+	fmt.Fprintf(&b, "// %sGenesisBlock defines the genesis block.\n", prefix)
+	fmt.Fprintf(&b, "var %sGenesisBlock = wire.MsgBlock{\n", prefix)
+	b.WriteString("\tHeader: wire.BlockHeader{\n")
+	fmt.Fprintf(&b, "\t\tVersion:    %d,\n", header.Version)
+	b.WriteString("\t\tPrevBlock:  chainhash.Hash{},\n")
+	fmt.Fprintf(&b, "\t\tMerkleRoot: %sGenesisMerkleRoot,\n", prefix)
+	b.WriteString("\t\tCIDRoot:    chainhash.Hash{},\n")
+	fmt.Fprintf(&b, "\t\tTimestamp:  time.Unix(%d, 0), // %s\n",
+		header.Timestamp.Unix(), header.Timestamp.UTC())
+	fmt.Fprintf(&b, "\t\tBits:       0x%08x, // %d\n", header.Bits,
+		header.Bits)
+	fmt.Fprintf(&b, "\t\tNonce:      0x%x, // %d\n", header.Nonce,
+		header.Nonce)
+	b.WriteString("\t},\n")
+	fmt.Fprintf(&b, "\tTransactions: []*wire.MsgTx{&%sGenesisCoinbaseTx},\n",
+		prefix)
+	b.WriteString("}\n\n")
+
+	b.WriteString("// Params fields:\n")
+	fmt.Fprintf(&b, "//\tGenesisBlock: &%sGenesisBlock,\n", prefix)
+	fmt.Fprintf(&b, "//\tGenesisHash:  &%sGenesisHash,\n", prefix)
+
+	source, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return "", err
+	}
+	return string(source), nil
+}
+
+// Verify ensures the passed block is a genesis block whose merkle root commits
+// to its transactions and whose nonce satisfies its target.
+func Verify(block *wire.MsgBlock) error {
+	header := &block.Header
+	if header.PrevBlock != (chainhash.Hash{}) {
+		return errors.New("genesis block refers to a previous block")
+	}
+	if len(block.Transactions) != 1 {
+		return errors.New("genesis block must hold a single transaction")
+	}
+	if header.MerkleRoot != block.Transactions[0].TxHash() {
+		return errors.New("genesis merkle root does not commit to the " +
+			"coinbase transaction")
+	}
+	info := &consensus.CzzConsensusParam{
+		HeadHash: header.BlockHashNoNonce(),
+		Target:   consensus.CompactToBig(header.Bits),
+	}
+	return consensus.VerifyBlockSeal(info, header.Nonce)
+}
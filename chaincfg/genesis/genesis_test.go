@@ -0,0 +1,164 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package genesis
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+)
+
+// TestMine ensures mined genesis blocks satisfy their target and are described
+// by the chain definition and Go source they render to, and that blocks whose
+// coinbase changed after mining are rejected.
+func TestMine(t *testing.T) {
+	config := Config{
+		Params:    &chaincfg.RegressionNetParams,
+		Timestamp: time.Unix(1577836800, 0),
+		Message:   []byte("privnet genesis"),
+	}
+	g, err := Mine(&config, nil)
+	if err != nil {
+		t.Fatalf("Mine: unexpected error: %v", err)
+	}
+	if err := Verify(g.Block); err != nil {
+		t.Fatalf("Verify: unexpected error: %v", err)
+	}
+	if g.Hash != g.Block.BlockHash() {
+		t.Fatalf("Mine: got hash %v, want %v", g.Hash, g.Block.BlockHash())
+	}
+
+	// The chain definition of the block builds the same block.
+	genesisDef, err := g.Definition()
+	if err != nil {
+		t.Fatalf("Definition: unexpected error: %v", err)
+	}
+	def := chaincfg.ChainDefinition{
+		Name:    "privnet",
+		Base:    "regtest",
+		Net:     "0x0badcafe",
+		Genesis: genesisDef,
+	}
+	params, err := def.Params()
+	if err != nil {
+		t.Fatalf("Params: unexpected error: %v", err)
+	}
+	if *params.GenesisHash != g.Hash {
+		t.Fatalf("Params: got genesis hash %v, want %v",
+			params.GenesisHash, g.Hash)
+	}
+
+	source, err := g.GoSource("privNet")
+	if err != nil {
+		t.Fatalf("GoSource: unexpected error: %v", err)
+	}
+	_, err = parser.ParseFile(token.NewFileSet(), "",
+		"package chaincfg\n\n"+source, 0)
+	if err != nil {
+		t.Fatalf("GoSource: source does not parse: %v", err)
+	}
+	for _, decl := range []string{"privNetGenesisCoinbaseTx",
+		"privNetGenesisHash", "privNetGenesisMerkleRoot",
+		"privNetGenesisBlock"} {
+
+		if !strings.Contains(source, "var "+decl+" = ") {
+			t.Fatalf("GoSource: %s is not declared", decl)
+		}
+	}
+
+	// A changed coinbase no longer matches the merkle root.
+	g.Block.Transactions[0].LockTime = 1
+	if err := Verify(g.Block); err == nil {
+		t.Fatal("Verify: unexpected success of changed coinbase")
+	}
+}
+
+// TestPoolOutputs ensures genesis blocks with pool outputs carry the coinbase
+// output structure of blocks after the entangle height and can not be described
+// by a chain definition.
+func TestPoolOutputs(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	config := Config{
+		Params:      params,
+		Timestamp:   time.Unix(1577836800, 0),
+		Message:     []byte("privnet genesis"),
+		PoolOutputs: true,
+	}
+	g, err := Mine(&config, nil)
+	if err != nil {
+		t.Fatalf("Mine: unexpected error: %v", err)
+	}
+	if err := Verify(g.Block); err != nil {
+		t.Fatalf("Verify: unexpected error: %v", err)
+	}
+	coinbase := g.Block.Transactions[0]
+	if len(coinbase.TxOut) != 4 {
+		t.Fatalf("Mine: got %d coinbase outputs, want 4",
+			len(coinbase.TxOut))
+	}
+	for i, txOut := range coinbase.TxOut {
+		if txOut.Value != 0 {
+			t.Fatalf("Mine: coinbase output %d has value %d", i,
+				txOut.Value)
+		}
+	}
+	if !strings.Contains(string(coinbase.TxOut[1].PkScript),
+		string(params.CoinPoolHashes[0][:])) {
+
+		t.Fatal("Mine: second output does not pay the first coin pool")
+	}
+	if _, err := g.Definition(); err == nil {
+		t.Fatal("Definition: unexpected success with pool outputs")
+	}
+	if _, err := g.GoSource("privNet"); err != nil {
+		t.Fatalf("GoSource: unexpected error: %v", err)
+	}
+}
+
+// TestInvalidConfig ensures invalid genesis configs are rejected and that
+// mining stops when asked to.
+func TestInvalidConfig(t *testing.T) {
+	valid := Config{
+		Params:    &chaincfg.MainNetParams,
+		Timestamp: time.Unix(1577836800, 0),
+		Message:   []byte("genesis"),
+	}
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+	}{
+		{"no params", func(c *Config) { c.Params = nil }},
+		{"no message", func(c *Config) { c.Message = nil }},
+		{"no timestamp", func(c *Config) { c.Timestamp = time.Time{} }},
+		{"bits above limit", func(c *Config) { c.Bits = 0x207fffff }},
+		{"negative bits", func(c *Config) { c.Bits = 0x1d80ffff }},
+	}
+	for _, test := range tests {
+		config := valid
+		test.modify(&config)
+		if _, err := config.Block(); err == nil {
+			t.Errorf("%s: unexpected success", test.name)
+		}
+	}
+
+	block, err := valid.Block()
+	if err != nil {
+		t.Fatalf("Block: unexpected error: %v", err)
+	}
+	if block.Header.Bits != chaincfg.MainNetParams.PowLimitBits ||
+		block.Header.Version != 1 {
+
+		t.Fatalf("Block: got header %+v", block.Header)
+	}
+	quit := make(chan struct{})
+	close(quit)
+	if err := Solve(block, quit); err != ErrAborted {
+		t.Fatalf("Solve: got error %v, want %v", err, ErrAborted)
+	}
+}
@@ -5,7 +5,9 @@
 package chaincfg
 
 import (
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"math"
 	"math/big"
 	"strings"
@@ -38,6 +40,27 @@ var (
 	// simNetPowLimit is the highest proof of work value a Bitcoin block
 	// can have for the simulation test network.  It is the value 2^255 - 1.
 	simNetPowLimit = new(big.Int).Sub(new(big.Int).Lsh(bigOne, 255), bigOne)
+
+	// defaultCoinPoolHashes are the hashes of the coin pool addresses of
+	// the default networks.
+	defaultCoinPoolHashes = [2][20]byte{{19: 1}, {19: 2}}
+
+	// defaultScriptFlagSchedule is the script flag schedule of the default
+	// networks, which have validated their scripts under the same rules
+	// since the genesis block.
+	defaultScriptFlagSchedule = []ScriptFlagActivation{{
+		Height: 0,
+		Flags: []string{"P2SH", "DERSIG", "CHECKLOCKTIMEVERIFY",
+			"STRICTENC", "SIGHASH_FORKID", "LOW_S", "NULLFAIL",
+			"SIGPUSHONLY", "CLEANSTACK", "CHECKDATASIG", "SCHNORR",
+			"ALLOW_SEGWIT_RECOVERY"},
+	}}
+
+	// sigNetChallenge is the block signature challenge of the signed test
+	// network.  It is a 1-of-1 multisig script, so more signers can be
+	// added without changing the kind of challenge.
+	sigNetChallenge = hexToBytes("5121021dfa8ccdf0a9d3d7063b3813dbcda5cb" +
+		"428750be23c3e8236040cbf5b8ba750651ae")
 )
 
 // Checkpoint identifies a known good point in the block chain.  Using
@@ -97,12 +120,90 @@ const (
 	//Ensure that the time of the parent block is less than the current time
 	DeploymentSEQ
 
+	// DeploymentSigChecks defines the rule change deployment ID for the
+	// signature check accounting which limits transactions and blocks by
+	// the number of signature checks their scripts execute instead of by
+	// their static signature operation count.
+	DeploymentSigChecks
+
+	// DeploymentUtxoCommitment defines the rule change deployment ID for
+	// the commitment of the coinbase transactions to the hash of the utxo
+	// set the blocks are built on.
+	DeploymentUtxoCommitment
+
 	// NOTE: DefinedDeployments must always come last since it is used to
 	// determine how many defined deployments there currently are.
 	// DefinedDeployments is the number of currently defined deployments.
 	DefinedDeployments
 )
 
+// DifficultyAlgorithm identifies a difficulty adjustment algorithm of the
+// blockchain package.
+type DifficultyAlgorithm uint32
+
+const (
+	// DifficultyLegacy adjusts the difficulty of every block from the
+	// difficulty of its parent by a step depending on how long after it the
+	// block came.  It is in effect from the genesis block unless the
+	// difficulty schedule of the network says otherwise.
+	DifficultyLegacy DifficultyAlgorithm = iota
+
+	// DifficultyASERT computes the target of every block from the target
+	// of the block before the activation height, which it doubles or halves
+	// for every ASERTHalfLife the chain is behind or ahead of the schedule
+	// of one block every TargetTimePerBlock.  It is the aserti3-2d
+	// algorithm of Bitcoin Cash.
+	DifficultyASERT
+
+	// DifficultyRollingWindow computes the target of every block from the
+	// work and the time span of the most recent 144 blocks, the ends of the
+	// window being the median of three blocks so a single block timestamp
+	// has a limited effect.  It is the cw-144 algorithm of Bitcoin Cash.
+	DifficultyRollingWindow
+
+	// NOTE: numDifficultyAlgorithms must always come last since it is used
+	// to determine how many difficulty algorithms are defined.
+	numDifficultyAlgorithms
+)
+
+// difficultyAlgorithmNames are the names of the difficulty algorithms.
+var difficultyAlgorithmNames = [numDifficultyAlgorithms]string{
+	DifficultyLegacy:        "legacy",
+	DifficultyASERT:         "asert",
+	DifficultyRollingWindow: "rollingwindow",
+}
+
+// String returns the name of the difficulty algorithm.
+func (a DifficultyAlgorithm) String() string {
+	if a < numDifficultyAlgorithms {
+		return difficultyAlgorithmNames[a]
+	}
+	return fmt.Sprintf("unknown(%d)", uint32(a))
+}
+
+// DifficultyActivation switches the difficulty adjustment algorithm of the
+// blocks from a height on.
+type DifficultyActivation struct {
+	// Height is the height of the first block the algorithm computes the
+	// difficulty of.
+	Height int32
+
+	// Algorithm is the difficulty adjustment algorithm.
+	Algorithm DifficultyAlgorithm
+}
+
+// ScriptFlagActivation enforces additional script verification flags on the
+// transactions of the blocks from a height on.
+type ScriptFlagActivation struct {
+	// Height is the height of the first block validated with the flags.
+	Height int32
+
+	// Flags are the names of the script verification flags, such as P2SH
+	// or SCHNORR, as accepted by txscript.ParseScriptFlag.  They are names
+	// rather than txscript flags since txscript depends on this package.
+	Flags []string
+}
+
 // Params defines a Bitcoin network by its parameters.  These parameters may be
 // used by Bitcoin applications to differentiate networks as well as addresses
 // and keys for one network from those intended for use on another network.
@@ -116,6 +217,15 @@ type Params struct {
 	// DefaultPort defines the default peer-to-peer port for the network.
 	DefaultPort string
 
+	// ProtocolVersion is the maximum protocol version advertised to peers
+	// on the network.  Zero selects the latest version supported by the
+	// wire package.
+	ProtocolVersion uint32
+
+	// MinProtocolVersion is the minimum protocol version a peer must
+	// advertise to be allowed to connect.  Zero allows any version.
+	MinProtocolVersion uint32
+
 	// DNSSeeds defines a list of DNS seeds for the network that are used
 	// as one method to discover peers.
 	DNSSeeds []DNSSeed
@@ -176,11 +286,49 @@ type Params struct {
 	// NOTE: This only applies if ReduceMinDifficulty is true.
 	MinDiffReductionTime time.Duration
 
+	// DifficultySchedule switches the difficulty adjustment algorithm at
+	// the activation heights, which must be increasing.  The blocks below
+	// the first activation height use DifficultyLegacy.
+	DifficultySchedule []DifficultyActivation
+
+	// ASERTHalfLife is the time the chain must be ahead of or behind its
+	// schedule to double or halve the target of DifficultyASERT.
+	//
+	// NOTE: This only applies if DifficultySchedule activates DifficultyASERT.
+	ASERTHalfLife time.Duration
+
 	// GenerateSupported specifies whether or not CPU mining is allowed.
 	GenerateSupported bool
 
+	// AllowedFutureBlockTime is how far a block timestamp may be ahead of
+	// the local clock.  Unlike MaxTimeOffset it is not adjusted by the
+	// time of the peers, so it suits networks whose miners keep their
+	// clocks synced.
+	AllowedFutureBlockTime time.Duration
+
+	// MaxTimeOffset is how far a block timestamp may be ahead of the
+	// network adjusted time.
+	MaxTimeOffset time.Duration
+
 	EntangleHeight int32
 
+	// CoinPoolHashes are the hashes of the pay-to-pubkey-hash addresses
+	// the coin pool outputs of coinbase transactions pay to.
+	CoinPoolHashes [2][20]byte
+
+	// SignetChallenge is the script the block signature carried by the
+	// coinbase transaction of every block must satisfy in addition to the
+	// proof of work.  It is nil on networks without block signatures.
+	SignetChallenge []byte
+
+	// ScriptFlagSchedule enables script verification flags at the
+	// activation heights, which must be increasing and start with the
+	// genesis block.  A block is validated with the flags of all the
+	// activations at or below its height, in addition to the flags enabled
+	// by the rule change deployments such as CSV.  Register sets the
+	// schedule of the default networks when it is empty.
+	ScriptFlagSchedule []ScriptFlagActivation
+
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints []Checkpoint
 
@@ -243,8 +391,14 @@ var MainNetParams = Params{
 	SubsidyReductionInterval: 1000000,
 	TargetTimePerBlock:       30, // 10 minutes
 	GenerateSupported:        true,
+	AllowedFutureBlockTime:   time.Second * 10,
+	MaxTimeOffset:            time.Hour * 2,
 
 	EntangleHeight: 120000,
+	CoinPoolHashes: defaultCoinPoolHashes,
+
+	ScriptFlagSchedule: defaultScriptFlagSchedule,
+
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints: []Checkpoint{
 		{Height: 11111, Hash: newHashFromStr("1faf0d2246f07608c6a97a6ca698055a89d07f84c52db4455addad0cc86175aa")},
@@ -275,6 +429,15 @@ var MainNetParams = Params{
 			BitNumber:  0,
 			StartTime:  1572868800,    //
 			ExpireTime: math.MaxInt64, // Never expires
+		}, DeploymentSigChecks: {
+			BitNumber:  1,
+			StartTime:  math.MaxInt64, // Not yet scheduled
+			ExpireTime: math.MaxInt64, // Never expires
+		},
+		DeploymentUtxoCommitment: {
+			BitNumber:  2,
+			StartTime:  math.MaxInt64, // Not yet scheduled
+			ExpireTime: math.MaxInt64, // Never expires
 		},
 	},
 
@@ -322,6 +485,15 @@ var RegressionNetParams = Params{
 	NoDifficultyAdjustment:   true,
 	MinDiffReductionTime:     time.Minute * 20, // TargetTimePerBlock * 2
 	GenerateSupported:        true,
+	AllowedFutureBlockTime:   time.Second * 10,
+	MaxTimeOffset:            time.Hour * 2,
+
+	// Entangle transactions are accepted from the first block, so the
+	// entangle flow can be tested with few blocks.
+	EntangleHeight: 1,
+	CoinPoolHashes: defaultCoinPoolHashes,
+
+	ScriptFlagSchedule: defaultScriptFlagSchedule,
 
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints: nil,
@@ -342,6 +514,15 @@ var RegressionNetParams = Params{
 			BitNumber:  0,
 			StartTime:  0,             // Always available for vote
 			ExpireTime: math.MaxInt64, // Never expires
+		}, DeploymentSigChecks: {
+			BitNumber:  1,
+			StartTime:  0,             // Always available for vote
+			ExpireTime: math.MaxInt64, // Never expires
+		},
+		DeploymentUtxoCommitment: {
+			BitNumber:  2,
+			StartTime:  0,             // Always available for vote
+			ExpireTime: math.MaxInt64, // Never expires
 		},
 	},
 
@@ -397,6 +578,12 @@ var TestNet3Params = Params{
 	NoDifficultyAdjustment:   false,
 	MinDiffReductionTime:     time.Minute * 20, // TargetTimePerBlock * 2
 	GenerateSupported:        false,
+	AllowedFutureBlockTime:   time.Second * 10,
+	MaxTimeOffset:            time.Hour * 2,
+
+	CoinPoolHashes: defaultCoinPoolHashes,
+
+	ScriptFlagSchedule: defaultScriptFlagSchedule,
 
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints: []Checkpoint{},
@@ -417,6 +604,15 @@ var TestNet3Params = Params{
 			BitNumber:  0,
 			StartTime:  1456790400, // March 1st, 2016
 			ExpireTime: 1493596800, // May 1st, 2017
+		}, DeploymentSigChecks: {
+			BitNumber:  1,
+			StartTime:  math.MaxInt64, // Not yet scheduled
+			ExpireTime: math.MaxInt64, // Never expires
+		},
+		DeploymentUtxoCommitment: {
+			BitNumber:  2,
+			StartTime:  math.MaxInt64, // Not yet scheduled
+			ExpireTime: math.MaxInt64, // Never expires
 		},
 	},
 
@@ -467,6 +663,12 @@ var SimNetParams = Params{
 	NoDifficultyAdjustment:   true,
 	MinDiffReductionTime:     time.Minute * 20, // TargetTimePerBlock * 2
 	GenerateSupported:        true,
+	AllowedFutureBlockTime:   time.Second * 10,
+	MaxTimeOffset:            time.Hour * 2,
+
+	CoinPoolHashes: defaultCoinPoolHashes,
+
+	ScriptFlagSchedule: defaultScriptFlagSchedule,
 
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints: nil,
@@ -487,6 +689,15 @@ var SimNetParams = Params{
 			BitNumber:  0,
 			StartTime:  0,             // Always available for vote
 			ExpireTime: math.MaxInt64, // Never expires
+		}, DeploymentSigChecks: {
+			BitNumber:  1,
+			StartTime:  0,             // Always available for vote
+			ExpireTime: math.MaxInt64, // Never expires
+		},
+		DeploymentUtxoCommitment: {
+			BitNumber:  2,
+			StartTime:  0,             // Always available for vote
+			ExpireTime: math.MaxInt64, // Never expires
 		},
 	},
 
@@ -510,6 +721,95 @@ var SimNetParams = Params{
 	HDCoinType: 115, //
 }
 
+// SigNetParams defines the network parameters for the signed test network.
+// Like the test network it is public, but a block is only valid when its
+// coinbase transaction carries a signature satisfying the network's challenge
+// script, so the signer controls block production and the network can not be
+// griefed by bursts of hash power.
+var SigNetParams = Params{
+	Name:        "signet",
+	Net:         wire.SigNet,
+	DefaultPort: "38333",
+	DNSSeeds:    []DNSSeed{},
+
+	// Chain parameters
+	GenesisBlock: &genesisBlock,
+	GenesisHash:  &genesisHash,
+	PowLimit:     mainPowLimit,
+	PowLimitBits: 0x1e10624d,
+
+	CoinbaseMaturity:         100,
+	SubsidyReductionInterval: 1000000,
+	TargetTimespan:           time.Hour * 24 * 14, // 14 days
+	TargetTimePerBlock:       time.Minute * 10,    // 10 minutes
+	RetargetAdjustmentFactor: 4,                   // 25% less, 400% more
+	ReduceMinDifficulty:      false,
+	NoDifficultyAdjustment:   false,
+	MinDiffReductionTime:     0,
+	GenerateSupported:        true,
+	AllowedFutureBlockTime:   time.Second * 10,
+	MaxTimeOffset:            time.Hour * 2,
+
+	// Entangle transactions are accepted from the first block, so the
+	// block signature always follows the keeped amount output of the
+	// coinbase transaction.
+	EntangleHeight:  1,
+	CoinPoolHashes:  defaultCoinPoolHashes,
+	SignetChallenge: sigNetChallenge,
+
+	ScriptFlagSchedule: defaultScriptFlagSchedule,
+
+	// Checkpoints ordered from oldest to newest.
+	Checkpoints: nil,
+
+	// Consensus rule change deployments.
+	//
+	// The miner confirmation window is defined as:
+	//   target proof of work timespan / target proof of work spacing
+	RuleChangeActivationThreshold: 1512, // 75% of MinerConfirmationWindow
+	MinerConfirmationWindow:       2016,
+	Deployments: [DefinedDeployments]ConsensusDeployment{
+		DeploymentTestDummy: {
+			BitNumber:  28,
+			StartTime:  0,             // Always available for vote
+			ExpireTime: math.MaxInt64, // Never expires
+		},
+		DeploymentCSV: {
+			BitNumber:  0,
+			StartTime:  0,             // Always available for vote
+			ExpireTime: math.MaxInt64, // Never expires
+		}, DeploymentSigChecks: {
+			BitNumber:  1,
+			StartTime:  0,             // Always available for vote
+			ExpireTime: math.MaxInt64, // Never expires
+		},
+		DeploymentUtxoCommitment: {
+			BitNumber:  2,
+			StartTime:  0,             // Always available for vote
+			ExpireTime: math.MaxInt64, // Never expires
+		},
+	},
+
+	// Mempool parameters
+	RelayNonStdTxs: true,
+
+	// The prefix for the cashaddress
+	CashAddressPrefix: "czztest", // always czztest for signet
+
+	// Address encoding magics
+	LegacyPubKeyHashAddrID: 0x6f, // starts with m or n
+	LegacyScriptHashAddrID: 0xc4, // starts with 2
+	PrivateKeyID:           0xef, // starts with 9 (uncompressed) or c (compressed)
+
+	// BIP32 hierarchical deterministic extended key magics
+	HDPrivateKeyID: [4]byte{0x04, 0x35, 0x83, 0x94}, // starts with tprv
+	HDPublicKeyID:  [4]byte{0x04, 0x35, 0x87, 0xcf}, // starts with tpub
+
+	// BIP44 coin type used in the hierarchical deterministic path for
+	// address generation.
+	HDCoinType: 1, // all coins use 1
+}
+
 var (
 	// ErrDuplicateNet describes an error where the parameters for a Bitcoin
 	// network could not be set due to the network already being a standard
@@ -535,10 +835,136 @@ func (d DNSSeed) String() string {
 	return d.Host
 }
 
+// CheckTimePolicy returns an error when the time sanity policy of the network
+// can not be enforced.  Both windows must be at least a second, the precision
+// of block timestamps, and the window against the local clock can not exceed
+// the one against the network adjusted time.
+func (p *Params) CheckTimePolicy() error {
+	if p.AllowedFutureBlockTime < time.Second {
+		return fmt.Errorf("allowed future block time of %v is less "+
+			"than a second", p.AllowedFutureBlockTime)
+	}
+	if p.MaxTimeOffset < time.Second {
+		return fmt.Errorf("maximum time offset of %v is less than a "+
+			"second", p.MaxTimeOffset)
+	}
+	if p.AllowedFutureBlockTime > p.MaxTimeOffset {
+		return fmt.Errorf("allowed future block time of %v exceeds "+
+			"the maximum time offset of %v", p.AllowedFutureBlockTime,
+			p.MaxTimeOffset)
+	}
+	return nil
+}
+
+// DifficultyActivation returns the activation of the difficulty adjustment
+// algorithm computing the difficulty of the block at the passed height.  The
+// height of the returned activation is zero when the block uses
+// DifficultyLegacy because the schedule activates nothing before it.
+func (p *Params) DifficultyActivation(height int32) DifficultyActivation {
+	var activation DifficultyActivation
+	for _, next := range p.DifficultySchedule {
+		if next.Height > height {
+			break
+		}
+		activation = next
+	}
+	return activation
+}
+
+// CheckDifficultySchedule returns an error when the difficulty schedule of the
+// network can not be followed.  The activation heights must be increasing and
+// the algorithms known.  Since the chain needs a block to anchor to, ASERT can
+// not be activated at the genesis block, and both ASERT and the rolling window
+// need a target time per block of at least a second, the precision of block
+// timestamps, which is also the smallest half life of ASERT.
+func (p *Params) CheckDifficultySchedule() error {
+	var prevHeight int32 = -1
+	for _, activation := range p.DifficultySchedule {
+		if activation.Height <= prevHeight {
+			return fmt.Errorf("difficulty activation heights are not "+
+				"increasing at height %d", activation.Height)
+		}
+		prevHeight = activation.Height
+
+		switch activation.Algorithm {
+		case DifficultyLegacy:
+			continue
+		case DifficultyASERT:
+			if activation.Height < 1 {
+				return fmt.Errorf("the %v difficulty algorithm can "+
+					"not be activated at the genesis block",
+					activation.Algorithm)
+			}
+			if p.ASERTHalfLife < time.Second {
+				return fmt.Errorf("ASERT half life of %v is less "+
+					"than a second", p.ASERTHalfLife)
+			}
+		case DifficultyRollingWindow:
+		default:
+			return fmt.Errorf("unknown difficulty algorithm %v at "+
+				"height %d", activation.Algorithm, activation.Height)
+		}
+		if p.TargetTimePerBlock < time.Second {
+			return fmt.Errorf("target time per block of %v is less than "+
+				"a second as required by the %v difficulty algorithm",
+				p.TargetTimePerBlock, activation.Algorithm)
+		}
+	}
+	return nil
+}
+
+// ScriptFlags returns the names of the script verification flags the schedule
+// enables for the block at the passed height, in the order of the schedule.
+func (p *Params) ScriptFlags(height int32) []string {
+	var flags []string
+	for _, activation := range p.ScriptFlagSchedule {
+		if activation.Height > height {
+			break
+		}
+		flags = append(flags, activation.Flags...)
+	}
+	return flags
+}
+
+// CheckScriptFlagSchedule returns an error when the script flag schedule of
+// the network is not valid.  The first activation must be at the genesis
+// block, so a missing or truncated schedule is not mistaken for validating
+// scripts without any flag, the activation heights must be increasing and
+// every activation must enable at least one flag.  The names of the flags are
+// checked by blockchain.New, since this package does not know them.
+func (p *Params) CheckScriptFlagSchedule() error {
+	if len(p.ScriptFlagSchedule) == 0 {
+		return errors.New("script flag schedule is empty")
+	}
+	if p.ScriptFlagSchedule[0].Height != 0 {
+		return fmt.Errorf("script flag schedule starts at height %d "+
+			"instead of the genesis block",
+			p.ScriptFlagSchedule[0].Height)
+	}
+
+	var prevHeight int32 = -1
+	for _, activation := range p.ScriptFlagSchedule {
+		if activation.Height <= prevHeight {
+			return fmt.Errorf("script flag activation heights are not "+
+				"increasing at height %d", activation.Height)
+		}
+		prevHeight = activation.Height
+
+		if len(activation.Flags) == 0 {
+			return fmt.Errorf("script flag activation at height %d "+
+				"enables no flags", activation.Height)
+		}
+	}
+	return nil
+}
+
 // Register registers the network parameters for a Bitcoin network.  This may
 // error with ErrDuplicateNet if the network is already registered (either
 // due to a previous Register call, or the network being one of the default
-// networks).
+// networks), or with the error of CheckTimePolicy, CheckDifficultySchedule or
+// CheckScriptFlagSchedule when its time sanity policy, its difficulty schedule
+// or its script flag schedule is not valid.  A network without a script flag
+// schedule gets the one of the default networks.
 //
 // Network parameters should be registered into this package by a main package
 // as early as possible.  Then, library packages may lookup networks or network
@@ -548,6 +974,18 @@ func Register(params *Params) error {
 	if _, ok := registeredNets[params.Net]; ok {
 		return ErrDuplicateNet
 	}
+	if err := params.CheckTimePolicy(); err != nil {
+		return err
+	}
+	if err := params.CheckDifficultySchedule(); err != nil {
+		return err
+	}
+	if len(params.ScriptFlagSchedule) == 0 {
+		params.ScriptFlagSchedule = defaultScriptFlagSchedule
+	}
+	if err := params.CheckScriptFlagSchedule(); err != nil {
+		return err
+	}
 	registeredNets[params.Net] = struct{}{}
 	pubKeyHashAddrIDs[params.LegacyPubKeyHashAddrID] = struct{}{}
 	scriptHashAddrIDs[params.LegacyScriptHashAddrID] = struct{}{}
@@ -634,10 +1072,23 @@ func newHashFromStr(hexStr string) *chainhash.Hash {
 	return hash
 }
 
+// hexToBytes converts the passed hex string into bytes and will panic if there
+// is an error.  This is only provided for the hard-coded constants so errors in
+// the source code can be detected.  It will only (and must only) be called with
+// hard-coded values.
+func hexToBytes(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic("invalid hex in source file: " + s)
+	}
+	return b
+}
+
 func init() {
 	// Register all default networks when the package is initialized.
 	mustRegister(&MainNetParams)
 	mustRegister(&TestNet3Params)
 	mustRegister(&RegressionNetParams)
 	mustRegister(&SimNetParams)
+	mustRegister(&SigNetParams)
 }
@@ -4,7 +4,11 @@
 
 package chaincfg
 
-import "testing"
+import (
+	"strings"
+	"testing"
+	"time"
+)
 
 // TestInvalidHashStr ensures the newShaHashFromStr function panics when used to
 // with an invalid hash string.
@@ -62,3 +66,173 @@ func TestSeeds(t *testing.T) {
 		}
 	}
 }
+
+// TestCheckTimePolicy ensures the time sanity policies of the default networks
+// are valid and keep the windows every network used before they were
+// configurable, and that unusable ones are rejected.
+func TestCheckTimePolicy(t *testing.T) {
+	t.Parallel()
+
+	for _, params := range []*Params{&MainNetParams, &TestNet3Params,
+		&RegressionNetParams, &SimNetParams, &SigNetParams} {
+
+		if err := params.CheckTimePolicy(); err != nil {
+			t.Errorf("%s: unexpected error: %v", params.Name, err)
+		}
+		if params.AllowedFutureBlockTime != 10*time.Second ||
+			params.MaxTimeOffset != 2*time.Hour {
+
+			t.Errorf("%s: time windows changed to %v and %v",
+				params.Name, params.AllowedFutureBlockTime,
+				params.MaxTimeOffset)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		allowedFuture time.Duration
+		maxOffset     time.Duration
+		valid         bool
+	}{
+		{"equal windows", time.Minute, time.Minute, true},
+		{"unset", 0, 0, false},
+		{"subsecond future time", time.Millisecond, time.Hour, false},
+		{"subsecond offset", time.Second, time.Millisecond, false},
+		{"future time past offset", time.Hour, time.Minute, false},
+	}
+	for _, test := range tests {
+		params := Params{
+			AllowedFutureBlockTime: test.allowedFuture,
+			MaxTimeOffset:          test.maxOffset,
+		}
+		err := params.CheckTimePolicy()
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%s: unexpected success", test.name)
+		}
+	}
+}
+
+// TestDifficultySchedule ensures the difficulty activation of a height is the
+// last one at or below it and that unusable schedules are rejected.
+func TestDifficultySchedule(t *testing.T) {
+	t.Parallel()
+
+	for _, params := range []*Params{&MainNetParams, &TestNet3Params,
+		&RegressionNetParams, &SimNetParams, &SigNetParams} {
+
+		if err := params.CheckDifficultySchedule(); err != nil {
+			t.Errorf("%s: unexpected error: %v", params.Name, err)
+		}
+	}
+
+	asert := DifficultyActivation{Height: 100, Algorithm: DifficultyASERT}
+	rolling := DifficultyActivation{Height: 200, Algorithm: DifficultyRollingWindow}
+	params := Params{
+		TargetTimePerBlock: time.Minute,
+		ASERTHalfLife:      time.Hour,
+		DifficultySchedule: []DifficultyActivation{asert, rolling},
+	}
+	for height, want := range map[int32]DifficultyActivation{
+		0:   {},
+		99:  {},
+		100: asert,
+		199: asert,
+		200: rolling,
+		500: rolling,
+	} {
+		if got := params.DifficultyActivation(height); got != want {
+			t.Errorf("DifficultyActivation(%d): got %+v, want %+v",
+				height, got, want)
+		}
+	}
+	if err := params.CheckDifficultySchedule(); err != nil {
+		t.Errorf("CheckDifficultySchedule: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		schedule []DifficultyActivation
+		spacing  time.Duration
+		halfLife time.Duration
+	}{
+		{"unordered", []DifficultyActivation{rolling, asert}, time.Minute,
+			time.Hour},
+		{"duplicate height", []DifficultyActivation{asert, {Height: 100}},
+			time.Minute, time.Hour},
+		{"unknown algorithm", []DifficultyActivation{{Height: 1,
+			Algorithm: numDifficultyAlgorithms}}, time.Minute, time.Hour},
+		{"asert at genesis", []DifficultyActivation{{Height: 0,
+			Algorithm: DifficultyASERT}}, time.Minute, time.Hour},
+		{"subsecond half life", []DifficultyActivation{asert},
+			time.Minute, time.Millisecond},
+		{"subsecond spacing", []DifficultyActivation{rolling},
+			time.Millisecond, time.Hour},
+	}
+	for _, test := range tests {
+		params := Params{
+			TargetTimePerBlock: test.spacing,
+			ASERTHalfLife:      test.halfLife,
+			DifficultySchedule: test.schedule,
+		}
+		if err := params.CheckDifficultySchedule(); err == nil {
+			t.Errorf("%s: unexpected success", test.name)
+		}
+	}
+}
+
+// TestScriptFlagSchedule ensures the script flags of a height are those of all
+// the activations at or below it and that invalid schedules, including the ones
+// which do not start at the genesis block, are rejected.
+func TestScriptFlagSchedule(t *testing.T) {
+	t.Parallel()
+
+	for _, params := range []*Params{&MainNetParams, &TestNet3Params,
+		&RegressionNetParams, &SimNetParams, &SigNetParams} {
+
+		if err := params.CheckScriptFlagSchedule(); err != nil {
+			t.Errorf("%s: unexpected error: %v", params.Name, err)
+		}
+	}
+
+	genesis := ScriptFlagActivation{Height: 0, Flags: []string{"P2SH"}}
+	upgrade := ScriptFlagActivation{Height: 100, Flags: []string{"SCHNORR",
+		"SIGCHECKS"}}
+	params := Params{
+		ScriptFlagSchedule: []ScriptFlagActivation{genesis, upgrade},
+	}
+	for height, want := range map[int32]string{
+		0:   "P2SH",
+		99:  "P2SH",
+		100: "P2SH,SCHNORR,SIGCHECKS",
+		500: "P2SH,SCHNORR,SIGCHECKS",
+	} {
+		got := strings.Join(params.ScriptFlags(height), ",")
+		if got != want {
+			t.Errorf("ScriptFlags(%d): got %s, want %s", height, got,
+				want)
+		}
+	}
+	if err := params.CheckScriptFlagSchedule(); err != nil {
+		t.Errorf("CheckScriptFlagSchedule: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		schedule []ScriptFlagActivation
+	}{
+		{"unordered", []ScriptFlagActivation{upgrade, genesis}},
+		{"duplicate height", []ScriptFlagActivation{upgrade, {Height: 100,
+			Flags: []string{"P2SH"}}}},
+		{"no flags", []ScriptFlagActivation{genesis, {Height: 1}}},
+		{"empty", nil},
+		{"no genesis activation", []ScriptFlagActivation{upgrade}},
+	}
+	for _, test := range tests {
+		params := Params{ScriptFlagSchedule: test.schedule}
+		if err := params.CheckScriptFlagSchedule(); err == nil {
+			t.Errorf("%s: unexpected success", test.name)
+		}
+	}
+}
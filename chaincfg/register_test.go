@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	. "github.com/bourbaki-czz/classzz/chaincfg"
 )
@@ -21,6 +22,8 @@ var mockNetParams = Params{
 	HDPrivateKeyID:         [4]byte{0x01, 0x02, 0x03, 0x04},
 	HDPublicKeyID:          [4]byte{0x05, 0x06, 0x07, 0x08},
 	CashAddressPrefix:      "czzmock",
+	AllowedFutureBlockTime: time.Minute,
+	MaxTimeOffset:          time.Hour,
 }
 
 func TestRegister(t *testing.T) {
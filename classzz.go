@@ -8,16 +8,18 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-	_ "net/http/pprof"
 	"os"
 	"path/filepath"
 	"runtime"
 	"runtime/debug"
 	"runtime/pprof"
+	"time"
 
 	"github.com/bourbaki-czz/classzz/blockchain/indexers"
 	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/database/ffldb"
 	"github.com/bourbaki-czz/classzz/limits"
+	"github.com/bourbaki-czz/classzz/lockstat"
 	"github.com/bourbaki-czz/classzz/version"
 	"github.com/bourbaki-czz/classzz/wire"
 )
@@ -73,13 +75,23 @@ func czzdMain(serverChan chan<- *server) error {
 		go func() {
 			listenAddr := net.JoinHostPort("", cfg.Profile)
 			czzdLog.Infof("Profile server listening on %s", listenAddr)
-			profileRedirect := http.RedirectHandler("/debug/pprof",
-				http.StatusSeeOther)
-			http.Handle("/", profileRedirect)
-			czzdLog.Errorf("%v", http.ListenAndServe(listenAddr, nil))
+			handler := newProfileHandler(cfg.RPCUser, cfg.RPCPass)
+			czzdLog.Errorf("%v", http.ListenAndServe(listenAddr, handler))
 		}()
 	}
 
+	// Push profiles to the collecting endpoint if requested.
+	if cfg.profilePusher != nil {
+		cfg.profilePusher.Start()
+		defer cfg.profilePusher.Stop()
+	}
+
+	// Instrument the chain and mempool locks if requested.  This must be
+	// done before they are created.
+	if cfg.LockStats {
+		lockstat.Enable(cfg.LockWatchdog)
+	}
+
 	// Write cpu profile if requested.
 	if cfg.CPUProfile != "" {
 		f, err := os.Create(cfg.CPUProfile)
@@ -103,6 +115,16 @@ func czzdMain(serverChan chan<- *server) error {
 		return nil
 	}
 
+	// Create the wallet file and exit if requested.
+	if cfg.CreateWallet {
+		if err := createWallet(activeNetParams.Params); err != nil {
+			czzdLog.Errorf("Unable to create the wallet: %v", err)
+			return err
+		}
+
+		return nil
+	}
+
 	// Load the block database.
 	db, err := loadBlockDB()
 	if err != nil {
@@ -148,6 +170,30 @@ func czzdMain(serverChan chan<- *server) error {
 
 		return nil
 	}
+	if cfg.DropSpentIndex {
+		if err := indexers.DropSpentIndex(db, interrupt); err != nil {
+			czzdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropScriptTypeIndex {
+		if err := indexers.DropScriptTypeIndex(db, interrupt); err != nil {
+			czzdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropWalletIndex {
+		if err := dropWalletIndex(db, interrupt); err != nil {
+			czzdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
 
 	// Create server and start it.
 	server, err := newServer(cfg.Listeners, db, activeNetParams.Params,
@@ -160,15 +206,23 @@ func czzdMain(serverChan chan<- *server) error {
 	}
 	defer func() {
 		czzdLog.Infof("Gracefully shutting down the server...")
-		server.Stop()
-		server.WaitForShutdown()
-		srvrLog.Infof("Server shutdown complete")
+		shutdownServer(server, cfg.ShutdownTimeout)
 	}()
 	server.Start()
 	if serverChan != nil {
 		serverChan <- server
 	}
 
+	// Tell the service manager the node is up when run as a systemd
+	// service.
+	if err := sdNotify("READY=1"); err != nil {
+		czzdLog.Warnf("Unable to notify the service manager: %v", err)
+	}
+
+	// Reload the options which can be changed while the node is running
+	// when a reload signal is received.  The outcome is logged.
+	reloadListener(func() { server.reloadConfig() }, interrupt)
+
 	// Wait until the interrupt signal is received from an OS signal or
 	// shutdown is requested through one of the subsystems such as the RPC
 	// server.
@@ -215,6 +269,34 @@ func blockDbPath(dbType string) string {
 	return dbPath
 }
 
+// blockStorageConfig returns the config of where and how the database of the
+// passed type stores the blocks according to the block storage options.
+func blockStorageConfig(dbType string) ffldb.BlockStorageConfig {
+	// The directories are named after the database like the database path.
+	dbName := blockDbNamePrefix + "_" + dbType
+	storage := ffldb.BlockStorageConfig{
+		MaxFileSize: cfg.BlockFileSize * 1024 * 1024,
+		ColdAge:     time.Duration(cfg.BlockColdAge) * 24 * time.Hour,
+	}
+	if cfg.BlocksDir != "" {
+		storage.BlocksDir = filepath.Join(cfg.BlocksDir, dbName)
+	}
+	if cfg.BlockColdDir != "" {
+		storage.ColdDir = filepath.Join(cfg.BlockColdDir, dbName)
+	}
+	return storage
+}
+
+// dbWriteConfig returns the config of how the database batches and syncs
+// writes according to the database write options.
+func dbWriteConfig() ffldb.WriteConfig {
+	return ffldb.WriteConfig{
+		WriteBuffer:  int(cfg.DBWriteBuffer) * 1024 * 1024,
+		BatchSize:    uint64(cfg.DBBatchSize) * 1024 * 1024,
+		SyncInterval: cfg.DBSyncInterval,
+	}
+}
+
 // warnMultipleDBs shows a warning if multiple block database types are detected.
 // This is not a situation most users want.  It is handy for development however
 // to support multiple side-by-side databases.
@@ -222,7 +304,7 @@ func warnMultipleDBs() {
 	// This is intentionally not using the known db types which depend
 	// on the database types compiled into the binary since we want to
 	// detect legacy db types as well.
-	dbTypes := []string{"ffldb", "leveldb", "sqlite"}
+	dbTypes := []string{"ffldb", "ldb", "leveldb", "sqlite"}
 	duplicateDbPaths := make([]string, 0, len(dbTypes)-1)
 	for _, dbType := range dbTypes {
 		if dbType == cfg.DbType {
@@ -270,13 +352,31 @@ func loadBlockDB() (database.DB, error) {
 
 	// The database name is based on the database type.
 	dbPath := blockDbPath(cfg.DbType)
+	storage := blockStorageConfig(cfg.DbType)
+	write := dbWriteConfig()
 
 	// The regression test is special in that it needs a clean database for
-	// each run, so remove it now if it already exists.
-	removeRegressionDB(dbPath)
+	// each run, so remove it now if it already exists.  This includes the
+	// block directories when the blocks are stored elsewhere.
+	for _, path := range []string{dbPath, storage.BlocksDir, storage.ColdDir} {
+		if path != "" {
+			removeRegressionDB(path)
+		}
+	}
 
 	czzdLog.Infof("Loading block database from '%s'", dbPath)
-	db, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net, cfg.DBCacheSize*1024*1024, cfg.DBFlushInterval)
+	if storage.BlocksDir != "" {
+		czzdLog.Infof("Storing blocks in '%s'", storage.BlocksDir)
+	}
+	if storage.ColdDir != "" {
+		czzdLog.Infof("Moving block files older than %d days to '%s'",
+			cfg.BlockColdAge, storage.ColdDir)
+	}
+	if write.SyncInterval > 0 {
+		czzdLog.Infof("Syncing the block database at most every %v",
+			write.SyncInterval)
+	}
+	db, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net, cfg.DBCacheSize*1024*1024, cfg.DBFlushInterval, storage, write)
 	if err != nil {
 		// Return the error if it's not because the database doesn't
 		// exist.
@@ -291,7 +391,7 @@ func loadBlockDB() (database.DB, error) {
 		if err != nil {
 			return nil, err
 		}
-		db, err = database.Create(cfg.DbType, dbPath, activeNetParams.Net, cfg.DBCacheSize*1024*1024, cfg.DBFlushInterval)
+		db, err = database.Create(cfg.DbType, dbPath, activeNetParams.Net, cfg.DBCacheSize*1024*1024, cfg.DBFlushInterval, storage, write)
 		if err != nil {
 			return nil, err
 		}
@@ -33,6 +33,7 @@ type importResults struct {
 type blockImporter struct {
 	db                database.DB
 	chain             *blockchain.BlockChain
+	indexManager      *indexers.Manager
 	r                 io.ReadSeeker
 	processQueue      chan []byte
 	doneChan          chan bool
@@ -283,10 +284,19 @@ func (bi *blockImporter) Import() chan *importResults {
 	go bi.readHandler()
 	go bi.processHandler()
 
+	// Catch up the indexes which are behind the imported chain while the
+	// blocks are imported.
+	if bi.indexManager != nil {
+		bi.indexManager.Start()
+	}
+
 	// Wait for the import to finish in a separate goroutine and signal
 	// the status handler when done.
 	go func() {
 		bi.wg.Wait()
+		if bi.indexManager != nil {
+			bi.indexManager.Stop()
+		}
 		// Flush the changes made to the blockchain.
 		log.Info("Flushing blockchain caches to the disk...")
 		if err := bi.chain.FlushCachedState(blockchain.FlushRequired); err != nil {
@@ -334,8 +344,10 @@ func newBlockImporter(db database.DB, r io.ReadSeeker) (*blockImporter, error) {
 
 	// Create an index manager if any of the optional indexes are enabled.
 	var indexManager blockchain.IndexManager
+	var manager *indexers.Manager
 	if len(indexes) > 0 {
-		indexManager = indexers.NewManager(db, indexes)
+		manager = indexers.NewManager(db, indexes)
+		indexManager = manager
 	}
 
 	chain, err := blockchain.New(&blockchain.Config{
@@ -359,6 +371,7 @@ func newBlockImporter(db database.DB, r io.ReadSeeker) (*blockImporter, error) {
 		errChan:      make(chan error),
 		quit:         make(chan struct{}),
 		chain:        chain,
+		indexManager: manager,
 		lastLogTime:  time.Now(),
 	}, nil
 }
@@ -0,0 +1,93 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bourbaki-czz/classzz/mining/remotesigner"
+	"github.com/bourbaki-czz/czzutil"
+	flags "github.com/jessevdk/go-flags"
+)
+
+const defaultListen = "127.0.0.1:8339"
+
+type config struct {
+	Listen  string `short:"l" long:"listen" description:"Interface/port to serve signing requests on"`
+	KeyFile string `short:"k" long:"keyfile" description:"File holding the WIF-encoded private key to sign blocks with"`
+	User    string `short:"u" long:"user" description:"Username required with HTTP basic authentication"`
+	Pass    string `short:"P" long:"pass" default-mask:"-" description:"Password required with HTTP basic authentication"`
+	TLSCert string `long:"tlscert" description:"File containing the certificate to serve requests with TLS"`
+	TLSKey  string `long:"tlskey" description:"File containing the certificate key to serve requests with TLS"`
+}
+
+func main() {
+	cfg := config{
+		Listen: defaultListen,
+	}
+	parser := flags.NewParser(&cfg, flags.Default)
+	_, err := parser.Parse()
+	if err != nil {
+		if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
+			parser.WriteHelp(os.Stderr)
+		}
+		return
+	}
+
+	if cfg.KeyFile == "" {
+		fmt.Fprintln(os.Stderr, "no key file specified")
+		os.Exit(1)
+	}
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		fmt.Fprintln(os.Stderr, "tlscert and tlskey must be used together")
+		os.Exit(1)
+	}
+	keyStr, err := ioutil.ReadFile(cleanAndExpandPath(cfg.KeyFile))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot read key file: %v\n", err)
+		os.Exit(1)
+	}
+	wif, err := czzutil.DecodeWIF(strings.TrimSpace(string(keyStr)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot decode key: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The public key is printed for the signetsignerpubkey option of the
+	// nodes using the signer.
+	fmt.Printf("Serving signing requests for public key %s on %s\n",
+		hex.EncodeToString(wif.PrivKey.PubKey().SerializeCompressed()),
+		cfg.Listen)
+	handler := remotesigner.NewHandler(wif.PrivKey, cfg.User, cfg.Pass)
+	if cfg.TLSCert != "" {
+		err = http.ListenAndServeTLS(cfg.Listen,
+			cleanAndExpandPath(cfg.TLSCert),
+			cleanAndExpandPath(cfg.TLSKey), handler)
+	} else {
+		err = http.ListenAndServe(cfg.Listen, handler)
+	}
+	fmt.Fprintf(os.Stderr, "cannot serve signing requests: %v\n", err)
+	os.Exit(1)
+}
+
+// cleanAndExpandPath expands environement variables and leading ~ in the
+// passed path, cleans the result, and returns it.
+func cleanAndExpandPath(path string) string {
+	// Expand initial ~ to OS specific home directory.
+	if strings.HasPrefix(path, "~") {
+		homeDir := filepath.Dir(czzutil.AppDataDir("blocksigner", false))
+		path = strings.Replace(path, "~", homeDir, 1)
+	}
+
+	// NOTE: The os.ExpandEnv doesn't work with Windows-style %VARIABLE%,
+	// but they variables can still be expanded via POSIX-style $VARIABLE.
+	return filepath.Clean(os.ExpandEnv(path))
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/blockchain/indexers"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/limits"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzlog"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+const (
+	// blockDbNamePrefix is the prefix for the classzz block database.
+	blockDbNamePrefix = "blocks"
+)
+
+var (
+	cfg *config
+	log czzlog.Logger
+)
+
+// loadBlockDB opens the block database and returns a handle to it.  Unlike
+// classzz, the database is not created when it does not exist.
+func loadBlockDB() (database.DB, error) {
+	// The database name is based on the database type.
+	dbName := blockDbNamePrefix + "_" + cfg.DbType
+	dbPath := filepath.Join(cfg.DataDir, dbName)
+
+	log.Infof("Loading block database from '%s'", dbPath)
+	db, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Block database loaded")
+	return db, nil
+}
+
+// analyzeChain walks the utxo set of the chain as of its best block and
+// returns the report of its statistics.
+func analyzeChain(chain *blockchain.BlockChain) (*Report, error) {
+	snapshot, err := chain.UtxoSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Close()
+
+	// Collect the timestamps of the blocks ages are measured with.
+	times := make([]time.Time, snapshot.Height()+1)
+	for height := range times {
+		hash, err := chain.BlockHashByHeight(int32(height))
+		if err != nil {
+			return nil, err
+		}
+		header, err := chain.HeaderByHash(hash)
+		if err != nil {
+			return nil, err
+		}
+		times[height] = header.Timestamp
+	}
+
+	log.Infof("Walking the utxo set at height %d. This is going to take "+
+		"a while...", snapshot.Height())
+	// The fee was validated when the configuration was loaded.
+	minRelayTxFee, _ := czzutil.NewAmount(cfg.MinRelayTxFee)
+	a := newAnalyzer(activeNetParams, minRelayTxFee, times)
+	err = snapshot.ForEach(func(outpoint *wire.OutPoint, entry *blockchain.UtxoEntry) error {
+		a.add(outpoint, entry)
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Attribute the entangle payouts, which requires the transactions of
+	// their blocks.
+	heights := a.payoutHeights()
+	log.Infof("Reading %d blocks with entangle payouts", len(heights))
+	for _, height := range heights {
+		block, err := chain.BlockByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		a.addPayouts(block)
+	}
+
+	return a.report(snapshot.Hash().String(), snapshot.Height()), nil
+}
+
+// realMain is the real main function for the utility.  It is necessary to work
+// around the fact that deferred functions do not run when os.Exit() is called.
+func realMain() error {
+	// Load configuration and parse command line.
+	tcfg, _, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	cfg = tcfg
+
+	// Setup logging.  Log to standard error so the report can be written
+	// to standard output.
+	backendLogger := czzlog.NewBackend(os.Stderr)
+	log = backendLogger.Logger("MAIN")
+	database.UseLogger(backendLogger.Logger("BCDB"))
+	blockchain.UseLogger(backendLogger.Logger("CHAN"))
+	indexers.UseLogger(backendLogger.Logger("INDX"))
+
+	// Load the block database.
+	db, err := loadBlockDB()
+	if err != nil {
+		log.Errorf("Failed to load database: %v", err)
+		return err
+	}
+	defer db.Close()
+
+	var reportWriter io.Writer = os.Stdout
+	if cfg.OutFile != "" {
+		reportFile, err := os.Create(cfg.OutFile)
+		if err != nil {
+			log.Errorf("Unable to create file at: %s", cfg.OutFile)
+			return err
+		}
+		defer reportFile.Close()
+
+		reportWriter = reportFile
+	}
+
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: activeNetParams,
+		TimeSource:  blockchain.NewMedianTime(),
+		// No nice way to get the main configuration here.
+		// For now just accept up to the default.
+		ExcessiveBlockSize: 32000000,
+	})
+	if err != nil {
+		log.Errorf("Failed to load block chain: %v", err)
+		return err
+	}
+
+	report, err := analyzeChain(chain)
+	if err != nil {
+		log.Errorf("%v", err)
+		return err
+	}
+	if cfg.Format == "csv" {
+		err = report.writeCSV(reportWriter)
+	} else {
+		err = report.writeJSON(reportWriter)
+	}
+	if err != nil {
+		log.Errorf("Unable to write report: %v", err)
+		return err
+	}
+	return nil
+}
+
+func main() {
+	// Use all processor cores and up some limits.
+	runtime.GOMAXPROCS(runtime.NumCPU())
+	if err := limits.SetLimits(); err != nil {
+		os.Exit(1)
+	}
+
+	// Work around defer not working after os.Exit()
+	if err := realMain(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/database"
+	_ "github.com/bourbaki-czz/classzz/database/ffldb"
+	"github.com/bourbaki-czz/classzz/mining"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzlog"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// spendFee is the fee of the transaction of the fixture chain.
+const spendFee = 1000
+
+// fixtureTxSource is a mining transaction source offering the transactions set
+// to the generated blocks.
+type fixtureTxSource struct {
+	descs []*mining.TxDesc
+}
+
+func (s *fixtureTxSource) LastUpdated() time.Time        { return time.Time{} }
+func (s *fixtureTxSource) Sequence() uint64              { return 0 }
+func (s *fixtureTxSource) MiningDescs() []*mining.TxDesc { return s.descs }
+func (s *fixtureTxSource) HaveTransaction(hash *chainhash.Hash) bool {
+	for _, desc := range s.descs {
+		if desc.Tx.Hash().IsEqual(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// fixtureChain is a chain on the regression test network stored in a
+// temporary database.  Its first coinbase output paying to anyone is spent by
+// a transaction of the last block, once it is mature, and the other outputs
+// are unspent.
+type fixtureChain struct {
+	chain  *blockchain.BlockChain
+	params *chaincfg.Params
+	blocks []*czzutil.Block
+	spend  *czzutil.Tx
+}
+
+// newFixtureChain returns the fixture chain along with a function to remove
+// its database.  It also sets the globals the analysis uses.
+func newFixtureChain(t *testing.T) (*fixtureChain, func()) {
+	dir, err := ioutil.TempDir("", "chainstats")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	// The blocks are mined faster than one a second, so their times run
+	// ahead of the local clock by more than the future block time window of
+	// the network.
+	params := chaincfg.RegressionNetParams
+	params.AllowedFutureBlockTime = params.MaxTimeOffset
+	db, err := database.Create("ffldb", filepath.Join(dir, "db"), params.Net)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("database.Create: unexpected error: %v", err)
+	}
+	teardown := func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:                 db,
+		ChainParams:        &params,
+		TimeSource:         blockchain.NewMedianTime(),
+		ExcessiveBlockSize: 1000000,
+	})
+	if err != nil {
+		teardown()
+		t.Fatalf("blockchain.New: unexpected error: %v", err)
+	}
+
+	fc := &fixtureChain{chain: chain, params: &params}
+	var source fixtureTxSource
+	policy := mining.Policy{BlockMaxSize: 1000000}
+	g := mining.NewBlkTmplGenerator(&policy, &params, &source, chain,
+		blockchain.NewMedianTime(), txscript.NewSigCache(100),
+		txscript.NewHashCache(100))
+	for i := 0; i <= int(params.CoinbaseMaturity); i++ {
+		if i == int(params.CoinbaseMaturity) {
+			fc.spend = spendCoinbase(t, fc.blocks[0])
+			source.descs = []*mining.TxDesc{{
+				Tx:       fc.spend,
+				Added:    time.Now(),
+				Height:   fc.blocks[0].Height(),
+				Fee:      spendFee,
+				FeePerKB: spendFee * 1000 / int64(fc.spend.MsgTx().SerializeSize()),
+			}}
+		}
+		template, err := g.NewBlockTemplate(nil)
+		if err != nil {
+			teardown()
+			t.Fatalf("NewBlockTemplate: unexpected error: %v", err)
+		}
+		block := czzutil.NewBlock(template.Block)
+		_, _, err = chain.ProcessBlock(block, blockchain.BFNoPoWCheck)
+		if err != nil {
+			teardown()
+			t.Fatalf("ProcessBlock: unexpected error: %v", err)
+		}
+		block.SetHeight(template.Height)
+		fc.blocks = append(fc.blocks, block)
+	}
+	if len(fc.blocks[len(fc.blocks)-1].Transactions()) != 2 {
+		teardown()
+		t.Fatal("the spending transaction was not mined")
+	}
+
+	activeNetParams = &params
+	cfg = &config{MinRelayTxFee: 0.00001}
+	log = czzlog.Disabled
+	return fc, teardown
+}
+
+// spendCoinbase returns a transaction spending the output of the coinbase of
+// the passed block paying to anyone to a new output paying to anyone.
+func spendCoinbase(t *testing.T, block *czzutil.Block) *czzutil.Tx {
+	coinbase := block.Transactions()[0]
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: *coinbase.Hash()},
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	tx.AddTxOut(&wire.TxOut{
+		PkScript: coinbase.MsgTx().TxOut[0].PkScript,
+		Value:    coinbase.MsgTx().TxOut[0].Value - spendFee,
+	})
+
+	// The padding lets the transaction reach the minimum size.
+	padding, err := txscript.NullDataScript(make([]byte, 40))
+	if err != nil {
+		t.Fatalf("NullDataScript: unexpected error: %v", err)
+	}
+	tx.AddTxOut(&wire.TxOut{PkScript: padding})
+	return czzutil.NewTx(tx)
+}
+
+// findBucket returns the bucket with the passed name of the passed buckets.
+func findBucket(buckets []Bucket, name string) Bucket {
+	for _, bucket := range buckets {
+		if bucket.Name == name {
+			return bucket
+		}
+	}
+	return Bucket{Name: name}
+}
+
+// checkBucket ensures the passed bucket counts the passed number of outputs
+// and amount.
+func checkBucket(t *testing.T, bucket Bucket, count int64, amount int64) {
+	t.Helper()
+	if bucket.Count != count || bucket.Amount != czzutil.Amount(amount) {
+		t.Errorf("%s: got %d outputs of %d, want %d outputs of %d",
+			bucket.Name, bucket.Count, int64(bucket.Amount), count,
+			amount)
+	}
+}
+
+// TestAnalyzeChain ensures the utxo set of the fixture chain is aggregated by
+// origin, script type and age, and that the total amount is the supply held by
+// the outputs of its blocks which are not spent.
+func TestAnalyzeChain(t *testing.T) {
+	fc, teardown := newFixtureChain(t)
+	defer teardown()
+
+	report, err := analyzeChain(fc.chain)
+	if err != nil {
+		t.Fatalf("analyzeChain: unexpected error: %v", err)
+	}
+	tip := fc.blocks[len(fc.blocks)-1]
+	if report.Hash != tip.Hash().String() || report.Height != tip.Height() {
+		t.Fatalf("got report for %s (height %d), want %v (height %d)",
+			report.Hash, report.Height, tip.Hash(), tip.Height())
+	}
+
+	// The coinbases pay the miner and then the two coin pools, followed
+	// by the unspendable keeped amounts, and the fee of the transaction
+	// goes to the miner of the last block.
+	numBlocks := int64(len(fc.blocks))
+	var mined, pool int64
+	for _, block := range fc.blocks[1:] {
+		mined += block.Transactions()[0].MsgTx().TxOut[0].Value
+	}
+	for _, block := range fc.blocks {
+		for _, txOut := range block.Transactions()[0].MsgTx().TxOut[1:3] {
+			pool += txOut.Value
+		}
+	}
+	spendAmount := fc.spend.MsgTx().TxOut[0].Value
+	supply := mined + pool + spendAmount
+	if want := fc.blocks[0].Transactions()[0].MsgTx().TxOut[0].Value -
+		spendFee; spendAmount != want {
+		t.Fatalf("got %d spent to the transaction, want %d",
+			spendAmount, want)
+	}
+
+	checkBucket(t, report.Utxos, 3*numBlocks, supply)
+	if len(report.Origins) != 3 {
+		t.Errorf("got origins %v, want mined, pool and transaction",
+			report.Origins)
+	}
+	checkBucket(t, findBucket(report.Origins, originMined), numBlocks-1, mined)
+	checkBucket(t, findBucket(report.Origins, originPool), 2*numBlocks, pool)
+	checkBucket(t, findBucket(report.Origins, originTransaction), 1,
+		spendAmount)
+
+	checkBucket(t, findBucket(report.ScriptTypes,
+		txscript.NonStandardTy.String()), numBlocks, mined+spendAmount)
+	checkBucket(t, findBucket(report.ScriptTypes,
+		txscript.PubKeyHashTy.String()), 2*numBlocks, pool)
+
+	// The coin pools of the first block, at the entangle height, are empty.
+	checkBucket(t, report.Dust, 2, 0)
+
+	// All the blocks were generated just now.
+	checkBucket(t, report.Ages[0], 3*numBlocks, supply)
+	for _, bucket := range report.Ages[1:] {
+		checkBucket(t, bucket, 0, 0)
+	}
+}
+
+// TestAnalyzerAges ensures the unspent outputs are counted in the buckets of
+// their age relative to the timestamp of the best block.
+func TestAnalyzerAges(t *testing.T) {
+	fc, teardown := newFixtureChain(t)
+	defer teardown()
+
+	// The outputs of the second and third blocks are aged by their
+	// timestamps.
+	const day = 24 * time.Hour
+	now := time.Now()
+	times := make([]time.Time, len(fc.blocks)+1)
+	for i := range times {
+		times[i] = now
+	}
+	times[2] = now.Add(-400 * day)
+	times[3] = now.Add(-3 * 365 * day)
+
+	a := newAnalyzer(fc.params, 0, times)
+	for _, block := range fc.blocks[1:3] {
+		outpoint := wire.OutPoint{Hash: *block.Transactions()[0].Hash()}
+		entry, err := fc.chain.FetchUtxoEntry(outpoint)
+		if err != nil || entry == nil {
+			t.Fatalf("FetchUtxoEntry: got %v, %v, want an entry",
+				entry, err)
+		}
+		a.add(&outpoint, entry)
+	}
+
+	report := a.report("", 0)
+	reward := fc.blocks[1].Transactions()[0].MsgTx().TxOut[0].Value
+	for _, bucket := range report.Ages {
+		switch bucket.Name {
+		case "<2y", ">=2y":
+			checkBucket(t, bucket, 1, reward)
+		default:
+			checkBucket(t, bucket, 0, 0)
+		}
+	}
+}
+
+// TestReportCSV ensures the csv report identifies the block and lists the
+// buckets of each section with their amounts in CZZ.
+func TestReportCSV(t *testing.T) {
+	report := &Report{
+		Hash:        "00ff",
+		Height:      7,
+		Utxos:       Bucket{Name: "utxos", Count: 3, Amount: 150000000},
+		Ages:        []Bucket{{Name: "<1d", Count: 3, Amount: 150000000}},
+		Origins:     []Bucket{{Name: originMined, Count: 3, Amount: 150000000}},
+		ScriptTypes: []Bucket{{Name: "pubkeyhash", Count: 3, Amount: 150000000}},
+		Dust:        Bucket{Name: "dust", Count: 1},
+	}
+	var buf bytes.Buffer
+	if err := report.writeCSV(&buf); err != nil {
+		t.Fatalf("writeCSV: unexpected error: %v", err)
+	}
+	want := strings.Join([]string{
+		"section,name,count,amount",
+		"block,hash,,00ff",
+		"block,height,,7",
+		"summary,utxos,3,1.50000000",
+		"summary,dust,1,0.00000000",
+		"age,<1d,3,1.50000000",
+		"origin,mined,3,1.50000000",
+		"scripttype,pubkeyhash,3,1.50000000",
+	}, "\n") + "\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("got csv report:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/database"
+	_ "github.com/bourbaki-czz/classzz/database/ffldb"
+	"github.com/bourbaki-czz/classzz/mempool"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+	flags "github.com/jessevdk/go-flags"
+)
+
+const (
+	defaultDbType = "ffldb"
+	defaultFormat = "json"
+)
+
+var (
+	czzdHomeDir     = czzutil.AppDataDir("classzz", false)
+	defaultDataDir  = filepath.Join(czzdHomeDir, "data")
+	knownDbTypes    = database.SupportedDrivers()
+	activeNetParams = &chaincfg.MainNetParams
+)
+
+// config defines the configuration options for chainstats.
+//
+// See loadConfig for details on the configuration load process.
+type config struct {
+	DataDir        string  `short:"d" long:"datadir" description:"Location of the classzz data directory"`
+	DbType         string  `long:"dbtype" description:"Database backend to use for the Block Chain"`
+	TestNet3       bool    `long:"testnet" description:"Use the test network"`
+	RegressionTest bool    `long:"regtest" description:"Use the regression test network"`
+	SimNet         bool    `long:"simnet" description:"Use the simulation test network"`
+	SigNet         bool    `long:"signet" description:"Use the signed test network"`
+	Format         string  `short:"f" long:"format" description:"Format of the report: json or csv"`
+	OutFile        string  `short:"o" long:"out" description:"Write the report to this file instead of standard output"`
+	MinRelayTxFee  float64 `long:"minrelaytxfee" description:"The minimum transaction fee in CZZ/kB the outputs counted as dust are defined by"`
+}
+
+// validDbType returns whether or not dbType is a supported database type.
+func validDbType(dbType string) bool {
+	for _, knownType := range knownDbTypes {
+		if dbType == knownType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// netName returns the name used when referring to a bitcoin network.  At the
+// time of writing, classzz currently places blocks for testnet version 3 in the
+// data and log directory "testnet", which does not match the Name field of the
+// chaincfg parameters.  This function can be used to override this directory name
+// as "testnet" when the passed active network matches wire.TestNet3.
+//
+// A proper upgrade to move the data and log directories for this network to
+// "testnet3" is planned for the future, at which point this function can be
+// removed and the network parameter's name used instead.
+func netName(chainParams *chaincfg.Params) string {
+	switch chainParams.Net {
+	case wire.TestNet3:
+		return "testnet"
+	default:
+		return chainParams.Name
+	}
+}
+
+// loadConfig initializes and parses the config using command line options.
+func loadConfig() (*config, []string, error) {
+	// Default config.
+	cfg := config{
+		DataDir:       defaultDataDir,
+		DbType:        defaultDbType,
+		Format:        defaultFormat,
+		MinRelayTxFee: mempool.DefaultMinRelayTxFee.ToCZZ(),
+	}
+
+	// Parse command line options.
+	parser := flags.NewParser(&cfg, flags.Default)
+	remainingArgs, err := parser.Parse()
+	if err != nil {
+		if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
+			parser.WriteHelp(os.Stderr)
+		}
+		return nil, nil, err
+	}
+
+	// Multiple networks can't be selected simultaneously.
+	funcName := "loadConfig"
+	numNets := 0
+	// Count number of network flags passed; assign active network params
+	// while we're at it
+	if cfg.TestNet3 {
+		numNets++
+		activeNetParams = &chaincfg.TestNet3Params
+	}
+	if cfg.RegressionTest {
+		numNets++
+		activeNetParams = &chaincfg.RegressionNetParams
+	}
+	if cfg.SimNet {
+		numNets++
+		activeNetParams = &chaincfg.SimNetParams
+	}
+	if cfg.SigNet {
+		numNets++
+		activeNetParams = &chaincfg.SigNetParams
+	}
+	if numNets > 1 {
+		str := "%s: The testnet, regtest, simnet, and signet params " +
+			"can't be used together -- choose one of the four"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
+	// Validate database type.
+	if !validDbType(cfg.DbType) {
+		str := "%s: The specified database type [%v] is invalid -- " +
+			"supported types %v"
+		err := fmt.Errorf(str, funcName, cfg.DbType, knownDbTypes)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
+	// Validate the report format.
+	if cfg.Format != "json" && cfg.Format != "csv" {
+		str := "%s: The specified format [%v] is invalid -- " +
+			"supported formats are json and csv"
+		err := fmt.Errorf(str, funcName, cfg.Format)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
+	// Validate the fee dust is defined by.
+	if _, err := czzutil.NewAmount(cfg.MinRelayTxFee); err != nil ||
+		cfg.MinRelayTxFee < 0 {
+
+		str := "%s: The specified minimum relay fee [%v] is invalid"
+		err := fmt.Errorf(str, funcName, cfg.MinRelayTxFee)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
+	// Append the network type to the data directory so it is "namespaced"
+	// per network.  In addition to the block database, there are other
+	// pieces of data that are saved to disk such as address manager state.
+	// All data is specific to a network, so namespacing the data directory
+	// means each individual piece of serialized data does not have to
+	// worry about changing names per network and such.
+	cfg.DataDir = filepath.Join(cfg.DataDir, netName(activeNetParams))
+
+	return &cfg, remainingArgs, nil
+}
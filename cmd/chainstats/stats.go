@@ -0,0 +1,302 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/cross"
+	"github.com/bourbaki-czz/classzz/mempool"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// firstPayoutIndex is the index of the first output of the coinbase
+// transactions after the entangle height which pays out an entangle
+// transaction.  It follows the reward, the two coin pools and the keeped
+// amounts.
+const firstPayoutIndex = 4
+
+// These are the origins unspent outputs are grouped by.  The origin of the
+// entangle payouts is suffixed by the chain the coins were entangled from.
+const (
+	originMined       = "mined"
+	originPool        = "pool"
+	originKeeped      = "keeped"
+	originEntangled   = "entangled-"
+	originTransaction = "transaction"
+)
+
+// ageBucket is an upper bound of the age of the unspent outputs counted in a
+// bucket of the age distribution.
+type ageBucket struct {
+	name   string
+	maxAge time.Duration
+}
+
+// ageBuckets are the buckets of the age distribution, ordered by age.  The
+// outputs older than the last bound are counted in a final bucket.
+var ageBuckets = []ageBucket{
+	{"<1d", 24 * time.Hour},
+	{"<1w", 7 * 24 * time.Hour},
+	{"<1m", 30 * 24 * time.Hour},
+	{"<6m", 182 * 24 * time.Hour},
+	{"<1y", 365 * 24 * time.Hour},
+	{"<2y", 2 * 365 * 24 * time.Hour},
+}
+
+// Bucket is the number and total amount of the unspent outputs of a group.
+type Bucket struct {
+	Name   string         `json:"name"`
+	Count  int64          `json:"count"`
+	Amount czzutil.Amount `json:"amount"`
+}
+
+// add counts an unspent output of the passed amount in the bucket.
+func (b *Bucket) add(amount int64) {
+	b.Count++
+	b.Amount += czzutil.Amount(amount)
+}
+
+// Report is the result of an analysis of the utxo set.
+type Report struct {
+	Hash        string   `json:"hash"`
+	Height      int32    `json:"height"`
+	Utxos       Bucket   `json:"utxos"`
+	Ages        []Bucket `json:"ages"`
+	Origins     []Bucket `json:"origins"`
+	ScriptTypes []Bucket `json:"scripttypes"`
+	Dust        Bucket   `json:"dust"`
+}
+
+// payout is an unspent coinbase output which may pay out an entangle
+// transaction.  The chain the coins were entangled from is only known once the
+// block is read.
+type payout struct {
+	index  uint32
+	amount int64
+}
+
+// analyzer accumulates the statistics of the unspent outputs it is passed.
+type analyzer struct {
+	params        *chaincfg.Params
+	minRelayTxFee czzutil.Amount
+
+	// times are the timestamps of the blocks of the main chain by height,
+	// against which ages are measured from the last.
+	times []time.Time
+
+	utxos       Bucket
+	ages        []Bucket
+	origins     map[string]*Bucket
+	scriptTypes map[string]*Bucket
+	dust        Bucket
+
+	// payouts are the unspent coinbase outputs which may pay out entangle
+	// transactions by the height of their block.
+	payouts map[int32][]payout
+}
+
+// newAnalyzer returns an analyzer measuring ages against the passed block
+// timestamps of the main chain ordered by height.
+func newAnalyzer(params *chaincfg.Params, minRelayTxFee czzutil.Amount,
+	times []time.Time) *analyzer {
+
+	ages := make([]Bucket, len(ageBuckets)+1)
+	for i, bucket := range ageBuckets {
+		ages[i].Name = bucket.name
+	}
+	ages[len(ageBuckets)].Name = ">=" + ageBuckets[len(ageBuckets)-1].name[1:]
+	return &analyzer{
+		params:        params,
+		minRelayTxFee: minRelayTxFee,
+		times:         times,
+		utxos:         Bucket{Name: "utxos"},
+		ages:          ages,
+		origins:       make(map[string]*Bucket),
+		scriptTypes:   make(map[string]*Bucket),
+		dust:          Bucket{Name: "dust"},
+		payouts:       make(map[int32][]payout),
+	}
+}
+
+// addTo counts an unspent output of the passed amount in the named bucket of
+// the passed buckets.
+func addTo(buckets map[string]*Bucket, name string, amount int64) {
+	bucket, ok := buckets[name]
+	if !ok {
+		bucket = &Bucket{Name: name}
+		buckets[name] = bucket
+	}
+	bucket.add(amount)
+}
+
+// add accumulates the statistics of the passed unspent output.
+func (a *analyzer) add(outpoint *wire.OutPoint, entry *blockchain.UtxoEntry) {
+	amount := entry.Amount()
+	a.utxos.add(amount)
+
+	// Measure the age against the timestamp of the best block rather than
+	// the current time, so reports of the same chain state are the same.
+	height := entry.BlockHeight()
+	if height >= 0 && int(height) < len(a.times) {
+		age := a.times[len(a.times)-1].Sub(a.times[height])
+		i := sort.Search(len(ageBuckets), func(i int) bool {
+			return age < ageBuckets[i].maxAge
+		})
+		a.ages[i].add(amount)
+	}
+
+	switch {
+	case !entry.IsCoinBase():
+		addTo(a.origins, originTransaction, amount)
+	case outpoint.Index == 0:
+		addTo(a.origins, originMined, amount)
+	case outpoint.Index <= 2:
+		addTo(a.origins, originPool, amount)
+	case height < a.params.EntangleHeight:
+		addTo(a.origins, originMined, amount)
+	case outpoint.Index < firstPayoutIndex:
+		addTo(a.origins, originKeeped, amount)
+	default:
+		a.payouts[height] = append(a.payouts[height],
+			payout{index: outpoint.Index, amount: amount})
+	}
+
+	pkScript := entry.PkScript()
+	addTo(a.scriptTypes, txscript.GetScriptClass(pkScript).String(), amount)
+	if mempool.IsDust(wire.NewTxOut(amount, pkScript), a.minRelayTxFee) {
+		a.dust.add(amount)
+	}
+}
+
+// payoutChains returns the names of the chains the entangle payouts of the
+// coinbase transaction of the passed block were entangled from, in the order
+// of the payouts.  The payouts follow the entangle outputs of the transactions
+// of the block in order, but the order of the outputs of a transaction with
+// entangle outputs of several chains is not defined, so their chains are
+// unknown.
+func payoutChains(block *czzutil.Block) []string {
+	var chains []string
+	for _, tx := range block.Transactions()[1:] {
+		infos, err := cross.IsEntangleTx(tx.MsgTx())
+		if err != nil {
+			continue
+		}
+		name := ""
+		for _, info := range infos {
+			switch typeName := info.ExTxType.String(); {
+			case name == "":
+				name = typeName
+			case name != typeName:
+				name = "unknown"
+			}
+		}
+		for range infos {
+			chains = append(chains, name)
+		}
+	}
+	return chains
+}
+
+// addPayouts attributes the unspent entangle payouts of the passed block to
+// the chains the coins were entangled from.
+func (a *analyzer) addPayouts(block *czzutil.Block) {
+	chains := payoutChains(block)
+	for _, p := range a.payouts[block.Height()] {
+		name := "unknown"
+		if i := int(p.index) - firstPayoutIndex; i < len(chains) {
+			name = chains[i]
+		}
+		addTo(a.origins, originEntangled+name, p.amount)
+	}
+}
+
+// payoutHeights returns the heights of the blocks with unspent entangle
+// payouts in ascending order.
+func (a *analyzer) payoutHeights() []int32 {
+	heights := make([]int32, 0, len(a.payouts))
+	for height := range a.payouts {
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool {
+		return heights[i] < heights[j]
+	})
+	return heights
+}
+
+// sortedBuckets returns the passed buckets ordered by name.
+func sortedBuckets(buckets map[string]*Bucket) []Bucket {
+	sorted := make([]Bucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		sorted = append(sorted, *bucket)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// report returns the report of the statistics accumulated so far for the
+// utxo set as of the passed block.
+func (a *analyzer) report(hash string, height int32) *Report {
+	return &Report{
+		Hash:        hash,
+		Height:      height,
+		Utxos:       a.utxos,
+		Ages:        a.ages,
+		Origins:     sortedBuckets(a.origins),
+		ScriptTypes: sortedBuckets(a.scriptTypes),
+		Dust:        a.dust,
+	}
+}
+
+// writeJSON writes the report as an indented json object.
+func (r *Report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(r)
+}
+
+// writeCSV writes the report as csv records of the form
+// section,name,count,amount with the amounts in CZZ.  The first records
+// identify the block the report is for.
+func (r *Report) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	records := [][]string{
+		{"section", "name", "count", "amount"},
+		{"block", "hash", "", r.Hash},
+		{"block", "height", "", strconv.Itoa(int(r.Height))},
+	}
+	sections := []struct {
+		name    string
+		buckets []Bucket
+	}{
+		{"summary", []Bucket{r.Utxos, r.Dust}},
+		{"age", r.Ages},
+		{"origin", r.Origins},
+		{"scripttype", r.ScriptTypes},
+	}
+	for _, section := range sections {
+		for _, bucket := range section.buckets {
+			records = append(records, []string{section.name,
+				bucket.Name, strconv.FormatInt(bucket.Count, 10),
+				strconv.FormatFloat(bucket.Amount.ToCZZ(), 'f', 8, 64)})
+		}
+	}
+	if err := cw.WriteAll(records); err != nil {
+		return err
+	}
+	return cw.Error()
+}
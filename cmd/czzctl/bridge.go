@@ -0,0 +1,119 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bourbaki-czz/classzz/btcjson"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// extAmountDecimals is the number of decimal places of the base units of the
+// amounts of the chains coins are entangled from.
+const extAmountDecimals = 8
+
+// humanFormatters are the functions formatting the results of the bridge
+// commands as human-readable text when the --human flag is specified.
+var humanFormatters = map[string]func(result []byte) (string, error){
+	"getentangleinfo": formatEntangleInfo,
+	"getpoolbalance":  formatPoolBalance,
+	"listentangletxs": formatEntangleTxs,
+}
+
+// formatExtAmount returns the passed amount in the base unit of the chain of
+// the passed entangle type as a decimal number followed by the ticker of the
+// chain, such as 12.5 DOGE.
+func formatExtAmount(amount int64, exTxType string) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	var unit int64 = 1
+	for i := 0; i < extAmountDecimals; i++ {
+		unit *= 10
+	}
+	str := fmt.Sprintf("%s%d.%0*d", sign, amount/unit, extAmountDecimals,
+		amount%unit)
+	str = strings.TrimRight(strings.TrimRight(str, "0"), ".")
+	return str + " " + strings.ToUpper(exTxType)
+}
+
+// formatCZZAmount returns the passed amount in CZZ followed by the unit.
+func formatCZZAmount(amount float64) string {
+	a, err := czzutil.NewAmount(amount)
+	if err != nil {
+		return fmt.Sprintf("%v CZZ", amount)
+	}
+	return a.String()
+}
+
+// formatTable formats the passed rows as columns separated by spaces.
+func formatTable(rows [][]string) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// formatEntangleInfo formats the result of the getentangleinfo command.
+func formatEntangleInfo(result []byte) (string, error) {
+	var infos []btcjson.EntangleInfoChainResult
+	if err := json.Unmarshal(result, &infos); err != nil {
+		return "", err
+	}
+	rows := [][]string{{"CHAIN", "ENTANGLED"}}
+	for _, info := range infos {
+		rows = append(rows, []string{info.ExTxType,
+			formatExtAmount(info.Amount, info.ExTxType)})
+	}
+	return formatTable(rows), nil
+}
+
+// formatPoolBalance formats the result of the getpoolbalance command.
+func formatPoolBalance(result []byte) (string, error) {
+	var balance btcjson.GetPoolBalanceResult
+	if err := json.Unmarshal(result, &balance); err != nil {
+		return "", err
+	}
+	rows := [][]string{{"POOL", "ADDRESS", "BALANCE"}}
+	for i, pool := range balance.Pools {
+		rows = append(rows, []string{fmt.Sprint(i + 1), pool.Address,
+			formatCZZAmount(pool.Amount)})
+	}
+	return fmt.Sprintf("Height: %d\n%s", balance.Height, formatTable(rows)),
+		nil
+}
+
+// formatEntangleTxs formats the result of the listentangletxs command.
+func formatEntangleTxs(result []byte) (string, error) {
+	var txs []btcjson.EntangleTxResult
+	if err := json.Unmarshal(result, &txs); err != nil {
+		return "", err
+	}
+	if len(txs) == 0 {
+		return "No entangle transactions", nil
+	}
+	rows := [][]string{{"HEIGHT", "OUTPOINT", "AMOUNT", "EXTERNAL TX",
+		"EXTERNAL HEIGHT"}}
+	for _, tx := range txs {
+		rows = append(rows, []string{
+			fmt.Sprint(tx.Height),
+			fmt.Sprintf("%s:%d", tx.TxID, tx.Vout),
+			formatExtAmount(tx.Amount, tx.ExTxType),
+			fmt.Sprintf("%s:%d", tx.ExtTxHash, tx.ExtTxIndex),
+			fmt.Sprint(tx.ExtHeight),
+		})
+	}
+	return formatTable(rows), nil
+}
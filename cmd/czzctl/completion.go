@@ -0,0 +1,118 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/bourbaki-czz/classzz/btcjson"
+	flags "github.com/jessevdk/go-flags"
+)
+
+// bashCompletion is the template of the bash completion script.  It completes
+// the options, and the command when none has been given yet.  The options
+// taking an argument are skipped along with their argument while looking for
+// the command, and the arguments of the command are completed as files.
+const bashCompletion = `# bash completion for %[1]s
+_%[1]s() {
+	local cur="${COMP_WORDS[COMP_CWORD]}"
+	local opts="%[2]s"
+	local argopts=" %[3]s "
+	local cmds="%[4]s"
+	local i word command=""
+
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		word="${COMP_WORDS[i]}"
+		case "$word" in
+		--*=*) ;;
+		-*)
+			if [[ "$argopts" == *" $word "* ]]; then
+				((i++))
+			fi
+			;;
+		*)
+			command="$word"
+			break
+			;;
+		esac
+	done
+
+	if [[ "$cur" == -* ]]; then
+		COMPREPLY=($(compgen -W "$opts" -- "$cur"))
+	elif [[ -z "$command" ]]; then
+		COMPREPLY=($(compgen -W "$cmds" -- "$cur"))
+	else
+		COMPREPLY=($(compgen -f -- "$cur"))
+	fi
+}
+complete -F _%[1]s %[1]s
+`
+
+// zshCompletion is the prefix of the zsh completion script, which reuses the
+// bash completion script through the bash completion emulation of zsh.
+const zshCompletion = "autoload -U +X bashcompinit && bashcompinit\n"
+
+// usableCommands returns the registered commands which are usable from this
+// utility.
+func usableCommands() []string {
+	var methods []string
+	for _, method := range btcjson.RegisteredCmdMethods() {
+		usageFlags, err := btcjson.MethodUsageFlags(method)
+		if err != nil || usageFlags&unusableFlags != 0 {
+			continue
+		}
+		methods = append(methods, method)
+	}
+	return methods
+}
+
+// optionNames appends the names of the options of the passed group and its
+// subgroups to opts, and the names of those taking an argument to argOpts.
+func optionNames(group *flags.Group, opts, argOpts []string) ([]string, []string) {
+	for _, option := range group.Options() {
+		var names []string
+		if option.ShortName != 0 {
+			names = append(names, "-"+string(option.ShortName))
+		}
+		if option.LongName != "" {
+			names = append(names, "--"+option.LongName)
+		}
+		opts = append(opts, names...)
+
+		// Boolean options and those calling a function, such as the
+		// help option, do not take an argument.
+		kind := option.Field().Type.Kind()
+		if kind != reflect.Bool && kind != reflect.Func {
+			argOpts = append(argOpts, names...)
+		}
+	}
+	for _, subgroup := range group.Groups() {
+		opts, argOpts = optionNames(subgroup, opts, argOpts)
+	}
+	return opts, argOpts
+}
+
+// writeCompletion writes the completion script of the passed shell for the
+// options of the passed parser and the usable commands.  The supported shells
+// are bash and zsh.
+func writeCompletion(w io.Writer, shell, appName string, parser *flags.Parser) error {
+	opts, argOpts := optionNames(parser.Group, nil, nil)
+
+	script := fmt.Sprintf(bashCompletion, appName, strings.Join(opts, " "),
+		strings.Join(argOpts, " "), strings.Join(usableCommands(), " "))
+	switch shell {
+	case "bash":
+	case "zsh":
+		script = zshCompletion + script
+	default:
+		return fmt.Errorf("unsupported shell %q -- supported shells "+
+			"are bash and zsh", shell)
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
@@ -108,6 +108,8 @@ type config struct {
 	SimNet        bool   `long:"simnet" description:"Connect to the simulation test network"`
 	TLSSkipVerify bool   `long:"skipverify" description:"Do not verify tls certificates (not recommended!)"`
 	Wallet        bool   `long:"wallet" description:"Connect to wallet"`
+	Human         bool   `long:"human" description:"Format the results of the bridge commands (getentangleinfo, getpoolbalance, listentangletxs) as human-readable text"`
+	Completion    string `long:"completion" description:"Print the shell completion script for the given shell (bash or zsh) and exit"`
 }
 
 // normalizeAddress returns addr with the passed default port appended if
@@ -211,6 +213,18 @@ func loadConfig() (*config, []string, error) {
 		os.Exit(0)
 	}
 
+	// Print the completion script and exit if the associated flag was
+	// specified.
+	if preCfg.Completion != "" {
+		err := writeCompletion(os.Stdout, preCfg.Completion, appName,
+			preParser)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, err
+		}
+		os.Exit(0)
+	}
+
 	if _, err := os.Stat(preCfg.ConfigFile); os.IsNotExist(err) {
 		// Use config file for RPC server to create default czzctl config
 		var serverConfigPath string
@@ -141,6 +141,18 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Format the results of the bridge commands for humans when requested.
+	if format, ok := humanFormatters[method]; ok && cfg.Human {
+		str, err := format(result)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to format result: %v\n",
+				err)
+			os.Exit(1)
+		}
+		fmt.Println(str)
+		return
+	}
+
 	// Choose how to display the result based on its type.
 	strResult := string(result)
 	if strings.HasPrefix(strResult, "{") || strings.HasPrefix(strResult, "[") {
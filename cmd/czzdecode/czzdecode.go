@@ -0,0 +1,99 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/txdecode"
+	flags "github.com/jessevdk/go-flags"
+)
+
+type config struct {
+	Block          bool   `short:"b" long:"block" description:"Decode a block instead of a transaction"`
+	File           string `short:"f" long:"file" description:"File holding the hex to decode instead of the command line or stdin"`
+	TestNet3       bool   `long:"testnet" description:"Encode the addresses for the test network"`
+	RegressionTest bool   `long:"regtest" description:"Encode the addresses for the regression test network"`
+	SimNet         bool   `long:"simnet" description:"Encode the addresses for the simulation test network"`
+}
+
+func main() {
+	var cfg config
+	parser := flags.NewParser(&cfg, flags.Default)
+	parser.Usage = "[OPTIONS] [hex]\n\nDecodes the hex of a raw " +
+		"transaction or block read from the command line, a file or stdin."
+	args, err := parser.Parse()
+	if err != nil {
+		if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
+			parser.WriteHelp(os.Stderr)
+		}
+		return
+	}
+
+	params := &chaincfg.MainNetParams
+	numNets := 0
+	if cfg.TestNet3 {
+		numNets++
+		params = &chaincfg.TestNet3Params
+	}
+	if cfg.RegressionTest {
+		numNets++
+		params = &chaincfg.RegressionNetParams
+	}
+	if cfg.SimNet {
+		numNets++
+		params = &chaincfg.SimNetParams
+	}
+	if numNets > 1 {
+		fmt.Fprintln(os.Stderr, "the testnet, regtest, and simnet "+
+			"params can't be used together -- choose one of the three")
+		os.Exit(1)
+	}
+
+	var hexStr string
+	switch {
+	case len(args) > 1 || (len(args) == 1 && cfg.File != ""):
+		fmt.Fprintln(os.Stderr, "only one hex string can be decoded")
+		os.Exit(1)
+	case len(args) == 1 && args[0] != "-":
+		hexStr = args[0]
+	case cfg.File != "":
+		data, err := ioutil.ReadFile(cfg.File)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot read %s: %v\n", cfg.File, err)
+			os.Exit(1)
+		}
+		hexStr = string(data)
+	default:
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot read stdin: %v\n", err)
+			os.Exit(1)
+		}
+		hexStr = string(data)
+	}
+
+	var result interface{}
+	if cfg.Block {
+		result, err = txdecode.DecodeBlockHex(hexStr, params)
+	} else {
+		result, err = txdecode.DecodeTxHex(hexStr, params)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	output, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println(string(output))
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzlog"
+	"github.com/bourbaki-czz/czzutil"
+	flags "github.com/jessevdk/go-flags"
+)
+
+const (
+	defaultListen        = ":53"
+	defaultMaxCrawls     = 8
+	defaultCrawlInterval = 15 * time.Minute
+	defaultDebugLevel    = "info"
+)
+
+var (
+	defaultHomeDir  = czzutil.AppDataDir("czzseeder", false)
+	activeNetParams = &chaincfg.MainNetParams
+)
+
+// config defines the configuration options for czzseeder.
+//
+// See loadConfig for details on the configuration load process.
+type config struct {
+	DataDir        string        `short:"b" long:"datadir" description:"Directory to store the addresses of the crawled nodes in"`
+	Host           string        `short:"H" long:"host" description:"Host name of the seed the addresses of the nodes are served for"`
+	Nameserver     string        `short:"n" long:"nameserver" description:"Host name of the name server the seed is delegated to"`
+	Listen         string        `short:"l" long:"listen" description:"Interface/port to answer the DNS queries on"`
+	Seeders        []string      `short:"s" long:"seeder" description:"Add a node to start crawling the network from, in addition to the seeds of the network"`
+	MaxCrawls      int           `long:"maxcrawls" description:"Maximum number of nodes crawled at the same time"`
+	CrawlInterval  time.Duration `long:"crawlinterval" description:"Interval at which every known node is crawled again"`
+	TestNet3       bool          `long:"testnet" description:"Use the test network"`
+	RegressionTest bool          `long:"regtest" description:"Use the regression test network"`
+	SimNet         bool          `long:"simnet" description:"Use the simulation test network"`
+	SigNet         bool          `long:"signet" description:"Use the signed test network"`
+	DebugLevel     string        `short:"d" long:"debuglevel" description:"Logging level {trace, debug, info, warn, error, critical}"`
+}
+
+// netName returns the name used when referring to a bitcoin network.  At the
+// time of writing, classzz currently places blocks for testnet version 3 in the
+// data and log directory "testnet", which does not match the Name field of the
+// chaincfg parameters.  This function can be used to override this directory name
+// as "testnet" when the passed active network matches wire.TestNet3.
+func netName(chainParams *chaincfg.Params) string {
+	switch chainParams.Net {
+	case wire.TestNet3:
+		return "testnet"
+	default:
+		return chainParams.Name
+	}
+}
+
+// loadConfig initializes and parses the config using command line options.
+func loadConfig() (*config, []string, error) {
+	// Default config.
+	cfg := config{
+		DataDir:       defaultHomeDir,
+		Listen:        defaultListen,
+		MaxCrawls:     defaultMaxCrawls,
+		CrawlInterval: defaultCrawlInterval,
+		DebugLevel:    defaultDebugLevel,
+	}
+
+	// Parse command line options.
+	parser := flags.NewParser(&cfg, flags.Default)
+	remainingArgs, err := parser.Parse()
+	if err != nil {
+		if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
+			parser.WriteHelp(os.Stderr)
+		}
+		return nil, nil, err
+	}
+
+	// Multiple networks can't be selected simultaneously.
+	funcName := "loadConfig"
+	numNets := 0
+	// Count number of network flags passed; assign active network params
+	// while we're at it
+	if cfg.TestNet3 {
+		numNets++
+		activeNetParams = &chaincfg.TestNet3Params
+	}
+	if cfg.RegressionTest {
+		numNets++
+		activeNetParams = &chaincfg.RegressionNetParams
+	}
+	if cfg.SimNet {
+		numNets++
+		activeNetParams = &chaincfg.SimNetParams
+	}
+	if cfg.SigNet {
+		numNets++
+		activeNetParams = &chaincfg.SigNetParams
+	}
+	if numNets > 1 {
+		str := "%s: The testnet, regtest, simnet, and signet params " +
+			"can't be used together -- choose one of the four"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
+	// The seed and name server host names are required to answer the
+	// queries for the zone of the seed.
+	if cfg.Host == "" || cfg.Nameserver == "" {
+		str := "%s: Both the --host and --nameserver options must be " +
+			"specified"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+	cfg.Host = strings.ToLower(strings.TrimSuffix(cfg.Host, "."))
+	cfg.Nameserver = strings.ToLower(strings.TrimSuffix(cfg.Nameserver, "."))
+
+	// Validate the address the queries are answered on.
+	if _, _, err := net.SplitHostPort(cfg.Listen); err != nil {
+		str := "%s: The specified listen address [%v] is invalid: %v"
+		err := fmt.Errorf(str, funcName, cfg.Listen, err)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
+	// Validate the crawling limits.
+	if cfg.MaxCrawls < 1 || cfg.CrawlInterval < time.Minute {
+		str := "%s: The maximum number of crawls must be at least 1 " +
+			"and the crawl interval at least one minute"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
+	// Validate the debug level.
+	if _, ok := czzlog.LevelFromString(cfg.DebugLevel); !ok {
+		str := "%s: The specified debug level [%v] is invalid"
+		err := fmt.Errorf(str, funcName, cfg.DebugLevel)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
+	// Append the network type to the data directory so the addresses of
+	// the nodes of every network are kept apart.
+	cfg.DataDir = filepath.Join(cfg.DataDir, netName(activeNetParams))
+
+	return &cfg, remainingArgs, nil
+}
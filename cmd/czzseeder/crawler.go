@@ -0,0 +1,386 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/addrmgr"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/connmgr"
+	"github.com/bourbaki-czz/classzz/peer"
+	"github.com/bourbaki-czz/classzz/version"
+	"github.com/bourbaki-czz/classzz/wire"
+)
+
+const (
+	// dialTimeout is the time allowed to connect to a node.
+	dialTimeout = 10 * time.Second
+
+	// handshakeTimeout is the time allowed for a node to complete the
+	// version handshake once connected.
+	handshakeTimeout = 20 * time.Second
+
+	// addrTimeout is the time a node is given to answer the getaddr
+	// message once the handshake completed.
+	addrTimeout = 30 * time.Second
+
+	// scheduleInterval is the interval at which nodes are picked to be
+	// crawled.
+	scheduleInterval = 5 * time.Second
+
+	// maxPicksPerSchedule is the number of addresses picked from the
+	// address manager every schedule.
+	maxPicksPerSchedule = 64
+
+	// staleFactor is the number of crawl intervals after which a node which
+	// could not be crawled again is no longer served.
+	staleFactor = 3
+)
+
+var (
+	// errHandshakeTimeout is returned when a node does not complete the
+	// version handshake in time.
+	errHandshakeTimeout = errors.New("version handshake timed out")
+
+	// errCrawlerStopped is returned when a crawl is interrupted because
+	// the crawler is stopped.
+	errCrawlerStopped = errors.New("crawler stopped")
+)
+
+// node is a node which was crawled successfully.
+type node struct {
+	na          *wire.NetAddress
+	services    wire.ServiceFlag
+	userAgent   string
+	lastBlock   int32
+	lastSuccess time.Time
+}
+
+// crawler crawls the network from the addresses of the address manager and
+// keeps the nodes which completed the version handshake recently.  It is safe
+// for concurrent access.
+type crawler struct {
+	amgr          *addrmgr.AddrManager
+	chainParams   *chaincfg.Params
+	crawlInterval time.Duration
+	sem           chan struct{}
+	quit          chan struct{}
+	wg            sync.WaitGroup
+
+	mtx      sync.RWMutex
+	nodes    map[string]*node
+	inFlight map[string]struct{}
+
+	// seeders are the nodes passed to start crawling from, which are
+	// crawled even if the address manager does not keep them, as it does
+	// for unroutable addresses, with the time they were last attempted.
+	seeders map[string]*seedNode
+}
+
+// seedNode is a node passed to start crawling from.
+type seedNode struct {
+	na          *wire.NetAddress
+	lastAttempt time.Time
+}
+
+// newCrawler returns a new crawler using the passed address manager, which
+// crawls up to maxCrawls nodes at the same time and every node once per
+// crawlInterval.
+func newCrawler(amgr *addrmgr.AddrManager, chainParams *chaincfg.Params,
+	maxCrawls int, crawlInterval time.Duration) *crawler {
+
+	return &crawler{
+		amgr:          amgr,
+		chainParams:   chainParams,
+		crawlInterval: crawlInterval,
+		sem:           make(chan struct{}, maxCrawls),
+		quit:          make(chan struct{}),
+		nodes:         make(map[string]*node),
+		inFlight:      make(map[string]struct{}),
+		seeders:       make(map[string]*seedNode),
+	}
+}
+
+// bootstrap adds the passed seeders and, if the address manager knows too few
+// addresses, the addresses served by the DNS seeds of the network to the
+// address manager.
+func (c *crawler) bootstrap(seeders []string) {
+	for _, seeder := range seeders {
+		host, portStr, err := net.SplitHostPort(seeder)
+		if err != nil {
+			host, portStr = seeder, c.chainParams.DefaultPort
+		}
+		seederPort, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil {
+			log.Warnf("Invalid port of seeder %s: %v", seeder, err)
+			continue
+		}
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			log.Warnf("Unable to resolve seeder %s: %v", seeder, err)
+			continue
+		}
+		for _, ip := range ips {
+			na := wire.NewNetAddressIPPort(ip, uint16(seederPort),
+				wire.SFNodeNetwork)
+			c.amgr.AddAddress(na, na)
+			c.seeders[addrmgr.NetAddressKey(na)] = &seedNode{na: na}
+		}
+	}
+
+	if !c.amgr.NeedMoreAddresses() {
+		return
+	}
+	connmgr.SeedFromDNS(c.chainParams, wire.SFNodeNetwork, net.LookupIP,
+		func(addrs []*wire.NetAddress) {
+			// Bitcoind uses a lookup of the dns seeder here.  This
+			// is rather strange since the values looked up by the
+			// DNS seed lookups will vary quite a lot.  To replicate
+			// this behaviour we put all addresses as having
+			// come from the first one.
+			c.amgr.AddAddresses(addrs, addrs[0])
+		})
+}
+
+// Start begins crawling the network.
+func (c *crawler) Start() {
+	c.wg.Add(1)
+	go c.scheduler()
+}
+
+// Stop stops crawling the network and waits for the crawls in progress to
+// finish.
+func (c *crawler) Stop() {
+	close(c.quit)
+	c.wg.Wait()
+}
+
+// scheduler periodically picks the nodes to crawl until the crawler is
+// stopped.  It must be run as a goroutine.
+func (c *crawler) scheduler() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+	for {
+		for _, na := range c.pickAddresses() {
+			select {
+			case c.sem <- struct{}{}:
+			case <-c.quit:
+				return
+			}
+			c.wg.Add(1)
+			go func(na *wire.NetAddress) {
+				defer c.wg.Done()
+				c.crawl(na)
+				<-c.sem
+			}(na)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// pickAddresses returns the addresses to crawl next, which are the served
+// nodes due to be crawled again and random addresses of the address manager
+// which were not attempted during the last crawl interval.  The picked
+// addresses are marked in flight.
+func (c *crawler) pickAddresses() []*wire.NetAddress {
+	now := time.Now()
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var addrs []*wire.NetAddress
+	pick := func(na *wire.NetAddress) {
+		// The overlay networks can't be dialed without a proxy.
+		if addrmgr.IsTor(na) || addrmgr.IsI2P(na) {
+			return
+		}
+		key := addrmgr.NetAddressKey(na)
+		if _, ok := c.inFlight[key]; ok {
+			return
+		}
+		c.inFlight[key] = struct{}{}
+		addrs = append(addrs, na)
+	}
+
+	for key, n := range c.nodes {
+		// Stop serving the nodes which could not be crawled for a
+		// while.
+		if now.Sub(n.lastSuccess) > staleFactor*c.crawlInterval {
+			delete(c.nodes, key)
+			continue
+		}
+		if now.Sub(n.lastSuccess) > c.crawlInterval {
+			pick(n.na)
+		}
+	}
+
+	for key, s := range c.seeders {
+		if _, ok := c.nodes[key]; ok {
+			continue
+		}
+		if now.Sub(s.lastAttempt) > c.crawlInterval {
+			s.lastAttempt = now
+			pick(s.na)
+		}
+	}
+
+	for i := 0; i < maxPicksPerSchedule; i++ {
+		ka := c.amgr.GetAddress()
+		if ka == nil {
+			break
+		}
+		if now.Sub(ka.LastAttempt()) < c.crawlInterval {
+			continue
+		}
+		pick(ka.NetAddress())
+	}
+	return addrs
+}
+
+// crawl connects to the node at the passed address, completes the version
+// handshake, asks for the addresses known to the node and records whether the
+// node is alive.
+func (c *crawler) crawl(na *wire.NetAddress) {
+	key := addrmgr.NetAddressKey(na)
+	defer func() {
+		c.mtx.Lock()
+		delete(c.inFlight, key)
+		c.mtx.Unlock()
+	}()
+
+	c.amgr.Attempt(na)
+	n, err := c.handshake(na)
+	if err != nil {
+		log.Debugf("Unable to crawl %s: %v", key, err)
+		c.mtx.Lock()
+		delete(c.nodes, key)
+		c.mtx.Unlock()
+		return
+	}
+
+	c.amgr.Connected(na)
+	c.amgr.Good(na)
+	c.amgr.SetServices(na, n.services)
+
+	c.mtx.Lock()
+	c.nodes[key] = n
+	c.mtx.Unlock()
+	log.Debugf("Crawled %s (%s, services %v, height %d)", key,
+		n.userAgent, n.services, n.lastBlock)
+}
+
+// handshake connects to the node at the passed address and returns it once the
+// version handshake completed and the node sent its addresses or did not in
+// time.
+func (c *crawler) handshake(na *wire.NetAddress) (*node, error) {
+	verack := make(chan struct{}, 1)
+	addrs := make(chan struct{}, 1)
+	onAddrs := func(p *peer.Peer, addrList []*wire.NetAddress) {
+		if len(addrList) > 0 {
+			c.amgr.AddAddresses(addrList, p.NA())
+		}
+		select {
+		case addrs <- struct{}{}:
+		default:
+		}
+	}
+	peerCfg := &peer.Config{
+		UserAgentName:    "czzseeder",
+		UserAgentVersion: version.String(),
+		ChainParams:      c.chainParams,
+		Services:         0,
+		DisableRelayTx:   true,
+		Listeners: peer.MessageListeners{
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				verack <- struct{}{}
+			},
+			OnAddr: func(p *peer.Peer, msg *wire.MsgAddr) {
+				onAddrs(p, msg.AddrList)
+			},
+			OnAddrV2: func(p *peer.Peer, msg *wire.MsgAddrV2) {
+				onAddrs(p, msg.AddrList)
+			},
+		},
+	}
+
+	addr := addrmgr.NetAddressKey(na)
+	p, err := peer.NewOutboundPeer(peerCfg, addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	p.AssociateConnection(conn)
+	defer func() {
+		p.Disconnect()
+		p.WaitForDisconnect()
+	}()
+
+	select {
+	case <-verack:
+	case <-time.After(handshakeTimeout):
+		return nil, errHandshakeTimeout
+	case <-c.quit:
+		return nil, errCrawlerStopped
+	}
+
+	p.QueueMessage(wire.NewMsgGetAddr(), nil)
+	select {
+	case <-addrs:
+	case <-time.After(addrTimeout):
+	case <-c.quit:
+	}
+
+	return &node{
+		na:          na,
+		services:    p.Services(),
+		userAgent:   p.UserAgent(),
+		lastBlock:   p.LastBlock(),
+		lastSuccess: time.Now(),
+	}, nil
+}
+
+// goodAddresses returns the addresses of the served nodes listening on the
+// default port of the network which advertise all the passed services, IPv4
+// ones when ipv4 is set and IPv6 ones otherwise.
+func (c *crawler) goodAddresses(services wire.ServiceFlag, ipv4 bool) []net.IP {
+	port, _ := strconv.ParseUint(c.chainParams.DefaultPort, 10, 16)
+
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	var ips []net.IP
+	for _, n := range c.nodes {
+		if n.na.Port != uint16(port) || n.services&services != services {
+			continue
+		}
+		if addrmgr.IsIPv4(n.na) != ipv4 {
+			continue
+		}
+		ips = append(ips, n.na.IP)
+	}
+	return ips
+}
+
+// numNodes returns the number of served nodes.
+func (c *crawler) numNodes() int {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	return len(c.nodes)
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math/rand"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/addrmgr"
+	"github.com/bourbaki-czz/classzz/connmgr"
+	"github.com/bourbaki-czz/classzz/peer"
+	"github.com/bourbaki-czz/czzlog"
+)
+
+// statsInterval is the interval at which the number of served nodes is
+// logged.
+const statsInterval = 10 * time.Minute
+
+var (
+	cfg *config
+	log czzlog.Logger
+)
+
+// realMain is the real main function for the utility.  It is necessary to work
+// around the fact that deferred functions do not run when os.Exit() is called.
+func realMain() error {
+	// Load configuration and parse command line.
+	tcfg, _, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	cfg = tcfg
+
+	// Setup logging.
+	backendLogger := czzlog.NewBackend(os.Stdout)
+	level, _ := czzlog.LevelFromString(cfg.DebugLevel)
+	log = backendLogger.Logger("SEED")
+	log.SetLevel(level)
+	amgrLog := backendLogger.Logger("AMGR")
+	amgrLog.SetLevel(level)
+	addrmgr.UseLogger(amgrLog)
+	cmgrLog := backendLogger.Logger("CMGR")
+	cmgrLog.SetLevel(level)
+	connmgr.UseLogger(cmgrLog)
+	peer.UseLogger(backendLogger.Logger("PEER"))
+
+	rand.Seed(time.Now().UnixNano())
+
+	if err := os.MkdirAll(cfg.DataDir, 0700); err != nil {
+		log.Errorf("Unable to create data directory: %v", err)
+		return err
+	}
+
+	// The address manager keeps the crawled addresses across restarts in
+	// the data directory.
+	amgr := addrmgr.New(cfg.DataDir, net.LookupIP)
+	amgr.Start()
+	defer amgr.Stop()
+
+	c := newCrawler(amgr, activeNetParams, cfg.MaxCrawls, cfg.CrawlInterval)
+	c.bootstrap(cfg.Seeders)
+	c.Start()
+	defer c.Stop()
+
+	conn, err := net.ListenPacket("udp", cfg.Listen)
+	if err != nil {
+		log.Errorf("Unable to listen for DNS queries: %v", err)
+		return err
+	}
+	s := newDNSServer(cfg.Host, cfg.Nameserver, c, conn)
+	go s.Serve()
+	defer conn.Close()
+	log.Infof("Serving the %s nodes for %s on %s", activeNetParams.Name,
+		cfg.Host, conn.LocalAddr())
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			log.Infof("Serving %d nodes out of %d known addresses",
+				c.numNodes(), amgr.NumAddresses())
+		case <-interrupt:
+			log.Infof("Received signal, shutting down...")
+			return nil
+		}
+	}
+}
+
+func main() {
+	// Work around defer not working after os.Exit()
+	if err := realMain(); err != nil {
+		os.Exit(1)
+	}
+}
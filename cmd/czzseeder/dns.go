@@ -0,0 +1,345 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/wire"
+)
+
+// These constants define the parts of the DNS protocol (RFC 1035) the seeder
+// implements.
+const (
+	dnsHeaderSize  = 12
+	maxUDPSize     = 512
+	maxLabelLength = 63
+
+	// Header flags.
+	dnsFlagResponse      = 1 << 15
+	dnsFlagAuthoritative = 1 << 10
+	dnsFlagRecursion     = 1 << 8
+	dnsOpcodeMask        = 0xf << 11
+
+	// Response codes.
+	dnsRcodeFormErr  = 1
+	dnsRcodeNXDomain = 3
+	dnsRcodeNotImp   = 4
+	dnsRcodeRefused  = 5
+
+	// Record types and class.
+	dnsTypeA    = 1
+	dnsTypeNS   = 2
+	dnsTypeSOA  = 6
+	dnsTypeAAAA = 28
+	dnsTypeANY  = 255
+	dnsClassIN  = 1
+
+	// nameOffset is the compression pointer to the name of the question,
+	// which always follows the header.
+	nameOffset = 0xc000 | dnsHeaderSize
+
+	// addrTTL is the time the addresses of the nodes may be cached, short
+	// so that the clients get fresh nodes.
+	addrTTL = 60
+
+	// zoneTTL is the time the name server and authority of the zone may be
+	// cached.
+	zoneTTL = 3600
+
+	// maxAnswers is the maximum number of addresses served in a response.
+	maxAnswers = 25
+)
+
+// errMalformedQuery is returned when a DNS query can't be parsed.
+var errMalformedQuery = errors.New("malformed DNS query")
+
+// dnsQuestion is the question of a DNS query.
+type dnsQuestion struct {
+	name  string
+	qtype uint16
+	class uint16
+}
+
+// dnsServer answers the DNS queries for the zone of the seed with the
+// addresses of the nodes served by the crawler.
+//
+// The nodes advertising some services are served on the x<hex services>
+// subdomain of the seed, as the connmgr package expects of the seeds supporting
+// filtering, and the full nodes on the seed itself.
+type dnsServer struct {
+	host       string
+	nameserver string
+	crawler    *crawler
+	conn       net.PacketConn
+}
+
+// newDNSServer returns a DNS server answering the queries on the passed
+// connection for the zone of the passed host, delegated to the passed name
+// server, from the nodes of the passed crawler.
+func newDNSServer(host, nameserver string, crawler *crawler, conn net.PacketConn) *dnsServer {
+	return &dnsServer{
+		host:       host,
+		nameserver: nameserver,
+		crawler:    crawler,
+		conn:       conn,
+	}
+}
+
+// Serve answers the queries received on the connection of the server until it
+// is closed.
+func (s *dnsServer) Serve() {
+	buf := make([]byte, maxUDPSize)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			if !strings.Contains(err.Error(), "use of closed network connection") {
+				log.Errorf("Unable to read DNS query: %v", err)
+			}
+			return
+		}
+
+		resp := s.handleQuery(buf[:n])
+		if resp == nil {
+			continue
+		}
+		if _, err := s.conn.WriteTo(resp, addr); err != nil {
+			log.Debugf("Unable to answer DNS query from %v: %v",
+				addr, err)
+		}
+	}
+}
+
+// parseQuery returns the ID, the flags and the question of the passed query.
+func parseQuery(query []byte) (uint16, uint16, *dnsQuestion, int, error) {
+	if len(query) < dnsHeaderSize {
+		return 0, 0, nil, 0, errMalformedQuery
+	}
+	id := binary.BigEndian.Uint16(query[0:2])
+	flags := binary.BigEndian.Uint16(query[2:4])
+	qdCount := binary.BigEndian.Uint16(query[4:6])
+	if flags&dnsFlagResponse != 0 || qdCount != 1 {
+		return id, flags, nil, 0, errMalformedQuery
+	}
+
+	// Read the labels of the name, which are not compressed in a question.
+	var labels []string
+	offset := dnsHeaderSize
+	for {
+		if offset >= len(query) {
+			return id, flags, nil, 0, errMalformedQuery
+		}
+		length := int(query[offset])
+		offset++
+		if length == 0 {
+			break
+		}
+		if length > maxLabelLength || offset+length > len(query) {
+			return id, flags, nil, 0, errMalformedQuery
+		}
+		labels = append(labels, string(query[offset:offset+length]))
+		offset += length
+	}
+	if offset+4 > len(query) {
+		return id, flags, nil, 0, errMalformedQuery
+	}
+	q := &dnsQuestion{
+		name:  strings.ToLower(strings.Join(labels, ".")),
+		qtype: binary.BigEndian.Uint16(query[offset : offset+2]),
+		class: binary.BigEndian.Uint16(query[offset+2 : offset+4]),
+	}
+	return id, flags, q, offset + 4, nil
+}
+
+// parseServices returns the services requested by the passed name of the zone
+// of the seed, and whether the name exists.
+func (s *dnsServer) parseServices(name string) (wire.ServiceFlag, bool) {
+	if name == s.host {
+		return wire.SFNodeNetwork, true
+	}
+	sub := strings.TrimSuffix(name, "."+s.host)
+	if sub == name || len(sub) < 2 || sub[0] != 'x' {
+		return 0, false
+	}
+	services, err := strconv.ParseUint(sub[1:], 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	return wire.ServiceFlag(services), true
+}
+
+// inZone returns whether the passed name belongs to the zone of the seed.
+func (s *dnsServer) inZone(name string) bool {
+	return name == s.host || strings.HasSuffix(name, "."+s.host)
+}
+
+// handleQuery returns the response to the passed query, or nil when the query
+// is not worth a response.
+func (s *dnsServer) handleQuery(query []byte) []byte {
+	id, flags, q, questionEnd, err := parseQuery(query)
+	if err != nil {
+		if len(query) < dnsHeaderSize || flags&dnsFlagResponse != 0 {
+			return nil
+		}
+		return dnsHeader(id, flags, dnsRcodeFormErr, 0, 0, 0)
+	}
+	question := query[dnsHeaderSize:questionEnd]
+
+	switch {
+	case flags&dnsOpcodeMask != 0:
+		return withQuestion(dnsHeader(id, flags, dnsRcodeNotImp, 0, 0, 0), question)
+	case q.class != dnsClassIN || !s.inZone(q.name):
+		return withQuestion(dnsHeader(id, flags, dnsRcodeRefused, 0, 0, 0), question)
+	}
+	services, ok := s.parseServices(q.name)
+	if !ok {
+		resp := withQuestion(dnsHeader(id, flags, dnsRcodeNXDomain, 0, 1, 0), question)
+		return append(resp, s.soaRecord()...)
+	}
+
+	var answers [][]byte
+	switch q.qtype {
+	case dnsTypeA, dnsTypeAAAA, dnsTypeANY:
+		if q.qtype != dnsTypeAAAA {
+			for _, ip := range s.pickIPs(services, true) {
+				answers = append(answers, addrRecord(ip.To4(), dnsTypeA))
+			}
+		}
+		if q.qtype != dnsTypeA {
+			for _, ip := range s.pickIPs(services, false) {
+				answers = append(answers, addrRecord(ip.To16(), dnsTypeAAAA))
+			}
+		}
+		if q.qtype == dnsTypeANY && q.name == s.host {
+			answers = append(answers, s.nsRecord())
+		}
+	case dnsTypeNS:
+		if q.name == s.host {
+			answers = append(answers, s.nsRecord())
+		}
+	case dnsTypeSOA:
+		if q.name == s.host {
+			answers = append(answers, s.soaRecord())
+		}
+	}
+
+	// Keep the response within the size of a UDP message.
+	size := dnsHeaderSize + len(question)
+	var count uint16
+	for _, answer := range answers {
+		if size+len(answer) > maxUDPSize {
+			break
+		}
+		size += len(answer)
+		count++
+	}
+	resp := withQuestion(dnsHeader(id, flags, 0, count, 0, 0), question)
+	for _, answer := range answers[:count] {
+		resp = append(resp, answer...)
+	}
+	return resp
+}
+
+// pickIPs returns up to maxAnswers random addresses of the nodes advertising
+// the passed services.
+func (s *dnsServer) pickIPs(services wire.ServiceFlag, ipv4 bool) []net.IP {
+	ips := s.crawler.goodAddresses(services, ipv4)
+	rand.Shuffle(len(ips), func(i, j int) {
+		ips[i], ips[j] = ips[j], ips[i]
+	})
+	if len(ips) > maxAnswers {
+		ips = ips[:maxAnswers]
+	}
+	return ips
+}
+
+// dnsHeader returns the header of a response to the query with the passed ID
+// and flags.
+func dnsHeader(id, flags uint16, rcode int, anCount, nsCount, arCount uint16) []byte {
+	header := make([]byte, dnsHeaderSize)
+	binary.BigEndian.PutUint16(header[0:2], id)
+	respFlags := dnsFlagResponse | dnsFlagAuthoritative |
+		flags&(dnsOpcodeMask|dnsFlagRecursion) | uint16(rcode)
+	binary.BigEndian.PutUint16(header[2:4], respFlags)
+	if rcode != dnsRcodeFormErr {
+		binary.BigEndian.PutUint16(header[4:6], 1)
+	}
+	binary.BigEndian.PutUint16(header[6:8], anCount)
+	binary.BigEndian.PutUint16(header[8:10], nsCount)
+	binary.BigEndian.PutUint16(header[10:12], arCount)
+	return header
+}
+
+// withQuestion appends the passed question to the passed header.
+func withQuestion(header, question []byte) []byte {
+	return append(header, question...)
+}
+
+// resourceRecord returns a record of the passed type and data for the name of
+// the question.
+func resourceRecord(rtype uint16, ttl uint32, data []byte) []byte {
+	record := make([]byte, 12, 12+len(data))
+	binary.BigEndian.PutUint16(record[0:2], nameOffset)
+	binary.BigEndian.PutUint16(record[2:4], rtype)
+	binary.BigEndian.PutUint16(record[4:6], dnsClassIN)
+	binary.BigEndian.PutUint32(record[6:10], ttl)
+	binary.BigEndian.PutUint16(record[10:12], uint16(len(data)))
+	return append(record, data...)
+}
+
+// addrRecord returns an A or AAAA record of the passed address.
+func addrRecord(ip net.IP, rtype uint16) []byte {
+	return resourceRecord(rtype, addrTTL, ip)
+}
+
+// encodeName returns the passed name encoded as a sequence of labels.
+func encodeName(name string) []byte {
+	var encoded []byte
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			continue
+		}
+		encoded = append(encoded, byte(len(label)))
+		encoded = append(encoded, label...)
+	}
+	return append(encoded, 0)
+}
+
+// nsRecord returns the NS record of the zone of the seed.
+func (s *dnsServer) nsRecord() []byte {
+	return resourceRecord(dnsTypeNS, zoneTTL, encodeName(s.nameserver))
+}
+
+// soaRecord returns the SOA record of the zone of the seed.  It is returned as
+// the authority of the negative responses, so it is named after the host of
+// the seed rather than the question.
+func (s *dnsServer) soaRecord() []byte {
+	data := encodeName(s.nameserver)
+	data = append(data, encodeName("hostmaster."+s.host)...)
+	var fields [20]byte
+	binary.BigEndian.PutUint32(fields[0:4], uint32(time.Now().Unix()))
+	binary.BigEndian.PutUint32(fields[4:8], zoneTTL)
+	binary.BigEndian.PutUint32(fields[8:12], zoneTTL/6)
+	binary.BigEndian.PutUint32(fields[12:16], 7*24*zoneTTL)
+	binary.BigEndian.PutUint32(fields[16:20], addrTTL)
+	data = append(data, fields[:]...)
+
+	name := encodeName(s.host)
+	record := make([]byte, 0, len(name)+10+len(data))
+	record = append(record, name...)
+	var hdr [10]byte
+	binary.BigEndian.PutUint16(hdr[0:2], dnsTypeSOA)
+	binary.BigEndian.PutUint16(hdr[2:4], dnsClassIN)
+	binary.BigEndian.PutUint32(hdr[4:8], zoneTTL)
+	binary.BigEndian.PutUint16(hdr[8:10], uint16(len(data)))
+	record = append(record, hdr[:]...)
+	return append(record, data...)
+}
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -22,12 +23,15 @@ import (
 	"time"
 
 	"github.com/btcsuite/go-socks/socks"
+	"github.com/bourbaki-czz/classzz/blockchain"
 	"github.com/bourbaki-czz/classzz/chaincfg"
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
 	"github.com/bourbaki-czz/classzz/connmgr"
+	"github.com/bourbaki-czz/classzz/czzec"
 	"github.com/bourbaki-czz/classzz/database"
 	_ "github.com/bourbaki-czz/classzz/database/ffldb"
 	"github.com/bourbaki-czz/classzz/mempool"
+	"github.com/bourbaki-czz/classzz/mining/remotesigner"
 	"github.com/bourbaki-czz/classzz/peer"
 	"github.com/bourbaki-czz/classzz/version"
 	"github.com/bourbaki-czz/czzutil"
@@ -41,17 +45,29 @@ const (
 	defaultLogLevel                = "info"
 	defaultLogDirname              = "logs"
 	defaultLogFilename             = "classzz.log"
+	defaultLogFormat               = logFormatText
+	defaultLogMaxSizeMiB           = 10
+	defaultLogMaxRolls             = 3
+	minLogRotateInterval           = time.Minute
+	defaultWalletFilename          = "wallet.json"
 	defaultMaxPeers                = 125
 	defaultMaxPeersPerIP           = 5
 	defaultBanDuration             = time.Hour * 24
 	defaultBanThreshold            = 100
+	defaultInboundRateLimit        = 10
+	defaultMaxHalfOpen             = 32
+	defaultHandshakeTimeout        = peer.DefaultHandshakeTimeout
 	defaultConnectTimeout          = time.Second * 30
+	defaultShutdownTimeout         = time.Minute * 2
+	defaultProfilePushInterval     = time.Minute * 10
+	defaultLockWatchdog            = time.Second * 30
 	defaultMaxRPCClients           = 10
 	defaultMaxRPCWebsockets        = 25
 	defaultMaxRPCConcurrentReqs    = 20
 	defaultDbType                  = "ffldb"
 	defaultFreeTxRelayLimit        = 15.0
 	defaultTrickleInterval         = peer.DefaultTrickleInterval
+	defaultTxDiffusionDelay        = time.Second * 5
 	defaultExcessiveBlockSize      = 8000000
 	defaultBlockMinSize            = 0
 	defaultBlockMaxSize            = 7500000
@@ -69,6 +85,16 @@ const (
 	minPruneDepth                  = 288
 	defaultDBCacheSize             = 500
 	defaultDBFlushSecs             = 1800
+	defaultBlockFileSize           = 512
+	blockFileSizeMin               = 16
+	blockFileSizeMax               = 4095
+	defaultBlockColdAge            = 30
+	defaultDBProfile               = "default"
+	defaultDBWriteBuffer           = 4
+	dbWriteBufferMax               = 1024
+	throughputDBWriteBuffer        = 64
+	throughputDBBatchSize          = 32
+	throughputDBSyncInterval       = time.Minute
 )
 
 var (
@@ -111,8 +137,13 @@ type config struct {
 	ConfigFile              string        `short:"C" long:"configfile" description:"Path to configuration file"`
 	DataDir                 string        `short:"b" long:"datadir" description:"Directory to store data"`
 	LogDir                  string        `long:"logdir" description:"Directory to log output."`
+	LogFormat               string        `long:"logformat" description:"Format of the log output: text, or json or logfmt with one structured record per line {text, json, logfmt}"`
+	LogMaxSize              uint32        `long:"logmaxsize" description:"Rotate the log file once it reaches this size in MiB"`
+	LogMaxRolls             int           `long:"logmaxrolls" description:"Number of rotated log files to keep (0 to keep all)"`
+	LogRotateInterval       time.Duration `long:"logrotateinterval" description:"Also rotate the log file at every multiple of this interval since the Unix epoch, for example 24h for daily rotation at midnight UTC (0 to disable)"`
 	AddPeers                []string      `short:"a" long:"addpeer" description:"Add a peer to connect with at startup"`
 	ConnectPeers            []string      `long:"connect" description:"Connect only to the specified peers at startup"`
+	BridgePeers             []string      `long:"bridgepeer" description:"Add a bridge peer, a node of a coin pool custodian, to stay connected with -- Bridge peers are granted the bridge permissions and are announced the entangle transactions right away (eg. 203.0.113.5 or pool.example.com:8333)"`
 	DisableListen           bool          `long:"nolisten" description:"Disable listening for incoming connections -- NOTE: Listening is automatically disabled if the --connect or --proxy options are used without also specifying listen interfaces via --listen"`
 	Listeners               []string      `long:"listen" description:"Add an interface/port to listen for connections (default all interfaces port: 8333, testnet: 18333)"`
 	MaxPeers                int           `long:"maxpeers" description:"Max number of inbound and outbound peers"`
@@ -121,7 +152,12 @@ type config struct {
 	DisableBanning          bool          `long:"nobanning" description:"Disable banning of misbehaving peers"`
 	BanDuration             time.Duration `long:"banduration" description:"How long to ban misbehaving peers.  Valid time units are {s, m, h}.  Minimum 1 second"`
 	BanThreshold            uint32        `long:"banthreshold" description:"Maximum allowed ban score before disconnecting and banning misbehaving peers."`
-	Whitelists              []string      `long:"whitelist" description:"Add an IP network or IP that will not be banned. (eg. 192.168.1.0/24 or ::1)"`
+	Whitelists              []string      `long:"whitelist" description:"Add an IP network or IP whose peers are granted permissions, optionally prefixed with a comma separated list of permissions {noban, forcerelay, mempool, download, bridge, all} followed by '@' (default: noban,mempool,download) (eg. 192.168.1.0/24, ::1 or noban,forcerelay@10.0.0.0/8)"`
+	Whitebinds              []string      `long:"whitebind" description:"Add an interface/port to listen for connections whose peers are granted permissions, optionally prefixed with permissions like --whitelist (eg. download@127.0.0.1:8336)"`
+	InboundRateLimit        int           `long:"inboundratelimit" description:"Max number of inbound connections accepted from a single IP per minute, 0 for no limit"`
+	MaxHalfOpen             int           `long:"maxhalfopen" description:"Max number of inbound connections which have not completed the handshake yet, 0 for no limit"`
+	HandshakeTimeout        time.Duration `long:"handshaketimeout" description:"Time peers have to complete the version handshake before they are disconnected.  Valid time units are {s, m, h}.  Minimum 1 second"`
+	MaxUploadTarget         uint64        `long:"maxuploadtarget" description:"Maximum number of MiB uploaded to peers per 24 hours, historical blocks are no longer served to non-whitelisted peers once it is reached (0 for no limit)"`
 	RPCUser                 string        `short:"u" long:"rpcuser" description:"Username for RPC connections"`
 	RPCPass                 string        `short:"P" long:"rpcpass" default-mask:"-" description:"Password for RPC connections"`
 	RPCLimitUser            string        `long:"rpclimituser" description:"Username for limited RPC connections"`
@@ -132,27 +168,51 @@ type config struct {
 	RPCMaxClients           int           `long:"rpcmaxclients" description:"Max number of RPC clients for standard connections"`
 	RPCMaxWebsockets        int           `long:"rpcmaxwebsockets" description:"Max number of RPC websocket connections"`
 	RPCMaxConcurrentReqs    int           `long:"rpcmaxconcurrentreqs" description:"Max number of concurrent RPC requests that may be processed concurrently"`
+	RPCSlowLog              time.Duration `long:"rpcslowlog" description:"Log the RPC requests which take longer than this duration with their parameters redacted, for example 2s (0 to disable)"`
 	RPCQuirks               bool          `long:"rpcquirks" description:"Mirror some JSON-RPC quirks of Bitcoin Core -- NOTE: Discouraged unless interoperability issues need to be worked around"`
 	DisableRPC              bool          `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass or rpclimituser/rpclimitpass is specified"`
 	DisableTLS              bool          `long:"notls" description:"Disable TLS for the RPC server -- NOTE: This is only allowed if the RPC server is bound to localhost"`
 	DisableDNSSeed          bool          `long:"nodnsseed" description:"Disable DNS seeding for peers"`
+	DNSSeeds                []string      `long:"dnsseed" description:"Add a DNS seed to query for peers in addition to the seeds of the network"`
+	SeedFile                string        `long:"seedfile" description:"File listing peers to bootstrap from, one host or host:port per line"`
+	ASMap                   string        `long:"asmap" description:"File mapping IP networks to autonomous systems used to diversify outbound peers by AS instead of by /16"`
 	ExternalIPs             []string      `long:"externalip" description:"Add an ip to the list of local addresses we claim to listen on to peers"`
 	Proxy                   string        `long:"proxy" description:"Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
 	ProxyUser               string        `long:"proxyuser" description:"Username for proxy server"`
 	ProxyPass               string        `long:"proxypass" default-mask:"-" description:"Password for proxy server"`
+	IPv4Proxy               string        `long:"ipv4proxy" description:"Connect to IPv4 peers via this SOCKS5 proxy instead of --proxy, using the --proxyuser and --proxypass credentials (eg. 127.0.0.1:1080)"`
+	IPv6Proxy               string        `long:"ipv6proxy" description:"Connect to IPv6 peers via this SOCKS5 proxy instead of --proxy, using the --proxyuser and --proxypass credentials"`
+	NoLANProxy              bool          `long:"nolanproxy" description:"Connect directly to peers on loopback, private and link-local networks instead of through a proxy"`
 	OnionProxy              string        `long:"onion" description:"Connect to tor hidden services via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
 	OnionProxyUser          string        `long:"onionuser" description:"Username for onion proxy server"`
 	OnionProxyPass          string        `long:"onionpass" default-mask:"-" description:"Password for onion proxy server"`
 	NoOnion                 bool          `long:"noonion" description:"Disable connecting to tor hidden services"`
 	TorIsolation            bool          `long:"torisolation" description:"Enable Tor stream isolation by randomizing user credentials for each connection."`
+	TorControl              string        `long:"torcontrol" description:"Create an onion service for inbound connections via the Tor control port (eg. 127.0.0.1:9051)"`
+	TorPassword             string        `long:"torpassword" default-mask:"-" description:"Password for the Tor control port -- cookie authentication is used when not set"`
+	TorAnonymous            bool          `long:"toranonymous" description:"Only connect through the Tor proxy, prefer onion peers and do not advertise clearnet addresses"`
 	TestNet3                bool          `long:"testnet" description:"Use the test network"`
 	RegressionTest          bool          `long:"regtest" description:"Use the regression test network"`
 	SimNet                  bool          `long:"simnet" description:"Use the simulation test network"`
+	SigNet                  bool          `long:"signet" description:"Use the signed test network"`
+	ChainDef                string        `long:"chaindef" description:"Use the private network described by the chain definition file"`
 	AddCheckpoints          []string      `long:"addcheckpoint" description:"Add a custom checkpoint.  Format: '<height>:<hash>'"`
+	Deployments             []string      `long:"deployment" description:"Override the start and expire times of a rule change deployment on regtest, simnet or a chain definition.  Format: '<name>:<starttime>:<expiretime>' where name is dummy, csv, seq, sigchecks or utxocommitment"`
+	EntangleHeight          int32         `long:"entangleheight" description:"Override the height entangle transactions are accepted from on regtest, simnet or a chain definition"`
 	DisableCheckpoints      bool          `long:"nocheckpoints" description:"Disable built-in checkpoints.  Don't do this unless you know what you're doing."`
 	DbType                  string        `long:"dbtype" description:"Database backend to use for the Block Chain"`
-	Profile                 string        `long:"profile" description:"Enable HTTP profiling on given port -- NOTE port must be between 1024 and 65536"`
+	BlocksDir               string        `long:"blocksdir" description:"Directory to store the blocks in instead of the data directory, such as a different volume"`
+	BlockFileSize           uint32        `long:"blockfilesize" description:"The maximum size in MiB of each block file of the ffldb database"`
+	BlockColdDir            string        `long:"blockcolddir" description:"Directory to move block files of the ffldb database to once they are older than blockcoldage, such as a slower volume or a mounted object store"`
+	BlockColdAge            uint32        `long:"blockcoldage" description:"The number of days after the last write a block file is moved to blockcolddir"`
+	Profile                 string        `long:"profile" description:"Enable HTTP profiling on given port, authenticated with the RPC admin credentials -- NOTE port must be between 1024 and 65536"`
+	ProfilePush             string        `long:"profilepush" default-mask:"-" description:"URL of an endpoint to periodically push cpu and heap profiles to -- Credentials may be given in the URL"`
+	ProfilePushInterval     time.Duration `long:"profilepushinterval" description:"Interval between the pushes of the profiles to the profilepush endpoint"`
 	CPUProfile              string        `long:"cpuprofile" description:"Write CPU profile to the specified file"`
+	LockStats               bool          `long:"lockstats" description:"Record the wait and hold times of the chain and mempool locks, served with the metrics, and watch for the locks held too long"`
+	LockWatchdog            time.Duration `long:"lockwatchdog" description:"Log the stacks of all goroutines when a lock is held longer than this duration with lockstats enabled (0 to disable)"`
+	MetricsListeners        []string      `long:"metricslisten" description:"Add an interface/port to serve Prometheus metrics on at /metrics -- the metrics are not served unless specified (default port: 8336, testnet: 18336)"`
+	BridgeListeners         []string      `long:"bridgelisten" description:"Add an interface/port to serve the status page of the entangle bridge on at / and as JSON at /api/status -- the status is not served unless specified (default port: 8337, testnet: 18337)"`
 	DebugLevel              string        `short:"d" long:"debuglevel" description:"Logging level for all subsystems {trace, debug, info, warn, error, critical} -- You may also specify <subsystem>=<level>,<subsystem2>=<level>,... to set the log level for individual subsystems -- Use show to list available subsystems"`
 	Upnp                    bool          `long:"upnp" description:"Use UPnP to map our listening port outside of NAT"`
 	ExcessiveBlockSize      uint32        `long:"excessiveblocksize" description:"The maximum size block (in bytes) this node will accept. Cannot be less than 32000000."`
@@ -160,23 +220,44 @@ type config struct {
 	FreeTxRelayLimit        float64       `long:"limitfreerelay" description:"Limit relay of transactions with no transaction fee to the given amount in thousands of bytes per minute"`
 	NoRelayPriority         bool          `long:"norelaypriority" description:"Do not require free or low-fee transactions to have high priority for relaying"`
 	TrickleInterval         time.Duration `long:"trickleinterval" description:"Minimum time between attempts to send new inventory to a connected peer"`
+	TxDiffusionDelay        time.Duration `long:"txdiffusiondelay" description:"Maximum random delay before announcing a locally submitted transaction to each peer but the first few -- Set to 0 to announce it to all peers at once"`
 	MaxOrphanTxs            int           `long:"maxorphantx" description:"Max number of orphan transactions to keep in memory"`
+	DataCarrierSize         int           `long:"datacarriersize" description:"Max number of bytes in a data carrier (OP_RETURN) output to relay and mine -- Entangle outputs are not limited"`
+	MaxDataCarrierOutputs   int           `long:"maxdatacarrieroutputs" description:"Max number of data carrier (OP_RETURN) outputs per transaction to relay and mine -- Set to 0 to reject transactions with data carrier outputs"`
+	RejectTxs               []string      `long:"rejecttx" description:"Add the hash of a transaction to refuse to relay and mine -- Blocks which include it are still accepted"`
+	RejectScripts           []string      `long:"rejectscript" description:"Add the hex of an output script template to refuse to relay and mine transactions with a matching output, where each ?? matches any byte -- Blocks which include them are still accepted"`
 	Generate                bool          `long:"generate" description:"Generate (mine) bitcoins using the CPU"`
 	MiningAddrs             []string      `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address is required if the generate option is set"`
+	SignetKey               string        `long:"signetkey" default-mask:"-" description:"WIF-encoded private key to sign generated blocks with on a signed network -- Required to generate blocks on such a network unless a signetsigner is specified"`
+	SignetSigner            string        `long:"signetsigner" default-mask:"-" description:"URL of a remote signer to sign generated blocks with on a signed network instead of holding the private key -- Credentials may be given in the URL, requires signetsignerpubkey"`
+	SignetSignerPubKey      string        `long:"signetsignerpubkey" description:"Hex-encoded compressed public key the remote signer signs generated blocks with"`
 	BlockMinSize            uint32        `long:"blockminsize" description:"Mininum block size in bytes to be used when creating a block"`
 	BlockMaxSize            uint32        `long:"blockmaxsize" description:"Maximum block size in bytes to be used when creating a block"`
 	BlockPrioritySize       uint32        `long:"blockprioritysize" description:"Size in bytes for high-priority/low-fee transactions when creating a block"`
 	UserAgentComments       []string      `long:"uacomment" description:"Comment to add to the user agent -- See BIP 14 for more information."`
 	NoPeerBloomFilters      bool          `long:"nopeerbloomfilters" description:"Disable bloom filtering support"`
 	NoCFilters              bool          `long:"nocfilters" description:"Disable committed filtering (CF) support"`
+	Graphene                bool          `long:"graphene" description:"Enable the experimental graphene block relay protocol with peers which support it"`
+	UtxoSnapshot            string        `long:"utxosnapshot" description:"Serve this UTXO set at the last checkpoint, as exported by utxotool, to peers in fast sync mode"`
 	DropCfIndex             bool          `long:"dropcfindex" description:"Deletes the index used for committed filtering (CF) support from the database on start up and then exits."`
 	SigCacheMaxSize         uint          `long:"sigcachemaxsize" description:"The maximum number of entries in the signature verification cache"`
+	ScriptCacheMaxSize      uint          `long:"scriptcachemaxsize" description:"The maximum number of transactions in the script validation cache, which spares validating the scripts of the transactions of the memory pool again once in a block -- Set to 0 to disable the cache"`
 	UtxoCacheMaxSizeMiB     uint          `long:"utxocachemaxsize" description:"The maximum size in MiB of the UTXO cache"`
+	BlockCacheSize          uint          `long:"blockcachesize" description:"Number of the most recent blocks to keep deserialized in memory to serve them without reading the database -- Set to 0 to disable the cache"`
 	BlocksOnly              bool          `long:"blocksonly" description:"Do not accept transactions from remote peers."`
 	TxIndex                 bool          `long:"txindex" description:"Maintain a full hash-based transaction index which makes all transactions available via the getrawtransaction RPC"`
 	DropTxIndex             bool          `long:"droptxindex" description:"Deletes the hash-based transaction index from the database on start up and then exits."`
 	AddrIndex               bool          `long:"addrindex" description:"Maintain a full address-based transaction index which makes the searchrawtransactions RPC available"`
 	DropAddrIndex           bool          `long:"dropaddrindex" description:"Deletes the address-based transaction index from the database on start up and then exits."`
+	SpentIndex              bool          `long:"spentindex" description:"Maintain an index of the inputs spending every output which makes the getspentinfo RPC available"`
+	DropSpentIndex          bool          `long:"dropspentindex" description:"Deletes the spent output index from the database on start up and then exits."`
+	ScriptTypeIndex         bool          `long:"scripttypeindex" description:"Maintain an index of the number and value of the outputs created and spent by every block by script type which makes the getscripttypestats RPC available"`
+	DropScriptTypeIndex     bool          `long:"dropscripttypeindex" description:"Deletes the script type index from the database on start up and then exits."`
+	BlockTxMeta             bool          `long:"blocktxmeta" description:"Store the fee, size and in-block dependencies of the transactions of every connected block, which the getblocktxmeta RPC then serves without recomputing them, even for pruned blocks"`
+	Wallet                  bool          `long:"wallet" description:"Enable the built-in wallet and its RPCs -- Requires a node built with the wallet build tag and a wallet file created with --createwallet"`
+	CreateWallet            bool          `long:"createwallet" description:"Creates a new wallet file, or recovers one from a mnemonic, interactively on start up and then exits."`
+	MiningWallet            bool          `long:"miningwallet" description:"Add a new address of the built-in wallet to the addresses to use for generated blocks, so the keys of the payouts are kept encrypted in the wallet file -- Requires --wallet"`
+	DropWalletIndex         bool          `long:"dropwalletindex" description:"Deletes the index of the outputs paying to the built-in wallet from the database on start up and then exits."`
 	RelayNonStd             bool          `long:"relaynonstd" description:"Relay non-standard transactions regardless of the default settings for the active network."`
 	RejectNonStd            bool          `long:"rejectnonstd" description:"RejFect non-standard transactions regardless of the default settings for the active network."`
 	Prune                   bool          `long:"prune" description:"Delete historical blocks from the chain. A buffer of blocks will be retained in case of a reorg."`
@@ -188,6 +269,12 @@ type config struct {
 	GrpcAuthToken           string        `long:"grpcauthtoken" description:"An authentication token for the gRPC API to authenticate clients"`
 	DBCacheSize             uint64        `long:"dbcachesize" description:"The maximum size in MiB of the database cache"`
 	DBFlushInterval         uint32        `long:"dbflushinterval" description:"The number of seconds between database flushes"`
+	DBCompactWindow         string        `long:"dbcompactwindow" description:"Compact the block database once a day within this UTC time window, for example 02:00-04:00"`
+	DBProfile               string        `long:"dbprofile" description:"Database write profile supplying the defaults of the dbwritebuffer, dbbatchsize and dbsyncinterval options: default or throughput, which speeds up the initial block download at the risk of losing the most recent blocks on a crash {default, throughput}"`
+	DBWriteBuffer           uint32        `long:"dbwritebuffer" description:"The size in MiB of the write buffers of the database"`
+	DBBatchSize             uint32        `long:"dbbatchsize" description:"The maximum size in MiB of a database flush which is written as a single batch (0 to disable batches)"`
+	DBSyncInterval          time.Duration `long:"dbsyncinterval" description:"The minimum interval between syncs of the database to disk, for example 1m (0 to sync every flush)"`
+	ShutdownTimeout         time.Duration `long:"shutdowntimeout" description:"Time the server has to stop on shutdown before the chain state is flushed and the database closed regardless (0 to wait forever)"`
 	DogeCoinRPC             []string      `long:"dogecoinrpc" description:""`
 	DogeCoinRPCUser         string        `long:"dogecoinrpcuser" description:""`
 	DogeCoinRPCPass         string        `long:"dogecoinrpcpass" description:""`
@@ -197,10 +284,29 @@ type config struct {
 	lookup                  func(string) ([]net.IP, error)
 	oniondial               func(string, string, time.Duration) (net.Conn, error)
 	dial                    func(string, string, time.Duration) (net.Conn, error)
+	ipv4dial                func(string, string, time.Duration) (net.Conn, error)
+	ipv6dial                func(string, string, time.Duration) (net.Conn, error)
 	addCheckpoints          []chaincfg.Checkpoint
 	miningAddrs             []czzutil.Address
+	blockSigner             blockchain.BlockSigner
+	profilePusher           *profilePusher
 	minRelayTxFee           czzutil.Amount
-	whitelists              []*net.IPNet
+	whitelists              []whitelist
+	denylist                *mempool.Denylist
+	whitebinds              []whitebind
+	dbCompactWindow         *maintenanceWindow
+}
+
+// whitelist is an IP network whose peers are granted permissions.
+type whitelist struct {
+	ipnet *net.IPNet
+	perms peer.Permissions
+}
+
+// whitebind is a listen address whose inbound peers are granted permissions.
+type whitebind struct {
+	addr  string
+	perms peer.Permissions
 }
 
 // serviceOptions defines the configuration options for the daemon as a service on
@@ -256,32 +362,34 @@ func supportedSubsystems() []string {
 	return subsystems
 }
 
-// parseAndSetDebugLevels attempts to parse the specified debug level and set
-// the levels accordingly.  An appropriate error is returned if anything is
-// invalid.
-func parseAndSetDebugLevels(debugLevel string) error {
+// parseDebugLevels attempts to parse the specified debug level and returns
+// the levels of the subsystems it sets.  An appropriate error is returned if
+// anything is invalid.
+func parseDebugLevels(debugLevel string) (map[string]string, error) {
+	levels := make(map[string]string)
+
 	// When the specified string doesn't have any delimters, treat it as
 	// the log level for all subsystems.
 	if !strings.Contains(debugLevel, ",") && !strings.Contains(debugLevel, "=") {
 		// Validate debug log level.
 		if !validLogLevel(debugLevel) {
 			str := "The specified debug level [%v] is invalid"
-			return fmt.Errorf(str, debugLevel)
+			return nil, fmt.Errorf(str, debugLevel)
 		}
 
-		// Change the logging level for all subsystems.
-		setLogLevels(debugLevel)
-
-		return nil
+		for subsysID := range subsystemLoggers {
+			levels[subsysID] = debugLevel
+		}
+		return levels, nil
 	}
 
 	// Split the specified string into subsystem/level pairs while detecting
-	// issues and update the log levels accordingly.
+	// issues.
 	for _, logLevelPair := range strings.Split(debugLevel, ",") {
 		if !strings.Contains(logLevelPair, "=") {
 			str := "The specified debug level contains an invalid " +
 				"subsystem/level pair [%v]"
-			return fmt.Errorf(str, logLevelPair)
+			return nil, fmt.Errorf(str, logLevelPair)
 		}
 
 		// Extract the specified subsystem and log level.
@@ -292,21 +400,76 @@ func parseAndSetDebugLevels(debugLevel string) error {
 		if _, exists := subsystemLoggers[subsysID]; !exists {
 			str := "The specified subsystem [%v] is invalid -- " +
 				"supported subsytems %v"
-			return fmt.Errorf(str, subsysID, supportedSubsystems())
+			return nil, fmt.Errorf(str, subsysID, supportedSubsystems())
 		}
 
 		// Validate log level.
 		if !validLogLevel(logLevel) {
 			str := "The specified debug level [%v] is invalid"
-			return fmt.Errorf(str, logLevel)
+			return nil, fmt.Errorf(str, logLevel)
 		}
 
-		setLogLevel(subsysID, logLevel)
+		levels[subsysID] = logLevel
 	}
 
+	return levels, nil
+}
+
+// parseAndSetDebugLevels attempts to parse the specified debug level and set
+// the levels accordingly.  An appropriate error is returned if anything is
+// invalid, in which case no level is changed.
+func parseAndSetDebugLevels(debugLevel string) error {
+	levels, err := parseDebugLevels(debugLevel)
+	if err != nil {
+		return err
+	}
+	for subsysID, logLevel := range levels {
+		setLogLevel(subsysID, logLevel)
+	}
 	return nil
 }
 
+// parseWhitelists parses the passed whitelisted IP addresses and networks,
+// optionally prefixed with the permissions granted to their peers.
+func parseWhitelists(addrs []string) ([]whitelist, error) {
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+
+	whitelists := make([]whitelist, 0, len(addrs))
+	for _, addr := range addrs {
+		perms, addr, err := splitPermissions(addr)
+		if err != nil {
+			str := "The whitelist value of '%s' is invalid: %v"
+			return nil, fmt.Errorf(str, addr, err)
+		}
+		_, ipnet, err := net.ParseCIDR(addr)
+		if err != nil {
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				str := "The whitelist value of '%s' is invalid"
+				return nil, fmt.Errorf(str, addr)
+			}
+			var bits int
+			if ip.To4() == nil {
+				// IPv6
+				bits = 128
+			} else {
+				bits = 32
+			}
+			ipnet = &net.IPNet{
+				IP:   ip,
+				Mask: net.CIDRMask(bits, bits),
+			}
+		}
+		whitelists = append(whitelists, whitelist{
+			ipnet: ipnet,
+			perms: perms,
+		})
+	}
+	return whitelists, nil
+}
+
 // validDbType returns whether or not dbType is a supported database type.
 func validDbType(dbType string) bool {
 	for _, knownType := range knownDbTypes {
@@ -342,6 +505,21 @@ func normalizeAddress(addr, defaultPort string) string {
 	return addr
 }
 
+// splitPermissions splits the permissions prefixed to a whitelisted network or
+// listen address, separated by '@', from the address.  The default permissions
+// are returned when there is no prefix.
+func splitPermissions(s string) (peer.Permissions, string, error) {
+	i := strings.Index(s, "@")
+	if i < 0 {
+		return peer.PermissionsDefault, s, nil
+	}
+	perms, err := peer.ParsePermissions(s[:i])
+	if err != nil {
+		return 0, s[i+1:], err
+	}
+	return perms, s[i+1:], nil
+}
+
 // normalizeAddresses returns a new slice with all the passed peer addresses
 // normalized with the given default port, and all duplicates removed.
 func normalizeAddresses(addrs []string, defaultPort string) []string {
@@ -383,6 +561,34 @@ func newCheckpointFromStr(checkpoint string) (chaincfg.Checkpoint, error) {
 	}, nil
 }
 
+// overrideDeployments applies the deployment overrides in the
+// '<name>:<starttime>:<expiretime>' format to the passed network parameters.
+func overrideDeployments(params *chaincfg.Params, overrides []string) error {
+	for _, override := range overrides {
+		parts := strings.Split(override, ":")
+		if len(parts) != 3 {
+			return fmt.Errorf("unable to parse deployment %q -- use "+
+				"the syntax <name>:<starttime>:<expiretime>",
+				override)
+		}
+		startTime, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("unable to parse deployment %q due "+
+				"to malformed start time", override)
+		}
+		expireTime, err := strconv.ParseUint(parts[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("unable to parse deployment %q due "+
+				"to malformed expire time", override)
+		}
+		err = params.OverrideDeployment(parts[0], startTime, expireTime)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // parseCheckpoints checks the checkpoint strings for valid syntax
 // ('<height>:<hash>') and parses them to chaincfg.Checkpoint instances.
 func parseCheckpoints(checkpointStrings []string) ([]chaincfg.Checkpoint, error) {
@@ -419,21 +625,9 @@ func newConfigParser(cfg *config, so *serviceOptions, options flags.Options) *fl
 	return parser
 }
 
-// loadConfig initializes and parses the config using a config file and command
-// line options.
-//
-// The configuration proceeds as follows:
-// 	1) Start with a default config with sane settings
-// 	2) Pre-parse the command line to check for an alternative config file
-// 	3) Load configuration file overwriting defaults with any specified options
-// 	4) Parse CLI options and overwrite/add any specified options
-//
-// The above results in classzz functioning properly without any config settings
-// while still allowing the user to override settings with config files and
-// command line options.  Command line options always take precedence.
-func loadConfig() (*config, []string, error) {
-	// Default config.
-	cfg := config{
+// defaultConfig returns the config with the default settings.
+func defaultConfig() config {
+	return config{
 		ConfigFile:              defaultConfigFile,
 		DebugLevel:              defaultLogLevel,
 		MaxPeers:                defaultMaxPeers,
@@ -441,24 +635,40 @@ func loadConfig() (*config, []string, error) {
 		MinSyncPeerNetworkSpeed: defaultMinSyncPeerNetworkSpeed,
 		BanDuration:             defaultBanDuration,
 		BanThreshold:            defaultBanThreshold,
+		InboundRateLimit:        defaultInboundRateLimit,
+		MaxHalfOpen:             defaultMaxHalfOpen,
+		HandshakeTimeout:        defaultHandshakeTimeout,
+		ShutdownTimeout:         defaultShutdownTimeout,
+		ProfilePushInterval:     defaultProfilePushInterval,
+		LockWatchdog:            defaultLockWatchdog,
 		RPCMaxClients:           defaultMaxRPCClients,
 		RPCMaxWebsockets:        defaultMaxRPCWebsockets,
 		RPCMaxConcurrentReqs:    defaultMaxRPCConcurrentReqs,
 		DataDir:                 defaultDataDir,
 		LogDir:                  defaultLogDir,
+		LogFormat:               defaultLogFormat,
+		LogMaxSize:              defaultLogMaxSizeMiB,
+		LogMaxRolls:             defaultLogMaxRolls,
 		DbType:                  defaultDbType,
+		BlockFileSize:           defaultBlockFileSize,
+		BlockColdAge:            defaultBlockColdAge,
 		RPCKey:                  defaultRPCKeyFile,
 		RPCCert:                 defaultRPCCertFile,
 		ExcessiveBlockSize:      defaultExcessiveBlockSize,
 		MinRelayTxFee:           mempool.DefaultMinRelayTxFee.ToCZZ(),
 		FreeTxRelayLimit:        defaultFreeTxRelayLimit,
 		TrickleInterval:         defaultTrickleInterval,
+		TxDiffusionDelay:        defaultTxDiffusionDelay,
 		BlockMinSize:            defaultBlockMinSize,
 		BlockMaxSize:            defaultBlockMaxSize,
 		BlockPrioritySize:       mempool.DefaultBlockPrioritySize,
 		MaxOrphanTxs:            defaultMaxOrphanTransactions,
+		DataCarrierSize:         mempool.DefaultMaxDataCarrierSize,
+		MaxDataCarrierOutputs:   mempool.DefaultMaxDataCarrierOutputs,
 		SigCacheMaxSize:         defaultSigCacheMaxSize,
+		ScriptCacheMaxSize:      blockchain.DefaultScriptCacheSize,
 		UtxoCacheMaxSizeMiB:     defaultUtxoCacheMaxSizeMiB,
+		BlockCacheSize:          blockchain.DefaultBlockCacheSize,
 		Generate:                defaultGenerate,
 		TxIndex:                 defaultTxIndex,
 		AddrIndex:               defaultAddrIndex,
@@ -466,7 +676,26 @@ func loadConfig() (*config, []string, error) {
 		TargetOutboundPeers:     defaultTargetOutboundPeers,
 		DBCacheSize:             defaultDBCacheSize,
 		DBFlushInterval:         defaultDBFlushSecs,
+		DBProfile:               defaultDBProfile,
+		DBWriteBuffer:           defaultDBWriteBuffer,
 	}
+}
+
+// loadConfig initializes and parses the config using a config file and command
+// line options.
+//
+// The configuration proceeds as follows:
+// 	1) Start with a default config with sane settings
+// 	2) Pre-parse the command line to check for an alternative config file
+// 	3) Load configuration file overwriting defaults with any specified options
+// 	4) Parse CLI options and overwrite/add any specified options
+//
+// The above results in classzz functioning properly without any config settings
+// while still allowing the user to override settings with config files and
+// command line options.  Command line options always take precedence.
+func loadConfig() (*config, []string, error) {
+	// Default config.
+	cfg := defaultConfig()
 
 	// Service options which are only added on Windows.
 	serviceOpts := serviceOptions{}
@@ -583,15 +812,62 @@ func loadConfig() (*config, []string, error) {
 		activeNetParams = &simNetParams
 		cfg.DisableDNSSeed = true
 	}
+	if cfg.SigNet {
+		numNets++
+		activeNetParams = &sigNetParams
+	}
+	if cfg.ChainDef != "" {
+		numNets++
+		cfg.ChainDef = cleanAndExpandPath(cfg.ChainDef)
+		chainDef, err := chainDefParams(cfg.ChainDef)
+		if err != nil {
+			str := "%s: Unable to load chain definition %s: %v"
+			err := fmt.Errorf(str, funcName, cfg.ChainDef, err)
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, err
+		}
+		activeNetParams = chainDef
+	}
 	if numNets > 1 {
-		str := "%s: The testnet, regtest, segnet, and simnet params " +
-			"can't be used together -- choose one of the four"
+		str := "%s: The testnet, regtest, simnet, signet, and chaindef " +
+			"params can't be used together -- choose one of the five"
 		err := fmt.Errorf(str, funcName)
 		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr, usageMessage)
 		return nil, nil, err
 	}
 
+	// Override the deployments and entangle height of the network, which is
+	// only allowed on the networks which do not follow a public chain.
+	if len(cfg.Deployments) != 0 || cfg.EntangleHeight != 0 {
+		if !(cfg.RegressionTest || cfg.SimNet || cfg.ChainDef != "") {
+			str := "%s: the deployment and entangleheight options " +
+				"can only be used with regtest, simnet or chaindef"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		err := overrideDeployments(activeNetParams.Params, cfg.Deployments)
+		if err != nil {
+			str := "%s: Error parsing deployments: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if cfg.EntangleHeight < 0 {
+			str := "%s: the entangle height %d is negative"
+			err := fmt.Errorf(str, funcName, cfg.EntangleHeight)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if cfg.EntangleHeight != 0 {
+			activeNetParams.EntangleHeight = cfg.EntangleHeight
+		}
+	}
+
 	// Re-indexing and pruning don't mix.
 	if cfg.ReIndexChainState && cfg.Prune {
 		str := "%s: reindexchainstate can not be used with a pruned blockchain."
@@ -612,8 +888,10 @@ func loadConfig() (*config, []string, error) {
 
 	// Indexing also doesn't work with fast sync as the indexes will not go
 	// back to genesis.
-	if (cfg.TxIndex || cfg.AddrIndex) && cfg.FastSync {
-		str := "%s: txindex and addrindex can not be used with fast sync mode."
+	if (cfg.TxIndex || cfg.AddrIndex || cfg.SpentIndex ||
+		cfg.ScriptTypeIndex || cfg.Wallet) && cfg.FastSync {
+
+		str := "%s: txindex, addrindex, spentindex, scripttypeindex and wallet can not be used with fast sync mode."
 		err := fmt.Errorf(str, funcName)
 		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr, usageMessage)
@@ -669,9 +947,50 @@ func loadConfig() (*config, []string, error) {
 		os.Exit(0)
 	}
 
+	// Validate the log format and rotation options.
+	switch cfg.LogFormat {
+	case logFormatText, logFormatJSON, logFormatLogfmt:
+	default:
+		str := "%s: The specified log format [%v] is invalid -- " +
+			"supported formats are %v, %v and %v"
+		err := fmt.Errorf(str, funcName, cfg.LogFormat, logFormatText,
+			logFormatJSON, logFormatLogfmt)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.LogMaxSize == 0 {
+		str := "%s: The logmaxsize option must be at least 1 MiB"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.LogMaxRolls < 0 {
+		str := "%s: The logmaxrolls option may not be negative"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.LogRotateInterval != 0 &&
+		cfg.LogRotateInterval < minLogRotateInterval {
+
+		str := "%s: The logrotateinterval option must be at least %v " +
+			"-- parsed [%v]"
+		err := fmt.Errorf(str, funcName, minLogRotateInterval,
+			cfg.LogRotateInterval)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Initialize log rotation.  After log rotation has been initialized, the
 	// logger variables may be used.
-	initLogRotator(filepath.Join(cfg.LogDir, defaultLogFilename))
+	logFormat = cfg.LogFormat
+	initLogRotator(filepath.Join(cfg.LogDir, defaultLogFilename),
+		int64(cfg.LogMaxSize)*1024*1024, cfg.LogRotateInterval,
+		cfg.LogMaxRolls)
 
 	// Parse, validate, and set debug log level(s).
 	if err := parseAndSetDebugLevels(cfg.DebugLevel); err != nil {
@@ -691,6 +1010,48 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Append the network type to the block directories so they are
+	// "namespaced" per network in the same fashion as the data directory.
+	if cfg.BlocksDir != "" {
+		cfg.BlocksDir = cleanAndExpandPath(cfg.BlocksDir)
+		cfg.BlocksDir = filepath.Join(cfg.BlocksDir, netName(activeNetParams))
+	}
+	if cfg.BlockColdDir != "" {
+		cfg.BlockColdDir = cleanAndExpandPath(cfg.BlockColdDir)
+		cfg.BlockColdDir = filepath.Join(cfg.BlockColdDir,
+			netName(activeNetParams))
+	}
+
+	// Validate the block file options.
+	if cfg.BlockFileSize < blockFileSizeMin ||
+		cfg.BlockFileSize > blockFileSizeMax {
+
+		str := "%s: The blockfilesize option must be in between %d " +
+			"and %d -- parsed [%d]"
+		err := fmt.Errorf(str, funcName, blockFileSizeMin,
+			blockFileSizeMax, cfg.BlockFileSize)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.DbType != "ffldb" && (cfg.BlockColdDir != "" ||
+		cfg.BlockFileSize != defaultBlockFileSize) {
+
+		str := "%s: The blockfilesize and blockcolddir options are " +
+			"only supported by the ffldb database type"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.BlockColdAge == 0 {
+		str := "%s: The blockcoldage option must be at least 1 day"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Validate profile port number
 	if cfg.Profile != "" {
 		profilePort, err := strconv.Atoi(cfg.Profile)
@@ -701,6 +1062,38 @@ func loadConfig() (*config, []string, error) {
 			fmt.Fprintln(os.Stderr, usageMessage)
 			return nil, nil, err
 		}
+
+		// The profiles expose the memory of the process, so they are
+		// only served to the RPC admin.
+		if cfg.RPCUser == "" || cfg.RPCPass == "" {
+			str := "%s: The profile option requires the rpcuser and " +
+				"rpcpass options to authenticate the profile requests"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
+	// Validate the profile push endpoint and interval.
+	if cfg.ProfilePush != "" {
+		if cfg.ProfilePushInterval < 2*profilePushCPUDuration {
+			str := "%s: The profilepushinterval option must be at " +
+				"least %v"
+			err := fmt.Errorf(str, funcName, 2*profilePushCPUDuration)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.profilePusher, err = newProfilePusher(cfg.ProfilePush,
+			cfg.ProfilePushInterval)
+		if err != nil {
+			str := "%s: The profilepush URL is invalid: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
 	}
 
 	// Don't allow ban durations that are too short.
@@ -712,6 +1105,14 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	if cfg.HandshakeTimeout < time.Second {
+		str := "%s: The handshaketimeout option may not be less than 1s -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.HandshakeTimeout)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	if cfg.Prune && cfg.PruneDepth < minPruneDepth {
 		str := "%s: The pruneheight option may not be less than %d -- parsed [%d]"
 		err := fmt.Errorf(str, minPruneDepth, funcName, cfg.PruneDepth)
@@ -720,36 +1121,107 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Parse the database compaction window.
+	if cfg.DBCompactWindow != "" {
+		window, err := parseMaintenanceWindow(cfg.DBCompactWindow)
+		if err != nil {
+			str := "%s: The dbcompactwindow option is invalid: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.dbCompactWindow = window
+	}
+
+	// Apply the database write profile to the write options which were not
+	// set and validate them.
+	switch cfg.DBProfile {
+	case defaultDBProfile:
+	case "throughput":
+		if cfg.DBWriteBuffer == defaultDBWriteBuffer {
+			cfg.DBWriteBuffer = throughputDBWriteBuffer
+		}
+		if cfg.DBBatchSize == 0 {
+			cfg.DBBatchSize = throughputDBBatchSize
+		}
+		if cfg.DBSyncInterval == 0 {
+			cfg.DBSyncInterval = throughputDBSyncInterval
+		}
+	default:
+		str := "%s: The dbprofile option must be either default or " +
+			"throughput -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.DBProfile)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.DBWriteBuffer == 0 || cfg.DBWriteBuffer > dbWriteBufferMax {
+		str := "%s: The dbwritebuffer option must be in between 1 and " +
+			"%d -- parsed [%d]"
+		err := fmt.Errorf(str, funcName, dbWriteBufferMax,
+			cfg.DBWriteBuffer)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.DBBatchSize > cfg.DBWriteBuffer {
+		str := "%s: The dbbatchsize option may not exceed the " +
+			"dbwritebuffer option -- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.DBBatchSize)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.DBSyncInterval < 0 {
+		str := "%s: The dbsyncinterval option may not be negative " +
+			"-- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.DBSyncInterval)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.ShutdownTimeout < 0 {
+		str := "%s: The shutdowntimeout option may not be negative " +
+			"-- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.ShutdownTimeout)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Validate any given whitelisted IP addresses and networks.
-	if len(cfg.Whitelists) > 0 {
-		var ip net.IP
-		cfg.whitelists = make([]*net.IPNet, 0, len(cfg.Whitelists))
+	cfg.whitelists, err = parseWhitelists(cfg.Whitelists)
+	if err != nil {
+		err := fmt.Errorf("%s: %v", funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
 
-		for _, addr := range cfg.Whitelists {
-			_, ipnet, err := net.ParseCIDR(addr)
-			if err != nil {
-				ip = net.ParseIP(addr)
-				if ip == nil {
-					str := "%s: The whitelist value of '%s' is invalid"
-					err = fmt.Errorf(str, funcName, addr)
-					fmt.Fprintln(os.Stderr, err)
-					fmt.Fprintln(os.Stderr, usageMessage)
-					return nil, nil, err
-				}
-				var bits int
-				if ip.To4() == nil {
-					// IPv6
-					bits = 128
-				} else {
-					bits = 32
-				}
-				ipnet = &net.IPNet{
-					IP:   ip,
-					Mask: net.CIDRMask(bits, bits),
-				}
+	// Validate any given whitelisted listen addresses and listen on them.
+	for _, addr := range cfg.Whitebinds {
+		perms, addr, err := splitPermissions(addr)
+		if err == nil {
+			addr = normalizeAddress(addr, activeNetParams.DefaultPort)
+			var host string
+			host, _, err = net.SplitHostPort(addr)
+			if err == nil && host != "" && net.ParseIP(host) == nil {
+				err = errors.New("the interface must be an IP address")
 			}
-			cfg.whitelists = append(cfg.whitelists, ipnet)
 		}
+		if err != nil {
+			str := "%s: The whitebind value of '%s' is invalid: %v"
+			err = fmt.Errorf(str, funcName, addr, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.whitebinds = append(cfg.whitebinds, whitebind{
+			addr:  addr,
+			perms: perms,
+		})
+		cfg.Listeners = append(cfg.Listeners, addr)
 	}
 
 	// --addPeer and --connect do not mix.
@@ -762,6 +1234,14 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// --torcontrol without --listen listens on the loopback interface only
+	// since inbound connections arrive through the onion service.
+	if cfg.TorControl != "" && len(cfg.Listeners) == 0 && !cfg.DisableListen {
+		cfg.Listeners = []string{
+			net.JoinHostPort("127.0.0.1", activeNetParams.DefaultPort),
+		}
+	}
+
 	// --proxy or --connect without --listen disables listening.
 	if (cfg.Proxy != "" || len(cfg.ConnectPeers) > 0) &&
 		len(cfg.Listeners) == 0 {
@@ -773,6 +1253,26 @@ func loadConfig() (*config, []string, error) {
 		cfg.DisableDNSSeed = true
 	}
 
+	// DNS seeds must be host names.
+	for _, seed := range cfg.DNSSeeds {
+		if seed == "" || strings.ContainsAny(seed, ":/ ") {
+			str := "%s: the DNS seed '%s' is not a valid host name"
+			err := fmt.Errorf(str, funcName, seed)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+	if cfg.SeedFile != "" {
+		cfg.SeedFile = cleanAndExpandPath(cfg.SeedFile)
+	}
+	if cfg.ASMap != "" {
+		cfg.ASMap = cleanAndExpandPath(cfg.ASMap)
+	}
+	if cfg.UtxoSnapshot != "" {
+		cfg.UtxoSnapshot = cleanAndExpandPath(cfg.UtxoSnapshot)
+	}
+
 	// Add the default listener if none were specified. The default
 	// listener is all addresses on the listen port for the network
 	// we are to connect to.
@@ -825,6 +1325,24 @@ func loadConfig() (*config, []string, error) {
 		}
 	}
 
+	if cfg.LockWatchdog < 0 {
+		str := "%s: The lockwatchdog option may not be less than 0 -- " +
+			"parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.LockWatchdog)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.RPCSlowLog < 0 {
+		str := "%s: The rpcslowlog option may not be less than 0 -- " +
+			"parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.RPCSlowLog)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	if cfg.RPCMaxConcurrentReqs < 0 {
 		str := "%s: The rpcmaxwebsocketconcurrentrequests option may " +
 			"not be less than 0 -- parsed [%d]"
@@ -854,6 +1372,44 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// The diffusion delay of locally submitted transactions may not be
+	// negative.
+	if cfg.TxDiffusionDelay < 0 {
+		str := "%s: The txdiffusiondelay option may not be less than 0 " +
+			"-- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.TxDiffusionDelay)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Limit the data carrier policy to sane values.
+	if cfg.DataCarrierSize < 0 {
+		str := "%s: The datacarriersize option may not be less than 0 " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.DataCarrierSize)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.MaxDataCarrierOutputs < 0 {
+		str := "%s: The maxdatacarrieroutputs option may not be less " +
+			"than 0 -- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.MaxDataCarrierOutputs)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Validate any given denylisted transactions and script templates.
+	cfg.denylist, err = mempool.NewDenylist(cfg.RejectTxs, cfg.RejectScripts)
+	if err != nil {
+		err := fmt.Errorf("%s: %v", funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Excessive blocksize cannot be set less than the default but it can be higher.
 	cfg.ExcessiveBlockSize = maxUint32(cfg.ExcessiveBlockSize, defaultExcessiveBlockSize)
 
@@ -909,6 +1465,58 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// --spentindex and --dropspentindex do not mix.
+	if cfg.SpentIndex && cfg.DropSpentIndex {
+		err := fmt.Errorf("%s: the --spentindex and --dropspentindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --scripttypeindex and --dropscripttypeindex do not mix.
+	if cfg.ScriptTypeIndex && cfg.DropScriptTypeIndex {
+		err := fmt.Errorf("%s: the --scripttypeindex and "+
+			"--dropscripttypeindex options may not be activated at "+
+			"the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The built-in wallet is only available when it is compiled in.
+	if (cfg.Wallet || cfg.CreateWallet || cfg.DropWalletIndex) &&
+		!walletSupported {
+
+		err := fmt.Errorf("%s: the --wallet, --createwallet and "+
+			"--dropwalletindex options require a node built with "+
+			"the wallet build tag (go build -tags wallet)",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The mining payouts can only go to the wallet when it is enabled.
+	if cfg.MiningWallet && !cfg.Wallet {
+		err := fmt.Errorf("%s: the --miningwallet option requires "+
+			"--wallet", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --wallet and --dropwalletindex do not mix.
+	if cfg.Wallet && cfg.DropWalletIndex {
+		err := fmt.Errorf("%s: the --wallet and --dropwalletindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// --addrindex and --droptxindex do not mix.
 	if cfg.AddrIndex && cfg.DropTxIndex {
 		err := fmt.Errorf("%s: the --addrindex and --droptxindex "+
@@ -944,7 +1552,7 @@ func loadConfig() (*config, []string, error) {
 
 	// Ensure there is at least one mining address when the generate flag is
 	// set.
-	if cfg.Generate && len(cfg.MiningAddrs) == 0 {
+	if cfg.Generate && len(cfg.MiningAddrs) == 0 && !cfg.MiningWallet {
 		str := "%s: the generate flag is set, but there are no mining " +
 			"addresses specified "
 		err := fmt.Errorf(str, funcName)
@@ -953,6 +1561,93 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Check the block signing key is valid for a signed network and save
+	// the parsed version.
+	if cfg.SignetKey != "" {
+		if activeNetParams.SignetChallenge == nil {
+			str := "%s: the signetkey option can only be used on a " +
+				"signed network"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		wif, err := czzutil.DecodeWIF(cfg.SignetKey)
+		if err != nil || !wif.IsForNet(activeNetParams.Params) {
+			str := "%s: the signet key is not a valid private key " +
+				"of the network"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.blockSigner = blockchain.NewPrivKeySigner(wif.PrivKey)
+	}
+
+	// Check the remote block signer options are valid for a signed network,
+	// so the node holds only the public key of the block signing key.
+	if cfg.SignetSigner != "" || cfg.SignetSignerPubKey != "" {
+		if activeNetParams.SignetChallenge == nil {
+			str := "%s: the signetsigner and signetsignerpubkey " +
+				"options can only be used on a signed network"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if cfg.SignetKey != "" {
+			str := "%s: the signetkey and signetsigner options " +
+				"can not be used together"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if cfg.SignetSigner == "" || cfg.SignetSignerPubKey == "" {
+			str := "%s: the signetsigner and signetsignerpubkey " +
+				"options must be used together"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		pubKeyBytes, err := hex.DecodeString(cfg.SignetSignerPubKey)
+		var pubKey *czzec.PublicKey
+		if err == nil && len(pubKeyBytes) == czzec.PubKeyBytesLenCompressed {
+			pubKey, err = czzec.ParsePubKey(pubKeyBytes, czzec.S256())
+		}
+		if pubKey == nil || err != nil {
+			str := "%s: the signet signer public key is not a valid " +
+				"hex-encoded compressed public key"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		signer, err := remotesigner.New(cfg.SignetSigner, pubKey)
+		if err != nil {
+			str := "%s: the signet signer URL is invalid: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.blockSigner = signer
+	}
+
+	// Ensure there is a signer to sign the generated blocks with on a
+	// signed network.
+	if cfg.Generate && activeNetParams.SignetChallenge != nil &&
+		cfg.blockSigner == nil {
+
+		str := "%s: the generate flag is set on a signed network, but " +
+			"there is no signet key or signer specified"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Check mining addresses are valid and saved parsed versions.
 	cfg.miningAddrs = make([]czzutil.Address, 0, len(cfg.MiningAddrs))
 	for _, strAddr := range cfg.MiningAddrs {
@@ -989,6 +1684,16 @@ func loadConfig() (*config, []string, error) {
 	cfg.GrpcListeners = normalizeAddresses(cfg.GrpcListeners,
 		activeNetParams.gRRPPort)
 
+	// Add default port to all metrics listener addresses if needed and
+	// remove duplicate addresses.
+	cfg.MetricsListeners = normalizeAddresses(cfg.MetricsListeners,
+		activeNetParams.metricsPort)
+
+	// Add default port to all bridge status listener addresses if needed
+	// and remove duplicate addresses.
+	cfg.BridgeListeners = normalizeAddresses(cfg.BridgeListeners,
+		activeNetParams.bridgePort)
+
 	// Only allow TLS to be disabled if the RPC or gRPC is bound to localhost
 	// addresses.
 	if !cfg.DisableRPC && cfg.DisableTLS {
@@ -1046,6 +1751,8 @@ func loadConfig() (*config, []string, error) {
 		activeNetParams.DefaultPort)
 	cfg.ConnectPeers = normalizeAddresses(cfg.ConnectPeers,
 		activeNetParams.DefaultPort)
+	cfg.BridgePeers = normalizeAddresses(cfg.BridgePeers,
+		activeNetParams.DefaultPort)
 
 	// --noonion and --onion do not mix.
 	if cfg.NoOnion && cfg.OnionProxy != "" {
@@ -1056,6 +1763,52 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// --torcontrol requires a valid address and a listener the onion
+	// service can forward connections to.
+	if cfg.TorControl != "" {
+		_, _, err := net.SplitHostPort(cfg.TorControl)
+		if err != nil {
+			str := "%s: Tor control address '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, cfg.TorControl, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if cfg.DisableListen {
+			str := "%s: the --torcontrol and --nolisten options may " +
+				"not be activated at the same time"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
+	// Anonymity mode requires all connections to go through a Tor proxy
+	// and implies stream isolation.  UPnP is disabled since mapping a port
+	// would reveal the address of the node.
+	if cfg.TorAnonymous {
+		if cfg.Proxy == "" || cfg.NoOnion {
+			str := "%s: the --toranonymous option requires --proxy " +
+				"to be set to a Tor proxy"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if cfg.IPv4Proxy != "" || cfg.IPv6Proxy != "" || cfg.NoLANProxy {
+			str := "%s: the --toranonymous option may not be " +
+				"combined with --ipv4proxy, --ipv6proxy or " +
+				"--nolanproxy"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.TorIsolation = true
+		cfg.Upnp = false
+	}
+
 	// Check the checkpoints for syntax errors.
 	cfg.addCheckpoints, err = parseCheckpoints(cfg.AddCheckpoints)
 	if err != nil {
@@ -1094,14 +1847,11 @@ func loadConfig() (*config, []string, error) {
 			return nil, nil, err
 		}
 
-		// Tor isolation flag means proxy credentials will be overridden
-		// unless there is also an onion proxy configured in which case
-		// that one will be overridden.
-		torIsolation := false
-		if cfg.TorIsolation && cfg.OnionProxy == "" &&
-			(cfg.ProxyUser != "" || cfg.ProxyPass != "") {
-
-			torIsolation = true
+		// Tor isolation flag means proxy credentials will be randomized
+		// for each connection unless there is also an onion proxy
+		// configured in which case that one will be isolated instead.
+		torIsolation := cfg.TorIsolation && cfg.OnionProxy == ""
+		if torIsolation && (cfg.ProxyUser != "" || cfg.ProxyPass != "") {
 			fmt.Fprintln(os.Stderr, "Tor isolation set -- "+
 				"overriding specified proxy user credentials")
 		}
@@ -1124,6 +1874,36 @@ func loadConfig() (*config, []string, error) {
 		}
 	}
 
+	// Setup the dial functions of the networks with their own proxy.  They
+	// take precedence over the dial function selected above for addresses
+	// of their network.
+	for _, p := range []struct {
+		name string
+		addr string
+		dial *func(string, string, time.Duration) (net.Conn, error)
+	}{
+		{"IPv4", cfg.IPv4Proxy, &cfg.ipv4dial},
+		{"IPv6", cfg.IPv6Proxy, &cfg.ipv6dial},
+	} {
+		if p.addr == "" {
+			continue
+		}
+		_, _, err := net.SplitHostPort(p.addr)
+		if err != nil {
+			str := "%s: %s proxy address '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, p.name, p.addr, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		proxy := &socks.Proxy{
+			Addr:     p.addr,
+			Username: cfg.ProxyUser,
+			Password: cfg.ProxyPass,
+		}
+		*p.dial = proxy.DialTimeout
+	}
+
 	// Setup onion address dial function depending on the specified options.
 	// The default is to use the same dial function selected above.  However,
 	// when an onion-specific proxy is specified, the onion address dial
@@ -1260,7 +2040,59 @@ func czzdDial(addr net.Addr) (net.Conn, error) {
 		return cfg.oniondial(addr.Network(), addr.String(),
 			defaultConnectTimeout)
 	}
-	return cfg.dial(addr.Network(), addr.String(), defaultConnectTimeout)
+	return dialForAddr(addr)(addr.Network(), addr.String(),
+		defaultConnectTimeout)
+}
+
+// dialForAddr returns the dial function used to connect to the non-onion
+// address depending on its network.  Addresses on local networks are dialed
+// directly when --nolanproxy is set, and IPv4 and IPv6 addresses use the proxy
+// of their network when one was specified.
+func dialForAddr(addr net.Addr) func(string, string, time.Duration) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return cfg.dial
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return cfg.dial
+	case cfg.NoLANProxy && (ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast()):
+		return net.DialTimeout
+	case ip.To4() != nil && cfg.ipv4dial != nil:
+		return cfg.ipv4dial
+	case ip.To4() == nil && cfg.ipv6dial != nil:
+		return cfg.ipv6dial
+	}
+	return cfg.dial
+}
+
+// networkProxy returns the address of the proxy used to connect to peers on
+// the passed network, which is one of "ipv4", "ipv6" and "onion", and whether
+// the proxy credentials are randomized for each connection.  An empty address
+// means connections are made directly.
+func networkProxy(network string) (string, bool) {
+	proxyIsolation := cfg.Proxy != "" && cfg.TorIsolation &&
+		cfg.OnionProxy == ""
+	switch network {
+	case "ipv4":
+		if cfg.IPv4Proxy != "" {
+			return cfg.IPv4Proxy, false
+		}
+	case "ipv6":
+		if cfg.IPv6Proxy != "" {
+			return cfg.IPv6Proxy, false
+		}
+	case "onion":
+		if cfg.NoOnion {
+			return "", false
+		}
+		if cfg.OnionProxy != "" {
+			return cfg.OnionProxy, cfg.TorIsolation
+		}
+	}
+	return cfg.Proxy, proxyIsolation
 }
 
 // czzdLookup resolves the IP of the given host using the correct DNS lookup
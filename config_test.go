@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/bourbaki-czz/classzz/chaincfg"
 	"github.com/bourbaki-czz/classzz/czzec"
+	"github.com/bourbaki-czz/classzz/peer"
 	"github.com/bourbaki-czz/czzutil"
 	"io/ioutil"
 	"os"
@@ -113,3 +114,132 @@ func TestGenesisAdderss(t *testing.T) {
 	fmt.Println(address.String())
 
 }
+
+func TestNetworkProxy(t *testing.T) {
+	os.Args = []string{"classzz", "--proxy=127.0.0.1:9050",
+		"--ipv6proxy=[::1]:1080", "--torisolation"}
+
+	tcfg, _, err := loadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+	origCfg := cfg
+	cfg = tcfg
+	defer func() { cfg = origCfg }()
+
+	tests := []struct {
+		network   string
+		proxy     string
+		isolation bool
+	}{
+		{"ipv4", "127.0.0.1:9050", true},
+		{"ipv6", "[::1]:1080", false},
+		{"onion", "127.0.0.1:9050", true},
+	}
+	for _, test := range tests {
+		proxy, isolation := networkProxy(test.network)
+		if proxy != test.proxy || isolation != test.isolation {
+			t.Errorf("networkProxy(%s): got %s %v, want %s %v",
+				test.network, proxy, isolation, test.proxy,
+				test.isolation)
+		}
+	}
+}
+
+// TestOverrideDeployments ensures deployment overrides replace the start and
+// expire times of the named deployments and that malformed ones are rejected.
+func TestOverrideDeployments(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	err := overrideDeployments(&params, []string{"csv:10:20",
+		"seq:0:18446744073709551615"})
+	if err != nil {
+		t.Fatalf("overrideDeployments: unexpected error: %v", err)
+	}
+	csv := params.Deployments[chaincfg.DeploymentCSV]
+	if csv.StartTime != 10 || csv.ExpireTime != 20 ||
+		csv.BitNumber != chaincfg.RegressionNetParams.Deployments[chaincfg.DeploymentCSV].BitNumber {
+
+		t.Errorf("overrideDeployments: got csv deployment %+v", csv)
+	}
+	seq := params.Deployments[chaincfg.DeploymentSEQ]
+	if seq.StartTime != 0 || seq.ExpireTime != 1<<64-1 {
+		t.Errorf("overrideDeployments: got seq deployment %+v", seq)
+	}
+	if chaincfg.RegressionNetParams.Deployments[chaincfg.DeploymentCSV] == csv {
+		t.Errorf("overrideDeployments: base network was modified")
+	}
+
+	for _, override := range []string{"csv", "csv:1", "foo:1:2",
+		"csv:x:2", "csv:1:x", "csv:2:1"} {
+
+		if err := overrideDeployments(&params, []string{override}); err == nil {
+			t.Errorf("overrideDeployments(%q): unexpected success",
+				override)
+		}
+	}
+}
+
+// TestParseDebugLevels ensures debug levels are parsed into the levels of the
+// subsystems they set and that invalid ones are rejected.
+func TestParseDebugLevels(t *testing.T) {
+	levels, err := parseDebugLevels("debug")
+	if err != nil {
+		t.Fatalf("parseDebugLevels: unexpected error: %v", err)
+	}
+	if len(levels) != len(subsystemLoggers) || levels["PEER"] != "debug" {
+		t.Errorf("parseDebugLevels: got %v", levels)
+	}
+
+	levels, err = parseDebugLevels("PEER=trace,RPCS=warn")
+	if err != nil {
+		t.Fatalf("parseDebugLevels: unexpected error: %v", err)
+	}
+	if len(levels) != 2 || levels["PEER"] != "trace" ||
+		levels["RPCS"] != "warn" {
+
+		t.Errorf("parseDebugLevels: got %v", levels)
+	}
+
+	for _, debugLevel := range []string{"loud", "PEER", "PEER=loud",
+		"NONE=debug", "PEER=debug,trace"} {
+
+		if _, err := parseDebugLevels(debugLevel); err == nil {
+			t.Errorf("parseDebugLevels(%q): unexpected success",
+				debugLevel)
+		}
+	}
+}
+
+// TestParseWhitelists ensures whitelisted addresses and networks are parsed
+// along with the permissions granted to their peers.
+func TestParseWhitelists(t *testing.T) {
+	whitelists, err := parseWhitelists([]string{"10.0.0.0/8", "::1",
+		"noban,forcerelay@192.168.1.1"})
+	if err != nil {
+		t.Fatalf("parseWhitelists: unexpected error: %v", err)
+	}
+	want := []struct {
+		ipnet string
+		perms peer.Permissions
+	}{
+		{"10.0.0.0/8", peer.PermissionsDefault},
+		{"::1/128", peer.PermissionsDefault},
+		{"192.168.1.1/32", peer.PermissionNoBan | peer.PermissionForceRelay},
+	}
+	if len(whitelists) != len(want) {
+		t.Fatalf("parseWhitelists: got %d whitelists, want %d",
+			len(whitelists), len(want))
+	}
+	for i, wl := range whitelists {
+		if wl.ipnet.String() != want[i].ipnet || wl.perms != want[i].perms {
+			t.Errorf("parseWhitelists #%d: got %v %v, want %v %v", i,
+				wl.ipnet, wl.perms, want[i].ipnet, want[i].perms)
+		}
+	}
+
+	for _, addr := range []string{"10.0.0", "foo@10.0.0.1"} {
+		if _, err := parseWhitelists([]string{addr}); err == nil {
+			t.Errorf("parseWhitelists(%q): unexpected success", addr)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/mempool"
+	"github.com/jessevdk/go-flags"
+)
+
+// reparseConfig parses the configuration file and the command line options on
+// top of the default config the same way loadConfig does, without validating
+// the options or acting on them.
+func reparseConfig() (*config, error) {
+	newCfg := defaultConfig()
+	serviceOpts := serviceOptions{}
+	parser := newConfigParser(&newCfg, &serviceOpts, flags.None)
+	if !(cfg.RegressionTest || cfg.SimNet) || cfg.ConfigFile !=
+		defaultConfigFile {
+
+		err := flags.NewIniParser(parser).ParseFile(cfg.ConfigFile)
+		if err != nil {
+			if _, ok := err.(*os.PathError); !ok {
+				return nil, err
+			}
+		}
+	}
+
+	// Parse command line options again to ensure they take precedence.
+	if _, err := parser.Parse(); err != nil {
+		return nil, err
+	}
+	return &newCfg, nil
+}
+
+// stringsEqual returns whether the passed string slices hold the same strings
+// in the same order.
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// reloadConfig parses the configuration file and the command line options
+// again and applies the options which can be changed while the node is
+// running: the debug levels, the ban options, the whitelisted networks, the
+// relay denylist, the limits of the RPC server, the slow RPC request log and the
+// RPC servers of the nodes entangled outputs are verified against.  The names of the options whose values changed are
+// returned.
+//
+// The changed options are only applied when all of them are valid.  The
+// whitelisted networks apply to the peers which connect afterwards and the
+// RPC limits to the clients which connect afterwards.  The transactions of the
+// memory pool rejected by the new denylist are removed.  Other options keep
+// their values until the node is restarted.
+//
+// This function is safe for concurrent access.
+func (s *server) reloadConfig() ([]string, error) {
+	s.reloadMtx.Lock()
+	defer s.reloadMtx.Unlock()
+
+	changed, err := s.applyReloadedConfig()
+	if err != nil {
+		czzdLog.Errorf("Unable to reload the configuration: %v", err)
+		return nil, err
+	}
+	if len(changed) == 0 {
+		czzdLog.Infof("Reloaded the configuration -- no option changed")
+	} else {
+		czzdLog.Infof("Reloaded the configuration -- changed %s",
+			strings.Join(changed, ", "))
+	}
+	return changed, nil
+}
+
+// applyReloadedConfig implements reloadConfig.
+//
+// This function MUST be called with the reload mutex held.
+func (s *server) applyReloadedConfig() ([]string, error) {
+	newCfg, err := reparseConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate the changed options before any of them is applied.
+	var levels map[string]string
+	if newCfg.DebugLevel != cfg.DebugLevel {
+		levels, err = parseDebugLevels(newCfg.DebugLevel)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if newCfg.BanDuration < time.Second {
+		str := "The banduration option may not be less than 1s -- " +
+			"parsed [%v]"
+		return nil, fmt.Errorf(str, newCfg.BanDuration)
+	}
+	whitelists, err := parseWhitelists(newCfg.Whitelists)
+	if err != nil {
+		return nil, err
+	}
+	denylist, err := mempool.NewDenylist(newCfg.RejectTxs,
+		newCfg.RejectScripts)
+	if err != nil {
+		return nil, err
+	}
+	if newCfg.RPCMaxConcurrentReqs < 0 {
+		str := "The rpcmaxconcurrentreqs option may not be less than " +
+			"0 -- parsed [%d]"
+		return nil, fmt.Errorf(str, newCfg.RPCMaxConcurrentReqs)
+	}
+	if newCfg.RPCSlowLog < 0 {
+		str := "The rpcslowlog option may not be less than 0 -- " +
+			"parsed [%v]"
+		return nil, fmt.Errorf(str, newCfg.RPCSlowLog)
+	}
+
+	var changed []string
+	if !stringsEqual(newCfg.DogeCoinRPC, cfg.DogeCoinRPC) {
+		changed = append(changed, "dogecoinrpc")
+	}
+	if newCfg.DogeCoinRPCUser != cfg.DogeCoinRPCUser {
+		changed = append(changed, "dogecoinrpcuser")
+	}
+	if newCfg.DogeCoinRPCPass != cfg.DogeCoinRPCPass {
+		changed = append(changed, "dogecoinrpcpass")
+	}
+	if !stringsEqual(newCfg.LtcCoinRPC, cfg.LtcCoinRPC) {
+		changed = append(changed, "ltccoinrpc")
+	}
+	if newCfg.LtcCoinRPCUser != cfg.LtcCoinRPCUser {
+		changed = append(changed, "ltccoinrpcuser")
+	}
+	if newCfg.LtcCoinRPCPass != cfg.LtcCoinRPCPass {
+		changed = append(changed, "ltccoinrpcpass")
+	}
+
+	// The clients of the entangle RPC servers are replaced first since it
+	// is the only change which can still fail.
+	if len(changed) > 0 {
+		err := s.chain.SetEntangleRPC(newCfg.DogeCoinRPC,
+			newCfg.DogeCoinRPCUser, newCfg.DogeCoinRPCPass,
+			newCfg.LtcCoinRPC, newCfg.LtcCoinRPCUser,
+			newCfg.LtcCoinRPCPass)
+		if err != nil {
+			return nil, err
+		}
+		cfg.DogeCoinRPC = newCfg.DogeCoinRPC
+		cfg.DogeCoinRPCUser = newCfg.DogeCoinRPCUser
+		cfg.DogeCoinRPCPass = newCfg.DogeCoinRPCPass
+		cfg.LtcCoinRPC = newCfg.LtcCoinRPC
+		cfg.LtcCoinRPCUser = newCfg.LtcCoinRPCUser
+		cfg.LtcCoinRPCPass = newCfg.LtcCoinRPCPass
+	}
+
+	if levels != nil {
+		for subsysID, logLevel := range levels {
+			setLogLevel(subsysID, logLevel)
+		}
+		cfg.DebugLevel = newCfg.DebugLevel
+		changed = append(changed, "debuglevel")
+	}
+	if newCfg.DisableBanning != cfg.DisableBanning {
+		cfg.DisableBanning = newCfg.DisableBanning
+		changed = append(changed, "nobanning")
+	}
+	if newCfg.BanDuration != cfg.BanDuration {
+		cfg.BanDuration = newCfg.BanDuration
+		changed = append(changed, "banduration")
+	}
+	if newCfg.BanThreshold != cfg.BanThreshold {
+		cfg.BanThreshold = newCfg.BanThreshold
+		changed = append(changed, "banthreshold")
+	}
+	if !stringsEqual(newCfg.Whitelists, cfg.Whitelists) {
+		cfg.Whitelists = newCfg.Whitelists
+		cfg.whitelists = whitelists
+		changed = append(changed, "whitelist")
+	}
+	rejectTxsChanged := !stringsEqual(newCfg.RejectTxs, cfg.RejectTxs)
+	rejectScriptsChanged := !stringsEqual(newCfg.RejectScripts,
+		cfg.RejectScripts)
+	if rejectTxsChanged || rejectScriptsChanged {
+		s.txMemPool.SetDenylist(denylist)
+		cfg.RejectTxs = newCfg.RejectTxs
+		cfg.RejectScripts = newCfg.RejectScripts
+		cfg.denylist = denylist
+		if rejectTxsChanged {
+			changed = append(changed, "rejecttx")
+		}
+		if rejectScriptsChanged {
+			changed = append(changed, "rejectscript")
+		}
+	}
+	if newCfg.RPCMaxClients != cfg.RPCMaxClients {
+		cfg.RPCMaxClients = newCfg.RPCMaxClients
+		changed = append(changed, "rpcmaxclients")
+	}
+	if newCfg.RPCMaxWebsockets != cfg.RPCMaxWebsockets {
+		cfg.RPCMaxWebsockets = newCfg.RPCMaxWebsockets
+		changed = append(changed, "rpcmaxwebsockets")
+	}
+	if newCfg.RPCMaxConcurrentReqs != cfg.RPCMaxConcurrentReqs {
+		cfg.RPCMaxConcurrentReqs = newCfg.RPCMaxConcurrentReqs
+		changed = append(changed, "rpcmaxconcurrentreqs")
+	}
+	if newCfg.RPCSlowLog != cfg.RPCSlowLog {
+		cfg.RPCSlowLog = newCfg.RPCSlowLog
+		changed = append(changed, "rpcslowlog")
+	}
+	return changed, nil
+}
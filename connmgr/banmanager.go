@@ -0,0 +1,284 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// banListVersion is the version of the serialized ban list.
+const banListVersion = 1
+
+// maxBanReasonLen is the maximum length of a ban reason which is kept.
+const maxBanReasonLen = 256
+
+// BanListDatabaseKey is the key used in the database metadata bucket to store
+// the ban list.
+var BanListDatabaseKey = []byte("banlist")
+
+// ErrInvalidBanList indicates a serialized ban list could not be decoded.
+var ErrInvalidBanList = errors.New("invalid serialized ban list")
+
+// Ban describes a banned IP network.
+type Ban struct {
+	// Net is the banned network.  Bans of a single address have a full
+	// length mask.
+	Net *net.IPNet
+
+	// Created is the time the ban was created.
+	Created time.Time
+
+	// Until is the time the ban expires.
+	Until time.Time
+
+	// Reason describes why the network was banned.
+	Reason string
+}
+
+// BanManager keeps track of banned IP networks.  Bans expire after their
+// duration and the list can be serialized so bans survive restarts.
+//
+// A BanManager is safe for concurrent access.
+type BanManager struct {
+	mtx  sync.Mutex
+	bans map[string]*Ban
+}
+
+// NewBanManager returns a ban manager without any bans.
+func NewBanManager() *BanManager {
+	return &BanManager{bans: make(map[string]*Ban)}
+}
+
+// ParseBanSubnet parses an IP address or a network in CIDR notation into the
+// network to ban.  A single address is returned as a network with a full
+// length mask.
+func ParseBanSubnet(s string) (*net.IPNet, error) {
+	if _, ipnet, err := net.ParseCIDR(s); err == nil {
+		return normalizeBanNet(ipnet), nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address or subnet %q", s)
+	}
+	return singleIPNet(ip), nil
+}
+
+// singleIPNet returns the network containing only ip.
+func singleIPNet(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip.To16(), Mask: net.CIDRMask(128, 128)}
+}
+
+// normalizeBanNet returns ipnet with IPv4 networks converted to their 4 byte
+// form so that equal networks have the same key.
+func normalizeBanNet(ipnet *net.IPNet) *net.IPNet {
+	ones, bits := ipnet.Mask.Size()
+	if ip4 := ipnet.IP.To4(); ip4 != nil && bits == 128 && ones >= 96 {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(ones-96, 32)}
+	}
+	return ipnet
+}
+
+// Ban bans ipnet until the passed time.  An existing ban of the same network
+// is replaced.
+func (bm *BanManager) Ban(ipnet *net.IPNet, until time.Time, reason string) {
+	ipnet = normalizeBanNet(ipnet)
+	if len(reason) > maxBanReasonLen {
+		reason = reason[:maxBanReasonLen]
+	}
+
+	bm.mtx.Lock()
+	bm.bans[ipnet.String()] = &Ban{
+		Net:     ipnet,
+		Created: time.Now(),
+		Until:   until,
+		Reason:  reason,
+	}
+	bm.mtx.Unlock()
+}
+
+// BanIP bans the single address ip for the passed duration.
+func (bm *BanManager) BanIP(ip net.IP, duration time.Duration, reason string) {
+	bm.Ban(singleIPNet(ip), time.Now().Add(duration), reason)
+}
+
+// Unban removes the ban of ipnet.  It returns whether the network was banned.
+func (bm *BanManager) Unban(ipnet *net.IPNet) bool {
+	key := normalizeBanNet(ipnet).String()
+
+	bm.mtx.Lock()
+	defer bm.mtx.Unlock()
+
+	ban, ok := bm.bans[key]
+	if !ok {
+		return false
+	}
+	delete(bm.bans, key)
+	return time.Now().Before(ban.Until)
+}
+
+// Clear removes all bans.
+func (bm *BanManager) Clear() {
+	bm.mtx.Lock()
+	bm.bans = make(map[string]*Ban)
+	bm.mtx.Unlock()
+}
+
+// IsBanned returns the ban which applies to ip, if any.  When several bans
+// apply the one which expires last is returned.
+func (bm *BanManager) IsBanned(ip net.IP) (*Ban, bool) {
+	now := time.Now()
+
+	bm.mtx.Lock()
+	defer bm.mtx.Unlock()
+
+	var match *Ban
+	for key, ban := range bm.bans {
+		if !now.Before(ban.Until) {
+			delete(bm.bans, key)
+			continue
+		}
+		if ban.Net.Contains(ip) && (match == nil ||
+			ban.Until.After(match.Until)) {
+
+			match = ban
+		}
+	}
+	if match == nil {
+		return nil, false
+	}
+	ban := *match
+	return &ban, true
+}
+
+// Bans returns the bans which have not expired ordered by network.
+func (bm *BanManager) Bans() []Ban {
+	now := time.Now()
+
+	bm.mtx.Lock()
+	bans := make([]Ban, 0, len(bm.bans))
+	for key, ban := range bm.bans {
+		if !now.Before(ban.Until) {
+			delete(bm.bans, key)
+			continue
+		}
+		bans = append(bans, *ban)
+	}
+	bm.mtx.Unlock()
+
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].Net.String() < bans[j].Net.String()
+	})
+	return bans
+}
+
+// Serialize returns the bans which have not expired in a format suitable to
+// be stored in the database and restored with Deserialize.
+func (bm *BanManager) Serialize() []byte {
+	bans := bm.Bans()
+
+	var buf bytes.Buffer
+	buf.WriteByte(banListVersion)
+	binary.Write(&buf, binary.BigEndian, uint32(len(bans)))
+	for _, ban := range bans {
+		ones, _ := ban.Net.Mask.Size()
+		buf.WriteByte(byte(len(ban.Net.IP)))
+		buf.Write(ban.Net.IP)
+		buf.WriteByte(byte(ones))
+		binary.Write(&buf, binary.BigEndian, ban.Created.Unix())
+		binary.Write(&buf, binary.BigEndian, ban.Until.Unix())
+		binary.Write(&buf, binary.BigEndian, uint16(len(ban.Reason)))
+		buf.WriteString(ban.Reason)
+	}
+	return buf.Bytes()
+}
+
+// Deserialize adds the bans of a ban list returned by Serialize.  Bans which
+// have expired in the meantime are dropped.
+func (bm *BanManager) Deserialize(data []byte) error {
+	r := bytes.NewReader(data)
+	version, err := r.ReadByte()
+	if err != nil || version != banListVersion {
+		return ErrInvalidBanList
+	}
+	var count uint32
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return ErrInvalidBanList
+	}
+
+	now := time.Now()
+	bans := make([]*Ban, 0, count)
+	for i := uint32(0); i < count; i++ {
+		ban, err := readBan(r)
+		if err != nil {
+			return ErrInvalidBanList
+		}
+		if now.Before(ban.Until) {
+			bans = append(bans, ban)
+		}
+	}
+
+	bm.mtx.Lock()
+	for _, ban := range bans {
+		bm.bans[ban.Net.String()] = ban
+	}
+	bm.mtx.Unlock()
+	return nil
+}
+
+// readBan reads a single ban written by Serialize from r.
+func readBan(r *bytes.Reader) (*Ban, error) {
+	ipLen, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if ipLen != net.IPv4len && ipLen != net.IPv6len {
+		return nil, ErrInvalidBanList
+	}
+	ip := make(net.IP, ipLen)
+	if _, err := io.ReadFull(r, ip); err != nil {
+		return nil, err
+	}
+	ones, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if int(ones) > int(ipLen)*8 {
+		return nil, ErrInvalidBanList
+	}
+
+	var created, until int64
+	var reasonLen uint16
+	if err := binary.Read(r, binary.BigEndian, &created); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &until); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &reasonLen); err != nil {
+		return nil, err
+	}
+	reason := make([]byte, reasonLen)
+	if _, err := io.ReadFull(r, reason); err != nil {
+		return nil, err
+	}
+
+	return &Ban{
+		Net:     &net.IPNet{IP: ip, Mask: net.CIDRMask(int(ones), int(ipLen)*8)},
+		Created: time.Unix(created, 0),
+		Until:   time.Unix(until, 0),
+		Reason:  string(reason),
+	}, nil
+}
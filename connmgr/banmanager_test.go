@@ -0,0 +1,86 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// TestBanManager ensures single address and CIDR bans match the expected
+// addresses, expire, and survive a serialization round trip.
+func TestBanManager(t *testing.T) {
+	bm := NewBanManager()
+	bm.BanIP(net.ParseIP("1.2.3.4"), time.Hour, "misbehaving")
+	subnet, err := ParseBanSubnet("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("ParseBanSubnet: unexpected error: %v", err)
+	}
+	bm.Ban(subnet, time.Now().Add(time.Hour), "manually added")
+	v6, err := ParseBanSubnet("2001:db8::/32")
+	if err != nil {
+		t.Fatalf("ParseBanSubnet: unexpected error: %v", err)
+	}
+	bm.Ban(v6, time.Now().Add(time.Hour), "")
+	expired, _ := ParseBanSubnet("192.168.0.1")
+	bm.Ban(expired, time.Now().Add(-time.Second), "")
+
+	tests := []struct {
+		ip     string
+		banned bool
+	}{
+		{"1.2.3.4", true},
+		{"1.2.3.5", false},
+		{"10.200.1.1", true},
+		{"::ffff:10.1.1.1", true},
+		{"11.0.0.1", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+		{"192.168.0.1", false},
+	}
+	check := func(bm *BanManager) {
+		for _, test := range tests {
+			_, banned := bm.IsBanned(net.ParseIP(test.ip))
+			if banned != test.banned {
+				t.Errorf("IsBanned(%s): got %v, want %v", test.ip,
+					banned, test.banned)
+			}
+		}
+	}
+	check(bm)
+
+	bans := bm.Bans()
+	if len(bans) != 3 {
+		t.Fatalf("Bans: got %d bans, want 3", len(bans))
+	}
+	if bans[0].Net.String() != "1.2.3.4/32" || bans[0].Reason != "misbehaving" {
+		t.Fatalf("Bans: got %v %q", bans[0].Net, bans[0].Reason)
+	}
+
+	restored := NewBanManager()
+	if err := restored.Deserialize(bm.Serialize()); err != nil {
+		t.Fatalf("Deserialize: unexpected error: %v", err)
+	}
+	check(restored)
+	if err := restored.Deserialize([]byte{banListVersion, 0, 0, 0, 1}); err == nil {
+		t.Fatalf("Deserialize: unexpected success for truncated list")
+	}
+
+	if !restored.Unban(subnet) {
+		t.Fatalf("Unban: subnet was not banned")
+	}
+	if _, banned := restored.IsBanned(net.ParseIP("10.0.0.1")); banned {
+		t.Fatalf("IsBanned: address still banned after Unban")
+	}
+	restored.Clear()
+	if len(restored.Bans()) != 0 {
+		t.Fatalf("Clear: bans left")
+	}
+
+	if _, err := ParseBanSubnet("1.2.3"); err == nil {
+		t.Fatalf("ParseBanSubnet: unexpected success for invalid address")
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxTrackedIPs is the number of addresses the inbound limiter keeps track of
+// before it forgets addresses which are not rate limited anymore.
+const maxTrackedIPs = 4096
+
+var (
+	// ErrInboundRateLimited indicates an inbound connection was refused
+	// because the address connected too often recently.
+	ErrInboundRateLimited = errors.New("inbound connection rate limit " +
+		"exceeded")
+
+	// ErrTooManyHalfOpen indicates an inbound connection was refused
+	// because too many inbound connections did not complete the handshake
+	// yet.
+	ErrTooManyHalfOpen = errors.New("too many half-open inbound " +
+		"connections")
+)
+
+// rateBucket is the token bucket limiting the connections of one address.
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// InboundLimiter protects against inbound connection floods.  It limits the
+// rate of connections accepted from a single IP address and the number of
+// accepted connections which did not complete the handshake yet, so a flood
+// can not exhaust the file descriptors of the process.
+//
+// An InboundLimiter is safe for concurrent access.
+type InboundLimiter struct {
+	mtx         sync.Mutex
+	ratePerMin  int
+	maxHalfOpen int
+	halfOpen    int
+	buckets     map[string]*rateBucket
+}
+
+// NewInboundLimiter returns an inbound limiter which accepts up to ratePerMin
+// connections per minute from a single address, in bursts of at most that many
+// connections, and up to maxHalfOpen connections which did not complete the
+// handshake.  A non-positive value disables the respective limit.
+func NewInboundLimiter(ratePerMin, maxHalfOpen int) *InboundLimiter {
+	return &InboundLimiter{
+		ratePerMin:  ratePerMin,
+		maxHalfOpen: maxHalfOpen,
+		buckets:     make(map[string]*rateBucket),
+	}
+}
+
+// Accept decides whether a new inbound connection from ip is accepted.  When
+// it returns nil the connection holds a half-open slot which must be released
+// with Done once the handshake completed or the connection was closed.
+func (l *InboundLimiter) Accept(ip net.IP) error {
+	return l.accept(ip, time.Now())
+}
+
+// accept implements Accept with the current time passed in.
+func (l *InboundLimiter) accept(ip net.IP, now time.Time) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.maxHalfOpen > 0 && l.halfOpen >= l.maxHalfOpen {
+		return ErrTooManyHalfOpen
+	}
+
+	if l.ratePerMin > 0 {
+		key := ip.String()
+		b, ok := l.buckets[key]
+		if !ok {
+			if len(l.buckets) >= maxTrackedIPs {
+				l.prune(now)
+			}
+			b = &rateBucket{tokens: float64(l.ratePerMin), last: now}
+			l.buckets[key] = b
+		}
+		l.refill(b, now)
+		if b.tokens < 1 {
+			return ErrInboundRateLimited
+		}
+		b.tokens--
+	}
+
+	l.halfOpen++
+	return nil
+}
+
+// refill adds the tokens earned since the last connection to b.  It must be
+// called with the mutex held.
+func (l *InboundLimiter) refill(b *rateBucket, now time.Time) {
+	b.tokens += now.Sub(b.last).Minutes() * float64(l.ratePerMin)
+	if b.tokens > float64(l.ratePerMin) {
+		b.tokens = float64(l.ratePerMin)
+	}
+	b.last = now
+}
+
+// prune forgets the addresses whose buckets are full again, which behave like
+// addresses that never connected.  It must be called with the mutex held.
+func (l *InboundLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= float64(l.ratePerMin) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Done releases the half-open slot of a connection accepted by Accept.
+func (l *InboundLimiter) Done() {
+	l.mtx.Lock()
+	if l.halfOpen > 0 {
+		l.halfOpen--
+	}
+	l.mtx.Unlock()
+}
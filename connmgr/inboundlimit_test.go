@@ -0,0 +1,54 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// TestInboundLimiter ensures the inbound limiter enforces the per address rate
+// and the half-open connection cap.
+func TestInboundLimiter(t *testing.T) {
+	now := time.Now()
+	ip := net.ParseIP("1.2.3.4")
+	other := net.ParseIP("5.6.7.8")
+
+	l := NewInboundLimiter(2, 0)
+	for i := 0; i < 2; i++ {
+		if err := l.accept(ip, now); err != nil {
+			t.Fatalf("accept #%d: unexpected error: %v", i, err)
+		}
+	}
+	if err := l.accept(ip, now); err != ErrInboundRateLimited {
+		t.Fatalf("accept: got %v, want %v", err, ErrInboundRateLimited)
+	}
+	if err := l.accept(other, now); err != nil {
+		t.Fatalf("accept: unexpected error for other address: %v", err)
+	}
+
+	// A token is earned back after half a minute.
+	if err := l.accept(ip, now.Add(30*time.Second)); err != nil {
+		t.Fatalf("accept: unexpected error after refill: %v", err)
+	}
+	if err := l.accept(ip, now.Add(30*time.Second)); err != ErrInboundRateLimited {
+		t.Fatalf("accept: got %v, want %v", err, ErrInboundRateLimited)
+	}
+
+	l = NewInboundLimiter(0, 2)
+	for i := 0; i < 2; i++ {
+		if err := l.accept(ip, now); err != nil {
+			t.Fatalf("accept #%d: unexpected error: %v", i, err)
+		}
+	}
+	if err := l.accept(other, now); err != ErrTooManyHalfOpen {
+		t.Fatalf("accept: got %v, want %v", err, ErrTooManyHalfOpen)
+	}
+	l.Done()
+	if err := l.accept(other, now); err != nil {
+		t.Fatalf("accept: unexpected error after Done: %v", err)
+	}
+}
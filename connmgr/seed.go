@@ -5,10 +5,13 @@
 package connmgr
 
 import (
+	"bufio"
 	"fmt"
 	mrand "math/rand"
 	"net"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bourbaki-czz/classzz/chaincfg"
@@ -29,11 +32,33 @@ type OnSeed func(addrs []*wire.NetAddress)
 // LookupFunc is the signature of the DNS lookup function.
 type LookupFunc func(string) ([]net.IP, error)
 
+// ResolveFunc is the signature of the function which converts a host and
+// port into a network address.
+type ResolveFunc func(host string, port uint16) (*wire.NetAddress, error)
+
+// seedTimestamp returns a time randomly selected between 3 and 7 days ago,
+// rounded to single second precision.  bitcoind seeds the address manager
+// with addresses last seen at such a time.
+func seedTimestamp(randSource *mrand.Rand) time.Time {
+	seen := time.Now().Add(-1 * time.Second * time.Duration(secondsIn3Days+
+		randSource.Int31n(secondsIn4Days)))
+	return time.Unix(seen.Unix(), 0)
+}
+
 // SeedFromDNS uses DNS seeding to populate the address manager with peers.
 func SeedFromDNS(chainParams *chaincfg.Params, reqServices wire.ServiceFlag,
 	lookupFn LookupFunc, seedFn OnSeed) {
 
-	for _, dnsseed := range chainParams.DNSSeeds {
+	SeedFromDNSSeeds(chainParams.DNSSeeds, chainParams.DefaultPort,
+		reqServices, lookupFn, seedFn)
+}
+
+// SeedFromDNSSeeds uses the passed DNS seeds to populate the address manager
+// with peers listening on defaultPort.
+func SeedFromDNSSeeds(dnsSeeds []chaincfg.DNSSeed, defaultPort string,
+	reqServices wire.ServiceFlag, lookupFn LookupFunc, seedFn OnSeed) {
+
+	for _, dnsseed := range dnsSeeds {
 		var host string
 		if !dnsseed.HasFiltering || reqServices == wire.SFNodeNetwork {
 			host = dnsseed.Host
@@ -58,18 +83,83 @@ func SeedFromDNS(chainParams *chaincfg.Params, reqServices wire.ServiceFlag,
 			}
 			addresses := make([]*wire.NetAddress, len(seedpeers))
 			// if this errors then we have *real* problems
-			intPort, _ := strconv.Atoi(chainParams.DefaultPort)
+			intPort, _ := strconv.Atoi(defaultPort)
 			for i, peer := range seedpeers {
 				addresses[i] = wire.NewNetAddressTimestamp(
-					// bitcoind seeds with addresses from
-					// a time randomly selected between 3
-					// and 7 days ago.
-					time.Now().Add(-1*time.Second*time.Duration(secondsIn3Days+
-						randSource.Int31n(secondsIn4Days))),
-					reqServices, peer, uint16(intPort))
+					seedTimestamp(randSource), reqServices,
+					peer, uint16(intPort))
 			}
 
 			seedFn(addresses)
 		}(host)
 	}
 }
+
+// SeedFromFile populates the address manager with the peers listed in the
+// file at path.  The file lists one host or host:port per line, the default
+// port is used when the port is omitted.  Empty lines and lines starting with
+// '#' are ignored.
+//
+// The file is read before returning, but hosts are resolved asynchronously
+// since resolving may take a while when it is done through a proxy.
+func SeedFromFile(path string, defaultPort string, resolveFn ResolveFunc,
+	seedFn OnSeed) error {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	type hostPort struct {
+		host string
+		port uint16
+	}
+	var hosts []hostPort
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		host, portStr, err := net.SplitHostPort(line)
+		if err != nil {
+			host, portStr = line, defaultPort
+		}
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil || host == "" {
+			return fmt.Errorf("%s:%d: invalid peer address %q", path,
+				lineNum, line)
+		}
+		hosts = append(hosts, hostPort{host, uint16(port)})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	go func() {
+		randSource := mrand.New(mrand.NewSource(time.Now().UnixNano()))
+
+		addresses := make([]*wire.NetAddress, 0, len(hosts))
+		for _, hp := range hosts {
+			na, err := resolveFn(hp.host, hp.port)
+			if err != nil {
+				log.Infof("Unable to resolve seed peer %s: %v",
+					hp.host, err)
+				continue
+			}
+			na.Timestamp = seedTimestamp(randSource)
+			addresses = append(addresses, na)
+		}
+
+		log.Infof("%d addresses found from seed file %s",
+			len(addresses), path)
+
+		if len(addresses) == 0 {
+			return
+		}
+		seedFn(addresses)
+	}()
+
+	return nil
+}
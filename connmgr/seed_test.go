@@ -0,0 +1,86 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/wire"
+)
+
+// TestSeedFromFile ensures peers are read from a seed file, with the default
+// port used when the port is omitted, and that invalid files are rejected.
+func TestSeedFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seedfile")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "peers.txt")
+	contents := "# bootstrap peers\n\n1.2.3.4\n5.6.7.8:9333\n[::1]:18333\n" +
+		"unresolvable.example.com\n"
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	resolve := func(host string, port uint16) (*wire.NetAddress, error) {
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return nil, &net.DNSError{Err: "no such host", Name: host}
+		}
+		return wire.NewNetAddressIPPort(ip, port, wire.SFNodeNetwork), nil
+	}
+	seeded := make(chan []*wire.NetAddress, 1)
+	err = SeedFromFile(path, "8333", resolve, func(addrs []*wire.NetAddress) {
+		seeded <- addrs
+	})
+	if err != nil {
+		t.Fatalf("SeedFromFile: unexpected error: %v", err)
+	}
+
+	var addrs []*wire.NetAddress
+	select {
+	case addrs = <-seeded:
+	case <-time.After(time.Second):
+		t.Fatalf("SeedFromFile: no addresses seeded")
+	}
+	want := []string{"1.2.3.4:8333", "5.6.7.8:9333", "[::1]:18333"}
+	if len(addrs) != len(want) {
+		t.Fatalf("SeedFromFile: got %d addresses, want %d", len(addrs),
+			len(want))
+	}
+	for i, na := range addrs {
+		got := net.JoinHostPort(na.IP.String(), strconv.Itoa(int(na.Port)))
+		if got != want[i] {
+			t.Errorf("SeedFromFile: address %d is %s, want %s", i, got,
+				want[i])
+		}
+		if age := time.Since(na.Timestamp); age < 3*24*time.Hour ||
+			age > 7*24*time.Hour {
+
+			t.Errorf("SeedFromFile: address %d last seen %v ago", i, age)
+		}
+	}
+
+	if err := ioutil.WriteFile(path, []byte("1.2.3.4:port\n"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	err = SeedFromFile(path, "8333", resolve, func([]*wire.NetAddress) {})
+	if err == nil {
+		t.Fatalf("SeedFromFile: unexpected success for invalid port")
+	}
+	err = SeedFromFile(filepath.Join(dir, "missing"), "8333", resolve,
+		func([]*wire.NetAddress) {})
+	if err == nil {
+		t.Fatalf("SeedFromFile: unexpected success for missing file")
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// torControlOK is the status code of successful Tor control replies.
+	torControlOK = 250
+
+	// torCookieSize is the size of the Tor control authentication cookie.
+	torCookieSize = 32
+
+	// torServerHashKey and torClientHashKey are the HMAC keys used by the
+	// SAFECOOKIE authentication method.
+	torServerHashKey = "Tor safe cookie authentication server-to-controller hash"
+	torClientHashKey = "Tor safe cookie authentication controller-to-server hash"
+)
+
+var (
+	// ErrTorNoAuthMethod indicates the Tor control port does not offer an
+	// authentication method the controller can use.
+	ErrTorNoAuthMethod = errors.New("no supported tor control " +
+		"authentication method")
+
+	// ErrTorInvalidControlReply indicates the Tor control port replied in
+	// an unexpected format.
+	ErrTorInvalidControlReply = errors.New("invalid tor control reply")
+)
+
+// TorController is a client for the Tor control protocol.  It is used to
+// create onion services which forward inbound connections from the Tor
+// network to a local listener.
+//
+// Ephemeral onion services only exist as long as the control connection which
+// created them, so the controller must be kept open for as long as the
+// service is needed.
+type TorController struct {
+	conn *textproto.Conn
+}
+
+// DialTorController connects to the Tor control port at addr.
+func DialTorController(addr string, timeout time.Duration) (*TorController, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return NewTorController(conn), nil
+}
+
+// NewTorController returns a controller which speaks the Tor control protocol
+// over conn.
+func NewTorController(conn net.Conn) *TorController {
+	return &TorController{conn: textproto.NewConn(conn)}
+}
+
+// Close closes the control connection.  Tor removes any ephemeral onion
+// services created through the connection.
+func (c *TorController) Close() error {
+	return c.conn.Close()
+}
+
+// command sends a command to the control port and returns the lines of the
+// successful reply.
+func (c *TorController) command(format string, args ...interface{}) ([]string, error) {
+	id, err := c.conn.Cmd(format, args...)
+	if err != nil {
+		return nil, err
+	}
+	c.conn.StartResponse(id)
+	defer c.conn.EndResponse(id)
+
+	_, msg, err := c.conn.ReadResponse(torControlOK)
+	if err != nil {
+		return nil, fmt.Errorf("tor control: %v", err)
+	}
+	return strings.Split(msg, "\n"), nil
+}
+
+// Authenticate authenticates the control connection.  The password is used
+// when it is set and the control port accepts passwords.  Otherwise cookie
+// authentication is used, preferring SAFECOOKIE over COOKIE, or no
+// authentication at all if the control port does not require it.
+func (c *TorController) Authenticate(password string) error {
+	lines, err := c.command("PROTOCOLINFO 1")
+	if err != nil {
+		return err
+	}
+
+	methods := make(map[string]bool)
+	var cookieFile string
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "AUTH ") {
+			continue
+		}
+		fields := parseTorReplyFields(line[len("AUTH "):])
+		for _, method := range strings.Split(fields["METHODS"], ",") {
+			methods[method] = true
+		}
+		cookieFile = fields["COOKIEFILE"]
+	}
+
+	switch {
+	case password != "" && methods["HASHEDPASSWORD"]:
+		_, err = c.command("AUTHENTICATE %s", strconv.Quote(password))
+		return err
+
+	case methods["NULL"]:
+		_, err = c.command("AUTHENTICATE")
+		return err
+
+	case cookieFile != "" && methods["SAFECOOKIE"]:
+		cookie, err := readTorCookie(cookieFile)
+		if err != nil {
+			return err
+		}
+		return c.authenticateSafeCookie(cookie)
+
+	case cookieFile != "" && methods["COOKIE"]:
+		cookie, err := readTorCookie(cookieFile)
+		if err != nil {
+			return err
+		}
+		_, err = c.command("AUTHENTICATE %s", hex.EncodeToString(cookie))
+		return err
+	}
+
+	return ErrTorNoAuthMethod
+}
+
+// authenticateSafeCookie authenticates using the SAFECOOKIE method which,
+// unlike COOKIE, proves the control port also knows the cookie before the
+// cookie is revealed to it.
+func (c *TorController) authenticateSafeCookie(cookie []byte) error {
+	clientNonce := make([]byte, 32)
+	if _, err := rand.Read(clientNonce); err != nil {
+		return err
+	}
+	lines, err := c.command("AUTHCHALLENGE SAFECOOKIE %s",
+		hex.EncodeToString(clientNonce))
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(lines[0], "AUTHCHALLENGE ") {
+		return ErrTorInvalidControlReply
+	}
+	fields := parseTorReplyFields(lines[0][len("AUTHCHALLENGE "):])
+	serverHash, err := hex.DecodeString(fields["SERVERHASH"])
+	if err != nil {
+		return ErrTorInvalidControlReply
+	}
+	serverNonce, err := hex.DecodeString(fields["SERVERNONCE"])
+	if err != nil {
+		return ErrTorInvalidControlReply
+	}
+
+	msg := make([]byte, 0, len(cookie)+len(clientNonce)+len(serverNonce))
+	msg = append(msg, cookie...)
+	msg = append(msg, clientNonce...)
+	msg = append(msg, serverNonce...)
+	if !hmac.Equal(serverHash, torCookieHash(torServerHashKey, msg)) {
+		return errors.New("tor control port does not know the " +
+			"authentication cookie")
+	}
+
+	clientHash := torCookieHash(torClientHashKey, msg)
+	_, err = c.command("AUTHENTICATE %s", hex.EncodeToString(clientHash))
+	return err
+}
+
+// AddOnion creates an onion service which forwards connections to virtPort of
+// the service to target.  A new ed25519 key is created for the service when
+// privateKey is empty, otherwise privateKey must be a key returned by a
+// previous call.  The onion address of the service, including the .onion
+// suffix, is returned along with the private key of the service.
+func (c *TorController) AddOnion(privateKey string, virtPort uint16,
+	target string) (string, string, error) {
+
+	keyArg := privateKey
+	if keyArg == "" {
+		keyArg = "NEW:ED25519-V3"
+	}
+	lines, err := c.command("ADD_ONION %s Port=%d,%s", keyArg, virtPort,
+		target)
+	if err != nil {
+		return "", "", err
+	}
+
+	var serviceID string
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "ServiceID="):
+			serviceID = line[len("ServiceID="):]
+		case strings.HasPrefix(line, "PrivateKey="):
+			privateKey = line[len("PrivateKey="):]
+		}
+	}
+	if serviceID == "" || privateKey == "" {
+		return "", "", ErrTorInvalidControlReply
+	}
+	return serviceID + ".onion", privateKey, nil
+}
+
+// readTorCookie reads the Tor control authentication cookie from path.
+func readTorCookie(path string) ([]byte, error) {
+	cookie, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(cookie) != torCookieSize {
+		return nil, fmt.Errorf("tor cookie file %s is %d bytes, want %d",
+			path, len(cookie), torCookieSize)
+	}
+	return cookie, nil
+}
+
+// torCookieHash returns the HMAC-SHA256 of msg with the passed key.
+func torCookieHash(key string, msg []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(msg)
+	return mac.Sum(nil)
+}
+
+// parseTorReplyFields parses the space separated KEY=VALUE pairs of a Tor
+// control reply line.  Values may be quoted strings.
+func parseTorReplyFields(line string) map[string]string {
+	fields := make(map[string]string)
+	for line != "" {
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			break
+		}
+		key := line[:eq]
+		line = line[eq+1:]
+
+		var value string
+		if strings.HasPrefix(line, "\"") {
+			end := 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				end = len(line) - 1
+			}
+			unquoted, err := strconv.Unquote(line[:end+1])
+			if err != nil {
+				unquoted = line[1:end]
+			}
+			value = unquoted
+			line = line[end+1:]
+		} else {
+			end := strings.IndexByte(line, ' ')
+			if end < 0 {
+				end = len(line)
+			}
+			value = line[:end]
+			line = line[end:]
+		}
+		fields[key] = value
+		line = strings.TrimLeft(line, " ")
+	}
+	return fields
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeTorControl serves the control protocol on conn by calling reply for
+// every command received and writing back the returned reply lines.
+func fakeTorControl(conn net.Conn, reply func(cmd string) []string) {
+	tc := textproto.NewConn(conn)
+	go func() {
+		defer tc.Close()
+		for {
+			cmd, err := tc.ReadLine()
+			if err != nil {
+				return
+			}
+			for _, line := range reply(cmd) {
+				if err := tc.PrintfLine("%s", line); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// TestTorControllerPassword ensures the controller authenticates with a
+// password and parses the reply of ADD_ONION.
+func TestTorControllerPassword(t *testing.T) {
+	client, server := net.Pipe()
+	var cmds []string
+	fakeTorControl(server, func(cmd string) []string {
+		cmds = append(cmds, cmd)
+		switch {
+		case cmd == "PROTOCOLINFO 1":
+			return []string{
+				"250-PROTOCOLINFO 1",
+				`250-AUTH METHODS=COOKIE,HASHEDPASSWORD COOKIEFILE="/nonexistent"`,
+				`250-VERSION Tor="0.4.1.6"`,
+				"250 OK",
+			}
+		case strings.HasPrefix(cmd, "AUTHENTICATE "):
+			if cmd != `AUTHENTICATE "pass\"word"` {
+				return []string{"515 Authentication failed"}
+			}
+			return []string{"250 OK"}
+		case strings.HasPrefix(cmd, "ADD_ONION "):
+			return []string{
+				"250-ServiceID=pg6mmjiyjmcrsslvykfwnntlaru7p5svn6y2ymmju6nubxndf4pscryd",
+				"250-PrivateKey=ED25519-V3:a2V5",
+				"250 OK",
+			}
+		}
+		return []string{"510 Unrecognized command"}
+	})
+
+	c := NewTorController(client)
+	defer c.Close()
+	if err := c.Authenticate(`pass"word`); err != nil {
+		t.Fatalf("Authenticate: unexpected error: %v", err)
+	}
+	host, key, err := c.AddOnion("", 8333, "127.0.0.1:8333")
+	if err != nil {
+		t.Fatalf("AddOnion: unexpected error: %v", err)
+	}
+	wantHost := "pg6mmjiyjmcrsslvykfwnntlaru7p5svn6y2ymmju6nubxndf4pscryd.onion"
+	if host != wantHost || key != "ED25519-V3:a2V5" {
+		t.Fatalf("AddOnion: got %s %s", host, key)
+	}
+	if want := "ADD_ONION NEW:ED25519-V3 Port=8333,127.0.0.1:8333"; cmds[2] != want {
+		t.Fatalf("AddOnion: sent %q, want %q", cmds[2], want)
+	}
+
+	// Reusing a key passes it as is and keeps it.
+	_, key, err = c.AddOnion("ED25519-V3:a2V5", 8333, "127.0.0.1:8333")
+	if err != nil || key != "ED25519-V3:a2V5" {
+		t.Fatalf("AddOnion: got key %s, error %v", key, err)
+	}
+	if want := "ADD_ONION ED25519-V3:a2V5 Port=8333,127.0.0.1:8333"; cmds[3] != want {
+		t.Fatalf("AddOnion: sent %q, want %q", cmds[3], want)
+	}
+
+	if err := c.Authenticate("wrong"); err == nil {
+		t.Fatalf("Authenticate: unexpected success with wrong password")
+	}
+}
+
+// TestTorControllerSafeCookie ensures the controller authenticates with the
+// SAFECOOKIE method and refuses control ports which do not know the cookie.
+func TestTorControllerSafeCookie(t *testing.T) {
+	dir, err := ioutil.TempDir("", "torcontrol")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cookie := bytes.Repeat([]byte{0x42}, torCookieSize)
+	cookieFile := filepath.Join(dir, "control_auth_cookie")
+	if err := ioutil.WriteFile(cookieFile, cookie, 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	serverNonce := bytes.Repeat([]byte{0x01}, 32)
+
+	for _, knowsCookie := range []bool{true, false} {
+		client, server := net.Pipe()
+		var authenticated bool
+		fakeTorControl(server, func(cmd string) []string {
+			switch {
+			case cmd == "PROTOCOLINFO 1":
+				return []string{
+					"250-PROTOCOLINFO 1",
+					fmt.Sprintf(`250-AUTH METHODS=COOKIE,SAFECOOKIE COOKIEFILE="%s"`,
+						cookieFile),
+					"250 OK",
+				}
+			case strings.HasPrefix(cmd, "AUTHCHALLENGE SAFECOOKIE "):
+				clientNonce, _ := hex.DecodeString(cmd[len("AUTHCHALLENGE SAFECOOKIE "):])
+				msg := append(append(append([]byte{}, cookie...),
+					clientNonce...), serverNonce...)
+				if !knowsCookie {
+					msg[0] ^= 0xff
+				}
+				return []string{fmt.Sprintf("250 AUTHCHALLENGE "+
+					"SERVERHASH=%x SERVERNONCE=%x",
+					torCookieHash(torServerHashKey, msg), serverNonce)}
+			case strings.HasPrefix(cmd, "AUTHENTICATE "):
+				authenticated = true
+				return []string{"250 OK"}
+			}
+			return []string{"510 Unrecognized command"}
+		})
+
+		c := NewTorController(client)
+		err := c.Authenticate("")
+		c.Close()
+		if knowsCookie && (err != nil || !authenticated) {
+			t.Errorf("Authenticate: unexpected error: %v", err)
+		}
+		if !knowsCookie && (err == nil || authenticated) {
+			t.Errorf("Authenticate: cookie revealed to control port " +
+				"which does not know it")
+		}
+	}
+}
+
+// TestParseTorReplyFields ensures KEY=VALUE pairs of control replies are
+// parsed including quoted values.
+func TestParseTorReplyFields(t *testing.T) {
+	got := parseTorReplyFields(`METHODS=COOKIE,SAFECOOKIE COOKIEFILE="/var/run/tor/a \"b\"" X=1`)
+	want := map[string]string{
+		"METHODS":    "COOKIE,SAFECOOKIE",
+		"COOKIEFILE": `/var/run/tor/a "b"`,
+		"X":          "1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseTorReplyFields: got %v, want %v", got, want)
+	}
+}
@@ -2,7 +2,6 @@ package cross
 
 import (
 	"bytes"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"math/big"
@@ -19,17 +18,25 @@ type ExpandedTxType uint8
 
 const (
 	// Entangle Transcation type
-	ExpandedTxEntangle_Doge = 0xF0
-	ExpandedTxEntangle_Ltc  = 0xF1
+	ExpandedTxEntangle_Doge = txscript.EntangleTypeDoge
+	ExpandedTxEntangle_Ltc  = txscript.EntangleTypeLtc
 )
 
+// String returns the name of the chain of the entangle transaction type as
+// used by the entangle RPCs.
+func (t ExpandedTxType) String() string {
+	switch t {
+	case ExpandedTxEntangle_Doge:
+		return "doge"
+	case ExpandedTxEntangle_Ltc:
+		return "ltc"
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(t))
+}
+
 var (
 	NoEntangle = errors.New("no entangle info in transcation")
 
-	infoFixed = map[ExpandedTxType]uint32{
-		ExpandedTxEntangle_Doge: 64,
-		ExpandedTxEntangle_Ltc:  64,
-	}
 	baseUnit = new(big.Int).Exp(big.NewInt(10), big.NewInt(9), nil)
 	dogeUnit = new(big.Int).Mul(big.NewInt(int64(12500000)), baseUnit)
 )
@@ -76,56 +83,36 @@ type EntangleTxInfo struct {
 }
 
 func (info *EntangleTxInfo) Serialize() []byte {
-	buf := new(bytes.Buffer)
-
-	buf.WriteByte(byte(info.ExTxType))
-	binary.Write(buf, binary.LittleEndian, info.Index)
-	binary.Write(buf, binary.LittleEndian, info.Height)
-	b1 := info.Amount.Bytes()
-	len := uint8(len(b1))
-	buf.WriteByte(byte(len))
-
-	buf.Write(b1)
-	buf.Write(info.ExtTxHash)
-	return buf.Bytes()
+	return info.toEntangleData().Bytes()
 }
 
 func (info *EntangleTxInfo) Parse(data []byte) error {
-	if len(data) <= 14 {
-		return errors.New("wrong lenght!")
-	}
-	//data = data[4:]
-	info.ExTxType = ExpandedTxType(data[0])
-	switch info.ExTxType {
-	case ExpandedTxEntangle_Doge, ExpandedTxEntangle_Ltc:
-		break
-	default:
-		return errors.New("Parse failed,not entangle tx")
-	}
-	buf := bytes.NewBuffer(data[1:])
-	binary.Read(buf, binary.LittleEndian, &info.Index)
-	binary.Read(buf, binary.LittleEndian, &info.Height)
-	l, _ := buf.ReadByte()
-	b0 := make([]byte, int(uint32(l)))
-	n, _ := buf.Read(b0)
-	if int(uint32(l)) != n {
-		panic("b0 not equal n")
+	d, err := txscript.ParseEntangleData(data)
+	if err != nil {
+		return err
 	}
-	amount := big.NewInt(0)
-	amount.SetBytes(b0)
-	info.Amount = amount
-	info.ExtTxHash = make([]byte, int(infoFixed[info.ExTxType]))
-	n2, _ := buf.Read(info.ExtTxHash)
+	info.ExTxType = ExpandedTxType(d.ExTxType)
+	info.Index = d.Index
+	info.Height = d.Height
+	info.Amount = d.Amount
+	info.ExtTxHash = d.ExtTxHash
+	return nil
+}
 
-	if len(info.ExtTxHash) != n2 {
-		panic("len(info.ExtTxHash) not equal n2")
-	}
+// Validate checks that the entangle info can be carried by an entangle
+// output the network will accept.
+func (info *EntangleTxInfo) Validate() error {
+	return info.toEntangleData().Validate()
+}
 
-	// if len(info.ExtTxHash) != int(infoFixed[info.ExTxType]) {
-	// 	e := fmt.Sprintf("lenght not match,[request:%v,exist:%v]", infoFixed[info.ExTxType], len(info.ExtTxHash))
-	// 	return errors.New(e)
-	// }
-	return nil
+func (info *EntangleTxInfo) toEntangleData() *txscript.EntangleData {
+	return &txscript.EntangleData{
+		ExTxType:  byte(info.ExTxType),
+		Index:     info.Index,
+		Height:    info.Height,
+		Amount:    info.Amount,
+		ExtTxHash: info.ExtTxHash,
+	}
 }
 
 type KeepedItem struct {
@@ -138,21 +125,24 @@ type KeepedAmount struct {
 }
 
 func (info *KeepedAmount) Serialize() []byte {
-	buf := new(bytes.Buffer)
+	return info.toKeepedAmountData().Bytes()
+}
 
-	buf.WriteByte(info.Count)
+func (info *KeepedAmount) toKeepedAmountData() *txscript.KeepedAmountData {
+	d := &txscript.KeepedAmountData{
+		Items: make([]txscript.KeepedAmountItem, 0, len(info.Items)),
+	}
 	for _, v := range info.Items {
-		buf.WriteByte(byte(v.ExTxType))
-		b1 := v.Amount.Bytes()
-		len := uint8(len(b1))
-		buf.WriteByte(byte(len))
-		buf.Write(b1)
+		d.Items = append(d.Items, txscript.KeepedAmountItem{
+			ExTxType: byte(v.ExTxType),
+			Amount:   v.Amount,
+		})
 	}
-	return buf.Bytes()
+	return d
 }
 
 func (info *KeepedAmount) Parse(data []byte) error {
-	if data == nil {
+	if len(data) == 0 {
 		return nil
 	}
 	info.Count = data[0]
@@ -193,7 +183,7 @@ func (info *KeepedAmount) GetValue(t ExpandedTxType) *big.Int {
 func MakeEntangleTx(params *chaincfg.Params, inputs []*wire.TxIn, feeRate, inAmount czzutil.Amount,
 	changeAddr czzutil.Address, info *EntangleTxInfo) (*wire.MsgTx, error) {
 	// make pay script info include txHash and height
-	scriptInfo, err := txscript.EntangleScript(info.Serialize())
+	scriptInfo, err := txscript.NewEntangleScript(info.toEntangleData())
 	if err != nil {
 		return nil, err
 	}
@@ -269,14 +259,24 @@ func IsEntangleTx(tx *wire.MsgTx) (map[uint32]*EntangleTxInfo, error) {
 	return nil, NoEntangle
 }
 
+// EntangleTxFromScript returns the entangle info carried by the passed
+// entangle script.  A payload with a truncated amount or foreign tx hash is an
+// error, which IsEntangleTx takes as the output not being an entangle output.
+// Such a payload used to panic the node instead, so no block in the chain
+// carries one.
 func EntangleTxFromScript(script []byte) (*EntangleTxInfo, error) {
-	data, err := txscript.GetEntangleInfoData(script)
+	d, err := txscript.ExtractEntangleData(script)
 	if err != nil {
 		return nil, err
 	}
-	info := &EntangleTxInfo{}
-	err = info.Parse(data)
-	return info, err
+	info := &EntangleTxInfo{
+		ExTxType:  ExpandedTxType(d.ExTxType),
+		Index:     d.Index,
+		Height:    d.Height,
+		Amount:    d.Amount,
+		ExtTxHash: d.ExtTxHash,
+	}
+	return info, nil
 }
 
 func GetMaxHeight(items map[uint32]*EntangleTxInfo) uint64 {
@@ -354,7 +354,7 @@ func MakeMergeCoinbaseTx(tx *wire.MsgTx, pool *PoolAddrItem, items []*EntangleIt
 
 	reserve1, reserve2 := pool.Amount[0].Int64()+tx.TxOut[1].Value, pool.Amount[1].Int64()
 	updateTxOutValue(tx.TxOut[2], reserve2)
-	if ok := EnoughAmount(reserve1, items, keepInfo); !ok {
+	if ok := EnoughAmount(reserve1, items, keepInfo); !ok && len(items) > 0 {
 		return errors.New("not enough amount to be entangle...")
 	}
 
@@ -432,16 +432,21 @@ func keepEntangleAmount(info *KeepedAmount, tx *wire.MsgTx) error {
 	tx.TxOut[3] = txout
 	return nil
 }
+// KeepedAmountFromScript returns the entangle totals carried by the passed
+// keeped-amount script of a coinbase.  The next coinbase carries on from these
+// totals, so the script is parsed as leniently as the blocks in the chain were
+// by KeepedAmount.Parse, where a truncated item reads as zero, rather than
+// rejected like txscript.ExtractKeepedAmountData does.
 func KeepedAmountFromScript(script []byte) (*KeepedAmount, error) {
 	if script == nil {
 		return &KeepedAmount{Items: []KeepedItem{}}, nil
 	}
-	data, err1 := txscript.GetKeepedAmountData(script)
-	if err1 != nil {
-		return nil, err1
+	data, err := txscript.GetKeepedAmountData(script)
+	if err != nil {
+		return nil, err
 	}
 	keepInfo := &KeepedAmount{Items: []KeepedItem{}}
-	err := keepInfo.Parse(data)
+	err = keepInfo.Parse(data)
 	return keepInfo, err
 }
 
@@ -553,6 +558,28 @@ func toLtc(entangled, needed *big.Int) *big.Int {
 		return new(big.Int).Add(toCzz(f1), toCzz(f2))
 	}
 }
+// ConversionRate returns the number of coins of the chain of the passed
+// entangle type exchanged for one CZZ by the next entangle once the passed
+// amount was entangled from that chain.  The rate rises with every step of
+// entangled coins, as applied by the conversion of the entangled outputs.
+func ConversionRate(exTxType ExpandedTxType, entangled *big.Int) (float64, error) {
+	if entangled == nil {
+		entangled = big.NewInt(0)
+	}
+	switch exTxType {
+	case ExpandedTxEntangle_Doge:
+		steps := new(big.Int).Div(entangled, dogeUnit)
+		return 25 + float64(steps.Int64()), nil
+	case ExpandedTxEntangle_Ltc:
+		fixed := new(big.Int).Mul(big.NewInt(int64(1150)), baseUnit)
+		steps := new(big.Int).Div(entangled, fixed)
+		rate, _ := new(big.Float).Add(big.NewFloat(0.0008),
+			new(big.Float).Mul(big.NewFloat(0.0001),
+				big.NewFloat(float64(steps.Int64())))).Float64()
+		return rate, nil
+	}
+	return 0, fmt.Errorf("unknown entangle type %d", exTxType)
+}
 func toCzz(val *big.Float) *big.Int {
 	val = val.Mul(val, big.NewFloat(float64(baseUnit.Int64())))
 	ii, _ := val.Int64()
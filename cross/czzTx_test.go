@@ -191,3 +191,135 @@ func TestAddrTrans(t *testing.T) {
 	fmt.Println(addr.String())
 }
 
+// TestKeepedAmountFromTruncatedScript ensures keeped-amount scripts with
+// truncated items, which txscript.ExtractKeepedAmountData rejects, are parsed
+// the way the blocks in the chain were, with the missing bytes read as zero.
+func TestKeepedAmountFromTruncatedScript(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []byte
+		items []KeepedItem
+	}{{
+		name: "truncated amount",
+		data: []byte{1, ExpandedTxEntangle_Doge, 3, 0x01, 0x02},
+		items: []KeepedItem{
+			{ExpandedTxEntangle_Doge, big.NewInt(0x010200)},
+		},
+	}, {
+		name: "missing item",
+		data: []byte{2, ExpandedTxEntangle_Ltc, 1, 0x05},
+		items: []KeepedItem{
+			{ExpandedTxEntangle_Ltc, big.NewInt(5)},
+			{0, big.NewInt(0)},
+		},
+	}, {
+		name:  "missing amount length",
+		data:  []byte{1, ExpandedTxEntangle_Doge},
+		items: []KeepedItem{{ExpandedTxEntangle_Doge, big.NewInt(0)}},
+	}}
+	for _, test := range tests {
+		script, err := txscript.KeepedAmountScript(test.data)
+		if err != nil {
+			t.Fatalf("%s: KeepedAmountScript: unexpected error: %v",
+				test.name, err)
+		}
+		if _, err := txscript.ExtractKeepedAmountData(script); err == nil {
+			t.Errorf("%s: ExtractKeepedAmountData: unexpected success",
+				test.name)
+		}
+
+		keepInfo, err := KeepedAmountFromScript(script)
+		if err != nil {
+			t.Errorf("%s: KeepedAmountFromScript: unexpected error: %v",
+				test.name, err)
+			continue
+		}
+		if int(keepInfo.Count) != len(test.items) ||
+			len(keepInfo.Items) != len(test.items) {
+			t.Errorf("%s: got %d (count %d) items, want %d", test.name,
+				len(keepInfo.Items), keepInfo.Count, len(test.items))
+			continue
+		}
+		for i, item := range keepInfo.Items {
+			want := test.items[i]
+			if item.ExTxType != want.ExTxType ||
+				item.Amount.Cmp(want.Amount) != 0 {
+				t.Errorf("%s: item %d: got %v %v, want %v %v",
+					test.name, i, item.ExTxType, item.Amount,
+					want.ExTxType, want.Amount)
+			}
+		}
+	}
+}
+
+// TestEntangleTxFromTruncatedScript ensures entangle scripts with a truncated
+// payload, which used to panic the node, are not entangle outputs.
+func TestEntangleTxFromTruncatedScript(t *testing.T) {
+	info := &EntangleTxInfo{
+		ExTxType:  ExpandedTxEntangle_Doge,
+		Index:     1,
+		Height:    2972841,
+		Amount:    big.NewInt(225226803000),
+		ExtTxHash: make([]byte, txscript.EntangleExtTxHashSize),
+	}
+	data := info.Serialize()
+	for _, size := range []int{len(data) - 1, 16} {
+		script, err := txscript.EntangleScript(data[:size])
+		if err != nil {
+			t.Fatalf("EntangleScript: unexpected error: %v", err)
+		}
+		if _, err := EntangleTxFromScript(script); err == nil {
+			t.Errorf("EntangleTxFromScript: unexpected success for "+
+				"%d of %d bytes", size, len(data))
+		}
+
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxOut(wire.NewTxOut(0, script))
+		if _, err := IsEntangleTx(tx); err != NoEntangle {
+			t.Errorf("IsEntangleTx: got error %v for %d of %d "+
+				"bytes, want %v", err, size, len(data), NoEntangle)
+		}
+	}
+}
+
+// TestExpandedTxTypeString ensures the entangle types are named after their
+// chains and the unknown types after their number.
+func TestExpandedTxTypeString(t *testing.T) {
+	for exTxType, want := range map[ExpandedTxType]string{
+		ExpandedTxEntangle_Doge: "doge",
+		ExpandedTxEntangle_Ltc:  "ltc",
+		0:                       "unknown(0)",
+	} {
+		if got := exTxType.String(); got != want {
+			t.Errorf("String of %d: got %q, want %q", uint8(exTxType),
+				got, want)
+		}
+	}
+}
+
+func TestConversionRate(t *testing.T) {
+	tests := []struct {
+		exTxType  ExpandedTxType
+		entangled *big.Int
+		rate      float64
+	}{
+		{ExpandedTxEntangle_Doge, nil, 25},
+		{ExpandedTxEntangle_Doge, new(big.Int).Sub(dogeUnit, big.NewInt(1)), 25},
+		{ExpandedTxEntangle_Doge, new(big.Int).Mul(dogeUnit, big.NewInt(3)), 28},
+		{ExpandedTxEntangle_Ltc, big.NewInt(0), 0.0008},
+		{ExpandedTxEntangle_Ltc, new(big.Int).Mul(big.NewInt(2300), baseUnit), 0.001},
+	}
+	for i, test := range tests {
+		rate, err := ConversionRate(test.exTxType, test.entangled)
+		if err != nil {
+			t.Fatalf("test #%d: unexpected error: %v", i, err)
+		}
+		if diff := rate - test.rate; diff > 1e-12 || diff < -1e-12 {
+			t.Errorf("test #%d: got rate %v, want %v", i, rate, test.rate)
+		}
+	}
+
+	if _, err := ConversionRate(ExpandedTxType(0), nil); err == nil {
+		t.Errorf("ConversionRate accepted an unknown entangle type")
+	}
+}
@@ -0,0 +1,302 @@
+package cross
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/czzec"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+var (
+	ErrReserveBlockNotInChain = errors.New("the reserve attestation block is not in the best chain")
+	ErrReserveStale           = errors.New("a reserve attestation of a later block was already verified")
+)
+
+// reserveChain describes how the custodians of the coin pool of a chain sign
+// the reserve attestations and receive the reserve.
+type reserveChain struct {
+	name     string
+	params   *chaincfg.Params
+	magic    string
+	poolAddr string
+	maturity int64
+}
+
+// reserveChains are the chains the coins can be entangled from.  The script
+// hash address ids are the ones the entangle outputs are verified with, so the
+// pool addresses compare the same way.
+var reserveChains = map[ExpandedTxType]*reserveChain{
+	ExpandedTxEntangle_Doge: {
+		name: "doge",
+		params: &chaincfg.Params{
+			LegacyPubKeyHashAddrID: 0x1e,
+			LegacyScriptHashAddrID: 0x1e,
+		},
+		magic:    "Dogecoin Signed Message:\n",
+		poolAddr: dogePoolAddr,
+		maturity: dogeMaturity,
+	},
+	ExpandedTxEntangle_Ltc: {
+		name: "ltc",
+		params: &chaincfg.Params{
+			LegacyPubKeyHashAddrID: 0x30,
+			LegacyScriptHashAddrID: 0x32,
+		},
+		magic:    "Litecoin Signed Message:\n",
+		poolAddr: ltcPoolAddr,
+		maturity: ltcMaturity,
+	},
+}
+
+// ReserveSignature is the signature of the reserve attestation message by the
+// custodian of a pay-to-pubkey-hash address of the chain the coins are
+// entangled from, as made by the signmessage command of its wallet.
+type ReserveSignature struct {
+	Address   string
+	Signature []byte
+}
+
+// ReserveAttestation is the claim of the custodians of the coin pool of a
+// chain that the listed outputs, unspent as of the referenced block of that
+// chain, back the coins entangled from it.  The outputs pay either the pool
+// address or one of the signed addresses.
+type ReserveAttestation struct {
+	ExTxType    ExpandedTxType
+	BlockHeight int64
+	BlockHash   chainhash.Hash
+	Signatures  []ReserveSignature
+	OutPoints   []wire.OutPoint
+}
+
+// ReserveProof is a reserve attestation verified against a node of the chain
+// the coins are entangled from.
+type ReserveProof struct {
+	*ReserveAttestation
+
+	// Reserve is the total value of the attested outputs in the units of
+	// the chain they are on.
+	Reserve int64
+
+	// Confirmations is the number of confirmations of the referenced block
+	// when the attestation was verified.
+	Confirmations int64
+
+	// Verified is when the attestation was verified.
+	Verified time.Time
+}
+
+// ReserveAttestationMessage returns the message the custodians sign to attest
+// the reserve as of the passed block of the chain the coins are entangled
+// from.
+func ReserveAttestationMessage(blockHash *chainhash.Hash) string {
+	return "classzz reserve attestation " + blockHash.String()
+}
+
+// reserveAddresses returns the addresses the signatures of the passed
+// attestation prove the custodians control, along with the pool address.
+func reserveAddresses(att *ReserveAttestation, chain *reserveChain) (map[string]struct{}, error) {
+	var buf bytes.Buffer
+	wire.WriteVarString(&buf, 0, chain.magic)
+	wire.WriteVarString(&buf, 0, ReserveAttestationMessage(&att.BlockHash))
+	messageHash := chainhash.DoubleHashB(buf.Bytes())
+
+	addrs := map[string]struct{}{chain.poolAddr: {}}
+	for _, sig := range att.Signatures {
+		pk, wasCompressed, err := czzec.RecoverCompact(czzec.S256(),
+			sig.Signature, messageHash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature for %s: %v",
+				sig.Address, err)
+		}
+		var serializedPK []byte
+		if wasCompressed {
+			serializedPK = pk.SerializeCompressed()
+		} else {
+			serializedPK = pk.SerializeUncompressed()
+		}
+		addr, err := czzutil.NewLegacyAddressPubKeyHash(
+			czzutil.Hash160(serializedPK), chain.params)
+		if err != nil {
+			return nil, err
+		}
+		if addr.String() != sig.Address {
+			return nil, fmt.Errorf("the signature for %s was made "+
+				"by %s", sig.Address, addr)
+		}
+		addrs[sig.Address] = struct{}{}
+	}
+	return addrs, nil
+}
+
+// reserveScriptAddress returns the address the passed output script of the
+// passed chain pays.
+func reserveScriptAddress(pkScript []byte, chain *reserveChain) (string, error) {
+	class, hash, err := txscript.ExtractPkScriptPub(pkScript)
+	if err != nil {
+		return "", err
+	}
+	var addr czzutil.Address
+	if class == txscript.PubKeyHashTy {
+		addr, err = czzutil.NewLegacyAddressPubKeyHash(hash, chain.params)
+	} else {
+		addr, err = czzutil.NewLegacyAddressScriptHashFromHash(hash,
+			chain.params)
+	}
+	if err != nil {
+		return "", err
+	}
+	return addr.String(), nil
+}
+
+// VerifyReserveAttestation verifies the passed attestation against a node of
+// the chain the coins are entangled from.  The referenced block must be in its
+// best chain and mature, and the attested outputs must be unspent and pay the
+// pool address or a signed address.
+//
+// This function is safe for concurrent access.
+func (ev *EntangleVerify) VerifyReserveAttestation(att *ReserveAttestation) (*ReserveProof, error) {
+	chain, ok := reserveChains[att.ExTxType]
+	if !ok {
+		return nil, fmt.Errorf("unknown entangle type %d", att.ExTxType)
+	}
+	if len(att.OutPoints) == 0 {
+		return nil, errors.New("the reserve attestation has no outputs")
+	}
+	addrs, err := reserveAddresses(att, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	ev.clientsMtx.RLock()
+	defer ev.clientsMtx.RUnlock()
+
+	clients := ev.DogeCoinRPC
+	if att.ExTxType == ExpandedTxEntangle_Ltc {
+		clients = ev.LtcCoinRPC
+	}
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("no %s node to verify the reserve "+
+			"attestation against", chain.name)
+	}
+	client := clients[rand.Intn(len(clients))]
+
+	blockHash, err := client.GetBlockHash(att.BlockHeight)
+	if err != nil {
+		return nil, err
+	}
+	if *blockHash != att.BlockHash {
+		return nil, ErrReserveBlockNotInChain
+	}
+	count, err := client.GetBlockCount()
+	if err != nil {
+		return nil, err
+	}
+	confirmations := count - att.BlockHeight + 1
+	if count-att.BlockHeight <= chain.maturity {
+		return nil, fmt.Errorf("the reserve attestation block has %d "+
+			"confirmations, %d are required", confirmations,
+			chain.maturity+2)
+	}
+
+	var reserve int64
+	seen := make(map[wire.OutPoint]struct{}, len(att.OutPoints))
+	for i := range att.OutPoints {
+		op := &att.OutPoints[i]
+		if _, ok := seen[*op]; ok {
+			return nil, fmt.Errorf("output %v is attested twice", op)
+		}
+		seen[*op] = struct{}{}
+
+		txOut, err := client.GetTxOut(&op.Hash, op.Index, false)
+		if err != nil {
+			return nil, err
+		}
+		if txOut == nil {
+			return nil, fmt.Errorf("output %v is spent", op)
+		}
+		pkScript, err := hex.DecodeString(txOut.ScriptPubKey.Hex)
+		if err != nil {
+			return nil, err
+		}
+		addr, err := reserveScriptAddress(pkScript, chain)
+		if err != nil {
+			return nil, fmt.Errorf("output %v: %v", op, err)
+		}
+		if _, ok := addrs[addr]; !ok {
+			return nil, fmt.Errorf("output %v pays %s which is not "+
+				"attested", op, addr)
+		}
+		value, err := czzutil.NewAmount(txOut.Value)
+		if err != nil {
+			return nil, err
+		}
+		reserve += int64(value)
+	}
+
+	return &ReserveProof{
+		ReserveAttestation: att,
+		Reserve:            reserve,
+		Confirmations:      confirmations,
+		Verified:           time.Now(),
+	}, nil
+}
+
+// SubmitReserveAttestation verifies the passed attestation and makes it the
+// latest reserve proof of its chain unless one of a later block was verified.
+//
+// This function is safe for concurrent access.
+func (ev *EntangleVerify) SubmitReserveAttestation(att *ReserveAttestation) (*ReserveProof, error) {
+	ev.reservesMtx.Lock()
+	latest := ev.reserves[att.ExTxType]
+	ev.reservesMtx.Unlock()
+	if latest != nil && latest.BlockHeight > att.BlockHeight {
+		return nil, ErrReserveStale
+	}
+
+	proof, err := ev.VerifyReserveAttestation(att)
+	if err != nil {
+		return nil, err
+	}
+
+	ev.reservesMtx.Lock()
+	defer ev.reservesMtx.Unlock()
+
+	if latest := ev.reserves[att.ExTxType]; latest != nil &&
+		latest.BlockHeight > att.BlockHeight {
+
+		return nil, ErrReserveStale
+	}
+	if ev.reserves == nil {
+		ev.reserves = make(map[ExpandedTxType]*ReserveProof)
+	}
+	ev.reserves[att.ExTxType] = proof
+	return proof, nil
+}
+
+// ReserveProofs returns the latest reserve proof of every chain one was
+// submitted for, in entangle type order.
+//
+// This function is safe for concurrent access.
+func (ev *EntangleVerify) ReserveProofs() []*ReserveProof {
+	ev.reservesMtx.Lock()
+	defer ev.reservesMtx.Unlock()
+
+	proofs := make([]*ReserveProof, 0, len(ev.reserves))
+	for _, proof := range ev.reserves {
+		proofs = append(proofs, proof)
+	}
+	sort.Slice(proofs, func(i, j int) bool {
+		return proofs[i].ExTxType < proofs[j].ExTxType
+	})
+	return proofs
+}
@@ -0,0 +1,141 @@
+package cross
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/czzec"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+	"github.com/bourbaki-czz/czzutil/base58"
+)
+
+// signReserve returns the address of a new key of the passed chain along with
+// its signature of the attestation message for the passed block, made the
+// way the signmessage command of the wallets of the chain does.
+func signReserve(t *testing.T, chain *reserveChain, blockHash *chainhash.Hash) ReserveSignature {
+	key, err := czzec.NewPrivateKey(czzec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	addr, err := czzutil.NewLegacyAddressPubKeyHash(
+		czzutil.Hash160(key.PubKey().SerializeCompressed()), chain.params)
+	if err != nil {
+		t.Fatalf("NewLegacyAddressPubKeyHash: unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	wire.WriteVarString(&buf, 0, chain.magic)
+	wire.WriteVarString(&buf, 0, ReserveAttestationMessage(blockHash))
+	sig, err := czzec.SignCompact(czzec.S256(), key,
+		chainhash.DoubleHashB(buf.Bytes()), true)
+	if err != nil {
+		t.Fatalf("SignCompact: unexpected error: %v", err)
+	}
+	return ReserveSignature{Address: addr.String(), Signature: sig}
+}
+
+// TestReserveAddresses ensures the addresses of a reserve attestation are the
+// pool address and the ones whose custodians signed the attested block.
+func TestReserveAddresses(t *testing.T) {
+	chain := reserveChains[ExpandedTxEntangle_Ltc]
+	blockHash := chainhash.Hash{0x01}
+	sig := signReserve(t, chain, &blockHash)
+	if sig.Address[0] != 'L' {
+		t.Fatalf("unexpected ltc address %s", sig.Address)
+	}
+
+	att := &ReserveAttestation{
+		ExTxType:   ExpandedTxEntangle_Ltc,
+		BlockHash:  blockHash,
+		Signatures: []ReserveSignature{sig},
+	}
+	addrs, err := reserveAddresses(att, chain)
+	if err != nil {
+		t.Fatalf("reserveAddresses: unexpected error: %v", err)
+	}
+	for _, addr := range []string{ltcPoolAddr, sig.Address} {
+		if _, ok := addrs[addr]; !ok {
+			t.Errorf("address %s is missing", addr)
+		}
+	}
+	if len(addrs) != 2 {
+		t.Errorf("got %d addresses, want 2", len(addrs))
+	}
+
+	// A signature of another block, another chain or another address
+	// does not attest the address.
+	att.BlockHash = chainhash.Hash{0x02}
+	if _, err := reserveAddresses(att, chain); err == nil {
+		t.Errorf("reserveAddresses: signature of another block accepted")
+	}
+	att.BlockHash = blockHash
+	if _, err := reserveAddresses(att, reserveChains[ExpandedTxEntangle_Doge]); err == nil {
+		t.Errorf("reserveAddresses: signature of another chain accepted")
+	}
+	other := signReserve(t, chain, &blockHash)
+	att.Signatures[0].Address = other.Address
+	if _, err := reserveAddresses(att, chain); err == nil {
+		t.Errorf("reserveAddresses: signature of another address accepted")
+	}
+}
+
+// TestReserveScriptAddress ensures the outputs of the entangled chains are
+// matched to the addresses they pay.
+func TestReserveScriptAddress(t *testing.T) {
+	chain := reserveChains[ExpandedTxEntangle_Doge]
+	poolHash, _, err := base58.CheckDecode(dogePoolAddr)
+	if err != nil {
+		t.Fatalf("CheckDecode: unexpected error: %v", err)
+	}
+	poolScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_HASH160).
+		AddData(poolHash).AddOp(txscript.OP_EQUAL).Script()
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+	addr, err := reserveScriptAddress(poolScript, chain)
+	if err != nil || addr != dogePoolAddr {
+		t.Errorf("reserveScriptAddress: got %s (%v), want %s", addr, err,
+			dogePoolAddr)
+	}
+
+	if _, err := reserveScriptAddress([]byte{txscript.OP_TRUE}, chain); err == nil {
+		t.Errorf("reserveScriptAddress: non-standard script accepted")
+	}
+}
+
+// TestSubmitReserveAttestation ensures the reserve attestations are refused
+// before the nodes of their chains are queried when they cannot be verified.
+func TestSubmitReserveAttestation(t *testing.T) {
+	ev := &EntangleVerify{}
+	blockHash := chainhash.Hash{0x01}
+	tests := []struct {
+		name string
+		att  *ReserveAttestation
+	}{
+		{"unknown chain", &ReserveAttestation{
+			ExTxType:  0xff,
+			OutPoints: []wire.OutPoint{{Index: 1}},
+		}},
+		{"no outputs", &ReserveAttestation{
+			ExTxType: ExpandedTxEntangle_Doge,
+		}},
+		{"no nodes", &ReserveAttestation{
+			ExTxType:  ExpandedTxEntangle_Doge,
+			BlockHash: blockHash,
+			Signatures: []ReserveSignature{signReserve(t,
+				reserveChains[ExpandedTxEntangle_Doge], &blockHash)},
+			OutPoints: []wire.OutPoint{{Index: 1}},
+		}},
+	}
+	for _, test := range tests {
+		if _, err := ev.SubmitReserveAttestation(test.att); err == nil {
+			t.Errorf("%s: attestation accepted", test.name)
+		}
+	}
+	if proofs := ev.ReserveProofs(); len(proofs) != 0 {
+		t.Errorf("got %d reserve proofs, want none", len(proofs))
+	}
+}
@@ -3,11 +3,14 @@ package cross
 import (
 	"errors"
 	"fmt"
+	"github.com/bourbaki-czz/classzz/btcjson"
 	"github.com/bourbaki-czz/classzz/chaincfg"
 	"github.com/bourbaki-czz/classzz/txscript"
 	"github.com/bourbaki-czz/czzutil"
 	"math/big"
 	"math/rand"
+	"sync"
+	"time"
 
 	"github.com/bourbaki-czz/classzz/rpcclient"
 	"github.com/bourbaki-czz/classzz/wire"
@@ -15,8 +18,47 @@ import (
 
 var (
 	ErrHeightTooClose = errors.New("the block heigth to close for entangling")
+
+	// ErrNoClients indicates that there is no node of the chain the coins
+	// were entangled from to verify the entangled outputs against.
+	ErrNoClients = errors.New("no node to verify the entangled outputs against")
 )
 
+// ClientError is an error, such as a failed connection, which means that a node
+// of a chain the coins are entangled from did not answer whether an entangled
+// output verifies.  Unlike the other verification errors it does not mean
+// the output is invalid, it may verify once the node answers.
+type ClientError struct {
+	// ExTxType is the entangle type of the chain of the node.
+	ExTxType ExpandedTxType
+
+	// Err is the error of the client of the node.
+	Err error
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *ClientError) Error() string {
+	return fmt.Sprintf("entangle type %d node: %v", e.ExTxType, e.Err)
+}
+
+// IsClientError returns whether the passed verification error only means a
+// node could not answer whether the entangled outputs verify.
+func IsClientError(err error) bool {
+	_, ok := err.(*ClientError)
+	return ok
+}
+
+// clientError returns the passed error of the client of a node of the chain of
+// the passed entangle type as a ClientError unless the node answered that the
+// transaction the output was entangled from does not exist, which means the
+// output does not verify.
+func clientError(exTxType ExpandedTxType, err error) error {
+	if jerr, ok := err.(*btcjson.RPCError); ok && jerr.Code == btcjson.ErrRPCNoTxInfo {
+		return err
+	}
+	return &ClientError{ExTxType: exTxType, Err: err}
+}
+
 const (
 	dogePoolAddr = "DNGzkoZbnVMihLTMq8M1m7L62XvN3d2cN2"
 	ltcPoolAddr  = "MUy9qiaLQtaqmKBSk27FXrEEfUkRBeddCZ"
@@ -28,6 +70,137 @@ type EntangleVerify struct {
 	DogeCoinRPC []*rpcclient.Client
 	LtcCoinRPC  []*rpcclient.Client
 	Cache       *CacheEntangleInfo
+
+	// clientsMtx protects the clients, which are replaced by SetClients,
+	// while verifications use them.
+	clientsMtx sync.RWMutex
+
+	statsMtx sync.Mutex
+	stats    map[ExpandedTxType]*VerifyStats
+
+	// reserves are the latest reserve proofs by chain.
+	reservesMtx sync.Mutex
+	reserves    map[ExpandedTxType]*ReserveProof
+}
+
+// VerifyStats are the statistics of the verifications of entangled outputs
+// against the nodes of the chain the coins were entangled from.
+type VerifyStats struct {
+	// Verified is the number of outputs which were verified.
+	Verified uint64
+
+	// Failed is the number of outputs which failed to verify.
+	Failed uint64
+
+	// Duration is the total time spent verifying the outputs.
+	Duration time.Duration
+}
+
+// ClientStatus is the state of a client of a node the entangled outputs are
+// verified against.
+type ClientStatus struct {
+	// ExTxType is the entangle type of the chain of the node.
+	ExTxType ExpandedTxType
+
+	// Height is the height of the best block of the node.
+	Height int64
+
+	// Latency is the time the node took to answer.
+	Latency time.Duration
+
+	// Err is the error the node answered with, if any.
+	Err error
+}
+
+// ClientStatuses queries the best block of every node the entangled outputs
+// are verified against and returns the states of their clients, the doge ones
+// first.
+//
+// This function is safe for concurrent access.
+func (ev *EntangleVerify) ClientStatuses() []ClientStatus {
+	ev.clientsMtx.RLock()
+	defer ev.clientsMtx.RUnlock()
+
+	statuses := make([]ClientStatus, len(ev.DogeCoinRPC)+len(ev.LtcCoinRPC))
+	var wg sync.WaitGroup
+	query := func(i int, exTxType ExpandedTxType, client *rpcclient.Client) {
+		defer wg.Done()
+		start := time.Now()
+		height, err := client.GetBlockCount()
+		statuses[i] = ClientStatus{
+			ExTxType: exTxType,
+			Height:   height,
+			Latency:  time.Since(start),
+			Err:      err,
+		}
+	}
+	for i, client := range ev.DogeCoinRPC {
+		wg.Add(1)
+		go query(i, ExpandedTxEntangle_Doge, client)
+	}
+	for i, client := range ev.LtcCoinRPC {
+		wg.Add(1)
+		go query(len(ev.DogeCoinRPC)+i, ExpandedTxEntangle_Ltc, client)
+	}
+	wg.Wait()
+	return statuses
+}
+
+// SetClients replaces the clients of the nodes the entangled outputs are
+// verified against once the verifications in progress are done and shuts the
+// replaced clients down.
+//
+// This function is safe for concurrent access.
+func (ev *EntangleVerify) SetClients(dogeClients, ltcClients []*rpcclient.Client) {
+	ev.clientsMtx.Lock()
+	oldDogeClients, oldLtcClients := ev.DogeCoinRPC, ev.LtcCoinRPC
+	ev.DogeCoinRPC = dogeClients
+	ev.LtcCoinRPC = ltcClients
+	ev.clientsMtx.Unlock()
+
+	for _, client := range oldDogeClients {
+		client.Shutdown()
+	}
+	for _, client := range oldLtcClients {
+		client.Shutdown()
+	}
+}
+
+// Stats returns the statistics of the verifications of the entangled outputs
+// by the chain the coins were entangled from.
+//
+// This function is safe for concurrent access.
+func (ev *EntangleVerify) Stats() map[ExpandedTxType]VerifyStats {
+	ev.statsMtx.Lock()
+	defer ev.statsMtx.Unlock()
+
+	stats := make(map[ExpandedTxType]VerifyStats, len(ev.stats))
+	for exTxType, s := range ev.stats {
+		stats[exTxType] = *s
+	}
+	return stats
+}
+
+// recordVerify adds a verification of an entangled output of the passed type
+// which took the passed time to the statistics.
+func (ev *EntangleVerify) recordVerify(exTxType ExpandedTxType, elapsed time.Duration, err error) {
+	ev.statsMtx.Lock()
+	defer ev.statsMtx.Unlock()
+
+	if ev.stats == nil {
+		ev.stats = make(map[ExpandedTxType]*VerifyStats)
+	}
+	s, ok := ev.stats[exTxType]
+	if !ok {
+		s = &VerifyStats{}
+		ev.stats[exTxType] = s
+	}
+	if err != nil {
+		s.Failed++
+	} else {
+		s.Verified++
+	}
+	s.Duration += elapsed
 }
 
 func (ev *EntangleVerify) VerifyEntangleTx(tx *wire.MsgTx) ([]*TuplePubIndex, error) {
@@ -54,7 +227,13 @@ func (ev *EntangleVerify) VerifyEntangleTx(tx *wire.MsgTx) ([]*TuplePubIndex, er
 	}
 
 	for i, v := range einfos {
-		if pub, err := ev.verifyTx(v.ExTxType, v.ExtTxHash, v.Index, v.Height, v.Amount); err != nil {
+		start := time.Now()
+		pub, err := ev.verifyTx(v.ExTxType, v.ExtTxHash, v.Index, v.Height, v.Amount)
+		ev.recordVerify(v.ExTxType, time.Since(start), err)
+		if IsClientError(err) {
+			return nil, err
+		}
+		if err != nil {
 			errStr := fmt.Sprintf("[txid:%v, height:%v]", v.ExtTxHash, v.Index)
 			return nil, errors.New("txid verify failed:" + errStr + " err:" + err.Error())
 		} else {
@@ -77,6 +256,9 @@ func (ev *EntangleVerify) VerifyEntangleTx(tx *wire.MsgTx) ([]*TuplePubIndex, er
 
 func (ev *EntangleVerify) verifyTx(ExTxType ExpandedTxType, ExtTxHash []byte, Vout uint32,
 	height uint64, amount *big.Int) ([]byte, error) {
+	ev.clientsMtx.RLock()
+	defer ev.clientsMtx.RUnlock()
+
 	switch ExTxType {
 	case ExpandedTxEntangle_Doge:
 		return ev.verifyDogeTx(ExtTxHash, Vout, amount, height)
@@ -88,13 +270,17 @@ func (ev *EntangleVerify) verifyTx(ExTxType ExpandedTxType, ExtTxHash []byte, Vo
 
 func (ev *EntangleVerify) verifyDogeTx(ExtTxHash []byte, Vout uint32, Amount *big.Int, height uint64) ([]byte, error) {
 
+	if len(ev.DogeCoinRPC) == 0 {
+		return nil, &ClientError{ExTxType: ExpandedTxEntangle_Doge, Err: ErrNoClients}
+	}
+
 	// Notice the notification parameter is nil since notifications are
 	// not supported in HTTP POST mode.
 	client := ev.DogeCoinRPC[rand.Intn(len(ev.DogeCoinRPC))]
 
 	// Get the current block count.
 	if tx, err := client.GetRawTransaction(string(ExtTxHash)); err != nil {
-		return nil, err
+		return nil, clientError(ExpandedTxEntangle_Doge, err)
 	} else {
 		if len(tx.MsgTx().TxOut) < int(Vout) {
 			return nil, errors.New("doge TxOut index err")
@@ -117,7 +303,9 @@ func (ev *EntangleVerify) verifyDogeTx(ExtTxHash []byte, Vout uint32, Amount *bi
 			return nil, err
 		}
 
-		addr, err := czzutil.NewLegacyAddressScriptHash(pub, dogeparams)
+		// The pool address encodes the hash paid to, which must not be
+		// hashed again.
+		addr, err := czzutil.NewLegacyAddressScriptHashFromHash(pub, dogeparams)
 		if err != nil {
 			e := fmt.Sprintf("doge Pool err")
 			return nil, errors.New(e)
@@ -135,7 +323,7 @@ func (ev *EntangleVerify) verifyDogeTx(ExtTxHash []byte, Vout uint32, Amount *bi
 		} else {
 
 			if count, err := client.GetBlockCount(); err != nil {
-				return nil, err
+				return nil, &ClientError{ExTxType: ExpandedTxEntangle_Doge, Err: err}
 			} else {
 				fmt.Println("pk.Script()", pk)
 				if count-int64(height) > dogeMaturity {
@@ -152,13 +340,17 @@ func (ev *EntangleVerify) verifyDogeTx(ExtTxHash []byte, Vout uint32, Amount *bi
 
 func (ev *EntangleVerify) verifyLtcTx(ExtTxHash []byte, Vout uint32, Amount *big.Int, height uint64) ([]byte, error) {
 
+	if len(ev.LtcCoinRPC) == 0 {
+		return nil, &ClientError{ExTxType: ExpandedTxEntangle_Ltc, Err: ErrNoClients}
+	}
+
 	// Notice the notification parameter is nil since notifications are
 	// not supported in HTTP POST mode.
 	client := ev.LtcCoinRPC[rand.Intn(len(ev.LtcCoinRPC))]
 
 	// Get the current block count.
 	if tx, err := client.GetRawTransaction(string(ExtTxHash)); err != nil {
-		return nil, err
+		return nil, clientError(ExpandedTxEntangle_Ltc, err)
 	} else {
 		if len(tx.MsgTx().TxOut) < int(Vout) {
 			return nil, errors.New("ltc TxOut index err")
@@ -180,7 +372,7 @@ func (ev *EntangleVerify) verifyLtcTx(ExtTxHash []byte, Vout uint32, Amount *big
 		ltcparams := &chaincfg.Params{
 			LegacyScriptHashAddrID: 0x32,
 		}
-		addr, err := czzutil.NewLegacyAddressScriptHash(pub, ltcparams)
+		addr, err := czzutil.NewLegacyAddressScriptHashFromHash(pub, ltcparams)
 		if err != nil {
 			e := fmt.Sprintf("ltcaddr err")
 			return nil, errors.New(e)
@@ -197,7 +389,7 @@ func (ev *EntangleVerify) verifyLtcTx(ExtTxHash []byte, Vout uint32, Amount *big
 			return nil, errors.New(e)
 		} else {
 			if count, err := client.GetBlockCount(); err != nil {
-				return nil, err
+				return nil, &ClientError{ExTxType: ExpandedTxEntangle_Ltc, Err: err}
 			} else {
 				if count-int64(height) > ltcMaturity {
 					return pk, nil
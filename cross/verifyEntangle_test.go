@@ -1,6 +1,7 @@
 package cross
 
 import (
+	"errors"
 	"fmt"
 	"github.com/bourbaki-czz/classzz/btcjson"
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
@@ -85,10 +86,52 @@ func TestVerifyTx(t *testing.T) {
 		PkScript: scriptInfo,
 	}
 	tx.AddTxOut(txout)
-	err, puk := entangleVerify.VerifyEntangleTx(tx)
+	puk, err := entangleVerify.VerifyEntangleTx(tx)
+	if IsClientError(err) {
+		t.Skipf("dogecoin node at %s not available: %v", dogecoinrpc, err)
+	}
 	if err != nil {
-		t.Error("err", err)
+		t.Fatal("err", err)
 	}
 
 	t.Log(puk[0].Pub)
 }
+
+// TestClientError ensures the errors which keep a node from answering are told
+// apart from the answers that an entangled output does not verify.
+func TestClientError(t *testing.T) {
+	rpcErr := btcjson.NewRPCError(btcjson.ErrRPCNoTxInfo,
+		"No information available about transaction")
+	if err := clientError(ExpandedTxEntangle_Doge, rpcErr); IsClientError(err) {
+		t.Errorf("unknown transaction: got client error %v", err)
+	}
+	downloading := btcjson.NewRPCError(btcjson.ErrRPCClientInInitialDownload,
+		"Bitcoin is downloading blocks...")
+	if err := clientError(ExpandedTxEntangle_Doge, downloading); !IsClientError(err) {
+		t.Errorf("downloading node: got %v, want a client error", err)
+	}
+	refused := errors.New("connection refused")
+	if err := clientError(ExpandedTxEntangle_Ltc, refused); !IsClientError(err) {
+		t.Errorf("failed connection: got %v, want a client error", err)
+	}
+
+	// An entangle can not be verified without nodes.
+	script, err := txscript.NewEntangleScript(&txscript.EntangleData{
+		ExTxType:  txscript.EntangleTypeLtc,
+		Height:    100,
+		Amount:    big.NewInt(1e8),
+		ExtTxHash: make([]byte, txscript.EntangleExtTxHashSize),
+	})
+	if err != nil {
+		t.Fatalf("NewEntangleScript: unexpected error: %v", err)
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(&wire.TxIn{Sequence: wire.MaxTxInSequenceNum})
+	tx.AddTxOut(&wire.TxOut{PkScript: script})
+	ev := &EntangleVerify{}
+	_, err = ev.VerifyEntangleTx(tx)
+	if cerr, ok := err.(*ClientError); !ok || cerr.Err != ErrNoClients {
+		t.Errorf("VerifyEntangleTx without nodes: got %v, want %v", err,
+			ErrNoClients)
+	}
+}
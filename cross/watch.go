@@ -0,0 +1,125 @@
+package cross
+
+import (
+	"fmt"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+)
+
+// ChainEvent is a change of the best block of the node of a chain the coins
+// are entangled from.
+type ChainEvent struct {
+	// ExTxType is the entangle type of the chain.
+	ExTxType ExpandedTxType
+
+	// Chain is the name of the chain.
+	Chain string
+
+	// Height and Hash identify the new best block.
+	Height int64
+	Hash   chainhash.Hash
+
+	// Reorg is set when the previous best block is no longer in the best
+	// chain, so the entangled outputs verified against it may no longer
+	// exist or be mature.
+	Reorg bool
+}
+
+// chainTip is the best block of a chain last seen by a chain watcher.
+type chainTip struct {
+	height int64
+	hash   chainhash.Hash
+}
+
+// ChainWatcher follows the best blocks of the nodes of the chains the coins are
+// entangled from and reports their changes.
+type ChainWatcher struct {
+	ev   *EntangleVerify
+	tips map[ExpandedTxType]*chainTip
+}
+
+// NewChainWatcher returns a watcher of the nodes the passed verifier verifies
+// the entangled outputs against.
+func NewChainWatcher(ev *EntangleVerify) *ChainWatcher {
+	return &ChainWatcher{
+		ev:   ev,
+		tips: make(map[ExpandedTxType]*chainTip),
+	}
+}
+
+// Poll queries the best block of the first node of the chain of the passed
+// entangle type and returns its change since the previous poll, or nil when it
+// did not change.  The first poll of a chain only records its best block.
+//
+// This function is safe for concurrent access with the verifications but not
+// with the other polls.
+func (w *ChainWatcher) Poll(exTxType ExpandedTxType) (*ChainEvent, error) {
+	chain, ok := reserveChains[exTxType]
+	if !ok {
+		return nil, fmt.Errorf("unknown entangle type %d", exTxType)
+	}
+
+	w.ev.clientsMtx.RLock()
+	defer w.ev.clientsMtx.RUnlock()
+
+	clients := w.ev.DogeCoinRPC
+	if exTxType == ExpandedTxEntangle_Ltc {
+		clients = w.ev.LtcCoinRPC
+	}
+	if len(clients) == 0 {
+		return nil, nil
+	}
+	client := clients[0]
+
+	height, err := client.GetBlockCount()
+	if err != nil {
+		return nil, fmt.Errorf("%s node: %v", chain.name, err)
+	}
+	hash, err := client.GetBlockHash(height)
+	if err != nil {
+		return nil, fmt.Errorf("%s node: %v", chain.name, err)
+	}
+
+	prev := w.tips[exTxType]
+	if prev != nil && prev.height == height && prev.hash == *hash {
+		return nil, nil
+	}
+	var reorg bool
+	if prev != nil {
+		reorg, err = tipReorganized(prev, height, hash, client.GetBlockHash)
+		if err != nil {
+			return nil, fmt.Errorf("%s node: %v", chain.name, err)
+		}
+	}
+	w.tips[exTxType] = &chainTip{height: height, hash: *hash}
+	if prev == nil {
+		return nil, nil
+	}
+	return &ChainEvent{
+		ExTxType: exTxType,
+		Chain:    chain.name,
+		Height:   height,
+		Hash:     *hash,
+		Reorg:    reorg,
+	}, nil
+}
+
+// tipReorganized returns whether the passed previous best block is no longer
+// in the best chain of a node whose best block is at the passed height and
+// hash.  The hashes of the blocks of the best chain of the node are looked up
+// with the passed function.
+func tipReorganized(prev *chainTip, height int64, hash *chainhash.Hash,
+	blockHash func(int64) (*chainhash.Hash, error)) (bool, error) {
+
+	switch {
+	case height < prev.height:
+		return true, nil
+	case height == prev.height:
+		return *hash != prev.hash, nil
+	}
+	prevHash, err := blockHash(prev.height)
+	if err != nil {
+		return false, err
+	}
+	return *prevHash != prev.hash, nil
+}
@@ -0,0 +1,52 @@
+package cross
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+)
+
+// TestTipReorganized ensures a previous best block is reported reorganized
+// only when it is no longer in the best chain of the node.
+func TestTipReorganized(t *testing.T) {
+	prev := &chainTip{height: 10, hash: chainhash.Hash{0x0a}}
+	chain := map[int64]chainhash.Hash{10: {0x0a}}
+	blockHash := func(height int64) (*chainhash.Hash, error) {
+		hash, ok := chain[height]
+		if !ok {
+			return nil, errors.New("block height out of range")
+		}
+		return &hash, nil
+	}
+
+	tests := []struct {
+		name   string
+		height int64
+		hash   chainhash.Hash
+		chain  chainhash.Hash
+		reorg  bool
+	}{
+		{"extended", 12, chainhash.Hash{0x0c}, chainhash.Hash{0x0a}, false},
+		{"replaced", 10, chainhash.Hash{0x1a}, chainhash.Hash{0x1a}, true},
+		{"shortened", 9, chainhash.Hash{0x09}, chainhash.Hash{0x0a}, true},
+		{"forked", 12, chainhash.Hash{0x1c}, chainhash.Hash{0x1a}, true},
+	}
+	for _, test := range tests {
+		chain[10] = test.chain
+		reorg, err := tipReorganized(prev, test.height, &test.hash, blockHash)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if reorg != test.reorg {
+			t.Errorf("%s: got reorg %v, want %v", test.name, reorg,
+				test.reorg)
+		}
+	}
+
+	delete(chain, 10)
+	if _, err := tipReorganized(prev, 12, &chainhash.Hash{0x0c}, blockHash); err == nil {
+		t.Errorf("tipReorganized: lookup error not returned")
+	}
+}
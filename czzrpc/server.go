@@ -66,11 +66,10 @@ type NetManager interface {
 	// rebroadcasted at random intervals until they show up in a block.
 	AddRebroadcastInventory(iv *wire.InvVect, data interface{})
 
-	// AnnounceNewTransactions generates and relays inventory vectors and notifies
-	// both websocket and getblocktemplate long poll clients of the passed
-	// transactions.  This function should be called whenever new transactions
-	// are added to the mempool.
-	AnnounceNewTransactions(txns []*mempool.TxDesc)
+	// AnnounceLocalTransactions generates and diffuses inventory vectors of
+	// the passed locally submitted transactions and notifies both websocket
+	// and getblocktemplate long poll clients of them.
+	AnnounceLocalTransactions(txns []*mempool.TxDesc)
 }
 
 // GrpcServerConfig hols the various objects needed by the GrpcServer to
@@ -965,10 +964,10 @@ func (s *GrpcServer) SubmitTransaction(ctx context.Context, req *pb.SubmitTransa
 		return nil, status.Errorf(codes.Internal, "transaction %v is not in accepted list", tx.Hash())
 	}
 
-	// Generate and relay inventory vectors for all newly accepted
+	// Generate and diffuse inventory vectors for all newly accepted
 	// transactions into the memory pool due to the original being
 	// accepted.
-	s.netMgr.AnnounceNewTransactions(acceptedTxs)
+	s.netMgr.AnnounceLocalTransactions(acceptedTxs)
 
 	// Keep track of all the sendrawtransaction request txns so that they
 	// can be rebroadcast if they don't make their way into a block.
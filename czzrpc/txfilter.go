@@ -68,9 +68,15 @@ func (f *txFilter) AddAddress(addr czzutil.Address) {
 	case *czzutil.AddressPubKeyHash:
 		f.pubKeyHashes[*a.Hash160()] = struct{}{}
 
+	case *czzutil.LegacyAddressPubKeyHash:
+		f.pubKeyHashes[*a.Hash160()] = struct{}{}
+
 	case *czzutil.AddressScriptHash:
 		f.scriptHashes[*a.Hash160()] = struct{}{}
 
+	case *czzutil.LegacyAddressScriptHash:
+		f.scriptHashes[*a.Hash160()] = struct{}{}
+
 	case *czzutil.AddressPubKey:
 		pubkeyBytes := a.ScriptAddress()
 		switch len(pubkeyBytes) {
@@ -101,9 +107,15 @@ func (f *txFilter) RemoveAddress(addr czzutil.Address) {
 	case *czzutil.AddressPubKeyHash:
 		delete(f.pubKeyHashes, *a.Hash160())
 
+	case *czzutil.LegacyAddressPubKeyHash:
+		delete(f.pubKeyHashes, *a.Hash160())
+
 	case *czzutil.AddressScriptHash:
 		delete(f.scriptHashes, *a.Hash160())
 
+	case *czzutil.LegacyAddressScriptHash:
+		delete(f.scriptHashes, *a.Hash160())
+
 	case *czzutil.AddressPubKey:
 		pubkeyBytes := a.ScriptAddress()
 		switch len(pubkeyBytes) {
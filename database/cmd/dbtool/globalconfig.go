@@ -12,7 +12,7 @@ import (
 
 	"github.com/bourbaki-czz/classzz/chaincfg"
 	"github.com/bourbaki-czz/classzz/database"
-	_ "github.com/bourbaki-czz/classzz/database/ffldb"
+	"github.com/bourbaki-czz/classzz/database/ffldb"
 	"github.com/bourbaki-czz/classzz/wire"
 	"github.com/bourbaki-czz/czzutil"
 )
@@ -33,6 +33,8 @@ var (
 type config struct {
 	DataDir        string `short:"b" long:"datadir" description:"Location of the classzz data directory"`
 	DbType         string `long:"dbtype" description:"Database backend to use for the Block Chain"`
+	BlocksDir      string `long:"blocksdir" description:"Location of the blocks when they are not stored in the data directory"`
+	BlockColdDir   string `long:"blockcolddir" description:"Location of the block files moved to cold storage"`
 	TestNet3       bool   `long:"testnet" description:"Use the test network"`
 	RegressionTest bool   `long:"regtest" description:"Use the regression test network"`
 	SimNet         bool   `long:"simnet" description:"Use the simulation test network"`
@@ -117,5 +119,30 @@ func setupGlobalConfig() error {
 	// worry about changing names per network and such.
 	cfg.DataDir = filepath.Join(cfg.DataDir, netName(activeNetParams))
 
+	// The block directories are namespaced per network in the same
+	// fashion.
+	if cfg.BlocksDir != "" {
+		cfg.BlocksDir = filepath.Join(cfg.BlocksDir, netName(activeNetParams))
+	}
+	if cfg.BlockColdDir != "" {
+		cfg.BlockColdDir = filepath.Join(cfg.BlockColdDir,
+			netName(activeNetParams))
+	}
+
 	return nil
 }
+
+// blockStorageConfig returns the config of where the database of the passed
+// type stores the blocks.  Files are never moved to cold storage by the
+// utility.
+func blockStorageConfig(dbType string) ffldb.BlockStorageConfig {
+	var storage ffldb.BlockStorageConfig
+	dbName := blockDbNamePrefix + "_" + dbType
+	if cfg.BlocksDir != "" {
+		storage.BlocksDir = filepath.Join(cfg.BlocksDir, dbName)
+	}
+	if cfg.BlockColdDir != "" {
+		storage.ColdDir = filepath.Join(cfg.BlockColdDir, dbName)
+	}
+	return storage
+}
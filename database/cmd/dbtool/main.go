@@ -31,8 +31,11 @@ func loadBlockDB() (database.DB, error) {
 	dbName := blockDbNamePrefix + "_" + cfg.DbType
 	dbPath := filepath.Join(cfg.DataDir, dbName)
 
+	storage := blockStorageConfig(cfg.DbType)
+
 	log.Infof("Loading block database from '%s'", dbPath)
-	db, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net)
+	db, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net,
+		uint64(0), uint32(0), storage)
 	if err != nil {
 		// Return the error if it's not because the database doesn't
 		// exist.
@@ -47,7 +50,8 @@ func loadBlockDB() (database.DB, error) {
 		if err != nil {
 			return nil, err
 		}
-		db, err = database.Create(cfg.DbType, dbPath, activeNetParams.Net)
+		db, err = database.Create(cfg.DbType, dbPath, activeNetParams.Net,
+			uint64(0), uint32(0), storage)
 		if err != nil {
 			return nil, err
 		}
@@ -89,6 +93,11 @@ func realMain() error {
 	parser.AddCommand("fetchblockregion",
 		"Fetch the specified block region from the database", "",
 		&blockRegionCfg)
+	parser.AddCommand("migrate",
+		"Copy the block database to a different database backend",
+		"Copy all blocks and metadata of the block database to a new "+
+			"database of the backend specified with --to.  The "+
+			"source database is left untouched.", &migrateCfg)
 
 	// Parse command line and invoke the Execute function for the specified
 	// command.
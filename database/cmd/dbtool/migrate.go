@@ -0,0 +1,291 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+const (
+	// migrateBatchSize is the number of metadata keys copied per
+	// transaction.
+	migrateBatchSize = 50000
+
+	// migrateBlockBatchSize is the number of blocks copied per
+	// transaction.
+	migrateBlockBatchSize = 500
+)
+
+var (
+	// The following names are internal to the drivers and the blockchain
+	// package.  The block index and the write cursor are maintained by
+	// the destination database itself, while the hash index is only read
+	// to store the blocks with their height.
+	blockIdxBucketName  = []byte("ffldb-blockidx")
+	writeLocKeyName     = []byte("ffldb-writeloc")
+	hashIndexBucketName = []byte("hashidx")
+)
+
+// migrateCmd defines the configuration options for the migrate command.
+type migrateCmd struct {
+	To string `long:"to" description:"Database backend to migrate the block database to"`
+}
+
+var (
+	// migrateCfg defines the configuration options for the command.
+	migrateCfg = migrateCmd{}
+)
+
+// migrateBlock is a block to copy along with its main chain height, which is
+// unknown for blocks not in the main chain.
+type migrateBlock struct {
+	hash   chainhash.Hash
+	height int32
+}
+
+// migrator copies metadata into the destination database in batches so the
+// pending changes of a single transaction do not exhaust the memory.
+type migrator struct {
+	db      database.DB
+	tx      database.Tx
+	pending int
+}
+
+// bucket returns the bucket at the passed path in the current transaction.
+func (m *migrator) bucket(path [][]byte) database.Bucket {
+	bucket := m.tx.Metadata()
+	for _, key := range path {
+		bucket = bucket.Bucket(key)
+	}
+	return bucket
+}
+
+// flush commits the current transaction once it holds enough changes, or
+// unconditionally when force is set, and begins a new one.
+func (m *migrator) flush(force bool) error {
+	if m.tx != nil {
+		if !force && m.pending < migrateBatchSize {
+			return nil
+		}
+		if err := m.tx.Commit(); err != nil {
+			m.tx = nil
+			return err
+		}
+	}
+
+	m.pending = 0
+	tx, err := m.db.Begin(true)
+	m.tx = tx
+	return err
+}
+
+// copyBucket recursively copies all keys and nested buckets of the source
+// bucket to the bucket at the passed path of the destination database.
+func (m *migrator) copyBucket(src database.Bucket, path [][]byte) error {
+	err := src.ForEach(func(k, v []byte) error {
+		if len(path) == 0 && string(k) == string(writeLocKeyName) {
+			return nil
+		}
+
+		key := append([]byte(nil), k...)
+		value := append([]byte(nil), v...)
+		if err := m.bucket(path).Put(key, value); err != nil {
+			return err
+		}
+		m.pending++
+		return m.flush(false)
+	})
+	if err != nil {
+		return err
+	}
+
+	return src.ForEachBucket(func(k []byte) error {
+		if len(path) == 0 && string(k) == string(blockIdxBucketName) {
+			return nil
+		}
+
+		key := append([]byte(nil), k...)
+		_, err := m.bucket(path).CreateBucketIfNotExists(key)
+		if err != nil {
+			return err
+		}
+		nested := append(append([][]byte(nil), path...), key)
+		return m.copyBucket(src.Bucket(k), nested)
+	})
+}
+
+// migrateBlocks copies all blocks of the source database to the destination
+// database in order of their height.  Blocks which can not be fetched, such as
+// pruned blocks, are skipped.  It returns the number of copied and skipped
+// blocks.
+func migrateBlocks(src, dst database.DB) (int, int, error) {
+	var blocks []migrateBlock
+	err := src.View(func(tx database.Tx) error {
+		hashIndex := tx.Metadata().Bucket(hashIndexBucketName)
+		return tx.Metadata().Bucket(blockIdxBucketName).ForEach(
+			func(k, v []byte) error {
+				block := migrateBlock{height: czzutil.BlockHeightUnknown}
+				copy(block.hash[:], k)
+				if hashIndex != nil {
+					serialized := hashIndex.Get(k)
+					if len(serialized) == 4 {
+						block.height = int32(binary.LittleEndian.Uint32(
+							serialized))
+					}
+				}
+				blocks = append(blocks, block)
+				return nil
+			})
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	sort.SliceStable(blocks, func(i, j int) bool {
+		return uint32(blocks[i].height) < uint32(blocks[j].height)
+	})
+
+	log.Infof("Copying %d blocks...", len(blocks))
+	var copied, skipped int
+	for start := 0; start < len(blocks); start += migrateBlockBatchSize {
+		end := start + migrateBlockBatchSize
+		if end > len(blocks) {
+			end = len(blocks)
+		}
+
+		var batch []*czzutil.Block
+		err := src.View(func(tx database.Tx) error {
+			for _, b := range blocks[start:end] {
+				blockBytes, err := tx.FetchBlock(&b.hash)
+				if err != nil {
+					log.Debugf("Skipping block %v: %v", b.hash, err)
+					skipped++
+					continue
+				}
+				block, err := czzutil.NewBlockFromBytes(blockBytes)
+				if err != nil {
+					return err
+				}
+				block.SetHeight(b.height)
+				batch = append(batch, block)
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+
+		err = dst.Update(func(tx database.Tx) error {
+			for _, block := range batch {
+				if err := tx.StoreBlock(block); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+		copied += len(batch)
+		log.Infof("Copied %d of %d blocks", copied+skipped, len(blocks))
+	}
+	return copied, skipped, nil
+}
+
+// migrate copies all blocks and metadata of the source database to the
+// destination database.
+func migrate(src, dst database.DB) error {
+	copied, skipped, err := migrateBlocks(src, dst)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Copying metadata...")
+	m := &migrator{db: dst}
+	if err := m.flush(true); err != nil {
+		return err
+	}
+	err = src.View(func(tx database.Tx) error {
+		return m.copyBucket(tx.Metadata(), nil)
+	})
+	if err != nil {
+		if m.tx != nil {
+			_ = m.tx.Rollback()
+		}
+		return err
+	}
+	if err := m.tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Infof("Migrated %d blocks (%d skipped) and the metadata", copied,
+		skipped)
+	return nil
+}
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *migrateCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	if migrateCfg.To == "" {
+		return errors.New("required --to database type not specified")
+	}
+	if !validDbType(migrateCfg.To) {
+		str := "The specified database type [%v] is invalid -- " +
+			"supported types %v"
+		return fmt.Errorf(str, migrateCfg.To, knownDbTypes)
+	}
+	if migrateCfg.To == cfg.DbType {
+		return errors.New("the source and destination database types " +
+			"must differ")
+	}
+
+	// Load the source block database.
+	src, err := loadBlockDB()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Create the destination block database, which must not exist yet.
+	dbName := blockDbNamePrefix + "_" + migrateCfg.To
+	dbPath := filepath.Join(cfg.DataDir, dbName)
+	if fileExists(dbPath) {
+		return fmt.Errorf("the destination database %q already exists",
+			dbPath)
+	}
+	log.Infof("Creating block database at '%s'", dbPath)
+	dst, err := database.Create(migrateCfg.To, dbPath, activeNetParams.Net)
+	if err != nil {
+		return err
+	}
+
+	startTime := time.Now()
+	if err := migrate(src, dst); err != nil {
+		// Remove the partially migrated database.
+		dst.Close()
+		_ = os.RemoveAll(dbPath)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	log.Infof("Migration finished in %v -- start classzz with --dbtype=%s "+
+		"to use the new database", time.Since(startTime), migrateCfg.To)
+	return nil
+}
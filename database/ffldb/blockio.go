@@ -8,6 +8,7 @@
 package ffldb
 
 import (
+	"bufio"
 	"container/list"
 	"encoding/binary"
 	"fmt"
@@ -18,6 +19,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
 	"github.com/bourbaki-czz/classzz/database"
@@ -58,6 +60,10 @@ const (
 	//  [4:8]  File offset (4 bytes)
 	//  [8:12] Block length (4 bytes)
 	blockLocSize = 12
+
+	// verifyBlockFiles is the number of most recent block files whose
+	// blocks are verified when the database is opened.
+	verifyBlockFiles = 2
 )
 
 var (
@@ -103,6 +109,62 @@ type writeCursor struct {
 	curOffset uint32
 }
 
+// blockBackend describes the storage of the raw blocks which is used by the
+// database next to the metadata.  The locations handed out when writing blocks
+// are stored in the block index and the write cursor position is stored in the
+// metadata so the block data and metadata can be reconciled after unclean
+// shutdowns.
+//
+// All writes happen during a write transaction, of which there can be only one
+// at a time, while reads are safe for concurrent access.
+type blockBackend interface {
+	// writeBlock stores the raw block of the passed height and returns its
+	// location.
+	writeBlock(rawBlock []byte, height uint32) (blockLocation, error)
+
+	// readBlock returns the raw block at the passed location.
+	readBlock(hash *chainhash.Hash, loc blockLocation) ([]byte, error)
+
+	// readBlockRegion returns numBytes bytes at the passed offset into the
+	// raw block at the passed location.
+	readBlockRegion(loc blockLocation, offset, numBytes uint32) ([]byte, error)
+
+	// deleteBlocks deletes stored blocks below the passed height.
+	deleteBlocks(deleteBefore uint32) error
+
+	// syncBlocks ensures all written blocks are persisted.
+	syncBlocks() error
+
+	// writeCursorPos returns the position the next block will be written
+	// to.
+	writeCursorPos() (uint32, uint32)
+
+	// handleRollback removes all blocks written after the passed write
+	// cursor position.
+	handleRollback(oldBlockFileNum, oldBlockOffset uint32)
+
+	// verifyBlocks verifies the most recently written blocks before the
+	// passed write cursor position and returns the position right after
+	// the last intact block, which is the passed one unless blocks are
+	// damaged or missing.
+	verifyBlocks(endFileNum, endOffset uint32) (uint32, uint32, error)
+
+	// storePath returns the path the blocks are stored at.
+	storePath() string
+
+	// stats returns statistics about the stored blocks.
+	stats() (database.StoreStats, error)
+
+	// compact compacts the stored blocks.
+	compact() error
+
+	// close releases all resources held by the backend.
+	close() error
+}
+
+// Ensure blockStore implements the blockBackend interface.
+var _ blockBackend = (*blockStore)(nil)
+
 // blockStore houses information used to handle reading and writing blocks (and
 // part of blocks) into flat files with support for multiple concurrent readers.
 type blockStore struct {
@@ -110,9 +172,16 @@ type blockStore struct {
 	// block.
 	network wire.BitcoinNet
 
-	// basePath is the base path used for the flat block files and metadata.
+	// basePath is the base path used for the flat block files.  It is the
+	// database path unless the blocks are stored on a different volume.
 	basePath string
 
+	// coldPath is the directory the flat block files which were not
+	// written to for coldAge are moved to.  Tiering is disabled when it is
+	// empty.
+	coldPath string
+	coldAge  time.Duration
+
 	// maxBlockFileSize is the maximum size for each file used to store
 	// blocks.  It is defined on the store so the whitebox tests can
 	// override the value.
@@ -132,7 +201,12 @@ type blockStore struct {
 	// lruMutex protects concurrent access to the least recently used list
 	// and lookup map.
 	//
-	// fbhMutex protects concurrent access to the fileBlockHeights map.
+	// fbhMutex protects concurrent access to the fileBlockHeights map.  It
+	// is also held while a file is moved to cold storage so the file is
+	// not deleted at the same time.
+	//
+	// coldMutex protects concurrent access to the coldFiles map.  It is
+	// never held while acquiring any of the other mutexes.
 	//
 	// openBlocksLRU tracks how the open files are refenced by pushing the
 	// most recently used files to the front of the list thereby trickling
@@ -181,6 +255,16 @@ type blockStore struct {
 	deleteFileFunc    func(fileNum uint32) error
 
 	fileBlockHeights map[uint32]uint32
+
+	// coldFiles houses the numbers of the block files which were moved to
+	// the cold directory.
+	coldMutex sync.RWMutex
+	coldFiles map[uint32]struct{}
+
+	// tierQuit and tierWg are used to stop the goroutine moving the block
+	// files to cold storage.
+	tierQuit chan struct{}
+	tierWg   sync.WaitGroup
 }
 
 // blockLocation identifies a particular block file and location.
@@ -230,6 +314,18 @@ func blockFilePath(dbPath string, fileNum uint32) string {
 	return filepath.Join(dbPath, fileName)
 }
 
+// filePath returns the path of the provided block file number, which is in the
+// cold directory when the file was moved there.
+func (s *blockStore) filePath(fileNum uint32) string {
+	s.coldMutex.RLock()
+	_, cold := s.coldFiles[fileNum]
+	s.coldMutex.RUnlock()
+	if cold {
+		return blockFilePath(s.coldPath, fileNum)
+	}
+	return blockFilePath(s.basePath, fileNum)
+}
+
 // openWriteFile returns a file handle for the passed flat file number in
 // read/write mode.  The file will be created if needed.  It is typically used
 // for the current file that will have all new data appended.  Unlike openFile,
@@ -258,7 +354,7 @@ func (s *blockStore) openWriteFile(fileNum uint32) (filer, error) {
 // for WRITES.
 func (s *blockStore) openFile(fileNum uint32) (*lockableFile, error) {
 	// Open the appropriate file as read-only.
-	filePath := blockFilePath(s.basePath, fileNum)
+	filePath := s.filePath(fileNum)
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, makeDbErr(database.ErrDriverSpecific, err.Error(),
@@ -306,11 +402,14 @@ func (s *blockStore) openFile(fileNum uint32) (*lockableFile, error) {
 // must already be closed and it is the responsibility of the caller to do any
 // other state cleanup necessary.
 func (s *blockStore) deleteFile(fileNum uint32) error {
-	filePath := blockFilePath(s.basePath, fileNum)
+	filePath := s.filePath(fileNum)
 	if err := os.Remove(filePath); err != nil {
 		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
 	}
 
+	s.coldMutex.Lock()
+	delete(s.coldFiles, fileNum)
+	s.coldMutex.Unlock()
 	return nil
 }
 
@@ -771,6 +870,172 @@ func (s *blockStore) handleRollback(oldBlockFileNum, oldBlockOffset uint32) {
 	}
 }
 
+// verifyBlockFile verifies the checksums of the blocks in the passed block file
+// up to the passed end offset, or up to the end of the file when end is
+// negative, and returns the offset right after the last intact block along with
+// whether all of them are intact.  A missing file is only considered damaged
+// when it is expected to contain blocks up to the passed end offset, since
+// older files might have been pruned.
+//
+// Format: <network><block length><serialized block><checksum>
+func (s *blockStore) verifyBlockFile(fileNum uint32, end int64) (uint32, bool, error) {
+	file, err := os.Open(s.filePath(fileNum))
+	if os.IsNotExist(err) {
+		return 0, end <= 0, nil
+	}
+	if err != nil {
+		return 0, false, makeDbErr(database.ErrDriverSpecific,
+			err.Error(), err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, false, makeDbErr(database.ErrDriverSpecific,
+			err.Error(), err)
+	}
+	limit := info.Size()
+	if end >= 0 && end < limit {
+		limit = end
+	}
+
+	// Block files which were rolled over end with the height of their last
+	// block, so a remainder shorter than a block record is expected when
+	// verifying them completely.
+	var offset int64
+	reader := bufio.NewReaderSize(file, 1<<20)
+	for offset < limit {
+		remaining := limit - offset
+		if end < 0 && remaining < 12 {
+			return uint32(offset), remaining == 4, nil
+		}
+
+		var header [8]byte
+		if remaining < 12 {
+			break
+		}
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			break
+		}
+		network := byteOrder.Uint32(header[0:4])
+		blockLen := int64(byteOrder.Uint32(header[4:8]))
+		if network != uint32(s.network) || blockLen+12 > remaining {
+			break
+		}
+
+		hasher := crc32.New(castagnoli)
+		_, _ = hasher.Write(header[:])
+		if _, err := io.CopyN(hasher, reader, blockLen); err != nil {
+			break
+		}
+		var checksum [4]byte
+		if _, err := io.ReadFull(reader, checksum[:]); err != nil {
+			break
+		}
+		if binary.BigEndian.Uint32(checksum[:]) != hasher.Sum32() {
+			break
+		}
+		offset += blockLen + 12
+	}
+	return uint32(offset), offset == limit && (end < 0 || offset == end),
+		nil
+}
+
+// verifyBlocks verifies the checksums of the blocks in the last
+// verifyBlockFiles block files before the passed write cursor position and
+// returns the position right after the last intact block.
+//
+// This is part of the blockBackend interface.
+func (s *blockStore) verifyBlocks(endFileNum, endOffset uint32) (uint32, uint32, error) {
+	var fileNum uint32
+	if endFileNum >= verifyBlockFiles {
+		fileNum = endFileNum - verifyBlockFiles + 1
+	}
+	for ; fileNum <= endFileNum; fileNum++ {
+		end := int64(-1)
+		if fileNum == endFileNum {
+			end = int64(endOffset)
+		}
+		offset, intact, err := s.verifyBlockFile(fileNum, end)
+		if err != nil {
+			return 0, 0, err
+		}
+		if !intact {
+			return fileNum, offset, nil
+		}
+	}
+	return endFileNum, endOffset, nil
+}
+
+// writeCursorPos returns the current block file number and offset of the write
+// cursor.
+func (s *blockStore) writeCursorPos() (uint32, uint32) {
+	wc := s.writeCursor
+	wc.RLock()
+	defer wc.RUnlock()
+	return wc.curFileNum, wc.curOffset
+}
+
+// storePath returns the directory the flat block files are stored in.
+func (s *blockStore) storePath() string {
+	return s.basePath
+}
+
+// stats returns the number and total size of the flat files, including the
+// ones in cold storage.  Since blocks are only ever appended, and pruned by
+// deleting whole files, the flat files do not contain any tombstones or dead
+// data.
+func (s *blockStore) stats() (database.StoreStats, error) {
+	files, size, err := dirStats(s.basePath, isBlockFileName)
+	if err != nil {
+		return database.StoreStats{}, err
+	}
+	if s.coldPath != "" {
+		coldFiles, coldSize, err := dirStats(s.coldPath, isBlockFileName)
+		if err != nil {
+			return database.StoreStats{}, err
+		}
+		files += coldFiles
+		size += coldSize
+	}
+
+	return database.StoreStats{
+		Name:              "blocks",
+		Engine:            "flatfiles",
+		Files:             files,
+		Size:              size,
+		SizeAmplification: 1,
+	}, nil
+}
+
+// compact is a no-op since flat files do not need compaction.
+func (s *blockStore) compact() error {
+	return nil
+}
+
+// close stops moving files to cold storage and closes any open flat files that
+// house the blocks.
+func (s *blockStore) close() error {
+	if s.tierQuit != nil {
+		close(s.tierQuit)
+		s.tierWg.Wait()
+		s.tierQuit = nil
+	}
+
+	wc := s.writeCursor
+	if wc.curFile.file != nil {
+		_ = wc.curFile.file.Close()
+		wc.curFile.file = nil
+	}
+	for _, blockFile := range s.openBlockFiles {
+		_ = blockFile.file.Close()
+	}
+	s.openBlockFiles = nil
+	s.openBlocksLRU.Init()
+	s.fileNumToLRUElem = nil
+	return nil
+}
+
 // scanBlockFiles searches the database directory for all flat block files to
 // find the end of the most recent file.  This position is considered the
 // current write cursor which is also stored in the metadata.  Thus, it is used
@@ -824,7 +1089,7 @@ func (s *blockStore) loadLastBlockHeights(dbPath string) error {
 		if uint32(i) == s.writeCursor.curFileNum {
 			continue
 		}
-		filePath := blockFilePath(dbPath, uint32(i))
+		filePath := s.filePath(uint32(i))
 		_, err := os.Stat(filePath)
 		if err != nil {
 			break
@@ -849,8 +1114,9 @@ func (s *blockStore) loadLastBlockHeights(dbPath string) error {
 }
 
 // newBlockStore returns a new block store with the current block file number
-// and offset set and all fields initialized.
-func newBlockStore(basePath string, network wire.BitcoinNet) (*blockStore, error) {
+// and offset set and all fields initialized.  When a cold directory and age are
+// configured, it also starts moving old block files there.
+func newBlockStore(basePath string, network wire.BitcoinNet, storage BlockStorageConfig) (*blockStore, error) {
 	// Look for the end of the latest block to file to determine what the
 	// write cursor position is from the viewpoing of the block files on
 	// disk.
@@ -863,10 +1129,22 @@ func newBlockStore(basePath string, network wire.BitcoinNet) (*blockStore, error
 		fileOff = 0
 	}
 
+	fileSize := maxBlockFileSize
+	if storage.MaxFileSize != 0 {
+		fileSize = storage.MaxFileSize
+	}
+	coldFiles, err := scanColdFiles(basePath, storage.ColdDir)
+	if err != nil {
+		return nil, err
+	}
+
 	store := &blockStore{
 		network:          network,
 		basePath:         basePath,
-		maxBlockFileSize: maxBlockFileSize,
+		coldPath:         storage.ColdDir,
+		coldAge:          storage.ColdAge,
+		coldFiles:        coldFiles,
+		maxBlockFileSize: fileSize,
 		openBlockFiles:   make(map[uint32]*lockableFile),
 		openBlocksLRU:    list.New(),
 		fileNumToLRUElem: make(map[uint32]*list.Element),
@@ -885,5 +1163,11 @@ func newBlockStore(basePath string, network wire.BitcoinNet) (*blockStore, error
 	if err := store.loadLastBlockHeights(basePath); err != nil {
 		return nil, err
 	}
+
+	if store.coldPath != "" && store.coldAge > 0 {
+		store.tierQuit = make(chan struct{})
+		store.tierWg.Add(1)
+		go store.tierHandler()
+	}
 	return store, nil
 }
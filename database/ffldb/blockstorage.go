@@ -0,0 +1,254 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains the functions for storing the blocks in a different
+// directory than the metadata and for moving old flat block files to cold
+// storage.
+
+package ffldb
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/database"
+)
+
+// tierCheckInterval is the interval at which the block files are checked for
+// files old enough to be moved to cold storage.
+const tierCheckInterval = time.Hour
+
+// parseBlockFileName returns the block file number of the passed file name and
+// whether it is the name of a flat block file at all.
+func parseBlockFileName(name string) (uint32, bool) {
+	if !strings.HasSuffix(name, ".fdb") {
+		return 0, false
+	}
+	fileNum, err := strconv.ParseUint(strings.TrimSuffix(name, ".fdb"), 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(fileNum), true
+}
+
+// isBlockFileName returns whether the passed file name is the name of a flat
+// block file.
+func isBlockFileName(name string) bool {
+	_, ok := parseBlockFileName(name)
+	return ok
+}
+
+// hasBlocks returns whether the passed directory houses the blocks of a
+// database of the passed driver type.
+func hasBlocks(dbType, path string) bool {
+	if dbType == ldbDbType {
+		return fileExists(filepath.Join(path, blocksDbName))
+	}
+	files, _, err := dirStats(path, isBlockFileName)
+	return err == nil && files > 0
+}
+
+// prepareBlockStorage validates the passed block storage config of a database
+// of the passed driver type and creates the directories it refers to.  It also
+// ensures the blocks of an existing database were not left behind in the
+// database path when the block directory changed, as they would appear to be
+// missing otherwise.
+func prepareBlockStorage(dbType, dbPath, blocksPath string, storage BlockStorageConfig) error {
+	if storage.ColdDir != "" {
+		if dbType == ldbDbType {
+			str := "the ldb database does not support cold block " +
+				"storage"
+			return makeDbErr(database.ErrDriverSpecific, str, nil)
+		}
+		if filepath.Clean(storage.ColdDir) == filepath.Clean(blocksPath) {
+			str := "the cold block directory must differ from the " +
+				"block directory"
+			return makeDbErr(database.ErrDriverSpecific, str, nil)
+		}
+	}
+
+	if filepath.Clean(blocksPath) != filepath.Clean(dbPath) &&
+		hasBlocks(dbType, dbPath) && !hasBlocks(dbType, blocksPath) {
+
+		str := "the blocks are stored in " + strconv.Quote(dbPath) +
+			" -- move them to " + strconv.Quote(blocksPath) +
+			" before changing the block directory"
+		return makeDbErr(database.ErrDriverSpecific, str, nil)
+	}
+
+	for _, path := range []string{blocksPath, storage.ColdDir} {
+		if path == "" {
+			continue
+		}
+		if err := os.MkdirAll(path, 0700); err != nil {
+			return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+		}
+	}
+	return nil
+}
+
+// scanColdFiles returns the numbers of the block files in the passed cold
+// directory.  Files which are also in the base path are skipped since their
+// move was interrupted, so they will be moved again.
+func scanColdFiles(basePath, coldPath string) (map[uint32]struct{}, error) {
+	coldFiles := make(map[uint32]struct{})
+	if coldPath == "" {
+		return coldFiles, nil
+	}
+
+	infos, err := ioutil.ReadDir(coldPath)
+	if err != nil {
+		return nil, makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	for _, info := range infos {
+		fileNum, ok := parseBlockFileName(info.Name())
+		if !ok || !info.Mode().IsRegular() {
+			continue
+		}
+		if fileExists(blockFilePath(basePath, fileNum)) {
+			continue
+		}
+		coldFiles[fileNum] = struct{}{}
+	}
+
+	log.Tracef("Scan found %d block files in cold storage", len(coldFiles))
+	return coldFiles, nil
+}
+
+// copyBlockFile copies the passed block file to the passed destination path.
+// The copy is written to a temporary file which is only renamed once it is
+// synced so a partial copy is never mistaken for a block file.
+func copyBlockFile(srcPath, dstPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmpPath := dstPath + ".tmp"
+	dst, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if err == nil {
+		err = dst.Sync()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, dstPath)
+}
+
+// moveToCold moves the passed block file, which must not be the current write
+// file, to the cold directory.  The file is copied first and only switched
+// over to the copy once it is complete, so readers are not blocked meanwhile.
+func (s *blockStore) moveToCold(fileNum uint32) error {
+	hotPath := blockFilePath(s.basePath, fileNum)
+	coldPath := blockFilePath(s.coldPath, fileNum)
+	if err := copyBlockFile(hotPath, coldPath); err != nil {
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+
+	// Hold the block heights lock so the file can't be pruned while it is
+	// switched over.  Remove the copy when it was pruned meanwhile.
+	s.fbhMutex.Lock()
+	defer s.fbhMutex.Unlock()
+	if !fileExists(hotPath) {
+		_ = os.Remove(coldPath)
+		return nil
+	}
+
+	// Close the file when it's open under the write lock for the file in
+	// case any readers are currently reading from it.  It is reopened
+	// from the cold directory when it is read the next time.
+	s.obfMutex.Lock()
+	if obf, ok := s.openBlockFiles[fileNum]; ok {
+		s.lruMutex.Lock()
+		s.openBlocksLRU.Remove(s.fileNumToLRUElem[fileNum])
+		delete(s.fileNumToLRUElem, fileNum)
+		s.lruMutex.Unlock()
+
+		obf.Lock()
+		_ = obf.file.Close()
+		obf.Unlock()
+		delete(s.openBlockFiles, fileNum)
+	}
+	s.coldMutex.Lock()
+	s.coldFiles[fileNum] = struct{}{}
+	s.coldMutex.Unlock()
+	s.obfMutex.Unlock()
+
+	if err := os.Remove(hotPath); err != nil {
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	return nil
+}
+
+// tierBlockFiles moves all block files before the current write file which
+// were not modified for the configured cold age to the cold directory.  It
+// returns the number of moved files.
+func (s *blockStore) tierBlockFiles(now time.Time) (int, error) {
+	infos, err := ioutil.ReadDir(s.basePath)
+	if err != nil {
+		return 0, makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+
+	var moved int
+	curFileNum, _ := s.writeCursorPos()
+	for _, info := range infos {
+		fileNum, ok := parseBlockFileName(info.Name())
+		if !ok || !info.Mode().IsRegular() || fileNum >= curFileNum ||
+			now.Sub(info.ModTime()) < s.coldAge {
+
+			continue
+		}
+
+		select {
+		case <-s.tierQuit:
+			return moved, nil
+		default:
+		}
+
+		if err := s.moveToCold(fileNum); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// tierHandler periodically moves old block files to the cold directory until
+// the block store is closed.  It must be run as a goroutine.
+func (s *blockStore) tierHandler() {
+	defer s.tierWg.Done()
+
+	ticker := time.NewTicker(tierCheckInterval)
+	defer ticker.Stop()
+	for {
+		moved, err := s.tierBlockFiles(time.Now())
+		if err != nil {
+			log.Warnf("Unable to move block files to cold storage: %v",
+				err)
+		}
+		if moved > 0 {
+			log.Infof("Moved %d block files to cold storage", moved)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.tierQuit:
+			return
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// TestBlockStorage ensures the blocks are stored in the configured block
+// directory, old block files are moved to the cold directory and blocks in
+// either one are read after reopening the database.
+func TestBlockStorage(t *testing.T) {
+	t.Parallel()
+
+	basePath := filepath.Join(os.TempDir(), "ffldb-blockstoragetest")
+	_ = os.RemoveAll(basePath)
+	defer os.RemoveAll(basePath)
+
+	// Create blocks which only differ in their nonce and size the block
+	// files so each one ends up in its own file.
+	var blocks []*czzutil.Block
+	for i := 0; i < 5; i++ {
+		msgBlock := *chaincfg.MainNetParams.GenesisBlock
+		msgBlock.Header.Nonce += uint64(i)
+		block := czzutil.NewBlock(&msgBlock)
+		block.SetHeight(int32(i))
+		blocks = append(blocks, block)
+	}
+	blockBytes, err := blocks[0].Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: unexpected error: %v", err)
+	}
+
+	dbPath := filepath.Join(basePath, "db")
+	storage := BlockStorageConfig{
+		BlocksDir:   filepath.Join(basePath, "blocks"),
+		MaxFileSize: uint32(len(blockBytes)) + 12,
+		ColdDir:     filepath.Join(basePath, "cold"),
+		ColdAge:     time.Hour,
+	}
+	idb, err := openDB(dbType, dbPath, blockDataNet, true, 0, 0, storage, WriteConfig{})
+	if err != nil {
+		t.Fatalf("openDB: unexpected error: %v", err)
+	}
+	err = idb.Update(func(tx database.Tx) error {
+		for _, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		idb.Close()
+		t.Fatalf("StoreBlock: unexpected error: %v", err)
+	}
+
+	// fetchBlocks ensures all blocks can be fetched from the database.
+	fetchBlocks := func(idb database.DB) {
+		t.Helper()
+		err := idb.View(func(tx database.Tx) error {
+			for _, block := range blocks {
+				wantBytes, _ := block.Bytes()
+				gotBytes, err := tx.FetchBlock(block.Hash())
+				if err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(gotBytes, wantBytes) {
+					t.Errorf("FetchBlock: block %v mismatch",
+						block.Hash())
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("FetchBlock: unexpected error: %v", err)
+		}
+	}
+
+	// countFiles returns the number of block files in the passed
+	// directory.
+	countFiles := func(path string) int {
+		t.Helper()
+		files, _, err := dirStats(path, isBlockFileName)
+		if err != nil {
+			t.Fatalf("dirStats: unexpected error: %v", err)
+		}
+		return files
+	}
+
+	// Ensure the block files are in the block directory rather than in
+	// the database path.
+	fetchBlocks(idb)
+	if files := countFiles(dbPath); files != 0 {
+		t.Errorf("database path: got %d block files, want 0", files)
+	}
+	if files := countFiles(storage.BlocksDir); files != len(blocks) {
+		t.Errorf("block directory: got %d block files, want %d",
+			files, len(blocks))
+	}
+
+	// Ensure recent files are kept while old ones, except for the current
+	// write file, are moved to the cold directory and still readable.
+	store := idb.(*db).store.(*blockStore)
+	moved, err := store.tierBlockFiles(time.Now())
+	if err != nil || moved != 0 {
+		t.Errorf("tierBlockFiles: got (%d, %v), want (0, nil)", moved,
+			err)
+	}
+	moved, err = store.tierBlockFiles(time.Now().Add(2 * time.Hour))
+	if err != nil || moved != len(blocks)-1 {
+		t.Errorf("tierBlockFiles: got (%d, %v), want (%d, nil)", moved,
+			err, len(blocks)-1)
+	}
+	fetchBlocks(idb)
+	if files := countFiles(storage.BlocksDir); files != 1 {
+		t.Errorf("block directory: got %d block files, want 1", files)
+	}
+	if files := countFiles(storage.ColdDir); files != len(blocks)-1 {
+		t.Errorf("cold directory: got %d block files, want %d", files,
+			len(blocks)-1)
+	}
+	stats, err := store.stats()
+	if err != nil || stats.Files != len(blocks) {
+		t.Errorf("stats: got (%d, %v), want (%d, nil)", stats.Files,
+			err, len(blocks))
+	}
+
+	// Ensure the blocks in both directories are found after reopening.
+	idb.Close()
+	idb, err = openDB(dbType, dbPath, blockDataNet, false, 0, 0, storage, WriteConfig{})
+	if err != nil {
+		t.Fatalf("openDB: unexpected error: %v", err)
+	}
+	fetchBlocks(idb)
+	idb.Close()
+
+	// Ensure the database refuses to open with the cold directory being
+	// the block directory.
+	wantErrCode := database.ErrDriverSpecific
+	badStorage := storage
+	badStorage.ColdDir = storage.BlocksDir
+	_, err = openDB(dbType, dbPath, blockDataNet, false, 0, 0, badStorage, WriteConfig{})
+	if !checkDbError(t, "openDB", err, wantErrCode) {
+		return
+	}
+
+	// Ensure a database with the blocks stored along with the metadata
+	// refuses to open with a block directory the blocks were not moved
+	// to.
+	defaultPath := filepath.Join(basePath, "default")
+	idb, err = openDB(dbType, defaultPath, blockDataNet, true, 0, 0,
+		BlockStorageConfig{}, WriteConfig{})
+	if err != nil {
+		t.Fatalf("openDB: unexpected error: %v", err)
+	}
+	err = idb.Update(func(tx database.Tx) error {
+		return tx.StoreBlock(blocks[0])
+	})
+	idb.Close()
+	if err != nil {
+		t.Fatalf("StoreBlock: unexpected error: %v", err)
+	}
+	_, err = openDB(dbType, defaultPath, blockDataNet, false, 0, 0,
+		BlockStorageConfig{BlocksDir: filepath.Join(basePath, "moved")}, WriteConfig{})
+	if !checkDbError(t, "openDB", err, wantErrCode) {
+		return
+	}
+}
+
+// TestParseBlockFileName ensures block file names are recognized.
+func TestParseBlockFileName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		fileNum uint32
+		ok      bool
+	}{
+		{"000000000.fdb", 0, true},
+		{"000000123.fdb", 123, true},
+		{"000000123.fdb.tmp", 0, false},
+		{"CURRENT", 0, false},
+		{"abc.fdb", 0, false},
+	}
+	for _, test := range tests {
+		fileNum, ok := parseBlockFileName(test.name)
+		if fileNum != test.fileNum || ok != test.ok {
+			t.Errorf("parseBlockFileName(%q): got (%d, %v), want "+
+				"(%d, %v)", test.name, fileNum, ok, test.fileNum,
+				test.ok)
+		}
+	}
+}
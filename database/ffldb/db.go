@@ -1666,11 +1666,7 @@ func (tx *transaction) writePendingAndCommit() error {
 	// These variables are only updated here in this function and there can
 	// only be one write transaction active at a time, so it's safe to store
 	// them for potential rollback.
-	wc := tx.db.store.writeCursor
-	wc.RLock()
-	oldBlkFileNum := wc.curFileNum
-	oldBlkOffset := wc.curOffset
-	wc.RUnlock()
+	oldBlkFileNum, oldBlkOffset := tx.db.store.writeCursorPos()
 
 	// rollback is a closure that is used to rollback all writes to the
 	// block files.
@@ -1713,7 +1709,7 @@ func (tx *transaction) writePendingAndCommit() error {
 	}
 
 	// Update the metadata for the current write file and offset.
-	writeRow := serializeWriteRow(wc.curFileNum, wc.curOffset)
+	writeRow := serializeWriteRow(tx.db.store.writeCursorPos())
 	if err := tx.metaBucket.Put(writeLocKeyName, writeRow); err != nil {
 		rollback()
 		return convertErr("failed to store write cursor", err)
@@ -1785,8 +1781,10 @@ type db struct {
 	writeLock sync.Mutex   // Limit to one write transaction at a time.
 	closeLock sync.RWMutex // Make database close block while txns active.
 	closed    bool         // Is the database closed?
-	store     *blockStore  // Handles read/writing blocks to flat files.
+	store     blockBackend // Handles read/writing blocks.
 	cache     *dbCache     // Cache layer which wraps underlying leveldb DB.
+	dbType    string       // Driver type the database was opened with.
+	dbPath    string       // Path housing the metadata database.
 }
 
 // Enforce db implements the database.DB interface.
@@ -1797,7 +1795,7 @@ var _ database.DB = (*db)(nil)
 //
 // This function is part of the database.DB interface implementation.
 func (db *db) Type() string {
-	return dbType
+	return db.dbType
 }
 
 // begin is the implementation function for the Begin database method.  See its
@@ -1809,18 +1807,27 @@ func (db *db) Type() string {
 func (db *db) begin(writable bool) (*transaction, error) {
 	// Make sure there is enough available disk space so we can inform the
 	// user of the problem instead of causing a db failure.
+	// The blocks might be stored on a different volume than the metadata,
+	// so both are checked in that case.
 	if writable {
-		freeSpace, err := getAvailableDiskSpace(db.store.basePath)
-		if err != nil {
-			return nil, makeDbErr(database.ErrDriverSpecific,
-				"failed to inspect available disk space", err)
+		paths := []string{db.dbPath}
+		if storePath := db.store.storePath(); storePath != db.dbPath {
+			paths = append(paths, storePath)
 		}
+		for _, path := range paths {
+			freeSpace, err := getAvailableDiskSpace(path)
+			if err != nil {
+				return nil, makeDbErr(database.ErrDriverSpecific,
+					"failed to inspect available disk space", err)
+			}
 
-		if freeSpace < minAvailableSpaceUpdate {
-			errMsg := fmt.Sprintf("available disk space too low: "+
-				"%.1f MiB", float64(freeSpace)/float64(bytesMiB))
-			return nil, makeDbErr(database.ErrAvailableDiskSpace,
-				errMsg, nil)
+			if freeSpace < minAvailableSpaceUpdate {
+				errMsg := fmt.Sprintf("available disk space too "+
+					"low on %q: %.1f MiB", path,
+					float64(freeSpace)/float64(bytesMiB))
+				return nil, makeDbErr(database.ErrAvailableDiskSpace,
+					errMsg, nil)
+			}
 		}
 	}
 
@@ -1997,18 +2004,10 @@ func (db *db) Close() error {
 	// good way for the caller to recover from a failure here anyways.
 	closeErr := db.cache.Close()
 
-	// Close any open flat files that house the blocks.
-	wc := db.store.writeCursor
-	if wc.curFile.file != nil {
-		_ = wc.curFile.file.Close()
-		wc.curFile.file = nil
-	}
-	for _, blockFile := range db.store.openBlockFiles {
-		_ = blockFile.file.Close()
+	// Close the block storage.
+	if err := db.store.close(); err != nil && closeErr == nil {
+		closeErr = err
 	}
-	db.store.openBlockFiles = nil
-	db.store.openBlocksLRU.Init()
-	db.store.fileNumToLRUElem = nil
 
 	return closeErr
 }
@@ -2052,9 +2051,11 @@ func initDB(ldb *leveldb.DB) error {
 	return nil
 }
 
-// openDB opens the database at the provided path.  database.ErrDbDoesNotExist
-// is returned if the database doesn't exist and the create flag is not set.
-func openDB(dbPath string, network wire.BitcoinNet, create bool, cacheSize uint64, flushSecs uint32) (database.DB, error) {
+// openDB opens the database of the passed driver type at the provided path.
+// The ffldb type stores the blocks in flat files while the ldb type stores
+// them in a separate leveldb database.  database.ErrDbDoesNotExist is returned
+// if the database doesn't exist and the create flag is not set.
+func openDB(dbType, dbPath string, network wire.BitcoinNet, create bool, cacheSize uint64, flushSecs uint32, storage BlockStorageConfig, write WriteConfig) (database.DB, error) {
 	// Error if the database doesn't exist and the create flag is not set.
 	metadataDbPath := filepath.Join(dbPath, metadataDbName)
 	dbExists := fileExists(metadataDbPath)
@@ -2071,25 +2072,41 @@ func openDB(dbPath string, network wire.BitcoinNet, create bool, cacheSize uint6
 		_ = os.MkdirAll(dbPath, 0700)
 	}
 
+	// Validate the block storage config and create the block directories.
+	blocksPath := dbPath
+	if storage.BlocksDir != "" {
+		blocksPath = storage.BlocksDir
+	}
+	if err := prepareBlockStorage(dbType, dbPath, blocksPath, storage); err != nil {
+		return nil, err
+	}
+
 	// Open the metadata database (will create it if needed).
 	opts := opt.Options{
 		ErrorIfExist: create,
 		Strict:       opt.DefaultStrict,
 		Compression:  opt.NoCompression,
 		Filter:       filter.NewBloomFilter(10),
+		WriteBuffer:  write.WriteBuffer,
 	}
-	ldb, err := leveldb.OpenFile(metadataDbPath, &opts)
+	ldb, err := openLevelDB(metadataDbPath, &opts)
 	if err != nil {
-		return nil, convertErr(err.Error(), err)
+		return nil, err
 	}
 
-	// Create the block store which includes scanning the existing flat
-	// block files to find what the current write cursor position is
-	// according to the data that is actually on disk.  Also create the
-	// database cache which wraps the underlying leveldb database to provide
-	// write caching.
-	store, err := newBlockStore(dbPath, network)
+	// Create the block store which includes scanning the existing blocks
+	// to find what the current write cursor position is according to the
+	// data that is actually on disk.  Also create the database cache which
+	// wraps the underlying leveldb database to provide write caching.
+	var store blockBackend
+	if dbType == ldbDbType {
+		store, err = newLdbBlockStore(blocksPath, network,
+			write.WriteBuffer)
+	} else {
+		store, err = newBlockStore(blocksPath, network, storage)
+	}
 	if err != nil {
+		_ = ldb.Close()
 		return nil, err
 	}
 	if cacheSize == 0 {
@@ -2098,8 +2115,8 @@ func openDB(dbPath string, network wire.BitcoinNet, create bool, cacheSize uint6
 	if flushSecs == 0 {
 		flushSecs = defaultFlushSecs
 	}
-	cache := newDbCache(ldb, store, cacheSize, flushSecs)
-	pdb := &db{store: store, cache: cache}
+	cache := newDbCache(ldb, store, cacheSize, flushSecs, write)
+	pdb := &db{store: store, cache: cache, dbType: dbType, dbPath: dbPath}
 
 	// Perform any reconciliation needed between the block and metadata as
 	// well as database initialization, if needed.
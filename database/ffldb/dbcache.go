@@ -8,10 +8,12 @@ import (
 	"bytes"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/btcsuite/goleveldb/leveldb"
 	"github.com/btcsuite/goleveldb/leveldb/iterator"
+	"github.com/btcsuite/goleveldb/leveldb/opt"
 	"github.com/btcsuite/goleveldb/leveldb/util"
 	"github.com/bourbaki-czz/classzz/database/internal/treap"
 )
@@ -357,8 +359,13 @@ type dbCache struct {
 	// ldb is the underlying leveldb DB for metadata.
 	ldb *leveldb.DB
 
-	// store is used to sync blocks to flat files.
-	store *blockStore
+	// store is used to sync the stored blocks.
+	store blockBackend
+
+	// tombstones is the number of deletions written to the underlying
+	// leveldb database since the last compaction.  It must be accessed
+	// atomically.
+	tombstones uint64
 
 	// The following fields are related to flushing the cache to persistent
 	// storage.  Note that all flushing is performed in an opportunistic
@@ -380,6 +387,20 @@ type dbCache struct {
 	flushInterval time.Duration
 	lastFlush     time.Time
 
+	// batchSize is the maximum size of a flush which is written as a
+	// single leveldb batch instead of a transaction.
+	//
+	// syncInterval is the minimum interval between syncs of the block data
+	// and the metadata to disk.
+	//
+	// lastSync is the time the cache was last flushed with syncing.
+	//
+	// NOTE: These sync related fields are protected by the database write
+	// lock.
+	batchSize    uint64
+	syncInterval time.Duration
+	lastSync     time.Time
+
 	// The following fields hold the keys that need to be stored or deleted
 	// from the underlying database once the cache is full, enough time has
 	// passed, or when the database is shutting down.  Note that these are
@@ -445,13 +466,48 @@ func (c *dbCache) updateDB(fn func(ldbTx *leveldb.Transaction) error) error {
 // the database with the same function.
 type TreapForEacher interface {
 	ForEach(func(k, v []byte) bool)
+	Size() uint64
+}
+
+// writeBatch atomically commits all of the passed pending add/update/remove
+// updates to the underlying database as a single batch.  The batch is only
+// synced to disk when the sync flag is set.
+func (c *dbCache) writeBatch(pendingKeys, pendingRemove TreapForEacher, sync bool) error {
+	batch := new(leveldb.Batch)
+	pendingKeys.ForEach(func(k, v []byte) bool {
+		batch.Put(k, v)
+		return true
+	})
+	var numRemoved uint64
+	pendingRemove.ForEach(func(k, v []byte) bool {
+		batch.Delete(k)
+		numRemoved++
+		return true
+	})
+
+	if err := c.ldb.Write(batch, &opt.WriteOptions{Sync: sync}); err != nil {
+		return convertErr("failed to write leveldb batch", err)
+	}
+
+	atomic.AddUint64(&c.tombstones, numRemoved)
+	return nil
 }
 
 // commitTreaps atomically commits all of the passed pending add/update/remove
-// updates to the underlying database.
-func (c *dbCache) commitTreaps(pendingKeys, pendingRemove TreapForEacher) error {
+// updates to the underlying database.  Updates up to the configured batch size
+// are written as a single batch, which is only synced to disk when the sync
+// flag is set, while larger ones are written as a transaction, which is always
+// synced.
+func (c *dbCache) commitTreaps(pendingKeys, pendingRemove TreapForEacher, sync bool) error {
+	if c.batchSize > 0 && pendingKeys.Size()+pendingRemove.Size() <=
+		c.batchSize {
+
+		return c.writeBatch(pendingKeys, pendingRemove, sync)
+	}
+
 	// Perform all leveldb updates using an atomic transaction.
-	return c.updateDB(func(ldbTx *leveldb.Transaction) error {
+	var numRemoved uint64
+	err := c.updateDB(func(ldbTx *leveldb.Transaction) error {
 		var innerErr error
 		pendingKeys.ForEach(func(k, v []byte) bool {
 			if dbErr := ldbTx.Put(k, v, nil); dbErr != nil {
@@ -474,15 +530,32 @@ func (c *dbCache) commitTreaps(pendingKeys, pendingRemove TreapForEacher) error
 				innerErr = convertErr(str, dbErr)
 				return false
 			}
+			numRemoved++
 			return true
 		})
 		return innerErr
 	})
+	if err != nil {
+		return err
+	}
+
+	atomic.AddUint64(&c.tombstones, numRemoved)
+	return nil
+}
+
+// syncDue returns whether or not the next flush of the database cache needs to
+// sync the block data and the metadata to disk based on how much time has
+// elapsed since the last sync.
+//
+// This function MUST be called with the database write lock held.
+func (c *dbCache) syncDue() bool {
+	return time.Since(c.lastSync) >= c.syncInterval
 }
 
 // flush flushes the database cache to persistent storage.  This involes syncing
 // the block store and replaying all transactions that have been applied to the
-// cache to the underlying database.
+// cache to the underlying database.  The syncs are skipped when the configured
+// sync interval has not elapsed since the last one.
 //
 // This function MUST be called with the database write lock held.
 func (c *dbCache) flush() error {
@@ -491,9 +564,15 @@ func (c *dbCache) flush() error {
 	// Sync the current write file associated with the block store.  This is
 	// necessary before writing the metadata to prevent the case where the
 	// metadata contains information about a block which actually hasn't
-	// been written yet in unexpected shutdown scenarios.
-	if err := c.store.syncBlocks(); err != nil {
-		return err
+	// been written yet in unexpected shutdown scenarios.  When the sync is
+	// skipped, this case is instead handled by rolling back to the last
+	// intact block when the database is opened.
+	sync := c.syncDue()
+	if sync {
+		if err := c.store.syncBlocks(); err != nil {
+			return err
+		}
+		c.lastSync = c.lastFlush
 	}
 
 	// Since the cached keys to be added and removed use an immutable treap,
@@ -510,7 +589,7 @@ func (c *dbCache) flush() error {
 	}
 
 	// Perform all leveldb updates using an atomic transaction.
-	if err := c.commitTreaps(cachedKeys, cachedRemove); err != nil {
+	if err := c.commitTreaps(cachedKeys, cachedRemove, sync); err != nil {
 		return err
 	}
 
@@ -568,12 +647,13 @@ func (c *dbCache) commitTx(tx *transaction) error {
 	// Flush the cache and write the current transaction directly to the
 	// database if a flush is needed.
 	if c.needsFlush(tx) {
+		sync := c.syncDue()
 		if err := c.flush(); err != nil {
 			return err
 		}
 
-		// Perform all leveldb updates using an atomic transaction.
-		err := c.commitTreaps(tx.pendingKeys, tx.pendingRemove)
+		// Perform all leveldb updates atomically.
+		err := c.commitTreaps(tx.pendingKeys, tx.pendingRemove, sync)
 		if err != nil {
 			return err
 		}
@@ -625,7 +705,9 @@ func (c *dbCache) commitTx(tx *transaction) error {
 //
 // This function MUST be called with the database write lock held.
 func (c *dbCache) Close() error {
-	// Flush any outstanding cached entries to disk.
+	// Flush any outstanding cached entries to disk and ensure they are
+	// synced regardless of the sync interval.
+	c.lastSync = time.Time{}
 	if err := c.flush(); err != nil {
 		// Even if there is an error while flushing, attempt to close
 		// the underlying database.  The error is ignored since it would
@@ -646,14 +728,17 @@ func (c *dbCache) Close() error {
 // newDbCache returns a new database cache instance backed by the provided
 // leveldb instance.  The cache will be flushed to leveldb when the max size
 // exceeds the provided value or it has been longer than the provided interval
-// since the last flush.
-func newDbCache(ldb *leveldb.DB, store *blockStore, maxSize uint64, flushIntervalSecs uint32) *dbCache {
+// since the last flush.  The flushes are batched and synced as configured by
+// the passed write config.
+func newDbCache(ldb *leveldb.DB, store blockBackend, maxSize uint64, flushIntervalSecs uint32, write WriteConfig) *dbCache {
 	return &dbCache{
 		ldb:           ldb,
 		store:         store,
 		maxSize:       maxSize,
 		flushInterval: time.Second * time.Duration(flushIntervalSecs),
 		lastFlush:     time.Now(),
+		batchSize:     write.BatchSize,
+		syncInterval:  write.SyncInterval,
 		cachedKeys:    treap.NewImmutable(),
 		cachedRemove:  treap.NewImmutable(),
 	}
@@ -25,5 +25,58 @@ database path as a string and the block network:
 	if err != nil {
 		// Handle error
 	}
+
+The package also provides the database type of "ldb", which takes the same
+parameters.  It shares the metadata handling, but stores the blocks in a second
+leveldb database instead of flat files, which performs better on file systems
+that handle the large flat files poorly.  The migrate command of dbtool copies a
+database between the two types.
+
+Block Storage
+
+Both types optionally take the cache size, the flush seconds and a
+BlockStorageConfig after the block network.  It allows storing the blocks on a
+different volume than the metadata and configuring the size of the flat block
+files.  With "ffldb", block files which were not written to for a configured
+age can also be moved to a cold directory, such as a slower volume or a mounted
+object store, from which they are still read:
+
+	storage := ffldb.BlockStorageConfig{
+		BlocksDir: "path/to/blocks",
+		ColdDir:   "path/to/cold/blocks",
+		ColdAge:   30 * 24 * time.Hour,
+	}
+	db, err := database.Open("ffldb", "path/to/database", wire.MainNet,
+		uint64(0), uint32(0), storage)
+	if err != nil {
+		// Handle error
+	}
+
+Write Config
+
+A WriteConfig may be passed after the BlockStorageConfig to tune how writes are
+batched and synced to disk.  Syncing less often speeds up writing many blocks,
+such as during the initial block download, at the risk of losing the blocks
+written shortly before a crash:
+
+	write := ffldb.WriteConfig{
+		WriteBuffer:  64 * 1024 * 1024,
+		BatchSize:    32 * 1024 * 1024,
+		SyncInterval: time.Minute,
+	}
+	db, err := database.Open("ffldb", "path/to/database", wire.MainNet,
+		uint64(0), uint32(0), ffldb.BlockStorageConfig{}, write)
+	if err != nil {
+		// Handle error
+	}
+
+Recovery
+
+When a database is opened, the checksums of the most recently written blocks
+are verified.  Should they be damaged or missing, for example after a crash
+while the block files were being written, the database is rolled back to the
+last intact block instead of failing to open.  The removed blocks are logged
+and have to be downloaded again.  A damaged metadata database is recovered from
+its table files in the same way.
 */
 package ffldb
@@ -6,6 +6,7 @@ package ffldb
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/bourbaki-czz/classzz/database"
 	"github.com/bourbaki-czz/classzz/wire"
@@ -16,25 +17,89 @@ var log = czzlog.Disabled
 
 const (
 	dbType = "ffldb"
+
+	// ldbDbType is the type of the driver which stores the blocks in a
+	// leveldb database instead of flat files.
+	ldbDbType = "ldb"
 )
 
-// parseArgs parses the arguments from the database Open/Create methods.
-func parseArgs(funcName string, args ...interface{}) (string, wire.BitcoinNet, uint64, uint32, error) {
-	if len(args) < 2 || len(args) > 4 {
-		return "", 0, 0, 0, fmt.Errorf("invalid arguments to %s.%s -- "+
-			"expected database path and block network with optional cache size "+
-			"and flush seconds", dbType, funcName)
+// BlockStorageConfig configures where and how the blocks are stored.  It may
+// be passed to the database Open/Create methods as the optional argument after
+// the flush seconds.  The zero value keeps the blocks along with the metadata
+// in files of the default size.
+type BlockStorageConfig struct {
+	// BlocksDir is the directory housing the blocks.  It defaults to the
+	// database path.  It allows keeping the blocks on a different volume
+	// than the metadata.
+	BlocksDir string
+
+	// MaxFileSize is the maximum size in bytes of each flat block file.
+	// It defaults to 512 MiB and is ignored by the ldb driver.
+	MaxFileSize uint32
+
+	// ColdDir is the directory flat block files are moved to once they
+	// were not written to for ColdAge, typically on a cheaper, slower
+	// volume or a mounted object store.  Moved files are still read from
+	// there.  Tiering is disabled when it is empty and it is not supported
+	// by the ldb driver.
+	ColdDir string
+
+	// ColdAge is the age after which flat block files are moved to
+	// ColdDir.  When it is zero, no files are moved, but the files already
+	// moved are still read from ColdDir.
+	ColdAge time.Duration
+}
+
+// WriteConfig tunes how writes are batched and synced to disk.  It may be passed
+// to the database Open/Create methods as the optional argument after the block
+// storage config.  The zero value syncs all data whenever the cache is flushed,
+// which is the safest setting.
+type WriteConfig struct {
+	// WriteBuffer is the size in bytes of the in-memory buffers of the
+	// leveldb databases, which are written to sorted tables once full.
+	// Larger buffers result in fewer and larger tables.  It defaults to
+	// 4 MiB.
+	WriteBuffer int
+
+	// BatchSize is the maximum size in bytes of a cache flush which is
+	// written as a single batch through the leveldb journal.  Larger
+	// flushes are written as a transaction, which writes and syncs its own
+	// tables.  It should not exceed the write buffer since leveldb writes
+	// larger batches as a transaction anyway.  Batches are disabled when
+	// it is zero.
+	BatchSize uint64
+
+	// SyncInterval is the minimum interval between syncs of the block
+	// data and the metadata to disk.  Cache flushes in between are handed
+	// to the operating system without waiting for them to reach the disk,
+	// so the data written during the last interval might be lost in case
+	// of a crash or power loss.  The database is rolled back to the last
+	// intact block when it is opened then.  Flushes are always synced when
+	// it is zero.
+	SyncInterval time.Duration
+}
+
+// parseArgs parses the arguments from the database Open/Create methods of the
+// passed driver type.
+func parseArgs(dbType, funcName string, args ...interface{}) (string, wire.BitcoinNet, uint64, uint32, BlockStorageConfig, WriteConfig, error) {
+	var storage BlockStorageConfig
+	var write WriteConfig
+	if len(args) < 2 || len(args) > 6 {
+		return "", 0, 0, 0, storage, write, fmt.Errorf("invalid "+
+			"arguments to %s.%s -- expected database path and block "+
+			"network with optional cache size, flush seconds, block "+
+			"storage config and write config", dbType, funcName)
 	}
 
 	dbPath, ok := args[0].(string)
 	if !ok {
-		return "", 0, 0, 0, fmt.Errorf("first argument to %s.%s is invalid -- "+
+		return "", 0, 0, 0, storage, write, fmt.Errorf("first argument to %s.%s is invalid -- "+
 			"expected database path string", dbType, funcName)
 	}
 
 	network, ok := args[1].(wire.BitcoinNet)
 	if !ok {
-		return "", 0, 0, 0, fmt.Errorf("second argument to %s.%s is invalid -- "+
+		return "", 0, 0, 0, storage, write, fmt.Errorf("second argument to %s.%s is invalid -- "+
 			"expected block network", dbType, funcName)
 	}
 
@@ -43,7 +108,7 @@ func parseArgs(funcName string, args ...interface{}) (string, wire.BitcoinNet, u
 	if len(args) > 2 {
 		cacheSize, ok = args[2].(uint64)
 		if !ok {
-			return "", 0, 0, 0, fmt.Errorf("third argument to %s.%s is invalid -- "+
+			return "", 0, 0, 0, storage, write, fmt.Errorf("third argument to %s.%s is invalid -- "+
 				"expected cache size", dbType, funcName)
 		}
 	}
@@ -51,34 +116,59 @@ func parseArgs(funcName string, args ...interface{}) (string, wire.BitcoinNet, u
 	if len(args) > 3 {
 		flushSecs, ok = args[3].(uint32)
 		if !ok {
-			return "", 0, 0, 0, fmt.Errorf("third argument to %s.%s is invalid -- "+
+			return "", 0, 0, 0, storage, write, fmt.Errorf("third argument to %s.%s is invalid -- "+
 				"expected flush seconds", dbType, funcName)
 		}
 	}
 
-	return dbPath, network, cacheSize, flushSecs, nil
-}
+	if len(args) > 4 {
+		storage, ok = args[4].(BlockStorageConfig)
+		if !ok {
+			return "", 0, 0, 0, storage, write, fmt.Errorf("fifth argument to %s.%s is invalid -- "+
+				"expected block storage config", dbType, funcName)
+		}
+	}
 
-// openDBDriver is the callback provided during driver registration that opens
-// an existing database for use.
-func openDBDriver(args ...interface{}) (database.DB, error) {
-	dbPath, network, cacheSize, flushSecs, err := parseArgs("Open", args...)
-	if err != nil {
-		return nil, err
+	if len(args) > 5 {
+		write, ok = args[5].(WriteConfig)
+		if !ok {
+			return "", 0, 0, 0, storage, write, fmt.Errorf("sixth argument to %s.%s is invalid -- "+
+				"expected write config", dbType, funcName)
+		}
 	}
 
-	return openDB(dbPath, network, false, cacheSize, flushSecs)
+	return dbPath, network, cacheSize, flushSecs, storage, write, nil
 }
 
-// createDBDriver is the callback provided during driver registration that
-// creates, initializes, and opens a database for use.
-func createDBDriver(args ...interface{}) (database.DB, error) {
-	dbPath, network, cacheSize, flushSecs, err := parseArgs("Create", args...)
-	if err != nil {
-		return nil, err
+// openDBDriver returns the callback provided during driver registration that
+// opens an existing database of the passed driver type for use.
+func openDBDriver(dbType string) func(args ...interface{}) (database.DB, error) {
+	return func(args ...interface{}) (database.DB, error) {
+		dbPath, network, cacheSize, flushSecs, storage, write, err :=
+			parseArgs(dbType, "Open", args...)
+		if err != nil {
+			return nil, err
+		}
+
+		return openDB(dbType, dbPath, network, false, cacheSize,
+			flushSecs, storage, write)
 	}
+}
+
+// createDBDriver returns the callback provided during driver registration that
+// creates, initializes, and opens a database of the passed driver type for
+// use.
+func createDBDriver(dbType string) func(args ...interface{}) (database.DB, error) {
+	return func(args ...interface{}) (database.DB, error) {
+		dbPath, network, cacheSize, flushSecs, storage, write, err :=
+			parseArgs(dbType, "Create", args...)
+		if err != nil {
+			return nil, err
+		}
 
-	return openDB(dbPath, network, true, cacheSize, flushSecs)
+		return openDB(dbType, dbPath, network, true, cacheSize,
+			flushSecs, storage, write)
+	}
 }
 
 // useLogger is the callback provided during driver registration that sets the
@@ -88,15 +178,18 @@ func useLogger(logger czzlog.Logger) {
 }
 
 func init() {
-	// Register the driver.
-	driver := database.Driver{
-		DbType:    dbType,
-		Create:    createDBDriver,
-		Open:      openDBDriver,
-		UseLogger: useLogger,
-	}
-	if err := database.RegisterDriver(driver); err != nil {
-		panic(fmt.Sprintf("Failed to regiser database driver '%s': %v",
-			dbType, err))
+	// Register the drivers.  Both share the metadata database and only
+	// differ in how the blocks are stored.
+	for _, dbType := range []string{dbType, ldbDbType} {
+		driver := database.Driver{
+			DbType:    dbType,
+			Create:    createDBDriver(dbType),
+			Open:      openDBDriver(dbType),
+			UseLogger: useLogger,
+		}
+		if err := database.RegisterDriver(driver); err != nil {
+			panic(fmt.Sprintf("Failed to regiser database driver '%s': %v",
+				dbType, err))
+		}
 	}
 }
@@ -11,8 +11,10 @@ import (
 	"reflect"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
 	"github.com/bourbaki-czz/classzz/database"
 	"github.com/bourbaki-czz/classzz/database/ffldb"
 	"github.com/bourbaki-czz/czzutil"
@@ -37,8 +39,9 @@ func TestCreateOpenFail(t *testing.T) {
 	// Ensure that attempting to open a database with the wrong number of
 	// parameters returns the expected error.
 	wantErr := fmt.Errorf("invalid arguments to %s.Open -- expected "+
-		"database path and block network with optional cache size and flush seconds", dbType)
-	_, err = database.Open(dbType, 1, 2, 3, 4, 5)
+		"database path and block network with optional cache size, "+
+		"flush seconds, block storage config and write config", dbType)
+	_, err = database.Open(dbType, 1, 2, 3, 4, 5, 6, 7)
 	if err.Error() != wantErr.Error() {
 		t.Errorf("Open: did not receive expected error - got %v, "+
 			"want %v", err, wantErr)
@@ -70,8 +73,9 @@ func TestCreateOpenFail(t *testing.T) {
 	// Ensure that attempting to create a database with the wrong number of
 	// parameters returns the expected error.
 	wantErr = fmt.Errorf("invalid arguments to %s.Create -- expected "+
-		"database path and block network with optional cache size and flush seconds", dbType)
-	_, err = database.Create(dbType, 1, 2, 3, 4, 5)
+		"database path and block network with optional cache size, "+
+		"flush seconds, block storage config and write config", dbType)
+	_, err = database.Create(dbType, 1, 2, 3, 4, 5, 6, 7)
 	if err.Error() != wantErr.Error() {
 		t.Errorf("Create: did not receive expected error - got %v, "+
 			"want %v", err, wantErr)
@@ -286,3 +290,149 @@ func TestInterface(t *testing.T) {
 		testInterface(t, db)
 	})
 }
+
+// TestLdbPersistence ensures blocks stored by the driver which houses the
+// blocks in leveldb are still valid after closing and reopening the database.
+func TestLdbPersistence(t *testing.T) {
+	t.Parallel()
+
+	// Create a new database to run tests against.
+	const ldbDbType = "ldb"
+	dbPath := filepath.Join(os.TempDir(), "ldb-persistencetest")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(ldbDbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Errorf("Failed to create test database (%s) %v", ldbDbType, err)
+		return
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+
+	// Ensure the driver type is the expected value.
+	if gotDbType := db.Type(); gotDbType != ldbDbType {
+		t.Errorf("Type: unepxected driver type - got %v, want %v",
+			gotDbType, ldbDbType)
+		return
+	}
+
+	genesisBlock := czzutil.NewBlock(chaincfg.MainNetParams.GenesisBlock)
+	genesisHash := chaincfg.MainNetParams.GenesisHash
+	err = db.Update(func(tx database.Tx) error {
+		return tx.StoreBlock(genesisBlock)
+	})
+	if err != nil {
+		t.Errorf("StoreBlock: unexpected error: %v", err)
+		return
+	}
+
+	// Close and reopen the database to ensure the block persists.
+	db.Close()
+	db, err = database.Open(ldbDbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Errorf("Failed to open test database (%s) %v", ldbDbType, err)
+		return
+	}
+	defer db.Close()
+
+	err = db.View(func(tx database.Tx) error {
+		genesisBlockBytes, _ := genesisBlock.Bytes()
+		gotBytes, err := tx.FetchBlock(genesisHash)
+		if err != nil {
+			return fmt.Errorf("FetchBlock: unexpected error: %v",
+				err)
+		}
+		if !reflect.DeepEqual(gotBytes, genesisBlockBytes) {
+			return fmt.Errorf("FetchBlock: stored block mismatch")
+		}
+
+		region := database.BlockRegion{
+			Hash:   genesisHash,
+			Offset: 4,
+			Len:    chainhash.HashSize,
+		}
+		gotRegion, err := tx.FetchBlockRegion(&region)
+		if err != nil {
+			return fmt.Errorf("FetchBlockRegion: unexpected error: %v",
+				err)
+		}
+		if !reflect.DeepEqual(gotRegion, genesisBlockBytes[4:36]) {
+			return fmt.Errorf("FetchBlockRegion: region mismatch")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("View: unexpected error: %v", err)
+	}
+}
+
+// TestWriteConfig ensures blocks and metadata written with batches and without
+// syncing every flush are still valid after closing and reopening the database.
+func TestWriteConfig(t *testing.T) {
+	t.Parallel()
+
+	write := ffldb.WriteConfig{
+		WriteBuffer:  1024 * 1024,
+		BatchSize:    1024 * 1024,
+		SyncInterval: time.Hour,
+	}
+	genesisBlock := czzutil.NewBlock(chaincfg.MainNetParams.GenesisBlock)
+	genesisHash := chaincfg.MainNetParams.GenesisHash
+	for _, dbType := range []string{dbType, "ldb"} {
+		// Create a new database with a cache size of one byte so every
+		// transaction flushes it.
+		dbPath := filepath.Join(os.TempDir(), dbType+"-writeconfigtest")
+		_ = os.RemoveAll(dbPath)
+		db, err := database.Create(dbType, dbPath, blockDataNet,
+			uint64(1), uint32(0), ffldb.BlockStorageConfig{}, write)
+		if err != nil {
+			t.Errorf("Failed to create test database (%s) %v", dbType,
+				err)
+			return
+		}
+		defer os.RemoveAll(dbPath)
+
+		err = db.Update(func(tx database.Tx) error {
+			return tx.StoreBlock(genesisBlock)
+		})
+		if err != nil {
+			db.Close()
+			t.Errorf("%s: StoreBlock: unexpected error: %v", dbType, err)
+			return
+		}
+		for i := 0; i < 3; i++ {
+			err = db.Update(func(tx database.Tx) error {
+				return tx.Metadata().Put([]byte("key"), []byte{byte(i)})
+			})
+			if err != nil {
+				db.Close()
+				t.Errorf("%s: Put: unexpected error: %v", dbType, err)
+				return
+			}
+		}
+
+		// Close and reopen the database to ensure the block and the
+		// metadata persist.
+		db.Close()
+		db, err = database.Open(dbType, dbPath, blockDataNet,
+			uint64(1), uint32(0), ffldb.BlockStorageConfig{}, write)
+		if err != nil {
+			t.Errorf("Failed to open test database (%s) %v", dbType, err)
+			return
+		}
+		err = db.View(func(tx database.Tx) error {
+			if _, err := tx.FetchBlock(genesisHash); err != nil {
+				return fmt.Errorf("FetchBlock: unexpected error: %v",
+					err)
+			}
+			value := tx.Metadata().Get([]byte("key"))
+			if !reflect.DeepEqual(value, []byte{2}) {
+				return fmt.Errorf("Get: got %x, want 02", value)
+			}
+			return nil
+		})
+		db.Close()
+		if err != nil {
+			t.Errorf("%s: View: %v", dbType, err)
+		}
+	}
+}
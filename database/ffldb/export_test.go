@@ -15,12 +15,17 @@ import "github.com/bourbaki-czz/classzz/database"
 
 // TstRunWithMaxBlockFileSize runs the passed function with the maximum allowed
 // file size for the database set to the provided value.  The value will be set
-// back to the original value upon completion.
+// back to the original value upon completion.  The function is simply run for
+// databases which do not store the blocks in flat files.
 func TstRunWithMaxBlockFileSize(idb database.DB, size uint32, fn func()) {
-	ffldb := idb.(*db)
-	origSize := ffldb.store.maxBlockFileSize
+	store, ok := idb.(*db).store.(*blockStore)
+	if !ok {
+		fn()
+		return
+	}
+	origSize := store.maxBlockFileSize
 
-	ffldb.store.maxBlockFileSize = size
+	store.maxBlockFileSize = size
 	fn()
-	ffldb.store.maxBlockFileSize = origSize
+	store.maxBlockFileSize = origSize
 }
@@ -0,0 +1,444 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains the implementation functions for reading, writing, and
+// otherwise working with the blocks of the ldb driver, which houses them in a
+// separate leveldb database rather than in flat files.
+
+package ffldb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/btcsuite/goleveldb/leveldb"
+	"github.com/btcsuite/goleveldb/leveldb/opt"
+	"github.com/btcsuite/goleveldb/leveldb/util"
+)
+
+const (
+	// blocksDbName is the name used for the leveldb database housing the
+	// blocks of the ldb driver.
+	blocksDbName = "blocks"
+
+	// ldbBlockPrefix is the key prefix of the stored blocks.
+	ldbBlockPrefix = 'b'
+
+	// ldbHeightPrefix is the key prefix of the height index used to find
+	// the blocks to delete when pruning.
+	ldbHeightPrefix = 'h'
+
+	// verifyLdbBlocks is the number of most recent blocks which are
+	// verified when the database is opened.
+	verifyLdbBlocks = 256
+)
+
+var (
+	// ldbSyncKeyName is the key written synchronously to persist all
+	// previously written blocks.
+	ldbSyncKeyName = []byte("s")
+)
+
+// -----------------------------------------------------------------------------
+// The ldb block store assigns every written block the next number of an
+// increasing sequence and stores it under that number.  The sequence number
+// takes the place of the file offset in the block location and in the write
+// cursor position stored in the metadata while the file number is always 0.
+// This allows the metadata and the blocks to be reconciled the same way as with
+// flat files.
+//
+// The serialized format for keys and values of the blocks is:
+//
+//   <'b'><sequence number> = <height><network><block length><block><checksum>
+//
+// and the serialized format for the keys of the height index is:
+//
+//   <'h'><height><sequence number> = <empty>
+//
+// The sequence numbers and heights in keys are stored in big endian so they
+// sort in numerical order.  The checksum is a Castagnoli CRC-32 of the network,
+// block length and block, just like in the flat files.
+// -----------------------------------------------------------------------------
+
+// ldbBlockKey returns the key of the block with the passed sequence number.
+func ldbBlockKey(seq uint32) []byte {
+	var key [5]byte
+	key[0] = ldbBlockPrefix
+	binary.BigEndian.PutUint32(key[1:], seq)
+	return key[:]
+}
+
+// ldbHeightKey returns the height index key of the block with the passed
+// height and sequence number.
+func ldbHeightKey(height, seq uint32) []byte {
+	var key [9]byte
+	key[0] = ldbHeightPrefix
+	binary.BigEndian.PutUint32(key[1:5], height)
+	binary.BigEndian.PutUint32(key[5:9], seq)
+	return key[:]
+}
+
+// ldbBlockStore houses the blocks of the ldb driver in a leveldb database.  It
+// avoids the large, preallocated flat files which perform poorly on some file
+// systems.
+type ldbBlockStore struct {
+	// network is the specific network stored with each block.
+	network wire.BitcoinNet
+
+	// basePath is the base path used for the block and metadata databases.
+	basePath string
+
+	// ldb is the leveldb database housing the blocks.
+	ldb *leveldb.DB
+
+	// mtx protects nextSeq, which is the sequence number of the next
+	// block to write.
+	mtx     sync.RWMutex
+	nextSeq uint32
+
+	// tombstones is the number of deletions written since the last
+	// compaction.  It must be accessed atomically.
+	tombstones uint64
+}
+
+// Ensure ldbBlockStore implements the blockBackend interface.
+var _ blockBackend = (*ldbBlockStore)(nil)
+
+// writeBlock stores the specified raw block bytes under the next sequence
+// number and advances it.  The data is not synced to disk until syncBlocks is
+// called.
+//
+// This is part of the blockBackend interface.
+func (s *ldbBlockStore) writeBlock(rawBlock []byte, height uint32) (blockLocation, error) {
+	blockLen := uint32(len(rawBlock))
+	fullLen := blockLen + 12
+
+	serialized := make([]byte, 4+fullLen)
+	byteOrder.PutUint32(serialized[0:4], height)
+	record := serialized[4:]
+	byteOrder.PutUint32(record[0:4], uint32(s.network))
+	byteOrder.PutUint32(record[4:8], blockLen)
+	copy(record[8:], rawBlock)
+	checksum := crc32.Checksum(record[:fullLen-4], castagnoli)
+	binary.BigEndian.PutUint32(record[fullLen-4:], checksum)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	batch := new(leveldb.Batch)
+	batch.Put(ldbBlockKey(s.nextSeq), serialized)
+	batch.Put(ldbHeightKey(height, s.nextSeq), nil)
+	if err := s.ldb.Write(batch, nil); err != nil {
+		str := fmt.Sprintf("failed to write block %d: %v", s.nextSeq,
+			err)
+		return blockLocation{}, makeDbErr(database.ErrDriverSpecific,
+			str, err)
+	}
+
+	loc := blockLocation{
+		blockFileNum: 0,
+		fileOffset:   s.nextSeq,
+		blockLen:     fullLen,
+	}
+	s.nextSeq++
+	return loc, nil
+}
+
+// readRecord returns the serialized record of the block at the passed
+// location, which excludes the height.
+func (s *ldbBlockStore) readRecord(loc blockLocation) ([]byte, error) {
+	serialized, err := s.ldb.Get(ldbBlockKey(loc.fileOffset), nil)
+	if err != nil {
+		str := fmt.Sprintf("failed to read block %d: %v",
+			loc.fileOffset, err)
+		return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	if len(serialized) < 16 || uint32(len(serialized)-4) != loc.blockLen {
+		str := fmt.Sprintf("block %d has length %d, want %d",
+			loc.fileOffset, len(serialized)-4, loc.blockLen)
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	return serialized[4:], nil
+}
+
+// readBlock reads the specified block record and returns the serialized block.
+// It ensures the integrity of the block data by checking that the serialized
+// network matches the current network associated with the block store and
+// comparing the calculated checksum against the stored one.
+//
+// This is part of the blockBackend interface.
+func (s *ldbBlockStore) readBlock(hash *chainhash.Hash, loc blockLocation) ([]byte, error) {
+	record, err := s.readRecord(loc)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(record)
+	serializedChecksum := binary.BigEndian.Uint32(record[n-4:])
+	calculatedChecksum := crc32.Checksum(record[:n-4], castagnoli)
+	if serializedChecksum != calculatedChecksum {
+		str := fmt.Sprintf("block data for block %s checksum "+
+			"does not match - got %x, want %x", hash,
+			calculatedChecksum, serializedChecksum)
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+
+	serializedNet := byteOrder.Uint32(record[:4])
+	if serializedNet != uint32(s.network) {
+		str := fmt.Sprintf("block data for block %s is for the "+
+			"wrong network - got %d, want %d", hash, serializedNet,
+			uint32(s.network))
+		return nil, makeDbErr(database.ErrDriverSpecific, str, nil)
+	}
+
+	return record[8 : n-4], nil
+}
+
+// readBlockRegion reads the specified amount of data at the provided offset for
+// a given block location.  The offset is relative to the start of the
+// serialized block.
+//
+// This is part of the blockBackend interface.
+func (s *ldbBlockStore) readBlockRegion(loc blockLocation, offset, numBytes uint32) ([]byte, error) {
+	record, err := s.readRecord(loc)
+	if err != nil {
+		return nil, err
+	}
+
+	readOffset := 8 + uint64(offset)
+	if readOffset+uint64(numBytes) > uint64(len(record)-4) {
+		str := fmt.Sprintf("failed to read region from block %d, "+
+			"offset %d, len %d: out of range", loc.fileOffset,
+			offset, numBytes)
+		return nil, makeDbErr(database.ErrDriverSpecific, str, nil)
+	}
+	return record[readOffset : readOffset+uint64(numBytes)], nil
+}
+
+// deleteBlocks deletes all stored blocks with a height less than the provided
+// height.
+//
+// This is part of the blockBackend interface.
+func (s *ldbBlockStore) deleteBlocks(deleteBefore uint32) error {
+	if deleteBefore == 0 {
+		return nil
+	}
+
+	heightRange := &util.Range{
+		Start: []byte{ldbHeightPrefix},
+		Limit: ldbHeightKey(deleteBefore, 0),
+	}
+	batch := new(leveldb.Batch)
+	iter := s.ldb.NewIterator(heightRange, nil)
+	for iter.Next() {
+		seq := binary.BigEndian.Uint32(iter.Key()[5:9])
+		batch.Delete(ldbBlockKey(seq))
+		batch.Delete(iter.Key())
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return convertErr("failed to iterate height index", err)
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+
+	if err := s.ldb.Write(batch, nil); err != nil {
+		return convertErr("failed to delete blocks", err)
+	}
+	atomic.AddUint64(&s.tombstones, uint64(batch.Len()))
+	return nil
+}
+
+// syncBlocks ensures all blocks written so far are persisted by synchronously
+// writing the next sequence number.  Since leveldb appends all writes to a
+// single journal, this also syncs the writes which preceded it.
+//
+// This is part of the blockBackend interface.
+func (s *ldbBlockStore) syncBlocks() error {
+	s.mtx.RLock()
+	var seq [4]byte
+	byteOrder.PutUint32(seq[:], s.nextSeq)
+	s.mtx.RUnlock()
+
+	err := s.ldb.Put(ldbSyncKeyName, seq[:], &opt.WriteOptions{Sync: true})
+	if err != nil {
+		return convertErr("failed to sync blocks", err)
+	}
+	return nil
+}
+
+// writeCursorPos returns file number 0 and the sequence number of the next
+// block to write.
+//
+// This is part of the blockBackend interface.
+func (s *ldbBlockStore) writeCursorPos() (uint32, uint32) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return 0, s.nextSeq
+}
+
+// handleRollback deletes all blocks with a sequence number of at least the
+// passed one.  As with the flat files, the write cursor is repositioned
+// regardless of any errors, which are only logged since blocks which failed to
+// be deleted are overwritten later.
+//
+// This is part of the blockBackend interface.
+func (s *ldbBlockStore) handleRollback(oldBlockFileNum, oldBlockOffset uint32) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.nextSeq == oldBlockOffset {
+		return
+	}
+	defer func() {
+		s.nextSeq = oldBlockOffset
+	}()
+
+	log.Debugf("ROLLBACK: Rolling back to block %d", oldBlockOffset)
+
+	blockRange := &util.Range{
+		Start: ldbBlockKey(oldBlockOffset),
+		Limit: []byte{ldbBlockPrefix + 1},
+	}
+	batch := new(leveldb.Batch)
+	iter := s.ldb.NewIterator(blockRange, nil)
+	for iter.Next() {
+		seq := binary.BigEndian.Uint32(iter.Key()[1:5])
+		if len(iter.Value()) >= 4 {
+			height := byteOrder.Uint32(iter.Value()[0:4])
+			batch.Delete(ldbHeightKey(height, seq))
+		}
+		batch.Delete(ldbBlockKey(seq))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		log.Warnf("ROLLBACK: Failed to iterate blocks: %v", err)
+		return
+	}
+
+	err := s.ldb.Write(batch, &opt.WriteOptions{Sync: true})
+	if err != nil {
+		log.Warnf("ROLLBACK: Failed to delete blocks: %v", err)
+		return
+	}
+	atomic.AddUint64(&s.tombstones, uint64(batch.Len()))
+}
+
+// verifyBlocks verifies the checksums of the last verifyLdbBlocks blocks before
+// the passed sequence number and returns the sequence number of the first
+// damaged one, or the passed one when they are intact.  Missing sequence
+// numbers are not considered damaged since the blocks might have been pruned.
+//
+// This is part of the blockBackend interface.
+func (s *ldbBlockStore) verifyBlocks(endFileNum, endOffset uint32) (uint32, uint32, error) {
+	blockRange := &util.Range{
+		Start: []byte{ldbBlockPrefix},
+		Limit: ldbBlockKey(endOffset),
+	}
+	iter := s.ldb.NewIterator(blockRange, nil)
+	defer iter.Release()
+
+	good := endOffset
+	for ok, n := iter.Last(), 0; ok && n < verifyLdbBlocks; ok, n = iter.Prev(), n+1 {
+		seq := binary.BigEndian.Uint32(iter.Key()[1:5])
+		record := iter.Value()
+		if len(record) < 16 {
+			good = seq
+			continue
+		}
+		record = record[4:]
+		checksum := binary.BigEndian.Uint32(record[len(record)-4:])
+		if byteOrder.Uint32(record[:4]) != uint32(s.network) ||
+			crc32.Checksum(record[:len(record)-4], castagnoli) != checksum {
+
+			good = seq
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return 0, 0, convertErr("failed to verify blocks", err)
+	}
+	return 0, good, nil
+}
+
+// storePath returns the base path of the database.
+//
+// This is part of the blockBackend interface.
+func (s *ldbBlockStore) storePath() string {
+	return s.basePath
+}
+
+// stats returns statistics about the leveldb database housing the blocks.
+//
+// This is part of the blockBackend interface.
+func (s *ldbBlockStore) stats() (database.StoreStats, error) {
+	return ldbStoreStats("blocks", filepath.Join(s.basePath, blocksDbName),
+		s.ldb, atomic.LoadUint64(&s.tombstones))
+}
+
+// compact compacts the leveldb database housing the blocks.
+//
+// This is part of the blockBackend interface.
+func (s *ldbBlockStore) compact() error {
+	tombstones, err := compactLdb(s.ldb, &s.tombstones)
+	if err != nil {
+		return err
+	}
+	log.Debugf("Compacted blocks dropping %d tombstones", tombstones)
+	return nil
+}
+
+// close closes the leveldb database housing the blocks.
+//
+// This is part of the blockBackend interface.
+func (s *ldbBlockStore) close() error {
+	if err := s.ldb.Close(); err != nil {
+		return convertErr("failed to close block database", err)
+	}
+	return nil
+}
+
+// newLdbBlockStore opens (or creates) the leveldb database housing the blocks
+// below the passed base path with the passed write buffer size and returns a
+// block store with the next sequence number positioned after the last stored
+// block.
+func newLdbBlockStore(basePath string, network wire.BitcoinNet, writeBuffer int) (*ldbBlockStore, error) {
+	opts := opt.Options{
+		Strict:      opt.DefaultStrict,
+		Compression: opt.NoCompression,
+		WriteBuffer: writeBuffer,
+	}
+	ldb, err := openLevelDB(filepath.Join(basePath, blocksDbName), &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Look for the last stored block to determine what the write cursor
+	// position is from the viewpoint of the stored blocks.
+	var nextSeq uint32
+	iter := ldb.NewIterator(util.BytesPrefix([]byte{ldbBlockPrefix}), nil)
+	if iter.Last() {
+		nextSeq = binary.BigEndian.Uint32(iter.Key()[1:5]) + 1
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		_ = ldb.Close()
+		return nil, convertErr("failed to scan blocks", err)
+	}
+
+	log.Tracef("Scan found next block sequence number %d", nextSeq)
+	return &ldbBlockStore{
+		network:  network,
+		basePath: basePath,
+		ldb:      ldb,
+		nextSeq:  nextSeq,
+	}, nil
+}
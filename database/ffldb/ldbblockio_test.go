@@ -0,0 +1,105 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/database"
+)
+
+// TestLdbBlockStore ensures the leveldb block store reads, deletes and rolls
+// back blocks and restores its write cursor when reopened.
+func TestLdbBlockStore(t *testing.T) {
+	t.Parallel()
+
+	dbPath := filepath.Join(os.TempDir(), "ldb-blockstoretest")
+	_ = os.RemoveAll(dbPath)
+	defer os.RemoveAll(dbPath)
+
+	store, err := newLdbBlockStore(dbPath, blockDataNet, 0)
+	if err != nil {
+		t.Fatalf("newLdbBlockStore: unexpected error: %v", err)
+	}
+
+	// Write a block at each of the heights 0 to 4.
+	var hash chainhash.Hash
+	var locs []blockLocation
+	for height := uint32(0); height < 5; height++ {
+		loc, err := store.writeBlock([]byte{byte(height), 0xaa, 0xbb}, height)
+		if err != nil {
+			t.Fatalf("writeBlock #%d: unexpected error: %v", height, err)
+		}
+		locs = append(locs, loc)
+	}
+	if _, seq := store.writeCursorPos(); seq != 5 {
+		t.Fatalf("writeCursorPos: got %d, want 5", seq)
+	}
+
+	raw, err := store.readBlock(&hash, locs[3])
+	if err != nil {
+		t.Fatalf("readBlock: unexpected error: %v", err)
+	}
+	if !bytes.Equal(raw, []byte{3, 0xaa, 0xbb}) {
+		t.Fatalf("readBlock: got %x, want 03aabb", raw)
+	}
+	region, err := store.readBlockRegion(locs[3], 1, 2)
+	if err != nil {
+		t.Fatalf("readBlockRegion: unexpected error: %v", err)
+	}
+	if !bytes.Equal(region, []byte{0xaa, 0xbb}) {
+		t.Fatalf("readBlockRegion: got %x, want aabb", region)
+	}
+	if _, err := store.readBlockRegion(locs[3], 2, 2); err == nil {
+		t.Fatal("readBlockRegion: out of range region did not fail")
+	}
+
+	// Blocks below the deletion height must be gone while the others are
+	// still readable.
+	if err := store.deleteBlocks(2); err != nil {
+		t.Fatalf("deleteBlocks: unexpected error: %v", err)
+	}
+	if _, err := store.readBlock(&hash, locs[1]); !isDriverSpecificErr(err) {
+		t.Fatalf("readBlock of deleted block: got %v, want "+
+			"driver specific error", err)
+	}
+	if _, err := store.readBlock(&hash, locs[2]); err != nil {
+		t.Fatalf("readBlock: unexpected error: %v", err)
+	}
+
+	// Rolled back blocks must be gone and the write cursor repositioned.
+	store.handleRollback(0, 4)
+	if _, err := store.readBlock(&hash, locs[4]); !isDriverSpecificErr(err) {
+		t.Fatalf("readBlock of rolled back block: got %v, want "+
+			"driver specific error", err)
+	}
+	if err := store.syncBlocks(); err != nil {
+		t.Fatalf("syncBlocks: unexpected error: %v", err)
+	}
+	if err := store.close(); err != nil {
+		t.Fatalf("close: unexpected error: %v", err)
+	}
+
+	// The write cursor must be positioned after the last block on reopen.
+	store, err = newLdbBlockStore(dbPath, blockDataNet, 0)
+	if err != nil {
+		t.Fatalf("newLdbBlockStore: unexpected error: %v", err)
+	}
+	defer store.close()
+	if _, seq := store.writeCursorPos(); seq != 4 {
+		t.Fatalf("writeCursorPos after reopen: got %d, want 4", seq)
+	}
+}
+
+// isDriverSpecificErr returns whether err is a database error with the
+// ErrDriverSpecific code.
+func isDriverSpecificErr(err error) bool {
+	dbErr, ok := err.(database.Error)
+	return ok && dbErr.ErrorCode == database.ErrDriverSpecific
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains the functions for compacting the database and reporting
+// statistics about its storage.
+
+package ffldb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/btcsuite/goleveldb/leveldb"
+	"github.com/btcsuite/goleveldb/leveldb/util"
+)
+
+// Enforce db implements the database.Maintainer interface.
+var _ database.Maintainer = (*db)(nil)
+
+// dirStats returns the number and total size of the regular files directly in
+// the passed directory whose names are accepted by match.
+func dirStats(path string, match func(name string) bool) (int, int64, error) {
+	dir, err := os.Open(path)
+	if err != nil {
+		return 0, 0, makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	infos, err := dir.Readdir(-1)
+	dir.Close()
+	if err != nil {
+		return 0, 0, makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+
+	var files int
+	var size int64
+	for _, info := range infos {
+		if !info.Mode().IsRegular() || !match(info.Name()) {
+			continue
+		}
+		files++
+		size += info.Size()
+	}
+	return files, size, nil
+}
+
+// ldbLevelStats returns the non-empty levels of the passed leveldb database.
+// They are parsed from the compaction statistics since those are the only ones
+// which include the level numbers.
+func ldbLevelStats(ldb *leveldb.DB) ([]database.LevelStats, error) {
+	stats, err := ldb.GetProperty("leveldb.stats")
+	if err != nil {
+		return nil, convertErr("failed to fetch leveldb stats", err)
+	}
+
+	var levels []database.LevelStats
+	for _, line := range strings.Split(stats, "\n") {
+		var level, tables int
+		var sizeMiB float64
+		n, _ := fmt.Sscanf(line, " %d | %d | %f", &level, &tables, &sizeMiB)
+		if n != 3 || tables == 0 {
+			continue
+		}
+		levels = append(levels, database.LevelStats{
+			Level:  level,
+			Tables: tables,
+			Size:   int64(sizeMiB * bytesMiB),
+		})
+	}
+	return levels, nil
+}
+
+// ldbStoreStats returns statistics about the passed leveldb database housed in
+// the passed directory.  The size amplification is estimated as the ratio of
+// the size on disk to the size of the deepest level, which is where the bulk of
+// the live data ends up.
+func ldbStoreStats(name, path string, ldb *leveldb.DB, tombstones uint64) (database.StoreStats, error) {
+	files, size, err := dirStats(path, func(string) bool { return true })
+	if err != nil {
+		return database.StoreStats{}, err
+	}
+	levels, err := ldbLevelStats(ldb)
+	if err != nil {
+		return database.StoreStats{}, err
+	}
+
+	amplification := 1.0
+	if len(levels) > 0 && levels[len(levels)-1].Size > 0 {
+		amplification = float64(size) /
+			float64(levels[len(levels)-1].Size)
+	}
+
+	return database.StoreStats{
+		Name:              name,
+		Engine:            "leveldb",
+		Files:             files,
+		Size:              size,
+		Tombstones:        tombstones,
+		SizeAmplification: amplification,
+		Levels:            levels,
+	}, nil
+}
+
+// compactLdb compacts the whole passed leveldb database and subtracts the
+// tombstones counted before the compaction started from the passed counter.
+// It returns the number of subtracted tombstones.
+func compactLdb(ldb *leveldb.DB, tombstones *uint64) (uint64, error) {
+	dropped := atomic.LoadUint64(tombstones)
+	if err := ldb.CompactRange(util.Range{}); err != nil {
+		return 0, convertErr("failed to compact leveldb database", err)
+	}
+	atomic.AddUint64(tombstones, ^(dropped - 1))
+	return dropped, nil
+}
+
+// StorageStats returns statistics about the metadata database and the store
+// housing the blocks.
+//
+// This function is part of the database.Maintainer interface implementation.
+func (db *db) StorageStats() ([]database.StoreStats, error) {
+	db.closeLock.RLock()
+	defer db.closeLock.RUnlock()
+	if db.closed {
+		return nil, makeDbErr(database.ErrDbNotOpen, errDbNotOpenStr, nil)
+	}
+
+	metadataPath := filepath.Join(db.dbPath, metadataDbName)
+	metadataStats, err := ldbStoreStats(metadataDbName, metadataPath,
+		db.cache.ldb, atomic.LoadUint64(&db.cache.tombstones))
+	if err != nil {
+		return nil, err
+	}
+	blockStats, err := db.store.stats()
+	if err != nil {
+		return nil, err
+	}
+	return []database.StoreStats{metadataStats, blockStats}, nil
+}
+
+// Compact flushes the database cache and then compacts the metadata database
+// and the store housing the blocks.  Only flushing the cache blocks writes, the
+// compaction itself runs concurrently with other transactions.
+//
+// This function is part of the database.Maintainer interface implementation.
+func (db *db) Compact() error {
+	// Flush the cache under the write lock so the cached deletions are
+	// included in the compaction.  The lock order matches begin.
+	db.writeLock.Lock()
+	db.closeLock.RLock()
+	defer db.closeLock.RUnlock()
+	if db.closed {
+		db.writeLock.Unlock()
+		return makeDbErr(database.ErrDbNotOpen, errDbNotOpenStr, nil)
+	}
+	err := db.cache.flush()
+	db.writeLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tombstones, err := compactLdb(db.cache.ldb, &db.cache.tombstones)
+	if err != nil {
+		return err
+	}
+	log.Debugf("Compacted metadata dropping %d tombstones", tombstones)
+
+	return db.store.compact()
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/database"
+)
+
+// TestMaintenance ensures both drivers report statistics about their stores
+// and drop the counted tombstones when compacted.
+func TestMaintenance(t *testing.T) {
+	t.Parallel()
+
+	for _, dbType := range []string{dbType, ldbDbType} {
+		dbPath := filepath.Join(os.TempDir(), dbType+"-maintenancetest")
+		_ = os.RemoveAll(dbPath)
+		defer os.RemoveAll(dbPath)
+
+		idb, err := openDB(dbType, dbPath, blockDataNet, true, 0, 0,
+			BlockStorageConfig{}, WriteConfig{})
+		if err != nil {
+			t.Fatalf("%s: openDB: unexpected error: %v", dbType, err)
+		}
+		defer idb.Close()
+		pdb := idb.(*db)
+
+		// Write and delete some keys and flush them so the deletions
+		// reach leveldb.
+		err = idb.Update(func(tx database.Tx) error {
+			return tx.Metadata().Put([]byte("key"), []byte("value"))
+		})
+		if err != nil {
+			t.Fatalf("%s: Put: unexpected error: %v", dbType, err)
+		}
+		err = idb.Update(func(tx database.Tx) error {
+			return tx.Metadata().Delete([]byte("key"))
+		})
+		if err != nil {
+			t.Fatalf("%s: Delete: unexpected error: %v", dbType, err)
+		}
+		pdb.writeLock.Lock()
+		err = pdb.cache.flush()
+		pdb.writeLock.Unlock()
+		if err != nil {
+			t.Fatalf("%s: flush: unexpected error: %v", dbType, err)
+		}
+
+		stats, err := pdb.StorageStats()
+		if err != nil {
+			t.Fatalf("%s: StorageStats: unexpected error: %v", dbType,
+				err)
+		}
+		if len(stats) != 2 || stats[0].Name != metadataDbName ||
+			stats[1].Name != "blocks" {
+
+			t.Fatalf("%s: StorageStats: unexpected stores %+v", dbType,
+				stats)
+		}
+		if stats[0].Files == 0 || stats[0].Tombstones != 1 {
+			t.Fatalf("%s: StorageStats: got %d files and %d tombstones, "+
+				"want files and 1 tombstone", dbType, stats[0].Files,
+				stats[0].Tombstones)
+		}
+
+		if err := pdb.Compact(); err != nil {
+			t.Fatalf("%s: Compact: unexpected error: %v", dbType, err)
+		}
+		if n := atomic.LoadUint64(&pdb.cache.tombstones); n != 0 {
+			t.Fatalf("%s: got %d tombstones after compaction, want 0",
+				dbType, n)
+		}
+	}
+}
@@ -8,7 +8,11 @@ import (
 	"fmt"
 	"hash/crc32"
 
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
 	"github.com/bourbaki-czz/classzz/database"
+	"github.com/btcsuite/goleveldb/leveldb"
+	ldberrors "github.com/btcsuite/goleveldb/leveldb/errors"
+	"github.com/btcsuite/goleveldb/leveldb/opt"
 )
 
 // The serialized write cursor location format is:
@@ -47,8 +51,80 @@ func deserializeWriteRow(writeRow []byte) (uint32, uint32, error) {
 	return fileNum, fileOffset, nil
 }
 
+// openLevelDB opens the leveldb database at the passed path.  When the
+// database is corrupted, it is recovered from its table files instead, which
+// drops the damaged parts.
+func openLevelDB(path string, opts *opt.Options) (*leveldb.DB, error) {
+	ldb, err := leveldb.OpenFile(path, opts)
+	if err == nil {
+		return ldb, nil
+	}
+	if !ldberrors.IsCorrupted(err) {
+		return nil, convertErr(err.Error(), err)
+	}
+
+	log.Warnf("***Database corruption detected***: %v", err)
+	log.Infof("Recovering leveldb database at %q...", path)
+	ldb, err = leveldb.RecoverFile(path, opts)
+	if err != nil {
+		return nil, convertErr(err.Error(), err)
+	}
+	log.Info("Recovered leveldb database")
+	return ldb, nil
+}
+
+// rollbackBlocks rolls the blocks back to the passed write cursor position by
+// removing all blocks written after it and their block index entries.  It
+// returns the hashes of the removed blocks.
+func rollbackBlocks(pdb *db, fileNum, offset uint32) ([]chainhash.Hash, error) {
+	pdb.store.handleRollback(fileNum, offset)
+
+	// Remove the block index entries of the blocks which were written at
+	// or after the new write cursor position.  Committing the transaction
+	// also stores the new write cursor position in the metadata, so it is
+	// flushed immediately.
+	var removed []chainhash.Hash
+	err := pdb.Update(func(tx database.Tx) error {
+		blockIdx := tx.Metadata().Bucket(blockIdxBucketName)
+		err := blockIdx.ForEach(func(k, v []byte) error {
+			if len(v) < blockLocSize {
+				return nil
+			}
+			loc := deserializeBlockLoc(v)
+			if loc.blockFileNum < fileNum || (loc.blockFileNum ==
+				fileNum && loc.fileOffset < offset) {
+
+				return nil
+			}
+
+			var hash chainhash.Hash
+			copy(hash[:], k)
+			removed = append(removed, hash)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for i := range removed {
+			if err := blockIdx.Delete(removed[i][:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return removed, pdb.cache.flush()
+}
+
 // reconcileDB reconciles the metadata with the flat block files on disk.  It
 // will also initialize the underlying database if the create flag is set.
+//
+// The most recently written blocks are verified and when they are damaged or
+// missing, or the write cursor in the metadata is damaged, the database is
+// rolled back to the last intact block rather than failing to open.
 func reconcileDB(pdb *db, create bool) (database.DB, error) {
 	// Perform initial internal bucket and value creation during database
 	// creation.
@@ -58,11 +134,13 @@ func reconcileDB(pdb *db, create bool) (database.DB, error) {
 		}
 	}
 
-	// Load the current write cursor position from the metadata.
+	// Load the current write cursor position from the metadata.  When it
+	// is damaged, assume the position of the block data on disk, which is
+	// verified below.
 	var curFileNum, curOffset uint32
 	err := pdb.View(func(tx database.Tx) error {
 		writeRow := tx.Metadata().Get(writeLocKeyName)
-		if writeRow == nil {
+		if writeRow == nil || len(writeRow) != 12 {
 			str := "write cursor does not exist"
 			return makeDbErr(database.ErrCorruption, str, nil)
 		}
@@ -71,8 +149,17 @@ func reconcileDB(pdb *db, create bool) (database.DB, error) {
 		curFileNum, curOffset, err = deserializeWriteRow(writeRow)
 		return err
 	})
+	cursorDamaged := false
 	if err != nil {
-		return nil, err
+		if dbErr, ok := err.(database.Error); !ok ||
+			dbErr.ErrorCode != database.ErrCorruption {
+
+			return nil, err
+		}
+
+		log.Warnf("***Database corruption detected***: %v", err)
+		curFileNum, curOffset = pdb.store.writeCursorPos()
+		cursorDamaged = true
 	}
 
 	// When the write cursor position found by scanning the block files on
@@ -82,36 +169,58 @@ func reconcileDB(pdb *db, create bool) (database.DB, error) {
 	// the middle of being written.  Since the metadata isn't updated until
 	// after the block data is written, this is effectively just a rollback
 	// to the known good point before the unclean shutdown.
-	wc := pdb.store.writeCursor
-	if wc.curFileNum > curFileNum || (wc.curFileNum == curFileNum &&
-		wc.curOffset > curOffset) {
+	wcFileNum, wcOffset := pdb.store.writeCursorPos()
+	if wcFileNum > curFileNum || (wcFileNum == curFileNum &&
+		wcOffset > curOffset) {
 
 		log.Info("Detected unclean shutdown - Repairing...")
 		log.Debugf("Metadata claims file %d, offset %d. Block data is "+
 			"at file %d, offset %d", curFileNum, curOffset,
-			wc.curFileNum, wc.curOffset)
+			wcFileNum, wcOffset)
 		pdb.store.handleRollback(curFileNum, curOffset)
 		log.Infof("Database sync complete")
 	}
 
-	// When the write cursor position found by scanning the block files on
-	// disk is BEFORE the position the metadata believes to be true, return
-	// a corruption error.  Since sync is called after each block is written
-	// and before the metadata is updated, this should only happen in the
-	// case of missing, deleted, or truncated block files, which generally
-	// is not an easily recoverable scenario.  In the future, it might be
-	// possible to rescan and rebuild the metadata from the block files,
-	// however, that would need to happen with coordination from a higher
-	// layer since it could invalidate other metadata.
-	if wc.curFileNum < curFileNum || (wc.curFileNum == curFileNum &&
-		wc.curOffset < curOffset) {
+	// Verify the most recently written blocks up to the position the
+	// metadata believes to be true, or the end of the block data on disk
+	// when it is BEFORE that position.  Since sync is called after each
+	// block is written and before the metadata is updated, the block data
+	// should only be missing or damaged in the case of deleted, truncated
+	// or corrupted block files.  Roll back to the last intact block in
+	// that case, which removes the missing and damaged blocks along with
+	// all blocks written after them from the block index.
+	endFileNum, endOffset := curFileNum, curOffset
+	if wcFileNum < curFileNum || (wcFileNum == curFileNum &&
+		wcOffset < curOffset) {
+
+		endFileNum, endOffset = wcFileNum, wcOffset
+	}
+	goodFileNum, goodOffset, err := pdb.store.verifyBlocks(endFileNum,
+		endOffset)
+	if err != nil {
+		return nil, err
+	}
+	if !cursorDamaged && goodFileNum == curFileNum && goodOffset == curOffset {
+		return pdb, nil
+	}
 
+	if !cursorDamaged {
 		str := fmt.Sprintf("metadata claims file %d, offset %d, but "+
-			"block data is at file %d, offset %d", curFileNum,
-			curOffset, wc.curFileNum, wc.curOffset)
+			"intact block data ends at file %d, offset %d",
+			curFileNum, curOffset, goodFileNum, goodOffset)
 		log.Warnf("***Database corruption detected***: %v", str)
-		return nil, makeDbErr(database.ErrCorruption, str, nil)
 	}
+	log.Infof("Rolling back to the last intact block at file %d, offset "+
+		"%d...", goodFileNum, goodOffset)
+	removed, err := rollbackBlocks(pdb, goodFileNum, goodOffset)
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range removed {
+		log.Warnf("Removed missing or damaged block %v", hash)
+	}
+	log.Infof("Database recovery complete -- removed %d blocks",
+		len(removed))
 
 	return pdb, nil
 }
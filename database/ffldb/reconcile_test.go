@@ -0,0 +1,167 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/czzutil"
+	"github.com/btcsuite/goleveldb/leveldb"
+)
+
+// TestRecovery ensures a database with damaged or missing recent blocks is
+// rolled back to the last intact block when it is opened.
+func TestRecovery(t *testing.T) {
+	t.Parallel()
+
+	// Create blocks which only differ in their nonce, so all of them
+	// have the same size.
+	var blocks []*czzutil.Block
+	for i := 0; i < 5; i++ {
+		msgBlock := *chaincfg.MainNetParams.GenesisBlock
+		msgBlock.Header.Nonce += uint64(i)
+		block := czzutil.NewBlock(&msgBlock)
+		block.SetHeight(int32(i))
+		blocks = append(blocks, block)
+	}
+	blockBytes, err := blocks[0].Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: unexpected error: %v", err)
+	}
+	recordLen := int64(len(blockBytes)) + 12
+
+	tests := []struct {
+		name   string
+		dbType string
+		damage func(dbPath string) error
+		intact int
+	}{
+		{
+			name:   "flipped byte",
+			dbType: dbType,
+			damage: func(dbPath string) error {
+				file, err := os.OpenFile(blockFilePath(dbPath, 0),
+					os.O_RDWR, 0)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				_, err = file.WriteAt([]byte{0xff}, 3*recordLen+20)
+				return err
+			},
+			intact: 3,
+		},
+		{
+			name:   "truncated file",
+			dbType: dbType,
+			damage: func(dbPath string) error {
+				return os.Truncate(blockFilePath(dbPath, 0),
+					4*recordLen+10)
+			},
+			intact: 4,
+		},
+		{
+			name:   "damaged ldb record",
+			dbType: ldbDbType,
+			damage: func(dbPath string) error {
+				ldb, err := leveldb.OpenFile(filepath.Join(dbPath,
+					blocksDbName), nil)
+				if err != nil {
+					return err
+				}
+				defer ldb.Close()
+				serialized, err := ldb.Get(ldbBlockKey(2), nil)
+				if err != nil {
+					return err
+				}
+				serialized[20] ^= 0xff
+				return ldb.Put(ldbBlockKey(2), serialized, nil)
+			},
+			intact: 2,
+		},
+	}
+
+	for _, test := range tests {
+		dbPath := filepath.Join(os.TempDir(), "ffldb-recoverytest")
+		_ = os.RemoveAll(dbPath)
+
+		idb, err := openDB(test.dbType, dbPath, blockDataNet, true, 0, 0,
+			BlockStorageConfig{}, WriteConfig{})
+		if err != nil {
+			t.Fatalf("%s: openDB: unexpected error: %v", test.name, err)
+		}
+		err = idb.Update(func(tx database.Tx) error {
+			for _, block := range blocks {
+				if err := tx.StoreBlock(block); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		idb.Close()
+		if err != nil {
+			t.Fatalf("%s: StoreBlock: unexpected error: %v", test.name,
+				err)
+		}
+
+		if err := test.damage(dbPath); err != nil {
+			t.Fatalf("%s: unable to damage blocks: %v", test.name, err)
+		}
+
+		// Reopen the database and ensure the damaged block and all
+		// blocks stored after it were removed.
+		idb, err = openDB(test.dbType, dbPath, blockDataNet, false, 0, 0,
+			BlockStorageConfig{}, WriteConfig{})
+		if err != nil {
+			t.Fatalf("%s: openDB: unexpected error: %v", test.name, err)
+		}
+		err = idb.View(func(tx database.Tx) error {
+			for i, block := range blocks {
+				_, err := tx.FetchBlock(block.Hash())
+				if i < test.intact && err != nil {
+					t.Errorf("%s: FetchBlock #%d: unexpected "+
+						"error: %v", test.name, i, err)
+				}
+				if i >= test.intact && !checkDbError(t, test.name,
+					err, database.ErrBlockNotFound) {
+
+					return nil
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%s: View: unexpected error: %v", test.name, err)
+		}
+
+		// Ensure the removed blocks can be stored again.
+		err = idb.Update(func(tx database.Tx) error {
+			for _, block := range blocks[test.intact:] {
+				if err := tx.StoreBlock(block); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%s: StoreBlock: unexpected error: %v", test.name,
+				err)
+		}
+		err = idb.View(func(tx database.Tx) error {
+			_, err := tx.FetchBlock(blocks[len(blocks)-1].Hash())
+			return err
+		})
+		if err != nil {
+			t.Errorf("%s: FetchBlock: unexpected error: %v", test.name,
+				err)
+		}
+		idb.Close()
+		_ = os.RemoveAll(dbPath)
+	}
+}
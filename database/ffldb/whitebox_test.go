@@ -189,7 +189,8 @@ func TestCornerCases(t *testing.T) {
 	// directory is needed.
 	testName := "openDB: fail due to file at target location"
 	wantErrCode := database.ErrDriverSpecific
-	idb, err := openDB(dbPath, blockDataNet, true, 0, 0)
+	idb, err := openDB(dbType, dbPath, blockDataNet, true, 0, 0,
+		BlockStorageConfig{}, WriteConfig{})
 	if !checkDbError(t, testName, err, wantErrCode) {
 		if err == nil {
 			idb.Close()
@@ -201,7 +202,8 @@ func TestCornerCases(t *testing.T) {
 	// Remove the file and create the database to run tests against.  It
 	// should be successful this time.
 	_ = os.RemoveAll(dbPath)
-	idb, err = openDB(dbPath, blockDataNet, true, 0, 0)
+	idb, err = openDB(dbType, dbPath, blockDataNet, true, 0, 0,
+		BlockStorageConfig{}, WriteConfig{})
 	if err != nil {
 		t.Errorf("openDB: unexpected error: %v", err)
 		return
@@ -217,7 +219,7 @@ func TestCornerCases(t *testing.T) {
 		t.Errorf("os.Mkdir: unexpected error: %v", err)
 		return
 	}
-	store := idb.(*db).store
+	store := idb.(*db).store.(*blockStore)
 	_, err = store.writeBlock([]byte{0x00}, 0)
 	if !checkDbError(t, testName, err, database.ErrDriverSpecific) {
 		return
@@ -296,7 +298,7 @@ func resetDatabase(tc *testContext) bool {
 	}
 
 	// Reset the mock files.
-	store := tc.db.(*db).store
+	store := tc.db.(*db).store.(*blockStore)
 	wc := store.writeCursor
 	wc.curFile.Lock()
 	if wc.curFile.file != nil {
@@ -321,7 +323,7 @@ func testWriteFailures(tc *testContext) bool {
 	}
 
 	// Ensure file sync errors during flush return the expected error.
-	store := tc.db.(*db).store
+	store := tc.db.(*db).store.(*blockStore)
 	testName := "flush: file sync failure"
 	store.writeCursor.Lock()
 	oldFile := store.writeCursor.curFile
@@ -420,7 +422,7 @@ func testBlockFileErrors(tc *testContext) bool {
 
 	// Ensure errors in blockFile and openFile when requesting invalid file
 	// numbers.
-	store := tc.db.(*db).store
+	store := tc.db.(*db).store.(*blockStore)
 	testName := "blockFile invalid file open"
 	_, err := store.blockFile(^uint32(0))
 	if !checkDbError(tc.t, testName, err, database.ErrDriverSpecific) {
@@ -635,7 +637,7 @@ func TestFailureScenarios(t *testing.T) {
 	// files with the test data set and replace the file-related functions
 	// to make use of mock files in memory.  This allows injection of
 	// various file-related errors.
-	store := idb.(*db).store
+	store := idb.(*db).store.(*blockStore)
 	store.maxBlockFileSize = 1024 // 1KiB
 	store.openWriteFileFunc = func(fileNum uint32) (filer, error) {
 		if file, ok := tc.files[fileNum]; ok {
@@ -475,3 +475,65 @@ type DB interface {
 	// back or committed).
 	Close() error
 }
+
+// LevelStats describes a level of a store organized as a log-structured merge
+// tree.
+type LevelStats struct {
+	// Level is the number of the level.
+	Level int
+
+	// Tables is the number of tables in the level.
+	Tables int
+
+	// Size is the total size of the tables in the level in bytes.
+	Size int64
+}
+
+// StoreStats describes one of the stores backing a database.
+type StoreStats struct {
+	// Name identifies the store within the database, for example metadata
+	// or blocks.
+	Name string
+
+	// Engine is the storage engine of the store, for example leveldb or
+	// flatfiles.
+	Engine string
+
+	// Files is the number of files of the store on disk.
+	Files int
+
+	// Size is the total size of the files of the store on disk in bytes.
+	Size int64
+
+	// Tombstones is the number of deletions written since the store was
+	// last compacted by Compact.  Compaction drops them along with the
+	// data they delete.
+	Tombstones uint64
+
+	// SizeAmplification is the ratio of the size on disk to the estimated
+	// size of the live data.  It is 1 for stores which do not keep
+	// overwritten or deleted data around.
+	SizeAmplification float64
+
+	// Levels describes the non-empty levels of stores organized as a
+	// log-structured merge tree.
+	Levels []LevelStats
+}
+
+// Maintainer is implemented by databases whose storage can be compacted and
+// inspected.  Long-running nodes use it to reclaim the space of overwritten
+// and deleted data, which otherwise slowly degrades performance.
+type Maintainer interface {
+	// StorageStats returns statistics about the stores backing the
+	// database.
+	StorageStats() ([]StoreStats, error)
+
+	// Compact compacts all stores backing the database.  It might take a
+	// long time for large databases, but the database stays usable while
+	// it runs.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrDbNotOpen if the database is not open
+	Compact() error
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/database"
+)
+
+// maintenanceCheckInterval is the interval at which the database maintenance
+// handler checks whether the maintenance window was entered.
+const maintenanceCheckInterval = time.Minute
+
+// maintenanceWindow is a daily UTC time window.  The window wraps around
+// midnight when it ends before it starts.
+type maintenanceWindow struct {
+	start time.Duration
+	end   time.Duration
+}
+
+// parseMaintenanceWindow parses a window of the form HH:MM-HH:MM.
+func parseMaintenanceWindow(s string) (*maintenanceWindow, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("window %q is not of the form HH:MM-HH:MM", s)
+	}
+
+	var bounds [2]time.Duration
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("window %q is not of the form "+
+				"HH:MM-HH:MM", s)
+		}
+		bounds[i] = time.Duration(t.Hour())*time.Hour +
+			time.Duration(t.Minute())*time.Minute
+	}
+	if bounds[0] == bounds[1] {
+		return nil, fmt.Errorf("window %q is empty", s)
+	}
+	return &maintenanceWindow{start: bounds[0], end: bounds[1]}, nil
+}
+
+// opening returns the time the window containing t opened, and whether t is
+// within a window at all.
+func (w *maintenanceWindow) opening(t time.Time) (time.Time, bool) {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	offset := t.Sub(midnight)
+
+	if w.start < w.end {
+		return midnight.Add(w.start), offset >= w.start && offset < w.end
+	}
+
+	// The window wraps around midnight.
+	if offset >= w.start {
+		return midnight.Add(w.start), true
+	}
+	return midnight.Add(w.start - 24*time.Hour), offset < w.end
+}
+
+// String returns the window in the form it is parsed from.
+func (w *maintenanceWindow) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()),
+			int(d.Minutes())%60)
+	}
+	return clock(w.start) + "-" + clock(w.end)
+}
+
+// dbMaintenanceHandler compacts the block database once within every opening
+// of the configured maintenance window.  It must be run as a goroutine.
+func (s *server) dbMaintenanceHandler(window *maintenanceWindow) {
+	defer s.wg.Done()
+
+	maintainer, ok := s.db.(database.Maintainer)
+	if !ok {
+		srvrLog.Warnf("The %s database does not support compaction",
+			s.db.Type())
+		return
+	}
+
+	srvrLog.Infof("Compacting the block database daily between %v UTC",
+		window)
+
+	var lastOpening time.Time
+	ticker := time.NewTicker(maintenanceCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			opening, ok := window.opening(now)
+			if !ok || opening.Equal(lastOpening) {
+				continue
+			}
+			lastOpening = opening
+
+			srvrLog.Infof("Compacting the block database")
+			start := time.Now()
+			if err := maintainer.Compact(); err != nil {
+				srvrLog.Errorf("Unable to compact the block "+
+					"database: %v", err)
+				continue
+			}
+			srvrLog.Infof("Compacted the block database in %v",
+				time.Since(start).Round(time.Second))
+
+		case <-s.quit:
+			return
+		}
+	}
+}
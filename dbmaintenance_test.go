@@ -0,0 +1,67 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestMaintenanceWindow ensures maintenance windows are parsed and report the
+// opening of the window containing a time as expected.
+func TestMaintenanceWindow(t *testing.T) {
+	t.Parallel()
+
+	// Ensure invalid windows are rejected.
+	for _, s := range []string{"", "02:00", "02:00-", "2-4", "25:00-04:00",
+		"02:00-02:00", "02:00-03:00-04:00"} {
+		if _, err := parseMaintenanceWindow(s); err == nil {
+			t.Errorf("parseMaintenanceWindow(%q): expected error", s)
+		}
+	}
+
+	day := func(d int, clock string) time.Time {
+		c, err := time.Parse("15:04", clock)
+		if err != nil {
+			t.Fatalf("time.Parse: %v", err)
+		}
+		return time.Date(2019, time.June, d, c.Hour(), c.Minute(), 0, 0,
+			time.UTC)
+	}
+
+	tests := []struct {
+		window  string
+		now     time.Time
+		opening time.Time
+		within  bool
+	}{
+		{"02:00-04:00", day(10, "01:59"), day(10, "02:00"), false},
+		{"02:00-04:00", day(10, "02:00"), day(10, "02:00"), true},
+		{"02:00-04:00", day(10, "03:59"), day(10, "02:00"), true},
+		{"02:00-04:00", day(10, "04:00"), day(10, "02:00"), false},
+		{"23:00-01:00", day(10, "23:30"), day(10, "23:00"), true},
+		{"23:00-01:00", day(11, "00:30"), day(10, "23:00"), true},
+		{"23:00-01:00", day(11, "01:00"), day(10, "23:00"), false},
+		{"23:00-01:00", day(11, "22:59"), day(10, "23:00"), false},
+	}
+	for _, test := range tests {
+		window, err := parseMaintenanceWindow(test.window)
+		if err != nil {
+			t.Errorf("parseMaintenanceWindow(%q): %v", test.window, err)
+			continue
+		}
+		if window.String() != test.window {
+			t.Errorf("String: got %q, want %q", window.String(),
+				test.window)
+		}
+
+		opening, within := window.opening(test.now)
+		if within != test.within || !opening.Equal(test.opening) {
+			t.Errorf("window %s at %v: got (%v, %v), want (%v, %v)",
+				test.window, test.now, opening, within,
+				test.opening, test.within)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestDiffusionDelays ensures locally submitted transactions are announced to
+// txDiffusionFanout peers right away, preferring outbound peers, and to the
+// others after a delay of up to the maximum.
+func TestDiffusionDelays(t *testing.T) {
+	const maxDelay = 5 * time.Second
+
+	inbound := []bool{true, false, true, true, false, true, false, true}
+	immediate := make(map[int]int)
+	for round := 0; round < 100; round++ {
+		delays := diffusionDelays(inbound, maxDelay)
+		var n int
+		for i, delay := range delays {
+			if delay < 0 || delay > maxDelay {
+				t.Fatalf("diffusionDelays: delay %v of peer %d is "+
+					"out of range", delay, i)
+			}
+			if delay != 0 {
+				continue
+			}
+			if inbound[i] {
+				t.Fatalf("diffusionDelays: inbound peer %d is "+
+					"announced first over outbound peers", i)
+			}
+			immediate[i]++
+			n++
+		}
+		if n != txDiffusionFanout {
+			t.Fatalf("diffusionDelays: got %d peers announced right "+
+				"away, want %d", n, txDiffusionFanout)
+		}
+	}
+
+	// The peers announced right away are picked at random among the
+	// outbound peers.
+	if len(immediate) != 3 {
+		t.Fatalf("diffusionDelays: got %d distinct outbound peers "+
+			"announced first, want 3", len(immediate))
+	}
+
+	// Inbound peers are announced first when there are not enough
+	// outbound peers.
+	delays := diffusionDelays([]bool{true, false}, maxDelay)
+	if delays[0] != 0 || delays[1] != 0 {
+		t.Fatalf("diffusionDelays: got delays %v, want none", delays)
+	}
+
+	// Without a maximum delay all the peers are announced right away.
+	for i, delay := range diffusionDelays(inbound, 0) {
+		if delay != 0 {
+			t.Fatalf("diffusionDelays: got delay %v of peer %d "+
+				"without a maximum delay", delay, i)
+		}
+	}
+}
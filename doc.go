@@ -25,8 +25,23 @@ Application Options:
   -C, --configfile=         Path to configuration file
   -b, --datadir=            Directory to store data
       --logdir=             Directory to log output.
+      --logformat=          Format of the log output: text, or json or logfmt
+                            with one structured record per line {text, json,
+                            logfmt} (default: text)
+      --logmaxsize=         Rotate the log file once it reaches this size in
+                            MiB (default: 10)
+      --logmaxrolls=        Number of rotated log files to keep (0 to keep
+                            all) (default: 3)
+      --logrotateinterval=  Also rotate the log file at every multiple of this
+                            interval since the Unix epoch, for example 24h for
+                            daily rotation at midnight UTC (0 to disable)
   -a, --addpeer=            Add a peer to connect with at startup
       --connect=            Connect only to the specified peers at startup
+      --bridgepeer=         Add a bridge peer, a node of a coin pool custodian,
+                            to stay connected with -- Bridge peers are granted
+                            the bridge permissions and are announced the
+                            entangle transactions right away (eg. 203.0.113.5
+                            or pool.example.com:8333)
       --nolisten            Disable listening for incoming connections -- NOTE:
                             Listening is automatically disabled if the --connect
                             or --proxy options are used without also specifying
@@ -39,8 +54,27 @@ Application Options:
                             are {s, m, h}.  Minimum 1 second (24h0m0s)
       --banthreshold=       Maximum allowed ban score before disconnecting and
                             banning misbehaving peers.
-      --whitelist=          Add an IP network or IP that will not be banned.
-                            (eg. 192.168.1.0/24 or ::1)
+      --whitelist=          Add an IP network or IP whose peers are granted
+                            permissions, optionally prefixed with a comma
+                            separated list of permissions {noban, forcerelay,
+                            mempool, download, bridge, all} followed by '@'
+                            (default: noban,mempool,download) (eg.
+                            192.168.1.0/24, ::1 or noban,forcerelay@10.0.0.0/8)
+      --whitebind=          Add an interface/port to listen for connections
+                            whose peers are granted permissions, optionally
+                            prefixed with permissions like --whitelist (eg.
+                            download@127.0.0.1:8336)
+      --inboundratelimit=   Max number of inbound connections accepted from a
+                            single IP per minute, 0 for no limit (10)
+      --maxhalfopen=        Max number of inbound connections which have not
+                            completed the handshake yet, 0 for no limit (32)
+      --handshaketimeout=   Time peers have to complete the version handshake
+                            before they are disconnected.  Valid time units are
+                            {s, m, h}.  Minimum 1 second (30s)
+      --maxuploadtarget=    Maximum number of MiB uploaded to peers per 24
+                            hours, historical blocks are no longer served to
+                            non-whitelisted peers once it is reached (0 for no
+                            limit)
   -u, --rpcuser=            Username for RPC connections
   -P, --rpcpass=            Password for RPC connections
       --rpclimituser=       Username for limited RPC connections
@@ -52,6 +86,9 @@ Application Options:
       --rpcmaxclients=      Max number of RPC clients for standard connections
                             (10)
       --rpcmaxwebsockets=   Max number of RPC websocket connections (25)
+      --rpcslowlog=         Log the RPC requests which take longer than this
+                            duration with their parameters redacted, for
+                            example 2s (0 to disable)
       --rpcquirks           Mirror some JSON-RPC quirks of Bitcoin Core -- NOTE:
                             Discouraged unless interoperability issues need to
                             be worked around
@@ -61,11 +98,25 @@ Application Options:
       --notls               Disable TLS for the RPC server -- NOTE: This is only
                             allowed if the RPC server is bound to localhost
       --nodnsseed           Disable DNS seeding for peers
+      --dnsseed=            Add a DNS seed to query for peers in addition to
+                            the seeds of the network
+      --seedfile=           File listing peers to bootstrap from, one host or
+                            host:port per line
+      --asmap=              File mapping IP networks to autonomous systems used
+                            to diversify outbound peers by AS instead of by /16
       --externalip=         Add an ip to the list of local addresses we claim to
                             listen on to peers
       --proxy=              Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)
       --proxyuser=          Username for proxy server
       --proxypass=          Password for proxy server
+      --ipv4proxy=          Connect to IPv4 peers via this SOCKS5 proxy instead
+                            of --proxy, using the --proxyuser and --proxypass
+                            credentials (eg. 127.0.0.1:1080)
+      --ipv6proxy=          Connect to IPv6 peers via this SOCKS5 proxy instead
+                            of --proxy, using the --proxyuser and --proxypass
+                            credentials
+      --nolanproxy          Connect directly to peers on loopback, private and
+                            link-local networks instead of through a proxy
       --onion=              Connect to tor hidden services via SOCKS5 proxy
                             (eg. 127.0.0.1:9050)
       --onionuser=          Username for onion proxy server
@@ -73,18 +124,83 @@ Application Options:
       --noonion             Disable connecting to tor hidden services
       --torisolation        Enable Tor stream isolation by randomizing user
                             credentials for each connection.
+      --torcontrol=         Create an onion service for inbound connections
+                            via the Tor control port (eg. 127.0.0.1:9051)
+      --torpassword=        Password for the Tor control port -- cookie
+                            authentication is used when not set
+      --toranonymous        Only connect through the Tor proxy, prefer onion
+                            peers and do not advertise clearnet addresses
       --testnet             Use the test network
       --regtest             Use the regression test network
       --simnet              Use the simulation test network
+      --signet              Use the signed test network
+      --chaindef=           Use the private network described by the chain
+                            definition file
       --addcheckpoint=      Add a custom checkpoint.  Format: '<height>:<hash>'
+      --deployment=         Override the start and expire times of a rule
+                            change deployment on regtest, simnet or a chain
+                            definition.  Format:
+                            '<name>:<starttime>:<expiretime>' where name is
+                            dummy, csv, seq, sigchecks or utxocommitment
+      --entangleheight=     Override the height entangle transactions are
+                            accepted from on regtest, simnet or a chain
+                            definition
       --nocheckpoints       Disable built-in checkpoints.  Don't do this unless
                             you know what you're doing.
       --uacomment=          Comment to add to the user agent --
                             See BIP 14 for more information.
-      --dbtype=             Database backend to use for the Block Chain (ffldb)
-      --profile=            Enable HTTP profiling on given port -- NOTE port
-                            must be between 1024 and 65536
+      --dbtype=             Database backend to use for the Block Chain: ffldb
+                            or ldb (ffldb)
+      --dbcompactwindow=    Compact the block database once a day within this
+                            UTC time window, for example 02:00-04:00
+      --dbprofile=          Database write profile supplying the defaults of
+                            the dbwritebuffer, dbbatchsize and dbsyncinterval
+                            options: default or throughput, which speeds up
+                            the initial block download at the risk of losing
+                            the most recent blocks on a crash {default,
+                            throughput} (default)
+      --dbwritebuffer=      The size in MiB of the write buffers of the
+                            database (4)
+      --dbbatchsize=        The maximum size in MiB of a database flush which
+                            is written as a single batch (0 to disable
+                            batches)
+      --dbsyncinterval=     The minimum interval between syncs of the database
+                            to disk, for example 1m (0 to sync every flush)
+      --shutdowntimeout=    Time the server has to stop on shutdown before the
+                            chain state is flushed and the database closed
+                            regardless (0 to wait forever) (2m)
+      --blocksdir=          Directory to store the blocks in instead of the
+                            data directory, such as a different volume
+      --blockfilesize=      The maximum size in MiB of each block file of the
+                            ffldb database (512)
+      --blockcolddir=       Directory to move block files of the ffldb
+                            database to once they are older than blockcoldage,
+                            such as a slower volume or a mounted object store
+      --blockcoldage=       The number of days after the last write a block
+                            file is moved to blockcolddir (30)
+      --profile=            Enable HTTP profiling on given port,
+                            authenticated with the RPC admin credentials --
+                            NOTE port must be between 1024 and 65536
+      --profilepush=        URL of an endpoint to periodically push cpu and
+                            heap profiles to -- Credentials may be given in the
+                            URL
+      --profilepushinterval= Interval between the pushes of the profiles to
+                            the profilepush endpoint (10m0s)
       --cpuprofile=         Write CPU profile to the specified file
+      --lockstats           Record the wait and hold times of the chain and
+                            mempool locks, served with the metrics, and watch
+                            for the locks held too long
+      --lockwatchdog=       Log the stacks of all goroutines when a lock is
+                            held longer than this duration with lockstats
+                            enabled (0 to disable) (30s)
+      --metricslisten=      Add an interface/port to serve Prometheus metrics
+                            on at /metrics -- the metrics are not served
+                            unless specified (default port: 8336, testnet:
+                            18336)
+      --bridgelisten=       Add an interface/port to serve the status page of
+                            the entangle bridge on at / and as JSON at
+                            /api/status -- the status is not served unless
+                            specified (default port: 8337, testnet: 18337)
   -d, --debuglevel=         Logging level for all subsystems {trace, debug,
                             info, warn, error, critical} -- You may also specify
                             <subsystem>=<level>,<subsystem2>=<level>,... to set
@@ -98,13 +214,33 @@ Application Options:
                             minute (15)
       --norelaypriority     Do not require free or low-fee transactions to have
                             high priority for relaying
+      --txdiffusiondelay=   Maximum random delay before announcing a locally
+                            submitted transaction to each peer but the first
+                            few -- Set to 0 to announce it to all peers at once
+                            (5s)
       --maxorphantx=        Max number of orphan transactions to keep in memory
                             (100)
+      --datacarriersize=    Max number of bytes in a data carrier (OP_RETURN)
+                            output to relay and mine -- Entangle outputs are
+                            not limited (223)
+      --maxdatacarrieroutputs= Max number of data carrier (OP_RETURN) outputs
+                            per transaction to relay and mine -- Set to 0 to
+                            reject transactions with data carrier outputs (1)
       --generate            Generate (mine) bitcoins using the CPU
       --miningaddr=         Add the specified payment address to the list of
                             addresses to use for generated blocks -- At least
                             one address is required if the generate option is
                             set
+      --signetkey=          WIF-encoded private key to sign generated blocks
+                            with on a signed network -- Required to generate
+                            blocks on such a network unless a signetsigner is
+                            specified
+      --signetsigner=       URL of a remote signer to sign generated blocks
+                            with on a signed network instead of holding the
+                            private key -- Credentials may be given in the URL,
+                            requires signetsignerpubkey
+      --signetsignerpubkey= Hex-encoded compressed public key the remote signer
+                            signs generated blocks with
       --blockminsize=       Mininum block size in bytes to be used when creating
                             a block
       --blockmaxsize=       Maximum block size in bytes to be used when creating
@@ -113,8 +249,21 @@ Application Options:
                             when creating a block (50000)
       --nopeerbloomfilters  Disable bloom filtering support.
       --nocfilters          Disable committed filtering (CF) support.
+      --graphene            Enable the experimental graphene block relay
+                            protocol with peers which support it.
+      --utxosnapshot=       Serve this UTXO set at the last checkpoint, as
+                            exported by utxotool, to peers in fast sync mode
       --sigcachemaxsize=    The maximum number of entries in the signature
                             verification cache.
+      --scriptcachemaxsize= The maximum number of transactions in the script
+                            validation cache, which spares validating the
+                            scripts of the transactions of the memory pool
+                            again once in a block -- Set to 0 to disable the
+                            cache (100000)
+      --blockcachesize=     Number of the most recent blocks to keep
+                            deserialized in memory to serve them without
+                            reading the database -- Set to 0 to disable the
+                            cache (10)
       --blocksonly          Do not accept transactions from remote peers.
       --relaynonstd         Relay non-standard transactions regardless of the
                             default settings for the active network.
@@ -0,0 +1,81 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"time"
+
+	"github.com/bourbaki-czz/classzz/cross"
+	"github.com/bourbaki-czz/classzz/wire"
+)
+
+// entangleWatchInterval is the interval at which the best blocks of the nodes
+// of the chains the coins are entangled from are polled.
+const entangleWatchInterval = 30 * time.Second
+
+// entangleWatchHandler follows the best blocks of the nodes of the chains the
+// coins are entangled from and verifies the entangle transactions of the
+// mempool from a chain again whenever it reorganized.  The transactions which
+// no longer verify are removed from the mempool, which makes the miners fetch
+// new block templates, and are no longer rebroadcast.  It must be run as a
+// goroutine.
+func (s *server) entangleWatchHandler() {
+	defer s.wg.Done()
+
+	watcher := cross.NewChainWatcher(s.chain.GetEntangleVerify())
+	ticker := time.NewTicker(entangleWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, exTxType := range []cross.ExpandedTxType{
+				cross.ExpandedTxEntangle_Doge,
+				cross.ExpandedTxEntangle_Ltc,
+			} {
+				s.pollEntangleChain(watcher, exTxType)
+			}
+
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// pollEntangleChain polls the chain of the passed entangle type with the
+// passed watcher and removes the entangle transactions from the chain which no
+// longer verify from the mempool when it reorganized.
+func (s *server) pollEntangleChain(watcher *cross.ChainWatcher, exTxType cross.ExpandedTxType) {
+	event, err := watcher.Poll(exTxType)
+	if err != nil {
+		srvrLog.Debugf("Unable to poll the best block of the entangled "+
+			"chain: %v", err)
+		return
+	}
+	// The entangled outputs only get more confirmations while the chain
+	// is extended, so they only need to be verified again after a
+	// reorganization.
+	if event == nil || !event.Reorg {
+		return
+	}
+
+	srvrLog.Infof("The %s chain reorganized to block %v at height %d, "+
+		"verifying the entangle transactions of the mempool", event.Chain,
+		event.Hash, event.Height)
+	removed := s.txMemPool.RevalidateEntangles(exTxType)
+	if len(removed) == 0 {
+		return
+	}
+	srvrLog.Warnf("Removed %d %s entangle transactions from the mempool",
+		len(removed), event.Chain)
+
+	// Rebroadcasting is only necessary when the RPC server is active.
+	if s.rpcServer == nil {
+		return
+	}
+	for _, tx := range removed {
+		s.RemoveRebroadcastInventory(wire.NewInvVect(wire.InvTypeTx,
+			tx.Hash()))
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"sort"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+)
+
+const (
+	// evictProtectNetGroups is the number of inbound peers with the highest
+	// keyed netgroup which are protected from eviction.  The netgroups are
+	// keyed with a secret so an attacker can not choose which ones are
+	// protected.
+	evictProtectNetGroups = 4
+
+	// evictProtectPing is the number of inbound peers with the lowest ping
+	// time which are protected from eviction.
+	evictProtectPing = 8
+
+	// evictProtectTx and evictProtectBlock are the number of inbound peers
+	// which most recently relayed new transactions or blocks which are
+	// protected from eviction.
+	evictProtectTx    = 4
+	evictProtectBlock = 4
+)
+
+// evictionCandidate describes an inbound peer which may be evicted to make
+// room for a new inbound connection.
+type evictionCandidate struct {
+	id            int32
+	netGroup      string
+	keyedNetGroup uint64
+	timeConnected time.Time
+	pingMicros    int64
+	lastBlockTime time.Time
+	lastTxTime    time.Time
+}
+
+// newEvictionCandidate returns the eviction candidate describing sp.  The
+// netgroup of the peer is keyed with key.
+func newEvictionCandidate(sp *serverPeer, key []byte) evictionCandidate {
+	netGroup := sp.server.addrManager.GroupKey(sp.NA())
+	keyed := chainhash.HashB(append(append([]byte{}, key...), netGroup...))
+	return evictionCandidate{
+		id:            sp.ID(),
+		netGroup:      netGroup,
+		keyedNetGroup: binary.LittleEndian.Uint64(keyed),
+		timeConnected: sp.TimeConnected(),
+		pingMicros:    sp.LastPingMicros(),
+		lastBlockTime: sp.LastBlockTime(),
+		lastTxTime:    sp.LastTxTime(),
+	}
+}
+
+// protectCandidates sorts the candidates with less and removes the first n
+// of them, which are protected from eviction.
+func protectCandidates(candidates []evictionCandidate, n int,
+	less func(a, b *evictionCandidate) bool) []evictionCandidate {
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return less(&candidates[i], &candidates[j])
+	})
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	return candidates[n:]
+}
+
+// selectEvictionCandidate selects the inbound peer to evict when all inbound
+// connection slots are in use.  Peers which are hard to replace for an
+// attacker are protected: peers in diverse netgroups, with low latency, which
+// recently relayed new transactions or blocks, and the half of the remaining
+// peers which have been connected the longest.  The youngest peer of the
+// netgroup with the most remaining peers is then evicted, so an attacker
+// opening many connections from the same netgroup only evicts its own peers.
+//
+// It returns false when every candidate is protected.
+func selectEvictionCandidate(candidates []evictionCandidate) (int32, bool) {
+	candidates = append([]evictionCandidate(nil), candidates...)
+
+	candidates = protectCandidates(candidates, evictProtectNetGroups,
+		func(a, b *evictionCandidate) bool {
+			return a.keyedNetGroup > b.keyedNetGroup
+		})
+	candidates = protectCandidates(candidates, evictProtectPing,
+		func(a, b *evictionCandidate) bool {
+			// Peers which did not answer a ping yet sort last.
+			if a.pingMicros == 0 || b.pingMicros == 0 {
+				return b.pingMicros == 0 && a.pingMicros != 0
+			}
+			return a.pingMicros < b.pingMicros
+		})
+	candidates = protectCandidates(candidates, evictProtectTx,
+		func(a, b *evictionCandidate) bool {
+			return a.lastTxTime.After(b.lastTxTime)
+		})
+	candidates = protectCandidates(candidates, evictProtectBlock,
+		func(a, b *evictionCandidate) bool {
+			return a.lastBlockTime.After(b.lastBlockTime)
+		})
+	candidates = protectCandidates(candidates, len(candidates)/2,
+		func(a, b *evictionCandidate) bool {
+			return a.timeConnected.Before(b.timeConnected)
+		})
+	if len(candidates) == 0 {
+		return 0, false
+	}
+
+	// Find the netgroup with the most peers, preferring the netgroup of
+	// the youngest peer on ties, and evict its youngest peer.
+	groups := make(map[string][]*evictionCandidate)
+	var evictGroup []*evictionCandidate
+	for i := range candidates {
+		c := &candidates[i]
+		group := append(groups[c.netGroup], c)
+		groups[c.netGroup] = group
+	}
+	for _, group := range groups {
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].timeConnected.After(group[j].timeConnected)
+		})
+		if len(group) > len(evictGroup) || (len(group) == len(evictGroup) &&
+			group[0].timeConnected.After(evictGroup[0].timeConnected)) {
+
+			evictGroup = group
+		}
+	}
+	return evictGroup[0].id, true
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestSelectEvictionCandidate ensures the eviction policy protects peers that
+// are hard to replace and evicts from the netgroup with the most peers.
+func TestSelectEvictionCandidate(t *testing.T) {
+	now := time.Now()
+
+	// No candidates or only protected candidates means nothing is evicted.
+	if _, ok := selectEvictionCandidate(nil); ok {
+		t.Fatalf("selectEvictionCandidate: evicted without candidates")
+	}
+	var few []evictionCandidate
+	for i := 0; i < evictProtectNetGroups; i++ {
+		few = append(few, evictionCandidate{
+			id:            int32(i),
+			netGroup:      fmt.Sprintf("10.%d", i),
+			keyedNetGroup: uint64(i),
+			timeConnected: now,
+		})
+	}
+	if _, ok := selectEvictionCandidate(few); ok {
+		t.Fatalf("selectEvictionCandidate: evicted a protected peer")
+	}
+
+	// An attacker fills the inbound slots from a single netgroup while
+	// honest peers in other netgroups are useful in various ways.
+	var candidates []evictionCandidate
+	for i := 0; i < 40; i++ {
+		candidates = append(candidates, evictionCandidate{
+			id:            int32(100 + i),
+			netGroup:      "6.6",
+			timeConnected: now.Add(-time.Duration(i) * time.Second),
+			pingMicros:    int64(1000 - i),
+		})
+	}
+	honest := map[int32]evictionCandidate{
+		1: {id: 1, netGroup: "1.1", keyedNetGroup: 1 << 60,
+			timeConnected: now},
+		2: {id: 2, netGroup: "2.2", timeConnected: now,
+			lastBlockTime: now},
+		3: {id: 3, netGroup: "3.3", timeConnected: now,
+			lastTxTime: now},
+		4: {id: 4, netGroup: "4.4", timeConnected: now.Add(-time.Hour)},
+	}
+	for _, c := range honest {
+		candidates = append(candidates, c)
+	}
+
+	id, ok := selectEvictionCandidate(candidates)
+	if !ok {
+		t.Fatalf("selectEvictionCandidate: no peer evicted")
+	}
+	if _, ok := honest[id]; ok {
+		t.Fatalf("selectEvictionCandidate: evicted honest peer %d", id)
+	}
+
+	// One of the younger attacking peers is evicted since the half of the
+	// remaining peers connected the longest is protected.
+	if id < 100 || id >= 120 {
+		t.Fatalf("selectEvictionCandidate: evicted peer %d, want one "+
+			"of the younger attacking peers", id)
+	}
+}
@@ -0,0 +1,88 @@
+package graphene
+
+import (
+	"math"
+)
+
+const (
+	// MaxBloomHashFuncs is the largest number of hash functions accepted
+	// for the bloom filter of a graphene block.
+	MaxBloomHashFuncs = 32
+
+	// bloomSeed is mixed into a key to derive the second hash used for
+	// the double hashing of bloom filter indexes.
+	bloomSeed = 0xc2b2ae3d27d4eb4f
+)
+
+// bloomFilter is a bloom filter over 64 bit short IDs.  Unlike the filters
+// of the czzutil bloom package it is not limited to the size allowed for
+// filterload messages, which is far too small for large blocks.  A filter
+// without any bits matches every key and is used when the false positive
+// rate would not be worth the bytes.
+type bloomFilter struct {
+	bits      []byte
+	hashFuncs uint32
+}
+
+// newBloomFilter returns an empty filter sized to hold the passed number of
+// keys at the passed false positive rate.
+func newBloomFilter(keys uint64, fpRate float64) *bloomFilter {
+	if fpRate >= 1 {
+		return &bloomFilter{}
+	}
+	if keys == 0 {
+		// A single empty byte matches nothing.
+		return &bloomFilter{bits: make([]byte, 1), hashFuncs: 1}
+	}
+
+	n := float64(keys)
+	numBits := math.Ceil(-n * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	numBytes := uint64(numBits+7) / 8
+	if numBytes == 0 {
+		numBytes = 1
+	}
+	hashFuncs := uint32(math.Round(float64(numBytes*8) / n * math.Ln2))
+	if hashFuncs < 1 {
+		hashFuncs = 1
+	}
+	if hashFuncs > MaxBloomHashFuncs {
+		hashFuncs = MaxBloomHashFuncs
+	}
+	return &bloomFilter{
+		bits:      make([]byte, numBytes),
+		hashFuncs: hashFuncs,
+	}
+}
+
+// index returns the bit of the filter selected by the i'th hash function for
+// the passed key.
+func (f *bloomFilter) index(key uint64, i uint32) uint64 {
+	h1 := mix64(key)
+	h2 := mix64(key^bloomSeed) | 1
+	return (h1 + uint64(i)*h2) % uint64(len(f.bits)*8)
+}
+
+// add inserts the passed key into the filter.
+func (f *bloomFilter) add(key uint64) {
+	if len(f.bits) == 0 {
+		return
+	}
+	for i := uint32(0); i < f.hashFuncs; i++ {
+		idx := f.index(key, i)
+		f.bits[idx>>3] |= 1 << (idx & 7)
+	}
+}
+
+// matches returns whether the passed key may have been added to the filter.
+func (f *bloomFilter) matches(key uint64) bool {
+	if len(f.bits) == 0 {
+		return true
+	}
+	for i := uint32(0); i < f.hashFuncs; i++ {
+		idx := f.index(key, i)
+		if f.bits[idx>>3]&(1<<(idx&7)) == 0 {
+			return false
+		}
+	}
+	return true
+}
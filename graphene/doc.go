@@ -0,0 +1,27 @@
+/*
+Package graphene implements the experimental graphene block propagation
+protocol.
+
+Graphene Overview
+
+Compact blocks replace every block transaction with a six byte short ID, so
+for blocks approaching the excessive block size even a compact block is
+large.  Graphene instead describes the set of block transactions with a bloom
+filter and an invertible bloom lookup table (IBLT) whose combined size depends
+mostly on the size of the difference between the block and the receiver's
+mempool rather than on the number of transactions in the block.
+
+The receiver passes its mempool through the bloom filter, builds its own IBLT
+from the short IDs that matched and subtracts it from the one that was sent.
+Decoding the difference yields the short IDs of the false positives, which are
+dropped, and of the block transactions missing from the mempool, which are
+requested from the sender.  Since the canonical transaction order fixes the
+position of every transaction after the coinbase, no ordering information
+needs to be sent and the reconstructed block is checked against the merkle
+root of its header.
+
+Decoding is probabilistic.  When the IBLT can not be decoded, or the result
+does not match the header, callers are expected to fall back to requesting
+the full block.
+*/
+package graphene
@@ -0,0 +1,324 @@
+package graphene
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+	"github.com/dchest/siphash"
+)
+
+const (
+	// ibltOverhead is the number of IBLT cells allocated per key expected
+	// in the difference between the block and the receiver's filtered
+	// mempool.
+	ibltOverhead = 1.5
+
+	// ibltMinCells is the number of cells added to every IBLT on top of
+	// the expected difference.  Small IBLTs fail to decode far more often
+	// than their size suggests so they get a larger share of slack.
+	ibltMinCells = 12 * DefaultIBLTHashFuncs
+)
+
+// ErrDecodeFailed is returned when a graphene block can not be reconstructed
+// from the mempool, typically because the IBLT difference was too large to
+// decode.  The full block should be requested instead.
+var ErrDecodeFailed = errors.New("graphene block could not be decoded")
+
+// shortIDHasher calculates the 64 bit short IDs of transactions for a
+// graphene block.  The siphash keys are derived the same way as those of the
+// compact block short IDs.
+type shortIDHasher struct {
+	key0, key1 uint64
+}
+
+// newShortIDHasher returns a hasher keyed by the passed header and nonce.
+func newShortIDHasher(header *wire.BlockHeader, nonce uint64) (*shortIDHasher, error) {
+	var buf bytes.Buffer
+	if err := header.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	var nonceBytes [8]byte
+	binary.LittleEndian.PutUint64(nonceBytes[:], nonce)
+	buf.Write(nonceBytes[:])
+
+	headerHash := sha256.Sum256(buf.Bytes())
+	return &shortIDHasher{
+		key0: binary.LittleEndian.Uint64(headerHash[0:8]),
+		key1: binary.LittleEndian.Uint64(headerHash[8:16]),
+	}, nil
+}
+
+// shortID returns the short ID of the transaction with the passed hash.
+func (h *shortIDHasher) shortID(txHash *chainhash.Hash) uint64 {
+	return siphash.Hash(h.key0, h.key1, txHash[:])
+}
+
+// sizeParams returns the bloom filter false positive rate and the number of
+// IBLT cells that minimize the size of a graphene block holding the passed
+// number of filtered transactions for a receiver with the passed number of
+// mempool transactions.
+//
+// The filter costs n*ln(1/fpRate)/(8*ln(2)^2) bytes and lets through about
+// a = fpRate*(m-n) false positives which each need ibltOverhead cells in the
+// IBLT.  The total is minimized by a = n/(8*ln(2)^2*ibltOverhead*cellSize).
+func sizeParams(n, m uint64) (float64, int) {
+	a := float64(n) / (8 * math.Ln2 * math.Ln2 * ibltOverhead *
+		wire.IBLTCellSize)
+	if a < 1 {
+		a = 1
+	}
+
+	// When the mempool holds barely more transactions than the block the
+	// filter is not worth sending at all.
+	fpRate := 1.0
+	if extra := float64(m) - float64(n); extra > a {
+		fpRate = a / extra
+	} else if extra > 0 {
+		a = extra
+	}
+
+	// The number of false positives varies, so leave room for three
+	// standard deviations more along with a few block transactions which
+	// the receiver does not have.
+	missing := 1 + float64(n)/100
+	diff := a + 3*math.Sqrt(a) + missing
+	cells := int(math.Ceil(ibltOverhead*diff)) + ibltMinCells
+	return fpRate, cells
+}
+
+// NewMsgGrapheneBlock builds a graphene block message from a block for a
+// receiver with the passed number of transactions in its mempool.  Only the
+// coinbase is prefilled, every other transaction is described by the bloom
+// filter and IBLT.  The block must be in canonical transaction order.
+func NewMsgGrapheneBlock(block *wire.MsgBlock, receiverMempool uint64) (*wire.MsgGrapheneBlock, error) {
+	nonce, err := wire.RandomUint64()
+	if err != nil {
+		return nil, err
+	}
+	return newMsgGrapheneBlock(block, receiverMempool, nonce)
+}
+
+// newMsgGrapheneBlock builds a graphene block message using the passed nonce
+// to key the short IDs.
+func newMsgGrapheneBlock(block *wire.MsgBlock, receiverMempool, nonce uint64) (*wire.MsgGrapheneBlock, error) {
+	if len(block.Transactions) == 0 {
+		return nil, errors.New("block has no transactions")
+	}
+	hasher, err := newShortIDHasher(&block.Header, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	txs := block.Transactions[1:]
+	fpRate, cells := sizeParams(uint64(len(txs)), receiverMempool)
+	filter := newBloomFilter(uint64(len(txs)), fpRate)
+	iblt := NewIBLT(cells, DefaultIBLTHashFuncs)
+	for _, tx := range txs {
+		txHash := tx.TxHash()
+		id := hasher.shortID(&txHash)
+		filter.add(id)
+		iblt.Insert(id)
+	}
+
+	msg := wire.NewMsgGrapheneBlock(&block.Header, nonce)
+	msg.TxCount = uint32(len(block.Transactions))
+	msg.FilterHashFuncs = filter.hashFuncs
+	msg.Filter = filter.bits
+	msg.IBLTHashFuncs = uint32(iblt.HashFuncs())
+	msg.IBLTCells = iblt.Cells()
+	msg.PrefilledTxs = []*wire.MsgTx{block.Transactions[0]}
+	return msg, nil
+}
+
+// RequestedTransactions returns the transactions of the passed block that are
+// requested by a getgrblktx message, in the order they were requested.
+func RequestedTransactions(block *wire.MsgBlock, msg *wire.MsgGetGrapheneTx) ([]*wire.MsgTx, error) {
+	hasher, err := newShortIDHasher(&block.Header, msg.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint64]*wire.MsgTx, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		txHash := tx.TxHash()
+		byID[hasher.shortID(&txHash)] = tx
+	}
+
+	txs := make([]*wire.MsgTx, 0, len(msg.ShortIDs))
+	for _, id := range msg.ShortIDs {
+		tx, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("block %v has no transaction "+
+				"with short id %016x", msg.BlockHash, id)
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+// PartialBlock is a graphene block under reconstruction.  It holds the block
+// transactions found in the mempool and the short IDs of those that still
+// need to be fetched from the sender.
+type PartialBlock struct {
+	msg     *wire.MsgGrapheneBlock
+	hasher  *shortIDHasher
+	txs     map[uint64]*wire.MsgTx
+	missing []uint64
+}
+
+// NewPartialBlock starts reconstructing the passed graphene block from the
+// passed mempool transactions.  ErrDecodeFailed is returned if the
+// difference between the block and the mempool can not be recovered.
+func NewPartialBlock(msg *wire.MsgGrapheneBlock, mempool []*wire.MsgTx) (*PartialBlock, error) {
+	if len(msg.PrefilledTxs) == 0 ||
+		!blockchain.IsCoinBaseTx(msg.PrefilledTxs[0]) {
+
+		return nil, errors.New("first prefilled transaction is not " +
+			"a coinbase")
+	}
+	if msg.FilterHashFuncs > MaxBloomHashFuncs ||
+		(len(msg.Filter) > 0 && msg.FilterHashFuncs == 0) {
+
+		return nil, fmt.Errorf("bloom filter uses %d hash functions",
+			msg.FilterHashFuncs)
+	}
+	filter := &bloomFilter{
+		bits:      msg.Filter,
+		hashFuncs: msg.FilterHashFuncs,
+	}
+	blockIBLT, err := IBLTFromCells(msg.IBLTCells, msg.IBLTHashFuncs)
+	if err != nil {
+		return nil, err
+	}
+	hasher, err := newShortIDHasher(&msg.Header, msg.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	prefilled := make(map[chainhash.Hash]struct{}, len(msg.PrefilledTxs))
+	for _, tx := range msg.PrefilledTxs {
+		prefilled[tx.TxHash()] = struct{}{}
+	}
+
+	// Build the IBLT of the mempool transactions that pass the filter.
+	// Two candidates with the same short ID can not be told apart.
+	txs := make(map[uint64]*wire.MsgTx)
+	localIBLT := NewIBLT(len(msg.IBLTCells), int(msg.IBLTHashFuncs))
+	for _, tx := range mempool {
+		txHash := tx.TxHash()
+		if _, ok := prefilled[txHash]; ok {
+			continue
+		}
+		id := hasher.shortID(&txHash)
+		if !filter.matches(id) {
+			continue
+		}
+		if _, ok := txs[id]; ok {
+			return nil, ErrDecodeFailed
+		}
+		txs[id] = tx
+		localIBLT.Insert(id)
+	}
+
+	diff, err := blockIBLT.Subtract(localIBLT)
+	if err != nil {
+		return nil, err
+	}
+	missing, falsePositives, ok := diff.Decode()
+	if !ok {
+		return nil, ErrDecodeFailed
+	}
+	for _, id := range falsePositives {
+		if _, ok := txs[id]; !ok {
+			return nil, ErrDecodeFailed
+		}
+		delete(txs, id)
+	}
+
+	want := int(msg.TxCount) - len(msg.PrefilledTxs)
+	if len(txs)+len(missing) != want {
+		return nil, ErrDecodeFailed
+	}
+	return &PartialBlock{
+		msg:     msg,
+		hasher:  hasher,
+		txs:     txs,
+		missing: missing,
+	}, nil
+}
+
+// MissingShortIDs returns the short IDs of the block transactions which were
+// not found in the mempool.
+func (pb *PartialBlock) MissingShortIDs() []uint64 {
+	return pb.missing
+}
+
+// NewMsgGetGrapheneTx returns the getgrblktx message requesting the missing
+// transactions of the block.
+func (pb *PartialBlock) NewMsgGetGrapheneTx() *wire.MsgGetGrapheneTx {
+	blockHash := pb.msg.BlockHash()
+	return wire.NewMsgGetGrapheneTx(&blockHash, pb.msg.Nonce, pb.missing)
+}
+
+// AddMissingTransactions adds the transactions received in response to the
+// getgrblktx message returned by NewMsgGetGrapheneTx.
+func (pb *PartialBlock) AddMissingTransactions(txs []*wire.MsgTx) error {
+	if len(txs) != len(pb.missing) {
+		return fmt.Errorf("received %d missing transactions, want %d",
+			len(txs), len(pb.missing))
+	}
+	for i, tx := range txs {
+		txHash := tx.TxHash()
+		if id := pb.hasher.shortID(&txHash); id != pb.missing[i] {
+			return fmt.Errorf("transaction %v does not match "+
+				"requested short id %016x", txHash,
+				pb.missing[i])
+		}
+	}
+	for i, tx := range txs {
+		pb.txs[pb.missing[i]] = tx
+	}
+	pb.missing = nil
+	return nil
+}
+
+// Block returns the reconstructed block.  The transactions after the coinbase
+// are placed in canonical order and an error is returned if any are still
+// missing or the result does not match the merkle root of the header.
+func (pb *PartialBlock) Block() (*wire.MsgBlock, error) {
+	if len(pb.missing) != 0 {
+		return nil, fmt.Errorf("%d transactions are still missing",
+			len(pb.missing))
+	}
+
+	txs := make([]*czzutil.Tx, 0, pb.msg.TxCount)
+	for _, tx := range pb.msg.PrefilledTxs[1:] {
+		txs = append(txs, czzutil.NewTx(tx))
+	}
+	for _, tx := range pb.txs {
+		txs = append(txs, czzutil.NewTx(tx))
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		return txs[i].Hash().Compare(txs[j].Hash()) < 0
+	})
+	txs = append([]*czzutil.Tx{czzutil.NewTx(pb.msg.PrefilledTxs[0])},
+		txs...)
+
+	if root := blockchain.CalcMerkleRoot(txs); !root.IsEqual(&pb.msg.Header.MerkleRoot) {
+		return nil, ErrDecodeFailed
+	}
+
+	msgBlock := wire.NewMsgBlock(&pb.msg.Header)
+	for _, tx := range txs {
+		msgBlock.AddTransaction(tx.MsgTx())
+	}
+	return msgBlock, nil
+}
@@ -0,0 +1,181 @@
+package graphene
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// testTx returns a unique transaction identified by the passed value.
+func testTx(value int64) *wire.MsgTx {
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0),
+		nil))
+	tx.AddTxOut(wire.NewTxOut(value, []byte{0x51}))
+	return tx
+}
+
+// testBlock returns a block with a coinbase followed by the passed
+// transactions in canonical order and a matching merkle root.
+func testBlock(txs []*wire.MsgTx) *wire.MsgBlock {
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+		math.MaxUint32), []byte{0x01, 0x02}))
+	coinbase.AddTxOut(wire.NewTxOut(5000000000, []byte{0x51}))
+
+	sorted := make([]*wire.MsgTx, len(txs))
+	copy(sorted, txs)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i].TxHash(), sorted[j].TxHash()
+		return a.Compare(&b) < 0
+	})
+
+	block := wire.NewMsgBlock(&wire.BlockHeader{Version: 1})
+	block.AddTransaction(coinbase)
+	for _, tx := range sorted {
+		block.AddTransaction(tx)
+	}
+	utilTxs := make([]*czzutil.Tx, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		utilTxs[i] = czzutil.NewTx(tx)
+	}
+	merkles := blockchain.BuildMerkleTreeStore(utilTxs)
+	block.Header.MerkleRoot = *merkles[len(merkles)-1]
+	return block
+}
+
+// TestGrapheneBlockReconstruct ensures a graphene block is reconstructed from
+// a mempool holding the block transactions along with many unrelated ones,
+// including the round trip for block transactions missing from the mempool.
+func TestGrapheneBlockReconstruct(t *testing.T) {
+	t.Parallel()
+
+	var blockTxs, mempool []*wire.MsgTx
+	for i := int64(0); i < 300; i++ {
+		tx := testTx(i)
+		blockTxs = append(blockTxs, tx)
+		mempool = append(mempool, tx)
+	}
+	for i := int64(1000); i < 3000; i++ {
+		mempool = append(mempool, testTx(i))
+	}
+	block := testBlock(blockTxs)
+
+	msg, err := newMsgGrapheneBlock(block, uint64(len(mempool)), 1)
+	if err != nil {
+		t.Fatalf("newMsgGrapheneBlock: unexpected error: %v", err)
+	}
+	if msg.TxCount != uint32(len(block.Transactions)) ||
+		len(msg.PrefilledTxs) != 1 {
+
+		t.Fatalf("newMsgGrapheneBlock: got %d txs with %d prefilled",
+			msg.TxCount, len(msg.PrefilledTxs))
+	}
+
+	pb, err := NewPartialBlock(msg, mempool)
+	if err != nil {
+		t.Fatalf("NewPartialBlock: unexpected error: %v", err)
+	}
+	if len(pb.MissingShortIDs()) != 0 {
+		t.Fatalf("NewPartialBlock: got %d missing txs, want 0",
+			len(pb.MissingShortIDs()))
+	}
+	got, err := pb.Block()
+	if err != nil {
+		t.Fatalf("Block: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, block) {
+		t.Fatalf("Block: reconstructed block does not match")
+	}
+
+	// Drop two block transactions from the mempool so they have to be
+	// requested from the sender.
+	pb, err = NewPartialBlock(msg, mempool[2:])
+	if err != nil {
+		t.Fatalf("NewPartialBlock: unexpected error: %v", err)
+	}
+	if n := len(pb.MissingShortIDs()); n != 2 {
+		t.Fatalf("NewPartialBlock: got %d missing txs, want 2", n)
+	}
+	if _, err := pb.Block(); err == nil {
+		t.Fatalf("Block: returned block with missing transactions")
+	}
+	getTxs := pb.NewMsgGetGrapheneTx()
+	if getTxs.BlockHash != block.BlockHash() || getTxs.Nonce != msg.Nonce {
+		t.Fatalf("NewMsgGetGrapheneTx: wrong block hash or nonce")
+	}
+	requested, err := RequestedTransactions(block, getTxs)
+	if err != nil {
+		t.Fatalf("RequestedTransactions: unexpected error: %v", err)
+	}
+	if err := pb.AddMissingTransactions(requested[:1]); err == nil {
+		t.Fatalf("AddMissingTransactions: accepted too few " +
+			"transactions")
+	}
+	if err := pb.AddMissingTransactions(requested); err != nil {
+		t.Fatalf("AddMissingTransactions: unexpected error: %v", err)
+	}
+	got, err = pb.Block()
+	if err != nil {
+		t.Fatalf("Block: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, block) {
+		t.Fatalf("Block: reconstructed block does not match")
+	}
+
+	// Requesting a short ID that is not in the block fails.
+	getTxs.ShortIDs = append(getTxs.ShortIDs, 0)
+	if _, err := RequestedTransactions(block, getTxs); err == nil {
+		t.Fatalf("RequestedTransactions: found unknown short id")
+	}
+}
+
+// TestGrapheneBlockFailures ensures graphene blocks that can not be decoded
+// or do not match their header are reported as such.
+func TestGrapheneBlockFailures(t *testing.T) {
+	t.Parallel()
+
+	var blockTxs []*wire.MsgTx
+	for i := int64(0); i < 200; i++ {
+		blockTxs = append(blockTxs, testTx(i))
+	}
+	block := testBlock(blockTxs)
+
+	// A receiver with an empty mempool is missing far more transactions
+	// than the IBLT is sized for.
+	msg, err := newMsgGrapheneBlock(block, 200, 1)
+	if err != nil {
+		t.Fatalf("newMsgGrapheneBlock: unexpected error: %v", err)
+	}
+	if _, err := NewPartialBlock(msg, nil); err != ErrDecodeFailed {
+		t.Fatalf("NewPartialBlock: got error %v, want %v", err,
+			ErrDecodeFailed)
+	}
+
+	// A header that commits to different transactions is detected once
+	// the block is assembled.
+	block.Header.MerkleRoot = chainhash.Hash{0xff}
+	msg, err = newMsgGrapheneBlock(block, 200, 1)
+	if err != nil {
+		t.Fatalf("newMsgGrapheneBlock: unexpected error: %v", err)
+	}
+	pb, err := NewPartialBlock(msg, blockTxs)
+	if err != nil {
+		t.Fatalf("NewPartialBlock: unexpected error: %v", err)
+	}
+	if _, err := pb.Block(); err != ErrDecodeFailed {
+		t.Fatalf("Block: got error %v, want %v", err, ErrDecodeFailed)
+	}
+
+	// The first prefilled transaction must be the coinbase.
+	msg.PrefilledTxs = []*wire.MsgTx{blockTxs[0]}
+	if _, err := NewPartialBlock(msg, blockTxs); err == nil {
+		t.Fatalf("NewPartialBlock: accepted block without coinbase")
+	}
+}
@@ -0,0 +1,184 @@
+package graphene
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bourbaki-czz/classzz/wire"
+)
+
+const (
+	// DefaultIBLTHashFuncs is the number of hash functions used by the
+	// IBLTs built by this package.
+	DefaultIBLTHashFuncs = 4
+
+	// MaxIBLTHashFuncs is the largest number of hash functions accepted
+	// from a remote peer.
+	MaxIBLTHashFuncs = 16
+
+	// ibltCheckSeed is mixed into a key to derive its check hash.
+	ibltCheckSeed = 0x5bd1e9955bd1e995
+)
+
+// mix64 is the splitmix64 finalizer.  It is used to derive the cell indexes
+// and check hashes of keys which are already uniformly distributed siphash
+// outputs, so a fast mixing function is sufficient.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// IBLT is an invertible bloom lookup table over 64 bit keys.  The cells are
+// split into one equally sized partition per hash function so every key is
+// stored in exactly that many distinct cells.
+type IBLT struct {
+	hashFuncs int
+	cells     []wire.IBLTCell
+}
+
+// NewIBLT returns an empty IBLT with at least the passed number of cells.
+// The number of cells is rounded up to a multiple of the number of hash
+// functions.
+func NewIBLT(cells, hashFuncs int) *IBLT {
+	if hashFuncs < 1 {
+		hashFuncs = 1
+	}
+	if cells < hashFuncs {
+		cells = hashFuncs
+	}
+	if r := cells % hashFuncs; r != 0 {
+		cells += hashFuncs - r
+	}
+	return &IBLT{
+		hashFuncs: hashFuncs,
+		cells:     make([]wire.IBLTCell, cells),
+	}
+}
+
+// IBLTFromCells returns an IBLT using the passed cells, such as those
+// received in a graphene block message.  The cells are copied.
+func IBLTFromCells(cells []wire.IBLTCell, hashFuncs uint32) (*IBLT, error) {
+	if hashFuncs < 1 || hashFuncs > MaxIBLTHashFuncs {
+		str := fmt.Sprintf("iblt uses %d hash functions, must be "+
+			"between 1 and %d", hashFuncs, MaxIBLTHashFuncs)
+		return nil, errors.New(str)
+	}
+	if len(cells) == 0 || len(cells)%int(hashFuncs) != 0 {
+		str := fmt.Sprintf("iblt has %d cells which is not a "+
+			"multiple of its %d hash functions", len(cells),
+			hashFuncs)
+		return nil, errors.New(str)
+	}
+	t := &IBLT{
+		hashFuncs: int(hashFuncs),
+		cells:     make([]wire.IBLTCell, len(cells)),
+	}
+	copy(t.cells, cells)
+	return t, nil
+}
+
+// Cells returns the cells of the IBLT.
+func (t *IBLT) Cells() []wire.IBLTCell {
+	return t.cells
+}
+
+// HashFuncs returns the number of hash functions used by the IBLT.
+func (t *IBLT) HashFuncs() int {
+	return t.hashFuncs
+}
+
+// checkHash returns the check hash of the passed key which is used to tell
+// pure cells, holding a single key, from cells holding several keys.
+func checkHash(key uint64) uint32 {
+	return uint32(mix64(key^ibltCheckSeed) >> 32)
+}
+
+// update adds the passed key to, or removes it from, every cell it maps to.
+func (t *IBLT) update(key uint64, delta int32) {
+	check := checkHash(key)
+	partition := uint64(len(t.cells) / t.hashFuncs)
+	for i := 0; i < t.hashFuncs; i++ {
+		seed := uint64(i+1) * 0x9e3779b97f4a7c15
+		idx := uint64(i)*partition + mix64(key^seed)%partition
+		cell := &t.cells[idx]
+		cell.Count += delta
+		cell.KeySum ^= key
+		cell.HashSum ^= check
+	}
+}
+
+// Insert adds the passed key to the IBLT.
+func (t *IBLT) Insert(key uint64) {
+	t.update(key, 1)
+}
+
+// Subtract returns a new IBLT holding the difference between the receiver and
+// the passed IBLT.  Keys present in both cancel out.
+func (t *IBLT) Subtract(other *IBLT) (*IBLT, error) {
+	if t.hashFuncs != other.hashFuncs || len(t.cells) != len(other.cells) {
+		return nil, errors.New("iblts have mismatched dimensions")
+	}
+	diff := &IBLT{
+		hashFuncs: t.hashFuncs,
+		cells:     make([]wire.IBLTCell, len(t.cells)),
+	}
+	for i := range t.cells {
+		diff.cells[i] = wire.IBLTCell{
+			Count:   t.cells[i].Count - other.cells[i].Count,
+			KeySum:  t.cells[i].KeySum ^ other.cells[i].KeySum,
+			HashSum: t.cells[i].HashSum ^ other.cells[i].HashSum,
+		}
+	}
+	return diff, nil
+}
+
+// isPure returns whether the cell holds exactly one key.
+func isPure(cell *wire.IBLTCell) bool {
+	return (cell.Count == 1 || cell.Count == -1) &&
+		cell.HashSum == checkHash(cell.KeySum)
+}
+
+// Decode lists the keys held by the IBLT.  Applied to the difference of two
+// IBLTs, added holds the keys which were only inserted into the first one and
+// removed the keys which were only inserted into the second one.  The
+// returned bool is false if the IBLT holds too many keys to be decoded, in
+// which case the returned keys are incomplete.  The receiver is not modified.
+func (t *IBLT) Decode() (added, removed []uint64, ok bool) {
+	work := &IBLT{
+		hashFuncs: t.hashFuncs,
+		cells:     make([]wire.IBLTCell, len(t.cells)),
+	}
+	copy(work.cells, t.cells)
+
+	for {
+		peeled := false
+		for i := range work.cells {
+			cell := &work.cells[i]
+			if !isPure(cell) {
+				continue
+			}
+			key, count := cell.KeySum, cell.Count
+			if count == 1 {
+				added = append(added, key)
+			} else {
+				removed = append(removed, key)
+			}
+			work.update(key, -count)
+			peeled = true
+		}
+		if !peeled {
+			break
+		}
+	}
+
+	for i := range work.cells {
+		if work.cells[i] != (wire.IBLTCell{}) {
+			return added, removed, false
+		}
+	}
+	return added, removed, true
+}
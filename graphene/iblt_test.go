@@ -0,0 +1,83 @@
+package graphene
+
+import (
+	"sort"
+	"testing"
+)
+
+// sortedKeys returns the passed keys in ascending order.
+func sortedKeys(keys []uint64) []uint64 {
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// TestIBLTDecode ensures the difference of two IBLTs decodes to the keys only
+// present in either of them, and that an overloaded IBLT reports failure.
+func TestIBLTDecode(t *testing.T) {
+	t.Parallel()
+
+	a := NewIBLT(60, DefaultIBLTHashFuncs)
+	b := NewIBLT(60, DefaultIBLTHashFuncs)
+	for key := uint64(1); key <= 1000; key++ {
+		k := mix64(key)
+		a.Insert(k)
+		b.Insert(k)
+	}
+	onlyA := []uint64{mix64(5001), mix64(5002), mix64(5003)}
+	onlyB := []uint64{mix64(6001), mix64(6002)}
+	for _, k := range onlyA {
+		a.Insert(k)
+	}
+	for _, k := range onlyB {
+		b.Insert(k)
+	}
+
+	diff, err := a.Subtract(b)
+	if err != nil {
+		t.Fatalf("Subtract: unexpected error: %v", err)
+	}
+	added, removed, ok := diff.Decode()
+	if !ok {
+		t.Fatalf("Decode: failed to decode difference")
+	}
+	check := func(name string, got, want []uint64) {
+		got, want = sortedKeys(got), sortedKeys(want)
+		if len(got) != len(want) {
+			t.Fatalf("%s: got %d keys, want %d", name, len(got),
+				len(want))
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("%s: got key %x, want %x", name, got[i],
+					want[i])
+			}
+		}
+	}
+	check("added", added, onlyA)
+	check("removed", removed, onlyB)
+
+	// Decoding must not modify the IBLT.
+	if _, _, ok := diff.Decode(); !ok {
+		t.Fatalf("Decode: second decode failed")
+	}
+
+	// Far more keys than cells can not be decoded.
+	small := NewIBLT(6, DefaultIBLTHashFuncs)
+	for key := uint64(1); key <= 100; key++ {
+		small.Insert(mix64(key))
+	}
+	if _, _, ok := small.Decode(); ok {
+		t.Fatalf("Decode: overloaded iblt decoded")
+	}
+
+	// Mismatched dimensions and invalid cells are rejected.
+	if _, err := a.Subtract(small); err == nil {
+		t.Errorf("Subtract: mismatched iblts subtracted")
+	}
+	if _, err := IBLTFromCells(small.Cells()[:5], 3); err == nil {
+		t.Errorf("IBLTFromCells: accepted partial partition")
+	}
+	if _, err := IBLTFromCells(small.Cells(), 0); err == nil {
+		t.Errorf("IBLTFromCells: accepted zero hash functions")
+	}
+}
@@ -0,0 +1,15 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package chainsim provides a harness simulating a network of `classzz` nodes
+// for integration tests of the consensus and bridge edge cases which involve
+// several nodes, such as reorganizations and entangles.
+//
+// A Network runs several regtest nodes, each driven by an rpctest harness, and
+// mocks the nodes of the chains coins are entangled from with ExternalChain
+// instances serving the RPC calls entangle verification relies on.  The nodes
+// can be partitioned and healed to force reorganizations, and every wait on the
+// nodes is bounded by a timeout so that a network which does not converge
+// fails the test instead of hanging it.
+package chainsim
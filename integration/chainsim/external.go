@@ -0,0 +1,223 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chainsim
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/bourbaki-czz/classzz/btcjson"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/cross"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil/base58"
+)
+
+const (
+	// EntangleMaturity is the number of blocks the external chain must
+	// have on top of the block of a transaction paying to its pool before
+	// the transaction can be entangled.  It matches the maturity required
+	// by the entangle verification of the cross package.
+	EntangleMaturity = 15
+)
+
+// poolAddrs are the addresses of the pools of the external chains, which the
+// entangle verification of the cross package requires the entangled coins to
+// be paid to.
+var poolAddrs = map[cross.ExpandedTxType]string{
+	cross.ExpandedTxEntangle_Doge: "DNGzkoZbnVMihLTMq8M1m7L62XvN3d2cN2",
+	cross.ExpandedTxEntangle_Ltc:  "MUy9qiaLQtaqmKBSk27FXrEEfUkRBeddCZ",
+}
+
+// ExternalChain is a mock of the node of a chain coins are entangled from.  It
+// serves the getblockcount and getrawtransaction RPC calls entangle
+// verification makes over HTTP POST, from a chain whose height and
+// transactions are set by the test.
+type ExternalChain struct {
+	// EType is the entangle type of the chain.
+	EType cross.ExpandedTxType
+
+	server *httptest.Server
+
+	mtx    sync.Mutex
+	height int64
+	txns   map[string]*wire.MsgTx
+	numTxs uint32
+}
+
+// NewExternalChain starts a mock of the node of the chain with the passed
+// entangle type.
+func NewExternalChain(eType cross.ExpandedTxType) (*ExternalChain, error) {
+	if _, ok := poolAddrs[eType]; !ok {
+		return nil, fmt.Errorf("unknown entangle type %d", eType)
+	}
+	c := &ExternalChain{
+		EType: eType,
+		txns:  make(map[string]*wire.MsgTx),
+	}
+	c.server = httptest.NewServer(http.HandlerFunc(c.serveRPC))
+	return c, nil
+}
+
+// Host returns the address of the RPC server of the chain, as expected by the
+// dogecoinrpc and ltccoinrpc options.
+func (c *ExternalChain) Host() string {
+	return strings.TrimPrefix(c.server.URL, "http://")
+}
+
+// Close stops the RPC server of the chain.
+func (c *ExternalChain) Close() {
+	c.server.Close()
+}
+
+// Height returns the height of the chain.
+//
+// This function is safe for concurrent access.
+func (c *ExternalChain) Height() int64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.height
+}
+
+// Generate extends the chain by the passed number of empty blocks.
+//
+// This function is safe for concurrent access.
+func (c *ExternalChain) Generate(numBlocks int64) {
+	c.mtx.Lock()
+	c.height += numBlocks
+	c.mtx.Unlock()
+}
+
+// PayToPool mines a block with a transaction paying the passed amount to the
+// pool of the chain from the passed compressed public key, and returns the
+// entangle info of an output claiming it.  The transaction is not mature until
+// EntangleMaturity more blocks are generated.
+//
+// This function is safe for concurrent access.
+func (c *ExternalChain) PayToPool(pubKey []byte, amount int64) (*cross.EntangleTxInfo, error) {
+	poolHash, _, err := base58.CheckDecode(poolAddrs[c.EType])
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_DUP).
+		AddOp(txscript.OP_HASH160).AddData(poolHash).
+		AddOp(txscript.OP_EQUALVERIFY).AddOp(txscript.OP_CHECKSIG).
+		Script()
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature is never checked, only the public key is read from
+	// the p2pkh signature script.
+	sigScript, err := txscript.NewScriptBuilder().
+		AddData(bytes.Repeat([]byte{0x30}, 72)).AddData(pubKey).Script()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	// Each transaction spends a distinct made up outpoint so that their
+	// hashes are unique.
+	c.numTxs++
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+		c.numTxs), sigScript))
+	tx.AddTxOut(wire.NewTxOut(amount, pkScript))
+
+	c.height++
+	txHash := tx.TxHash().String()
+	c.txns[txHash] = tx
+	return &cross.EntangleTxInfo{
+		ExTxType:  c.EType,
+		Index:     0,
+		Height:    uint64(c.height),
+		Amount:    big.NewInt(amount),
+		ExtTxHash: []byte(txHash),
+	}, nil
+}
+
+// Forget removes the transaction claimed by the passed entangle info from the
+// chain, as when the block including it is reorganized out.
+//
+// This function is safe for concurrent access.
+func (c *ExternalChain) Forget(info *cross.EntangleTxInfo) {
+	c.mtx.Lock()
+	delete(c.txns, string(info.ExtTxHash))
+	c.mtx.Unlock()
+}
+
+// serveRPC serves a JSON-RPC request of the entangle verification.
+func (c *ExternalChain) serveRPC(w http.ResponseWriter, r *http.Request) {
+	// The clients probe the server with a GET request when they are
+	// created.
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var request btcjson.Request
+	if err := json.Unmarshal(body, &request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, rpcErr := c.handleRPC(&request)
+	reply, err := btcjson.MarshalResponse(request.ID, result, rpcErr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(reply)
+}
+
+// handleRPC returns the result of the passed JSON-RPC request.
+func (c *ExternalChain) handleRPC(request *btcjson.Request) (interface{}, *btcjson.RPCError) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	switch request.Method {
+	case "getblockcount":
+		return c.height, nil
+
+	case "getrawtransaction":
+		var txHash string
+		if len(request.Params) == 0 ||
+			json.Unmarshal(request.Params[0], &txHash) != nil {
+
+			return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter,
+				"Invalid transaction hash")
+		}
+		tx, ok := c.txns[txHash]
+		if !ok {
+			return nil, btcjson.NewRPCError(btcjson.ErrRPCNoTxInfo,
+				"No information available about transaction")
+		}
+		var buf bytes.Buffer
+		if err := tx.Serialize(&buf); err != nil {
+			return nil, btcjson.NewRPCError(btcjson.ErrRPCInternal.Code,
+				err.Error())
+		}
+		return hex.EncodeToString(buf.Bytes()), nil
+	}
+
+	return nil, btcjson.ErrRPCMethodNotFound
+}
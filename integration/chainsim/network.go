@@ -0,0 +1,316 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chainsim
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/cross"
+	"github.com/bourbaki-czz/classzz/czzec"
+	"github.com/bourbaki-czz/classzz/integration/rpctest"
+	"github.com/bourbaki-czz/classzz/rpcclient"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+const (
+	// pollInterval is the interval at which the state of the nodes is
+	// polled while waiting for them.
+	pollInterval = 100 * time.Millisecond
+
+	// entangleFeeRate is the fee rate in satoshi per byte of the
+	// transactions carrying the entangle outputs.
+	entangleFeeRate = czzutil.Amount(10)
+)
+
+// link is a peer-to-peer connection from the node with the first index to the
+// node with the second index.
+type link [2]int
+
+// Network is a simulated network of regtest nodes verifying entangles against
+// mocks of the chains coins are entangled from.
+type Network struct {
+	// Nodes are the harnesses driving the nodes of the network.
+	Nodes []*rpctest.Harness
+
+	// Doge and Ltc are the mocks of the dogecoin and litecoin nodes all
+	// the nodes of the network verify entangles against.
+	Doge *ExternalChain
+	Ltc  *ExternalChain
+
+	links map[link]struct{}
+	cut   map[link]struct{}
+}
+
+// NewNetwork creates a network of the passed number of nodes and the mocks of
+// the external chains.  The nodes are not started until SetUp is called.
+func NewNetwork(numNodes int) (*Network, error) {
+	doge, err := NewExternalChain(cross.ExpandedTxEntangle_Doge)
+	if err != nil {
+		return nil, err
+	}
+	ltc, err := NewExternalChain(cross.ExpandedTxEntangle_Ltc)
+	if err != nil {
+		doge.Close()
+		return nil, err
+	}
+	n := &Network{
+		Doge:  doge,
+		Ltc:   ltc,
+		links: make(map[link]struct{}),
+		cut:   make(map[link]struct{}),
+	}
+
+	args := []string{
+		"--dogecoinrpc=" + doge.Host(),
+		"--ltccoinrpc=" + ltc.Host(),
+	}
+	for i := 0; i < numNodes; i++ {
+		h, err := rpctest.New(&chaincfg.RegressionNetParams, nil, args)
+		if err != nil {
+			n.TearDown()
+			return nil, err
+		}
+		n.Nodes = append(n.Nodes, h)
+	}
+	return n, nil
+}
+
+// SetUp starts the nodes, mines enough blocks on the first one for the passed
+// number of mature coinbase outputs, connects every node to the others and
+// waits for them to converge within the passed timeout.
+func (n *Network) SetUp(numMatureOutputs uint32, timeout time.Duration) error {
+	for i, h := range n.Nodes {
+		if err := h.SetUp(i == 0, numMatureOutputs); err != nil {
+			return err
+		}
+	}
+	for i := range n.Nodes {
+		for j := i + 1; j < len(n.Nodes); j++ {
+			if err := n.Connect(i, j); err != nil {
+				return err
+			}
+		}
+	}
+	return n.WaitForConvergence(timeout)
+}
+
+// TearDown stops the nodes and the mocks of the external chains, returning the
+// last error.
+func (n *Network) TearDown() error {
+	var returnErr error
+	for _, h := range n.Nodes {
+		if err := h.TearDown(); err != nil {
+			returnErr = err
+		}
+	}
+	n.Doge.Close()
+	n.Ltc.Close()
+	return returnErr
+}
+
+// Connect connects the node with index from to the node with index to.
+func (n *Network) Connect(from, to int) error {
+	if err := rpctest.ConnectNode(n.Nodes[from], n.Nodes[to]); err != nil {
+		return err
+	}
+	n.links[link{from, to}] = struct{}{}
+	return nil
+}
+
+// Disconnect removes the connection from the node with index from to the node
+// with index to and waits for it to be closed.
+func (n *Network) Disconnect(from, to int, timeout time.Duration) error {
+	addr := n.Nodes[to].P2PAddress()
+	err := n.Nodes[from].Node.AddNode(addr, rpcclient.ANRemove)
+	if err != nil {
+		return err
+	}
+	delete(n.links, link{from, to})
+
+	return waitFor(timeout, func() (bool, error) {
+		peers, err := n.Nodes[from].Node.GetPeerInfo()
+		if err != nil {
+			return false, err
+		}
+		for _, peer := range peers {
+			if peer.Addr == addr {
+				return false, nil
+			}
+		}
+		return true, nil
+	}, func() string {
+		return fmt.Sprintf("node %d is still connected to node %d",
+			from, to)
+	})
+}
+
+// Partition disconnects the nodes of each of the passed groups of node indexes
+// from the nodes of the other groups, until Heal is called.  Nodes which are
+// in no group keep their connections.
+func (n *Network) Partition(timeout time.Duration, groups ...[]int) error {
+	group := make(map[int]int)
+	for g, nodes := range groups {
+		for _, i := range nodes {
+			group[i] = g
+		}
+	}
+	for l := range n.links {
+		fromGroup, ok := group[l[0]]
+		if !ok {
+			continue
+		}
+		toGroup, ok := group[l[1]]
+		if !ok || fromGroup == toGroup {
+			continue
+		}
+		if err := n.Disconnect(l[0], l[1], timeout); err != nil {
+			return err
+		}
+		n.cut[l] = struct{}{}
+	}
+	return nil
+}
+
+// Heal restores the connections removed by Partition.
+func (n *Network) Heal() error {
+	for l := range n.cut {
+		if err := n.Connect(l[0], l[1]); err != nil {
+			return err
+		}
+		delete(n.cut, l)
+	}
+	return nil
+}
+
+// Generate mines the passed number of blocks on the node with the passed index.
+func (n *Network) Generate(node int, numBlocks uint32) ([]*chainhash.Hash, error) {
+	return n.Nodes[node].Node.Generate(numBlocks)
+}
+
+// WaitForConvergence waits for all the nodes to have the same best block, and
+// reports their best blocks when they did not converge within the passed
+// timeout.
+func (n *Network) WaitForConvergence(timeout time.Duration) error {
+	return n.WaitForNodes(timeout, n.allNodes()...)
+}
+
+// WaitForNodes waits for the nodes with the passed indexes to have the same
+// best block, and reports their best blocks when they did not converge within
+// the passed timeout.
+func (n *Network) WaitForNodes(timeout time.Duration, nodes ...int) error {
+	var tips []string
+	return waitFor(timeout, func() (bool, error) {
+		tips = tips[:0]
+		converged := true
+		var bestHash *chainhash.Hash
+		for _, i := range nodes {
+			hash, height, err := n.Nodes[i].Node.GetBestBlock()
+			if err != nil {
+				return false, err
+			}
+			tips = append(tips, fmt.Sprintf("node %d: %v (%d)", i,
+				hash, height))
+			if bestHash != nil && *hash != *bestHash {
+				converged = false
+			}
+			bestHash = hash
+		}
+		return converged, nil
+	}, func() string {
+		return fmt.Sprintf("nodes did not converge: %v", tips)
+	})
+}
+
+// Entangle pays the passed amount to the pool of the passed external chain from
+// a new key, matures the payment and sends a transaction entangling it from the
+// node with the passed index.  It returns the hash of the transaction and the
+// address the entangled coins are paid to by the coinbase of the block which
+// includes it.
+func (n *Network) Entangle(node int, chain *ExternalChain, amount int64) (*chainhash.Hash, czzutil.Address, error) {
+	key, err := czzec.NewPrivateKey(czzec.S256())
+	if err != nil {
+		return nil, nil, err
+	}
+	pubKey := key.PubKey().SerializeCompressed()
+	info, err := chain.PayToPool(pubKey, amount)
+	if err != nil {
+		return nil, nil, err
+	}
+	chain.Generate(EntangleMaturity)
+
+	txHash, err := n.SendEntangleTx(node, info)
+	if err != nil {
+		return nil, nil, err
+	}
+	addr, err := czzutil.NewAddressPubKeyHash(czzutil.Hash160(pubKey),
+		n.Nodes[node].ActiveNet)
+	if err != nil {
+		return nil, nil, err
+	}
+	return txHash, addr, nil
+}
+
+// SendEntangleTx sends a transaction with an entangle output carrying the
+// passed entangle info from the node with the passed index, funded by the
+// wallet of its harness.  The node rejects it when the info does not match a
+// mature payment to the pool of the external chain.
+func (n *Network) SendEntangleTx(node int, info *cross.EntangleTxInfo) (*chainhash.Hash, error) {
+	script, err := txscript.NewEntangleScript(&txscript.EntangleData{
+		ExTxType:  byte(info.ExTxType),
+		Index:     info.Index,
+		Height:    info.Height,
+		Amount:    info.Amount,
+		ExtTxHash: info.ExtTxHash,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h := n.Nodes[node]
+	tx, err := h.CreateTransaction([]*wire.TxOut{wire.NewTxOut(0, script)},
+		entangleFeeRate, true)
+	if err != nil {
+		return nil, err
+	}
+	txHash, err := h.Node.SendRawTransaction(tx, true)
+	if err != nil {
+		h.UnlockOutputs(tx.TxIn)
+		return nil, err
+	}
+	return txHash, nil
+}
+
+// allNodes returns the indexes of all the nodes.
+func (n *Network) allNodes() []int {
+	nodes := make([]int, len(n.Nodes))
+	for i := range nodes {
+		nodes[i] = i
+	}
+	return nodes
+}
+
+// waitFor polls the passed condition until it holds, and returns an error with
+// the passed description when it did not within the passed timeout.
+func waitFor(timeout time.Duration, cond func() (bool, error), describe func() string) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := cond()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout after %v: %s", timeout, describe())
+		}
+		time.Sleep(pollInterval)
+	}
+}
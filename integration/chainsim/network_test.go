@@ -0,0 +1,168 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is ignored during the regular tests due to the following build tag.
+// +build rpctest
+
+package chainsim
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/czzec"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+const (
+	// testTimeout bounds every wait of the tests on the nodes.
+	testTimeout = 30 * time.Second
+)
+
+// setUpNetwork starts a network of the passed number of nodes with mature
+// coinbase outputs to fund entangles.
+func setUpNetwork(t *testing.T, numNodes int) *Network {
+	n, err := NewNetwork(numNodes)
+	if err != nil {
+		t.Fatalf("NewNetwork: unexpected error: %v", err)
+	}
+	if err := n.SetUp(10, testTimeout); err != nil {
+		n.TearDown()
+		t.Fatalf("SetUp: unexpected error: %v", err)
+	}
+	return n
+}
+
+// TestReorgConvergence ensures the nodes of a partitioned network converge on
+// the chain with the most work once it is healed, reorganizing the others.
+func TestReorgConvergence(t *testing.T) {
+	n := setUpNetwork(t, 3)
+	defer n.TearDown()
+
+	if err := n.Partition(testTimeout, []int{0}, []int{1, 2}); err != nil {
+		t.Fatalf("Partition: unexpected error: %v", err)
+	}
+	if _, err := n.Generate(0, 2); err != nil {
+		t.Fatalf("Generate: unexpected error: %v", err)
+	}
+	hashes, err := n.Generate(1, 4)
+	if err != nil {
+		t.Fatalf("Generate: unexpected error: %v", err)
+	}
+	if err := n.WaitForNodes(testTimeout, 1, 2); err != nil {
+		t.Fatalf("WaitForNodes: %v", err)
+	}
+
+	if err := n.Heal(); err != nil {
+		t.Fatalf("Heal: unexpected error: %v", err)
+	}
+	if err := n.WaitForConvergence(testTimeout); err != nil {
+		t.Fatalf("WaitForConvergence: %v", err)
+	}
+	bestHash, _, err := n.Nodes[0].Node.GetBestBlock()
+	if err != nil {
+		t.Fatalf("GetBestBlock: unexpected error: %v", err)
+	}
+	if *bestHash != *hashes[len(hashes)-1] {
+		t.Fatalf("node 0 did not reorganize to the longest chain: got "+
+			"tip %v, want %v", bestHash, hashes[len(hashes)-1])
+	}
+}
+
+// TestEntangleConvergence ensures an entangle sent to one node is mined by
+// another one, paying the entangled coins to the address of the key which paid
+// the pool of the external chain, and that the nodes agree on the block.
+func TestEntangleConvergence(t *testing.T) {
+	n := setUpNetwork(t, 2)
+	defer n.TearDown()
+
+	txHash, addr, err := n.Entangle(0, n.Doge, 2500000000)
+	if err != nil {
+		t.Fatalf("Entangle: unexpected error: %v", err)
+	}
+	err = waitFor(testTimeout, func() (bool, error) {
+		pool, err := n.Nodes[1].Node.GetRawMempool()
+		if err != nil {
+			return false, err
+		}
+		for _, hash := range pool {
+			if *hash == *txHash {
+				return true, nil
+			}
+		}
+		return false, nil
+	}, func() string {
+		return "entangle transaction was not relayed"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashes, err := n.Generate(1, 1)
+	if err != nil {
+		t.Fatalf("Generate: unexpected error: %v", err)
+	}
+	if err := n.WaitForConvergence(testTimeout); err != nil {
+		t.Fatalf("WaitForConvergence: %v", err)
+	}
+	block, err := n.Nodes[0].Node.GetBlock(hashes[0].String())
+	if err != nil {
+		t.Fatalf("GetBlock: unexpected error: %v", err)
+	}
+	var mined bool
+	for _, tx := range block.Transactions[1:] {
+		if tx.TxHash() == *txHash {
+			mined = true
+		}
+	}
+	if !mined {
+		t.Fatalf("entangle transaction %v was not mined", txHash)
+	}
+	if !paysTo(t, block.Transactions[0].TxOut, addr) {
+		t.Fatalf("coinbase does not pay the entangled coins to %v", addr)
+	}
+}
+
+// TestEntangleRejected ensures entangles of immature or unknown payments to
+// the pool of the external chain are rejected.
+func TestEntangleRejected(t *testing.T) {
+	n := setUpNetwork(t, 1)
+	defer n.TearDown()
+
+	key, err := czzec.NewPrivateKey(czzec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	info, err := n.Ltc.PayToPool(key.PubKey().SerializeCompressed(),
+		100000000)
+	if err != nil {
+		t.Fatalf("PayToPool: unexpected error: %v", err)
+	}
+	if _, err := n.SendEntangleTx(0, info); err == nil {
+		t.Fatal("SendEntangleTx: immature payment was accepted")
+	}
+
+	n.Ltc.Generate(EntangleMaturity)
+	n.Ltc.Forget(info)
+	if _, err := n.SendEntangleTx(0, info); err == nil {
+		t.Fatal("SendEntangleTx: unknown payment was accepted")
+	}
+}
+
+// paysTo returns whether one of the passed outputs pays to the passed address.
+func paysTo(t *testing.T, outs []*wire.TxOut, addr czzutil.Address) bool {
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+	for _, out := range outs {
+		if out.Value > 0 && bytes.Equal(out.PkScript, pkScript) {
+			return true
+		}
+	}
+	return false
+}
@@ -126,6 +126,11 @@ func createCoinbaseTx(coinbaseScript []byte, nextBlockHeight int32,
 			tx.AddTxOut(&mineTo[i])
 		}
 	}
+	// Make sure the coinbase is above the minimum size threshold.
+	if tx.SerializeSize() < blockchain.MinTransactionSize {
+		tx.TxIn[0].SignatureScript = append(tx.TxIn[0].SignatureScript,
+			make([]byte, blockchain.MinTransactionSize-tx.SerializeSize())...)
+	}
 	return czzutil.NewTx(tx), nil
 }
 
@@ -586,10 +586,9 @@ func (m *memWallet) ConfirmedBalance() czzutil.Amount {
 
 // keyToAddr maps the passed private to corresponding p2pkh address.
 func keyToAddr(key *czzec.PrivateKey, net *chaincfg.Params) (czzutil.Address, error) {
+	// The address is derived from the parameters rather than from a pubkey
+	// address, whose network is looked up by the legacy address id shared
+	// by testnet and regtest.
 	serializedKey := key.PubKey().SerializeCompressed()
-	pubKeyAddr, err := czzutil.NewAddressPubKey(serializedKey, net)
-	if err != nil {
-		return nil, err
-	}
-	return pubKeyAddr.AddressPubKeyHash(), nil
+	return czzutil.NewAddressPubKeyHash(czzutil.Hash160(serializedKey), net)
 }
@@ -471,7 +471,7 @@ func (h *Harness) GenerateAndSubmitBlockWithCustomCoinbaseOutputs(
 	}
 
 	// Submit the block to the simnet node.
-	if err := h.Node.SubmitBlock(newBlock, nil); err != nil {
+	if _, err := h.Node.SubmitBlock(newBlock, nil); err != nil {
 		return nil, err
 	}
 
@@ -484,7 +484,7 @@ func (h *Harness) GenerateAndSubmitBlockWithCustomCoinbaseOutputs(
 // support multiple test nodes running at once, the p2p and rpc port are
 // incremented after each initialization.
 func generateListeningAddresses() (string, string) {
-	localhost := "0.0.0.0"
+	localhost := "127.0.0.1"
 
 	portString := func(minPort, maxPort int) string {
 		port := minPort + numTestInstances + ((20 * processID) %
@@ -0,0 +1,417 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package lockstat provides a reader/writer mutex which can optionally record
+// how long it is waited for and held, and a watchdog reporting the locks held
+// for too long along with the stacks of the goroutines holding them.
+//
+// The instrumentation is disabled by default, in which case the mutex only
+// adds a nil check to sync.RWMutex.  It is enabled for the whole process by
+// calling Enable before the mutexes are named with SetName.  Only the named
+// mutexes are instrumented.
+package lockstat
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NumBuckets is the number of buckets of the histograms of the wait and hold
+// times.  The last bucket has no upper bound.
+const NumBuckets = 8
+
+// BucketBounds are the upper bounds of all buckets of the histograms but the
+// last one.
+var BucketBounds = [NumBuckets - 1]time.Duration{
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// maxHolderFrames is the largest number of stack frames recorded for the
+// holders of the write locks.
+const maxHolderFrames = 32
+
+// maxStackDumpSize is the largest size of the dumps of the stacks of all
+// goroutines written by the watchdog.
+const maxStackDumpSize = 16 << 20
+
+var (
+	// enabled is whether the mutexes named after it is set are
+	// instrumented.  It is only ever set from zero to one.
+	enabled int32
+
+	// registry holds the instrumented mutexes.
+	registry struct {
+		sync.Mutex
+		locks []*lockStats
+	}
+)
+
+// Enable enables the instrumentation of the mutexes named after it is called
+// and, when the passed threshold is positive, starts a watchdog reporting the
+// locks held longer than it.  It must only be called once, before the mutexes
+// to instrument are named.
+func Enable(watchdog time.Duration) {
+	atomic.StoreInt32(&enabled, 1)
+	if watchdog > 0 {
+		go watchdogHandler(watchdog)
+	}
+}
+
+// Enabled returns whether the instrumentation is enabled.
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) != 0
+}
+
+// histogram is a concurrency safe histogram of durations.
+type histogram struct {
+	buckets [NumBuckets]uint64
+	sum     int64
+}
+
+// observe adds the passed duration to the histogram.
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(BucketBounds) && d > BucketBounds[i] {
+		i++
+	}
+	atomic.AddUint64(&h.buckets[i], 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+// snapshot returns the current state of the histogram.
+func (h *histogram) snapshot() Histogram {
+	var s Histogram
+	for i := range h.buckets {
+		s.Buckets[i] = atomic.LoadUint64(&h.buckets[i])
+		s.Count += s.Buckets[i]
+	}
+	s.Sum = time.Duration(atomic.LoadInt64(&h.sum))
+	return s
+}
+
+// lockStats holds the statistics of an instrumented mutex.  The fields
+// accessed atomically come first so they are 64-bit aligned on all platforms.
+type lockStats struct {
+	writeHold histogram
+	readHold  histogram
+	writeWait histogram
+	readWait  histogram
+
+	writeAcquisitions uint64
+	readAcquisitions  uint64
+	writeContended    uint64
+	readContended     uint64
+	longHolds         uint64
+
+	// writers and readers are the number of goroutines waiting for or
+	// holding the lock in each mode, while activeReaders is the number of
+	// those holding a read lock.
+	writers       int64
+	readers       int64
+	activeReaders int64
+
+	// writeSince is when the write lock was acquired and readSince is when
+	// the readers started to hold the lock, in nanoseconds since the epoch,
+	// or zero when the lock is not held in the mode.
+	writeSince int64
+	readSince  int64
+
+	// reportedWrite and reportedRead are the holds last reported by the
+	// watchdog, so each hold is reported once.  They are only accessed by
+	// the watchdog.
+	reportedWrite int64
+	reportedRead  int64
+
+	name string
+
+	holderMtx    sync.Mutex
+	holder       [maxHolderFrames]uintptr
+	holderFrames int
+}
+
+// setHolder records the stack of the goroutine which acquired the write lock,
+// skipping the passed number of frames.
+func (s *lockStats) setHolder(skip int) {
+	s.holderMtx.Lock()
+	s.holderFrames = runtime.Callers(skip+1, s.holder[:])
+	s.holderMtx.Unlock()
+}
+
+// holderStack returns the stack of the goroutine which last acquired the
+// write lock.
+func (s *lockStats) holderStack() string {
+	s.holderMtx.Lock()
+	frames := runtime.CallersFrames(s.holder[:s.holderFrames])
+	s.holderMtx.Unlock()
+
+	var buf bytes.Buffer
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			fmt.Fprintf(&buf, "%s\n\t%s:%d\n", frame.Function, frame.File,
+				frame.Line)
+		}
+		if !more {
+			break
+		}
+	}
+	return buf.String()
+}
+
+// RWMutex is a reader/writer mutex which records its wait and hold times when
+// the instrumentation is enabled and it is named.  The zero value is an
+// unlocked mutex which is not instrumented.
+type RWMutex struct {
+	mtx   sync.RWMutex
+	stats *lockStats
+}
+
+// SetName names the mutex in the statistics and instruments it when the
+// instrumentation is enabled.  It must be called before the mutex is used, and
+// at most once.
+func (m *RWMutex) SetName(name string) {
+	if !Enabled() {
+		return
+	}
+	m.stats = &lockStats{name: name}
+
+	registry.Lock()
+	registry.locks = append(registry.locks, m.stats)
+	registry.Unlock()
+}
+
+// Lock locks the mutex for writing.
+func (m *RWMutex) Lock() {
+	s := m.stats
+	if s == nil {
+		m.mtx.Lock()
+		return
+	}
+
+	start := time.Now()
+	if atomic.AddInt64(&s.writers, 1) > 1 || atomic.LoadInt64(&s.readers) > 0 {
+		atomic.AddUint64(&s.writeContended, 1)
+	}
+	m.mtx.Lock()
+	now := time.Now()
+	atomic.AddUint64(&s.writeAcquisitions, 1)
+	s.writeWait.observe(now.Sub(start))
+	s.setHolder(2)
+	atomic.StoreInt64(&s.writeSince, now.UnixNano())
+}
+
+// Unlock unlocks the mutex for writing.
+func (m *RWMutex) Unlock() {
+	s := m.stats
+	if s == nil {
+		m.mtx.Unlock()
+		return
+	}
+
+	since := atomic.SwapInt64(&s.writeSince, 0)
+	s.writeHold.observe(time.Duration(time.Now().UnixNano() - since))
+	atomic.AddInt64(&s.writers, -1)
+	m.mtx.Unlock()
+}
+
+// RLock locks the mutex for reading.
+//
+// Since the readers share the lock, the read hold times are those of the
+// periods during which at least one reader holds it.
+func (m *RWMutex) RLock() {
+	s := m.stats
+	if s == nil {
+		m.mtx.RLock()
+		return
+	}
+
+	start := time.Now()
+	atomic.AddInt64(&s.readers, 1)
+	if atomic.LoadInt64(&s.writers) > 0 {
+		atomic.AddUint64(&s.readContended, 1)
+	}
+	m.mtx.RLock()
+	now := time.Now()
+	atomic.AddUint64(&s.readAcquisitions, 1)
+	s.readWait.observe(now.Sub(start))
+	if atomic.AddInt64(&s.activeReaders, 1) == 1 {
+		atomic.StoreInt64(&s.readSince, now.UnixNano())
+	}
+}
+
+// RUnlock undoes a single RLock call.
+func (m *RWMutex) RUnlock() {
+	s := m.stats
+	if s == nil {
+		m.mtx.RUnlock()
+		return
+	}
+
+	if atomic.AddInt64(&s.activeReaders, -1) == 0 {
+		// The start of the period is zero when a new reader acquired
+		// the lock before it was recorded.
+		since := atomic.SwapInt64(&s.readSince, 0)
+		if since != 0 {
+			s.readHold.observe(time.Duration(time.Now().UnixNano() - since))
+		}
+	}
+	atomic.AddInt64(&s.readers, -1)
+	m.mtx.RUnlock()
+}
+
+// Histogram is a histogram of wait or hold times.
+type Histogram struct {
+	// Buckets are the number of durations in each bucket, the upper
+	// bounds of which are BucketBounds.
+	Buckets [NumBuckets]uint64
+
+	// Sum and Count are the total and the number of the durations.
+	Sum   time.Duration
+	Count uint64
+}
+
+// Stats are the statistics of an instrumented mutex.
+type Stats struct {
+	Name string
+
+	// WriteWait and ReadWait are the times waited for the lock in each
+	// mode.  WriteHold is the time the write lock was held, while ReadHold
+	// is the time at least one reader held the lock.
+	WriteWait Histogram
+	ReadWait  Histogram
+	WriteHold Histogram
+	ReadHold  Histogram
+
+	// WriteAcquisitions and ReadAcquisitions are the number of times the
+	// lock was acquired in each mode, while WriteContended and
+	// ReadContended are the number of those for which it was held or
+	// waited for in a conflicting mode.
+	WriteAcquisitions uint64
+	ReadAcquisitions  uint64
+	WriteContended    uint64
+	ReadContended     uint64
+
+	// LongHolds is the number of holds the watchdog reported.
+	LongHolds uint64
+}
+
+// Snapshot returns the statistics of the instrumented mutexes in the order
+// they were named.
+//
+// This function is safe for concurrent access.
+func Snapshot() []Stats {
+	registry.Lock()
+	locks := registry.locks
+	registry.Unlock()
+
+	stats := make([]Stats, 0, len(locks))
+	for _, s := range locks {
+		stats = append(stats, Stats{
+			Name:              s.name,
+			WriteWait:         s.writeWait.snapshot(),
+			ReadWait:          s.readWait.snapshot(),
+			WriteHold:         s.writeHold.snapshot(),
+			ReadHold:          s.readHold.snapshot(),
+			WriteAcquisitions: atomic.LoadUint64(&s.writeAcquisitions),
+			ReadAcquisitions:  atomic.LoadUint64(&s.readAcquisitions),
+			WriteContended:    atomic.LoadUint64(&s.writeContended),
+			ReadContended:     atomic.LoadUint64(&s.readContended),
+			LongHolds:         atomic.LoadUint64(&s.longHolds),
+		})
+	}
+	return stats
+}
+
+// longHold is a hold of a lock the watchdog reports.
+type longHold struct {
+	name  string
+	mode  string
+	held  time.Duration
+	stack string
+}
+
+// checkLongHolds returns the holds of the lock which started more than the
+// passed threshold before the passed time and were not returned yet.  The
+// stack of the holder is only known for the write lock.
+//
+// This function must only be called from the watchdog.
+func (s *lockStats) checkLongHolds(now time.Time, threshold time.Duration) []longHold {
+	var holds []longHold
+	check := func(mode string, since int64, reported *int64) {
+		held := time.Duration(now.UnixNano() - since)
+		if since == 0 || since == *reported || held <= threshold {
+			return
+		}
+		*reported = since
+		atomic.AddUint64(&s.longHolds, 1)
+		hold := longHold{name: s.name, mode: mode, held: held}
+		if mode == "write" {
+			hold.stack = s.holderStack()
+		}
+		holds = append(holds, hold)
+	}
+	check("write", atomic.LoadInt64(&s.writeSince), &s.reportedWrite)
+	check("read", atomic.LoadInt64(&s.readSince), &s.reportedRead)
+	return holds
+}
+
+// allStacks returns the stacks of all goroutines.
+func allStacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxStackDumpSize {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// watchdogHandler periodically reports the locks held longer than the passed
+// threshold along with the stacks of all goroutines, so the goroutines
+// holding them and those waiting for them can be told.
+//
+// It must be run as a goroutine.
+func watchdogHandler(threshold time.Duration) {
+	interval := threshold / 4
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		registry.Lock()
+		locks := registry.locks
+		registry.Unlock()
+
+		var holds []longHold
+		for _, s := range locks {
+			holds = append(holds, s.checkLongHolds(now, threshold)...)
+		}
+		if len(holds) == 0 {
+			continue
+		}
+		for _, hold := range holds {
+			if hold.stack != "" {
+				log.Warnf("The %s lock has been held for writing for "+
+					"%v by:\n%s", hold.name, hold.held, hold.stack)
+				continue
+			}
+			log.Warnf("The %s lock has been held for reading for %v",
+				hold.name, hold.held)
+		}
+		log.Warnf("Stacks of all goroutines:\n%s", allStacks())
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package lockstat
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRWMutex ensures the instrumented mutexes record their acquisitions and
+// hold times, and that the long holds are reported once with the stack of the
+// holder of the write lock.
+func TestRWMutex(t *testing.T) {
+	var plain RWMutex
+	plain.SetName("plain")
+	if plain.stats != nil {
+		t.Fatal("SetName instrumented a mutex while disabled")
+	}
+
+	Enable(0)
+	var m RWMutex
+	m.SetName("test")
+	m.Lock()
+	m.Unlock()
+	m.RLock()
+	m.RLock()
+	m.RUnlock()
+	m.RUnlock()
+
+	stats := Snapshot()
+	if len(stats) != 1 || stats[0].Name != "test" {
+		t.Fatalf("Snapshot: got %+v, want the stats of the test mutex",
+			stats)
+	}
+	s := stats[0]
+	if s.WriteAcquisitions != 1 || s.ReadAcquisitions != 2 ||
+		s.WriteHold.Count != 1 || s.ReadHold.Count != 1 ||
+		s.WriteWait.Count != 1 || s.ReadWait.Count != 2 {
+
+		t.Fatalf("Snapshot: unexpected stats %+v", s)
+	}
+
+	m.Lock()
+	now := time.Now()
+	holds := m.stats.checkLongHolds(now, time.Second)
+	if len(holds) != 0 {
+		t.Fatalf("checkLongHolds: got %+v for a short hold", holds)
+	}
+	holds = m.stats.checkLongHolds(now.Add(2*time.Second), time.Second)
+	if len(holds) != 1 || holds[0].mode != "write" ||
+		!strings.Contains(holds[0].stack, "TestRWMutex") {
+
+		t.Fatalf("checkLongHolds: got %+v, want the long write hold",
+			holds)
+	}
+	holds = m.stats.checkLongHolds(now.Add(3*time.Second), time.Second)
+	if len(holds) != 0 {
+		t.Fatalf("checkLongHolds: reported the write hold again")
+	}
+	m.Unlock()
+
+	m.RLock()
+	holds = m.stats.checkLongHolds(time.Now().Add(2*time.Second), time.Second)
+	if len(holds) != 1 || holds[0].mode != "read" {
+		t.Fatalf("checkLongHolds: got %+v, want the long read hold",
+			holds)
+	}
+	m.RUnlock()
+
+	if longHolds := Snapshot()[0].LongHolds; longHolds != 2 {
+		t.Fatalf("Snapshot: got %d long holds, want 2", longHolds)
+	}
+}
+
+// TestHistogram ensures the durations are counted in the bucket of the
+// smallest upper bound they do not exceed.
+func TestHistogram(t *testing.T) {
+	var h histogram
+	for _, d := range []time.Duration{0, 10 * time.Microsecond,
+		11 * time.Microsecond, time.Second, time.Minute} {
+
+		h.observe(d)
+	}
+	s := h.snapshot()
+	want := [NumBuckets]uint64{2, 1, 0, 0, 0, 1, 0, 1}
+	if s.Buckets != want || s.Count != 5 ||
+		s.Sum != 61*time.Second+21*time.Microsecond {
+
+		t.Fatalf("snapshot: got %+v, want buckets %v", s, want)
+	}
+}
@@ -10,12 +10,14 @@ import (
 	"github.com/bourbaki-czz/classzz/czzrpc"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/bourbaki-czz/classzz/addrmgr"
 	"github.com/bourbaki-czz/classzz/blockchain"
 	"github.com/bourbaki-czz/classzz/blockchain/indexers"
 	"github.com/bourbaki-czz/classzz/connmgr"
 	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/lockstat"
 	"github.com/bourbaki-czz/classzz/mempool"
 	"github.com/bourbaki-czz/classzz/mining"
 	"github.com/bourbaki-czz/classzz/mining/cpuminer"
@@ -24,17 +26,19 @@ import (
 	"github.com/bourbaki-czz/classzz/txscript"
 
 	"github.com/bourbaki-czz/czzlog"
-	"github.com/jrick/logrotate/rotator"
 )
 
 // logWriter implements an io.Writer that outputs to both standard output and
-// the write-end pipe of an initialized log rotator.
+// the write-end pipe of an initialized log rotator.  The records are converted
+// to the configured log format on the way.
 type logWriter struct{}
 
 func (logWriter) Write(p []byte) (n int, err error) {
+	n = len(p)
+	p = formatLogRecord(logFormat, p)
 	os.Stdout.Write(p)
 	logRotator.Write(p)
-	return len(p), nil
+	return n, nil
 }
 
 // Loggers per subsystem.  A single backend logger is created and all subsytem
@@ -53,7 +57,11 @@ var (
 
 	// logRotator is one of the logging outputs.  It should be closed on
 	// application shutdown.
-	logRotator *rotator.Rotator
+	logRotator *fileRotator
+
+	// logFormat is the format the records of the logging backend are
+	// written in.  It must be set before the log rotator is initialized.
+	logFormat = logFormatText
 
 	adxrLog = backendLog.Logger("ADXR")
 	amgrLog = backendLog.Logger("AMGR")
@@ -63,6 +71,7 @@ var (
 	chanLog = backendLog.Logger("CHAN")
 	discLog = backendLog.Logger("DISC")
 	indxLog = backendLog.Logger("INDX")
+	lockLog = backendLog.Logger("LOCK")
 	minrLog = backendLog.Logger("MINR")
 	peerLog = backendLog.Logger("PEER")
 	rpcsLog = backendLog.Logger("RPCS")
@@ -71,6 +80,7 @@ var (
 	syncLog = backendLog.Logger("SYNC")
 	txmpLog = backendLog.Logger("TXMP")
 	grpcLog = backendLog.Logger("GRPC")
+	wlltLog = backendLog.Logger("WLLT")
 )
 
 // Initialize package-global logger variables.
@@ -80,6 +90,7 @@ func init() {
 	database.UseLogger(bcdbLog)
 	blockchain.UseLogger(chanLog)
 	indexers.UseLogger(indxLog)
+	lockstat.UseLogger(lockLog)
 	mining.UseLogger(minrLog)
 	cpuminer.UseLogger(minrLog)
 	peer.UseLogger(peerLog)
@@ -99,6 +110,7 @@ var subsystemLoggers = map[string]czzlog.Logger{
 	"CHAN": chanLog,
 	"DISC": discLog,
 	"INDX": indxLog,
+	"LOCK": lockLog,
 	"MINR": minrLog,
 	"PEER": peerLog,
 	"RPCS": rpcsLog,
@@ -107,19 +119,22 @@ var subsystemLoggers = map[string]czzlog.Logger{
 	"SYNC": syncLog,
 	"TXMP": txmpLog,
 	"GRPC": grpcLog,
+	"WLLT": wlltLog,
 }
 
 // initLogRotator initializes the logging rotater to write logs to logFile and
-// create roll files in the same directory.  It must be called before the
-// package-global log rotater variables are used.
-func initLogRotator(logFile string) {
+// create roll files in the same directory.  The file is rotated once it
+// reaches maxSize bytes and, unless interval is zero, at every multiple of
+// interval.  Only the maxRolls most recent roll files are kept.  It must be
+// called before the package-global log rotater variables are used.
+func initLogRotator(logFile string, maxSize int64, interval time.Duration, maxRolls int) {
 	logDir, _ := filepath.Split(logFile)
 	err := os.MkdirAll(logDir, 0700)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to create log directory: %v\n", err)
 		os.Exit(1)
 	}
-	r, err := rotator.New(logFile, 10*1024, false, 3)
+	r, err := newFileRotator(logFile, maxSize, interval, maxRolls)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to create file rotator: %v\n", err)
 		os.Exit(1)
@@ -154,6 +169,24 @@ func setLogLevels(logLevel string) {
 	}
 }
 
+// logLevelName returns the name of the passed level as it is specified by the
+// debuglevel option.
+func logLevelName(level czzlog.Level) string {
+	if name, ok := logLevelNames[level.String()]; ok {
+		return name
+	}
+	return "off"
+}
+
+// logLevels returns the names of the log levels of all subsystems.
+func logLevels() map[string]string {
+	levels := make(map[string]string, len(subsystemLoggers))
+	for subsystemID, logger := range subsystemLoggers {
+		levels[subsystemID] = logLevelName(logger.Level())
+	}
+	return levels
+}
+
 // directionString is a helper function that returns a string that represents
 // the direction of a connection (inbound or outbound).
 func directionString(inbound bool) string {
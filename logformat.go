@@ -0,0 +1,147 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// These are the formats the log can be written in.
+const (
+	// logFormatText is the format of the logging backend:
+	// 'YYYY-MM-DD hh:mm:ss.sss [LVL] TAG: message'.
+	logFormatText = "text"
+
+	// logFormatJSON writes every record as a JSON object on its own line.
+	logFormatJSON = "json"
+
+	// logFormatLogfmt writes every record as a line of key=value pairs.
+	logFormatLogfmt = "logfmt"
+)
+
+// logHeaderTimeLayout is the layout of the timestamps the logging backend
+// starts the records with.
+const logHeaderTimeLayout = "2006-01-02 15:04:05.000"
+
+// logTimeLayout is the layout of the timestamps of the structured records.
+const logTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// logLevelNames maps the level tags of the logging backend to the names used
+// in the structured records, which match the names of the debuglevel option.
+var logLevelNames = map[string]string{
+	"TRC": "trace",
+	"DBG": "debug",
+	"INF": "info",
+	"WRN": "warn",
+	"ERR": "error",
+	"CRT": "critical",
+}
+
+// logRecord is a log record parsed from the output of the logging backend.
+type logRecord struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Subsystem string `json:"subsystem"`
+	Caller    string `json:"caller,omitempty"`
+	Msg       string `json:"msg"`
+}
+
+// parseLogRecord parses a record written by the logging backend, whose
+// subsystem tag is followed by the caller when the LOGFLAGS environment
+// variable asks for it.
+func parseLogRecord(p []byte) (*logRecord, bool) {
+	s := strings.TrimSuffix(string(p), "\n")
+	if len(s) < len(logHeaderTimeLayout) {
+		return nil, false
+	}
+	t, err := time.ParseInLocation(logHeaderTimeLayout,
+		s[:len(logHeaderTimeLayout)], time.Local)
+	if err != nil {
+		return nil, false
+	}
+	s = s[len(logHeaderTimeLayout):]
+
+	// The level tag is enclosed in brackets.
+	if len(s) < len(" [LVL] ") || s[:2] != " [" || s[5:7] != "] " {
+		return nil, false
+	}
+	level, ok := logLevelNames[s[2:5]]
+	if !ok {
+		return nil, false
+	}
+	s = s[7:]
+
+	end := strings.Index(s, ": ")
+	if end < 0 {
+		return nil, false
+	}
+	record := &logRecord{
+		Time:      t.Format(logTimeLayout),
+		Level:     level,
+		Subsystem: s[:end],
+		Msg:       s[end+2:],
+	}
+	if i := strings.IndexByte(record.Subsystem, ' '); i >= 0 {
+		record.Caller = record.Subsystem[i+1:]
+		record.Subsystem = record.Subsystem[:i]
+	}
+	return record, true
+}
+
+// logfmtValue returns the passed value quoted when it is empty or contains
+// characters which are not allowed in unquoted logfmt values.
+func logfmtValue(value string) string {
+	needsQuotes := value == ""
+	for _, r := range value {
+		if r == '=' || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			needsQuotes = true
+			break
+		}
+	}
+	if needsQuotes {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// formatLogRecord formats the passed record written by the logging backend
+// in the passed format.  Records which can not be parsed are returned
+// unchanged.
+func formatLogRecord(format string, p []byte) []byte {
+	if format == logFormatText {
+		return p
+	}
+	record, ok := parseLogRecord(p)
+	if !ok {
+		return p
+	}
+
+	switch format {
+	case logFormatJSON:
+		b, err := json.Marshal(record)
+		if err != nil {
+			return p
+		}
+		return append(b, '\n')
+
+	case logFormatLogfmt:
+		var buf bytes.Buffer
+		buf.WriteString("time=" + logfmtValue(record.Time))
+		buf.WriteString(" level=" + logfmtValue(record.Level))
+		buf.WriteString(" subsystem=" + logfmtValue(record.Subsystem))
+		if record.Caller != "" {
+			buf.WriteString(" caller=" + logfmtValue(record.Caller))
+		}
+		buf.WriteString(" msg=" + logfmtValue(record.Msg))
+		buf.WriteByte('\n')
+		return buf.Bytes()
+	}
+	return p
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestFormatLogRecord ensures the records of the logging backend are converted
+// to the structured log formats.
+func TestFormatLogRecord(t *testing.T) {
+	stamp := time.Date(2019, 10, 14, 14, 34, 45, 785e6, time.Local)
+	header := stamp.Format(logHeaderTimeLayout)
+	zone := stamp.Format("Z07:00")
+
+	tests := []struct {
+		name   string
+		format string
+		record string
+		want   string
+	}{
+		{
+			name:   "text",
+			format: logFormatText,
+			record: header + " [INF] SRVR: Server listening\n",
+			want:   header + " [INF] SRVR: Server listening\n",
+		},
+		{
+			name:   "json",
+			format: logFormatJSON,
+			record: header + " [WRN] PEER: Peer \"a\" misbehaved: 10\n",
+			want: `{"time":"2019-10-14T14:34:45.785` + zone + `",` +
+				`"level":"warn","subsystem":"PEER",` +
+				`"msg":"Peer \"a\" misbehaved: 10"}` + "\n",
+		},
+		{
+			name:   "json with caller",
+			format: logFormatJSON,
+			record: header + " [DBG] CHAN chain.go:12: Line one\nline two\n",
+			want: `{"time":"2019-10-14T14:34:45.785` + zone + `",` +
+				`"level":"debug","subsystem":"CHAN",` +
+				`"caller":"chain.go:12","msg":"Line one\nline two"}` + "\n",
+		},
+		{
+			name:   "logfmt",
+			format: logFormatLogfmt,
+			record: header + " [ERR] RPCS: Failed: a=b\n",
+			want: "time=2019-10-14T14:34:45.785" + zone + " level=error " +
+				`subsystem=RPCS msg="Failed: a=b"` + "\n",
+		},
+		{
+			name:   "logfmt unquoted",
+			format: logFormatLogfmt,
+			record: header + " [TRC] SYNC: Done\n",
+			want: "time=2019-10-14T14:34:45.785" + zone + " level=trace " +
+				"subsystem=SYNC msg=Done\n",
+		},
+		{
+			name:   "unparsable",
+			format: logFormatJSON,
+			record: "not a log record\n",
+			want:   "not a log record\n",
+		},
+	}
+
+	for _, test := range tests {
+		got := string(formatLogRecord(test.format, []byte(test.record)))
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
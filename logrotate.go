@@ -0,0 +1,195 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileRotator writes the log to a file which is rotated once it reaches a size
+// threshold, and optionally whenever a multiple of a rotation interval has
+// passed since the Unix epoch, so a daily interval rotates at midnight UTC.
+//
+// The rotated files are named after the log file followed by an increasing
+// roll number and are compressed with gzip in the background.  Only the most
+// recent rolls are kept.
+type fileRotator struct {
+	mtx       sync.Mutex
+	filename  string
+	threshold int64
+	interval  time.Duration
+	maxRolls  int
+	out       *os.File
+	size      int64
+	deadline  time.Time
+	wg        sync.WaitGroup
+}
+
+// newFileRotator returns a rotator writing to the passed file, which is
+// created if it does not exist.  A zero interval or maxRolls disables the
+// rotation by time and the removal of old rolls respectively.
+func newFileRotator(filename string, threshold int64, interval time.Duration,
+	maxRolls int) (*fileRotator, error) {
+
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	r := &fileRotator{
+		filename:  filename,
+		threshold: threshold,
+		interval:  interval,
+		maxRolls:  maxRolls,
+		out:       f,
+		size:      stat.Size(),
+	}
+	r.deadline = r.nextDeadline(time.Now())
+	return r, nil
+}
+
+// nextDeadline returns the time the file is rotated by time after the passed
+// time.  It is the zero time when the rotation by time is disabled.
+func (r *fileRotator) nextDeadline(now time.Time) time.Time {
+	if r.interval <= 0 {
+		return time.Time{}
+	}
+	return now.Truncate(r.interval).Add(r.interval)
+}
+
+// Write implements the io.Writer interface.  The file is rotated before the
+// write rather than after it, so a log record, which the logging backend
+// writes with a single call, is never split across rolls.
+func (r *fileRotator) Write(p []byte) (int, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	now := time.Now()
+	expired := !r.deadline.IsZero() && !now.Before(r.deadline)
+	if r.size > 0 && (r.size >= r.threshold || expired) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	if expired {
+		r.deadline = r.nextDeadline(now)
+	}
+
+	n, err := r.out.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the file and waits for the rotated files to be compressed.
+func (r *fileRotator) Close() error {
+	r.mtx.Lock()
+	err := r.out.Close()
+	r.mtx.Unlock()
+
+	r.wg.Wait()
+	return err
+}
+
+// rollNumber returns the roll number of the passed rotated file name, and
+// whether it is one.
+func (r *fileRotator) rollNumber(name string) (int, bool) {
+	suffix := strings.TrimPrefix(name, r.filename+".")
+	if suffix == name {
+		return 0, false
+	}
+	num, err := strconv.Atoi(strings.TrimSuffix(suffix, ".gz"))
+	return num, err == nil && num > 0
+}
+
+// rotate moves the log file to the next roll, starts its compression and
+// opens a new log file.  The rolls older than the kept ones are removed.
+//
+// This function MUST be called with the mutex held.
+func (r *fileRotator) rotate() error {
+	// Wait for the previous roll to be compressed, so it is not removed
+	// while it is being compressed.
+	r.wg.Wait()
+
+	existing, err := filepath.Glob(r.filename + ".*")
+	if err != nil {
+		return err
+	}
+	maxNum := 0
+	for _, name := range existing {
+		if num, ok := r.rollNumber(name); ok && num > maxNum {
+			maxNum = num
+		}
+	}
+
+	rollName := fmt.Sprintf("%s.%d", r.filename, maxNum+1)
+	if err := os.Rename(r.filename, rollName); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(r.filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY,
+		0644)
+	if err != nil {
+		return err
+	}
+	r.out.Close()
+	r.out = out
+	r.size = 0
+
+	if r.maxRolls > 0 {
+		for _, name := range existing {
+			num, ok := r.rollNumber(name)
+			if ok && num <= maxNum+1-r.maxRolls {
+				os.Remove(name)
+			}
+		}
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		if err := compressLogFile(rollName); err == nil {
+			os.Remove(rollName)
+		}
+	}()
+	return nil
+}
+
+// compressLogFile writes the passed file compressed with gzip to a file of
+// the same name with the .gz extension.
+func compressLogFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	arc, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_EXCL|os.O_WRONLY,
+		0644)
+	if err != nil {
+		return err
+	}
+	z := gzip.NewWriter(arc)
+	if _, err := io.Copy(z, f); err != nil {
+		arc.Close()
+		return err
+	}
+	if err := z.Close(); err != nil {
+		arc.Close()
+		return err
+	}
+	return arc.Close()
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readLogRoll returns the contents of the passed compressed log roll.
+func readLogRoll(t *testing.T, name string) string {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("unable to open roll: %v", err)
+	}
+	defer f.Close()
+	z, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("unable to read roll %s: %v", name, err)
+	}
+	b, err := ioutil.ReadAll(z)
+	if err != nil {
+		t.Fatalf("unable to read roll %s: %v", name, err)
+	}
+	return string(b)
+}
+
+// TestFileRotator ensures the log file is rotated by size and by time, the
+// rolls are compressed and only the most recent rolls are kept.
+func TestFileRotator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrotate")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "test.log")
+
+	r, err := newFileRotator(logFile, 10, 0, 2)
+	if err != nil {
+		t.Fatalf("newFileRotator: unexpected error: %v", err)
+	}
+	for _, record := range []string{"one\n", "two two two\n", "three\n",
+		"four four\n", "five\n"} {
+
+		if _, err := r.Write([]byte(record)); err != nil {
+			t.Fatalf("Write: unexpected error: %v", err)
+		}
+	}
+
+	// The file was rotated before the third and the fifth records, once
+	// the records before them reached the threshold.  Expire the rotation
+	// deadline to rotate before the sixth record too, which removes the
+	// first roll since only two are kept.
+	r.deadline = time.Now().Add(-time.Second)
+	r.interval = time.Hour
+	if _, err := r.Write([]byte("six\n")); err != nil {
+		t.Fatalf("Write: unexpected error: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(logFile + ".1.gz"); !os.IsNotExist(err) {
+		t.Fatalf("the oldest roll was not removed: %v", err)
+	}
+	if got := readLogRoll(t, logFile+".2.gz"); got != "three\nfour four\n" {
+		t.Fatalf("unexpected second roll %q", got)
+	}
+	if got := readLogRoll(t, logFile+".3.gz"); got != "five\n" {
+		t.Fatalf("unexpected third roll %q", got)
+	}
+	got, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("unable to read log file: %v", err)
+	}
+	if string(got) != "six\n" {
+		t.Fatalf("unexpected log file %q", got)
+	}
+	if !r.deadline.After(time.Now()) {
+		t.Fatalf("the rotation deadline %v was not advanced", r.deadline)
+	}
+}
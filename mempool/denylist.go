@@ -0,0 +1,131 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// scriptWildcard is the byte of a script template which matches any byte.
+const scriptWildcard = "??"
+
+// scriptTemplate is a pattern output scripts are matched against.
+type scriptTemplate struct {
+	// text is the template as configured, used in the audit log.
+	text string
+
+	// script holds the bytes of the template, which are only compared
+	// where wildcard is false.
+	script   []byte
+	wildcard []bool
+}
+
+// parseScriptTemplate parses the passed hex encoded script template, where each
+// ?? matches any byte.
+func parseScriptTemplate(text string) (*scriptTemplate, error) {
+	lower := strings.ToLower(text)
+	if len(lower) == 0 || len(lower)%2 != 0 {
+		return nil, fmt.Errorf("script template %q is not an even "+
+			"number of hex digits", text)
+	}
+
+	t := &scriptTemplate{
+		text:     lower,
+		script:   make([]byte, len(lower)/2),
+		wildcard: make([]bool, len(lower)/2),
+	}
+	for i := range t.script {
+		digits := lower[2*i : 2*i+2]
+		if digits == scriptWildcard {
+			t.wildcard[i] = true
+			continue
+		}
+		b, err := hex.DecodeString(digits)
+		if err != nil {
+			return nil, fmt.Errorf("script template %q: invalid "+
+				"byte %q", text, digits)
+		}
+		t.script[i] = b[0]
+	}
+	return t, nil
+}
+
+// matches returns whether the passed script matches the template.
+func (t *scriptTemplate) matches(script []byte) bool {
+	if len(script) != len(t.script) {
+		return false
+	}
+	for i, b := range script {
+		if !t.wildcard[i] && b != t.script[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Denylist is a set of transaction hashes and output script templates of
+// transactions the pool refuses to accept, and therefore to relay and to mine,
+// regardless of their validity.  It only applies to the relay policy: blocks
+// which include such transactions are validated as usual.
+type Denylist struct {
+	txHashes  map[chainhash.Hash]struct{}
+	templates []*scriptTemplate
+}
+
+// NewDenylist returns a denylist of the passed transaction hashes and hex
+// encoded output script templates, where each ?? matches any byte.
+func NewDenylist(txHashes, scriptTemplates []string) (*Denylist, error) {
+	d := &Denylist{
+		txHashes: make(map[chainhash.Hash]struct{}, len(txHashes)),
+	}
+	for _, s := range txHashes {
+		if len(s) != 2*chainhash.HashSize {
+			return nil, fmt.Errorf("invalid transaction hash %q", s)
+		}
+		hash, err := chainhash.NewHashFromStr(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transaction hash %q", s)
+		}
+		d.txHashes[*hash] = struct{}{}
+	}
+	for _, s := range scriptTemplates {
+		t, err := parseScriptTemplate(s)
+		if err != nil {
+			return nil, err
+		}
+		d.templates = append(d.templates, t)
+	}
+	return d, nil
+}
+
+// IsEmpty returns whether the denylist rejects no transaction.
+func (d *Denylist) IsEmpty() bool {
+	return d == nil || (len(d.txHashes) == 0 && len(d.templates) == 0)
+}
+
+// match returns the reason the passed transaction is denied, or an empty string
+// when it is not.
+func (d *Denylist) match(tx *czzutil.Tx) string {
+	if d.IsEmpty() {
+		return ""
+	}
+	if _, ok := d.txHashes[*tx.Hash()]; ok {
+		return "transaction hash is denylisted"
+	}
+	for i, txOut := range tx.MsgTx().TxOut {
+		for _, t := range d.templates {
+			if t.matches(txOut.PkScript) {
+				return fmt.Sprintf("output %d matches denylisted "+
+					"script template %s", i, t.text)
+			}
+		}
+	}
+	return ""
+}
@@ -14,7 +14,6 @@ import (
 	"github.com/bourbaki-czz/classzz/cross"
 	"github.com/dchest/siphash"
 	"math"
-	"sync"
 	"sync/atomic"
 	"time"
 
@@ -23,6 +22,7 @@ import (
 	"github.com/bourbaki-czz/classzz/btcjson"
 	"github.com/bourbaki-czz/classzz/chaincfg"
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/lockstat"
 	"github.com/bourbaki-czz/classzz/mining"
 	"github.com/bourbaki-czz/classzz/txscript"
 	"github.com/bourbaki-czz/classzz/wire"
@@ -51,6 +51,16 @@ const (
 // so that orphans can be identified by which peer first relayed them.
 type Tag uint64
 
+// EntangleVerifier verifies the entangled outputs of entangle transactions
+// against the nodes of the chains the coins were entangled from.  It is
+// implemented by cross.EntangleVerify.
+type EntangleVerifier interface {
+	// VerifyEntangleTx returns the public keys the entangled outputs of
+	// the passed transaction were locked by, or an error when they do not
+	// verify.  A cross.ClientError means a node could not answer.
+	VerifyEntangleTx(tx *wire.MsgTx) ([]*cross.TuplePubIndex, error)
+}
+
 // Config is a descriptor containing the memory pool configuration.
 type Config struct {
 	// Policy defines the various mempool configuration options related
@@ -67,7 +77,11 @@ type Config struct {
 
 	FetchEntangleUtxoView func(info *cross.EntangleTxInfo) bool
 
-	EntangleVerify *cross.EntangleVerify
+	// EntangleVerify verifies the entangled outputs of the entangle
+	// transactions against the nodes of the chains the coins were
+	// entangled from.
+	EntangleVerify EntangleVerifier
+
 	// BestHeight defines the function to use to access the block height of
 	// the current best chain.
 	BestHeight func() int32
@@ -88,6 +102,13 @@ type Config struct {
 	// into the mempool or not.
 	IsDeploymentActive func(deploymentID uint32) (bool, error)
 
+	// CheckTransactionScripts defines the function to use to validate the
+	// scripts of a transaction with the consensus flags of the next block,
+	// so the validation is skipped once the transaction is in a block.
+	// This can be nil if the scripts are only validated with the policy
+	// flags.
+	CheckTransactionScripts func(*czzutil.Tx, *blockchain.UtxoViewpoint) error
+
 	// SigCache defines a signature cache to use.
 	SigCache *txscript.SigCache
 
@@ -142,6 +163,20 @@ type Policy struct {
 	// MinRelayTxFee defines the minimum transaction fee in CZZ/kB to be
 	// considered a non-zero fee.
 	MinRelayTxFee czzutil.Amount
+
+	// MaxDataCarrierSize is the maximum number of bytes a null data output
+	// script may carry for the transaction to be considered standard.
+	// Entangle and keeped-amount outputs are not subject to this limit.
+	MaxDataCarrierSize int
+
+	// MaxDataCarrierOutputs is the maximum number of null data outputs a
+	// transaction may have to be considered standard.  A value of zero
+	// disables relaying transactions with null data outputs.
+	MaxDataCarrierOutputs int
+
+	// Denylist holds the transactions which are rejected regardless of
+	// their validity.  It may be nil and is replaced with SetDenylist.
+	Denylist *Denylist
 }
 
 // TxDesc is a descriptor containing a transaction in the mempool along with
@@ -168,9 +203,10 @@ type orphanTx struct {
 // peers.
 type TxPool struct {
 	// The following variables must only be used atomically.
-	lastUpdated int64 // last time pool was updated
+	lastUpdated int64  // last time pool was updated
+	sequence    uint64 // number of changes to the pool
 
-	mtx           sync.RWMutex
+	mtx           lockstat.RWMutex
 	cfg           Config
 	pool          map[chainhash.Hash]*TxDesc
 	entanglepool  map[string]*TxDesc
@@ -496,6 +532,7 @@ func (mp *TxPool) removeTransaction(tx *czzutil.Tx, removeRedeemers bool) {
 		}
 
 		atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+		atomic.AddUint64(&mp.sequence, 1)
 	}
 }
 
@@ -512,6 +549,97 @@ func (mp *TxPool) RemoveTransaction(tx *czzutil.Tx, removeRedeemers bool) {
 	mp.mtx.Unlock()
 }
 
+// SetDenylist replaces the denylist of the policy and removes the transactions
+// it rejects from the pool and the orphan pool, along with the transactions
+// which redeem their outputs.  The number of removed denylisted transactions is
+// returned.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) SetDenylist(denylist *Denylist) int {
+	// Protect concurrent access.
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	mp.cfg.Policy.Denylist = denylist
+	var numRemoved int
+	for _, txDesc := range mp.pool {
+		if reason := denylist.match(txDesc.Tx); reason != "" {
+			log.Infof("Denylist removed transaction %v: %s",
+				txDesc.Tx.Hash(), reason)
+			mp.removeTransaction(txDesc.Tx, true)
+			numRemoved++
+		}
+	}
+	for _, otx := range mp.orphans {
+		if reason := denylist.match(otx.tx); reason != "" {
+			log.Infof("Denylist removed orphan transaction %v: %s",
+				otx.tx.Hash(), reason)
+			mp.removeOrphan(otx.tx, true)
+			numRemoved++
+		}
+	}
+	return numRemoved
+}
+
+// RevalidateEntangles verifies the entangled outputs of the entangle
+// transactions of the passed type in the pool again and removes the ones which
+// no longer verify, along with the transactions which redeem their outputs, so
+// the block templates do not include entangles which fail the validation of
+// the block after the chain they were entangled from reorganized.  The
+// transactions a node could not be asked about are kept, they are verified
+// again by the next reorganization or when they are mined.  The removed
+// entangle transactions are returned.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) RevalidateEntangles(exTxType cross.ExpandedTxType) []*czzutil.Tx {
+	mp.mtx.RLock()
+	var entangles []*czzutil.Tx
+	for _, txDesc := range mp.pool {
+		einfos, _ := cross.IsEntangleTx(txDesc.Tx.MsgTx())
+		for _, v := range einfos {
+			if v.ExTxType == exTxType {
+				entangles = append(entangles, txDesc.Tx)
+				break
+			}
+		}
+	}
+	mp.mtx.RUnlock()
+
+	// The entangled outputs are verified against the nodes of the other
+	// chain without holding the lock so the pool is not blocked meanwhile.
+	failed := make(map[*czzutil.Tx]error)
+	for _, tx := range entangles {
+		_, err := mp.cfg.EntangleVerify.VerifyEntangleTx(tx.MsgTx())
+		if cross.IsClientError(err) {
+			log.Warnf("Unable to verify entangle transaction %v "+
+				"again: %v", tx.Hash(), err)
+			continue
+		}
+		if err != nil {
+			failed[tx] = err
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	removed := make([]*czzutil.Tx, 0, len(failed))
+	for tx, err := range failed {
+		// Skip the transactions mined or removed meanwhile.
+		if _, exists := mp.pool[*tx.Hash()]; !exists {
+			continue
+		}
+		log.Infof("Removed entangle transaction %v which no longer "+
+			"verifies: %v", tx.Hash(), err)
+		mp.removeTransaction(tx, true)
+		removed = append(removed, tx)
+	}
+	return removed
+}
+
 // RemoveDoubleSpends removes all transactions which spend outputs spent by the
 // passed transaction from the memory pool.  Removing those transactions then
 // leads to removing all transactions which rely on them, recursively.  This is
@@ -565,6 +693,7 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *czzutil
 		mp.outpoints[txIn.PreviousOutPoint] = tx
 	}
 	atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+	atomic.AddUint64(&mp.sequence, 1)
 
 	// Add unconfirmed address index entries associated with the transaction
 	// if enabled.
@@ -747,6 +876,20 @@ func (mp *TxPool) maybeAcceptTransaction(tx *czzutil.Tx, isNew, rateLimit, rejec
 			txscript.ScriptVerifyCheckDataSig
 	}
 
+	// Once the sigchecks deployment is active, limit transactions by the
+	// number of signature checks their scripts execute rather than by
+	// their signature operation count.
+	if mp.cfg.IsDeploymentActive != nil {
+		sigChecksActive, err := mp.cfg.IsDeploymentActive(
+			chaincfg.DeploymentSigChecks)
+		if err != nil {
+			return nil, nil, err
+		}
+		if sigChecksActive {
+			scriptFlags |= txscript.ScriptVerifySigChecks
+		}
+	}
+
 	// Perform preliminary sanity checks on the transaction.  This makes
 	// use of blockchain which contains the invariant rules for what
 	// transactions are allowed into blocks.
@@ -765,12 +908,22 @@ func (mp *TxPool) maybeAcceptTransaction(tx *czzutil.Tx, isNew, rateLimit, rejec
 		return nil, nil, txRuleError(wire.RejectInvalid, str)
 	}
 
+	// Don't accept the transactions the operator denylisted.  The
+	// rejections are logged for auditing.
+	if reason := mp.cfg.Policy.Denylist.match(tx); reason != "" {
+		log.Infof("Denylist rejected transaction %v: %s", txHash, reason)
+		str := fmt.Sprintf("transaction %v is denylisted: %s", txHash,
+			reason)
+		return nil, nil, txRuleError(wire.RejectNonstandard, str)
+	}
+
 	// Don't allow non-standard transactions if the network parameters
 	// forbid their acceptance.
 	if !mp.cfg.Policy.AcceptNonStd {
 		err = checkTransactionStandard(tx, nextBlockHeight,
 			medianTimePast, mp.cfg.Policy.MinRelayTxFee,
-			mp.cfg.Policy.MaxTxVersion)
+			mp.cfg.Policy.MaxTxVersion, mp.cfg.Policy.MaxDataCarrierSize,
+			mp.cfg.Policy.MaxDataCarrierOutputs)
 		if err != nil {
 			// Attempt to extract a reject code from the error so
 			// it can be retained.  When not possible, fall back to
@@ -920,7 +1073,9 @@ func (mp *TxPool) maybeAcceptTransaction(tx *czzutil.Tx, isNew, rateLimit, rejec
 		}
 		return nil, nil, err
 	}
-	if sigOps > mp.cfg.Policy.MaxSigOpPerTx {
+	if !scriptFlags.HasFlag(txscript.ScriptVerifySigChecks) &&
+		sigOps > mp.cfg.Policy.MaxSigOpPerTx {
+
 		str := fmt.Sprintf("transaction %v sigop cost is too high: %d > %d",
 			txHash, sigOps, mp.cfg.Policy.MaxSigOpPerTx)
 		return nil, nil, txRuleError(wire.RejectNonstandard, str)
@@ -998,6 +1153,19 @@ func (mp *TxPool) maybeAcceptTransaction(tx *czzutil.Tx, isNew, rateLimit, rejec
 		return nil, nil, err
 	}
 
+	// Validate them again with the consensus flags, which is mostly served
+	// by the signature cache, to remember them for when the transaction is
+	// in a block.
+	if mp.cfg.CheckTransactionScripts != nil {
+		err = mp.cfg.CheckTransactionScripts(tx, utxoView)
+		if err != nil {
+			if cerr, ok := err.(blockchain.RuleError); ok {
+				return nil, nil, chainRuleError(cerr)
+			}
+			return nil, nil, err
+		}
+	}
+
 	// Add to transaction pool.
 	txD := mp.addTransaction(utxoView, tx, bestHeight, txFee)
 
@@ -1321,6 +1489,15 @@ func (mp *TxPool) LastUpdated() time.Time {
 	return time.Unix(atomic.LoadInt64(&mp.lastUpdated), 0)
 }
 
+// Sequence returns the number of times a transaction was added to or removed
+// from the main pool.  Unlike LastUpdated, it tells apart the updates made
+// within the same second.  It does not include the orphan pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Sequence() uint64 {
+	return atomic.LoadUint64(&mp.sequence)
+}
+
 // DecodeCompressedBlock takes in a block interface and attempts to decode it
 // according to know block compressing algorithms. If successful it returns
 // the complete block.
@@ -1415,7 +1592,7 @@ func (mp *TxPool) DecodeCompressedBlock(iBlock interface{}) (*wire.MsgBlock, err
 // New returns a new memory pool for validating and storing standalone
 // transactions until they are mined into a block.
 func New(cfg *Config) *TxPool {
-	return &TxPool{
+	mp := &TxPool{
 		cfg:            *cfg,
 		pool:           make(map[chainhash.Hash]*TxDesc),
 		entanglepool:   make(map[string]*TxDesc),
@@ -1424,4 +1601,6 @@ func New(cfg *Config) *TxPool {
 		nextExpireScan: time.Now().Add(orphanExpireScanInterval),
 		outpoints:      make(map[wire.OutPoint]*czzutil.Tx),
 	}
+	mp.mtx.SetName("mempool")
+	return mp
 }
@@ -6,9 +6,12 @@ package mempool
 
 import (
 	"encoding/hex"
+	"errors"
 	"github.com/bourbaki-czz/classzz/mining"
+	"math/big"
 	"reflect"
 	"runtime"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -16,6 +19,7 @@ import (
 	"github.com/bourbaki-czz/classzz/blockchain"
 	"github.com/bourbaki-czz/classzz/chaincfg"
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/cross"
 	"github.com/bourbaki-czz/classzz/czzec"
 	"github.com/bourbaki-czz/classzz/txscript"
 	"github.com/bourbaki-czz/classzz/wire"
@@ -312,13 +316,15 @@ func newPoolHarness(chainParams *chaincfg.Params) (*poolHarness, []spendableOutp
 		chain: chain,
 		txPool: New(&Config{
 			Policy: Policy{
-				DisableRelayPriority: true,
-				FreeTxRelayLimit:     15.0,
-				MaxOrphanTxs:         5,
-				MaxOrphanTxSize:      1000,
-				MaxSigOpPerTx:        blockchain.MaxTransactionSigOps,
-				MinRelayTxFee:        1000, // 1 Satoshi per byte
-				MaxTxVersion:         1,
+				DisableRelayPriority:  true,
+				FreeTxRelayLimit:      15.0,
+				MaxOrphanTxs:          5,
+				MaxOrphanTxSize:       1000,
+				MaxSigOpPerTx:         blockchain.MaxTransactionSigOps,
+				MinRelayTxFee:         1000, // 1 Satoshi per byte
+				MaxTxVersion:          1,
+				MaxDataCarrierSize:    DefaultMaxDataCarrierSize,
+				MaxDataCarrierOutputs: DefaultMaxDataCarrierOutputs,
 			},
 			ChainParams:      chainParams,
 			FetchUtxoView:    chain.FetchUtxoView,
@@ -870,6 +876,98 @@ func TestCheckSpend(t *testing.T) {
 	}
 }
 
+// TestDenylist ensures transactions matching the denylist are rejected and
+// that replacing it removes the matching transactions and their redeemers from
+// the pool.
+func TestDenylist(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	const txChainLength = 3
+	chainedTxns, err := harness.CreateTxChain(outputs[0], txChainLength)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	for _, tx := range chainedTxns {
+		_, err := harness.txPool.ProcessTransaction(tx, true, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept tx: %v",
+				err)
+		}
+	}
+
+	// Denylisting the second transaction removes it and the transaction
+	// spending it, but not the transaction it spends.
+	denylist, err := NewDenylist([]string{chainedTxns[1].Hash().String()},
+		nil)
+	if err != nil {
+		t.Fatalf("NewDenylist: unexpected error: %v", err)
+	}
+	if n := harness.txPool.SetDenylist(denylist); n != 1 {
+		t.Fatalf("SetDenylist: removed %d transactions, want 1", n)
+	}
+	testPoolMembership(tc, chainedTxns[0], false, true)
+	testPoolMembership(tc, chainedTxns[1], false, false)
+	testPoolMembership(tc, chainedTxns[2], false, false)
+
+	// The denylisted transaction is rejected as non-standard.
+	_, err = harness.txPool.ProcessTransaction(chainedTxns[1], true, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectNonstandard {
+		t.Fatalf("ProcessTransaction: got error %v, want reject code %v",
+			err, wire.RejectNonstandard)
+	}
+
+	// A template matching the pay-to-pubkey-hash script of the harness
+	// with any hash removes the remaining transaction.
+	denylist, err = NewDenylist(nil, []string{"76a914" +
+		strings.Repeat(scriptWildcard, 20) + "88ac"})
+	if err != nil {
+		t.Fatalf("NewDenylist: unexpected error: %v", err)
+	}
+	if n := harness.txPool.SetDenylist(denylist); n != 1 {
+		t.Fatalf("SetDenylist: removed %d transactions, want 1", n)
+	}
+	testPoolMembership(tc, chainedTxns[0], false, false)
+
+	// Clearing the denylist accepts the transactions again.
+	harness.txPool.SetDenylist(nil)
+	for _, tx := range chainedTxns {
+		_, err := harness.txPool.ProcessTransaction(tx, true, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept tx: %v",
+				err)
+		}
+	}
+}
+
+// TestNewDenylistErrors ensures invalid transaction hashes and script templates
+// are rejected.
+func TestNewDenylistErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		txHashes  []string
+		templates []string
+	}{
+		{name: "short hash", txHashes: []string{"00ff"}},
+		{name: "non hex hash", txHashes: []string{strings.Repeat("zz", 32)}},
+		{name: "empty template", templates: []string{""}},
+		{name: "odd template", templates: []string{"76a"}},
+		{name: "non hex template", templates: []string{"76?a"}},
+	}
+	for _, test := range tests {
+		if _, err := NewDenylist(test.txHashes, test.templates); err == nil {
+			t.Errorf("%s: NewDenylist did not return an error", test.name)
+		}
+	}
+}
+
 // TestTxPool_DecodeCompressedBlock tests that a compact block is decoded
 // correctly against the mempool.
 func TestTxPool_DecodeCompressedBlock(t *testing.T) {
@@ -938,3 +1036,172 @@ func TestTxPool_DecodeCompressedBlock(t *testing.T) {
 		}
 	}
 }
+
+// TestSequence ensures the sequence of the pool counts the transactions added to
+// and removed from the main pool, but not the orphan pool.
+func TestSequence(t *testing.T) {
+	t.Parallel()
+
+	harness, spendableOuts, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	chainedTxns, err := harness.CreateTxChain(spendableOuts[0], 3)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	checkSequence := func(want uint64) {
+		t.Helper()
+		if got := harness.txPool.Sequence(); got != want {
+			t.Fatalf("Sequence: got %d, want %d", got, want)
+		}
+	}
+	checkSequence(0)
+
+	// The orphan does not change the main pool.
+	_, err = harness.txPool.ProcessTransaction(chainedTxns[2], true, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept valid orphan %v",
+			err)
+	}
+	checkSequence(0)
+
+	// Both transactions are added once the first one is.
+	_, err = harness.txPool.ProcessTransaction(chainedTxns[1], true, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept valid orphan %v",
+			err)
+	}
+	_, err = harness.txPool.ProcessTransaction(chainedTxns[0], true, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept valid tx %v", err)
+	}
+	checkSequence(3)
+
+	// Removing the transaction along with its redeemers removes them all.
+	harness.txPool.RemoveTransaction(chainedTxns[0], true)
+	checkSequence(6)
+}
+
+// fakeEntangleVerifier is an entangle verifier which fails the entangles from
+// the foreign transactions with an error set.
+type fakeEntangleVerifier struct {
+	errs map[string]error
+}
+
+func (v *fakeEntangleVerifier) VerifyEntangleTx(tx *wire.MsgTx) ([]*cross.TuplePubIndex, error) {
+	einfos, err := cross.IsEntangleTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range einfos {
+		if err := v.errs[string(info.ExtTxHash)]; err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// TestRevalidateEntangles ensures revalidating the entangles removes the ones
+// which fail to verify along with their redeemers, and keeps the ones which
+// verify or which a node could not be asked about.
+func TestRevalidateEntangles(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+	verifier := &fakeEntangleVerifier{errs: make(map[string]error)}
+	harness.txPool.cfg.EntangleVerify = verifier
+
+	splitTx, err := harness.CreateSignedTx(outputs, 3)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+
+	// entangle returns a transaction spending the passed output which
+	// entangles the coins of the passed doge transaction.
+	entangle := func(output spendableOutput, extTxHash string) *czzutil.Tx {
+		script, err := txscript.NewEntangleScript(&txscript.EntangleData{
+			ExTxType:  txscript.EntangleTypeDoge,
+			Height:    100,
+			Amount:    big.NewInt(1e8),
+			ExtTxHash: []byte(extTxHash),
+		})
+		if err != nil {
+			t.Fatalf("NewEntangleScript: unexpected error: %v", err)
+		}
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: output.outPoint,
+			Sequence:         wire.MaxTxInSequenceNum,
+		})
+		tx.AddTxOut(&wire.TxOut{PkScript: script})
+		tx.AddTxOut(&wire.TxOut{
+			PkScript: harness.payScript,
+			Value:    int64(output.amount),
+		})
+		return czzutil.NewTx(tx)
+	}
+	invalidHash := strings.Repeat("b", txscript.EntangleExtTxHashSize)
+	unverifiedHash := strings.Repeat("c", txscript.EntangleExtTxHashSize)
+	verified := entangle(txOutToSpendableOut(splitTx, 0),
+		strings.Repeat("a", txscript.EntangleExtTxHashSize))
+	invalid := entangle(txOutToSpendableOut(splitTx, 1), invalidHash)
+	unverified := entangle(txOutToSpendableOut(splitTx, 2), unverifiedHash)
+	redeemers, err := harness.CreateTxChain(txOutToSpendableOut(invalid, 1), 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+
+	// The entangles are verified when they are accepted, which is not what
+	// is tested, so the transactions are added directly.
+	harness.txPool.mtx.Lock()
+	for _, tx := range []*czzutil.Tx{splitTx, verified, invalid, unverified,
+		redeemers[0]} {
+		harness.txPool.addTransaction(harness.chain.utxos, tx,
+			harness.chain.BestHeight(), 1000)
+	}
+	harness.txPool.mtx.Unlock()
+
+	verifier.errs[invalidHash] = errors.New("doge TxOut index err")
+	verifier.errs[unverifiedHash] = &cross.ClientError{
+		ExTxType: cross.ExpandedTxEntangle_Doge,
+		Err:      errors.New("connection refused"),
+	}
+
+	// Only the entangle which fails to verify is removed, along with the
+	// transaction redeeming it.
+	removed := harness.txPool.RevalidateEntangles(cross.ExpandedTxEntangle_Doge)
+	if len(removed) != 1 || removed[0] != invalid {
+		t.Fatalf("RevalidateEntangles: removed %v, want %v", removed,
+			invalid.Hash())
+	}
+	testPoolMembership(tc, splitTx, false, true)
+	testPoolMembership(tc, verified, false, true)
+	testPoolMembership(tc, invalid, false, false)
+	testPoolMembership(tc, redeemers[0], false, false)
+	testPoolMembership(tc, unverified, false, true)
+
+	// The other entangle types are not verified.
+	verifier.errs = nil
+	removed = harness.txPool.RevalidateEntangles(cross.ExpandedTxEntangle_Ltc)
+	if len(removed) != 0 {
+		t.Fatalf("RevalidateEntangles: removed %v, want none", removed)
+	}
+
+	// The entangle the node could not be asked about is removed once the
+	// node answers that it fails to verify.
+	verifier.errs = map[string]error{
+		unverifiedHash: errors.New("dogeMaturity err"),
+	}
+	removed = harness.txPool.RevalidateEntangles(cross.ExpandedTxEntangle_Doge)
+	if len(removed) != 1 || removed[0] != unverified {
+		t.Fatalf("RevalidateEntangles: removed %v, want %v", removed,
+			unverified.Hash())
+	}
+	testPoolMembership(tc, verified, false, true)
+	testPoolMembership(tc, unverified, false, false)
+}
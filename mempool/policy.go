@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/czzec"
 	"github.com/bourbaki-czz/classzz/txscript"
 	"github.com/bourbaki-czz/classzz/wire"
 	"github.com/bourbaki-czz/czzutil"
@@ -52,6 +53,15 @@ const (
 
 	// maxStandardTxSize is the maximum size of a transaction
 	maxStandardTxSize = 1000000
+
+	// DefaultMaxDataCarrierSize is the default maximum number of bytes a
+	// null data output script may carry for the transaction to be
+	// considered standard.
+	DefaultMaxDataCarrierSize = txscript.MaxDataCarrierSize
+
+	// DefaultMaxDataCarrierOutputs is the default maximum number of null
+	// data outputs a standard transaction may have.
+	DefaultMaxDataCarrierOutputs = 1
 )
 
 // calcMinRequiredTxRelayFee returns the minimum transaction fee required for a
@@ -125,7 +135,7 @@ func checkInputsStandard(tx *czzutil.Tx, utxoView *blockchain.UtxoViewpoint, scr
 // script (public key script) to ensure it is a "standard" public key script.
 // A standard public key script is one that is a recognized form, and for
 // multi-signature scripts, only contains from 1 to maxStandardMultiSigKeys
-// public keys.
+// public keys, and for vault scripts, only contains valid public keys.
 func checkPkScriptStandard(pkScript []byte, scriptClass txscript.ScriptClass) error {
 	switch scriptClass {
 	case txscript.MultiSigTy:
@@ -163,6 +173,24 @@ func checkPkScriptStandard(pkScript []byte, scriptClass txscript.ScriptClass) er
 			return txRuleError(wire.RejectNonstandard, str)
 		}
 
+	case txscript.VaultTy:
+		// A standard vault script must lock the coins to valid public
+		// keys so both of its spend paths remain usable.
+		pushes, err := txscript.ExtractVaultDataPushes(pkScript)
+		if err != nil || pushes == nil {
+			str := fmt.Sprintf("vault script parse failure: %v", err)
+			return txRuleError(wire.RejectNonstandard, str)
+		}
+		for _, pubKey := range [][]byte{pushes.OwnerPubKey,
+			pushes.RecoveryPubKey} {
+
+			if _, err := czzec.ParsePubKey(pubKey, czzec.S256()); err != nil {
+				str := fmt.Sprintf("vault script with an invalid "+
+					"public key: %v", err)
+				return txRuleError(wire.RejectNonstandard, str)
+			}
+		}
+
 	case txscript.NonStandardTy:
 		return txRuleError(wire.RejectNonstandard,
 			"non-standard script form")
@@ -171,6 +199,13 @@ func checkPkScriptStandard(pkScript []byte, scriptClass txscript.ScriptClass) er
 	return nil
 }
 
+// IsDust returns whether or not the passed transaction output amount is
+// considered dust based on the passed minimum transaction relay fee.  It allows
+// callers creating transactions to avoid outputs the mempool would reject.
+func IsDust(txOut *wire.TxOut, minRelayTxFee czzutil.Amount) bool {
+	return isDust(txOut, minRelayTxFee)
+}
+
 // isDust returns whether or not the passed transaction output amount is
 // considered dust or not based on the passed minimum transaction relay fee.
 // Dust is defined in terms of the minimum transaction relay fee.  In
@@ -254,7 +289,7 @@ func isDust(txOut *wire.TxOut, minRelayTxFee czzutil.Amount) bool {
 // so small it costs more to process them than they are worth).
 func checkTransactionStandard(tx *czzutil.Tx, height int32,
 	medianTimePast time.Time, minRelayTxFee czzutil.Amount,
-	maxTxVersion int32) error {
+	maxTxVersion int32, maxDataCarrierSize, maxDataCarrierOutputs int) error {
 
 	// The transaction must be a currently supported version.
 	msgTx := tx.MsgTx()
@@ -310,6 +345,23 @@ func checkTransactionStandard(tx *czzutil.Tx, height int32,
 	numNullDataOutputs := 0
 	numEntangleTyOutputs := 0
 	for i, txOut := range msgTx.TxOut {
+		// Null data scripts are limited by the configured data carrier
+		// size rather than the default one used to classify scripts,
+		// so they are handled before the script class is considered.
+		// Entangle and keeped-amount scripts are never counted as data
+		// carriers.
+		if dataLen, ok := txscript.DataCarrierSize(txOut.PkScript); ok {
+			if dataLen > maxDataCarrierSize {
+				str := fmt.Sprintf("transaction output %d: data "+
+					"carrier size of %d bytes is larger than "+
+					"max allowed size of %d bytes", i, dataLen,
+					maxDataCarrierSize)
+				return txRuleError(wire.RejectNonstandard, str)
+			}
+			numNullDataOutputs++
+			continue
+		}
+
 		scriptClass := txscript.GetScriptClass(txOut.PkScript)
 		err := checkPkScriptStandard(txOut.PkScript, scriptClass)
 		if err != nil {
@@ -327,9 +379,7 @@ func checkTransactionStandard(tx *czzutil.Tx, height int32,
 		// Accumulate the number of outputs which only carry data.  For
 		// all other script types, ensure the output value is not
 		// "dust".
-		if scriptClass == txscript.NullDataTy {
-			numNullDataOutputs++
-		} else if scriptClass == txscript.EntangleTy {
+		if scriptClass == txscript.EntangleTy {
 			numEntangleTyOutputs++
 		} else if isDust(txOut, minRelayTxFee) {
 			str := fmt.Sprintf("transaction output %d: payment "+
@@ -338,10 +388,12 @@ func checkTransactionStandard(tx *czzutil.Tx, height int32,
 		}
 	}
 
-	// A standard transaction must not have more than one output script that
-	// only carries data.
-	if numNullDataOutputs > 1 {
-		str := "more than one transaction output in a nulldata script"
+	// A standard transaction must not have more output scripts that only
+	// carry data than the configured maximum.
+	if numNullDataOutputs > maxDataCarrierOutputs {
+		str := fmt.Sprintf("%d transaction outputs in a nulldata "+
+			"script which is more than the allowed max of %d",
+			numNullDataOutputs, maxDataCarrierOutputs)
 		return txRuleError(wire.RejectNonstandard, str)
 	}
 
@@ -183,6 +183,36 @@ func TestCheckPkScriptStandard(t *testing.T) {
 				AddData(pubKeys[0]).AddData(pubKeys[1]),
 			false,
 		},
+		{
+			"vault",
+			txscript.NewScriptBuilder().AddOp(txscript.OP_IF).
+				AddData(pubKeys[1]).AddOp(txscript.OP_CHECKSIG).
+				AddOp(txscript.OP_ELSE).AddInt64(500000).
+				AddOp(txscript.OP_CHECKLOCKTIMEVERIFY).
+				AddOp(txscript.OP_DROP).AddData(pubKeys[0]).
+				AddOp(txscript.OP_CHECKSIG).AddOp(txscript.OP_ENDIF),
+			true,
+		},
+		{
+			"vault with an invalid recovery key",
+			txscript.NewScriptBuilder().AddOp(txscript.OP_IF).
+				AddData(make([]byte, 33)).AddOp(txscript.OP_CHECKSIG).
+				AddOp(txscript.OP_ELSE).AddInt64(500000).
+				AddOp(txscript.OP_CHECKLOCKTIMEVERIFY).
+				AddOp(txscript.OP_DROP).AddData(pubKeys[0]).
+				AddOp(txscript.OP_CHECKSIG).AddOp(txscript.OP_ENDIF),
+			false,
+		},
+		{
+			"vault without lock time",
+			txscript.NewScriptBuilder().AddOp(txscript.OP_IF).
+				AddData(pubKeys[1]).AddOp(txscript.OP_CHECKSIG).
+				AddOp(txscript.OP_ELSE).AddOp(txscript.OP_0).
+				AddOp(txscript.OP_CHECKLOCKTIMEVERIFY).
+				AddOp(txscript.OP_DROP).AddData(pubKeys[0]).
+				AddOp(txscript.OP_CHECKSIG).AddOp(txscript.OP_ENDIF),
+			false,
+		},
 	}
 
 	for _, test := range tests {
@@ -536,7 +566,8 @@ func TestCheckTransactionStandard(t *testing.T) {
 	for _, test := range tests {
 		// Ensure standardness is as expected.
 		err := checkTransactionStandard(czzutil.NewTx(&test.tx),
-			test.height, pastMedianTime, DefaultMinRelayTxFee, 1)
+			test.height, pastMedianTime, DefaultMinRelayTxFee, 1,
+			DefaultMaxDataCarrierSize, DefaultMaxDataCarrierOutputs)
 		if err == nil && test.isStandard {
 			// Test passes since function returned standard for a
 			// transaction which is intended to be standard.
@@ -576,3 +607,121 @@ func TestCheckTransactionStandard(t *testing.T) {
 		}
 	}
 }
+
+// TestCheckTransactionStandardDataCarrier ensures the configured data carrier
+// size and output count are applied to null data outputs while entangle
+// outputs are not subject to them.
+func TestCheckTransactionStandardDataCarrier(t *testing.T) {
+	prevOutHash, err := chainhash.NewHashFromStr("01")
+	if err != nil {
+		t.Fatalf("NewShaHashFromStr: unexpected error: %v", err)
+	}
+	dummyTxIn := wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: *prevOutHash},
+		SignatureScript:  []byte{txscript.OP_TRUE},
+		Sequence:         wire.MaxTxInSequenceNum,
+	}
+
+	nullData := func(size int) []byte {
+		script, err := txscript.NewScriptBuilder().
+			AddOp(txscript.OP_RETURN).
+			AddFullData(make([]byte, size)).Script()
+		if err != nil {
+			t.Fatalf("NewScriptBuilder: unexpected error: %v", err)
+		}
+		return script
+	}
+	entangle, err := txscript.EntangleScript(make([]byte, 200))
+	if err != nil {
+		t.Fatalf("EntangleScript: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		pkScripts  [][]byte
+		maxSize    int
+		maxOutputs int
+		isStandard bool
+	}{
+		{
+			name:       "Large nulldata output within raised size",
+			pkScripts:  [][]byte{nullData(1000)},
+			maxSize:    1004,
+			maxOutputs: 1,
+			isStandard: true,
+		},
+		{
+			name:       "Large nulldata output exceeding raised size",
+			pkScripts:  [][]byte{nullData(1001)},
+			maxSize:    1004,
+			maxOutputs: 1,
+			isStandard: false,
+		},
+		{
+			name:       "Two nulldata outputs with raised count",
+			pkScripts:  [][]byte{nullData(10), nullData(10)},
+			maxSize:    DefaultMaxDataCarrierSize,
+			maxOutputs: 2,
+			isStandard: true,
+		},
+		{
+			name:       "Nulldata output with data carriers disabled",
+			pkScripts:  [][]byte{nullData(10)},
+			maxSize:    DefaultMaxDataCarrierSize,
+			maxOutputs: 0,
+			isStandard: false,
+		},
+		{
+			name:       "Entangle output with data carriers disabled",
+			pkScripts:  [][]byte{entangle},
+			maxSize:    0,
+			maxOutputs: 0,
+			isStandard: true,
+		},
+		{
+			name:       "Entangle output alongside nulldata output",
+			pkScripts:  [][]byte{entangle, nullData(10)},
+			maxSize:    DefaultMaxDataCarrierSize,
+			maxOutputs: 1,
+			isStandard: true,
+		},
+	}
+
+	pastMedianTime := time.Now()
+	for _, test := range tests {
+		tx := wire.MsgTx{
+			Version: 1,
+			TxIn:    []*wire.TxIn{&dummyTxIn},
+		}
+		for _, pkScript := range test.pkScripts {
+			tx.TxOut = append(tx.TxOut, &wire.TxOut{PkScript: pkScript})
+		}
+
+		err := checkTransactionStandard(czzutil.NewTx(&tx), 300000,
+			pastMedianTime, DefaultMinRelayTxFee, 1, test.maxSize,
+			test.maxOutputs)
+		if test.isStandard && err != nil {
+			t.Errorf("checkTransactionStandard (%s): nonstandard "+
+				"when it should not be: %v", test.name, err)
+			continue
+		}
+		if !test.isStandard {
+			if err == nil {
+				t.Errorf("checkTransactionStandard (%s): standard "+
+					"when it should not be", test.name)
+				continue
+			}
+			rerr, ok := err.(RuleError)
+			if !ok {
+				t.Errorf("checkTransactionStandard (%s): unexpected "+
+					"error type - got %T", test.name, err)
+				continue
+			}
+			txrerr, ok := rerr.Err.(TxRuleError)
+			if !ok || txrerr.RejectCode != wire.RejectNonstandard {
+				t.Errorf("checkTransactionStandard (%s): unexpected "+
+					"error %v", test.name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,549 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/cross"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/lockstat"
+	"github.com/bourbaki-czz/classzz/netsync"
+	"github.com/bourbaki-czz/classzz/peer"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// metricsContentType is the content type of the Prometheus text exposition
+// format the metrics are served in.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricsWriter writes metrics in the Prometheus text exposition format.
+type metricsWriter struct {
+	w io.Writer
+}
+
+// helpEscaper escapes the help text of a metric family.
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// labelEscaper escapes the value of a label.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// family writes the help text and type of a metric family.  The samples of the
+// family must be written right after it.
+func (mw *metricsWriter) family(name, metricType, help string) {
+	fmt.Fprintf(mw.w, "# HELP %s %s\n", name, helpEscaper.Replace(help))
+	fmt.Fprintf(mw.w, "# TYPE %s %s\n", name, metricType)
+}
+
+// sample writes a sample of the named metric.  The labels are passed as pairs
+// of names and values.
+func (mw *metricsWriter) sample(name string, value float64, labels ...string) {
+	io.WriteString(mw.w, name)
+	if len(labels) > 0 {
+		pairs := make([]string, 0, len(labels)/2)
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i],
+				labelEscaper.Replace(labels[i+1])))
+		}
+		fmt.Fprintf(mw.w, "{%s}", strings.Join(pairs, ","))
+	}
+	fmt.Fprintf(mw.w, " %s\n", formatMetricValue(value))
+}
+
+// formatMetricValue returns the passed value as it is written in the text
+// exposition format.
+func formatMetricValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// rpcLatencySamples is the number of the most recent requests of each RPC
+// method whose durations the latency percentiles are computed over.
+const rpcLatencySamples = 256
+
+// rpcQuantiles are the latency quantiles of the RPC requests which are
+// exported.
+var rpcQuantiles = []float64{0.5, 0.9, 0.99}
+
+// rpcMethodStats are the statistics of the requests of an RPC method.
+type rpcMethodStats struct {
+	requests uint64
+	errors   uint64
+	duration time.Duration
+
+	// recent is a ring of the durations of the most recent requests, the
+	// next of which is stored at index next.
+	recent [rpcLatencySamples]time.Duration
+	next   int
+}
+
+// quantiles returns the passed quantiles, between 0 and 1, of the durations of
+// the most recent requests using the nearest rank method.  They are all zero
+// when there was no request.
+func (s *rpcMethodStats) quantiles(qs []float64) []time.Duration {
+	n := rpcLatencySamples
+	if s.requests < rpcLatencySamples {
+		n = int(s.requests)
+	}
+	durations := make([]time.Duration, n)
+	copy(durations, s.recent[:n])
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
+	})
+
+	result := make([]time.Duration, len(qs))
+	if n == 0 {
+		return result
+	}
+	for i, q := range qs {
+		rank := int(math.Ceil(q*float64(n))) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		if rank >= n {
+			rank = n - 1
+		}
+		result[i] = durations[rank]
+	}
+	return result
+}
+
+// rpcStats keeps the statistics of the RPC requests by method.  It is safe for
+// concurrent access.
+type rpcStats struct {
+	mtx     sync.Mutex
+	methods map[string]*rpcMethodStats
+}
+
+// record adds a request of the passed method which took the passed time to
+// the statistics.
+func (r *rpcStats) record(method string, elapsed time.Duration, failed bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.methods == nil {
+		r.methods = make(map[string]*rpcMethodStats)
+	}
+	stats, ok := r.methods[method]
+	if !ok {
+		stats = &rpcMethodStats{}
+		r.methods[method] = stats
+	}
+	stats.requests++
+	if failed {
+		stats.errors++
+	}
+	stats.duration += elapsed
+	stats.recent[stats.next] = elapsed
+	stats.next = (stats.next + 1) % rpcLatencySamples
+}
+
+// snapshot returns a copy of the statistics by method.
+func (r *rpcStats) snapshot() map[string]rpcMethodStats {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	methods := make(map[string]rpcMethodStats, len(r.methods))
+	for method, stats := range r.methods {
+		methods[method] = *stats
+	}
+	return methods
+}
+
+// metricsServer serves the metrics of the node in the Prometheus text
+// exposition format at /metrics.
+type metricsServer struct {
+	server     *server
+	listeners  []net.Listener
+	httpServer http.Server
+}
+
+// newMetricsServer returns a metrics server serving the metrics of the passed
+// server on the passed listeners.
+func newMetricsServer(s *server, listeners []net.Listener) *metricsServer {
+	m := &metricsServer{
+		server:    s,
+		listeners: listeners,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", m.handleMetrics)
+	m.httpServer.Handler = mux
+	return m
+}
+
+// setupHTTPListeners returns the listeners on the passed addresses of an HTTP
+// server, such as the metrics one, skipping the addresses which can't be
+// listened on.
+func setupHTTPListeners(addrs []string) ([]net.Listener, error) {
+	netAddrs, err := parseListeners(addrs)
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := make([]net.Listener, 0, len(netAddrs))
+	for _, addr := range netAddrs {
+		listener, err := net.Listen(addr.Network(), addr.String())
+		if err != nil {
+			srvrLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// Start begins serving the metrics on the listeners of the server.
+func (m *metricsServer) Start() {
+	for _, listener := range m.listeners {
+		srvrLog.Infof("Metrics server listening on %s", listener.Addr())
+		go func(listener net.Listener) {
+			err := m.httpServer.Serve(listener)
+			if err != http.ErrServerClosed {
+				srvrLog.Errorf("Metrics server stopped serving on "+
+					"%s: %v", listener.Addr(), err)
+			}
+		}(listener)
+	}
+}
+
+// Stop closes the listeners and the connections of the server.
+func (m *metricsServer) Stop() {
+	m.httpServer.Close()
+}
+
+// handleMetrics serves a scrape of the metrics.
+func (m *metricsServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	m.writeMetrics(&metricsWriter{w: &buf})
+
+	w.Header().Set("Content-Type", metricsContentType)
+	w.Write(buf.Bytes())
+}
+
+// writeMetrics writes the current metrics of the node.
+func (m *metricsServer) writeMetrics(mw *metricsWriter) {
+	m.writeChainMetrics(mw)
+	m.writePeerMetrics(mw)
+	writeBlockPropagationMetrics(mw, m.server.syncManager.BlockLatencyStats())
+	m.writeMempoolMetrics(mw)
+	if m.server.rpcServer != nil {
+		writeRPCMetrics(mw, m.server.rpcServer.stats.snapshot())
+	}
+	writeEntangleMetrics(mw, m.server.chain.GetEntangleVerify().Stats())
+	m.writeDBMetrics(mw)
+	writeLockMetrics(mw, lockstat.Snapshot())
+}
+
+// writeChainMetrics writes the metrics of the state of the main chain.
+func (m *metricsServer) writeChainMetrics(mw *metricsWriter) {
+	best := m.server.chain.BestSnapshot()
+
+	mw.family("czzd_chain_height", "gauge",
+		"Height of the best block of the main chain.")
+	mw.sample("czzd_chain_height", float64(best.Height))
+
+	if header, err := m.server.chain.HeaderByHash(&best.Hash); err == nil {
+		mw.family("czzd_chain_best_block_timestamp_seconds", "gauge",
+			"Timestamp of the best block of the main chain.")
+		mw.sample("czzd_chain_best_block_timestamp_seconds",
+			float64(header.Timestamp.Unix()))
+	}
+
+	mw.family("czzd_chain_transactions", "gauge",
+		"Number of transactions in the main chain.")
+	mw.sample("czzd_chain_transactions", float64(best.TotalTxns))
+
+	// The progress is estimated the same way as by getblockchaininfo.
+	var progress float64
+	if syncHeight := m.server.syncManager.SyncHeight(); syncHeight > 0 {
+		progress = math.Min(float64(best.Height)/float64(syncHeight), 1.0)
+	}
+	mw.family("czzd_chain_verification_progress", "gauge",
+		"Estimated fraction of the chain of the peers which is verified.")
+	mw.sample("czzd_chain_verification_progress", progress)
+
+	var synced float64
+	if m.server.syncManager.IsCurrent() {
+		synced = 1
+	}
+	mw.family("czzd_chain_synced", "gauge",
+		"Whether the main chain is believed to be synced with the peers.")
+	mw.sample("czzd_chain_synced", synced)
+}
+
+// writePeerMetrics writes the metrics of the peers and the network traffic.
+func (m *metricsServer) writePeerMetrics(mw *metricsWriter) {
+	var inbound, outbound, bridge int
+	for _, sp := range m.server.connectedPeers() {
+		if sp.Inbound() {
+			inbound++
+		} else {
+			outbound++
+		}
+		if sp.permissions.Has(peer.PermissionBridge) {
+			bridge++
+		}
+	}
+	mw.family("czzd_peers", "gauge", "Number of connected peers.")
+	mw.sample("czzd_peers", float64(inbound), "direction", "inbound")
+	mw.sample("czzd_peers", float64(outbound), "direction", "outbound")
+
+	mw.family("czzd_bridge_peers", "gauge",
+		"Number of connected bridge peers.")
+	mw.sample("czzd_bridge_peers", float64(bridge))
+	mw.family("czzd_bridge_peer_disconnects_total", "counter",
+		"Disconnects of bridge peers.")
+	mw.sample("czzd_bridge_peer_disconnects_total",
+		float64(m.server.BridgeDisconnects()))
+
+	received, sent := m.server.NetTotals()
+	mw.family("czzd_network_received_bytes_total", "counter",
+		"Bytes received from all peers.")
+	mw.sample("czzd_network_received_bytes_total", float64(received))
+	mw.family("czzd_network_sent_bytes_total", "counter",
+		"Bytes sent to all peers.")
+	mw.sample("czzd_network_sent_bytes_total", float64(sent))
+}
+
+// writeBlockPropagationMetrics writes the metrics of the passed cumulated
+// latencies between the stages of the propagation of the blocks.
+func writeBlockPropagationMetrics(mw *metricsWriter, stats map[netsync.BlockLatency]netsync.LatencyStats) {
+	mw.family("czzd_block_propagation_seconds", "summary",
+		"Latencies between the stages of the propagation of the blocks "+
+			"to the node and their validation.")
+	for latency := netsync.LatencyInvToBlock; latency <= netsync.LatencyValidatedToAnnounced; latency++ {
+		stat := stats[latency]
+		mw.sample("czzd_block_propagation_seconds_sum",
+			stat.Total.Seconds(), "stage", latency.String())
+		mw.sample("czzd_block_propagation_seconds_count",
+			float64(stat.Count), "stage", latency.String())
+	}
+}
+
+// writeMempoolMetrics writes the metrics of the transaction memory pool.
+func (m *metricsServer) writeMempoolMetrics(mw *metricsWriter) {
+	descs := m.server.txMemPool.TxDescs()
+	var size int
+	var fees int64
+	for _, desc := range descs {
+		size += desc.Tx.MsgTx().SerializeSize()
+		fees += desc.Fee
+	}
+
+	mw.family("czzd_mempool_transactions", "gauge",
+		"Number of transactions in the mempool.")
+	mw.sample("czzd_mempool_transactions", float64(len(descs)))
+
+	mw.family("czzd_mempool_size_bytes", "gauge",
+		"Serialized size of the transactions in the mempool.")
+	mw.sample("czzd_mempool_size_bytes", float64(size))
+
+	mw.family("czzd_mempool_fees_czz", "gauge",
+		"Total fees of the transactions in the mempool in CZZ.")
+	mw.sample("czzd_mempool_fees_czz", czzutil.Amount(fees).ToCZZ())
+
+	mw.family("czzd_mempool_min_relay_fee_czz_per_kb", "gauge",
+		"Minimum fee rate of the transactions relayed in CZZ/kB.")
+	mw.sample("czzd_mempool_min_relay_fee_czz_per_kb",
+		cfg.minRelayTxFee.ToCZZ())
+}
+
+// writeRPCMetrics writes the metrics of the passed RPC request statistics by
+// method.
+func writeRPCMetrics(mw *metricsWriter, methods map[string]rpcMethodStats) {
+	names := make([]string, 0, len(methods))
+	for method := range methods {
+		names = append(names, method)
+	}
+	sort.Strings(names)
+
+	mw.family("czzd_rpc_request_duration_seconds", "summary",
+		"Time spent handling the RPC requests by method.  The quantiles "+
+			"are those of the most recent requests.")
+	for _, method := range names {
+		stats := methods[method]
+		for i, quantile := range stats.quantiles(rpcQuantiles) {
+			mw.sample("czzd_rpc_request_duration_seconds",
+				quantile.Seconds(), "method", method, "quantile",
+				formatMetricValue(rpcQuantiles[i]))
+		}
+		mw.sample("czzd_rpc_request_duration_seconds_sum",
+			stats.duration.Seconds(), "method", method)
+		mw.sample("czzd_rpc_request_duration_seconds_count",
+			float64(stats.requests), "method", method)
+	}
+
+	mw.family("czzd_rpc_request_errors_total", "counter",
+		"Number of RPC requests which failed by method.")
+	for _, method := range names {
+		mw.sample("czzd_rpc_request_errors_total",
+			float64(methods[method].errors), "method", method)
+	}
+}
+
+// writeLockHistogram writes the samples of the passed histogram of lock wait or
+// hold times with cumulative buckets.
+func writeLockHistogram(mw *metricsWriter, name string, h lockstat.Histogram, lock, mode string) {
+	var cumulative uint64
+	for i, count := range h.Buckets {
+		cumulative += count
+		le := math.Inf(1)
+		if i < len(lockstat.BucketBounds) {
+			le = lockstat.BucketBounds[i].Seconds()
+		}
+		mw.sample(name+"_bucket", float64(cumulative), "lock", lock,
+			"mode", mode, "le", formatMetricValue(le))
+	}
+	mw.sample(name+"_sum", h.Sum.Seconds(), "lock", lock, "mode", mode)
+	mw.sample(name+"_count", float64(h.Count), "lock", lock, "mode", mode)
+}
+
+// writeLockMetrics writes the metrics of the passed statistics of the
+// instrumented locks, which are only recorded with the lockstats option.
+func writeLockMetrics(mw *metricsWriter, stats []lockstat.Stats) {
+	if len(stats) == 0 {
+		return
+	}
+
+	mw.family("czzd_lock_wait_seconds", "histogram",
+		"Time spent waiting for the locks by mode.")
+	for _, s := range stats {
+		writeLockHistogram(mw, "czzd_lock_wait_seconds", s.WriteWait,
+			s.Name, "write")
+		writeLockHistogram(mw, "czzd_lock_wait_seconds", s.ReadWait,
+			s.Name, "read")
+	}
+
+	mw.family("czzd_lock_hold_seconds", "histogram",
+		"Time the locks were held by mode.  The read hold times are those "+
+			"of the periods during which at least one reader held the lock.")
+	for _, s := range stats {
+		writeLockHistogram(mw, "czzd_lock_hold_seconds", s.WriteHold,
+			s.Name, "write")
+		writeLockHistogram(mw, "czzd_lock_hold_seconds", s.ReadHold,
+			s.Name, "read")
+	}
+
+	mw.family("czzd_lock_acquisitions_total", "counter",
+		"Number of times the locks were acquired by mode.")
+	for _, s := range stats {
+		mw.sample("czzd_lock_acquisitions_total",
+			float64(s.WriteAcquisitions), "lock", s.Name, "mode", "write")
+		mw.sample("czzd_lock_acquisitions_total",
+			float64(s.ReadAcquisitions), "lock", s.Name, "mode", "read")
+	}
+
+	mw.family("czzd_lock_contended_total", "counter",
+		"Number of acquisitions of the locks which found them held or "+
+			"waited for in a conflicting mode.")
+	for _, s := range stats {
+		mw.sample("czzd_lock_contended_total", float64(s.WriteContended),
+			"lock", s.Name, "mode", "write")
+		mw.sample("czzd_lock_contended_total", float64(s.ReadContended),
+			"lock", s.Name, "mode", "read")
+	}
+
+	mw.family("czzd_lock_long_holds_total", "counter",
+		"Number of holds of the locks longer than the lockwatchdog "+
+			"duration.")
+	for _, s := range stats {
+		mw.sample("czzd_lock_long_holds_total", float64(s.LongHolds),
+			"lock", s.Name)
+	}
+}
+
+// writeEntangleMetrics writes the metrics of the passed statistics of the
+// verifications of entangled outputs by entangle type.
+func writeEntangleMetrics(mw *metricsWriter, stats map[cross.ExpandedTxType]cross.VerifyStats) {
+	types := make([]cross.ExpandedTxType, 0, len(stats))
+	for exTxType := range stats {
+		types = append(types, exTxType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i] < types[j]
+	})
+
+	mw.family("czzd_entangle_verifications_total", "counter",
+		"Number of entangled outputs verified against the nodes of the "+
+			"chain the coins were entangled from.")
+	for _, exTxType := range types {
+		s := stats[exTxType]
+		mw.sample("czzd_entangle_verifications_total", float64(s.Verified),
+			"chain", exTxType.String(), "result", "verified")
+		mw.sample("czzd_entangle_verifications_total", float64(s.Failed),
+			"chain", exTxType.String(), "result", "failed")
+	}
+
+	mw.family("czzd_entangle_verification_duration_seconds", "summary",
+		"Time spent verifying entangled outputs by chain.")
+	for _, exTxType := range types {
+		s := stats[exTxType]
+		mw.sample("czzd_entangle_verification_duration_seconds_sum",
+			s.Duration.Seconds(), "chain", exTxType.String())
+		mw.sample("czzd_entangle_verification_duration_seconds_count",
+			float64(s.Verified+s.Failed), "chain", exTxType.String())
+	}
+}
+
+// writeDBMetrics writes the metrics of the stores backing the block database
+// when the database reports them.
+func (m *metricsServer) writeDBMetrics(mw *metricsWriter) {
+	maintainer, ok := m.server.db.(database.Maintainer)
+	if !ok {
+		return
+	}
+	stores, err := maintainer.StorageStats()
+	if err != nil {
+		srvrLog.Debugf("Unable to collect the database metrics: %v", err)
+		return
+	}
+
+	mw.family("czzd_db_store_files", "gauge",
+		"Number of files of the stores of the block database.")
+	for _, store := range stores {
+		mw.sample("czzd_db_store_files", float64(store.Files),
+			"store", store.Name)
+	}
+
+	mw.family("czzd_db_store_size_bytes", "gauge",
+		"Size on disk of the stores of the block database.")
+	for _, store := range stores {
+		mw.sample("czzd_db_store_size_bytes", float64(store.Size),
+			"store", store.Name)
+	}
+
+	mw.family("czzd_db_store_tombstones", "gauge",
+		"Deletions written to the stores of the block database since "+
+			"they were last compacted.")
+	for _, store := range stores {
+		mw.sample("czzd_db_store_tombstones", float64(store.Tombstones),
+			"store", store.Name)
+	}
+
+	mw.family("czzd_db_store_size_amplification", "gauge",
+		"Ratio of the size on disk of the stores of the block database "+
+			"to the estimated size of their live data.")
+	for _, store := range stores {
+		mw.sample("czzd_db_store_size_amplification",
+			store.SizeAmplification, "store", store.Name)
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/cross"
+	"github.com/bourbaki-czz/classzz/lockstat"
+	"github.com/bourbaki-czz/classzz/netsync"
+)
+
+// TestMetricsWriter ensures metrics are written in the Prometheus text
+// exposition format with the help texts and label values escaped.
+func TestMetricsWriter(t *testing.T) {
+	var buf bytes.Buffer
+	mw := &metricsWriter{w: &buf}
+	mw.family("test_metric", "gauge", "Help with a \\ and a\nnewline.")
+	mw.sample("test_metric", 1.5)
+	mw.sample("test_metric", 42, "a", "x", "b", "quote \" backslash \\ newline \n")
+	mw.sample("test_metric", math.Inf(1), "a", "y")
+	mw.sample("test_metric", math.NaN(), "a", "z")
+
+	want := "# HELP test_metric Help with a \\\\ and a\\nnewline.\n" +
+		"# TYPE test_metric gauge\n" +
+		"test_metric 1.5\n" +
+		"test_metric{a=\"x\",b=\"quote \\\" backslash \\\\ newline \\n\"} 42\n" +
+		"test_metric{a=\"y\"} +Inf\n" +
+		"test_metric{a=\"z\"} NaN\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected metrics -- got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestRPCMetrics ensures the RPC request statistics are recorded by method and
+// written sorted by method.
+func TestRPCMetrics(t *testing.T) {
+	var stats rpcStats
+	stats.record("getblockcount", time.Second, false)
+	stats.record("getblock", 2*time.Second, true)
+	stats.record("getblockcount", 500*time.Millisecond, false)
+
+	var buf bytes.Buffer
+	writeRPCMetrics(&metricsWriter{w: &buf}, stats.snapshot())
+
+	want := "# HELP czzd_rpc_request_duration_seconds Time spent handling the RPC requests by method.  The quantiles are those of the most recent requests.\n" +
+		"# TYPE czzd_rpc_request_duration_seconds summary\n" +
+		"czzd_rpc_request_duration_seconds{method=\"getblock\",quantile=\"0.5\"} 2\n" +
+		"czzd_rpc_request_duration_seconds{method=\"getblock\",quantile=\"0.9\"} 2\n" +
+		"czzd_rpc_request_duration_seconds{method=\"getblock\",quantile=\"0.99\"} 2\n" +
+		"czzd_rpc_request_duration_seconds_sum{method=\"getblock\"} 2\n" +
+		"czzd_rpc_request_duration_seconds_count{method=\"getblock\"} 1\n" +
+		"czzd_rpc_request_duration_seconds{method=\"getblockcount\",quantile=\"0.5\"} 0.5\n" +
+		"czzd_rpc_request_duration_seconds{method=\"getblockcount\",quantile=\"0.9\"} 1\n" +
+		"czzd_rpc_request_duration_seconds{method=\"getblockcount\",quantile=\"0.99\"} 1\n" +
+		"czzd_rpc_request_duration_seconds_sum{method=\"getblockcount\"} 1.5\n" +
+		"czzd_rpc_request_duration_seconds_count{method=\"getblockcount\"} 2\n" +
+		"# HELP czzd_rpc_request_errors_total Number of RPC requests which failed by method.\n" +
+		"# TYPE czzd_rpc_request_errors_total counter\n" +
+		"czzd_rpc_request_errors_total{method=\"getblock\"} 1\n" +
+		"czzd_rpc_request_errors_total{method=\"getblockcount\"} 0\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected metrics -- got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestRPCQuantiles ensures the latency quantiles of an RPC method are those of
+// its most recent requests.
+func TestRPCQuantiles(t *testing.T) {
+	var stats rpcStats
+	for i := 1; i <= rpcLatencySamples+100; i++ {
+		stats.record("getblock", time.Duration(i)*time.Millisecond, false)
+	}
+
+	getblock := stats.snapshot()["getblock"]
+	got := getblock.quantiles([]float64{0, 0.5, 1})
+	want := []time.Duration{101 * time.Millisecond, 228 * time.Millisecond,
+		356 * time.Millisecond}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("quantile #%d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	var none rpcMethodStats
+	if got := none.quantiles([]float64{0.5}); got[0] != 0 {
+		t.Errorf("quantile of no request: got %v, want 0", got[0])
+	}
+}
+
+// TestEntangleMetrics ensures the entangle verification statistics are
+// written by chain.
+func TestEntangleMetrics(t *testing.T) {
+	stats := map[cross.ExpandedTxType]cross.VerifyStats{
+		cross.ExpandedTxEntangle_Ltc:  {Verified: 3, Duration: 3 * time.Second},
+		cross.ExpandedTxEntangle_Doge: {Verified: 1, Failed: 1, Duration: time.Second},
+	}
+
+	var buf bytes.Buffer
+	writeEntangleMetrics(&metricsWriter{w: &buf}, stats)
+
+	want := "# HELP czzd_entangle_verifications_total Number of entangled outputs verified against the nodes of the chain the coins were entangled from.\n" +
+		"# TYPE czzd_entangle_verifications_total counter\n" +
+		"czzd_entangle_verifications_total{chain=\"doge\",result=\"verified\"} 1\n" +
+		"czzd_entangle_verifications_total{chain=\"doge\",result=\"failed\"} 1\n" +
+		"czzd_entangle_verifications_total{chain=\"ltc\",result=\"verified\"} 3\n" +
+		"czzd_entangle_verifications_total{chain=\"ltc\",result=\"failed\"} 0\n" +
+		"# HELP czzd_entangle_verification_duration_seconds Time spent verifying entangled outputs by chain.\n" +
+		"# TYPE czzd_entangle_verification_duration_seconds summary\n" +
+		"czzd_entangle_verification_duration_seconds_sum{chain=\"doge\"} 1\n" +
+		"czzd_entangle_verification_duration_seconds_count{chain=\"doge\"} 2\n" +
+		"czzd_entangle_verification_duration_seconds_sum{chain=\"ltc\"} 3\n" +
+		"czzd_entangle_verification_duration_seconds_count{chain=\"ltc\"} 3\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected metrics -- got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestBlockPropagationMetrics ensures the block propagation latencies are
+// written by stage.
+func TestBlockPropagationMetrics(t *testing.T) {
+	stats := map[netsync.BlockLatency]netsync.LatencyStats{
+		netsync.LatencyInvToBlock:       {Count: 2, Total: 3 * time.Second},
+		netsync.LatencyBlockToValidated: {Count: 1, Total: 250 * time.Millisecond},
+	}
+
+	var buf bytes.Buffer
+	writeBlockPropagationMetrics(&metricsWriter{w: &buf}, stats)
+
+	want := "# HELP czzd_block_propagation_seconds Latencies between the stages of the propagation of the blocks to the node and their validation.\n" +
+		"# TYPE czzd_block_propagation_seconds summary\n" +
+		"czzd_block_propagation_seconds_sum{stage=\"inv_to_block\"} 3\n" +
+		"czzd_block_propagation_seconds_count{stage=\"inv_to_block\"} 2\n" +
+		"czzd_block_propagation_seconds_sum{stage=\"header_to_block\"} 0\n" +
+		"czzd_block_propagation_seconds_count{stage=\"header_to_block\"} 0\n" +
+		"czzd_block_propagation_seconds_sum{stage=\"block_to_validated\"} 0.25\n" +
+		"czzd_block_propagation_seconds_count{stage=\"block_to_validated\"} 1\n" +
+		"czzd_block_propagation_seconds_sum{stage=\"validated_to_announced\"} 0\n" +
+		"czzd_block_propagation_seconds_count{stage=\"validated_to_announced\"} 0\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected metrics -- got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestLockMetrics ensures the lock hold and wait times are written as
+// histograms with cumulative buckets, and that nothing is written when the
+// locks are not instrumented.
+func TestLockMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	writeLockMetrics(&metricsWriter{w: &buf}, nil)
+	if buf.Len() != 0 {
+		t.Fatalf("unexpected metrics without instrumented locks:\n%s",
+			buf.String())
+	}
+
+	stats := lockstat.Stats{
+		Name: "chain",
+		WriteHold: lockstat.Histogram{
+			Buckets: [lockstat.NumBuckets]uint64{1, 0, 2, 0, 0, 0, 0, 1},
+			Sum:     time.Minute,
+			Count:   4,
+		},
+		WriteAcquisitions: 4,
+		ReadContended:     2,
+		LongHolds:         1,
+	}
+	writeLockMetrics(&metricsWriter{w: &buf}, []lockstat.Stats{stats})
+
+	got := buf.String()
+	for _, want := range []string{
+		"# TYPE czzd_lock_hold_seconds histogram\n",
+		"czzd_lock_hold_seconds_bucket{lock=\"chain\",mode=\"write\",le=\"1e-05\"} 1\n",
+		"czzd_lock_hold_seconds_bucket{lock=\"chain\",mode=\"write\",le=\"0.0001\"} 1\n",
+		"czzd_lock_hold_seconds_bucket{lock=\"chain\",mode=\"write\",le=\"0.001\"} 3\n",
+		"czzd_lock_hold_seconds_bucket{lock=\"chain\",mode=\"write\",le=\"10\"} 3\n",
+		"czzd_lock_hold_seconds_bucket{lock=\"chain\",mode=\"write\",le=\"+Inf\"} 4\n",
+		"czzd_lock_hold_seconds_sum{lock=\"chain\",mode=\"write\"} 60\n",
+		"czzd_lock_hold_seconds_count{lock=\"chain\",mode=\"write\"} 4\n",
+		"czzd_lock_wait_seconds_count{lock=\"chain\",mode=\"read\"} 0\n",
+		"czzd_lock_acquisitions_total{lock=\"chain\",mode=\"write\"} 4\n",
+		"czzd_lock_contended_total{lock=\"chain\",mode=\"read\"} 2\n",
+		"czzd_lock_long_holds_total{lock=\"chain\"} 1\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("metrics do not contain %q -- got:\n%s", want, got)
+		}
+	}
+}
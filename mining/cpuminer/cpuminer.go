@@ -83,6 +83,11 @@ type Config struct {
 	// not current since any solved blocks would be on a side chain and and
 	// up orphaned anyways.
 	IsCurrent func() bool
+
+	// Signer signs the generated blocks on a network with a signet
+	// challenge.  Blocks can not be generated on such a network without
+	// it.
+	Signer blockchain.BlockSigner
 }
 
 // CPUMiner provides facilities for solving blocks (mining) using the CPU in
@@ -294,9 +299,35 @@ func (m *CPUMiner) solveBlock2(msgBlock *wire.MsgBlock, blockHeight int32,
 	return false
 }
 
+// signBlock signs the passed block with the configured signer when the
+// network has a signet challenge.  It returns false when the block could not
+// be signed.
+func (m *CPUMiner) signBlock(msgBlock *wire.MsgBlock) bool {
+	params := m.cfg.ChainParams
+	if params.SignetChallenge == nil {
+		return true
+	}
+	if m.cfg.Signer == nil {
+		log.Errorf("No signer to sign blocks with")
+		return false
+	}
+	err := blockchain.SignBlock(msgBlock, params, m.cfg.Signer)
+	if err != nil {
+		log.Errorf("Failed to sign block: %v", err)
+		return false
+	}
+	return true
+}
+
 func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight int32,
 	ticker *time.Ticker, quit chan struct{}) bool {
 
+	// A signed block must be signed before searching for the proof of
+	// work, since the signature changes the merkle root.
+	if !m.signBlock(msgBlock) {
+		return false
+	}
+
 	begin, err := wire.RandomUint64()
 	if err != nil {
 		log.Errorf("Unexpected error while generating random "+
@@ -349,6 +380,9 @@ func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight int32,
 			}
 
 			m.g.UpdateBlockTime(msgBlock)
+			if !m.signBlock(msgBlock) {
+				return false
+			}
 			param.Info.HeadHash = header.BlockHashNoNonce()
 
 		default:
@@ -623,6 +657,27 @@ func (m *CPUMiner) NumWorkers() int32 {
 // generating a new block template.  When a block is solved, it is submitted.
 // The function returns a list of the hashes of generated blocks.
 func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
+	return m.generateNBlocks(n, nil)
+}
+
+// GenerateNBlocksToAddress generates the requested number of blocks paying to
+// the passed address instead of the configured mining addresses.  See
+// GenerateNBlocks for details.
+func (m *CPUMiner) GenerateNBlocksToAddress(n uint32, payToAddr czzutil.Address) ([]*chainhash.Hash, error) {
+	return m.generateNBlocks(n, payToAddr)
+}
+
+// generateNBlocks generates the requested number of blocks paying to the
+// passed address, or to configured mining addresses chosen at random when it
+// is nil.
+func (m *CPUMiner) generateNBlocks(n uint32, payToAddr czzutil.Address) ([]*chainhash.Hash, error) {
+	// Respond with an error if the blocks can not be signed, since the
+	// attempts to solve them would never end otherwise.
+	if m.cfg.ChainParams.SignetChallenge != nil && m.cfg.Signer == nil {
+		return nil, errors.New("no signer was specified to sign " +
+			"the blocks of the network with")
+	}
+
 	m.Lock()
 
 	// Respond with an error if server is already mining.
@@ -666,14 +721,17 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 		m.submitBlockLock.Lock()
 		curHeight := m.g.BestSnapshot().Height
 
-		// Choose a payment address at random.
-		rand.Seed(time.Now().UnixNano())
-		payToAddr := m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
+		// Choose a payment address at random unless one was passed.
+		addr := payToAddr
+		if addr == nil {
+			rand.Seed(time.Now().UnixNano())
+			addr = m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
+		}
 
 		// Create a new block template using the available transactions
 		// in the memory pool as a source of transactions to potentially
 		// include in the block.
-		template, err := m.g.NewBlockTemplate(payToAddr)
+		template, err := m.g.NewBlockTemplate(addr)
 		m.submitBlockLock.Unlock()
 		if err != nil {
 			errStr := fmt.Sprintf("Failed to create new block "+
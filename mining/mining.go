@@ -66,6 +66,10 @@ type TxSource interface {
 	// removed from the source pool.
 	LastUpdated() time.Time
 
+	// Sequence returns the number of times a transaction was added to or
+	// removed from the source pool.
+	Sequence() uint64
+
 	// MiningDescs returns a slice of mining descriptors for all the
 	// transactions in the source pool.
 	MiningDescs() []*TxDesc
@@ -276,8 +280,8 @@ func createCoinbaseTx(params *chaincfg.Params, coinbaseScript []byte, nextBlockH
 	var pkScript1 []byte
 	var pkScript2 []byte
 
-	CoinPool1 := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
-	CoinPool2 := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2}
+	CoinPool1 := params.CoinPoolHashes[0][:]
+	CoinPool2 := params.CoinPoolHashes[1][:]
 
 	var err error
 	pkScript1, err = txscript.PayToPubKeyHashScript(CoinPool1)
@@ -572,6 +576,40 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress czzutil.Address) (*Bloc
 	// segwit recovery txs.
 	scriptFlags := txscript.StandardVerifyFlags
 
+	// Once the sigchecks deployment is active, the block is limited by the
+	// number of signature checks its transactions execute rather than by
+	// their signature operation count.
+	sigChecksActive, err := g.chain.IsDeploymentActive(
+		chaincfg.DeploymentSigChecks)
+	if err != nil {
+		return nil, err
+	}
+	if sigChecksActive {
+		scriptFlags |= txscript.ScriptVerifySigChecks
+	}
+
+	// Once the utxo commitment deployment is active, the coinbase commits
+	// to the hash of the utxo set the block is built on.  The output is
+	// added last, once the other outputs of the coinbase are known, so its
+	// size is reserved below.
+	var utxoCommitment *wire.TxOut
+	utxoCommitmentActive, err := g.chain.IsDeploymentActive(
+		chaincfg.DeploymentUtxoCommitment)
+	if err != nil {
+		return nil, err
+	}
+	if utxoCommitmentActive {
+		utxoSetHash := g.chain.UtxoSetHash()
+		if utxoSetHash == nil {
+			return nil, errors.New("the utxo set is not known yet")
+		}
+		script, err := blockchain.UtxoCommitmentScript(utxoSetHash)
+		if err != nil {
+			return nil, err
+		}
+		utxoCommitment = wire.NewTxOut(0, script)
+	}
+
 	coinbaseSigOps := int64(blockchain.CountSigOps(coinbaseTx, scriptFlags))
 
 	// Get the current source transactions and create a priority queue to
@@ -724,8 +762,12 @@ mempoolLoop:
 	// The starting block size is the size of the block header plus the max
 	// possible transaction count size, plus the size of the coinbase
 	// transaction.
-	blockSize := uint32(blockHeaderOverhead * coinbaseTx.MsgTx().SerializeSize())
+	blockSize := uint32(blockHeaderOverhead + coinbaseTx.MsgTx().SerializeSize())
+	if utxoCommitment != nil {
+		blockSize += uint32(utxoCommitment.SerializeSize())
+	}
 	blockSigOps := coinbaseSigOps
+	blockSigChecks := 0
 	totalFees := int64(0)
 	maxSigOps := blockchain.MaxBlockSigOps(blockSize)
 
@@ -762,8 +804,20 @@ mempoolLoop:
 			logSkippedDeps(tx, deps)
 			continue
 		}
-		if blockSigOps+int64(sigOps) < blockSigOps ||
-			blockSigOps+int64(sigOps) > int64(maxSigOps) {
+
+		// Enforce maximum signature operations per transaction, which
+		// the sanity checks of the block enforce whether or not the
+		// sigchecks deployment is active.
+		if blockchain.CountSigOps(tx, scriptFlags) >
+			blockchain.MaxTransactionSigOps {
+
+			log.Debugf("Skipping tx %s because it exceeds the "+
+				"maximum sigops per transaction", tx.Hash())
+			logSkippedDeps(tx, deps)
+			continue
+		}
+		if !sigChecksActive && (blockSigOps+int64(sigOps) < blockSigOps ||
+			blockSigOps+int64(sigOps) > int64(maxSigOps)) {
 			log.Debugf("Skipping tx %s because it would "+
 				"exceed the maximum sigops per block", tx.Hash())
 			logSkippedDeps(tx, deps)
@@ -847,15 +901,25 @@ mempoolLoop:
 			}
 			entangleAddress[*tx.Hash()] = obj
 		}
-		err = blockchain.ValidateTransactionScripts(tx, blockUtxos,
-			txscript.StandardVerifyFlags, g.sigCache,
-			g.hashCache)
+		sigChecks, err := blockchain.ValidateTransactionScriptsSigChecks(
+			tx, blockUtxos, scriptFlags, g.sigCache, g.hashCache)
 		if err != nil {
 			log.Tracef("Skipping tx %s due to error in "+
 				"ValidateTransactionScripts: %v", tx.Hash(), err)
 			logSkippedDeps(tx, deps)
 			continue
 		}
+
+		// Enforce maximum signature checks per block once the sigchecks
+		// deployment is active.
+		if sigChecksActive && blockSigChecks+sigChecks >
+			blockchain.MaxBlockSigChecks(blockPlusTxSize) {
+
+			log.Debugf("Skipping tx %s because it would "+
+				"exceed the maximum sigchecks per block", tx.Hash())
+			logSkippedDeps(tx, deps)
+			continue
+		}
 		// Spend the transaction inputs in the block utxo view and add
 		// an entry for it to ensure any transactions which reference
 		// this one have it available as an input and can ensure they
@@ -868,6 +932,7 @@ mempoolLoop:
 		blockTxns = append(blockTxns, tx)
 		blockSize = blockPlusTxSize
 		blockSigOps += int64(sigOps)
+		blockSigChecks += sigChecks
 		totalFees += prioItem.fee
 		txFees = append(txFees, prioItem.fee)
 		txSigOps = append(txSigOps, int64(sigOps))
@@ -922,15 +987,17 @@ mempoolLoop:
 			return nil, err
 		}
 	}
+	if utxoCommitment != nil {
+		coinbaseTx.MsgTx().AddTxOut(utxoCommitment)
+	}
 	blockTxns = append([]*czzutil.Tx{coinbaseTx}, blockTxns...)
 
 	// Create a new block ready to be solved.
-	merkles := blockchain.BuildMerkleTreeStore(blockTxns)
 	var msgBlock wire.MsgBlock
 	msgBlock.Header = wire.BlockHeader{
 		Version:    nextBlockVersion,
 		PrevBlock:  best.Hash,
-		MerkleRoot: *merkles[len(merkles)-1],
+		MerkleRoot: blockchain.CalcMerkleRoot(blockTxns),
 		CIDRoot:    chainhash.Hash{},
 		Timestamp:  ts,
 		Bits:       reqDifficulty,
@@ -1014,8 +1081,7 @@ func (g *BlkTmplGenerator) UpdateExtraNonce(msgBlock *wire.MsgBlock, blockHeight
 
 	// Recalculate the merkle root with the updated extra nonce.
 	block := czzutil.NewBlock(msgBlock)
-	merkles := blockchain.BuildMerkleTreeStore(block.Transactions())
-	msgBlock.Header.MerkleRoot = *merkles[len(merkles)-1]
+	msgBlock.Header.MerkleRoot = blockchain.CalcMerkleRoot(block.Transactions())
 	return nil
 }
 
@@ -1059,10 +1125,19 @@ func toPoolAddrItems(view *blockchain.UtxoViewpoint) *cross.PoolAddrItem {
 		Script: make([][]byte, 2),
 		Amount: make([]*big.Int, 2),
 	}
+	for i := range items.Amount {
+		items.Amount[i] = new(big.Int)
+	}
 	if view != nil {
 		m := view.Entries()
 		for k, v := range m {
 			items.POut[k.Index-1] = k
+			// The pool outputs of the genesis block are not in the
+			// utxo set, so the first block after it only references
+			// them.
+			if v == nil {
+				continue
+			}
 			items.Script[k.Index-1] = v.PkScript()
 			items.Amount[k.Index-1] = new(big.Int).SetInt64(v.Amount())
 		}
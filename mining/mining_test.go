@@ -5,10 +5,21 @@
 package mining
 
 import (
+	"bytes"
 	"container/heap"
+	"io/ioutil"
 	"math/rand"
+	"os"
 	"testing"
+	"time"
 
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/database"
+	_ "github.com/bourbaki-czz/classzz/database/ffldb"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
 	"github.com/bourbaki-czz/czzutil"
 )
 
@@ -108,3 +119,171 @@ func TestTxFeePrioHeap(t *testing.T) {
 		highest = prioItem
 	}
 }
+
+// fakeTxSource provides a transaction source which serves a fixed set of
+// transactions for the block template generator.
+type fakeTxSource struct {
+	descs []*TxDesc
+}
+
+func (s *fakeTxSource) LastUpdated() time.Time { return time.Time{} }
+func (s *fakeTxSource) Sequence() uint64       { return 0 }
+func (s *fakeTxSource) MiningDescs() []*TxDesc { return s.descs }
+func (s *fakeTxSource) HaveTransaction(hash *chainhash.Hash) bool {
+	for _, desc := range s.descs {
+		if desc.Tx.Hash().IsEqual(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// newShortWindowChain returns a regression test chain on a temporary database
+// whose deployments activate after a few blocks, a block template generator for
+// it serving the transactions of the returned source, a function which mines a
+// block from a new template and connects it, and a teardown function.
+func newShortWindowChain(t *testing.T, dbName string) (*blockchain.BlockChain,
+	*BlkTmplGenerator, *fakeTxSource, func() *czzutil.Block, func()) {
+
+	dbPath, err := ioutil.TempDir("", dbName)
+	if err != nil {
+		t.Fatalf("unable to create db dir: %v", err)
+	}
+
+	// Shorten the threshold windows so the deployments activate after a
+	// few blocks and let the coinbases be spent right away.
+	params := chaincfg.RegressionNetParams
+	params.RuleChangeActivationThreshold = 3
+	params.MinerConfirmationWindow = 4
+	params.CoinbaseMaturity = 1
+
+	db, err := database.Create("ffldb", dbPath, params.Net)
+	if err != nil {
+		os.RemoveAll(dbPath)
+		t.Fatalf("unable to create db: %v", err)
+	}
+	teardown := func() {
+		db.Close()
+		os.RemoveAll(dbPath)
+	}
+	timeSource := blockchain.NewMedianTime()
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:                 db,
+		ChainParams:        &params,
+		TimeSource:         timeSource,
+		ExcessiveBlockSize: 1000000,
+	})
+	if err != nil {
+		teardown()
+		t.Fatalf("unable to create chain: %v", err)
+	}
+
+	txSource := &fakeTxSource{}
+	policy := &Policy{BlockMaxSize: 1000000}
+	g := NewBlkTmplGenerator(policy, &params, txSource, chain,
+		timeSource, nil, nil)
+
+	// mineBlock solves a block from a new template and connects it.
+	mineBlock := func() *czzutil.Block {
+		template, err := g.NewBlockTemplate(nil)
+		if err != nil {
+			t.Fatalf("NewBlockTemplate: unexpected error: %v", err)
+		}
+		msgBlock := template.Block
+		target := blockchain.CompactToBig(msgBlock.Header.Bits)
+		for {
+			hash := msgBlock.Header.BlockHash()
+			if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+				break
+			}
+			msgBlock.Header.Nonce++
+		}
+		block := czzutil.NewBlock(msgBlock)
+		_, isOrphan, err := chain.ProcessBlock(block, blockchain.BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock: unexpected error: %v", err)
+		}
+		if isOrphan {
+			t.Fatal("ProcessBlock: block is an orphan")
+		}
+		return block
+	}
+
+	return chain, g, txSource, mineBlock, teardown
+}
+
+// TestSigOpsLimitWithSigChecks ensures transactions over the signature
+// operation limit per transaction are left out of block templates once the
+// sigchecks deployment is active, since the sanity checks of blocks still
+// enforce that limit.
+func TestSigOpsLimitWithSigChecks(t *testing.T) {
+	chain, g, txSource, mineBlock, teardown := newShortWindowChain(t,
+		"sigopslimit")
+	defer teardown()
+
+	var coinbase *wire.MsgTx
+	for i := 0; i < 16; i++ {
+		block := mineBlock()
+		if coinbase == nil {
+			coinbase = block.MsgBlock().Transactions[0]
+		}
+	}
+	active, err := chain.IsDeploymentActive(chaincfg.DeploymentSigChecks)
+	if err != nil {
+		t.Fatalf("IsDeploymentActive: unexpected error: %v", err)
+	}
+	if !active {
+		t.Fatal("sigchecks deployment is not active")
+	}
+
+	// Spend the anyone-can-spend output of the first coinbase to an output
+	// counting one more signature operation than allowed per transaction.
+	numOps := blockchain.MaxTransactionSigOps/txscript.MaxPubKeysPerMultiSig + 1
+	pkScript := bytes.Repeat([]byte{txscript.OP_CHECKMULTISIG}, numOps)
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, 0), nil))
+	tx.TxIn[0].PreviousOutPoint.Hash = coinbase.TxHash()
+	tx.AddTxOut(wire.NewTxOut(coinbase.TxOut[0].Value-10000, pkScript))
+	txSource.descs = []*TxDesc{{Tx: czzutil.NewTx(tx), Fee: 10000}}
+
+	template, err := g.NewBlockTemplate(nil)
+	if err != nil {
+		t.Fatalf("NewBlockTemplate: unexpected error: %v", err)
+	}
+	if len(template.Block.Transactions) != 1 {
+		t.Fatalf("template has %d transactions, want only the coinbase",
+			len(template.Block.Transactions))
+	}
+	block := czzutil.NewBlock(template.Block)
+	block.SetHeight(template.Height)
+	if err := chain.CheckConnectBlockTemplate(block); err != nil {
+		t.Fatalf("CheckConnectBlockTemplate: unexpected error: %v", err)
+	}
+}
+
+// TestUtxoSetKeptOnceLockedIn ensures the multiset of the utxo set is only kept
+// once the utxo commitment deployment is locked in, and that the one computed
+// when it locks in checks the commitments of the blocks once it is active.
+func TestUtxoSetKeptOnceLockedIn(t *testing.T) {
+	chain, _, _, mineBlock, teardown := newShortWindowChain(t, "utxoset")
+	defer teardown()
+
+	var state blockchain.ThresholdState
+	for i := 0; i < 16; i++ {
+		var err error
+		state, err = chain.ThresholdState(chaincfg.DeploymentUtxoCommitment)
+		if err != nil {
+			t.Fatalf("ThresholdState: unexpected error: %v", err)
+		}
+		lockedIn := state == blockchain.ThresholdLockedIn ||
+			state == blockchain.ThresholdActive
+		if hash := chain.UtxoSetHash(); (hash != nil) != lockedIn {
+			t.Fatalf("height %d: utxo set hash %v with deployment "+
+				"state %v", i, hash, state)
+		}
+		mineBlock()
+	}
+	if state != blockchain.ThresholdActive {
+		t.Fatalf("utxo commitment deployment is %v, want active", state)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package remotesigner
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/bourbaki-czz/classzz/czzec"
+)
+
+const (
+	// maxRequestSize is the maximum size of a request read by a handler.
+	maxRequestSize = 4096
+
+	// hashSize is the size of the hashes a handler signs.
+	hashSize = 32
+)
+
+// handler serves signing requests with a private key.
+type handler struct {
+	key    *czzec.PrivateKey
+	pubKey []byte
+	user   string
+	pass   string
+}
+
+// NewHandler returns an HTTP handler serving signing requests with the passed
+// private key.  Only requests for the public key of the private key are served.
+// When either of the passed user and password is not empty, requests must
+// carry them with HTTP basic authentication.
+func NewHandler(key *czzec.PrivateKey, user, pass string) http.Handler {
+	return &handler{
+		key:    key,
+		pubKey: key.PubKey().SerializeCompressed(),
+		user:   user,
+		pass:   pass,
+	}
+}
+
+// reply writes the passed response with the passed status.
+func reply(w http.ResponseWriter, status int, resp *SignResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ServeHTTP serves a signing request.  This is part of the http.Handler
+// interface implementation.
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		reply(w, http.StatusMethodNotAllowed,
+			&SignResponse{Error: "method not allowed"})
+		return
+	}
+	if h.user != "" || h.pass != "" {
+		user, pass, _ := r.BasicAuth()
+		userOk := subtle.ConstantTimeCompare([]byte(user), []byte(h.user)) == 1
+		passOk := subtle.ConstantTimeCompare([]byte(pass), []byte(h.pass)) == 1
+		if !userOk || !passOk {
+			w.Header().Set("WWW-Authenticate", `Basic realm="signer"`)
+			reply(w, http.StatusUnauthorized,
+				&SignResponse{Error: "unauthorized"})
+			return
+		}
+	}
+
+	var req SignRequest
+	err := json.NewDecoder(io.LimitReader(r.Body, maxRequestSize)).Decode(&req)
+	if err != nil {
+		reply(w, http.StatusBadRequest,
+			&SignResponse{Error: "malformed request"})
+		return
+	}
+	pubKey, err := hex.DecodeString(req.PubKey)
+	if err != nil || !bytes.Equal(pubKey, h.pubKey) {
+		reply(w, http.StatusBadRequest,
+			&SignResponse{Error: "unknown public key"})
+		return
+	}
+	hash, err := hex.DecodeString(req.Hash)
+	if err != nil || len(hash) != hashSize {
+		reply(w, http.StatusBadRequest,
+			&SignResponse{Error: "malformed hash"})
+		return
+	}
+
+	var sig *czzec.Signature
+	switch req.Algorithm {
+	case AlgorithmSchnorr:
+		sig, err = h.key.SignSchnorr(hash)
+	case AlgorithmECDSA:
+		sig, err = h.key.SignECDSA(hash)
+	default:
+		reply(w, http.StatusBadRequest,
+			&SignResponse{Error: "unknown algorithm"})
+		return
+	}
+	if err != nil {
+		reply(w, http.StatusInternalServerError,
+			&SignResponse{Error: err.Error()})
+		return
+	}
+	reply(w, http.StatusOK,
+		&SignResponse{Signature: hex.EncodeToString(sig.Serialize())})
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package remotesigner implements a small HTTP protocol to sign the blocks of
+// a network with a signet challenge in a separate signer process, so the node
+// generating blocks only holds the public key of the challenge.
+//
+// A signing request is a POST of a JSON encoded SignRequest to the URL of the
+// signer.  The signer replies with status 200 and a JSON encoded SignResponse
+// carrying the signature, or another status and a SignResponse carrying the
+// error.  Requests may be authenticated with HTTP basic authentication.
+package remotesigner
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/czzec"
+)
+
+const (
+	// AlgorithmSchnorr requests a Schnorr signature.
+	AlgorithmSchnorr = "schnorr"
+
+	// AlgorithmECDSA requests a DER encoded ECDSA signature.
+	AlgorithmECDSA = "ecdsa"
+
+	// maxResponseSize is the maximum size of a response read from a signer.
+	maxResponseSize = 4096
+
+	// requestTimeout is the maximum duration of a signing request.
+	requestTimeout = time.Second * 30
+)
+
+// SignRequest is a request to sign a hash.
+type SignRequest struct {
+	// PubKey is the hex encoded compressed public key to sign with.
+	PubKey string `json:"pubkey"`
+
+	// Hash is the hex encoded hash to sign.
+	Hash string `json:"hash"`
+
+	// Algorithm is either AlgorithmSchnorr or AlgorithmECDSA.
+	Algorithm string `json:"algorithm"`
+}
+
+// SignResponse is the response to a SignRequest.
+type SignResponse struct {
+	// Signature is the hex encoded serialized signature.
+	Signature string `json:"signature,omitempty"`
+
+	// Error describes why the hash was not signed.
+	Error string `json:"error,omitempty"`
+}
+
+// Client signs hashes through a remote signer.  It implements the
+// blockchain.BlockSigner interface.
+type Client struct {
+	url    string
+	user   string
+	pass   string
+	pubKey *czzec.PublicKey
+	client *http.Client
+}
+
+// New returns a client signing with the key of the passed public key through
+// the signer at the passed URL.  The credentials of the URL, if any, are sent
+// with HTTP basic authentication rather than as part of the URL.
+func New(signerURL string, pubKey *czzec.PublicKey) (*Client, error) {
+	u, err := url.Parse(signerURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported signer URL scheme %q",
+			u.Scheme)
+	}
+	c := &Client{
+		pubKey: pubKey,
+		client: &http.Client{Timeout: requestTimeout},
+	}
+	if u.User != nil {
+		c.user = u.User.Username()
+		c.pass, _ = u.User.Password()
+		u.User = nil
+	}
+	c.url = u.String()
+	return c, nil
+}
+
+// PubKey returns the public key the signer signs with.  This is part of the
+// blockchain.BlockSigner interface implementation.
+func (c *Client) PubKey() *czzec.PublicKey {
+	return c.pubKey
+}
+
+// Sign requests the signature of the passed hash from the signer.  The
+// returned signature is verified against the public key, so a misbehaving
+// signer can not make the node produce invalid blocks.  This is part of the
+// blockchain.BlockSigner interface implementation.
+func (c *Client) Sign(hash []byte, schnorr bool) ([]byte, error) {
+	req := SignRequest{
+		PubKey:    hex.EncodeToString(c.pubKey.SerializeCompressed()),
+		Hash:      hex.EncodeToString(hash),
+		Algorithm: AlgorithmECDSA,
+	}
+	if schnorr {
+		req.Algorithm = AlgorithmSchnorr
+	}
+	body, err := json.Marshal(&req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequest("POST", c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.user != "" || c.pass != "" {
+		httpReq.SetBasicAuth(c.user, c.pass)
+	}
+	httpResp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(httpResp.Body,
+		maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	var resp SignResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("malformed signer response (status "+
+			"%s): %v", httpResp.Status, err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		if resp.Error == "" {
+			resp.Error = httpResp.Status
+		}
+		return nil, fmt.Errorf("signer refused to sign: %s", resp.Error)
+	}
+
+	sigBytes, err := hex.DecodeString(resp.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("malformed signer signature: %v", err)
+	}
+	var sig *czzec.Signature
+	if schnorr {
+		sig, err = czzec.ParseSchnorrSignature(sigBytes)
+	} else {
+		sig, err = czzec.ParseDERSignature(sigBytes, czzec.S256())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("malformed signer signature: %v", err)
+	}
+	if !sig.Verify(hash, c.pubKey) {
+		return nil, errors.New("signer returned an invalid signature")
+	}
+	return sigBytes, nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package remotesigner
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/czzec"
+)
+
+// TestSign ensures a client gets valid signatures of both algorithms from a
+// handler, and that requests with bad credentials or for another key fail.
+func TestSign(t *testing.T) {
+	key, err := czzec.NewPrivateKey(czzec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	otherKey, err := czzec.NewPrivateKey(czzec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	srv := httptest.NewServer(NewHandler(key, "user", "pass"))
+	defer srv.Close()
+	signerURL := strings.Replace(srv.URL, "http://", "http://user:pass@", 1)
+	hash := chainhash.DoubleHashB([]byte("block"))
+
+	c, err := New(signerURL, key.PubKey())
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	for _, schnorr := range []bool{true, false} {
+		sigBytes, err := c.Sign(hash, schnorr)
+		if err != nil {
+			t.Fatalf("Sign(schnorr=%v): unexpected error: %v", schnorr,
+				err)
+		}
+		var sig *czzec.Signature
+		if schnorr {
+			sig, err = czzec.ParseSchnorrSignature(sigBytes)
+		} else {
+			sig, err = czzec.ParseDERSignature(sigBytes, czzec.S256())
+		}
+		if err != nil || !sig.Verify(hash, key.PubKey()) {
+			t.Fatalf("Sign(schnorr=%v): invalid signature: %v",
+				schnorr, err)
+		}
+	}
+
+	badAuth, err := New(strings.Replace(signerURL, "pass@", "wrong@", 1),
+		key.PubKey())
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	if _, err := badAuth.Sign(hash, true); err == nil {
+		t.Fatal("Sign: unexpected success with bad credentials")
+	}
+
+	otherSigner, err := New(signerURL, otherKey.PubKey())
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	if _, err := otherSigner.Sign(hash, true); err == nil {
+		t.Fatal("Sign: unexpected success for another key")
+	}
+
+	if _, err := New("ftp://localhost", key.PubKey()); err == nil {
+		t.Fatal("New: unexpected success with an unsupported scheme")
+	}
+}
+
+// TestSignInvalidSignature ensures a client rejects signatures which do not
+// verify against its public key.
+func TestSignInvalidSignature(t *testing.T) {
+	key, err := czzec.NewPrivateKey(czzec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	otherKey, err := czzec.NewPrivateKey(czzec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	hash := chainhash.DoubleHashB([]byte("block"))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+
+		sig, err := otherKey.SignSchnorr(hash)
+		if err != nil {
+			t.Errorf("SignSchnorr: unexpected error: %v", err)
+		}
+		json.NewEncoder(w).Encode(&SignResponse{
+			Signature: hex.EncodeToString(sig.Serialize()),
+		})
+	}))
+	defer srv.Close()
+
+	c, err := New(srv.URL, key.PubKey())
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	if _, err := c.Sign(hash, true); err == nil {
+		t.Fatal("Sign: unexpected success with an invalid signature")
+	}
+}
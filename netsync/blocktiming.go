@@ -0,0 +1,242 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+)
+
+// maxTrackedBlockTimings is the number of the most recently seen blocks whose
+// propagation timings are kept.
+const maxTrackedBlockTimings = 100
+
+// BlockTimings are the times a block went through the successive stages of its
+// propagation to the node and its validation.  A zero time means the stage was
+// not observed, for instance the first inv of a block mined locally or the
+// header of a block sent in full.
+type BlockTimings struct {
+	// Hash is the hash of the block.
+	Hash chainhash.Hash
+
+	// Height is the height of the block, or 0 until it is validated.
+	Height int32
+
+	// Peer is the address of the peer the block was received from, if any.
+	Peer string
+
+	// FirstInv is when the block was first announced by an inv.
+	FirstInv time.Time
+
+	// HeaderReceived is when the header of the block was first received
+	// ahead of its transactions, in a compact or graphene block.
+	HeaderReceived time.Time
+
+	// BlockReceived is when the full block was first received or
+	// reconstructed.
+	BlockReceived time.Time
+
+	// Validated is when the block was accepted into the block chain.
+	Validated time.Time
+
+	// Announced is when the block was relayed to the peers.
+	Announced time.Time
+}
+
+// BlockLatency identifies a latency between two stages of the propagation of
+// the blocks.
+type BlockLatency int
+
+// These constants define the latencies between the stages of the propagation
+// of the blocks.
+const (
+	// LatencyInvToBlock is the time from the first inv of a block to the
+	// reception of the full block.
+	LatencyInvToBlock BlockLatency = iota
+
+	// LatencyHeaderToBlock is the time from the reception of the header of
+	// a block to the reconstruction of the full block.
+	LatencyHeaderToBlock
+
+	// LatencyBlockToValidated is the time spent validating a received
+	// block.
+	LatencyBlockToValidated
+
+	// LatencyValidatedToAnnounced is the time from the validation of a
+	// block to its relay to the peers.
+	LatencyValidatedToAnnounced
+
+	numBlockLatencies
+)
+
+// blockLatencyStrings is a map of block latencies back to their constant names
+// for pretty printing.
+var blockLatencyStrings = map[BlockLatency]string{
+	LatencyInvToBlock:           "inv_to_block",
+	LatencyHeaderToBlock:        "header_to_block",
+	LatencyBlockToValidated:     "block_to_validated",
+	LatencyValidatedToAnnounced: "validated_to_announced",
+}
+
+// String returns the BlockLatency as a human-readable name.
+func (l BlockLatency) String() string {
+	if s, ok := blockLatencyStrings[l]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+// LatencyStats are the cumulated latencies of a kind observed for the blocks
+// seen since the node started.
+type LatencyStats struct {
+	// Count is the number of blocks the latency was observed for.
+	Count uint64
+
+	// Total is the sum of the observed latencies.
+	Total time.Duration
+}
+
+// blockTimingTracker keeps the propagation timings of the most recently seen
+// blocks and the cumulated latencies between their stages.  It is safe for
+// concurrent access since blocks are received on the peer goroutines while
+// they are validated on the block handler goroutine.
+type blockTimingTracker struct {
+	mtx       sync.Mutex
+	timings   map[chainhash.Hash]*BlockTimings
+	order     []chainhash.Hash
+	latencies [numBlockLatencies]LatencyStats
+}
+
+// newBlockTimingTracker returns a new empty block timing tracker.
+func newBlockTimingTracker() *blockTimingTracker {
+	return &blockTimingTracker{
+		timings: make(map[chainhash.Hash]*BlockTimings),
+	}
+}
+
+// lookupOrAdd returns the timings of the block with the passed hash, tracking
+// the block and evicting the oldest tracked one when it is not tracked yet.
+//
+// This function MUST be called with the tracker lock held.
+func (t *blockTimingTracker) lookupOrAdd(hash *chainhash.Hash) *BlockTimings {
+	if timings, ok := t.timings[*hash]; ok {
+		return timings
+	}
+	if len(t.order) >= maxTrackedBlockTimings {
+		delete(t.timings, t.order[0])
+		t.order = t.order[1:]
+	}
+	timings := &BlockTimings{Hash: *hash}
+	t.timings[*hash] = timings
+	t.order = append(t.order, *hash)
+	return timings
+}
+
+// addLatency adds the latency between the passed times to the cumulated
+// latencies of the passed kind when both stages were observed in order.
+//
+// This function MUST be called with the tracker lock held.
+func (t *blockTimingTracker) addLatency(latency BlockLatency, from, to time.Time) {
+	if from.IsZero() || to.Before(from) {
+		return
+	}
+	t.latencies[latency].Count++
+	t.latencies[latency].Total += to.Sub(from)
+}
+
+// recordInv records the first announcement of the block with the passed hash.
+func (t *blockTimingTracker) recordInv(hash *chainhash.Hash, when time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	timings := t.lookupOrAdd(hash)
+	if timings.FirstInv.IsZero() {
+		timings.FirstInv = when
+	}
+}
+
+// recordHeader records the first reception of the header of the block with
+// the passed hash.
+func (t *blockTimingTracker) recordHeader(hash *chainhash.Hash, when time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	timings := t.lookupOrAdd(hash)
+	if timings.HeaderReceived.IsZero() {
+		timings.HeaderReceived = when
+	}
+}
+
+// recordBlock records the first reception of the block with the passed hash
+// from the passed peer.
+func (t *blockTimingTracker) recordBlock(hash *chainhash.Hash, peer string, when time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	timings := t.lookupOrAdd(hash)
+	if !timings.BlockReceived.IsZero() {
+		return
+	}
+	timings.BlockReceived = when
+	timings.Peer = peer
+	t.addLatency(LatencyInvToBlock, timings.FirstInv, when)
+	t.addLatency(LatencyHeaderToBlock, timings.HeaderReceived, when)
+}
+
+// recordValidated records the acceptance into the block chain of the block
+// with the passed hash and height.
+func (t *blockTimingTracker) recordValidated(hash *chainhash.Hash, height int32, when time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	timings := t.lookupOrAdd(hash)
+	if !timings.Validated.IsZero() {
+		return
+	}
+	timings.Height = height
+	timings.Validated = when
+	t.addLatency(LatencyBlockToValidated, timings.BlockReceived, when)
+}
+
+// recordAnnounced records the relay to the peers of the block with the passed
+// hash.
+func (t *blockTimingTracker) recordAnnounced(hash *chainhash.Hash, when time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	timings := t.lookupOrAdd(hash)
+	if !timings.Announced.IsZero() {
+		return
+	}
+	timings.Announced = when
+	t.addLatency(LatencyValidatedToAnnounced, timings.Validated, when)
+}
+
+// snapshot returns a copy of the timings of the tracked blocks, from the
+// first seen to the last seen one.
+func (t *blockTimingTracker) snapshot() []BlockTimings {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	timings := make([]BlockTimings, 0, len(t.order))
+	for _, hash := range t.order {
+		timings = append(timings, *t.timings[hash])
+	}
+	return timings
+}
+
+// latencyStats returns a copy of the cumulated latencies by kind.
+func (t *blockTimingTracker) latencyStats() map[BlockLatency]LatencyStats {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	stats := make(map[BlockLatency]LatencyStats, numBlockLatencies)
+	for latency := BlockLatency(0); latency < numBlockLatencies; latency++ {
+		stats[latency] = t.latencies[latency]
+	}
+	return stats
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+)
+
+// TestBlockTimingTracker ensures the block timing tracker keeps the first time
+// of every stage, cumulates the latencies between the observed stages and
+// evicts the oldest blocks.
+func TestBlockTimingTracker(t *testing.T) {
+	tracker := newBlockTimingTracker()
+	start := time.Unix(1600000000, 0)
+	hash := chainhash.Hash{1}
+
+	tracker.recordInv(&hash, start)
+	tracker.recordInv(&hash, start.Add(time.Second))
+	tracker.recordBlock(&hash, "127.0.0.1:8333", start.Add(2*time.Second))
+	tracker.recordValidated(&hash, 10, start.Add(2500*time.Millisecond))
+	tracker.recordAnnounced(&hash, start.Add(2600*time.Millisecond))
+
+	// A block mined locally is only validated and announced.
+	local := chainhash.Hash{2}
+	tracker.recordValidated(&local, 11, start.Add(3*time.Second))
+	tracker.recordAnnounced(&local, start.Add(3200*time.Millisecond))
+
+	timings := tracker.snapshot()
+	if len(timings) != 2 {
+		t.Fatalf("snapshot: got %d blocks, want 2", len(timings))
+	}
+	got := timings[0]
+	want := BlockTimings{
+		Hash:          hash,
+		Height:        10,
+		Peer:          "127.0.0.1:8333",
+		FirstInv:      start,
+		BlockReceived: start.Add(2 * time.Second),
+		Validated:     start.Add(2500 * time.Millisecond),
+		Announced:     start.Add(2600 * time.Millisecond),
+	}
+	if got != want {
+		t.Fatalf("snapshot: got %+v, want %+v", got, want)
+	}
+
+	stats := tracker.latencyStats()
+	wantStats := map[BlockLatency]LatencyStats{
+		LatencyInvToBlock:           {Count: 1, Total: 2 * time.Second},
+		LatencyHeaderToBlock:        {},
+		LatencyBlockToValidated:     {Count: 1, Total: 500 * time.Millisecond},
+		LatencyValidatedToAnnounced: {Count: 2, Total: 300 * time.Millisecond},
+	}
+	for latency, want := range wantStats {
+		if stats[latency] != want {
+			t.Errorf("latencyStats: got %+v for %v, want %+v",
+				stats[latency], latency, want)
+		}
+	}
+
+	// Tracking more blocks than the limit evicts the first seen ones.
+	for i := 0; i < maxTrackedBlockTimings; i++ {
+		hash := chainhash.Hash{3, byte(i)}
+		tracker.recordInv(&hash, start)
+	}
+	timings = tracker.snapshot()
+	if len(timings) != maxTrackedBlockTimings {
+		t.Fatalf("snapshot: got %d blocks, want %d", len(timings),
+			maxTrackedBlockTimings)
+	}
+	if timings[0].Hash != (chainhash.Hash{3, 0}) {
+		t.Fatalf("snapshot: got %v as the oldest block", timings[0].Hash)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/bourbaki-czz/classzz/chaincfg"
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
 	"github.com/bourbaki-czz/classzz/mempool"
+	"github.com/bourbaki-czz/classzz/netsync"
 	"github.com/bourbaki-czz/classzz/peer"
 	"github.com/bourbaki-czz/classzz/txscript"
 	"github.com/bourbaki-czz/classzz/wire"
@@ -163,6 +164,8 @@ type MockPeerNotifier struct {
 	updatePeerHeightsChan       chan *updatePeerHeightsCall
 	relayInventoryChan          chan *relayInventoryCall
 	transactionConfirmedChan    chan *transactionConfirmedCall
+	chainReorganizedChan        chan *chainReorganizedCall
+	peerMisbehavedChan          chan *peerMisbehavedCall
 }
 
 type announceNewTransactionsCall struct {
@@ -184,6 +187,15 @@ type transactionConfirmedCall struct {
 	tx *czzutil.Tx
 }
 
+type chainReorganizedCall struct {
+	reorg *netsync.Reorganization
+}
+
+type peerMisbehavedCall struct {
+	peer   *peer.Peer
+	reason string
+}
+
 func (mock *MockPeerNotifier) AnnounceNewTransactions(newTxs []*mempool.TxDesc) {
 	mock.announceNewTransactionsChan <- &announceNewTransactionsCall{
 		newTxs: newTxs,
@@ -209,6 +221,17 @@ func (mock *MockPeerNotifier) TransactionConfirmed(tx *czzutil.Tx) {
 	mock.transactionConfirmedChan <- &transactionConfirmedCall{tx: tx}
 }
 
+func (mock *MockPeerNotifier) ChainReorganized(reorg *netsync.Reorganization) {
+	mock.chainReorganizedChan <- &chainReorganizedCall{reorg: reorg}
+}
+
+func (mock *MockPeerNotifier) PeerMisbehaved(peer *peer.Peer, reason string) {
+	mock.peerMisbehavedChan <- &peerMisbehavedCall{
+		peer:   peer,
+		reason: reason,
+	}
+}
+
 // NewMockPeerNotifier creates a new MockPeerNotifier and initializes the
 // channels.
 func NewMockPeerNotifier() *MockPeerNotifier {
@@ -217,6 +240,8 @@ func NewMockPeerNotifier() *MockPeerNotifier {
 		updatePeerHeightsChan:       make(chan *updatePeerHeightsCall, 10),
 		relayInventoryChan:          make(chan *relayInventoryCall, 10),
 		transactionConfirmedChan:    make(chan *transactionConfirmedCall, 10),
+		chainReorganizedChan:        make(chan *chainReorganizedCall, 10),
+		peerMisbehavedChan:          make(chan *peerMisbehavedCall, 10),
 	}
 }
 
@@ -6,8 +6,12 @@
 Package netsync implements a concurrency safe block syncing protocol. The
 SyncManager communicates with connected peers to perform an initial block
 download, keep the chain and unconfirmed transaction pool in sync, and announce
-new blocks connected to the chain. Currently the sync manager selects a single
-sync peer that it downloads all blocks from until it is up to date with the
-longest chain the sync peer is aware of.
+new blocks connected to the chain. The sync manager selects a sync peer and,
+while the chain is behind it, first downloads and validates its header chain,
+then downloads the blocks of the headers from all the sync candidates in
+parallel windows. The blocks of the peers which stall the download are
+requested from the other peers. Once the blocks are processed, the sync manager
+follows the sync peer until it is up to date with the longest chain the sync
+peer is aware of.
 */
 package netsync
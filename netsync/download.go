@@ -0,0 +1,509 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/mempool"
+	peerpkg "github.com/bourbaki-czz/classzz/peer"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+const (
+	// blockDownloadWindow is the number of blocks, from the first one not
+	// processed yet, which may be downloaded at the same time during a
+	// headers-first synchronization.  The blocks received ahead of their
+	// parents are kept in memory, so the window bounds the memory used.
+	blockDownloadWindow = 512
+
+	// maxBlocksInFlightPerPeer is the maximum number of blocks requested
+	// from a peer which have not been received yet.
+	maxBlocksInFlightPerPeer = 16
+
+	// blockStallTimeout is how long a peer may not deliver any of the
+	// blocks requested from it before they are requested from other peers.
+	blockStallTimeout = 10 * time.Second
+
+	// blockStallCheckInterval is how often the peers are checked for
+	// stalled block downloads.
+	blockStallCheckInterval = 2 * time.Second
+
+	// maxBlockStalls is the number of times a peer may stall the block
+	// download before it is disconnected.
+	maxBlockStalls = 3
+)
+
+// blockDownload is a block of the header chain downloaded during a
+// headers-first synchronization.
+type blockDownload struct {
+	height int32
+	hash   chainhash.Hash
+
+	// peer is the peer the block is requested from, or nil when it is not
+	// requested.
+	peer *peerpkg.Peer
+
+	// block is the block once it was received, until its parent is
+	// processed, and source the peer which sent it.
+	block  *czzutil.Block
+	source *peerpkg.Peer
+}
+
+// headersSync is the state of a headers-first synchronization.  The header
+// chain is downloaded from the sync peer and validated by the chain first,
+// while the blocks of the validated headers are downloaded from all the sync
+// candidates in parallel and processed in order.
+type headersSync struct {
+	// lastHash and lastHeight identify the last header received.
+	lastHash   chainhash.Hash
+	lastHeight int32
+
+	// headersDone is set once the sync peer sent its last header.
+	headersDone bool
+
+	// queue holds the blocks of the received headers which were not
+	// processed yet ordered by height, and downloads indexes them by hash.
+	queue     []*blockDownload
+	downloads map[chainhash.Hash]*blockDownload
+}
+
+// window returns the blocks of the queue which may be downloaded.
+func (hs *headersSync) window() []*blockDownload {
+	if len(hs.queue) > blockDownloadWindow {
+		return hs.queue[:blockDownloadWindow]
+	}
+	return hs.queue
+}
+
+// downloadPeer is a peer the blocks of the download window may be assigned
+// to.
+type downloadPeer struct {
+	peer     *peerpkg.Peer
+	height   int32
+	inFlight int
+}
+
+// assignBlockDownloads assigns the blocks of the passed window which are
+// neither requested nor received to the passed peers.  Each block is assigned
+// to the peer with the fewest blocks in flight among those which announced its
+// height, and no peer is assigned more than maxBlocksInFlightPerPeer blocks in
+// flight.  The blocks are assigned from the lowest height, so the blocks
+// needed next are requested first.
+func assignBlockDownloads(window []*blockDownload, peers []*downloadPeer) map[*peerpkg.Peer][]*blockDownload {
+	assignments := make(map[*peerpkg.Peer][]*blockDownload)
+	for _, d := range window {
+		if d.peer != nil || d.block != nil {
+			continue
+		}
+
+		var best *downloadPeer
+		for _, p := range peers {
+			if p.inFlight >= maxBlocksInFlightPerPeer ||
+				p.height < d.height {
+				continue
+			}
+			if best == nil || p.inFlight < best.inFlight {
+				best = p
+			}
+		}
+		if best == nil {
+			continue
+		}
+		best.inFlight++
+		assignments[best.peer] = append(assignments[best.peer], d)
+	}
+	return assignments
+}
+
+// stalledDownloadPeers returns the peers of the passed window which did not
+// deliver any of the blocks requested from them since blockStallTimeout
+// before the passed time, according to the passed function returning when a
+// peer last made progress.
+func stalledDownloadPeers(window []*blockDownload, progress func(*peerpkg.Peer) time.Time, now time.Time) map[*peerpkg.Peer]struct{} {
+	stalled := make(map[*peerpkg.Peer]struct{})
+	for _, d := range window {
+		if d.peer == nil {
+			continue
+		}
+		if now.Sub(progress(d.peer)) > blockStallTimeout {
+			stalled[d.peer] = struct{}{}
+		}
+	}
+	return stalled
+}
+
+// startHeadersSync starts a headers-first synchronization from the passed
+// peer by requesting the headers after the passed locator.
+func (sm *SyncManager) startHeadersSync(peer *peerpkg.Peer, locator blockchain.BlockLocator) error {
+	if err := peer.PushGetHeadersMsg(locator, &zeroHash); err != nil {
+		return err
+	}
+
+	best := sm.chain.BestSnapshot()
+	sm.headersSync = &headersSync{
+		lastHash:   best.Hash,
+		lastHeight: best.Height,
+		downloads:  make(map[chainhash.Hash]*blockDownload),
+	}
+	log.Infof("Downloading headers from block %d from peer %s",
+		best.Height+1, peer.Addr())
+	return nil
+}
+
+// stopHeadersSync ends the headers-first synchronization and forgets about
+// the blocks requested for it.
+func (sm *SyncManager) stopHeadersSync() {
+	for _, d := range sm.headersSync.window() {
+		sm.releaseDownload(d)
+	}
+	sm.headersSync = nil
+}
+
+// finishHeadersSync ends the headers-first synchronization once the blocks of
+// all the headers were processed and requests the blocks found since then from
+// the sync peer.
+func (sm *SyncManager) finishHeadersSync() {
+	sm.stopHeadersSync()
+	sm.rejectedTxns = make(map[chainhash.Hash]struct{})
+	if err := sm.chain.FlushCachedState(blockchain.FlushPeriodic); err != nil {
+		log.Errorf("Error while flushing the blockchain cache: %v", err)
+	}
+
+	log.Infof("Downloaded the blocks of the header chain -- switching to " +
+		"normal mode")
+	if sm.syncPeer == nil {
+		return
+	}
+	locator, err := sm.chain.LatestBlockLocator()
+	if err != nil {
+		log.Errorf("Failed to get block locator for the latest block: %v",
+			err)
+		return
+	}
+	if err := sm.syncPeer.PushGetBlocksMsg(locator, &zeroHash); err != nil {
+		log.Warnf("Failed to send getblocks message to peer %s: %v",
+			sm.syncPeer.Addr(), err)
+	}
+}
+
+// handleSyncHeaders handles the headers received during a headers-first
+// synchronization.  The headers of the sync peer are validated by the chain
+// and their blocks queued for download, while the headers of the other peers
+// are ignored.
+func (sm *SyncManager) handleSyncHeaders(peer *peerpkg.Peer, msg *wire.MsgHeaders) {
+	hs := sm.headersSync
+	if peer != sm.syncPeer || hs.headersDone {
+		log.Debugf("Ignoring %d unrequested headers from %s",
+			len(msg.Headers), peer)
+		return
+	}
+	if len(msg.Headers) == 0 {
+		hs.headersDone = true
+		sm.processDownloadedBlocks()
+		return
+	}
+
+	// The headers must extend the last header received.  Only the first
+	// batch may fork off the main chain below its tip, when the sync peer
+	// is on another chain.
+	headers := make([]wire.BlockHeader, len(msg.Headers))
+	for i, header := range msg.Headers {
+		headers[i] = *header
+	}
+	prevHash := headers[0].PrevBlock
+	height := hs.lastHeight
+	if prevHash != hs.lastHash {
+		forkHeight, err := sm.chain.BlockHeightByHash(&prevHash)
+		if err != nil || len(hs.queue) > 0 {
+			log.Warnf("Received headers from %s which do not connect "+
+				"to the last header -- disconnecting", peer)
+			peer.Disconnect()
+			return
+		}
+		height = forkHeight
+	}
+
+	if err := sm.chain.ProcessHeaders(headers); err != nil {
+		log.Warnf("Rejected headers from %s: %v -- disconnecting", peer,
+			err)
+		if blockMisbehaving(err) {
+			go sm.peerNotifier.PeerMisbehaved(peer, fmt.Sprintf(
+				"sent invalid headers: %v", err))
+		}
+		peer.Disconnect()
+		return
+	}
+
+	for i := range headers {
+		height++
+		d := &blockDownload{height: height, hash: headers[i].BlockHash()}
+		hs.queue = append(hs.queue, d)
+		hs.downloads[d.hash] = d
+	}
+	hs.lastHash = hs.queue[len(hs.queue)-1].hash
+	hs.lastHeight = height
+	sm.lastProgressTime = time.Now()
+
+	if len(headers) < wire.MaxBlockHeadersPerMsg {
+		hs.headersDone = true
+		log.Infof("Received the headers up to block %d from peer %s: "+
+			"downloading the blocks", height, peer.Addr())
+	} else {
+		locator := blockchain.BlockLocator([]*chainhash.Hash{&hs.lastHash})
+		if err := peer.PushGetHeadersMsg(locator, &zeroHash); err != nil {
+			log.Warnf("Failed to send getheaders message to peer "+
+				"%s: %v", peer.Addr(), err)
+		}
+	}
+
+	sm.progressLogger.SetLastLogTime(time.Now())
+	sm.processDownloadedBlocks()
+	if sm.headersSync != nil {
+		sm.fetchDownloadBlocks()
+	}
+}
+
+// fetchDownloadBlocks requests the blocks of the download window which are
+// neither requested nor received from the sync candidates.  The peers which
+// stalled recently are left out.
+func (sm *SyncManager) fetchDownloadBlocks() {
+	now := time.Now()
+	var peers []*downloadPeer
+	for peer, state := range sm.peerStates {
+		if !state.syncCandidate && peer != sm.syncPeer {
+			continue
+		}
+		if now.Before(state.stalledUntil) {
+			continue
+		}
+		peers = append(peers, &downloadPeer{
+			peer:     peer,
+			height:   peer.TopBlock(),
+			inFlight: len(state.requestedBlocks),
+		})
+	}
+
+	assignments := assignBlockDownloads(sm.headersSync.window(), peers)
+	for peer, downloads := range assignments {
+		state := sm.peerStates[peer]
+		if len(state.requestedBlocks) == 0 {
+			state.downloadProgress = now
+		}
+
+		gdmsg := wire.NewMsgGetDataSizeHint(uint(len(downloads)))
+		for _, d := range downloads {
+			d.peer = peer
+			sm.requestedBlocks[d.hash] = struct{}{}
+			state.requestedBlocks[d.hash] = struct{}{}
+			gdmsg.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, &d.hash))
+		}
+		peer.QueueMessage(gdmsg, nil)
+	}
+}
+
+// releaseDownload forgets about the request of the passed block, so it can be
+// requested again.
+func (sm *SyncManager) releaseDownload(d *blockDownload) {
+	if d.peer == nil {
+		return
+	}
+	if state, exists := sm.peerStates[d.peer]; exists {
+		delete(state.requestedBlocks, d.hash)
+	}
+	delete(sm.requestedBlocks, d.hash)
+	d.peer = nil
+}
+
+// releasePeerDownloads forgets about the requests of the blocks of the
+// download window made to the passed peer, so they can be requested from other
+// peers.
+func (sm *SyncManager) releasePeerDownloads(peer *peerpkg.Peer) {
+	for _, d := range sm.headersSync.window() {
+		if d.peer == peer {
+			sm.releaseDownload(d)
+		}
+	}
+}
+
+// handleDownloadedBlock handles the blocks received during a headers-first
+// synchronization.  The blocks of the download queue are accepted from any
+// peer since their headers were already validated, including from the peers
+// they were requested from before they stalled, and the other blocks are
+// ignored.
+func (sm *SyncManager) handleDownloadedBlock(bmsg *blockMsg) {
+	peer := bmsg.peer
+	state, exists := sm.peerStates[peer]
+	if !exists {
+		log.Warnf("Received block message from unknown peer %s", peer)
+		return
+	}
+
+	blockHash := bmsg.block.Hash()
+	d, exists := sm.headersSync.downloads[*blockHash]
+	if !exists || d.block != nil {
+		log.Debugf("Ignoring block %v from %s which is not downloaded",
+			blockHash, peer)
+		return
+	}
+
+	sm.releaseDownload(d)
+	delete(state.requestedBlocks, *blockHash)
+	state.downloadProgress = time.Now()
+	d.block = bmsg.block
+	d.source = peer
+
+	sm.processDownloadedBlocks()
+	if sm.headersSync != nil {
+		sm.fetchDownloadBlocks()
+	}
+}
+
+// processDownloadedBlocks processes the received blocks of the download queue
+// whose parents were processed.  The proof of work of the blocks is not checked
+// again since it was checked with their headers, and the blocks up to the last
+// checkpoint are added with less validation, like with the checkpoints of the
+// other headers-first modes.
+//
+// A block with transactions not matching its header is requested again from
+// another peer, while any other failure, which proves the header chain of the
+// sync peer leads to an invalid block, disconnects the sync peer.
+func (sm *SyncManager) processDownloadedBlocks() {
+	hs := sm.headersSync
+	fastAddHeight := int32(-1)
+	if checkpoint := sm.lastCheckpoint(); checkpoint != nil {
+		fastAddHeight = checkpoint.Height
+	}
+
+	for len(hs.queue) > 0 {
+		d := hs.queue[0]
+		if d.block == nil {
+			// The blocks the chain already has, such as those of
+			// the main chain below the fork point of the header
+			// chain, are not downloaded.
+			if d.peer != nil {
+				break
+			}
+			if have, err := sm.chain.HaveBlock(&d.hash); err != nil || !have {
+				break
+			}
+			sm.popDownload()
+			continue
+		}
+
+		flags := blockchain.BFNoPoWCheck
+		if d.height <= fastAddHeight {
+			flags |= blockchain.BFFastAdd
+		}
+		_, isOrphan, err := sm.chain.ProcessBlock(d.block, flags)
+		if err == nil && isOrphan {
+			err = fmt.Errorf("block %v does not connect to the chain",
+				d.hash)
+		}
+		if rerr, ok := err.(blockchain.RuleError); ok &&
+			rerr.ErrorCode == blockchain.ErrDuplicateBlock {
+			err = nil
+		}
+		if err != nil {
+			sm.handleDownloadError(d, err)
+			return
+		}
+
+		now := time.Now()
+		d.source.UpdateLastBlockTime(now)
+		sm.lastProgressTime = now
+		if sm.syncPeerState != nil {
+			sm.syncPeerState.lastBlockTime = now
+		}
+		sm.progressLogger.LogBlockHeight(d.block, sm.SyncHeight(), sm.chain)
+		sm.popDownload()
+	}
+
+	if hs.headersDone && len(hs.queue) == 0 {
+		sm.finishHeadersSync()
+	}
+}
+
+// handleDownloadError handles the failure to process the passed downloaded
+// block.
+func (sm *SyncManager) handleDownloadError(d *blockDownload, err error) {
+	if dbErr, ok := err.(database.Error); ok && dbErr.ErrorCode ==
+		database.ErrCorruption {
+		panic(dbErr)
+	}
+
+	log.Infof("Rejected block %v from %s: %v", d.hash, d.source, err)
+	if blockMisbehaving(err) {
+		go sm.peerNotifier.PeerMisbehaved(d.source, fmt.Sprintf(
+			"sent invalid block %v: %v", d.hash, err))
+	}
+	if _, ok := err.(blockchain.RuleError); ok {
+		code, reason := mempool.ErrToRejectErr(err)
+		d.source.PushRejectMsg(wire.CmdBlock, code, reason, &d.hash, false)
+	}
+
+	if rerr, ok := err.(blockchain.RuleError); ok &&
+		rerr.ErrorCode == blockchain.ErrBadMerkleRoot {
+		d.block = nil
+		d.source = nil
+		sm.fetchDownloadBlocks()
+		return
+	}
+
+	log.Warnf("The header chain of sync peer %s leads to block %v which "+
+		"can not be processed -- disconnecting", sm.syncPeer, d.hash)
+	sm.updateSyncPeer(true)
+}
+
+// popDownload removes the first block of the download queue, once it was
+// processed.
+func (sm *SyncManager) popDownload() {
+	hs := sm.headersSync
+	d := hs.queue[0]
+	hs.queue[0] = nil
+	hs.queue = hs.queue[1:]
+	delete(hs.downloads, d.hash)
+}
+
+// handleDownloadStalls reassigns the blocks requested from the peers which
+// stalled the block download to other peers.  The stalled peers are not
+// assigned blocks for blockStallTimeout and are disconnected once they stalled
+// maxBlockStalls times.
+func (sm *SyncManager) handleDownloadStalls() {
+	if sm.headersSync == nil {
+		return
+	}
+
+	now := time.Now()
+	progress := func(peer *peerpkg.Peer) time.Time {
+		if state, exists := sm.peerStates[peer]; exists {
+			return state.downloadProgress
+		}
+		return now
+	}
+	stalled := stalledDownloadPeers(sm.headersSync.window(), progress, now)
+	for peer := range stalled {
+		sm.releasePeerDownloads(peer)
+		state := sm.peerStates[peer]
+		state.downloadStalls++
+		state.stalledUntil = now.Add(blockStallTimeout)
+		if state.downloadStalls >= maxBlockStalls {
+			log.Infof("Peer %s stalled the block download %d times "+
+				"-- disconnecting", peer, state.downloadStalls)
+			peer.Disconnect()
+			continue
+		}
+		log.Debugf("Peer %s stalled the block download -- requesting "+
+			"its blocks from other peers", peer)
+	}
+	if len(stalled) > 0 {
+		sm.fetchDownloadBlocks()
+	}
+}
@@ -0,0 +1,567 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/database"
+	_ "github.com/bourbaki-czz/classzz/database/ffldb"
+	"github.com/bourbaki-czz/classzz/mempool"
+	"github.com/bourbaki-czz/classzz/mining"
+	peerpkg "github.com/bourbaki-czz/classzz/peer"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// newTestDownloads returns the block downloads of the passed number of blocks
+// from height 1.
+func newTestDownloads(numBlocks int) []*blockDownload {
+	downloads := make([]*blockDownload, numBlocks)
+	for i := range downloads {
+		downloads[i] = &blockDownload{height: int32(i + 1)}
+		downloads[i].hash[0] = byte(i + 1)
+	}
+	return downloads
+}
+
+// TestAssignBlockDownloads ensures the blocks of the download window are
+// spread over the peers which have them without exceeding the blocks in
+// flight per peer.
+func TestAssignBlockDownloads(t *testing.T) {
+	short := peerpkg.NewInboundPeer(&peerpkg.Config{})
+	busy := peerpkg.NewInboundPeer(&peerpkg.Config{})
+	idle := peerpkg.NewInboundPeer(&peerpkg.Config{})
+
+	window := newTestDownloads(40)
+	window[0].peer = busy
+	window[1].block = &czzutil.Block{}
+	peers := []*downloadPeer{
+		{peer: short, height: 5},
+		{peer: busy, height: 100, inFlight: maxBlocksInFlightPerPeer - 4},
+		{peer: idle, height: 100},
+	}
+	assignments := assignBlockDownloads(window, peers)
+
+	// The peer with the fewest blocks in flight gets the blocks first, and
+	// the peers with blocks in flight only get blocks once the others have
+	// as many.
+	for peer, want := range map[*peerpkg.Peer]int{
+		short: 2,
+		busy:  4,
+		idle:  maxBlocksInFlightPerPeer,
+	} {
+		if got := len(assignments[peer]); got != want {
+			t.Errorf("got %d blocks assigned to %s, want %d", got,
+				peer, want)
+		}
+	}
+	for _, d := range assignments[short] {
+		if d.height > 5 {
+			t.Errorf("block %d assigned to a peer at height 5",
+				d.height)
+		}
+	}
+	assigned := make(map[*blockDownload]struct{})
+	for _, downloads := range assignments {
+		for _, d := range downloads {
+			if d.peer != nil || d.block != nil {
+				t.Errorf("block %d assigned while requested or "+
+					"received", d.height)
+			}
+			if _, ok := assigned[d]; ok {
+				t.Errorf("block %d assigned twice", d.height)
+			}
+			assigned[d] = struct{}{}
+		}
+	}
+
+	// The lowest blocks are assigned first.
+	for _, d := range window[2:24] {
+		if _, ok := assigned[d]; !ok {
+			t.Errorf("block %d not assigned", d.height)
+		}
+	}
+}
+
+// TestStalledDownloadPeers ensures the peers which did not deliver the blocks
+// requested from them within the stall timeout are reported as stalled.
+func TestStalledDownloadPeers(t *testing.T) {
+	now := time.Now()
+	slow := peerpkg.NewInboundPeer(&peerpkg.Config{})
+	fast := peerpkg.NewInboundPeer(&peerpkg.Config{})
+	progress := map[*peerpkg.Peer]time.Time{
+		slow: now.Add(-blockStallTimeout - time.Second),
+		fast: now.Add(-time.Second),
+	}
+
+	window := newTestDownloads(3)
+	window[0].peer = slow
+	window[1].peer = fast
+	stalled := stalledDownloadPeers(window, func(peer *peerpkg.Peer) time.Time {
+		return progress[peer]
+	}, now)
+	if _, ok := stalled[slow]; !ok || len(stalled) != 1 {
+		t.Errorf("got stalled peers %v, want only the slow peer", stalled)
+	}
+
+	// Peers without blocks in flight do not stall.
+	window[0].peer = nil
+	stalled = stalledDownloadPeers(window, func(peer *peerpkg.Peer) time.Time {
+		return progress[peer]
+	}, now)
+	if len(stalled) != 0 {
+		t.Errorf("got stalled peers %v, want none", stalled)
+	}
+}
+
+// downloadTxSource is a mining transaction source without transactions, so the
+// generated blocks only contain their coinbase.
+type downloadTxSource struct{}
+
+func (downloadTxSource) LastUpdated() time.Time               { return time.Time{} }
+func (downloadTxSource) Sequence() uint64                     { return 0 }
+func (downloadTxSource) MiningDescs() []*mining.TxDesc        { return nil }
+func (downloadTxSource) HaveTransaction(*chainhash.Hash) bool { return false }
+
+// downloadTestNotifier is a peer notifier which records the peers reported as
+// misbehaving.
+type downloadTestNotifier struct {
+	mtx        sync.Mutex
+	misbehaved map[*peerpkg.Peer]struct{}
+}
+
+func (n *downloadTestNotifier) AnnounceNewTransactions([]*mempool.TxDesc) {}
+func (n *downloadTestNotifier) RelayInventory(*wire.InvVect, interface{}) {}
+func (n *downloadTestNotifier) TransactionConfirmed(*czzutil.Tx)          {}
+func (n *downloadTestNotifier) ChainReorganized(*Reorganization)          {}
+
+func (n *downloadTestNotifier) UpdatePeerHeights(*chainhash.Hash, int32, *peerpkg.Peer) {}
+
+func (n *downloadTestNotifier) PeerMisbehaved(peer *peerpkg.Peer, reason string) {
+	n.mtx.Lock()
+	n.misbehaved[peer] = struct{}{}
+	n.mtx.Unlock()
+}
+
+// waitMisbehaved returns whether the passed peer is reported as misbehaving
+// within a second, since the reports are made asynchronously.
+func (n *downloadTestNotifier) waitMisbehaved(peer *peerpkg.Peer) bool {
+	deadline := time.Now().Add(time.Second)
+	for {
+		n.mtx.Lock()
+		_, ok := n.misbehaved[peer]
+		n.mtx.Unlock()
+		if ok || time.Now().After(deadline) {
+			return ok
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// downloadTest is a sync manager downloading the blocks of a source chain from
+// peers which are not connected, so the messages queued to them are dropped.
+// The parameters are a copy of the regression test network parameters, which
+// the sync manager synchronizes headers first with.
+type downloadTest struct {
+	t        *testing.T
+	params   *chaincfg.Params
+	dir      string
+	dbs      []database.DB
+	notifier *downloadTestNotifier
+	sm       *SyncManager
+	source   *blockchain.BlockChain
+}
+
+// newDownloadTest returns a new download test.  It must be torn down.
+func newDownloadTest(t *testing.T) *downloadTest {
+	dir, err := ioutil.TempDir("", "download")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	params := chaincfg.RegressionNetParams
+	dt := &downloadTest{
+		t:      t,
+		params: &params,
+		dir:    dir,
+		notifier: &downloadTestNotifier{
+			misbehaved: make(map[*peerpkg.Peer]struct{}),
+		},
+	}
+
+	chain := dt.newChain("chain")
+	txMemPool := mempool.New(&mempool.Config{
+		Policy: mempool.Policy{
+			MaxTxVersion:          2,
+			MaxOrphanTxSize:       100,
+			MaxOrphanTxs:          1,
+			MaxDataCarrierSize:    mempool.DefaultMaxDataCarrierSize,
+			MaxDataCarrierOutputs: mempool.DefaultMaxDataCarrierOutputs,
+		},
+		ChainParams:    dt.params,
+		FetchUtxoView:  chain.FetchUtxoView,
+		BestHeight:     func() int32 { return chain.BestSnapshot().Height },
+		MedianTimePast: func() time.Time { return chain.BestSnapshot().MedianTime },
+		CalcSequenceLock: func(tx *czzutil.Tx, view *blockchain.UtxoViewpoint) (*blockchain.SequenceLock, error) {
+			return chain.CalcSequenceLock(tx, view, true)
+		},
+		IsDeploymentActive: chain.IsDeploymentActive,
+	})
+	dt.sm, err = New(&Config{
+		PeerNotifier: dt.notifier,
+		Chain:        chain,
+		TxMemPool:    txMemPool,
+		ChainParams:  dt.params,
+		MaxPeers:     8,
+	})
+	if err != nil {
+		dt.teardown()
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	dt.source = dt.newChain("source")
+	return dt
+}
+
+// newChain returns a new chain stored in a database with the passed name.
+func (dt *downloadTest) newChain(name string) *blockchain.BlockChain {
+	db, err := database.Create("ffldb", filepath.Join(dt.dir, name),
+		dt.params.Net)
+	if err != nil {
+		dt.teardown()
+		dt.t.Fatalf("database.Create: unexpected error: %v", err)
+	}
+	dt.dbs = append(dt.dbs, db)
+
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:                 db,
+		ChainParams:        dt.params,
+		TimeSource:         blockchain.NewMedianTime(),
+		ExcessiveBlockSize: 1000000,
+	})
+	if err != nil {
+		dt.teardown()
+		dt.t.Fatalf("blockchain.New: unexpected error: %v", err)
+	}
+	return chain
+}
+
+// teardown closes the databases of the download test and removes them.
+func (dt *downloadTest) teardown() {
+	for _, db := range dt.dbs {
+		db.Close()
+	}
+	os.RemoveAll(dt.dir)
+}
+
+// generate extends the main chain of the passed chain with the passed number
+// of blocks whose coinbases use the passed extra nonce and returns them.
+func (dt *downloadTest) generate(chain *blockchain.BlockChain, n int, extraNonce uint64) []*czzutil.Block {
+	policy := mining.Policy{BlockMaxSize: 1000000}
+	g := mining.NewBlkTmplGenerator(&policy, dt.params, downloadTxSource{},
+		chain, blockchain.NewMedianTime(), txscript.NewSigCache(100),
+		txscript.NewHashCache(100))
+
+	blocks := make([]*czzutil.Block, 0, n)
+	for i := 0; i < n; i++ {
+		template, err := g.NewBlockTemplate(nil)
+		if err != nil {
+			dt.t.Fatalf("NewBlockTemplate: unexpected error: %v", err)
+		}
+		err = g.UpdateExtraNonce(template.Block, template.Height, extraNonce)
+		if err != nil {
+			dt.t.Fatalf("UpdateExtraNonce: unexpected error: %v", err)
+		}
+		block := czzutil.NewBlock(template.Block)
+		_, _, err = chain.ProcessBlock(block, blockchain.BFNoPoWCheck)
+		if err != nil {
+			dt.t.Fatalf("ProcessBlock: unexpected error: %v", err)
+		}
+		block.SetHeight(template.Height)
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// addPeer adds a sync candidate at the passed height to the sync manager like
+// handleNewPeerMsg does, so it becomes the sync peer when there is none.
+func (dt *downloadTest) addPeer(height int32) *peerpkg.Peer {
+	peer := peerpkg.NewInboundPeer(&peerpkg.Config{})
+	peer.UpdateLastBlockHeight(height)
+	dt.sm.peerStates[peer] = &peerSyncState{
+		syncCandidate:   true,
+		requestedTxns:   make(map[chainhash.Hash]struct{}),
+		requestedBlocks: make(map[chainhash.Hash]struct{}),
+	}
+	if dt.sm.syncPeer == nil {
+		dt.sm.startSync()
+	}
+	return peer
+}
+
+// sendHeaders handles the headers of the passed blocks sent by the passed peer.
+func (dt *downloadTest) sendHeaders(peer *peerpkg.Peer, blocks []*czzutil.Block) {
+	msg := wire.NewMsgHeaders()
+	for _, block := range blocks {
+		msg.AddBlockHeader(&block.MsgBlock().Header)
+	}
+	dt.sm.handleHeadersMsg(&headersMsg{headers: msg, peer: peer})
+}
+
+// sendBlock handles the passed block sent by the peer it was requested from.
+func (dt *downloadTest) sendBlock(block *czzutil.Block) {
+	d := dt.download(block)
+	if d.peer == nil {
+		dt.t.Fatalf("block %v is not requested", block.Hash())
+	}
+	dt.sendBlockFrom(d.peer, block)
+}
+
+// sendBlockFrom handles the passed block sent by the passed peer.
+func (dt *downloadTest) sendBlockFrom(peer *peerpkg.Peer, block *czzutil.Block) {
+	block = czzutil.NewBlock(block.MsgBlock())
+	dt.sm.handleDownloadedBlock(&blockMsg{block: block, peer: peer})
+}
+
+// download returns the download of the passed block.
+func (dt *downloadTest) download(block *czzutil.Block) *blockDownload {
+	dt.t.Helper()
+	if dt.sm.headersSync == nil {
+		dt.t.Fatalf("no headers-first synchronization in progress")
+	}
+	d, ok := dt.sm.headersSync.downloads[*block.Hash()]
+	if !ok {
+		dt.t.Fatalf("block %v is not downloaded", block.Hash())
+	}
+	return d
+}
+
+// checkTip ensures the tip of the chain of the sync manager is the passed
+// block and that the synchronization finished.
+func (dt *downloadTest) checkTip(block *czzutil.Block) {
+	dt.t.Helper()
+	best := dt.sm.chain.BestSnapshot()
+	if best.Hash != *block.Hash() {
+		dt.t.Fatalf("got tip %v (height %d), want %v (height %d)",
+			best.Hash, best.Height, block.Hash(), block.Height())
+	}
+	if dt.sm.headersSync != nil {
+		dt.t.Fatalf("headers-first synchronization still in progress")
+	}
+	if len(dt.sm.requestedBlocks) != 0 {
+		dt.t.Fatalf("got %d blocks requested after synchronizing",
+			len(dt.sm.requestedBlocks))
+	}
+}
+
+// TestHeadersSyncOutOfOrder ensures the blocks of the header chain are
+// requested from all the sync candidates and processed in order whatever the
+// order they arrive in.
+func TestHeadersSyncOutOfOrder(t *testing.T) {
+	dt := newDownloadTest(t)
+	defer dt.teardown()
+
+	blocks := dt.generate(dt.source, 6, 0)
+	syncPeer := dt.addPeer(6)
+	other := dt.addPeer(6)
+	if dt.sm.syncPeer != syncPeer {
+		t.Fatalf("got sync peer %v, want %v", dt.sm.syncPeer, syncPeer)
+	}
+	dt.sendHeaders(syncPeer, blocks)
+
+	requested := make(map[*peerpkg.Peer]int)
+	for _, block := range blocks {
+		requested[dt.download(block).peer]++
+	}
+	if requested[syncPeer] != 3 || requested[other] != 3 {
+		t.Fatalf("got %d blocks requested from the sync peer and %d "+
+			"from the other peer, want 3 each", requested[syncPeer],
+			requested[other])
+	}
+
+	// The blocks received ahead of their parents are kept until the
+	// parents are processed.
+	for i := len(blocks) - 1; i > 0; i-- {
+		dt.sendBlock(blocks[i])
+		if height := dt.sm.chain.BestSnapshot().Height; height != 0 {
+			t.Fatalf("block %d processed before its parents: got "+
+				"height %d", blocks[i].Height(), height)
+		}
+	}
+	dt.sendBlock(blocks[0])
+	dt.checkTip(blocks[len(blocks)-1])
+}
+
+// TestHeadersSyncFork ensures the header chain of a sync peer forking off the
+// main chain below its tip is downloaded from the fork point and becomes the
+// main chain once it has more work.
+func TestHeadersSyncFork(t *testing.T) {
+	dt := newDownloadTest(t)
+	defer dt.teardown()
+
+	main := dt.generate(dt.sm.chain, 2, 0)
+	fork := dt.generate(dt.source, 3, 1)
+	peer := dt.addPeer(3)
+	dt.sendHeaders(peer, fork)
+	for _, block := range fork {
+		if d := dt.download(block); d.height != block.Height() {
+			t.Fatalf("got height %d for block %v, want %d", d.height,
+				block.Hash(), block.Height())
+		}
+	}
+
+	for _, block := range fork {
+		dt.sendBlock(block)
+	}
+	dt.checkTip(fork[len(fork)-1])
+	for _, block := range main {
+		if dt.sm.chain.MainChainHasBlock(block.Hash()) {
+			t.Fatalf("block %v of the old chain is in the main chain",
+				block.Hash())
+		}
+	}
+}
+
+// TestHeadersSyncBadMerkleRoot ensures a block whose transactions do not match
+// its header is requested again instead of failing the synchronization, and
+// that the peer which sent it is reported as misbehaving.
+func TestHeadersSyncBadMerkleRoot(t *testing.T) {
+	dt := newDownloadTest(t)
+	defer dt.teardown()
+
+	blocks := dt.generate(dt.source, 2, 0)
+	syncPeer := dt.addPeer(2)
+	dt.sendHeaders(syncPeer, blocks)
+
+	msgBlock := *blocks[0].MsgBlock()
+	coinbase := msgBlock.Transactions[0].Copy()
+	coinbase.TxOut[0].Value--
+	msgBlock.Transactions = []*wire.MsgTx{coinbase}
+	bad := czzutil.NewBlock(&msgBlock)
+	if *bad.Hash() != *blocks[0].Hash() {
+		t.Fatalf("got hash %v for the bad block, want %v", bad.Hash(),
+			blocks[0].Hash())
+	}
+	dt.sendBlock(bad)
+
+	if dt.sm.syncPeer != syncPeer || dt.sm.headersSync == nil {
+		t.Fatal("the synchronization with the sync peer stopped")
+	}
+	d := dt.download(blocks[0])
+	if d.block != nil || d.peer != syncPeer {
+		t.Fatalf("bad block not requested again: got block %v from %v",
+			d.block, d.peer)
+	}
+	if !dt.notifier.waitMisbehaved(syncPeer) {
+		t.Fatal("the peer which sent the bad block is not reported")
+	}
+
+	for _, block := range blocks {
+		dt.sendBlock(block)
+	}
+	dt.checkTip(blocks[len(blocks)-1])
+}
+
+// TestHeadersSyncStall ensures the blocks requested from a peer which stalled
+// the download are requested from the other peers, the stalled peer is not
+// requested blocks for a while, and that the blocks it still sends are
+// accepted.
+func TestHeadersSyncStall(t *testing.T) {
+	dt := newDownloadTest(t)
+	defer dt.teardown()
+
+	blocks := dt.generate(dt.source, 4, 0)
+	stalled := dt.addPeer(4)
+	other := dt.addPeer(4)
+	dt.sendHeaders(stalled, blocks)
+
+	var stalledBlock *czzutil.Block
+	for _, block := range blocks {
+		if dt.download(block).peer == stalled {
+			stalledBlock = block
+			break
+		}
+	}
+	if stalledBlock == nil {
+		t.Fatal("no block requested from the stalled peer")
+	}
+
+	state := dt.sm.peerStates[stalled]
+	state.downloadProgress = time.Now().Add(-blockStallTimeout - time.Second)
+	dt.sm.handleDownloadStalls()
+	for _, block := range blocks {
+		if peer := dt.download(block).peer; peer != other {
+			t.Fatalf("block %d requested from %v, want %v",
+				block.Height(), peer, other)
+		}
+	}
+	if len(state.requestedBlocks) != 0 {
+		t.Fatalf("got %d blocks requested from the stalled peer",
+			len(state.requestedBlocks))
+	}
+	if state.downloadStalls != 1 || !state.stalledUntil.After(time.Now()) {
+		t.Fatalf("got %d stalls until %v, want 1 stall until later",
+			state.downloadStalls, state.stalledUntil)
+	}
+
+	dt.sendBlockFrom(stalled, stalledBlock)
+	d, ok := dt.sm.headersSync.downloads[*stalledBlock.Hash()]
+	if (!ok || d.block == nil) &&
+		!dt.sm.chain.MainChainHasBlock(stalledBlock.Hash()) {
+		t.Fatal("block of the stalled peer not accepted")
+	}
+	for _, block := range blocks {
+		if block != stalledBlock {
+			dt.sendBlock(block)
+		}
+	}
+	dt.checkTip(blocks[len(blocks)-1])
+}
+
+// TestHeadersSyncPeerLoss ensures the synchronization continues from another
+// sync candidate and from the blocks already processed when the sync peer is
+// lost during the download.
+func TestHeadersSyncPeerLoss(t *testing.T) {
+	dt := newDownloadTest(t)
+	defer dt.teardown()
+
+	blocks := dt.generate(dt.source, 4, 0)
+	lost := dt.addPeer(4)
+	other := dt.addPeer(4)
+	dt.sendHeaders(lost, blocks)
+	dt.sendBlock(blocks[0])
+
+	dt.sm.handleDonePeerMsg(lost)
+	if dt.sm.syncPeer != other {
+		t.Fatalf("got sync peer %v, want %v", dt.sm.syncPeer, other)
+	}
+	hs := dt.sm.headersSync
+	if hs == nil || hs.lastHash != *blocks[0].Hash() || len(hs.queue) != 0 {
+		t.Fatal("the synchronization did not restart from the last " +
+			"processed block")
+	}
+	if len(dt.sm.requestedBlocks) != 0 {
+		t.Fatalf("got %d blocks requested after losing the sync peer",
+			len(dt.sm.requestedBlocks))
+	}
+
+	dt.sendHeaders(other, blocks[1:])
+	for _, block := range blocks[1:] {
+		if peer := dt.download(block).peer; peer != other {
+			t.Fatalf("block %d requested from %v, want %v",
+				block.Height(), peer, other)
+		}
+		dt.sendBlock(block)
+	}
+	dt.checkTip(blocks[len(blocks)-1])
+}
@@ -25,6 +25,10 @@ type PeerNotifier interface {
 	RelayInventory(invVect *wire.InvVect, data interface{})
 
 	TransactionConfirmed(tx *czzutil.Tx)
+
+	ChainReorganized(reorg *Reorganization)
+
+	PeerMisbehaved(peer *peer.Peer, reason string)
 }
 
 // Config is a configuration struct used to initialize a new SyncManager.
@@ -42,4 +46,8 @@ type Config struct {
 	MinSyncPeerNetworkSpeed uint64
 
 	FastSyncMode bool
+
+	// Graphene enables requesting blocks using the experimental graphene
+	// block propagation protocol from peers which support it.
+	Graphene bool
 }
@@ -6,6 +6,7 @@ package netsync
 
 import (
 	"container/list"
+	"fmt"
 	"math/rand"
 	"net"
 	"sync"
@@ -23,11 +24,6 @@ import (
 )
 
 const (
-	// minInFlightBlocks is the minimum number of blocks that should be
-	// in the request queue for headers-first mode before requesting
-	// more.
-	minInFlightBlocks = 10
-
 	// maxNetworkViolations is the max number of network violations a
 	// sync peer can have before a new sync peer is found.
 	maxNetworkViolations = 3
@@ -122,6 +118,26 @@ type getSyncPeerMsg struct {
 	reply chan int32
 }
 
+// getPeerSyncStatesMsg is a message type to be sent across the message channel
+// for retrieving the sync state of all peers.
+type getPeerSyncStatesMsg struct {
+	reply chan map[int32]PeerSyncState
+}
+
+// PeerSyncState describes the sync state the sync manager keeps for a peer.
+type PeerSyncState struct {
+	// SyncCandidate is whether the peer may be selected as sync peer.
+	SyncCandidate bool
+
+	// InFlightBlocks are the hashes of the blocks requested from the peer
+	// which have not been received yet.
+	InFlightBlocks []chainhash.Hash
+
+	// InFlightTxns is the number of transactions requested from the peer
+	// which have not been received yet.
+	InFlightTxns int
+}
+
 // processBlockResponse is a response sent to the reply channel of a
 // processBlockMsg.
 type processBlockResponse struct {
@@ -170,6 +186,16 @@ type peerSyncState struct {
 	requestQueue    []*wire.InvVect
 	requestedTxns   map[chainhash.Hash]struct{}
 	requestedBlocks map[chainhash.Hash]struct{}
+
+	// The following fields are used for the parallel block download of a
+	// headers-first synchronization.  downloadProgress is when the peer
+	// last delivered a requested block or was requested blocks while
+	// having none in flight, downloadStalls the number of times it
+	// stalled the download and stalledUntil the time until which it is
+	// not requested blocks after stalling.
+	downloadProgress time.Time
+	downloadStalls   int
+	stalledUntil     time.Time
 }
 
 // syncPeerState stores additional info about the sync peer.
@@ -239,12 +265,17 @@ type SyncManager struct {
 	peerStates       map[*peerpkg.Peer]*peerSyncState
 	lastProgressTime time.Time
 
-	// The following fields are used for headers-first mode.
+	// The following fields are used for the headers-first mode of fast
+	// sync, which downloads the headers up to the checkpoints.
 	headersFirstMode bool
 	headerList       *list.List
 	startHeader      *list.Element
 	nextCheckpoint   *chaincfg.Checkpoint
 
+	// headersSync is the state of the headers-first synchronization with
+	// parallel block download, or nil when none is in progress.
+	headersSync *headersSync
+
 	// An optional fee estimator.
 	feeEstimator *mempool.FeeEstimator
 
@@ -260,6 +291,19 @@ type SyncManager struct {
 	// signal that it has finished the UTXO set download before proceeding
 	// to make the standard getblocks request.
 	fastSyncMode bool
+
+	// graphene requests blocks from peers advertising SFNodeGraphene
+	// using the graphene block propagation protocol.
+	graphene bool
+
+	// blockTimings tracks the propagation timings of the most recently
+	// seen blocks.  It has its own lock.
+	blockTimings *blockTimingTracker
+
+	// reorgRejects remembers the transactions of the blocks disconnected
+	// by an ongoing reorganization the memory pool refused.  It has its
+	// own lock.
+	reorgRejects reorgRejects
 }
 
 // resetHeaderState sets the headers-first mode state to values appropriate for
@@ -379,57 +423,56 @@ func (sm *SyncManager) startSync() {
 		log.Infof("Syncing to block height %d / bestHeight %d from peer %v ",
 			bestPeer.LastBlock(), best.Height, bestPeer.Addr())
 
-		// When the current height is less than a known checkpoint we
-		// can use block headers to learn about which blocks comprise
-		// the chain up to the checkpoint and perform less validation
-		// for them.  This is possible since each header contains the
-		// hash of the previous header and a merkle root.  Therefore if
-		// we validate all of the received headers link together
-		// properly and the checkpoint hashes match, we can be sure the
-		// hashes for the blocks in between are accurate.  Further, once
-		// the full blocks are downloaded, the merkle root is computed
-		// and compared against the value in the header which proves the
-		// full block hasn't been tampered with.
+		// When the chain is behind the peer, download and validate the
+		// header chain of the peer first and then the blocks of the
+		// headers from all the sync candidates in parallel, see
+		// download.go.  Since each header contains the hash of the
+		// previous header and a merkle root, the blocks can be
+		// downloaded out of order and checked against their headers as
+		// they arrive, while they are processed in order.
 		//
-		// Once we have passed the final checkpoint, or checkpoints are
-		// disabled, use standard inv messages learn about the blocks
-		// and fully validate them.  Finally, regression test mode does
-		// not support the headers-first approach so do normal block
-		// downloads when in regression test mode.
-		if sm.nextCheckpoint != nil &&
-			best.Height < sm.nextCheckpoint.Height &&
+		// Fast sync mode downloads the headers between the checkpoints
+		// instead and then waits for the UTXO set to catch up with the
+		// header chain.  Regression test mode does not support the
+		// headers-first approach so do normal block downloads when in
+		// regression test mode.
+		if sm.fastSyncMode {
+			if sm.nextCheckpoint != nil &&
+				best.Height < sm.nextCheckpoint.Height &&
+				sm.chainParams != &chaincfg.RegressionNetParams {
+
+				if err := bestPeer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash); err != nil {
+					log.Infof("Downloading headers for blocks %d to "+
+						"%d from peer %s ,err %s", best.Height+1,
+						sm.nextCheckpoint.Height, bestPeer.Addr(), err.Error())
+					return
+				}
+				sm.headersFirstMode = true
+				log.Infof("Downloading headers for blocks %d to "+
+					"%d from peer %s", best.Height+1,
+					sm.nextCheckpoint.Height, bestPeer.Addr())
+			} else if sm.nextCheckpoint == nil {
+				// If the next checkpoint is nil then we are waiting
+				// for the UTXO set to catch up with header chain.
+				// Let's wait here and then start the sync again.
+				go func() {
+					<-sm.chain.FastSyncDoneChan()
+					sm.fastSyncMode = false
+					sm.startSync()
+				}()
+			}
+		} else if bestPeer.TopBlock() > best.Height &&
 			sm.chainParams != &chaincfg.RegressionNetParams {
 
-			if err := bestPeer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash); err != nil {
-				log.Infof("Downloading headers for blocks %d to "+
-					"%d from peer %s ,err %s", best.Height+1,
-					sm.nextCheckpoint.Height, bestPeer.Addr(), err.Error())
+			if err := sm.startHeadersSync(bestPeer, locator); err != nil {
+				log.Warnf("Failed to send getheaders message to "+
+					"peer %s: %v", bestPeer.Addr(), err)
 				return
 			}
-			sm.headersFirstMode = true
-			log.Infof("Downloading headers for blocks %d to "+
-				"%d from peer %s", best.Height+1,
-				sm.nextCheckpoint.Height, bestPeer.Addr())
-		} else if sm.fastSyncMode && sm.nextCheckpoint == nil {
-			// If fast sync mode is enabled and the next checkpoint is
-			// nil then we are waiting for the UTXO set to catch up with
-			// header chain. Let's wait here and then start the sync again.
-			go func() {
-				<-sm.chain.FastSyncDoneChan()
-				sm.fastSyncMode = false
-				sm.startSync()
-			}()
-		} else if !sm.fastSyncMode {
-
-			// We will only send the getBlocks message if we are not
-			// in fast sync mode. If we are in fast sync mode we will
-			// set this bool to false once the UTXO download/verification
-			// finishes and then we can proceed as if we are syncing
-			// normally.
+		} else {
 			if err := bestPeer.PushGetBlocksMsg(locator, &zeroHash); err != nil {
-				log.Infof("Downloading fastSyncMode headers for blocks %d to "+
-					"%d from peer %s ,err %s", best.Height+1,
-					sm.nextCheckpoint.Height, bestPeer.Addr(), err.Error())
+				log.Warnf("Failed to send getblocks message to "+
+					"peer %s: %v", bestPeer.Addr(), err)
 				return
 			}
 		}
@@ -593,6 +636,13 @@ func (sm *SyncManager) handleDonePeerMsg(peer *peerpkg.Peer) {
 		// Update the sync peer. The server has already disconnected the
 		// peer before signaling to the sync manager.
 		sm.updateSyncPeer(false)
+		return
+	}
+
+	// Request the blocks the peer was downloading from the other peers.
+	if sm.headersSync != nil {
+		sm.releasePeerDownloads(peer)
+		sm.fetchDownloadBlocks()
 	}
 }
 
@@ -627,6 +677,9 @@ func (sm *SyncManager) updateSyncPeer(dcSyncPeer bool) {
 		best := sm.chain.BestSnapshot()
 		sm.resetHeaderState(&best.Hash, best.Height)
 	}
+	if sm.headersSync != nil {
+		sm.stopHeadersSync()
+	}
 
 	sm.syncPeer = nil
 	sm.startSync()
@@ -651,10 +704,15 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 	// interoperability.
 	txHash := tmsg.tx.Hash()
 
+	// Transactions from peers with the forcerelay permission are processed
+	// and relayed even when they were rejected or are already known, and
+	// are not subject to the rate limiting of free transactions.
+	forceRelay := peer.Permissions().Has(peerpkg.PermissionForceRelay)
+
 	// Ignore transactions that we have already rejected.  Do not
 	// send a reject message here because if the transaction was already
 	// rejected, the transaction was unsolicited.
-	if _, exists = sm.rejectedTxns[*txHash]; exists {
+	if _, exists = sm.rejectedTxns[*txHash]; exists && !forceRelay {
 		log.Debugf("Ignoring unsolicited previously rejected "+
 			"transaction %v from %s", txHash, peer)
 		return
@@ -663,7 +721,7 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 	// Process the transaction to include validation, insertion in the
 	// memory pool, orphan handling, etc.
 	acceptedTxs, err := sm.txMemPool.ProcessTransaction(tmsg.tx,
-		true, true, mempool.Tag(peer.ID()))
+		true, !forceRelay, mempool.Tag(peer.ID()))
 
 	// Remove transaction from request maps. Either the mempool/chain
 	// already knows about it and as such we shouldn't have any more
@@ -673,6 +731,16 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 	delete(sm.requestedTxns, *txHash)
 
 	if err != nil {
+		// Relay the transaction again when it is already in the
+		// mempool and the peer has the forcerelay permission.
+		if forceRelay {
+			if txD, err := sm.txMemPool.FetchTxDesc(txHash); err == nil {
+				sm.peerNotifier.AnnounceNewTransactions(
+					[]*mempool.TxDesc{txD})
+				return
+			}
+		}
+
 		// Do not request this transaction again until a new block
 		// has been processed.
 		sm.rejectedTxns[*txHash] = struct{}{}
@@ -685,6 +753,10 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 		if _, ok := err.(mempool.RuleError); ok {
 			log.Debugf("Rejected transaction %v from %s: %v",
 				txHash, peer, err)
+			if txMisbehaving(err) {
+				go sm.peerNotifier.PeerMisbehaved(peer, fmt.Sprintf(
+					"sent invalid transaction %v: %v", txHash, err))
+			}
 		} else {
 			log.Errorf("Failed to process transaction %v: %v",
 				txHash, err)
@@ -698,6 +770,7 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 	}
 
 	if len(acceptedTxs) > 0 {
+		peer.UpdateLastTxTime(time.Now())
 		sm.peerNotifier.AnnounceNewTransactions(acceptedTxs)
 	}
 }
@@ -767,30 +840,6 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg, behaviorFlags blockchain.B
 		}
 	}
 
-	// When in headers-first mode, if the block matches the hash of the
-	// first header in the list of headers that are being fetched, it's
-	// eligible for less validation since the headers have already been
-	// verified to link together and are valid up to the next checkpoint.
-	// Also, remove the list entry for all blocks except the checkpoint
-	// since it is needed to verify the next round of headers links
-	// properly.
-	isCheckpointBlock := false
-	//behaviorFlags := blockchain.BFNone
-	if sm.headersFirstMode {
-		firstNodeEl := sm.headerList.Front()
-		if firstNodeEl != nil {
-			firstNode := firstNodeEl.Value.(*headerNode)
-			if blockHash.IsEqual(firstNode.hash) {
-				behaviorFlags |= blockchain.BFFastAdd
-				if firstNode.hash.IsEqual(sm.nextCheckpoint.Hash) {
-					isCheckpointBlock = true
-				} else {
-					sm.headerList.Remove(firstNodeEl)
-				}
-			}
-		}
-	}
-
 	// Remove block from request maps. Either chain will know about it and
 	// so we shouldn't have any more instances of trying to fetch it, or we
 	// will fail the insert and thus we'll retry next time we get an inv.
@@ -808,6 +857,10 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg, behaviorFlags blockchain.B
 		if _, ok := err.(blockchain.RuleError); ok {
 			log.Infof("Rejected block %v from %s: %v", blockHash,
 				peer, err)
+			if blockMisbehaving(err) {
+				go sm.peerNotifier.PeerMisbehaved(peer, fmt.Sprintf(
+					"sent invalid block %v: %v", blockHash, err))
+			}
 		} else {
 			log.Errorf("Failed to process block %v: %v",
 				blockHash, err)
@@ -862,6 +915,10 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg, behaviorFlags blockchain.B
 			peer.PushGetBlocksMsg(locator, orphanRoot)
 		}
 	} else {
+		// Remember the peer relayed a new block.  Inbound peers which
+		// recently did are protected from eviction.
+		peer.UpdateLastBlockTime(time.Now())
+
 		// Only consider non-orphans for the timer.
 		if peer == sm.syncPeer {
 			sm.syncPeerState.lastBlockTime = time.Now()
@@ -895,69 +952,10 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg, behaviorFlags blockchain.B
 		}
 	}
 
-	// If we are not in headers first mode, it's a good time to periodically
-	// flush the blockchain cache because we don't expect new blocks immediately.
-	// After that, there is nothing more to do.
-
-	if !sm.headersFirstMode {
-		if err := sm.chain.FlushCachedState(blockchain.FlushPeriodic); err != nil {
-			log.Errorf("Error while flushing the blockchain cache: %v", err)
-		}
-		return
-	}
-
-	// This is headers-first mode, so if the block is not a checkpoint
-	// request more blocks using the header list when the request queue is
-	// getting short.
-	log.Debug(" (sm *SyncManager) handleBlockMsg", "isCheckpointBlock", isCheckpointBlock)
-
-	if !isCheckpointBlock {
-		if sm.startHeader != nil &&
-			len(state.requestedBlocks) < minInFlightBlocks {
-			sm.fetchHeaderBlocks()
-		}
-		return
-	}
-
-	// This is headers-first mode and the block is a checkpoint.  When
-	// there is a next checkpoint, get the next round of headers by asking
-	// for headers starting from the block after this one up to the next
-	// checkpoint.
-	prevHeight := sm.nextCheckpoint.Height
-	prevHash := sm.nextCheckpoint.Hash
-	sm.nextCheckpoint = sm.findNextHeaderCheckpoint(prevHeight)
-
-	if sm.nextCheckpoint != nil {
-		log.Debug(" (sm *SyncManager) handleBlockMsg", "sm.nextCheckpoint", sm.nextCheckpoint.Height, "sm.nextCheckpoint", sm.nextCheckpoint.Hash)
-		locator := blockchain.BlockLocator([]*chainhash.Hash{prevHash})
-		err := peer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash)
-		if err != nil {
-			log.Warnf("Failed to send getheaders message to "+
-				"peer %s: %v", peer.Addr(), err)
-			return
-		}
-
-		if sm.syncPeer != nil {
-			log.Infof("Downloading headers for blocks %d to %d from "+
-				"peer %s", prevHeight+1, sm.nextCheckpoint.Height,
-				sm.syncPeer.Addr())
-		}
-		return
-	}
-
-	// This is headers-first mode, the block is a checkpoint, and there are
-	// no more checkpoints, so switch to normal mode by requesting blocks
-	// from the block after this one up to the end of the chain (zero hash).
-	sm.headersFirstMode = false
-	sm.headerList.Init()
-	log.Debug(" (sm *SyncManager) handleBlockMsg", "sm.headersFirstMode", sm.headersFirstMode)
-	log.Infof("Reached the final checkpoint -- switching to normal mode")
-	locator := blockchain.BlockLocator([]*chainhash.Hash{blockHash})
-	err = peer.PushGetBlocksMsg(locator, &zeroHash)
-	if err != nil {
-		log.Warnf("Failed to send getblocks message to peer %s: %v",
-			peer.Addr(), err)
-		return
+	// It's a good time to periodically flush the blockchain cache because
+	// we don't expect new blocks immediately.
+	if err := sm.chain.FlushCachedState(blockchain.FlushPeriodic); err != nil {
+		log.Errorf("Error while flushing the blockchain cache: %v", err)
 	}
 }
 
@@ -971,61 +969,9 @@ func (sm *SyncManager) handleBlockError(msg *blockErrorMsg) {
 	delete(sm.requestedBlocks, *msg.hash)
 }
 
-// fetchHeaderBlocks creates and sends a request to the syncPeer for the next
-// list of blocks to be downloaded based on the current list of headers.
-func (sm *SyncManager) fetchHeaderBlocks() {
-	// Nothing to do if there is no sync peer.
-	if sm.syncPeer == nil {
-		log.Warnf("fetchHeaderBlocks called with no sync peer")
-		return
-	}
-
-	// Nothing to do if there is no start header.
-	if sm.startHeader == nil {
-		log.Warnf("fetchHeaderBlocks called with no start header")
-		return
-	}
-
-	// Build up a getdata request for the list of blocks the headers
-	// describe.  The size hint will be limited to wire.MaxInvPerMsg by
-	// the function, so no need to double check it here.
-	gdmsg := wire.NewMsgGetDataSizeHint(uint(sm.headerList.Len()))
-	numRequested := 0
-	for e := sm.startHeader; e != nil; e = e.Next() {
-		node, ok := e.Value.(*headerNode)
-		if !ok {
-			log.Warn("Header list node type is not a headerNode")
-			continue
-		}
-
-		iv := wire.NewInvVect(wire.InvTypeBlock, node.hash)
-		haveInv, err := sm.haveInventory(iv)
-		if err != nil {
-			log.Warnf("Unexpected failure when checking for "+
-				"existing inventory during header block "+
-				"fetch: %v", err)
-		}
-		if !haveInv {
-			syncPeerState := sm.peerStates[sm.syncPeer]
-
-			sm.requestedBlocks[*node.hash] = struct{}{}
-			syncPeerState.requestedBlocks[*node.hash] = struct{}{}
-
-			gdmsg.AddInvVect(iv)
-			numRequested++
-		}
-		sm.startHeader = e.Next()
-		if numRequested >= wire.MaxInvPerMsg {
-			break
-		}
-	}
-	if len(gdmsg.InvList) > 0 {
-		sm.syncPeer.QueueMessage(gdmsg, nil)
-	}
-}
-
 // handleHeadersMsg handles block header messages from all peers.  Headers are
-// requested when performing a headers-first sync.
+// requested when performing a headers-first sync, either with parallel block
+// download or in fast sync mode.
 func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 	peer := hmsg.peer
 	_, exists := sm.peerStates[peer]
@@ -1033,6 +979,10 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 		log.Warnf("Received headers message from unknown peer %s", peer)
 		return
 	}
+	if sm.headersSync != nil {
+		sm.handleSyncHeaders(peer, hmsg.headers)
+		return
+	}
 
 	// The remote peer is misbehaving if we didn't request headers.
 	msg := hmsg.headers
@@ -1068,10 +1018,7 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 
 		// Ensure the header properly connects to the previous one and
 		// add it to the list of headers.
-		node := headerNode{hash: &blockHash}
-		if sm.fastSyncMode {
-			node.header = blockHeader
-		}
+		node := headerNode{hash: &blockHash, header: blockHeader}
 		prevNode := prevNodeEl.Value.(*headerNode)
 		if prevNode.hash.IsEqual(&blockHeader.PrevBlock) {
 			node.height = prevNode.height + 1
@@ -1108,52 +1055,44 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 		}
 	}
 
-	// When this header is a checkpoint, switch to fetching the blocks for
-	// all of the headers since the last checkpoint.
+	// When this header is a checkpoint, add all of the headers since the
+	// last checkpoint to the block index.
 	if receivedCheckpoint {
 		// Since the first entry of the list is always the final block
 		// that is already in the database and is only used to ensure
 		// the next header links properly, it must be removed before
-		// fetching the blocks.
+		// adding the headers.
 		sm.headerList.Remove(sm.headerList.Front())
 
 		// By this point we've downloaded and validated all headers from the previous
 		// checkpoint to the next checkpoint so we can add them to the block index
 		// all at once.
-		if sm.fastSyncMode {
-			var finalHeight int32
-			for e := sm.startHeader; e != nil; e = e.Next() {
-				node, ok := e.Value.(*headerNode)
-				if !ok {
-					log.Warn("Header list node type is not a headerNode")
-					continue
-				}
-				err := sm.chain.AddHeader(node.header)
-				if err != nil {
-					log.Warnf("Error saving header to block index: %s", err.Error())
-					continue
-				}
-				sm.startHeader = e.Next()
-				finalHeight = node.height
+		var finalHeight int32
+		for e := sm.startHeader; e != nil; e = e.Next() {
+			node, ok := e.Value.(*headerNode)
+			if !ok {
+				log.Warn("Header list node type is not a headerNode")
+				continue
 			}
-			sm.nextCheckpoint = sm.findNextHeaderCheckpoint(finalHeight)
-			// If we've reached the last checkpoint then wait here for the UTXO download to complete before
-			// proceeding with chain sync as normal.
-			if finalHash.IsEqual(sm.lastCheckpoint().Hash) {
-				log.Info("Header download complete waiting for UTXO verification to finish...")
-				sm.headerList.Init()
-				go func() {
-					<-sm.chain.FastSyncDoneChan()
-					sm.fastSyncMode = false
-					sm.startSync()
-				}()
-				return
+			err := sm.chain.AddHeader(node.header)
+			if err != nil {
+				log.Warnf("Error saving header to block index: %s", err.Error())
+				continue
 			}
-		} else {
-			log.Infof("Received %v block headers: Fetching blocks",
-				sm.headerList.Len())
-			sm.progressLogger.SetLastLogTime(time.Now())
-			sm.fetchHeaderBlocks()
+			sm.startHeader = e.Next()
+			finalHeight = node.height
+		}
+		sm.nextCheckpoint = sm.findNextHeaderCheckpoint(finalHeight)
+		// If we've reached the last checkpoint then wait here for the UTXO download to complete before
+		// proceeding with chain sync as normal.
+		if finalHash.IsEqual(sm.lastCheckpoint().Hash) {
+			log.Info("Header download complete waiting for UTXO verification to finish...")
+			sm.headerList.Init()
+			go func() {
+				<-sm.chain.FastSyncDoneChan()
+				sm.fastSyncMode = false
+				sm.startSync()
+			}()
 			return
 		}
 	}
@@ -1247,6 +1186,17 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 		peer.UpdateLastAnnouncedBlock(&invVects[lastBlock].Hash)
 	}
 
+	// Record when the blocks announced once the chain is current are
+	// first seen to measure how fast they propagate.
+	if lastBlock != -1 && sm.current() {
+		now := time.Now()
+		for _, iv := range invVects {
+			if iv.Type == wire.InvTypeBlock {
+				sm.blockTimings.recordInv(&iv.Hash, now)
+			}
+		}
+	}
+
 	// Ignore invs from peers that aren't the sync if we are not current.
 	// Helps prevent fetching a mass of orphans.
 	if peer != sm.syncPeer && !sm.current() || sm.fastSyncMode {
@@ -1280,7 +1230,7 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 		peer.AddKnownInventory(iv)
 
 		// Ignore inventory when we're in headers-first mode.
-		if sm.headersFirstMode {
+		if sm.headersFirstMode || sm.headersSync != nil {
 			continue
 		}
 
@@ -1366,8 +1316,20 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 				sm.limitMap(sm.requestedBlocks, maxRequestedBlocks)
 				state.requestedBlocks[iv.Hash] = struct{}{}
 
-				// Request a compact block if this peer supports it.
-				if sm.current() {
+				// Request a graphene block, or else a compact
+				// block, if this peer supports it.  While syncing
+				// full blocks are requested since the mempool can
+				// not help reconstruct them.
+				if sm.current() && sm.graphene &&
+					peer.Services()&wire.SFNodeGraphene == wire.SFNodeGraphene {
+
+					mempoolCount := uint64(sm.txMemPool.Count())
+					peer.QueueMessage(wire.NewMsgGetGrapheneBlock(
+						&iv.Hash, mempoolCount), nil)
+					numRequested++
+					break
+				}
+				if sm.current() && peer.WantsCompactBlocks() {
 					iv.Type = wire.InvTypeCmpctBlock
 				}
 				gdmsg.AddInvVect(iv)
@@ -1424,6 +1386,8 @@ func (sm *SyncManager) limitMap(m map[chainhash.Hash]struct{}, limit int) {
 func (sm *SyncManager) blockHandler() {
 	stallTicker := time.NewTicker(stallSampleInterval)
 	defer stallTicker.Stop()
+	downloadTicker := time.NewTicker(blockStallCheckInterval)
+	defer downloadTicker.Stop()
 	var bmsgs []*blockMsg
 
 out:
@@ -1437,6 +1401,9 @@ out:
 				bmsgs = []*blockMsg{}
 			}
 
+		case <-downloadTicker.C:
+			sm.handleDownloadStalls()
+
 		case m := <-sm.msgChan:
 			switch msg := m.(type) {
 			case *newPeerMsg:
@@ -1452,7 +1419,9 @@ out:
 				}
 
 			case *blockMsg:
-				if sm.syncPeer == nil {
+				if sm.headersSync != nil {
+					sm.handleDownloadedBlock(msg)
+				} else if sm.syncPeer == nil {
 					sm.handleBlockMsg(msg, blockchain.BFNone)
 					bmsgs = []*blockMsg{}
 
@@ -1494,6 +1463,9 @@ out:
 				}
 				msg.reply <- peerID
 
+			case getPeerSyncStatesMsg:
+				msg.reply <- sm.peerSyncStates()
+
 			case processBlockMsg:
 				_, isOrphan, err := sm.chain.ProcessBlock(
 					msg.block, msg.flags)
@@ -1597,21 +1569,24 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 	// A block has been accepted into the block chain.  Relay it to other
 	// peers.
 	case blockchain.NTBlockAccepted:
-		// Don't relay if we are not current. Other peers that are
-		// current should already know about it.
-		if !sm.current() {
-			return
-		}
-
 		block, ok := notification.Data.(*czzutil.Block)
 		if !ok {
 			log.Warnf("Chain accepted notification is not a block.")
 			break
 		}
+		sm.blockTimings.recordValidated(block.Hash(), block.Height(),
+			time.Now())
+
+		// Don't relay if we are not current. Other peers that are
+		// current should already know about it.
+		if !sm.current() {
+			return
+		}
 
 		// Generate the inventory vector and relay it.
 		iv := wire.NewInvVect(wire.InvTypeBlock, block.Hash())
 		sm.peerNotifier.RelayInventory(iv, block.MsgBlock())
+		sm.blockTimings.recordAnnounced(block.Hash(), time.Now())
 
 	// A block has been connected to the main block chain.
 	case blockchain.NTBlockConnected:
@@ -1669,6 +1644,7 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 				// that depend on it if it wasn't accepted into
 				// the transaction pool.
 				sm.txMemPool.RemoveTransaction(tx, true)
+				sm.reorgRejects.add(tx.Hash(), err)
 			}
 		}
 
@@ -1676,6 +1652,24 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 		if sm.feeEstimator != nil {
 			sm.feeEstimator.Rollback(block.Hash())
 		}
+
+	// The chain was reorganized.  Report what became of the transactions
+	// of the disconnected blocks now that the blocks of the new best chain
+	// are connected.
+	case blockchain.NTChainReorganized:
+		data, ok := notification.Data.(*blockchain.ReorganizationData)
+		if !ok {
+			log.Warnf("Chain reorganized notification is not a " +
+				"reorganization.")
+			break
+		}
+
+		fates := reorganizedFates(data, sm.txMemPool.HaveTransaction,
+			sm.reorgRejects.take())
+		sm.peerNotifier.ChainReorganized(&Reorganization{
+			ReorganizationData: data,
+			Disconnected:       fates,
+		})
 	}
 }
 
@@ -1712,9 +1706,19 @@ func (sm *SyncManager) QueueBlock(block *czzutil.Block, peer *peerpkg.Peer, done
 		return
 	}
 
+	sm.blockTimings.recordBlock(block.Hash(), peer.Addr(), time.Now())
 	sm.msgChan <- &blockMsg{block: block, peer: peer, reply: done}
 }
 
+// BlockHeaderReceived records the reception of the header of the block with the
+// passed hash ahead of its transactions, as in a compact or graphene block, for
+// the block propagation timings.
+//
+// This function is safe for concurrent access.
+func (sm *SyncManager) BlockHeaderReceived(hash *chainhash.Hash) {
+	sm.blockTimings.recordHeader(hash, time.Now())
+}
+
 // QueueBlockError adds the passed block message and peer to the block handling
 // queue to remove the requested block for our queues.
 func (sm *SyncManager) QueueBlockError(hash *chainhash.Hash, peer *peerpkg.Peer) {
@@ -1792,6 +1796,48 @@ func (sm *SyncManager) SyncPeerID() int32 {
 	return <-reply
 }
 
+// PeerSyncStates returns the sync state of every peer known to the sync
+// manager keyed by peer ID.
+func (sm *SyncManager) PeerSyncStates() map[int32]PeerSyncState {
+	reply := make(chan map[int32]PeerSyncState)
+	sm.msgChan <- getPeerSyncStatesMsg{reply: reply}
+	return <-reply
+}
+
+// peerSyncStates returns the sync state of every peer keyed by peer ID.  It
+// MUST only be called from the block handler goroutine.
+func (sm *SyncManager) peerSyncStates() map[int32]PeerSyncState {
+	states := make(map[int32]PeerSyncState, len(sm.peerStates))
+	for peer, state := range sm.peerStates {
+		inFlight := make([]chainhash.Hash, 0, len(state.requestedBlocks))
+		for hash := range state.requestedBlocks {
+			inFlight = append(inFlight, hash)
+		}
+		states[peer.ID()] = PeerSyncState{
+			SyncCandidate:  state.syncCandidate,
+			InFlightBlocks: inFlight,
+			InFlightTxns:   len(state.requestedTxns),
+		}
+	}
+	return states
+}
+
+// BlockTimings returns the propagation timings of the most recently seen
+// blocks, from the first seen to the last seen one.
+//
+// This function is safe for concurrent access.
+func (sm *SyncManager) BlockTimings() []BlockTimings {
+	return sm.blockTimings.snapshot()
+}
+
+// BlockLatencyStats returns the cumulated latencies between the stages of the
+// propagation of the blocks seen since the sync manager was created.
+//
+// This function is safe for concurrent access.
+func (sm *SyncManager) BlockLatencyStats() map[BlockLatency]LatencyStats {
+	return sm.blockTimings.latencyStats()
+}
+
 // ProcessBlock makes use of ProcessBlock on an internal instance of a block
 // chain.
 func (sm *SyncManager) ProcessBlock(block *czzutil.Block, flags blockchain.BehaviorFlags) (bool, error) {
@@ -1838,6 +1884,8 @@ func New(config *Config) (*SyncManager, error) {
 		feeEstimator:            config.FeeEstimator,
 		minSyncPeerNetworkSpeed: config.MinSyncPeerNetworkSpeed,
 		fastSyncMode:            config.FastSyncMode,
+		graphene:                config.Graphene,
+		blockTimings:            newBlockTimingTracker(),
 	}
 
 	best := sm.chain.BestSnapshot()
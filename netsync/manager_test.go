@@ -6,6 +6,7 @@ package netsync_test
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"testing"
@@ -85,9 +86,11 @@ func (ctx *testContext) Setup(config *testConfig) error {
 
 	txMemPool := mempool.New(&mempool.Config{
 		Policy: mempool.Policy{
-			MaxTxVersion:    2,
-			MaxOrphanTxSize: 100,
-			MaxOrphanTxs:    1,
+			MaxTxVersion:          2,
+			MaxOrphanTxSize:       1000,
+			MaxOrphanTxs:          1,
+			MaxDataCarrierSize:    mempool.DefaultMaxDataCarrierSize,
+			MaxDataCarrierOutputs: mempool.DefaultMaxDataCarrierOutputs,
 		},
 		ChainParams:    ctx.cfg.chainParams,
 		FetchUtxoView:  chain.FetchUtxoView,
@@ -207,6 +210,28 @@ func TestPeerConnections(t *testing.T) {
 			"expected %d", syncMgr.SyncPeerID(), localNode2.ID())
 	}
 
+	// The sync states, which are reported by getpeerinfo, tell the sync
+	// candidates apart and have no blocks in flight yet.
+	states := syncMgr.PeerSyncStates()
+	if len(states) != 3 {
+		t.Fatalf("Expected sync states of 3 peers, got %d", len(states))
+	}
+	for _, node := range []*peer.Peer{localNode1, localNode2, localNode3} {
+		state, ok := states[node.ID()]
+		if !ok {
+			t.Fatalf("No sync state for peer %d", node.ID())
+		}
+		wantCandidate := node != localNode1
+		if state.SyncCandidate != wantCandidate {
+			t.Fatalf("Expected peer %d sync candidate %v, got %v",
+				node.ID(), wantCandidate, state.SyncCandidate)
+		}
+		if len(state.InFlightBlocks) != 0 {
+			t.Fatalf("Expected no blocks in flight from peer %d, got %v",
+				node.ID(), state.InFlightBlocks)
+		}
+	}
+
 	// SyncManager should unregister peer when it is done. When sync peer drops,
 	// manager should start syncing from another valid peer.
 	syncMgr.DonePeer(localNode2, syncChan)
@@ -244,6 +269,8 @@ func TestPeerConnections(t *testing.T) {
 func TestBlockchainSync(t *testing.T) {
 	chainParams := chaincfg.RegressionNetParams
 	chainParams.CoinbaseMaturity = 1
+	// The coinbases created by rpctest do not spend the coin pools.
+	chainParams.EntangleHeight = math.MaxInt32
 
 	var ctx testContext
 	err := ctx.Setup(&testConfig{
@@ -296,14 +323,18 @@ func TestBlockchainSync(t *testing.T) {
 	}
 	syncMgr.NewPeer(localNode, nil)
 
-	// SyncManager should send a getblocks message to start block download
+	// SyncManager should send a getblocks message to start block download,
+	// since the remote node did not announce any block to download the
+	// headers of first.  The chain stores the genesis block under the hash
+	// of its header, which the locator starts from.
+	genesisHash := chainParams.GenesisBlock.BlockHash()
 	select {
 	case msg := <-remoteMessages.getBlocksChan:
 		if msg.HashStop != zeroHash {
 			t.Fatalf("Expected no hash stop in getblocks, got %v", msg.HashStop)
 		}
 		if len(msg.BlockLocatorHashes) != 1 ||
-			*msg.BlockLocatorHashes[0] != *chainParams.GenesisHash {
+			*msg.BlockLocatorHashes[0] != genesisHash {
 			t.Fatal("Received unexpected block locator in getblocks message")
 		}
 	case <-time.After(time.Second):
@@ -316,7 +347,10 @@ func TestBlockchainSync(t *testing.T) {
 		t.Fatalf("Error constructing P2SH address: %v", err)
 	}
 
+	// The height of the genesis block is not known from the block alone,
+	// but the coinbase of the block building on it must commit to height 1.
 	genesisBlock := czzutil.NewBlock(chainParams.GenesisBlock)
+	genesisBlock.SetHeight(0)
 
 	// Generate chain of 3 blocks
 	blocks := make([]*czzutil.Block, 0, 3)
@@ -368,16 +402,21 @@ func TestBlockchainSync(t *testing.T) {
 			localNode.LastBlock())
 	}
 
-	if syncMgr.IsCurrent() {
-		t.Fatal("Expected IsCurrent() to be false as blocks have old " +
-			"timestamps")
-	}
-
-	// Check that no blocks were relayed to peers since syncer is not current
-	select {
-	case <-ctx.peerNotifier.relayInventoryChan:
-		t.Fatal("PeerNotifier received unexpected RelayInventory call")
-	default:
+	// The chain is current regardless of the timestamp of its tip, see
+	// BlockChain.IsCurrent, so the blocks were relayed to the peers as
+	// they were processed.
+	for _, block := range blocks {
+		select {
+		case call := <-ctx.peerNotifier.relayInventoryChan:
+			if call.invVect.Type != wire.InvTypeBlock ||
+				call.invVect.Hash != *block.Hash() {
+				t.Fatalf("PeerNotifier received unexpected "+
+					"RelayInventory call: %v", call.invVect)
+			}
+		default:
+			t.Fatalf("Expected SyncManager to relay block %d",
+				block.Height())
+		}
 	}
 
 	// Create current block with a non-Coinbase transaction
@@ -512,6 +551,8 @@ func TestBlockchainSync(t *testing.T) {
 func TestMempoolSync(t *testing.T) {
 	chainParams := chaincfg.RegressionNetParams
 	chainParams.CoinbaseMaturity = 1
+	// The coinbases created by rpctest do not spend the coin pools.
+	chainParams.EntangleHeight = math.MaxInt32
 
 	var ctx testContext
 	err := ctx.Setup(&testConfig{
@@ -558,7 +599,10 @@ func TestMempoolSync(t *testing.T) {
 		t.Fatalf("Error constructing P2SH address: %v", err)
 	}
 
+	// The height of the genesis block is not known from the block alone,
+	// but the coinbase of the block building on it must commit to height 1.
 	genesisBlock := czzutil.NewBlock(chainParams.GenesisBlock)
+	genesisBlock.SetHeight(0)
 
 	// Generate block with spendable coinbase
 	blockVersion := int32(2)
@@ -717,6 +761,106 @@ func TestMempoolSync(t *testing.T) {
 	}
 }
 
+// TestCompactBlockRequests ensures announced blocks are only requested as
+// compact blocks from peers which asked for them with a sendcmpct message.
+func TestCompactBlockRequests(t *testing.T) {
+	chainParams := &chaincfg.MainNetParams
+
+	var ctx testContext
+	err := ctx.Setup(&testConfig{
+		dbName:      "TestCompactBlockRequests",
+		chainParams: chainParams,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Teardown()
+
+	syncMgr := ctx.syncManager
+	syncMgr.Start()
+
+	remoteMessages := newMessageChans()
+	remotePeerCfg := peer.Config{
+		Listeners: peer.MessageListeners{
+			OnGetData: func(p *peer.Peer, msg *wire.MsgGetData) {
+				remoteMessages.getDataChan <- msg
+			},
+		},
+		UserAgentName:    "btcdtest",
+		UserAgentVersion: "1.0",
+		ChainParams:      chainParams,
+		Services:         0,
+	}
+	localPeerCfg := remotePeerCfg
+	localPeerCfg.Listeners = peer.MessageListeners{}
+
+	remoteNode, localNode, err := MakeConnectedPeers(remotePeerCfg,
+		localPeerCfg, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	syncChan := make(chan struct{})
+	syncMgr.NewPeer(localNode, syncChan)
+	select {
+	case <-syncChan:
+	case <-time.After(time.Second):
+		t.Fatalf("Timeout waiting for sync manager to register peer %d",
+			localNode.ID())
+	}
+
+	// requestType announces a new block from the remote peer and returns
+	// the inventory type it is requested with.
+	requestType := func(b byte) wire.InvType {
+		hash := chainhash.Hash{b}
+		inv := wire.NewMsgInv()
+		inv.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, &hash))
+		syncMgr.QueueInv(inv, localNode)
+
+		select {
+		case msg := <-remoteMessages.getDataChan:
+			if len(msg.InvList) != 1 || msg.InvList[0].Hash != hash {
+				t.Fatalf("Received unexpected getdata message %v",
+					msg.InvList)
+			}
+			return msg.InvList[0].Type
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for remote node to receive " +
+				"getdata message")
+		}
+		return 0
+	}
+
+	// A full block is requested from a peer which did not ask for compact
+	// blocks.
+	if localNode.WantsCompactBlocks() {
+		t.Fatal("Peer wants compact blocks before sending sendcmpct")
+	}
+	if invType := requestType(1); invType != wire.InvTypeBlock {
+		t.Fatalf("Expected block to be requested as %v, got %v",
+			wire.InvTypeBlock, invType)
+	}
+
+	// A compact block is requested once the peer asked for them.
+	remoteNode.QueueMessage(wire.NewMsgSendCmpct(true,
+		wire.CompactBlocksProtocolVersion), nil)
+	if !WaitUntil(localNode.WantsCompactBlocks, time.Second) {
+		t.Fatal("Timeout waiting for peer to want compact blocks")
+	}
+	if !localNode.WantsDirectBlockRelay() {
+		t.Fatal("Peer does not want direct block relay after sendcmpct " +
+			"with announce set")
+	}
+	if invType := requestType(2); invType != wire.InvTypeCmpctBlock {
+		t.Fatalf("Expected block to be requested as %v, got %v",
+			wire.InvTypeCmpctBlock, invType)
+	}
+
+	err = syncMgr.Stop()
+	if err != nil {
+		t.Fatalf("failed to stop SyncManager: %v", err)
+	}
+}
+
 type msgChans struct {
 	memPoolChan    chan *wire.MsgMemPool
 	txChan         chan *wire.MsgTx
@@ -753,7 +897,8 @@ func buildBlockInv(blocks ...*czzutil.Block) *wire.MsgInv {
 }
 
 // createSpendingTx constructs a transaction spending from the provided one
-// which sends the entire value of one output to the given address.
+// which sends the entire value of one output to the given address.  A null data
+// output pads the transaction to the minimum transaction size.
 func createSpendingTx(prevTx *czzutil.Tx, index uint32, scriptSig []byte, address czzutil.Address) (*czzutil.Tx, error) {
 	scriptPubKey, err := txscript.PayToAddrScript(address)
 	if err != nil {
@@ -767,5 +912,13 @@ func createSpendingTx(prevTx *czzutil.Tx, index uint32, scriptSig []byte, addres
 	spendTx := wire.NewMsgTx(1)
 	spendTx.AddTxIn(wire.NewTxIn(prevOutPoint, scriptSig))
 	spendTx.AddTxOut(wire.NewTxOut(prevOut.Value, scriptPubKey))
+	if spendTx.SerializeSize() < blockchain.MinTransactionSize {
+		padding := make([]byte, blockchain.MinTransactionSize-spendTx.SerializeSize())
+		nullData, err := txscript.NullDataScript(padding)
+		if err != nil {
+			return nil, err
+		}
+		spendTx.AddTxOut(wire.NewTxOut(0, nullData))
+	}
 	return czzutil.NewTx(spendTx), nil
 }
@@ -0,0 +1,70 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/mempool"
+)
+
+// blockMisbehaving returns whether err, as returned when processing a block
+// sent by a peer, proves the peer is misbehaving.  Blocks violating the
+// consensus rules can not be relayed by an honest peer since they do not
+// depend on the state of the node, so the peer is banned right away.
+//
+// Errors which depend on the local clock or configuration, which are caused by
+// the order blocks arrive in, or which are not caused by the block at all are
+// not punished.
+func blockMisbehaving(err error) bool {
+	rerr, ok := err.(blockchain.RuleError)
+	if !ok {
+		return false
+	}
+
+	switch rerr.ErrorCode {
+	// The block is already known or does not connect to the chain yet, its
+	// timestamp is judged by the local clock or its maximum size is
+	// configured by the operator.
+	case blockchain.ErrDuplicateBlock,
+		blockchain.ErrPreviousBlockUnknown,
+		blockchain.ErrPrevBlockNotBest,
+		blockchain.ErrTimeTooNew,
+		blockchain.ErrBlockTooBig:
+
+		return false
+	}
+	return true
+}
+
+// txMisbehaving returns whether err, as returned when processing a transaction
+// sent by a peer, proves the peer is misbehaving.  Only transactions which can
+// never be valid are punished.  Policy rejections, such as an insufficient fee
+// or a non-standard script, and rejections depending on the current state of
+// the chain, such as immature or missing inputs, are not.
+func txMisbehaving(err error) bool {
+	rerr, ok := err.(mempool.RuleError)
+	if !ok {
+		return false
+	}
+	cerr, ok := rerr.Err.(blockchain.RuleError)
+	if !ok {
+		return false
+	}
+
+	switch cerr.ErrorCode {
+	case blockchain.ErrNoTxInputs,
+		blockchain.ErrNoTxOutputs,
+		blockchain.ErrTxTooBig,
+		blockchain.ErrTxTooSmall,
+		blockchain.ErrBadTxOutValue,
+		blockchain.ErrDuplicateTxInputs,
+		blockchain.ErrBadTxInput,
+		blockchain.ErrSpendTooHigh,
+		blockchain.ErrBadFees:
+
+		return true
+	}
+	return false
+}
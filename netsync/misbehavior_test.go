@@ -0,0 +1,60 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/mempool"
+	"github.com/bourbaki-czz/classzz/wire"
+)
+
+// TestMisbehaving ensures validation errors are mapped to misbehavior as
+// expected.
+func TestMisbehaving(t *testing.T) {
+	chainErr := func(c blockchain.ErrorCode) blockchain.RuleError {
+		return blockchain.RuleError{ErrorCode: c}
+	}
+
+	blockTests := []struct {
+		err  error
+		want bool
+	}{
+		{chainErr(blockchain.ErrHighHash), true},
+		{chainErr(blockchain.ErrBadMerkleRoot), true},
+		{chainErr(blockchain.ErrScriptValidation), true},
+		{chainErr(blockchain.ErrDuplicateBlock), false},
+		{chainErr(blockchain.ErrTimeTooNew), false},
+		{chainErr(blockchain.ErrBlockTooBig), false},
+		{errors.New("database failure"), false},
+	}
+	for i, test := range blockTests {
+		if got := blockMisbehaving(test.err); got != test.want {
+			t.Errorf("blockMisbehaving #%d (%v): got %v, want %v", i,
+				test.err, got, test.want)
+		}
+	}
+
+	txTests := []struct {
+		err  error
+		want bool
+	}{
+		{mempool.RuleError{Err: chainErr(blockchain.ErrNoTxInputs)}, true},
+		{mempool.RuleError{Err: chainErr(blockchain.ErrBadFees)}, true},
+		{mempool.RuleError{Err: chainErr(blockchain.ErrImmatureSpend)}, false},
+		{mempool.RuleError{Err: chainErr(blockchain.ErrScriptValidation)}, false},
+		{mempool.RuleError{Err: mempool.TxRuleError{
+			RejectCode: wire.RejectInsufficientFee}}, false},
+		{chainErr(blockchain.ErrNoTxInputs), false},
+	}
+	for i, test := range txTests {
+		if got := txMisbehaving(test.err); got != test.want {
+			t.Errorf("txMisbehaving #%d (%v): got %v, want %v", i,
+				test.err, got, test.want)
+		}
+	}
+}
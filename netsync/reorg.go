@@ -0,0 +1,154 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// TxFate describes what became of a transaction of a block disconnected by a
+// reorganization of the chain.
+type TxFate int
+
+const (
+	// TxFateConfirmed indicates the transaction was confirmed again by a
+	// block of the new best chain.
+	TxFateConfirmed TxFate = iota
+
+	// TxFateMempool indicates the transaction re-entered the memory pool
+	// and awaits confirmation again.
+	TxFateMempool
+
+	// TxFateInvalid indicates the transaction is not valid anymore, such
+	// as when it conflicts with a transaction of the new best chain, and
+	// will not be confirmed.
+	TxFateInvalid
+)
+
+// txFateStrings is a map of transaction fates back to the names reported to
+// the clients.
+var txFateStrings = map[TxFate]string{
+	TxFateConfirmed: "confirmed",
+	TxFateMempool:   "mempool",
+	TxFateInvalid:   "invalid",
+}
+
+// String returns the TxFate in human-readable form.
+func (f TxFate) String() string {
+	if s, ok := txFateStrings[f]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown TxFate (%d)", int(f))
+}
+
+// ReorganizedTx describes the fate of a transaction of a block disconnected by
+// a reorganization.
+type ReorganizedTx struct {
+	Tx   *czzutil.Tx
+	Fate TxFate
+
+	// Block is the hash of the block of the new best chain confirming the
+	// transaction when it was confirmed again.
+	Block *chainhash.Hash
+
+	// Reason is why the transaction is not valid anymore when it is not.
+	Reason string
+}
+
+// ReorganizedBlock describes the fates of the transactions, except the
+// coinbase, of a block disconnected by a reorganization.
+type ReorganizedBlock struct {
+	Block *czzutil.Block
+	Txs   []ReorganizedTx
+}
+
+// Reorganization describes a reorganization of the chain along with the fates
+// of the transactions of the disconnected blocks, in the order the blocks were
+// disconnected.
+type Reorganization struct {
+	*blockchain.ReorganizationData
+	Disconnected []ReorganizedBlock
+}
+
+// reorgRejects remembers why the transactions of the blocks disconnected by an
+// ongoing reorganization were refused by the memory pool, until the
+// reorganization completes and their fates are reported.
+//
+// The blocks are disconnected by whichever goroutine processes them, such as
+// the RPC server for the invalidateblock command, so it has its own lock.
+type reorgRejects struct {
+	mtx     sync.Mutex
+	rejects map[chainhash.Hash]error
+}
+
+// add records the passed transaction was refused by the memory pool.
+func (r *reorgRejects) add(txHash *chainhash.Hash, err error) {
+	r.mtx.Lock()
+	if r.rejects == nil {
+		r.rejects = make(map[chainhash.Hash]error)
+	}
+	r.rejects[*txHash] = err
+	r.mtx.Unlock()
+}
+
+// take returns the recorded refusals and forgets them.
+func (r *reorgRejects) take() map[chainhash.Hash]error {
+	r.mtx.Lock()
+	rejects := r.rejects
+	r.rejects = nil
+	r.mtx.Unlock()
+	return rejects
+}
+
+// reorganizedFates returns the fates of the transactions of the blocks the
+// passed reorganization disconnected.  The transactions confirmed by an
+// attached block are confirmed, the ones found in the memory pool by the
+// passed function re-entered it and the others are invalid, for the reason the
+// memory pool refused them if it is among the passed ones.  Otherwise they
+// were accepted but evicted once the attached blocks confirmed a conflicting
+// transaction.
+func reorganizedFates(data *blockchain.ReorganizationData,
+	inMempool func(*chainhash.Hash) bool,
+	rejects map[chainhash.Hash]error) []ReorganizedBlock {
+
+	confirmedBy := make(map[chainhash.Hash]*chainhash.Hash)
+	for _, block := range data.Attached {
+		for _, tx := range block.Transactions()[1:] {
+			confirmedBy[*tx.Hash()] = block.Hash()
+		}
+	}
+
+	fates := make([]ReorganizedBlock, 0, len(data.Detached))
+	for _, block := range data.Detached {
+		txs := block.Transactions()[1:]
+		reorgBlock := ReorganizedBlock{
+			Block: block,
+			Txs:   make([]ReorganizedTx, 0, len(txs)),
+		}
+		for _, tx := range txs {
+			fate := ReorganizedTx{Tx: tx}
+			if blockHash, ok := confirmedBy[*tx.Hash()]; ok {
+				fate.Fate = TxFateConfirmed
+				fate.Block = blockHash
+			} else if inMempool(tx.Hash()) {
+				fate.Fate = TxFateMempool
+			} else {
+				fate.Fate = TxFateInvalid
+				fate.Reason = "conflicts with the new best chain"
+				if err, ok := rejects[*tx.Hash()]; ok {
+					fate.Reason = err.Error()
+				}
+			}
+			reorgBlock.Txs = append(reorgBlock.Txs, fate)
+		}
+		fates = append(fates, reorgBlock)
+	}
+	return fates
+}
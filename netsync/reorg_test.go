@@ -0,0 +1,136 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// reorgTestBlock returns a block at the passed height with a coinbase and a
+// transaction for each of the passed lock times, which tell them apart.
+func reorgTestBlock(height int32, lockTimes ...uint32) *czzutil.Block {
+	msgBlock := &wire.MsgBlock{
+		Header: wire.BlockHeader{Nonce: uint64(height)},
+	}
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: []byte{byte(height), 0},
+	})
+	coinbase.AddTxOut(&wire.TxOut{Value: 1})
+	msgBlock.AddTransaction(coinbase)
+	for _, lockTime := range lockTimes {
+		tx := wire.NewMsgTx(1)
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Index: lockTime},
+		})
+		tx.AddTxOut(&wire.TxOut{Value: 1})
+		tx.LockTime = lockTime
+		msgBlock.AddTransaction(tx)
+	}
+	block := czzutil.NewBlock(msgBlock)
+	block.SetHeight(height)
+	return block
+}
+
+// TestReorganizedFates ensures the transactions of the disconnected blocks are
+// reported as confirmed by the attached blocks, back in the memory pool or
+// invalid along with the reason.
+func TestReorganizedFates(t *testing.T) {
+	detached := []*czzutil.Block{
+		reorgTestBlock(11, 1, 2),
+		reorgTestBlock(10, 3, 4),
+	}
+	attached := []*czzutil.Block{
+		reorgTestBlock(10),
+		reorgTestBlock(11, 2, 5),
+	}
+	data := &blockchain.ReorganizationData{
+		Detached: detached,
+		Attached: attached,
+	}
+	txHash := func(block, tx int) chainhash.Hash {
+		return *detached[block].Transactions()[tx].Hash()
+	}
+
+	mempool := map[chainhash.Hash]bool{txHash(0, 1): true}
+	inMempool := func(hash *chainhash.Hash) bool {
+		return mempool[*hash]
+	}
+	rejects := map[chainhash.Hash]error{
+		txHash(1, 1): errors.New("output already spent"),
+		txHash(0, 2): errors.New("rejected before confirmed again"),
+	}
+	fates := reorganizedFates(data, inMempool, rejects)
+
+	tests := []struct {
+		block, tx int
+		fate      TxFate
+		confirmed *chainhash.Hash
+		reason    string
+	}{
+		{0, 1, TxFateMempool, nil, ""},
+		{0, 2, TxFateConfirmed, attached[1].Hash(), ""},
+		{1, 1, TxFateInvalid, nil, "output already spent"},
+		{1, 2, TxFateInvalid, nil, "conflicts with the new best chain"},
+	}
+	if len(fates) != len(detached) {
+		t.Fatalf("got %d blocks, want %d", len(fates), len(detached))
+	}
+	for i, block := range fates {
+		if block.Block != detached[i] {
+			t.Fatalf("block %d: got %v, want %v", i, block.Block.Hash(),
+				detached[i].Hash())
+		}
+		if len(block.Txs) != 2 {
+			t.Fatalf("block %d: got %d transactions, want 2", i,
+				len(block.Txs))
+		}
+	}
+	for _, test := range tests {
+		fate := fates[test.block].Txs[test.tx-1]
+		if *fate.Tx.Hash() != txHash(test.block, test.tx) {
+			t.Errorf("block %d tx %d: unexpected transaction %v",
+				test.block, test.tx, fate.Tx.Hash())
+			continue
+		}
+		if fate.Fate != test.fate || fate.Reason != test.reason {
+			t.Errorf("block %d tx %d: got %v (%q), want %v (%q)",
+				test.block, test.tx, fate.Fate, fate.Reason,
+				test.fate, test.reason)
+		}
+		if (fate.Block == nil) != (test.confirmed == nil) ||
+			(fate.Block != nil && *fate.Block != *test.confirmed) {
+
+			t.Errorf("block %d tx %d: got confirming block %v, want %v",
+				test.block, test.tx, fate.Block, test.confirmed)
+		}
+	}
+}
+
+// TestTxFateStringer tests the stringized output for the TxFate type.
+func TestTxFateStringer(t *testing.T) {
+	tests := []struct {
+		in   TxFate
+		want string
+	}{
+		{TxFateConfirmed, "confirmed"},
+		{TxFateMempool, "mempool"},
+		{TxFateInvalid, "invalid"},
+		{0xffff, "Unknown TxFate (65535)"},
+	}
+	for _, test := range tests {
+		if got := test.in.String(); got != test.want {
+			t.Errorf("String: got %q, want %q", got, test.want)
+		}
+	}
+}
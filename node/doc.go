@@ -0,0 +1,34 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package node embeds a full node in the process of an application.  The node
+validates the chain it syncs from a set of peers and keeps a mempool of the
+transactions they relay, so that applications such as indexers and bridges can
+use the chain, the mempool and the indexes directly rather than over the RPC
+server of czzd:
+
+	n := node.New(&node.Config{
+		DataDir:     dataDir,
+		ChainParams: &chaincfg.MainNetParams,
+		Peers:       []string{"203.0.113.5", "pool.example.com:8333"},
+		TxIndex:     true,
+	})
+	if err := n.Start(ctx); err != nil {
+		return err
+	}
+	n.Chain().Subscribe(func(ntfn *blockchain.Notification) {
+		...
+	})
+	<-n.Done()
+
+The node only connects to the configured peers, and it neither accepts inbound
+connections nor serves blocks or runs an RPC server.  The transactions of its
+mempool are served to its peers, so the transactions submitted through the node
+are relayed.  The node stops once the context passed to Start is canceled or
+Stop is called.
+
+The package logs nothing unless a logger is set with UseLogger.
+*/
+package node
@@ -0,0 +1,531 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/blockchain/indexers"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/connmgr"
+	"github.com/bourbaki-czz/classzz/database"
+	_ "github.com/bourbaki-czz/classzz/database/ffldb"
+	"github.com/bourbaki-czz/classzz/mempool"
+	"github.com/bourbaki-czz/classzz/netsync"
+	"github.com/bourbaki-czz/classzz/peer"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+const (
+	// DefaultDbType is the database backend used when none is configured.
+	DefaultDbType = "ffldb"
+
+	// DefaultUtxoCacheMaxSize is the default maximum size in bytes of the
+	// cache of unspent transaction outputs.
+	DefaultUtxoCacheMaxSize = 450 * 1024 * 1024
+
+	// DefaultDBCacheSize is the default maximum size in bytes of the
+	// database cache.
+	DefaultDBCacheSize = 500 * 1024 * 1024
+
+	// DefaultSigCacheMaxSize is the default maximum number of entries of
+	// the signature and the hash caches.
+	DefaultSigCacheMaxSize = 100000
+
+	// DefaultExcessiveBlockSize is the default maximum size in bytes of the
+	// blocks accepted.
+	DefaultExcessiveBlockSize = 8000000
+
+	// blockDbNamePrefix is the prefix of the name of the block database,
+	// which is the same as the one of czzd so that a node can be embedded
+	// on the data directory of czzd.
+	blockDbNamePrefix = "blocks"
+
+	// userAgentName is the name the node reports to its peers.
+	userAgentName = "/classzz-node"
+)
+
+var (
+	// ErrStarted is returned by Start when the node was already started.
+	ErrStarted = errors.New("node already started")
+
+	// ErrNotStarted is returned when the node is used before it was
+	// started.
+	ErrNotStarted = errors.New("node not started")
+)
+
+// Config is the configuration of an embedded node.  Only DataDir is required,
+// the zero value of the other fields selects the defaults.
+type Config struct {
+	// DataDir is the directory the block database is stored in.  The
+	// database is named like the one of czzd, so the data directory of a
+	// czzd node which is not running may be used.
+	DataDir string
+
+	// DbType is the database backend.  It defaults to DefaultDbType.
+	DbType string
+
+	// ChainParams identifies the network.  It defaults to the main network.
+	ChainParams *chaincfg.Params
+
+	// Peers are the addresses of the peers the node stays connected with
+	// and syncs from.  The default port of the network is used for the
+	// addresses without a port.  The node only syncs the chain that was
+	// already stored when there are none.
+	Peers []string
+
+	// Dial connects to the peers.  It defaults to net.Dial with a timeout
+	// of 30 seconds.
+	Dial func(net.Addr) (net.Conn, error)
+
+	// TxIndex and AddrIndex enable the transaction and the address
+	// indexes, which may be queried through the accessors of the node.
+	// The address index requires the transaction index, which is enabled
+	// along with it.
+	TxIndex   bool
+	AddrIndex bool
+
+	// DisableCheckpoints disables the checkpoints of the network.
+	DisableCheckpoints bool
+
+	// UtxoCacheMaxSize and DBCacheSize are the maximum sizes in bytes of
+	// the cache of unspent transaction outputs and of the database cache.
+	// They default to DefaultUtxoCacheMaxSize and DefaultDBCacheSize.
+	UtxoCacheMaxSize uint64
+	DBCacheSize      uint64
+
+	// SigCacheMaxSize is the maximum number of entries of the signature
+	// and the hash caches.  It defaults to DefaultSigCacheMaxSize.
+	SigCacheMaxSize uint
+
+	// ExcessiveBlockSize is the maximum size in bytes of the blocks
+	// accepted.  It defaults to DefaultExcessiveBlockSize.
+	ExcessiveBlockSize uint32
+
+	// MempoolPolicy is the policy of the transaction memory pool.  The
+	// defaults of czzd are used when it is nil.
+	MempoolPolicy *mempool.Policy
+
+	// BlocksOnly disables the relay of transactions by the peers, so only
+	// the transactions submitted through the node enter its mempool.
+	BlocksOnly bool
+
+	// UserAgentComments are added to the user agent reported to the peers.
+	UserAgentComments []string
+
+	// The RPC servers and credentials of the chains the coins are
+	// entangled from, used to verify the entangle transactions.
+	DogeCoinRPC     []string
+	DogeCoinRPCUser string
+	DogeCoinRPCPass string
+	LtcCoinRPC      []string
+	LtcCoinRPCUser  string
+	LtcCoinRPCPass  string
+}
+
+// Node is a full node embedded in the process of an application.  It validates
+// the chain it syncs from the configured peers, and keeps a mempool of the
+// transactions they relay, so that applications such as indexers and bridges
+// access the chain and the mempool directly instead of through the RPC server
+// of czzd.
+//
+// The node does not accept inbound connections, serve blocks or run an RPC
+// server.  It serves the transactions of its mempool to its peers, so the
+// transactions submitted through it are relayed.
+type Node struct {
+	started  int32
+	shutdown int32
+
+	cfg         Config
+	chainParams *chaincfg.Params
+	db          database.DB
+	chain       *blockchain.BlockChain
+	txMemPool   *mempool.TxPool
+	syncManager *netsync.SyncManager
+	connManager *connmgr.ConnManager
+	timeSource  blockchain.MedianTimeSource
+	txIndex     *indexers.TxIndex
+	addrIndex   *indexers.AddrIndex
+
+	peersMtx sync.Mutex
+	peers    map[*peer.Peer]*nodePeer
+
+	wg   sync.WaitGroup
+	quit chan struct{}
+	done chan struct{}
+}
+
+// New returns a new node with the passed configuration.  Nothing is opened or
+// connected to until the node is started.
+func New(cfg *Config) *Node {
+	n := &Node{
+		cfg:   *cfg,
+		peers: make(map[*peer.Peer]*nodePeer),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if n.cfg.DbType == "" {
+		n.cfg.DbType = DefaultDbType
+	}
+	n.chainParams = n.cfg.ChainParams
+	if n.chainParams == nil {
+		n.chainParams = &chaincfg.MainNetParams
+	}
+	if n.cfg.Dial == nil {
+		n.cfg.Dial = func(addr net.Addr) (net.Conn, error) {
+			return net.DialTimeout(addr.Network(), addr.String(),
+				30*time.Second)
+		}
+	}
+	if n.cfg.UtxoCacheMaxSize == 0 {
+		n.cfg.UtxoCacheMaxSize = DefaultUtxoCacheMaxSize
+	}
+	if n.cfg.DBCacheSize == 0 {
+		n.cfg.DBCacheSize = DefaultDBCacheSize
+	}
+	if n.cfg.SigCacheMaxSize == 0 {
+		n.cfg.SigCacheMaxSize = DefaultSigCacheMaxSize
+	}
+	if n.cfg.ExcessiveBlockSize == 0 {
+		n.cfg.ExcessiveBlockSize = DefaultExcessiveBlockSize
+	}
+	return n
+}
+
+// Start opens the block database, loads the chain, and starts syncing from
+// the peers.  It returns once the node is running, which may take a while when
+// the indexes catch up with the chain.  Canceling the passed context aborts
+// the start, or stops the node once it is running.
+//
+// The limits of the wire protocol are global to the process, so the excessive
+// block size of the last node started applies to all of them.
+func (n *Node) Start(ctx context.Context) error {
+	if atomic.AddInt32(&n.started, 1) != 1 {
+		return ErrStarted
+	}
+	if err := n.start(ctx); err != nil {
+		if n.db != nil {
+			n.db.Close()
+		}
+		atomic.StoreInt32(&n.shutdown, 1)
+		close(n.done)
+		return err
+	}
+
+	// Stop the node once the context is canceled.
+	go func() {
+		select {
+		case <-ctx.Done():
+			n.Stop()
+		case <-n.quit:
+		}
+	}()
+	return nil
+}
+
+// start opens the block database, creates the chain and starts connecting to
+// the peers.
+func (n *Node) start(ctx context.Context) error {
+	if n.cfg.DataDir == "" {
+		return errors.New("node: no data directory configured")
+	}
+
+	wire.SetLimits(n.cfg.ExcessiveBlockSize)
+
+	addrs, err := n.resolvePeers()
+	if err != nil {
+		return err
+	}
+	n.db, err = n.openBlockDB()
+	if err != nil {
+		return err
+	}
+	if err := n.createChain(ctx); err != nil {
+		return err
+	}
+
+	// The connection manager only connects to the configured peers since
+	// it is not given a source of new addresses.
+	n.connManager, err = connmgr.New(&connmgr.Config{
+		TargetOutbound: uint32(len(addrs)),
+		OnConnection:   n.outboundPeerConnected,
+		Dial:           n.cfg.Dial,
+	})
+	if err != nil {
+		return err
+	}
+
+	n.syncManager.Start()
+	n.connManager.Start()
+	for _, addr := range addrs {
+		go n.connManager.Connect(&connmgr.ConnReq{
+			Addr:      addr,
+			Permanent: true,
+		})
+	}
+	log.Infof("Node started on %s with %d peers", n.chainParams.Name,
+		len(addrs))
+	return nil
+}
+
+// Stop disconnects the peers, stops syncing and closes the block database.
+// It is safe to call it more than once and it returns once the node stopped.
+func (n *Node) Stop() error {
+	if atomic.LoadInt32(&n.started) == 0 {
+		return ErrNotStarted
+	}
+	if atomic.AddInt32(&n.shutdown, 1) != 1 {
+		<-n.done
+		return nil
+	}
+	close(n.quit)
+
+	log.Info("Node shutting down")
+	if n.connManager != nil {
+		n.connManager.Stop()
+		n.connManager.Wait()
+	}
+	n.peersMtx.Lock()
+	for p := range n.peers {
+		p.Disconnect()
+	}
+	n.peersMtx.Unlock()
+	n.wg.Wait()
+
+	var err error
+	if n.syncManager != nil {
+		n.syncManager.Stop()
+		err = n.chain.FlushCachedState(blockchain.FlushRequired)
+	}
+	if n.db != nil {
+		if dbErr := n.db.Close(); err == nil {
+			err = dbErr
+		}
+	}
+	close(n.done)
+	log.Info("Node stopped")
+	return err
+}
+
+// Done returns a channel which is closed once the node stopped.
+func (n *Node) Done() <-chan struct{} {
+	return n.done
+}
+
+// Chain returns the block chain of the node, or nil before it was started.
+func (n *Node) Chain() *blockchain.BlockChain {
+	return n.chain
+}
+
+// TxMemPool returns the transaction memory pool of the node, or nil before it
+// was started.
+func (n *Node) TxMemPool() *mempool.TxPool {
+	return n.txMemPool
+}
+
+// SyncManager returns the sync manager of the node, or nil before it was
+// started.
+func (n *Node) SyncManager() *netsync.SyncManager {
+	return n.syncManager
+}
+
+// DB returns the block database of the node, or nil before it was started.
+func (n *Node) DB() database.DB {
+	return n.db
+}
+
+// TxIndex returns the transaction index of the node, or nil when it is not
+// enabled.
+func (n *Node) TxIndex() *indexers.TxIndex {
+	return n.txIndex
+}
+
+// AddrIndex returns the address index of the node, or nil when it is not
+// enabled.
+func (n *Node) AddrIndex() *indexers.AddrIndex {
+	return n.addrIndex
+}
+
+// ConnectedCount returns the number of connected peers.
+func (n *Node) ConnectedCount() int {
+	n.peersMtx.Lock()
+	defer n.peersMtx.Unlock()
+	return len(n.peers)
+}
+
+// SubmitTransaction adds the passed transaction to the mempool and announces
+// it to the peers.  The descriptors of the transactions accepted to the
+// mempool are returned, which include the orphans the transaction made
+// acceptable.
+func (n *Node) SubmitTransaction(tx *czzutil.Tx) ([]*mempool.TxDesc, error) {
+	if n.txMemPool == nil {
+		return nil, ErrNotStarted
+	}
+	acceptedTxs, err := n.txMemPool.ProcessTransaction(tx, false, false, 0)
+	if err != nil {
+		return nil, err
+	}
+	n.relayTransactions(acceptedTxs)
+	return acceptedTxs, nil
+}
+
+// resolvePeers resolves the addresses of the configured peers.
+func (n *Node) resolvePeers() ([]net.Addr, error) {
+	addrs := make([]net.Addr, 0, len(n.cfg.Peers))
+	for _, peerAddr := range n.cfg.Peers {
+		if _, _, err := net.SplitHostPort(peerAddr); err != nil {
+			peerAddr = net.JoinHostPort(peerAddr,
+				n.chainParams.DefaultPort)
+		}
+		addr, err := net.ResolveTCPAddr("tcp", peerAddr)
+		if err != nil {
+			return nil, fmt.Errorf("node: unable to resolve peer "+
+				"%s: %v", peerAddr, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// openBlockDB opens the block database, creating it when it does not exist.
+func (n *Node) openBlockDB() (database.DB, error) {
+	dbPath := filepath.Join(n.cfg.DataDir, blockDbNamePrefix+"_"+
+		n.cfg.DbType)
+	log.Infof("Loading block database from '%s'", dbPath)
+	db, err := database.Open(n.cfg.DbType, dbPath, n.chainParams.Net,
+		n.cfg.DBCacheSize, uint32(0))
+	if err == nil {
+		return db, nil
+	}
+	if dbErr, ok := err.(database.Error); !ok || dbErr.ErrorCode !=
+		database.ErrDbDoesNotExist {
+
+		return nil, err
+	}
+
+	if err := os.MkdirAll(n.cfg.DataDir, 0700); err != nil {
+		return nil, err
+	}
+	return database.Create(n.cfg.DbType, dbPath, n.chainParams.Net,
+		n.cfg.DBCacheSize, uint32(0))
+}
+
+// createChain creates the indexes, the block chain, the mempool and the sync
+// manager of the node like czzd does.
+func (n *Node) createChain(ctx context.Context) error {
+	// CAUTION: the txindex needs to be first in the indexes array because
+	// the addrindex uses data from the txindex during catchup.
+	var indexes []indexers.Indexer
+	if n.cfg.TxIndex || n.cfg.AddrIndex {
+		n.txIndex = indexers.NewTxIndex(n.db)
+		indexes = append(indexes, n.txIndex)
+	}
+	if n.cfg.AddrIndex {
+		n.addrIndex = indexers.NewAddrIndex(n.db, n.chainParams)
+		indexes = append(indexes, n.addrIndex)
+	}
+	var indexManager blockchain.IndexManager
+	if len(indexes) > 0 {
+		indexManager = indexers.NewManager(n.db, indexes)
+	}
+
+	var checkpoints []chaincfg.Checkpoint
+	if !n.cfg.DisableCheckpoints {
+		checkpoints = n.chainParams.Checkpoints
+	}
+
+	n.timeSource = blockchain.NewMedianTime()
+	sigCache := txscript.NewSigCache(n.cfg.SigCacheMaxSize)
+	hashCache := txscript.NewHashCache(n.cfg.SigCacheMaxSize)
+	var err error
+	n.chain, err = blockchain.New(&blockchain.Config{
+		DB:                 n.db,
+		UtxoCacheMaxSize:   n.cfg.UtxoCacheMaxSize,
+		Interrupt:          ctx.Done(),
+		ChainParams:        n.chainParams,
+		Checkpoints:        checkpoints,
+		TimeSource:         n.timeSource,
+		SigCache:           sigCache,
+		IndexManager:       indexManager,
+		HashCache:          hashCache,
+		ExcessiveBlockSize: n.cfg.ExcessiveBlockSize,
+		DogeCoinRPC:        n.cfg.DogeCoinRPC,
+		DogeCoinRPCUser:    n.cfg.DogeCoinRPCUser,
+		DogeCoinRPCPass:    n.cfg.DogeCoinRPCPass,
+		LtcCoinRPC:         n.cfg.LtcCoinRPC,
+		LtcCoinRPCUser:     n.cfg.LtcCoinRPCUser,
+		LtcCoinRPCPass:     n.cfg.LtcCoinRPCPass,
+	})
+	if err != nil {
+		return err
+	}
+
+	policy := mempool.Policy{
+		FreeTxRelayLimit:      15.0,
+		MaxOrphanTxs:          100,
+		MaxOrphanTxSize:       100000,
+		MaxSigOpPerTx:         blockchain.MaxTransactionSigOps,
+		MinRelayTxFee:         mempool.DefaultMinRelayTxFee,
+		MaxTxVersion:          2,
+		MaxDataCarrierSize:    mempool.DefaultMaxDataCarrierSize,
+		MaxDataCarrierOutputs: mempool.DefaultMaxDataCarrierOutputs,
+	}
+	if n.cfg.MempoolPolicy != nil {
+		policy = *n.cfg.MempoolPolicy
+	}
+	chain := n.chain
+	n.txMemPool = mempool.New(&mempool.Config{
+		Policy:                policy,
+		ChainParams:           n.chainParams,
+		FetchUtxoView:         chain.FetchUtxoView,
+		FetchEntangleUtxoView: chain.GetEntangleVerify().Cache.FetchEntangleUtxoView,
+		EntangleVerify:        chain.GetEntangleVerify(),
+		BestHeight:            func() int32 { return chain.BestSnapshot().Height },
+		MedianTimePast:        func() time.Time { return chain.BestSnapshot().MedianTime },
+		CalcSequenceLock: func(tx *czzutil.Tx, view *blockchain.UtxoViewpoint) (*blockchain.SequenceLock, error) {
+			return chain.CalcSequenceLock(tx, view, true)
+		},
+		IsDeploymentActive:      chain.IsDeploymentActive,
+		CheckTransactionScripts: chain.CheckTransactionScripts,
+		SigCache:                sigCache,
+		HashCache:               hashCache,
+		AddrIndex:               n.addrIndex,
+	})
+
+	// The sync manager sizes its queue by the number of peers, which must
+	// not be zero.
+	n.syncManager, err = netsync.New(&netsync.Config{
+		PeerNotifier:       (*peerNotifier)(n),
+		Chain:              chain,
+		TxMemPool:          n.txMemPool,
+		ChainParams:        n.chainParams,
+		DisableCheckpoints: n.cfg.DisableCheckpoints,
+		MaxPeers:           len(n.cfg.Peers) + 1,
+	})
+	return err
+}
+
+// relayTransactions announces the passed transactions to the peers.
+func (n *Node) relayTransactions(txns []*mempool.TxDesc) {
+	n.peersMtx.Lock()
+	defer n.peersMtx.Unlock()
+	for _, txD := range txns {
+		iv := wire.NewInvVect(wire.InvTypeTx, txD.Tx.Hash())
+		for p := range n.peers {
+			p.QueueInventory(iv)
+		}
+	}
+}
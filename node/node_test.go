@@ -0,0 +1,69 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package node
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+)
+
+// TestNodeStartStop ensures a node without peers loads the chain of an empty
+// data directory and stops once its context is canceled.
+func TestNodeStartStop(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "nodetest")
+	if err != nil {
+		t.Fatalf("unable to create the data directory: %v", err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	if err := New(&Config{}).Start(context.Background()); err == nil {
+		t.Fatalf("Start: no error without a data directory")
+	}
+
+	n := New(&Config{
+		DataDir:     dataDir,
+		ChainParams: &chaincfg.RegressionNetParams,
+		TxIndex:     true,
+	})
+	if err := n.Stop(); err != ErrNotStarted {
+		t.Fatalf("Stop: got %v before the start, want %v", err,
+			ErrNotStarted)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := n.Start(ctx); err != nil {
+		t.Fatalf("Start: unexpected error: %v", err)
+	}
+	if err := n.Start(ctx); err != ErrStarted {
+		t.Fatalf("Start: got %v when started twice, want %v", err,
+			ErrStarted)
+	}
+
+	best := n.Chain().BestSnapshot()
+	if best.Height != 0 {
+		t.Errorf("got best block at height %d, want the genesis block",
+			best.Height)
+	}
+	if n.TxMemPool().Count() != 0 {
+		t.Errorf("got %d transactions in the mempool, want 0",
+			n.TxMemPool().Count())
+	}
+	if n.TxIndex() == nil || n.AddrIndex() != nil {
+		t.Errorf("got tx index %v and address index %v, want only the "+
+			"tx index", n.TxIndex(), n.AddrIndex())
+	}
+	if n.ConnectedCount() != 0 {
+		t.Errorf("got %d connected peers, want 0", n.ConnectedCount())
+	}
+
+	cancel()
+	<-n.Done()
+	if err := n.Stop(); err != nil {
+		t.Errorf("Stop: unexpected error after the stop: %v", err)
+	}
+}
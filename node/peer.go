@@ -0,0 +1,249 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package node
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/connmgr"
+	"github.com/bourbaki-czz/classzz/mempool"
+	"github.com/bourbaki-czz/classzz/netsync"
+	"github.com/bourbaki-czz/classzz/peer"
+	"github.com/bourbaki-czz/classzz/version"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// userAgentVersion is the version the node reports to its peers.
+var userAgentVersion = fmt.Sprintf("%d.%d.%d", version.AppMajor,
+	version.AppMinor, version.AppPatch)
+
+// nodePeer extends a peer with the state the node keeps about it.
+type nodePeer struct {
+	*peer.Peer
+
+	node           *Node
+	connReq        *connmgr.ConnReq
+	blockProcessed chan struct{}
+	txProcessed    chan struct{}
+}
+
+// newPeerConfig returns the configuration of the passed peer.  The node does
+// not advertise any services since it does not serve blocks.
+func (n *Node) newPeerConfig(np *nodePeer) *peer.Config {
+	return &peer.Config{
+		Listeners: peer.MessageListeners{
+			OnVersion: np.OnVersion,
+			OnTx:      np.OnTx,
+			OnBlock:   np.OnBlock,
+			OnInv:     np.OnInv,
+			OnHeaders: np.OnHeaders,
+			OnGetData: np.OnGetData,
+			OnReject:  np.OnReject,
+		},
+		NewestBlock: func() (*chainhash.Hash, int32, error) {
+			best := n.chain.BestSnapshot()
+			return &best.Hash, best.Height, nil
+		},
+		UserAgentName:     userAgentName,
+		UserAgentVersion:  userAgentVersion,
+		UserAgentComments: n.cfg.UserAgentComments,
+		ChainParams:       n.chainParams,
+		DisableRelayTx:    n.cfg.BlocksOnly,
+		ProtocolVersion:   peer.MaxProtocolVersion,
+	}
+}
+
+// outboundPeerConnected is invoked by the connection manager when a connection
+// to one of the peers is established.
+func (n *Node) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
+	np := &nodePeer{
+		node:           n,
+		connReq:        c,
+		blockProcessed: make(chan struct{}, 1),
+		txProcessed:    make(chan struct{}, 1),
+	}
+	p, err := peer.NewOutboundPeer(n.newPeerConfig(np), c.Addr.String())
+	if err != nil {
+		log.Debugf("Cannot create outbound peer %s: %v", c.Addr, err)
+		n.connManager.Disconnect(c.ID())
+		return
+	}
+	np.Peer = p
+
+	n.peersMtx.Lock()
+	n.peers[p] = np
+	n.peersMtx.Unlock()
+
+	np.AssociateConnection(conn)
+	n.wg.Add(1)
+	go n.peerDoneHandler(np)
+}
+
+// peerDoneHandler removes the passed peer once it disconnected and has the
+// connection manager reconnect to it unless the node is shutting down.
+func (n *Node) peerDoneHandler(np *nodePeer) {
+	np.WaitForDisconnect()
+
+	n.peersMtx.Lock()
+	delete(n.peers, np.Peer)
+	n.peersMtx.Unlock()
+
+	if np.VersionKnown() {
+		n.syncManager.DonePeer(np.Peer, nil)
+	}
+	select {
+	case <-n.quit:
+	default:
+		n.connManager.Disconnect(np.connReq.ID())
+	}
+	log.Debugf("Peer %s disconnected", np)
+	n.wg.Done()
+}
+
+// OnVersion is invoked when the peer receives a version message.  The peer is
+// offered to the sync manager as a sync candidate.
+func (np *nodePeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) *wire.MsgReject {
+	np.node.timeSource.AddTimeSample(np.Addr(), msg.Timestamp)
+	np.node.syncManager.NewPeer(np.Peer, nil)
+	return nil
+}
+
+// OnTx is invoked when the peer receives a tx message.  It blocks until the
+// transaction was processed by the sync manager.
+func (np *nodePeer) OnTx(_ *peer.Peer, msg *wire.MsgTx) {
+	if np.node.cfg.BlocksOnly {
+		return
+	}
+
+	tx := czzutil.NewTx(msg)
+	np.AddKnownInventory(wire.NewInvVect(wire.InvTypeTx, tx.Hash()))
+	np.node.syncManager.QueueTx(tx, np.Peer, np.txProcessed)
+	<-np.txProcessed
+}
+
+// OnBlock is invoked when the peer receives a block message.  It blocks until
+// the block was processed by the sync manager.
+func (np *nodePeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
+	block := czzutil.NewBlockFromBlockAndBytes(msg, buf)
+	np.AddKnownInventory(wire.NewInvVect(wire.InvTypeBlock, block.Hash()))
+	np.node.syncManager.QueueBlock(block, np.Peer, np.blockProcessed)
+	<-np.blockProcessed
+}
+
+// OnInv is invoked when the peer receives an inv message.  The inventory is
+// passed to the sync manager, without the transactions when only blocks are
+// relayed.
+func (np *nodePeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
+	if !np.node.cfg.BlocksOnly {
+		if len(msg.InvList) > 0 {
+			np.node.syncManager.QueueInv(msg, np.Peer)
+		}
+		return
+	}
+
+	newInv := wire.NewMsgInvSizeHint(uint(len(msg.InvList)))
+	for _, invVect := range msg.InvList {
+		if invVect.Type == wire.InvTypeTx {
+			continue
+		}
+		if err := newInv.AddInvVect(invVect); err != nil {
+			break
+		}
+	}
+	if len(newInv.InvList) > 0 {
+		np.node.syncManager.QueueInv(newInv, np.Peer)
+	}
+}
+
+// OnHeaders is invoked when the peer receives a headers message.  The headers
+// are passed to the sync manager.
+func (np *nodePeer) OnHeaders(_ *peer.Peer, msg *wire.MsgHeaders) {
+	np.node.syncManager.QueueHeaders(msg, np.Peer)
+}
+
+// OnGetData is invoked when the peer receives a getdata message.  Only the
+// transactions of the mempool are served, the other inventory is reported as
+// not found.
+func (np *nodePeer) OnGetData(_ *peer.Peer, msg *wire.MsgGetData) {
+	notFound := wire.NewMsgNotFound()
+	for _, iv := range msg.InvList {
+		if iv.Type == wire.InvTypeTx {
+			tx, err := np.node.txMemPool.FetchTransaction(&iv.Hash)
+			if err == nil {
+				np.QueueMessage(tx.MsgTx(), nil)
+				continue
+			}
+		}
+		notFound.AddInvVect(iv)
+	}
+	if len(notFound.InvList) > 0 {
+		np.QueueMessage(notFound, nil)
+	}
+}
+
+// OnReject logs the reject messages received from the peer.
+func (np *nodePeer) OnReject(_ *peer.Peer, msg *wire.MsgReject) {
+	log.Debugf("Rejected %v from %s: %v", msg.Cmd, np, msg.Reason)
+}
+
+// peerNotifier implements the netsync.PeerNotifier interface for the node.
+type peerNotifier Node
+
+// Ensure peerNotifier implements the netsync.PeerNotifier interface.
+var _ netsync.PeerNotifier = (*peerNotifier)(nil)
+
+// AnnounceNewTransactions announces the transactions accepted to the mempool
+// to the peers.
+func (pn *peerNotifier) AnnounceNewTransactions(newTxs []*mempool.TxDesc) {
+	(*Node)(pn).relayTransactions(newTxs)
+}
+
+// UpdatePeerHeights updates the heights of the peers which announced the
+// passed block, other than the peer which it came from.
+func (pn *peerNotifier) UpdatePeerHeights(latestBlkHash *chainhash.Hash, latestHeight int32, updateSource *peer.Peer) {
+	pn.peersMtx.Lock()
+	defer pn.peersMtx.Unlock()
+	for p := range pn.peers {
+		if p == updateSource {
+			continue
+		}
+		announced := p.LastAnnouncedBlock()
+		if announced != nil && *announced == *latestBlkHash {
+			p.UpdateLastBlockHeight(latestHeight)
+			p.UpdateLastAnnouncedBlock(nil)
+		}
+	}
+}
+
+// RelayInventory relays the inventory of the transactions to the peers.  The
+// blocks are not relayed since the node does not serve them.
+func (pn *peerNotifier) RelayInventory(invVect *wire.InvVect, data interface{}) {
+	if invVect.Type != wire.InvTypeTx {
+		return
+	}
+	pn.peersMtx.Lock()
+	defer pn.peersMtx.Unlock()
+	for p := range pn.peers {
+		p.QueueInventory(invVect)
+	}
+}
+
+// TransactionConfirmed does nothing since the node does not rebroadcast
+// transactions.
+func (pn *peerNotifier) TransactionConfirmed(tx *czzutil.Tx) {}
+
+// ChainReorganized does nothing, the applications are notified of the blocks
+// disconnected through the notifications of the chain.
+func (pn *peerNotifier) ChainReorganized(reorg *netsync.Reorganization) {}
+
+// PeerMisbehaved disconnects the passed peer.  It is reconnected to after the
+// retry duration of the connection manager.
+func (pn *peerNotifier) PeerMisbehaved(p *peer.Peer, reason string) {
+	log.Warnf("Disconnecting misbehaving peer %s: %s", p, reason)
+	p.Disconnect()
+}
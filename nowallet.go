@@ -0,0 +1,53 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build !wallet
+
+package main
+
+import (
+	"errors"
+
+	"github.com/bourbaki-czz/classzz/blockchain/indexers"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// walletSupported indicates whether the built-in wallet is compiled in, which
+// is the case when building with the wallet build tag.
+const walletSupported = false
+
+// errWalletNotSupported is returned by the wallet functions when the built-in
+// wallet is not compiled in.
+var errWalletNotSupported = errors.New("the built-in wallet requires a node " +
+	"built with the wallet build tag")
+
+// nodeWallet stands in for the built-in wallet when it is not compiled in.  It
+// is never created, so the wallet RPCs are left to an external wallet.
+type nodeWallet struct {
+	indexers.Indexer
+}
+
+// openWallet returns an error since the built-in wallet is not compiled in.
+func openWallet(db database.DB, chainParams *chaincfg.Params) (*nodeWallet, error) {
+	return nil, errWalletNotSupported
+}
+
+// createWallet returns an error since the built-in wallet is not compiled in.
+func createWallet(chainParams *chaincfg.Params) error {
+	return errWalletNotSupported
+}
+
+// dropWalletIndex returns an error since the built-in wallet is not compiled
+// in.
+func dropWalletIndex(db database.DB, interrupt <-chan struct{}) error {
+	return errWalletNotSupported
+}
+
+// walletMiningAddr returns an error since the built-in wallet is not compiled
+// in.
+func walletMiningAddr(w *nodeWallet) (czzutil.Address, error) {
+	return nil, errWalletNotSupported
+}
@@ -5,6 +5,8 @@
 package main
 
 import (
+	"fmt"
+
 	"github.com/bourbaki-czz/classzz/chaincfg"
 	"github.com/bourbaki-czz/classzz/wire"
 )
@@ -17,8 +19,10 @@ var activeNetParams = &mainNetParams
 // network and test networks.
 type params struct {
 	*chaincfg.Params
-	rpcPort  string
-	gRRPPort string
+	rpcPort     string
+	gRRPPort    string
+	metricsPort string
+	bridgePort  string
 }
 
 // mainNetParams contains parameters specific to the main network
@@ -28,9 +32,11 @@ type params struct {
 // it does not handle on to classzz.  This approach allows the wallet process
 // to emulate the full reference implementation RPC API.
 var mainNetParams = params{
-	Params:   &chaincfg.MainNetParams,
-	rpcPort:  "8334",
-	gRRPPort: "8335",
+	Params:      &chaincfg.MainNetParams,
+	rpcPort:     "8334",
+	gRRPPort:    "8335",
+	metricsPort: "8336",
+	bridgePort:  "8337",
 }
 
 // regressionNetParams contains parameters specific to the regression test
@@ -38,26 +44,42 @@ var mainNetParams = params{
 // than the reference implementation - see the mainNetParams comment for
 // details.
 var regressionNetParams = params{
-	Params:   &chaincfg.RegressionNetParams,
-	rpcPort:  "18334",
-	gRRPPort: "18335",
+	Params:      &chaincfg.RegressionNetParams,
+	rpcPort:     "18334",
+	gRRPPort:    "18335",
+	metricsPort: "18336",
+	bridgePort:  "18337",
 }
 
 // testNet3Params contains parameters specific to the test network (version 3)
 // (wire.TestNet3).  NOTE: The RPC port is intentionally different than the
 // reference implementation - see the mainNetParams comment for details.
 var testNet3Params = params{
-	Params:   &chaincfg.TestNet3Params,
-	rpcPort:  "18334",
-	gRRPPort: "18335",
+	Params:      &chaincfg.TestNet3Params,
+	rpcPort:     "18334",
+	gRRPPort:    "18335",
+	metricsPort: "18336",
+	bridgePort:  "18337",
 }
 
 // simNetParams contains parameters specific to the simulation test network
 // (wire.SimNet).
 var simNetParams = params{
-	Params:   &chaincfg.SimNetParams,
-	rpcPort:  "18556",
-	gRRPPort: "18557",
+	Params:      &chaincfg.SimNetParams,
+	rpcPort:     "18556",
+	gRRPPort:    "18557",
+	metricsPort: "18558",
+	bridgePort:  "18559",
+}
+
+// sigNetParams contains parameters specific to the signed test network
+// (wire.SigNet).
+var sigNetParams = params{
+	Params:      &chaincfg.SigNetParams,
+	rpcPort:     "38334",
+	gRRPPort:    "38335",
+	metricsPort: "38336",
+	bridgePort:  "38337",
 }
 
 // netName returns the name used when referring to a bitcoin network.  At the
@@ -77,3 +99,40 @@ func netName(chainParams *params) string {
 		return chainParams.Name
 	}
 }
+
+// chainDefParams loads the chain definition file at path and registers the
+// private network it describes.  The RPC ports default to the ones of the
+// network the definition is based on.
+func chainDefParams(path string) (*params, error) {
+	def, err := chaincfg.LoadChainDefinition(path)
+	if err != nil {
+		return nil, err
+	}
+	chainParams, err := def.Params()
+	if err != nil {
+		return nil, err
+	}
+	if err := chaincfg.Register(chainParams); err != nil {
+		return nil, fmt.Errorf("unable to register network %s: %v",
+			chainParams.Name, err)
+	}
+
+	p := &params{Params: chainParams}
+	for _, base := range []*params{&mainNetParams, &testNet3Params,
+		&regressionNetParams, &simNetParams, &sigNetParams} {
+
+		if base.Name == def.Base {
+			p.rpcPort = base.rpcPort
+			p.gRRPPort = base.gRRPPort
+			p.metricsPort = base.metricsPort
+			p.bridgePort = base.bridgePort
+		}
+	}
+	if def.RPCPort != "" {
+		p.rpcPort = def.RPCPort
+	}
+	if def.GRPCPort != "" {
+		p.gRRPPort = def.GRPCPort
+	}
+	return p, nil
+}
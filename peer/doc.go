@@ -40,6 +40,10 @@ A quick overview of the major features peer provides are as follows:
    specify the related flag to signal support
    - Disconnects the peer when the protocol version is high enough
    - Does not invoke the related callbacks for older protocol versions
+ - Records the optional protocol features advertised by the peer, such as
+   compact blocks and addrv2, so the legacy messages can be used with peers
+   lacking them
+   - Ignores messages of unknown commands instead of disconnecting the peer
  - Snapshottable peer statistics such as the total number of bytes read and
    written, the remote address, user agent, and negotiated protocol version
  - Helper functions pushing addresses, getblocks, getheaders, and reject
@@ -0,0 +1,99 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"strings"
+
+	"github.com/bourbaki-czz/classzz/wire"
+)
+
+// Features is a set of optional protocol features a peer advertised.  The
+// features a peer did not advertise are replaced by the legacy behaviors they
+// extend, such as relaying full blocks instead of compact blocks or addresses
+// with addr instead of addrv2 messages.
+type Features uint32
+
+const (
+	// FeatureCompactBlocks indicates the peer sent a sendcmpct message for
+	// a known version of the compact blocks protocol (BIP0152).
+	FeatureCompactBlocks Features = 1 << iota
+
+	// FeatureXVersion indicates the peer sent an xversion message
+	// advertising its capabilities.
+	FeatureXVersion
+
+	// FeatureAddrV2 indicates the peer sent a sendaddrv2 message before
+	// its verack (BIP0155).
+	FeatureAddrV2
+
+	// FeatureCFilters indicates the peer advertised the service of serving
+	// committed filters (BIP0157).
+	FeatureCFilters
+)
+
+// featureNames maps the features to their names in the order they are
+// printed.
+var featureNames = []struct {
+	feature Features
+	name    string
+}{
+	{FeatureCompactBlocks, "cmpctblocks"},
+	{FeatureXVersion, "xversion"},
+	{FeatureAddrV2, "addrv2"},
+	{FeatureCFilters, "cfilters"},
+}
+
+// Has returns whether all of the passed features were advertised.
+func (f Features) Has(features Features) bool {
+	return f&features == features
+}
+
+// Names returns the names of the advertised features.
+func (f Features) Names() []string {
+	names := make([]string, 0, len(featureNames))
+	for _, feature := range featureNames {
+		if f.Has(feature.feature) {
+			names = append(names, feature.name)
+		}
+	}
+	return names
+}
+
+// String returns the features as a comma separated list of names.
+func (f Features) String() string {
+	return strings.Join(f.Names(), ",")
+}
+
+// features returns the optional protocol features the peer advertised.
+//
+// This function MUST be called with the flags mutex held.
+func (p *Peer) features() Features {
+	var features Features
+	if p.compactBlocksPreferred {
+		features |= FeatureCompactBlocks
+	}
+	if p.xVersionReceived {
+		features |= FeatureXVersion
+	}
+	if p.sendAddrV2Preferred {
+		features |= FeatureAddrV2
+	}
+	if p.services&wire.SFNodeCF == wire.SFNodeCF {
+		features |= FeatureCFilters
+	}
+	return features
+}
+
+// Features returns the optional protocol features the peer advertised.
+//
+// This function is safe for concurrent access.
+func (p *Peer) Features() Features {
+	p.flagsMtx.Lock()
+	features := p.features()
+	p.flagsMtx.Unlock()
+
+	return features
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestFeatures ensures feature sets are printed as expected.
+func TestFeatures(t *testing.T) {
+	tests := []struct {
+		in    Features
+		names []string
+		str   string
+	}{
+		{0, []string{}, ""},
+		{FeatureAddrV2, []string{"addrv2"}, "addrv2"},
+		{FeatureCFilters | FeatureCompactBlocks,
+			[]string{"cmpctblocks", "cfilters"}, "cmpctblocks,cfilters"},
+		{FeatureCompactBlocks | FeatureXVersion | FeatureAddrV2 |
+			FeatureCFilters, []string{"cmpctblocks", "xversion",
+			"addrv2", "cfilters"}, "cmpctblocks,xversion,addrv2,cfilters"},
+	}
+	for _, test := range tests {
+		if names := test.in.Names(); !reflect.DeepEqual(names, test.names) {
+			t.Errorf("Names: got %v, want %v", names, test.names)
+		}
+		if str := test.in.String(); str != test.str {
+			t.Errorf("String: got %q, want %q", str, test.str)
+		}
+	}
+
+	features := FeatureXVersion | FeatureAddrV2
+	if !features.Has(FeatureAddrV2) {
+		t.Errorf("Has: %v lacks addrv2", features)
+	}
+	if features.Has(FeatureAddrV2 | FeatureCFilters) {
+		t.Errorf("Has: %v has cfilters", features)
+	}
+}
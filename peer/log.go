@@ -159,6 +159,9 @@ func messageSummary(msg wire.Message) string {
 	case *wire.MsgAddr:
 		return fmt.Sprintf("%d addr", len(msg.AddrList))
 
+	case *wire.MsgAddrV2:
+		return fmt.Sprintf("%d addr", len(msg.AddrList))
+
 	case *wire.MsgPing:
 		// No summary - perhaps add nonce.
 
@@ -221,6 +224,24 @@ func messageSummary(msg wire.Message) string {
 	case *wire.MsgBlockTxns:
 		return fmt.Sprintf("txs %d", len(msg.Txs))
 
+	case *wire.MsgGetGrapheneBlock:
+		return fmt.Sprintf("hash %s, mempool %d", msg.BlockHash,
+			msg.MempoolCount)
+
+	case *wire.MsgGrapheneBlock:
+		header := &msg.Header
+		return fmt.Sprintf("hash %s, ver %d, %d txs, %d filter bytes, "+
+			"%d iblt cells, %d prefilledTxs, %s", msg.BlockHash(),
+			header.Version, msg.TxCount, len(msg.Filter),
+			len(msg.IBLTCells), len(msg.PrefilledTxs), header.Timestamp)
+
+	case *wire.MsgGetGrapheneTx:
+		return fmt.Sprintf("hash %s, shortIDs %d", msg.BlockHash,
+			len(msg.ShortIDs))
+
+	case *wire.MsgGrapheneTx:
+		return fmt.Sprintf("hash %s, txs %d", msg.BlockHash, len(msg.Txs))
+
 	case *wire.MsgReject:
 		// Ensure the variable length strings don't contain any
 		// characters which are even remotely dangerous such as HTML
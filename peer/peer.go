@@ -39,6 +39,10 @@ const (
 	// inventory cache.
 	DefaultMaxKnownInventory = 2000
 
+	// DefaultHandshakeTimeout is the default time a peer has to complete
+	// the version negotiation and acknowledge our version with a verack.
+	DefaultHandshakeTimeout = 30 * time.Second
+
 	// outputBufferSize is the number of elements the output channels use.
 	outputBufferSize = 50
 
@@ -50,10 +54,6 @@ const (
 	// messages.
 	pingInterval = 2 * time.Minute
 
-	// negotiateTimeout is the duration of inactivity before we timeout a
-	// peer that hasn't completed the initial version negotiation.
-	negotiateTimeout = 30 * time.Second
-
 	// idleTimeout is the duration of inactivity before we time out a peer.
 	idleTimeout = 5 * time.Minute
 
@@ -98,6 +98,9 @@ type MessageListeners struct {
 	// OnAddr is invoked when a peer receives an addr bitcoin message.
 	OnAddr func(p *Peer, msg *wire.MsgAddr)
 
+	// OnAddrV2 is invoked when a peer receives an addrv2 bitcoin message.
+	OnAddrV2 func(p *Peer, msg *wire.MsgAddrV2)
+
 	// OnPing is invoked when a peer receives a ping bitcoin message.
 	OnPing func(p *Peer, msg *wire.MsgPing)
 
@@ -201,6 +204,10 @@ type MessageListeners struct {
 	// message.
 	OnSendCmpct func(p *Peer, msg *wire.MsgSendCmpct)
 
+	// OnSendAddrV2 is invoked when a peer receives a sendaddrv2 bitcoin
+	// message.
+	OnSendAddrV2 func(p *Peer, msg *wire.MsgSendAddrV2)
+
 	// OnCmpctBlock is invoked when a peer receives a cmpctblock bitcoin
 	// message.
 	OnCmpctBlock func(p *Peer, msg *wire.MsgCmpctBlock)
@@ -213,6 +220,30 @@ type MessageListeners struct {
 	// message.
 	OnBlockTxns func(p *Peer, msg *wire.MsgBlockTxns)
 
+	// OnGetGrapheneBlock is invoked when a peer receives a getgrblk
+	// bitcoin message.
+	OnGetGrapheneBlock func(p *Peer, msg *wire.MsgGetGrapheneBlock)
+
+	// OnGrapheneBlock is invoked when a peer receives a grblk bitcoin
+	// message.
+	OnGrapheneBlock func(p *Peer, msg *wire.MsgGrapheneBlock)
+
+	// OnGetGrapheneTx is invoked when a peer receives a getgrblktx bitcoin
+	// message.
+	OnGetGrapheneTx func(p *Peer, msg *wire.MsgGetGrapheneTx)
+
+	// OnGrapheneTx is invoked when a peer receives a grblktx bitcoin
+	// message.
+	OnGrapheneTx func(p *Peer, msg *wire.MsgGrapheneTx)
+
+	// OnGetUtxoSnapshot is invoked when a peer receives a getutxosnap
+	// bitcoin message.
+	OnGetUtxoSnapshot func(p *Peer, msg *wire.MsgGetUtxoSnapshot)
+
+	// OnUtxoSnapshot is invoked when a peer receives a utxosnap bitcoin
+	// message.
+	OnUtxoSnapshot func(p *Peer, msg *wire.MsgUtxoSnapshot)
+
 	// OnRead is invoked when a peer receives a bitcoin message.  It
 	// consists of the number of bytes read, the message, and whether or not
 	// an error in the read occurred.  Typically, callers will opt to use
@@ -284,6 +315,9 @@ type Config struct {
 	// not send inv messages for transactions.
 	DisableRelayTx bool
 
+	// Permissions specifies the privileges granted to the remote peer.
+	Permissions Permissions
+
 	// Listeners houses callback functions to be invoked on receiving peer
 	// messages.
 	Listeners MessageListeners
@@ -292,6 +326,13 @@ type Config struct {
 	// inventory to a peer.
 	TrickleInterval time.Duration
 
+	// HandshakeTimeout is the time the remote peer has to complete the
+	// version negotiation and send its verack after the connection was
+	// established.  The peer is disconnected when either takes longer.
+	// This field can be omitted in which case DefaultHandshakeTimeout
+	// will be used.
+	HandshakeTimeout time.Duration
+
 	// TstAllowSelfConnection is only used to allow the tests to bypass the self
 	// connection detecting and disconnect logic since they intentionally
 	// do so for testing purposes.
@@ -405,8 +446,19 @@ type StatsSnap struct {
 	LastPingTime   time.Time
 	LastPingMicros int64
 	SyncPeer       bool
+	Features       Features
+
+	// BytesSentPerMsg and BytesRecvPerMsg are the bytes sent and received
+	// keyed by message command.  Bytes which could not be decoded into a
+	// message are counted under OtherMsgCommand.
+	BytesSentPerMsg map[string]uint64
+	BytesRecvPerMsg map[string]uint64
 }
 
+// OtherMsgCommand is the key under which bytes that do not belong to a known
+// message are accounted in the per-message byte counts of a peer.
+const OtherMsgCommand = "*other*"
+
 // HashFunc is a function which returns a block hash, height and error
 // It is used as a callback to get newest block details.
 type HashFunc func() (hash *chainhash.Hash, height int32, err error)
@@ -473,6 +525,8 @@ type Peer struct {
 	sendHeadersPreferred bool   // peer sent a sendheaders message
 	verAckReceived       bool
 	xVersionReceived     bool
+	xVersion             *wire.MsgXVersion // capabilities sent by remote
+	sendAddrV2Preferred  bool              // peer sent a sendaddrv2 message
 	syncPeer             bool
 
 	wireEncoding wire.MessageEncoding
@@ -493,6 +547,10 @@ type Peer struct {
 	startingHeight     int32
 	lastBlock          int32
 	lastAnnouncedBlock *chainhash.Hash
+	lastBlockTime      time.Time
+	lastTxTime         time.Time
+	bytesSentPerMsg    map[string]uint64
+	bytesRecvPerMsg    map[string]uint64
 	lastPingNonce      uint64    // Set to nonce if we have a pending ping.
 	lastPingTime       time.Time // Time we sent last ping.
 	lastPingMicros     int64     // Time for last ping to return.
@@ -551,6 +609,26 @@ func (p *Peer) UpdateLastAnnouncedBlock(blkHash *chainhash.Hash) {
 	p.statsMtx.Unlock()
 }
 
+// UpdateLastBlockTime records the time the peer last relayed a block which was
+// new to the local chain.
+//
+// This function is safe for concurrent access.
+func (p *Peer) UpdateLastBlockTime(t time.Time) {
+	p.statsMtx.Lock()
+	p.lastBlockTime = t
+	p.statsMtx.Unlock()
+}
+
+// UpdateLastTxTime records the time the peer last relayed a transaction which
+// was accepted to the local mempool.
+//
+// This function is safe for concurrent access.
+func (p *Peer) UpdateLastTxTime(t time.Time) {
+	p.statsMtx.Lock()
+	p.lastTxTime = t
+	p.statsMtx.Unlock()
+}
+
 // AddKnownInventory adds the passed inventory to the cache of known inventory
 // for the peer.
 //
@@ -603,6 +681,7 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 	userAgent := p.userAgent
 	services := p.services
 	protocolVersion := p.advertisedProtoVer
+	features := p.features()
 	p.flagsMtx.Unlock()
 
 	// Get a copy of all relevant flags and stats.
@@ -625,12 +704,40 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 		LastPingMicros: p.lastPingMicros,
 		LastPingTime:   p.lastPingTime,
 		SyncPeer:       p.SyncPeer(),
+		Features:       features,
+
+		BytesSentPerMsg: copyMsgBytes(p.bytesSentPerMsg),
+		BytesRecvPerMsg: copyMsgBytes(p.bytesRecvPerMsg),
 	}
 
 	p.statsMtx.RUnlock()
 	return statsSnap
 }
 
+// copyMsgBytes returns a copy of the passed per-message byte counts.
+func copyMsgBytes(counts map[string]uint64) map[string]uint64 {
+	c := make(map[string]uint64, len(counts))
+	for cmd, n := range counts {
+		c[cmd] = n
+	}
+	return c
+}
+
+// addMsgBytes accounts n bytes of the passed message to counts.  The bytes
+// are accounted under OtherMsgCommand when msg is nil.
+//
+// This function MUST be called with the stats lock held (for writes).
+func addMsgBytes(counts map[string]uint64, msg wire.Message, n int) {
+	if n == 0 {
+		return
+	}
+	cmd := OtherMsgCommand
+	if msg != nil {
+		cmd = msg.Command()
+	}
+	counts[cmd] += uint64(n)
+}
+
 // ID returns the peer id.
 //
 // This function is safe for concurrent access.
@@ -802,6 +909,37 @@ func (p *Peer) LastBlock() int32 {
 	return lastBlock
 }
 
+// LastBlockTime returns the time the peer last relayed a new block.  It is
+// the zero time if the peer never did.
+//
+// This function is safe for concurrent access.
+func (p *Peer) LastBlockTime() time.Time {
+	p.statsMtx.RLock()
+	lastBlockTime := p.lastBlockTime
+	p.statsMtx.RUnlock()
+
+	return lastBlockTime
+}
+
+// LastTxTime returns the time the peer last relayed a transaction accepted to
+// the mempool.  It is the zero time if the peer never did.
+//
+// This function is safe for concurrent access.
+func (p *Peer) LastTxTime() time.Time {
+	p.statsMtx.RLock()
+	lastTxTime := p.lastTxTime
+	p.statsMtx.RUnlock()
+
+	return lastTxTime
+}
+
+// Permissions returns the privileges granted to the remote peer.
+//
+// This function is safe for concurrent access.
+func (p *Peer) Permissions() Permissions {
+	return p.cfg.Permissions
+}
+
 // LastSend returns the last send time of the peer.
 //
 // This function is safe for concurrent access.
@@ -901,6 +1039,30 @@ func (p *Peer) WantsCompactBlocks() bool {
 	return compactBlocksPreferred
 }
 
+// WantsAddrV2 returns if the peer wants addresses relayed with addrv2 messages
+// instead of addr messages.
+//
+// This function is safe for concurrent access.
+func (p *Peer) WantsAddrV2() bool {
+	p.flagsMtx.Lock()
+	sendAddrV2Preferred := p.sendAddrV2Preferred
+	p.flagsMtx.Unlock()
+
+	return sendAddrV2Preferred
+}
+
+// XVersion returns the xversion message carrying the capabilities of the
+// remote peer, or nil if none was received.
+//
+// This function is safe for concurrent access.
+func (p *Peer) XVersion() *wire.MsgXVersion {
+	p.flagsMtx.Lock()
+	xVersion := p.xVersion
+	p.flagsMtx.Unlock()
+
+	return xVersion
+}
+
 // WantsDirectBlockRelay returns if the peer wants us to relay blocks without
 // announcing them in inv messages.
 //
@@ -917,36 +1079,50 @@ func (p *Peer) WantsDirectBlockRelay() bool {
 // addresses.  This function is useful over manually sending the message via
 // QueueMessage since it automatically limits the addresses to the maximum
 // number allowed by the message and randomizes the chosen addresses when there
-// are too many.  It returns the addresses that were actually sent and no
-// message will be sent if there are no entries in the provided addresses slice.
+// are too many.  An addrv2 message is sent instead when the peer asked for it
+// with sendaddrv2, otherwise addresses which can not be carried by the addr
+// message, such as Tor v3 and I2P, are dropped.  It returns the addresses that
+// were actually sent and no message will be sent if there are no entries in
+// the provided addresses slice.
 //
 // This function is safe for concurrent access.
 func (p *Peer) PushAddrMsg(addresses []*wire.NetAddress) ([]*wire.NetAddress, error) {
-	addressCount := len(addresses)
+	addrV2 := p.WantsAddrV2()
+	addrList := make([]*wire.NetAddress, 0, len(addresses))
+	for _, na := range addresses {
+		if addrV2 || na.AddrV1Compatible() {
+			addrList = append(addrList, na)
+		}
+	}
+	addressCount := len(addrList)
 
 	// Nothing to send.
 	if addressCount == 0 {
 		return nil, nil
 	}
 
-	msg := wire.NewMsgAddr()
-	msg.AddrList = make([]*wire.NetAddress, addressCount)
-	copy(msg.AddrList, addresses)
-
 	// Randomize the addresses sent if there are more than the maximum allowed.
 	if addressCount > wire.MaxAddrPerMsg {
 		// Shuffle the address list.
 		for i := 0; i < wire.MaxAddrPerMsg; i++ {
 			j := i + rand.Intn(addressCount-i)
-			msg.AddrList[i], msg.AddrList[j] = msg.AddrList[j], msg.AddrList[i]
+			addrList[i], addrList[j] = addrList[j], addrList[i]
 		}
 
 		// Truncate it to the maximum size.
-		msg.AddrList = msg.AddrList[:wire.MaxAddrPerMsg]
+		addrList = addrList[:wire.MaxAddrPerMsg]
 	}
 
-	p.QueueMessage(msg, nil)
-	return msg.AddrList, nil
+	if addrV2 {
+		msg := wire.NewMsgAddrV2()
+		msg.AddrList = addrList
+		p.QueueMessage(msg, nil)
+	} else {
+		msg := wire.NewMsgAddr()
+		msg.AddrList = addrList
+		p.QueueMessage(msg, nil)
+	}
+	return addrList, nil
 }
 
 // PushGetBlocksMsg sends a getblocks message for the provided block locator
@@ -1106,6 +1282,9 @@ func (p *Peer) readMessage(encoding wire.MessageEncoding) (wire.Message, []byte,
 	n, msg, buf, err := wire.ReadMessageWithEncodingN(p.conn,
 		p.ProtocolVersion(), p.cfg.ChainParams.Net, encoding)
 	atomic.AddUint64(&p.bytesReceived, uint64(n))
+	p.statsMtx.Lock()
+	addMsgBytes(p.bytesRecvPerMsg, msg, n)
+	p.statsMtx.Unlock()
 	if p.cfg.Listeners.OnRead != nil {
 		p.cfg.Listeners.OnRead(p, n, msg, err)
 	}
@@ -1169,6 +1348,9 @@ func (p *Peer) writeMessage(msg wire.Message, enc wire.MessageEncoding) error {
 	n, err := wire.WriteMessageWithEncodingN(p.conn, msg,
 		p.ProtocolVersion(), p.cfg.ChainParams.Net, enc)
 	atomic.AddUint64(&p.bytesSent, uint64(n))
+	p.statsMtx.Lock()
+	addMsgBytes(p.bytesSentPerMsg, msg, n)
+	p.statsMtx.Unlock()
 	if p.cfg.Listeners.OnWrite != nil {
 		p.cfg.Listeners.OnWrite(p, n, msg, err)
 	}
@@ -1257,6 +1439,21 @@ func (p *Peer) maybeAddDeadline(pendingResponses map[string]time.Time, msgCmd st
 		// Expects a blocktxns message.
 		pendingResponses[wire.CmdBlockTxns] = deadline
 
+	case wire.CmdGetGrapheneBlock:
+		// Expects a grblk message or, for blocks too small to benefit,
+		// a cmpctblock message.
+		pendingResponses[wire.CmdGrapheneBlock] = deadline
+
+	case wire.CmdGetGrapheneTx:
+		// Expects a grblktx message.
+		pendingResponses[wire.CmdGrapheneTx] = deadline
+
+	case wire.CmdGetUtxoSnapshot:
+		// Expects a utxosnap message.  Use a longer deadline since the
+		// chunk it carries can take a while to transfer.
+		deadline = time.Now().Add(stallResponseTimeout * 3)
+		pendingResponses[wire.CmdUtxoSnapshot] = deadline
+
 	case wire.CmdGetData:
 		// Expects a block, cmpctblock, merkleblock, tx, or notfound message.
 		pendingResponses[wire.CmdBlock] = deadline
@@ -1320,6 +1517,8 @@ out:
 					fallthrough
 				case wire.CmdCmpctBlock:
 					fallthrough
+				case wire.CmdGrapheneBlock:
+					fallthrough
 				case wire.CmdMerkleBlock:
 					fallthrough
 				case wire.CmdTx:
@@ -1327,6 +1526,7 @@ out:
 				case wire.CmdNotFound:
 					delete(pendingResponses, wire.CmdBlock)
 					delete(pendingResponses, wire.CmdCmpctBlock)
+					delete(pendingResponses, wire.CmdGrapheneBlock)
 					delete(pendingResponses, wire.CmdMerkleBlock)
 					delete(pendingResponses, wire.CmdTx)
 					delete(pendingResponses, wire.CmdNotFound)
@@ -1444,6 +1644,15 @@ out:
 		log.Debug("peer inHandler ", "rmsg", reflect.TypeOf(rmsg), "addr", p.addr)
 		idleTimer.Stop()
 		if err != nil {
+			// Ignore the messages of unknown commands, which are
+			// skipped by wire, so peers may use protocol features
+			// this one does not support without being disconnected.
+			if wire.IsMessageErrorCode(err, wire.ErrUnknownCommand) {
+				log.Debugf("Ignoring message from %s: %v", p, err)
+				idleTimer.Reset(idleTimeout)
+				continue
+			}
+
 			// In order to allow regression tests with malformed messages, don't
 			// disconnect the peer when we're in regression test mode and the
 			// error is one of the allowed errors.
@@ -1498,6 +1707,7 @@ out:
 
 			p.flagsMtx.Lock()
 			p.xVersionReceived = true
+			p.xVersion = msg
 			p.flagsMtx.Unlock()
 
 			if p.cfg.Listeners.OnXVersion != nil {
@@ -1530,6 +1740,24 @@ out:
 				p.cfg.Listeners.OnAddr(p, msg)
 			}
 
+		case *wire.MsgAddrV2:
+			if p.cfg.Listeners.OnAddrV2 != nil {
+				p.cfg.Listeners.OnAddrV2(p, msg)
+			}
+
+		case *wire.MsgSendAddrV2:
+			// BIP0155 requires sendaddrv2 to be sent before verack so
+			// ignore it when it arrives later.
+			p.flagsMtx.Lock()
+			if !p.verAckReceived {
+				p.sendAddrV2Preferred = true
+			}
+			p.flagsMtx.Unlock()
+
+			if p.cfg.Listeners.OnSendAddrV2 != nil {
+				p.cfg.Listeners.OnSendAddrV2(p, msg)
+			}
+
 		case *wire.MsgPing:
 			p.handlePingMsg(msg)
 			if p.cfg.Listeners.OnPing != nil {
@@ -1660,8 +1888,10 @@ out:
 			// We only know of one version so if they want something
 			// else we can't use compact blocks.
 			if msg.Version == wire.CompactBlocksProtocolVersion {
+				p.flagsMtx.Lock()
 				p.compactBlocksPreferred = true
 				p.directBlockRelayPreferred = msg.Announce
+				p.flagsMtx.Unlock()
 			}
 			if p.cfg.Listeners.OnSendCmpct != nil {
 				p.cfg.Listeners.OnSendCmpct(p, msg)
@@ -1682,6 +1912,36 @@ out:
 				p.cfg.Listeners.OnBlockTxns(p, msg)
 			}
 
+		case *wire.MsgGetGrapheneBlock:
+			if p.cfg.Listeners.OnGetGrapheneBlock != nil {
+				p.cfg.Listeners.OnGetGrapheneBlock(p, msg)
+			}
+
+		case *wire.MsgGrapheneBlock:
+			if p.cfg.Listeners.OnGrapheneBlock != nil {
+				p.cfg.Listeners.OnGrapheneBlock(p, msg)
+			}
+
+		case *wire.MsgGetGrapheneTx:
+			if p.cfg.Listeners.OnGetGrapheneTx != nil {
+				p.cfg.Listeners.OnGetGrapheneTx(p, msg)
+			}
+
+		case *wire.MsgGrapheneTx:
+			if p.cfg.Listeners.OnGrapheneTx != nil {
+				p.cfg.Listeners.OnGrapheneTx(p, msg)
+			}
+
+		case *wire.MsgGetUtxoSnapshot:
+			if p.cfg.Listeners.OnGetUtxoSnapshot != nil {
+				p.cfg.Listeners.OnGetUtxoSnapshot(p, msg)
+			}
+
+		case *wire.MsgUtxoSnapshot:
+			if p.cfg.Listeners.OnUtxoSnapshot != nil {
+				p.cfg.Listeners.OnUtxoSnapshot(p, msg)
+			}
+
 		default:
 			log.Debugf("Received unhandled message of type %v "+
 				"from %v", rmsg.Command(), p)
@@ -2079,6 +2339,17 @@ func (p *Peer) readRemoteVersionMsg() error {
 		return errors.New("disconnecting peer connected to self")
 	}
 
+	// Disconnect peers which are older than the network allows.
+	minPver := p.cfg.ChainParams.MinProtocolVersion
+	if minPver != 0 && uint32(msg.ProtocolVersion) < minPver {
+		reason := fmt.Sprintf("protocol version must be %d or greater",
+			minPver)
+		rejectMsg := wire.NewMsgReject(msg.Command(), wire.RejectObsolete,
+			reason)
+		_ = p.writeMessage(rejectMsg, wire.LatestEncoding)
+		return errors.New(reason)
+	}
+
 	// Negotiate the protocol version and set the services to what the remote
 	// peer advertised.
 	p.flagsMtx.Lock()
@@ -2230,14 +2501,15 @@ func (p *Peer) start() error {
 		}
 	}()
 
-	// Negotiate the protocol within the specified negotiateTimeout.
+	// Negotiate the protocol within the handshake timeout.
+	handshakeDeadline := time.Now().Add(p.cfg.HandshakeTimeout)
 	select {
 	case err := <-negotiateErr:
 		if err != nil {
 			p.Disconnect()
 			return err
 		}
-	case <-time.After(negotiateTimeout):
+	case <-time.After(p.cfg.HandshakeTimeout):
 		p.Disconnect()
 		return errors.New("protocol negotiation timeout")
 	}
@@ -2251,8 +2523,24 @@ func (p *Peer) start() error {
 	go p.outHandler()
 	go p.pingHandler()
 
+	// Ask the peer to relay addresses with addrv2 when it supports it.  This
+	// must be sent before verack.
+	if p.ProtocolVersion() >= wire.AddrV2Version {
+		p.QueueMessage(wire.NewMsgSendAddrV2(), nil)
+	}
+
 	// Send our verack message now that the IO processing machinery has started.
 	p.QueueMessage(wire.NewMsgVerAck(), nil)
+
+	// Disconnect the peer if it does not acknowledge our version before
+	// the handshake deadline.
+	time.AfterFunc(time.Until(handshakeDeadline), func() {
+		if !p.VerAckReceived() {
+			log.Debugf("Peer %s did not send verack within %s -- "+
+				"disconnecting", p, p.cfg.HandshakeTimeout)
+			p.Disconnect()
+		}
+	})
 	return nil
 }
 
@@ -2314,6 +2602,14 @@ func newPeerBase(origCfg *Config, inbound bool) *Peer {
 		cfg.ChainParams = &chaincfg.TestNet3Params
 	}
 
+	// Never advertise a protocol version above the one configured for the
+	// network.
+	if pver := cfg.ChainParams.ProtocolVersion; pver != 0 &&
+		cfg.ProtocolVersion > pver {
+
+		cfg.ProtocolVersion = pver
+	}
+
 	// Set the trickle interval if a non-positive value is specified.
 	if cfg.TrickleInterval <= 0 {
 		cfg.TrickleInterval = DefaultTrickleInterval
@@ -2324,10 +2620,17 @@ func newPeerBase(origCfg *Config, inbound bool) *Peer {
 		cfg.MaxKnownInventory = DefaultMaxKnownInventory
 	}
 
+	// Set the handshake timeout if a non-positive value is specified.
+	if cfg.HandshakeTimeout <= 0 {
+		cfg.HandshakeTimeout = DefaultHandshakeTimeout
+	}
+
 	p := Peer{
 		inbound:         inbound,
 		wireEncoding:    wire.BaseEncoding,
 		knownInventory:  newMruInventoryMap(cfg.MaxKnownInventory),
+		bytesSentPerMsg: make(map[string]uint64),
+		bytesRecvPerMsg: make(map[string]uint64),
 		stallControl:    make(chan stallControlMsg, 1), // nonblocking sync
 		outputQueue:     make(chan outMsg, outputBufferSize),
 		sendQueue:       make(chan outMsg, 1),   // nonblocking sync
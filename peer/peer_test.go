@@ -13,11 +13,11 @@ import (
 	"testing"
 	"time"
 
-	"github.com/btcsuite/go-socks/socks"
 	"github.com/bourbaki-czz/classzz/chaincfg"
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
 	"github.com/bourbaki-czz/classzz/peer"
 	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/btcsuite/go-socks/socks"
 )
 
 // fixedExcessiveBlockSize should not be the default -we want to ensure it will work in all cases
@@ -351,6 +351,23 @@ func TestPeerConnection(t *testing.T) {
 		testPeer(t, inPeer, wantStats2)
 		testPeer(t, outPeer, wantStats1)
 
+		// Ensure the handshake is accounted per message command.
+		for _, p := range []*peer.Peer{inPeer, outPeer} {
+			stats := p.StatsSnapshot()
+			if n := stats.BytesSentPerMsg[wire.CmdVerAck]; n != 24 {
+				t.Errorf("TestPeerConnection #%d: got %d verack "+
+					"bytes sent, want 24", i, n)
+			}
+			if n := stats.BytesRecvPerMsg[wire.CmdVerAck]; n != 24 {
+				t.Errorf("TestPeerConnection #%d: got %d verack "+
+					"bytes received, want 24", i, n)
+			}
+			if stats.BytesRecvPerMsg[wire.CmdVersion] == 0 {
+				t.Errorf("TestPeerConnection #%d: no version "+
+					"bytes received", i)
+			}
+		}
+
 		inPeer.Disconnect()
 		outPeer.Disconnect()
 		inPeer.WaitForDisconnect()
@@ -917,3 +934,112 @@ func TestDuplicateVersionMsg(t *testing.T) {
 		t.Fatal("peer did not disconnect")
 	}
 }
+
+// unknownMsg is a message of a command peers do not know, as sent by peers
+// supporting protocol features this one does not.
+type unknownMsg struct{}
+
+func (unknownMsg) CzzDecode(io.Reader, uint32, wire.MessageEncoding) error {
+	return nil
+}
+
+func (unknownMsg) CzzEncode(w io.Writer, _ uint32, _ wire.MessageEncoding) error {
+	_, err := w.Write([]byte{0x01, 0x02, 0x03})
+	return err
+}
+
+func (unknownMsg) Command() string                { return "futurefeat" }
+func (unknownMsg) MaxPayloadLength(uint32) uint32 { return 3 }
+
+// TestFeatureNegotiation ensures the optional protocol features advertised by
+// a peer are recorded, that they are only negotiated with peers whose protocol
+// version knows them, and that unknown messages do not disconnect the peer.
+func TestFeatureNegotiation(t *testing.T) {
+	tests := []struct {
+		name          string
+		inVersion     uint32
+		wantHandshake peer.Features
+	}{
+		{"current", 0, peer.FeatureAddrV2},
+		{"legacy", wire.AddrV2Version - 1, 0},
+	}
+	for _, test := range tests {
+		verack := make(chan struct{})
+		xversion := make(chan struct{})
+		listeners := peer.MessageListeners{
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				verack <- struct{}{}
+			},
+			OnXVersion: func(p *peer.Peer, msg *wire.MsgXVersion) {
+				xversion <- struct{}{}
+			},
+		}
+		outCfg := &peer.Config{
+			Listeners:              listeners,
+			UserAgentName:          "peer",
+			UserAgentVersion:       "1.0",
+			ChainParams:            &chaincfg.MainNetParams,
+			Services:               wire.SFNodeCF,
+			TstAllowSelfConnection: true,
+		}
+		inCfg := *outCfg
+		inCfg.Services = 0
+		inCfg.ProtocolVersion = test.inVersion
+
+		inConn, outConn := pipe(
+			&conn{laddr: "10.0.0.1:9108", raddr: "10.0.0.2:9108"},
+			&conn{laddr: "10.0.0.2:9108", raddr: "10.0.0.1:9108"},
+		)
+		outPeer, err := peer.NewOutboundPeer(outCfg, inConn.laddr)
+		if err != nil {
+			t.Fatalf("%s: NewOutboundPeer: unexpected err: %v", test.name,
+				err)
+		}
+		outPeer.AssociateConnection(outConn)
+		inPeer := peer.NewInboundPeer(&inCfg)
+		inPeer.AssociateConnection(inConn)
+		for i := 0; i < 2; i++ {
+			select {
+			case <-verack:
+			case <-time.After(time.Second):
+				t.Fatalf("%s: verack timeout", test.name)
+			}
+		}
+
+		// The addrv2 relay is only negotiated by the peers which know it.
+		if got := outPeer.Features(); got != test.wantHandshake {
+			t.Errorf("%s: outbound features: got %v, want %v",
+				test.name, got, test.wantHandshake)
+		}
+		want := test.wantHandshake | peer.FeatureCFilters
+		if got := inPeer.Features(); got != want {
+			t.Errorf("%s: inbound features: got %v, want %v", test.name,
+				got, want)
+		}
+
+		// The unknown message is ignored while the others are processed.
+		outPeer.QueueMessage(unknownMsg{}, nil)
+		outPeer.QueueMessage(wire.NewMsgSendCmpct(false,
+			wire.CompactBlocksProtocolVersion), nil)
+		outPeer.QueueMessage(wire.NewMsgXVersion(), nil)
+		select {
+		case <-xversion:
+		case <-time.After(time.Second):
+			t.Fatalf("%s: xversion timeout", test.name)
+		}
+		want |= peer.FeatureCompactBlocks | peer.FeatureXVersion
+		if got := inPeer.StatsSnapshot().Features; got != want {
+			t.Errorf("%s: inbound features: got %v, want %v", test.name,
+				got, want)
+		}
+		if !inPeer.Connected() {
+			t.Errorf("%s: peer disconnected upon unknown message",
+				test.name)
+		}
+
+		outPeer.Disconnect()
+		inPeer.Disconnect()
+		inPeer.WaitForDisconnect()
+		outPeer.WaitForDisconnect()
+	}
+}
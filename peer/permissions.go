@@ -0,0 +1,123 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Permissions is a set of privileges granted to a trusted peer, such as a peer
+// on a whitelisted network or connected to a whitelisted listener.
+type Permissions uint32
+
+const (
+	// PermissionNoBan prevents the peer from being banned, disconnected
+	// for misbehavior or evicted, and allows it to connect while its
+	// address is banned.
+	PermissionNoBan Permissions = 1 << iota
+
+	// PermissionForceRelay relays the transactions of the peer even when
+	// they are already in the mempool and exempts them from the rate
+	// limiting of free transactions.
+	PermissionForceRelay
+
+	// PermissionMempool allows the peer to request the contents of the
+	// mempool even when bloom filtering is disabled.
+	PermissionMempool
+
+	// PermissionDownload allows the peer to download historical blocks
+	// after the upload target has been reached.
+	PermissionDownload
+
+	// PermissionBridge marks the peer as a bridge peer, a node run by the
+	// custodians of the coin pools.  The entangle transactions are
+	// announced to the bridge peers right away instead of with the next
+	// batch of inventory, and their disconnects are logged as warnings
+	// and counted in the metrics.
+	PermissionBridge
+
+	// PermissionsAll is the set of all permissions.  It does not include
+	// PermissionBridge, which marks the peers as bridge peers rather than
+	// granting them a privilege.
+	PermissionsAll = PermissionNoBan | PermissionForceRelay |
+		PermissionMempool | PermissionDownload
+
+	// PermissionsBridge is the set of permissions granted by the name
+	// "bridge".  Besides marking the peers as bridge peers, it exempts
+	// them from banning, eviction, the rate limits of inbound connections
+	// and free transactions and the upload target.
+	PermissionsBridge = PermissionBridge | PermissionNoBan |
+		PermissionForceRelay | PermissionDownload
+
+	// PermissionsDefault is the set of permissions granted when none are
+	// specified explicitly.
+	PermissionsDefault = PermissionNoBan | PermissionMempool |
+		PermissionDownload
+)
+
+// permissionNames maps the permissions to their names in the order they are
+// printed.
+var permissionNames = []struct {
+	perm Permissions
+	name string
+}{
+	{PermissionNoBan, "noban"},
+	{PermissionForceRelay, "forcerelay"},
+	{PermissionMempool, "mempool"},
+	{PermissionDownload, "download"},
+	{PermissionBridge, "bridge"},
+}
+
+// ParsePermissions parses a comma separated list of permission names.  The
+// name "all" grants PermissionsAll and the name "bridge" PermissionsBridge.
+func ParsePermissions(s string) (Permissions, error) {
+	var perms Permissions
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "all":
+			perms |= PermissionsAll
+			continue
+		case "bridge":
+			perms |= PermissionsBridge
+			continue
+		}
+
+		found := false
+		for _, p := range permissionNames {
+			if p.name == name {
+				perms |= p.perm
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown permission %q", name)
+		}
+	}
+	return perms, nil
+}
+
+// Has returns whether all of the passed permissions are granted.
+func (p Permissions) Has(perms Permissions) bool {
+	return p&perms == perms
+}
+
+// Names returns the names of the granted permissions.
+func (p Permissions) Names() []string {
+	names := make([]string, 0, len(permissionNames))
+	for _, perm := range permissionNames {
+		if p.Has(perm.perm) {
+			names = append(names, perm.name)
+		}
+	}
+	return names
+}
+
+// String returns the permissions as a comma separated list of names.
+func (p Permissions) String() string {
+	return strings.Join(p.Names(), ",")
+}
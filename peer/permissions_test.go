@@ -0,0 +1,55 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import "testing"
+
+// TestParsePermissions ensures permission lists are parsed and printed as
+// expected.
+func TestParsePermissions(t *testing.T) {
+	tests := []struct {
+		in    string
+		perms Permissions
+		str   string
+	}{
+		{"noban", PermissionNoBan, "noban"},
+		{"mempool, Download", PermissionMempool | PermissionDownload,
+			"mempool,download"},
+		{"forcerelay,noban", PermissionNoBan | PermissionForceRelay,
+			"noban,forcerelay"},
+		{"all", PermissionsAll, "noban,forcerelay,mempool,download"},
+		{"bridge", PermissionsBridge, "noban,forcerelay,download,bridge"},
+		{"mempool,bridge", PermissionsBridge | PermissionMempool,
+			"noban,forcerelay,mempool,download,bridge"},
+	}
+	for _, test := range tests {
+		perms, err := ParsePermissions(test.in)
+		if err != nil {
+			t.Errorf("ParsePermissions(%q): unexpected error: %v",
+				test.in, err)
+			continue
+		}
+		if perms != test.perms {
+			t.Errorf("ParsePermissions(%q): got %v, want %v", test.in,
+				perms, test.perms)
+		}
+		if perms.String() != test.str {
+			t.Errorf("String: got %q, want %q", perms.String(),
+				test.str)
+		}
+	}
+
+	if !PermissionsAll.Has(PermissionNoBan | PermissionDownload) {
+		t.Errorf("Has: all permissions lack noban and download")
+	}
+	if PermissionsDefault.Has(PermissionForceRelay) {
+		t.Errorf("Has: default permissions include forcerelay")
+	}
+	for _, in := range []string{"", "noban,", "relay"} {
+		if _, err := ParsePermissions(in); err == nil {
+			t.Errorf("ParsePermissions(%q): unexpected success", in)
+		}
+	}
+}
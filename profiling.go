@@ -0,0 +1,269 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	httppprof "net/http/pprof"
+	"net/url"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/version"
+)
+
+const (
+	// maxProfileSeconds is the maximum duration of the capture of a cpu
+	// profile or an execution trace.
+	maxProfileSeconds = 300
+
+	// profilePushCPUDuration is the duration of the cpu profiles captured
+	// to be pushed.
+	profilePushCPUDuration = time.Second * 30
+
+	// profilePushTimeout is the maximum duration of a push of a profile.
+	profilePushTimeout = time.Minute
+)
+
+// errProfileBusy is returned when a cpu profile or an execution trace is
+// requested while another one is being captured.
+var errProfileBusy = errors.New("a cpu profile or an execution trace is " +
+	"already being captured")
+
+// timedProfileBusy is set while a cpu profile or an execution trace is being
+// captured, since only one of each can be captured at a time.  It must be
+// accessed atomically.
+var timedProfileBusy int32
+
+// isTimedProfile returns whether the profile of the passed type is captured
+// over a duration rather than at once.
+func isTimedProfile(profileType string) bool {
+	return profileType == "cpu" || profileType == "trace"
+}
+
+// validProfileType returns whether the profile of the passed type can be
+// captured.
+func validProfileType(profileType string) bool {
+	switch profileType {
+	case "cpu", "trace", "heap", "allocs", "goroutine", "threadcreate":
+		return true
+	}
+	return false
+}
+
+// captureProfile writes the profile of the passed type to the passed writer.
+// Cpu profiles and execution traces are captured over the passed duration, or
+// until the quit channel is closed.
+func captureProfile(w io.Writer, profileType string, duration time.Duration,
+	quit <-chan struct{}) error {
+
+	if !isTimedProfile(profileType) {
+		p := pprof.Lookup(profileType)
+		if p == nil {
+			return fmt.Errorf("unknown profile %q", profileType)
+		}
+		return p.WriteTo(w, 0)
+	}
+
+	if !atomic.CompareAndSwapInt32(&timedProfileBusy, 0, 1) {
+		return errProfileBusy
+	}
+	defer atomic.StoreInt32(&timedProfileBusy, 0)
+
+	var err error
+	if profileType == "cpu" {
+		err = pprof.StartCPUProfile(w)
+	} else {
+		err = trace.Start(w)
+	}
+	if err != nil {
+		return err
+	}
+	timer := time.NewTimer(duration)
+	select {
+	case <-timer.C:
+	case <-quit:
+		timer.Stop()
+	}
+	if profileType == "cpu" {
+		pprof.StopCPUProfile()
+	} else {
+		trace.Stop()
+	}
+	return nil
+}
+
+// newProfileHandler returns the handler of the HTTP profiling server, which
+// serves the pprof profiles to the clients authenticated with the passed user
+// and password.
+func newProfileHandler(user, pass string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", httppprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", httppprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", httppprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", httppprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", httppprof.Trace)
+	mux.Handle("/", http.RedirectHandler("/debug/pprof/",
+		http.StatusSeeOther))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqUser, reqPass, _ := r.BasicAuth()
+		userOk := subtle.ConstantTimeCompare([]byte(reqUser), []byte(user)) == 1
+		passOk := subtle.ConstantTimeCompare([]byte(reqPass), []byte(pass)) == 1
+		if !userOk || !passOk {
+			czzdLog.Warnf("Profile server authentication failure "+
+				"from %s", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Basic realm="czzd profile"`)
+			http.Error(w, "401 Unauthorized.", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// profilePusher periodically captures a cpu and a heap profile and pushes them
+// to a collecting endpoint, so the performance of a node can be followed over
+// time.
+type profilePusher struct {
+	url      string
+	user     string
+	pass     string
+	interval time.Duration
+	client   http.Client
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// newProfilePusher returns a pusher pushing the profiles to the passed URL at
+// the passed interval.  The credentials of the URL, if any, are sent with HTTP
+// basic authentication.
+func newProfilePusher(pushURL string, interval time.Duration) (*profilePusher, error) {
+	u, err := url.Parse(pushURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported profile push URL scheme %q",
+			u.Scheme)
+	}
+	p := &profilePusher{
+		interval: interval,
+		client:   http.Client{Timeout: profilePushTimeout},
+		quit:     make(chan struct{}),
+	}
+	if u.User != nil {
+		p.user = u.User.Username()
+		p.pass, _ = u.User.Password()
+		u.User = nil
+	}
+	p.url = u.String()
+	return p, nil
+}
+
+// Start begins pushing the profiles.
+func (p *profilePusher) Start() {
+	p.wg.Add(1)
+	go p.pushHandler()
+}
+
+// Stop stops pushing the profiles and waits for the push in progress, if any,
+// to end.
+func (p *profilePusher) Stop() {
+	close(p.quit)
+	p.wg.Wait()
+}
+
+// pushHandler pushes the profiles at the interval of the pusher until it is
+// stopped.  It must be run as a goroutine.
+func (p *profilePusher) pushHandler() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.pushProfiles()
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// pushProfiles captures and pushes a cpu and a heap profile.
+func (p *profilePusher) pushProfiles() {
+	for _, profileType := range []string{"cpu", "heap"} {
+		var buf bytes.Buffer
+		start := time.Now()
+		err := captureProfile(&buf, profileType, profilePushCPUDuration,
+			p.quit)
+		if err == errProfileBusy {
+			czzdLog.Debugf("Skipping pushing a cpu profile: %v", err)
+			continue
+		}
+		if err != nil {
+			czzdLog.Warnf("Unable to capture %s profile to push: %v",
+				profileType, err)
+			continue
+		}
+		select {
+		case <-p.quit:
+			return
+		default:
+		}
+		err = p.push(profileType, start, time.Now(), buf.Bytes())
+		if err != nil {
+			czzdLog.Warnf("Unable to push %s profile: %v",
+				profileType, err)
+			continue
+		}
+		czzdLog.Debugf("Pushed %s profile (%d bytes)", profileType,
+			buf.Len())
+	}
+}
+
+// push posts the passed profile captured over the passed time range to the
+// endpoint of the pusher.  The type of the profile, the time range and the
+// version of the node are passed as query parameters.
+func (p *profilePusher) push(profileType string, start, end time.Time,
+	data []byte) error {
+
+	query := url.Values{}
+	query.Set("type", profileType)
+	query.Set("from", strconv.FormatInt(start.Unix(), 10))
+	query.Set("until", strconv.FormatInt(end.Unix(), 10))
+	query.Set("version", version.String())
+	pushURL := p.url + "?" + query.Encode()
+	if u, err := url.Parse(p.url); err == nil && u.RawQuery != "" {
+		pushURL = p.url + "&" + query.Encode()
+	}
+
+	req, err := http.NewRequest("POST", pushURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if p.user != "" || p.pass != "" {
+		req.SetBasicAuth(p.user, p.pass)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint replied %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/czzlog"
+)
+
+// TestCaptureProfile ensures profiles are captured, and that only one cpu
+// profile or execution trace is captured at a time.
+func TestCaptureProfile(t *testing.T) {
+	var buf bytes.Buffer
+	if err := captureProfile(&buf, "heap", 0, nil); err != nil {
+		t.Fatalf("captureProfile: unexpected error: %v", err)
+	}
+	if buf.Len() == 0 {
+		t.Fatal("captureProfile: empty heap profile")
+	}
+
+	// A closed quit channel ends the capture of a cpu profile early.
+	quit := make(chan struct{})
+	close(quit)
+	buf.Reset()
+	if err := captureProfile(&buf, "cpu", time.Hour, quit); err != nil {
+		t.Fatalf("captureProfile: unexpected error: %v", err)
+	}
+	if buf.Len() == 0 {
+		t.Fatal("captureProfile: empty cpu profile")
+	}
+
+	timedProfileBusy = 1
+	err := captureProfile(ioutil.Discard, "trace", time.Second, quit)
+	timedProfileBusy = 0
+	if err != errProfileBusy {
+		t.Fatalf("captureProfile: unexpected error while busy: %v", err)
+	}
+
+	if err := captureProfile(ioutil.Discard, "bogus", 0, nil); err == nil {
+		t.Fatal("captureProfile: unexpected success for unknown profile")
+	}
+}
+
+// TestProfileHandler ensures the profiles are only served to authenticated
+// clients.
+func TestProfileHandler(t *testing.T) {
+	defer func(logger czzlog.Logger) { czzdLog = logger }(czzdLog)
+	czzdLog = czzlog.Disabled
+
+	srv := httptest.NewServer(newProfileHandler("user", "pass"))
+	defer srv.Close()
+
+	tests := []struct {
+		user, pass string
+		status     int
+	}{
+		{"user", "pass", http.StatusOK},
+		{"user", "wrong", http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", srv.URL+"/debug/pprof/", nil)
+		if err != nil {
+			t.Fatalf("NewRequest: unexpected error: %v", err)
+		}
+		if test.user != "" {
+			req.SetBasicAuth(test.user, test.pass)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Do: unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("user %q pass %q: got status %d, want %d",
+				test.user, test.pass, resp.StatusCode, test.status)
+		}
+	}
+}
+
+// TestProfilePusherPush ensures profiles are pushed with their type and the
+// credentials of the push URL.
+func TestProfilePusherPush(t *testing.T) {
+	var mtx sync.Mutex
+	var gotType, gotUser, gotPass string
+	var gotData []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		gotType = r.URL.Query().Get("type")
+		gotUser, gotPass, _ = r.BasicAuth()
+		gotData, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	pushURL := strings.Replace(srv.URL, "http://", "http://user:pass@", 1)
+	p, err := newProfilePusher(pushURL+"/ingest", time.Minute)
+	if err != nil {
+		t.Fatalf("newProfilePusher: unexpected error: %v", err)
+	}
+	now := time.Now()
+	if err := p.push("heap", now, now, []byte("profile")); err != nil {
+		t.Fatalf("push: unexpected error: %v", err)
+	}
+	mtx.Lock()
+	defer mtx.Unlock()
+	if gotType != "heap" || gotUser != "user" || gotPass != "pass" ||
+		string(gotData) != "profile" {
+
+		t.Fatalf("push: got type %q, user %q, pass %q, data %q", gotType,
+			gotUser, gotPass, gotData)
+	}
+
+	if _, err := newProfilePusher("ftp://localhost", time.Minute); err == nil {
+		t.Fatal("newProfilePusher: unexpected success with an " +
+			"unsupported scheme")
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+// The Combiner merges several PSBTs for the same unsigned transaction, such as
+// the ones returned by the different signers of a multisig input, into a
+// single PSBT carrying the union of their key-value pairs.
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrCombineDifferentTx indicates that the PSBTs passed to Combine are not
+// for the same unsigned transaction.
+var ErrCombineDifferentTx = errors.New("Cannot combine PSBTs of different " +
+	"unsigned transactions")
+
+// Combine encapsulates the role 'Combiner' as specified in BIP174; it returns
+// a new packet with the union of the key-value pairs of the passed packets,
+// which must all be for the same unsigned transaction.  When several packets
+// carry a different value for the same key, the value of the first one is
+// kept.  The passed packets are not modified.
+func Combine(packets ...*Packet) (*Packet, error) {
+	if len(packets) == 0 {
+		return nil, ErrInvalidPsbtFormat
+	}
+
+	var txBuf bytes.Buffer
+	if err := packets[0].UnsignedTx.Serialize(&txBuf); err != nil {
+		return nil, err
+	}
+	for _, p := range packets {
+		if err := p.SanityCheck(); err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := p.UnsignedTx.Serialize(&buf); err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(buf.Bytes(), txBuf.Bytes()) {
+			return nil, ErrCombineDifferentTx
+		}
+	}
+
+	combined, err := NewFromUnsignedTx(packets[0].UnsignedTx.Copy())
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range packets {
+		combined.Unknowns = combineUnknowns(combined.Unknowns, p.Unknowns)
+		for i := range p.Inputs {
+			combineInput(&combined.Inputs[i], &p.Inputs[i])
+		}
+		for i := range p.Outputs {
+			combineOutput(&combined.Outputs[i], &p.Outputs[i])
+		}
+	}
+
+	if err := combined.SanityCheck(); err != nil {
+		return nil, err
+	}
+	return combined, nil
+}
+
+// combineInput adds the key-value pairs of the src input which dst does not
+// carry yet to dst.
+func combineInput(dst, src *PInput) {
+	if dst.NonWitnessUtxo == nil && src.NonWitnessUtxo != nil {
+		dst.NonWitnessUtxo = src.NonWitnessUtxo.Copy()
+	}
+	for _, ps := range src.PartialSigs {
+		if !hasPartialSig(dst.PartialSigs, ps.PubKey) {
+			dst.PartialSigs = append(dst.PartialSigs, &PartialSig{
+				PubKey:    copyBytes(ps.PubKey),
+				Signature: copyBytes(ps.Signature),
+			})
+		}
+	}
+	if dst.SighashType == 0 {
+		dst.SighashType = src.SighashType
+	}
+	if dst.RedeemScript == nil {
+		dst.RedeemScript = copyBytes(src.RedeemScript)
+	}
+	dst.Bip32Derivation = combineBip32Derivations(dst.Bip32Derivation,
+		src.Bip32Derivation)
+	if dst.FinalScriptSig == nil {
+		dst.FinalScriptSig = copyBytes(src.FinalScriptSig)
+	}
+	for _, u := range src.Unknowns {
+		if !hasUnknown(dst.Unknowns, u.Key) {
+			dst.Unknowns = append(dst.Unknowns, &Unknown{
+				Key:   copyBytes(u.Key),
+				Value: copyBytes(u.Value),
+			})
+		}
+	}
+}
+
+// combineOutput adds the key-value pairs of the src output which dst does not
+// carry yet to dst.
+func combineOutput(dst, src *POutput) {
+	if dst.RedeemScript == nil {
+		dst.RedeemScript = copyBytes(src.RedeemScript)
+	}
+	dst.Bip32Derivation = combineBip32Derivations(dst.Bip32Derivation,
+		src.Bip32Derivation)
+	for _, u := range src.Unknowns {
+		if !hasUnknown(dst.Unknowns, u.Key) {
+			dst.Unknowns = append(dst.Unknowns, &Unknown{
+				Key:   copyBytes(u.Key),
+				Value: copyBytes(u.Value),
+			})
+		}
+	}
+}
+
+// combineBip32Derivations returns the derivations of dst along with the ones
+// of src for the public keys dst has no derivation of.
+func combineBip32Derivations(dst, src []*Bip32Derivation) []*Bip32Derivation {
+	for _, d := range src {
+		known := false
+		for _, x := range dst {
+			if bytes.Equal(x.PubKey, d.PubKey) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			dst = append(dst, &Bip32Derivation{
+				PubKey:               copyBytes(d.PubKey),
+				MasterKeyFingerprint: d.MasterKeyFingerprint,
+				Bip32Path:            append([]uint32(nil), d.Bip32Path...),
+			})
+		}
+	}
+	return dst
+}
+
+// combineUnknowns returns the global unknowns of dst along with the ones of
+// src whose keys dst does not carry.
+func combineUnknowns(dst, src []Unknown) []Unknown {
+	for _, u := range src {
+		known := false
+		for _, x := range dst {
+			if bytes.Equal(x.Key, u.Key) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			dst = append(dst, Unknown{
+				Key:   copyBytes(u.Key),
+				Value: copyBytes(u.Value),
+			})
+		}
+	}
+	return dst
+}
+
+// hasPartialSig returns whether the passed partial signatures include one by
+// the passed public key.
+func hasPartialSig(sigs []*PartialSig, pubKey []byte) bool {
+	for _, ps := range sigs {
+		if bytes.Equal(ps.PubKey, pubKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasUnknown returns whether the passed unknowns include one with the passed
+// key.
+func hasUnknown(unknowns []*Unknown, key []byte) bool {
+	for _, u := range unknowns {
+		if bytes.Equal(u.Key, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyBytes returns a copy of the passed byte slice, keeping nil as nil.
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
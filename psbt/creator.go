@@ -0,0 +1,72 @@
+// Copyright (c) 2018 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"github.com/bourbaki-czz/classzz/wire"
+)
+
+// MinTxVersion is the lowest transaction version that we'll permit.
+const MinTxVersion = 1
+
+// New on provision of an input and output 'skeleton' for the transaction, a
+// new partially populated PBST packet. The populated packet will include the
+// unsigned transaction, and the set of known inputs and outputs contained
+// within the unsigned transaction.  The values of nLockTime and transaction
+// version (must be at least 1) must also be passed as arguments.  Note that
+// the default sequence number is 0xffffffff, but a slice of sequence numbers
+// may be passed as well.
+//
+// Entangle outputs built with txscript.EntangleScript may be passed like
+// any other output; they must carry a zero value.
+func New(inputs []*wire.OutPoint,
+	outputs []*wire.TxOut, version int32, nLockTime uint32,
+	nSequences []uint32) (*Packet, error) {
+
+	// Create the new struct; the input and output slices will be empty
+	// since the only data at this stage is the unsigned transaction.
+	if version < MinTxVersion {
+		return nil, ErrInvalidPsbtFormat
+	}
+
+	// We'll define the packet with the specified version, and simply add
+	// the lock time.
+	unsignedTx := wire.NewMsgTx(version)
+	unsignedTx.LockTime = nLockTime
+	for i, in := range inputs {
+		txIn := &wire.TxIn{
+			PreviousOutPoint: *in,
+			Sequence:         wire.MaxTxInSequenceNum,
+		}
+		if i < len(nSequences) {
+			txIn.Sequence = nSequences[i]
+		}
+
+		unsignedTx.AddTxIn(txIn)
+	}
+	for _, out := range outputs {
+		unsignedTx.AddTxOut(out)
+	}
+
+	// The input and output lists are empty, but there is a list of those
+	// two lists, and each one must be of length matching the unsigned
+	// transaction; the unknown list can be nil.
+	pInputs := make([]PInput, len(unsignedTx.TxIn))
+	pOutputs := make([]POutput, len(unsignedTx.TxOut))
+
+	// This new Psbt is "raw" and contains no key-value fields, so the
+	// sanity check only has the unsigned transaction to look at.
+	p := &Packet{
+		UnsignedTx: unsignedTx,
+		Inputs:     pInputs,
+		Outputs:    pOutputs,
+		Unknowns:   nil,
+	}
+	if err := p.SanityCheck(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
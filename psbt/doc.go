@@ -0,0 +1,29 @@
+// Copyright (c) 2018 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package psbt implements a Partially Signed CZZ Transaction format modelled
+on BIP 174.
+
+A Packet carries an unsigned transaction together with per-input and
+per-output metadata that the parties of a multi-step signing workflow need:
+the full previous transaction of every input (so that signers can verify the
+amount committed to by the forkid signature hash), partial signatures,
+sighash types, redeem scripts and BIP32 derivation paths.
+
+The workflow is split into the roles described by BIP 174:
+
+	Creator:   New builds a Packet from inputs and outputs.
+	Updater:   Updater adds UTXOs, scripts and derivation paths.
+	Signer:    Updater.Sign attaches a partial signature to an input.
+	Combiner:  Combine merges the packets of several signers.
+	Finalizer: MaybeFinalize / MaybeFinalizeAll build final scriptSigs.
+	Extractor: Extract produces the network-serialized wire.MsgTx.
+
+Entangle and keeped-amount outputs (OP_RETURN scripts recognised by
+txscript) are carried unchanged in the unsigned transaction.  Because
+consensus requires such outputs to carry no value, SanityCheck rejects
+packets in which an entangle output has a non-zero amount.
+*/
+package psbt
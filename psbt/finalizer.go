@@ -0,0 +1,204 @@
+// Copyright (c) 2018 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+// The Finalizer requires provision of a single PSBT input in which all
+// necessary signatures are encoded, and uses it to construct a fully valid
+// final scriptSig.
+
+import (
+	"github.com/bourbaki-czz/classzz/txscript"
+)
+
+// isFinalized considers this input finalized if it contains a final
+// scriptSig.  This function does *not* check that the scriptSig is valid.
+func isFinalized(p *Packet, inIndex int) bool {
+	input := p.Inputs[inIndex]
+	return input.FinalScriptSig != nil
+}
+
+// isFinalizableInput checks whether the input at inIndex has sufficient
+// partial signatures to be finalized.
+func isFinalizableInput(p *Packet, inIndex int) bool {
+	pInput := p.Inputs[inIndex]
+
+	if pInput.NonWitnessUtxo == nil {
+		return false
+	}
+	_, pkScript, err := prevOutput(p, inIndex)
+	if err != nil {
+		return false
+	}
+
+	if txscript.IsPayToScriptHash(pkScript) {
+		if pInput.RedeemScript == nil {
+			return false
+		}
+		return checkFinalScriptSigInputs(pInput.RedeemScript, pInput)
+	}
+
+	return checkFinalScriptSigInputs(pkScript, pInput)
+}
+
+// checkFinalScriptSigInputs returns whether the partial signatures of the
+// input are sufficient to spend the passed script.
+func checkFinalScriptSigInputs(script []byte, pInput PInput) bool {
+	switch txscript.GetScriptClass(script) {
+	case txscript.PubKeyHashTy, txscript.PubKeyTy:
+		return len(pInput.PartialSigs) == 1
+
+	case txscript.MultiSigTy:
+		_, numSigs, err := txscript.CalcMultiSigStats(script)
+		if err != nil {
+			return false
+		}
+		return len(pInput.PartialSigs) >= numSigs
+	}
+
+	return false
+}
+
+// MaybeFinalize attempts to finalize the input at index inIndex in the PSBT
+// p, returning true with no error if it succeeds, OR if the input has already
+// been finalized.
+func MaybeFinalize(p *Packet, inIndex int) (bool, error) {
+	if isFinalized(p, inIndex) {
+		return true, nil
+	}
+
+	if !isFinalizableInput(p, inIndex) {
+		return false, ErrNotFinalizable
+	}
+
+	if err := Finalize(p, inIndex); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// MaybeFinalizeAll attempts to finalize all inputs of the psbt.Packet that
+// are not already finalized, and returns an error if it fails to do so.
+func MaybeFinalizeAll(p *Packet) error {
+
+	for i := range p.UnsignedTx.TxIn {
+		success, err := MaybeFinalize(p, i)
+		if err != nil || !success {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Finalize assumes that the provided psbt.Packet struct has all partial
+// signatures and redeem scripts necessary to construct the final scriptSig
+// for the input at inIndex.  Once the scriptSig is constructed all of the
+// partial signing data is removed from the input.
+func Finalize(p *Packet, inIndex int) error {
+	pInput := p.Inputs[inIndex]
+
+	// Depending on the UTXO type, we either attempt to finalize it as a
+	// bare, P2PKH, or P2SH input.
+	_, pkScript, err := prevOutput(p, inIndex)
+	if err != nil {
+		return err
+	}
+
+	script := pkScript
+	isP2SH := txscript.IsPayToScriptHash(pkScript)
+	if isP2SH {
+		if pInput.RedeemScript == nil {
+			return ErrNotFinalizable
+		}
+		script = pInput.RedeemScript
+	}
+
+	builder := txscript.NewScriptBuilder()
+	switch txscript.GetScriptClass(script) {
+	case txscript.PubKeyHashTy:
+		if len(pInput.PartialSigs) != 1 {
+			return ErrNotFinalizable
+		}
+		builder.AddData(pInput.PartialSigs[0].Signature)
+		builder.AddData(pInput.PartialSigs[0].PubKey)
+
+	case txscript.PubKeyTy:
+		if len(pInput.PartialSigs) != 1 {
+			return ErrNotFinalizable
+		}
+		builder.AddData(pInput.PartialSigs[0].Signature)
+
+	case txscript.MultiSigTy:
+		// We need to account for the multi-sig op-code bug, so we
+		// prepend an OP_0 and then push the signatures in the order
+		// their public keys appear in the script.
+		pubKeys, sigs, err := requiredSigs(script, pInput)
+		if err != nil {
+			return err
+		}
+		sortedSigs, err := extractKeyOrderFromScript(
+			script, pubKeys, sigs,
+		)
+		if err != nil {
+			return err
+		}
+
+		builder.AddOp(txscript.OP_FALSE)
+		for _, sig := range sortedSigs {
+			builder.AddData(sig)
+		}
+
+	default:
+		return ErrUnsupportedScriptType
+	}
+
+	if isP2SH {
+		builder.AddData(pInput.RedeemScript)
+	}
+
+	sigScript, err := builder.Script()
+	if err != nil {
+		return err
+	}
+
+	// At this point, a scriptSig has been constructed.  Remove all fields
+	// other than the non-witness utxo and the final scriptSig.
+	newInput := NewPsbtInput(pInput.NonWitnessUtxo)
+	newInput.FinalScriptSig = sigScript
+	newInput.Unknowns = pInput.Unknowns
+
+	// Finally, we overwrite the entry in the input list at the correct
+	// index.
+	p.Inputs[inIndex] = *newInput
+
+	// Before returning we sanity check the PSBT to ensure we don't extract
+	// an invalid transaction or produce an invalid intermediate state.
+	if err := p.SanityCheck(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// requiredSigs selects exactly as many partial signatures as the multisig
+// script requires, returning the matching public keys alongside them.
+func requiredSigs(script []byte, pInput PInput) ([][]byte, [][]byte, error) {
+	_, numSigs, err := txscript.CalcMultiSigStats(script)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(pInput.PartialSigs) < numSigs {
+		return nil, nil, ErrNotFinalizable
+	}
+
+	pubKeys := make([][]byte, 0, numSigs)
+	sigs := make([][]byte, 0, numSigs)
+	for _, ps := range pInput.PartialSigs[:numSigs] {
+		pubKeys = append(pubKeys, ps.PubKey)
+		sigs = append(sigs, ps.Signature)
+	}
+	return pubKeys, sigs, nil
+}
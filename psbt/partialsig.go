@@ -0,0 +1,61 @@
+// Copyright (c) 2018 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"bytes"
+
+	"github.com/bourbaki-czz/classzz/czzec"
+)
+
+// PartialSig encapsulate a (CZZ public key, ECDSA or Schnorr signature)
+// pair, note that the fields are stored as byte slices, not
+// czzec.PublicKey or czzec.Signature (because manipulations will be with the
+// former not the latter, here); compliance with consensus serialization is
+// enforced with .checkValid()
+type PartialSig struct {
+	PubKey    []byte
+	Signature []byte
+}
+
+// PartialSigSorter implements sort.Interface for PartialSig.
+type PartialSigSorter []*PartialSig
+
+func (s PartialSigSorter) Len() int { return len(s) }
+
+func (s PartialSigSorter) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s PartialSigSorter) Less(i, j int) bool {
+	return bytes.Compare(s[i].PubKey, s[j].PubKey) < 0
+}
+
+// validatePubkey checks if pubKey is *any* valid pubKey serialization in a
+// CZZ context (compressed/uncomp. OK).
+func validatePubkey(pubKey []byte) bool {
+	_, err := czzec.ParsePubKey(pubKey, czzec.S256())
+	return err == nil
+}
+
+// validateSignature checks that the passed byte slice is a valid signature
+// with the sighash type byte appended.  Both Schnorr (64 byte) and strict
+// DER encoded ECDSA signatures are accepted.
+func validateSignature(sig []byte) bool {
+	if len(sig) < 2 {
+		return false
+	}
+	sigBytes := sig[:len(sig)-1]
+	if len(sigBytes) == 64 {
+		_, err := czzec.ParseSchnorrSignature(sigBytes)
+		return err == nil
+	}
+	_, err := czzec.ParseDERSignature(sigBytes, czzec.S256())
+	return err == nil
+}
+
+// checkValid checks that both the pubkey and sig are valid. See the methods
+// (PartialSig, validatePubkey, validateSignature) for more details.
+func (ps *PartialSig) checkValid() bool {
+	return validatePubkey(ps.PubKey) && validateSignature(ps.Signature)
+}
@@ -0,0 +1,405 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/czzec"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// testKey returns the private key with the passed scalar.
+func testKey(b byte) *czzec.PrivateKey {
+	key, _ := czzec.PrivKeyFromBytes(czzec.S256(), bytes.Repeat([]byte{b}, 32))
+	return key
+}
+
+// testScripts returns a pay-to-pubkey-hash script paying to key1 and a 2-of-2
+// multisig redeem script of key1 and key2.
+func testScripts(t *testing.T, key1, key2 *czzec.PrivateKey) ([]byte, []byte) {
+	params := &chaincfg.RegressionNetParams
+	pkh, err := czzutil.NewLegacyAddressPubKeyHash(
+		czzutil.Hash160(key1.PubKey().SerializeCompressed()), params)
+	if err != nil {
+		t.Fatalf("NewLegacyAddressPubKeyHash: unexpected error: %v", err)
+	}
+	pkhScript, err := txscript.PayToAddrScript(pkh)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+
+	var pubKeys []*czzutil.AddressPubKey
+	for _, key := range []*czzec.PrivateKey{key1, key2} {
+		pk, err := czzutil.NewAddressPubKey(
+			key.PubKey().SerializeCompressed(), params)
+		if err != nil {
+			t.Fatalf("NewAddressPubKey: unexpected error: %v", err)
+		}
+		pubKeys = append(pubKeys, pk)
+	}
+	redeemScript, err := txscript.MultiSigScript(pubKeys, 2)
+	if err != nil {
+		t.Fatalf("MultiSigScript: unexpected error: %v", err)
+	}
+	return pkhScript, redeemScript
+}
+
+// testPacket returns a packet spending a pay-to-pubkey-hash output of key1 and
+// a pay-to-script-hash output of the 2-of-2 multisig of key1 and key2 along
+// with the transaction it spends and the multisig redeem script.
+func testPacket(t *testing.T, key1, key2 *czzec.PrivateKey) (*Packet, *wire.MsgTx, []byte) {
+	pkhScript, redeemScript := testScripts(t, key1, key2)
+	p2shScript, err := payToScriptHash(redeemScript)
+	if err != nil {
+		t.Fatalf("payToScriptHash: unexpected error: %v", err)
+	}
+
+	prevTx := wire.NewMsgTx(1)
+	prevTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: 7},
+		SignatureScript:  []byte{txscript.OP_TRUE},
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	prevTx.AddTxOut(wire.NewTxOut(50000, pkhScript))
+	prevTx.AddTxOut(wire.NewTxOut(70000, p2shScript))
+	prevHash := prevTx.TxHash()
+
+	p, err := New([]*wire.OutPoint{
+		{Hash: prevHash, Index: 0},
+		{Hash: prevHash, Index: 1},
+	}, []*wire.TxOut{
+		wire.NewTxOut(110000, pkhScript),
+	}, 1, 0, nil)
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	return p, prevTx, redeemScript
+}
+
+// sign returns the signature of the input of the packet with the passed key.
+func sign(t *testing.T, p *Packet, inIndex int, key *czzec.PrivateKey) []byte {
+	hashType := txscript.SigHashAll | txscript.SigHashForkID
+	hash, err := SignatureHash(p, inIndex, hashType)
+	if err != nil {
+		t.Fatalf("SignatureHash: unexpected error: %v", err)
+	}
+	sig, err := key.SignECDSA(hash)
+	if err != nil {
+		t.Fatalf("SignECDSA: unexpected error: %v", err)
+	}
+	return append(sig.Serialize(), byte(hashType))
+}
+
+// serialize returns the raw serialization of the passed packet.
+func serialize(t *testing.T, p *Packet) []byte {
+	var buf bytes.Buffer
+	if err := p.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestPacketRoundTrip ensures a packet carrying every key type is serialized
+// the same after being deserialized, both raw and base64 encoded.
+func TestPacketRoundTrip(t *testing.T) {
+	key1, key2 := testKey(1), testKey(2)
+	p, prevTx, redeemScript := testPacket(t, key1, key2)
+	u, err := NewUpdater(p)
+	if err != nil {
+		t.Fatalf("NewUpdater: unexpected error: %v", err)
+	}
+	for i := range p.Inputs {
+		if err := u.AddInNonWitnessUtxo(prevTx, i); err != nil {
+			t.Fatalf("AddInNonWitnessUtxo: unexpected error: %v", err)
+		}
+	}
+	hashType := txscript.SigHashAll | txscript.SigHashForkID
+	if err := u.AddInSighashType(hashType, 1); err != nil {
+		t.Fatalf("AddInSighashType: unexpected error: %v", err)
+	}
+	if err := u.AddInRedeemScript(redeemScript, 1); err != nil {
+		t.Fatalf("AddInRedeemScript: unexpected error: %v", err)
+	}
+	pubKey1 := key1.PubKey().SerializeCompressed()
+	err = u.AddInBip32Derivation(0x01020304, []uint32{44, 1, 0}, pubKey1, 1)
+	if err != nil {
+		t.Fatalf("AddInBip32Derivation: unexpected error: %v", err)
+	}
+	err = u.AddOutBip32Derivation(0x01020304, []uint32{44, 1, 1}, pubKey1, 0)
+	if err != nil {
+		t.Fatalf("AddOutBip32Derivation: unexpected error: %v", err)
+	}
+	if err := u.AddUnknownInput([]byte{0xfc, 0x01}, []byte{0x02}, 0); err != nil {
+		t.Fatalf("AddUnknownInput: unexpected error: %v", err)
+	}
+	outcome, err := u.Sign(0, sign(t, p, 0, key1), pubKey1, nil)
+	if err != nil || outcome != SignSuccesful {
+		t.Fatalf("Sign: got outcome %v (%v), want success", outcome, err)
+	}
+
+	raw := serialize(t, p)
+	got, err := NewFromRawBytes(bytes.NewReader(raw), false)
+	if err != nil {
+		t.Fatalf("NewFromRawBytes: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got.Inputs[1].Bip32Derivation,
+		p.Inputs[1].Bip32Derivation) {
+		t.Errorf("got input derivations %v, want %v",
+			got.Inputs[1].Bip32Derivation, p.Inputs[1].Bip32Derivation)
+	}
+	if !reflect.DeepEqual(got.Inputs[0].PartialSigs, p.Inputs[0].PartialSigs) {
+		t.Errorf("got partial signatures %v, want %v",
+			got.Inputs[0].PartialSigs, p.Inputs[0].PartialSigs)
+	}
+	if got.Inputs[1].SighashType != hashType {
+		t.Errorf("got sighash type %v, want %v",
+			got.Inputs[1].SighashType, hashType)
+	}
+	if reserialized := serialize(t, got); !bytes.Equal(reserialized, raw) {
+		t.Errorf("got reserialization %x, want %x", reserialized, raw)
+	}
+
+	b64, err := p.B64Encode()
+	if err != nil {
+		t.Fatalf("B64Encode: unexpected error: %v", err)
+	}
+	got, err = NewFromRawBytes(bytes.NewReader([]byte(b64)), true)
+	if err != nil {
+		t.Fatalf("NewFromRawBytes: unexpected error for base64: %v", err)
+	}
+	if reserialized := serialize(t, got); !bytes.Equal(reserialized, raw) {
+		t.Errorf("got base64 reserialization %x, want %x", reserialized,
+			raw)
+	}
+}
+
+// TestCombineFinalize ensures the packets signed by the different signers of a
+// multisig input are combined into a packet which finalizes into a
+// transaction passing the script checks, and that a packet is only finalized
+// once it carries enough signatures.
+func TestCombineFinalize(t *testing.T) {
+	key1, key2 := testKey(1), testKey(2)
+	p, prevTx, redeemScript := testPacket(t, key1, key2)
+	u, err := NewUpdater(p)
+	if err != nil {
+		t.Fatalf("NewUpdater: unexpected error: %v", err)
+	}
+	for i := range p.Inputs {
+		if err := u.AddInNonWitnessUtxo(prevTx, i); err != nil {
+			t.Fatalf("AddInNonWitnessUtxo: unexpected error: %v", err)
+		}
+	}
+
+	// Each signer signs a copy of the packet.
+	raw := serialize(t, p)
+	signed := make([]*Packet, 2)
+	for i, key := range []*czzec.PrivateKey{key1, key2} {
+		signed[i], err = NewFromRawBytes(bytes.NewReader(raw), false)
+		if err != nil {
+			t.Fatalf("NewFromRawBytes: unexpected error: %v", err)
+		}
+		u := &Updater{Upsbt: signed[i]}
+		pubKey := key.PubKey().SerializeCompressed()
+		if i == 0 {
+			_, err := u.Sign(0, sign(t, signed[i], 0, key), pubKey, nil)
+			if err != nil {
+				t.Fatalf("Sign: unexpected error: %v", err)
+			}
+		}
+		if err := u.AddInRedeemScript(redeemScript, 1); err != nil {
+			t.Fatalf("AddInRedeemScript: unexpected error: %v", err)
+		}
+		_, err = u.Sign(1, sign(t, signed[i], 1, key), pubKey, nil)
+		if err != nil {
+			t.Fatalf("Sign: unexpected error: %v", err)
+		}
+	}
+
+	// A single signature of the multisig input is not enough.
+	if _, err := MaybeFinalize(signed[0], 1); err != ErrNotFinalizable {
+		t.Errorf("MaybeFinalize: got %v, want %v", err, ErrNotFinalizable)
+	}
+	if _, err := Extract(signed[0]); err != ErrIncompletePSBT {
+		t.Errorf("Extract: got %v, want %v", err, ErrIncompletePSBT)
+	}
+
+	combined, err := Combine(signed...)
+	if err != nil {
+		t.Fatalf("Combine: unexpected error: %v", err)
+	}
+	if n := len(combined.Inputs[1].PartialSigs); n != 2 {
+		t.Fatalf("got %d partial signatures, want 2", n)
+	}
+	if len(signed[0].Inputs[1].PartialSigs) != 1 {
+		t.Errorf("Combine modified the packets it combined")
+	}
+	if err := MaybeFinalizeAll(combined); err != nil {
+		t.Fatalf("MaybeFinalizeAll: unexpected error: %v", err)
+	}
+	if !combined.IsComplete() {
+		t.Fatalf("finalized packet is not complete")
+	}
+	if sigs := combined.Inputs[1].PartialSigs; len(sigs) != 0 {
+		t.Errorf("finalized input still carries %d partial signatures",
+			len(sigs))
+	}
+
+	tx, err := Extract(combined)
+	if err != nil {
+		t.Fatalf("Extract: unexpected error: %v", err)
+	}
+	hashCache := txscript.NewTxSigHashes(tx)
+	for i, txIn := range tx.TxIn {
+		prevOut := prevTx.TxOut[txIn.PreviousOutPoint.Index]
+		vm, err := txscript.NewEngine(prevOut.PkScript, tx, i,
+			txscript.StandardVerifyFlags, nil, hashCache, prevOut.Value)
+		if err != nil {
+			t.Fatalf("NewEngine: unexpected error: %v", err)
+		}
+		if err := vm.Execute(); err != nil {
+			t.Errorf("input %d of the extracted transaction does not "+
+				"verify: %v", i, err)
+		}
+	}
+
+	// Packets of different transactions are not combined.
+	other, _, _ := testPacket(t, key2, key1)
+	if _, err := Combine(p, other); err != ErrCombineDifferentTx {
+		t.Errorf("Combine: got %v, want %v", err, ErrCombineDifferentTx)
+	}
+}
+
+// TestInvalidPackets ensures malformed serializations are rejected.
+func TestInvalidPackets(t *testing.T) {
+	key1, key2 := testKey(1), testKey(2)
+	p, prevTx, _ := testPacket(t, key1, key2)
+
+	var txBuf bytes.Buffer
+	if err := p.UnsignedTx.Serialize(&txBuf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	var prevBuf bytes.Buffer
+	if err := prevTx.Serialize(&prevBuf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	signedTx := p.UnsignedTx.Copy()
+	signedTx.TxIn[0].SignatureScript = []byte{txscript.OP_TRUE}
+	var signedBuf bytes.Buffer
+	if err := signedTx.Serialize(&signedBuf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+
+	// kv returns the serialization of a key-value pair.
+	kv := func(key, value []byte) []byte {
+		var buf bytes.Buffer
+		if err := serializeKVpair(&buf, key, value); err != nil {
+			t.Fatalf("serializeKVpair: unexpected error: %v", err)
+		}
+		return buf.Bytes()
+	}
+	// packet returns a serialization with the global unsigned transaction
+	// pair followed by the passed bytes.
+	packet := func(tx []byte, rest ...[]byte) []byte {
+		b := append(append([]byte{}, psbtMagic[:]...),
+			kv([]byte{byte(UnsignedTxType)}, tx)...)
+		for _, r := range rest {
+			b = append(b, r...)
+		}
+		return b
+	}
+	sep := []byte{0x00}
+	utxo := kv([]byte{byte(NonWitnessUtxoType)}, prevBuf.Bytes())
+	var sighash [4]byte
+	binary.LittleEndian.PutUint32(sighash[:], 0x41)
+
+	valid := packet(txBuf.Bytes(), sep, sep, sep, sep)
+	if _, err := NewFromRawBytes(bytes.NewReader(valid), false); err != nil {
+		t.Fatalf("NewFromRawBytes: unexpected error for the valid "+
+			"packet: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		b    []byte
+		err  error
+	}{{
+		name: "bad magic",
+		b:    append([]byte{0x70, 0x73, 0x62, 0x74, 0x00}, valid[5:]...),
+		err:  ErrInvalidMagicBytes,
+	}, {
+		name: "no unsigned transaction",
+		b: append(append([]byte{}, psbtMagic[:]...),
+			kv([]byte{0x01}, txBuf.Bytes())...),
+		err: ErrInvalidPsbtFormat,
+	}, {
+		name: "signed transaction",
+		b:    packet(signedBuf.Bytes(), sep, sep, sep, sep),
+		err:  ErrInvalidRawTxSigned,
+	}, {
+		name: "missing separator",
+		b:    packet(txBuf.Bytes()),
+		err:  ErrInvalidPsbtFormat,
+	}, {
+		name: "duplicate non-witness utxo",
+		b:    packet(txBuf.Bytes(), sep, utxo, utxo, sep, sep, sep),
+		err:  ErrDuplicateKey,
+	}, {
+		name: "non-witness utxo with key data",
+		b: packet(txBuf.Bytes(), sep, kv([]byte{byte(NonWitnessUtxoType),
+			0x01}, prevBuf.Bytes()), sep, sep, sep),
+		err: ErrInvalidKeydata,
+	}, {
+		name: "short sighash type",
+		b: packet(txBuf.Bytes(), sep, kv([]byte{byte(SighashType)},
+			sighash[:3]), sep, sep, sep),
+		err: ErrInvalidKeydata,
+	}, {
+		name: "partial signature with bad public key",
+		b: packet(txBuf.Bytes(), sep, kv([]byte{byte(PartialSigType),
+			0x02, 0x01}, []byte{0x30, 0x41}), sep, sep, sep),
+		err: ErrInvalidPsbtFormat,
+	}, {
+		name: "non-witness utxo of another transaction",
+		b: packet(txBuf.Bytes(), sep, kv([]byte{byte(NonWitnessUtxoType)},
+			txBuf.Bytes()), sep, sep, sep),
+		err: ErrInvalidPrevOutNonWitnessTransaction,
+	}, {
+		name: "key too long",
+		b: packet(txBuf.Bytes(), sep, kv(make([]byte,
+			MaxPsbtKeyLength+1), nil), sep, sep, sep),
+		err: ErrInvalidKeydata,
+	}}
+	for _, test := range tests {
+		_, err := NewFromRawBytes(bytes.NewReader(test.b), false)
+		if err != test.err {
+			t.Errorf("%s: got error %v, want %v", test.name, err,
+				test.err)
+		}
+	}
+
+	// Truncated serializations are rejected.
+	for i := 0; i < len(valid); i++ {
+		if _, err := NewFromRawBytes(bytes.NewReader(valid[:i]), false); err == nil {
+			t.Errorf("serialization truncated to %d bytes accepted", i)
+		}
+	}
+
+	// Entangle outputs carrying a value are rejected.
+	entangle, err := txscript.EntangleScript([]byte{0x01, 0x02})
+	if err != nil {
+		t.Fatalf("EntangleScript: unexpected error: %v", err)
+	}
+	_, err = New(nil, []*wire.TxOut{wire.NewTxOut(1, entangle)}, 1, 0, nil)
+	if err != ErrInvalidEntangleOutput {
+		t.Errorf("New: got %v, want %v", err, ErrInvalidEntangleOutput)
+	}
+}
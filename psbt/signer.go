@@ -0,0 +1,151 @@
+// Copyright (c) 2018 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+// signer encapsulates the role 'Signer' as specified in BIP174; it controls
+// the insertion of signatures; the Sign() function will attempt to insert
+// signatures using Updater.addPartialSignature, after first ensuring the Psbt
+// is in the correct state.
+
+import (
+	"github.com/bourbaki-czz/classzz/czzec"
+	"github.com/bourbaki-czz/classzz/txscript"
+)
+
+// SignOutcome is a enum-like value that expresses the outcome of a call to
+// the Sign method.
+type SignOutcome int
+
+const (
+	// SignSuccesful indicates that the partial signature was successfully
+	// attached.
+	SignSuccesful = 0
+
+	// SignFinalized  indicates that this input is already finalized, so
+	// the provided signature was *not* attached
+	SignFinalized = 1
+
+	// SignInvalid indicates that the provided signature data was not
+	// valid. In this case an error will also be returned.
+	SignInvalid = -1
+)
+
+// Sign allows the caller to sign a PSBT at a particular input; they may also
+// optionally provide a redeemScript where the input is P2SH.
+//
+// The signature must be produced over the forkid signature hash of the
+// input, which commits to the amount of the previous output.  The previous
+// transaction must therefore already be attached with AddInNonWitnessUtxo;
+// the signature is checked against it before it is attached.  The first
+// return value is an enum-like value expressing the outcome of the attempt;
+// in the SignInvalid case an error is also returned.
+func (u *Updater) Sign(inIndex int, sig []byte, pubKey []byte,
+	redeemScript []byte) (SignOutcome, error) {
+
+	if isFinalized(u.Upsbt, inIndex) {
+		return SignFinalized, nil
+	}
+
+	// Check that the sighash type of the signature matches the one
+	// requested by the input, if any.
+	if len(sig) == 0 {
+		return SignInvalid, ErrInvalidSignatureForInput
+	}
+	hashType := txscript.SigHashType(sig[len(sig)-1])
+	if u.Upsbt.Inputs[inIndex].SighashType != 0 &&
+		u.Upsbt.Inputs[inIndex].SighashType != hashType {
+
+		return SignInvalid, ErrInvalidSigHashFlags
+	}
+
+	if redeemScript != nil {
+		err := u.AddInRedeemScript(redeemScript, inIndex)
+		if err != nil {
+			return SignInvalid, err
+		}
+	}
+
+	if err := verifyPartialSig(u.Upsbt, inIndex, sig, pubKey); err != nil {
+		return SignInvalid, err
+	}
+
+	err := u.addPartialSignature(inIndex, sig, pubKey)
+	if err != nil {
+		return SignInvalid, err
+	}
+
+	return SignSuccesful, nil
+}
+
+// SignatureHash returns the forkid signature hash a signer must sign for the
+// input at inIndex using the passed hash type.  The subscript is the redeem
+// script for P2SH inputs and the previous output script otherwise.  This is
+// intended for signers, such as hardware wallets, that only accept a digest.
+func SignatureHash(p *Packet, inIndex int,
+	hashType txscript.SigHashType) ([]byte, error) {
+
+	if inIndex < 0 || inIndex >= len(p.Inputs) {
+		return nil, ErrInvalidPsbtFormat
+	}
+
+	amt, pkScript, err := prevOutput(p, inIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	subScript := pkScript
+	if txscript.IsPayToScriptHash(pkScript) {
+		subScript = p.Inputs[inIndex].RedeemScript
+		if subScript == nil {
+			return nil, ErrInvalidSignatureForInput
+		}
+	}
+
+	if hashType&txscript.SigHashForkID != txscript.SigHashForkID {
+		hashType |= txscript.SigHashForkID
+	}
+
+	return txscript.CalcSignatureHash(subScript,
+		txscript.NewTxSigHashes(p.UnsignedTx), hashType, p.UnsignedTx,
+		inIndex, amt, true)
+}
+
+// verifyPartialSig checks that sig is a valid signature by pubKey over the
+// signature hash of the input at inIndex.
+func verifyPartialSig(p *Packet, inIndex int, sig, pubKey []byte) error {
+	if len(sig) < 2 {
+		return ErrInvalidSignatureForInput
+	}
+	hashType := txscript.SigHashType(sig[len(sig)-1])
+	if hashType&txscript.SigHashForkID != txscript.SigHashForkID {
+		return ErrInvalidSigHashFlags
+	}
+
+	pk, err := czzec.ParsePubKey(pubKey, czzec.S256())
+	if err != nil {
+		return ErrInvalidSignatureForInput
+	}
+
+	var signature *czzec.Signature
+	sigBytes := sig[:len(sig)-1]
+	if len(sigBytes) == 64 {
+		signature, err = czzec.ParseSchnorrSignature(sigBytes)
+	} else {
+		signature, err = czzec.ParseDERSignature(sigBytes, czzec.S256())
+	}
+	if err != nil {
+		return ErrInvalidSignatureForInput
+	}
+
+	hash, err := SignatureHash(p, inIndex, hashType)
+	if err != nil {
+		return err
+	}
+	if !signature.Verify(hash, pk) {
+		return ErrInvalidSignatureForInput
+	}
+
+	return nil
+}
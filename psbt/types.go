@@ -0,0 +1,63 @@
+// Copyright (c) 2018 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+// GlobalType is the set of types that are used at the global scope level
+// within the PSBT.
+type GlobalType uint8
+
+const (
+	// UnsignedTxType is the global scope key that houses the unsigned
+	// transaction of the PSBT.  The value is a transaction in network
+	// serialization.  The scriptSigs must be empty.
+	UnsignedTxType GlobalType = 0
+)
+
+// InputType is the set of types that are defined for each input included
+// within the PSBT.
+type InputType uint32
+
+const (
+	// NonWitnessUtxoType has no key ({0x00}) and houses the transaction in
+	// network serialization format the current input spends from.
+	NonWitnessUtxoType InputType = 0
+
+	// PartialSigType is used to include a partial signature with key
+	// ({0x02}|{public key}).
+	PartialSigType InputType = 2
+
+	// SighashType is an empty key ({0x03}).  The value contains the 32-bit
+	// unsigned integer specifying the sighash type to be used for this
+	// input.
+	SighashType InputType = 3
+
+	// RedeemScriptInputType is an empty key ({0x04}).  The value is the
+	// redeem script of the input if present.
+	RedeemScriptInputType InputType = 4
+
+	// Bip32DerivationInputType is a type that carries the pubkey along with
+	// the key ({0x06}|{public key}).  The value is the master key
+	// fingerprint concatenated with the derivation path of the public key.
+	Bip32DerivationInputType InputType = 6
+
+	// FinalScriptSigType is an empty key ({0x07}).  The value contains a
+	// fully constructed scriptSig with signatures and any other scripts
+	// necessary for the input to pass validation.
+	FinalScriptSigType InputType = 7
+)
+
+// OutputType is the set of types defined per output within the PSBT.
+type OutputType uint32
+
+const (
+	// RedeemScriptOutputType is an empty key ({0x00}).  The value is the
+	// redeem script of the output if present.
+	RedeemScriptOutputType OutputType = 0
+
+	// Bip32DerivationOutputType is used to communicate derivation
+	// information needed to spend this output.  The key is
+	// ({0x02}|{public key}).
+	Bip32DerivationOutputType OutputType = 2
+)
@@ -0,0 +1,306 @@
+// Copyright (c) 2018 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+// The Updater requires provision of a single PSBT and is able to add data to
+// both input and output sections.  It can be called repeatedly to add more
+// data.  It also allows addition of signatures via the addPartialSignature
+// function; this is called internally to the package in the Sign() function
+// of Updater, located in signer.go
+
+import (
+	"bytes"
+
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// Updater encapsulates the role 'Updater' as specified in BIP174; it accepts
+// Psbt structs and has methods to add fields to the inputs and outputs.
+type Updater struct {
+	Upsbt *Packet
+}
+
+// NewUpdater returns a new instance of Updater, if the passed Psbt struct is
+// in a valid form, else an error.
+func NewUpdater(p *Packet) (*Updater, error) {
+	if err := p.SanityCheck(); err != nil {
+		return nil, err
+	}
+
+	return &Updater{Upsbt: p}, nil
+
+}
+
+// AddInNonWitnessUtxo adds the utxo information for an input which is
+// spent by the unsigned transaction.  The transaction must be the one
+// referenced by the input's previous outpoint.
+func (u *Updater) AddInNonWitnessUtxo(tx *wire.MsgTx, inIndex int) error {
+	if inIndex > len(u.Upsbt.Inputs)-1 {
+		return ErrInvalidPrevOutNonWitnessTransaction
+	}
+
+	prevOut := u.Upsbt.UnsignedTx.TxIn[inIndex].PreviousOutPoint
+	txHash := tx.TxHash()
+	if !txHash.IsEqual(&prevOut.Hash) ||
+		int(prevOut.Index) >= len(tx.TxOut) {
+
+		return ErrInvalidPrevOutNonWitnessTransaction
+	}
+
+	u.Upsbt.Inputs[inIndex].NonWitnessUtxo = tx
+
+	if err := u.Upsbt.SanityCheck(); err != nil {
+		return ErrInvalidPrevOutNonWitnessTransaction
+	}
+
+	return nil
+}
+
+// addPartialSignature allows the Updater role to insert fields of type
+// partial signature into a Psbt, consisting of both the pubkey (as keydata)
+// and the signature (as value).
+//
+// NOTE: This function does not validate the the signature against the
+// signature hash, callers should use Updater.Sign which does.
+func (u *Updater) addPartialSignature(inIndex int, sig []byte,
+	pubkey []byte) error {
+
+	partialSig := PartialSig{
+		PubKey: pubkey, Signature: sig,
+	}
+
+	// First validate the passed (sig, pub).
+	if !partialSig.checkValid() {
+		return ErrInvalidPsbtFormat
+	}
+
+	pInput := u.Upsbt.Inputs[inIndex]
+
+	// First check; don't add duplicates.
+	for _, x := range pInput.PartialSigs {
+		if bytes.Equal(x.PubKey, partialSig.PubKey) {
+			return ErrDuplicateKey
+		}
+	}
+
+	// Attaching a partial signature requires the previous transaction so
+	// that the script being spent can be checked.
+	if pInput.NonWitnessUtxo == nil {
+		return ErrInvalidSignatureForInput
+	}
+
+	outIndex := u.Upsbt.UnsignedTx.TxIn[inIndex].PreviousOutPoint.Index
+	pkScript := pInput.NonWitnessUtxo.TxOut[outIndex].PkScript
+
+	// If this is a P2SH output, the redeem script must already be
+	// present and must hash to the script hash.
+	if txscript.IsPayToScriptHash(pkScript) {
+		if pInput.RedeemScript == nil {
+			return ErrInvalidSignatureForInput
+		}
+		script, err := payToScriptHash(pInput.RedeemScript)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(script, pkScript) {
+			return ErrInvalidSignatureForInput
+		}
+	}
+
+	// Attach the signature only after all checks have passed.
+	u.Upsbt.Inputs[inIndex].PartialSigs = append(
+		u.Upsbt.Inputs[inIndex].PartialSigs, &partialSig,
+	)
+
+	if err := u.Upsbt.SanityCheck(); err != nil {
+		return err
+	}
+
+	// Addition of a non-duplicate-key partial signature cannot violate
+	// sanity-check rules.
+	return nil
+}
+
+// AddInSighashType adds the sighash type information for an input.  The
+// sighash type is passed as a 32 bit unsigned integer, along with the index
+// for the input.  An error is returned if addition of this key-value pair to
+// the Psbt fails.
+func (u *Updater) AddInSighashType(sighashType txscript.SigHashType,
+	inIndex int) error {
+
+	u.Upsbt.Inputs[inIndex].SighashType = sighashType
+
+	if err := u.Upsbt.SanityCheck(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// AddInRedeemScript adds the redeem script information for an input.  The
+// redeem script is passed serialized, as a byte slice, along with the index
+// of the input.  An error is returned if addition of this key-value pair to
+// the Psbt fails.
+func (u *Updater) AddInRedeemScript(redeemScript []byte,
+	inIndex int) error {
+
+	u.Upsbt.Inputs[inIndex].RedeemScript = redeemScript
+
+	if err := u.Upsbt.SanityCheck(); err != nil {
+		return ErrInvalidPsbtFormat
+	}
+
+	return nil
+}
+
+// AddInBip32Derivation takes a master key fingerprint as defined in BIP32, a
+// BIP32 path as a slice of uint32 values, and a serialized pubkey as a byte
+// slice, along with the integer index of the input, and inserts this data
+// into that input.
+//
+// NOTE: This can be called multiple times for the same input.  An error is
+// returned if addition of this key-value pair to the Psbt fails.
+func (u *Updater) AddInBip32Derivation(masterKeyFingerprint uint32,
+	bip32Path []uint32, pubKeyData []byte, inIndex int) error {
+
+	bip32Derivation := Bip32Derivation{
+		PubKey:               pubKeyData,
+		MasterKeyFingerprint: masterKeyFingerprint,
+		Bip32Path:            bip32Path,
+	}
+
+	if !bip32Derivation.checkValid() {
+		return ErrInvalidPsbtFormat
+	}
+
+	// Don't allow duplicate keys
+	for _, x := range u.Upsbt.Inputs[inIndex].Bip32Derivation {
+		if bytes.Equal(x.PubKey, bip32Derivation.PubKey) {
+			return ErrDuplicateKey
+		}
+	}
+
+	u.Upsbt.Inputs[inIndex].Bip32Derivation = append(
+		u.Upsbt.Inputs[inIndex].Bip32Derivation, &bip32Derivation,
+	)
+
+	if err := u.Upsbt.SanityCheck(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AddOutBip32Derivation takes a master key fingerprint as defined in BIP32,
+// a BIP32 path as a slice of uint32 values, and a serialized pubkey as a
+// byte slice, along with the integer index of the output, and inserts this
+// data into that output.
+//
+// NOTE: That this can be called multiple times for the same output.  An
+// error is returned if addition of this key-value pair to the Psbt fails.
+func (u *Updater) AddOutBip32Derivation(masterKeyFingerprint uint32,
+	bip32Path []uint32, pubKeyData []byte, outIndex int) error {
+
+	bip32Derivation := Bip32Derivation{
+		PubKey:               pubKeyData,
+		MasterKeyFingerprint: masterKeyFingerprint,
+		Bip32Path:            bip32Path,
+	}
+
+	if !bip32Derivation.checkValid() {
+		return ErrInvalidPsbtFormat
+	}
+
+	// Don't allow duplicate keys
+	for _, x := range u.Upsbt.Outputs[outIndex].Bip32Derivation {
+		if bytes.Equal(x.PubKey, bip32Derivation.PubKey) {
+			return ErrDuplicateKey
+		}
+	}
+
+	u.Upsbt.Outputs[outIndex].Bip32Derivation = append(
+		u.Upsbt.Outputs[outIndex].Bip32Derivation, &bip32Derivation,
+	)
+
+	if err := u.Upsbt.SanityCheck(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AddOutRedeemScript takes a redeem script as a byte slice and appends it to
+// the output at index outIndex.
+func (u *Updater) AddOutRedeemScript(redeemScript []byte,
+	outIndex int) error {
+
+	u.Upsbt.Outputs[outIndex].RedeemScript = redeemScript
+
+	if err := u.Upsbt.SanityCheck(); err != nil {
+		return ErrInvalidPsbtFormat
+	}
+
+	return nil
+}
+
+// AddUnknownInput adds a proprietary key-value pair to the input at index
+// inIndex.  Key must be the full key, including the leading key type byte.
+func (u *Updater) AddUnknownInput(key, value []byte, inIndex int) error {
+	for _, x := range u.Upsbt.Inputs[inIndex].Unknowns {
+		if bytes.Equal(x.Key, key) {
+			return ErrDuplicateKey
+		}
+	}
+
+	u.Upsbt.Inputs[inIndex].Unknowns = append(
+		u.Upsbt.Inputs[inIndex].Unknowns,
+		&Unknown{Key: key, Value: value},
+	)
+	return nil
+}
+
+// payToScriptHash returns the standard P2SH script paying to the hash of
+// the passed redeem script.
+func payToScriptHash(redeemScript []byte) ([]byte, error) {
+	return txscript.NewScriptBuilder().AddOp(txscript.OP_HASH160).
+		AddData(czzutil.Hash160(redeemScript)).
+		AddOp(txscript.OP_EQUAL).Script()
+}
+
+// SumUtxoInputValues tries to extract the sum of all inputs specified in the
+// UTXO fields of the PSBT.  An error is returned if any input does not carry
+// its previous transaction.
+func SumUtxoInputValues(packet *Packet) (int64, error) {
+	if len(packet.Inputs) != len(packet.UnsignedTx.TxIn) {
+		return 0, ErrInvalidPsbtFormat
+	}
+
+	var sum int64
+	for idx := range packet.Inputs {
+		amt, _, err := prevOutput(packet, idx)
+		if err != nil {
+			return 0, err
+		}
+		sum += amt
+	}
+	return sum, nil
+}
+
+// prevOutput returns the value and pkScript of the output spent by the input
+// at inIndex, extracted from its non-witness UTXO.
+func prevOutput(packet *Packet, inIndex int) (int64, []byte, error) {
+	in := packet.Inputs[inIndex]
+	if in.NonWitnessUtxo == nil {
+		return 0, nil, ErrInvalidPrevOutNonWitnessTransaction
+	}
+
+	outIndex := packet.UnsignedTx.TxIn[inIndex].PreviousOutPoint.Index
+	if int(outIndex) >= len(in.NonWitnessUtxo.TxOut) {
+		return 0, nil, ErrInvalidPrevOutNonWitnessTransaction
+	}
+	txOut := in.NonWitnessUtxo.TxOut[outIndex]
+	return txOut.Value, txOut.PkScript, nil
+}
@@ -5,10 +5,13 @@
 package main
 
 import (
+	"net"
 	"sync/atomic"
+	"time"
 
 	"github.com/bourbaki-czz/classzz/blockchain"
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/connmgr"
 	"github.com/bourbaki-czz/classzz/mempool"
 	"github.com/bourbaki-czz/classzz/netsync"
 	"github.com/bourbaki-czz/classzz/peer"
@@ -57,7 +60,7 @@ func (p *rpcPeer) BanScore() uint32 {
 // This function is safe for concurrent access and is part of the rpcserverPeer
 // interface implementation.
 func (p *rpcPeer) IsWhitelisted() bool {
-	return (*serverPeer)(p).isWhitelisted
+	return (*serverPeer)(p).permissions != 0
 }
 
 // FeeFilter returns the requested current minimum fee rate for which
@@ -69,6 +72,23 @@ func (p *rpcPeer) FeeFilter() int64 {
 	return atomic.LoadInt64(&(*serverPeer)(p).feeFilter)
 }
 
+// Permissions returns the privileges granted to the peer.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) Permissions() peer.Permissions {
+	return (*serverPeer)(p).permissions
+}
+
+// IsDirectRelay returns whether the peer was selected to announce new blocks
+// to us with compact blocks right away.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) IsDirectRelay() bool {
+	return (*serverPeer)(p).isDirectRelay()
+}
+
 // rpcConnManager provides a connection manager for use with the RPC server and
 // implements the rpcserverConnManager interface.
 type rpcConnManager struct {
@@ -225,10 +245,62 @@ func (cm *rpcConnManager) AddRebroadcastInventory(iv *wire.InvVect, data interfa
 	cm.server.AddRebroadcastInventory(iv, data)
 }
 
-// RelayTransactions generates and relays inventory vectors for all of the
-// passed transactions to all connected peers.
-func (cm *rpcConnManager) RelayTransactions(txns []*mempool.TxDesc) {
-	cm.server.relayTransactions(txns)
+// DiffuseTransactions generates and diffuses inventory vectors for all of the
+// passed locally submitted transactions to the connected peers.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) DiffuseTransactions(txns []*mempool.TxDesc) {
+	cm.server.diffuseTransactions(txns)
+}
+
+// BanSubnet bans the passed subnet until the passed time and disconnects any
+// connected peers within it.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) BanSubnet(subnet *net.IPNet, until time.Time, reason string) {
+	cm.server.banManager.Ban(subnet, until, reason)
+	cm.server.saveBanList()
+	srvrLog.Infof("Banned %s until %v: %s", subnet, until, reason)
+
+	replyChan := make(chan []*serverPeer)
+	cm.server.query <- getPeersMsg{reply: replyChan}
+	for _, sp := range <-replyChan {
+		if na := sp.NA(); na != nil && subnet.Contains(na.IP) {
+			sp.Disconnect()
+		}
+	}
+}
+
+// UnbanSubnet removes the ban of the passed subnet.  It returns whether the
+// subnet was banned.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) UnbanSubnet(subnet *net.IPNet) bool {
+	if !cm.server.banManager.Unban(subnet) {
+		return false
+	}
+	cm.server.saveBanList()
+	return true
+}
+
+// BannedSubnets returns the subnets which are currently banned.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) BannedSubnets() []connmgr.Ban {
+	return cm.server.banManager.Bans()
+}
+
+// ClearBanned removes all bans.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) ClearBanned() {
+	cm.server.banManager.Clear()
+	cm.server.saveBanList()
 }
 
 // rpcSyncMgr provides a block manager for use with the RPC server and
@@ -276,6 +348,14 @@ func (b *rpcSyncMgr) SyncPeerID() int32 {
 	return b.syncMgr.SyncPeerID()
 }
 
+// PeerSyncStates returns the sync state of every peer keyed by peer ID.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverSyncManager interface implementation.
+func (b *rpcSyncMgr) PeerSyncStates() map[int32]netsync.PeerSyncState {
+	return b.syncMgr.PeerSyncStates()
+}
+
 // LocateBlocks returns the hashes of the blocks after the first known block in
 // the provided locators until the provided stop hash or the current tip is
 // reached, up to a max of wire.MaxBlockHeadersPerMsg hashes.
@@ -290,3 +370,21 @@ func (b *rpcSyncMgr) LocateHeaders(locators []*chainhash.Hash, hashStop *chainha
 func (b *rpcSyncMgr) SyncHeight() uint64 {
 	return b.syncMgr.SyncHeight()
 }
+
+// BlockTimings returns the propagation timings of the most recently seen
+// blocks.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverSyncManager interface implementation.
+func (b *rpcSyncMgr) BlockTimings() []netsync.BlockTimings {
+	return b.syncMgr.BlockTimings()
+}
+
+// BlockLatencyStats returns the cumulated latencies between the stages of the
+// propagation of the blocks.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverSyncManager interface implementation.
+func (b *rpcSyncMgr) BlockLatencyStats() map[netsync.BlockLatency]netsync.LatencyStats {
+	return b.syncMgr.BlockLatencyStats()
+}
@@ -233,6 +233,219 @@ func (c *Client) GetBlockCount() (int64, error) {
 	return c.GetBlockCountAsync().Receive()
 }
 
+// FutureGetIndexInfoResult is a future promise to deliver the result of a
+// GetIndexInfoAsync RPC invocation (or an applicable error).
+type FutureGetIndexInfoResult chan *response
+
+// Receive waits for the response promised by the future and returns the state
+// of the optional indexes keyed by their name.
+func (r FutureGetIndexInfoResult) Receive() (map[string]btcjson.GetIndexInfoResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as a map of getindexinfo result objects.
+	var result map[string]btcjson.GetIndexInfoResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetIndexInfoAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GetIndexInfo for the blocking version and more details.
+func (c *Client) GetIndexInfoAsync() FutureGetIndexInfoResult {
+	cmd := btcjson.NewGetIndexInfoCmd(nil)
+	return c.sendCmd(cmd)
+}
+
+// GetIndexInfo returns the state of the optional indexes, which are built in
+// the background when they are behind the main chain, keyed by their name.
+func (c *Client) GetIndexInfo() (map[string]btcjson.GetIndexInfoResult, error) {
+	return c.GetIndexInfoAsync().Receive()
+}
+
+// FutureGetDBStatsResult is a future promise to deliver the result of a
+// GetDBStatsAsync RPC invocation (or an applicable error).
+type FutureGetDBStatsResult chan *response
+
+// Receive waits for the response promised by the future and returns
+// statistics about the stores backing the block database.
+func (r FutureGetDBStatsResult) Receive() (*btcjson.GetDBStatsResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as a getdbstats result object.
+	var result btcjson.GetDBStatsResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetDBStatsAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GetDBStats for the blocking version and more details.
+func (c *Client) GetDBStatsAsync() FutureGetDBStatsResult {
+	cmd := btcjson.NewGetDBStatsCmd()
+	return c.sendCmd(cmd)
+}
+
+// GetDBStats returns statistics about the stores backing the block database.
+func (c *Client) GetDBStats() (*btcjson.GetDBStatsResult, error) {
+	return c.GetDBStatsAsync().Receive()
+}
+
+// FutureCompactDBResult is a future promise to deliver the result of a
+// CompactDBAsync RPC invocation (or an applicable error).
+type FutureCompactDBResult chan *response
+
+// Receive waits for the response promised by the future and returns an error
+// if the compaction failed.
+func (r FutureCompactDBResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// CompactDBAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See CompactDB for the blocking version and more details.
+func (c *Client) CompactDBAsync() FutureCompactDBResult {
+	cmd := btcjson.NewCompactDBCmd()
+	return c.sendCmd(cmd)
+}
+
+// CompactDB flushes the database cache and compacts the block database.  It
+// blocks until the compaction finished.
+func (c *Client) CompactDB() error {
+	return c.CompactDBAsync().Receive()
+}
+
+// FutureScanTxOutSetResult is a future promise to deliver the result of a
+// ScanTxOutSetAsync RPC invocation (or an applicable error).
+type FutureScanTxOutSetResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// unspent transaction outputs found by the scan.
+func (r FutureScanTxOutSetResult) Receive() (*btcjson.ScanTxOutSetResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as a scantxoutset result object.
+	var result btcjson.ScanTxOutSetResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ScanTxOutSetAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See ScanTxOutSet for the blocking version and more details.
+func (c *Client) ScanTxOutSetAsync(scanObjects []string) FutureScanTxOutSetResult {
+	cmd := btcjson.NewScanTxOutSetCmd("start", &scanObjects)
+	return c.sendCmd(cmd)
+}
+
+// ScanTxOutSet scans the unspent transaction output set for outputs matching
+// the passed addr(<address>) and raw(<script hex>) scan objects.  It blocks
+// until the scan finished or was aborted.
+func (c *Client) ScanTxOutSet(scanObjects []string) (*btcjson.ScanTxOutSetResult, error) {
+	return c.ScanTxOutSetAsync(scanObjects).Receive()
+}
+
+// FutureScanTxOutSetAbortResult is a future promise to deliver the result of a
+// ScanTxOutSetAbortAsync RPC invocation (or an applicable error).
+type FutureScanTxOutSetAbortResult chan *response
+
+// Receive waits for the response promised by the future and returns whether or
+// not a scan was running and aborted.
+func (r FutureScanTxOutSetAbortResult) Receive() (bool, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return false, err
+	}
+
+	// Unmarshal the result as a boolean.
+	var aborted bool
+	err = json.Unmarshal(res, &aborted)
+	if err != nil {
+		return false, err
+	}
+	return aborted, nil
+}
+
+// ScanTxOutSetAbortAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See ScanTxOutSetAbort for the blocking version and more details.
+func (c *Client) ScanTxOutSetAbortAsync() FutureScanTxOutSetAbortResult {
+	cmd := btcjson.NewScanTxOutSetCmd("abort", nil)
+	return c.sendCmd(cmd)
+}
+
+// ScanTxOutSetAbort aborts the running unspent transaction output set scan and
+// returns whether or not a scan was running.
+func (c *Client) ScanTxOutSetAbort() (bool, error) {
+	return c.ScanTxOutSetAbortAsync().Receive()
+}
+
+// FutureScanTxOutSetStatusResult is a future promise to deliver the result of
+// a ScanTxOutSetStatusAsync RPC invocation (or an applicable error).
+type FutureScanTxOutSetStatusResult chan *response
+
+// Receive waits for the response promised by the future and returns the status
+// of the running scan, which is nil when no scan is running.
+func (r FutureScanTxOutSetStatusResult) Receive() (*btcjson.ScanTxOutSetStatusResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as a scantxoutset status result object, which
+	// is null when no scan is running.
+	var result *btcjson.ScanTxOutSetStatusResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ScanTxOutSetStatusAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See ScanTxOutSetStatus for the blocking version and more details.
+func (c *Client) ScanTxOutSetStatusAsync() FutureScanTxOutSetStatusResult {
+	cmd := btcjson.NewScanTxOutSetCmd("status", nil)
+	return c.sendCmd(cmd)
+}
+
+// ScanTxOutSetStatus returns the progress of the running unspent transaction
+// output set scan, which is nil when no scan is running.
+func (c *Client) ScanTxOutSetStatus() (*btcjson.ScanTxOutSetStatusResult, error) {
+	return c.ScanTxOutSetStatusAsync().Receive()
+}
+
 // FutureGetDifficultyResult is a future promise to deliver the result of a
 // GetDifficultyAsync RPC invocation (or an applicable error).
 type FutureGetDifficultyResult chan *response
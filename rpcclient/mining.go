@@ -59,6 +59,22 @@ func (c *Client) Generate(numBlocks uint32) ([]*chainhash.Hash, error) {
 	return c.GenerateAsync(numBlocks).Receive()
 }
 
+// GenerateToAddressAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GenerateToAddress for the blocking version and more details.
+func (c *Client) GenerateToAddressAsync(numBlocks uint32, address czzutil.Address) FutureGenerateResult {
+	cmd := btcjson.NewGenerateToAddressCmd(numBlocks, address.EncodeAddress())
+	return c.sendCmd(cmd)
+}
+
+// GenerateToAddress generates numBlocks blocks paying to the passed address and
+// returns their hashes.
+func (c *Client) GenerateToAddress(numBlocks uint32, address czzutil.Address) ([]*chainhash.Hash, error) {
+	return c.GenerateToAddressAsync(numBlocks, address).Receive()
+}
+
 // FutureGetGenerateResult is a future promise to deliver the result of a
 // GetGenerateAsync RPC invocation (or an applicable error).
 type FutureGetGenerateResult chan *response
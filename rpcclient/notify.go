@@ -179,6 +179,13 @@ type NotificationHandlers struct {
 	// made to register for the notification and the function is non-nil.
 	OnTxAcceptedVerbose func(txDetails *btcjson.TxRawResult)
 
+	// OnChainReorganized is invoked when the best chain is reorganized,
+	// once the blocks of the new best chain are connected, with the fates
+	// of the transactions of the disconnected blocks.  It will only be
+	// invoked if a preceding call to NotifyBlocks has been made to register
+	// for the notification and the function is non-nil.
+	OnChainReorganized func(reorg *btcjson.ChainReorganization)
+
 	// OnBchdConnected is invoked when a wallet connects or disconnects from
 	// classzz.
 	//
@@ -409,6 +416,23 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 
 		c.ntfnHandlers.OnTxAcceptedVerbose(rawTx)
 
+	// OnChainReorganized
+	case btcjson.ChainReorganizedNtfnMethod:
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnChainReorganized == nil {
+			return
+		}
+
+		reorg, err := parseChainReorganizedNtfnParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid chain reorganized "+
+				"notification: %v", err)
+			return
+		}
+
+		c.ntfnHandlers.OnChainReorganized(reorg)
+
 	// OnBchdConnected
 	case btcjson.BtcdConnectedNtfnMethod:
 		// Ignore the notification if the client is not interested in
@@ -720,7 +744,7 @@ func parseRescanProgressParams(params []json.RawMessage) (*chainhash.Hash, int32
 func parseTxAcceptedNtfnParams(params []json.RawMessage) (*chainhash.Hash,
 	czzutil.Amount, error) {
 
-	if len(params) != 2 {
+	if len(params) < 2 {
 		return nil, 0, wrongNumParams(len(params))
 	}
 
@@ -731,15 +755,9 @@ func parseTxAcceptedNtfnParams(params []json.RawMessage) (*chainhash.Hash,
 		return nil, 0, err
 	}
 
-	// Unmarshal second parameter as a floating point number.
-	var famt float64
-	err = json.Unmarshal(params[1], &famt)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	// Bounds check amount.
-	amt, err := czzutil.NewAmount(famt)
+	// Unmarshal second parameter as an amount in CZZ.
+	var amt btcjson.Amount
+	err = json.Unmarshal(params[1], &amt)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -750,7 +768,7 @@ func parseTxAcceptedNtfnParams(params []json.RawMessage) (*chainhash.Hash,
 		return nil, 0, err
 	}
 
-	return txHash, amt, nil
+	return txHash, czzutil.Amount(amt), nil
 }
 
 // parseTxAcceptedVerboseNtfnParams parses out details about a raw transaction
@@ -758,7 +776,7 @@ func parseTxAcceptedNtfnParams(params []json.RawMessage) (*chainhash.Hash,
 func parseTxAcceptedVerboseNtfnParams(params []json.RawMessage) (*btcjson.TxRawResult,
 	error) {
 
-	if len(params) != 1 {
+	if len(params) < 1 {
 		return nil, wrongNumParams(len(params))
 	}
 
@@ -775,6 +793,25 @@ func parseTxAcceptedVerboseNtfnParams(params []json.RawMessage) (*btcjson.TxRawR
 	return &rawTx, nil
 }
 
+// parseChainReorganizedNtfnParams parses out the reorganization of the chain
+// from the parameters of a chainreorganized notification.
+func parseChainReorganizedNtfnParams(params []json.RawMessage) (*btcjson.ChainReorganization,
+	error) {
+
+	if len(params) != 1 {
+		return nil, wrongNumParams(len(params))
+	}
+
+	// Unmarshal first parameter as a chain reorganization object.
+	var reorg btcjson.ChainReorganization
+	err := json.Unmarshal(params[0], &reorg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &reorg, nil
+}
+
 // parseBchdConnectedNtfnParams parses out the connection status of classzz
 // and czzwallet from the parameters of a czzdconnected notification.
 func parseBchdConnectedNtfnParams(params []json.RawMessage) (bool, error) {
@@ -808,9 +845,9 @@ func parseAccountBalanceNtfnParams(params []json.RawMessage) (account string,
 		return "", 0, false, err
 	}
 
-	// Unmarshal second parameter as a floating point number.
-	var fbal float64
-	err = json.Unmarshal(params[1], &fbal)
+	// Unmarshal second parameter as an amount in CZZ.
+	var bal btcjson.Amount
+	err = json.Unmarshal(params[1], &bal)
 	if err != nil {
 		return "", 0, false, err
 	}
@@ -821,13 +858,7 @@ func parseAccountBalanceNtfnParams(params []json.RawMessage) (account string,
 		return "", 0, false, err
 	}
 
-	// Bounds check amount.
-	bal, err := czzutil.NewAmount(fbal)
-	if err != nil {
-		return "", 0, false, err
-	}
-
-	return account, bal, confirmed, nil
+	return account, czzutil.Amount(bal), confirmed, nil
 }
 
 // parseWalletLockStateNtfnParams parses out the account name and locked
@@ -689,3 +689,91 @@ func (c *Client) DecodeScriptAsync(serializedScript []byte) FutureDecodeScriptRe
 func (c *Client) DecodeScript(serializedScript []byte) (*btcjson.DecodeScriptResult, error) {
 	return c.DecodeScriptAsync(serializedScript).Receive()
 }
+
+// FutureGetAddressTxIDsResult is a future promise to deliver the result of a
+// GetAddressTxIDsAsync RPC invocation (or an applicable error).
+type FutureGetAddressTxIDsResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// transactions involving the address.
+func (r FutureGetAddressTxIDsResult) Receive() ([]btcjson.GetAddressTxIDsResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal as an array of getaddresstxids result objects.
+	var result []btcjson.GetAddressTxIDsResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetAddressTxIDsAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GetAddressTxIDs for the blocking version and more details.
+func (c *Client) GetAddressTxIDsAsync(address czzutil.Address, skip, count int, reverse bool) FutureGetAddressTxIDsResult {
+	addr := address.EncodeAddress()
+	cmd := btcjson.NewGetAddressTxIDsCmd(addr, &skip, &count, &reverse)
+	return c.sendCmd(cmd)
+}
+
+// GetAddressTxIDs returns the hashes and heights of the transactions that
+// involve the passed address.
+//
+// NOTE: Chain servers do not typically provide this capability unless it has
+// specifically been enabled.
+func (c *Client) GetAddressTxIDs(address czzutil.Address, skip, count int, reverse bool) ([]btcjson.GetAddressTxIDsResult, error) {
+	return c.GetAddressTxIDsAsync(address, skip, count, reverse).Receive()
+}
+
+// FutureGetSpentInfoResult is a future promise to deliver the result of a
+// GetSpentInfoAsync RPC invocation (or an applicable error).
+type FutureGetSpentInfoResult chan *response
+
+// Receive waits for the response promised by the future and returns the input
+// spending the output.
+func (r FutureGetSpentInfoResult) Receive() (*btcjson.GetSpentInfoResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a getspentinfo result object.
+	var result btcjson.GetSpentInfoResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetSpentInfoAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GetSpentInfo for the blocking version and more details.
+func (c *Client) GetSpentInfoAsync(txHash *chainhash.Hash, index uint32) FutureGetSpentInfoResult {
+	hash := ""
+	if txHash != nil {
+		hash = txHash.String()
+	}
+
+	cmd := btcjson.NewGetSpentInfoCmd(hash, index)
+	return c.sendCmd(cmd)
+}
+
+// GetSpentInfo returns the input spending the passed transaction output along
+// with the height of the block containing the spending transaction.
+//
+// NOTE: Chain servers do not typically provide this capability unless it has
+// specifically been enabled.
+func (c *Client) GetSpentInfo(txHash *chainhash.Hash, index uint32) (*btcjson.GetSpentInfoResult, error) {
+	return c.GetSpentInfoAsync(txHash, index).Receive()
+}
@@ -6,7 +6,6 @@ package rpcclient
 
 import (
 	"encoding/json"
-	"strconv"
 
 	"github.com/bourbaki-czz/classzz/btcjson"
 	"github.com/bourbaki-czz/classzz/chaincfg"
@@ -1360,8 +1359,9 @@ func (r FutureListAccountsResult) Receive() (map[string]czzutil.Amount, error) {
 		return nil, err
 	}
 
-	// Unmarshal result as a json object.
-	var accounts map[string]float64
+	// Unmarshal result as a json object of amounts in CZZ, which are
+	// parsed exactly.
+	var accounts map[string]btcjson.Amount
 	err = json.Unmarshal(res, &accounts)
 	if err != nil {
 		return nil, err
@@ -1369,12 +1369,7 @@ func (r FutureListAccountsResult) Receive() (map[string]czzutil.Amount, error) {
 
 	accountsMap := make(map[string]czzutil.Amount)
 	for k, v := range accounts {
-		amount, err := czzutil.NewAmount(v)
-		if err != nil {
-			return nil, err
-		}
-
-		accountsMap[k] = amount
+		accountsMap[k] = czzutil.Amount(v)
 	}
 
 	return accountsMap, nil
@@ -1429,19 +1424,14 @@ func (r FutureGetBalanceResult) Receive() (czzutil.Amount, error) {
 		return 0, err
 	}
 
-	// Unmarshal result as a floating point number.
-	var balance float64
-	err = json.Unmarshal(res, &balance)
+	// Unmarshal result as an amount in CZZ, which is parsed exactly.
+	var amount btcjson.Amount
+	err = json.Unmarshal(res, &amount)
 	if err != nil {
 		return 0, err
 	}
 
-	amount, err := czzutil.NewAmount(balance)
-	if err != nil {
-		return 0, err
-	}
-
-	return amount, nil
+	return czzutil.Amount(amount), nil
 }
 
 // FutureGetBalanceParseResult is same as FutureGetBalanceResult except
@@ -1465,11 +1455,7 @@ func (r FutureGetBalanceParseResult) Receive() (czzutil.Amount, error) {
 		return 0, err
 	}
 
-	balance, err := strconv.ParseFloat(balanceString, 64)
-	if err != nil {
-		return 0, err
-	}
-	amount, err := czzutil.NewAmount(balance)
+	amount, err := btcjson.ParseAmount(balanceString, czzutil.AmountCZZ)
 	if err != nil {
 		return 0, err
 	}
@@ -1532,19 +1518,14 @@ func (r FutureGetReceivedByAccountResult) Receive() (czzutil.Amount, error) {
 		return 0, err
 	}
 
-	// Unmarshal result as a floating point number.
-	var balance float64
-	err = json.Unmarshal(res, &balance)
-	if err != nil {
-		return 0, err
-	}
-
-	amount, err := czzutil.NewAmount(balance)
+	// Unmarshal result as an amount in CZZ, which is parsed exactly.
+	var amount btcjson.Amount
+	err = json.Unmarshal(res, &amount)
 	if err != nil {
 		return 0, err
 	}
 
-	return amount, nil
+	return czzutil.Amount(amount), nil
 }
 
 // GetReceivedByAccountAsync returns an instance of a type that can be used to
@@ -1597,19 +1578,14 @@ func (r FutureGetUnconfirmedBalanceResult) Receive() (czzutil.Amount, error) {
 		return 0, err
 	}
 
-	// Unmarshal result as a floating point number.
-	var balance float64
-	err = json.Unmarshal(res, &balance)
+	// Unmarshal result as an amount in CZZ, which is parsed exactly.
+	var amount btcjson.Amount
+	err = json.Unmarshal(res, &amount)
 	if err != nil {
 		return 0, err
 	}
 
-	amount, err := czzutil.NewAmount(balance)
-	if err != nil {
-		return 0, err
-	}
-
-	return amount, nil
+	return czzutil.Amount(amount), nil
 }
 
 // GetUnconfirmedBalanceAsync returns an instance of a type that can be used to
@@ -1641,19 +1617,14 @@ func (r FutureGetReceivedByAddressResult) Receive() (czzutil.Amount, error) {
 		return 0, err
 	}
 
-	// Unmarshal result as a floating point number.
-	var balance float64
-	err = json.Unmarshal(res, &balance)
-	if err != nil {
-		return 0, err
-	}
-
-	amount, err := czzutil.NewAmount(balance)
+	// Unmarshal result as an amount in CZZ, which is parsed exactly.
+	var amount btcjson.Amount
+	err = json.Unmarshal(res, &amount)
 	if err != nil {
 		return 0, err
 	}
 
-	return amount, nil
+	return czzutil.Amount(amount), nil
 }
 
 // GetReceivedByAddressAsync returns an instance of a type that can be used to
@@ -2115,6 +2086,36 @@ func (c *Client) ImportAddressRescan(address string, account string, rescan bool
 	return c.ImportAddressRescanAsync(address, account, rescan).Receive()
 }
 
+// FutureImportDescriptorResult is a future promise to deliver the result of an
+// ImportDescriptorAsync RPC invocation (or an applicable error).
+type FutureImportDescriptorResult chan *response
+
+// Receive waits for the response promised by the future and returns the result
+// of importing the passed output descriptor.
+func (r FutureImportDescriptorResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// ImportDescriptorAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See ImportDescriptor for the blocking version and more details.
+func (c *Client) ImportDescriptorAsync(descriptor string, rescan bool) FutureImportDescriptorResult {
+	cmd := btcjson.NewImportDescriptorCmd(descriptor, &rescan)
+	return c.sendCmd(cmd)
+}
+
+// ImportDescriptor imports the passed output descriptor into the wallet, which
+// watches the output script it describes from now on.  When rescan is set, the
+// outputs already paying to the script are found.
+//
+// NOTE: This is a classzz extension.
+func (c *Client) ImportDescriptor(descriptor string, rescan bool) error {
+	return c.ImportDescriptorAsync(descriptor, rescan).Receive()
+}
+
 // FutureImportPrivKeyResult is a future promise to deliver the result of an
 // ImportPrivKeyAsync RPC invocation (or an applicable error).
 type FutureImportPrivKeyResult chan *response
@@ -2279,10 +2280,50 @@ func (c *Client) GetInfo() (*btcjson.InfoWalletResult, error) {
 	return c.GetInfoAsync().Receive()
 }
 
+// FutureGetWalletInfoResult is a future promise to deliver the result of a
+// GetWalletInfoAsync RPC invocation (or an applicable error).
+type FutureGetWalletInfoResult chan *response
+
+// Receive waits for the response promised by the future and returns the state
+// of the wallet provided by the server.
+func (r FutureGetWalletInfoResult) Receive() (*btcjson.GetWalletInfoResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a getwalletinfo result object.
+	var infoRes btcjson.GetWalletInfoResult
+	err = json.Unmarshal(res, &infoRes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &infoRes, nil
+}
+
+// GetWalletInfoAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GetWalletInfo for the blocking version and more details.
+func (c *Client) GetWalletInfoAsync() FutureGetWalletInfoResult {
+	cmd := btcjson.NewGetWalletInfoCmd()
+	return c.sendCmd(cmd)
+}
+
+// GetWalletInfo returns the balances of the wallet, including the parts paid
+// out by entangle transactions, along with its sync and lock state.
+//
+// NOTE: This is a classzz extension which requires a node built with the
+// built-in wallet.
+func (c *Client) GetWalletInfo() (*btcjson.GetWalletInfoResult, error) {
+	return c.GetWalletInfoAsync().Receive()
+}
+
 // TODO(davec): Implement
 // backupwallet (NYI in czzwallet)
 // encryptwallet (Won't be supported by czzwallet since it's always encrypted)
-// getwalletinfo (NYI in czzwallet or btcjson)
 // listaddressgroupings (NYI in czzwallet)
 // listreceivedbyaccount (NYI in czzwallet)
 
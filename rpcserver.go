@@ -23,6 +23,8 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -30,17 +32,20 @@ import (
 	"time"
 
 	"github.com/btcsuite/websocket"
+	"github.com/bourbaki-czz/classzz/addrmgr"
 	"github.com/bourbaki-czz/classzz/blockchain"
 	"github.com/bourbaki-czz/classzz/blockchain/indexers"
 	"github.com/bourbaki-czz/classzz/btcjson"
 	"github.com/bourbaki-czz/classzz/chaincfg"
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/connmgr"
 	"github.com/bourbaki-czz/classzz/consensus"
 	"github.com/bourbaki-czz/classzz/czzec"
 	"github.com/bourbaki-czz/classzz/database"
 	"github.com/bourbaki-czz/classzz/mempool"
 	"github.com/bourbaki-czz/classzz/mining"
 	"github.com/bourbaki-czz/classzz/mining/cpuminer"
+	"github.com/bourbaki-czz/classzz/netsync"
 	"github.com/bourbaki-czz/classzz/peer"
 	"github.com/bourbaki-czz/classzz/txscript"
 	"github.com/bourbaki-czz/classzz/version"
@@ -80,6 +85,14 @@ const (
 
 	// maxProtocolVersion is the max protocol version the server supports.
 	maxProtocolVersion = wire.ProtocolVersion
+
+	// maxDifficultyHistory is the maximum number of blocks the
+	// getdifficultyhistory RPC returns the difficulties of at once.
+	maxDifficultyHistory = 2016
+
+	// maxRawTransactionsPerRequest is the maximum number of transactions
+	// the getrawtransactions RPC returns at once.
+	maxRawTransactionsPerRequest = 1000
 )
 
 var (
@@ -133,14 +146,21 @@ type commandHandler func(*rpcServer, interface{}, <-chan struct{}) (interface{},
 var rpcHandlers map[string]commandHandler
 var rpcHandlersBeforeInit = map[string]commandHandler{
 	"addnode":                      handleAddNode,
+	"captureprofile":               handleCaptureProfile,
+	"clearbanned":                  handleClearBanned,
+	"compactdb":                    handleCompactDB,
+	"createmultisig":               handleCreateMultisig,
 	"createrawtransaction":         handleCreateRawTransaction,
 	"createrawentangletransaction": handleCreateRawEntangleTransaction,
+	"createvault":                  handleCreateVault,
 	"debuglevel":                   handleDebugLevel,
 	"decoderawtransaction":         handleDecodeRawTransaction,
 	"decodescript":                 handleDecodeScript,
 	"estimatefee":                  handleEstimateFee,
 	"generate":                     handleGenerate,
+	"generatetoaddress":            handleGenerateToAddress,
 	"getaddednodeinfo":             handleGetAddedNodeInfo,
+	"getaddresstxids":              handleGetAddressTxIDs,
 	"getbestblock":                 handleGetBestBlock,
 	"getbestblockhash":             handleGetBestBlockHash,
 	"getblock":                     handleGetBlock,
@@ -149,14 +169,21 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"getblockhash":                 handleGetBlockHash,
 	"getblockheader":               handleGetBlockHeader,
 	"getblocktemplate":             handleGetBlockTemplate,
+	"getblocktimings":              handleGetBlockTimings,
+	"getblocktxmeta":               handleGetBlockTxMeta,
 	"getcfilter":                   handleGetCFilter,
 	"getcfilterheader":             handleGetCFilterHeader,
+	"getchaintxstats":              handleGetChainTxStats,
+	"getcheckpointcandidates":      handleGetCheckpointCandidates,
 	"getconnectioncount":           handleGetConnectionCount,
 	"getcurrentnet":                handleGetCurrentNet,
+	"getdbstats":                   handleGetDBStats,
 	"getdifficulty":                handleGetDifficulty,
+	"getdifficultyhistory":         handleGetDifficultyHistory,
 	"getgenerate":                  handleGetGenerate,
 	"gethashespersec":              handleGetHashesPerSec,
 	"getheaders":                   handleGetHeaders,
+	"getindexinfo":                 handleGetIndexInfo,
 	"getinfo":                      handleGetInfo,
 	"getentangleinfo":              handleGetEntangleInfo,
 	"getwork":                      handleGetWork,
@@ -164,25 +191,42 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"getmininginfo":                handleGetMiningInfo,
 	"getnettotals":                 handleGetNetTotals,
 	"getnetworkhashps":             handleGetNetworkHashPS,
+	"getnetworkinfo":               handleGetNetworkInfo,
 	"getpeerinfo":                  handleGetPeerInfo,
+	"getpoolbalance":               handleGetPoolBalance,
+	"getreserveattestations":       handleGetReserveAttestations,
 	"getrawmempool":                handleGetRawMempool,
 	"getrawtransaction":            handleGetRawTransaction,
+	"getrawtransactions":           handleGetRawTransactions,
+	"getrpcinfo":                   handleGetRPCInfo,
+	"getscripttypestats":           handleGetScriptTypeStats,
+	"getspentinfo":                 handleGetSpentInfo,
 	"gettxout":                     handleGetTxOut,
 	"gettxoutproof":                handleGetTxOutProof,
+	"gettxreorgrisk":               handleGetTxReorgRisk,
+	"getutxoreport":                handleGetUtxoReport,
 	"help":                         handleHelp,
 	"invalidateblock":              handleInvalidateBlock,
+	"listbanned":                   handleListBanned,
+	"listentangletxs":              handleListEntangleTxs,
 	"node":                         handleNode,
 	"ping":                         handlePing,
 	"reconsiderblock":              handleReconsiderBlock,
+	"reloadconfig":                 handleReloadConfig,
+	"scantxoutset":                 handleScanTxOutSet,
 	"searchrawtransactions":        handleSearchRawTransactions,
 	"sendrawtransaction":           handleSendRawTransaction,
+	"setban":                       handleSetBan,
 	"setgenerate":                  handleSetGenerate,
+	"setloglevel":                  handleSetLogLevel,
 	"stop":                         handleStop,
 	"submitblock":                  handleSubmitBlock,
+	"submitreserveattestation":     handleSubmitReserveAttestation,
 	"submitwork":                   handleSubmitWork,
 	"uptime":                       handleUptime,
 	"validateaddress":              handleValidateAddress,
 	"verifychain":                  handleVerifyChain,
+	"verifyindexes":                handleVerifyIndexes,
 	"verifymessage":                handleVerifyMessage,
 	"verifytxoutproof":             handleVerifyTxOutProof,
 	"version":                      handleVersion,
@@ -195,7 +239,6 @@ var rpcAskWallet = map[string]struct{}{
 	"addmultisigaddress":     {},
 	"backupwallet":           {},
 	"createencryptedwallet":  {},
-	"createmultisig":         {},
 	"dumpprivkey":            {},
 	"dumpwallet":             {},
 	"encryptwallet":          {},
@@ -241,7 +284,6 @@ var rpcUnimplemented = map[string]struct{}{
 	"estimatepriority": {},
 	"getchaintips":     {},
 	"getmempoolentry":  {},
-	"getnetworkinfo":   {},
 	"preciousblock":    {},
 }
 
@@ -261,33 +303,49 @@ var rpcLimited = map[string]struct{}{
 	"help": {},
 
 	// HTTP/S-only commands
+	"createmultisig":               {},
 	"createrawtransaction":         {},
 	"createrawentangletransaction": {},
+	"createvault":                  {},
 	"decoderawtransaction":         {},
 	"decodescript":                 {},
 	"estimatefee":                  {},
+	"getaddresstxids":              {},
 	"getbestblock":                 {},
 	"getbestblockhash":             {},
 	"getblock":                     {},
 	"getblockcount":                {},
 	"getblockhash":                 {},
 	"getblockheader":               {},
+	"getblocktxmeta":               {},
 	"getcfilter":                   {},
 	"getcfilterheader":             {},
+	"getchaintxstats":              {},
 	"getcurrentnet":                {},
 	"getdifficulty":                {},
+	"getdifficultyhistory":         {},
 	"getheaders":                   {},
+	"getindexinfo":                 {},
 	"getinfo":                      {},
 	"getentangleinfo":              {},
+	"getpoolbalance":               {},
+	"getreserveattestations":       {},
+	"listentangletxs":              {},
 	"getnettotals":                 {},
 	"getnetworkhashps":             {},
+	"getnetworkinfo":               {},
 	"getrawmempool":                {},
 	"getrawtransaction":            {},
+	"getrawtransactions":           {},
+	"getscripttypestats":           {},
+	"getspentinfo":                 {},
 	"gettxout":                     {},
 	"gettxoutproof":                {},
+	"gettxreorgrisk":               {},
 	"searchrawtransactions":        {},
 	"sendrawtransaction":           {},
 	"submitblock":                  {},
+	"submitreserveattestation":     {},
 	"submitwork":                   {},
 	"uptime":                       {},
 	"validateaddress":              {},
@@ -330,6 +388,21 @@ func rpcDecodeHexError(gotHex string) *btcjson.RPCError {
 			gotHex))
 }
 
+// rpcPrunedTxError returns a nicely formatted RPC error when the block which
+// contains the provided transaction was pruned, so the transaction can not be
+// loaded even though it is in the transaction index.  It returns nil when the
+// data of the block is available.
+func rpcPrunedTxError(s *rpcServer, txHash, blockHash *chainhash.Hash) error {
+	height, err := s.cfg.Chain.BlockHeightByHash(blockHash)
+	if err != nil || height >= s.cfg.Chain.PruneHeight() {
+		return nil
+	}
+
+	return btcjson.NewRPCError(btcjson.ErrRPCNoTxInfo,
+		fmt.Sprintf("Transaction %v is in block %v at height %d which "+
+			"was pruned", txHash, blockHash, height))
+}
+
 // rpcNoTxInfoError is a convenience function for returning a nicely formatted
 // RPC error which indicates there is no information available for the provided
 // transaction hash.
@@ -344,22 +417,26 @@ func rpcNoTxInfoError(txHash *chainhash.Hash) *btcjson.RPCError {
 type gbtWorkState struct {
 	sync.Mutex
 	lastTxUpdate  time.Time
+	lastTxSeq     uint64
 	lastGenerated time.Time
 	prevHash      *chainhash.Hash
 	minTimestamp  time.Time
 	template      *mining.BlockTemplate
 	notifyMap     map[chainhash.Hash]map[int64]chan struct{}
 	timeSource    blockchain.MedianTimeSource
+	maxTimeOffset time.Duration
 	maxSigOps     uint32
 	maxBlockSize  uint32
 }
 
 // newGbtWorkState returns a new instance of a gbtWorkState with all internal
-// fields initialized and ready to use.
-func newGbtWorkState(timeSource blockchain.MedianTimeSource) *gbtWorkState {
+// fields initialized and ready to use.  The templates are served as long as
+// their time is at most maxTimeOffset ahead of the adjusted time.
+func newGbtWorkState(timeSource blockchain.MedianTimeSource, maxTimeOffset time.Duration) *gbtWorkState {
 	return &gbtWorkState{
-		notifyMap:  make(map[chainhash.Hash]map[int64]chan struct{}),
-		timeSource: timeSource,
+		notifyMap:     make(map[chainhash.Hash]map[int64]chan struct{}),
+		timeSource:    timeSource,
+		maxTimeOffset: maxTimeOffset,
 	}
 }
 
@@ -525,6 +602,208 @@ func messageToHex(msg wire.Message) (string, error) {
 	return hex.EncodeToString(buf.Bytes()), nil
 }
 
+// handleClearBanned implements the clearbanned command.
+func handleClearBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	s.cfg.ConnMgr.ClearBanned()
+	return nil, nil
+}
+
+// dbMaintainer returns the compaction and statistics interface of the block
+// database or an error when the database does not support it.
+func dbMaintainer(s *rpcServer) (database.Maintainer, error) {
+	maintainer, ok := s.cfg.DB.(database.Maintainer)
+	if !ok {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCMisc,
+			Message: fmt.Sprintf("The %s database does not support "+
+				"maintenance", s.cfg.DB.Type()),
+		}
+	}
+	return maintainer, nil
+}
+
+// handleCaptureProfile implements the captureprofile command.
+func handleCaptureProfile(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CaptureProfileCmd)
+
+	if !validProfileType(c.Type) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid profile type %q -- valid "+
+				"types are cpu, trace, heap, allocs, goroutine "+
+				"and threadcreate", c.Type),
+		}
+	}
+	seconds := 0
+	if isTimedProfile(c.Type) {
+		seconds = *c.Seconds
+		if seconds < 1 || seconds > maxProfileSeconds {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("The seconds must be between "+
+					"1 and %d", maxProfileSeconds),
+			}
+		}
+	}
+
+	// Stop capturing early when either the client goes away or the server
+	// shuts down.
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-closeChan:
+		case <-s.quit:
+		case <-done:
+			return
+		}
+		close(quit)
+	}()
+
+	var buf bytes.Buffer
+	err := captureProfile(&buf, c.Type, time.Duration(seconds)*time.Second,
+		quit)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: fmt.Sprintf("Unable to capture profile: %v", err),
+		}
+	}
+	rpcsLog.Infof("Captured %s profile (%d bytes)", c.Type, buf.Len())
+
+	return &btcjson.CaptureProfileResult{
+		Type:    c.Type,
+		Seconds: seconds,
+		Size:    buf.Len(),
+		Data:    base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// handleCompactDB implements the compactdb command.
+func handleCompactDB(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	maintainer, err := dbMaintainer(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := maintainer.Compact(); err != nil {
+		context := "Failed to compact the database"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return nil, nil
+}
+
+// decodeMultiSigKeys decodes the hex-encoded public keys of a multisig redeem
+// script.
+func decodeMultiSigKeys(keys []string, params *chaincfg.Params) ([]*czzutil.AddressPubKey, error) {
+	if len(keys) == 0 || len(keys) > txscript.MaxP2SHMultiSigKeys {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Number of keys must be between 1 "+
+				"and %d", txscript.MaxP2SHMultiSigKeys),
+		}
+	}
+
+	pubKeys := make([]*czzutil.AddressPubKey, 0, len(keys))
+	for _, key := range keys {
+		pubKey, err := decodePubKey(key, params)
+		if err != nil {
+			return nil, err
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	return pubKeys, nil
+}
+
+// decodePubKey decodes a hex-encoded compressed or uncompressed public key.
+func decodePubKey(key string, params *chaincfg.Params) (*czzutil.AddressPubKey, error) {
+	serialized, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, rpcDecodeHexError(key)
+	}
+	pubKey, err := czzutil.NewAddressPubKey(serialized, params)
+	if err != nil || pubKey.Format() == czzutil.PKFHybrid {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid public key: " + key,
+		}
+	}
+	return pubKey, nil
+}
+
+// handleCreateMultisig handles createmultisig commands.
+func handleCreateMultisig(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CreateMultisigCmd)
+
+	pubKeys, err := decodeMultiSigKeys(c.Keys, s.cfg.ChainParams)
+	if err != nil {
+		return nil, err
+	}
+	if c.NRequired < 1 || c.NRequired > len(pubKeys) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Number of required signatures must "+
+				"be between 1 and the number of keys (%d)",
+				len(pubKeys)),
+		}
+	}
+
+	addr, script, err := txscript.NewMultiSigAddress(c.NRequired, pubKeys,
+		s.cfg.ChainParams)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unable to create the redeem script: " + err.Error(),
+		}
+	}
+
+	return &btcjson.CreateMultiSigResult{
+		Address:      addr.EncodeAddress(),
+		RedeemScript: hex.EncodeToString(script),
+	}, nil
+}
+
+// handleCreateVault handles createvault commands.
+func handleCreateVault(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CreateVaultCmd)
+
+	ownerKey, err := decodePubKey(c.OwnerKey, s.cfg.ChainParams)
+	if err != nil {
+		return nil, err
+	}
+	recoveryKey, err := decodePubKey(c.RecoveryKey, s.cfg.ChainParams)
+	if err != nil {
+		return nil, err
+	}
+	if c.LockTime <= 0 || c.LockTime > txscript.MaxVaultLockTime {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Locktime out of range",
+		}
+	}
+
+	script, err := txscript.VaultScript(ownerKey.ScriptAddress(),
+		recoveryKey.ScriptAddress(), c.LockTime)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unable to create the redeem script: " + err.Error(),
+		}
+	}
+	addr, err := czzutil.NewAddressScriptHash(script, s.cfg.ChainParams)
+	if err != nil {
+		context := "Failed to convert script to pay-to-script-hash"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return &btcjson.CreateVaultResult{
+		Address:      addr.EncodeAddress(),
+		RedeemScript: hex.EncodeToString(script),
+		LockTime:     c.LockTime,
+	}, nil
+}
+
 // handleCreateRawTransaction handles createrawtransaction commands.
 func handleCreateRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.CreateRawTransactionCmd)
@@ -583,6 +862,7 @@ func handleCreateRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan
 		case *czzutil.AddressPubKeyHash:
 		case *czzutil.AddressScriptHash:
 		case *czzutil.LegacyAddressPubKeyHash:
+		case *czzutil.LegacyAddressScriptHash:
 		default:
 			return nil, &btcjson.RPCError{
 				Code:    btcjson.ErrRPCInvalidAddressOrKey,
@@ -662,9 +942,18 @@ func handleCreateRawEntangleTransaction(s *rpcServer, cmd interface{}, closeChan
 	}
 
 	for _, entangle := range c.EntangleOuts {
-		scriptInfo, err := txscript.EntangleScript(entangle.Serialize())
+		scriptInfo, err := txscript.NewEntangleScript(&txscript.EntangleData{
+			ExTxType:  entangle.ExTxType,
+			Index:     entangle.Index,
+			Height:    entangle.Height,
+			Amount:    entangle.Amount,
+			ExtTxHash: []byte(entangle.ExtTxHash),
+		})
 		if err != nil {
-			return nil, err
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid entangle output: " + err.Error(),
+			}
 		}
 
 		mtx.AddTxOut(&wire.TxOut{
@@ -921,6 +1210,12 @@ func handleDecodeScript(s *rpcServer, cmd interface{}, closeChan <-chan struct{}
 		Type:      scriptClass.String(),
 		Addresses: addresses,
 	}
+	if scriptClass == txscript.VaultTy {
+		pushes, err := txscript.ExtractVaultDataPushes(script)
+		if err == nil && pushes != nil {
+			reply.LockTime = pushes.LockTime
+		}
+	}
 	if scriptClass != txscript.ScriptHashTy {
 		reply.P2sh = p2sh.EncodeAddress()
 	}
@@ -961,6 +1256,29 @@ func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 		}
 	}
 
+	c := cmd.(*btcjson.GenerateCmd)
+	return generateBlocks(s, c.NumBlocks, nil)
+}
+
+// handleGenerateToAddress handles generatetoaddress commands.
+func handleGenerateToAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GenerateToAddressCmd)
+
+	params := s.cfg.ChainParams
+	addr, err := czzutil.DecodeAddress(c.Address, params)
+	if err != nil || !addr.IsForNet(params) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address: " + c.Address,
+		}
+	}
+	return generateBlocks(s, c.NumBlocks, addr)
+}
+
+// generateBlocks mines the passed number of blocks paying to the passed
+// address, or to the configured mining addresses when it is nil, and returns
+// their hashes for the generate and generatetoaddress commands.
+func generateBlocks(s *rpcServer, numBlocks uint32, payToAddr czzutil.Address) (interface{}, error) {
 	// Respond with an error if there's virtually 0 chance of mining a block
 	// with the CPU.
 	if !s.cfg.ChainParams.GenerateSupported {
@@ -973,10 +1291,8 @@ func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 		}
 	}
 
-	c := cmd.(*btcjson.GenerateCmd)
-
 	// Respond with an error if the client is requesting 0 blocks to be generated.
-	if c.NumBlocks == 0 {
+	if numBlocks == 0 {
 		return nil, &btcjson.RPCError{
 			Code:    btcjson.ErrRPCInternal.Code,
 			Message: "Please request a nonzero number of blocks to generate.",
@@ -984,9 +1300,16 @@ func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	}
 
 	// Create a reply
-	reply := make([]string, c.NumBlocks)
+	reply := make([]string, numBlocks)
 
-	blockHashes, err := s.cfg.CPUMiner.GenerateNBlocks(c.NumBlocks)
+	var blockHashes []*chainhash.Hash
+	var err error
+	if payToAddr != nil {
+		blockHashes, err = s.cfg.CPUMiner.GenerateNBlocksToAddress(
+			numBlocks, payToAddr)
+	} else {
+		blockHashes, err = s.cfg.CPUMiner.GenerateNBlocks(numBlocks)
+	}
 	if err != nil {
 		return nil, &btcjson.RPCError{
 			Code:    btcjson.ErrRPCInternal.Code,
@@ -1093,6 +1416,160 @@ func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return results, nil
 }
 
+// decodeAddressOrScript decodes the passed address or, when it is not a valid
+// address, the hex-encoded public key script paying to a single address.
+func decodeAddressOrScript(encoded string, params *chaincfg.Params) (czzutil.Address, error) {
+	addr, err := czzutil.DecodeAddress(encoded, params)
+	if err == nil {
+		return addr, nil
+	}
+
+	pkScript, hexErr := hex.DecodeString(encoded)
+	if hexErr != nil {
+		return nil, err
+	}
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) != 1 {
+		return nil, errors.New("script does not pay to a single address")
+	}
+	return addrs[0], nil
+}
+
+// handleGetAddressTxIDs implements the getaddresstxids command.
+func handleGetAddressTxIDs(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the address index is not enabled.  Whether
+	// the inputs of a transaction spend from the address is determined
+	// with the transaction index, which the address index relies on.
+	if s.cfg.AddrIndex == nil || s.cfg.TxIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Address index must be enabled (--addrindex)",
+		}
+	}
+
+	c := cmd.(*btcjson.GetAddressTxIDsCmd)
+	params := s.cfg.ChainParams
+	addr, err := decodeAddressOrScript(c.Address, params)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: " + err.Error(),
+		}
+	}
+
+	// Override the default number of requested entries if needed.
+	numRequested := 100
+	if c.Count != nil {
+		numRequested = *c.Count
+		if numRequested < 0 {
+			numRequested = 1
+		}
+	}
+	if numRequested == 0 {
+		return []btcjson.GetAddressTxIDsResult{}, nil
+	}
+
+	// Override the default number of entries to skip if needed.
+	var numToSkip int
+	if c.Skip != nil {
+		numToSkip = *c.Skip
+		if numToSkip < 0 {
+			numToSkip = 0
+		}
+	}
+
+	// Override the reverse flag if needed.
+	var reverse bool
+	if c.Reverse != nil {
+		reverse = *c.Reverse
+	}
+
+	addressTxns, err := fetchAddressTxns(s, addr, numToSkip, numRequested,
+		reverse)
+	if err != nil {
+		return nil, err
+	}
+
+	// The address index does not distinguish between public keys and their
+	// hashes, so outputs paying to either are matched.
+	encodeAddr := func(addr czzutil.Address) string {
+		if pk, ok := addr.(*czzutil.AddressPubKey); ok {
+			return pk.AddressPubKeyHash().EncodeAddress()
+		}
+		return addr.EncodeAddress()
+	}
+	encodedAddr := encodeAddr(addr)
+	paysToAddr := func(pkScript []byte) bool {
+		// Ignore the error here since a script which does not parse
+		// can not pay to the address.
+		_, addrs, _, _ := txscript.ExtractPkScriptAddrs(pkScript, params)
+		for _, a := range addrs {
+			if encodeAddr(a) == encodedAddr {
+				return true
+			}
+		}
+		return false
+	}
+
+	results := make([]btcjson.GetAddressTxIDsResult, 0, len(addressTxns))
+	for i := range addressTxns {
+		rtx := &addressTxns[i]
+		var mtx *wire.MsgTx
+		if rtx.tx == nil {
+			mtx = new(wire.MsgTx)
+			err := mtx.Deserialize(bytes.NewReader(rtx.txBytes))
+			if err != nil {
+				context := "Failed to deserialize transaction"
+				return nil, internalRPCError(err.Error(),
+					context)
+			}
+		} else {
+			mtx = rtx.tx.MsgTx()
+		}
+
+		// Transactions from the mempool are not in a block yet.
+		result := btcjson.GetAddressTxIDsResult{
+			TxID:   mtx.TxHash().String(),
+			Height: -1,
+		}
+		if blkHash := rtx.blkHash; blkHash != nil {
+			height, err := s.cfg.Chain.BlockHeightByHash(blkHash)
+			if err != nil {
+				context := "Failed to obtain block height"
+				return nil, internalRPCError(err.Error(), context)
+			}
+			result.BlockHash = blkHash.String()
+			result.Height = height
+		}
+
+		for _, txOut := range mtx.TxOut {
+			if paysToAddr(txOut.PkScript) {
+				result.Funding = true
+				break
+			}
+		}
+		if !blockchain.IsCoinBaseTx(mtx) {
+			originOutputs, err := fetchInputTxos(s, mtx)
+			if err != nil {
+				return nil, err
+			}
+			for _, txOut := range originOutputs {
+				if paysToAddr(txOut.PkScript) {
+					result.Spending = true
+					break
+				}
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
 // handleGetBestBlock implements the getbestblock command.
 func handleGetBestBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// All other "get block" commands give either the height, the
@@ -1136,23 +1613,23 @@ func getDifficultyRatio(bits uint32, params *chaincfg.Params) float64 {
 func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetBlockCmd)
 
-	// Load the raw block bytes from the database.
+	// Load the block from the cache of recent blocks or the database.
 	hash, err := chainhash.NewHashFromStr(c.Hash)
 	if err != nil {
 		return nil, rpcDecodeHexError(c.Hash)
 	}
-	var blkBytes []byte
-	err = s.cfg.DB.View(func(dbTx database.Tx) error {
-		var err error
-		blkBytes, err = dbTx.FetchBlock(hash)
-		return err
-	})
+	blk, err := s.cfg.Chain.FetchBlock(hash)
 	if err != nil {
 		return nil, &btcjson.RPCError{
 			Code:    btcjson.ErrRPCBlockNotFound,
 			Message: "Block not found",
 		}
 	}
+	blkBytes, err := blk.Bytes()
+	if err != nil {
+		context := "Failed to serialize block"
+		return nil, internalRPCError(err.Error(), context)
+	}
 
 	// When the verbosity value set to 0, simply return the serialized block
 	// as a hex-encoded string.
@@ -1162,20 +1639,12 @@ func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 
 	// Generate the JSON object and return it.
 
-	// Deserialize the block.
-	blk, err := czzutil.NewBlockFromBytes(blkBytes)
-	if err != nil {
-		context := "Failed to deserialize block"
-		return nil, internalRPCError(err.Error(), context)
-	}
-
 	// Get the block height from chain.
 	blockHeight, err := s.cfg.Chain.BlockHeightByHash(hash)
 	if err != nil {
 		context := "Failed to obtain block height"
 		return nil, internalRPCError(err.Error(), context)
 	}
-	blk.SetHeight(blockHeight)
 	best := s.cfg.Chain.BestSnapshot()
 
 	// Get next block hash unless there are none.
@@ -1266,6 +1735,37 @@ func softForkStatus(state blockchain.ThresholdState) (string, error) {
 	}
 }
 
+// estimateVerificationProgress returns an estimate of the fraction of the
+// transactions of the chain which were verified, from the transactions of the
+// passed best state and those expected in the blocks the node is missing.  The
+// missing blocks are those up to the passed height of the sync peer, or those
+// expected to be mined since the passed time of the best block if there are
+// more, so that the progress drops while the sync is stalled.
+func estimateVerificationProgress(snapshot *blockchain.BestState,
+	syncHeight uint64, tipTime, now time.Time,
+	targetTimePerBlock time.Duration) float64 {
+
+	if snapshot.TotalTxns == 0 {
+		return 0
+	}
+
+	var missingBlocks float64
+	if syncHeight > uint64(snapshot.Height) {
+		missingBlocks = float64(syncHeight - uint64(snapshot.Height))
+	}
+	if elapsed := now.Sub(tipTime); elapsed > 0 && targetTimePerBlock > 0 {
+		missingBlocks = math.Max(missingBlocks,
+			float64(elapsed)/float64(targetTimePerBlock))
+	}
+
+	// The missing blocks are expected to have as many transactions as the
+	// average block of the chain.
+	txnsPerBlock := float64(snapshot.TotalTxns) /
+		float64(snapshot.Height+1)
+	totalTxns := float64(snapshot.TotalTxns)
+	return math.Min(totalTxns/(totalTxns+missingBlocks*txnsPerBlock), 1.0)
+}
+
 // handleGetBlockChainInfo implements the getblockchaininfo command.
 func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Obtain a snapshot of the current best known blockchain state. We'll
@@ -1276,10 +1776,14 @@ func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan str
 
 	// Estimate the verification (sync) progress of the node.
 	syncHeight := s.cfg.SyncMgr.SyncHeight()
-	var verifyProgress float64
-	if syncHeight > 0 {
-		verifyProgress = math.Min(float64(chainSnapshot.Height)/float64(syncHeight), 1.0)
+	tipHeader, err := chain.HeaderByHash(&chainSnapshot.Hash)
+	if err != nil {
+		context := "Failed to obtain the best block header"
+		return nil, internalRPCError(err.Error(), context)
 	}
+	verifyProgress := estimateVerificationProgress(chainSnapshot,
+		syncHeight, tipHeader.Timestamp, time.Now(),
+		params.TargetTimePerBlock)
 
 	chainInfo := &btcjson.GetBlockChainInfoResult{
 		Chain:                params.Name,
@@ -1292,6 +1796,8 @@ func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan str
 		Bip9SoftForks:        make(map[string]*btcjson.Bip9SoftForkDescription),
 		VerificationProgress: verifyProgress,
 		SyncHeight:           syncHeight,
+		SizeOnDisk:           chainSnapshot.TotalSize,
+		Sequence:             chainSnapshot.Sequence,
 	}
 
 	// Next, populate the response with information describing the current
@@ -1313,6 +1819,15 @@ func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan str
 		case chaincfg.DeploymentCSV:
 			forkName = "csv"
 
+		case chaincfg.DeploymentSEQ:
+			forkName = "seq"
+
+		case chaincfg.DeploymentSigChecks:
+			forkName = "sigchecks"
+
+		case chaincfg.DeploymentUtxoCommitment:
+			forkName = "utxocommitment"
+
 		default:
 			return nil, &btcjson.RPCError{
 				Code: btcjson.ErrRPCInternal.Code,
@@ -1600,18 +2115,21 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 	if lastTxUpdate.IsZero() {
 		lastTxUpdate = time.Now()
 	}
+	lastTxSeq := generator.TxSource().Sequence()
 
 	// Generate a new block template when the current best block has
 	// changed or the transactions in the memory pool have been updated and
 	// it has been at least gbtRegenerateSecond since the last template was
-	// generated.
+	// generated.  The updates are detected with the sequence of the memory
+	// pool since the time of the last update only has a one second
+	// resolution.
 	var msgBlock *wire.MsgBlock
 	var targetDifficulty string
 	latestHash := &s.cfg.Chain.BestSnapshot().Hash
 	template := state.template
 	if template == nil || state.prevHash == nil ||
 		!state.prevHash.IsEqual(latestHash) ||
-		(state.lastTxUpdate != lastTxUpdate &&
+		(state.lastTxSeq != lastTxSeq &&
 			time.Now().After(state.lastGenerated.Add(time.Second*
 				gbtRegenerateSeconds))) {
 
@@ -1655,6 +2173,7 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 		state.template = template
 		state.lastGenerated = time.Now()
 		state.lastTxUpdate = lastTxUpdate
+		state.lastTxSeq = lastTxSeq
 		state.prevHash = latestHash
 		state.minTimestamp = minTimestamp
 
@@ -1695,8 +2214,8 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 
 			// Update the merkle root.
 			block := czzutil.NewBlock(template.Block)
-			merkles := blockchain.BuildMerkleTreeStore(block.Transactions())
-			template.Block.Header.MerkleRoot = *merkles[len(merkles)-1]
+			template.Block.Header.MerkleRoot = blockchain.CalcMerkleRoot(
+				block.Transactions())
 		}
 
 		// Set locals for convenience.
@@ -1732,7 +2251,7 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 	msgBlock := template.Block
 	header := &msgBlock.Header
 	adjustedTime := state.timeSource.AdjustedTime()
-	maxTime := adjustedTime.Add(time.Second * blockchain.MaxTimeOffsetSeconds)
+	maxTime := adjustedTime.Add(state.maxTimeOffset)
 	if header.Timestamp.After(maxTime) {
 		return nil, &btcjson.RPCError{
 			Code: btcjson.ErrRPCOutOfRange,
@@ -2137,6 +2656,10 @@ func chainErrToGBTErrString(err error) string {
 		return "inconclusive-not-best-prvblk"
 	case blockchain.ErrInvalidTxOrder:
 		return "invalid-transaction-order"
+	case blockchain.ErrBadPoolOutput:
+		return "bad-cb-pool"
+	case blockchain.ErrBadEntangleAmount:
+		return "bad-cb-entangle"
 	}
 
 	return "rejected: " + err.Error()
@@ -2182,11 +2705,20 @@ func handleGetBlockTemplateProposal(s *rpcServer, request *btcjson.TemplateReque
 	expectedPrevHash := s.cfg.Chain.BestSnapshot().Hash
 	prevHash := &block.MsgBlock().Header.PrevBlock
 	if !expectedPrevHash.IsEqual(prevHash) {
+		if request.Verbose {
+			return &btcjson.BlockProposalResult{
+				Reason: "bad-prevblk",
+				Code:   blockchain.ErrPrevBlockNotBest.String(),
+				Message: fmt.Sprintf("previous block %v is not the "+
+					"current best block %v", prevHash, expectedPrevHash),
+			}, nil
+		}
 		return "bad-prevblk", nil
 	}
 
 	if err := s.cfg.Chain.CheckConnectBlockTemplate(block); err != nil {
-		if _, ok := err.(blockchain.RuleError); !ok {
+		ruleErr, ok := err.(blockchain.RuleError)
+		if !ok {
 			errStr := fmt.Sprintf("Failed to process block proposal: %v", err)
 			rpcsLog.Error(errStr)
 			return nil, &btcjson.RPCError{
@@ -2196,9 +2728,19 @@ func handleGetBlockTemplateProposal(s *rpcServer, request *btcjson.TemplateReque
 		}
 
 		rpcsLog.Infof("Rejected block proposal: %v", err)
+		if request.Verbose {
+			return &btcjson.BlockProposalResult{
+				Reason:  chainErrToGBTErrString(err),
+				Code:    ruleErr.ErrorCode.String(),
+				Message: ruleErr.Description,
+			}, nil
+		}
 		return chainErrToGBTErrString(err), nil
 	}
 
+	if request.Verbose {
+		return &btcjson.BlockProposalResult{Accepted: true}, nil
+	}
 	return nil, nil
 }
 
@@ -2229,6 +2771,100 @@ func handleGetBlockTemplate(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	}
 }
 
+// unixMillis returns the passed time in milliseconds since the unix epoch, or 0
+// for the zero time.
+func unixMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// handleGetBlockTimings implements the getblocktimings command.
+func handleGetBlockTimings(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	timings := s.cfg.SyncMgr.BlockTimings()
+	blocks := make([]btcjson.BlockTimingsResult, 0, len(timings))
+	for i := range timings {
+		t := &timings[i]
+		blocks = append(blocks, btcjson.BlockTimingsResult{
+			Hash:           t.Hash.String(),
+			Height:         t.Height,
+			Peer:           t.Peer,
+			FirstInv:       unixMillis(t.FirstInv),
+			HeaderReceived: unixMillis(t.HeaderReceived),
+			BlockReceived:  unixMillis(t.BlockReceived),
+			Validated:      unixMillis(t.Validated),
+			Announced:      unixMillis(t.Announced),
+		})
+	}
+
+	stats := s.cfg.SyncMgr.BlockLatencyStats()
+	latencies := make([]btcjson.BlockLatencyResult, 0, len(stats))
+	for latency := netsync.LatencyInvToBlock; latency <= netsync.LatencyValidatedToAnnounced; latency++ {
+		stat := stats[latency]
+		var average float64
+		if stat.Count > 0 {
+			average = float64(stat.Total) / float64(stat.Count) /
+				float64(time.Millisecond)
+		}
+		latencies = append(latencies, btcjson.BlockLatencyResult{
+			Name:      latency.String(),
+			Count:     stat.Count,
+			AverageMs: average,
+		})
+	}
+
+	return &btcjson.GetBlockTimingsResult{
+		Blocks:    blocks,
+		Latencies: latencies,
+	}, nil
+}
+
+// handleGetBlockTxMeta implements the getblocktxmeta command.
+func handleGetBlockTxMeta(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockTxMetaCmd)
+
+	hash, err := chainhash.NewHashFromStr(c.Hash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Hash)
+	}
+	height, err := s.cfg.Chain.BlockHeightByHash(hash)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found in the main chain",
+		}
+	}
+	metas, err := s.cfg.Chain.BlockTxMeta(hash)
+	if err != nil {
+		context := "Failed to load transaction metadata"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	txns := make([]btcjson.GetBlockTxMetaResultTx, 0, len(metas))
+	for i := range metas {
+		meta := &metas[i]
+		depends := make([]int64, 0, len(meta.Depends))
+		for _, depIdx := range meta.Depends {
+			depends = append(depends, int64(depIdx))
+		}
+		txns = append(txns, btcjson.GetBlockTxMetaResultTx{
+			TxID:    meta.Hash.String(),
+			Size:    int64(meta.Size),
+			Fee:     meta.Fee,
+			FeeRate: meta.FeeRate(),
+			Depends: depends,
+			Depth:   int64(meta.Depth),
+		})
+	}
+
+	return &btcjson.GetBlockTxMetaResult{
+		Hash:   hash.String(),
+		Height: height,
+		Tx:     txns,
+	}, nil
+}
+
 // handleGetCFilter implements the getcfilter command.
 func handleGetCFilter(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	if s.cfg.CfIndex == nil {
@@ -2289,6 +2925,123 @@ func handleGetCFilterHeader(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return hash.String(), nil
 }
 
+// handleGetChainTxStats implements the getchaintxstats command.
+func handleGetChainTxStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetChainTxStatsCmd)
+
+	var endHash *chainhash.Hash
+	if c.BlockHash != nil {
+		hash, err := chainhash.NewHashFromStr(*c.BlockHash)
+		if err != nil {
+			return nil, rpcDecodeHexError(*c.BlockHash)
+		}
+		endHash = hash
+	}
+	nBlocks := int32(2880)
+	if c.NBlocks != nil {
+		nBlocks = *c.NBlocks
+	}
+	if nBlocks < 0 || nBlocks > blockchain.MaxActivityWindow {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid block count: must be between "+
+				"0 and %d", blockchain.MaxActivityWindow),
+		}
+	}
+
+	activity, err := s.cfg.Chain.ChainActivity(endHash, nBlocks)
+	if err != nil {
+		if endHash != nil && !s.cfg.Chain.MainChainHasBlock(endHash) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not found in the main chain",
+			}
+		}
+		context := "Failed to compute the chain transaction statistics"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	header, err := s.cfg.Chain.HeaderByHash(&activity.EndHash)
+	if err != nil {
+		context := "Failed to load the window final block header"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	result := &btcjson.GetChainTxStatsResult{
+		Time:                   header.Timestamp.Unix(),
+		WindowFinalBlockHash:   activity.EndHash.String(),
+		WindowFinalBlockHeight: activity.EndHeight,
+		WindowBlockCount:       activity.NumBlocks,
+		WindowTxCount:          activity.NumTxns,
+		WindowInterval:         int64(activity.Interval / time.Second),
+		WindowTxSize:           activity.Size,
+		WindowFees:             activity.Fees,
+		WindowEntangle: make([]btcjson.ChainTxStatsEntangleResult, 0,
+			len(activity.Entangled)),
+	}
+	if result.WindowInterval > 0 {
+		result.TxRate = float64(activity.NumTxns) /
+			float64(result.WindowInterval)
+	}
+	if activity.Size > 0 {
+		result.WindowFeeRate = activity.Fees * 1000 / activity.Size
+	}
+	for _, exTxType := range []cross.ExpandedTxType{
+		cross.ExpandedTxEntangle_Doge, cross.ExpandedTxEntangle_Ltc} {
+
+		volume := activity.Entangled[exTxType]
+		entangle := btcjson.ChainTxStatsEntangleResult{
+			ExTxType: exTxType.String(),
+			Count:    volume.Count,
+		}
+		if volume.Amount != nil {
+			entangle.Amount = volume.Amount.Int64()
+		}
+		result.WindowEntangle = append(result.WindowEntangle, entangle)
+	}
+	return result, nil
+}
+
+// handleGetCheckpointCandidates implements the getcheckpointcandidates command.
+func handleGetCheckpointCandidates(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetCheckpointCandidatesCmd)
+
+	depth := int32(blockchain.CheckpointConfirmations)
+	if c.Depth != nil {
+		depth = *c.Depth
+	}
+	interval := int32(10000)
+	if c.Interval != nil {
+		interval = *c.Interval
+	}
+	if depth < 1 || interval < 1 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Depth and interval must be positive",
+		}
+	}
+
+	candidates, err := s.cfg.Chain.GenerateCheckpointCandidates(depth,
+		interval)
+	if err != nil {
+		context := "Failed to generate checkpoint candidates"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	// The Go syntax is the one of the checkpoints of the chain parameters
+	// so the candidates can be pasted into them.
+	results := make([]btcjson.CheckpointCandidateResult, 0, len(candidates))
+	for _, candidate := range candidates {
+		results = append(results, btcjson.CheckpointCandidateResult{
+			Height: candidate.Height,
+			Hash:   candidate.Hash.String(),
+			GoSyntax: fmt.Sprintf("{Height: %d, Hash: "+
+				"newHashFromStr(\"%v\")},", candidate.Height,
+				candidate.Hash),
+		})
+	}
+	return results, nil
+}
+
 // handleGetConnectionCount implements the getconnectioncount command.
 func handleGetConnectionCount(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	return s.cfg.ConnMgr.ConnectedCount(), nil
@@ -2299,12 +3052,95 @@ func handleGetCurrentNet(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 	return s.cfg.ChainParams.Net, nil
 }
 
+// handleGetDBStats implements the getdbstats command.
+func handleGetDBStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	maintainer, err := dbMaintainer(s)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := maintainer.StorageStats()
+	if err != nil {
+		context := "Failed to fetch database statistics"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	stores := make([]btcjson.DBStoreStats, 0, len(stats))
+	for _, store := range stats {
+		levels := make([]btcjson.DBLevelStats, 0, len(store.Levels))
+		for _, level := range store.Levels {
+			levels = append(levels, btcjson.DBLevelStats{
+				Level:  level.Level,
+				Tables: level.Tables,
+				Size:   level.Size,
+			})
+		}
+		stores = append(stores, btcjson.DBStoreStats{
+			Name:              store.Name,
+			Engine:            store.Engine,
+			Files:             store.Files,
+			Size:              store.Size,
+			Tombstones:        store.Tombstones,
+			SizeAmplification: store.SizeAmplification,
+			Levels:            levels,
+		})
+	}
+	return &btcjson.GetDBStatsResult{
+		Type:   s.cfg.DB.Type(),
+		Stores: stores,
+	}, nil
+}
+
 // handleGetDifficulty implements the getdifficulty command.
 func handleGetDifficulty(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	best := s.cfg.Chain.BestSnapshot()
 	return getDifficultyRatio(best.Bits, s.cfg.ChainParams), nil
 }
 
+// handleGetDifficultyHistory implements the getdifficultyhistory command.
+func handleGetDifficultyHistory(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetDifficultyHistoryCmd)
+	count := int32(100)
+	if c.Count != nil {
+		count = *c.Count
+	}
+	window := int32(120)
+	if c.Window != nil {
+		window = *c.Window
+	}
+	if count <= 0 || count > maxDifficultyHistory || window <= 0 {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Count must be between 1 and %d and "+
+				"window must be positive", maxDifficultyHistory),
+		}
+	}
+
+	entries, err := s.cfg.Chain.DifficultyHistory(c.StartHeight, count,
+		window)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCOutOfRange,
+			Message: "Block number out of range",
+		}
+	}
+	results := make([]btcjson.DifficultyHistoryResult, 0, len(entries))
+	for _, entry := range entries {
+		results = append(results, btcjson.DifficultyHistoryResult{
+			Height:       entry.Height,
+			Hash:         entry.Hash.String(),
+			Time:         entry.Timestamp.Unix(),
+			Bits:         strconv.FormatInt(int64(entry.Bits), 16),
+			Target:       fmt.Sprintf("%064x", blockchain.CompactToBig(entry.Bits)),
+			Difficulty:   getDifficultyRatio(entry.Bits, s.cfg.ChainParams),
+			Work:         entry.Work.Text(16),
+			ChainWork:    entry.ChainWork.Text(16),
+			HashesPerSec: entry.HashesPerSec,
+		})
+	}
+	return results, nil
+}
+
 // handleGetGenerate implements the getgenerate command.
 func handleGetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	return s.cfg.CPUMiner.IsMining(), nil
@@ -2356,6 +3192,32 @@ func handleGetHeaders(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return hexBlockHeaders, nil
 }
 
+// handleGetIndexInfo implements the getindexinfo command.
+func handleGetIndexInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetIndexInfoCmd)
+
+	result := make(map[string]btcjson.GetIndexInfoResult)
+	if s.cfg.IndexManager == nil {
+		return result, nil
+	}
+
+	infos, err := s.cfg.IndexManager.IndexInfo()
+	if err != nil {
+		context := "Failed to fetch index state"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	for _, info := range infos {
+		if c.IndexName != nil && *c.IndexName != info.Name {
+			continue
+		}
+		result[info.Name] = btcjson.GetIndexInfoResult{
+			Synced:          info.Synced,
+			BestBlockHeight: info.Height,
+		}
+	}
+	return result, nil
+}
+
 // handleGetInfo implements the getinfo command. We only return the fields
 // that are not related to wallet functionality.
 func handleGetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
@@ -2376,8 +3238,27 @@ func handleGetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (in
 }
 
 func handleGetEntangleInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	best := s.cfg.Chain.BestSnapshot()
-	block, err := s.cfg.Chain.BlockByHash(&best.Hash)
+	keepedAmount, err := bestKeepedAmount(s.cfg.Chain)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]*btcjson.EntangleInfoChainResult, 0)
+	for _, item := range keepedAmount.Items {
+		info := &btcjson.EntangleInfoChainResult{
+			ExTxType: item.ExTxType.String(),
+			Amount:   item.Amount.Int64(),
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// bestKeepedAmount returns the amounts entangled from every chain as committed
+// in the coinbase transaction of the best block of the passed chain.
+func bestKeepedAmount(chain *blockchain.BlockChain) (*cross.KeepedAmount, error) {
+	best := chain.BestSnapshot()
+	block, err := chain.BlockByHash(&best.Hash)
 	if err != nil {
 		return nil, err
 	}
@@ -2385,23 +3266,287 @@ func handleGetEntangleInfo(s *rpcServer, cmd interface{}, closeChan <-chan struc
 	if len(txs) <= 0 {
 		return nil, errors.New("Transactions is nil")
 	}
+	if len(txs[0].MsgTx().TxOut) < 4 {
+		return nil, errors.New("coinbase transaction does not commit " +
+			"the entangled amounts")
+	}
 	txPkScript := txs[0].MsgTx().TxOut[3].PkScript
 
-	keepedAmount, err := cross.KeepedAmountFromScript(txPkScript)
+	return cross.KeepedAmountFromScript(txPkScript)
+}
 
-	infos := make([]*btcjson.EntangleInfoChainResult, 0)
-	for _, item := range keepedAmount.Items {
-		info := &btcjson.EntangleInfoChainResult{}
-		switch item.ExTxType {
-		case cross.ExpandedTxEntangle_Doge:
-			info.ExTxType = "doge"
-		case cross.ExpandedTxEntangle_Ltc:
-			info.ExTxType = "ltc"
-		}
-		info.Amount = item.Amount.Int64()
-		infos = append(infos, info)
+// handleGetPoolBalance implements the getpoolbalance command.
+func handleGetPoolBalance(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	height, pools, err := poolBalances(s.cfg.Chain, s.cfg.ChainParams)
+	if err != nil {
+		context := "Failed to compute the pool balances"
+		return nil, internalRPCError(err.Error(), context)
 	}
-	return infos, nil
+	return &btcjson.GetPoolBalanceResult{
+		Height: height,
+		Pools:  pools,
+	}, nil
+}
+
+// poolBalances returns the height of the best block of the passed chain and
+// the balances of the coin pools as of that block.
+func poolBalances(chain *blockchain.BlockChain, params *chaincfg.Params) (int32, []btcjson.PoolBalance, error) {
+	best := chain.BestSnapshot()
+
+	// The coinbase transactions merge the coin pools into their second
+	// and third outputs from the entangle height.  Until then every block
+	// pays its share of the subsidy to the pools in separate outputs.
+	var amounts [2]int64
+	if best.Height >= params.EntangleHeight {
+		block, err := chain.BlockByHash(&best.Hash)
+		if err != nil {
+			return 0, nil, err
+		}
+		coinbase := block.MsgBlock().Transactions[0]
+		if len(coinbase.TxOut) < 3 {
+			return 0, nil, errors.New("coinbase transaction does " +
+				"not pay the coin pools")
+		}
+		amounts[0] = coinbase.TxOut[1].Value
+		amounts[1] = coinbase.TxOut[2].Value
+	} else {
+		for height := int32(1); height <= best.Height; height++ {
+			subsidy := blockchain.CalcBlockSubsidy(height, params)
+			amounts[0] += subsidy * 19 / 100
+			amounts[1] += subsidy / 100
+		}
+	}
+
+	pools := make([]btcjson.PoolBalance, 0, len(amounts))
+	for i, amount := range amounts {
+		addr, err := czzutil.NewAddressPubKeyHash(
+			params.CoinPoolHashes[i][:], params)
+		if err != nil {
+			return 0, nil, err
+		}
+		pools = append(pools, btcjson.PoolBalance{
+			Address: addr.EncodeAddress(),
+			Amount:  czzutil.Amount(amount).ToCZZ(),
+		})
+	}
+	return best.Height, pools, nil
+}
+
+// handleListEntangleTxs implements the listentangletxs command.
+func handleListEntangleTxs(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ListEntangleTxsCmd)
+	count := int32(100)
+	if c.Count != nil {
+		count = *c.Count
+	}
+	if c.StartHeight < 0 || count <= 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Start height must not be negative and count must be positive",
+		}
+	}
+
+	best := s.cfg.Chain.BestSnapshot()
+	endHeight := best.Height
+	if c.StartHeight <= endHeight-count {
+		endHeight = c.StartHeight + count - 1
+	}
+	results := make([]btcjson.EntangleTxResult, 0)
+	for height := c.StartHeight; height <= endHeight; height++ {
+		select {
+		case <-closeChan:
+			return nil, ErrClientQuit
+		default:
+		}
+		block, err := s.cfg.Chain.BlockByHeight(height)
+		if err != nil {
+			context := "Failed to fetch block"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		results = append(results, blockEntangleTxs(block, height)...)
+	}
+	return results, nil
+}
+
+// blockEntangleTxs returns the entangle outputs of the transactions of the
+// passed block at the passed height, in transaction and output order.
+func blockEntangleTxs(block *czzutil.Block, height int32) []btcjson.EntangleTxResult {
+	var results []btcjson.EntangleTxResult
+
+	// Skip the coinbase transaction since only regular transactions carry
+	// entangle outputs.
+	for _, tx := range block.Transactions()[1:] {
+		infos, err := cross.IsEntangleTx(tx.MsgTx())
+		if err != nil {
+			continue
+		}
+		vouts := make([]int, 0, len(infos))
+		for vout := range infos {
+			vouts = append(vouts, int(vout))
+		}
+		sort.Ints(vouts)
+		for _, vout := range vouts {
+			info := infos[uint32(vout)]
+			results = append(results, btcjson.EntangleTxResult{
+				TxID:       tx.Hash().String(),
+				Vout:       uint32(vout),
+				Height:     height,
+				ExTxType:   info.ExTxType.String(),
+				ExtTxHash:  string(info.ExtTxHash),
+				ExtTxIndex: info.Index,
+				ExtHeight:  info.Height,
+				Amount:     info.Amount.Int64(),
+			})
+		}
+	}
+	return results
+}
+
+// handleSubmitReserveAttestation implements the submitreserveattestation
+// command.
+func handleSubmitReserveAttestation(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SubmitReserveAttestationCmd)
+
+	exTxType, ok := parseEntangleType(c.ExTxType)
+	if !ok {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unknown entangle type: " + c.ExTxType,
+		}
+	}
+	blockHash, err := chainhash.NewHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+	att := &cross.ReserveAttestation{
+		ExTxType:    exTxType,
+		BlockHeight: c.BlockHeight,
+		BlockHash:   *blockHash,
+		Signatures:  make([]cross.ReserveSignature, 0, len(c.Signatures)),
+		OutPoints:   make([]wire.OutPoint, 0, len(c.OutPoints)),
+	}
+	for _, sig := range c.Signatures {
+		signature, err := base64.StdEncoding.DecodeString(sig.Signature)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCParse.Code,
+				Message: "Malformed base64 encoding: " + err.Error(),
+			}
+		}
+		att.Signatures = append(att.Signatures, cross.ReserveSignature{
+			Address:   sig.Address,
+			Signature: signature,
+		})
+	}
+	for _, op := range c.OutPoints {
+		txHash, err := chainhash.NewHashFromStr(op.Hash)
+		if err != nil {
+			return nil, rpcDecodeHexError(op.Hash)
+		}
+		att.OutPoints = append(att.OutPoints, *wire.NewOutPoint(txHash,
+			op.Index))
+	}
+
+	proof, err := s.cfg.Chain.GetEntangleVerify().SubmitReserveAttestation(att)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCVerify,
+			Message: "Reserve attestation rejected: " + err.Error(),
+		}
+	}
+	rpcsLog.Infof("Verified the %s reserve attestation of block %v "+
+		"(height %d, reserve %d)", c.ExTxType, blockHash, c.BlockHeight,
+		proof.Reserve)
+
+	return reserveAttestationResult(s.cfg.Chain, proof, nil), nil
+}
+
+// handleGetReserveAttestations implements the getreserveattestations command.
+func handleGetReserveAttestations(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetReserveAttestationsCmd)
+	ev := s.cfg.Chain.GetEntangleVerify()
+
+	results := make([]*btcjson.ReserveAttestationResult, 0)
+	for _, proof := range ev.ReserveProofs() {
+		// The proofs are reported as of their verification unless the
+		// attested outputs are to be checked again for being unspent.
+		var verifyErr error
+		if c.Verify != nil && *c.Verify {
+			select {
+			case <-closeChan:
+				return nil, ErrClientQuit
+			default:
+			}
+			reverified, err := ev.VerifyReserveAttestation(
+				proof.ReserveAttestation)
+			if err != nil {
+				verifyErr = err
+			} else {
+				proof = reverified
+			}
+		}
+		results = append(results, reserveAttestationResult(s.cfg.Chain,
+			proof, verifyErr))
+	}
+	return results, nil
+}
+
+// reserveAttestationResult returns the passed reserve proof along with the
+// amount entangled from its chain as of the best block of the passed chain and
+// whether the reserve backs it.  The passed error is why the proof failed to
+// verify again, if it did.
+func reserveAttestationResult(chain *blockchain.BlockChain, proof *cross.ReserveProof,
+	verifyErr error) *btcjson.ReserveAttestationResult {
+
+	addresses := make([]string, 0, len(proof.Signatures))
+	for _, sig := range proof.Signatures {
+		addresses = append(addresses, sig.Address)
+	}
+	outPoints := make([]btcjson.OutPoint, 0, len(proof.OutPoints))
+	for _, op := range proof.OutPoints {
+		outPoints = append(outPoints, btcjson.OutPoint{
+			Hash:  op.Hash.String(),
+			Index: op.Index,
+		})
+	}
+
+	// The entangled amounts are only committed from the entangle height,
+	// before which nothing is entangled.
+	var entangled int64
+	if keepedAmount, err := bestKeepedAmount(chain); err == nil {
+		if amount := keepedAmount.GetValue(proof.ExTxType); amount != nil {
+			entangled = amount.Int64()
+		}
+	}
+
+	result := &btcjson.ReserveAttestationResult{
+		ExTxType:      proof.ExTxType.String(),
+		BlockHeight:   proof.BlockHeight,
+		BlockHash:     proof.BlockHash.String(),
+		Confirmations: proof.Confirmations,
+		Addresses:     addresses,
+		OutPoints:     outPoints,
+		Reserve:       proof.Reserve,
+		Entangled:     entangled,
+		Backed:        verifyErr == nil && proof.Reserve >= entangled,
+		VerifiedTime:  proof.Verified.Unix(),
+	}
+	if verifyErr != nil {
+		result.Error = verifyErr.Error()
+	}
+	return result
+}
+
+// parseEntangleType returns the entangle transaction type of the chain of the
+// passed name as used by the entangle RPCs.
+func parseEntangleType(name string) (cross.ExpandedTxType, bool) {
+	switch name {
+	case "doge":
+		return cross.ExpandedTxEntangle_Doge, true
+	case "ltc":
+		return cross.ExpandedTxEntangle_Ltc, true
+	}
+	return 0, false
 }
 
 func handleGetWork(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
@@ -2425,6 +3570,9 @@ func handleGetWork(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (in
 
 // handleGetMempoolInfo implements the getmempoolinfo command.
 func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// The sequence is loaded first so it never accounts for changes the
+	// returned details miss.
+	sequence := s.cfg.TxMemPool.Sequence()
 	mempoolTxns := s.cfg.TxMemPool.TxDescs()
 
 	var numBytes int64
@@ -2433,8 +3581,9 @@ func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct
 	}
 
 	ret := &btcjson.GetMempoolInfoResult{
-		Size:  int64(len(mempoolTxns)),
-		Bytes: numBytes,
+		Size:     int64(len(mempoolTxns)),
+		Bytes:    numBytes,
+		Sequence: sequence,
 	}
 
 	return ret, nil
@@ -2486,6 +3635,52 @@ func handleGetNetTotals(s *rpcServer, cmd interface{}, closeChan <-chan struct{}
 	return reply, nil
 }
 
+// handleGetNetworkInfo implements the getnetworkinfo command.
+func handleGetNetworkInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	networks := make([]btcjson.NetworksResult, 0, 3)
+	for _, name := range []string{"ipv4", "ipv6", "onion"} {
+		proxy, isolation := networkProxy(name)
+		reachable := name != "onion" || (!cfg.NoOnion && proxy != "")
+		networks = append(networks, btcjson.NetworksResult{
+			Name:                      name,
+			Limited:                   !reachable,
+			Reachable:                 reachable,
+			Proxy:                     proxy,
+			ProxyRandomizeCredentials: isolation,
+		})
+	}
+
+	localAddrs := s.cfg.AddrManager.LocalAddresses()
+	localAddresses := make([]btcjson.LocalAddressesResult, 0, len(localAddrs))
+	for _, la := range localAddrs {
+		host, _, err := net.SplitHostPort(addrmgr.NetAddressKey(la.NetAddress))
+		if err != nil {
+			continue
+		}
+		localAddresses = append(localAddresses, btcjson.LocalAddressesResult{
+			Address: host,
+			Port:    la.NetAddress.Port,
+			Score:   int32(la.Score),
+		})
+	}
+
+	reply := &btcjson.GetNetworkInfoResult{
+		Version:         int32(1000000*version.AppMajor + 10000*version.AppMinor + 100*version.AppPatch),
+		SubVersion:      fmt.Sprintf("%s:%s/", userAgentName, userAgentVersion),
+		ProtocolVersion: int32(maxProtocolVersion),
+		LocalServices:   fmt.Sprintf("%016x", uint64(s.cfg.Services)),
+		LocalRelay:      !cfg.BlocksOnly,
+		TimeOffset:      int64(s.cfg.TimeSource.Offset().Seconds()),
+		Connections:     s.cfg.ConnMgr.ConnectedCount(),
+		NetworkActive:   true,
+		Networks:        networks,
+		RelayFee:        cfg.minRelayTxFee.ToCZZ(),
+		IncrementalFee:  cfg.minRelayTxFee.ToCZZ(),
+		LocalAddresses:  localAddresses,
+	}
+	return reply, nil
+}
+
 // handleGetNetworkHashPS implements the getnetworkhashps command.
 func handleGetNetworkHashPS(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Note: All valid error return paths should return an int64.
@@ -2535,48 +3730,14 @@ func handleGetNetworkHashPS(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	rpcsLog.Debugf("Calculating network hashes per second from %d to %d",
 		startHeight, endHeight)
 
-	// Find the min and max block timestamps as well as calculate the total
-	// amount of work that happened between the start and end blocks.
-	var minTimestamp, maxTimestamp time.Time
-	totalWork := big.NewFloat(0.0)
-	for curHeight := startHeight; curHeight <= endHeight; curHeight++ {
-		hash, err := s.cfg.Chain.BlockHashByHeight(curHeight)
-		if err != nil {
-			context := "Failed to fetch block hash"
-			return nil, internalRPCError(err.Error(), context)
-		}
-
-		// Fetch the header from chain.
-		header, err := s.cfg.Chain.HeaderByHash(hash)
-		if err != nil {
-			context := "Failed to fetch block header"
-			return nil, internalRPCError(err.Error(), context)
-		}
-
-		if curHeight == startHeight {
-			minTimestamp = header.Timestamp
-			maxTimestamp = minTimestamp
-		} else {
-			totalWork.Add(totalWork, new(big.Float).SetInt(blockchain.CalcWork(header.Bits)))
-
-			if minTimestamp.After(header.Timestamp) {
-				minTimestamp = header.Timestamp
-			}
-			if maxTimestamp.Before(header.Timestamp) {
-				maxTimestamp = header.Timestamp
-			}
-		}
-	}
-
-	// Calculate the difference in seconds between the min and max block
-	// timestamps and avoid division by zero in the case where there is no
-	// time difference.
-	timeDiff := int64(maxTimestamp.Sub(minTimestamp) / time.Second)
-	if timeDiff == 0 {
-		return float64(0), nil
+	// The work of the blocks after the starting block over the time span
+	// of all of them is the estimate, taken from the block index.
+	hashesPerSec, err := s.cfg.Chain.EstimateHashRate(endHeight,
+		endHeight-startHeight)
+	if err != nil {
+		context := "Failed to estimate the hashrate"
+		return nil, internalRPCError(err.Error(), context)
 	}
-
-	hashesPerSec, _ := new(big.Float).Quo(totalWork, new(big.Float).SetInt64(timeDiff)).Float64()
 	return hashesPerSec, nil
 }
 
@@ -2584,32 +3745,70 @@ func handleGetNetworkHashPS(s *rpcServer, cmd interface{}, closeChan <-chan stru
 func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	peers := s.cfg.ConnMgr.ConnectedPeers()
 	syncPeerID := s.cfg.SyncMgr.SyncPeerID()
+	syncStates := s.cfg.SyncMgr.PeerSyncStates()
 	infos := make([]*btcjson.GetPeerInfoResult, 0, len(peers))
 	for _, p := range peers {
 		statsSnap := p.ToPeer().StatsSnapshot()
+		syncState := syncStates[statsSnap.ID]
+		inFlight := make([]string, 0, len(syncState.InFlightBlocks))
+		for _, hash := range syncState.InFlightBlocks {
+			inFlight = append(inFlight, hash.String())
+		}
+
+		// The common height is the height of the last block announced by
+		// the peer which is also in our main chain.
+		commonHeight := int32(-1)
+		if hash := p.ToPeer().LastAnnouncedBlock(); hash != nil &&
+			s.cfg.Chain.MainChainHasBlock(hash) {
+
+			if height, err := s.cfg.Chain.BlockHeightByHash(hash); err == nil {
+				commonHeight = height
+			}
+		}
+
+		// Bandwidth is averaged over the lifetime of the connection.
+		var bytesSentPerSec, bytesRecvPerSec float64
+		if secs := time.Since(statsSnap.ConnTime).Seconds(); secs > 0 {
+			bytesSentPerSec = float64(statsSnap.BytesSent) / secs
+			bytesRecvPerSec = float64(statsSnap.BytesRecv) / secs
+		}
+
 		info := &btcjson.GetPeerInfoResult{
-			ID:             statsSnap.ID,
-			Addr:           statsSnap.Addr,
-			AddrLocal:      p.ToPeer().LocalAddr().String(),
-			Services:       fmt.Sprintf("%08d", uint64(statsSnap.Services)),
-			ServicesStr:    statsSnap.Services.String(),
-			RelayTxes:      !p.IsTxRelayDisabled(),
-			LastSend:       statsSnap.LastSend.Unix(),
-			LastRecv:       statsSnap.LastRecv.Unix(),
-			BytesSent:      statsSnap.BytesSent,
-			BytesRecv:      statsSnap.BytesRecv,
-			ConnTime:       statsSnap.ConnTime.Unix(),
-			PingTime:       float64(statsSnap.LastPingMicros),
-			TimeOffset:     statsSnap.TimeOffset,
-			Version:        statsSnap.Version,
-			SubVer:         statsSnap.UserAgent,
-			Inbound:        statsSnap.Inbound,
-			StartingHeight: statsSnap.StartingHeight,
-			CurrentHeight:  statsSnap.LastBlock,
-			BanScore:       int32(p.BanScore()),
-			Whitelisted:    p.IsWhitelisted(),
-			FeeFilter:      p.FeeFilter(),
-			SyncNode:       statsSnap.ID == syncPeerID,
+			ID:              statsSnap.ID,
+			Addr:            statsSnap.Addr,
+			AddrLocal:       p.ToPeer().LocalAddr().String(),
+			Services:        fmt.Sprintf("%08d", uint64(statsSnap.Services)),
+			ServicesStr:     statsSnap.Services.String(),
+			RelayTxes:       !p.IsTxRelayDisabled(),
+			LastSend:        statsSnap.LastSend.Unix(),
+			LastRecv:        statsSnap.LastRecv.Unix(),
+			BytesSent:       statsSnap.BytesSent,
+			BytesRecv:       statsSnap.BytesRecv,
+			BytesSentPerMsg: statsSnap.BytesSentPerMsg,
+			BytesRecvPerMsg: statsSnap.BytesRecvPerMsg,
+			ConnTime:        statsSnap.ConnTime.Unix(),
+			PingTime:        float64(statsSnap.LastPingMicros),
+			TimeOffset:      statsSnap.TimeOffset,
+			Version:         statsSnap.Version,
+			SubVer:          statsSnap.UserAgent,
+			Inbound:         statsSnap.Inbound,
+			StartingHeight:  statsSnap.StartingHeight,
+			CurrentHeight:   statsSnap.LastBlock,
+			BanScore:        int32(p.BanScore()),
+			Whitelisted:     p.IsWhitelisted(),
+			FeeFilter:       p.FeeFilter(),
+			SyncNode:        statsSnap.ID == syncPeerID,
+			SyncCandidate:   syncState.SyncCandidate,
+			CommonHeight:    commonHeight,
+			InFlight:        inFlight,
+			SendHeaders:     p.ToPeer().WantsHeaders(),
+			CompactBlocks:   p.ToPeer().WantsCompactBlocks(),
+			Bip152HBTo:      p.IsDirectRelay(),
+			Bip152HBFrom:    p.ToPeer().WantsDirectBlockRelay(),
+			BytesSentPerSec: bytesSentPerSec,
+			BytesRecvPerSec: bytesRecvPerSec,
+			Permissions:     p.Permissions().Names(),
+			Features:        statsSnap.Features.Names(),
 		}
 		if p.ToPeer().LastPingNonce() != 0 {
 			wait := float64(time.Since(statsSnap.LastPingTime).Nanoseconds())
@@ -2681,6 +3880,9 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan str
 		if blockRegion == nil {
 			return nil, rpcNoTxInfoError(txHash)
 		}
+		if err := rpcPrunedTxError(s, txHash, blockRegion.Hash); err != nil {
+			return nil, err
+		}
 
 		// Load the raw transaction bytes from the database.
 		var txBytes []byte
@@ -2729,35 +3931,285 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan str
 			if err != nil {
 				return nil, err
 			}
-			return mtxHex, nil
+			return mtxHex, nil
+		}
+
+		mtx = tx.MsgTx()
+	}
+
+	// The verbose flag is set, so generate the JSON object and return it.
+	var blkHeader *wire.BlockHeader
+	var blkHashStr string
+	var chainHeight int32
+	if blkHash != nil {
+		// Fetch the header from chain.
+		header, err := s.cfg.Chain.HeaderByHash(blkHash)
+		if err != nil {
+			context := "Failed to fetch block header"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		blkHeader = &header
+		blkHashStr = blkHash.String()
+		chainHeight = s.cfg.Chain.BestSnapshot().Height
+	}
+
+	rawTxn, err := createTxRawResult(s.cfg.ChainParams, mtx, txHash.String(),
+		blkHeader, blkHashStr, blkHeight, chainHeight)
+	if err != nil {
+		return nil, err
+	}
+	return *rawTxn, nil
+}
+
+// handleGetRawTransactions implements the getrawtransactions command.
+func handleGetRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetRawTransactionsCmd)
+
+	if len(c.Txids) > maxRawTransactionsPerRequest {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("At most %d transactions can be "+
+				"requested at once", maxRawTransactionsPerRequest),
+		}
+	}
+	txHashes := make([]*chainhash.Hash, 0, len(c.Txids))
+	for _, txid := range c.Txids {
+		txHash, err := chainhash.NewHashFromStr(txid)
+		if err != nil {
+			return nil, rpcDecodeHexError(txid)
+		}
+		txHashes = append(txHashes, txHash)
+	}
+	if s.cfg.TxIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCNoTxInfo,
+			Message: "The transaction index must be enabled to " +
+				"query the blockchain (specify --txindex)",
+		}
+	}
+
+	// Fetch the transactions from the memory pool or the block database.
+	// The transactions which are not available are reported as missing
+	// rather than failing the whole request.
+	result := &btcjson.GetRawTransactionsResult{
+		Transactions: make([]btcjson.SearchRawTransactionsResult, 0,
+			len(txHashes)),
+		Missing: []string{},
+	}
+	txns := make([]*wire.MsgTx, 0, len(txHashes))
+	blkHashes := make([]*chainhash.Hash, 0, len(txHashes))
+	for _, txHash := range txHashes {
+		mtx, blkHash, err := fetchTxByHash(s, txHash)
+		if err != nil {
+			return nil, err
+		}
+		if mtx == nil {
+			result.Missing = append(result.Missing, txHash.String())
+			continue
+		}
+		txns = append(txns, mtx)
+		blkHashes = append(blkHashes, blkHash)
+	}
+
+	// Look up the outputs spent by all of the transactions at once so the
+	// transactions they are spent from are only loaded once.
+	originOutputs, err := fetchBulkInputTxos(s, txns)
+	if err != nil {
+		return nil, err
+	}
+
+	// The transactions of a rescan are usually in the same blocks, so
+	// the headers and heights of the blocks are only looked up once.
+	type blockInfo struct {
+		header wire.BlockHeader
+		height int32
+	}
+	blocks := make(map[chainhash.Hash]*blockInfo)
+	best := s.cfg.Chain.BestSnapshot()
+	params := s.cfg.ChainParams
+	for i, mtx := range txns {
+		mtxHex, err := messageToHex(mtx)
+		if err != nil {
+			return nil, err
+		}
+		txResult := btcjson.SearchRawTransactionsResult{
+			Hex:      mtxHex,
+			Txid:     mtx.TxHash().String(),
+			Vin:      vinListPrevOut(mtx, params, originOutputs, true, nil),
+			Vout:     createVoutList(mtx, params, nil),
+			Version:  mtx.Version,
+			LockTime: mtx.LockTime,
+		}
+
+		// Transactions of the memory pool have no block information.
+		blkHash := blkHashes[i]
+		if blkHash == nil {
+			result.Transactions = append(result.Transactions, txResult)
+			continue
+		}
+		block, ok := blocks[*blkHash]
+		if !ok {
+			header, err := s.cfg.Chain.HeaderByHash(blkHash)
+			if err != nil {
+				return nil, &btcjson.RPCError{
+					Code:    btcjson.ErrRPCBlockNotFound,
+					Message: "Block not found",
+				}
+			}
+			height, err := s.cfg.Chain.BlockHeightByHash(blkHash)
+			if err != nil {
+				context := "Failed to obtain block height"
+				return nil, internalRPCError(err.Error(), context)
+			}
+			block = &blockInfo{header: header, height: height}
+			blocks[*blkHash] = block
 		}
+		txResult.Time = block.header.Timestamp.Unix()
+		txResult.Blocktime = block.header.Timestamp.Unix()
+		txResult.BlockHash = blkHash.String()
+		txResult.Confirmations = uint64(1 + best.Height - block.height)
+		result.Transactions = append(result.Transactions, txResult)
+	}
 
-		mtx = tx.MsgTx()
+	return result, nil
+}
+
+// handleGetRPCInfo implements the getrpcinfo command.
+func handleGetRPCInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	toMillis := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+
+	methods := s.stats.snapshot()
+	infos := make([]btcjson.RPCMethodInfo, 0, len(methods))
+	for method, stats := range methods {
+		quantiles := stats.quantiles([]float64{0.5, 0.9, 0.99})
+		infos = append(infos, btcjson.RPCMethodInfo{
+			Method:    method,
+			Requests:  stats.requests,
+			Errors:    stats.errors,
+			ErrorRate: float64(stats.errors) / float64(stats.requests),
+			TotalTime: toMillis(stats.duration),
+			AvgTime:   toMillis(stats.duration) / float64(stats.requests),
+			P50Time:   toMillis(quantiles[0]),
+			P90Time:   toMillis(quantiles[1]),
+			P99Time:   toMillis(quantiles[2]),
+		})
 	}
 
-	// The verbose flag is set, so generate the JSON object and return it.
-	var blkHeader *wire.BlockHeader
-	var blkHashStr string
-	var chainHeight int32
-	if blkHash != nil {
-		// Fetch the header from chain.
-		header, err := s.cfg.Chain.HeaderByHash(blkHash)
-		if err != nil {
-			context := "Failed to fetch block header"
-			return nil, internalRPCError(err.Error(), context)
+	// The methods the most time was spent on come first since they are
+	// the ones loading the node the most.
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].TotalTime != infos[j].TotalTime {
+			return infos[i].TotalTime > infos[j].TotalTime
 		}
+		return infos[i].Method < infos[j].Method
+	})
+	return &btcjson.GetRPCInfoResult{
+		SlowLog: int64(cfg.RPCSlowLog / time.Millisecond),
+		Methods: infos,
+	}, nil
+}
 
-		blkHeader = &header
-		blkHashStr = blkHash.String()
-		chainHeight = s.cfg.Chain.BestSnapshot().Height
+// handleGetScriptTypeStats implements the getscripttypestats command.
+func handleGetScriptTypeStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the script type index is not enabled.
+	if s.cfg.ScriptIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Script type index must be enabled (--scripttypeindex)",
+		}
 	}
 
-	rawTxn, err := createTxRawResult(s.cfg.ChainParams, mtx, txHash.String(),
-		blkHeader, blkHashStr, blkHeight, chainHeight)
+	c := cmd.(*btcjson.GetScriptTypeStatsCmd)
+	endHeight := s.cfg.Chain.BestSnapshot().Height
+	if c.EndHeight != nil {
+		endHeight = *c.EndHeight
+	}
+	if c.StartHeight < 0 || endHeight < c.StartHeight ||
+		endHeight > s.cfg.Chain.BestSnapshot().Height {
+
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid block height range",
+		}
+	}
+
+	counts, err := s.cfg.ScriptIndex.Counts(c.StartHeight, endHeight)
 	if err != nil {
-		return nil, err
+		context := "Failed to sum the script type index entries"
+		return nil, internalRPCError(err.Error(), context)
 	}
-	return *rawTxn, nil
+
+	types := make([]btcjson.ScriptTypeStats, 0, len(counts))
+	for i := range counts {
+		count := &counts[i]
+		types = append(types, btcjson.ScriptTypeStats{
+			Type:         indexers.ScriptType(i).String(),
+			Created:      count.Created,
+			CreatedValue: czzutil.Amount(count.CreatedValue).ToCZZ(),
+			Spent:        count.Spent,
+			SpentValue:   czzutil.Amount(count.SpentValue).ToCZZ(),
+		})
+	}
+	return &btcjson.GetScriptTypeStatsResult{
+		StartHeight: c.StartHeight,
+		EndHeight:   endHeight,
+		Types:       types,
+	}, nil
+}
+
+// handleGetSpentInfo handles getspentinfo commands.
+func handleGetSpentInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the spent output index is not enabled.
+	if s.cfg.SpentIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Spent output index must be enabled (--spentindex)",
+		}
+	}
+
+	c := cmd.(*btcjson.GetSpentInfoCmd)
+
+	// Convert the provided transaction hash hex to a Hash.
+	txHash, err := chainhash.NewHashFromStr(c.Txid)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Txid)
+	}
+	op := wire.OutPoint{Hash: *txHash, Index: c.Index}
+
+	// Outputs spent by a transaction in the memory pool are not in the
+	// index yet, so look for the spending transaction there first.
+	if spendingTx := s.cfg.TxMemPool.CheckSpend(op); spendingTx != nil {
+		for i, txIn := range spendingTx.MsgTx().TxIn {
+			if txIn.PreviousOutPoint == op {
+				return &btcjson.GetSpentInfoResult{
+					TxID:   spendingTx.Hash().String(),
+					Index:  uint32(i),
+					Height: -1,
+				}, nil
+			}
+		}
+	}
+
+	info, err := s.cfg.SpentIndex.SpentInfo(&op)
+	if err != nil {
+		context := "Failed to look up spent output"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if info == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCNoTxInfo,
+			Message: fmt.Sprintf("No spending input for %v", op),
+		}
+	}
+
+	return &btcjson.GetSpentInfoResult{
+		TxID:   info.TxHash.String(),
+		Index:  info.Index,
+		Height: info.Height,
+	}, nil
 }
 
 // handleGetTxOut handles gettxout commands.
@@ -3101,10 +4553,22 @@ func handleInvalidateBlock(s *rpcServer, cmd interface{}, closeChan <-chan struc
 
 	hash, err := chainhash.NewHashFromStr(c.BlockHash)
 	if err != nil {
-		return nil, err
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+	if _, err := s.cfg.Chain.HeaderByHash(hash); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
 	}
 
-	return nil, s.cfg.Chain.InvalidateBlock(hash)
+	if err := s.cfg.Chain.InvalidateBlock(hash); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Failed to invalidate block: " + err.Error(),
+		}
+	}
+	return nil, nil
 }
 
 // handleReconsiderBlock implements the reconsiderblock command
@@ -3112,11 +4576,320 @@ func handleReconsiderBlock(s *rpcServer, cmd interface{}, closeChan <-chan struc
 	c := cmd.(*btcjson.ReconsiderBlockCmd)
 
 	hash, err := chainhash.NewHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+	if _, err := s.cfg.Chain.HeaderByHash(hash); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
+
+	if err := s.cfg.Chain.ReconsiderBlock(hash); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Failed to reconsider block: " + err.Error(),
+		}
+	}
+	return nil, nil
+}
+
+// handleReloadConfig implements the reloadconfig command.
+func handleReloadConfig(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	changed, err := s.cfg.ReloadConfig()
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Unable to reload the configuration: " + err.Error(),
+		}
+	}
+	if changed == nil {
+		changed = []string{}
+	}
+	return changed, nil
+}
+
+// utxoScan tracks a utxo set scan started by the scantxoutset command.
+type utxoScan struct {
+	// progress is the position of the scan in the key space of the utxo
+	// set in 1/65536ths.  It must be accessed atomically.
+	progress uint32
+
+	// abort is closed to abort the scan.
+	abort     chan struct{}
+	abortOnce sync.Once
+}
+
+// parseScanObjects returns the output scripts described by the passed scan
+// objects of the scantxoutset command keyed by the script along with the scan
+// object describing them.  The scan objects are output descriptors as parsed by
+// txscript.ParseDescriptor.
+func parseScanObjects(scanObjects []string, params *chaincfg.Params) (map[string]string, error) {
+	scripts := make(map[string]string, len(scanObjects))
+	for _, object := range scanObjects {
+		desc, err := txscript.ParseDescriptor(object, params)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Invalid scan object %q: %v",
+					object, err),
+			}
+		}
+		scripts[string(desc.PkScript())] = object
+	}
+	return scripts, nil
+}
+
+// handleScanTxOutSet implements the scantxoutset command.
+func handleScanTxOutSet(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ScanTxOutSetCmd)
+
+	switch c.Action {
+	case "status":
+		s.utxoScanMtx.Lock()
+		scan := s.utxoScan
+		s.utxoScanMtx.Unlock()
+		if scan == nil {
+			return nil, nil
+		}
+		progress := atomic.LoadUint32(&scan.progress)
+		return &btcjson.ScanTxOutSetStatusResult{
+			Progress: float64(progress) * 100 / 65536,
+		}, nil
+
+	case "abort":
+		s.utxoScanMtx.Lock()
+		scan := s.utxoScan
+		s.utxoScanMtx.Unlock()
+		if scan == nil {
+			return false, nil
+		}
+		scan.abortOnce.Do(func() { close(scan.abort) })
+		return true, nil
+
+	case "start":
+	default:
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid action %q -- must be start, "+
+				"abort or status", c.Action),
+		}
+	}
+
+	if c.ScanObjects == nil || len(*c.ScanObjects) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "The start action requires scan objects",
+		}
+	}
+	scripts, err := parseScanObjects(*c.ScanObjects, s.cfg.ChainParams)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &btcjson.ScanTxOutSetResult{
+		Unspents: []btcjson.ScanTxOutSetUnspent{},
+	}
+	var totalAmount int64
+	height, hash, aborted, err := s.scanUtxoSet(closeChan,
+		func(outpoint *wire.OutPoint, entry *blockchain.UtxoEntry) {
+			result.TxOuts++
+			desc, ok := scripts[string(entry.PkScript())]
+			if !ok {
+				return
+			}
+			result.Unspents = append(result.Unspents, btcjson.ScanTxOutSetUnspent{
+				TxID:         outpoint.Hash.String(),
+				Vout:         outpoint.Index,
+				ScriptPubKey: hex.EncodeToString(entry.PkScript()),
+				Desc:         desc,
+				Amount:       czzutil.Amount(entry.Amount()).ToCZZ(),
+				Height:       entry.BlockHeight(),
+			})
+			totalAmount += entry.Amount()
+		})
 	if err != nil {
 		return nil, err
 	}
+	result.Success = !aborted
+	result.Height = height
+	result.BestBlock = hash.String()
+	result.TotalAmount = czzutil.Amount(totalAmount).ToCZZ()
+	return result, nil
+}
+
+// scanUtxoSet calls the passed function with every output of a snapshot of the
+// utxo set as the running utxo scan, which the scantxoutset command reports the
+// progress of and aborts.  It returns the height and hash of the block the
+// snapshot was taken at and whether the scan was aborted before completing.
+func (s *rpcServer) scanUtxoSet(closeChan <-chan struct{},
+	fn func(outpoint *wire.OutPoint, entry *blockchain.UtxoEntry)) (int32, *chainhash.Hash, bool, error) {
+
+	// Only a single scan may run at a time.
+	scan := &utxoScan{abort: make(chan struct{})}
+	s.utxoScanMtx.Lock()
+	if s.utxoScan != nil {
+		s.utxoScanMtx.Unlock()
+		return 0, nil, false, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "A scan is already in progress",
+		}
+	}
+	s.utxoScan = scan
+	s.utxoScanMtx.Unlock()
+	defer func() {
+		s.utxoScanMtx.Lock()
+		s.utxoScan = nil
+		s.utxoScanMtx.Unlock()
+	}()
+
+	// Scan a snapshot of the utxo set so blocks keep being connected while
+	// the scan runs.
+	snapshot, err := s.cfg.Chain.UtxoSnapshot()
+	if err != nil {
+		context := "Failed to take a snapshot of the utxo set"
+		return 0, nil, false, internalRPCError(err.Error(), context)
+	}
+	defer snapshot.Close()
+
+	// Interrupt the scan when it is aborted, the client disconnects or the
+	// server shuts down.
+	interrupt := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-scan.abort:
+		case <-closeChan:
+		case <-s.quit:
+		case <-done:
+			return
+		}
+		close(interrupt)
+	}()
+
+	err = snapshot.ForEach(func(outpoint *wire.OutPoint, entry *blockchain.UtxoEntry) error {
+		atomic.StoreUint32(&scan.progress, uint32(outpoint.Hash[0])<<8|
+			uint32(outpoint.Hash[1]))
+		fn(outpoint, entry)
+		return nil
+	}, interrupt)
+	select {
+	case <-interrupt:
+		return snapshot.Height(), snapshot.Hash(), true, nil
+	default:
+		if err != nil {
+			context := "Failed to scan the utxo set"
+			return 0, nil, false, internalRPCError(err.Error(), context)
+		}
+	}
+	return snapshot.Height(), snapshot.Hash(), false, nil
+}
+
+// handleGetTxReorgRisk implements the gettxreorgrisk command.
+func handleGetTxReorgRisk(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetTxReorgRiskCmd)
+
+	txHash, err := chainhash.NewHashFromStr(c.Txid)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Txid)
+	}
+	depth := int32(10)
+	if c.Depth != nil {
+		depth = *c.Depth
+	}
+	if depth < 0 || depth > blockchain.MaxReorgRiskDepth {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Depth must be between 0 and %d",
+				blockchain.MaxReorgRiskDepth),
+		}
+	}
+
+	// A transaction of the memory pool is not confirmed yet.
+	result := &btcjson.GetTxReorgRiskResult{
+		TxID:      c.Txid,
+		WorkOnTop: "0",
+		Depth:     depth,
+		Forks:     []btcjson.CompetingForkResult{},
+	}
+	if s.cfg.TxMemPool.IsTransactionInPool(txHash) {
+		result.BlockHeight = -1
+		return result, nil
+	}
+
+	if s.cfg.TxIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCNoTxInfo,
+			Message: "The transaction index must be enabled to " +
+				"query the blockchain (specify --txindex)",
+		}
+	}
+	blockRegion, err := s.cfg.TxIndex.TxBlockRegion(txHash)
+	if err != nil {
+		context := "Failed to retrieve transaction location"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if blockRegion == nil {
+		return nil, rpcNoTxInfoError(txHash)
+	}
+
+	risk, err := s.cfg.Chain.ReorgRisk(blockRegion.Hash, depth)
+	if err != nil {
+		context := "Failed to assess the reorganization risk"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	result.BlockHash = risk.Hash.String()
+	result.BlockHeight = risk.Height
+	result.Confirmations = risk.Confirmations
+	result.WorkOnTop = risk.WorkOnTop.Text(16)
+	result.HasCompetingFork = len(risk.Forks) > 0
+	for _, fork := range risk.Forks {
+		result.Forks = append(result.Forks, btcjson.CompetingForkResult{
+			TipHash:     fork.TipHash.String(),
+			TipHeight:   fork.TipHeight,
+			ForkHeight:  fork.ForkHeight,
+			Work:        fork.Work.Text(16),
+			WorkBehind:  fork.WorkBehind.Text(16),
+			HeadersOnly: fork.HeadersOnly,
+		})
+	}
+	return result, nil
+}
+
+// handleGetUtxoReport implements the getutxoreport command.
+func handleGetUtxoReport(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetUtxoReportCmd)
+
+	minReuse, count := 10, 20
+	if c.MinReuse != nil {
+		minReuse = *c.MinReuse
+	}
+	if c.Count != nil {
+		count = *c.Count
+	}
+	if minReuse < 1 || count < 0 {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "The minimum reuse must be positive and the " +
+				"count can not be negative",
+		}
+	}
 
-	return nil, s.cfg.Chain.ReconsiderBlock(hash)
+	report := newUtxoReport(s.cfg.ChainParams, cfg.minRelayTxFee)
+	height, hash, aborted, err := s.scanUtxoSet(closeChan,
+		func(outpoint *wire.OutPoint, entry *blockchain.UtxoEntry) {
+			report.Add(entry.PkScript(), entry.Amount())
+		})
+	if err != nil {
+		return nil, err
+	}
+	result := report.Result(minReuse, count)
+	result.Success = !aborted
+	result.Height = height
+	result.BestBlock = hash.String()
+	return result, nil
 }
 
 // handleHelp implements the help command.
@@ -3158,6 +4931,21 @@ func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return help, nil
 }
 
+// handleListBanned implements the listbanned command.
+func handleListBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	bans := s.cfg.ConnMgr.BannedSubnets()
+	results := make([]btcjson.ListBannedResult, 0, len(bans))
+	for _, ban := range bans {
+		results = append(results, btcjson.ListBannedResult{
+			Address:     ban.Net.String(),
+			BanCreated:  ban.Created.Unix(),
+			BannedUntil: ban.Until.Unix(),
+			BanReason:   ban.Reason,
+		})
+	}
+	return results, nil
+}
+
 // handlePing implements the ping command.
 func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Ask server to ping \o_
@@ -3217,6 +5005,10 @@ func fetchInputTxos(s *rpcServer, tx *wire.MsgTx) (map[wire.OutPoint]wire.TxOut,
 		if blockRegion == nil {
 			return nil, rpcNoTxInfoError(&origin.Hash)
 		}
+		err = rpcPrunedTxError(s, &origin.Hash, blockRegion.Hash)
+		if err != nil {
+			return nil, err
+		}
 
 		// Load the raw transaction bytes from the database.
 		var txBytes []byte
@@ -3250,39 +5042,143 @@ func fetchInputTxos(s *rpcServer, tx *wire.MsgTx) (map[wire.OutPoint]wire.TxOut,
 	return originOutputs, nil
 }
 
+// fetchTxByHash fetches the transaction with the passed hash from the memory
+// pool or, using the transaction index, from the block database along with the
+// hash of its block.  The block hash is nil for the transactions of the memory
+// pool and the transaction is nil when it is not available, for instance
+// because its block was pruned.
+func fetchTxByHash(s *rpcServer, txHash *chainhash.Hash) (*wire.MsgTx, *chainhash.Hash, error) {
+	tx, err := s.cfg.TxMemPool.FetchTransaction(txHash)
+	if err == nil {
+		return tx.MsgTx(), nil, nil
+	}
+
+	// Look up the location of the transaction.
+	blockRegion, err := s.cfg.TxIndex.TxBlockRegion(txHash)
+	if err != nil {
+		context := "Failed to retrieve transaction location"
+		return nil, nil, internalRPCError(err.Error(), context)
+	}
+	if blockRegion == nil || rpcPrunedTxError(s, txHash, blockRegion.Hash) != nil {
+		return nil, nil, nil
+	}
+
+	// Load the raw transaction bytes from the database.
+	var txBytes []byte
+	err = s.cfg.DB.View(func(dbTx database.Tx) error {
+		var err error
+		txBytes, err = dbTx.FetchBlockRegion(blockRegion)
+		return err
+	})
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	var msgTx wire.MsgTx
+	err = msgTx.Deserialize(bytes.NewReader(txBytes))
+	if err != nil {
+		context := "Failed to deserialize transaction"
+		return nil, nil, internalRPCError(err.Error(), context)
+	}
+	return &msgTx, blockRegion.Hash, nil
+}
+
+// fetchBulkInputTxos fetches the outputs spent by the inputs of all of the
+// passed transactions.  The outputs are looked up in the passed transactions
+// and the unspent transaction output set first, and the transactions they are
+// spent from are only fetched once from the memory pool or the transaction
+// index otherwise.  The outputs which are not available are left out.
+func fetchBulkInputTxos(s *rpcServer, txns []*wire.MsgTx) (map[wire.OutPoint]wire.TxOut, error) {
+	originTxns := make(map[chainhash.Hash]*wire.MsgTx, len(txns))
+	for _, mtx := range txns {
+		originTxns[mtx.TxHash()] = mtx
+	}
+
+	originOutputs := make(map[wire.OutPoint]wire.TxOut)
+	for _, mtx := range txns {
+		if blockchain.IsCoinBaseTx(mtx) {
+			continue
+		}
+		for _, txIn := range mtx.TxIn {
+			origin := txIn.PreviousOutPoint
+			if _, ok := originOutputs[origin]; ok {
+				continue
+			}
+
+			originTx, ok := originTxns[origin.Hash]
+			if !ok {
+				entry, err := s.cfg.Chain.FetchUtxoEntry(origin)
+				if err != nil {
+					context := "Failed to fetch unspent output"
+					return nil, internalRPCError(err.Error(), context)
+				}
+				if entry != nil && !entry.IsSpent() {
+					originOutputs[origin] = wire.TxOut{
+						Value:    entry.Amount(),
+						PkScript: entry.PkScript(),
+					}
+					continue
+				}
+
+				// The transactions which are not available are
+				// remembered as well so they are only looked up
+				// once.
+				originTx, _, err = fetchTxByHash(s, &origin.Hash)
+				if err != nil {
+					return nil, err
+				}
+				originTxns[origin.Hash] = originTx
+			}
+			if originTx == nil || origin.Index >= uint32(len(originTx.TxOut)) {
+				continue
+			}
+			originOutputs[origin] = *originTx.TxOut[origin.Index]
+		}
+	}
+
+	return originOutputs, nil
+}
+
 // createVinListPrevOut returns a slice of JSON objects for the inputs of the
 // passed transaction.
 func createVinListPrevOut(s *rpcServer, mtx *wire.MsgTx, chainParams *chaincfg.Params, vinExtra bool, filterAddrMap map[string]struct{}) ([]btcjson.VinPrevOut, error) {
+	// Lookup all of the referenced transaction outputs needed to populate
+	// the previous output information if requested.
+	var originOutputs map[wire.OutPoint]wire.TxOut
+	if !blockchain.IsCoinBaseTx(mtx) && (vinExtra || len(filterAddrMap) > 0) {
+		var err error
+		originOutputs, err = fetchInputTxos(s, mtx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return vinListPrevOut(mtx, chainParams, originOutputs, vinExtra,
+		filterAddrMap), nil
+}
+
+// vinListPrevOut returns a slice of JSON objects for the inputs of the passed
+// transaction with the previous output information from the passed outputs.
+func vinListPrevOut(mtx *wire.MsgTx, chainParams *chaincfg.Params, originOutputs map[wire.OutPoint]wire.TxOut, vinExtra bool, filterAddrMap map[string]struct{}) []btcjson.VinPrevOut {
 	// Coinbase transactions only have a single txin by definition.
 	if blockchain.IsCoinBaseTx(mtx) {
 		// Only include the transaction if the filter map is empty
 		// because a coinbase input has no addresses and so would never
 		// match a non-empty filter.
 		if len(filterAddrMap) != 0 {
-			return nil, nil
+			return nil
 		}
 
 		txIn := mtx.TxIn[0]
 		vinList := make([]btcjson.VinPrevOut, 1)
 		vinList[0].Coinbase = hex.EncodeToString(txIn.SignatureScript)
 		vinList[0].Sequence = txIn.Sequence
-		return vinList, nil
+		return vinList
 	}
 
 	// Use a dynamically sized list to accommodate the address filter.
 	vinList := make([]btcjson.VinPrevOut, 0, len(mtx.TxIn))
 
-	// Lookup all of the referenced transaction outputs needed to populate
-	// the previous output information if requested.
-	var originOutputs map[wire.OutPoint]wire.TxOut
-	if vinExtra || len(filterAddrMap) > 0 {
-		var err error
-		originOutputs, err = fetchInputTxos(s, mtx)
-		if err != nil {
-			return nil, err
-		}
-	}
-
 	for _, txIn := range mtx.TxIn {
 		// The disassembled string will contain [error] inline
 		// if the script doesn't fully parse, so ignore the
@@ -3364,7 +5260,7 @@ func createVinListPrevOut(s *rpcServer, mtx *wire.MsgTx, chainParams *chaincfg.P
 		}
 	}
 
-	return vinList, nil
+	return vinList
 }
 
 // fetchMempoolTxnsForAddress queries the address index for all unconfirmed
@@ -3388,75 +5284,11 @@ func fetchMempoolTxnsForAddress(s *rpcServer, addr czzutil.Address, numToSkip, n
 	return mpTxns[numToSkip:rangeEnd], numToSkip
 }
 
-// handleSearchRawTransactions implements the searchrawtransactions command.
-func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	// Respond with an error if the address index is not enabled.
-	addrIndex := s.cfg.AddrIndex
-	if addrIndex == nil {
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCMisc,
-			Message: "Address index must be enabled (--addrindex)",
-		}
-	}
-
-	// Override the flag for including extra previous output information in
-	// each input if needed.
-	c := cmd.(*btcjson.SearchRawTransactionsCmd)
-	vinExtra := false
-	if c.VinExtra != nil {
-		vinExtra = *c.VinExtra != 0
-	}
-
-	// Including the extra previous output information requires the
-	// transaction index.  Currently the address index relies on the
-	// transaction index, so this check is redundant, but it's better to be
-	// safe in case the address index is ever changed to not rely on it.
-	if vinExtra && s.cfg.TxIndex == nil {
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCMisc,
-			Message: "Transaction index must be enabled (--txindex)",
-		}
-	}
-
-	// Attempt to decode the supplied address.
-	params := s.cfg.ChainParams
-	addr, err := czzutil.DecodeAddress(c.Address, params)
-	if err != nil {
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCInvalidAddressOrKey,
-			Message: "Invalid address or key: " + err.Error(),
-		}
-	}
-
-	// Override the default number of requested entries if needed.  Also,
-	// just return now if the number of requested entries is zero to avoid
-	// extra work.
-	numRequested := 100
-	if c.Count != nil {
-		numRequested = *c.Count
-		if numRequested < 0 {
-			numRequested = 1
-		}
-	}
-	if numRequested == 0 {
-		return nil, nil
-	}
-
-	// Override the default number of entries to skip if needed.
-	var numToSkip int
-	if c.Skip != nil {
-		numToSkip = *c.Skip
-		if numToSkip < 0 {
-			numToSkip = 0
-		}
-	}
-
-	// Override the reverse flag if needed.
-	var reverse bool
-	if c.Reverse != nil {
-		reverse = *c.Reverse
-	}
-
+// fetchAddressTxns queries the address index and the mempool for the
+// transactions involving the provided address.  Confirmed transactions are
+// returned before unconfirmed ones, or after them when reverse is set, and the
+// results are limited by the number to skip and the number requested.
+func fetchAddressTxns(s *rpcServer, addr czzutil.Address, numToSkip, numRequested int, reverse bool) ([]retrievedTx, error) {
 	// Add transactions from mempool first if client asked for reverse
 	// order.  Otherwise, they will be added last (as needed depending on
 	// the requested counts).
@@ -3481,8 +5313,8 @@ func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan
 	// Fetch transactions from the database in the desired order if more are
 	// needed.
 	if len(addressTxns) < numRequested {
-		err = s.cfg.DB.View(func(dbTx database.Tx) error {
-			regions, dbSkipped, err := addrIndex.TxRegionsForAddress(
+		err := s.cfg.DB.View(func(dbTx database.Tx) error {
+			regions, dbSkipped, err := s.cfg.AddrIndex.TxRegionsForAddress(
 				dbTx, addr, uint32(numToSkip)-numSkipped,
 				uint32(numRequested-len(addressTxns)), reverse)
 			if err != nil {
@@ -3515,7 +5347,6 @@ func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan
 			context := "Failed to load address index entries"
 			return nil, internalRPCError(err.Error(), context)
 		}
-
 	}
 
 	// Add transactions from mempool last if client did not request reverse
@@ -3524,15 +5355,92 @@ func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan
 		// Transactions in the mempool are not in a block header yet,
 		// so the block header field in the retieved transaction struct
 		// is left nil.
-		mpTxns, mpSkipped := fetchMempoolTxnsForAddress(s, addr,
+		mpTxns, _ := fetchMempoolTxnsForAddress(s, addr,
 			uint32(numToSkip)-numSkipped, uint32(numRequested-
 				len(addressTxns)))
-		numSkipped += mpSkipped
 		for _, tx := range mpTxns {
 			addressTxns = append(addressTxns, retrievedTx{tx: tx})
 		}
 	}
 
+	return addressTxns, nil
+}
+
+// handleSearchRawTransactions implements the searchrawtransactions command.
+func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the address index is not enabled.
+	addrIndex := s.cfg.AddrIndex
+	if addrIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Address index must be enabled (--addrindex)",
+		}
+	}
+
+	// Override the flag for including extra previous output information in
+	// each input if needed.
+	c := cmd.(*btcjson.SearchRawTransactionsCmd)
+	vinExtra := false
+	if c.VinExtra != nil {
+		vinExtra = *c.VinExtra != 0
+	}
+
+	// Including the extra previous output information requires the
+	// transaction index.  Currently the address index relies on the
+	// transaction index, so this check is redundant, but it's better to be
+	// safe in case the address index is ever changed to not rely on it.
+	if vinExtra && s.cfg.TxIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Transaction index must be enabled (--txindex)",
+		}
+	}
+
+	// Attempt to decode the supplied address.
+	params := s.cfg.ChainParams
+	addr, err := czzutil.DecodeAddress(c.Address, params)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: " + err.Error(),
+		}
+	}
+
+	// Override the default number of requested entries if needed.  Also,
+	// just return now if the number of requested entries is zero to avoid
+	// extra work.
+	numRequested := 100
+	if c.Count != nil {
+		numRequested = *c.Count
+		if numRequested < 0 {
+			numRequested = 1
+		}
+	}
+	if numRequested == 0 {
+		return nil, nil
+	}
+
+	// Override the default number of entries to skip if needed.
+	var numToSkip int
+	if c.Skip != nil {
+		numToSkip = *c.Skip
+		if numToSkip < 0 {
+			numToSkip = 0
+		}
+	}
+
+	// Override the reverse flag if needed.
+	var reverse bool
+	if c.Reverse != nil {
+		reverse = *c.Reverse
+	}
+
+	addressTxns, err := fetchAddressTxns(s, addr, numToSkip, numRequested,
+		reverse)
+	if err != nil {
+		return nil, err
+	}
+
 	// Address has never been used if neither source yielded any results.
 	if len(addressTxns) == 0 {
 		return nil, &btcjson.RPCError{
@@ -3711,10 +5619,10 @@ func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan st
 		return nil, internalRPCError(errStr, "")
 	}
 
-	// Generate and relay inventory vectors for all newly accepted
+	// Generate and diffuse inventory vectors for all newly accepted
 	// transactions into the memory pool due to the original being
 	// accepted.
-	s.cfg.ConnMgr.RelayTransactions(acceptedTxs)
+	s.cfg.ConnMgr.DiffuseTransactions(acceptedTxs)
 
 	// Notify both websocket and getblocktemplate long poll clients of all
 	// newly accepted transactions.
@@ -3729,6 +5637,60 @@ func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan st
 	return tx.Hash().String(), nil
 }
 
+// handleSetBan implements the setban command.
+func handleSetBan(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetBanCmd)
+
+	subnet, err := connmgr.ParseBanSubnet(c.SubNet)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: err.Error(),
+		}
+	}
+
+	switch c.SubCmd {
+	case btcjson.SBAdd:
+		// The ban time is the number of seconds to ban the subnet for
+		// unless it is absolute, in which case it is the unix time the
+		// ban expires.  The configured ban duration is used when it is
+		// not set.
+		until := time.Now().Add(cfg.BanDuration)
+		if c.BanTime != nil && *c.BanTime != 0 {
+			if c.Absolute != nil && *c.Absolute {
+				until = time.Unix(*c.BanTime, 0)
+			} else {
+				until = time.Now().Add(time.Duration(*c.BanTime) *
+					time.Second)
+			}
+		}
+		if !until.After(time.Now()) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "ban time is in the past",
+			}
+		}
+		s.cfg.ConnMgr.BanSubnet(subnet, until, "manually added")
+
+	case btcjson.SBRemove:
+		if !s.cfg.ConnMgr.UnbanSubnet(subnet) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "subnet is not banned",
+			}
+		}
+
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "invalid subcommand for setban",
+		}
+	}
+
+	// no data returned unless an error.
+	return nil, nil
+}
+
 // handleSetGenerate implements the setgenerate command.
 func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.SetGenerateCmd)
@@ -3765,6 +5727,39 @@ func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return nil, nil
 }
 
+// handleSetLogLevel implements the setloglevel command.
+func handleSetLogLevel(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetLogLevelCmd)
+
+	if !validLogLevel(c.Level) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid log level %q -- valid levels "+
+				"are trace, debug, info, warn, error and critical",
+				c.Level),
+		}
+	}
+
+	subsystem := "all subsystems"
+	if c.Subsystem == nil {
+		setLogLevels(c.Level)
+	} else {
+		subsystem = *c.Subsystem
+		if _, ok := subsystemLoggers[subsystem]; !ok {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Invalid subsystem %q -- "+
+					"supported subsystems %v", subsystem,
+					supportedSubsystems()),
+			}
+		}
+		setLogLevel(subsystem, c.Level)
+	}
+	rpcsLog.Infof("Log level of %s set to %s", subsystem, c.Level)
+
+	return logLevels(), nil
+}
+
 // handleStop implements the stop command.
 func handleStop(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	select {
@@ -3868,6 +5863,17 @@ func handleValidateAddress(s *rpcServer, cmd interface{}, closeChan <-chan struc
 	result.Address = addr.EncodeAddress()
 	result.IsValid = true
 
+	// Report both encodings of hash based addresses so callers can
+	// migrate between the legacy and cashaddr formats.
+	switch addr.(type) {
+	case *czzutil.AddressPubKeyHash, *czzutil.AddressScriptHash,
+		*czzutil.LegacyAddressPubKeyHash, *czzutil.LegacyAddressScriptHash:
+
+		params := s.cfg.ChainParams
+		result.CashAddress = txscript.ToCashAddress(addr, params).EncodeAddress()
+		result.LegacyAddress = txscript.ToLegacyAddress(addr, params).EncodeAddress()
+	}
+
 	return result, nil
 }
 
@@ -3928,6 +5934,48 @@ func handleVerifyChain(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return err == nil, nil
 }
 
+// handleVerifyIndexes implements the verifyindexes command.
+func handleVerifyIndexes(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if s.cfg.IndexManager == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "No optional indexes are enabled",
+		}
+	}
+
+	c := cmd.(*btcjson.VerifyIndexesCmd)
+	var startHeight, step int32
+	endHeight := int32(-1)
+	if c.StartHeight != nil {
+		startHeight = *c.StartHeight
+	}
+	if c.EndHeight != nil {
+		endHeight = *c.EndHeight
+	}
+	if c.Step != nil {
+		step = *c.Step
+	}
+	repair := c.Repair != nil && *c.Repair
+
+	results, err := s.cfg.IndexManager.VerifyIndexes(startHeight,
+		endHeight, step, repair, closeChan)
+	if err != nil {
+		context := "Failed to verify indexes"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	reply := make([]btcjson.VerifyIndexesResult, 0, len(results))
+	for _, result := range results {
+		reply = append(reply, btcjson.VerifyIndexesResult{
+			Name:          result.Name,
+			BlocksChecked: result.BlocksChecked,
+			Divergent:     result.Divergent,
+			Repaired:      result.Repaired,
+		})
+	}
+	return reply, nil
+}
+
 // handleVerifyMessage implements the verifymessage command.
 func handleVerifyMessage(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.VerifyMessageCmd)
@@ -3942,7 +5990,9 @@ func handleVerifyMessage(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 		}
 	}
 
-	// Only P2PKH addresses are valid for signing.
+	// Only P2PKH addresses are valid for signing.  Legacy addresses are
+	// compared in their cashaddr form below.
+	addr = txscript.ToCashAddress(addr, params)
 	if _, ok := addr.(*czzutil.AddressPubKeyHash); !ok {
 		return nil, &btcjson.RPCError{
 			Code:    btcjson.ErrRPCType,
@@ -3988,7 +6038,7 @@ func handleVerifyMessage(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 	}
 
 	// Return boolean if addresses match.
-	return address.EncodeAddress() == c.Address, nil
+	return address.AddressPubKeyHash().EncodeAddress() == addr.EncodeAddress(), nil
 }
 
 // handleVersion implements the version command.
@@ -4022,6 +6072,15 @@ type rpcServer struct {
 	helpCacher             *helpCacher
 	requestProcessShutdown chan struct{}
 	quit                   chan int
+
+	// utxoScanMtx protects utxoScan, which is the utxo set scan started by
+	// the scantxoutset command that is currently running, if any.
+	utxoScanMtx sync.Mutex
+	utxoScan    *utxoScan
+
+	// stats keeps the statistics of the requests served by the standard
+	// handlers, which are exported by the metrics server.
+	stats rpcStats
 }
 
 // httpStatusLine returns a response Status-Line (RFC 2616 Section 6.1)
@@ -4208,6 +6267,9 @@ type parsedRPCCmd struct {
 	method string
 	cmd    interface{}
 	err    *btcjson.RPCError
+
+	// caller is the address of the client which sent the request.
+	caller string
 }
 
 // standardCmdResult checks that a parsed command is a standard Bitcoin JSON-RPC
@@ -4232,7 +6294,44 @@ func (s *rpcServer) standardCmdResult(cmd *parsedRPCCmd, closeChan <-chan struct
 	return nil, btcjson.ErrRPCMethodNotFound
 handled:
 
-	return handler(s, cmd.cmd, closeChan)
+	start := time.Now()
+	result, err := handler(s, cmd.cmd, closeChan)
+	elapsed := time.Since(start)
+	s.stats.record(cmd.method, elapsed, err != nil)
+	if cfg.RPCSlowLog > 0 && elapsed >= cfg.RPCSlowLog {
+		rpcsLog.Warnf("Slow RPC request %s(%s) from %s took %v",
+			cmd.method, redactedParams(cmd.cmd), cmd.caller,
+			elapsed.Round(time.Millisecond))
+	}
+	return result, err
+}
+
+// redactedParams returns the names of the parameters passed to the passed
+// command for logging.  Their values are left out since they may hold
+// passphrases or private keys, and the optional parameters which were not
+// passed are skipped.
+func redactedParams(cmd interface{}) string {
+	rv := reflect.ValueOf(cmd)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return ""
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return ""
+	}
+
+	rt := rv.Type()
+	params := make([]string, 0, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		if field := rv.Field(i); field.Kind() == reflect.Ptr && field.IsNil() {
+			continue
+		}
+		params = append(params, strings.ToLower(rt.Field(i).Name)+
+			"=<redacted>")
+	}
+	return strings.Join(params, ", ")
 }
 
 // parseCmd parses a JSON-RPC request object into known concrete command.  The
@@ -4385,6 +6484,7 @@ func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin
 			// Attempt to parse the JSON-RPC request into a known concrete
 			// command.
 			parsedCmd := parseCmd(&request)
+			parsedCmd.caller = r.RemoteAddr
 			if parsedCmd.err != nil {
 				jsonErr = parsedCmd.err
 			} else {
@@ -4541,6 +6641,13 @@ type rpcserverPeer interface {
 	// FeeFilter returns the requested current minimum fee rate for which
 	// transactions should be announced.
 	FeeFilter() int64
+
+	// Permissions returns the privileges granted to the peer.
+	Permissions() peer.Permissions
+
+	// IsDirectRelay returns whether the peer was selected to announce new
+	// blocks with compact blocks right away.
+	IsDirectRelay() bool
 }
 
 // rpcserverConnManager represents a connection manager for use with the RPC
@@ -4599,9 +6706,23 @@ type rpcserverConnManager interface {
 	// in a block.
 	AddRebroadcastInventory(iv *wire.InvVect, data interface{})
 
-	// RelayTransactions generates and relays inventory vectors for all of
-	// the passed transactions to all connected peers.
-	RelayTransactions(txns []*mempool.TxDesc)
+	// DiffuseTransactions generates and diffuses inventory vectors for all
+	// of the passed locally submitted transactions to the connected peers.
+	DiffuseTransactions(txns []*mempool.TxDesc)
+
+	// BanSubnet bans the passed subnet until the passed time and
+	// disconnects any connected peers within it.
+	BanSubnet(subnet *net.IPNet, until time.Time, reason string)
+
+	// UnbanSubnet removes the ban of the passed subnet.  It returns
+	// whether the subnet was banned.
+	UnbanSubnet(subnet *net.IPNet) bool
+
+	// BannedSubnets returns the subnets which are currently banned.
+	BannedSubnets() []connmgr.Ban
+
+	// ClearBanned removes all bans.
+	ClearBanned()
 }
 
 // rpcserverSyncManager represents a sync manager for use with the RPC server.
@@ -4624,9 +6745,21 @@ type rpcserverSyncManager interface {
 	// used to sync from or 0 if there is none.
 	SyncPeerID() int32
 
+	// PeerSyncStates returns the sync state of every peer keyed by peer
+	// ID.
+	PeerSyncStates() map[int32]netsync.PeerSyncState
+
 	// SyncHeight returns the block height of the best peer selected to sync from
 	SyncHeight() uint64
 
+	// BlockTimings returns the propagation timings of the most recently
+	// seen blocks.
+	BlockTimings() []netsync.BlockTimings
+
+	// BlockLatencyStats returns the cumulated latencies between the stages
+	// of the propagation of the blocks.
+	BlockLatencyStats() map[netsync.BlockLatency]netsync.LatencyStats
+
 	// LocateHeaders returns the headers of the blocks after the first known
 	// block in the provided locators until the provided stop hash or the
 	// current tip is reached, up to a max of wire.MaxBlockHeadersPerMsg
@@ -4655,6 +6788,12 @@ type rpcserverConfig struct {
 	// SyncMgr defines the sync manager for the RPC server to use.
 	SyncMgr rpcserverSyncManager
 
+	// AddrManager provides the local addresses advertised to peers.
+	AddrManager *addrmgr.AddrManager
+
+	// Services are the services advertised to peers.
+	Services wire.ServiceFlag
+
 	// These fields allow the RPC server to interface with the local block
 	// chain data and state.
 	TimeSource  blockchain.MedianTimeSource
@@ -4674,14 +6813,26 @@ type rpcserverConfig struct {
 	CPUMiner  *cpuminer.CPUMiner
 
 	// These fields define any optional indexes the RPC server can make use
-	// of to provide additional data when queried.
-	TxIndex   *indexers.TxIndex
-	AddrIndex *indexers.AddrIndex
-	CfIndex   *indexers.CfIndex
+	// of to provide additional data when queried.  The index manager
+	// reports the state of the indexes.
+	IndexManager *indexers.Manager
+	TxIndex      *indexers.TxIndex
+	AddrIndex    *indexers.AddrIndex
+	SpentIndex   *indexers.SpentIndex
+	ScriptIndex  *indexers.ScriptTypeIndex
+	CfIndex      *indexers.CfIndex
+
+	// Wallet is the built-in wallet the wallet RPCs are served by.  It is
+	// nil unless the wallet is compiled in and enabled.
+	Wallet *nodeWallet
 
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
 	FeeEstimator *mempool.FeeEstimator
+
+	// ReloadConfig reloads the options which can be changed while the
+	// node is running and returns the names of the changed options.
+	ReloadConfig func() ([]string, error)
 }
 
 // newRPCServer returns a new instance of the rpcServer struct.
@@ -4689,7 +6840,8 @@ func newRPCServer(config *rpcserverConfig) (*rpcServer, error) {
 	rpc := rpcServer{
 		cfg:                    *config,
 		statusLines:            make(map[int]string),
-		gbtWorkState:           newGbtWorkState(config.TimeSource),
+		gbtWorkState:           newGbtWorkState(config.TimeSource,
+			config.ChainParams.MaxTimeOffset),
 		helpCacher:             newHelpCacher(),
 		requestProcessShutdown: make(chan struct{}),
 
@@ -0,0 +1,88 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/btcjson"
+)
+
+// TestEstimateVerificationProgress ensures the verification progress accounts
+// for the transactions of the blocks missing up to the height of the sync peer
+// or since the time of the best block, whichever are more.
+func TestEstimateVerificationProgress(t *testing.T) {
+	const target = 10 * time.Minute
+	tipTime := time.Unix(1560000000, 0)
+
+	// A chain of 100 blocks with 4 transactions each.
+	snapshot := &blockchain.BestState{Height: 99, TotalTxns: 400}
+	tests := []struct {
+		name       string
+		snapshot   *blockchain.BestState
+		syncHeight uint64
+		now        time.Time
+		want       float64
+	}{{
+		name:     "no transactions",
+		snapshot: &blockchain.BestState{},
+		now:      tipTime,
+		want:     0,
+	}, {
+		name:     "synced",
+		snapshot: snapshot,
+		now:      tipTime,
+		want:     1,
+	}, {
+		name:       "behind the sync peer",
+		snapshot:   snapshot,
+		syncHeight: 199,
+		now:        tipTime.Add(target),
+		want:       0.5,
+	}, {
+		name:       "stalled past the sync peer height",
+		snapshot:   snapshot,
+		syncHeight: 99,
+		now:        tipTime.Add(300 * target),
+		want:       0.25,
+	}, {
+		name:     "tip in the future",
+		snapshot: snapshot,
+		now:      tipTime.Add(-target),
+		want:     1,
+	}}
+
+	for _, test := range tests {
+		got := estimateVerificationProgress(test.snapshot,
+			test.syncHeight, tipTime, test.now, target)
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: got progress %v, want %v", test.name, got,
+				test.want)
+		}
+	}
+}
+
+// TestRedactedParams ensures only the names of the parameters passed to a
+// command are logged.
+func TestRedactedParams(t *testing.T) {
+	tests := []struct {
+		cmd  interface{}
+		want string
+	}{
+		{btcjson.NewGetBlockCountCmd(), ""},
+		{btcjson.NewGetRawTransactionCmd("secret", nil), "txid=<redacted>"},
+		{btcjson.NewGetRawTransactionCmd("secret", btcjson.Int(1)),
+			"txid=<redacted>, verbose=<redacted>"},
+		{(*btcjson.GetBlockCountCmd)(nil), ""},
+	}
+	for i, test := range tests {
+		if got := redactedParams(test.cmd); got != test.want {
+			t.Errorf("test #%d: got %q, want %q", i, got, test.want)
+		}
+	}
+}
@@ -34,12 +34,52 @@ var helpDescsEnUS = map[string]string{
 	"addnode-addr":      "IP address and port of the peer to operate on",
 	"addnode-subcmd":    "'add' to add a persistent peer, 'remove' to remove a persistent peer, or 'onetry' to try a single connection to a peer",
 
+	// CaptureProfileCmd help.
+	"captureprofile--synopsis": "Captures a runtime profile of the server for performance diagnostics.\n" +
+		"Cpu profiles and execution traces are captured over the passed duration, the other profiles at once.\n" +
+		"The profile is returned base64 encoded, in the pprof format or the execution trace format for traces.",
+	"captureprofile-type":          "The profile: cpu, trace (execution trace), heap, allocs, goroutine or threadcreate",
+	"captureprofile-seconds":       "The duration in seconds to capture a cpu profile or an execution trace over",
+	"captureprofileresult-type":    "The profile",
+	"captureprofileresult-seconds": "The duration in seconds of the capture of a cpu profile or an execution trace",
+	"captureprofileresult-size":    "The size in bytes of the profile",
+	"captureprofileresult-data":    "The base64 encoded profile",
+
+	// ClearBannedCmd help.
+	"clearbanned--synopsis": "Removes all bans.",
+
+	// CompactDBCmd help.
+	"compactdb--synopsis": "Flushes the database cache and compacts the block database to reclaim the space of overwritten and deleted data.\n" +
+		"This might take a long time for large databases, during which the node keeps running.",
+
 	// NodeCmd help.
 	"node--synopsis":     "Attempts to add or remove a peer.",
 	"node-subcmd":        "'disconnect' to remove all matching non-persistent peers, 'remove' to remove a persistent peer, or 'connect' to connect to a peer",
 	"node-target":        "Either the IP address and port of the peer to operate on, or a valid peer ID.",
 	"node-connectsubcmd": "'perm' to make the connected peer a permanent one, 'temp' to try a single connect to a peer",
 
+	// CreateMultisigCmd help.
+	"createmultisig--synopsis": "Returns the pay-to-script-hash address and the redeem script of a multisig script requiring the passed number of signatures of the passed public keys.\n" +
+		"The keys are used in the passed order, so all participants must pass them in the same order to arrive at the same address.",
+	"createmultisig-nrequired": "The number of signatures required to spend outputs paying to the address",
+	"createmultisig-keys":      "The hex-encoded compressed or uncompressed public keys",
+
+	// CreateMultiSigResult help.
+	"createmultisigresult-address":      "The pay-to-script-hash address",
+	"createmultisigresult-redeemScript": "The hex-encoded redeem script needed to spend outputs paying to the address",
+
+	// CreateVaultCmd help.
+	"createvault--synopsis": "Returns the pay-to-script-hash address and the redeem script of a vault the owner key can spend from once the lock time passed and the recovery key can spend from at any time, such as to claw the coins back.\n" +
+		"Spending with the owner key requires a transaction with a lock time at or past the one of the vault and a non final input sequence number.",
+	"createvault-ownerkey":    "The hex-encoded compressed or uncompressed public key which can spend from the vault once the lock time passed",
+	"createvault-recoverykey": "The hex-encoded compressed or uncompressed public key which can spend from the vault at any time",
+	"createvault-locktime":    "The block height, or unix time from 500000000, until which the owner key can't spend from the vault",
+
+	// CreateVaultResult help.
+	"createvaultresult-address":      "The pay-to-script-hash address",
+	"createvaultresult-redeemScript": "The hex-encoded redeem script needed to spend outputs paying to the address",
+	"createvaultresult-locktime":     "The lock time of the vault",
+
 	// TransactionInput help.
 	"transactioninput-txid": "The hash of the input transaction",
 	"transactioninput-vout": "The specific output of the input transaction to redeem",
@@ -121,6 +161,7 @@ var helpDescsEnUS = map[string]string{
 	"decodescriptresult-reqSigs":   "The number of required signatures",
 	"decodescriptresult-type":      "The type of the script (e.g. 'pubkeyhash')",
 	"decodescriptresult-addresses": "The bitcoin addresses associated with this script",
+	"decodescriptresult-locktime":  "The lock time of the owner spend path of a vault script",
 	"decodescriptresult-p2sh":      "The script hash for use in pay-to-script-hash transactions (only present if the provided redeem script is not already a pay-to-script-hash script)",
 
 	// DecodeScriptCmd help.
@@ -142,6 +183,13 @@ var helpDescsEnUS = map[string]string{
 	"generate-numblocks": "Number of blocks to generate",
 	"generate--result0":  "The hashes, in order, of blocks generated by the call",
 
+	// GenerateToAddressCmd help
+	"generatetoaddress--synopsis": "Generates a set number of blocks paying to the passed address (simnet or regtest only) and returns a JSON\n" +
+		" array of their hashes.",
+	"generatetoaddress-numblocks": "Number of blocks to generate",
+	"generatetoaddress-address":   "The address the coinbase transactions of the blocks pay to",
+	"generatetoaddress--result0":  "The hashes, in order, of blocks generated by the call",
+
 	// GetAddedNodeInfoResultAddr help.
 	"getaddednodeinforesultaddr-address":   "The ip address for this DNS entry",
 	"getaddednodeinforesultaddr-connected": "The connection 'direction' (inbound/outbound/false)",
@@ -159,6 +207,22 @@ var helpDescsEnUS = map[string]string{
 	"getaddednodeinfo--condition1": "dns=true",
 	"getaddednodeinfo--result0":    "List of added peers",
 
+	// GetAddressTxIDsResult help.
+	"getaddresstxidsresult-txid":      "The hash of the transaction",
+	"getaddresstxidsresult-blockhash": "The hash of the block containing the transaction, omitted for unconfirmed transactions",
+	"getaddresstxidsresult-height":    "The height of the block containing the transaction, -1 for unconfirmed transactions",
+	"getaddresstxidsresult-funding":   "Whether or not an output of the transaction pays to the address",
+	"getaddresstxidsresult-spending":  "Whether or not an input of the transaction spends an output paying to the address",
+
+	// GetAddressTxIDsCmd help.
+	"getaddresstxids--synopsis": "Returns the transactions involving the passed address or public key script along with the height they were confirmed at.\n" +
+		"Confirmed transactions are returned in chronological order followed by the transactions currently in the mempool.\n" +
+		"Usage of this RPC requires the optional --addrindex flag to be activated.",
+	"getaddresstxids-address": "The address or hex-encoded public key script to search for",
+	"getaddresstxids-skip":    "The number of leading transactions to leave out of the final response",
+	"getaddresstxids-count":   "The maximum number of transactions to return",
+	"getaddresstxids-reverse": "Specifies that the transactions should be returned in reverse chronological order",
+
 	// GetBestBlockResult help.
 	"getbestblockresult-hash":   "Hex-encoded bytes of the best block hash",
 	"getbestblockresult-height": "Height of the best block",
@@ -192,8 +256,9 @@ var helpDescsEnUS = map[string]string{
 	"getblockchaininforesult-bestblockhash":         "The block hash for the latest block in the main chain",
 	"getblockchaininforesult-difficulty":            "The current chain difficulty",
 	"getblockchaininforesult-mediantime":            "The median time from the PoV of the best block in the chain",
-	"getblockchaininforesult-verificationprogress":  "An estimate for how much of the best chain we've verified",
+	"getblockchaininforesult-verificationprogress":  "An estimate for how much of the transactions of the best chain we've verified, which drops while the sync is stalled",
 	"getblockchaininforesult-syncheight":            "The block height obtained from the best peer",
+	"getblockchaininforesult-size_on_disk":          "The total size in bytes of the stored blocks of the best chain",
 	"getblockchaininforesult-pruned":                "A bool that indicates if the node is pruned or not",
 	"getblockchaininforesult-pruneheight":           "The lowest block retained in the current pruned chain",
 	"getblockchaininforesult-chainwork":             "The total cumulative work in the best chain",
@@ -202,6 +267,7 @@ var helpDescsEnUS = map[string]string{
 	"getblockchaininforesult-bip9_softforks--key":   "bip9_softforks",
 	"getblockchaininforesult-bip9_softforks--value": "An object describing a particular BIP009 deployment",
 	"getblockchaininforesult-bip9_softforks--desc":  "The status of any defined BIP0009 soft-fork deployments",
+	"getblockchaininforesult-sequence":              "The number of times the best block changed since the chain was loaded, which tells caches of the chain state whether they are stale",
 
 	// SoftForkDescription help.
 	"softforkdescription-reject":  "The current activation status of the softfork",
@@ -301,6 +367,7 @@ var helpDescsEnUS = map[string]string{
 	"templaterequest-target":       "The desired target for the block template (this parameter is ignored)",
 	"templaterequest-data":         "Hex-encoded block data (only for mode=proposal)",
 	"templaterequest-workid":       "The server provided workid if provided in block template (not applicable)",
+	"templaterequest-verbose":      "Return the structured result of a proposal rather than the BIP0023 string (only for mode=proposal)",
 
 	// GetBlockTemplateResultTx help.
 	"getblocktemplateresulttx-data":    "Hex-encoded transaction data (byte-for-byte)",
@@ -346,8 +413,80 @@ var helpDescsEnUS = map[string]string{
 	"getblocktemplate--condition0": "mode=template",
 	"getblocktemplate--condition1": "mode=proposal, rejected",
 	"getblocktemplate--condition2": "mode=proposal, accepted",
+	"getblocktemplate--condition3": "mode=proposal, verbose=true",
 	"getblocktemplate--result1":    "An error string which represents why the proposal was rejected or nothing if accepted",
 
+	// BlockProposalResult help.
+	"blockproposalresult-accepted": "Whether the proposed block passed all the consensus rules",
+	"blockproposalresult-reason":   "The BIP0023 reason the proposal was rejected",
+	"blockproposalresult-code":     "The name of the consensus rule the proposal violated",
+	"blockproposalresult-message":  "The description of the violation of the rule",
+
+	// GetBlockTimingsCmd help.
+	"getblocktimings--synopsis": "Returns the times the most recently seen blocks went through the stages of their propagation to the node and their validation, " +
+		"and the average latencies between the stages for the blocks seen since the node started.",
+
+	// GetBlockTimingsResult help.
+	"getblocktimingsresult-blocks":    "The timings of the most recently seen blocks, from the first seen to the last seen one",
+	"getblocktimingsresult-latencies": "The cumulated latencies between the stages of the propagation of the blocks",
+
+	// BlockTimingsResult help.
+	"blocktimingsresult-hash":           "The hash of the block",
+	"blocktimingsresult-height":         "The height of the block, 0 until it is validated",
+	"blocktimingsresult-peer":           "The address of the peer the block was received from",
+	"blocktimingsresult-firstinv":       "When the block was first announced by an inv in milliseconds since the unix epoch, 0 if not observed",
+	"blocktimingsresult-headerreceived": "When the header of the block was received in a compact or graphene block in milliseconds since the unix epoch, 0 if not observed",
+	"blocktimingsresult-blockreceived":  "When the full block was received or reconstructed in milliseconds since the unix epoch, 0 if not observed",
+	"blocktimingsresult-validated":      "When the block was accepted into the block chain in milliseconds since the unix epoch, 0 if not observed",
+	"blocktimingsresult-announced":      "When the block was relayed to the peers in milliseconds since the unix epoch, 0 if not observed",
+
+	// BlockLatencyResult help.
+	"blocklatencyresult-name":      "The stages the latency is measured between (inv_to_block, header_to_block, block_to_validated or validated_to_announced)",
+	"blocklatencyresult-count":     "The number of blocks the latency was observed for",
+	"blocklatencyresult-averagems": "The average latency in milliseconds",
+
+	// GetBlockTxMetaCmd help.
+	"getblocktxmeta--synopsis": "Returns the size, fee and in-block dependencies of the transactions of a main chain block, in the order of the block.\n" +
+		"The metadata is served from the database when the node stores it (--blocktxmeta), and otherwise computed from the block and the outputs it spends, which is not possible for pruned blocks.",
+	"getblocktxmeta-hash": "The hash of the block",
+
+	// GetBlockTxMetaResult help.
+	"getblocktxmetaresult-hash":   "The hash of the block",
+	"getblocktxmetaresult-height": "The height of the block",
+	"getblocktxmetaresult-tx":     "The metadata of the transactions of the block",
+
+	// GetBlockTxMetaResultTx help.
+	"getblocktxmetaresulttx-txid":    "The hash of the transaction",
+	"getblocktxmetaresulttx-size":    "The serialized size of the transaction in bytes",
+	"getblocktxmetaresulttx-fee":     "The fee paid by the transaction in satoshi, 0 for the coinbase",
+	"getblocktxmetaresulttx-feerate": "The fee rate of the transaction in satoshi per kilobyte",
+	"getblocktxmetaresulttx-depends": "The indexes in the block of the transactions whose outputs the transaction spends",
+	"getblocktxmetaresulttx-depth":   "0 if the transaction spends no output created in the block, otherwise 1 more than the greatest depth of the transactions it depends on",
+
+	// GetChainTxStatsCmd help.
+	"getchaintxstats--synopsis": "Returns the transaction, fee and entangle activity of a window of blocks of the main chain.\n" +
+		"The activity of the most recent blocks is recorded as they are connected, so the windows ending near the best block are computed without reading the blocks.",
+	"getchaintxstats-nblocks":   "The number of blocks of the window, at most 20160",
+	"getchaintxstats-blockhash": "The hash of the final block of the window, the best block by default",
+
+	// GetChainTxStatsResult help.
+	"getchaintxstatsresult-time":                      "The timestamp of the final block of the window in seconds since 1 Jan 1970 GMT",
+	"getchaintxstatsresult-window_final_block_hash":   "The hash of the final block of the window",
+	"getchaintxstatsresult-window_final_block_height": "The height of the final block of the window",
+	"getchaintxstatsresult-window_block_count":        "The number of blocks of the window, which stops at the genesis block",
+	"getchaintxstatsresult-window_tx_count":           "The number of transactions of the window, excluding the coinbase transactions",
+	"getchaintxstatsresult-window_interval":           "The time between the final block of the window and the block before its first one in seconds",
+	"getchaintxstatsresult-txrate":                    "The average number of transactions per second over the window",
+	"getchaintxstatsresult-window_tx_size":            "The total size of the transactions of the window in bytes",
+	"getchaintxstatsresult-window_fees":               "The total fee paid by the transactions of the window in satoshi",
+	"getchaintxstatsresult-window_feerate":            "The average fee rate of the transactions of the window in satoshi per kilobyte",
+	"getchaintxstatsresult-window_entangle":           "The entangle outputs of the window for each chain the coins are entangled from",
+
+	// ChainTxStatsEntangleResult help.
+	"chaintxstatsentangleresult-extxtype": "The chain the coins are entangled from (doge or ltc)",
+	"chaintxstatsentangleresult-count":    "The number of entangle outputs",
+	"chaintxstatsentangleresult-amount":   "The total amount of the entangle outputs in the base unit of that chain",
+
 	// GetCFilterCmd help.
 	"getcfilter--synopsis":  "Returns a block's committed filter given its hash.",
 	"getcfilter-filtertype": "The type of filter to return (0=regular)",
@@ -360,6 +499,18 @@ var helpDescsEnUS = map[string]string{
 	"getcfilterheader-hash":       "The hash of the block",
 	"getcfilterheader--result0":   "The block's gcs filter header",
 
+	// GetCheckpointCandidatesCmd help.
+	"getcheckpointcandidates--synopsis": "Returns checkpoint candidates for the blocks of the main chain after the latest checkpoint, to be reviewed before they are added to the chain parameters.\n" +
+		"A candidate is buried by at least depth blocks, has no known fork at its height, timestamps in order with its neighbors and only standard transactions.",
+	"getcheckpointcandidates-depth":    "The number of blocks a candidate must be buried by",
+	"getcheckpointcandidates-interval": "The minimum number of blocks between two candidates, and between the latest checkpoint and the first candidate",
+	"getcheckpointcandidates--result0": "The checkpoint candidates, lowest first",
+
+	// CheckpointCandidateResult help.
+	"checkpointcandidateresult-height":   "The height of the block",
+	"checkpointcandidateresult-hash":     "The hash of the block",
+	"checkpointcandidateresult-gosyntax": "The checkpoint in the Go syntax of the checkpoints of the chain parameters",
+
 	// GetConnectionCountCmd help.
 	"getconnectioncount--synopsis": "Returns the number of active connections to other peers.",
 	"getconnectioncount--result0":  "The number of connections",
@@ -368,10 +519,48 @@ var helpDescsEnUS = map[string]string{
 	"getcurrentnet--synopsis": "Get bitcoin network the server is running on.",
 	"getcurrentnet--result0":  "The network identifer",
 
+	// GetDBStatsCmd help.
+	"getdbstats--synopsis": "Returns statistics about the stores backing the block database.",
+
+	// GetDBStatsResult help.
+	"getdbstatsresult-type":   "The database backend",
+	"getdbstatsresult-stores": "The stores backing the database",
+
+	// DBStoreStats help.
+	"dbstorestats-name":              "The name of the store",
+	"dbstorestats-engine":            "The storage engine of the store (leveldb or flatfiles)",
+	"dbstorestats-files":             "The number of files of the store on disk",
+	"dbstorestats-size":              "The total size of the files of the store in bytes",
+	"dbstorestats-tombstones":        "The number of deletions written since the last compaction",
+	"dbstorestats-sizeamplification": "The estimated ratio of the size on disk to the size of the live data",
+	"dbstorestats-levels":            "The non-empty levels of leveldb stores",
+
+	// DBLevelStats help.
+	"dblevelstats-level":  "The number of the level",
+	"dblevelstats-tables": "The number of tables in the level",
+	"dblevelstats-size":   "The total size of the tables in the level in bytes",
+
 	// GetDifficultyCmd help.
 	"getdifficulty--synopsis": "Returns the proof-of-work difficulty as a multiple of the minimum difficulty.",
 	"getdifficulty--result0":  "The difficulty",
 
+	// GetDifficultyHistoryCmd help.
+	"getdifficultyhistory--synopsis":   "Returns the difficulty of a range of blocks of the main chain along with the network hashrate estimated from the work of the blocks before each of them.",
+	"getdifficultyhistory-startheight": "The height of the first block",
+	"getdifficultyhistory-count":       "The maximum number of blocks, at most 2016",
+	"getdifficultyhistory-window":      "The number of blocks the hashrates are estimated from",
+
+	// DifficultyHistoryResult help.
+	"difficultyhistoryresult-height":       "The height of the block",
+	"difficultyhistoryresult-hash":         "The hash of the block",
+	"difficultyhistoryresult-time":         "The block time in seconds since 1 Jan 1970 GMT",
+	"difficultyhistoryresult-bits":         "The difficulty target of the block in compact form",
+	"difficultyhistoryresult-target":       "The hex-encoded difficulty target of the block",
+	"difficultyhistoryresult-difficulty":   "The difficulty of the block as a multiple of the minimum difficulty",
+	"difficultyhistoryresult-work":         "The hex-encoded expected number of hashes needed to find the block",
+	"difficultyhistoryresult-chainwork":    "The hex-encoded expected number of hashes of the main chain up to and including the block",
+	"difficultyhistoryresult-hashespersec": "The hashrate estimated from the work and time span of the window of blocks ending with the block",
+
 	// GetGenerateCmd help.
 	"getgenerate--synopsis": "Returns if the server is set to generate coins (mine) or not.",
 	"getgenerate--result0":  "True if mining, false if not",
@@ -380,6 +569,17 @@ var helpDescsEnUS = map[string]string{
 	"gethashespersec--synopsis": "Returns a recent hashes per second performance measurement while generating coins (mining).",
 	"gethashespersec--result0":  "The number of hashes per second",
 
+	// GetIndexInfoCmd help.
+	"getindexinfo--synopsis":       "Returns the state of the optional indexes.  Indexes enabled on a synced node are built in the background and become usable once synced.",
+	"getindexinfo-indexname":       "Only return the state of the index with this name",
+	"getindexinfo--result0--desc":  "Index states keyed by the index name",
+	"getindexinfo--result0--key":   "The name of the index",
+	"getindexinfo--result0--value": "Object containing the state of the index",
+
+	// GetIndexInfoResult help.
+	"getindexinforesult-synced":            "Whether or not the index caught up to the main chain",
+	"getindexinforesult-best_block_height": "The height of the last block in the index",
+
 	// InfoChainResult help.
 	"infochainresult-version":         "The version of the server",
 	"infochainresult-protocolversion": "The latest supported protocol version",
@@ -426,8 +626,9 @@ var helpDescsEnUS = map[string]string{
 	"getmempoolinfo--synopsis": "Returns memory pool information",
 
 	// GetMempoolInfoResult help.
-	"getmempoolinforesult-bytes": "Size in bytes of the mempool",
-	"getmempoolinforesult-size":  "Number of transactions in the mempool",
+	"getmempoolinforesult-bytes":    "Size in bytes of the mempool",
+	"getmempoolinforesult-size":     "Number of transactions in the mempool",
+	"getmempoolinforesult-sequence": "The number of times a transaction was added to or removed from the mempool since the daemon started, which tells caches of the mempool whether they are stale",
 
 	// GetMiningInfoResult help.
 	"getmininginforesult-blocks":           "Height of the latest best block",
@@ -459,34 +660,94 @@ var helpDescsEnUS = map[string]string{
 	"getnettotalsresult-totalbytessent": "Total bytes sent",
 	"getnettotalsresult-timemillis":     "Number of milliseconds since 1 Jan 1970 GMT",
 
+	// GetNetworkInfoCmd help.
+	"getnetworkinfo--synopsis": "Returns a JSON object containing network-related information.",
+
+	// GetNetworkInfoResult help.
+	"getnetworkinforesult-version":         "The version of the node as a numeric value",
+	"getnetworkinforesult-subversion":      "The user agent of the node",
+	"getnetworkinforesult-protocolversion": "The latest supported protocol version",
+	"getnetworkinforesult-localservices":   "The services advertised to peers as a hex bitmask",
+	"getnetworkinforesult-localrelay":      "Whether transactions are requested from peers",
+	"getnetworkinforesult-timeoffset":      "The time offset in seconds",
+	"getnetworkinforesult-connections":     "The number of connected peers",
+	"getnetworkinforesult-networkactive":   "Whether networking is enabled",
+	"getnetworkinforesult-networks":        "Information about each network",
+	"getnetworkinforesult-relayfee":        "Minimum fee in CZZ/kB for transactions to be relayed",
+	"getnetworkinforesult-incrementalfee":  "Minimum fee rate increment in CZZ/kB, equal to the relay fee",
+	"getnetworkinforesult-localaddresses":  "The local addresses advertised to peers",
+	"getnetworkinforesult-warnings":        "Any network warnings",
+
+	// NetworksResult help.
+	"networksresult-name":                        "The network (ipv4, ipv6 or onion)",
+	"networksresult-limited":                     "Whether connections to the network are disabled",
+	"networksresult-reachable":                   "Whether peers on the network can be connected to",
+	"networksresult-proxy":                       "The proxy used to connect to the network, empty for direct connections",
+	"networksresult-proxy_randomize_credentials": "Whether the proxy credentials are randomized for each connection",
+
+	// LocalAddressesResult help.
+	"localaddressesresult-address": "The local address",
+	"localaddressesresult-port":    "The local port",
+	"localaddressesresult-score":   "The priority of the address",
+
 	// GetPeerInfoResult help.
-	"getpeerinforesult-id":             "A unique node ID",
-	"getpeerinforesult-addr":           "The ip address and port of the peer",
-	"getpeerinforesult-addrlocal":      "Local address",
-	"getpeerinforesult-services":       "Services bitmask which represents the services supported by the peer",
-	"getpeerinforesult-servicesStr":    "Services string which represents the services supported by the peer",
-	"getpeerinforesult-relaytxes":      "Peer has requested transactions be relayed to it",
-	"getpeerinforesult-lastsend":       "Time the last message was received in seconds since 1 Jan 1970 GMT",
-	"getpeerinforesult-lastrecv":       "Time the last message was sent in seconds since 1 Jan 1970 GMT",
-	"getpeerinforesult-bytessent":      "Total bytes sent",
-	"getpeerinforesult-bytesrecv":      "Total bytes received",
-	"getpeerinforesult-conntime":       "Time the connection was made in seconds since 1 Jan 1970 GMT",
-	"getpeerinforesult-timeoffset":     "The time offset of the peer",
-	"getpeerinforesult-pingtime":       "Number of microseconds the last ping took",
-	"getpeerinforesult-pingwait":       "Number of microseconds a queued ping has been waiting for a response",
-	"getpeerinforesult-version":        "The protocol version of the peer",
-	"getpeerinforesult-subver":         "The user agent of the peer",
-	"getpeerinforesult-inbound":        "Whether or not the peer is an inbound connection",
-	"getpeerinforesult-startingheight": "The latest block height the peer knew about when the connection was established",
-	"getpeerinforesult-currentheight":  "The current height of the peer",
-	"getpeerinforesult-banscore":       "The ban score",
-	"getpeerinforesult-whitelisted":    "Peer IP is whitelisted",
-	"getpeerinforesult-feefilter":      "The requested minimum fee a transaction must have to be announced to the peer",
-	"getpeerinforesult-syncnode":       "Whether or not the peer is the sync peer",
+	"getpeerinforesult-id":                       "A unique node ID",
+	"getpeerinforesult-addr":                     "The ip address and port of the peer",
+	"getpeerinforesult-addrlocal":                "Local address",
+	"getpeerinforesult-services":                 "Services bitmask which represents the services supported by the peer",
+	"getpeerinforesult-servicesStr":              "Services string which represents the services supported by the peer",
+	"getpeerinforesult-relaytxes":                "Peer has requested transactions be relayed to it",
+	"getpeerinforesult-lastsend":                 "Time the last message was received in seconds since 1 Jan 1970 GMT",
+	"getpeerinforesult-lastrecv":                 "Time the last message was sent in seconds since 1 Jan 1970 GMT",
+	"getpeerinforesult-bytessent":                "Total bytes sent",
+	"getpeerinforesult-bytesrecv":                "Total bytes received",
+	"getpeerinforesult-bytessent_per_msg":        "Bytes sent keyed by message command, bytes of undecodable messages are under *other*",
+	"getpeerinforesult-bytessent_per_msg--key":   "command",
+	"getpeerinforesult-bytessent_per_msg--value": "Total bytes sent for the command",
+	"getpeerinforesult-bytessent_per_msg--desc":  "Bytes sent per message command",
+	"getpeerinforesult-bytesrecv_per_msg":        "Bytes received keyed by message command, bytes of undecodable messages are under *other*",
+	"getpeerinforesult-bytesrecv_per_msg--key":   "command",
+	"getpeerinforesult-bytesrecv_per_msg--value": "Total bytes received for the command",
+	"getpeerinforesult-bytesrecv_per_msg--desc":  "Bytes received per message command",
+	"getpeerinforesult-conntime":                 "Time the connection was made in seconds since 1 Jan 1970 GMT",
+	"getpeerinforesult-timeoffset":               "The time offset of the peer",
+	"getpeerinforesult-pingtime":                 "Number of microseconds the last ping took",
+	"getpeerinforesult-pingwait":                 "Number of microseconds a queued ping has been waiting for a response",
+	"getpeerinforesult-version":                  "The protocol version of the peer",
+	"getpeerinforesult-subver":                   "The user agent of the peer",
+	"getpeerinforesult-inbound":                  "Whether or not the peer is an inbound connection",
+	"getpeerinforesult-startingheight":           "The latest block height the peer knew about when the connection was established",
+	"getpeerinforesult-currentheight":            "The current height of the peer",
+	"getpeerinforesult-banscore":                 "The ban score",
+	"getpeerinforesult-whitelisted":              "Peer IP is whitelisted",
+	"getpeerinforesult-feefilter":                "The requested minimum fee a transaction must have to be announced to the peer",
+	"getpeerinforesult-syncnode":                 "Whether or not the peer is the sync peer",
+	"getpeerinforesult-synccandidate":            "Whether or not the peer may be selected as the sync peer",
+	"getpeerinforesult-commonheight":             "The height of the last block announced by the peer which is in the main chain, -1 if unknown",
+	"getpeerinforesult-inflight":                 "The hashes of the blocks requested from the peer which have not been received yet",
+	"getpeerinforesult-sendheaders":              "Whether or not the peer wants new blocks announced with headers messages",
+	"getpeerinforesult-compactblocks":            "Whether or not the peer wants blocks relayed as compact blocks",
+	"getpeerinforesult-bip152_hb_to":             "Whether or not the peer was selected to announce new compact blocks to us right away",
+	"getpeerinforesult-bip152_hb_from":           "Whether or not the peer wants new compact blocks announced to it right away",
+	"getpeerinforesult-bytessent_per_sec":        "Average bytes sent per second since the connection was made",
+	"getpeerinforesult-bytesrecv_per_sec":        "Average bytes received per second since the connection was made",
+	"getpeerinforesult-permissions":              "The permissions granted to the peer",
+	"getpeerinforesult-features":                 "The optional protocol features the peer advertised (cmpctblocks, xversion, addrv2, cfilters), the others falling back to the legacy messages",
 
 	// GetPeerInfoCmd help.
 	"getpeerinfo--synopsis": "Returns data about each connected network peer as an array of json objects.",
 
+	// GetPoolBalanceCmd help.
+	"getpoolbalance--synopsis": "Returns the balances of the two coin pools funding the entangle transactions as of the best block.",
+
+	// GetPoolBalanceResult help.
+	"getpoolbalanceresult-height": "The height of the best block",
+	"getpoolbalanceresult-pools":  "The address and balance of each coin pool",
+
+	// PoolBalance help.
+	"poolbalance-address": "The address the coinbase transactions pay the coin pool to",
+	"poolbalance-amount":  "The balance of the coin pool in CZZ",
+
 	// GetRawMempoolVerboseResult help.
 	"getrawmempoolverboseresult-size":             "Transaction size in bytes",
 	"getrawmempoolverboseresult-fee":              "Transaction fee in bitcoins",
@@ -512,6 +773,81 @@ var helpDescsEnUS = map[string]string{
 	"getrawtransaction--condition1": "verbose=true",
 	"getrawtransaction--result0":    "Hex-encoded bytes of the serialized transaction",
 
+	// GetRawTransactionsCmd help.
+	"getrawtransactions--synopsis": "Returns the transactions with the given hashes along with the address and the value of the output spent by each of their inputs.\n" +
+		"The transactions are looked up with the transaction index (--txindex) and the spent outputs in the unspent transaction output set first.",
+	"getrawtransactions-txids": "The hashes of the transactions, at most 1000",
+
+	// GetRawTransactionsResult help.
+	"getrawtransactionsresult-transactions": "The transactions which are available, in the order of the hashes",
+	"getrawtransactionsresult-missing":      "The hashes of the transactions which are not available, such as the ones of pruned blocks",
+
+	// GetReserveAttestationsCmd help.
+	"getreserveattestations--synopsis": "Returns the latest verified reserve attestation of the coin pool of every chain the coins are entangled from.",
+	"getreserveattestations-verify":    "Checks the attested outputs are still unspent instead of reporting the attestations as of their verification",
+
+	// ReserveAttestationResult help.
+	"reserveattestationresult-extxtype":      "The chain the entangled coins come from (doge or ltc)",
+	"reserveattestationresult-blockheight":   "The height of the attested block of the other chain",
+	"reserveattestationresult-blockhash":     "The hash of the attested block of the other chain",
+	"reserveattestationresult-confirmations": "The number of confirmations of the attested block when the attestation was verified",
+	"reserveattestationresult-addresses":     "The custodian addresses which signed the attestation",
+	"reserveattestationresult-outpoints":     "The outputs of the other chain holding the reserve",
+	"reserveattestationresult-reserve":       "The value of the attested outputs in the base unit of the other chain",
+	"reserveattestationresult-entangled":     "The amount entangled from the other chain as of the best block in its base unit",
+	"reserveattestationresult-backed":        "Whether the reserve covers the entangled amount",
+	"reserveattestationresult-verifiedtime":  "The time the attestation was verified in seconds since 1 Jan 1970 GMT",
+	"reserveattestationresult-error":         "Why the attestation failed to verify again (only when verify is true)",
+
+	// GetRPCInfoCmd help.
+	"getrpcinfo--synopsis": "Returns the statistics of the requests served by the RPC server since it started by method, the methods the most time was spent on first.",
+
+	// GetRPCInfoResult help.
+	"getrpcinforesult-slowlog": "The duration in milliseconds from which the requests are logged, 0 when they are not",
+	"getrpcinforesult-methods": "The statistics of the methods which were requested",
+
+	// RPCMethodInfo help.
+	"rpcmethodinfo-method":    "The name of the method",
+	"rpcmethodinfo-requests":  "The number of requests of the method",
+	"rpcmethodinfo-errors":    "The number of requests which failed",
+	"rpcmethodinfo-errorrate": "The fraction of the requests which failed",
+	"rpcmethodinfo-totaltime": "The total time spent handling the requests in milliseconds",
+	"rpcmethodinfo-avgtime":   "The average time spent handling a request in milliseconds",
+	"rpcmethodinfo-p50time":   "The median time spent handling the most recent requests in milliseconds",
+	"rpcmethodinfo-p90time":   "The 90th percentile of the time spent handling the most recent requests in milliseconds",
+	"rpcmethodinfo-p99time":   "The 99th percentile of the time spent handling the most recent requests in milliseconds",
+
+	// GetScriptTypeStatsCmd help.
+	"getscripttypestats--synopsis": "Returns the number and the value of the outputs of every script type created and spent by a range of blocks of the main chain.\n" +
+		"The outputs paying the coin pools are counted as the pool type rather than pubkeyhash.\n" +
+		"This command requires the script type index to be enabled (--scripttypeindex).",
+	"getscripttypestats-startheight": "The height of the first block of the range",
+	"getscripttypestats-endheight":   "The height of the last block of the range, the best block by default",
+
+	// GetScriptTypeStatsResult help.
+	"getscripttypestatsresult-startheight": "The height of the first block of the range",
+	"getscripttypestatsresult-endheight":   "The height of the last block of the range",
+	"getscripttypestatsresult-types":       "The outputs of every script type",
+
+	// ScriptTypeStats help.
+	"scripttypestats-type":         "The script type (nonstandard, pubkey, pubkeyhash, scripthash, multisig, nulldata, entangle, keepedamount, vault or pool)",
+	"scripttypestats-created":      "The number of outputs of the type created by the blocks",
+	"scripttypestats-createdvalue": "The total value of the outputs created in CZZ",
+	"scripttypestats-spent":        "The number of outputs of the type spent by the blocks",
+	"scripttypestats-spentvalue":   "The total value of the outputs spent in CZZ",
+
+	// GetSpentInfoCmd help.
+	"getspentinfo--synopsis": "Returns the input spending a transaction output along with the height of the block containing the spending transaction.\n" +
+		"The spending transaction may be in the memory pool.\n" +
+		"This command requires the spent output index to be enabled (--spentindex).",
+	"getspentinfo-txid":  "The hash of the transaction",
+	"getspentinfo-index": "The index of the output",
+
+	// GetSpentInfoResult help.
+	"getspentinforesult-txid":   "The hash of the spending transaction",
+	"getspentinforesult-index":  "The index of the spending input in the transaction",
+	"getspentinforesult-height": "The height of the block containing the spending transaction, -1 if it is in the memory pool",
+
 	// GetTxOutResult help.
 	"gettxoutresult-bestblock":     "The block hash that contains the transaction output",
 	"gettxoutresult-confirmations": "The number of confirmations",
@@ -532,6 +868,57 @@ var helpDescsEnUS = map[string]string{
 	"gettxoutproof-blockhash": "The block hash the transactions are in",
 	"gettxoutproof--result0":  "Hex encoded merkle proof",
 
+	// GetTxReorgRiskCmd help.
+	"gettxreorgrisk--synopsis": "Returns the confirmations of a transaction, the work of the blocks on top of its block and the competing forks which would disconnect its block, so the confirmations required can depend on the work and on the forks rather than on a fixed number of blocks.\n" +
+		"The transactions of the memory pool have no confirmations.  The blocks are looked up with the transaction index (--txindex).",
+	"gettxreorgrisk-txid":  "The hash of the transaction",
+	"gettxreorgrisk-depth": "The number of blocks below the block of the transaction to look for forks at",
+
+	// GetTxReorgRiskResult help.
+	"gettxreorgriskresult-txid":             "The hash of the transaction",
+	"gettxreorgriskresult-blockhash":        "The hash of the block containing the transaction (omitted for the memory pool)",
+	"gettxreorgriskresult-blockheight":      "The height of the block containing the transaction, -1 if it is in the memory pool",
+	"gettxreorgriskresult-confirmations":    "The number of confirmations",
+	"gettxreorgriskresult-workontop":        "The total work of the blocks of the main chain after the block in hex",
+	"gettxreorgriskresult-depth":            "The number of blocks below the block the forks were looked for at",
+	"gettxreorgriskresult-hascompetingfork": "Whether a competing fork forks off the main chain within the depth below the block",
+	"gettxreorgriskresult-forks":            "The competing forks, the closest to overtaking the main chain first",
+
+	// CompetingForkResult help.
+	"competingforkresult-tiphash":     "The hash of the last block of the fork",
+	"competingforkresult-tipheight":   "The height of the last block of the fork",
+	"competingforkresult-forkheight":  "The height of the last block the fork has in common with the main chain",
+	"competingforkresult-work":        "The total work of the blocks of the fork in hex",
+	"competingforkresult-workbehind":  "How much more work the main chain has than the fork in hex",
+	"competingforkresult-headersonly": "Whether only the header of the last block of the fork is known",
+
+	// GetUtxoReportCmd help.
+	"getutxoreport--synopsis": "Scans the unspent transaction output set and reports the output scripts holding the most outputs, the dust outputs and the outputs of the coin pools.\n" +
+		"The scan runs as the scan of scantxoutset, which reports its progress and aborts it.",
+	"getutxoreport-minreuse": "The number of outputs an output script must hold to be reported as reused",
+	"getutxoreport-count":    "The maximum number of reused output scripts to list",
+
+	// GetUtxoReportResult help.
+	"getutxoreportresult-success":        "Whether or not the scan completed without being aborted",
+	"getutxoreportresult-height":         "The height of the block the scanned snapshot was taken at",
+	"getutxoreportresult-bestblock":      "The hash of the block the scanned snapshot was taken at",
+	"getutxoreportresult-txouts":         "The number of unspent transaction outputs scanned",
+	"getutxoreportresult-total_amount":   "The total amount of the outputs",
+	"getutxoreportresult-scripts":        "The number of distinct output scripts",
+	"getutxoreportresult-dust_txouts":    "The number of outputs the relay policy considers dust",
+	"getutxoreportresult-dust_amount":    "The total amount of the dust outputs",
+	"getutxoreportresult-reused_scripts": "The number of output scripts holding at least minreuse outputs",
+	"getutxoreportresult-reused_txouts":  "The number of outputs held by the reused output scripts",
+	"getutxoreportresult-reused":         "The reused output scripts holding the most outputs, up to count of them",
+	"getutxoreportresult-pools":          "The outputs of the coin pools",
+
+	// UtxoReportScript help.
+	"utxoreportscript-address":     "The first address the output script pays to, or the hex-encoded script when it pays to none",
+	"utxoreportscript-txouts":      "The number of outputs of the script",
+	"utxoreportscript-amount":      "The total amount of the outputs of the script",
+	"utxoreportscript-dust_txouts": "The number of dust outputs of the script",
+	"utxoreportscript-dust_amount": "The total amount of the dust outputs of the script",
+
 	// VerifyTxOutProofCmd help.
 	"verifytxoutproof--synopsis": "Verifies that a proof points to a transaction in a block, returning the transaction it commits to and throwing an RPC error if the block is not in our best chain",
 	"verifytxoutproof-proof":     "The hex-encoded proof generated by gettxoutproof",
@@ -545,6 +932,30 @@ var helpDescsEnUS = map[string]string{
 	"help--result0":    "List of commands",
 	"help--result1":    "Help for specified command",
 
+	// ListBannedCmd help.
+	"listbanned--synopsis": "Returns the banned IP addresses and subnets.",
+
+	// ListBannedResult help.
+	"listbannedresult-address":      "The banned IP address or subnet",
+	"listbannedresult-ban_created":  "The time the ban was created in seconds since 1 Jan 1970 GMT",
+	"listbannedresult-banned_until": "The time the ban expires in seconds since 1 Jan 1970 GMT",
+	"listbannedresult-ban_reason":   "The reason the address or subnet was banned",
+
+	// ListEntangleTxsCmd help.
+	"listentangletxs--synopsis":   "Returns the entangle outputs of the transactions in a range of blocks of the main chain.",
+	"listentangletxs-startheight": "The height of the first block to scan",
+	"listentangletxs-count":       "The maximum number of blocks to scan",
+
+	// EntangleTxResult help.
+	"entangletxresult-txid":       "The hash of the entangle transaction",
+	"entangletxresult-vout":       "The index of the entangle output",
+	"entangletxresult-height":     "The height of the block containing the transaction",
+	"entangletxresult-extxtype":   "The chain the entangled coins come from (doge or ltc)",
+	"entangletxresult-exttxhash":  "The hash of the transaction on the other chain",
+	"entangletxresult-exttxindex": "The index of the output of the transaction on the other chain",
+	"entangletxresult-extheight":  "The height of the transaction on the other chain",
+	"entangletxresult-amount":     "The entangled amount in the base unit of the other chain",
+
 	// PingCmd help.
 	"ping--synopsis": "Queues a ping to be sent to each connected peer.\n" +
 		"Ping times are provided by getpeerinfo via the pingtime and pingwait fields.",
@@ -573,18 +984,75 @@ var helpDescsEnUS = map[string]string{
 	"sendrawtransaction--result0":      "The hash of the transaction",
 
 	// ReconsiderBlockCmd
-	"reconsiderblock--synopsis": "Reconsider a block for validation.",
+	"reconsiderblock--synopsis": "Reconsider a block for validation.\n" +
+		"The block, its ancestors and its descendants are no longer considered invalid and the chain is reorganized to the valid chain with the most work, which revalidates the blocks.",
 	"reconsiderblock-blockhash": "Hash of the block you want to reconsider",
 
+	// ReloadConfigCmd help.
+	"reloadconfig--synopsis": "Reloads the configuration file and applies the options which can be changed while the node is running: " +
+		"debuglevel, the ban options, whitelist, the rpcmax options and the dogecoin and litecoin RPC options.\n" +
+		"The other options keep their values until the node is restarted.",
+	"reloadconfig--result0": "The names of the options whose values changed",
+
+	// ScanTxOutSetCmd help.
+	"scantxoutset--synopsis": "Scans the unspent transaction output set for outputs matching the scan objects.\n" +
+		"The scan reads a snapshot of the output set as of the best block, so blocks keep being connected meanwhile.\n" +
+		"Only one scan may run at a time.",
+	"scantxoutset-action":      "The action to perform: start a scan and wait for its result, abort the running scan or return the status of the running scan",
+	"scantxoutset-scanobjects": "The output scripts to scan for as pkh(<pubkey>), sh(multi(<n>,<pubkey>,...)), sh(sortedmulti(<n>,<pubkey>,...)), raw(<script hex>) or addr(<address>) descriptors with an optional checksum, required by the start action",
+	"scantxoutset--condition0": "action=start",
+	"scantxoutset--condition1": "action=abort",
+	"scantxoutset--condition2": "action=status",
+	"scantxoutset--result0":    "The outputs found by the scan",
+	"scantxoutset--result1":    "Whether or not a scan was running and aborted",
+	"scantxoutset--result2":    "The progress of the running scan or null when no scan is running",
+
+	// ScanTxOutSetResult help.
+	"scantxoutsetresult-success":      "Whether or not the scan completed without being aborted",
+	"scantxoutsetresult-txouts":       "The number of unspent transaction outputs scanned",
+	"scantxoutsetresult-height":       "The height of the block the scanned snapshot was taken at",
+	"scantxoutsetresult-bestblock":    "The hash of the block the scanned snapshot was taken at",
+	"scantxoutsetresult-unspents":     "The unspent transaction outputs matching the scan objects",
+	"scantxoutsetresult-total_amount": "The total amount of the matching outputs",
+
+	// ScanTxOutSetUnspent help.
+	"scantxoutsetunspent-txid":         "The hash of the transaction containing the output",
+	"scantxoutsetunspent-vout":         "The index of the output",
+	"scantxoutsetunspent-scriptPubKey": "The hex-encoded output script",
+	"scantxoutsetunspent-desc":         "The scan object which matched the output",
+	"scantxoutsetunspent-amount":       "The amount of the output",
+	"scantxoutsetunspent-height":       "The height of the block containing the output",
+
+	// ScanTxOutSetStatusResult help.
+	"scantxoutsetstatusresult-progress": "The approximate progress of the scan in percent",
+
 	// InvalidateBlockCmd
-	"invalidateblock--synopsis": "Invalidate a block.",
+	"invalidateblock--synopsis": "Invalidate a block.\n" +
+		"The block and its descendants are considered invalid until they are reconsidered, including across restarts, and the chain is reorganized to the valid chain with the most work when the block is part of the main chain.",
 	"invalidateblock-blockhash": "Hash of the block you want to invalidate",
 
+	// SetBanCmd help.
+	"setban--synopsis": "Bans an IP address or subnet, disconnecting any connected peers within it, or removes a ban.\n" +
+		"Bans are kept across restarts.",
+	"setban-subnet":   "The IP address or subnet in CIDR notation (eg. 192.168.0.0/16) to operate on",
+	"setban-subcmd":   "'add' to ban the address or subnet, 'remove' to remove the ban",
+	"setban-bantime":  "The number of seconds to ban for or 0 to use the configured ban duration",
+	"setban-absolute": "Whether the bantime is the unix time the ban expires instead of a number of seconds",
+
 	// SetGenerateCmd help.
 	"setgenerate--synopsis":    "Set the server to generate coins (mine) or not.",
 	"setgenerate-generate":     "Use true to enable generation, false to disable it",
 	"setgenerate-genproclimit": "The number of processors (cores) to limit generation to or -1 for default",
 
+	// SetLogLevelCmd help.
+	"setloglevel--synopsis": "Changes the log level of a subsystem, or of all subsystems when none is specified.\n" +
+		"Use debuglevel show to list the available subsystems.",
+	"setloglevel-level":           "The log level: trace, debug, info, warn, error or critical",
+	"setloglevel-subsystem":       "The subsystem to change the log level of, such as PEER",
+	"setloglevel--result0--desc":  "The log levels of all subsystems keyed by the subsystem",
+	"setloglevel--result0--key":   "The subsystem",
+	"setloglevel--result0--value": "The log level of the subsystem",
+
 	// StopCmd help.
 	"stop--synopsis": "Shutdown classzz.",
 	"stop--result0":  "The string 'classzz stopping.'",
@@ -600,9 +1068,24 @@ var helpDescsEnUS = map[string]string{
 	"submitblock--condition1": "Block rejected",
 	"submitblock--result1":    "The reason the block was rejected",
 
+	// ReserveSignature help.
+	"reservesignature-address":   "The pay-to-pubkey-hash address of the other chain of the custodian",
+	"reservesignature-signature": "The base64-encoded signature of the attestation message made by the signmessage command of the wallet of the other chain",
+
+	// SubmitReserveAttestationCmd help.
+	"submitreserveattestation--synopsis": "Verifies a proof-of-reserve attestation of the coin pool of a chain the coins are entangled from against its nodes and makes it the latest one of the chain.\n" +
+		"The custodians sign the message 'classzz reserve attestation <blockhash>' and the attested outputs must be unspent and pay the pool address or a signed address.",
+	"submitreserveattestation-extxtype":    "The chain the entangled coins come from (doge or ltc)",
+	"submitreserveattestation-blockheight": "The height of the attested block of the other chain",
+	"submitreserveattestation-blockhash":   "The hash of the attested block of the other chain",
+	"submitreserveattestation-signatures":  "The signatures of the attestation message by the custodians",
+	"submitreserveattestation-outpoints":   "The outputs of the other chain holding the reserve",
+
 	// ValidateAddressResult help.
-	"validateaddresschainresult-isvalid": "Whether or not the address is valid",
-	"validateaddresschainresult-address": "The bitcoin address (only when isvalid is true)",
+	"validateaddresschainresult-isvalid":       "Whether or not the address is valid",
+	"validateaddresschainresult-address":       "The bitcoin address (only when isvalid is true)",
+	"validateaddresschainresult-cashaddress":   "The cashaddr encoding of the address (only for pubkey hash and script hash addresses)",
+	"validateaddresschainresult-legacyaddress": "The legacy base58 encoding of the address (only for pubkey hash and script hash addresses)",
 
 	// ValidateAddressCmd help.
 	"validateaddress--synopsis": "Verify an address is valid.",
@@ -618,6 +1101,20 @@ var helpDescsEnUS = map[string]string{
 	"verifychain-checkdepth": "The number of blocks to check",
 	"verifychain--result0":   "Whether or not the chain verified",
 
+	// VerifyIndexesCmd help.
+	"verifyindexes--synopsis": "Cross-checks the entries of the optional indexes against the blocks of the main chain and optionally repairs divergent entries.\n" +
+		"The address index is not verified.",
+	"verifyindexes-startheight": "The height of the first block to check",
+	"verifyindexes-endheight":   "The height of the last block to check, -1 for the best chain tip",
+	"verifyindexes-step":        "Only check every step-th block, 1 checks every block",
+	"verifyindexes-repair":      "Replace divergent entries with the expected ones",
+
+	// VerifyIndexesResult help.
+	"verifyindexesresult-name":          "The name of the index",
+	"verifyindexesresult-blockschecked": "The number of blocks whose entries were checked",
+	"verifyindexesresult-divergent":     "The number of entries which did not match the blocks",
+	"verifyindexesresult-repaired":      "The number of divergent entries which were repaired",
+
 	// VerifyMessageCmd help.
 	"verifymessage--synopsis": "Verify a signed message.",
 	"verifymessage-address":   "The bitcoin address to use for the signature",
@@ -714,54 +1211,85 @@ var helpDescsEnUS = map[string]string{
 // pointer to the type (or nil to indicate no return value).
 var rpcResultTypes = map[string][]interface{}{
 	"addnode":                      nil,
+	"captureprofile":               {(*btcjson.CaptureProfileResult)(nil)},
+	"clearbanned":                  nil,
+	"compactdb":                    nil,
+	"createmultisig":               {(*btcjson.CreateMultiSigResult)(nil)},
 	"createrawtransaction":         {(*string)(nil)},
 	"createrawentangletransaction": {(*string)(nil)},
+	"createvault":                  {(*btcjson.CreateVaultResult)(nil)},
 	"debuglevel":                   {(*string)(nil), (*string)(nil)},
 	"decoderawtransaction":         {(*btcjson.TxRawDecodeResult)(nil)},
 	"decodescript":                 {(*btcjson.DecodeScriptResult)(nil)},
 	"estimatefee":                  {(*float64)(nil)},
 	"generate":                     {(*[]string)(nil)},
+	"generatetoaddress":            {(*[]string)(nil)},
 	"getaddednodeinfo":             {(*[]string)(nil), (*[]btcjson.GetAddedNodeInfoResult)(nil)},
+	"getaddresstxids":              {(*[]btcjson.GetAddressTxIDsResult)(nil)},
 	"getbestblock":                 {(*btcjson.GetBestBlockResult)(nil)},
 	"getbestblockhash":             {(*string)(nil)},
 	"getblock":                     {(*string)(nil), (*btcjson.GetBlockVerboseResult)(nil), (*btcjson.GetBlockVerboseTxResult)(nil)},
 	"getblockcount":                {(*int64)(nil)},
 	"getblockhash":                 {(*string)(nil)},
 	"getblockheader":               {(*string)(nil), (*btcjson.GetBlockHeaderVerboseResult)(nil)},
-	"getblocktemplate":             {(*btcjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
+	"getblocktemplate":             {(*btcjson.GetBlockTemplateResult)(nil), (*string)(nil), nil, (*btcjson.BlockProposalResult)(nil)},
+	"getblocktimings":              {(*btcjson.GetBlockTimingsResult)(nil)},
+	"getblocktxmeta":               {(*btcjson.GetBlockTxMetaResult)(nil)},
+	"getchaintxstats":              {(*btcjson.GetChainTxStatsResult)(nil)},
+	"getcheckpointcandidates":      {(*[]btcjson.CheckpointCandidateResult)(nil)},
 	"getblockchaininfo":            {(*btcjson.GetBlockChainInfoResult)(nil)},
 	"getcfilter":                   {(*string)(nil)},
 	"getcfilterheader":             {(*string)(nil)},
 	"getconnectioncount":           {(*int32)(nil)},
 	"getcurrentnet":                {(*uint32)(nil)},
+	"getdbstats":                   {(*btcjson.GetDBStatsResult)(nil)},
 	"getdifficulty":                {(*float64)(nil)},
+	"getdifficultyhistory":         {(*[]btcjson.DifficultyHistoryResult)(nil)},
 	"getgenerate":                  {(*bool)(nil)},
 	"gethashespersec":              {(*float64)(nil)},
 	"getheaders":                   {(*[]string)(nil)},
+	"getindexinfo":                 {(*map[string]btcjson.GetIndexInfoResult)(nil)},
 	"getinfo":                      {(*btcjson.InfoChainResult)(nil)},
 	"getentangleinfo":              {(*btcjson.EntangleInfoChainResult)(nil)},
 	"getmempoolinfo":               {(*btcjson.GetMempoolInfoResult)(nil)},
 	"getmininginfo":                {(*btcjson.GetMiningInfoResult)(nil)},
 	"getnettotals":                 {(*btcjson.GetNetTotalsResult)(nil)},
+	"getnetworkinfo":               {(*btcjson.GetNetworkInfoResult)(nil)},
 	"getnetworkhashps":             {(*float64)(nil)},
 	"getpeerinfo":                  {(*[]btcjson.GetPeerInfoResult)(nil)},
+	"getpoolbalance":               {(*btcjson.GetPoolBalanceResult)(nil)},
 	"getrawmempool":                {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
 	"getrawtransaction":            {(*string)(nil), (*btcjson.TxRawResult)(nil)},
+	"getrawtransactions":           {(*btcjson.GetRawTransactionsResult)(nil)},
+	"getreserveattestations":       {(*[]btcjson.ReserveAttestationResult)(nil)},
+	"getrpcinfo":                   {(*btcjson.GetRPCInfoResult)(nil)},
+	"getscripttypestats":           {(*btcjson.GetScriptTypeStatsResult)(nil)},
+	"getspentinfo":                 {(*btcjson.GetSpentInfoResult)(nil)},
 	"gettxout":                     {(*btcjson.GetTxOutResult)(nil)},
 	"gettxoutproof":                {(*string)(nil)},
+	"gettxreorgrisk":               {(*btcjson.GetTxReorgRiskResult)(nil)},
+	"getutxoreport":                {(*btcjson.GetUtxoReportResult)(nil)},
 	"node":                         nil,
 	"help":                         {(*string)(nil), (*string)(nil)},
 	"invalidateblock":              nil,
+	"listbanned":                   {(*[]btcjson.ListBannedResult)(nil)},
+	"listentangletxs":              {(*[]btcjson.EntangleTxResult)(nil)},
 	"ping":                         nil,
 	"reconsiderblock":              nil,
+	"reloadconfig":                 {(*[]string)(nil)},
+	"scantxoutset":                 {(*btcjson.ScanTxOutSetResult)(nil), (*bool)(nil), (*btcjson.ScanTxOutSetStatusResult)(nil)},
 	"searchrawtransactions":        {(*string)(nil), (*[]btcjson.SearchRawTransactionsResult)(nil)},
 	"sendrawtransaction":           {(*string)(nil)},
+	"setban":                       nil,
 	"setgenerate":                  nil,
+	"setloglevel":                  {(*map[string]string)(nil)},
 	"stop":                         {(*string)(nil)},
 	"submitblock":                  {nil, (*string)(nil)},
+	"submitreserveattestation":     {(*btcjson.ReserveAttestationResult)(nil)},
 	"uptime":                       {(*int64)(nil)},
 	"validateaddress":              {(*btcjson.ValidateAddressChainResult)(nil)},
 	"verifychain":                  {(*bool)(nil)},
+	"verifyindexes":                {(*[]btcjson.VerifyIndexesResult)(nil)},
 	"verifymessage":                {(*bool)(nil)},
 	"verifytxoutproof":             {(*[]string)(nil)},
 	"version":                      {(*map[string]btcjson.VersionResult)(nil)},
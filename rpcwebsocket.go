@@ -28,6 +28,7 @@ import (
 	"github.com/bourbaki-czz/classzz/chaincfg"
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
 	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/netsync"
 	"github.com/bourbaki-czz/classzz/txscript"
 	"github.com/bourbaki-czz/classzz/wire"
 	"github.com/bourbaki-czz/czzutil"
@@ -227,6 +228,19 @@ func (m *wsNotificationManager) NotifyBlockDisconnected(block *czzutil.Block) {
 	}
 }
 
+// NotifyChainReorganized passes a reorganization of the best chain to the
+// notification manager for block notification processing.
+func (m *wsNotificationManager) NotifyChainReorganized(reorg *netsync.Reorganization) {
+	// As NotifyChainReorganized will be called by the block manager
+	// and the RPC server may no longer be running, use a select
+	// statement to unblock enqueuing the notification once the RPC
+	// server has begun shutting down.
+	select {
+	case m.queueNotification <- (*notificationChainReorganized)(reorg):
+	case <-m.quit:
+	}
+}
+
 // NotifyMempoolTx passes a transaction accepted by mempool to the
 // notification manager for transaction notification processing.  If
 // isNew is true, the tx is is a new transaction, rather than one
@@ -302,9 +316,15 @@ func (f *wsClientFilter) addAddress(a czzutil.Address) {
 	case *czzutil.AddressPubKeyHash:
 		f.pubKeyHashes[*a.Hash160()] = struct{}{}
 		return
+	case *czzutil.LegacyAddressPubKeyHash:
+		f.pubKeyHashes[*a.Hash160()] = struct{}{}
+		return
 	case *czzutil.AddressScriptHash:
 		f.scriptHashes[*a.Hash160()] = struct{}{}
 		return
+	case *czzutil.LegacyAddressScriptHash:
+		f.scriptHashes[*a.Hash160()] = struct{}{}
+		return
 	case *czzutil.AddressPubKey:
 		serializedPubKey := a.ScriptAddress()
 		switch len(serializedPubKey) {
@@ -348,9 +368,15 @@ func (f *wsClientFilter) existsAddress(a czzutil.Address) bool {
 	case *czzutil.AddressPubKeyHash:
 		_, ok := f.pubKeyHashes[*a.Hash160()]
 		return ok
+	case *czzutil.LegacyAddressPubKeyHash:
+		_, ok := f.pubKeyHashes[*a.Hash160()]
+		return ok
 	case *czzutil.AddressScriptHash:
 		_, ok := f.scriptHashes[*a.Hash160()]
 		return ok
+	case *czzutil.LegacyAddressScriptHash:
+		_, ok := f.scriptHashes[*a.Hash160()]
+		return ok
 	case *czzutil.AddressPubKey:
 		serializedPubKey := a.ScriptAddress()
 		switch len(serializedPubKey) {
@@ -386,9 +412,15 @@ func (f *wsClientFilter) removeAddress(a czzutil.Address) {
 	case *czzutil.AddressPubKeyHash:
 		delete(f.pubKeyHashes, *a.Hash160())
 		return
+	case *czzutil.LegacyAddressPubKeyHash:
+		delete(f.pubKeyHashes, *a.Hash160())
+		return
 	case *czzutil.AddressScriptHash:
 		delete(f.scriptHashes, *a.Hash160())
 		return
+	case *czzutil.LegacyAddressScriptHash:
+		delete(f.scriptHashes, *a.Hash160())
+		return
 	case *czzutil.AddressPubKey:
 		serializedPubKey := a.ScriptAddress()
 		switch len(serializedPubKey) {
@@ -448,6 +480,7 @@ func (f *wsClientFilter) removeUnspentOutPoint(op *wire.OutPoint) {
 // Notification types
 type notificationBlockConnected czzutil.Block
 type notificationBlockDisconnected czzutil.Block
+type notificationChainReorganized netsync.Reorganization
 type notificationTxAcceptedByMempool struct {
 	isNew bool
 	tx    *czzutil.Tx
@@ -533,6 +566,12 @@ out:
 						block)
 				}
 
+			case *notificationChainReorganized:
+				if len(blockNotifications) != 0 {
+					m.notifyChainReorganized(blockNotifications,
+						(*netsync.Reorganization)(n))
+				}
+
 			case *notificationTxAcceptedByMempool:
 				if n.isNew && len(txNotifications) != 0 {
 					m.notifyForNewTx(txNotifications, n.tx)
@@ -731,6 +770,59 @@ func (*wsNotificationManager) notifyBlockDisconnected(clients map[chan struct{}]
 	}
 }
 
+// notifyChainReorganized notifies websocket clients that have registered for
+// block updates when the best chain is reorganized, detailing what became of
+// the transactions of the disconnected blocks.
+func (m *wsNotificationManager) notifyChainReorganized(clients map[chan struct{}]*wsClient,
+	reorg *netsync.Reorganization) {
+
+	result := btcjson.ChainReorganization{
+		OldHash:      reorg.OldHash.String(),
+		OldHeight:    reorg.OldHeight,
+		NewHash:      reorg.NewHash.String(),
+		NewHeight:    reorg.NewHeight,
+		ForkHash:     reorg.ForkHash.String(),
+		ForkHeight:   reorg.ForkHeight,
+		Disconnected: make([]btcjson.ReorganizedBlock, 0, len(reorg.Disconnected)),
+		Connected:    make([]string, 0, len(reorg.Attached)),
+		Sequence:     m.server.cfg.Chain.BestSnapshot().Sequence,
+	}
+	for _, block := range reorg.Disconnected {
+		blockResult := btcjson.ReorganizedBlock{
+			Hash:         block.Block.Hash().String(),
+			Height:       block.Block.Height(),
+			Transactions: make([]btcjson.ReorganizedTx, 0, len(block.Txs)),
+		}
+		for _, tx := range block.Txs {
+			txResult := btcjson.ReorganizedTx{
+				TxID:   tx.Tx.Hash().String(),
+				Fate:   tx.Fate.String(),
+				Reason: tx.Reason,
+			}
+			if tx.Block != nil {
+				txResult.BlockHash = tx.Block.String()
+			}
+			blockResult.Transactions = append(blockResult.Transactions,
+				txResult)
+		}
+		result.Disconnected = append(result.Disconnected, blockResult)
+	}
+	for _, block := range reorg.Attached {
+		result.Connected = append(result.Connected, block.Hash().String())
+	}
+
+	ntfn := btcjson.NewChainReorganizedNtfn(result)
+	marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal chain reorganized "+
+			"notification: %v", err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
 // notifyFilteredBlockConnected notifies websocket clients that have registered for
 // block updates when a block is connected to the main chain.
 func (m *wsNotificationManager) notifyFilteredBlockConnected(clients map[chan struct{}]*wsClient,
@@ -745,8 +837,9 @@ func (m *wsNotificationManager) notifyFilteredBlockConnected(clients map[chan st
 			"connected notification: %v", err)
 		return
 	}
+	sequence := m.server.cfg.Chain.BestSnapshot().Sequence
 	ntfn := btcjson.NewFilteredBlockConnectedNtfn(block.Height(),
-		hex.EncodeToString(w.Bytes()), nil)
+		hex.EncodeToString(w.Bytes()), nil, &sequence)
 
 	// Search for relevant transactions for each client and save them
 	// serialized in hex encoding for the notification.
@@ -779,7 +872,7 @@ func (m *wsNotificationManager) notifyFilteredBlockConnected(clients map[chan st
 // notifyFilteredBlockDisconnected notifies websocket clients that have registered for
 // block updates when a block is disconnected from the main chain (due to a
 // reorganize).
-func (*wsNotificationManager) notifyFilteredBlockDisconnected(clients map[chan struct{}]*wsClient,
+func (m *wsNotificationManager) notifyFilteredBlockDisconnected(clients map[chan struct{}]*wsClient,
 	block *czzutil.Block) {
 	// Skip notification creation if no clients have requested block
 	// connected/disconnected notifications.
@@ -795,8 +888,9 @@ func (*wsNotificationManager) notifyFilteredBlockDisconnected(clients map[chan s
 			"disconnected notification: %v", err)
 		return
 	}
+	sequence := m.server.cfg.Chain.BestSnapshot().Sequence
 	ntfn := btcjson.NewFilteredBlockDisconnectedNtfn(block.Height(),
-		hex.EncodeToString(w.Bytes()))
+		hex.EncodeToString(w.Bytes()), &sequence)
 	marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
 	if err != nil {
 		rpcsLog.Errorf("Failed to marshal filtered block disconnected "+
@@ -831,7 +925,9 @@ func (m *wsNotificationManager) notifyForNewTx(clients map[chan struct{}]*wsClie
 		amount += txOut.Value
 	}
 
-	ntfn := btcjson.NewTxAcceptedNtfn(txHashStr, czzutil.Amount(amount).ToCZZ())
+	sequence := m.server.cfg.TxMemPool.Sequence()
+	ntfn := btcjson.NewTxAcceptedNtfn(txHashStr,
+		czzutil.Amount(amount).ToCZZ(), &sequence)
 	marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
 	if err != nil {
 		rpcsLog.Errorf("Failed to marshal tx notification: %s", err.Error())
@@ -854,7 +950,8 @@ func (m *wsNotificationManager) notifyForNewTx(clients map[chan struct{}]*wsClie
 				return
 			}
 
-			verboseNtfn = btcjson.NewTxAcceptedVerboseNtfn(*rawTx)
+			verboseNtfn = btcjson.NewTxAcceptedVerboseNtfn(*rawTx,
+				&sequence)
 			marshalledJSONVerbose, err = btcjson.MarshalCmd(nil,
 				verboseNtfn)
 			if err != nil {
@@ -1371,6 +1468,7 @@ out:
 		}
 
 		cmd := parseCmd(&request)
+		cmd.caller = c.addr
 		if cmd.err != nil {
 			if !c.authenticated {
 				break out
@@ -0,0 +1,96 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sdNotify sends the passed state, such as READY=1, to the service manager
+// when the process is run as a systemd service of Type=notify, see
+// sd_notify(3).  It does nothing when the process is not.
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	// Abstract socket names starting with '@' are handled by the net
+	// package.
+	conn, err := net.DialUnix("unixgram", nil,
+		&net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// sdWatchdogInterval returns the interval at which the service manager expects
+// the WATCHDOG=1 keep-alive notifications, or zero when the watchdog is not
+// enabled for the process.
+func sdWatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	pid := os.Getenv("WATCHDOG_PID")
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// sdExtendTimeout keeps asking the service manager for more time until the
+// passed channel is closed, so a shutdown which takes longer than the stop
+// timeout of the unit, for instance while flushing a large chain state, is not
+// cut short by a kill.  The shutdown is bounded by --shutdowntimeout instead.
+func sdExtendTimeout(done <-chan struct{}) {
+	const interval = 10 * time.Second
+	extend := fmt.Sprintf("EXTEND_TIMEOUT_USEC=%d",
+		int64(3*interval/time.Microsecond))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		sdNotify(extend)
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+// watchdogHandler sends the WATCHDOG=1 keep-alive notifications to the service
+// manager at half the passed interval for as long as the peer handler answers
+// queries, so the service manager restarts a hung node.  It must be run as a
+// goroutine.
+func (s *server) watchdogHandler(interval time.Duration) {
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+out:
+	for {
+		select {
+		case <-ticker.C:
+			// The query returns no peers once the server is
+			// shutting down, so this does not block the shutdown.
+			s.connectedPeers()
+			if err := sdNotify("WATCHDOG=1"); err != nil {
+				srvrLog.Warnf("Unable to notify the service "+
+					"manager: %v", err)
+			}
+
+		case <-s.quit:
+			break out
+		}
+	}
+	s.wg.Done()
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestSdNotify ensures the states are sent to the notification socket and
+// nothing is sent without one.
+func TestSdNotify(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix datagram sockets are not supported on windows")
+	}
+
+	dir, err := ioutil.TempDir("", "sdnotify")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram",
+		&net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer conn.Close()
+
+	defer os.Setenv("NOTIFY_SOCKET", os.Getenv("NOTIFY_SOCKET"))
+	os.Unsetenv("NOTIFY_SOCKET")
+	if err := sdNotify("READY=1"); err != nil {
+		t.Fatalf("sdNotify: unexpected error without a socket: %v", err)
+	}
+
+	os.Setenv("NOTIFY_SOCKET", socket)
+	if err := sdNotify("READY=1"); err != nil {
+		t.Fatalf("sdNotify: unexpected error: %v", err)
+	}
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("unable to read the notification: %v", err)
+	}
+	if got := string(buf[:n]); got != "READY=1" {
+		t.Fatalf("got notification %q, want READY=1", got)
+	}
+}
+
+// TestSdWatchdogInterval ensures the watchdog interval is only returned when
+// the watchdog is enabled for the process.
+func TestSdWatchdogInterval(t *testing.T) {
+	defer os.Setenv("WATCHDOG_USEC", os.Getenv("WATCHDOG_USEC"))
+	defer os.Setenv("WATCHDOG_PID", os.Getenv("WATCHDOG_PID"))
+
+	tests := []struct {
+		usec string
+		pid  string
+		want time.Duration
+	}{
+		{"", "", 0},
+		{"garbage", "", 0},
+		{"-1", "", 0},
+		{"30000000", "", 30 * time.Second},
+		{"30000000", strconv.Itoa(os.Getpid()), 30 * time.Second},
+		{"30000000", strconv.Itoa(os.Getpid() + 1), 0},
+	}
+	for i, test := range tests {
+		os.Setenv("WATCHDOG_USEC", test.usec)
+		os.Setenv("WATCHDOG_PID", test.pid)
+		if got := sdWatchdogInterval(); got != test.want {
+			t.Errorf("#%d: got interval %v, want %v", i, got,
+				test.want)
+		}
+	}
+}
@@ -6,15 +6,17 @@
 package main
 
 import (
-	"bytes"
 	"crypto/rand"
 	"crypto/tls"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"github.com/bourbaki-czz/classzz/czzrpc"
+	"io/ioutil"
 	"math"
+	mrand "math/rand"
 	"net"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
@@ -31,7 +33,9 @@ import (
 	"github.com/bourbaki-czz/classzz/chaincfg"
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
 	"github.com/bourbaki-czz/classzz/connmgr"
+	"github.com/bourbaki-czz/classzz/cross"
 	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/graphene"
 	"github.com/bourbaki-czz/classzz/mempool"
 	"github.com/bourbaki-czz/classzz/mining"
 	"github.com/bourbaki-czz/classzz/mining/cpuminer"
@@ -58,6 +62,14 @@ const (
 	// number of retries such that there is a retry backoff.
 	connectionRetryInterval = time.Second * 5
 
+	// maxAnchors is the maximum number of outbound peers saved on shutdown
+	// to reconnect to first on the next start.
+	maxAnchors = 2
+
+	// onionKeyFilename is the name of the file in the data directory the
+	// private key of the onion service is kept in.
+	onionKeyFilename = "onion_v3_private_key"
+
 	// maxDirectRelayPeers specifies the maximum number of direct relay
 	// peers. As part of the compact block protocol (BIP0152) we can tell
 	// a remote peer to send us a block directly without first sending an
@@ -65,6 +77,11 @@ const (
 	// than necessary. For this reason we cap the number of peers we
 	// allow to send us blocks directly at three.
 	maxDirectRelayPeers = 3
+
+	// txDiffusionFanout is the number of peers a locally submitted
+	// transaction is announced to right away, before the other peers are
+	// announced it after their random diffusion delays.
+	txDiffusionFanout = 2
 )
 
 var (
@@ -147,6 +164,10 @@ type broadcastInventoryDel *wire.InvVect
 type relayMsg struct {
 	invVect *wire.InvVect
 	data    interface{}
+
+	// diffuse is set for the transactions submitted locally, which are
+	// diffused to the peers rather than announced to them all at once.
+	diffuse bool
 }
 
 // updatePeerHeightsMsg is a message sent from the blockmanager to the server
@@ -161,6 +182,13 @@ type updatePeerHeightsMsg struct {
 	originPeer *peer.Peer
 }
 
+// peerMisbehavedMsg is a message sent from the sync manager to the server when
+// a peer relayed data which proves it is misbehaving.
+type peerMisbehavedMsg struct {
+	peer   *peer.Peer
+	reason string
+}
+
 // maybeAddDirectRelayPeerMsg holds a response chan which returns whether or
 // not the peer was added to the peerstate directRelayPeers map. This will be
 // true if we have less than maxDirectRelayPeers.
@@ -170,13 +198,12 @@ type maybeAddDirectRelayPeerMsg struct {
 }
 
 // peerState maintains state of inbound, persistent, outbound peers as well
-// as banned peers and outbound groups.
+// as outbound groups.
 type peerState struct {
 	inboundPeers     map[int32]*serverPeer
 	outboundPeers    map[int32]*serverPeer
 	persistentPeers  map[int32]*serverPeer
 	directRelayPeers map[int32]*serverPeer
-	banned           map[string]time.Time
 	outboundGroups   map[string]int
 	connectionCount  map[string]int
 }
@@ -226,6 +253,11 @@ type server struct {
 	// Putting the uint64s first makes them 64-bit aligned for 32-bit systems.
 	bytesReceived uint64 // Total bytes received from all peers since start.
 	bytesSent     uint64 // Total bytes sent by all peers since start.
+
+	// bridgeDisconnects is the number of times a bridge peer disconnected
+	// since start.
+	bridgeDisconnects uint64
+
 	started       int32
 	shutdown      int32
 	shutdownSched int32
@@ -238,6 +270,10 @@ type server struct {
 	hashCache               *txscript.HashCache
 	rpcServer               *rpcServer
 	gRPCServer              *czzrpc.GrpcServer
+	metricsServer           *metricsServer
+	bridgeServer            *bridgeServer
+	bridgeHosts             map[string]struct{}
+	utxoSnapshot            *utxoSnapshot
 	syncManager             *netsync.SyncManager
 	chain                   *blockchain.BlockChain
 	txMemPool               *mempool.TxPool
@@ -245,16 +281,17 @@ type server struct {
 	modifyRebroadcastInv    chan interface{}
 	newPeers                chan *serverPeer
 	donePeers               chan *serverPeer
-	banPeers                chan *serverPeer
 	maybeAddDirectRelayPeer chan *maybeAddDirectRelayPeerMsg
 	query                   chan interface{}
 	relayInv                chan relayMsg
 	relayCmpctBlock         chan *wire.MsgCmpctBlock
 	broadcast               chan broadcastMsg
 	peerHeightsUpdate       chan updatePeerHeightsMsg
+	peerMisbehaved          chan peerMisbehavedMsg
 	wg                      sync.WaitGroup
 	quit                    chan struct{}
 	nat                     NAT
+	onionTarget             string // listener the onion service forwards to
 	db                      database.DB
 	timeSource              blockchain.MedianTimeSource
 	services                wire.ServiceFlag
@@ -263,18 +300,44 @@ type server struct {
 	// if the associated index is not enabled.  These fields are set during
 	// initial creation of the server and never changed afterwards, so they
 	// do not need to be protected for concurrent access.
-	txIndex   *indexers.TxIndex
-	addrIndex *indexers.AddrIndex
-	cfIndex   *indexers.CfIndex
+	indexManager *indexers.Manager
+	txIndex      *indexers.TxIndex
+	addrIndex    *indexers.AddrIndex
+	spentIndex   *indexers.SpentIndex
+	scriptIndex  *indexers.ScriptTypeIndex
+	cfIndex      *indexers.CfIndex
+
+	// wallet is the built-in wallet, which is only set when it is compiled
+	// in and enabled.  It is maintained by the index manager.
+	wallet *nodeWallet
 
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
 	feeEstimator *mempool.FeeEstimator
 
+	// The ban manager keeps track of banned networks.  The bans are kept
+	// in the database so they survive restarts.
+	banManager *connmgr.BanManager
+
+	// evictionKey is a random secret used to key the netgroups of inbound
+	// peers when selecting a peer to evict.
+	evictionKey []byte
+
+	// inboundLimiter limits the rate of inbound connections per address
+	// and the number of inbound connections still in the handshake.
+	inboundLimiter *connmgr.InboundLimiter
+
+	// uploadTarget limits the bytes uploaded to peers per timeframe by
+	// no longer serving historical blocks once it is reached.
+	uploadTarget *uploadTarget
+
 	// cfCheckptCaches stores a cached slice of filter headers for cfcheckpt
 	// messages for each filter type.
 	cfCheckptCaches    map[wire.FilterType][]cfHeaderKV
 	cfCheckptCachesMtx sync.RWMutex
+
+	// reloadMtx serializes the reloads of the configuration.
+	reloadMtx sync.Mutex
 }
 
 // spMsg represents a message over the wire from a specific peer.
@@ -295,7 +358,8 @@ type spMsgSubscription struct {
 // the blockmanager.
 type serverPeer struct {
 	// The following variables must only be used atomically
-	feeFilter int64
+	feeFilter          int64
+	utxoSnapshotHeight int32
 
 	*peer.Peer
 
@@ -306,8 +370,11 @@ type serverPeer struct {
 	relayMtx        sync.Mutex
 	processBlockMtx sync.Mutex
 	disableRelayTx  bool
+	directRelay     bool
 	sentAddrs       bool
-	isWhitelisted   bool
+	permissions     peer.Permissions
+	handshakeSlot   bool
+	handshakeOnce   sync.Once
 	filter          *bloom.Filter
 	addrMtx         sync.RWMutex
 	knownAddresses  map[string]struct{}
@@ -380,6 +447,18 @@ func (sp *serverPeer) relayTxDisabled() bool {
 	return isDisabled
 }
 
+// isDirectRelay returns whether the peer was asked to announce new blocks with
+// compact blocks right away, which makes it one of the high bandwidth compact
+// block relay peers.
+// It is safe for concurrent access.
+func (sp *serverPeer) isDirectRelay() bool {
+	sp.relayMtx.Lock()
+	directRelay := sp.directRelay
+	sp.relayMtx.Unlock()
+
+	return directRelay
+}
+
 // pushAddrMsg sends an addr message to the connected peer using the provided
 // addresses.
 func (sp *serverPeer) pushAddrMsg(addresses []*wire.NetAddress) {
@@ -409,7 +488,7 @@ func (sp *serverPeer) addBanScore(persistent, transient uint32, reason string) {
 	if cfg.DisableBanning {
 		return
 	}
-	if sp.isWhitelisted {
+	if sp.permissions.Has(peer.PermissionNoBan) {
 		peerLog.Debugf("Misbehaving whitelisted peer %s: %s", sp, reason)
 		return
 	}
@@ -432,7 +511,7 @@ func (sp *serverPeer) addBanScore(persistent, transient uint32, reason string) {
 		if score > cfg.BanThreshold {
 			peerLog.Warnf("Misbehaving peer %s -- banning and disconnecting",
 				sp)
-			sp.server.BanPeer(sp)
+			sp.server.BanPeer(sp, reason)
 			sp.Disconnect()
 		}
 	}
@@ -542,30 +621,101 @@ func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) *wire.MsgRej
 	// Add valid peer to the server.
 	sp.server.AddPeer(sp)
 
-	// This peer supports the compact blocks version so we should
-	// send them a sendcmpt message.
-	resp := make(chan bool)
-	sp.server.maybeAddDirectRelayPeer <- &maybeAddDirectRelayPeerMsg{response: resp, peer: sp}
-	announce := <-resp
-	sendCmpctMessage := wire.NewMsgSendCmpct(announce, wire.CompactBlocksProtocolVersion)
-	sp.Peer.QueueMessage(sendCmpctMessage, nil)
+	// Only negotiate the optional protocol features the version of the
+	// peer knows, as older peers disconnect upon unknown messages.  The
+	// others are served with the legacy messages instead, such as full
+	// blocks in place of compact blocks.
+	if sp.ProtocolVersion() >= wire.ShortIDsBlocksVersion {
+		// This peer supports the compact blocks version so we should
+		// send them a sendcmpt message.
+		resp := make(chan bool)
+		sp.server.maybeAddDirectRelayPeer <- &maybeAddDirectRelayPeerMsg{response: resp, peer: sp}
+		announce := <-resp
+		sp.relayMtx.Lock()
+		sp.directRelay = announce
+		sp.relayMtx.Unlock()
+		sendCmpctMessage := wire.NewMsgSendCmpct(announce, wire.CompactBlocksProtocolVersion)
+		sp.Peer.QueueMessage(sendCmpctMessage, nil)
+	}
+
+	// Tell the peer which optional protocol features we support.
+	if sp.ProtocolVersion() >= wire.XVersionVersion {
+		sp.Peer.QueueMessage(sp.server.newMsgXVersion(), nil)
+	}
 
 	return nil
 }
 
-// OnXVersion is invoked when a peer receives an xversion message.
+// newMsgXVersion returns the xversion message advertising the capabilities of
+// the server.
+func (s *server) newMsgXVersion() *wire.MsgXVersion {
+	relay := wire.XVersionRelayCompactBlocks
+	if s.services&wire.SFNodeGraphene == wire.SFNodeGraphene {
+		relay |= wire.XVersionRelayGraphene
+	}
+
+	msg := wire.NewMsgXVersion()
+	msg.SetUint64(wire.XVersionKeyBlockRelay, relay)
+	if len(cfg.DogeCoinRPC) > 0 || len(cfg.LtcCoinRPC) > 0 {
+		msg.SetUint64(wire.XVersionKeyEntangleProof,
+			wire.EntangleProofVersion)
+	}
+	msg.SetUint64(wire.XVersionKeyMinFeeRate, uint64(cfg.minRelayTxFee))
+	if s.utxoSnapshot.served() {
+		msg.SetUint64(wire.XVersionKeyUtxoSnapshot,
+			uint64(s.utxoSnapshot.checkpoint.Height))
+	}
+	return msg
+}
+
+// OnXVersion is invoked when a peer receives an xversion message.  A minimum
+// fee rate advertised by the peer is applied like a feefilter message.  The
+// height of the UTXO snapshot served by the peer is recorded for fast sync.
 func (sp *serverPeer) OnXVersion(_ *peer.Peer, msg *wire.MsgXVersion) {
+	if feeRate, ok := msg.Uint64(wire.XVersionKeyMinFeeRate); ok {
+		if feeRate > czzutil.MaxSatoshi {
+			peerLog.Debugf("Peer %v sent an invalid xversion min fee "+
+				"rate '%v' -- disconnecting", sp,
+				czzutil.Amount(feeRate))
+			sp.Disconnect()
+			return
+		}
+		atomic.StoreInt64(&sp.feeFilter, int64(feeRate))
+	}
+	if height, ok := msg.Uint64(wire.XVersionKeyUtxoSnapshot); ok &&
+		height <= math.MaxInt32 {
+
+		atomic.StoreInt32(&sp.utxoSnapshotHeight, int32(height))
+	}
+
 	sp.Peer.QueueMessage(wire.NewMsgXVerAck(), nil)
 }
 
+// OnVerAck is invoked when a peer receives a verack bitcoin message.  The
+// handshake is complete, so an inbound peer no longer counts as half-open.
+func (sp *serverPeer) OnVerAck(_ *peer.Peer, _ *wire.MsgVerAck) {
+	sp.releaseHandshakeSlot()
+}
+
+// releaseHandshakeSlot releases the half-open connection slot an inbound peer
+// holds until it completed the handshake or disconnected.  It is safe to call
+// multiple times.
+func (sp *serverPeer) releaseHandshakeSlot() {
+	if sp.handshakeSlot {
+		sp.handshakeOnce.Do(sp.server.inboundLimiter.Done)
+	}
+}
+
 // OnMemPool is invoked when a peer receives a mempool bitcoin message.
 // It creates and sends an inventory message with the contents of the memory
 // pool up to the maximum inventory allowed per message.  When the peer has a
 // bloom filter loaded, the contents are filtered accordingly.
 func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 	// Only allow mempool requests if the server has bloom filtering
-	// enabled.
-	if sp.server.services&wire.SFNodeBloom != wire.SFNodeBloom {
+	// enabled or the peer has the mempool permission.
+	if sp.server.services&wire.SFNodeBloom != wire.SFNodeBloom &&
+		!sp.permissions.Has(peer.PermissionMempool) {
+
 		peerLog.Debugf("peer %v sent mempool request with bloom "+
 			"filtering disabled -- disconnecting", sp)
 		sp.Disconnect()
@@ -611,10 +761,7 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 // cfilter message.
 func (sp *serverPeer) OnGetCFMemPool(_ *peer.Peer, msg *wire.MsgGetCFMempool) {
 	// Only allow getcfmempool requests if the server has nodeCF enabled
-	if sp.server.services&wire.SFNodeCF != wire.SFNodeCF {
-		peerLog.Debugf("peer %v sent getcfmempool request with NodeCF "+
-			"disabled -- disconnecting", sp)
-		sp.Disconnect()
+	if !sp.enforceNodeCFFlag(msg.Command()) {
 		return
 	}
 
@@ -729,6 +876,7 @@ func (sp *serverPeer) processComapactBlock(msg *wire.MsgCmpctBlock) {
 		sp.server.syncManager.QueueBlockError(&targetHash, sp.Peer)
 		return
 	}
+	sp.server.syncManager.BlockHeaderReceived(&targetHash)
 
 	msgBlock, err := sp.server.txMemPool.DecodeCompressedBlock(msg)
 	if err != nil {
@@ -803,6 +951,18 @@ func (sp *serverPeer) processComapactBlock(msg *wire.MsgCmpctBlock) {
 	// convenience methods and things such as hash caching.
 	block := czzutil.NewBlock(msgBlock)
 
+	// A short ID may have matched an unrelated mempool transaction, so the
+	// reconstructed transactions are checked against the header.  The peer
+	// is not at fault for a mismatch, so rather than processing a block
+	// which gets it banned the full block is requested instead.
+	merkleRoot := blockchain.CalcMerkleRoot(block.Transactions())
+	if !merkleRoot.IsEqual(&msgBlock.Header.MerkleRoot) {
+		peerLog.Debugf("Cmpctblock %v from %v does not match its "+
+			"header, requesting full block", targetHash, sp)
+		sp.requestFullBlock(&targetHash)
+		return
+	}
+
 	// Add the block to the known inventory for the peer.
 	iv := wire.NewInvVect(wire.InvTypeBlock, block.Hash())
 	sp.AddKnownInventory(iv)
@@ -850,35 +1010,206 @@ func (sp *serverPeer) OnGetBlockTxns(_ *peer.Peer, msg *wire.MsgGetBlockTxns) {
 	// half of its value.
 	sp.addBanScore(0, 33, "getblocktxns")
 
-	// Fetch the raw block bytes from the database.
+	// Fetch the block from the cache of recent blocks or the database.
 	hash := msg.BlockHash
-	var blockBytes []byte
-	err := sp.server.db.View(func(dbTx database.Tx) error {
-		var err error
-		blockBytes, err = dbTx.FetchBlock(&hash)
-		return err
-	})
+	block, err := sp.server.chain.FetchBlock(&hash)
 	if err != nil {
 		peerLog.Tracef("Unable to fetch requested block hash %v: %v",
 			hash, err)
 		return
 	}
+	msgBlock := block.MsgBlock()
+	requestdTxs, err := msg.RequestedTransactions(msgBlock)
+	if err != nil {
+		peerLog.Tracef("Unable to extract requested transactions: %v", err)
+		return
+	}
+	msgBlockTxns := wire.NewMsgBlockTxns(hash, requestdTxs)
+	sp.QueueMessage(msgBlockTxns, nil)
+}
 
-	// Deserialize the block.
-	var msgBlock wire.MsgBlock
-	err = msgBlock.Deserialize(bytes.NewReader(blockBytes))
+// OnGetGrapheneBlock is invoked when a peer receives a getgrblk bitcoin
+// message.  The requested block is sent as a graphene block sized for the
+// mempool of the peer, or as a compact block when that would be smaller.
+func (sp *serverPeer) OnGetGrapheneBlock(_ *peer.Peer, msg *wire.MsgGetGrapheneBlock) {
+	// Only allow getgrblk requests if the server has graphene enabled.
+	if sp.server.services&wire.SFNodeGraphene != wire.SFNodeGraphene {
+		peerLog.Debugf("peer %v sent getgrblk request with graphene "+
+			"disabled -- disconnecting", sp)
+		sp.Disconnect()
+		return
+	}
+
+	// Fetch the block from the cache of recent blocks or the database.
+	hash := msg.BlockHash
+	block, err := sp.server.chain.FetchBlock(&hash)
+	if err != nil {
+		peerLog.Tracef("Unable to fetch requested block hash %v: %v",
+			hash, err)
+		return
+	}
+	msgBlock := block.MsgBlock()
+
+	grapheneBlock, err := graphene.NewMsgGrapheneBlock(msgBlock,
+		msg.MempoolCount)
 	if err != nil {
-		peerLog.Tracef("Unable to deserialize requested block hash "+
+		peerLog.Tracef("Unable to build requested graphene block hash "+
 			"%v: %v", hash, err)
 		return
 	}
-	requestdTxs, err := msg.RequestedTransactions(&msgBlock)
+
+	// Small blocks, or peers with huge mempools, are better served by
+	// the short IDs of a compact block.
+	grapheneSize := len(grapheneBlock.Filter) +
+		len(grapheneBlock.IBLTCells)*wire.IBLTCellSize
+	if grapheneSize >= len(msgBlock.Transactions)*wire.ShortIDSize {
+		cmpctBlock, err := wire.NewMsgCmpctBlockFromBlock(msgBlock,
+			sp.GetKnownTxInventory())
+		if err != nil {
+			peerLog.Tracef("Unable to build requested cmpctblock "+
+				"hash %v: %v", hash, err)
+			return
+		}
+		sp.QueueMessage(cmpctBlock, nil)
+		return
+	}
+	sp.QueueMessage(grapheneBlock, nil)
+}
+
+// OnGrapheneBlock is invoked when a peer receives a grblk bitcoin message.
+func (sp *serverPeer) OnGrapheneBlock(_ *peer.Peer, msg *wire.MsgGrapheneBlock) {
+	go sp.processGrapheneBlock(msg)
+}
+
+// requestFullBlock asks the peer for the full block with the passed hash.
+// It is used when a graphene block could not be reconstructed.  The block is
+// still tracked as requested from this peer by the sync manager.
+func (sp *serverPeer) requestFullBlock(hash *chainhash.Hash) {
+	gdmsg := wire.NewMsgGetData()
+	gdmsg.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, hash))
+	sp.QueueMessage(gdmsg, nil)
+}
+
+// processGrapheneBlock attempts to reconstruct a full wire.MsgBlock from a
+// wire.MsgGrapheneBlock using the mempool.  This may require making another
+// round trip to the peer to retrieve missing transactions and, should the
+// reconstruction fail, falls back to requesting the full block.  Run it in a
+// separate goroutine.
+func (sp *serverPeer) processGrapheneBlock(msg *wire.MsgGrapheneBlock) {
+	targetHash := msg.BlockHash()
+
+	// We check the header here before proceeding so no round trips are
+	// wasted on an invalid block.
+	if err := sp.server.chain.CheckBlockHeaderContext(&msg.Header); err != nil {
+		peerLog.Debugf("Ignoring graphene block %v from %v -- "+
+			"invalid header: %v", targetHash, sp, err)
+		sp.server.syncManager.QueueBlockError(&targetHash, sp.Peer)
+		return
+	}
+	sp.server.syncManager.BlockHeaderReceived(&targetHash)
+
+	txDescs := sp.server.txMemPool.TxDescs()
+	mempool := make([]*wire.MsgTx, 0, len(txDescs))
+	for _, txDesc := range txDescs {
+		mempool = append(mempool, txDesc.Tx.MsgTx())
+	}
+	partial, err := graphene.NewPartialBlock(msg, mempool)
+	if err != nil {
+		peerLog.Debugf("Error decoding graphene block %v from %v, "+
+			"requesting full block: %v", targetHash, sp, err)
+		sp.requestFullBlock(&targetHash)
+		return
+	}
+
+	if len(partial.MissingShortIDs()) > 0 {
+		quitChan := make(chan struct{})
+		msgChan := make(chan spMsg)
+		subscription := spMsgSubscription{
+			command:  wire.CmdGrapheneTx,
+			quitChan: quitChan,
+			msgChan:  msgChan,
+		}
+		sp.subscribeRecvMsg(subscription)
+		sp.QueueMessage(partial.NewMsgGetGrapheneTx(), nil)
+		timeout := time.After(time.Second * 30)
+		select {
+		case <-timeout:
+			sp.unsubscribeRecvMsgs(subscription)
+			close(quitChan)
+			peerLog.Debugf("Peer %v timed out waiting for grblktx for "+
+				"graphene block %v", sp, targetHash)
+			sp.server.syncManager.QueueBlockError(&targetHash, sp.Peer)
+			return
+		case resp := <-msgChan:
+			sp.unsubscribeRecvMsgs(subscription)
+			grapheneTx, ok := resp.msg.(*wire.MsgGrapheneTx)
+			if !ok || !grapheneTx.BlockHash.IsEqual(&targetHash) {
+				peerLog.Debugf("grblktx response for graphene block "+
+					"%v from peer %v contained incorrect hash",
+					targetHash, sp)
+				sp.server.syncManager.QueueBlockError(&targetHash, sp.Peer)
+				return
+			}
+			err := partial.AddMissingTransactions(grapheneTx.Txs)
+			if err != nil {
+				peerLog.Debugf("grblktx response for graphene block "+
+					"%v from peer %v is invalid: %v", targetHash,
+					sp, err)
+				sp.server.syncManager.QueueBlockError(&targetHash, sp.Peer)
+				return
+			}
+		}
+	}
+
+	msgBlock, err := partial.Block()
+	if err != nil {
+		peerLog.Debugf("Graphene block %v from %v does not match its "+
+			"header, requesting full block: %v", targetHash, sp, err)
+		sp.requestFullBlock(&targetHash)
+		return
+	}
+
+	// Convert the raw MsgBlock to a czzutil.Block which provides some
+	// convenience methods and things such as hash caching.
+	block := czzutil.NewBlock(msgBlock)
+
+	// Add the block to the known inventory for the peer.
+	iv := wire.NewInvVect(wire.InvTypeBlock, block.Hash())
+	sp.AddKnownInventory(iv)
+
+	// Queue the block up to be handled by the block manager and
+	// intentionally block further receives until it is fully processed.
+	// See processComapactBlock for why the processing lock is held.
+	sp.processBlockMtx.Lock()
+	sp.server.syncManager.QueueBlock(block, sp.Peer, sp.blockProcessed)
+	<-sp.blockProcessed
+	sp.processBlockMtx.Unlock()
+}
+
+// OnGetGrapheneTx is invoked when a peer receives a getgrblktx bitcoin
+// message.  Like OnGetBlockTxns it waits for any block being processed first.
+func (sp *serverPeer) OnGetGrapheneTx(_ *peer.Peer, msg *wire.MsgGetGrapheneTx) {
+	sp.processBlockMtx.Lock()
+	sp.processBlockMtx.Unlock()
+
+	// A decaying ban score increase is applied to prevent flooding.
+	sp.addBanScore(0, 33, "getgrblktx")
+
+	// Fetch the block from the cache of recent blocks or the database.
+	hash := msg.BlockHash
+	block, err := sp.server.chain.FetchBlock(&hash)
+	if err != nil {
+		peerLog.Tracef("Unable to fetch requested block hash %v: %v",
+			hash, err)
+		return
+	}
+	msgBlock := block.MsgBlock()
+	requestedTxs, err := graphene.RequestedTransactions(msgBlock, msg)
 	if err != nil {
 		peerLog.Tracef("Unable to extract requested transactions: %v", err)
 		return
 	}
-	msgBlockTxns := wire.NewMsgBlockTxns(hash, requestdTxs)
-	sp.QueueMessage(msgBlockTxns, nil)
+	sp.QueueMessage(wire.NewMsgGrapheneTx(hash, requestedTxs), nil)
 }
 
 // OnInv is invoked when a peer receives an inv bitcoin message and is
@@ -956,6 +1287,18 @@ func (sp *serverPeer) OnGetData(_ *peer.Peer, msg *wire.MsgGetData) {
 			// Buffered so as to not make the send goroutine block.
 			c = make(chan struct{}, 1)
 		}
+		// Disconnect peers requesting historical blocks once the
+		// upload target is reached since they are syncing and can
+		// download the blocks from other peers.
+		if iv.Type != wire.InvTypeTx && sp.server.historicalBlockLimited(sp,
+			&iv.Hash) {
+
+			peerLog.Infof("Upload target reached, disconnecting peer %v "+
+				"requesting historical block %v", sp, iv.Hash)
+			sp.Disconnect()
+			return
+		}
+
 		var err error
 		switch iv.Type {
 		case wire.InvTypeTx:
@@ -1082,6 +1425,11 @@ func (sp *serverPeer) OnGetHeaders(_ *peer.Peer, msg *wire.MsgGetHeaders) {
 
 // OnGetCFilters is invoked when a peer receives a getcfilters bitcoin message.
 func (sp *serverPeer) OnGetCFilters(_ *peer.Peer, msg *wire.MsgGetCFilters) {
+	// Disconnect the peer if committed filters are disabled.
+	if !sp.enforceNodeCFFlag(msg.Command()) {
+		return
+	}
+
 	// Ignore getcfilters requests if not in sync.
 	if !sp.server.syncManager.IsCurrent() {
 		return
@@ -1138,6 +1486,11 @@ func (sp *serverPeer) OnGetCFilters(_ *peer.Peer, msg *wire.MsgGetCFilters) {
 
 // OnGetCFHeaders is invoked when a peer receives a getcfheader bitcoin message.
 func (sp *serverPeer) OnGetCFHeaders(_ *peer.Peer, msg *wire.MsgGetCFHeaders) {
+	// Disconnect the peer if committed filters are disabled.
+	if !sp.enforceNodeCFFlag(msg.Command()) {
+		return
+	}
+
 	// Ignore getcfilterheader requests if not in sync.
 	if !sp.server.syncManager.IsCurrent() {
 		return
@@ -1171,6 +1524,7 @@ func (sp *serverPeer) OnGetCFHeaders(_ *peer.Peer, msg *wire.MsgGetCFHeaders) {
 	)
 	if err != nil {
 		peerLog.Debugf("Invalid getcfheaders request: %v", err)
+		return
 	}
 
 	// This is possible if StartHeight is one greater that the height of
@@ -1255,6 +1609,11 @@ func (sp *serverPeer) OnGetCFHeaders(_ *peer.Peer, msg *wire.MsgGetCFHeaders) {
 
 // OnGetCFCheckpt is invoked when a peer receives a getcfcheckpt bitcoin message.
 func (sp *serverPeer) OnGetCFCheckpt(_ *peer.Peer, msg *wire.MsgGetCFCheckpt) {
+	// Disconnect the peer if committed filters are disabled.
+	if !sp.enforceNodeCFFlag(msg.Command()) {
+		return
+	}
+
 	// Ignore getcfcheckpt requests if not in sync.
 	if !sp.server.syncManager.IsCurrent() {
 		return
@@ -1437,6 +1796,20 @@ func (sp *serverPeer) enforceNodeBloomFlag(cmd string) bool {
 	return true
 }
 
+// enforceNodeCFFlag disconnects the peer if the server is not configured to
+// serve committed filters, in which case the filter index does not exist.
+// It returns whether the request may be served.
+func (sp *serverPeer) enforceNodeCFFlag(cmd string) bool {
+	if sp.server.services&wire.SFNodeCF != wire.SFNodeCF {
+		peerLog.Debugf("%s sent an unsupported %s request with "+
+			"committed filters disabled -- disconnecting", sp, cmd)
+		sp.Disconnect()
+		return false
+	}
+
+	return true
+}
+
 // OnFeeFilter is invoked when a peer receives a feefilter bitcoin message and
 // is used by remote peers to request that no transactions which have a fee rate
 // lower than provided value are inventoried to them.  The peer will be
@@ -1564,6 +1937,19 @@ func (sp *serverPeer) OnGetAddr(_ *peer.Peer, msg *wire.MsgGetAddr) {
 // OnAddr is invoked when a peer receives an addr bitcoin message and is
 // used to notify the server about advertised addresses.
 func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
+	sp.handleAddrList(msg.Command(), msg.AddrList)
+}
+
+// OnAddrV2 is invoked when a peer receives an addrv2 bitcoin message and is
+// used to notify the server about advertised addresses, including those on
+// networks the addr message can not carry such as Tor v3 and I2P.
+func (sp *serverPeer) OnAddrV2(_ *peer.Peer, msg *wire.MsgAddrV2) {
+	sp.handleAddrList(msg.Command(), msg.AddrList)
+}
+
+// handleAddrList adds the addresses advertised by the peer in an addr or
+// addrv2 message to the known addresses of the peer and the address manager.
+func (sp *serverPeer) handleAddrList(cmd string, addrList []*wire.NetAddress) {
 	// Ignore addresses when running on the simulation test network.  This
 	// helps prevent the network from becoming another public test network
 	// since it will not be able to learn about other peers that have not
@@ -1572,14 +1958,14 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 		return
 	}
 	// A message that has no addresses is invalid.
-	if len(msg.AddrList) == 0 {
+	if len(addrList) == 0 {
 		peerLog.Errorf("Command [%s] from %s does not contain any addresses",
-			msg.Command(), sp.Peer)
+			cmd, sp.Peer)
 		sp.Disconnect()
 		return
 	}
 
-	for _, na := range msg.AddrList {
+	for _, na := range addrList {
 		// Don't add more address if we're disconnecting.
 		if !sp.Connected() {
 			return
@@ -1602,7 +1988,7 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 	// addresses, and last seen updates.
 	// XXX bitcoind gives a 2 hour time penalty here, do we want to do the
 	// same?
-	sp.server.addrManager.AddAddresses(msg.AddrList, sp.NA())
+	sp.server.addrManager.AddAddresses(addrList, sp.NA())
 }
 
 // OnReject logs all reject messages received from the remote peer.
@@ -1693,6 +2079,15 @@ func (s *server) relayTransactions(txns []*mempool.TxDesc) {
 	}
 }
 
+// diffuseTransactions generates and diffuses inventory vectors for all of the
+// passed locally submitted transactions to the connected peers.
+func (s *server) diffuseTransactions(txns []*mempool.TxDesc) {
+	for _, txD := range txns {
+		iv := wire.NewInvVect(wire.InvTypeTx, txD.Tx.Hash())
+		s.DiffuseInventory(iv, txD)
+	}
+}
+
 // AnnounceNewTransactions generates and relays inventory vectors and notifies
 // both websocket and getblocktemplate long poll clients of the passed
 // transactions.  This function should be called whenever new transactions
@@ -1701,7 +2096,20 @@ func (s *server) AnnounceNewTransactions(txns []*mempool.TxDesc) {
 	// Generate and relay inventory vectors for all newly accepted
 	// transactions.
 	s.relayTransactions(txns)
+	s.notifyNewTransactions(txns)
+}
+
+// AnnounceLocalTransactions is like AnnounceNewTransactions for transactions
+// submitted locally, which are diffused to the peers instead so that the order
+// in which they are announced does not reveal this node as their origin.
+func (s *server) AnnounceLocalTransactions(txns []*mempool.TxDesc) {
+	s.diffuseTransactions(txns)
+	s.notifyNewTransactions(txns)
+}
 
+// notifyNewTransactions notifies both websocket and getblocktemplate long poll
+// clients and the gRPC server of the passed transactions.
+func (s *server) notifyNewTransactions(txns []*mempool.TxDesc) {
 	// Notify both websocket and getblocktemplate long poll clients of all
 	// newly accepted transactions.
 	if s.rpcServer != nil {
@@ -1726,6 +2134,15 @@ func (s *server) TransactionConfirmed(tx *czzutil.Tx) {
 	s.RemoveRebroadcastInventory(iv)
 }
 
+// ChainReorganized notifies the websocket clients of the RPC server of the
+// passed reorganization of the chain and of what became of the transactions of
+// the disconnected blocks.
+func (s *server) ChainReorganized(reorg *netsync.Reorganization) {
+	if s.rpcServer != nil {
+		s.rpcServer.ntfnMgr.NotifyChainReorganized(reorg)
+	}
+}
+
 // pushTxMsg sends a tx message for the provided transaction hash to the
 // connected peer.  An error is returned if the transaction hash is not known.
 func (s *server) pushTxMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<- struct{},
@@ -1755,18 +2172,30 @@ func (s *server) pushTxMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<-
 	return nil
 }
 
+// historicalBlockLimited returns whether the block with the passed hash may not
+// be served to the peer because it is historical and the upload target has been
+// reached.  Peers with the download permission are always served.
+func (s *server) historicalBlockLimited(sp *serverPeer, hash *chainhash.Hash) bool {
+	now := time.Now()
+	if sp.permissions.Has(peer.PermissionDownload) ||
+		!s.uploadTarget.Reached(now) {
+
+		return false
+	}
+	header, err := s.chain.HeaderByHash(hash)
+	if err != nil {
+		return false
+	}
+	return now.Sub(header.Timestamp) > historicalBlockAge
+}
+
 // pushBlockMsg sends a block message for the provided block hash to the
 // connected peer.  An error is returned if the block hash is not known.
 func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<- struct{},
 	waitChan <-chan struct{}, encoding wire.MessageEncoding) error {
 
-	// Fetch the raw block bytes from the database.
-	var blockBytes []byte
-	err := sp.server.db.View(func(dbTx database.Tx) error {
-		var err error
-		blockBytes, err = dbTx.FetchBlock(hash)
-		return err
-	})
+	// Fetch the block from the cache of recent blocks or the database.
+	block, err := sp.server.chain.FetchBlock(hash)
 	if err != nil {
 		peerLog.Tracef("Unable to fetch requested block hash %v: %v",
 			hash, err)
@@ -1776,19 +2205,7 @@ func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan cha
 		}
 		return err
 	}
-
-	// Deserialize the block.
-	var msgBlock wire.MsgBlock
-	err = msgBlock.Deserialize(bytes.NewReader(blockBytes))
-	if err != nil {
-		peerLog.Tracef("Unable to deserialize requested block hash "+
-			"%v: %v", hash, err)
-
-		if doneChan != nil {
-			doneChan <- struct{}{}
-		}
-		return err
-	}
+	msgBlock := block.MsgBlock()
 
 	// Once we have fetched data wait for any previous operation to finish.
 	if waitChan != nil {
@@ -1803,7 +2220,7 @@ func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan cha
 	if !sendInv {
 		dc = doneChan
 	}
-	sp.QueueMessageWithEncoding(&msgBlock, dc, encoding)
+	sp.QueueMessageWithEncoding(msgBlock, dc, encoding)
 
 	// When the peer requests the final block that was advertised in
 	// response to a getblocks message which requested more blocks than
@@ -1826,13 +2243,8 @@ func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan cha
 func (s *server) pushCmpctBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<- struct{},
 	waitChan <-chan struct{}, encoding wire.MessageEncoding) error {
 
-	// Fetch the raw block bytes from the database.
-	var blockBytes []byte
-	err := sp.server.db.View(func(dbTx database.Tx) error {
-		var err error
-		blockBytes, err = dbTx.FetchBlock(hash)
-		return err
-	})
+	// Fetch the block from the cache of recent blocks or the database.
+	block, err := sp.server.chain.FetchBlock(hash)
 	if err != nil {
 		peerLog.Tracef("Unable to fetch requested block hash %v: %v",
 			hash, err)
@@ -1842,21 +2254,9 @@ func (s *server) pushCmpctBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneCha
 		}
 		return err
 	}
+	msgBlock := block.MsgBlock()
 
-	// Deserialize the block.
-	var msgBlock wire.MsgBlock
-	err = msgBlock.Deserialize(bytes.NewReader(blockBytes))
-	if err != nil {
-		peerLog.Tracef("Unable to deserialize requested block hash "+
-			"%v: %v", hash, err)
-
-		if doneChan != nil {
-			doneChan <- struct{}{}
-		}
-		return err
-	}
-
-	cmpctBlock, err := wire.NewMsgCmpctBlockFromBlock(&msgBlock, sp.GetKnownTxInventory())
+	cmpctBlock, err := wire.NewMsgCmpctBlockFromBlock(msgBlock, sp.GetKnownTxInventory())
 	if err != nil {
 		peerLog.Tracef("Unable to build requested cmpctblock hash "+
 			"%v: %v", hash, err)
@@ -1969,6 +2369,17 @@ func (s *server) handleUpdatePeerHeights(state *peerState, umsg updatePeerHeight
 	})
 }
 
+// handlePeerMisbehaved raises the ban score of a peer which relayed invalid
+// data above the ban threshold, so it is banned right away unless it has the
+// noban permission.  It is invoked from the peerHandler goroutine.
+func (s *server) handlePeerMisbehaved(state *peerState, mmsg peerMisbehavedMsg) {
+	state.forAllPeers(func(sp *serverPeer) {
+		if sp.Peer == mmsg.peer {
+			sp.addBanScore(cfg.BanThreshold+1, 0, mmsg.reason)
+		}
+	})
+}
+
 // handleAddPeerMsg deals with adding new peers.  It is invoked from the
 // peerHandler goroutine.
 func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
@@ -1990,16 +2401,13 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		sp.Disconnect()
 		return false
 	}
-	if banEnd, ok := state.banned[host]; ok {
-		if time.Now().Before(banEnd) {
-			srvrLog.Debugf("Peer %s is banned for another %v - disconnecting",
-				host, time.Until(banEnd))
-			sp.Disconnect()
-			return false
-		}
+	if ban, ok := s.banManager.IsBanned(net.ParseIP(host)); ok &&
+		!sp.permissions.Has(peer.PermissionNoBan) {
 
-		srvrLog.Infof("Peer %s is no longer banned", host)
-		delete(state.banned, host)
+		srvrLog.Debugf("Peer %s is banned for another %v - disconnecting",
+			host, time.Until(ban.Until))
+		sp.Disconnect()
+		return false
 	}
 
 	// Limit max number of total peers per ip.
@@ -2011,8 +2419,11 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		return false
 	}
 
-	// Limit max number of total peers.
-	if state.Count() >= cfg.MaxPeers {
+	// Limit max number of total peers.  New inbound peers take the place
+	// of an existing inbound peer chosen by the eviction policy, if any.
+	if state.Count() >= cfg.MaxPeers && !(sp.Inbound() &&
+		s.evictInboundPeer(state)) {
+
 		srvrLog.Infof("Max peers reached [%d] - disconnecting peer %s",
 			cfg.MaxPeers, sp)
 		sp.Disconnect()
@@ -2028,7 +2439,7 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		state.inboundPeers[sp.ID()] = sp
 		state.connectionCount[host]++
 	} else {
-		state.outboundGroups[addrmgr.GroupKey(sp.NA())]++
+		state.outboundGroups[s.addrManager.GroupKey(sp.NA())]++
 
 		if sp.persistent {
 			state.persistentPeers[sp.ID()] = sp
@@ -2041,6 +2452,34 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	return true
 }
 
+// evictInboundPeer disconnects the inbound peer selected by the eviction
+// policy to make room for a new inbound peer.  Peers with the noban permission
+// are never evicted.  It returns whether a peer was evicted.  It is invoked from the
+// peerHandler goroutine.
+func (s *server) evictInboundPeer(state *peerState) bool {
+	candidates := make([]evictionCandidate, 0, len(state.inboundPeers))
+	for _, sp := range state.inboundPeers {
+		if sp.permissions.Has(peer.PermissionNoBan) || sp.NA() == nil {
+			continue
+		}
+		candidates = append(candidates, newEvictionCandidate(sp,
+			s.evictionKey))
+	}
+	id, ok := selectEvictionCandidate(candidates)
+	if !ok {
+		return false
+	}
+
+	sp := state.inboundPeers[id]
+	srvrLog.Infof("Evicting inbound peer %s to make room for a new peer", sp)
+	delete(state.inboundPeers, id)
+	if host, _, err := net.SplitHostPort(sp.Addr()); err == nil {
+		state.connectionCount[host]--
+	}
+	sp.Disconnect()
+	return true
+}
+
 // handleDonePeerMsg deals with peers that have signalled they are done.  It is
 // invoked from the peerHandler goroutine.
 func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
@@ -2056,7 +2495,7 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 
 	if _, ok := list[sp.ID()]; ok {
 		if !sp.Inbound() && sp.VersionKnown() {
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		}
 
 		if !sp.Inbound() && sp.connReq != nil {
@@ -2094,23 +2533,19 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 	// or we purposefully deleted it.
 }
 
-// handleBanPeerMsg deals with banning peers.  It is invoked from the
-// peerHandler goroutine.
-func (s *server) handleBanPeerMsg(state *peerState, sp *serverPeer) {
-	host, _, err := net.SplitHostPort(sp.Addr())
-	if err != nil {
-		srvrLog.Debugf("can't split ban peer %s %v", sp.Addr(), err)
-		return
-	}
-	direction := directionString(sp.Inbound())
-	srvrLog.Infof("Banned peer %s (%s) for %v", host, direction,
-		cfg.BanDuration)
-	state.banned[host] = time.Now().Add(cfg.BanDuration)
-}
-
 // handleRelayInvMsg deals with relaying inventory to peers that are not already
 // known to have it.  It is invoked from the peerHandler goroutine.
 func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
+	// Entangle transactions are announced to the bridge peers right away,
+	// ahead of the other peers which are announced them with their next
+	// batch of inventory or after their diffusion delay.
+	var entangle bool
+	if txD, ok := msg.data.(*mempool.TxDesc); ok {
+		_, err := cross.IsEntangleTx(txD.Tx.MsgTx())
+		entangle = err == nil
+	}
+
+	var diffusePeers []*serverPeer
 	state.forAllPeers(func(sp *serverPeer) {
 		if !sp.Connected() {
 			return
@@ -2136,7 +2571,7 @@ func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
 			time.AfterFunc(time.Minute, func() {
 				for _, tx := range block.Transactions[1:] {
 					txHash := tx.TxHash()
-					txiv := wire.NewInvVect(wire.InvTypeBlock, &txHash)
+					txiv := wire.NewInvVect(wire.InvTypeTx, &txHash)
 					sp.DeleteKnownInventory(txiv)
 				}
 			})
@@ -2155,6 +2590,7 @@ func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
 							"%v: %v", block.BlockHash(), err)
 						return
 					}
+					sp.AddKnownInventory(blockInv)
 					sp.QueueMessage(cmpctBlock, nil)
 					return
 				}
@@ -2205,24 +2641,91 @@ func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
 			}
 		}
 
+		if entangle && sp.permissions.Has(peer.PermissionBridge) {
+			sp.announceInventoryNow(msg.invVect)
+			return
+		}
+
+		// Diffused inventory is announced once all the peers which
+		// should be announced it are known.
+		if msg.diffuse {
+			diffusePeers = append(diffusePeers, sp)
+			return
+		}
+
 		// Queue the inventory to be relayed with the next batch.
 		// It will be ignored if the peer is already known to
 		// have the inventory.
 		sp.QueueInventory(msg.invVect)
 	})
+
+	if msg.diffuse {
+		diffuseInventory(diffusePeers, msg.invVect, cfg.TxDiffusionDelay)
+	}
+}
+
+// diffuseInventory announces the passed inventory vector to the passed peers
+// after the delays of diffusionDelays.  Peers which learn of the inventory from
+// other peers in the meantime are not announced it again, so an observer
+// connected to many nodes cannot tell which one announced it first.
+func diffuseInventory(peers []*serverPeer, iv *wire.InvVect, maxDelay time.Duration) {
+	inbound := make([]bool, len(peers))
+	for i, sp := range peers {
+		inbound[i] = sp.Inbound()
+	}
+	for i, delay := range diffusionDelays(inbound, maxDelay) {
+		sp := peers[i]
+		if delay == 0 {
+			sp.QueueInventory(iv)
+			continue
+		}
+		time.AfterFunc(delay, func() {
+			sp.QueueInventory(iv)
+		})
+	}
+}
+
+// diffusionDelays returns the delays after which to announce a locally
+// submitted transaction to peers with the passed inbound flags.  A random subset
+// of txDiffusionFanout of the peers is announced it right away, preferring
+// outbound peers which are harder for an observer to control, and each of the
+// others after its own random delay of up to the passed maximum.  All the peers
+// are announced it right away when the maximum delay is not positive.
+func diffusionDelays(inbound []bool, maxDelay time.Duration) []time.Duration {
+	delays := make([]time.Duration, len(inbound))
+	if maxDelay <= 0 {
+		return delays
+	}
+
+	order := mrand.Perm(len(inbound))
+	sort.SliceStable(order, func(i, j int) bool {
+		return !inbound[order[i]] && inbound[order[j]]
+	})
+	for n, i := range order {
+		if n >= txDiffusionFanout {
+			delays[i] = 1 + time.Duration(mrand.Int63n(int64(maxDelay)))
+		}
+	}
+	return delays
 }
 
 // handleRelayCmpctBlock deals with direct relaying a compact block to
 // peers which both want a compact block and accept direct relay.
 func (s *server) handleRelayCmpctBlock(state *peerState, msg *wire.MsgCmpctBlock) {
 	state.forAllPeers(func(sp *serverPeer) {
-		if sp.WantsCompactBlocks() && sp.WantsDirectBlockRelay() {
+		if !sp.WantsCompactBlocks() || !sp.WantsDirectBlockRelay() {
+			return
+		}
 
-			blockHash := msg.BlockHash()
-			iv := wire.NewInvVect(wire.InvTypeBlock, &blockHash)
-			sp.AddKnownInventory(iv)
-			sp.QueueMessage(msg, nil)
+		// Don't send the block to a peer that already has it, such as
+		// the peer it was received from.
+		blockHash := msg.BlockHash()
+		iv := wire.NewInvVect(wire.InvTypeBlock, &blockHash)
+		if sp.HasKnownInventory(iv) {
+			return
 		}
+		sp.AddKnownInventory(iv)
+		sp.QueueMessage(msg, nil)
 	})
 }
 
@@ -2334,7 +2837,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found := disconnectPeer(state.persistentPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		})
 
 		if found {
@@ -2370,7 +2873,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		})
 		if found {
 			// If there are multiple outbound connections to the same
@@ -2378,7 +2881,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 			// peers are found.
 			for found {
 				found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
-					state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+					state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 				})
 			}
 			msg.reply <- nil
@@ -2413,36 +2916,56 @@ func disconnectPeer(peerList map[int32]*serverPeer, compareFunc func(*serverPeer
 	return false
 }
 
+// announceInventoryNow announces the passed inventory vector to the peer in an
+// inv message of its own instead of with its next batch of inventory, unless
+// the peer is already known to have it.
+func (sp *serverPeer) announceInventoryNow(iv *wire.InvVect) {
+	if sp.HasKnownInventory(iv) {
+		return
+	}
+	sp.AddKnownInventory(iv)
+	invMsg := wire.NewMsgInvSizeHint(1)
+	invMsg.AddInvVect(iv)
+	sp.QueueMessage(invMsg, nil)
+}
+
 // newPeerConfig returns the configuration for the given serverPeer.
 func newPeerConfig(sp *serverPeer) *peer.Config {
 	return &peer.Config{
+		Permissions: sp.permissions,
 		Listeners: peer.MessageListeners{
-			OnVersion:      sp.OnVersion,
-			OnXVersion:     sp.OnXVersion,
-			OnMemPool:      sp.OnMemPool,
-			OnTx:           sp.OnTx,
-			OnBlock:        sp.OnBlock,
-			OnCmpctBlock:   sp.OnCmpctBlock,
-			OnGetBlockTxns: sp.OnGetBlockTxns,
-			OnInv:          sp.OnInv,
-			OnHeaders:      sp.OnHeaders,
-			OnGetData:      sp.OnGetData,
-			OnGetBlocks:    sp.OnGetBlocks,
-			OnGetHeaders:   sp.OnGetHeaders,
-			OnGetCFilters:  sp.OnGetCFilters,
-			OnGetCFHeaders: sp.OnGetCFHeaders,
-			OnGetCFCheckpt: sp.OnGetCFCheckpt,
-			OnGetCFMempool: sp.OnGetCFMemPool,
-			OnFeeFilter:    sp.OnFeeFilter,
-			OnFilterAdd:    sp.OnFilterAdd,
-			OnFilterClear:  sp.OnFilterClear,
-			OnFilterLoad:   sp.OnFilterLoad,
-			OnGetAddr:      sp.OnGetAddr,
-			OnAddr:         sp.OnAddr,
-			OnRead:         sp.OnRead,
-			OnWrite:        sp.OnWrite,
-			OnReject:       sp.OnReject,
-			OnNotFound:     sp.OnNotFound,
+			OnVersion:          sp.OnVersion,
+			OnVerAck:           sp.OnVerAck,
+			OnXVersion:         sp.OnXVersion,
+			OnMemPool:          sp.OnMemPool,
+			OnTx:               sp.OnTx,
+			OnBlock:            sp.OnBlock,
+			OnCmpctBlock:       sp.OnCmpctBlock,
+			OnGetBlockTxns:     sp.OnGetBlockTxns,
+			OnGetGrapheneBlock: sp.OnGetGrapheneBlock,
+			OnGrapheneBlock:    sp.OnGrapheneBlock,
+			OnGetGrapheneTx:    sp.OnGetGrapheneTx,
+			OnGetUtxoSnapshot:  sp.OnGetUtxoSnapshot,
+			OnInv:              sp.OnInv,
+			OnHeaders:          sp.OnHeaders,
+			OnGetData:          sp.OnGetData,
+			OnGetBlocks:        sp.OnGetBlocks,
+			OnGetHeaders:       sp.OnGetHeaders,
+			OnGetCFilters:      sp.OnGetCFilters,
+			OnGetCFHeaders:     sp.OnGetCFHeaders,
+			OnGetCFCheckpt:     sp.OnGetCFCheckpt,
+			OnGetCFMempool:     sp.OnGetCFMemPool,
+			OnFeeFilter:        sp.OnFeeFilter,
+			OnFilterAdd:        sp.OnFilterAdd,
+			OnFilterClear:      sp.OnFilterClear,
+			OnFilterLoad:       sp.OnFilterLoad,
+			OnGetAddr:          sp.OnGetAddr,
+			OnAddr:             sp.OnAddr,
+			OnAddrV2:           sp.OnAddrV2,
+			OnRead:             sp.OnRead,
+			OnWrite:            sp.OnWrite,
+			OnReject:           sp.OnReject,
+			OnNotFound:         sp.OnNotFound,
 		},
 		AddrMe:            addrMe,
 		NewestBlock:       sp.newestBlock,
@@ -2456,6 +2979,7 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 		DisableRelayTx:    cfg.BlocksOnly,
 		ProtocolVersion:   peer.MaxProtocolVersion,
 		TrickleInterval:   cfg.TrickleInterval,
+		HandshakeTimeout:  cfg.HandshakeTimeout,
 		MaxKnownInventory: uint((cfg.ExcessiveBlockSize / 1000000) * peer.DefaultMaxKnownInventory),
 	}
 }
@@ -2466,7 +2990,27 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 // for disconnection.
 func (s *server) inboundPeerConnected(conn net.Conn) {
 	sp := newServerPeer(s, false)
-	sp.isWhitelisted = isWhitelisted(conn.RemoteAddr())
+	sp.permissions = whitelistPermissions(conn, true)
+	if s.isBridgeAddr(conn.RemoteAddr()) {
+		sp.permissions |= peer.PermissionsBridge
+	}
+
+	// Refuse connections flooding the listeners before any resources are
+	// spent on them.  Peers with the noban permission are never limited.
+	if !sp.permissions.Has(peer.PermissionNoBan) {
+		host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+		if err == nil {
+			err = s.inboundLimiter.Accept(net.ParseIP(host))
+		}
+		if err != nil {
+			srvrLog.Debugf("Refusing inbound connection from %s: %v",
+				conn.RemoteAddr(), err)
+			conn.Close()
+			return
+		}
+		sp.handshakeSlot = true
+	}
+
 	sp.Peer = peer.NewInboundPeer(newPeerConfig(sp))
 	sp.AssociateConnection(conn)
 	go s.peerDoneHandler(sp)
@@ -2479,6 +3023,10 @@ func (s *server) inboundPeerConnected(conn net.Conn) {
 // manager of the attempt.
 func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	sp := newServerPeer(s, c.Permanent)
+	sp.permissions = whitelistPermissions(conn, false)
+	if s.isBridgeAddr(c.Addr) {
+		sp.permissions |= peer.PermissionsBridge
+	}
 	p, err := peer.NewOutboundPeer(newPeerConfig(sp), c.Addr.String())
 	if err != nil {
 		srvrLog.Debugf("Cannot create outbound peer %s: %v", c.Addr, err)
@@ -2486,18 +3034,55 @@ func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	}
 	sp.Peer = p
 	sp.connReq = c
-	sp.isWhitelisted = isWhitelisted(conn.RemoteAddr())
 	sp.AssociateConnection(conn)
 	go s.peerDoneHandler(sp)
 	s.addrManager.Attempt(sp.NA())
 }
 
+// dialOutbound dials the passed address of an outbound peer, recording the
+// failures to connect to it with the address manager since they never reach
+// outboundPeerConnected.
+func (s *server) dialOutbound(addr net.Addr) (net.Conn, error) {
+	conn, err := czzdDial(addr)
+	if err != nil {
+		na, naErr := s.addrManager.DeserializeNetAddress(addr.String(), 0)
+		if naErr == nil {
+			s.addrManager.Attempt(na)
+			s.addrManager.Failed(na)
+		}
+	}
+	return conn, err
+}
+
 // peerDoneHandler handles peer disconnects by notifiying the server that it's
 // done along with other performing other desirable cleanup.
 func (s *server) peerDoneHandler(sp *serverPeer) {
 	sp.WaitForDisconnect()
+	sp.releaseHandshakeSlot()
 	s.donePeers <- sp
 
+	// The disconnects of the bridge peers are warned about since the
+	// entangles depend on them, but not when the server shuts down.
+	if sp.permissions.Has(peer.PermissionBridge) &&
+		atomic.LoadInt32(&s.shutdown) == 0 {
+
+		atomic.AddUint64(&s.bridgeDisconnects, 1)
+		srvrLog.Warnf("Bridge peer %s disconnected", sp)
+	}
+
+	// Record how the outbound connection went so the address manager
+	// prefers the addresses of the peers which answered quickly after a
+	// restart.  Disconnecting before the version exchange completed is a
+	// failed connection.
+	if !sp.Inbound() && sp.NA() != nil {
+		if sp.VerAckReceived() {
+			latency := time.Duration(sp.LastPingMicros()) * time.Microsecond
+			s.addrManager.SetLatency(sp.NA(), latency)
+		} else {
+			s.addrManager.Failed(sp.NA())
+		}
+	}
+
 	// Only tell sync manager we are gone if we ever told it we existed.
 	if sp.VersionKnown() {
 		s.syncManager.DonePeer(sp.Peer, nil)
@@ -2532,22 +3117,55 @@ func (s *server) peerHandler() {
 		persistentPeers:  make(map[int32]*serverPeer),
 		outboundPeers:    make(map[int32]*serverPeer),
 		directRelayPeers: make(map[int32]*serverPeer),
-		banned:           make(map[string]time.Time),
 		outboundGroups:   make(map[string]int),
 		connectionCount:  make(map[string]int),
 	}
 
+	// Bitcoind uses a lookup of the dns seeder as the source of seeded
+	// addresses. This is rather strange since the values looked up by the
+	// DNS seed lookups will vary quite a lot.  To replicate this behaviour
+	// we put all addresses as having come from the first one.
+	addSeedAddrs := func(addrs []*wire.NetAddress) {
+		s.addrManager.AddAddresses(addrs, addrs[0])
+	}
+
 	if !cfg.DisableDNSSeed {
-		// Add peers discovered through DNS to the address manager.
-		connmgr.SeedFromDNS(activeNetParams.Params, defaultRequiredServices,
-			czzdLookup, func(addrs []*wire.NetAddress) {
-				// Bitcoind uses a lookup of the dns seeder here. This
-				// is rather strange since the values looked up by the
-				// DNS seed lookups will vary quite a lot.
-				// to replicate this behaviour we put all addresses as
-				// having come from the first one.
-				s.addrManager.AddAddresses(addrs, addrs[0])
-			})
+		// Add peers discovered through DNS to the address manager.  The
+		// seeds are resolved with czzdLookup, so they are resolved
+		// through Tor when a Tor proxy is configured.
+		dnsSeeds := make([]chaincfg.DNSSeed, 0,
+			len(activeNetParams.DNSSeeds)+len(cfg.DNSSeeds))
+		dnsSeeds = append(dnsSeeds, activeNetParams.DNSSeeds...)
+		for _, host := range cfg.DNSSeeds {
+			dnsSeeds = append(dnsSeeds, chaincfg.DNSSeed{Host: host})
+		}
+		connmgr.SeedFromDNSSeeds(dnsSeeds, activeNetParams.DefaultPort,
+			defaultRequiredServices, czzdLookup, addSeedAddrs)
+	}
+
+	if cfg.SeedFile != "" && len(cfg.ConnectPeers) == 0 {
+		// Add the peers listed in the seed file to the address manager.
+		err := connmgr.SeedFromFile(cfg.SeedFile, activeNetParams.DefaultPort,
+			func(host string, port uint16) (*wire.NetAddress, error) {
+				return s.addrManager.HostToNetAddress(host, port,
+					defaultRequiredServices)
+			}, addSeedAddrs)
+		if err != nil {
+			srvrLog.Errorf("Unable to read seed file: %v", err)
+		}
+	}
+	// Reconnect to the anchors saved on the last shutdown before
+	// selecting any other outbound peers.
+	if len(cfg.ConnectPeers) == 0 {
+		for _, na := range s.addrManager.LoadAnchors() {
+			addr, err := addrStringToNetAddr(addrmgr.NetAddressKey(na))
+			if err != nil {
+				srvrLog.Debugf("Ignoring anchor %v: %v", na.IP, err)
+				continue
+			}
+			srvrLog.Infof("Connecting to anchor %s", addr)
+			go s.connManager.Connect(&connmgr.ConnReq{Addr: addr})
+		}
 	}
 	go s.connManager.Start()
 
@@ -2566,9 +3184,9 @@ out:
 		case umsg := <-s.peerHeightsUpdate:
 			s.handleUpdatePeerHeights(state, umsg)
 
-		// Peer to ban.
-		case p := <-s.banPeers:
-			s.handleBanPeerMsg(state, p)
+		// A peer relayed invalid data, ban it.
+		case mmsg := <-s.peerMisbehaved:
+			s.handlePeerMisbehaved(state, mmsg)
 
 		// New inventory to potentially be relayed to other peers.
 		case invMsg := <-s.relayInv:
@@ -2596,6 +3214,10 @@ out:
 			}
 
 		case <-s.quit:
+			// Remember the longest lived outbound peers so they are
+			// reconnected to first on the next start.
+			s.saveAnchors(state)
+
 			// Disconnect all peers on server shutdown.
 			state.forAllPeers(func(sp *serverPeer) {
 				srvrLog.Tracef("Shutdown peer %s", sp)
@@ -2617,6 +3239,7 @@ cleanup:
 		case <-s.newPeers:
 		case <-s.donePeers:
 		case <-s.peerHeightsUpdate:
+		case <-s.peerMisbehaved:
 		case <-s.relayInv:
 		case <-s.broadcast:
 		case <-s.query:
@@ -2628,14 +3251,71 @@ cleanup:
 	srvrLog.Tracef("Peer handler done")
 }
 
+// saveAnchors saves the addresses of up to maxAnchors outbound peers which have
+// been connected the longest as anchors to reconnect to on the next start.
+// Persistent peers are reconnected to anyway and are not anchors.  It is
+// invoked from the peerHandler goroutine.
+func (s *server) saveAnchors(state *peerState) {
+	if len(cfg.ConnectPeers) > 0 {
+		return
+	}
+
+	peers := make([]*serverPeer, 0, len(state.outboundPeers))
+	for _, sp := range state.outboundPeers {
+		if sp.VerAckReceived() && sp.NA() != nil {
+			peers = append(peers, sp)
+		}
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].TimeConnected().Before(peers[j].TimeConnected())
+	})
+	if len(peers) > maxAnchors {
+		peers = peers[:maxAnchors]
+	}
+
+	anchors := make([]*wire.NetAddress, 0, len(peers))
+	for _, sp := range peers {
+		anchors = append(anchors, sp.NA())
+	}
+	if err := s.addrManager.SaveAnchors(anchors); err != nil {
+		srvrLog.Errorf("Unable to save anchors: %v", err)
+	}
+}
+
 // AddPeer adds a new peer that has already been connected to the server.
 func (s *server) AddPeer(sp *serverPeer) {
 	s.newPeers <- sp
 }
 
-// BanPeer bans a peer that has already been connected to the server by ip.
-func (s *server) BanPeer(sp *serverPeer) {
-	s.banPeers <- sp
+// BanPeer bans a peer that has already been connected to the server by ip
+// for the configured ban duration.
+func (s *server) BanPeer(sp *serverPeer, reason string) {
+	host, _, err := net.SplitHostPort(sp.Addr())
+	if err != nil {
+		srvrLog.Debugf("can't split ban peer %s %v", sp.Addr(), err)
+		return
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		srvrLog.Debugf("can't ban peer %s without an IP address", sp.Addr())
+		return
+	}
+	direction := directionString(sp.Inbound())
+	srvrLog.Infof("Banned peer %s (%s) for %v: %s", host, direction,
+		cfg.BanDuration, reason)
+	s.banManager.BanIP(ip, cfg.BanDuration, reason)
+	s.saveBanList()
+}
+
+// saveBanList stores the ban list in the database so bans survive restarts.
+func (s *server) saveBanList() {
+	err := s.db.Update(func(tx database.Tx) error {
+		return tx.Metadata().Put(connmgr.BanListDatabaseKey,
+			s.banManager.Serialize())
+	})
+	if err != nil {
+		srvrLog.Errorf("Unable to save ban list: %v", err)
+	}
 }
 
 // RelayInventory relays the passed inventory vector to all connected peers
@@ -2644,6 +3324,13 @@ func (s *server) RelayInventory(invVect *wire.InvVect, data interface{}) {
 	s.relayInv <- relayMsg{invVect: invVect, data: data}
 }
 
+// DiffuseInventory relays the passed inventory vector of a locally submitted
+// transaction to the connected peers not already known to have it, first to a
+// random subset of them and then to each of the others after a random delay.
+func (s *server) DiffuseInventory(invVect *wire.InvVect, data interface{}) {
+	s.relayInv <- relayMsg{invVect: invVect, data: data, diffuse: true}
+}
+
 // BroadcastMessage sends msg to all peers currently connected to the server
 // except those in the passed peers to exclude.
 func (s *server) BroadcastMessage(msg wire.Message, exclPeers ...*serverPeer) {
@@ -2660,6 +3347,18 @@ func (s *server) ConnectedCount() int32 {
 	return <-replyChan
 }
 
+// connectedPeers returns the connected peers of the server.  No peers are
+// returned once the server is shutting down.
+func (s *server) connectedPeers() []*serverPeer {
+	replyChan := make(chan []*serverPeer)
+	select {
+	case s.query <- getPeersMsg{reply: replyChan}:
+		return <-replyChan
+	case <-s.quit:
+		return nil
+	}
+}
+
 // OutboundGroupCount returns the number of peers connected to the given
 // outbound group key.
 func (s *server) OutboundGroupCount(key string) int {
@@ -2672,6 +3371,7 @@ func (s *server) OutboundGroupCount(key string) int {
 // for the server.  It is safe for concurrent access.
 func (s *server) AddBytesSent(bytesSent uint64) {
 	atomic.AddUint64(&s.bytesSent, bytesSent)
+	s.uploadTarget.AddBytesSent(bytesSent, time.Now())
 }
 
 // AddBytesReceived adds the passed number of bytes to the total bytes received
@@ -2687,6 +3387,14 @@ func (s *server) NetTotals() (uint64, uint64) {
 		atomic.LoadUint64(&s.bytesSent)
 }
 
+// BridgeDisconnects returns the number of times a bridge peer disconnected
+// since the server started.
+//
+// This function is safe for concurrent access.
+func (s *server) BridgeDisconnects() uint64 {
+	return atomic.LoadUint64(&s.bridgeDisconnects)
+}
+
 // UpdatePeerHeights updates the heights of all peers who have have announced
 // the latest connected main chain block, or a recognized orphan. These height
 // updates allow us to dynamically refresh peer heights, ensuring sync peer
@@ -2699,6 +3407,15 @@ func (s *server) UpdatePeerHeights(latestBlkHash *chainhash.Hash, latestHeight i
 	}
 }
 
+// PeerMisbehaved bans the passed peer for relaying data which violates the
+// consensus rules, unless it is exempt from banning.
+func (s *server) PeerMisbehaved(p *peer.Peer, reason string) {
+	s.peerMisbehaved <- peerMisbehavedMsg{
+		peer:   p,
+		reason: reason,
+	}
+}
+
 // rebroadcastHandler keeps track of user submitted inventories that we have
 // sent out but have not yet made it into a block. We periodically rebroadcast
 // them in case our peers restarted or otherwise lost track of them.
@@ -2726,10 +3443,11 @@ out:
 
 		case <-timer.C:
 			// Any inventory we have has not made it into a block
-			// yet. We periodically resubmit them until they have.
+			// yet. We periodically resubmit them until they have,
+			// diffusing them as when they were submitted.
 			for iv, data := range pendingInvs {
 				ivCopy := iv
-				s.RelayInventory(&ivCopy, data)
+				s.DiffuseInventory(&ivCopy, data)
 			}
 
 			// Process at a random time up to 30mins (in seconds)
@@ -2776,6 +3494,16 @@ func (s *server) Start() {
 		go s.upnpUpdateThread()
 	}
 
+	if s.onionTarget != "" {
+		s.wg.Add(1)
+		go s.onionServiceHandler()
+	}
+
+	if cfg.dbCompactWindow != nil {
+		s.wg.Add(1)
+		go s.dbMaintenanceHandler(cfg.dbCompactWindow)
+	}
+
 	if !cfg.DisableRPC {
 		s.wg.Add(1)
 
@@ -2789,6 +3517,34 @@ func (s *server) Start() {
 		}
 	}
 
+	if s.metricsServer != nil {
+		s.metricsServer.Start()
+	}
+
+	if s.bridgeServer != nil {
+		s.bridgeServer.Start()
+	}
+
+	if interval := sdWatchdogInterval(); interval > 0 {
+		s.wg.Add(1)
+		go s.watchdogHandler(interval)
+	}
+
+	// Start watching the chains the coins are entangled from so the
+	// entangles of the mempool which they invalidate are removed.
+	s.wg.Add(1)
+	go s.entangleWatchHandler()
+
+	if s.utxoSnapshot != nil {
+		go s.utxoSnapshot.verify()
+	}
+
+	// Start catching up the optional indexes which are behind the main
+	// chain.
+	if s.indexManager != nil {
+		s.indexManager.Start()
+	}
+
 	// Start the CPU miner if generation is enabled.
 	if cfg.Generate {
 		s.cpuMiner.Start()
@@ -2809,6 +3565,15 @@ func (s *server) Stop() error {
 	// Stop the CPU miner if needed
 	s.cpuMiner.Stop()
 
+	// Stop serving the metrics and the bridge status before the subsystems
+	// they are collected from are stopped.
+	if s.metricsServer != nil {
+		s.metricsServer.Stop()
+	}
+	if s.bridgeServer != nil {
+		s.bridgeServer.Stop()
+	}
+
 	// Shutdown the RPC server if it's not disabled.
 	if !cfg.DisableRPC {
 		s.rpcServer.Stop()
@@ -2817,10 +3582,16 @@ func (s *server) Stop() error {
 		}
 	}
 
-	// Save fee estimator state in the database.
+	// Stop catching up the optional indexes.
+	if s.indexManager != nil {
+		s.indexManager.Stop()
+	}
+
+	// Save fee estimator state and the ban list in the database.
 	s.db.Update(func(tx database.Tx) error {
 		metadata := tx.Metadata()
 		metadata.Put(mempool.EstimateFeeDatabaseKey, s.feeEstimator.Save())
+		metadata.Put(connmgr.BanListDatabaseKey, s.banManager.Serialize())
 
 		return nil
 	})
@@ -2919,6 +3690,63 @@ func parseListeners(addrs []string) ([]net.Addr, error) {
 	return netAddrs, nil
 }
 
+// onionServiceHandler creates an onion service through the Tor control port
+// which forwards inbound connections from the Tor network to the local
+// listener and advertises the onion address to peers.  The control connection
+// is kept open until the server shuts down, at which point Tor removes the
+// service.  The key of the service is kept in the data directory so the onion
+// address does not change across restarts.
+//
+// It must be run as a goroutine.
+func (s *server) onionServiceHandler() {
+	defer s.wg.Done()
+
+	port, _ := strconv.ParseUint(activeNetParams.DefaultPort, 10, 16)
+	keyFile := filepath.Join(cfg.DataDir, onionKeyFilename)
+
+	ctrl, err := connmgr.DialTorController(cfg.TorControl,
+		defaultConnectTimeout)
+	if err != nil {
+		srvrLog.Errorf("Unable to connect to the Tor control port: %v", err)
+		return
+	}
+	defer ctrl.Close()
+
+	if err := ctrl.Authenticate(cfg.TorPassword); err != nil {
+		srvrLog.Errorf("Unable to authenticate to the Tor control port: %v",
+			err)
+		return
+	}
+
+	var key string
+	if b, err := ioutil.ReadFile(keyFile); err == nil {
+		key = strings.TrimSpace(string(b))
+	}
+	host, newKey, err := ctrl.AddOnion(key, uint16(port), s.onionTarget)
+	if err != nil {
+		srvrLog.Errorf("Unable to create onion service: %v", err)
+		return
+	}
+	if newKey != key {
+		err := ioutil.WriteFile(keyFile, []byte(newKey+"\n"), 0600)
+		if err != nil {
+			srvrLog.Warnf("Unable to save onion service key: %v", err)
+		}
+	}
+
+	na, err := s.addrManager.HostToNetAddress(host, uint16(port), s.services)
+	if err == nil {
+		err = s.addrManager.AddLocalAddress(na, addrmgr.ManualPrio)
+	}
+	if err != nil {
+		srvrLog.Warnf("Unable to advertise onion address %s: %v", host, err)
+	}
+	srvrLog.Infof("Onion service %s forwarding to %s", net.JoinHostPort(host,
+		activeNetParams.DefaultPort), s.onionTarget)
+
+	<-s.quit
+}
+
 func (s *server) upnpUpdateThread() {
 	// Go off immediately to prevent code duplication, thereafter we renew
 	// lease every 15 minutes.
@@ -3033,8 +3861,18 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	if cfg.NoCFilters {
 		services &^= wire.SFNodeCF
 	}
+	if cfg.Graphene {
+		services |= wire.SFNodeGraphene
+	}
 
 	amgr := addrmgr.New(cfg.DataDir, czzdLookup)
+	if cfg.ASMap != "" {
+		asmap, err := addrmgr.LoadASMap(cfg.ASMap)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load asmap: %v", err)
+		}
+		amgr.SetASMap(asmap)
+	}
 
 	var listeners []net.Listener
 	var nat NAT
@@ -3049,13 +3887,17 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		}
 	}
 
+	evictionKey := make([]byte, 32)
+	if _, err := rand.Read(evictionKey); err != nil {
+		return nil, err
+	}
+
 	s := server{
 		startupTime:             time.Now().Unix(),
 		chainParams:             chainParams,
 		addrManager:             amgr,
 		newPeers:                make(chan *serverPeer, cfg.MaxPeers),
 		donePeers:               make(chan *serverPeer, cfg.MaxPeers),
-		banPeers:                make(chan *serverPeer, cfg.MaxPeers),
 		maybeAddDirectRelayPeer: make(chan *maybeAddDirectRelayPeerMsg),
 
 		query:                make(chan interface{}),
@@ -3065,7 +3907,12 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		quit:                 make(chan struct{}),
 		modifyRebroadcastInv: make(chan interface{}),
 		peerHeightsUpdate:    make(chan updatePeerHeightsMsg),
+		peerMisbehaved:       make(chan peerMisbehavedMsg),
 		nat:                  nat,
+		onionTarget:          onionTarget(listeners),
+		evictionKey:          evictionKey,
+		inboundLimiter:       connmgr.NewInboundLimiter(cfg.InboundRateLimit, cfg.MaxHalfOpen),
+		uploadTarget:         newUploadTarget(cfg.MaxUploadTarget * 1024 * 1024),
 		db:                   db,
 		timeSource:           blockchain.NewMedianTime(),
 		services:             services,
@@ -3100,16 +3947,36 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		s.addrIndex = indexers.NewAddrIndex(db, chainParams)
 		indexes = append(indexes, s.addrIndex)
 	}
+	if cfg.SpentIndex {
+		indxLog.Info("Spent output index is enabled")
+		s.spentIndex = indexers.NewSpentIndex(db)
+		indexes = append(indexes, s.spentIndex)
+	}
+	if cfg.ScriptTypeIndex {
+		indxLog.Info("Script type index is enabled")
+		s.scriptIndex = indexers.NewScriptTypeIndex(db, chainParams)
+		indexes = append(indexes, s.scriptIndex)
+	}
 	if !cfg.NoCFilters {
 		indxLog.Info("Committed filter index is enabled")
 		s.cfIndex = indexers.NewCfIndex(db, chainParams)
 		indexes = append(indexes, s.cfIndex)
 	}
+	if cfg.Wallet {
+		w, err := openWallet(db, chainParams)
+		if err != nil {
+			return nil, err
+		}
+		indxLog.Info("Wallet index is enabled")
+		s.wallet = w
+		indexes = append(indexes, s.wallet)
+	}
 
 	// Create an index manager if any of the optional indexes are enabled.
 	var indexManager blockchain.IndexManager
 	if len(indexes) > 0 {
-		indexManager = indexers.NewManager(db, indexes)
+		s.indexManager = indexers.NewManager(db, indexes)
+		indexManager = s.indexManager
 	}
 
 	// Merge given checkpoints with the default ones unless they are disabled.
@@ -3123,6 +3990,8 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	s.chain, err = blockchain.New(&blockchain.Config{
 		DB:                 s.db,
 		UtxoCacheMaxSize:   uint64(cfg.UtxoCacheMaxSizeMiB) * 1024 * 1024,
+		BlockCacheSize:     int(cfg.BlockCacheSize),
+		ScriptCacheSize:    cfg.ScriptCacheMaxSize,
 		Interrupt:          interrupt,
 		ChainParams:        s.chainParams,
 		Checkpoints:        checkpoints,
@@ -3133,9 +4002,11 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		ExcessiveBlockSize: cfg.ExcessiveBlockSize,
 		Prune:              cfg.Prune,
 		PruneDepth:         cfg.PruneDepth,
+		PersistTxMeta:      cfg.BlockTxMeta,
 		ReIndexChainState:  cfg.ReIndexChainState,
 		FastSync:           cfg.FastSync,
 		FastSyncDataDir:    cfg.DataDir,
+		FetchUtxoSnapshot:  s.fetchUtxoSnapshot,
 		Proxy:              cfg.Proxy,
 		DogeCoinRPC:        cfg.DogeCoinRPC,
 		DogeCoinRPCUser:    cfg.DogeCoinRPCUser,
@@ -3153,6 +4024,14 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		s.services |= wire.SFNodeNetworkLimited
 	}
 
+	if cfg.UtxoSnapshot != "" {
+		s.utxoSnapshot, err = openUtxoSnapshot(cfg.UtxoSnapshot,
+			s.chain.LatestCheckpoint())
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Search for a FeeEstimator state in the database. If none can be found
 	// or if it cannot be loaded, create a new one.
 	db.Update(func(tx database.Tx) error {
@@ -3183,16 +4062,32 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 			mempool.DefaultEstimateFeeMinRegisteredBlocks)
 	}
 
+	// Restore the ban list from the database.
+	s.banManager = connmgr.NewBanManager()
+	db.View(func(tx database.Tx) error {
+		banList := tx.Metadata().Get(connmgr.BanListDatabaseKey)
+		if banList == nil {
+			return nil
+		}
+		if err := s.banManager.Deserialize(banList); err != nil {
+			srvrLog.Errorf("Failed to restore ban list: %v", err)
+		}
+		return nil
+	})
+
 	txC := mempool.Config{
 		Policy: mempool.Policy{
-			DisableRelayPriority: cfg.NoRelayPriority,
-			AcceptNonStd:         cfg.RelayNonStd,
-			FreeTxRelayLimit:     cfg.FreeTxRelayLimit,
-			MaxOrphanTxs:         cfg.MaxOrphanTxs,
-			MaxOrphanTxSize:      defaultMaxOrphanTxSize,
-			MaxSigOpPerTx:        blockchain.MaxTransactionSigOps,
-			MinRelayTxFee:        cfg.minRelayTxFee,
-			MaxTxVersion:         2,
+			DisableRelayPriority:  cfg.NoRelayPriority,
+			AcceptNonStd:          cfg.RelayNonStd,
+			FreeTxRelayLimit:      cfg.FreeTxRelayLimit,
+			MaxOrphanTxs:          cfg.MaxOrphanTxs,
+			MaxOrphanTxSize:       defaultMaxOrphanTxSize,
+			MaxSigOpPerTx:         blockchain.MaxTransactionSigOps,
+			MinRelayTxFee:         cfg.minRelayTxFee,
+			MaxTxVersion:          2,
+			MaxDataCarrierSize:    cfg.DataCarrierSize,
+			MaxDataCarrierOutputs: cfg.MaxDataCarrierOutputs,
+			Denylist:              cfg.denylist,
 		},
 		ChainParams:           chainParams,
 		FetchUtxoView:         s.chain.FetchUtxoView,
@@ -3203,11 +4098,12 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		CalcSequenceLock: func(tx *czzutil.Tx, view *blockchain.UtxoViewpoint) (*blockchain.SequenceLock, error) {
 			return s.chain.CalcSequenceLock(tx, view, true)
 		},
-		IsDeploymentActive: s.chain.IsDeploymentActive,
-		SigCache:           s.sigCache,
-		HashCache:          s.hashCache,
-		AddrIndex:          s.addrIndex,
-		FeeEstimator:       s.feeEstimator,
+		IsDeploymentActive:      s.chain.IsDeploymentActive,
+		CheckTransactionScripts: s.chain.CheckTransactionScripts,
+		SigCache:                s.sigCache,
+		HashCache:               s.hashCache,
+		AddrIndex:               s.addrIndex,
+		FeeEstimator:            s.feeEstimator,
 	}
 	s.txMemPool = mempool.New(&txC)
 
@@ -3227,6 +4123,7 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		FeeEstimator:            s.feeEstimator,
 		MinSyncPeerNetworkSpeed: cfg.MinSyncPeerNetworkSpeed,
 		FastSyncMode:            cfg.FastSync,
+		Graphene:                cfg.Graphene,
 	})
 	if err != nil {
 		return nil, err
@@ -3246,6 +4143,18 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	blockTemplateGenerator := mining.NewBlkTmplGenerator(&policy,
 		s.chainParams, s.txMemPool, s.chain, s.timeSource,
 		s.sigCache, s.hashCache)
+
+	// Pay the generated blocks to a new address of the wallet as well when
+	// the keys of the payouts are to be kept in the wallet file.  The wallet
+	// was initialized along with the chain.
+	if cfg.MiningWallet {
+		addr, err := walletMiningAddr(s.wallet)
+		if err != nil {
+			return nil, err
+		}
+		srvrLog.Infof("Generated blocks pay to wallet address %v", addr)
+		cfg.miningAddrs = append(cfg.miningAddrs, addr)
+	}
 	s.cpuMiner = cpuminer.New(&cpuminer.Config{
 		ChainParams:            chainParams,
 		BlockTemplateGenerator: blockTemplateGenerator,
@@ -3253,6 +4162,7 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		ProcessBlock:           s.syncManager.ProcessBlock,
 		ConnectedCount:         s.ConnectedCount,
 		IsCurrent:              s.syncManager.IsCurrent,
+		Signer:                 cfg.blockSigner,
 	})
 
 	// Only setup a function to return new addresses to connect to when
@@ -3276,17 +4186,44 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 				// in the same group so that we are not connecting
 				// to the same network segment at the expense of
 				// others.
-				key := addrmgr.GroupKey(addr.NetAddress())
+				key := s.addrManager.GroupKey(addr.NetAddress())
 				if s.OutboundGroupCount(key) != 0 {
 					continue
 				}
 
+				// I2P addresses are only kept to be relayed to
+				// other peers since they can not be dialed.
+				if addrmgr.IsI2P(addr.NetAddress()) {
+					continue
+				}
+
+				// Do not connect to banned addresses.
+				if _, banned := s.banManager.IsBanned(addr.NetAddress().IP); banned {
+					continue
+				}
+
+				// Prefer onion peers in anonymity mode and only
+				// fall back to other addresses after 40 tries.
+				if cfg.TorAnonymous && tries < 40 &&
+					!addrmgr.IsTor(addr.NetAddress()) {
+					continue
+				}
+
 				// only allow recent nodes (10mins) after we failed 30
 				// times
 				if tries < 30 && time.Since(addr.LastAttempt()) < 10*time.Minute {
 					continue
 				}
 
+				// Prefer the addresses which advertised serving
+				// the full chain the last time they were seen
+				// and only fall back to other addresses after 20
+				// tries.
+				if tries < 20 &&
+					addr.Services()&wire.SFNodeNetwork == 0 {
+					continue
+				}
+
 				// allow nondefault ports after 50 failed tries.
 				if tries < 50 && fmt.Sprintf("%d", addr.NetAddress().Port) !=
 					activeNetParams.DefaultPort {
@@ -3311,7 +4248,7 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		OnAccept:       s.inboundPeerConnected,
 		RetryDuration:  connectionRetryInterval,
 		TargetOutbound: targetOutbound,
-		Dial:           czzdDial,
+		Dial:           s.dialOutbound,
 		OnConnection:   s.outboundPeerConnected,
 		GetNewAddress:  newAddressFunc,
 	})
@@ -3337,6 +4274,26 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		})
 	}
 
+	// Start up the bridge peers.  They are recognized by the host they
+	// resolve to, so their inbound connections are recognized as well.
+	s.bridgeHosts = make(map[string]struct{}, len(cfg.BridgePeers))
+	for _, addr := range cfg.BridgePeers {
+		netAddr, err := addrStringToNetAddr(addr)
+		if err != nil {
+			return nil, err
+		}
+		host, _, err := net.SplitHostPort(netAddr.String())
+		if err != nil {
+			return nil, err
+		}
+		s.bridgeHosts[host] = struct{}{}
+
+		go s.connManager.Connect(&connmgr.ConnReq{
+			Addr:      netAddr,
+			Permanent: true,
+		})
+	}
+
 	if !cfg.DisableRPC {
 		// Setup listeners for the configured RPC listen addresses and
 		// TLS settings.
@@ -3353,6 +4310,8 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 			StartupTime:  s.startupTime,
 			ConnMgr:      &rpcConnManager{&s},
 			SyncMgr:      &rpcSyncMgr{&s, s.syncManager},
+			AddrManager:  s.addrManager,
+			Services:     s.services,
 			TimeSource:   s.timeSource,
 			Chain:        s.chain,
 			ChainParams:  chainParams,
@@ -3360,10 +4319,15 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 			TxMemPool:    s.txMemPool,
 			Generator:    blockTemplateGenerator,
 			CPUMiner:     s.cpuMiner,
+			IndexManager: s.indexManager,
 			TxIndex:      s.txIndex,
 			AddrIndex:    s.addrIndex,
+			SpentIndex:   s.spentIndex,
+			ScriptIndex:  s.scriptIndex,
 			CfIndex:      s.cfIndex,
+			Wallet:       s.wallet,
 			FeeEstimator: s.feeEstimator,
+			ReloadConfig: s.reloadConfig,
 		})
 		if err != nil {
 			return nil, err
@@ -3395,6 +4359,30 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		}()
 	}
 
+	// Setup the metrics server if it is enabled.
+	if len(cfg.MetricsListeners) > 0 {
+		metricsListeners, err := setupHTTPListeners(cfg.MetricsListeners)
+		if err != nil {
+			return nil, err
+		}
+		if len(metricsListeners) == 0 {
+			return nil, errors.New("metrics: No valid listen address")
+		}
+		s.metricsServer = newMetricsServer(&s, metricsListeners)
+	}
+
+	// Setup the bridge status server if it is enabled.
+	if len(cfg.BridgeListeners) > 0 {
+		bridgeListeners, err := setupHTTPListeners(cfg.BridgeListeners)
+		if err != nil {
+			return nil, err
+		}
+		if len(bridgeListeners) == 0 {
+			return nil, errors.New("bridge: No valid listen address")
+		}
+		s.bridgeServer = newBridgeServer(&s, bridgeListeners)
+	}
+
 	return &s, nil
 }
 
@@ -3446,6 +4434,12 @@ func initListeners(amgr *addrmgr.AddrManager, listenAddrs []string, services wir
 				srvrLog.Warnf("Not adding %s as externalip: %v", sip, err)
 				continue
 			}
+			if cfg.TorAnonymous && !addrmgr.IsTor(na) {
+				srvrLog.Warnf("Not adding %s as externalip: only "+
+					"onion addresses are advertised in anonymity "+
+					"mode", sip)
+				continue
+			}
 
 			// Found a valid external IP, make sure we use these details
 			// so peers get the correct IP information. Since we can only
@@ -3486,7 +4480,13 @@ func initListeners(amgr *addrmgr.AddrManager, listenAddrs []string, services wir
 		}
 
 		// Add bound addresses to address manager to be advertised to peers.
+		// They are never advertised in anonymity mode since they would
+		// reveal the address of the node.
 		for _, listener := range listeners {
+			if cfg.TorAnonymous {
+				break
+			}
+
 			addr := listener.Addr().String()
 			err := addLocalAddress(amgr, addr, services)
 			if err != nil {
@@ -3546,6 +4546,29 @@ func addrStringToNetAddr(addr string) (net.Addr, error) {
 	}, nil
 }
 
+// onionTarget returns the address the onion service created through the Tor
+// control port forwards connections to, or an empty string when no onion
+// service is to be created.  Listeners bound to an unspecified address are
+// reached through the loopback interface.
+func onionTarget(listeners []net.Listener) string {
+	if cfg.TorControl == "" || len(listeners) == 0 {
+		return ""
+	}
+
+	addr, ok := listeners[0].Addr().(*net.TCPAddr)
+	if !ok {
+		return listeners[0].Addr().String()
+	}
+	ip := addr.IP
+	if ip.IsUnspecified() {
+		ip = net.IPv4(127, 0, 0, 1)
+		if addr.IP.To4() == nil {
+			ip = net.IPv6loopback
+		}
+	}
+	return net.JoinHostPort(ip.String(), strconv.Itoa(addr.Port))
+}
+
 // addLocalAddress adds an address that this node is listening on to the
 // address manager so that it may be relayed to peers.
 func addLocalAddress(addrMgr *addrmgr.AddrManager, addr string, services wire.ServiceFlag) error {
@@ -3614,30 +4637,66 @@ func dynamicTickDuration(remaining time.Duration) time.Duration {
 	return time.Hour
 }
 
-// isWhitelisted returns whether the IP address is included in the whitelisted
-// networks and IPs.
-func isWhitelisted(addr net.Addr) bool {
-	if len(cfg.whitelists) == 0 {
+// isBridgeAddr returns whether the passed address is the one of a bridge peer
+// configured with the bridgepeer option.  Only the host is compared since the
+// inbound connections come from other ports.
+func (s *server) isBridgeAddr(addr net.Addr) bool {
+	if len(s.bridgeHosts) == 0 || addr == nil {
 		return false
 	}
-
 	host, _, err := net.SplitHostPort(addr.String())
 	if err != nil {
-		srvrLog.Warnf("Unable to SplitHostPort on '%s': %v", addr, err)
 		return false
 	}
+	_, ok := s.bridgeHosts[host]
+	return ok
+}
+
+// whitelistPermissions returns the permissions granted to the peer connected
+// through conn by the whitelisted networks and, for inbound peers, by the
+// whitelisted listen addresses.
+func whitelistPermissions(conn net.Conn, inbound bool) peer.Permissions {
+	var perms peer.Permissions
+	if len(cfg.whitelists) == 0 && len(cfg.whitebinds) == 0 {
+		return perms
+	}
+
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		srvrLog.Warnf("Unable to SplitHostPort on '%s': %v",
+			conn.RemoteAddr(), err)
+		return perms
+	}
 	ip := net.ParseIP(host)
 	if ip == nil {
-		srvrLog.Warnf("Unable to parse IP '%s'", addr)
-		return false
+		srvrLog.Warnf("Unable to parse IP '%s'", conn.RemoteAddr())
+		return perms
+	}
+	for _, wl := range cfg.whitelists {
+		if wl.ipnet.Contains(ip) {
+			perms |= wl.perms
+		}
 	}
 
-	for _, ipnet := range cfg.whitelists {
-		if ipnet.Contains(ip) {
-			return true
+	if !inbound {
+		return perms
+	}
+	localHost, localPort, err := net.SplitHostPort(conn.LocalAddr().String())
+	if err != nil {
+		return perms
+	}
+	localIP := net.ParseIP(localHost)
+	for _, wb := range cfg.whitebinds {
+		bindHost, bindPort, err := net.SplitHostPort(wb.addr)
+		if err != nil || bindPort != localPort {
+			continue
+		}
+		bindIP := net.ParseIP(bindHost)
+		if bindIP == nil || bindIP.IsUnspecified() || bindIP.Equal(localIP) {
+			perms |= wb.perms
 		}
 	}
-	return false
+	return perms
 }
 
 // checkpointSorter implements sort.Interface to allow a slice of checkpoints to
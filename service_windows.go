@@ -28,6 +28,17 @@ const (
 	// svcDesc is the description of the service.
 	svcDesc = "Downloads and stays synchronized with the bitcoin block " +
 		"chain and provides chain services to applications."
+
+	// svcWaitHint is the time, in milliseconds, the service control manager
+	// is told to wait for the next progress report of a pending start or
+	// stop.
+	svcWaitHint = 30000
+
+	// svcCheckPointInterval is the interval at which the progress of a
+	// pending start or stop is reported to the service control manager,
+	// so a start catching up the chain state or a stop flushing it is not
+	// considered hung.
+	svcCheckPointInterval = 5 * time.Second
 )
 
 // elog is used to send messages to the Windows event log.
@@ -54,9 +65,14 @@ type czzdService struct{}
 // long-running czzdMain (which is the real meat of classzz), handles service
 // change requests, and notifies the service control manager of changes.
 func (s *czzdService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
-	// Service start is pending.
+	// Service start is pending until the main server is started.
 	const cmdsAccepted = svc.AcceptStop | svc.AcceptShutdown
-	changes <- svc.Status{State: svc.StartPending}
+	status := svc.Status{
+		State:    svc.StartPending,
+		Accepts:  cmdsAccepted,
+		WaitHint: svcWaitHint,
+	}
+	changes <- status
 
 	// Start czzdMain in a separate goroutine so the service can start
 	// quickly.  Shutdown (along with a potential error) is reported via
@@ -69,8 +85,8 @@ func (s *czzdService) Execute(args []string, r <-chan svc.ChangeRequest, changes
 		doneChan <- err
 	}()
 
-	// Service is now started.
-	changes <- svc.Status{State: svc.Running, Accepts: cmdsAccepted}
+	ticker := time.NewTicker(svcCheckPointInterval)
+	defer ticker.Stop()
 
 	var mainServer *server
 loop:
@@ -82,9 +98,17 @@ loop:
 				changes <- c.CurrentStatus
 
 			case svc.Stop, svc.Shutdown:
+				if status.State == svc.StopPending {
+					continue
+				}
+
 				// Service stop is pending.  Don't accept any
 				// more commands while pending.
-				changes <- svc.Status{State: svc.StopPending}
+				status = svc.Status{
+					State:    svc.StopPending,
+					WaitHint: svcWaitHint,
+				}
+				changes <- status
 
 				// Signal the main function to exit.
 				shutdownRequestChannel <- struct{}{}
@@ -98,6 +122,25 @@ loop:
 			mainServer = srvr
 			logServiceStartOfDay(mainServer)
 
+			// Service is now started unless a stop is already
+			// pending.
+			if status.State == svc.StartPending {
+				status = svc.Status{
+					State:   svc.Running,
+					Accepts: cmdsAccepted,
+				}
+				changes <- status
+			}
+
+		case <-ticker.C:
+			// Report the progress of a pending start or stop.
+			if status.State == svc.StartPending ||
+				status.State == svc.StopPending {
+
+				status.CheckPoint++
+				changes <- status
+			}
+
 		case err := <-doneChan:
 			if err != nil {
 				elog.Error(1, err.Error())
@@ -0,0 +1,51 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"time"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+)
+
+// shutdownServer stops the server in order: the miner and the RPC servers
+// first so no new work arrives, then the peers and the sync manager, which
+// flushes the chain state on its way out.  The caller closes the database
+// afterwards.
+//
+// When the server did not stop within the passed timeout, for instance because
+// of a hung peer, the chain state is flushed regardless so it is not lost when
+// the database is closed.  A zero timeout waits for the server forever.
+func shutdownServer(s *server, timeout time.Duration) {
+	done := make(chan struct{})
+	defer close(done)
+	go sdExtendTimeout(done)
+	sdNotify("STOPPING=1")
+
+	s.Stop()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.WaitForShutdown()
+		close(stopped)
+	}()
+	var timeoutChan <-chan time.Time
+	if timeout > 0 {
+		timeoutChan = time.After(timeout)
+	}
+	select {
+	case <-stopped:
+		srvrLog.Infof("Server shutdown complete")
+
+	case <-timeoutChan:
+		srvrLog.Errorf("The server did not stop within %v -- flushing "+
+			"the chain state regardless", timeout)
+		err := s.chain.FlushCachedState(blockchain.FlushRequired)
+		if err != nil {
+			srvrLog.Errorf("Error while flushing blockchain caches: %v",
+				err)
+		}
+	}
+}
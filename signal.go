@@ -17,6 +17,10 @@ var shutdownRequestChannel = make(chan struct{})
 // shutdown.  This may be modified during init depending on the platform.
 var interruptSignals = []os.Signal{os.Interrupt}
 
+// reloadSignals defines the signals to catch in order to reload the
+// configuration.  This may be modified during init depending on the platform.
+var reloadSignals []os.Signal
+
 // interruptListener listens for OS Signals such as SIGINT (Ctrl+C) and shutdown
 // requests from shutdownRequestChannel.  It returns a channel that is closed
 // when either signal is received.
@@ -57,6 +61,32 @@ func interruptListener() <-chan struct{} {
 	return c
 }
 
+// reloadListener calls the passed function whenever one of the reloadSignals is
+// received until the passed channel is closed.
+func reloadListener(reload func(), quit <-chan struct{}) {
+	if len(reloadSignals) == 0 {
+		return
+	}
+
+	go func() {
+		reloadChannel := make(chan os.Signal, 1)
+		signal.Notify(reloadChannel, reloadSignals...)
+		defer signal.Stop(reloadChannel)
+
+		for {
+			select {
+			case sig := <-reloadChannel:
+				czzdLog.Infof("Received signal (%s).  Reloading "+
+					"the configuration...", sig)
+				reload()
+
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
 // interruptRequested returns true when the channel returned by
 // interruptListener was closed.  This simplifies early shutdown slightly since
 // the caller can just use an if statement instead of a select.
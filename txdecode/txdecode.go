@@ -0,0 +1,248 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package txdecode decodes serialized blocks and transactions into the JSON
+// objects returned by the RPC server, along with the entangle and keeped
+// amount data their outputs carry.  It needs no running node nor block
+// database, so it can be used to analyze blocks and transactions offline.
+package txdecode
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/btcjson"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/cross"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// Entangle is the entangle data carried by an entangle output.
+type Entangle struct {
+	ExTxType   string   `json:"extxtype"`
+	ExtTxHash  string   `json:"exttxhash"`
+	ExtTxIndex uint32   `json:"exttxindex"`
+	ExtHeight  uint64   `json:"extheight"`
+	Amount     *big.Int `json:"amount"`
+}
+
+// KeepedItem is the total amount entangled from a chain as committed by a
+// keeped amount output of a coinbase transaction.
+type KeepedItem struct {
+	ExTxType string   `json:"extxtype"`
+	Amount   *big.Int `json:"amount"`
+}
+
+// Vout is an output of a decoded transaction.  Entangle and KeepedAmount are
+// set for the outputs carrying entangle and keeped amount data, and
+// DecodeError tells why the data they carry could not be decoded.
+type Vout struct {
+	btcjson.Vout
+	Entangle     *Entangle    `json:"entangle,omitempty"`
+	KeepedAmount []KeepedItem `json:"keepedamount,omitempty"`
+	DecodeError  string       `json:"decodeerror,omitempty"`
+}
+
+// Tx is a decoded transaction.
+type Tx struct {
+	Txid     string        `json:"txid"`
+	Size     int           `json:"size"`
+	Version  int32         `json:"version"`
+	LockTime uint32        `json:"locktime"`
+	Vin      []btcjson.Vin `json:"vin"`
+	Vout     []Vout        `json:"vout"`
+}
+
+// Block is a decoded block.
+type Block struct {
+	Hash         string `json:"hash"`
+	Size         int    `json:"size"`
+	Version      int32  `json:"version"`
+	VersionHex   string `json:"versionHex"`
+	PreviousHash string `json:"previousblockhash"`
+	MerkleRoot   string `json:"merkleroot"`
+	CIDRoot      string `json:"cidroot"`
+	Time         int64  `json:"time"`
+	Bits         string `json:"bits"`
+	Nonce        uint64 `json:"nonce"`
+	MerkleValid  bool   `json:"merklevalid"`
+	Tx           []Tx   `json:"tx"`
+}
+
+// decodeVin returns the JSON object of the passed input of the passed
+// transaction.
+func decodeVin(mtx *wire.MsgTx, i int) btcjson.Vin {
+	txIn := mtx.TxIn[i]
+	if i == 0 && blockchain.IsCoinBaseTx(mtx) {
+		return btcjson.Vin{
+			Coinbase: hex.EncodeToString(txIn.SignatureScript),
+			Sequence: txIn.Sequence,
+		}
+	}
+
+	// The disassembled string will contain [error] inline if the script
+	// doesn't fully parse, so ignore the error here.
+	disbuf, _ := txscript.DisasmString(txIn.SignatureScript)
+	return btcjson.Vin{
+		Txid:     txIn.PreviousOutPoint.Hash.String(),
+		Vout:     txIn.PreviousOutPoint.Index,
+		Sequence: txIn.Sequence,
+		ScriptSig: &btcjson.ScriptSig{
+			Asm: disbuf,
+			Hex: hex.EncodeToString(txIn.SignatureScript),
+		},
+	}
+}
+
+// decodeVout returns the JSON object of the passed output.
+func decodeVout(txOut *wire.TxOut, n uint32, params *chaincfg.Params) Vout {
+	// The disassembled string will contain [error] inline if the script
+	// doesn't fully parse, so ignore the error here.
+	disbuf, _ := txscript.DisasmString(txOut.PkScript)
+
+	// Ignore the error here since an error means the script couldn't
+	// parse and there is no additional information about it anyways.
+	scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(
+		txOut.PkScript, params)
+	encodedAddrs := make([]string, len(addrs))
+	for i, addr := range addrs {
+		encodedAddrs[i] = addr.EncodeAddress()
+	}
+
+	var vout Vout
+	vout.N = n
+	vout.Value = czzutil.Amount(txOut.Value).ToCZZ()
+	vout.ScriptPubKey.Addresses = encodedAddrs
+	vout.ScriptPubKey.Asm = disbuf
+	vout.ScriptPubKey.Hex = hex.EncodeToString(txOut.PkScript)
+	vout.ScriptPubKey.Type = scriptClass.String()
+	vout.ScriptPubKey.ReqSigs = int32(reqSigs)
+
+	// The outputs not carrying entangle data fail with ErrNotEntangleScript
+	// while the malformed ones fail with other errors, which are reported
+	// since they are what the analysis is usually after.
+	info, err := cross.EntangleTxFromScript(txOut.PkScript)
+	switch {
+	case err == nil:
+		vout.Entangle = &Entangle{
+			ExTxType:   info.ExTxType.String(),
+			ExtTxHash:  string(info.ExtTxHash),
+			ExtTxIndex: info.Index,
+			ExtHeight:  info.Height,
+			Amount:     info.Amount,
+		}
+	case !txscript.IsErrorCode(err, txscript.ErrNotEntangleScript):
+		vout.DecodeError = err.Error()
+	}
+
+	if txscript.IsKeepedAmountScript(txOut.PkScript) {
+		keepedAmount, err := cross.KeepedAmountFromScript(txOut.PkScript)
+		if err != nil {
+			vout.DecodeError = err.Error()
+			return vout
+		}
+		vout.KeepedAmount = make([]KeepedItem, 0, len(keepedAmount.Items))
+		for _, item := range keepedAmount.Items {
+			vout.KeepedAmount = append(vout.KeepedAmount, KeepedItem{
+				ExTxType: item.ExTxType.String(),
+				Amount:   item.Amount,
+			})
+		}
+	}
+	return vout
+}
+
+// DecodeTx returns the JSON object of the passed transaction.  The addresses
+// of its outputs are encoded for the network of the passed parameters.
+func DecodeTx(mtx *wire.MsgTx, params *chaincfg.Params) *Tx {
+	tx := &Tx{
+		Txid:     mtx.TxHash().String(),
+		Size:     mtx.SerializeSize(),
+		Version:  mtx.Version,
+		LockTime: mtx.LockTime,
+		Vin:      make([]btcjson.Vin, len(mtx.TxIn)),
+		Vout:     make([]Vout, len(mtx.TxOut)),
+	}
+	for i := range mtx.TxIn {
+		tx.Vin[i] = decodeVin(mtx, i)
+	}
+	for i, txOut := range mtx.TxOut {
+		tx.Vout[i] = decodeVout(txOut, uint32(i), params)
+	}
+	return tx
+}
+
+// DecodeBlock returns the JSON object of the passed block and of all of its
+// transactions.  The merkle root is checked against the transactions so a
+// corrupted block is told apart, but the block is not otherwise validated.
+func DecodeBlock(msgBlock *wire.MsgBlock, params *chaincfg.Params) *Block {
+	header := &msgBlock.Header
+	block := &Block{
+		Hash:         header.BlockHash().String(),
+		Size:         msgBlock.SerializeSize(),
+		Version:      header.Version,
+		VersionHex:   fmt.Sprintf("%08x", header.Version),
+		PreviousHash: header.PrevBlock.String(),
+		MerkleRoot:   header.MerkleRoot.String(),
+		CIDRoot:      header.CIDRoot.String(),
+		Time:         header.Timestamp.Unix(),
+		Bits:         fmt.Sprintf("%08x", header.Bits),
+		Nonce:        header.Nonce,
+		Tx:           make([]Tx, len(msgBlock.Transactions)),
+	}
+	for i, mtx := range msgBlock.Transactions {
+		block.Tx[i] = *DecodeTx(mtx, params)
+	}
+
+	if len(msgBlock.Transactions) > 0 {
+		merkleRoot := blockchain.CalcMerkleRoot(
+			czzutil.NewBlock(msgBlock).Transactions())
+		block.MerkleValid = merkleRoot == header.MerkleRoot
+	}
+	return block
+}
+
+// decodeHex returns the bytes of the passed hex string, ignoring the white
+// space around it and padding it to an even length.
+func decodeHex(hexStr string) ([]byte, error) {
+	hexStr = strings.TrimSpace(hexStr)
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	return hex.DecodeString(hexStr)
+}
+
+// DecodeTxHex deserializes the transaction of the passed hex string and
+// returns its JSON object.
+func DecodeTxHex(hexStr string, params *chaincfg.Params) (*Tx, error) {
+	serializedTx, err := decodeHex(hexStr)
+	if err != nil {
+		return nil, err
+	}
+	var mtx wire.MsgTx
+	if err := mtx.Deserialize(bytes.NewReader(serializedTx)); err != nil {
+		return nil, fmt.Errorf("TX decode failed: %v", err)
+	}
+	return DecodeTx(&mtx, params), nil
+}
+
+// DecodeBlockHex deserializes the block of the passed hex string and returns
+// its JSON object.
+func DecodeBlockHex(hexStr string, params *chaincfg.Params) (*Block, error) {
+	serializedBlock, err := decodeHex(hexStr)
+	if err != nil {
+		return nil, err
+	}
+	var msgBlock wire.MsgBlock
+	if err := msgBlock.Deserialize(bytes.NewReader(serializedBlock)); err != nil {
+		return nil, fmt.Errorf("block decode failed: %v", err)
+	}
+	return DecodeBlock(&msgBlock, params), nil
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txdecode
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+)
+
+// TestDecodeTx ensures the entangle and keeped amount data of the outputs of
+// a transaction are decoded, and the malformed ones reported.
+func TestDecodeTx(t *testing.T) {
+	extTxHash := []byte(strings.Repeat("ab", 32))
+	entangleScript, err := txscript.NewEntangleScript(&txscript.EntangleData{
+		ExTxType:  txscript.EntangleTypeDoge,
+		Index:     2,
+		Height:    100,
+		Amount:    big.NewInt(5000),
+		ExtTxHash: extTxHash,
+	})
+	if err != nil {
+		t.Fatalf("NewEntangleScript: unexpected error: %v", err)
+	}
+	keepedScript, err := txscript.NewKeepedAmountScript(&txscript.KeepedAmountData{
+		Items: []txscript.KeepedAmountItem{{
+			ExTxType: txscript.EntangleTypeLtc,
+			Amount:   big.NewInt(7000),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("NewKeepedAmountScript: unexpected error: %v", err)
+	}
+	badScript, err := txscript.EntangleScript([]byte{txscript.EntangleTypeDoge})
+	if err != nil {
+		t.Fatalf("EntangleScript: unexpected error: %v", err)
+	}
+
+	mtx := wire.NewMsgTx(wire.TxVersion)
+	mtx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, []byte{0x51}))
+	mtx.AddTxOut(wire.NewTxOut(0, entangleScript))
+	mtx.AddTxOut(wire.NewTxOut(0, keepedScript))
+	mtx.AddTxOut(wire.NewTxOut(0, badScript))
+	mtx.AddTxOut(wire.NewTxOut(1e8, []byte{txscript.OP_TRUE}))
+
+	var buf bytes.Buffer
+	if err := mtx.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	tx, err := DecodeTxHex(hex.EncodeToString(buf.Bytes())+"\n",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("DecodeTxHex: unexpected error: %v", err)
+	}
+	if tx.Txid != mtx.TxHash().String() || len(tx.Vout) != 4 {
+		t.Fatalf("DecodeTxHex: unexpected transaction %+v", tx)
+	}
+
+	entangle := tx.Vout[0].Entangle
+	if entangle == nil || entangle.ExTxType != "doge" ||
+		entangle.ExtTxHash != string(extTxHash) ||
+		entangle.ExtTxIndex != 2 || entangle.ExtHeight != 100 ||
+		entangle.Amount.Int64() != 5000 {
+
+		t.Errorf("unexpected entangle data %+v", entangle)
+	}
+	keeped := tx.Vout[1].KeepedAmount
+	if len(keeped) != 1 || keeped[0].ExTxType != "ltc" ||
+		keeped[0].Amount.Int64() != 7000 {
+
+		t.Errorf("unexpected keeped amount %+v", keeped)
+	}
+	if tx.Vout[2].Entangle != nil || tx.Vout[2].DecodeError == "" {
+		t.Errorf("malformed entangle output decoded as %+v", tx.Vout[2])
+	}
+	if last := tx.Vout[3]; last.Entangle != nil ||
+		last.KeepedAmount != nil || last.DecodeError != "" {
+
+		t.Errorf("regular output decoded as %+v", last)
+	}
+
+	if _, err := DecodeTxHex("zz", &chaincfg.MainNetParams); err == nil {
+		t.Errorf("DecodeTxHex: no error for invalid hex")
+	}
+	if _, err := DecodeTxHex("0100", &chaincfg.MainNetParams); err == nil {
+		t.Errorf("DecodeTxHex: no error for a truncated transaction")
+	}
+}
+
+// TestDecodeBlock ensures the genesis block decodes with a valid merkle root
+// and a corrupted one is told apart.
+func TestDecodeBlock(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	var buf bytes.Buffer
+	if err := params.GenesisBlock.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	block, err := DecodeBlockHex(hex.EncodeToString(buf.Bytes()), params)
+	if err != nil {
+		t.Fatalf("DecodeBlockHex: unexpected error: %v", err)
+	}
+	if block.Hash != params.GenesisBlock.BlockHash().String() {
+		t.Errorf("got hash %s, want %s", block.Hash,
+			params.GenesisBlock.BlockHash())
+	}
+	if !block.MerkleValid || len(block.Tx) != 1 ||
+		block.Tx[0].Vin[0].Coinbase == "" {
+
+		t.Errorf("unexpected genesis block %+v", block)
+	}
+
+	corrupted := *params.GenesisBlock
+	corrupted.Header.MerkleRoot[0] ^= 0xff
+	if block := DecodeBlock(&corrupted, params); block.MerkleValid {
+		t.Errorf("corrupted merkle root reported valid")
+	}
+}
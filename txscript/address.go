@@ -0,0 +1,46 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// ToCashAddress returns the cashaddr form of the passed address for the
+// passed network.  Legacy base58 pay-to-pubkey-hash and pay-to-script-hash
+// addresses are converted to the equivalent cashaddr type, which pays to the
+// same script; all other addresses are returned unchanged.
+func ToCashAddress(addr czzutil.Address, net *chaincfg.Params) czzutil.Address {
+	switch a := addr.(type) {
+	case *czzutil.LegacyAddressPubKeyHash:
+		if cash, err := czzutil.NewAddressPubKeyHash(a.ScriptAddress(), net); err == nil {
+			return cash
+		}
+	case *czzutil.LegacyAddressScriptHash:
+		if cash, err := czzutil.NewAddressScriptHashFromHash(a.ScriptAddress(), net); err == nil {
+			return cash
+		}
+	}
+	return addr
+}
+
+// ToLegacyAddress returns the legacy base58 form of the passed address for
+// the passed network.  Cashaddr pay-to-pubkey-hash and pay-to-script-hash
+// addresses are converted to the equivalent legacy type, which pays to the
+// same script; all other addresses are returned unchanged.
+func ToLegacyAddress(addr czzutil.Address, net *chaincfg.Params) czzutil.Address {
+	switch a := addr.(type) {
+	case *czzutil.AddressPubKeyHash:
+		if legacy, err := czzutil.NewLegacyAddressPubKeyHash(a.ScriptAddress(), net); err == nil {
+			return legacy
+		}
+	case *czzutil.AddressScriptHash:
+		if legacy, err := czzutil.NewLegacyAddressScriptHashFromHash(a.ScriptAddress(), net); err == nil {
+			return legacy
+		}
+	}
+	return addr
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// TestAddressConversion ensures cashaddr and legacy addresses are converted to
+// each other without changing the script they pay to and that the addresses
+// without another form are returned unchanged.
+func TestAddressConversion(t *testing.T) {
+	hash := bytes.Repeat([]byte{0x42}, 20)
+	params := &chaincfg.MainNetParams
+
+	pkh, _ := czzutil.NewAddressPubKeyHash(hash, params)
+	sh, _ := czzutil.NewAddressScriptHashFromHash(hash, params)
+	for _, cash := range []czzutil.Address{pkh, sh} {
+		legacy := ToLegacyAddress(cash, params)
+		if legacy.EncodeAddress() == cash.EncodeAddress() {
+			t.Errorf("ToLegacyAddress: %s was not converted",
+				cash.EncodeAddress())
+		}
+		cashScript, err := PayToAddrScript(cash)
+		if err != nil {
+			t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+		}
+		legacyScript, err := PayToAddrScript(legacy)
+		if err != nil {
+			t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+		}
+		if !bytes.Equal(cashScript, legacyScript) {
+			t.Errorf("%s pays to %x, legacy %s pays to %x",
+				cash.EncodeAddress(), cashScript,
+				legacy.EncodeAddress(), legacyScript)
+		}
+
+		roundTrip := ToCashAddress(legacy, params)
+		if roundTrip.EncodeAddress() != cash.EncodeAddress() {
+			t.Errorf("ToCashAddress: got %s, want %s",
+				roundTrip.EncodeAddress(), cash.EncodeAddress())
+		}
+		if ToCashAddress(cash, params) != cash {
+			t.Errorf("ToCashAddress: %s was converted",
+				cash.EncodeAddress())
+		}
+	}
+
+	pubKey := newAddressPubKey(hexToBytes(descPubKey1))
+	if ToCashAddress(pubKey, params) != pubKey ||
+		ToLegacyAddress(pubKey, params) != pubKey {
+		t.Error("pay-to-pubkey address was converted")
+	}
+}
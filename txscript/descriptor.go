@@ -0,0 +1,256 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+const (
+	// descriptorInputCharset is the character set of descriptors, ordered
+	// so that the characters most commonly used in descriptors map to the
+	// lowest values of the checksum.
+	descriptorInputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+		"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+		"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+
+	// descriptorChecksumCharset is the character set of descriptor
+	// checksums, which is the bech32 character set.
+	descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	// descriptorChecksumLen is the number of characters of a descriptor
+	// checksum.
+	descriptorChecksumLen = 8
+)
+
+// ErrDescriptorChecksum describes an error in which the checksum of a
+// descriptor does not match the descriptor.
+var ErrDescriptorChecksum = errors.New("descriptor checksum mismatch")
+
+// Descriptor is an output descriptor, which describes an output script in the
+// notation many wallets share, so watch-only setups can express what they
+// track in a standard way.  A minimal subset of the descriptor language is
+// supported:
+//
+//	pkh(<hex public key>)
+//	sh(multi(<required>,<hex public key>,...))
+//	sh(sortedmulti(<required>,<hex public key>,...))
+//	raw(<hex output script>)
+//	addr(<address>)
+//
+// Extended keys, key origins and the segwit descriptors are not supported.
+type Descriptor struct {
+	desc         string
+	pkScript     []byte
+	redeemScript []byte
+}
+
+// descriptorPolyMod advances the checksum state c of a descriptor by the passed
+// value.
+func descriptorPolyMod(c uint64, val int) uint64 {
+	c0 := c >> 35
+	c = (c&0x7ffffffff)<<5 ^ uint64(val)
+	if c0&1 != 0 {
+		c ^= 0xf5dee51989
+	}
+	if c0&2 != 0 {
+		c ^= 0xa9fdca3312
+	}
+	if c0&4 != 0 {
+		c ^= 0x1bab10e32d
+	}
+	if c0&8 != 0 {
+		c ^= 0x3706b1677a
+	}
+	if c0&16 != 0 {
+		c ^= 0x644d626ffd
+	}
+	return c
+}
+
+// DescriptorChecksum returns the checksum of the passed descriptor, which is
+// appended to a descriptor after a '#' to protect it against typos.  The
+// descriptor must not already have a checksum.
+func DescriptorChecksum(desc string) (string, error) {
+	c := uint64(1)
+	var class, classCount int
+	for _, ch := range desc {
+		pos := strings.IndexRune(descriptorInputCharset, ch)
+		if pos < 0 {
+			return "", fmt.Errorf("invalid character %q in descriptor",
+				ch)
+		}
+		c = descriptorPolyMod(c, pos&31)
+		class = class*3 + pos>>5
+		classCount++
+		if classCount == 3 {
+			c = descriptorPolyMod(c, class)
+			class, classCount = 0, 0
+		}
+	}
+	if classCount > 0 {
+		c = descriptorPolyMod(c, class)
+	}
+	for i := 0; i < descriptorChecksumLen; i++ {
+		c = descriptorPolyMod(c, 0)
+	}
+	c ^= 1
+
+	checksum := make([]byte, descriptorChecksumLen)
+	for i := range checksum {
+		shift := uint(5 * (descriptorChecksumLen - 1 - i))
+		checksum[i] = descriptorChecksumCharset[(c>>shift)&31]
+	}
+	return string(checksum), nil
+}
+
+// splitDescriptorFunc splits the passed descriptor expression of the form
+// name(argument) into its name and argument.
+func splitDescriptorFunc(expr string) (string, string, error) {
+	open := strings.IndexByte(expr, '(')
+	if open <= 0 || !strings.HasSuffix(expr, ")") {
+		return "", "", fmt.Errorf("malformed descriptor expression %q",
+			expr)
+	}
+	return expr[:open], expr[open+1 : len(expr)-1], nil
+}
+
+// parseDescriptorKey parses the passed hex-encoded public key of a descriptor.
+func parseDescriptorKey(key string, net *chaincfg.Params) (*czzutil.AddressPubKey, error) {
+	serialized, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("malformed public key %q", key)
+	}
+	pubKey, err := czzutil.NewAddressPubKey(serialized, net)
+	if err != nil || pubKey.Format() == czzutil.PKFHybrid {
+		return nil, fmt.Errorf("invalid public key %q", key)
+	}
+	return pubKey, nil
+}
+
+// ParseDescriptor parses the passed output descriptor for the passed network.
+// A trailing checksum is optional, but it must match the descriptor when it is
+// present.  See Descriptor for the supported descriptors.
+func ParseDescriptor(desc string, net *chaincfg.Params) (*Descriptor, error) {
+	expr := desc
+	if i := strings.LastIndexByte(desc, '#'); i >= 0 {
+		expr = desc[:i]
+		checksum, err := DescriptorChecksum(expr)
+		if err != nil {
+			return nil, err
+		}
+		if desc[i+1:] != checksum {
+			return nil, ErrDescriptorChecksum
+		}
+	}
+
+	name, arg, err := splitDescriptorFunc(expr)
+	if err != nil {
+		return nil, err
+	}
+	d := &Descriptor{desc: expr}
+	switch name {
+	case "pkh":
+		pubKey, err := parseDescriptorKey(arg, net)
+		if err != nil {
+			return nil, err
+		}
+		d.pkScript, err = payToPubKeyHashScript(
+			czzutil.Hash160(pubKey.ScriptAddress()))
+		if err != nil {
+			return nil, err
+		}
+
+	case "sh":
+		name, arg, err := splitDescriptorFunc(arg)
+		if err != nil {
+			return nil, err
+		}
+		if name != "multi" && name != "sortedmulti" {
+			return nil, fmt.Errorf("unsupported descriptor sh(%s(...)) "+
+				"-- only multi and sortedmulti are supported in sh",
+				name)
+		}
+		args := strings.Split(arg, ",")
+		nRequired, err := strconv.Atoi(args[0])
+		if err != nil || args[0] != strconv.Itoa(nRequired) {
+			return nil, fmt.Errorf("malformed number of required "+
+				"signatures %q", args[0])
+		}
+		pubKeys := make([]*czzutil.AddressPubKey, 0, len(args)-1)
+		for _, key := range args[1:] {
+			pubKey, err := parseDescriptorKey(key, net)
+			if err != nil {
+				return nil, err
+			}
+			pubKeys = append(pubKeys, pubKey)
+		}
+		if name == "sortedmulti" {
+			SortPubKeys(pubKeys)
+		}
+		d.redeemScript, err = NewMultiSigRedeemScript(nRequired, pubKeys)
+		if err != nil {
+			return nil, err
+		}
+		d.pkScript, err = payToScriptHashScript(
+			czzutil.Hash160(d.redeemScript))
+		if err != nil {
+			return nil, err
+		}
+
+	case "raw":
+		d.pkScript, err = hex.DecodeString(arg)
+		if err != nil || len(d.pkScript) == 0 {
+			return nil, fmt.Errorf("malformed output script %q", arg)
+		}
+
+	case "addr":
+		addr, err := czzutil.DecodeAddress(arg, net)
+		if err != nil || !addr.IsForNet(net) {
+			return nil, fmt.Errorf("invalid address %q", arg)
+		}
+		switch addr := addr.(type) {
+		case *czzutil.AddressPubKeyHash:
+			d.pkScript, err = payToPubKeyHashScript(addr.ScriptAddress())
+		case *czzutil.AddressScriptHash:
+			d.pkScript, err = payToScriptHashScript(addr.ScriptAddress())
+		default:
+			return nil, fmt.Errorf("unsupported address %q", arg)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported descriptor %s(...)", name)
+	}
+	return d, nil
+}
+
+// PkScript returns the output script described by the descriptor.
+func (d *Descriptor) PkScript() []byte {
+	return d.pkScript
+}
+
+// RedeemScript returns the redeem script of a sh descriptor, or nil for the
+// other descriptors.
+func (d *Descriptor) RedeemScript() []byte {
+	return d.redeemScript
+}
+
+// String returns the descriptor along with its checksum.
+func (d *Descriptor) String() string {
+	// The characters of the descriptor were checked when it was parsed,
+	// so the checksum can not fail.
+	checksum, _ := DescriptorChecksum(d.desc)
+	return d.desc + "#" + checksum
+}
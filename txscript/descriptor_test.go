@@ -0,0 +1,198 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+const (
+	// descPubKey1 and descPubKey2 are compressed public keys used in the
+	// descriptor tests.  descPubKey1 sorts before descPubKey2.
+	descPubKey1 = "02192d74d0cb94344c9569c2e77901573d8d7903c3ebec3a957724895dca52c6b4"
+	descPubKey2 = "03b0bd634234abbb1ba1e986e884185c61cf43e001f9137f23c2c409273eb16e65"
+
+	// descHybridKey is a hybrid public key, which descriptors must reject.
+	descHybridKey = "0679be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"
+)
+
+// TestDescriptorChecksum ensures descriptor checksums match the test vectors of
+// BIP0380 and that descriptors with characters outside of the descriptor
+// character set are rejected.
+func TestDescriptorChecksum(t *testing.T) {
+	checksum, err := DescriptorChecksum("raw(deadbeef)")
+	if err != nil {
+		t.Fatalf("DescriptorChecksum: unexpected error: %v", err)
+	}
+	if checksum != "89f8spxm" {
+		t.Fatalf("DescriptorChecksum: got %s, want 89f8spxm", checksum)
+	}
+
+	if _, err := DescriptorChecksum("raw(deadbeef)é"); err == nil {
+		t.Fatal("DescriptorChecksum: accepted an invalid character")
+	}
+}
+
+// TestParseDescriptor ensures the supported descriptors describe the expected
+// output and redeem scripts and are returned with their checksum.
+func TestParseDescriptor(t *testing.T) {
+	net := &chaincfg.MainNetParams
+	pubKey1 := newAddressPubKey(hexToBytes(descPubKey1)).(*czzutil.AddressPubKey)
+	pubKey2 := newAddressPubKey(hexToBytes(descPubKey2)).(*czzutil.AddressPubKey)
+	pkhAddr := newAddressPubKeyHash(czzutil.Hash160(hexToBytes(descPubKey1)))
+	redeemScript, err := MultiSigScript([]*czzutil.AddressPubKey{pubKey1,
+		pubKey2}, 2)
+	if err != nil {
+		t.Fatalf("MultiSigScript: unexpected error: %v", err)
+	}
+	shAddr := newAddressScriptHash(czzutil.Hash160(redeemScript))
+	pkhScript, err := PayToAddrScript(pkhAddr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+	shScript, err := PayToAddrScript(shAddr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		desc         string
+		pkScript     []byte
+		redeemScript []byte
+	}{{
+		name:     "pkh",
+		desc:     "pkh(" + descPubKey1 + ")",
+		pkScript: pkhScript,
+	}, {
+		name:         "sh multi",
+		desc:         "sh(multi(2," + descPubKey1 + "," + descPubKey2 + "))",
+		pkScript:     shScript,
+		redeemScript: redeemScript,
+	}, {
+		name:         "sh sortedmulti",
+		desc:         "sh(sortedmulti(2," + descPubKey2 + "," + descPubKey1 + "))",
+		pkScript:     shScript,
+		redeemScript: redeemScript,
+	}, {
+		name:     "raw",
+		desc:     "raw(deadbeef)",
+		pkScript: hexToBytes("deadbeef"),
+	}, {
+		name:     "pubkey hash addr",
+		desc:     "addr(" + pkhAddr.EncodeAddress() + ")",
+		pkScript: pkhScript,
+	}, {
+		name:     "script hash addr",
+		desc:     "addr(" + shAddr.EncodeAddress() + ")",
+		pkScript: shScript,
+	}}
+
+	for _, test := range tests {
+		desc, err := ParseDescriptor(test.desc, net)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !bytes.Equal(desc.PkScript(), test.pkScript) {
+			t.Errorf("%s: got output script %x, want %x", test.name,
+				desc.PkScript(), test.pkScript)
+			continue
+		}
+		if !bytes.Equal(desc.RedeemScript(), test.redeemScript) {
+			t.Errorf("%s: got redeem script %x, want %x", test.name,
+				desc.RedeemScript(), test.redeemScript)
+			continue
+		}
+
+		// The descriptor is returned with its checksum, which is
+		// accepted when parsing it again.
+		checksum, err := DescriptorChecksum(test.desc)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		want := test.desc + "#" + checksum
+		if desc.String() != want {
+			t.Errorf("%s: got %s, want %s", test.name, desc, want)
+			continue
+		}
+		reparsed, err := ParseDescriptor(want, net)
+		if err != nil {
+			t.Errorf("%s: unexpected error parsing %s: %v", test.name,
+				want, err)
+			continue
+		}
+		if !bytes.Equal(reparsed.PkScript(), test.pkScript) {
+			t.Errorf("%s: got output script %x with checksum, want %x",
+				test.name, reparsed.PkScript(), test.pkScript)
+		}
+	}
+}
+
+// TestParseDescriptorErrors ensures malformed and unsupported descriptors are
+// rejected.
+func TestParseDescriptorErrors(t *testing.T) {
+	net := &chaincfg.MainNetParams
+	testAddr, err := czzutil.NewAddressPubKeyHash(
+		czzutil.Hash160(hexToBytes(descPubKey1)), &chaincfg.TestNet3Params)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		desc string
+	}{
+		{name: "empty", desc: ""},
+		{name: "not a function", desc: descPubKey1},
+		{name: "unterminated", desc: "pkh(" + descPubKey1},
+		{name: "unsupported", desc: "wpkh(" + descPubKey1 + ")"},
+		{name: "bad checksum", desc: "raw(deadbeef)#89f8spxn"},
+		{name: "truncated checksum", desc: "raw(deadbeef)#89f8spx"},
+		{name: "malformed key", desc: "pkh(" + descPubKey1[2:] + ")"},
+		{name: "hybrid key", desc: "pkh(" + descHybridKey + ")"},
+		{name: "sh of pkh", desc: "sh(pkh(" + descPubKey1 + "))"},
+		{
+			name: "padded required signatures",
+			desc: "sh(multi(02," + descPubKey1 + "," + descPubKey2 + "))",
+		},
+		{
+			name: "more required signatures than keys",
+			desc: "sh(multi(3," + descPubKey1 + "," + descPubKey2 + "))",
+		},
+		{
+			name: "no required signatures",
+			desc: "sh(multi(0," + descPubKey1 + "))",
+		},
+		{
+			name: "too many keys",
+			desc: "sh(multi(1" + strings.Repeat(","+descPubKey1,
+				MaxP2SHMultiSigKeys+1) + "))",
+		},
+		{name: "empty raw", desc: "raw()"},
+		{name: "malformed raw", desc: "raw(deadbee)"},
+		{name: "other network addr", desc: "addr(" + testAddr.EncodeAddress() + ")"},
+		{name: "malformed addr", desc: "addr(" + descPubKey1 + ")"},
+	}
+	for _, test := range tests {
+		if _, err := ParseDescriptor(test.desc, net); err == nil {
+			t.Errorf("%s: parsed invalid descriptor %q", test.name,
+				test.desc)
+		}
+	}
+
+	// A checksum mismatch is reported with a dedicated error.
+	_, err = ParseDescriptor("raw(deadbeef)#89f8spxn", net)
+	if err != ErrDescriptorChecksum {
+		t.Errorf("bad checksum: got %v, want %v", err,
+			ErrDescriptorChecksum)
+	}
+}
@@ -94,6 +94,13 @@ const (
 	// from the rule in order to allow users who accidentally sent funds to
 	// segwit addresses to recover them.
 	ScriptVerifyAllowSegwitRecovery
+
+	// ScriptVerifySigChecks defines that transactions and blocks are limited
+	// by the number of signature checks executed by their scripts rather
+	// than by their static signature operation count.  The engine always
+	// counts signature checks, see SigChecks, and the limits themselves are
+	// enforced by the caller.
+	ScriptVerifySigChecks
 )
 
 // HasFlag returns whether the ScriptFlags has the passed flag set.
@@ -101,6 +108,38 @@ func (scriptFlags ScriptFlags) HasFlag(flag ScriptFlags) bool {
 	return scriptFlags&flag == flag
 }
 
+// scriptFlagNames maps the names of the script flags, which are those of the
+// reference script tests, to the flags.
+var scriptFlagNames = map[string]ScriptFlags{
+	"P2SH":                       ScriptBip16,
+	"NULLDUMMY":                  ScriptStrictMultiSig,
+	"DISCOURAGE_UPGRADABLE_NOPS": ScriptDiscourageUpgradableNops,
+	"CHECKLOCKTIMEVERIFY":        ScriptVerifyCheckLockTimeVerify,
+	"CHECKSEQUENCEVERIFY":        ScriptVerifyCheckSequenceVerify,
+	"CLEANSTACK":                 ScriptVerifyCleanStack,
+	"DERSIG":                     ScriptVerifyDERSignatures,
+	"LOW_S":                      ScriptVerifyLowS,
+	"MINIMALDATA":                ScriptVerifyMinimalData,
+	"NULLFAIL":                   ScriptVerifyNullFail,
+	"SIGPUSHONLY":                ScriptVerifySigPushOnly,
+	"STRICTENC":                  ScriptVerifyStrictEncoding,
+	"SIGHASH_FORKID":             ScriptVerifyBip143SigHash,
+	"CHECKDATASIG":               ScriptVerifyCheckDataSig,
+	"SCHNORR":                    ScriptVerifySchnorr,
+	"ALLOW_SEGWIT_RECOVERY":      ScriptVerifyAllowSegwitRecovery,
+	"SIGCHECKS":                  ScriptVerifySigChecks,
+}
+
+// ParseScriptFlag returns the script flag with the passed name, such as P2SH
+// for ScriptBip16 or SIGHASH_FORKID for ScriptVerifyBip143SigHash.
+func ParseScriptFlag(name string) (ScriptFlags, error) {
+	flag, ok := scriptFlagNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown script flag %q", name)
+	}
+	return flag, nil
+}
+
 const (
 	// MaxStackSize is the maximum combined height of stack and alt stack
 	// during execution.
@@ -131,6 +170,17 @@ type Engine struct {
 	bip16           bool     // treat execution as pay-to-script-hash
 	savedFirstStack [][]byte // stack from first script for bip16 scripts
 	inputAmount     int64
+	sigChecks       int
+}
+
+// SigChecks returns the number of signature checks performed by the script
+// engine so far.  Each OP_CHECKSIG, OP_CHECKSIGVERIFY, OP_CHECKDATASIG and
+// OP_CHECKDATASIGVERIFY executed with a non-empty signature counts as one while
+// an OP_CHECKMULTISIG or OP_CHECKMULTISIGVERIFY counts its number of public
+// keys unless all of its signatures are empty.  Unlike the static signature
+// operation count, only the operations actually executed are counted.
+func (vm *Engine) SigChecks() int {
+	return vm.sigChecks
 }
 
 // hasFlag returns whether the script engine instance has the passed flag set.
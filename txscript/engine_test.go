@@ -5,6 +5,8 @@
 package txscript
 
 import (
+	"bytes"
+
 	"github.com/bourbaki-czz/classzz/chaincfg"
 	"github.com/bourbaki-czz/czzutil"
 	"testing"
@@ -746,3 +748,99 @@ func TestSegwitExemption(t *testing.T) {
 		t.Errorf("TestSegwitExemption expected segwit exemption to pass")
 	}
 }
+
+// TestSigChecks ensures the script engine counts the signature checks executed
+// by its scripts.
+func TestSigChecks(t *testing.T) {
+	t.Parallel()
+
+	pubKey := append([]byte{0x02}, bytes.Repeat([]byte{0x01}, 32)...)
+	sig := []byte{0x30, byte(SigHashAll)}
+
+	// checkSig returns a script running OP_CHECKSIG with the passed
+	// signature and dropping its result.
+	checkSig := func(b *ScriptBuilder, sig []byte) *ScriptBuilder {
+		return b.AddData(sig).AddData(pubKey).AddOp(OP_CHECKSIG).
+			AddOp(OP_DROP)
+	}
+	// checkMultiSig returns a script running a 1-of-3 OP_CHECKMULTISIG
+	// with the passed signature and dropping its result.
+	checkMultiSig := func(b *ScriptBuilder, sig []byte) *ScriptBuilder {
+		return b.AddOp(OP_0).AddData(sig).AddOp(OP_1).AddData(pubKey).
+			AddData(pubKey).AddData(pubKey).AddOp(OP_3).
+			AddOp(OP_CHECKMULTISIG).AddOp(OP_DROP)
+	}
+
+	tests := []struct {
+		name      string
+		script    *ScriptBuilder
+		sigChecks int
+	}{
+		{"checksig", checkSig(NewScriptBuilder(), sig), 1},
+		{"checksig empty sig", checkSig(NewScriptBuilder(), nil), 0},
+		{"two checksigs", checkSig(checkSig(NewScriptBuilder(), sig),
+			sig), 2},
+		{"checkmultisig", checkMultiSig(NewScriptBuilder(), sig), 3},
+		{"checkmultisig empty sig", checkMultiSig(NewScriptBuilder(),
+			nil), 0},
+		{"unexecuted branch", checkSig(NewScriptBuilder().
+			AddOp(OP_0).AddOp(OP_IF), sig).AddOp(OP_ENDIF), 0},
+	}
+
+	for _, test := range tests {
+		pkScript, err := test.script.AddOp(OP_TRUE).Script()
+		if err != nil {
+			t.Errorf("%s: unexpected script error: %v", test.name, err)
+			continue
+		}
+		tx := &wire.MsgTx{
+			Version: 1,
+			TxIn:    []*wire.TxIn{{Sequence: wire.MaxTxInSequenceNum}},
+			TxOut:   []*wire.TxOut{{Value: 0}},
+		}
+		vm, err := NewEngine(pkScript, tx, 0, 0, nil, nil, 0)
+		if err != nil {
+			t.Errorf("%s: unexpected engine error: %v", test.name, err)
+			continue
+		}
+		if err := vm.Execute(); err != nil {
+			t.Errorf("%s: unexpected execute error: %v", test.name, err)
+			continue
+		}
+		if got := vm.SigChecks(); got != test.sigChecks {
+			t.Errorf("%s: got %d sigchecks, want %d", test.name, got,
+				test.sigChecks)
+		}
+	}
+}
+
+// TestParseScriptFlag ensures every script flag has a name and that unknown
+// names are rejected.
+func TestParseScriptFlag(t *testing.T) {
+	t.Parallel()
+
+	var all ScriptFlags
+	for name := range scriptFlagNames {
+		flag, err := ParseScriptFlag(name)
+		if err != nil {
+			t.Fatalf("ParseScriptFlag(%s): unexpected error: %v", name,
+				err)
+		}
+		if all.HasFlag(flag) {
+			t.Errorf("ParseScriptFlag(%s): flag %#x has several names",
+				name, flag)
+		}
+		all |= flag
+	}
+	if want := ScriptVerifySigChecks<<1 - 1; all != want {
+		t.Errorf("named flags %#x, want %#x", all, want)
+	}
+
+	if flag, _ := ParseScriptFlag("SIGHASH_FORKID"); flag != ScriptVerifyBip143SigHash {
+		t.Errorf("ParseScriptFlag(SIGHASH_FORKID): got %#x, want %#x",
+			flag, ScriptVerifyBip143SigHash)
+	}
+	if _, err := ParseScriptFlag("p2sh"); err == nil {
+		t.Errorf("ParseScriptFlag(p2sh): unexpected success")
+	}
+}
@@ -0,0 +1,307 @@
+package txscript
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+)
+
+const (
+	// EntangleTypeDoge identifies an entangle output that locks coins on
+	// the Dogecoin chain.
+	EntangleTypeDoge = 0xF0
+
+	// EntangleTypeLtc identifies an entangle output that locks coins on
+	// the Litecoin chain.
+	EntangleTypeLtc = 0xF1
+
+	// EntangleExtTxHashSize is the size of the foreign transaction hash
+	// carried by an entangle output.  It is the hex encoding of the 32-byte
+	// transaction hash on the foreign chain.
+	EntangleExtTxHashSize = 64
+
+	// minEntangleDataSize is the smallest entangle payload accepted by
+	// the parser: type, index, height and the amount length prefix plus
+	// at least one byte that follows it.
+	minEntangleDataSize = 1 + 4 + 8 + 1 + 1
+
+	// maxEntangleAmountSize is the largest serialized amount that fits the
+	// single length byte prefix used by the entangle encodings.
+	maxEntangleAmountSize = 255
+
+	// MaxKeepedAmountItems is the largest number of items a keeped-amount
+	// output may carry, as limited by its single count byte.
+	MaxKeepedAmountItems = 255
+)
+
+// IsKnownEntangleType returns whether the passed type byte identifies one of
+// the foreign chains supported by the entangle protocol.
+func IsKnownEntangleType(t byte) bool {
+	switch t {
+	case EntangleTypeDoge, EntangleTypeLtc:
+		return true
+	}
+	return false
+}
+
+// EntangleData houses the fields carried by an entangle output, which is an
+// OP_RETURN OP_UNKNOWN193 <data> script with a zero value.  It is the typed
+// counterpart of the payload built by EntangleScript.
+type EntangleData struct {
+	ExTxType  byte
+	Index     uint32
+	Height    uint64
+	Amount    *big.Int
+	ExtTxHash []byte
+}
+
+// Validate returns an ErrInvalidEntangleData error if the entangle data can
+// not be safely serialized into an output that the network will accept.
+func (d *EntangleData) Validate() error {
+	if !IsKnownEntangleType(d.ExTxType) {
+		str := fmt.Sprintf("unknown entangle type %#x", d.ExTxType)
+		return scriptError(ErrInvalidEntangleData, str)
+	}
+	if err := validateEntangleAmount(d.Amount); err != nil {
+		return err
+	}
+	if len(d.ExtTxHash) != EntangleExtTxHashSize {
+		str := fmt.Sprintf("entangle foreign tx hash is %d bytes, "+
+			"want %d", len(d.ExtTxHash), EntangleExtTxHashSize)
+		return scriptError(ErrInvalidEntangleData, str)
+	}
+	return nil
+}
+
+// Bytes returns the serialized entangle payload.  The encoding is the type
+// byte, the little endian index and height, the length prefixed big endian
+// amount and finally the foreign transaction hash.
+func (d *EntangleData) Bytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(d.ExTxType)
+	binary.Write(&buf, binary.LittleEndian, d.Index)
+	binary.Write(&buf, binary.LittleEndian, d.Height)
+	writeEntangleAmount(&buf, d.Amount)
+	buf.Write(d.ExtTxHash)
+	return buf.Bytes()
+}
+
+// ParseEntangleData decodes an entangle payload as produced by Bytes.  Any
+// bytes following the foreign transaction hash are ignored so that outputs
+// already accepted by the network keep parsing the same way.
+func ParseEntangleData(data []byte) (*EntangleData, error) {
+	if len(data) < minEntangleDataSize {
+		str := fmt.Sprintf("entangle data is %d bytes, want at "+
+			"least %d", len(data), minEntangleDataSize)
+		return nil, scriptError(ErrInvalidEntangleData, str)
+	}
+	d := &EntangleData{ExTxType: data[0]}
+	if !IsKnownEntangleType(d.ExTxType) {
+		str := fmt.Sprintf("unknown entangle type %#x", d.ExTxType)
+		return nil, scriptError(ErrInvalidEntangleData, str)
+	}
+	d.Index = binary.LittleEndian.Uint32(data[1:5])
+	d.Height = binary.LittleEndian.Uint64(data[5:13])
+
+	amount, rest, err := readEntangleAmount(data[13:])
+	if err != nil {
+		return nil, err
+	}
+	d.Amount = amount
+
+	if len(rest) < EntangleExtTxHashSize {
+		str := fmt.Sprintf("entangle foreign tx hash is %d bytes, "+
+			"want %d", len(rest), EntangleExtTxHashSize)
+		return nil, scriptError(ErrInvalidEntangleData, str)
+	}
+	d.ExtTxHash = make([]byte, EntangleExtTxHashSize)
+	copy(d.ExtTxHash, rest)
+	return d, nil
+}
+
+// NewEntangleScript validates the passed entangle data and returns the
+// output script that carries it.
+func NewEntangleScript(d *EntangleData) ([]byte, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return EntangleScript(d.Bytes())
+}
+
+// ExtractEntangleData returns the entangle data carried by the passed output
+// script.  An ErrNotEntangleScript error is returned if the script is not an
+// entangle script.
+func ExtractEntangleData(script []byte) (*EntangleData, error) {
+	pops, err := parseScript(script)
+	if err != nil {
+		return nil, err
+	}
+	if !isEntangleTy(pops) || len(pops) < 3 {
+		return nil, scriptError(ErrNotEntangleScript,
+			"script is not an entangle script")
+	}
+	return ParseEntangleData(pops[2].data)
+}
+
+// KeepedAmountItem is the total amount entangled from a single foreign chain.
+type KeepedAmountItem struct {
+	ExTxType byte
+	Amount   *big.Int
+}
+
+// KeepedAmountData houses the running entangle totals carried by the
+// keeped-amount output of the coinbase, which is an OP_RETURN OP_UNKNOWN194
+// <data> script with a zero value.
+type KeepedAmountData struct {
+	Items []KeepedAmountItem
+}
+
+// Validate returns an ErrInvalidEntangleData error if the keeped-amount data
+// can not be safely serialized.  Each foreign chain may only appear once.
+func (d *KeepedAmountData) Validate() error {
+	if len(d.Items) > MaxKeepedAmountItems {
+		str := fmt.Sprintf("keeped amount has %d items, max %d",
+			len(d.Items), MaxKeepedAmountItems)
+		return scriptError(ErrInvalidEntangleData, str)
+	}
+	seen := make(map[byte]struct{}, len(d.Items))
+	for _, item := range d.Items {
+		if !IsKnownEntangleType(item.ExTxType) {
+			str := fmt.Sprintf("unknown entangle type %#x",
+				item.ExTxType)
+			return scriptError(ErrInvalidEntangleData, str)
+		}
+		if _, ok := seen[item.ExTxType]; ok {
+			str := fmt.Sprintf("duplicate keeped amount for "+
+				"entangle type %#x", item.ExTxType)
+			return scriptError(ErrInvalidEntangleData, str)
+		}
+		seen[item.ExTxType] = struct{}{}
+		if err := validateEntangleAmount(item.Amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bytes returns the serialized keeped-amount payload: the item count
+// followed by the type byte and length prefixed amount of every item.
+func (d *KeepedAmountData) Bytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(byte(len(d.Items)))
+	for _, item := range d.Items {
+		buf.WriteByte(item.ExTxType)
+		writeEntangleAmount(&buf, item.Amount)
+	}
+	return buf.Bytes()
+}
+
+// ParseKeepedAmountData decodes a keeped-amount payload as produced by
+// Bytes.  Empty data has no items, since the payload of no items is the single
+// zero count byte, which scripts push as OP_0.
+func ParseKeepedAmountData(data []byte) (*KeepedAmountData, error) {
+	if len(data) == 0 {
+		return &KeepedAmountData{Items: []KeepedAmountItem{}}, nil
+	}
+	count := int(data[0])
+	d := &KeepedAmountData{Items: make([]KeepedAmountItem, 0, count)}
+	rest := data[1:]
+	for i := 0; i < count; i++ {
+		if len(rest) < 1 {
+			str := fmt.Sprintf("keeped amount data truncated at "+
+				"item %d of %d", i, count)
+			return nil, scriptError(ErrInvalidEntangleData, str)
+		}
+		item := KeepedAmountItem{ExTxType: rest[0]}
+		amount, next, err := readEntangleAmount(rest[1:])
+		if err != nil {
+			return nil, err
+		}
+		item.Amount = amount
+		d.Items = append(d.Items, item)
+		rest = next
+	}
+	return d, nil
+}
+
+// NewKeepedAmountScript validates the passed keeped-amount data and returns
+// the output script that carries it.
+func NewKeepedAmountScript(d *KeepedAmountData) ([]byte, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return KeepedAmountScript(d.Bytes())
+}
+
+// ExtractKeepedAmountData returns the keeped-amount data carried by the
+// passed output script.  An ErrNotEntangleScript error is returned if the
+// script is not a keeped-amount script.
+func ExtractKeepedAmountData(script []byte) (*KeepedAmountData, error) {
+	pops, err := parseScript(script)
+	if err != nil {
+		return nil, err
+	}
+	if !isKeepedAmountInfo(pops) || len(pops) < 3 {
+		return nil, scriptError(ErrNotEntangleScript,
+			"script is not a keeped amount script")
+	}
+	return ParseKeepedAmountData(pops[2].data)
+}
+
+// IsKeepedAmountScript returns whether the passed script is a keeped-amount
+// script.
+func IsKeepedAmountScript(script []byte) bool {
+	pops, err := parseScript(script)
+	if err != nil {
+		return false
+	}
+	return isKeepedAmountInfo(pops)
+}
+
+// validateEntangleAmount returns an error if the amount is missing, negative
+// or too large for its length prefix.
+func validateEntangleAmount(amount *big.Int) error {
+	if amount == nil {
+		return scriptError(ErrInvalidEntangleData,
+			"entangle amount is missing")
+	}
+	if amount.Sign() < 0 {
+		str := fmt.Sprintf("entangle amount %v is negative", amount)
+		return scriptError(ErrInvalidEntangleData, str)
+	}
+	if len(amount.Bytes()) > maxEntangleAmountSize {
+		str := fmt.Sprintf("entangle amount is %d bytes, max %d",
+			len(amount.Bytes()), maxEntangleAmountSize)
+		return scriptError(ErrInvalidEntangleData, str)
+	}
+	return nil
+}
+
+// writeEntangleAmount writes the length prefixed big endian amount.  A nil
+// amount is written as zero.
+func writeEntangleAmount(buf *bytes.Buffer, amount *big.Int) {
+	var b []byte
+	if amount != nil {
+		b = amount.Bytes()
+	}
+	buf.WriteByte(byte(len(b)))
+	buf.Write(b)
+}
+
+// readEntangleAmount reads a length prefixed big endian amount and returns
+// it along with the remaining bytes.
+func readEntangleAmount(data []byte) (*big.Int, []byte, error) {
+	if len(data) < 1 {
+		return nil, nil, scriptError(ErrInvalidEntangleData,
+			"entangle amount length is missing")
+	}
+	l := int(data[0])
+	if len(data)-1 < l {
+		str := fmt.Sprintf("entangle amount is %d bytes, want %d",
+			len(data)-1, l)
+		return nil, nil, scriptError(ErrInvalidEntangleData, str)
+	}
+	amount := new(big.Int).SetBytes(data[1 : 1+l])
+	return amount, data[1+l:], nil
+}
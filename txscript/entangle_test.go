@@ -0,0 +1,216 @@
+package txscript
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+// testExtTxHash returns a foreign transaction hash of the expected size.
+func testExtTxHash() []byte {
+	return bytes.Repeat([]byte{'a'}, EntangleExtTxHashSize)
+}
+
+// TestEntangleScriptRoundTrip ensures entangle data survives being built into
+// an output script and extracted again, and that the payload layout matches
+// the encoding used by existing entangle outputs.
+func TestEntangleScriptRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	d := &EntangleData{
+		ExTxType:  EntangleTypeDoge,
+		Index:     3,
+		Height:    0x0102,
+		Amount:    big.NewInt(0x0a0b),
+		ExtTxHash: testExtTxHash(),
+	}
+
+	want := []byte{EntangleTypeDoge, 3, 0, 0, 0, 0x02, 0x01, 0, 0, 0, 0,
+		0, 0, 2, 0x0a, 0x0b}
+	want = append(want, testExtTxHash()...)
+	if got := d.Bytes(); !bytes.Equal(got, want) {
+		t.Fatalf("Bytes: got %x, want %x", got, want)
+	}
+
+	script, err := NewEntangleScript(d)
+	if err != nil {
+		t.Fatalf("NewEntangleScript: unexpected error: %v", err)
+	}
+	if !IsEntangleTy(script) {
+		t.Fatalf("IsEntangleTy: script %x not recognised", script)
+	}
+	if class := GetScriptClass(script); class != EntangleTy {
+		t.Fatalf("GetScriptClass: got %v, want %v", class, EntangleTy)
+	}
+
+	got, err := ExtractEntangleData(script)
+	if err != nil {
+		t.Fatalf("ExtractEntangleData: unexpected error: %v", err)
+	}
+	if got.ExTxType != d.ExTxType || got.Index != d.Index ||
+		got.Height != d.Height || got.Amount.Cmp(d.Amount) != 0 ||
+		!bytes.Equal(got.ExtTxHash, d.ExtTxHash) {
+
+		t.Fatalf("ExtractEntangleData: got %+v, want %+v", got, d)
+	}
+
+	// Trailing bytes after the hash are ignored.
+	got, err = ParseEntangleData(append(d.Bytes(), 0xff))
+	if err != nil {
+		t.Fatalf("ParseEntangleData: unexpected error: %v", err)
+	}
+	if !bytes.Equal(got.ExtTxHash, d.ExtTxHash) {
+		t.Fatalf("ParseEntangleData: got hash %x, want %x",
+			got.ExtTxHash, d.ExtTxHash)
+	}
+}
+
+// TestEntangleDataErrors ensures invalid entangle data is rejected by both
+// the builder and the parser with the expected error codes.
+func TestEntangleDataErrors(t *testing.T) {
+	t.Parallel()
+
+	valid := func() *EntangleData {
+		return &EntangleData{
+			ExTxType:  EntangleTypeLtc,
+			Amount:    big.NewInt(1),
+			ExtTxHash: testExtTxHash(),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(d *EntangleData)
+	}{
+		{"unknown type", func(d *EntangleData) { d.ExTxType = 0x01 }},
+		{"nil amount", func(d *EntangleData) { d.Amount = nil }},
+		{"negative amount", func(d *EntangleData) {
+			d.Amount = big.NewInt(-1)
+		}},
+		{"short hash", func(d *EntangleData) {
+			d.ExtTxHash = d.ExtTxHash[:10]
+		}},
+	}
+	for _, test := range tests {
+		d := valid()
+		test.mutate(d)
+		_, err := NewEntangleScript(d)
+		if !IsErrorCode(err, ErrInvalidEntangleData) {
+			t.Errorf("%s: got error %v, want %v", test.name, err,
+				ErrInvalidEntangleData)
+		}
+	}
+
+	data := valid().Bytes()
+	truncated := [][]byte{
+		data[:5],
+		data[:14],
+		data[:len(data)-1],
+	}
+	for i, b := range truncated {
+		_, err := ParseEntangleData(b)
+		if !IsErrorCode(err, ErrInvalidEntangleData) {
+			t.Errorf("truncated #%d: got error %v, want %v", i, err,
+				ErrInvalidEntangleData)
+		}
+	}
+
+	nullData, err := NullDataScript([]byte{1, 2, 3})
+	if err != nil {
+		t.Fatalf("NullDataScript: unexpected error: %v", err)
+	}
+	_, err = ExtractEntangleData(nullData)
+	if !IsErrorCode(err, ErrNotEntangleScript) {
+		t.Errorf("ExtractEntangleData: got error %v, want %v", err,
+			ErrNotEntangleScript)
+	}
+}
+
+// TestKeepedAmountScript ensures keeped-amount data round trips through its
+// output script and that invalid data is rejected.
+func TestKeepedAmountScript(t *testing.T) {
+	t.Parallel()
+
+	d := &KeepedAmountData{Items: []KeepedAmountItem{
+		{ExTxType: EntangleTypeDoge, Amount: big.NewInt(0)},
+		{ExTxType: EntangleTypeLtc, Amount: big.NewInt(300)},
+	}}
+	want := []byte{2, EntangleTypeDoge, 0, EntangleTypeLtc, 2, 0x01, 0x2c}
+	if got := d.Bytes(); !bytes.Equal(got, want) {
+		t.Fatalf("Bytes: got %x, want %x", got, want)
+	}
+
+	script, err := NewKeepedAmountScript(d)
+	if err != nil {
+		t.Fatalf("NewKeepedAmountScript: unexpected error: %v", err)
+	}
+	if !IsKeepedAmountScript(script) {
+		t.Fatalf("IsKeepedAmountScript: script %x not recognised",
+			script)
+	}
+	if IsEntangleTy(script) {
+		t.Fatalf("IsEntangleTy: keeped amount script %x recognised",
+			script)
+	}
+
+	got, err := ExtractKeepedAmountData(script)
+	if err != nil {
+		t.Fatalf("ExtractKeepedAmountData: unexpected error: %v", err)
+	}
+	if len(got.Items) != len(d.Items) {
+		t.Fatalf("ExtractKeepedAmountData: got %d items, want %d",
+			len(got.Items), len(d.Items))
+	}
+	for i, item := range got.Items {
+		if item.ExTxType != d.Items[i].ExTxType ||
+			item.Amount.Cmp(d.Items[i].Amount) != 0 {
+
+			t.Errorf("item #%d: got %+v, want %+v", i, item,
+				d.Items[i])
+		}
+	}
+
+	// The payload without items is pushed as OP_0.
+	script, err = NewKeepedAmountScript(&KeepedAmountData{})
+	if err != nil {
+		t.Fatalf("NewKeepedAmountScript: unexpected error: %v", err)
+	}
+	got, err = ExtractKeepedAmountData(script)
+	if err != nil {
+		t.Fatalf("ExtractKeepedAmountData: unexpected error for no "+
+			"items: %v", err)
+	}
+	if len(got.Items) != 0 {
+		t.Fatalf("ExtractKeepedAmountData: got %d items, want none",
+			len(got.Items))
+	}
+
+	dup := &KeepedAmountData{Items: []KeepedAmountItem{
+		{ExTxType: EntangleTypeDoge, Amount: big.NewInt(1)},
+		{ExTxType: EntangleTypeDoge, Amount: big.NewInt(2)},
+	}}
+	if _, err := NewKeepedAmountScript(dup); !IsErrorCode(err,
+		ErrInvalidEntangleData) {
+
+		t.Errorf("duplicate items: got error %v, want %v", err,
+			ErrInvalidEntangleData)
+	}
+
+	if _, err := ParseKeepedAmountData(want[:5]); !IsErrorCode(err,
+		ErrInvalidEntangleData) {
+
+		t.Errorf("truncated data: got error %v, want %v", err,
+			ErrInvalidEntangleData)
+	}
+
+	entangle, err := EntangleScript([]byte{1})
+	if err != nil {
+		t.Fatalf("EntangleScript: unexpected error: %v", err)
+	}
+	if _, err := ExtractKeepedAmountData(entangle); !IsErrorCode(err,
+		ErrNotEntangleScript) {
+
+		t.Errorf("ExtractKeepedAmountData: got error %v, want %v", err,
+			ErrNotEntangleScript)
+	}
+}
@@ -47,6 +47,14 @@ const (
 	// the provided data exceeds MaxDataCarrierSize.
 	ErrTooMuchNullData
 
+	// ErrNotEntangleScript is returned when a script passed to one of the
+	// entangle or keeped-amount parsers is not of the expected form.
+	ErrNotEntangleScript
+
+	// ErrInvalidEntangleData is returned when the data carried by an
+	// entangle or keeped-amount output is malformed or fails validation.
+	ErrInvalidEntangleData
+
 	// ------------------------------------------
 	// Failures related to final execution state.
 	// ------------------------------------------
@@ -320,6 +328,8 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrNotMultisigScript:        "ErrNotMultisigScript",
 	ErrTooManyRequiredSigs:      "ErrTooManyRequiredSigs",
 	ErrTooMuchNullData:          "ErrTooMuchNullData",
+	ErrNotEntangleScript:        "ErrNotEntangleScript",
+	ErrInvalidEntangleData:      "ErrInvalidEntangleData",
 	ErrEarlyReturn:              "ErrEarlyReturn",
 	ErrEmptyStack:               "ErrEmptyStack",
 	ErrEvalFalse:                "ErrEvalFalse",
@@ -0,0 +1,106 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// MaxP2SHMultiSigKeys is the maximum number of public keys of a multisig
+// redeem script which can be spent through a pay-to-script-hash output.  It is
+// limited by the maximum size of a redeem script, which is
+// MaxScriptElementSize.
+const MaxP2SHMultiSigKeys = 15
+
+// ErrInvalidMultiSig describes an error in which the parameters of a multisig
+// redeem script are invalid, or a script is not a multisig redeem script.
+var ErrInvalidMultiSig = errors.New("invalid multisig redeem script")
+
+// NewMultiSigRedeemScript returns the redeem script which requires nRequired
+// signatures of the passed public keys, in the order they are passed:
+//
+//	OP_<nRequired> <pubkey>... OP_<number of keys> OP_CHECKMULTISIG
+//
+// The public keys must be compressed or uncompressed, there must be at most
+// MaxP2SHMultiSigKeys of them and the script must not exceed
+// MaxScriptElementSize, so it can be spent through a pay-to-script-hash
+// output.
+func NewMultiSigRedeemScript(nRequired int, pubKeys []*czzutil.AddressPubKey) ([]byte, error) {
+	if nRequired < 1 || nRequired > len(pubKeys) ||
+		len(pubKeys) > MaxP2SHMultiSigKeys {
+
+		return nil, ErrInvalidMultiSig
+	}
+	for _, pubKey := range pubKeys {
+		if pubKey.Format() == czzutil.PKFHybrid {
+			return nil, ErrInvalidMultiSig
+		}
+	}
+
+	script, err := MultiSigScript(pubKeys, nRequired)
+	if err != nil {
+		return nil, err
+	}
+	if len(script) > MaxScriptElementSize {
+		return nil, ErrInvalidMultiSig
+	}
+	return script, nil
+}
+
+// ParseMultiSigRedeemScript returns the number of required signatures and the
+// serialized public keys of a redeem script created by NewMultiSigRedeemScript.
+func ParseMultiSigRedeemScript(script []byte) (int, [][]byte, error) {
+	pops, err := parseScript(script)
+	if err != nil || !isMultiSig(pops) {
+		return 0, nil, ErrInvalidMultiSig
+	}
+
+	nRequired := asSmallInt(pops[0].opcode)
+	keyPops := pops[1 : len(pops)-2]
+	if nRequired < 1 || nRequired > len(keyPops) {
+		return 0, nil, ErrInvalidMultiSig
+	}
+	pubKeys := make([][]byte, 0, len(keyPops))
+	for _, pop := range keyPops {
+		if !isPubKeyPush(pop) {
+			return 0, nil, ErrInvalidMultiSig
+		}
+		pubKeys = append(pubKeys, pop.data)
+	}
+	return nRequired, pubKeys, nil
+}
+
+// NewMultiSigAddress returns the pay-to-script-hash address of the redeem
+// script which requires nRequired signatures of the passed public keys along
+// with the redeem script, which is needed to spend outputs paying to it.  See
+// NewMultiSigRedeemScript for the requirements of the parameters.
+func NewMultiSigAddress(nRequired int, pubKeys []*czzutil.AddressPubKey,
+	net *chaincfg.Params) (*czzutil.AddressScriptHash, []byte, error) {
+
+	script, err := NewMultiSigRedeemScript(nRequired, pubKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+	addr, err := czzutil.NewAddressScriptHash(script, net)
+	if err != nil {
+		return nil, nil, err
+	}
+	return addr, script, nil
+}
+
+// SortPubKeys sorts the passed public keys by their serialized form as
+// proposed by BIP0067, so the participants of a multisig address arrive at the
+// same redeem script regardless of the order the keys were exchanged in.
+func SortPubKeys(pubKeys []*czzutil.AddressPubKey) {
+	sort.Slice(pubKeys, func(i, j int) bool {
+		return bytes.Compare(pubKeys[i].ScriptAddress(),
+			pubKeys[j].ScriptAddress()) < 0
+	})
+}
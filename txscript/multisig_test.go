@@ -0,0 +1,128 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// TestMultiSigRedeemScript ensures multisig redeem scripts are created from
+// sorted public keys, parsed back and that their pay-to-script-hash address
+// commits to them.
+func TestMultiSigRedeemScript(t *testing.T) {
+	pubKey1 := newAddressPubKey(hexToBytes(descPubKey1)).(*czzutil.AddressPubKey)
+	pubKey2 := newAddressPubKey(hexToBytes(descPubKey2)).(*czzutil.AddressPubKey)
+
+	pubKeys := []*czzutil.AddressPubKey{pubKey2, pubKey1}
+	SortPubKeys(pubKeys)
+	if pubKeys[0] != pubKey1 || pubKeys[1] != pubKey2 {
+		t.Fatal("SortPubKeys: public keys are not sorted")
+	}
+
+	addr, script, err := NewMultiSigAddress(1, pubKeys,
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewMultiSigAddress: unexpected error: %v", err)
+	}
+	want := mustParseShortForm("1 DATA_33 0x" + descPubKey1 +
+		" DATA_33 0x" + descPubKey2 + " 2 CHECKMULTISIG")
+	if !bytes.Equal(script, want) {
+		t.Fatalf("NewMultiSigAddress: got script %x, want %x", script,
+			want)
+	}
+	if !bytes.Equal(addr.ScriptAddress(), czzutil.Hash160(script)) {
+		t.Fatalf("NewMultiSigAddress: got script hash %x, want %x",
+			addr.ScriptAddress(), czzutil.Hash160(script))
+	}
+
+	nRequired, keys, err := ParseMultiSigRedeemScript(script)
+	if err != nil {
+		t.Fatalf("ParseMultiSigRedeemScript: unexpected error: %v", err)
+	}
+	if nRequired != 1 || len(keys) != 2 ||
+		!bytes.Equal(keys[0], pubKey1.ScriptAddress()) ||
+		!bytes.Equal(keys[1], pubKey2.ScriptAddress()) {
+
+		t.Fatalf("ParseMultiSigRedeemScript: got %d of %x", nRequired,
+			keys)
+	}
+}
+
+// TestInvalidMultiSigRedeemScript ensures invalid multisig parameters and
+// scripts which are not multisig redeem scripts are rejected.
+func TestInvalidMultiSigRedeemScript(t *testing.T) {
+	pubKey := newAddressPubKey(hexToBytes(descPubKey1)).(*czzutil.AddressPubKey)
+	hybrid, err := czzutil.NewAddressPubKey(hexToBytes(descHybridKey),
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressPubKey: unexpected error: %v", err)
+	}
+	uncompressed := newAddressPubKey(hexToBytes("0411db93e1dcdb8a016b49840f8c5" +
+		"3bc1eb68a382e97b1482ecad7b148a6909a5cb2e0eaddfb84ccf9744464f82e16" +
+		"0bfa9b8b64f9d4c03f999b8643f656b412a3")).(*czzutil.AddressPubKey)
+	tooMany := make([]*czzutil.AddressPubKey, MaxP2SHMultiSigKeys+1)
+	for i := range tooMany {
+		tooMany[i] = pubKey
+	}
+	tooLarge := make([]*czzutil.AddressPubKey, 9)
+	for i := range tooLarge {
+		tooLarge[i] = uncompressed
+	}
+
+	paramTests := []struct {
+		name      string
+		nRequired int
+		pubKeys   []*czzutil.AddressPubKey
+	}{
+		{name: "no keys", nRequired: 1},
+		{name: "no signatures", nRequired: 0,
+			pubKeys: []*czzutil.AddressPubKey{pubKey}},
+		{name: "more signatures than keys", nRequired: 2,
+			pubKeys: []*czzutil.AddressPubKey{pubKey}},
+		{name: "hybrid key", nRequired: 1,
+			pubKeys: []*czzutil.AddressPubKey{hybrid}},
+		{name: "too many keys", nRequired: 1, pubKeys: tooMany},
+		{name: "too large", nRequired: 1, pubKeys: tooLarge},
+	}
+	for _, test := range paramTests {
+		_, err := NewMultiSigRedeemScript(test.nRequired, test.pubKeys)
+		if err != ErrInvalidMultiSig {
+			t.Errorf("NewMultiSigRedeemScript %s: got %v, want %v",
+				test.name, err, ErrInvalidMultiSig)
+		}
+	}
+
+	scriptTests := []struct {
+		name   string
+		script string
+	}{
+		{name: "empty", script: ""},
+		{name: "no signatures", script: "0 DATA_33 0x" + descPubKey1 +
+			" 1 CHECKMULTISIG"},
+		{name: "more signatures than keys", script: "2 DATA_33 0x" +
+			descPubKey1 + " 1 CHECKMULTISIG"},
+		{name: "wrong key count", script: "1 DATA_33 0x" + descPubKey1 +
+			" 2 CHECKMULTISIG"},
+		{name: "non-canonical key push", script: "1 PUSHDATA1 0x21 0x" +
+			descPubKey1 + " 1 CHECKMULTISIG"},
+		{name: "short key", script: "1 DATA_32 0x" + descPubKey1[2:] +
+			" 1 CHECKMULTISIG"},
+		{name: "checksig", script: "1 DATA_33 0x" + descPubKey1 +
+			" 1 CHECKSIG"},
+		{name: "truncated", script: "1 DATA_33 0x" + descPubKey1[2:]},
+	}
+	for _, test := range scriptTests {
+		script := mustParseShortForm(test.script)
+		_, _, err := ParseMultiSigRedeemScript(script)
+		if err != ErrInvalidMultiSig {
+			t.Errorf("ParseMultiSigRedeemScript %s: got %v, want %v",
+				test.name, err, ErrInvalidMultiSig)
+		}
+	}
+}
@@ -2242,6 +2242,10 @@ func opcodeCheckSig(op *parsedOpcode, vm *Engine) error {
 		return nil
 	}
 
+	// Every checksig with a non-empty signature counts as a signature
+	// check regardless of whether or not it verifies.
+	vm.sigChecks++
+
 	// Trim off hashtype from the signature string and check if the
 	// signature and pubkey conform to the strict encoding requirements
 	// depending on the flags.
@@ -2453,6 +2457,15 @@ func opcodeCheckMultiSig(op *parsedOpcode, vm *Engine) error {
 		return scriptError(ErrSigNullDummy, str)
 	}
 
+	// A checkmultisig counts a signature check for every public key unless
+	// all of the signatures are empty.
+	for _, sigInfo := range signatures {
+		if len(sigInfo.signature) > 0 {
+			vm.sigChecks += numPubKeys
+			break
+		}
+	}
+
 	// Get script starting from the most recent OP_CODESEPARATOR.
 	script := vm.subScript()
 
@@ -2635,6 +2648,7 @@ func opcodeCheckDataSig(op *parsedOpcode, vm *Engine) error {
 		if err != nil {
 			return err
 		}
+		vm.sigChecks++
 	}
 	if err := vm.checkPubKeyEncoding(pkBytes); err != nil {
 		return err
@@ -168,6 +168,35 @@ func signMultiSig(tx *wire.MsgTx, idx int, amt int64, subScript []byte, hashType
 	return script, signed == nRequired
 }
 
+// signVault signs the provided vault script with the recovery key when it is
+// known, which spends the vault at any time, and with the owner key otherwise.
+// The addresses are the owner and recovery keys as extracted from the script.
+func signVault(tx *wire.MsgTx, idx int, amt int64, subScript []byte,
+	hashType SigHashType, addresses []czzutil.Address, kdb KeyDB) ([]byte, error) {
+
+	if len(addresses) != 2 {
+		return nil, errors.New("invalid vault public key")
+	}
+	if key, _, err := kdb.GetKey(addresses[1]); err == nil {
+		sig, err := RawTxInSchnorrSignature(tx, idx, subScript, hashType,
+			key, amt)
+		if err != nil {
+			return nil, err
+		}
+		return VaultRecoverySignatureScript(sig)
+	}
+
+	key, _, err := kdb.GetKey(addresses[0])
+	if err != nil {
+		return nil, err
+	}
+	sig, err := RawTxInSchnorrSignature(tx, idx, subScript, hashType, key, amt)
+	if err != nil {
+		return nil, err
+	}
+	return VaultOwnerSignatureScript(sig)
+}
+
 func sign(chainParams *chaincfg.Params, tx *wire.MsgTx, idx int, amt int64,
 	subScript []byte, hashType SigHashType, kdb KeyDB, sdb ScriptDB) ([]byte,
 	ScriptClass, []czzutil.Address, int, error) {
@@ -218,6 +247,14 @@ func sign(chainParams *chaincfg.Params, tx *wire.MsgTx, idx int, amt int64,
 		script, _ := signMultiSig(tx, idx, amt, subScript, hashType,
 			addresses, nrequired, kdb)
 		return script, class, addresses, nrequired, nil
+	case VaultTy:
+		script, err := signVault(tx, idx, amt, subScript, hashType,
+			addresses, kdb)
+		if err != nil {
+			return nil, class, nil, 0, err
+		}
+
+		return script, class, addresses, nrequired, nil
 	case NullDataTy:
 		return nil, class, nil, 0,
 			errors.New("can't sign NULLDATA transactions")
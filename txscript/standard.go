@@ -57,6 +57,7 @@ const (
 	MultiSigTy                       // Multi signature.
 	NullDataTy                       // Empty data-only (provably prunable).
 	EntangleTy                       //
+	VaultTy                          // Time-locked vault with a recovery key.
 )
 
 // scriptClassToName houses the human-readable strings which describe each
@@ -69,6 +70,7 @@ var scriptClassToName = []string{
 	MultiSigTy:    "multisig",
 	NullDataTy:    "nulldata",
 	EntangleTy:    "EntangleTy",
+	VaultTy:       "vault",
 }
 
 // String implements the Stringer interface by returning the name of
@@ -136,21 +138,20 @@ func isMultiSig(pops []parsedOpcode) bool {
 	return true
 }
 
-// isNullData returns true if the passed script is a null data transaction,
-// false otherwise.
-func isNullData(pops []parsedOpcode) bool {
+// nullDataLen returns whether the passed script has the form of a null data
+// script along with the number of bytes it counts against the data carrier
+// limit.  The size itself is not checked.
+func nullDataLen(pops []parsedOpcode) (int, bool) {
 	// A nulldata transaction is either a single OP_RETURN or an
-	// OP_RETURN SMALLDATA (where SMALLDATA is a data push up to
-	// MaxDataCarrierSize bytes).
+	// OP_RETURN SMALLDATA (where SMALLDATA is a data push).
 	//
-	// Multiple pushes of data are allowed but the total script
-	// may not exceed MaxDataCarrierSize.
+	// Multiple pushes of data are allowed.
 	l := len(pops)
 	if l < 1 || pops[0].opcode.value != OP_RETURN {
-		return false
+		return 0, false
 	}
 	if l >= 2 && pops[1].opcode.value == OP_UNKNOWN193 {
-		return false
+		return 0, false
 	}
 
 	scriptLen := 1
@@ -168,10 +169,31 @@ func isNullData(pops []parsedOpcode) bool {
 			scriptLen += -pop.opcode.length + 1
 		}
 		if !isDataOpcode {
-			return false
+			return 0, false
 		}
 	}
-	return scriptLen <= MaxDataCarrierSize
+	return scriptLen, true
+}
+
+// isNullData returns true if the passed script is a null data transaction,
+// false otherwise.
+func isNullData(pops []parsedOpcode) bool {
+	// The total script may not exceed MaxDataCarrierSize.
+	scriptLen, ok := nullDataLen(pops)
+	return ok && scriptLen <= MaxDataCarrierSize
+}
+
+// DataCarrierSize returns the number of bytes the passed script counts
+// against the data carrier limit and whether it has the form of a null data
+// script at all.  Unlike GetScriptClass, scripts larger than
+// MaxDataCarrierSize are still reported so that callers may apply their own
+// limit.  Entangle and keeped-amount scripts are not null data scripts.
+func DataCarrierSize(script []byte) (int, bool) {
+	pops, err := parseScript(script)
+	if err != nil {
+		return 0, false
+	}
+	return nullDataLen(pops)
 }
 
 func isEntangleTy(pops []parsedOpcode) bool {
@@ -203,6 +225,8 @@ func typeOfScript(pops []parsedOpcode) ScriptClass {
 		return NullDataTy
 	} else if isEntangleTy(pops) {
 		return EntangleTy
+	} else if isVault(pops) {
+		return VaultTy
 	}
 	return NonStandardTy
 }
@@ -253,6 +277,10 @@ func expectedInputs(pops []parsedOpcode, class ScriptClass) int {
 		// for the extra push that is required to compensate.
 		return asSmallInt(pops[0].opcode) + 1
 
+	case VaultTy:
+		// A signature and the selector of the spend path.
+		return 2
+
 	case EntangleTy:
 		fallthrough
 	case NullDataTy:
@@ -498,7 +526,7 @@ func GetKeepedAmountData(script []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	if !isKeepedAmountInfo(pops) {
+	if !isKeepedAmountInfo(pops) || len(pops) < 3 {
 		return nil, errors.New("not keepedAmount info type")
 	}
 	return pops[2].data, nil
@@ -508,7 +536,7 @@ func GetEntangleInfoData(script []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	if !isEntangleTy(pops) {
+	if !isEntangleTy(pops) || len(pops) < 3 {
 		return nil, errors.New("not Entangle info type")
 	}
 	return pops[2].data, nil
@@ -604,6 +632,22 @@ func ExtractPkScriptAddrs(pkScript []byte, chainParams *chaincfg.Params) (Script
 			}
 		}
 
+	case VaultTy:
+		// A vault script is of the form:
+		//  OP_IF <recovery pubkey> OP_CHECKSIG OP_ELSE <lock time>
+		//  OP_CHECKLOCKTIMEVERIFY OP_DROP <owner pubkey> OP_CHECKSIG
+		//  OP_ENDIF
+		// Either key spends it alone, so the owner key comes first
+		// followed by the recovery key.  Skip the keys which are
+		// invalid.
+		requiredSigs = 1
+		for _, pop := range []parsedOpcode{pops[7], pops[1]} {
+			addr, err := czzutil.NewAddressPubKey(pop.data, chainParams)
+			if err == nil {
+				addrs = append(addrs, addr)
+			}
+		}
+
 	case NullDataTy:
 		// Null data transactions have no addresses or required
 		// signatures.
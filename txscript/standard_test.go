@@ -1037,6 +1037,11 @@ func TestStringifyClass(t *testing.T) {
 			class:    NullDataTy,
 			stringed: "nulldata",
 		},
+		{
+			name:     "vaultty",
+			class:    VaultTy,
+			stringed: "vault",
+		},
 		{
 			name:     "broken",
 			class:    ScriptClass(255),
@@ -0,0 +1,142 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"errors"
+)
+
+const (
+	// MaxVaultLockTime is the largest lock time a vault can be locked until,
+	// as limited by the lock time of the spending transaction.
+	MaxVaultLockTime = 0xffffffff
+
+	// vaultScriptLen is the number of opcodes of a vault script.
+	vaultScriptLen = 10
+)
+
+// VaultDataPushes houses the data pushes found in vault scripts.
+type VaultDataPushes struct {
+	// OwnerPubKey is the public key which can spend the vault once the
+	// lock time passed.
+	OwnerPubKey []byte
+
+	// RecoveryPubKey is the public key which can spend the vault at any
+	// time, such as to claw the coins back to a new vault.
+	RecoveryPubKey []byte
+
+	// LockTime is the block height or time, following the lock time rules
+	// of the transactions, until which the owner can't spend the vault.
+	LockTime int64
+}
+
+// isPubKeyPush returns whether the passed opcode pushes a public key, which are
+// either 33 or 65 bytes.
+func isPubKeyPush(pop parsedOpcode) bool {
+	return (pop.opcode.value == OP_DATA_33 || pop.opcode.value == OP_DATA_65) &&
+		len(pop.data) == int(pop.opcode.value)
+}
+
+// vaultLockTime returns the lock time pushed by the passed opcode of a vault
+// script and whether it is a valid one.
+func vaultLockTime(pop parsedOpcode) (int64, bool) {
+	if isSmallInt(pop.opcode) {
+		lockTime := int64(asSmallInt(pop.opcode))
+		return lockTime, lockTime > 0
+	}
+	if !canonicalPush(pop) || len(pop.data) == 0 {
+		return 0, false
+	}
+	lockTime, err := makeScriptNum(pop.data, true, 5)
+	if err != nil || lockTime <= 0 || lockTime > MaxVaultLockTime {
+		return 0, false
+	}
+	return int64(lockTime), true
+}
+
+// isVault returns whether the passed script is a vault script of the form:
+//
+//	OP_IF
+//	  <recovery pubkey> OP_CHECKSIG
+//	OP_ELSE
+//	  <lock time> OP_CHECKLOCKTIMEVERIFY OP_DROP <owner pubkey> OP_CHECKSIG
+//	OP_ENDIF
+func isVault(pops []parsedOpcode) bool {
+	if len(pops) != vaultScriptLen {
+		return false
+	}
+	_, validLockTime := vaultLockTime(pops[4])
+	return pops[0].opcode.value == OP_IF &&
+		isPubKeyPush(pops[1]) &&
+		pops[2].opcode.value == OP_CHECKSIG &&
+		pops[3].opcode.value == OP_ELSE &&
+		validLockTime &&
+		pops[5].opcode.value == OP_CHECKLOCKTIMEVERIFY &&
+		pops[6].opcode.value == OP_DROP &&
+		isPubKeyPush(pops[7]) &&
+		pops[8].opcode.value == OP_CHECKSIG &&
+		pops[9].opcode.value == OP_ENDIF
+}
+
+// VaultScript returns a script locking coins in a vault the owner can spend
+// from once the passed lock time passed and the recovery key can spend from at
+// any time.  The lock time is either a block height or a time, following the
+// lock time rules of the transactions.
+func VaultScript(ownerPubKey, recoveryPubKey []byte, lockTime int64) ([]byte, error) {
+	if lockTime <= 0 || lockTime > MaxVaultLockTime {
+		return nil, errors.New("vault lock time out of range")
+	}
+	script, err := NewScriptBuilder().
+		AddOp(OP_IF).
+		AddData(recoveryPubKey).AddOp(OP_CHECKSIG).
+		AddOp(OP_ELSE).
+		AddInt64(lockTime).AddOp(OP_CHECKLOCKTIMEVERIFY).AddOp(OP_DROP).
+		AddData(ownerPubKey).AddOp(OP_CHECKSIG).
+		AddOp(OP_ENDIF).
+		Script()
+	if err != nil {
+		return nil, err
+	}
+
+	pops, err := parseScript(script)
+	if err != nil || !isVault(pops) {
+		return nil, errors.New("invalid vault public key")
+	}
+	return script, nil
+}
+
+// ExtractVaultDataPushes returns the data pushes from a vault script.  If the
+// script is not a vault script, ExtractVaultDataPushes returns (nil, nil).
+// Non-nil errors are returned for unparsable scripts.
+func ExtractVaultDataPushes(script []byte) (*VaultDataPushes, error) {
+	pops, err := parseScript(script)
+	if err != nil {
+		return nil, err
+	}
+	if !isVault(pops) {
+		return nil, nil
+	}
+
+	lockTime, _ := vaultLockTime(pops[4])
+	return &VaultDataPushes{
+		OwnerPubKey:    pops[7].data,
+		RecoveryPubKey: pops[1].data,
+		LockTime:       lockTime,
+	}, nil
+}
+
+// VaultRecoverySignatureScript returns the signature script spending a vault
+// with the passed signature of the recovery key, which is valid at any time.
+func VaultRecoverySignatureScript(sig []byte) ([]byte, error) {
+	return NewScriptBuilder().AddData(sig).AddOp(OP_TRUE).Script()
+}
+
+// VaultOwnerSignatureScript returns the signature script spending a vault with
+// the passed signature of the owner key.  It is only valid for transactions
+// with a lock time at or past the one of the vault and a non final sequence
+// number for the input.
+func VaultOwnerSignatureScript(sig []byte) ([]byte, error) {
+	return NewScriptBuilder().AddData(sig).AddOp(OP_FALSE).Script()
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/czzec"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// TestVaultScript ensures vault scripts are built, classified and their data
+// pushes extracted, and that malformed ones are rejected.
+func TestVaultScript(t *testing.T) {
+	t.Parallel()
+
+	owner, _ := czzec.NewPrivateKey(czzec.S256())
+	recovery, _ := czzec.NewPrivateKey(czzec.S256())
+	ownerPubKey := owner.PubKey().SerializeCompressed()
+	recoveryPubKey := recovery.PubKey().SerializeUncompressed()
+
+	for _, lockTime := range []int64{1, 16, 17, 500000, MaxVaultLockTime} {
+		script, err := VaultScript(ownerPubKey, recoveryPubKey, lockTime)
+		if err != nil {
+			t.Fatalf("lock time %d: unexpected error: %v", lockTime, err)
+		}
+		if class := GetScriptClass(script); class != VaultTy {
+			t.Fatalf("lock time %d: got class %v, want %v", lockTime,
+				class, VaultTy)
+		}
+		pushes, err := ExtractVaultDataPushes(script)
+		if err != nil || pushes == nil {
+			t.Fatalf("lock time %d: unable to extract the data "+
+				"pushes: %v", lockTime, err)
+		}
+		if !bytes.Equal(pushes.OwnerPubKey, ownerPubKey) ||
+			!bytes.Equal(pushes.RecoveryPubKey, recoveryPubKey) ||
+			pushes.LockTime != lockTime {
+
+			t.Fatalf("lock time %d: unexpected data pushes %+v",
+				lockTime, pushes)
+		}
+
+		class, addrs, reqSigs, err := ExtractPkScriptAddrs(script,
+			&chaincfg.MainNetParams)
+		if err != nil || class != VaultTy || reqSigs != 1 || len(addrs) != 2 {
+			t.Fatalf("lock time %d: unexpected addresses %v %v %d %v",
+				lockTime, class, addrs, reqSigs, err)
+		}
+		if !bytes.Equal(addrs[0].ScriptAddress(), ownerPubKey) ||
+			!bytes.Equal(addrs[1].ScriptAddress(), recoveryPubKey) {
+
+			t.Fatalf("lock time %d: addresses %v out of order",
+				lockTime, addrs)
+		}
+	}
+
+	for _, lockTime := range []int64{-1, 0, MaxVaultLockTime + 1} {
+		if _, err := VaultScript(ownerPubKey, recoveryPubKey, lockTime); err == nil {
+			t.Errorf("lock time %d: vault script accepted", lockTime)
+		}
+	}
+	if _, err := VaultScript(ownerPubKey[:32], recoveryPubKey, 10); err == nil {
+		t.Errorf("vault script accepted a truncated public key")
+	}
+
+	// Scripts of the vault shape with other opcodes are not vaults.
+	script, _ := NewScriptBuilder().AddOp(OP_IF).
+		AddData(recoveryPubKey).AddOp(OP_CHECKSIG).
+		AddOp(OP_ELSE).AddInt64(500000).AddOp(OP_CHECKSEQUENCEVERIFY).
+		AddOp(OP_DROP).AddData(ownerPubKey).AddOp(OP_CHECKSIG).
+		AddOp(OP_ENDIF).Script()
+	if class := GetScriptClass(script); class != NonStandardTy {
+		t.Errorf("got class %v for a relative lock, want %v", class,
+			NonStandardTy)
+	}
+	if pushes, err := ExtractVaultDataPushes(script); pushes != nil || err != nil {
+		t.Errorf("extracted data pushes %+v (%v) from a relative lock",
+			pushes, err)
+	}
+}
+
+// TestSignVault ensures vaults are spent at any time with the recovery key and
+// only past their lock time with the owner key.
+func TestSignVault(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.TestNet3Params
+	owner, _ := czzec.NewPrivateKey(czzec.S256())
+	recovery, _ := czzec.NewPrivateKey(czzec.S256())
+	ownerAddr, _ := czzutil.NewAddressPubKey(
+		owner.PubKey().SerializeCompressed(), params)
+	recoveryAddr, _ := czzutil.NewAddressPubKey(
+		recovery.PubKey().SerializeCompressed(), params)
+
+	const lockTime = 1000
+	pkScript, err := VaultScript(ownerAddr.ScriptAddress(),
+		recoveryAddr.ScriptAddress(), lockTime)
+	if err != nil {
+		t.Fatalf("unable to build the vault script: %v", err)
+	}
+
+	const amount = 5
+	tests := []struct {
+		name     string
+		key      *czzec.PrivateKey
+		addr     *czzutil.AddressPubKey
+		lockTime uint32
+		valid    bool
+	}{
+		{"recovery before lock time", recovery, recoveryAddr, 0, true},
+		{"owner before lock time", owner, ownerAddr, lockTime - 1, false},
+		{"owner at lock time", owner, ownerAddr, lockTime, true},
+	}
+	for _, test := range tests {
+		tx := &wire.MsgTx{
+			Version: 1,
+			TxIn: []*wire.TxIn{{
+				PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{}},
+				Sequence:         wire.MaxTxInSequenceNum - 1,
+			}},
+			TxOut:    []*wire.TxOut{{Value: 1}},
+			LockTime: test.lockTime,
+		}
+		kdb := mkGetKey(map[string]addressToKey{
+			test.addr.EncodeAddress(): {test.key, true},
+		})
+		sigScript, err := SignTxOutput(params, tx, 0, amount, pkScript,
+			SigHashAll, kdb, mkGetScript(nil), nil)
+		if err != nil {
+			t.Fatalf("%s: unable to sign: %v", test.name, err)
+		}
+
+		tx.TxIn[0].SignatureScript = sigScript
+		vm, err := NewEngine(pkScript, tx, 0, ScriptBip16|
+			ScriptVerifyBip143SigHash|ScriptVerifySchnorr|
+			ScriptVerifyCheckLockTimeVerify, nil, nil, amount)
+		if err != nil {
+			t.Fatalf("%s: unable to create the engine: %v", test.name, err)
+		}
+		err = vm.Execute()
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%s: spend accepted", test.name)
+		}
+	}
+
+	// Signing without either key fails.
+	tx := &wire.MsgTx{
+		Version: 1,
+		TxIn:    []*wire.TxIn{{Sequence: wire.MaxTxInSequenceNum}},
+		TxOut:   []*wire.TxOut{{Value: 1}},
+	}
+	_, err = SignTxOutput(params, tx, 0, amount, pkScript, SigHashAll,
+		mkGetKey(nil), mkGetScript(nil), nil)
+	if err == nil {
+		t.Errorf("vault signed without its keys")
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// uploadTargetTimeframe is the period the upload target applies to.
+	uploadTargetTimeframe = time.Hour * 24
+
+	// historicalBlockAge is the age after which a block is considered
+	// historical.  Historical blocks are only requested by peers which are
+	// syncing, so serving them is stopped once the upload target is reached
+	// while recent blocks are still relayed.
+	historicalBlockAge = time.Hour * 24 * 7
+)
+
+// uploadTarget keeps track of the number of bytes sent to peers during the
+// current timeframe to limit the upload of nodes on metered connections.
+//
+// An uploadTarget is safe for concurrent access.
+type uploadTarget struct {
+	mtx        sync.Mutex
+	target     uint64
+	cycleStart time.Time
+	sent       uint64
+}
+
+// newUploadTarget returns an upload target which is reached after target bytes
+// were sent within a timeframe.  A target of zero is never reached.
+func newUploadTarget(target uint64) *uploadTarget {
+	return &uploadTarget{
+		target:     target,
+		cycleStart: time.Now(),
+	}
+}
+
+// update starts a new timeframe when the current one ended.  It must be called
+// with the mutex held.
+func (u *uploadTarget) update(now time.Time) {
+	if now.Sub(u.cycleStart) >= uploadTargetTimeframe {
+		u.cycleStart = now
+		u.sent = 0
+	}
+}
+
+// AddBytesSent adds the passed number of bytes to the bytes sent during the
+// current timeframe.
+func (u *uploadTarget) AddBytesSent(n uint64, now time.Time) {
+	u.mtx.Lock()
+	u.update(now)
+	u.sent += n
+	u.mtx.Unlock()
+}
+
+// Reached returns whether the upload target of the current timeframe has been
+// reached.
+func (u *uploadTarget) Reached(now time.Time) bool {
+	if u.target == 0 {
+		return false
+	}
+
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	u.update(now)
+	return u.sent >= u.target
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestUploadTarget ensures the upload target is reached once enough bytes are
+// sent and is reset when a new timeframe starts.
+func TestUploadTarget(t *testing.T) {
+	now := time.Now()
+
+	if newUploadTarget(0).Reached(now) {
+		t.Fatalf("Reached: zero target reached")
+	}
+
+	u := newUploadTarget(1000)
+	u.cycleStart = now
+	u.AddBytesSent(999, now)
+	if u.Reached(now) {
+		t.Fatalf("Reached: target reached after 999 bytes")
+	}
+	u.AddBytesSent(1, now.Add(time.Hour))
+	if !u.Reached(now.Add(time.Hour)) {
+		t.Fatalf("Reached: target not reached after 1000 bytes")
+	}
+	if u.Reached(now.Add(uploadTargetTimeframe)) {
+		t.Fatalf("Reached: target reached in new timeframe")
+	}
+}
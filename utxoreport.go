@@ -0,0 +1,144 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"sort"
+
+	"github.com/bourbaki-czz/classzz/btcjson"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/mempool"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// utxoReportScript is the tally of the unspent outputs of an output script.
+type utxoReportScript struct {
+	txOuts     int64
+	amount     int64
+	dustTxOuts int64
+	dustAmount int64
+}
+
+// add tallies an unspent output of the passed amount.
+func (t *utxoReportScript) add(amount int64, dust bool) {
+	t.txOuts++
+	t.amount += amount
+	if dust {
+		t.dustTxOuts++
+		t.dustAmount += amount
+	}
+}
+
+// utxoReport tallies the unspent outputs of the utxo set per output script to
+// report the reused addresses and the dust outputs for the getutxoreport
+// command.  Outputs are dust when relaying a transaction creating them would be
+// refused by the memory pool policy.
+type utxoReport struct {
+	params        *chaincfg.Params
+	minRelayTxFee czzutil.Amount
+	total         utxoReportScript
+	scripts       map[string]*utxoReportScript
+}
+
+// newUtxoReport returns an empty report of the utxo set of the passed network
+// considering the outputs dust according to the passed minimum relay fee.
+func newUtxoReport(params *chaincfg.Params, minRelayTxFee czzutil.Amount) *utxoReport {
+	return &utxoReport{
+		params:        params,
+		minRelayTxFee: minRelayTxFee,
+		scripts:       make(map[string]*utxoReportScript),
+	}
+}
+
+// Add tallies an unspent output paying the passed amount to the passed script.
+func (r *utxoReport) Add(pkScript []byte, amount int64) {
+	dust := mempool.IsDust(&wire.TxOut{Value: amount, PkScript: pkScript},
+		r.minRelayTxFee)
+	r.total.add(amount, dust)
+
+	tally, ok := r.scripts[string(pkScript)]
+	if !ok {
+		tally = new(utxoReportScript)
+		r.scripts[string(pkScript)] = tally
+	}
+	tally.add(amount, dust)
+}
+
+// scriptResult returns the tally of the passed script as reported by the
+// getutxoreport command.  The script is reported as its first address, or as
+// hex when it does not pay to an address.
+func (r *utxoReport) scriptResult(pkScript []byte, tally *utxoReportScript) btcjson.UtxoReportScript {
+	address := hex.EncodeToString(pkScript)
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, r.params)
+	if err == nil && len(addrs) > 0 {
+		address = addrs[0].EncodeAddress()
+	}
+	return btcjson.UtxoReportScript{
+		Address:    address,
+		TxOuts:     tally.txOuts,
+		Amount:     czzutil.Amount(tally.amount).ToCZZ(),
+		DustTxOuts: tally.dustTxOuts,
+		DustAmount: czzutil.Amount(tally.dustAmount).ToCZZ(),
+	}
+}
+
+// Result returns the report of the tallied outputs.  The scripts holding at
+// least minReuse outputs are reused, and up to count of them holding the most
+// outputs are listed.  The coin pools are always listed.
+func (r *utxoReport) Result(minReuse, count int) *btcjson.GetUtxoReportResult {
+	result := &btcjson.GetUtxoReportResult{
+		Success:     true,
+		TxOuts:      r.total.txOuts,
+		TotalAmount: czzutil.Amount(r.total.amount).ToCZZ(),
+		Scripts:     int64(len(r.scripts)),
+		DustTxOuts:  r.total.dustTxOuts,
+		DustAmount:  czzutil.Amount(r.total.dustAmount).ToCZZ(),
+		Reused:      []btcjson.UtxoReportScript{},
+		Pools:       []btcjson.UtxoReportScript{},
+	}
+
+	var reused []string
+	for pkScript, tally := range r.scripts {
+		if tally.txOuts >= int64(minReuse) {
+			result.ReusedScripts++
+			result.ReusedTxOuts += tally.txOuts
+			reused = append(reused, pkScript)
+		}
+	}
+	sort.Slice(reused, func(i, j int) bool {
+		a, b := r.scripts[reused[i]], r.scripts[reused[j]]
+		if a.txOuts != b.txOuts {
+			return a.txOuts > b.txOuts
+		}
+		if a.amount != b.amount {
+			return a.amount > b.amount
+		}
+		return reused[i] < reused[j]
+	})
+	if len(reused) > count {
+		reused = reused[:count]
+	}
+	for _, pkScript := range reused {
+		result.Reused = append(result.Reused,
+			r.scriptResult([]byte(pkScript), r.scripts[pkScript]))
+	}
+
+	// The pools are paid to their public key hashes by the coinbases.
+	for _, poolHash := range r.params.CoinPoolHashes {
+		pkScript, err := txscript.PayToPubKeyHashScript(poolHash[:])
+		if err != nil {
+			continue
+		}
+		tally, ok := r.scripts[string(pkScript)]
+		if !ok {
+			tally = new(utxoReportScript)
+		}
+		result.Pools = append(result.Pools, r.scriptResult(pkScript, tally))
+	}
+	return result
+}
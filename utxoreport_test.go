@@ -0,0 +1,80 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/btcjson"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// TestUtxoReport ensures the outputs are tallied per script, the reused scripts
+// holding the most outputs are listed first and the coin pools always are.
+func TestUtxoReport(t *testing.T) {
+	params := &chaincfg.SimNetParams
+	payTo := func(hash byte) ([]byte, string) {
+		addr, err := czzutil.NewAddressPubKeyHash(
+			[]byte{19: hash}, params)
+		if err != nil {
+			t.Fatalf("unable to create address: %v", err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Fatalf("unable to create script: %v", err)
+		}
+		return pkScript, addr.EncodeAddress()
+	}
+	pool1, pool1Addr := payTo(params.CoinPoolHashes[0][19])
+	_, pool2Addr := payTo(params.CoinPoolHashes[1][19])
+	reused, reusedAddr := payTo(0xa0)
+	dusty, dustyAddr := payTo(0xa1)
+	single, _ := payTo(0xa2)
+
+	report := newUtxoReport(params, czzutil.Amount(1000))
+	report.Add(pool1, 5e8)
+	for i := 0; i < 3; i++ {
+		report.Add(reused, 1e8)
+	}
+	report.Add(dusty, 1e8)
+	report.Add(dusty, 1)
+	report.Add(single, 1)
+
+	result := report.Result(2, 1)
+	want := &btcjson.GetUtxoReportResult{
+		Success:       true,
+		TxOuts:        7,
+		TotalAmount:   9.00000002,
+		Scripts:       4,
+		DustTxOuts:    2,
+		DustAmount:    0.00000002,
+		ReusedScripts: 2,
+		ReusedTxOuts:  5,
+		Reused: []btcjson.UtxoReportScript{
+			{Address: reusedAddr, TxOuts: 3, Amount: 3},
+		},
+		Pools: []btcjson.UtxoReportScript{
+			{Address: pool1Addr, TxOuts: 1, Amount: 5},
+			{Address: pool2Addr},
+		},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Fatalf("unexpected report -- got %+v, want %+v", result, want)
+	}
+
+	result = report.Result(2, 5)
+	wantReused := []btcjson.UtxoReportScript{
+		{Address: reusedAddr, TxOuts: 3, Amount: 3},
+		{Address: dustyAddr, TxOuts: 2, Amount: 1.00000001,
+			DustTxOuts: 1, DustAmount: 0.00000001},
+	}
+	if !reflect.DeepEqual(result.Reused, wantReused) {
+		t.Fatalf("unexpected reused scripts -- got %+v, want %+v",
+			result.Reused, wantReused)
+	}
+}
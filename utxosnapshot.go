@@ -0,0 +1,378 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/peer"
+	"github.com/bourbaki-czz/classzz/wire"
+)
+
+const (
+	// maxUtxoSnapshotPeers is the maximum number of peers the UTXO snapshot
+	// is fetched from at the same time.
+	maxUtxoSnapshotPeers = 8
+
+	// utxoSnapshotPeerWait is how long fetching the UTXO snapshot waits for
+	// a connected peer serving it before giving up.
+	utxoSnapshotPeerWait = 5 * time.Minute
+
+	// utxoSnapshotChunkTimeout is how long a peer has to send a requested
+	// chunk of the UTXO snapshot.
+	utxoSnapshotChunkTimeout = 2 * time.Minute
+)
+
+// utxoSnapshotChunks returns the number of chunks a UTXO snapshot of the passed
+// size is served in.
+func utxoSnapshotChunks(size int64) uint32 {
+	return uint32((size + wire.UtxoSnapshotChunkSize - 1) /
+		wire.UtxoSnapshotChunkSize)
+}
+
+// utxoSnapshotChunkLen returns the length of the passed chunk of a UTXO
+// snapshot of the passed size.
+func utxoSnapshotChunkLen(size int64, chunk uint32) int {
+	length := size - int64(chunk)*wire.UtxoSnapshotChunkSize
+	if length > wire.UtxoSnapshotChunkSize {
+		length = wire.UtxoSnapshotChunkSize
+	}
+	return int(length)
+}
+
+// utxoSnapshot is a serialized UTXO set at the last checkpoint served to peers
+// in fast sync mode.
+type utxoSnapshot struct {
+	// The following variables must only be used atomically.
+	verified int32
+
+	checkpoint *chaincfg.Checkpoint
+	file       *os.File
+}
+
+// openUtxoSnapshot opens the passed UTXO snapshot of the passed checkpoint.
+// Its size must match the UTXO set size of the checkpoint, its hash is checked
+// by verify.
+func openUtxoSnapshot(fileName string, checkpoint *chaincfg.Checkpoint) (*utxoSnapshot, error) {
+	if checkpoint == nil || checkpoint.UtxoSetHash == nil ||
+		checkpoint.UtxoSetSize == 0 {
+
+		return nil, errors.New("the last checkpoint has no UTXO set")
+	}
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if stat.Size() != int64(checkpoint.UtxoSetSize) {
+		f.Close()
+		return nil, fmt.Errorf("the UTXO snapshot %s is %d bytes, the UTXO "+
+			"set at the last checkpoint is %d bytes", fileName,
+			stat.Size(), checkpoint.UtxoSetSize)
+	}
+
+	return &utxoSnapshot{
+		checkpoint: checkpoint,
+		file:       f,
+	}, nil
+}
+
+// verify checks the hash of the snapshot against the checkpoint and starts
+// serving the snapshot once it matches.  It takes a while for large sets, so
+// it is run as a goroutine.
+func (us *utxoSnapshot) verify() {
+	srvrLog.Infof("Verifying the UTXO snapshot %s", us.file.Name())
+	hash, err := blockchain.HashUtxoSetFile(us.file.Name())
+	if err != nil {
+		srvrLog.Errorf("Unable to verify the UTXO snapshot: %v", err)
+		return
+	}
+	if !hash.IsEqual(us.checkpoint.UtxoSetHash) {
+		srvrLog.Errorf("The UTXO snapshot hash %v does not match the "+
+			"checkpoint at height %d -- not serving it", hash,
+			us.checkpoint.Height)
+		return
+	}
+
+	atomic.StoreInt32(&us.verified, 1)
+	srvrLog.Infof("Serving the UTXO snapshot at height %d",
+		us.checkpoint.Height)
+}
+
+// served returns whether the snapshot is verified and served to peers.  A nil
+// snapshot is never served.
+func (us *utxoSnapshot) served() bool {
+	return us != nil && atomic.LoadInt32(&us.verified) == 1
+}
+
+// chunk returns the data of the passed chunk of the snapshot.
+func (us *utxoSnapshot) chunk(chunk uint32) ([]byte, error) {
+	size := int64(us.checkpoint.UtxoSetSize)
+	if chunk >= utxoSnapshotChunks(size) {
+		return nil, fmt.Errorf("chunk %d is out of range", chunk)
+	}
+	data := make([]byte, utxoSnapshotChunkLen(size, chunk))
+	_, err := us.file.ReadAt(data, int64(chunk)*wire.UtxoSnapshotChunkSize)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// OnGetUtxoSnapshot is invoked when a peer receives a getutxosnap bitcoin
+// message.  The requested chunk of the UTXO snapshot is sent when the snapshot
+// is served, otherwise an empty utxosnap message tells the peer to fetch the
+// chunk elsewhere.  Like historical blocks, the snapshot is not served to peers
+// without the download permission once the upload target is reached.
+func (sp *serverPeer) OnGetUtxoSnapshot(_ *peer.Peer, msg *wire.MsgGetUtxoSnapshot) {
+	us := sp.server.utxoSnapshot
+	limited := !sp.permissions.Has(peer.PermissionDownload) &&
+		sp.server.uploadTarget.Reached(time.Now())
+
+	reply := &wire.MsgUtxoSnapshot{BlockHash: msg.BlockHash, Chunk: msg.Chunk}
+	if us.served() && msg.BlockHash.IsEqual(us.checkpoint.Hash) && !limited {
+		data, err := us.chunk(msg.Chunk)
+		if err != nil {
+			peerLog.Debugf("Unable to serve UTXO snapshot chunk %d to "+
+				"%v: %v", msg.Chunk, sp, err)
+		} else {
+			reply.UtxoSetHash = *us.checkpoint.UtxoSetHash
+			reply.Size = uint64(us.checkpoint.UtxoSetSize)
+			reply.Data = data
+		}
+	}
+
+	// Wait for the chunk to be sent before the next message is read, so a
+	// peer can not have more than one chunk queued at a time.
+	doneChan := make(chan struct{}, 1)
+	sp.QueueMessage(reply, doneChan)
+	<-doneChan
+}
+
+// requestUtxoSnapshotChunk requests the passed chunk of the UTXO snapshot at
+// the passed checkpoint from the peer and returns its data once it is
+// received.  The size of the snapshot and its hash advertised by the peer must
+// match the checkpoint.
+func (sp *serverPeer) requestUtxoSnapshotChunk(checkpoint *chaincfg.Checkpoint,
+	chunk uint32, quit <-chan struct{}) ([]byte, error) {
+
+	quitChan := make(chan struct{})
+	msgChan := make(chan spMsg)
+	subscription := spMsgSubscription{
+		command:  wire.CmdUtxoSnapshot,
+		quitChan: quitChan,
+		msgChan:  msgChan,
+	}
+	sp.subscribeRecvMsg(subscription)
+	defer func() {
+		sp.unsubscribeRecvMsgs(subscription)
+		close(quitChan)
+	}()
+
+	sp.QueueMessage(wire.NewMsgGetUtxoSnapshot(checkpoint.Hash, chunk), nil)
+	timeout := time.After(utxoSnapshotChunkTimeout)
+	select {
+	case <-timeout:
+		return nil, errors.New("timed out")
+
+	case <-sp.quit:
+		return nil, errors.New("peer disconnected")
+
+	case <-quit:
+		return nil, errors.New("fetch stopped")
+
+	case resp := <-msgChan:
+		size := int64(checkpoint.UtxoSetSize)
+		msg := resp.msg.(*wire.MsgUtxoSnapshot)
+		switch {
+		case msg.Size == 0:
+			return nil, errors.New("chunk not served")
+
+		case !msg.BlockHash.IsEqual(checkpoint.Hash) || msg.Chunk != chunk:
+			return nil, fmt.Errorf("received chunk %d at block %v "+
+				"instead", msg.Chunk, msg.BlockHash)
+
+		case !msg.UtxoSetHash.IsEqual(checkpoint.UtxoSetHash) ||
+			msg.Size != uint64(size):
+
+			return nil, fmt.Errorf("snapshot %v of %d bytes does not "+
+				"match the checkpoint", msg.UtxoSetHash, msg.Size)
+
+		case len(msg.Data) != utxoSnapshotChunkLen(size, chunk):
+			return nil, fmt.Errorf("chunk is %d bytes", len(msg.Data))
+		}
+		return msg.Data, nil
+	}
+}
+
+// utxoSnapshotFetch holds the state of fetching a UTXO snapshot from peers
+// shared by the goroutines fetching chunks from each peer.
+type utxoSnapshotFetch struct {
+	// The following variables must only be used atomically.
+	remaining int64
+
+	checkpoint *chaincfg.Checkpoint
+	file       *os.File
+	chunks     chan uint32
+	done       chan struct{}
+	fail       chan error
+	quit       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// fetchFrom fetches chunks from the passed peer, one at a time, until all the
+// chunks are written.  A chunk the peer fails to send is queued to be fetched
+// from another peer and the peer is no longer used.  The peer is sent over the
+// finished channel when it is no longer used.  It must be run as a goroutine.
+func (f *utxoSnapshotFetch) fetchFrom(sp *serverPeer, finished chan<- *serverPeer) {
+	defer f.wg.Done()
+	defer func() {
+		select {
+		case finished <- sp:
+		case <-f.quit:
+		}
+	}()
+
+	for {
+		var chunk uint32
+		select {
+		case chunk = <-f.chunks:
+		case <-f.done:
+			return
+		case <-f.quit:
+			return
+		}
+
+		data, err := sp.requestUtxoSnapshotChunk(f.checkpoint, chunk, f.quit)
+		if err != nil {
+			peerLog.Debugf("Unable to fetch UTXO snapshot chunk %d from "+
+				"%v: %v", chunk, sp, err)
+			f.chunks <- chunk
+			return
+		}
+
+		offset := int64(chunk) * wire.UtxoSnapshotChunkSize
+		if _, err := f.file.WriteAt(data, offset); err != nil {
+			select {
+			case f.fail <- err:
+			default:
+			}
+			return
+		}
+		if atomic.AddInt64(&f.remaining, -1) == 0 {
+			close(f.done)
+		}
+	}
+}
+
+// fetchUtxoSnapshot fetches the UTXO set at the passed checkpoint from the
+// peers advertising it into a file in the passed directory and returns the name
+// of the file.  The chunks are fetched from up to maxUtxoSnapshotPeers peers at
+// the same time.  It gives up when no peer serving the snapshot is connected
+// for utxoSnapshotPeerWait.
+//
+// This is the FetchUtxoSnapshot function of the chain in fast sync mode, which
+// verifies the returned file against the checkpoint.
+func (s *server) fetchUtxoSnapshot(checkpoint *chaincfg.Checkpoint, dir string) (string, error) {
+	file, err := ioutil.TempFile(dir, "utxosnapshot")
+	if err != nil {
+		return "", err
+	}
+
+	numChunks := utxoSnapshotChunks(int64(checkpoint.UtxoSetSize))
+	f := &utxoSnapshotFetch{
+		remaining:  int64(numChunks),
+		checkpoint: checkpoint,
+		file:       file,
+		chunks:     make(chan uint32, numChunks),
+		done:       make(chan struct{}),
+		fail:       make(chan error, 1),
+		quit:       make(chan struct{}),
+	}
+	for i := uint32(0); i < numChunks; i++ {
+		f.chunks <- i
+	}
+
+	srvrLog.Infof("FastSync: Fetching the UTXO set at height %d from peers",
+		checkpoint.Height)
+
+	tried := make(map[*serverPeer]struct{})
+	finished := make(chan *serverPeer)
+	active := 0
+	lastActive := time.Now()
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	progressTicker := time.NewTicker(time.Minute)
+	defer progressTicker.Stop()
+out:
+	for {
+		// Start fetching from the peers serving the snapshot which were
+		// not used yet.
+		for _, sp := range s.connectedPeers() {
+			if active >= maxUtxoSnapshotPeers {
+				break
+			}
+			height := atomic.LoadInt32(&sp.utxoSnapshotHeight)
+			if _, ok := tried[sp]; ok || height != checkpoint.Height {
+				continue
+			}
+			tried[sp] = struct{}{}
+			active++
+			f.wg.Add(1)
+			go f.fetchFrom(sp, finished)
+		}
+		if active == 0 && time.Since(lastActive) > utxoSnapshotPeerWait {
+			err = errors.New("no connected peer serves the UTXO snapshot")
+			break out
+		}
+
+		select {
+		case <-f.done:
+			break out
+
+		case err = <-f.fail:
+			break out
+
+		case <-finished:
+			active--
+			lastActive = time.Now()
+
+		case <-ticker.C:
+
+		case <-progressTicker.C:
+			fetched := int64(numChunks) - atomic.LoadInt64(&f.remaining)
+			srvrLog.Infof("FastSync: Fetched %d of %d MiB of the UTXO "+
+				"set from %d peers", fetched, numChunks, active)
+
+		case <-s.quit:
+			err = errors.New("server shutting down")
+			break out
+		}
+	}
+	close(f.quit)
+	f.wg.Wait()
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
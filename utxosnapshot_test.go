@@ -0,0 +1,96 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/czzec"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzlog"
+)
+
+// TestUtxoSnapshot ensures a UTXO snapshot is only served once it matches the
+// checkpoint and is split in chunks of the chunk size.
+func TestUtxoSnapshot(t *testing.T) {
+	// The log rotator is not initialized in tests.
+	defer func(logger czzlog.Logger) { srvrLog = logger }(srvrLog)
+	srvrLog = czzlog.Disabled
+
+	// Serialize a set of a little more than two chunks.
+	var set bytes.Buffer
+	m := czzec.NewMultiset(czzec.S256())
+	script := make([]byte, 10000)
+	for i := 0; set.Len() <= 2*wire.UtxoSnapshotChunkSize; i++ {
+		utxo := make([]byte, 52+len(script))
+		binary.LittleEndian.PutUint32(utxo, uint32(i))
+		binary.LittleEndian.PutUint32(utxo[48:], uint32(len(script)))
+		copy(utxo[52:], script)
+		m.Add(utxo)
+		set.Write(utxo)
+	}
+
+	f, err := ioutil.TempFile("", "utxosnapshot")
+	if err != nil {
+		t.Fatalf("unable to create temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.Write(set.Bytes())
+	f.Close()
+
+	utxoSetHash := m.Hash()
+	checkpoint := &chaincfg.Checkpoint{
+		Height:      1000,
+		Hash:        &chainhash.Hash{},
+		UtxoSetHash: &utxoSetHash,
+		UtxoSetSize: uint32(set.Len()) + 1,
+	}
+	if _, err := openUtxoSnapshot(f.Name(), checkpoint); err == nil {
+		t.Fatal("openUtxoSnapshot: accepted a snapshot of the wrong size")
+	}
+
+	checkpoint.UtxoSetSize--
+	checkpoint.UtxoSetHash = &chainhash.Hash{}
+	us, err := openUtxoSnapshot(f.Name(), checkpoint)
+	if err != nil {
+		t.Fatalf("openUtxoSnapshot: unexpected error: %v", err)
+	}
+	us.verify()
+	if us.served() {
+		t.Fatal("served a snapshot not matching the checkpoint hash")
+	}
+	checkpoint.UtxoSetHash = &utxoSetHash
+	us.verify()
+	if !us.served() {
+		t.Fatal("did not serve a snapshot matching the checkpoint")
+	}
+
+	if n := utxoSnapshotChunks(int64(set.Len())); n != 3 {
+		t.Fatalf("utxoSnapshotChunks: got %d chunks, want 3", n)
+	}
+	var joined []byte
+	for i := uint32(0); i < 3; i++ {
+		data, err := us.chunk(i)
+		if err != nil {
+			t.Fatalf("chunk %d: unexpected error: %v", i, err)
+		}
+		if i < 2 && len(data) != wire.UtxoSnapshotChunkSize {
+			t.Fatalf("chunk %d: got %d bytes", i, len(data))
+		}
+		joined = append(joined, data...)
+	}
+	if !bytes.Equal(joined, set.Bytes()) {
+		t.Fatal("the chunks do not make up the snapshot")
+	}
+	if _, err := us.chunk(3); err == nil {
+		t.Fatal("chunk: served a chunk out of range")
+	}
+}
@@ -0,0 +1,855 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build wallet
+// +build wallet
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/bip39"
+	"github.com/bourbaki-czz/classzz/btcjson"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wallet"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+// walletSupported indicates whether the built-in wallet is compiled in, which
+// is the case when building with the wallet build tag.
+const walletSupported = true
+
+// nodeWallet is the built-in wallet of the node.
+type nodeWallet = wallet.Wallet
+
+// walletPath returns the path of the wallet file of the active network.
+func walletPath() string {
+	return filepath.Join(cfg.DataDir, defaultWalletFilename)
+}
+
+// openWallet opens the wallet file of the active network.  The returned wallet
+// must be added to the index manager, which maintains its outputs.
+func openWallet(db database.DB, chainParams *chaincfg.Params) (*nodeWallet, error) {
+	path := walletPath()
+	w, err := wallet.New(&wallet.Config{
+		DB:            db,
+		ChainParams:   chainParams,
+		Path:          path,
+		FeeRate:       cfg.minRelayTxFee,
+		MinRelayTxFee: cfg.minRelayTxFee,
+	})
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no wallet file at %s -- create one with "+
+			"--createwallet", path)
+	}
+	return w, err
+}
+
+// promptLine prints the passed prompt and returns the next line read from the
+// reader without surrounding whitespace.
+func promptLine(reader *bufio.Reader, prompt string) (string, error) {
+	fmt.Print(prompt)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// promptYesNo asks the passed question until it is answered with yes or no.
+func promptYesNo(reader *bufio.Reader, question string) (bool, error) {
+	for {
+		answer, err := promptLine(reader, question+" (n/no/y/yes) [no]: ")
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "", "n", "no":
+			return false, nil
+		case "y", "yes":
+			return true, nil
+		}
+	}
+}
+
+// promptPassphrase asks for a new passphrase until a non-empty one is entered
+// twice.
+func promptPassphrase(reader *bufio.Reader) ([]byte, error) {
+	for {
+		pass, err := promptLine(reader, "Enter the passphrase to "+
+			"encrypt the wallet file with: ")
+		if err != nil {
+			return nil, err
+		}
+		if pass == "" {
+			continue
+		}
+		confirm, err := promptLine(reader, "Confirm the passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if pass != confirm {
+			fmt.Println("The entered passphrases do not match")
+			continue
+		}
+		return []byte(pass), nil
+	}
+}
+
+// createWallet interactively creates the wallet file of the active network.
+// The seed of the wallet is either derived from a new mnemonic, which is shown
+// so it can be written down, or from an existing mnemonic to recover a wallet.
+//
+// NOTE: The passphrase is read from standard input as is, so it is shown while
+// it is typed.
+func createWallet(chainParams *chaincfg.Params) error {
+	path := walletPath()
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("the wallet file %s already exists", path)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	recovering, err := promptYesNo(reader, "Do you have an existing mnemonic "+
+		"to recover the wallet from?")
+	if err != nil {
+		return err
+	}
+
+	var mnemonic string
+	if recovering {
+		for {
+			mnemonic, err = promptLine(reader, "Enter the mnemonic: ")
+			if err != nil {
+				return err
+			}
+			_, err = bip39.EntropyFromMnemonic(mnemonic)
+			if err == nil {
+				break
+			}
+			fmt.Println(err)
+		}
+	} else {
+		entropy, err := bip39.NewEntropy(bip39.RecommendedEntropyBits)
+		if err != nil {
+			return err
+		}
+		mnemonic, err = bip39.NewMnemonic(entropy)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Your wallet mnemonic is:")
+		fmt.Println(mnemonic)
+		fmt.Println("IMPORTANT: Keep the mnemonic in a safe place as it " +
+			"is needed to recover the wallet if the wallet file is " +
+			"lost or damaged.")
+		for {
+			answer, err := promptLine(reader, "Once you have stored "+
+				"the mnemonic in a safe and secure location, enter "+
+				"\"OK\" to continue: ")
+			if err != nil {
+				return err
+			}
+			if strings.ToLower(answer) == "ok" {
+				break
+			}
+		}
+	}
+
+	passphrase, err := promptPassphrase(reader)
+	if err != nil {
+		return err
+	}
+	defer zeroBytes(passphrase)
+	seed, err := bip39.NewSeedFromMnemonic(mnemonic, "")
+	if err != nil {
+		return err
+	}
+	defer zeroBytes(seed)
+	if err := wallet.Create(path, chainParams, seed, passphrase); err != nil {
+		return err
+	}
+
+	fmt.Printf("The wallet file %s was created.  Start the node with "+
+		"--wallet to use it.\n", path)
+	if recovering {
+		fmt.Println("The outputs paying to the wallet are found while " +
+			"the wallet index is built from the genesis block.")
+	}
+	return nil
+}
+
+// zeroBytes clears the passed secret once it is not needed anymore.
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// dropWalletIndex drops the index of the outputs paying to the built-in wallet.
+func dropWalletIndex(db database.DB, interrupt <-chan struct{}) error {
+	return wallet.DropWalletIndex(db, interrupt)
+}
+
+// rpcNoWalletEnabled is the error returned by the wallet RPCs when the wallet is
+// compiled in, but not enabled.
+var rpcNoWalletEnabled = &btcjson.RPCError{
+	Code:    btcjson.ErrRPCNoWallet,
+	Message: "The wallet must be enabled (--wallet)",
+}
+
+// walletRPCError converts the passed wallet error to an RPC error.
+func walletRPCError(err error) *btcjson.RPCError {
+	switch err {
+	case wallet.ErrLocked:
+		return &btcjson.RPCError{
+			Code: btcjson.ErrRPCWalletUnlockNeeded,
+			Message: "Please enter the wallet passphrase with " +
+				"walletpassphrase first",
+		}
+	case wallet.ErrWrongPassphrase:
+		return &btcjson.RPCError{
+			Code:    btcjson.ErrRPCWalletPassphraseIncorrect,
+			Message: "The wallet passphrase entered was incorrect",
+		}
+	case wallet.ErrInsufficientFunds:
+		return &btcjson.RPCError{
+			Code:    btcjson.ErrRPCWalletInsufficientFunds,
+			Message: "Insufficient funds",
+		}
+	case wallet.ErrNotSynced:
+		return &btcjson.RPCError{
+			Code:    btcjson.ErrRPCWallet,
+			Message: err.Error(),
+		}
+	}
+	return internalRPCError(err.Error(), "Wallet")
+}
+
+// checkDefaultAccount returns an error when the passed account is not the
+// default account since the built-in wallet does not support accounts.
+func checkDefaultAccount(account *string) error {
+	if account == nil || *account == "" || *account == "*" ||
+		*account == "default" {
+
+		return nil
+	}
+	return &btcjson.RPCError{
+		Code:    btcjson.ErrRPCWalletInvalidAccountName,
+		Message: "Accounts are not supported by the built-in wallet",
+	}
+}
+
+// walletSend creates a transaction paying the passed amounts to the passed
+// addresses with the outputs of the wallet, submits it to the memory pool and
+// relays it.  The outputs reserved for the transaction are released when it is
+// rejected.
+func walletSend(s *rpcServer, amounts map[string]float64, minConf int) (interface{}, error) {
+	if minConf < 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Minconf must not be negative",
+		}
+	}
+
+	params := s.cfg.ChainParams
+	outputs := make([]*wire.TxOut, 0, len(amounts))
+	for encodedAddr, amount := range amounts {
+		if amount <= 0 || amount > czzutil.MaxSatoshi {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCType,
+				Message: "Invalid amount",
+			}
+		}
+		satoshi, err := czzutil.NewAmount(amount)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCType,
+				Message: "Invalid amount: " + err.Error(),
+			}
+		}
+
+		addr, err := czzutil.DecodeAddress(encodedAddr, params)
+		if err != nil || !addr.IsForNet(params) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid address: " + encodedAddr,
+			}
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid address: " + err.Error(),
+			}
+		}
+		outputs = append(outputs, wire.NewTxOut(int64(satoshi), pkScript))
+	}
+
+	tx, err := s.cfg.Wallet.CreateTx(outputs, int32(minConf))
+	if err != nil {
+		return nil, walletRPCError(err)
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(tx.SerializeSize())
+	if err := tx.Serialize(&buf); err != nil {
+		hash := tx.TxHash()
+		s.cfg.Wallet.AbandonTx(&hash)
+		context := "Failed to serialize transaction"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	cmd := btcjson.NewSendRawTransactionCmd(hex.EncodeToString(buf.Bytes()), nil)
+	result, err := handleSendRawTransaction(s, cmd, nil)
+	if err != nil {
+		hash := tx.TxHash()
+		s.cfg.Wallet.AbandonTx(&hash)
+		return nil, err
+	}
+	return result, nil
+}
+
+// handleAddMultisigAddress handles addmultisigaddress commands.
+func handleAddMultisigAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AddMultisigAddressCmd)
+	if s.cfg.Wallet == nil {
+		return nil, rpcNoWalletEnabled
+	}
+	if err := checkDefaultAccount(c.Account); err != nil {
+		return nil, err
+	}
+
+	// The keys may also be given as addresses of the wallet, which are
+	// replaced by their public keys.
+	params := s.cfg.ChainParams
+	keys := make([]string, len(c.Keys))
+	for i, key := range c.Keys {
+		keys[i] = key
+		addr, err := czzutil.DecodeAddress(key, params)
+		if err != nil {
+			continue
+		}
+		if _, ok := addr.(*czzutil.AddressPubKeyHash); !ok {
+			continue
+		}
+		pubKey, err := s.cfg.Wallet.PubKey(addr)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: err.Error(),
+			}
+		}
+		keys[i] = pubKey.String()
+	}
+
+	pubKeys, err := decodeMultiSigKeys(keys, params)
+	if err != nil {
+		return nil, err
+	}
+	if c.NRequired < 1 || c.NRequired > len(pubKeys) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Number of required signatures must "+
+				"be between 1 and the number of keys (%d)",
+				len(pubKeys)),
+		}
+	}
+
+	addr, err := s.cfg.Wallet.AddMultiSigAddress(c.NRequired, pubKeys)
+	if err == txscript.ErrInvalidMultiSig {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unable to create the redeem script: " + err.Error(),
+		}
+	}
+	if err != nil {
+		return nil, walletRPCError(err)
+	}
+	return addr.EncodeAddress(), nil
+}
+
+// handleGetBalance handles getbalance commands.
+func handleGetBalance(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBalanceCmd)
+	if s.cfg.Wallet == nil {
+		return nil, rpcNoWalletEnabled
+	}
+	if err := checkDefaultAccount(c.Account); err != nil {
+		return nil, err
+	}
+
+	balance, err := s.cfg.Wallet.Balance(int32(*c.MinConf))
+	if err != nil {
+		return nil, walletRPCError(err)
+	}
+
+	// The amount marshals to an exact JSON number in CZZ.
+	return btcjson.Amount(balance.Spendable), nil
+}
+
+// handleGetNewAddress handles getnewaddress commands.
+func handleGetNewAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetNewAddressCmd)
+	if s.cfg.Wallet == nil {
+		return nil, rpcNoWalletEnabled
+	}
+	if err := checkDefaultAccount(c.Account); err != nil {
+		return nil, err
+	}
+
+	addr, err := s.cfg.Wallet.NewAddress()
+	if err != nil {
+		return nil, walletRPCError(err)
+	}
+	return addr.EncodeAddress(), nil
+}
+
+// handleGetRawChangeAddress handles getrawchangeaddress commands.
+func handleGetRawChangeAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetRawChangeAddressCmd)
+	if s.cfg.Wallet == nil {
+		return nil, rpcNoWalletEnabled
+	}
+	if err := checkDefaultAccount(c.Account); err != nil {
+		return nil, err
+	}
+
+	addr, err := s.cfg.Wallet.ChangeAddress()
+	if err != nil {
+		return nil, walletRPCError(err)
+	}
+	return addr.EncodeAddress(), nil
+}
+
+// handleGetWalletInfo handles getwalletinfo commands.
+func handleGetWalletInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if s.cfg.Wallet == nil {
+		return nil, rpcNoWalletEnabled
+	}
+
+	balance, err := s.cfg.Wallet.Balance(1)
+	if err != nil {
+		return nil, walletRPCError(err)
+	}
+
+	// Per bitcoind, the wallet is locked when unlocked_until is zero.  A
+	// wallet which stays unlocked until walletlock is issued reports -1.
+	var unlockedUntil int64
+	if until, ok := s.cfg.Wallet.UnlockedUntil(); ok {
+		unlockedUntil = -1
+		if !until.IsZero() {
+			unlockedUntil = until.Unix()
+		}
+	}
+
+	return &btcjson.GetWalletInfoResult{
+		Balance:                  balance.Spendable.ToCZZ(),
+		UnconfirmedBalance:       balance.Unconfirmed.ToCZZ(),
+		ImmatureBalance:          balance.Immature.ToCZZ(),
+		EntangledBalance:         balance.Entangled.ToCZZ(),
+		ImmatureEntangledBalance: balance.ImmatureEntangled.ToCZZ(),
+		SyncedHeight:             s.cfg.Wallet.SyncedHeight(),
+		UnlockedUntil:            unlockedUntil,
+		PaytxFee:                 s.cfg.Wallet.FeeRate().ToCZZ(),
+	}, nil
+}
+
+// handleImportDescriptor handles importdescriptor commands.
+func handleImportDescriptor(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ImportDescriptorCmd)
+	if s.cfg.Wallet == nil {
+		return nil, rpcNoWalletEnabled
+	}
+
+	desc, err := txscript.ParseDescriptor(c.Descriptor, s.cfg.ChainParams)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid descriptor: " + err.Error(),
+		}
+	}
+
+	err = s.cfg.Wallet.ImportDescriptor(desc, *c.Rescan, s.cfg.Chain)
+	if err != nil {
+		return nil, walletRPCError(err)
+	}
+	return nil, nil
+}
+
+// handleImportPrivKey handles importprivkey commands.
+func handleImportPrivKey(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ImportPrivKeyCmd)
+	if s.cfg.Wallet == nil {
+		return nil, rpcNoWalletEnabled
+	}
+
+	wif, err := czzutil.DecodeWIF(c.PrivKey)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid private key: " + err.Error(),
+		}
+	}
+	if !wif.IsForNet(s.cfg.ChainParams) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "The private key is for the wrong network",
+		}
+	}
+
+	err = s.cfg.Wallet.ImportPrivKey(wif, *c.Rescan, s.cfg.Chain)
+	if err != nil {
+		return nil, walletRPCError(err)
+	}
+	return nil, nil
+}
+
+// handleListUnspent handles listunspent commands.
+func handleListUnspent(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ListUnspentCmd)
+	if s.cfg.Wallet == nil {
+		return nil, rpcNoWalletEnabled
+	}
+
+	params := s.cfg.ChainParams
+	var filter map[string]struct{}
+	if c.Addresses != nil {
+		filter = make(map[string]struct{}, len(*c.Addresses))
+		for _, encodedAddr := range *c.Addresses {
+			addr, err := czzutil.DecodeAddress(encodedAddr, params)
+			if err != nil || !addr.IsForNet(params) {
+				return nil, &btcjson.RPCError{
+					Code:    btcjson.ErrRPCInvalidAddressOrKey,
+					Message: "Invalid address: " + encodedAddr,
+				}
+			}
+			filter[addr.EncodeAddress()] = struct{}{}
+		}
+	}
+
+	unspent, err := s.cfg.Wallet.ListUnspent(int32(*c.MinConf),
+		int32(*c.MaxConf))
+	if err != nil {
+		return nil, walletRPCError(err)
+	}
+
+	results := make([]btcjson.ListUnspentResult, 0, len(unspent))
+	for _, credit := range unspent {
+		var address string
+		_, addrs, _, _ := txscript.ExtractPkScriptAddrs(credit.PkScript,
+			params)
+		if len(addrs) == 1 {
+			address = addrs[0].EncodeAddress()
+		}
+		if filter != nil {
+			if _, ok := filter[address]; !ok {
+				continue
+			}
+		}
+
+		results = append(results, btcjson.ListUnspentResult{
+			TxID:          credit.OutPoint.Hash.String(),
+			Vout:          credit.OutPoint.Index,
+			Address:       address,
+			ScriptPubKey:  hex.EncodeToString(credit.PkScript),
+			RedeemScript:  hex.EncodeToString(credit.RedeemScript),
+			Amount:        credit.Amount.ToCZZ(),
+			Confirmations: int64(credit.Confirmations),
+			Spendable: credit.Mature && !credit.Spent &&
+				!credit.WatchOnly,
+			Entangled: credit.Entangled,
+		})
+	}
+	return results, nil
+}
+
+// handleSendMany handles sendmany commands.
+func handleSendMany(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SendManyCmd)
+	if s.cfg.Wallet == nil {
+		return nil, rpcNoWalletEnabled
+	}
+	if err := checkDefaultAccount(&c.FromAccount); err != nil {
+		return nil, err
+	}
+	if len(c.Amounts) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "No addresses to send to",
+		}
+	}
+
+	return walletSend(s, c.Amounts, *c.MinConf)
+}
+
+// handleSendToAddress handles sendtoaddress commands.
+func handleSendToAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SendToAddressCmd)
+	if s.cfg.Wallet == nil {
+		return nil, rpcNoWalletEnabled
+	}
+	if c.SubtractFeeFromAmount != nil && *c.SubtractFeeFromAmount {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "Subtracting the fee from the amount is not " +
+				"supported by the built-in wallet",
+		}
+	}
+
+	return walletSend(s, map[string]float64{c.Address: c.Amount}, 1)
+}
+
+// handleSetTxFee handles settxfee commands.
+func handleSetTxFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetTxFeeCmd)
+	if s.cfg.Wallet == nil {
+		return nil, rpcNoWalletEnabled
+	}
+
+	feeRate, err := czzutil.NewAmount(c.Amount)
+	if err != nil || feeRate < cfg.minRelayTxFee {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("The fee rate must be at least the "+
+				"minimum relay fee of %v per kB", cfg.minRelayTxFee),
+		}
+	}
+	s.cfg.Wallet.SetFeeRate(feeRate)
+	return true, nil
+}
+
+// handleWalletLock handles walletlock commands.
+func handleWalletLock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if s.cfg.Wallet == nil {
+		return nil, rpcNoWalletEnabled
+	}
+
+	s.cfg.Wallet.Lock()
+	return nil, nil
+}
+
+// handleWalletPassphrase handles walletpassphrase commands.
+func handleWalletPassphrase(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.WalletPassphraseCmd)
+	if s.cfg.Wallet == nil {
+		return nil, rpcNoWalletEnabled
+	}
+	if c.Timeout < 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Timeout must not be negative",
+		}
+	}
+
+	timeout := time.Duration(c.Timeout) * time.Second
+	passphrase := []byte(c.Passphrase)
+	err := s.cfg.Wallet.Unlock(passphrase, timeout)
+	zeroBytes(passphrase)
+	if err != nil {
+		return nil, walletRPCError(err)
+	}
+	return nil, nil
+}
+
+// handleWalletPassphraseChange handles walletpassphrasechange commands.
+func handleWalletPassphraseChange(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.WalletPassphraseChangeCmd)
+	if s.cfg.Wallet == nil {
+		return nil, rpcNoWalletEnabled
+	}
+	if c.NewPassphrase == "" {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "The new passphrase must not be empty",
+		}
+	}
+
+	oldPassphrase := []byte(c.OldPassphrase)
+	newPassphrase := []byte(c.NewPassphrase)
+	err := s.cfg.Wallet.ChangePassphrase(oldPassphrase, newPassphrase)
+	zeroBytes(oldPassphrase)
+	zeroBytes(newPassphrase)
+	if err != nil {
+		return nil, walletRPCError(err)
+	}
+	return nil, nil
+}
+
+// walletMiningAddr returns a new address of the passed wallet to pay the
+// generated blocks to.
+func walletMiningAddr(w *nodeWallet) (czzutil.Address, error) {
+	return w.NewAddress()
+}
+
+// walletHelpDescsEnUS defines the English descriptions used for the help text
+// of the wallet RPCs.
+var walletHelpDescsEnUS = map[string]string{
+	// AddMultisigAddressCmd help.
+	"addmultisigaddress--synopsis": "Adds the multisig script requiring the passed number of signatures of the passed keys to the wallet as a watch-only address and returns its pay-to-script-hash address.\n" +
+		"The outputs paying to the address from now on are listed by listunspent along with the redeem script, but they are not part of the balance and are not spent by the wallet.",
+	"addmultisigaddress-nrequired": "The number of signatures required to spend outputs paying to the address",
+	"addmultisigaddress-keys":      "The hex-encoded public keys or addresses of the wallet, in the order they appear in the redeem script",
+	"addmultisigaddress-account":   "Only the default account is supported",
+	"addmultisigaddress--result0":  "The pay-to-script-hash address",
+
+	// GetBalanceCmd help.
+	"getbalance--synopsis": "Returns the balance of the wallet which can be spent with outputs which have at least the passed number of confirmations.\n" +
+		"Immature coinbase outputs, including entangle payouts, are not part of it.",
+	"getbalance-account":  "Only the default account is supported",
+	"getbalance-minconf":  "The minimum number of confirmations an output must have to be counted",
+	"getbalance--result0": "The spendable balance in CZZ",
+
+	// GetNewAddressCmd help.
+	"getnewaddress--synopsis": "Returns a new address of the wallet to receive payments with.",
+	"getnewaddress-account":   "Only the default account is supported",
+	"getnewaddress--result0":  "The new address",
+
+	// GetRawChangeAddressCmd help.
+	"getrawchangeaddress--synopsis": "Returns a new address of the wallet to receive change with.",
+	"getrawchangeaddress-account":   "Only the default account is supported",
+	"getrawchangeaddress--result0":  "The new change address",
+
+	// GetWalletInfoCmd help.
+	"getwalletinfo--synopsis": "Returns the balances of the wallet, including the parts paid out by entangle transactions, along with its sync and lock state.",
+
+	// GetWalletInfoResult help.
+	"getwalletinforesult-balance":                    "The spendable balance in CZZ of outputs with at least one confirmation",
+	"getwalletinforesult-unconfirmed_balance":        "The balance in CZZ of unconfirmed outputs, including the change of unconfirmed transactions of the wallet",
+	"getwalletinforesult-immature_balance":           "The balance in CZZ of coinbase outputs, including entangle payouts, which can not be spent yet",
+	"getwalletinforesult-entangled_balance":          "The part of the spendable balance in CZZ paid out by entangle transactions",
+	"getwalletinforesult-immature_entangled_balance": "The part of the immature balance in CZZ paid out by entangle transactions",
+	"getwalletinforesult-syncedheight":               "The height of the block the wallet is synced to",
+	"getwalletinforesult-unlocked_until":             "The unix time the wallet is locked again, -1 if it stays unlocked until walletlock is issued and 0 if it is locked",
+	"getwalletinforesult-paytxfee":                   "The fee rate in CZZ per kB transactions sent by the wallet pay",
+
+	// ImportDescriptorCmd help.
+	"importdescriptor--synopsis": "Imports an output descriptor into the wallet, which watches the output script it describes.  The outputs paying to the script are watch-only unless they pay to keys of the wallet.\n" +
+		"Rescanning scans a snapshot of the utxo set for the outputs already paying to the script, which requires the wallet to be synced.",
+	"importdescriptor-descriptor": "The pkh(<pubkey>), sh(multi(<n>,<pubkey>,...)), sh(sortedmulti(<n>,<pubkey>,...)), raw(<script hex>) or addr(<address>) descriptor with an optional checksum",
+	"importdescriptor-rescan":     "Whether to scan the utxo set for outputs paying to the script",
+
+	// ImportPrivKeyCmd help.
+	"importprivkey--synopsis": "Imports a private key into the wallet.  The wallet must be unlocked.\n" +
+		"Rescanning scans a snapshot of the utxo set for the outputs already paying to the key, which requires the wallet to be synced.",
+	"importprivkey-privkey": "The WIF-encoded private key",
+	"importprivkey-label":   "Unused",
+	"importprivkey-rescan":  "Whether to scan the utxo set for outputs paying to the key",
+
+	// ListUnspentCmd help.
+	"listunspent--synopsis": "Returns the unspent outputs paying to the wallet, including outputs spent by unconfirmed transactions of the wallet and immature outputs, which are not spendable.",
+	"listunspent-minconf":   "The minimum number of confirmations of the outputs",
+	"listunspent-maxconf":   "The maximum number of confirmations of the outputs",
+	"listunspent-addresses": "Only return outputs paying to the passed addresses",
+
+	// ListUnspentResult help.
+	"listunspentresult-txid":          "The hash of the transaction",
+	"listunspentresult-vout":          "The index of the output",
+	"listunspentresult-address":       "The address the output pays to",
+	"listunspentresult-account":       "Unused",
+	"listunspentresult-scriptPubKey":  "The hex-encoded public key script of the output",
+	"listunspentresult-redeemScript":  "The hex-encoded redeem script of outputs paying to a watch-only multisig address or sh descriptor",
+	"listunspentresult-amount":        "The amount of the output in CZZ",
+	"listunspentresult-confirmations": "The number of confirmations of the output",
+	"listunspentresult-spendable":     "Whether the output can be spent by the wallet, which is never the case for outputs paying to a watch-only multisig address or descriptor",
+	"listunspentresult-entangled":     "Whether the output pays out an entangle transaction",
+
+	// SendManyCmd help.
+	"sendmany--synopsis":      "Sends the passed amounts to the passed addresses with a single transaction and returns its hash.  The wallet must be unlocked.",
+	"sendmany-fromaccount":    "Only the default account is supported",
+	"sendmany-amounts":        "JSON object with the destination addresses as keys and amounts as values",
+	"sendmany-amounts--key":   "address",
+	"sendmany-amounts--value": "n.nnn",
+	"sendmany-amounts--desc":  "The destination address as the key and the amount in CZZ as the value",
+	"sendmany-minconf":        "The minimum number of confirmations of the spent outputs",
+	"sendmany-comment":        "Unused",
+	"sendmany--result0":       "The hash of the sent transaction",
+
+	// SendToAddressCmd help.
+	"sendtoaddress--synopsis":             "Sends the passed amount to the passed address and returns the hash of the transaction.  The wallet must be unlocked.",
+	"sendtoaddress-address":               "The address to send to",
+	"sendtoaddress-amount":                "The amount in CZZ to send",
+	"sendtoaddress-comment":               "Unused",
+	"sendtoaddress-commentto":             "Unused",
+	"sendtoaddress--result0":              "The hash of the sent transaction",
+	"sendtoaddress-subtractfeefromamount": "Not supported",
+
+	// SetTxFeeCmd help.
+	"settxfee--synopsis": "Sets the fee rate transactions sent by the wallet pay.",
+	"settxfee-amount":    "The fee rate in CZZ per kB, which must be at least the minimum relay fee",
+	"settxfee--result0":  "The fee rate was set",
+
+	// WalletLockCmd help.
+	"walletlock--synopsis": "Removes the decrypted keys of the wallet from memory.",
+
+	// WalletPassphraseCmd help.
+	"walletpassphrase--synopsis":  "Decrypts the keys of the wallet so transactions can be sent.",
+	"walletpassphrase-passphrase": "The passphrase of the wallet file",
+	"walletpassphrase-timeout":    "The number of seconds after which the wallet is locked again, 0 to stay unlocked until walletlock is issued",
+
+	// WalletPassphraseChangeCmd help.
+	"walletpassphrasechange--synopsis":     "Encrypts the keys of the wallet file with a new passphrase.  The wallet stays unlocked when it was.",
+	"walletpassphrasechange-oldpassphrase": "The current passphrase of the wallet file",
+	"walletpassphrasechange-newpassphrase": "The new passphrase of the wallet file",
+}
+
+// walletResultTypes specifies the result types of the wallet RPCs.
+var walletResultTypes = map[string][]interface{}{
+	"addmultisigaddress":     {(*string)(nil)},
+	"getbalance":             {(*float64)(nil)},
+	"getnewaddress":          {(*string)(nil)},
+	"getrawchangeaddress":    {(*string)(nil)},
+	"getwalletinfo":          {(*btcjson.GetWalletInfoResult)(nil)},
+	"importdescriptor":       nil,
+	"importprivkey":          nil,
+	"listunspent":            {(*[]btcjson.ListUnspentResult)(nil)},
+	"sendmany":               {(*string)(nil)},
+	"sendtoaddress":          {(*string)(nil)},
+	"settxfee":               {(*bool)(nil)},
+	"walletlock":             nil,
+	"walletpassphrase":       nil,
+	"walletpassphrasechange": nil,
+}
+
+// walletHandlers maps the wallet RPCs to their handlers.
+var walletHandlers = map[string]commandHandler{
+	"addmultisigaddress":     handleAddMultisigAddress,
+	"getbalance":             handleGetBalance,
+	"getnewaddress":          handleGetNewAddress,
+	"getrawchangeaddress":    handleGetRawChangeAddress,
+	"getwalletinfo":          handleGetWalletInfo,
+	"importdescriptor":       handleImportDescriptor,
+	"importprivkey":          handleImportPrivKey,
+	"listunspent":            handleListUnspent,
+	"sendmany":               handleSendMany,
+	"sendtoaddress":          handleSendToAddress,
+	"settxfee":               handleSetTxFee,
+	"walletlock":             handleWalletLock,
+	"walletpassphrase":       handleWalletPassphrase,
+	"walletpassphrasechange": handleWalletPassphraseChange,
+}
+
+func init() {
+	wallet.UseLogger(wlltLog)
+
+	for method, handler := range walletHandlers {
+		rpcHandlersBeforeInit[method] = handler
+	}
+	for key, desc := range walletHelpDescsEnUS {
+		helpDescsEnUS[key] = desc
+	}
+	for method, resultTypes := range walletResultTypes {
+		rpcResultTypes[method] = resultTypes
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package wallet implements a hierarchical deterministic wallet which runs inside
+the node.
+
+The wallet derives its addresses from a single BIP0044 account of a seed, which
+is commonly created from a BIP0039 mnemonic, and can additionally hold imported
+private keys.  The seed and the private keys are kept in the wallet file,
+encrypted with a passphrase, while the extended public key of the account is
+kept in the clear so new addresses can be given out and payments can be found
+while the wallet is locked.
+
+The unspent outputs paying to the wallet are tracked by an index which is
+maintained by the index manager of the blockchain/indexers package along with
+the chain, so the wallet is caught up when the node starts and follows chain
+reorganizations the same way as the other indexes.
+
+Entangle Outputs
+
+Coinbase transactions after the entangle height pay out entangle transactions
+with the outputs following the block reward, coin pool and keeped-amount
+outputs.  These outputs are subject to the coinbase maturity like the block
+reward, and the wallet reports the part of its balance which was paid out by
+entangle transactions separately, both for spendable and immature outputs.
+*/
+package wallet
@@ -0,0 +1,386 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/blockchain/indexers"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+const (
+	// walletIndexName is the human-readable name for the index.
+	walletIndexName = "wallet index"
+
+	// outpointKeySize is the size of a serialized outpoint used as key in
+	// the credits bucket.
+	outpointKeySize = chainhash.HashSize + 4
+
+	// creditHeaderSize is the size of the fixed part of a serialized
+	// credit which is followed by the output script.
+	creditHeaderSize = 8 + 4 + 1
+
+	// creditFlagCoinbase marks credits created by a coinbase transaction.
+	creditFlagCoinbase = 1 << 0
+)
+
+var (
+	// byteOrder is the preferred byte order used for serializing numeric
+	// fields for storage in the database.
+	byteOrder = binary.LittleEndian
+
+	// walletIndexKey is the key of the wallet index and the db bucket used
+	// to house it.
+	walletIndexKey = []byte("walletidx")
+
+	// creditsBucketName is the name of the bucket within the wallet index
+	// bucket which houses the unspent outputs of the wallet.
+	creditsBucketName = []byte("credits")
+
+	// metaBucketName is the name of the bucket within the wallet index
+	// bucket which houses the state of the wallet.
+	metaBucketName = []byte("meta")
+
+	// accountKeyName is the key of the extended public key of the account
+	// the index was built for.
+	accountKeyName = []byte("account")
+
+	// usedKeyName is the key of the number of addresses given out or used
+	// on each branch of the account.
+	usedKeyName = []byte("used")
+
+	// heightKeyName is the key of the height the index is synced to.
+	heightKeyName = []byte("height")
+)
+
+// -----------------------------------------------------------------------------
+// The wallet index consists of an entry for every unspent output paying to an
+// address of the wallet, along with the state needed to know which addresses
+// to watch.
+//
+// The serialized format for keys and values in the credits bucket is:
+//   <outpoint> = <amount><height><flags><pkscript>
+//
+//   Field           Type              Size
+//   outpoint hash   chainhash.Hash    32 bytes
+//   outpoint index  uint32            4 bytes
+//   -----
+//   Total: 36 bytes
+//
+//   Field           Type              Size
+//   amount          int64             8 bytes
+//   height          uint32            4 bytes
+//   flags           byte              1 byte
+//   pkscript        []byte            variable
+// -----------------------------------------------------------------------------
+
+// credit is an unspent output paying to an address of the wallet.
+type credit struct {
+	outpoint wire.OutPoint
+	amount   czzutil.Amount
+	height   int32
+	coinbase bool
+	pkScript []byte
+}
+
+// outpointKey returns the key of the credits bucket entry for the passed
+// outpoint.
+func outpointKey(op *wire.OutPoint) []byte {
+	key := make([]byte, outpointKeySize)
+	copy(key, op.Hash[:])
+	byteOrder.PutUint32(key[chainhash.HashSize:], op.Index)
+	return key
+}
+
+// serializeCredit returns the serialized credits bucket entry for the passed
+// credit.
+func serializeCredit(c *credit) []byte {
+	serialized := make([]byte, creditHeaderSize+len(c.pkScript))
+	byteOrder.PutUint64(serialized, uint64(c.amount))
+	byteOrder.PutUint32(serialized[8:], uint32(c.height))
+	if c.coinbase {
+		serialized[12] |= creditFlagCoinbase
+	}
+	copy(serialized[creditHeaderSize:], c.pkScript)
+	return serialized
+}
+
+// deserializeCredit decodes the passed credits bucket entry.
+func deserializeCredit(key, serialized []byte) (*credit, error) {
+	if len(key) != outpointKeySize || len(serialized) < creditHeaderSize {
+		return nil, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "unexpected end of data for wallet credit",
+		}
+	}
+
+	c := &credit{
+		amount:   czzutil.Amount(byteOrder.Uint64(serialized)),
+		height:   int32(byteOrder.Uint32(serialized[8:])),
+		coinbase: serialized[12]&creditFlagCoinbase != 0,
+		pkScript: make([]byte, len(serialized)-creditHeaderSize),
+	}
+	copy(c.outpoint.Hash[:], key[:chainhash.HashSize])
+	c.outpoint.Index = byteOrder.Uint32(key[chainhash.HashSize:])
+	copy(c.pkScript, serialized[creditHeaderSize:])
+	return c, nil
+}
+
+// creditsBucket returns the credits bucket of the wallet index.
+func creditsBucket(dbTx database.Tx) database.Bucket {
+	return dbTx.Metadata().Bucket(walletIndexKey).Bucket(creditsBucketName)
+}
+
+// metaBucket returns the meta bucket of the wallet index.
+func metaBucket(dbTx database.Tx) database.Bucket {
+	return dbTx.Metadata().Bucket(walletIndexKey).Bucket(metaBucketName)
+}
+
+// dbPutUsed stores the number of addresses given out or used on each branch.
+func dbPutUsed(dbTx database.Tx, used [2]uint32) error {
+	var serialized [8]byte
+	byteOrder.PutUint32(serialized[:], used[0])
+	byteOrder.PutUint32(serialized[4:], used[1])
+	return metaBucket(dbTx).Put(usedKeyName, serialized[:])
+}
+
+// dbPutHeight stores the height the wallet index is synced to.
+func dbPutHeight(dbTx database.Tx, height int32) error {
+	var serialized [4]byte
+	byteOrder.PutUint32(serialized[:], uint32(height))
+	return metaBucket(dbTx).Put(heightKeyName, serialized[:])
+}
+
+// Ensure the Wallet type implements the indexers.Indexer interface.
+var _ indexers.Indexer = (*Wallet)(nil)
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the indexers.Indexer interface.
+func (w *Wallet) Key() []byte {
+	return walletIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the indexers.Indexer interface.
+func (w *Wallet) Name() string {
+	return walletIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs to be
+// created for the first time.  It creates the buckets of the index and records
+// the account of the wallet file it is built for.
+//
+// This is part of the indexers.Indexer interface.
+func (w *Wallet) Create(dbTx database.Tx) error {
+	bucket, err := dbTx.Metadata().CreateBucket(walletIndexKey)
+	if err != nil {
+		return err
+	}
+	if _, err := bucket.CreateBucket(creditsBucketName); err != nil {
+		return err
+	}
+	meta, err := bucket.CreateBucket(metaBucketName)
+	if err != nil {
+		return err
+	}
+	if err := meta.Put(accountKeyName, []byte(w.ks.file.AccountXpub)); err != nil {
+		return err
+	}
+	if err := dbPutUsed(dbTx, [2]uint32{}); err != nil {
+		return err
+	}
+	return dbPutHeight(dbTx, -1)
+}
+
+// Migrate is only provided to satisfy the Indexer interface as there is nothing
+// to migrate this index.
+//
+// This is part of the indexers.Indexer interface.
+func (w *Wallet) Migrate(db database.DB, interrupt <-chan struct{}) error {
+	// Nothing to do.
+	return nil
+}
+
+// Init loads the state of the wallet from the index and derives the addresses
+// to watch.  The index must have been built for the account of the wallet file.
+//
+// This is part of the indexers.Indexer interface.
+func (w *Wallet) Init() error {
+	return w.view(func(dbTx database.Tx) error {
+		meta := metaBucket(dbTx)
+		account := meta.Get(accountKeyName)
+		if string(account) != w.ks.file.AccountXpub {
+			return fmt.Errorf("the %s was built for a different "+
+				"wallet and must be dropped with --dropwalletindex",
+				walletIndexName)
+		}
+
+		used := meta.Get(usedKeyName)
+		height := meta.Get(heightKeyName)
+		if len(used) != 8 || len(height) != 4 {
+			return database.Error{
+				ErrorCode:   database.ErrCorruption,
+				Description: "unexpected end of data for wallet state",
+			}
+		}
+		w.used[0] = byteOrder.Uint32(used)
+		w.used[1] = byteOrder.Uint32(used[4:])
+		w.height = int32(byteOrder.Uint32(height))
+
+		for branch := range w.used {
+			err := w.deriveAddresses(uint32(branch))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  It adds the outputs of the block paying to the
+// wallet to the credits and removes the credits spent by the block.
+//
+// This is part of the indexers.Indexer interface.
+func (w *Wallet) ConnectBlock(dbTx database.Tx, block *czzutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	// The outputs are added before the inputs are spent since blocks are
+	// ordered canonically, so a transaction may spend an output created by
+	// a transaction after it in the block.
+	credits := creditsBucket(dbTx)
+	used := w.used
+	for txIdx, tx := range block.Transactions() {
+		for i, txOut := range tx.MsgTx().TxOut {
+			ref, ok := w.lookupScript(txOut.PkScript)
+			if !ok {
+				continue
+			}
+			c := &credit{
+				outpoint: wire.OutPoint{Hash: *tx.Hash(), Index: uint32(i)},
+				amount:   czzutil.Amount(txOut.Value),
+				height:   block.Height(),
+				coinbase: txIdx == 0,
+				pkScript: txOut.PkScript,
+			}
+			err := credits.Put(outpointKey(&c.outpoint), serializeCredit(c))
+			if err != nil {
+				return err
+			}
+			if err := w.markUsed(ref); err != nil {
+				return err
+			}
+		}
+	}
+	for txIdx, tx := range block.Transactions() {
+		if txIdx == 0 {
+			continue
+		}
+		for _, txIn := range tx.MsgTx().TxIn {
+			op := txIn.PreviousOutPoint
+			if w.rescanned != nil {
+				w.rescanned[op] = struct{}{}
+			}
+			w.removeConflict(&op, tx.Hash())
+
+			key := outpointKey(&op)
+			if credits.Get(key) == nil {
+				continue
+			}
+			if err := credits.Delete(key); err != nil {
+				return err
+			}
+		}
+		w.removePending(tx.Hash())
+	}
+
+	if w.used != used {
+		if err := dbPutUsed(dbTx, w.used); err != nil {
+			return err
+		}
+	}
+	w.height = block.Height()
+	return dbPutHeight(dbTx, w.height)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  It restores the credits spent by the
+// block and removes the credits created by it.
+//
+// This is part of the indexers.Indexer interface.
+func (w *Wallet) DisconnectBlock(dbTx database.Tx, block *czzutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	// The spent outputs are restored before the created outputs are
+	// removed, so outputs both created and spent by the block are gone.
+	credits := creditsBucket(dbTx)
+	stxoIdx := 0
+	for txIdx, tx := range block.Transactions() {
+		if txIdx == 0 {
+			continue
+		}
+		for _, txIn := range tx.MsgTx().TxIn {
+			if stxoIdx >= len(stxos) {
+				return indexers.AssertError(fmt.Sprintf("missing "+
+					"spent output for input %v",
+					txIn.PreviousOutPoint))
+			}
+			stxo := &stxos[stxoIdx]
+			stxoIdx++
+			if _, ok := w.lookupScript(stxo.PkScript); !ok {
+				continue
+			}
+			c := &credit{
+				outpoint: txIn.PreviousOutPoint,
+				amount:   czzutil.Amount(stxo.Amount),
+				height:   stxo.Height,
+				coinbase: stxo.IsCoinBase,
+				pkScript: stxo.PkScript,
+			}
+			err := credits.Put(outpointKey(&c.outpoint), serializeCredit(c))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, tx := range block.Transactions() {
+		for i, txOut := range tx.MsgTx().TxOut {
+			op := wire.OutPoint{Hash: *tx.Hash(), Index: uint32(i)}
+			if w.rescanned != nil {
+				w.rescanned[op] = struct{}{}
+			}
+			if _, ok := w.lookupScript(txOut.PkScript); !ok {
+				continue
+			}
+			if err := credits.Delete(outpointKey(&op)); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.height = block.Height() - 1
+	return dbPutHeight(dbTx, w.height)
+}
+
+// DropWalletIndex drops the wallet index from the provided database if it
+// exists.  The wallet file is not affected, so the index is rebuilt from the
+// genesis block the next time the wallet is enabled.
+func DropWalletIndex(db database.DB, interrupt <-chan struct{}) error {
+	return indexers.DropIndex(db, walletIndexKey, walletIndexName, interrupt)
+}
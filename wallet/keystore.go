@@ -0,0 +1,472 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/bourbaki-czz/classzz/bip44"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/czzec"
+	"github.com/bourbaki-czz/czzutil"
+	"github.com/bourbaki-czz/czzutil/hdkeychain"
+)
+
+const (
+	// keystoreVersion is the current version of the wallet file.
+	keystoreVersion = 1
+
+	// defaultAccount is the BIP0044 account of the key chain the wallet
+	// derives its addresses from.
+	defaultAccount = 0
+
+	// kdfIterations is the number of PBKDF2 iterations used to derive the
+	// encryption key from the passphrase of new wallet files.
+	kdfIterations = 100000
+
+	// saltSize is the size of the random salt of the encryption key.
+	saltSize = 32
+)
+
+// importedKey is a private key imported into the wallet file.  The public key
+// is kept in the clear so the addresses of the key can be watched while the
+// wallet is locked.
+type importedKey struct {
+	PubKey  []byte `json:"pubkey"`
+	PrivKey []byte `json:"privkey"`
+}
+
+// keystoreFile is the JSON encoded content of the wallet file.  The seed and
+// the private keys are encrypted with AES-256-GCM using a key derived from the
+// passphrase with PBKDF2-HMAC-SHA256.  The extended public key of the account
+// is kept in the clear so addresses can be derived while the wallet is locked.
+type keystoreFile struct {
+	Version     int           `json:"version"`
+	Net         string        `json:"net"`
+	AccountXpub string        `json:"accountxpub"`
+	Salt        []byte        `json:"salt"`
+	Iterations  int           `json:"iterations"`
+	Seed        []byte        `json:"seed"`
+	Imported    []importedKey `json:"imported,omitempty"`
+	Scripts     [][]byte      `json:"scripts,omitempty"`
+	Descriptors []string      `json:"descriptors,omitempty"`
+}
+
+// keystore houses the keys of the wallet and is backed by the wallet file.
+// The secret material is only available while the keystore is unlocked.
+type keystore struct {
+	path     string
+	net      *chaincfg.Params
+	file     keystoreFile
+	branches [2]*hdkeychain.ExtendedKey
+
+	// These fields are only set while the keystore is unlocked.
+	cryptKey []byte
+	account  *hdkeychain.ExtendedKey
+}
+
+// deriveKey derives the 256-bit encryption key from the passphrase and salt
+// with PBKDF2-HMAC-SHA256.  The key is a single PBKDF2 block.
+func deriveKey(passphrase, salt []byte, iterations int) []byte {
+	prf := hmac.New(sha256.New, passphrase)
+	prf.Write(salt)
+	var blockNum [4]byte
+	binary.BigEndian.PutUint32(blockNum[:], 1)
+	prf.Write(blockNum[:])
+	u := prf.Sum(nil)
+
+	key := make([]byte, len(u))
+	copy(key, u)
+	for i := 1; i < iterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	zero(u)
+	return key
+}
+
+// encrypt seals the passed plaintext with AES-256-GCM.  The random nonce is
+// prepended to the returned ciphertext.
+func encrypt(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// decrypt opens ciphertext sealed by encrypt.  ErrWrongPassphrase is returned
+// when the ciphertext was not sealed with the passed key.
+func decrypt(key, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	nonce := ciphertext[:aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, ciphertext[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+// accountKey derives the private extended key of the default account from the
+// passed seed.
+func accountKey(seed []byte, net *chaincfg.Params) (*hdkeychain.ExtendedKey, error) {
+	master, err := hdkeychain.NewMaster(seed, net)
+	if err != nil {
+		return nil, err
+	}
+	path, err := bip44.AccountPath(net, defaultAccount)
+	if err != nil {
+		return nil, err
+	}
+	return bip44.DerivePath(master, path)
+}
+
+// createKeystore creates a new wallet file at the passed path holding the
+// passed seed encrypted with the passphrase.  It fails when the file already
+// exists.
+func createKeystore(path string, net *chaincfg.Params, seed, passphrase []byte) error {
+	account, err := accountKey(seed, net)
+	if err != nil {
+		return err
+	}
+	xpub, err := account.Neuter()
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	cryptKey := deriveKey(passphrase, salt, kdfIterations)
+	encryptedSeed, err := encrypt(cryptKey, seed)
+	zero(cryptKey)
+	if err != nil {
+		return err
+	}
+
+	ks := &keystore{
+		path: path,
+		net:  net,
+		file: keystoreFile{
+			Version:     keystoreVersion,
+			Net:         net.Name,
+			AccountXpub: xpub.String(),
+			Salt:        salt,
+			Iterations:  kdfIterations,
+			Seed:        encryptedSeed,
+		},
+	}
+	if _, err := os.Stat(path); err == nil {
+		return ErrWalletExists
+	}
+	return ks.save()
+}
+
+// openKeystore loads the wallet file at the passed path.  The keystore is
+// locked until unlock is called.
+func openKeystore(path string, net *chaincfg.Params) (*keystore, error) {
+	serialized, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ks := &keystore{path: path, net: net}
+	if err := json.Unmarshal(serialized, &ks.file); err != nil {
+		return nil, fmt.Errorf("malformed wallet file %s: %v", path, err)
+	}
+	if ks.file.Version != keystoreVersion {
+		return nil, fmt.Errorf("wallet file %s has unsupported version "+
+			"%d", path, ks.file.Version)
+	}
+	if ks.file.Net != net.Name {
+		return nil, fmt.Errorf("wallet file %s is for the %s network, "+
+			"not %s", path, ks.file.Net, net.Name)
+	}
+
+	xpub, err := hdkeychain.NewKeyFromString(ks.file.AccountXpub)
+	if err != nil {
+		return nil, fmt.Errorf("malformed account key in wallet file "+
+			"%s: %v", path, err)
+	}
+	for branch := range ks.branches {
+		ks.branches[branch], err = xpub.Child(uint32(branch))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+// save atomically replaces the wallet file with the current content of the
+// keystore.
+func (ks *keystore) save() error {
+	serialized, err := json.MarshalIndent(&ks.file, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := ks.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, serialized, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, ks.path)
+}
+
+// isLocked returns whether the secret material of the keystore is unavailable.
+func (ks *keystore) isLocked() bool {
+	return ks.cryptKey == nil
+}
+
+// unlock decrypts the seed with the passphrase and derives the private key of
+// the account from it.
+func (ks *keystore) unlock(passphrase []byte) error {
+	cryptKey := deriveKey(passphrase, ks.file.Salt, ks.file.Iterations)
+	seed, err := decrypt(cryptKey, ks.file.Seed)
+	if err != nil {
+		zero(cryptKey)
+		return err
+	}
+	account, err := accountKey(seed, ks.net)
+	zero(seed)
+	if err != nil {
+		zero(cryptKey)
+		return err
+	}
+
+	ks.lock()
+	ks.cryptKey = cryptKey
+	ks.account = account
+	return nil
+}
+
+// changePassphrase encrypts the seed and the imported private keys with a key
+// derived from the new passphrase and a new salt, and replaces the wallet file.
+// The old passphrase must decrypt the wallet file.  The keystore stays unlocked
+// when it was.
+func (ks *keystore) changePassphrase(oldPassphrase, newPassphrase []byte) error {
+	oldKey := deriveKey(oldPassphrase, ks.file.Salt, ks.file.Iterations)
+	defer zero(oldKey)
+	seed, err := decrypt(oldKey, ks.file.Seed)
+	if err != nil {
+		return err
+	}
+	defer zero(seed)
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	newKey := deriveKey(newPassphrase, salt, kdfIterations)
+	file, err := ks.reencrypt(oldKey, newKey, seed)
+	if err != nil {
+		zero(newKey)
+		return err
+	}
+	file.Salt = salt
+	file.Iterations = kdfIterations
+
+	oldFile := ks.file
+	ks.file = file
+	if err := ks.save(); err != nil {
+		ks.file = oldFile
+		zero(newKey)
+		return err
+	}
+	if ks.isLocked() {
+		zero(newKey)
+	} else {
+		zero(ks.cryptKey)
+		ks.cryptKey = newKey
+	}
+	return nil
+}
+
+// reencrypt returns the content of the wallet file with the passed seed and the
+// imported private keys, which are decrypted with the old key, encrypted with
+// the new key.
+func (ks *keystore) reencrypt(oldKey, newKey, seed []byte) (keystoreFile, error) {
+	file := ks.file
+	encryptedSeed, err := encrypt(newKey, seed)
+	if err != nil {
+		return file, err
+	}
+	file.Seed = encryptedSeed
+
+	file.Imported = nil
+	for _, imported := range ks.file.Imported {
+		privKey, err := decrypt(oldKey, imported.PrivKey)
+		if err != nil {
+			return file, err
+		}
+		encryptedKey, err := encrypt(newKey, privKey)
+		zero(privKey)
+		if err != nil {
+			return file, err
+		}
+		file.Imported = append(file.Imported, importedKey{
+			PubKey:  imported.PubKey,
+			PrivKey: encryptedKey,
+		})
+	}
+	return file, nil
+}
+
+// lock removes the secret material from memory.
+func (ks *keystore) lock() {
+	if ks.cryptKey != nil {
+		zero(ks.cryptKey)
+		ks.cryptKey = nil
+	}
+	if ks.account != nil {
+		ks.account.Zero()
+		ks.account = nil
+	}
+}
+
+// pubKey returns the serialized public key at the passed index of the passed
+// branch of the account.
+func (ks *keystore) pubKey(branch, index uint32) ([]byte, error) {
+	child, err := ks.branches[branch].Child(index)
+	if err != nil {
+		return nil, err
+	}
+	pubKey, err := child.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+	return pubKey.SerializeCompressed(), nil
+}
+
+// privKey returns the private key of the passed key reference.  The keystore
+// must be unlocked.
+func (ks *keystore) privKey(ref keyRef) (*czzec.PrivateKey, bool, error) {
+	if ks.isLocked() {
+		return nil, false, ErrLocked
+	}
+
+	if ref.imported {
+		imported := ks.file.Imported[ref.index]
+		serialized, err := decrypt(ks.cryptKey, imported.PrivKey)
+		if err != nil {
+			return nil, false, err
+		}
+		privKey, _ := czzec.PrivKeyFromBytes(czzec.S256(), serialized)
+		zero(serialized)
+		return privKey, len(imported.PubKey) == czzec.PubKeyBytesLenCompressed, nil
+	}
+
+	child, err := bip44.DerivePath(ks.account, []uint32{ref.branch, ref.index})
+	if err != nil {
+		return nil, false, err
+	}
+	privKey, err := child.ECPrivKey()
+	if err != nil {
+		return nil, false, err
+	}
+	return privKey, true, nil
+}
+
+// importKey adds the private key of the passed WIF to the wallet file and
+// returns its index among the imported keys.  The keystore must be unlocked.
+// Importing a key which already is in the wallet file returns its index.
+func (ks *keystore) importKey(wif *czzutil.WIF) (uint32, error) {
+	if ks.isLocked() {
+		return 0, ErrLocked
+	}
+
+	pubKey := wif.SerializePubKey()
+	for i, imported := range ks.file.Imported {
+		if string(imported.PubKey) == string(pubKey) {
+			return uint32(i), nil
+		}
+	}
+
+	encryptedKey, err := encrypt(ks.cryptKey, wif.PrivKey.Serialize())
+	if err != nil {
+		return 0, err
+	}
+	ks.file.Imported = append(ks.file.Imported, importedKey{
+		PubKey:  pubKey,
+		PrivKey: encryptedKey,
+	})
+	if err := ks.save(); err != nil {
+		ks.file.Imported = ks.file.Imported[:len(ks.file.Imported)-1]
+		return 0, err
+	}
+	return uint32(len(ks.file.Imported) - 1), nil
+}
+
+// addScript adds the passed redeem script to the wallet file and returns its
+// index among the redeem scripts.  Adding a redeem script which already is in
+// the wallet file returns its index.
+func (ks *keystore) addScript(script []byte) (uint32, error) {
+	for i, s := range ks.file.Scripts {
+		if string(s) == string(script) {
+			return uint32(i), nil
+		}
+	}
+
+	ks.file.Scripts = append(ks.file.Scripts, script)
+	if err := ks.save(); err != nil {
+		ks.file.Scripts = ks.file.Scripts[:len(ks.file.Scripts)-1]
+		return 0, err
+	}
+	return uint32(len(ks.file.Scripts) - 1), nil
+}
+
+// addDescriptor adds the passed watch-only output descriptor to the wallet file
+// and returns its index among the descriptors.  Adding a descriptor which
+// already is in the wallet file returns its index.
+func (ks *keystore) addDescriptor(desc string) (uint32, error) {
+	for i, d := range ks.file.Descriptors {
+		if d == desc {
+			return uint32(i), nil
+		}
+	}
+
+	ks.file.Descriptors = append(ks.file.Descriptors, desc)
+	if err := ks.save(); err != nil {
+		ks.file.Descriptors = ks.file.Descriptors[:len(ks.file.Descriptors)-1]
+		return 0, err
+	}
+	return uint32(len(ks.file.Descriptors) - 1), nil
+}
+
+// zero clears the passed secret.
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
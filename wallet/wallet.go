@@ -0,0 +1,1028 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bourbaki-czz/classzz/bip44"
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/czzec"
+	"github.com/bourbaki-czz/classzz/database"
+	"github.com/bourbaki-czz/classzz/mempool"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+	"github.com/bourbaki-czz/czzutil/hdkeychain"
+)
+
+const (
+	// addressLookahead is the number of addresses past the last address
+	// given out or used on a branch which are watched, so payments to
+	// addresses given out by another copy of the wallet are found.
+	addressLookahead = 20
+
+	// entanglePayoutIndex is the index of the first output of a coinbase
+	// transaction which pays out an entangle transaction.  The outputs
+	// before it are the block reward, the two coin pools and the
+	// keeped-amount output.
+	entanglePayoutIndex = 4
+
+	// spendSize is the largest number of bytes of a sigScript which
+	// spends a p2pkh output: OP_DATA_65 <sig> OP_DATA_65 <pubkey>.  The
+	// public key is only that large for imported uncompressed keys.
+	spendSize = 1 + 65 + 1 + 65
+
+	// changeSize is the serialized size of a p2pkh change output.
+	changeSize = 8 + 1 + 25
+)
+
+var (
+	// ErrWalletExists describes an error in which a wallet file is created
+	// where one already exists.
+	ErrWalletExists = errors.New("the wallet file already exists")
+
+	// ErrLocked describes an error in which an operation requires the
+	// private keys of a locked wallet.
+	ErrLocked = errors.New("the wallet is locked")
+
+	// ErrWrongPassphrase describes an error in which the passphrase does
+	// not decrypt the wallet file.
+	ErrWrongPassphrase = errors.New("the passphrase is incorrect")
+
+	// ErrInsufficientFunds describes an error in which the spendable
+	// outputs of the wallet do not cover the amount to send and the fee.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+
+	// ErrNotSynced describes an error in which an operation requires the
+	// wallet to be caught up with the best chain.
+	ErrNotSynced = errors.New("the wallet is still catching up with the " +
+		"best chain")
+)
+
+// keyRef identifies the key an address of the wallet belongs to.  It is either
+// the key at the index of a branch of the account, the imported key at the
+// index, the watch-only redeem script at the index, or the watch-only
+// descriptor at the index.
+type keyRef struct {
+	branch   uint32
+	index    uint32
+	imported bool
+	script   bool
+	watched  bool
+}
+
+// Config is the configuration of a wallet.
+type Config struct {
+	// DB is the database the outputs of the wallet are indexed in.
+	DB database.DB
+
+	// ChainParams identifies which chain parameters the wallet is
+	// associated with.
+	ChainParams *chaincfg.Params
+
+	// Path is the path of the wallet file.
+	Path string
+
+	// FeeRate is the fee rate in czz per kilobyte transactions sent by
+	// the wallet pay.  It must not be below the minimum relay fee.
+	FeeRate czzutil.Amount
+
+	// MinRelayTxFee is the minimum relay fee used to determine whether a
+	// change output would be dust.
+	MinRelayTxFee czzutil.Amount
+}
+
+// Balance is the balance of the wallet broken down by how the outputs can be
+// spent.  The entangled amounts are the parts of the spendable and immature
+// amounts paid out by entangle transactions.
+type Balance struct {
+	// Spendable is the amount of confirmed mature outputs which are not
+	// spent by an unconfirmed transaction of the wallet.
+	Spendable czzutil.Amount
+
+	// Unconfirmed is the amount of outputs with less than the minimum
+	// number of confirmations, including the change of unconfirmed
+	// transactions of the wallet.
+	Unconfirmed czzutil.Amount
+
+	// Immature is the amount of coinbase outputs, including entangle
+	// payouts, which can not be spent yet.
+	Immature czzutil.Amount
+
+	// Entangled is the part of the spendable amount paid out by entangle
+	// transactions.
+	Entangled czzutil.Amount
+
+	// ImmatureEntangled is the part of the immature amount paid out by
+	// entangle transactions.
+	ImmatureEntangled czzutil.Amount
+}
+
+// Credit describes an unspent output paying to the wallet.
+type Credit struct {
+	OutPoint      wire.OutPoint
+	Amount        czzutil.Amount
+	PkScript      []byte
+	Height        int32
+	Confirmations int32
+	Coinbase      bool
+
+	// Entangled is whether the output pays out an entangle transaction.
+	Entangled bool
+
+	// Mature is whether the output can be spent in the next block.  Only
+	// coinbase outputs are ever immature.
+	Mature bool
+
+	// Spent is whether the output is spent by an unconfirmed transaction
+	// of the wallet.
+	Spent bool
+
+	// WatchOnly is whether the output pays to a watch-only multisig
+	// address or descriptor, which the wallet does not spend.
+	WatchOnly bool
+
+	// RedeemScript is the redeem script of the output when it pays to a
+	// watch-only multisig address or sh descriptor.
+	RedeemScript []byte
+}
+
+// Wallet is a hierarchical deterministic wallet which is maintained along with
+// the chain as an index.  It derives the addresses of a single BIP0044 account
+// and can additionally hold imported keys.  The outputs paying to the wallet
+// are kept in the index, while the keys are kept encrypted in the wallet file.
+//
+// Transactions sent by the wallet are tracked until they are mined, so their
+// inputs are not spent twice and their change is reported as unconfirmed.  They
+// are only tracked in memory.
+type Wallet struct {
+	db            database.DB
+	params        *chaincfg.Params
+	ks            *keystore
+	minRelayTxFee czzutil.Amount
+
+	mtx       sync.Mutex
+	addrs     map[string]keyRef
+	scripts   map[string]uint32
+	watched   map[string]uint32
+	descs     []*txscript.Descriptor
+	used      [2]uint32
+	derived   [2]uint32
+	height    int32
+	feeRate   czzutil.Amount
+	pending   map[chainhash.Hash]*wire.MsgTx
+	spending  map[wire.OutPoint]chainhash.Hash
+	lockTimer *time.Timer
+	unlocked  time.Time
+
+	// rescanned records the outputs spent or removed by blocks while
+	// imported keys are being rescanned, which is the case when rescans
+	// is not zero.
+	rescans   int
+	rescanned map[wire.OutPoint]struct{}
+}
+
+// Create creates a new wallet file at the passed path holding the passed seed,
+// which is encrypted with the passphrase.  The seed is commonly derived from a
+// mnemonic with bip39.NewSeedFromMnemonic.
+func Create(path string, net *chaincfg.Params, seed, passphrase []byte) error {
+	return createKeystore(path, net, seed, passphrase)
+}
+
+// New opens the wallet file at the configured path and returns the wallet.  It
+// is locked and must be added to the index manager before the chain is
+// created, which initializes and maintains it.
+func New(cfg *Config) (*Wallet, error) {
+	ks, err := openKeystore(cfg.Path, cfg.ChainParams)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Wallet{
+		db:            cfg.DB,
+		params:        cfg.ChainParams,
+		ks:            ks,
+		minRelayTxFee: cfg.MinRelayTxFee,
+		addrs:         make(map[string]keyRef),
+		scripts:       make(map[string]uint32),
+		watched:       make(map[string]uint32),
+		height:        -1,
+		feeRate:       cfg.FeeRate,
+		pending:       make(map[chainhash.Hash]*wire.MsgTx),
+		spending:      make(map[wire.OutPoint]chainhash.Hash),
+	}
+	for i, imported := range ks.file.Imported {
+		ref := keyRef{index: uint32(i), imported: true}
+		w.addrs[string(czzutil.Hash160(imported.PubKey))] = ref
+	}
+	for i, script := range ks.file.Scripts {
+		w.scripts[string(czzutil.Hash160(script))] = uint32(i)
+	}
+	for i, d := range ks.file.Descriptors {
+		desc, err := txscript.ParseDescriptor(d, cfg.ChainParams)
+		if err != nil {
+			return nil, err
+		}
+		w.descs = append(w.descs, desc)
+		w.watched[string(desc.PkScript())] = uint32(i)
+	}
+	return w, nil
+}
+
+// deriveAddresses derives the addresses of the passed branch up to the
+// lookahead past the last address given out or used.
+//
+// This function MUST be called with the wallet lock held.
+func (w *Wallet) deriveAddresses(branch uint32) error {
+	for w.derived[branch] < w.used[branch]+addressLookahead {
+		index := w.derived[branch]
+		pubKey, err := w.ks.pubKey(branch, index)
+		if err == hdkeychain.ErrInvalidChild {
+			// The key at the index can not be derived, so it is
+			// skipped.
+			w.derived[branch]++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		w.addrs[string(czzutil.Hash160(pubKey))] = keyRef{
+			branch: branch,
+			index:  index,
+		}
+		w.derived[branch]++
+	}
+	return nil
+}
+
+// markUsed records that the address of the passed key received an output and
+// derives further addresses of its branch as needed.
+//
+// This function MUST be called with the wallet lock held.
+func (w *Wallet) markUsed(ref keyRef) error {
+	if ref.imported || ref.script || ref.watched || ref.index < w.used[ref.branch] {
+		return nil
+	}
+	w.used[ref.branch] = ref.index + 1
+	return w.deriveAddresses(ref.branch)
+}
+
+// scriptHash returns the hash of the public key the passed p2pkh or p2pk output
+// script pays to.
+func scriptHash(pkScript []byte, params *chaincfg.Params) ([]byte, bool) {
+	class, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, params)
+	if err != nil || len(addrs) != 1 {
+		return nil, false
+	}
+	switch class {
+	case txscript.PubKeyHashTy:
+		return addrs[0].ScriptAddress(), true
+	case txscript.PubKeyTy:
+		return czzutil.Hash160(addrs[0].ScriptAddress()), true
+	}
+	return nil, false
+}
+
+// lookupScript returns the key the passed output script pays to, if it pays
+// to the wallet.  Outputs paying to a watch-only redeem script of the wallet
+// refer to the redeem script instead, and outputs only described by a
+// watch-only descriptor refer to the descriptor.
+//
+// This function MUST be called with the wallet lock held.
+func (w *Wallet) lookupScript(pkScript []byte) (keyRef, bool) {
+	if txscript.IsPayToScriptHash(pkScript) {
+		if index, ok := w.scripts[string(pkScript[2:22])]; ok {
+			return keyRef{index: index, script: true}, true
+		}
+	} else if hash, ok := scriptHash(pkScript, w.params); ok {
+		if ref, ok := w.addrs[string(hash)]; ok {
+			return ref, true
+		}
+	}
+	index, ok := w.watched[string(pkScript)]
+	return keyRef{index: index, watched: true}, ok
+}
+
+// isEntanglePayout returns whether the output at the passed index of a
+// transaction in the block at the passed height pays out an entangle
+// transaction.
+func (w *Wallet) isEntanglePayout(coinbase bool, index uint32, height int32) bool {
+	return coinbase && height >= w.params.EntangleHeight &&
+		index >= entanglePayoutIndex
+}
+
+// isMature returns whether an output of a transaction in the block at the
+// passed height can be spent in the block after the synced height.
+//
+// This function MUST be called with the wallet lock held.
+func (w *Wallet) isMature(coinbase bool, height int32) bool {
+	return !coinbase ||
+		w.height+1-height >= int32(w.params.CoinbaseMaturity)
+}
+
+// isWatchOnly returns whether the passed output script pays to a watch-only
+// redeem script or descriptor of the wallet, which the wallet does not spend.
+//
+// This function MUST be called with the wallet lock held.
+func (w *Wallet) isWatchOnly(pkScript []byte) bool {
+	ref, ok := w.lookupScript(pkScript)
+	return ok && (ref.script || ref.watched)
+}
+
+// removePending stops tracking the passed unconfirmed transaction of the
+// wallet.
+//
+// This function MUST be called with the wallet lock held.
+func (w *Wallet) removePending(hash *chainhash.Hash) {
+	tx, ok := w.pending[*hash]
+	if !ok {
+		return
+	}
+	for _, txIn := range tx.TxIn {
+		delete(w.spending, txIn.PreviousOutPoint)
+	}
+	delete(w.pending, *hash)
+}
+
+// removeConflict stops tracking the unconfirmed transaction of the wallet
+// spending the passed outpoint unless it is the passed spending transaction.
+//
+// This function MUST be called with the wallet lock held.
+func (w *Wallet) removeConflict(op *wire.OutPoint, spender *chainhash.Hash) {
+	hash, ok := w.spending[*op]
+	if !ok || hash == *spender {
+		return
+	}
+	log.Infof("Unconfirmed transaction %v was double spent", hash)
+	w.removePending(&hash)
+}
+
+// address returns the p2pkh address of the passed key.
+func (w *Wallet) address(ref keyRef) (czzutil.Address, error) {
+	var pubKey []byte
+	if ref.imported {
+		pubKey = w.ks.file.Imported[ref.index].PubKey
+	} else {
+		var err error
+		pubKey, err = w.ks.pubKey(ref.branch, ref.index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return czzutil.NewAddressPubKeyHash(czzutil.Hash160(pubKey), w.params)
+}
+
+// nextAddress gives out the next address of the passed branch.
+func (w *Wallet) nextAddress(branch uint32) (czzutil.Address, error) {
+	// The database is updated before the wallet lock is taken, which is
+	// the order the index manager takes them in.
+	var ref keyRef
+	err := w.db.Update(func(dbTx database.Tx) error {
+		w.mtx.Lock()
+		defer w.mtx.Unlock()
+
+		ref = keyRef{branch: branch, index: w.used[branch]}
+		used := w.used
+		used[branch]++
+		if err := dbPutUsed(dbTx, used); err != nil {
+			return err
+		}
+		w.used = used
+		return w.deriveAddresses(branch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return w.address(ref)
+}
+
+// NewAddress returns a new address to receive payments with.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) NewAddress() (czzutil.Address, error) {
+	return w.nextAddress(bip44.ExternalBranch)
+}
+
+// ChangeAddress returns a new address to receive change with.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) ChangeAddress() (czzutil.Address, error) {
+	return w.nextAddress(bip44.InternalBranch)
+}
+
+// IsMine returns whether the passed address belongs to the wallet.  Only the
+// addresses given out so far and the lookahead of each branch are known.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) IsMine(addr czzutil.Address) bool {
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return false
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	_, ok := w.lookupScript(pkScript)
+	return ok
+}
+
+// SyncedHeight returns the height of the block the wallet is synced to.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) SyncedHeight() int32 {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.height
+}
+
+// FeeRate returns the fee rate in czz per kilobyte transactions sent by the
+// wallet pay.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) FeeRate() czzutil.Amount {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.feeRate
+}
+
+// SetFeeRate sets the fee rate in czz per kilobyte transactions sent by the
+// wallet pay.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) SetFeeRate(feeRate czzutil.Amount) {
+	w.mtx.Lock()
+	w.feeRate = feeRate
+	w.mtx.Unlock()
+}
+
+// Unlock decrypts the keys of the wallet with the passphrase so transactions
+// can be signed.  The wallet is locked again after the passed timeout, unless
+// it is zero.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) Unlock(passphrase []byte, timeout time.Duration) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if err := w.ks.unlock(passphrase); err != nil {
+		return err
+	}
+
+	if w.lockTimer != nil {
+		w.lockTimer.Stop()
+		w.lockTimer = nil
+	}
+	w.unlocked = time.Time{}
+	if timeout > 0 {
+		w.lockTimer = time.AfterFunc(timeout, w.Lock)
+		w.unlocked = time.Now().Add(timeout)
+	}
+	return nil
+}
+
+// ChangePassphrase encrypts the keys of the wallet file with the new passphrase
+// instead of the old one.  The wallet stays unlocked when it was, until its
+// timeout if any.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) ChangePassphrase(oldPassphrase, newPassphrase []byte) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.ks.changePassphrase(oldPassphrase, newPassphrase)
+}
+
+// Lock removes the decrypted keys of the wallet from memory.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) Lock() {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.lockTimer != nil {
+		w.lockTimer.Stop()
+		w.lockTimer = nil
+	}
+	w.unlocked = time.Time{}
+	w.ks.lock()
+}
+
+// UnlockedUntil returns whether the wallet is unlocked and the time it will be
+// locked again, which is zero when it stays unlocked until Lock is called.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) UnlockedUntil() (time.Time, bool) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.unlocked, !w.ks.isLocked()
+}
+
+// view calls the passed function with a read-only database transaction and the
+// wallet lock held.  The lock is taken inside the transaction, which is the
+// order the index manager takes them in.
+func (w *Wallet) view(fn func(dbTx database.Tx) error) error {
+	return w.db.View(func(dbTx database.Tx) error {
+		w.mtx.Lock()
+		defer w.mtx.Unlock()
+		return fn(dbTx)
+	})
+}
+
+// forEachCredit calls the passed function with every credit of the wallet.
+func forEachCredit(dbTx database.Tx, fn func(c *credit) error) error {
+	return creditsBucket(dbTx).ForEach(func(k, v []byte) error {
+		c, err := deserializeCredit(k, v)
+		if err != nil {
+			return err
+		}
+		return fn(c)
+	})
+}
+
+// Balance returns the balance of the wallet counting outputs with at least the
+// passed number of confirmations as confirmed.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) Balance(minConf int32) (*Balance, error) {
+	var balance Balance
+	err := w.view(func(dbTx database.Tx) error {
+		err := forEachCredit(dbTx, func(c *credit) error {
+			if _, ok := w.spending[c.outpoint]; ok {
+				return nil
+			}
+			if w.isWatchOnly(c.pkScript) {
+				return nil
+			}
+			entangled := w.isEntanglePayout(c.coinbase,
+				c.outpoint.Index, c.height)
+			switch {
+			case !w.isMature(c.coinbase, c.height):
+				balance.Immature += c.amount
+				if entangled {
+					balance.ImmatureEntangled += c.amount
+				}
+			case w.height-c.height+1 < minConf:
+				balance.Unconfirmed += c.amount
+			default:
+				balance.Spendable += c.amount
+				if entangled {
+					balance.Entangled += c.amount
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// The outputs of unconfirmed transactions of the wallet paying
+		// to the wallet are its change.
+		for _, tx := range w.pending {
+			for _, txOut := range tx.TxOut {
+				if _, ok := w.lookupScript(txOut.PkScript); ok {
+					balance.Unconfirmed += czzutil.Amount(txOut.Value)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &balance, nil
+}
+
+// ListUnspent returns the unspent outputs paying to the wallet with a number
+// of confirmations in the passed range.  A maximum of zero means there is no
+// maximum.  The outputs are sorted by their height and outpoint.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) ListUnspent(minConf, maxConf int32) ([]*Credit, error) {
+	var unspent []*Credit
+	err := w.view(func(dbTx database.Tx) error {
+		return forEachCredit(dbTx, func(c *credit) error {
+			confs := w.height - c.height + 1
+			if confs < minConf || (maxConf > 0 && confs > maxConf) {
+				return nil
+			}
+			_, spent := w.spending[c.outpoint]
+			var redeemScript []byte
+			if ref, ok := w.lookupScript(c.pkScript); ok {
+				switch {
+				case ref.script:
+					redeemScript = w.ks.file.Scripts[ref.index]
+				case ref.watched:
+					redeemScript = w.descs[ref.index].RedeemScript()
+				}
+			}
+			unspent = append(unspent, &Credit{
+				OutPoint:      c.outpoint,
+				Amount:        c.amount,
+				PkScript:      c.pkScript,
+				Height:        c.height,
+				Confirmations: confs,
+				Coinbase:      c.coinbase,
+				Entangled: w.isEntanglePayout(c.coinbase,
+					c.outpoint.Index, c.height),
+				Mature:       w.isMature(c.coinbase, c.height),
+				Spent:        spent,
+				WatchOnly:    w.isWatchOnly(c.pkScript),
+				RedeemScript: redeemScript,
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(unspent, func(i, j int) bool {
+		if unspent[i].Height != unspent[j].Height {
+			return unspent[i].Height < unspent[j].Height
+		}
+		a, b := &unspent[i].OutPoint, &unspent[j].OutPoint
+		if a.Hash != b.Hash {
+			return a.Hash.String() < b.Hash.String()
+		}
+		return a.Index < b.Index
+	})
+	return unspent, nil
+}
+
+// CreateTx returns a signed transaction paying to the passed outputs with
+// outputs of the wallet which have at least the passed number of
+// confirmations.  The largest outputs are spent first and any change which is
+// not dust is paid to a new change address.  The wallet must be unlocked.
+//
+// The spent outputs are reserved for the transaction until it is mined or
+// abandoned with AbandonTx, so the transaction must be either submitted to the
+// network or abandoned.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) CreateTx(outputs []*wire.TxOut, minConf int32) (*wire.MsgTx, error) {
+	// The change address is given out up front since it takes the
+	// database lock before the wallet lock.  It is simply not used when
+	// there is no change.
+	changeAddr, err := w.ChangeAddress()
+	if err != nil {
+		return nil, err
+	}
+	changeScript, err := txscript.PayToAddrScript(changeAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx *wire.MsgTx
+	err = w.view(func(dbTx database.Tx) error {
+		var err error
+		tx, err = w.createTx(dbTx, outputs, minConf, changeScript)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// createTx creates and signs a transaction for CreateTx and reserves the
+// outputs it spends.
+//
+// This function MUST be called with the wallet lock held.
+func (w *Wallet) createTx(dbTx database.Tx, outputs []*wire.TxOut, minConf int32,
+	changeScript []byte) (*wire.MsgTx, error) {
+
+	if w.ks.isLocked() {
+		return nil, ErrLocked
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	var amount czzutil.Amount
+	for _, txOut := range outputs {
+		amount += czzutil.Amount(txOut.Value)
+		tx.AddTxOut(txOut)
+	}
+
+	// Gather the spendable outputs, largest first.
+	var eligible []*credit
+	err := forEachCredit(dbTx, func(c *credit) error {
+		if !w.isMature(c.coinbase, c.height) ||
+			w.height-c.height+1 < minConf {
+			return nil
+		}
+		if _, ok := w.spending[c.outpoint]; ok {
+			return nil
+		}
+		if w.isWatchOnly(c.pkScript) {
+			return nil
+		}
+		eligible = append(eligible, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(eligible, func(i, j int) bool {
+		return eligible[i].amount > eligible[j].amount
+	})
+
+	// Select outputs until they cover the amount and the fee of the
+	// transaction including a change output.
+	var selected []*credit
+	var total, fee czzutil.Amount
+	for _, c := range eligible {
+		selected = append(selected, c)
+		total += c.amount
+		tx.AddTxIn(wire.NewTxIn(&c.outpoint, nil))
+
+		size := tx.SerializeSize() + spendSize*len(tx.TxIn) + changeSize
+		fee = w.feeRate * czzutil.Amount(size) / 1000
+		if total >= amount+fee {
+			break
+		}
+	}
+	if total < amount+fee {
+		return nil, ErrInsufficientFunds
+	}
+
+	// Pay the change to the wallet unless it is dust, in which case it is
+	// left to the miner.
+	change := &wire.TxOut{
+		Value:    int64(total - amount - fee),
+		PkScript: changeScript,
+	}
+	if !mempool.IsDust(change, w.minRelayTxFee) {
+		tx.AddTxOut(change)
+	}
+
+	// Sign the inputs.
+	for i, c := range selected {
+		ref, ok := w.lookupScript(c.pkScript)
+		if !ok {
+			return nil, errors.New("no key for output " +
+				c.outpoint.String())
+		}
+		privKey, compressed, err := w.ks.privKey(ref)
+		if err != nil {
+			return nil, err
+		}
+		lookupKey := func(czzutil.Address) (*czzec.PrivateKey, bool, error) {
+			return privKey, compressed, nil
+		}
+		sigScript, err := txscript.SignTxOutput(w.params, tx, i,
+			int64(c.amount), c.pkScript, txscript.SigHashAll,
+			txscript.KeyClosure(lookupKey), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		tx.TxIn[i].SignatureScript = sigScript
+	}
+
+	// Reserve the spent outputs for the transaction.
+	hash := tx.TxHash()
+	w.pending[hash] = tx
+	for _, txIn := range tx.TxIn {
+		w.spending[txIn.PreviousOutPoint] = hash
+	}
+	return tx, nil
+}
+
+// AbandonTx releases the outputs reserved for the passed transaction created
+// by CreateTx, which is used when it was not accepted by the network.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) AbandonTx(hash *chainhash.Hash) {
+	w.mtx.Lock()
+	w.removePending(hash)
+	w.mtx.Unlock()
+}
+
+// ImportPrivKey adds the private key of the passed WIF to the wallet file and
+// watches its address from now on.  The wallet must be unlocked.
+//
+// When rescan is set, the unspent outputs already paying to the address are
+// found by scanning a snapshot of the utxo set of the passed chain, which does
+// not block blocks from being connected in the mean time.  The wallet must be
+// caught up with the chain to rescan.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) ImportPrivKey(wif *czzutil.WIF, rescan bool, chain *blockchain.BlockChain) error {
+	if !wif.IsForNet(w.params) {
+		return errors.New("the key is for a different network")
+	}
+	hash := czzutil.Hash160(wif.SerializePubKey())
+	var bestHeight int32
+	if rescan {
+		bestHeight = chain.BestSnapshot().Height
+	}
+
+	w.mtx.Lock()
+	if rescan && w.height != bestHeight {
+		w.mtx.Unlock()
+		return ErrNotSynced
+	}
+	index, err := w.ks.importKey(wif)
+	if err != nil {
+		w.mtx.Unlock()
+		return err
+	}
+	w.addrs[string(hash)] = keyRef{index: index, imported: true}
+	if !rescan {
+		w.mtx.Unlock()
+		return nil
+	}
+	w.startRescan()
+	w.mtx.Unlock()
+
+	return w.rescan(chain, "the imported key", func(pkScript []byte) bool {
+		scriptHash, ok := scriptHash(pkScript, w.params)
+		return ok && string(scriptHash) == string(hash)
+	})
+}
+
+// ImportDescriptor adds the passed output descriptor to the wallet file and
+// watches the output script it describes from now on.  Like the multisig
+// addresses added by AddMultiSigAddress, the outputs paying to the script are
+// watch-only: they are listed by ListUnspent, but neither counted in the
+// balance nor spent by the wallet.  The outputs paying to keys of the wallet
+// remain spendable.
+//
+// See ImportPrivKey for the rescan.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) ImportDescriptor(desc *txscript.Descriptor, rescan bool, chain *blockchain.BlockChain) error {
+	pkScript := desc.PkScript()
+	var bestHeight int32
+	if rescan {
+		bestHeight = chain.BestSnapshot().Height
+	}
+
+	w.mtx.Lock()
+	if rescan && w.height != bestHeight {
+		w.mtx.Unlock()
+		return ErrNotSynced
+	}
+	index, err := w.ks.addDescriptor(desc.String())
+	if err != nil {
+		w.mtx.Unlock()
+		return err
+	}
+	if index == uint32(len(w.descs)) {
+		w.descs = append(w.descs, desc)
+	}
+	w.watched[string(pkScript)] = index
+	if !rescan {
+		w.mtx.Unlock()
+		return nil
+	}
+	w.startRescan()
+	w.mtx.Unlock()
+
+	return w.rescan(chain, "the descriptor", func(script []byte) bool {
+		return string(script) == string(pkScript)
+	})
+}
+
+// startRescan starts recording the outputs spent or removed by blocks, which
+// may be in the snapshot of a rescan started afterwards, but must not be
+// credited anymore.  Each call must be followed by a call to rescan.
+//
+// This function MUST be called with the wallet lock held.
+func (w *Wallet) startRescan() {
+	if w.rescans == 0 {
+		w.rescanned = make(map[wire.OutPoint]struct{})
+	}
+	w.rescans++
+}
+
+// rescan credits the unspent outputs of a snapshot of the utxo set of the
+// passed chain whose output scripts match, which were not spent or removed by
+// blocks since the rescan was started.  What describes the matched outputs in
+// the log.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) rescan(chain *blockchain.BlockChain, what string, match func(pkScript []byte) bool) error {
+	defer func() {
+		w.mtx.Lock()
+		w.rescans--
+		if w.rescans == 0 {
+			w.rescanned = nil
+		}
+		w.mtx.Unlock()
+	}()
+
+	snapshot, err := chain.UtxoSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	var found []*credit
+	err = snapshot.ForEach(func(op *wire.OutPoint, entry *blockchain.UtxoEntry) error {
+		if !match(entry.PkScript()) {
+			return nil
+		}
+		found = append(found, &credit{
+			outpoint: *op,
+			amount:   czzutil.Amount(entry.Amount()),
+			height:   entry.BlockHeight(),
+			coinbase: entry.IsCoinBase(),
+			pkScript: entry.PkScript(),
+		})
+		return nil
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	// Credit the found outputs which were not spent or removed by blocks
+	// since the snapshot was taken.  Outputs created by those blocks were
+	// credited when they were connected.
+	err = w.db.Update(func(dbTx database.Tx) error {
+		w.mtx.Lock()
+		defer w.mtx.Unlock()
+
+		credits := creditsBucket(dbTx)
+		for _, c := range found {
+			if _, ok := w.rescanned[c.outpoint]; ok {
+				continue
+			}
+			err := credits.Put(outpointKey(&c.outpoint),
+				serializeCredit(c))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Found %d unspent outputs of %s as of block %v (height %d)",
+		len(found), what, snapshot.Hash(), snapshot.Height())
+	return nil
+}
+
+// PubKey returns the public key of the passed p2pkh address of the wallet, so
+// it can be used as a key of a multisig address.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) PubKey(addr czzutil.Address) (*czzutil.AddressPubKey, error) {
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	ref, ok := w.lookupScript(pkScript)
+	if !ok || ref.script || ref.watched {
+		return nil, errors.New("the address " + addr.EncodeAddress() +
+			" does not belong to a key of the wallet")
+	}
+
+	var pubKey []byte
+	if ref.imported {
+		pubKey = w.ks.file.Imported[ref.index].PubKey
+	} else {
+		pubKey, err = w.ks.pubKey(ref.branch, ref.index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return czzutil.NewAddressPubKey(pubKey, w.params)
+}
+
+// AddMultiSigAddress adds the multisig redeem script which requires nRequired
+// signatures of the passed public keys to the wallet file and returns its
+// pay-to-script-hash address.  The address is watch-only: the outputs paying
+// to it are listed by ListUnspent along with the redeem script, but they are
+// neither counted in the balance nor spent by the wallet, since spending them
+// requires the signatures of the other participants.
+//
+// Only the outputs paying to the address from the next block on are found.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) AddMultiSigAddress(nRequired int, pubKeys []*czzutil.AddressPubKey) (*czzutil.AddressScriptHash, error) {
+	addr, script, err := txscript.NewMultiSigAddress(nRequired, pubKeys,
+		w.params)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	index, err := w.ks.addScript(script)
+	if err != nil {
+		return nil, err
+	}
+	w.scripts[string(addr.ScriptAddress())] = index
+	return addr, nil
+}
@@ -0,0 +1,561 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/blockchain"
+	"github.com/bourbaki-czz/classzz/chaincfg"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/bourbaki-czz/classzz/czzec"
+	"github.com/bourbaki-czz/classzz/database"
+	_ "github.com/bourbaki-czz/classzz/database/ffldb"
+	"github.com/bourbaki-czz/classzz/txscript"
+	"github.com/bourbaki-czz/classzz/wire"
+	"github.com/bourbaki-czz/czzutil"
+)
+
+var testPassphrase = []byte("passphrase")
+
+// testParams returns chain parameters with a short coinbase maturity and an
+// entangle height of one, so entangle payouts can be tested with few blocks.
+func testParams() *chaincfg.Params {
+	params := chaincfg.RegressionNetParams
+	params.CoinbaseMaturity = 2
+	params.EntangleHeight = 1
+	return &params
+}
+
+// testWallet creates a wallet file and database in a temporary directory and
+// returns the wallet with its index created and initialized.  The returned
+// function removes the temporary directory.
+func testWallet(t *testing.T, params *chaincfg.Params) (*Wallet, func()) {
+	dir, err := ioutil.TempDir("", "wallettest")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	walletPath := filepath.Join(dir, "wallet.json")
+	seed := bytes.Repeat([]byte{0x5a}, 32)
+	if err := Create(walletPath, params, seed, testPassphrase); err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	db, err := database.Create("ffldb", filepath.Join(dir, "db"), params.Net)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("database.Create: unexpected error: %v", err)
+	}
+	teardown := func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+
+	w, err := New(&Config{
+		DB:            db,
+		ChainParams:   params,
+		Path:          walletPath,
+		FeeRate:       1000,
+		MinRelayTxFee: 1000,
+	})
+	if err != nil {
+		teardown()
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	if err := db.Update(w.Create); err != nil {
+		teardown()
+		t.Fatalf("Create index: unexpected error: %v", err)
+	}
+	if err := w.Init(); err != nil {
+		teardown()
+		t.Fatalf("Init: unexpected error: %v", err)
+	}
+	return w, teardown
+}
+
+// otherScript returns an output script which does not pay to the wallet.
+func otherScript(t *testing.T, params *chaincfg.Params) []byte {
+	addr, err := czzutil.NewAddressPubKeyHash(make([]byte, 20), params)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: unexpected error: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+	return pkScript
+}
+
+// testBlock returns a block at the passed height with a coinbase paying to the
+// passed outputs followed by the passed transactions.
+func testBlock(height int32, coinbaseOuts []*wire.TxOut, txns ...*wire.MsgTx) *czzutil.Block {
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+		wire.MaxPrevOutIndex), []byte{byte(height)}))
+	for _, txOut := range coinbaseOuts {
+		coinbase.AddTxOut(txOut)
+	}
+
+	msgBlock := &wire.MsgBlock{Transactions: []*wire.MsgTx{coinbase}}
+	msgBlock.Transactions = append(msgBlock.Transactions, txns...)
+	block := czzutil.NewBlock(msgBlock)
+	block.SetHeight(height)
+	return block
+}
+
+// TestKeystore ensures wallet files can only be created once and are only
+// unlocked with the correct passphrase.
+func TestKeystore(t *testing.T) {
+	params := testParams()
+	w, teardown := testWallet(t, params)
+	defer teardown()
+
+	seed := bytes.Repeat([]byte{0x5a}, 32)
+	err := Create(w.ks.path, params, seed, testPassphrase)
+	if err != ErrWalletExists {
+		t.Fatalf("Create existing: got %v, want %v", err, ErrWalletExists)
+	}
+	if _, err := openKeystore(w.ks.path, &chaincfg.MainNetParams); err == nil {
+		t.Fatal("openKeystore for other network: unexpected success")
+	}
+
+	if err := w.Unlock([]byte("wrong"), 0); err != ErrWrongPassphrase {
+		t.Fatalf("Unlock: got %v, want %v", err, ErrWrongPassphrase)
+	}
+	if _, ok := w.UnlockedUntil(); ok {
+		t.Fatal("wallet unlocked with wrong passphrase")
+	}
+	if err := w.Unlock(testPassphrase, 0); err != nil {
+		t.Fatalf("Unlock: unexpected error: %v", err)
+	}
+	if _, ok := w.UnlockedUntil(); !ok {
+		t.Fatal("wallet not unlocked")
+	}
+
+	// The private key of a derived address must match its public key.
+	addr, err := w.NewAddress()
+	if err != nil {
+		t.Fatalf("NewAddress: unexpected error: %v", err)
+	}
+	privKey, compressed, err := w.ks.privKey(keyRef{})
+	if err != nil {
+		t.Fatalf("privKey: unexpected error: %v", err)
+	}
+	hash := czzutil.Hash160(privKey.PubKey().SerializeCompressed())
+	if !compressed || !bytes.Equal(hash, addr.ScriptAddress()) {
+		t.Fatal("private key does not match the first address")
+	}
+
+	// Imported keys are watched and persisted in the wallet file.
+	wif, err := czzutil.NewWIF(privKey, params, false)
+	if err != nil {
+		t.Fatalf("NewWIF: unexpected error: %v", err)
+	}
+	if err := w.ImportPrivKey(wif, false, nil); err != nil {
+		t.Fatalf("ImportPrivKey: unexpected error: %v", err)
+	}
+	ks, err := openKeystore(w.ks.path, params)
+	if err != nil {
+		t.Fatalf("openKeystore: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(ks.file, w.ks.file) {
+		t.Fatal("reopened wallet file differs")
+	}
+
+	w.Lock()
+	if _, _, err := w.ks.privKey(keyRef{}); err != ErrLocked {
+		t.Fatalf("privKey: got %v, want %v", err, ErrLocked)
+	}
+}
+
+// TestChangePassphrase ensures the wallet file is only decrypted by the new
+// passphrase once it was changed, including its imported keys, and that the
+// wallet stays unlocked when it was.
+func TestChangePassphrase(t *testing.T) {
+	params := testParams()
+	w, teardown := testWallet(t, params)
+	defer teardown()
+
+	if err := w.Unlock(testPassphrase, 0); err != nil {
+		t.Fatalf("Unlock: unexpected error: %v", err)
+	}
+	privKey, _, err := w.ks.privKey(keyRef{})
+	if err != nil {
+		t.Fatalf("privKey: unexpected error: %v", err)
+	}
+	wif, err := czzutil.NewWIF(privKey, params, true)
+	if err != nil {
+		t.Fatalf("NewWIF: unexpected error: %v", err)
+	}
+	if err := w.ImportPrivKey(wif, false, nil); err != nil {
+		t.Fatalf("ImportPrivKey: unexpected error: %v", err)
+	}
+	salt := w.ks.file.Salt
+
+	newPassphrase := []byte("new passphrase")
+	err = w.ChangePassphrase([]byte("wrong"), newPassphrase)
+	if err != ErrWrongPassphrase {
+		t.Fatalf("ChangePassphrase: got %v, want %v", err,
+			ErrWrongPassphrase)
+	}
+	if err := w.ChangePassphrase(testPassphrase, newPassphrase); err != nil {
+		t.Fatalf("ChangePassphrase: unexpected error: %v", err)
+	}
+	if bytes.Equal(w.ks.file.Salt, salt) {
+		t.Fatal("the salt was not renewed")
+	}
+	if _, ok := w.UnlockedUntil(); !ok {
+		t.Fatal("wallet locked by the passphrase change")
+	}
+	if _, _, err := w.ks.privKey(keyRef{index: 0, imported: true}); err != nil {
+		t.Fatalf("privKey of imported key: unexpected error: %v", err)
+	}
+
+	// The reopened wallet file is only unlocked by the new passphrase.
+	ks, err := openKeystore(w.ks.path, params)
+	if err != nil {
+		t.Fatalf("openKeystore: unexpected error: %v", err)
+	}
+	if err := ks.unlock(testPassphrase); err != ErrWrongPassphrase {
+		t.Fatalf("unlock with old passphrase: got %v, want %v", err,
+			ErrWrongPassphrase)
+	}
+	if err := ks.unlock(newPassphrase); err != nil {
+		t.Fatalf("unlock: unexpected error: %v", err)
+	}
+	imported, _, err := ks.privKey(keyRef{index: 0, imported: true})
+	if err != nil {
+		t.Fatalf("privKey of imported key: unexpected error: %v", err)
+	}
+	if !bytes.Equal(imported.Serialize(), privKey.Serialize()) {
+		t.Fatal("imported key differs after the passphrase change")
+	}
+
+	// Locking clears the decrypted keys, which stay encrypted in the file.
+	cryptKey, account := ks.cryptKey, ks.account
+	ks.lock()
+	if !bytes.Equal(cryptKey, make([]byte, len(cryptKey))) {
+		t.Fatal("encryption key not cleared by lock")
+	}
+	if _, err := account.ECPrivKey(); err == nil {
+		t.Fatal("account key not cleared by lock")
+	}
+}
+
+// TestWalletCredits ensures the wallet tracks the outputs paying to it across
+// connected and disconnected blocks, accounts for entangle payouts and creates
+// valid transactions.
+func TestWalletCredits(t *testing.T) {
+	params := testParams()
+	w, teardown := testWallet(t, params)
+	defer teardown()
+
+	addr, err := w.NewAddress()
+	if err != nil {
+		t.Fatalf("NewAddress: unexpected error: %v", err)
+	}
+	walletScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+	other := otherScript(t, params)
+
+	connect := func(block *czzutil.Block) {
+		t.Helper()
+		err := w.db.Update(func(dbTx database.Tx) error {
+			return w.ConnectBlock(dbTx, block, nil)
+		})
+		if err != nil {
+			t.Fatalf("ConnectBlock: unexpected error: %v", err)
+		}
+	}
+	checkBalance := func(want Balance) {
+		t.Helper()
+		balance, err := w.Balance(1)
+		if err != nil {
+			t.Fatalf("Balance: unexpected error: %v", err)
+		}
+		if *balance != want {
+			t.Fatalf("Balance: got %+v, want %+v", *balance, want)
+		}
+	}
+
+	// The coinbase of the first block pays an entangle transaction out to
+	// the wallet after the reward, pool and keeped-amount outputs, and a
+	// regular transaction pays to the wallet.
+	payment := wire.NewMsgTx(wire.TxVersion)
+	payment.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil))
+	payment.AddTxOut(wire.NewTxOut(5e8, walletScript))
+	connect(testBlock(1, []*wire.TxOut{
+		wire.NewTxOut(50e8, other),
+		wire.NewTxOut(1e8, other),
+		wire.NewTxOut(1e8, other),
+		wire.NewTxOut(0, other),
+		wire.NewTxOut(7e8, walletScript),
+	}, payment))
+	checkBalance(Balance{
+		Spendable:         5e8,
+		Immature:          7e8,
+		ImmatureEntangled: 7e8,
+	})
+
+	// The entangle payout matures with the next block.
+	connect(testBlock(2, []*wire.TxOut{wire.NewTxOut(50e8, other)}))
+	checkBalance(Balance{Spendable: 12e8, Entangled: 7e8})
+
+	unspent, err := w.ListUnspent(1, 0)
+	if err != nil {
+		t.Fatalf("ListUnspent: unexpected error: %v", err)
+	}
+	if len(unspent) != 2 || unspent[0].Entangled == unspent[1].Entangled {
+		t.Fatalf("ListUnspent: got %d outputs, want one entangle "+
+			"payout and one regular output", len(unspent))
+	}
+
+	// Transactions are only created while the wallet is unlocked.
+	outputs := []*wire.TxOut{wire.NewTxOut(10e8, other)}
+	if _, err := w.CreateTx(outputs, 1); err != ErrLocked {
+		t.Fatalf("CreateTx: got %v, want %v", err, ErrLocked)
+	}
+	if err := w.Unlock(testPassphrase, 0); err != nil {
+		t.Fatalf("Unlock: unexpected error: %v", err)
+	}
+	tx, err := w.CreateTx(outputs, 1)
+	if err != nil {
+		t.Fatalf("CreateTx: unexpected error: %v", err)
+	}
+	if len(tx.TxIn) != 2 || len(tx.TxOut) != 2 {
+		t.Fatalf("CreateTx: got %d inputs and %d outputs, want 2 and 2",
+			len(tx.TxIn), len(tx.TxOut))
+	}
+	stxos := make([]blockchain.SpentTxOut, 0, len(tx.TxIn))
+	for i, txIn := range tx.TxIn {
+		var spent *Credit
+		for _, c := range unspent {
+			if c.OutPoint == txIn.PreviousOutPoint {
+				spent = c
+			}
+		}
+		if spent == nil {
+			t.Fatalf("input %d spends unknown output %v", i,
+				txIn.PreviousOutPoint)
+		}
+		vm, err := txscript.NewEngine(spent.PkScript, tx, i,
+			txscript.StandardVerifyFlags, nil, nil, int64(spent.Amount))
+		if err != nil {
+			t.Fatalf("NewEngine: unexpected error: %v", err)
+		}
+		if err := vm.Execute(); err != nil {
+			t.Fatalf("input %d does not verify: %v", i, err)
+		}
+		stxos = append(stxos, blockchain.SpentTxOut{
+			Amount:     int64(spent.Amount),
+			PkScript:   spent.PkScript,
+			Height:     spent.Height,
+			IsCoinBase: spent.Coinbase,
+		})
+	}
+
+	// The spent outputs are reserved and the change is unconfirmed until
+	// the transaction is mined.
+	change := czzutil.Amount(tx.TxOut[1].Value)
+	checkBalance(Balance{Unconfirmed: change})
+	if _, err := w.CreateTx(outputs, 1); err != ErrInsufficientFunds {
+		t.Fatalf("CreateTx: got %v, want %v", err, ErrInsufficientFunds)
+	}
+
+	block := testBlock(3, []*wire.TxOut{wire.NewTxOut(50e8, other)}, tx)
+	connect(block)
+	checkBalance(Balance{Spendable: change})
+	if len(w.pending) != 0 || len(w.spending) != 0 {
+		t.Fatal("mined transaction is still pending")
+	}
+
+	// Disconnecting the block restores the spent outputs.
+	err = w.db.Update(func(dbTx database.Tx) error {
+		return w.DisconnectBlock(dbTx, block, stxos)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: unexpected error: %v", err)
+	}
+	checkBalance(Balance{Spendable: 12e8, Entangled: 7e8})
+	if w.SyncedHeight() != 2 {
+		t.Fatalf("SyncedHeight: got %d, want 2", w.SyncedHeight())
+	}
+
+	// The state of the wallet is restored from the index.
+	w2, err := New(&Config{DB: w.db, ChainParams: params, Path: w.ks.path})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	if err := w2.Init(); err != nil {
+		t.Fatalf("Init: unexpected error: %v", err)
+	}
+	if w2.used != w.used || w2.height != w.height {
+		t.Fatalf("restored state: got used %v at height %d, want %v "+
+			"at height %d", w2.used, w2.height, w.used, w.height)
+	}
+}
+
+// TestWalletMultiSig ensures outputs paying to watch-only multisig addresses
+// are listed but neither counted in the balance nor spent.
+func TestWalletMultiSig(t *testing.T) {
+	params := testParams()
+	w, teardown := testWallet(t, params)
+	defer teardown()
+
+	addr, err := w.NewAddress()
+	if err != nil {
+		t.Fatalf("NewAddress: unexpected error: %v", err)
+	}
+	pubKey, err := w.PubKey(addr)
+	if err != nil {
+		t.Fatalf("PubKey: unexpected error: %v", err)
+	}
+	otherKey, err := czzec.NewPrivateKey(czzec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	otherPubKey, err := czzutil.NewAddressPubKey(
+		otherKey.PubKey().SerializeCompressed(), params)
+	if err != nil {
+		t.Fatalf("NewAddressPubKey: unexpected error: %v", err)
+	}
+
+	msAddr, err := w.AddMultiSigAddress(2,
+		[]*czzutil.AddressPubKey{pubKey, otherPubKey})
+	if err != nil {
+		t.Fatalf("AddMultiSigAddress: unexpected error: %v", err)
+	}
+	if !w.IsMine(msAddr) {
+		t.Fatal("multisig address is not watched")
+	}
+	msScript, err := txscript.PayToAddrScript(msAddr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+
+	payment := wire.NewMsgTx(wire.TxVersion)
+	payment.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil))
+	payment.AddTxOut(wire.NewTxOut(5e8, msScript))
+	err = w.db.Update(func(dbTx database.Tx) error {
+		block := testBlock(1, []*wire.TxOut{
+			wire.NewTxOut(50e8, otherScript(t, params)),
+		}, payment)
+		return w.ConnectBlock(dbTx, block, nil)
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: unexpected error: %v", err)
+	}
+
+	balance, err := w.Balance(1)
+	if err != nil {
+		t.Fatalf("Balance: unexpected error: %v", err)
+	}
+	if *balance != (Balance{}) {
+		t.Fatalf("Balance: got %+v, want an empty balance", *balance)
+	}
+	unspent, err := w.ListUnspent(1, 0)
+	if err != nil {
+		t.Fatalf("ListUnspent: unexpected error: %v", err)
+	}
+	if len(unspent) != 1 || unspent[0].RedeemScript == nil {
+		t.Fatal("ListUnspent: multisig output is not listed with its " +
+			"redeem script")
+	}
+
+	if err := w.Unlock(testPassphrase, 0); err != nil {
+		t.Fatalf("Unlock: unexpected error: %v", err)
+	}
+	outputs := []*wire.TxOut{wire.NewTxOut(1e8, otherScript(t, params))}
+	if _, err := w.CreateTx(outputs, 1); err != ErrInsufficientFunds {
+		t.Fatalf("CreateTx: got %v, want %v", err, ErrInsufficientFunds)
+	}
+
+	// The redeem script is persisted in the wallet file.
+	w2, err := New(&Config{DB: w.db, ChainParams: params, Path: w.ks.path})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	if !w2.IsMine(msAddr) {
+		t.Fatal("multisig address is not restored")
+	}
+}
+
+// TestWalletDescriptor ensures outputs described by imported descriptors are
+// watch-only, while outputs paying to keys of the wallet remain spendable.
+func TestWalletDescriptor(t *testing.T) {
+	params := testParams()
+	w, teardown := testWallet(t, params)
+	defer teardown()
+
+	addr, err := w.NewAddress()
+	if err != nil {
+		t.Fatalf("NewAddress: unexpected error: %v", err)
+	}
+	pubKey, err := w.PubKey(addr)
+	if err != nil {
+		t.Fatalf("PubKey: unexpected error: %v", err)
+	}
+	ownScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+	watchedScript := otherScript(t, params)
+
+	for _, d := range []string{
+		"pkh(" + pubKey.String() + ")",
+		"raw(" + hex.EncodeToString(watchedScript) + ")",
+	} {
+		desc, err := txscript.ParseDescriptor(d, params)
+		if err != nil {
+			t.Fatalf("ParseDescriptor: unexpected error: %v", err)
+		}
+		if err := w.ImportDescriptor(desc, false, nil); err != nil {
+			t.Fatalf("ImportDescriptor: unexpected error: %v", err)
+		}
+	}
+
+	err = w.db.Update(func(dbTx database.Tx) error {
+		block := testBlock(1, []*wire.TxOut{
+			wire.NewTxOut(50e8, ownScript),
+			wire.NewTxOut(5e8, watchedScript),
+		})
+		return w.ConnectBlock(dbTx, block, nil)
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: unexpected error: %v", err)
+	}
+
+	unspent, err := w.ListUnspent(0, 0)
+	if err != nil {
+		t.Fatalf("ListUnspent: unexpected error: %v", err)
+	}
+	if len(unspent) != 2 {
+		t.Fatalf("ListUnspent: got %d outputs, want 2", len(unspent))
+	}
+	for _, credit := range unspent {
+		watchOnly := bytes.Equal(credit.PkScript, watchedScript)
+		if credit.WatchOnly != watchOnly {
+			t.Fatalf("ListUnspent: output %v watch-only %v, want %v",
+				credit.OutPoint, credit.WatchOnly, watchOnly)
+		}
+	}
+
+	// The descriptors are persisted in the wallet file.
+	w2, err := New(&Config{DB: w.db, ChainParams: params, Path: w.ks.path})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	if len(w2.descs) != 2 || !w2.isWatchOnly(watchedScript) {
+		t.Fatal("descriptors are not restored")
+	}
+}
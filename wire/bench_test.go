@@ -632,3 +632,12 @@ func BenchmarkDoubleHashH(b *testing.B) {
 		_ = chainhash.DoubleHashH(txBytes)
 	}
 }
+
+// BenchmarkWriteMessageBlock performs a benchmark on how long it takes to
+// write a block message.
+func BenchmarkWriteMessageBlock(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		WriteMessage(ioutil.Discard, &blockOne, ProtocolVersion, MainNet)
+	}
+}
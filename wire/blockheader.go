@@ -5,7 +5,6 @@
 package wire
 
 import (
-	"bytes"
 	"io"
 	"time"
 
@@ -57,17 +56,13 @@ func (h *BlockHeader) BlockHash() chainhash.Hash {
 	// transactions.  Ignore the error returns since there is no way the
 	// encode could fail except being out of memory which would cause a
 	// run-time panic.
-	buf := bytes.NewBuffer(make([]byte, 0, BlockHeaderSize))
-	_ = writeBlockHeader(buf, 0, h)
-
-	return chainhash.DoubleHashH(buf.Bytes())
+	var buf [BlockHeaderSize]byte
+	return chainhash.DoubleHashH(appendBlockHeader(buf[:0], h))
 }
 
 func (h *BlockHeader) BlockHashNoNonce() chainhash.Hash {
-	buf := bytes.NewBuffer(make([]byte, 0, MaxBlockHeaderPayload))
-	_ = writeBlockHeader(buf, 0, h)
-
-	tmp := buf.Bytes()
+	var buf [BlockHeaderSize]byte
+	tmp := appendBlockHeader(buf[:0], h)
 	tmp = tmp[0:MaxBlockHeaderPayload]
 
 	return chainhash.DoubleHashH(tmp)
@@ -140,7 +135,19 @@ func readBlockHeader(r io.Reader, pver uint32, bh *BlockHeader) error {
 // encoding block headers to be stored to disk, such as in a database, as
 // opposed to encoding for the wire.
 func writeBlockHeader(w io.Writer, pver uint32, bh *BlockHeader) error {
-	sec := uint32(bh.Timestamp.Unix())
-	return writeElements(w, bh.Version, &bh.PrevBlock, &bh.MerkleRoot, &bh.CIDRoot,
-		sec, bh.Bits, bh.Nonce)
+	var buf [BlockHeaderSize]byte
+	_, err := w.Write(appendBlockHeader(buf[:0], bh))
+	return err
+}
+
+// appendBlockHeader appends the encoding of a bitcoin block header to b.  It
+// produces the same encoding as writeBlockHeader.
+func appendBlockHeader(b []byte, bh *BlockHeader) []byte {
+	b = appendUint32(b, uint32(bh.Version))
+	b = append(b, bh.PrevBlock[:]...)
+	b = append(b, bh.MerkleRoot[:]...)
+	b = append(b, bh.CIDRoot[:]...)
+	b = appendUint32(b, uint32(bh.Timestamp.Unix()))
+	b = appendUint32(b, bh.Bits)
+	return appendUint64(b, bh.Nonce)
 }
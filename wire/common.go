@@ -22,6 +22,16 @@ const (
 	// binaryFreeListMaxItems is the number of buffers to keep in the free
 	// list to use for binary serialization and deserialization.
 	binaryFreeListMaxItems = 1024
+
+	// serializeFreeListMaxItems is the number of buffers to keep in the
+	// free list to use for serializing messages.
+	serializeFreeListMaxItems = 128
+
+	// serializeFreeListMaxBufSize is the largest capacity of a buffer kept
+	// in the serialization free list.  Larger buffers, such as the ones
+	// used to serialize big blocks, are left to the garbage collector so
+	// the free list does not pin a lot of memory.
+	serializeFreeListMaxBufSize = 1024 * 1024
 )
 
 var (
@@ -171,6 +181,79 @@ func (l binaryFreeList) PutUint64(w io.Writer, byteOrder binary.ByteOrder, val u
 // deserializing primitive integer values to and from io.Readers and io.Writers.
 var binarySerializer binaryFreeList = make(chan []byte, binaryFreeListMaxItems)
 
+// serializeFreeList defines a concurrent safe free list of byte slices (up to
+// the maximum number defined by the serializeFreeListMaxItems constant) used
+// as scratch space to serialize whole messages and transactions.  Serializing
+// into a single buffer with the append functions below and handing it to the
+// writer in one call avoids the intermediate allocations and the many small
+// writes of encoding field by field.
+type serializeFreeList chan []byte
+
+// Borrow returns an empty byte slice from the free list with a capacity of at
+// least size.  A new buffer is allocated if there are no buffers available on
+// the free list or the available one is too small.
+func (l serializeFreeList) Borrow(size int) []byte {
+	select {
+	case buf := <-l:
+		if cap(buf) >= size {
+			return buf[:0]
+		}
+	default:
+	}
+	return make([]byte, 0, size)
+}
+
+// Return puts the provided byte slice back on the free list.  Buffers larger
+// than serializeFreeListMaxBufSize are ignored so they can go to the garbage
+// collector.  The buffer MUST NOT be used after it is returned.
+func (l serializeFreeList) Return(buf []byte) {
+	if cap(buf) > serializeFreeListMaxBufSize {
+		return
+	}
+	select {
+	case l <- buf:
+	default:
+		// Let it go to the garbage collector.
+	}
+}
+
+// serializePool provides a free list of buffers to use for serializing
+// messages.
+var serializePool serializeFreeList = make(chan []byte,
+	serializeFreeListMaxItems)
+
+// appendUint32 appends the little endian encoding of val to b.
+func appendUint32(b []byte, val uint32) []byte {
+	return append(b, byte(val), byte(val>>8), byte(val>>16), byte(val>>24))
+}
+
+// appendUint64 appends the little endian encoding of val to b.
+func appendUint64(b []byte, val uint64) []byte {
+	return append(b, byte(val), byte(val>>8), byte(val>>16), byte(val>>24),
+		byte(val>>32), byte(val>>40), byte(val>>48), byte(val>>56))
+}
+
+// appendVarInt appends the variable length integer encoding of val to b.  It
+// produces the same encoding as WriteVarInt.
+func appendVarInt(b []byte, val uint64) []byte {
+	switch {
+	case val < 0xfd:
+		return append(b, uint8(val))
+	case val <= math.MaxUint16:
+		return append(b, 0xfd, byte(val), byte(val>>8))
+	case val <= math.MaxUint32:
+		return appendUint32(append(b, 0xfe), uint32(val))
+	default:
+		return appendUint64(append(b, 0xff), val)
+	}
+}
+
+// appendVarBytes appends the variable length byte array encoding of bytes to
+// b.  It produces the same encoding as WriteVarBytes.
+func appendVarBytes(b []byte, bytes []byte) []byte {
+	return append(appendVarInt(b, uint64(len(bytes))), bytes...)
+}
+
 // errNonCanonicalVarInt is the common format string used for non-canonically
 // encoded variable length integer errors.
 var errNonCanonicalVarInt = "non-canonical varint %x - discriminant %x must " +
@@ -492,8 +575,9 @@ func ReadVarInt(r io.Reader, pver uint32) (uint64, error) {
 		// encoded using fewer bytes.
 		min := uint64(0x100000000)
 		if rv < min {
-			return 0, messageError("ReadVarInt", fmt.Sprintf(
-				errNonCanonicalVarInt, rv, discriminant, min))
+			return 0, messageErrorCode("ReadVarInt",
+				ErrNonCanonicalVarInt, fmt.Sprintf(
+					errNonCanonicalVarInt, rv, discriminant, min))
 		}
 
 	case 0xfe:
@@ -507,8 +591,9 @@ func ReadVarInt(r io.Reader, pver uint32) (uint64, error) {
 		// encoded using fewer bytes.
 		min := uint64(0x10000)
 		if rv < min {
-			return 0, messageError("ReadVarInt", fmt.Sprintf(
-				errNonCanonicalVarInt, rv, discriminant, min))
+			return 0, messageErrorCode("ReadVarInt",
+				ErrNonCanonicalVarInt, fmt.Sprintf(
+					errNonCanonicalVarInt, rv, discriminant, min))
 		}
 
 	case 0xfd:
@@ -522,8 +607,9 @@ func ReadVarInt(r io.Reader, pver uint32) (uint64, error) {
 		// encoded using fewer bytes.
 		min := uint64(0xfd)
 		if rv < min {
-			return 0, messageError("ReadVarInt", fmt.Sprintf(
-				errNonCanonicalVarInt, rv, discriminant, min))
+			return 0, messageErrorCode("ReadVarInt",
+				ErrNonCanonicalVarInt, fmt.Sprintf(
+					errNonCanonicalVarInt, rv, discriminant, min))
 		}
 
 	default:
@@ -533,6 +619,64 @@ func ReadVarInt(r io.Reader, pver uint32) (uint64, error) {
 	return rv, nil
 }
 
+// lenReader is implemented by readers, such as *bytes.Buffer and
+// *bytes.Reader, which know how many unread bytes remain.  Messages read by
+// ReadMessage are always decoded from such a reader.
+type lenReader interface {
+	Len() int
+}
+
+// checkRemaining returns an ErrTruncatedPayload error when r knows how many
+// bytes remain and they can not possibly hold count items of at least
+// minSize bytes each.  Decoders call it before allocating space for a count
+// read from the wire so the allocation is bounded by the payload that was
+// actually received rather than by the count a peer claims.
+func checkRemaining(r io.Reader, f string, count, minSize uint64,
+	fieldName string) error {
+
+	lr, ok := r.(lenReader)
+	if !ok || minSize == 0 {
+		return nil
+	}
+	remaining := uint64(lr.Len())
+	if count > remaining/minSize {
+		str := fmt.Sprintf("%s count %d needs at least %d bytes, but "+
+			"only %d remain", fieldName, count, count*minSize,
+			remaining)
+		return messageErrorCode(f, ErrTruncatedPayload, str)
+	}
+	return nil
+}
+
+// readCount reads a variable length integer count of items from r.  An
+// ErrTooManyItems error is returned if the count exceeds max and an
+// ErrTruncatedPayload error if the remaining bytes of r can not hold count
+// items of at least minSize bytes each.  The fieldName parameter is only used
+// for the error message so it provides more context in the error.
+func readCount(r io.Reader, pver uint32, f string, max, minSize uint64,
+	fieldName string) (uint64, error) {
+
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return 0, err
+	}
+
+	// Prevent more items than could possibly fit into the message.  It
+	// would be possible to cause memory exhaustion and panics without a
+	// sane upper bound on this count.
+	if count > max {
+		str := fmt.Sprintf("too many %s for message [count %d, max %d]",
+			fieldName, count, max)
+		return 0, messageErrorCode(f, ErrTooManyItems, str)
+	}
+
+	err = checkRemaining(r, f, count, minSize, fieldName)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 // WriteVarInt serializes val to w using a variable number of bytes depending
 // on its value.
 func WriteVarInt(w io.Writer, pver uint32, val uint64) error {
@@ -604,7 +748,13 @@ func ReadVarString(r io.Reader, pver uint32) (string, error) {
 	if count > uint64(maxMessagePayload()) {
 		str := fmt.Sprintf("variable length string is too long "+
 			"[count %d, max %d]", count, maxMessagePayload())
-		return "", messageError("ReadVarString", str)
+		return "", messageErrorCode("ReadVarString", ErrFieldTooLarge,
+			str)
+	}
+	err = checkRemaining(r, "ReadVarString", count, 1,
+		"variable length string")
+	if err != nil {
+		return "", err
 	}
 
 	buf := make([]byte, count)
@@ -648,7 +798,12 @@ func ReadVarBytes(r io.Reader, pver uint32, maxAllowed uint32,
 	if count > uint64(maxAllowed) {
 		str := fmt.Sprintf("%s is larger than the max allowed size "+
 			"[count %d, max %d]", fieldName, count, maxAllowed)
-		return nil, messageError("ReadVarBytes", str)
+		return nil, messageErrorCode("ReadVarBytes", ErrFieldTooLarge,
+			str)
+	}
+	err = checkRemaining(r, "ReadVarBytes", count, 1, fieldName)
+	if err != nil {
+		return nil, err
 	}
 
 	b := make([]byte, count)
@@ -765,3 +765,75 @@ func TestRandomUint64Errors(t *testing.T) {
 		t.Errorf("Nonce is not 0 [%v]", nonce)
 	}
 }
+
+// TestAppendEncoding ensures the append based encoders used on the hot
+// serialization paths produce the same bytes as the io.Writer based ones.
+func TestAppendEncoding(t *testing.T) {
+	pver := ProtocolVersion
+
+	vals := []uint64{0, 0xfc, 0xfd, 0xffff, 0x10000, 0xffffffff,
+		0x100000000, 0xffffffffffffffff}
+	for _, val := range vals {
+		var buf bytes.Buffer
+		WriteVarInt(&buf, pver, val)
+		if got := appendVarInt(nil, val); !bytes.Equal(got, buf.Bytes()) {
+			t.Errorf("appendVarInt %d: got %x, want %x", val, got,
+				buf.Bytes())
+		}
+	}
+
+	header := &blockOne.Header
+	var buf bytes.Buffer
+	writeElements(&buf, header.Version, &header.PrevBlock,
+		&header.MerkleRoot, &header.CIDRoot,
+		uint32(header.Timestamp.Unix()), header.Bits, header.Nonce)
+	if got := appendBlockHeader(nil, header); !bytes.Equal(got, buf.Bytes()) {
+		t.Errorf("appendBlockHeader: got %x, want %x", got, buf.Bytes())
+	}
+
+	for _, tx := range []*MsgTx{multiTx, blockOne.Transactions[0]} {
+		buf.Reset()
+		binarySerializer.PutUint32(&buf, littleEndian, uint32(tx.Version))
+		WriteVarInt(&buf, pver, uint64(len(tx.TxIn)))
+		for _, ti := range tx.TxIn {
+			writeTxIn(&buf, pver, tx.Version, ti)
+		}
+		WriteVarInt(&buf, pver, uint64(len(tx.TxOut)))
+		for _, to := range tx.TxOut {
+			WriteTxOut(&buf, pver, tx.Version, to)
+		}
+		binarySerializer.PutUint32(&buf, littleEndian, tx.LockTime)
+
+		got := tx.appendTx(serializePool.Borrow(0))
+		if !bytes.Equal(got, buf.Bytes()) {
+			t.Errorf("appendTx %v: got %x, want %x", tx.TxHash(), got,
+				buf.Bytes())
+		}
+		if len(got) != tx.SerializeSize() {
+			t.Errorf("appendTx %v: got %d bytes, SerializeSize %d",
+				tx.TxHash(), len(got), tx.SerializeSize())
+		}
+		serializePool.Return(got)
+	}
+}
+
+// TestSerializeFreeList ensures the serialization free list hands out empty
+// buffers of the requested capacity and does not keep oversized buffers.
+func TestSerializeFreeList(t *testing.T) {
+	pool := make(serializeFreeList, 1)
+
+	buf := pool.Borrow(100)
+	if len(buf) != 0 || cap(buf) < 100 {
+		t.Fatalf("Borrow: got len %d cap %d", len(buf), cap(buf))
+	}
+	pool.Return(append(buf, 1, 2, 3))
+	if buf = pool.Borrow(10); len(buf) != 0 || cap(buf) < 100 {
+		t.Fatalf("Borrow: got len %d cap %d, want reused buffer",
+			len(buf), cap(buf))
+	}
+
+	pool.Return(make([]byte, serializeFreeListMaxBufSize+1))
+	if len(pool) != 0 {
+		t.Fatalf("Return: oversized buffer kept on the free list")
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+)
+
+// TestReadMessageDeclaredLength ensures a header which declares a large
+// payload that is never sent does not cause a matching allocation.
+func TestReadMessageDeclaredLength(t *testing.T) {
+	pver := ProtocolVersion
+	czznet := MainNet
+
+	msg := makeHeader(czznet, CmdBlock, MaxBlockPayload(), 0)
+	msg = append(msg, make([]byte, 10)...)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	_, _, _, err := ReadMessageN(bytes.NewReader(msg), pver, czznet)
+	runtime.ReadMemStats(&after)
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("ReadMessageN: got error %v, want %v", err,
+			io.ErrUnexpectedEOF)
+	}
+
+	const maxAlloc = 4 * maxPayloadChunk
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > maxAlloc {
+		t.Fatalf("ReadMessageN: allocated %d bytes for a payload "+
+			"of 10 bytes, want at most %d", alloc, maxAlloc)
+	}
+}
+
+// TestDecodeCountLimits ensures counts decoded from messages are checked
+// against both the maximum allowed for the field and the bytes remaining in
+// the payload before any space is allocated for them.
+func TestDecodeCountLimits(t *testing.T) {
+	pver := ProtocolVersion
+
+	// varInt returns the encoding of the passed value prefixed by
+	// prefix.
+	varInt := func(prefix []byte, val uint64) []byte {
+		var buf bytes.Buffer
+		buf.Write(prefix)
+		WriteVarInt(&buf, pver, val)
+		return buf.Bytes()
+	}
+	hash := make([]byte, chainhash.HashSize)
+	header := make([]byte, BlockHeaderSize)
+	txPrefix := []byte{0x01, 0x00, 0x00, 0x00}
+
+	tests := []struct {
+		name string
+		msg  Message
+		buf  []byte
+		code MessageErrorCode
+	}{
+		{"inv over max", &MsgInv{}, varInt(nil, MaxInvPerMsg+1),
+			ErrTooManyItems},
+		{"inv truncated", &MsgInv{}, varInt(nil, MaxInvPerMsg),
+			ErrTruncatedPayload},
+		{"getdata truncated", &MsgGetData{}, varInt(nil, 2),
+			ErrTruncatedPayload},
+		{"notfound truncated", &MsgNotFound{}, varInt(nil, 2),
+			ErrTruncatedPayload},
+		{"addr truncated", &MsgAddr{}, varInt(nil, MaxAddrPerMsg),
+			ErrTruncatedPayload},
+		{"addrv2 truncated", &MsgAddrV2{}, varInt(nil, MaxAddrPerMsg),
+			ErrTruncatedPayload},
+		{"headers over max", &MsgHeaders{},
+			varInt(nil, MaxBlockHeadersPerMsg+1), ErrTooManyItems},
+		{"headers truncated", &MsgHeaders{}, varInt(nil, 100),
+			ErrTruncatedPayload},
+		{"block truncated", &MsgBlock{},
+			varInt(header, uint64(maxTxPerBlock())),
+			ErrTruncatedPayload},
+		{"block over max", &MsgBlock{},
+			varInt(header, uint64(maxTxPerBlock())+1),
+			ErrTooManyItems},
+		{"tx inputs truncated", &MsgTx{},
+			varInt(txPrefix, uint64(maxTxInPerMessage())),
+			ErrTruncatedPayload},
+		{"tx inputs over max", &MsgTx{},
+			varInt(txPrefix, uint64(maxTxInPerMessage())+1),
+			ErrTooManyItems},
+		{"tx outputs truncated", &MsgTx{},
+			varInt(append(append([]byte{}, txPrefix...), 0x00),
+				uint64(maxTxOutPerMessage())),
+			ErrTruncatedPayload},
+		{"tx script truncated", &MsgTx{},
+			varInt(append(append(append([]byte{}, txPrefix...), 0x01),
+				make([]byte, 36)...), 1000),
+			ErrTruncatedPayload},
+		{"blocktxn truncated", &MsgBlockTxns{}, varInt(hash, 1000),
+			ErrTruncatedPayload},
+		{"getblocktxn truncated", &MsgGetBlockTxns{},
+			varInt(hash, 1000), ErrTruncatedPayload},
+		{"getblocks truncated", &MsgGetBlocks{},
+			varInt(txPrefix, MaxBlockLocatorsPerMsg),
+			ErrTruncatedPayload},
+		{"getheaders over max", &MsgGetHeaders{},
+			varInt(txPrefix, MaxBlockLocatorsPerMsg+1),
+			ErrTooManyItems},
+		{"cmpctblock short ids truncated", &MsgCmpctBlock{},
+			varInt(append(append([]byte{}, header...),
+				make([]byte, 8)...), 1000), ErrTruncatedPayload},
+		{"merkleblock truncated", &MsgMerkleBlock{},
+			varInt(append(append([]byte{}, header...),
+				make([]byte, 4)...), 1000), ErrTruncatedPayload},
+		{"filterload truncated", &MsgFilterLoad{},
+			varInt(nil, MaxFilterLoadFilterSize), ErrTruncatedPayload},
+		{"filterload over max", &MsgFilterLoad{},
+			varInt(nil, MaxFilterLoadFilterSize+1), ErrFieldTooLarge},
+		{"non-canonical varint", &MsgInv{}, []byte{0xfd, 0x01, 0x00},
+			ErrNonCanonicalVarInt},
+	}
+
+	for _, test := range tests {
+		msg := reflect.New(reflect.TypeOf(test.msg).Elem()).Interface().(Message)
+		err := msg.CzzDecode(bytes.NewReader(test.buf), pver,
+			BaseEncoding)
+		if !IsMessageErrorCode(err, test.code) {
+			t.Errorf("%s: got error %v, want %v", test.name, err,
+				test.code)
+		}
+	}
+}
+
+// TestReadMessageErrorCodes ensures errors returned by ReadMessage carry the
+// expected error codes.
+func TestReadMessageErrorCodes(t *testing.T) {
+	pver := ProtocolVersion
+	czznet := MainNet
+
+	tests := []struct {
+		name string
+		buf  []byte
+		code MessageErrorCode
+	}{
+		{"wrong network", makeHeader(TestNet3, CmdVerAck, 0, 0),
+			ErrWrongNetwork},
+		{"invalid command", makeHeader(czznet, "\xff", 0, 0),
+			ErrInvalidCommand},
+		{"unknown command", makeHeader(czznet, "unknown", 0, 0),
+			ErrUnknownCommand},
+		{"payload exceeds type max", makeHeader(czznet, CmdAddr,
+			(&MsgAddr{}).MaxPayloadLength(pver)+1, 0),
+			ErrPayloadTooLarge},
+		{"payload exceeds max", makeHeader(czznet, CmdBlock,
+			maxMessagePayload()+1, 0), ErrPayloadTooLarge},
+		{"bad checksum", makeHeader(czznet, CmdVerAck, 0, 0),
+			ErrInvalidChecksum},
+	}
+
+	for _, test := range tests {
+		_, _, _, err := ReadMessageN(bytes.NewReader(test.buf), pver,
+			czznet)
+		if !IsMessageErrorCode(err, test.code) {
+			t.Errorf("%s: got error %v, want %v", test.name, err,
+				test.code)
+		}
+	}
+
+	// Ensure the codes are printed by name.
+	if s := ErrTruncatedPayload.String(); s != "ErrTruncatedPayload" {
+		t.Errorf("String: got %q, want %q", s, "ErrTruncatedPayload")
+	}
+	if s := MessageErrorCode(0xffff).String(); s !=
+		"Unknown MessageErrorCode (65535)" {
+
+		t.Errorf("String: got %q for unknown code", s)
+	}
+}
@@ -8,16 +8,86 @@ import (
 	"fmt"
 )
 
+// MessageErrorCode identifies a kind of issue with a message.
+type MessageErrorCode int
+
+// These constants are used to identify a specific MessageError.
+const (
+	// ErrMalformedMessage indicates a message could not be decoded for a
+	// reason not covered by one of the more specific codes.
+	ErrMalformedMessage MessageErrorCode = iota
+
+	// ErrPayloadTooLarge indicates the payload of a message, either as
+	// declared by its header or as encoded, exceeds the maximum allowed for
+	// all messages or for messages of its type.
+	ErrPayloadTooLarge
+
+	// ErrTooManyItems indicates a count of items decoded from a message
+	// exceeds the maximum allowed for the field.
+	ErrTooManyItems
+
+	// ErrFieldTooLarge indicates a variable length field of a message
+	// exceeds the maximum size allowed for it.
+	ErrFieldTooLarge
+
+	// ErrTruncatedPayload indicates a count or length decoded from a
+	// message can not possibly be satisfied by the bytes that remain in
+	// its payload.
+	ErrTruncatedPayload
+
+	// ErrNonCanonicalVarInt indicates a variable length integer was not
+	// encoded in its shortest form.
+	ErrNonCanonicalVarInt
+
+	// ErrWrongNetwork indicates a message was sent for another network.
+	ErrWrongNetwork
+
+	// ErrInvalidCommand indicates the command of a message is malformed.
+	ErrInvalidCommand
+
+	// ErrUnknownCommand indicates the command of a message is not known.
+	ErrUnknownCommand
+
+	// ErrInvalidChecksum indicates the checksum of a message does not
+	// match its payload.
+	ErrInvalidChecksum
+)
+
+// Map of MessageErrorCode values back to their constant names for pretty
+// printing.
+var messageErrorCodeStrings = map[MessageErrorCode]string{
+	ErrMalformedMessage:   "ErrMalformedMessage",
+	ErrPayloadTooLarge:    "ErrPayloadTooLarge",
+	ErrTooManyItems:       "ErrTooManyItems",
+	ErrFieldTooLarge:      "ErrFieldTooLarge",
+	ErrTruncatedPayload:   "ErrTruncatedPayload",
+	ErrNonCanonicalVarInt: "ErrNonCanonicalVarInt",
+	ErrWrongNetwork:       "ErrWrongNetwork",
+	ErrInvalidCommand:     "ErrInvalidCommand",
+	ErrUnknownCommand:     "ErrUnknownCommand",
+	ErrInvalidChecksum:    "ErrInvalidChecksum",
+}
+
+// String returns the MessageErrorCode as a human-readable name.
+func (e MessageErrorCode) String() string {
+	if s := messageErrorCodeStrings[e]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown MessageErrorCode (%d)", int(e))
+}
+
 // MessageError describes an issue with a message.
 // An example of some potential issues are messages from the wrong bitcoin
 // network, invalid commands, mismatched checksums, and exceeding max payloads.
 //
 // This provides a mechanism for the caller to type assert the error to
 // differentiate between general io errors such as io.EOF and issues that
-// resulted from malformed messages.
+// resulted from malformed messages.  The ErrorCode field can be used to
+// further tell apart the kind of issue.
 type MessageError struct {
-	Func        string // Function name
-	Description string // Human readable description of the issue
+	Func        string           // Function name
+	ErrorCode   MessageErrorCode // Describes the kind of issue
+	Description string           // Human readable description of the issue
 }
 
 // Error satisfies the error interface and prints human-readable errors.
@@ -28,7 +98,22 @@ func (e *MessageError) Error() string {
 	return e.Description
 }
 
-// messageError creates an error for the given function and description.
+// messageError creates an ErrMalformedMessage error for the given function
+// and description.
 func messageError(f string, desc string) *MessageError {
-	return &MessageError{Func: f, Description: desc}
+	return &MessageError{Func: f, ErrorCode: ErrMalformedMessage,
+		Description: desc}
+}
+
+// messageErrorCode creates an error for the given function, error code and
+// description.
+func messageErrorCode(f string, code MessageErrorCode, desc string) *MessageError {
+	return &MessageError{Func: f, ErrorCode: code, Description: desc}
+}
+
+// IsMessageErrorCode returns whether or not the provided error is a
+// MessageError with the provided error code.
+func IsMessageErrorCode(err error, code MessageErrorCode) bool {
+	merr, ok := err.(*MessageError)
+	return ok && merr.ErrorCode == code
 }
@@ -22,6 +22,10 @@ const MessageHeaderSize = 24
 // header.  Shorter commands must be zero padded.
 const CommandSize = 12
 
+// maxPayloadChunk is the largest amount of memory allocated at once while
+// reading a message payload.
+const maxPayloadChunk = 1024 * 1024
+
 // ebs is the excessive block size, used to determine reasonable maximum message sizes.
 // 32MB is the current default value
 var ebs uint32 = 32000000
@@ -39,41 +43,49 @@ func maxMessagePayload() uint32 {
 
 // Commands used in bitcoin message headers which describe the type of message.
 const (
-	CmdVersion      = "version"
-	CmdXVersion     = "xversion"
-	CmdVerAck       = "verack"
-	CmdXVerAck      = "xverack"
-	CmdGetAddr      = "getaddr"
-	CmdAddr         = "addr"
-	CmdGetBlocks    = "getblocks"
-	CmdInv          = "inv"
-	CmdGetData      = "getdata"
-	CmdNotFound     = "notfound"
-	CmdBlock        = "block"
-	CmdTx           = "tx"
-	CmdGetHeaders   = "getheaders"
-	CmdHeaders      = "headers"
-	CmdPing         = "ping"
-	CmdPong         = "pong"
-	CmdMemPool      = "mempool"
-	CmdFilterAdd    = "filteradd"
-	CmdFilterClear  = "filterclear"
-	CmdFilterLoad   = "filterload"
-	CmdMerkleBlock  = "merkleblock"
-	CmdReject       = "reject"
-	CmdSendHeaders  = "sendheaders"
-	CmdFeeFilter    = "feefilter"
-	CmdGetCFilters  = "getcfilters"
-	CmdGetCFHeaders = "getcfheaders"
-	CmdGetCFCheckpt = "getcfcheckpt"
-	CmdGetCFMempool = "getcfmempool"
-	CmdCFilter      = "cfilter"
-	CmdCFHeaders    = "cfheaders"
-	CmdCFCheckpt    = "cfcheckpt"
-	CmdSendCmpct    = "sendcmpct"
-	CmdCmpctBlock   = "cmpctblock"
-	CmdGetBlockTxns = "getblocktxn"
-	CmdBlockTxns    = "blocktxn"
+	CmdVersion          = "version"
+	CmdXVersion         = "xversion"
+	CmdVerAck           = "verack"
+	CmdXVerAck          = "xverack"
+	CmdGetAddr          = "getaddr"
+	CmdAddr             = "addr"
+	CmdAddrV2           = "addrv2"
+	CmdSendAddrV2       = "sendaddrv2"
+	CmdGetBlocks        = "getblocks"
+	CmdInv              = "inv"
+	CmdGetData          = "getdata"
+	CmdNotFound         = "notfound"
+	CmdBlock            = "block"
+	CmdTx               = "tx"
+	CmdGetHeaders       = "getheaders"
+	CmdHeaders          = "headers"
+	CmdPing             = "ping"
+	CmdPong             = "pong"
+	CmdMemPool          = "mempool"
+	CmdFilterAdd        = "filteradd"
+	CmdFilterClear      = "filterclear"
+	CmdFilterLoad       = "filterload"
+	CmdMerkleBlock      = "merkleblock"
+	CmdReject           = "reject"
+	CmdSendHeaders      = "sendheaders"
+	CmdFeeFilter        = "feefilter"
+	CmdGetCFilters      = "getcfilters"
+	CmdGetCFHeaders     = "getcfheaders"
+	CmdGetCFCheckpt     = "getcfcheckpt"
+	CmdGetCFMempool     = "getcfmempool"
+	CmdCFilter          = "cfilter"
+	CmdCFHeaders        = "cfheaders"
+	CmdCFCheckpt        = "cfcheckpt"
+	CmdSendCmpct        = "sendcmpct"
+	CmdCmpctBlock       = "cmpctblock"
+	CmdGetBlockTxns     = "getblocktxn"
+	CmdBlockTxns        = "blocktxn"
+	CmdGetGrapheneBlock = "getgrblk"
+	CmdGrapheneBlock    = "grblk"
+	CmdGetGrapheneTx    = "getgrblktx"
+	CmdGrapheneTx       = "grblktx"
+	CmdGetUtxoSnapshot  = "getutxosnap"
+	CmdUtxoSnapshot     = "utxosnap"
 )
 
 // MessageEncoding represents the wire message encoding format to be used.
@@ -123,6 +135,12 @@ func makeEmptyMessage(command string) (Message, error) {
 	case CmdAddr:
 		msg = &MsgAddr{}
 
+	case CmdAddrV2:
+		msg = &MsgAddrV2{}
+
+	case CmdSendAddrV2:
+		msg = &MsgSendAddrV2{}
+
 	case CmdGetBlocks:
 		msg = &MsgGetBlocks{}
 
@@ -210,6 +228,24 @@ func makeEmptyMessage(command string) (Message, error) {
 	case CmdBlockTxns:
 		msg = &MsgBlockTxns{}
 
+	case CmdGetGrapheneBlock:
+		msg = &MsgGetGrapheneBlock{}
+
+	case CmdGrapheneBlock:
+		msg = &MsgGrapheneBlock{}
+
+	case CmdGetGrapheneTx:
+		msg = &MsgGetGrapheneTx{}
+
+	case CmdGrapheneTx:
+		msg = &MsgGrapheneTx{}
+
+	case CmdGetUtxoSnapshot:
+		msg = &MsgGetUtxoSnapshot{}
+
+	case CmdUtxoSnapshot:
+		msg = &MsgUtxoSnapshot{}
+
 	default:
 		return nil, fmt.Errorf("unhandled command [%s]", command)
 	}
@@ -268,6 +304,34 @@ func discardInput(r io.Reader, n uint32) {
 	}
 }
 
+// readPayload reads a message payload of the passed length from r.  The
+// payload buffer is grown in chunks of at most maxPayloadChunk bytes as data
+// arrives rather than being allocated up front, so a header declaring a large
+// payload which is never sent can not force a large allocation.
+func readPayload(r io.Reader, length uint32) (int, []byte, error) {
+	chunk := length
+	if chunk > maxPayloadChunk {
+		chunk = maxPayloadChunk
+	}
+	payload := make([]byte, 0, chunk)
+
+	totalBytes := 0
+	for uint32(len(payload)) < length {
+		chunk = length - uint32(len(payload))
+		if chunk > maxPayloadChunk {
+			chunk = maxPayloadChunk
+		}
+		start := len(payload)
+		payload = append(payload, make([]byte, chunk)...)
+		n, err := io.ReadFull(r, payload[start:])
+		totalBytes += n
+		if err != nil {
+			return totalBytes, nil, err
+		}
+	}
+	return totalBytes, payload, nil
+}
+
 // WriteMessageN writes a bitcoin Message to w including the necessary header
 // information and returns the number of bytes written.    This function is the
 // same as WriteMessage except it also returns the number of bytes written.
@@ -301,13 +365,16 @@ func WriteMessageWithEncodingN(w io.Writer, msg Message, pver uint32,
 	if len(cmd) > CommandSize {
 		str := fmt.Sprintf("command [%s] is too long [max %v]",
 			cmd, CommandSize)
-		return totalBytes, messageError("WriteMessage", str)
+		return totalBytes, messageErrorCode("WriteMessage",
+			ErrInvalidCommand, str)
 	}
 	copy(command[:], []byte(cmd))
 
-	// Encode the message payload.
-	var bw bytes.Buffer
-	err := msg.CzzEncode(&bw, pver, encoding)
+	// Encode the message payload into a pooled buffer.  The buffer is
+	// returned to the pool once the payload has been written.
+	bw := bytes.NewBuffer(serializePool.Borrow(0))
+	defer func() { serializePool.Return(bw.Bytes()) }()
+	err := msg.CzzEncode(bw, pver, encoding)
 	if err != nil {
 		return totalBytes, err
 	}
@@ -319,7 +386,8 @@ func WriteMessageWithEncodingN(w io.Writer, msg Message, pver uint32,
 		str := fmt.Sprintf("message payload is too large - encoded "+
 			"%d bytes, but maximum message payload is %d bytes",
 			lenp, maxMessagePayload())
-		return totalBytes, messageError("WriteMessage", str)
+		return totalBytes, messageErrorCode("WriteMessage",
+			ErrPayloadTooLarge, str)
 	}
 
 	// Enforce maximum message payload based on the message type.
@@ -328,24 +396,22 @@ func WriteMessageWithEncodingN(w io.Writer, msg Message, pver uint32,
 		str := fmt.Sprintf("message payload is too large - encoded "+
 			"%d bytes, but maximum message payload size for "+
 			"messages of type [%s] is %d.", lenp, cmd, mpl)
-		return totalBytes, messageError("WriteMessage", str)
+		return totalBytes, messageErrorCode("WriteMessage",
+			ErrPayloadTooLarge, str)
 	}
 
-	// Create header for the message.
-	hdr := messageHeader{}
-	hdr.magic = czznet
-	hdr.command = cmd
-	hdr.length = uint32(lenp)
-	copy(hdr.checksum[:], chainhash.DoubleHashB(payload)[0:4])
-
 	// Encode the header for the message.  This is done to a buffer
-	// rather than directly to the writer since writeElements doesn't
-	// return the number of bytes written.
-	hw := bytes.NewBuffer(make([]byte, 0, MessageHeaderSize))
-	writeElements(hw, hdr.magic, command, hdr.length, hdr.checksum)
+	// rather than directly to the writer so the number of bytes written
+	// is known.
+	checksum := chainhash.DoubleHashH(payload)
+	var hdr [MessageHeaderSize]byte
+	littleEndian.PutUint32(hdr[0:4], uint32(czznet))
+	copy(hdr[4:4+CommandSize], command[:])
+	littleEndian.PutUint32(hdr[4+CommandSize:], uint32(lenp))
+	copy(hdr[8+CommandSize:], checksum[:4])
 
 	// Write header.
-	n, err := w.Write(hw.Bytes())
+	n, err := w.Write(hdr[:])
 	totalBytes += n
 	if err != nil {
 		return totalBytes, err
@@ -378,7 +444,8 @@ func ReadMessageWithEncodingN(r io.Reader, pver uint32, czznet BitcoinNet,
 		str := fmt.Sprintf("message payload is too large - header "+
 			"indicates %d bytes, but max message payload is %d "+
 			"bytes.", hdr.length, maxMessagePayload())
-		return totalBytes, nil, nil, messageError("ReadMessage", str)
+		return totalBytes, nil, nil, messageErrorCode("ReadMessage",
+			ErrPayloadTooLarge, str)
 
 	}
 
@@ -386,7 +453,8 @@ func ReadMessageWithEncodingN(r io.Reader, pver uint32, czznet BitcoinNet,
 	if hdr.magic != czznet {
 		discardInput(r, hdr.length)
 		str := fmt.Sprintf("message from other network [%v]", hdr.magic)
-		return totalBytes, nil, nil, messageError("ReadMessage", str)
+		return totalBytes, nil, nil, messageErrorCode("ReadMessage",
+			ErrWrongNetwork, str)
 	}
 
 	// Check for malformed commands.
@@ -394,15 +462,16 @@ func ReadMessageWithEncodingN(r io.Reader, pver uint32, czznet BitcoinNet,
 	if !utf8.ValidString(command) {
 		discardInput(r, hdr.length)
 		str := fmt.Sprintf("invalid command %v", []byte(command))
-		return totalBytes, nil, nil, messageError("ReadMessage", str)
+		return totalBytes, nil, nil, messageErrorCode("ReadMessage",
+			ErrInvalidCommand, str)
 	}
 
 	// Create struct of appropriate message type based on the command.
 	msg, err := makeEmptyMessage(command)
 	if err != nil {
 		discardInput(r, hdr.length)
-		return totalBytes, nil, nil, messageError("ReadMessage",
-			err.Error())
+		return totalBytes, nil, nil, messageErrorCode("ReadMessage",
+			ErrUnknownCommand, err.Error())
 	}
 
 	// Check for maximum length based on the message type as a malicious client
@@ -414,12 +483,12 @@ func ReadMessageWithEncodingN(r io.Reader, pver uint32, czznet BitcoinNet,
 		str := fmt.Sprintf("payload exceeds max length - header "+
 			"indicates %v bytes, but max payload size for "+
 			"messages of type [%v] is %v.", hdr.length, command, mpl)
-		return totalBytes, nil, nil, messageError("ReadMessage", str)
+		return totalBytes, nil, nil, messageErrorCode("ReadMessage",
+			ErrPayloadTooLarge, str)
 	}
 
 	// Read payload.
-	payload := make([]byte, hdr.length)
-	n, err = io.ReadFull(r, payload)
+	n, payload, err := readPayload(r, hdr.length)
 	totalBytes += n
 	if err != nil {
 		return totalBytes, nil, nil, err
@@ -431,7 +500,8 @@ func ReadMessageWithEncodingN(r io.Reader, pver uint32, czznet BitcoinNet,
 		str := fmt.Sprintf("payload checksum failed - header "+
 			"indicates %v, but actual checksum is %v.",
 			hdr.checksum, checksum)
-		return totalBytes, nil, nil, messageError("ReadMessage", str)
+		return totalBytes, nil, nil, messageErrorCode("ReadMessage",
+			ErrInvalidChecksum, str)
 	}
 
 	// Unmarshal message.  NOTE: This must be a *bytes.Buffer since the
@@ -51,6 +51,8 @@ func TestMessage(t *testing.T) {
 	msgVerack := NewMsgVerAck()
 	msgGetAddr := NewMsgGetAddr()
 	msgAddr := NewMsgAddr()
+	msgAddrV2 := NewMsgAddrV2()
+	msgSendAddrV2 := NewMsgSendAddrV2()
 	msgGetBlocks := NewMsgGetBlocks(&chainhash.Hash{})
 	msgBlock := &blockOne
 	msgInv := NewMsgInv()
@@ -75,6 +77,14 @@ func TestMessage(t *testing.T) {
 		[]byte("payload"))
 	msgCFHeaders := NewMsgCFHeaders()
 	msgCFCheckpt := NewMsgCFCheckpt(GCSFilterRegular, &chainhash.Hash{}, 0)
+	msgGetGrapheneBlock := NewMsgGetGrapheneBlock(&chainhash.Hash{}, 10)
+	msgGrapheneBlock := NewMsgGrapheneBlock(bh, 123123)
+	msgGetGrapheneTx := NewMsgGetGrapheneTx(&chainhash.Hash{}, 123123,
+		[]uint64{1, 2})
+	msgGrapheneTx := NewMsgGrapheneTx(chainhash.Hash{}, nil)
+	msgGetUtxoSnapshot := NewMsgGetUtxoSnapshot(&chainhash.Hash{}, 3)
+	msgUtxoSnapshot := NewMsgUtxoSnapshot(&chainhash.Hash{},
+		&chainhash.Hash{}, 1<<30, 3, []byte("payload"))
 
 	tests := []struct {
 		in     Message    // Value to encode
@@ -87,6 +97,8 @@ func TestMessage(t *testing.T) {
 		{msgVerack, msgVerack, pver, MainNet, 24},
 		{msgGetAddr, msgGetAddr, pver, MainNet, 24},
 		{msgAddr, msgAddr, pver, MainNet, 25},
+		{msgAddrV2, msgAddrV2, pver, MainNet, 25},
+		{msgSendAddrV2, msgSendAddrV2, pver, MainNet, 24},
 		{msgGetBlocks, msgGetBlocks, pver, MainNet, 61},
 		{msgBlock, msgBlock, pver, MainNet, 239},
 		{msgInv, msgInv, pver, MainNet, 25},
@@ -109,6 +121,12 @@ func TestMessage(t *testing.T) {
 		{msgCFilter, msgCFilter, pver, MainNet, 65},
 		{msgCFHeaders, msgCFHeaders, pver, MainNet, 90},
 		{msgCFCheckpt, msgCFCheckpt, pver, MainNet, 58},
+		{msgGetGrapheneBlock, msgGetGrapheneBlock, pver, MainNet, 64},
+		{msgGrapheneBlock, msgGrapheneBlock, pver, MainNet, 163},
+		{msgGetGrapheneTx, msgGetGrapheneTx, pver, MainNet, 81},
+		{msgGrapheneTx, msgGrapheneTx, pver, MainNet, 57},
+		{msgGetUtxoSnapshot, msgGetUtxoSnapshot, pver, MainNet, 60},
+		{msgUtxoSnapshot, msgUtxoSnapshot, pver, MainNet, 108},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -333,7 +351,7 @@ func TestReadMessageWireErrors(t *testing.T) {
 			pver,
 			czznet,
 			len(badMessageBytes),
-			io.EOF,
+			&MessageError{},
 			25,
 		},
 
@@ -58,18 +58,13 @@ func (msg *MsgAddr) ClearAddresses() {
 // CzzDecode decodes r using the bitcoin protocol encoding into the receiver.
 // This is part of the Message interface implementation.
 func (msg *MsgAddr) CzzDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
-	count, err := ReadVarInt(r, pver)
+	// Limit to max addresses per message.
+	count, err := readCount(r, pver, "MsgAddr.CzzDecode", MaxAddrPerMsg,
+		uint64(maxNetAddressPayload(pver)), "addresses")
 	if err != nil {
 		return err
 	}
 
-	// Limit to max addresses per message.
-	if count > MaxAddrPerMsg {
-		str := fmt.Sprintf("too many addresses for message "+
-			"[count %v, max %v]", count, MaxAddrPerMsg)
-		return messageError("MsgAddr.CzzDecode", str)
-	}
-
 	addrList := make([]NetAddress, count)
 	msg.AddrList = make([]*NetAddress, 0, count)
 	for i := uint64(0); i < count; i++ {
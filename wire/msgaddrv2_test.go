@@ -0,0 +1,134 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestAddrV2Wire tests the MsgAddrV2 wire encode and decode for addresses of
+// every known network.
+func TestAddrV2Wire(t *testing.T) {
+	pver := ProtocolVersion
+	ts := time.Unix(0x495fab29, 0) // 2009-01-03 12:15:05 -0600 CST
+
+	torV3 := bytes.Repeat([]byte{0x11}, TorV3AddrSize)
+	i2p := bytes.Repeat([]byte{0x22}, I2PAddrSize)
+
+	msg := NewMsgAddrV2()
+	msg.AddAddresses(
+		&NetAddress{Timestamp: ts, Services: SFNodeNetwork,
+			IP: net.ParseIP("127.0.0.1"), Port: 8333},
+		&NetAddress{Timestamp: ts, Services: SFNodeNetwork,
+			IP: net.ParseIP("2001:db8::1"), Port: 8334},
+		&NetAddress{Timestamp: ts, Services: SFNodeNetwork,
+			IP:   net.ParseIP("fd87:d87e:eb43:102:304:506:708:90a"),
+			Port: 8335},
+		&NetAddress{Timestamp: ts, Services: SFNodeNetwork,
+			Network: NetworkTorV3, Addr: torV3, Port: 8336},
+		&NetAddress{Timestamp: ts, Services: SFNodeNetwork,
+			Network: NetworkI2P, Addr: i2p, Port: 0},
+	)
+
+	entry := func(network byte, addr []byte, port uint16) []byte {
+		b := []byte{0x29, 0xab, 0x5f, 0x49, 0x01, network, byte(len(addr))}
+		b = append(b, addr...)
+		return append(b, byte(port>>8), byte(port))
+	}
+	want := []byte{0x05}
+	want = append(want, entry(1, []byte{127, 0, 0, 1}, 8333)...)
+	want = append(want, entry(2, net.ParseIP("2001:db8::1"), 8334)...)
+	want = append(want, entry(3, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		8335)...)
+	want = append(want, entry(4, torV3, 8336)...)
+	want = append(want, entry(5, i2p, 0)...)
+
+	var buf bytes.Buffer
+	if err := msg.CzzEncode(&buf, pver, BaseEncoding); err != nil {
+		t.Fatalf("CzzEncode: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("CzzEncode\n got: %s want: %s",
+			spew.Sdump(buf.Bytes()), spew.Sdump(want))
+	}
+
+	var got MsgAddrV2
+	if err := got.CzzDecode(bytes.NewReader(want), pver, BaseEncoding); err != nil {
+		t.Fatalf("CzzDecode: %v", err)
+	}
+	if !reflect.DeepEqual(&got, msg) {
+		t.Fatalf("CzzDecode\n got: %s want: %s", spew.Sdump(&got),
+			spew.Sdump(msg))
+	}
+
+	wantNets := []NetworkID{NetworkIPv4, NetworkIPv6, NetworkTorV2,
+		NetworkTorV3, NetworkI2P}
+	for i, na := range got.AddrList {
+		if id := na.NetworkID(); id != wantNets[i] {
+			t.Errorf("NetworkID #%d: got %v, want %v", i, id,
+				wantNets[i])
+		}
+		if compat := na.AddrV1Compatible(); compat != (i < 3) {
+			t.Errorf("AddrV1Compatible #%d: got %v", i, compat)
+		}
+	}
+}
+
+// TestAddrV2WireErrors performs negative tests against the MsgAddrV2 decoder
+// and ensures addresses of unknown networks are preserved.
+func TestAddrV2WireErrors(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Tor v3 address with a short key.
+	short := []byte{0x01, 0x29, 0xab, 0x5f, 0x49, 0x01, 0x04, 0x02, 0x01,
+		0x02, 0x20, 0x8d}
+	var msg MsgAddrV2
+	err := msg.CzzDecode(bytes.NewReader(short), pver, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("CzzDecode short address: got error %v, want "+
+			"MessageError", err)
+	}
+
+	// Address larger than the maximum allowed.
+	var buf bytes.Buffer
+	buf.Write([]byte{0x01, 0x29, 0xab, 0x5f, 0x49, 0x01, 0x40})
+	WriteVarInt(&buf, pver, MaxAddrV2Size+1)
+	buf.Write(make([]byte, MaxAddrV2Size+1))
+	buf.Write([]byte{0x20, 0x8d})
+	err = msg.CzzDecode(&buf, pver, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("CzzDecode large address: got error %v, want "+
+			"MessageError", err)
+	}
+
+	// Too many addresses.
+	buf.Reset()
+	WriteVarInt(&buf, pver, MaxAddrPerMsg+1)
+	err = msg.CzzDecode(&buf, pver, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("CzzDecode too many: got error %v, want "+
+			"MessageError", err)
+	}
+
+	// Unknown networks are decoded as is so callers can ignore them.
+	unknown := []byte{0x01, 0x29, 0xab, 0x5f, 0x49, 0x01, 0x40, 0x03, 0xaa,
+		0xbb, 0xcc, 0x20, 0x8d}
+	err = msg.CzzDecode(bytes.NewReader(unknown), pver, BaseEncoding)
+	if err != nil {
+		t.Fatalf("CzzDecode unknown network: %v", err)
+	}
+	na := msg.AddrList[0]
+	if na.NetworkID() != 0x40 || !bytes.Equal(na.Addr,
+		[]byte{0xaa, 0xbb, 0xcc}) || na.Port != 8333 {
+
+		t.Errorf("CzzDecode unknown network: got %v", spew.Sdump(na))
+	}
+}
@@ -6,7 +6,6 @@ package wire
 
 import (
 	"bytes"
-	"fmt"
 	"io"
 
 	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
@@ -70,18 +69,13 @@ func (msg *MsgBlock) CzzDecode(r io.Reader, pver uint32, enc MessageEncoding) er
 		return err
 	}
 
-	txCount, err := ReadVarInt(r, pver)
-	if err != nil {
-		return err
-	}
-
 	// Prevent more transactions than could possibly fit into a block.
 	// It would be possible to cause memory exhaustion and panics without
 	// a sane upper bound on this count.
-	if txCount > uint64(maxTxPerBlock()) {
-		str := fmt.Sprintf("too many transactions to fit into a block "+
-			"[count %d, max %d]", txCount, maxTxPerBlock())
-		return messageError("MsgBlock.CzzDecode", str)
+	txCount, err := readCount(r, pver, "MsgBlock.CzzDecode",
+		uint64(maxTxPerBlock()), minTxPayload, "transactions")
+	if err != nil {
+		return err
 	}
 
 	msg.Transactions = make([]*MsgTx, 0, txCount)
@@ -128,18 +122,13 @@ func (msg *MsgBlock) DeserializeTxLoc(r *bytes.Buffer) ([]TxLoc, error) {
 		return nil, err
 	}
 
-	txCount, err := ReadVarInt(r, 0)
-	if err != nil {
-		return nil, err
-	}
-
 	// Prevent more transactions than could possibly fit into a block.
 	// It would be possible to cause memory exhaustion and panics without
 	// a sane upper bound on this count.
-	if txCount > uint64(maxTxPerBlock()) {
-		str := fmt.Sprintf("too many transactions to fit into a block "+
-			"[count %d, max %d]", txCount, maxTxPerBlock())
-		return nil, messageError("MsgBlock.DeserializeTxLoc", str)
+	txCount, err := readCount(r, 0, "MsgBlock.DeserializeTxLoc",
+		uint64(maxTxPerBlock()), minTxPayload, "transactions")
+	if err != nil {
+		return nil, err
 	}
 
 	// Deserialize each transaction while keeping track of its location
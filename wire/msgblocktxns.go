@@ -21,11 +21,13 @@ func (msg *MsgBlockTxns) CzzDecode(r io.Reader, pver uint32, enc MessageEncoding
 		return err
 	}
 
-	txCount, err := ReadVarInt(r, pver)
+	txCount, err := readCount(r, pver, "MsgBlockTxns.CzzDecode",
+		uint64(maxTxPerBlock()), minTxPayload, "transactions")
 	if err != nil {
 		return err
 	}
 
+	msg.Txs = make([]*MsgTx, 0, txCount)
 	for i := uint64(0); i < txCount; i++ {
 		tx := MsgTx{}
 		err = tx.CzzDecode(r, pver, enc)
@@ -66,9 +68,9 @@ func (msg *MsgBlockTxns) Command() string {
 // MaxPayloadLength returns the maximum length the payload can be for the
 // receiver.  This is part of the Message interface implementation.
 func (msg *MsgBlockTxns) MaxPayloadLength(pver uint32) uint32 {
-	// In practice this will always be less than the payload but the number
-	// of txs in a block can vary so we really don't know the real max.
-	return maxMessagePayload()
+	// The transactions are a subset of a block and the block hash is no
+	// larger than the header it replaces.
+	return MaxBlockPayload()
 }
 
 // AbsoluteIndexes takes in the requested differential indexes from a MsgGetBlockTxns
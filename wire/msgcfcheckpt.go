@@ -74,6 +74,11 @@ func (msg *MsgCFCheckpt) CzzDecode(r io.Reader, pver uint32, _ MessageEncoding)
 	if count > maxCFHeadersLen {
 		return ErrInsaneCFHeaderCount
 	}
+	err = checkRemaining(r, "MsgCFCheckpt.CzzDecode", count,
+		chainhash.HashSize, "filter headers")
+	if err != nil {
+		return err
+	}
 
 	// Create a contiguous slice of hashes to deserialize into in order to
 	// reduce the number of allocations.
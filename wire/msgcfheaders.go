@@ -67,19 +67,14 @@ func (msg *MsgCFHeaders) CzzDecode(r io.Reader, pver uint32, _ MessageEncoding)
 	}
 
 	// Read number of filter headers
-	count, err := ReadVarInt(r, pver)
+	// Limit to max committed filter headers per message.
+	count, err := readCount(r, pver, "MsgCFHeaders.CzzDecode",
+		MaxCFHeadersPerMsg, chainhash.HashSize,
+		"committed filter headers")
 	if err != nil {
 		return err
 	}
 
-	// Limit to max committed filter headers per message.
-	if count > MaxCFHeadersPerMsg {
-		str := fmt.Sprintf("too many committed filter headers for "+
-			"message [count %v, max %v]", count,
-			MaxBlockHeadersPerMsg)
-		return messageError("MsgCFHeaders.CzzDecode", str)
-	}
-
 	// Create a contiguous slice of hashes to deserialize into in order to
 	// reduce the number of allocations.
 	msg.FilterHashes = make([]*chainhash.Hash, 0, count)
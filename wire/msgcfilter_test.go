@@ -0,0 +1,131 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestCFMessagesWire tests the wire encode and decode of the committed filter
+// serving messages.
+func TestCFMessagesWire(t *testing.T) {
+	pver := ProtocolVersion
+	enc := BaseEncoding
+	stopHash := blockOne.BlockHash()
+
+	cfHeaders := NewMsgCFHeaders()
+	cfHeaders.FilterType = GCSFilterRegular
+	cfHeaders.StopHash = stopHash
+	cfHeaders.PrevFilterHeader = chainhash.Hash{0x01}
+	cfHeaders.AddCFHash(&chainhash.Hash{0x02})
+	cfHeaders.AddCFHash(&chainhash.Hash{0x03})
+
+	cfCheckpt := NewMsgCFCheckpt(GCSFilterRegular, &stopHash, 2)
+	cfCheckpt.AddCFHeader(&chainhash.Hash{0x04})
+	cfCheckpt.AddCFHeader(&chainhash.Hash{0x05})
+
+	tests := []struct {
+		in  Message // Message to encode
+		out Message // Empty message to decode into
+		cmd string  // Expected command
+	}{
+		{
+			NewMsgGetCFilters(GCSFilterRegular, 1000, &stopHash),
+			&MsgGetCFilters{},
+			"getcfilters",
+		},
+		{
+			NewMsgCFilter(GCSFilterRegular, &stopHash, []byte{1, 2, 3}),
+			&MsgCFilter{},
+			"cfilter",
+		},
+		{
+			NewMsgGetCFHeaders(GCSFilterRegular, 1000, &stopHash),
+			&MsgGetCFHeaders{},
+			"getcfheaders",
+		},
+		{cfHeaders, &MsgCFHeaders{}, "cfheaders"},
+		{
+			NewMsgGetCFCheckpt(GCSFilterRegular, &stopHash),
+			&MsgGetCFCheckpt{},
+			"getcfcheckpt",
+		},
+		{cfCheckpt, &MsgCFCheckpt{}, "cfcheckpt"},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		if cmd := test.in.Command(); cmd != test.cmd {
+			t.Errorf("Command #%d: got %v, want %v", i, cmd, test.cmd)
+		}
+
+		var buf bytes.Buffer
+		if err := test.in.CzzEncode(&buf, pver, enc); err != nil {
+			t.Errorf("CzzEncode #%d error %v", i, err)
+			continue
+		}
+		if uint32(buf.Len()) > test.in.MaxPayloadLength(pver) {
+			t.Errorf("CzzEncode #%d: payload of %d bytes exceeds "+
+				"max %d", i, buf.Len(),
+				test.in.MaxPayloadLength(pver))
+		}
+
+		if err := test.out.CzzDecode(&buf, pver, enc); err != nil {
+			t.Errorf("CzzDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.out, test.in) {
+			t.Errorf("CzzDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(test.out), spew.Sdump(test.in))
+		}
+	}
+}
+
+// TestCFHeadersLimits ensures cfheaders messages with more filter hashes than
+// allowed are refused by both the encoder and the decoder.
+func TestCFHeadersLimits(t *testing.T) {
+	pver := ProtocolVersion
+	enc := BaseEncoding
+
+	msg := NewMsgCFHeaders()
+	for i := 0; i < MaxCFHeadersPerMsg; i++ {
+		if err := msg.AddCFHash(&chainhash.Hash{}); err != nil {
+			t.Fatalf("AddCFHash #%d: unexpected error %v", i, err)
+		}
+	}
+	if err := msg.AddCFHash(&chainhash.Hash{}); err == nil {
+		t.Fatalf("AddCFHash: added more than %d hashes",
+			MaxCFHeadersPerMsg)
+	}
+
+	// Forge an encoding that claims one hash too many.
+	var buf bytes.Buffer
+	buf.WriteByte(byte(GCSFilterRegular))
+	buf.Write(make([]byte, chainhash.HashSize*2))
+	WriteVarInt(&buf, pver, MaxCFHeadersPerMsg+1)
+
+	var readmsg MsgCFHeaders
+	err := readmsg.CzzDecode(&buf, pver, enc)
+	if _, ok := err.(*MessageError); !ok {
+		t.Fatalf("CzzDecode: got error %v, want MessageError", err)
+	}
+
+	// Checkpoint messages refuse insane header counts as well.
+	buf.Reset()
+	buf.WriteByte(byte(GCSFilterRegular))
+	buf.Write(make([]byte, chainhash.HashSize))
+	WriteVarInt(&buf, pver, maxCFHeadersLen+1)
+
+	var checkpt MsgCFCheckpt
+	if err := checkpt.CzzDecode(&buf, pver, enc); err != ErrInsaneCFHeaderCount {
+		t.Fatalf("CzzDecode: got error %v, want %v", err,
+			ErrInsaneCFHeaderCount)
+	}
+}
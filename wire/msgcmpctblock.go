@@ -40,11 +40,13 @@ func (msg *MsgCmpctBlock) CzzDecode(r io.Reader, pver uint32, enc MessageEncodin
 		return err
 	}
 
-	shortIDCount, err := ReadVarInt(r, pver)
+	shortIDCount, err := readCount(r, pver, "MsgCmpctBlock.CzzDecode",
+		uint64(maxTxPerBlock()), ShortIDSize, "short ids")
 	if err != nil {
 		return err
 	}
 
+	msg.ShortIDs = make([][ShortIDSize]byte, 0, shortIDCount)
 	for i := uint64(0); i < shortIDCount; i++ {
 		shortIDBytes := make([]byte, ShortIDSize)
 		_, err = io.ReadFull(r, shortIDBytes)
@@ -57,11 +59,14 @@ func (msg *MsgCmpctBlock) CzzDecode(r io.Reader, pver uint32, enc MessageEncodin
 		msg.ShortIDs = append(msg.ShortIDs, shortID)
 	}
 
-	prefilledTxCount, err := ReadVarInt(r, pver)
+	prefilledTxCount, err := readCount(r, pver,
+		"MsgCmpctBlock.CzzDecode", uint64(maxTxPerBlock()),
+		1+minTxPayload, "prefilled transactions")
 	if err != nil {
 		return err
 	}
 
+	msg.PrefilledTxs = make([]*PrefilledTx, 0, prefilledTxCount)
 	for i := uint64(0); i < prefilledTxCount; i++ {
 		index, err := ReadVarInt(r, pver)
 		if err != nil {
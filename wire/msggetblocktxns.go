@@ -22,11 +22,13 @@ func (msg *MsgGetBlockTxns) CzzDecode(r io.Reader, pver uint32, enc MessageEncod
 		return err
 	}
 
-	indexCount, err := ReadVarInt(r, pver)
+	indexCount, err := readCount(r, pver, "MsgGetBlockTxns.CzzDecode",
+		uint64(maxTxPerBlock()), 1, "indexes")
 	if err != nil {
 		return err
 	}
 
+	msg.Indexes = make([]uint32, 0, indexCount)
 	for i := uint64(0); i < indexCount; i++ {
 		index, err := ReadVarInt(r, pver)
 		if err != nil {
@@ -66,9 +68,10 @@ func (msg *MsgGetBlockTxns) Command() string {
 // MaxPayloadLength returns the maximum length the payload can be for the
 // receiver.  This is part of the Message interface implementation.
 func (msg *MsgGetBlockTxns) MaxPayloadLength(pver uint32) uint32 {
-	// In practice this will always be less than the payload but the number
-	// of txs in a block can vary so we really don't know the real max.
-	return maxMessagePayload()
+	// Block hash + num indexes (varInt) + an index for every transaction
+	// that could fit into a block.
+	return chainhash.HashSize + MaxVarIntPayload +
+		maxTxPerBlock()*MaxVarIntPayload
 }
 
 // RequestedTransactions extracts the transactions that were requested by this
@@ -0,0 +1,50 @@
+package wire
+
+import (
+	"io"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+)
+
+// MsgGetGrapheneBlock implements the Message interface and represents a
+// getgrblk message.  It is used to request a block using the graphene block
+// propagation protocol.  The number of transactions in the requesting node's
+// mempool is included so the sender can size the bloom filter and IBLT.
+type MsgGetGrapheneBlock struct {
+	BlockHash    chainhash.Hash
+	MempoolCount uint64
+}
+
+// CzzDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetGrapheneBlock) CzzDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	return readElements(r, &msg.BlockHash, &msg.MempoolCount)
+}
+
+// CzzEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetGrapheneBlock) CzzEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	return writeElements(w, &msg.BlockHash, msg.MempoolCount)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetGrapheneBlock) Command() string {
+	return CmdGetGrapheneBlock
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetGrapheneBlock) MaxPayloadLength(pver uint32) uint32 {
+	// Block hash + mempool count.
+	return chainhash.HashSize + 8
+}
+
+// NewMsgGetGrapheneBlock returns a new getgrblk message that conforms to the
+// Message interface using the passed parameters.
+func NewMsgGetGrapheneBlock(blockHash *chainhash.Hash, mempoolCount uint64) *MsgGetGrapheneBlock {
+	return &MsgGetGrapheneBlock{
+		BlockHash:    *blockHash,
+		MempoolCount: mempoolCount,
+	}
+}
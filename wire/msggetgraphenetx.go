@@ -0,0 +1,86 @@
+package wire
+
+import (
+	"io"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+)
+
+// MsgGetGrapheneTx implements the Message interface and represents a
+// getgrblktx message.  It is used to request the block transactions a node
+// could not find in its mempool while reconstructing a graphene block.  The
+// transactions are identified by the short IDs recovered from the IBLT, which
+// were calculated using the nonce of the graphene block.
+type MsgGetGrapheneTx struct {
+	BlockHash chainhash.Hash
+	Nonce     uint64
+	ShortIDs  []uint64
+}
+
+// CzzDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetGrapheneTx) CzzDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+
+	if err := readElements(r, &msg.BlockHash, &msg.Nonce); err != nil {
+		return err
+	}
+
+	// Prevent a larger allocation than the message could ever carry.
+	count, err := readCount(r, pver, "MsgGetGrapheneTx.CzzDecode",
+		uint64(maxTxPerBlock()), 8, "short ids")
+	if err != nil {
+		return err
+	}
+
+	msg.ShortIDs = make([]uint64, count)
+	for i := range msg.ShortIDs {
+		if err := readElement(r, &msg.ShortIDs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CzzEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetGrapheneTx) CzzEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+
+	if err := writeElements(w, &msg.BlockHash, msg.Nonce); err != nil {
+		return err
+	}
+
+	if err := WriteVarInt(w, pver, uint64(len(msg.ShortIDs))); err != nil {
+		return err
+	}
+
+	for _, id := range msg.ShortIDs {
+		if err := writeElement(w, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetGrapheneTx) Command() string {
+	return CmdGetGrapheneTx
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetGrapheneTx) MaxPayloadLength(pver uint32) uint32 {
+	// Block hash + nonce + num short ids (varInt) + a short id for every
+	// transaction that could fit into a block.
+	return chainhash.HashSize + 8 + MaxVarIntPayload + maxTxPerBlock()*8
+}
+
+// NewMsgGetGrapheneTx returns a new getgrblktx message that conforms to the
+// Message interface using the passed parameters.
+func NewMsgGetGrapheneTx(blockHash *chainhash.Hash, nonce uint64, shortIDs []uint64) *MsgGetGrapheneTx {
+	return &MsgGetGrapheneTx{
+		BlockHash: *blockHash,
+		Nonce:     nonce,
+		ShortIDs:  shortIDs,
+	}
+}
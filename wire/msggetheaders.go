@@ -54,15 +54,12 @@ func (msg *MsgGetHeaders) CzzDecode(r io.Reader, pver uint32, enc MessageEncodin
 	}
 
 	// Read num block locator hashes and limit to max.
-	count, err := ReadVarInt(r, pver)
+	count, err := readCount(r, pver, "MsgGetHeaders.CzzDecode",
+		MaxBlockLocatorsPerMsg, chainhash.HashSize,
+		"block locator hashes")
 	if err != nil {
 		return err
 	}
-	if count > MaxBlockLocatorsPerMsg {
-		str := fmt.Sprintf("too many block locator hashes for message "+
-			"[count %v, max %v]", count, MaxBlockLocatorsPerMsg)
-		return messageError("MsgGetHeaders.CzzDecode", str)
-	}
 
 	// Create a contiguous slice of hashes to deserialize into in order to
 	// reduce the number of allocations.
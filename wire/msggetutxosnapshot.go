@@ -0,0 +1,55 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"io"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+)
+
+// MsgGetUtxoSnapshot implements the Message interface and represents a
+// getutxosnap message.  It is used to request a chunk of the serialized UTXO
+// set at a checkpoint from a peer which advertised it under
+// XVersionKeyUtxoSnapshot.  The chunks are UtxoSnapshotChunkSize bytes long,
+// except for the last one, and are numbered from zero.
+type MsgGetUtxoSnapshot struct {
+	BlockHash chainhash.Hash
+	Chunk     uint32
+}
+
+// CzzDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetUtxoSnapshot) CzzDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	return readElements(r, &msg.BlockHash, &msg.Chunk)
+}
+
+// CzzEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetUtxoSnapshot) CzzEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	return writeElements(w, &msg.BlockHash, msg.Chunk)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetUtxoSnapshot) Command() string {
+	return CmdGetUtxoSnapshot
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetUtxoSnapshot) MaxPayloadLength(pver uint32) uint32 {
+	// Block hash + chunk number.
+	return chainhash.HashSize + 4
+}
+
+// NewMsgGetUtxoSnapshot returns a new getutxosnap message that conforms to
+// the Message interface using the passed parameters.
+func NewMsgGetUtxoSnapshot(blockHash *chainhash.Hash, chunk uint32) *MsgGetUtxoSnapshot {
+	return &MsgGetUtxoSnapshot{
+		BlockHash: *blockHash,
+		Chunk:     chunk,
+	}
+}
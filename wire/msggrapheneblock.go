@@ -0,0 +1,190 @@
+package wire
+
+import (
+	"io"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+)
+
+// GrapheneProtocolVersion is the current version of the graphene block
+// propagation protocol.
+const GrapheneProtocolVersion = 1
+
+// IBLTCellSize is the number of bytes in a serialized IBLT cell.
+const IBLTCellSize = 4 + 8 + 4
+
+// IBLTCell is a single cell of an invertible bloom lookup table.  It holds
+// the number of keys inserted into the cell together with the xor of those
+// keys and the xor of their check hashes.
+type IBLTCell struct {
+	Count   int32
+	KeySum  uint64
+	HashSum uint32
+}
+
+// MsgGrapheneBlock implements the Message interface and represents a graphene
+// block message.  It is sent in response to a getgrblk message and describes
+// the block transactions as a bloom filter which the receiver runs over its
+// mempool along with an IBLT which is used to repair the difference between
+// the filtered mempool and the block.  Transactions in graphene blocks are
+// identified by the 64 bit siphash short ID of their hash keyed the same way
+// as the compact block short IDs.
+type MsgGrapheneBlock struct {
+	Header BlockHeader
+	Nonce  uint64
+
+	// TxCount is the total number of transactions in the block including
+	// the prefilled ones.
+	TxCount uint32
+
+	// Filter holds the bloom filter over the short IDs of every block
+	// transaction which is not prefilled.
+	FilterHashFuncs uint32
+	Filter          []byte
+
+	// IBLTHashFuncs is the number of hash functions, and therefore equally
+	// sized partitions of the cells, used by the IBLT.
+	IBLTHashFuncs uint32
+	IBLTCells     []IBLTCell
+
+	// PrefilledTxs are sent in full.  The coinbase is always the first
+	// prefilled transaction and may be followed by transactions the sender
+	// expects the receiver not to have.  Their position in the block
+	// follows from the canonical transaction order so no index is sent.
+	PrefilledTxs []*MsgTx
+}
+
+// CzzDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGrapheneBlock) CzzDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+
+	if err := readBlockHeader(r, pver, &msg.Header); err != nil {
+		return err
+	}
+
+	if err := readElements(r, &msg.Nonce, &msg.TxCount,
+		&msg.FilterHashFuncs); err != nil {
+		return err
+	}
+
+	filter, err := ReadVarBytes(r, pver, maxMessagePayload(),
+		"graphene filter")
+	if err != nil {
+		return err
+	}
+	msg.Filter = filter
+
+	if err := readElement(r, &msg.IBLTHashFuncs); err != nil {
+		return err
+	}
+
+	// Prevent a larger allocation than the message could ever carry.
+	cellCount, err := readCount(r, pver, "MsgGrapheneBlock.CzzDecode",
+		uint64(maxMessagePayload()/IBLTCellSize), IBLTCellSize,
+		"iblt cells")
+	if err != nil {
+		return err
+	}
+
+	msg.IBLTCells = make([]IBLTCell, cellCount)
+	for i := range msg.IBLTCells {
+		cell := &msg.IBLTCells[i]
+		err := readElements(r, &cell.Count, &cell.KeySum, &cell.HashSum)
+		if err != nil {
+			return err
+		}
+	}
+
+	prefilledTxCount, err := readCount(r, pver,
+		"MsgGrapheneBlock.CzzDecode", uint64(msg.TxCount), minTxPayload,
+		"prefilled transactions")
+	if err != nil {
+		return err
+	}
+
+	msg.PrefilledTxs = make([]*MsgTx, 0, prefilledTxCount)
+
+	for i := uint64(0); i < prefilledTxCount; i++ {
+		tx := MsgTx{}
+		if err := tx.CzzDecode(r, pver, enc); err != nil {
+			return err
+		}
+		msg.PrefilledTxs = append(msg.PrefilledTxs, &tx)
+	}
+	return nil
+}
+
+// CzzEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGrapheneBlock) CzzEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+
+	if err := writeBlockHeader(w, pver, &msg.Header); err != nil {
+		return err
+	}
+
+	if err := writeElements(w, msg.Nonce, msg.TxCount,
+		msg.FilterHashFuncs); err != nil {
+		return err
+	}
+
+	if err := WriteVarBytes(w, pver, msg.Filter); err != nil {
+		return err
+	}
+
+	if err := writeElement(w, msg.IBLTHashFuncs); err != nil {
+		return err
+	}
+
+	if err := WriteVarInt(w, pver, uint64(len(msg.IBLTCells))); err != nil {
+		return err
+	}
+
+	for _, cell := range msg.IBLTCells {
+		err := writeElements(w, cell.Count, cell.KeySum, cell.HashSum)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := WriteVarInt(w, pver, uint64(len(msg.PrefilledTxs))); err != nil {
+		return err
+	}
+
+	for _, tx := range msg.PrefilledTxs {
+		if err := tx.CzzEncode(w, pver, enc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGrapheneBlock) Command() string {
+	return CmdGrapheneBlock
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGrapheneBlock) MaxPayloadLength(pver uint32) uint32 {
+	// This can take up to the max payload. The derived block
+	// cannot be larger than the excessive block size.
+	return maxMessagePayload()
+}
+
+// BlockHash computes the block identifier hash for this block.
+func (msg *MsgGrapheneBlock) BlockHash() chainhash.Hash {
+	return msg.Header.BlockHash()
+}
+
+// NewMsgGrapheneBlock returns a new graphene block message that conforms to
+// the Message interface using the passed parameters and defaults for the
+// remaining fields.
+func NewMsgGrapheneBlock(blockHeader *BlockHeader, nonce uint64) *MsgGrapheneBlock {
+	return &MsgGrapheneBlock{
+		Header:    *blockHeader,
+		Nonce:     nonce,
+		Filter:    make([]byte, 0),
+		IBLTCells: make([]IBLTCell, 0),
+	}
+}
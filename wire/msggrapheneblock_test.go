@@ -0,0 +1,125 @@
+package wire
+
+import (
+	"bytes"
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+	"github.com/davecgh/go-spew/spew"
+	"reflect"
+	"testing"
+)
+
+// TestGrapheneBlockWire tests the MsgGrapheneBlock wire encode and decode
+// round trip with all fields populated.
+func TestGrapheneBlockWire(t *testing.T) {
+	pver := ProtocolVersion
+	enc := BaseEncoding
+
+	msg := NewMsgGrapheneBlock(&blockOne.Header, 0x0102030405060708)
+	msg.TxCount = 3
+	msg.FilterHashFuncs = 4
+	msg.Filter = []byte{0x01, 0x02, 0x03}
+	msg.IBLTHashFuncs = 2
+	msg.IBLTCells = []IBLTCell{
+		{Count: 1, KeySum: 0xffeeddccbbaa9988, HashSum: 0x11223344},
+		{Count: -1, KeySum: 0x01, HashSum: 0x02},
+	}
+	msg.PrefilledTxs = []*MsgTx{blockOne.Transactions[0]}
+
+	if cmd := msg.Command(); cmd != "grblk" {
+		t.Errorf("NewMsgGrapheneBlock: wrong command - got %v want %v",
+			cmd, "grblk")
+	}
+
+	var buf bytes.Buffer
+	if err := msg.CzzEncode(&buf, pver, enc); err != nil {
+		t.Fatalf("CzzEncode: unexpected error %v", err)
+	}
+
+	var readmsg MsgGrapheneBlock
+	if err := readmsg.CzzDecode(&buf, pver, enc); err != nil {
+		t.Fatalf("CzzDecode: unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(&readmsg, msg) {
+		t.Errorf("CzzDecode: mismatched message - got %v, want %v",
+			spew.Sdump(&readmsg), spew.Sdump(msg))
+	}
+
+	// A message claiming more prefilled transactions than the block
+	// holds must be rejected.
+	msg.TxCount = 0
+	buf.Reset()
+	if err := msg.CzzEncode(&buf, pver, enc); err != nil {
+		t.Fatalf("CzzEncode: unexpected error %v", err)
+	}
+	readmsg = MsgGrapheneBlock{}
+	err := readmsg.CzzDecode(&buf, pver, enc)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("CzzDecode: got error %v, want MessageError", err)
+	}
+}
+
+// TestGrapheneTxWire tests the wire encode and decode round trip of the
+// getgrblk, getgrblktx and grblktx messages.
+func TestGrapheneTxWire(t *testing.T) {
+	pver := ProtocolVersion
+	enc := BaseEncoding
+	hash := blockOne.BlockHash()
+
+	tests := []struct {
+		in  Message
+		out Message
+		cmd string
+	}{
+		{
+			NewMsgGetGrapheneBlock(&hash, 5000),
+			&MsgGetGrapheneBlock{},
+			"getgrblk",
+		},
+		{
+			NewMsgGetGrapheneTx(&hash, 99, []uint64{1, 1 << 63}),
+			&MsgGetGrapheneTx{},
+			"getgrblktx",
+		},
+		{
+			NewMsgGrapheneTx(hash, []*MsgTx{blockOne.Transactions[0]}),
+			&MsgGrapheneTx{},
+			"grblktx",
+		},
+	}
+
+	for i, test := range tests {
+		if cmd := test.in.Command(); cmd != test.cmd {
+			t.Errorf("Command #%d: got %v want %v", i, cmd, test.cmd)
+		}
+
+		var buf bytes.Buffer
+		if err := test.in.CzzEncode(&buf, pver, enc); err != nil {
+			t.Errorf("CzzEncode #%d: unexpected error %v", i, err)
+			continue
+		}
+		if uint32(buf.Len()) > test.in.MaxPayloadLength(pver) {
+			t.Errorf("CzzEncode #%d: payload %d exceeds max %d", i,
+				buf.Len(), test.in.MaxPayloadLength(pver))
+		}
+		if err := test.out.CzzDecode(&buf, pver, enc); err != nil {
+			t.Errorf("CzzDecode #%d: unexpected error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.out, test.in) {
+			t.Errorf("CzzDecode #%d: got %v want %v", i,
+				spew.Sdump(test.out), spew.Sdump(test.in))
+		}
+	}
+
+	// An empty short ID list round trips.
+	var readmsg MsgGetGrapheneTx
+	var buf bytes.Buffer
+	NewMsgGetGrapheneTx(&chainhash.Hash{}, 0, nil).CzzEncode(&buf, pver, enc)
+	if err := readmsg.CzzDecode(&buf, pver, enc); err != nil {
+		t.Fatalf("CzzDecode: unexpected error %v", err)
+	}
+	if len(readmsg.ShortIDs) != 0 {
+		t.Errorf("CzzDecode: got %d short ids, want 0",
+			len(readmsg.ShortIDs))
+	}
+}
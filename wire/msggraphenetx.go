@@ -0,0 +1,83 @@
+package wire
+
+import (
+	"io"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+)
+
+// MsgGrapheneTx implements the Message interface and represents a grblktx
+// message.  It is sent in response to the getgrblktx message and carries the
+// requested transactions in the order they were requested.
+type MsgGrapheneTx struct {
+	BlockHash chainhash.Hash
+	Txs       []*MsgTx
+}
+
+// CzzDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGrapheneTx) CzzDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+
+	if err := readElement(r, &msg.BlockHash); err != nil {
+		return err
+	}
+
+	txCount, err := readCount(r, pver, "MsgGrapheneTx.CzzDecode",
+		uint64(maxTxPerBlock()), minTxPayload, "transactions")
+	if err != nil {
+		return err
+	}
+
+	msg.Txs = make([]*MsgTx, 0, txCount)
+	for i := uint64(0); i < txCount; i++ {
+		tx := MsgTx{}
+		if err := tx.CzzDecode(r, pver, enc); err != nil {
+			return err
+		}
+		msg.Txs = append(msg.Txs, &tx)
+	}
+	return nil
+}
+
+// CzzEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGrapheneTx) CzzEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+
+	if err := writeElement(w, &msg.BlockHash); err != nil {
+		return err
+	}
+
+	if err := WriteVarInt(w, pver, uint64(len(msg.Txs))); err != nil {
+		return err
+	}
+
+	for _, tx := range msg.Txs {
+		if err := tx.CzzEncode(w, pver, enc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGrapheneTx) Command() string {
+	return CmdGrapheneTx
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGrapheneTx) MaxPayloadLength(pver uint32) uint32 {
+	// The transactions are a subset of a block and the block hash is no
+	// larger than the header it replaces.
+	return MaxBlockPayload()
+}
+
+// NewMsgGrapheneTx returns a new grblktx message that conforms to the
+// Message interface using the passed parameters.
+func NewMsgGrapheneTx(blockHash chainhash.Hash, txs []*MsgTx) *MsgGrapheneTx {
+	return &MsgGrapheneTx{
+		BlockHash: blockHash,
+		Txs:       txs,
+	}
+}
@@ -37,18 +37,13 @@ func (msg *MsgHeaders) AddBlockHeader(bh *BlockHeader) error {
 // CzzDecode decodes r using the bitcoin protocol encoding into the receiver.
 // This is part of the Message interface implementation.
 func (msg *MsgHeaders) CzzDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
-	count, err := ReadVarInt(r, pver)
+	// Limit to max block headers per message.
+	count, err := readCount(r, pver, "MsgHeaders.CzzDecode",
+		MaxBlockHeadersPerMsg, BlockHeaderSize+1, "block headers")
 	if err != nil {
 		return err
 	}
 
-	// Limit to max block headers per message.
-	if count > MaxBlockHeadersPerMsg {
-		str := fmt.Sprintf("too many block headers for message "+
-			"[count %v, max %v]", count, MaxBlockHeadersPerMsg)
-		return messageError("MsgHeaders.CzzDecode", str)
-	}
-
 	// Create a contiguous slice of headers to deserialize into in order to
 	// reduce the number of allocations.
 	headers := make([]BlockHeader, count)
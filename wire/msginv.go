@@ -46,17 +46,13 @@ func (msg *MsgInv) AddInvVect(iv *InvVect) error {
 // CzzDecode decodes r using the bitcoin protocol encoding into the receiver.
 // This is part of the Message interface implementation.
 func (msg *MsgInv) CzzDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
-	count, err := ReadVarInt(r, pver)
+	// Limit to max inventory vectors per message.
+	count, err := readCount(r, pver, "MsgInv.CzzDecode", MaxInvPerMsg,
+		maxInvVectPayload, "inventory vectors")
 	if err != nil {
 		return err
 	}
 
-	// Limit to max inventory vectors per message.
-	if count > MaxInvPerMsg {
-		str := fmt.Sprintf("too many invvect in message [%v]", count)
-		return messageError("MsgInv.CzzDecode", str)
-	}
-
 	// Create a contiguous slice of inventory vectors to deserialize into in
 	// order to reduce the number of allocations.
 	invList := make([]InvVect, count)
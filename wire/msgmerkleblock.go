@@ -55,15 +55,12 @@ func (msg *MsgMerkleBlock) CzzDecode(r io.Reader, pver uint32, enc MessageEncodi
 	}
 
 	// Read num block locator hashes and limit to max.
-	count, err := ReadVarInt(r, pver)
+	count, err := readCount(r, pver, "MsgMerkleBlock.CzzDecode",
+		uint64(maxTxPerBlock()), chainhash.HashSize,
+		"transaction hashes")
 	if err != nil {
 		return err
 	}
-	if count > uint64(maxTxPerBlock()) {
-		str := fmt.Sprintf("too many transaction hashes for message "+
-			"[count %v, max %v]", count, maxTxPerBlock())
-		return messageError("MsgMerkleBlock.CzzDecode", str)
-	}
 
 	// Create a contiguous slice of hashes to deserialize into in order to
 	// reduce the number of allocations.
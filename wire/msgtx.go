@@ -5,7 +5,6 @@
 package wire
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"strconv"
@@ -281,9 +280,10 @@ func (msg *MsgTx) TxHash() chainhash.Hash {
 	// Ignore the error returns since the only way the encode could fail
 	// is being out of memory or due to nil pointers, both of which would
 	// cause a run-time panic.
-	buf := bytes.NewBuffer(make([]byte, 0, msg.SerializeSize()))
-	_ = msg.Serialize(buf)
-	return chainhash.DoubleHashH(buf.Bytes())
+	buf := msg.appendTx(serializePool.Borrow(msg.SerializeSize()))
+	hash := chainhash.DoubleHashH(buf)
+	serializePool.Return(buf)
+	return hash
 }
 
 // Copy creates a deep copy of a transaction so that the original does not get
@@ -360,18 +360,13 @@ func (msg *MsgTx) CzzDecode(r io.Reader, pver uint32, enc MessageEncoding) error
 	}
 	msg.Version = int32(version)
 
-	count, err := ReadVarInt(r, pver)
-	if err != nil {
-		return err
-	}
-
 	// Prevent more input transactions than could possibly fit into a
 	// message.  It would be possible to cause memory exhaustion and panics
 	// without a sane upper bound on this count.
-	if count > uint64(maxTxInPerMessage()) {
-		str := fmt.Sprintf("too many input transactions to fit into "+
-			"max message size [count %d, max %d]", count, maxTxInPerMessage())
-		return messageError("MsgTx.CzzDecode", str)
+	count, err := readCount(r, pver, "MsgTx.CzzDecode",
+		uint64(maxTxInPerMessage()), minTxInPayload, "input transactions")
+	if err != nil {
+		return err
 	}
 
 	// returnScriptBuffers is a closure that returns any script buffers that
@@ -414,20 +409,15 @@ func (msg *MsgTx) CzzDecode(r io.Reader, pver uint32, enc MessageEncoding) error
 		totalScriptSize += uint64(len(ti.SignatureScript))
 	}
 
-	count, err = ReadVarInt(r, pver)
-	if err != nil {
-		returnScriptBuffers()
-		return err
-	}
-
 	// Prevent more output transactions than could possibly fit into a
 	// message.  It would be possible to cause memory exhaustion and panics
 	// without a sane upper bound on this count.
-	if count > uint64(maxTxOutPerMessage()) {
+	count, err = readCount(r, pver, "MsgTx.CzzDecode",
+		uint64(maxTxOutPerMessage()), MinTxOutPayload,
+		"output transactions")
+	if err != nil {
 		returnScriptBuffers()
-		str := fmt.Sprintf("too many output transactions to fit into "+
-			"max message size [count %d, max %d]", count, maxTxOutPerMessage())
-		return messageError("MsgTx.CzzDecode", str)
+		return err
 	}
 
 	// Deserialize the outputs.
@@ -526,38 +516,35 @@ func (msg *MsgTx) Deserialize(r io.Reader) error {
 // See Serialize for encoding transactions to be stored to disk, such as in a
 // database, as opposed to encoding transactions for the wire.
 func (msg *MsgTx) CzzEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
-	err := binarySerializer.PutUint32(w, littleEndian, uint32(msg.Version))
-	if err != nil {
-		return err
-	}
+	// Serialize the whole transaction into a pooled buffer so it is
+	// written with a single call instead of one per field.
+	buf := msg.appendTx(serializePool.Borrow(msg.SerializeSize()))
+	_, err := w.Write(buf)
+	serializePool.Return(buf)
+	return err
+}
 
-	count := uint64(len(msg.TxIn))
-	err = WriteVarInt(w, pver, count)
-	if err != nil {
-		return err
-	}
+// appendTx appends the bitcoin protocol encoding of the transaction to b.  It
+// produces the same encoding as writing each field with writeTxIn and
+// WriteTxOut.
+func (msg *MsgTx) appendTx(b []byte) []byte {
+	b = appendUint32(b, uint32(msg.Version))
 
+	b = appendVarInt(b, uint64(len(msg.TxIn)))
 	for _, ti := range msg.TxIn {
-		err = writeTxIn(w, pver, msg.Version, ti)
-		if err != nil {
-			return err
-		}
-	}
-
-	count = uint64(len(msg.TxOut))
-	err = WriteVarInt(w, pver, count)
-	if err != nil {
-		return err
+		b = append(b, ti.PreviousOutPoint.Hash[:]...)
+		b = appendUint32(b, ti.PreviousOutPoint.Index)
+		b = appendVarBytes(b, ti.SignatureScript)
+		b = appendUint32(b, ti.Sequence)
 	}
 
+	b = appendVarInt(b, uint64(len(msg.TxOut)))
 	for _, to := range msg.TxOut {
-		err = WriteTxOut(w, pver, msg.Version, to)
-		if err != nil {
-			return err
-		}
+		b = appendUint64(b, uint64(to.Value))
+		b = appendVarBytes(b, to.PkScript)
 	}
 
-	return binarySerializer.PutUint32(w, littleEndian, msg.LockTime)
+	return appendUint32(b, msg.LockTime)
 }
 
 // Serialize encodes the transaction to w using a format that suitable for
@@ -706,7 +693,11 @@ func readScript(r io.Reader, pver uint32, maxAllowed uint32, fieldName string) (
 	if count > uint64(maxAllowed) {
 		str := fmt.Sprintf("%s is larger than the max allowed size "+
 			"[count %d, max %d]", fieldName, count, maxAllowed)
-		return nil, messageError("readScript", str)
+		return nil, messageErrorCode("readScript", ErrFieldTooLarge, str)
+	}
+	err = checkRemaining(r, "readScript", count, 1, fieldName)
+	if err != nil {
+		return nil, err
 	}
 
 	b := scriptPool.Borrow(count)
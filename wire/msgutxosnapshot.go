@@ -0,0 +1,91 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/bourbaki-czz/classzz/chaincfg/chainhash"
+)
+
+// UtxoSnapshotChunkSize is the size of the chunks a UTXO set snapshot is
+// served in.  Only the last chunk of a snapshot may be shorter.
+const UtxoSnapshotChunkSize = 1 << 20
+
+// MsgUtxoSnapshot implements the Message interface and represents a utxosnap
+// message.  It is sent in response to a getutxosnap message and carries the
+// requested chunk of the serialized UTXO set at the checkpoint block, along
+// with the ECMH hash and the total size of the set so the receiver can check
+// them against its own checkpoint before downloading the whole set.
+//
+// A zero size and no data mean the sender does not serve the requested chunk.
+type MsgUtxoSnapshot struct {
+	BlockHash   chainhash.Hash
+	UtxoSetHash chainhash.Hash
+	Size        uint64
+	Chunk       uint32
+	Data        []byte
+}
+
+// CzzDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgUtxoSnapshot) CzzDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	err := readElements(r, &msg.BlockHash, &msg.UtxoSetHash, &msg.Size,
+		&msg.Chunk)
+	if err != nil {
+		return err
+	}
+
+	msg.Data, err = ReadVarBytes(r, pver, UtxoSnapshotChunkSize,
+		"utxo snapshot chunk")
+	return err
+}
+
+// CzzEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgUtxoSnapshot) CzzEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if len(msg.Data) > UtxoSnapshotChunkSize {
+		str := fmt.Sprintf("utxo snapshot chunk is too large [size %v, "+
+			"max %v]", len(msg.Data), UtxoSnapshotChunkSize)
+		return messageError("MsgUtxoSnapshot.CzzEncode", str)
+	}
+
+	err := writeElements(w, &msg.BlockHash, &msg.UtxoSetHash, msg.Size,
+		msg.Chunk)
+	if err != nil {
+		return err
+	}
+	return WriteVarBytes(w, pver, msg.Data)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgUtxoSnapshot) Command() string {
+	return CmdUtxoSnapshot
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgUtxoSnapshot) MaxPayloadLength(pver uint32) uint32 {
+	// Block hash + UTXO set hash + size + chunk number + data length
+	// (varInt) + a full chunk.
+	return chainhash.HashSize*2 + 8 + 4 + MaxVarIntPayload +
+		UtxoSnapshotChunkSize
+}
+
+// NewMsgUtxoSnapshot returns a new utxosnap message that conforms to the
+// Message interface using the passed parameters.
+func NewMsgUtxoSnapshot(blockHash, utxoSetHash *chainhash.Hash, size uint64,
+	chunk uint32, data []byte) *MsgUtxoSnapshot {
+
+	return &MsgUtxoSnapshot{
+		BlockHash:   *blockHash,
+		UtxoSetHash: *utxoSetHash,
+		Size:        size,
+		Chunk:       chunk,
+		Data:        data,
+	}
+}
@@ -0,0 +1,84 @@
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestUtxoSnapshotWire tests the wire encode and decode round trip of the
+// getutxosnap and utxosnap messages.
+func TestUtxoSnapshotWire(t *testing.T) {
+	pver := ProtocolVersion
+	enc := BaseEncoding
+	hash := blockOne.BlockHash()
+	utxoSetHash := blockOne.Header.MerkleRoot
+
+	tests := []struct {
+		in  Message
+		out Message
+		cmd string
+	}{
+		{
+			NewMsgGetUtxoSnapshot(&hash, 7),
+			&MsgGetUtxoSnapshot{},
+			"getutxosnap",
+		},
+		{
+			NewMsgUtxoSnapshot(&hash, &utxoSetHash, 3*UtxoSnapshotChunkSize,
+				2, bytes.Repeat([]byte{0xa5}, UtxoSnapshotChunkSize)),
+			&MsgUtxoSnapshot{},
+			"utxosnap",
+		},
+		{
+			// A peer not serving the requested chunk.
+			NewMsgUtxoSnapshot(&hash, &utxoSetHash, 0, 2, []byte{}),
+			&MsgUtxoSnapshot{},
+			"utxosnap",
+		},
+	}
+
+	for i, test := range tests {
+		if cmd := test.in.Command(); cmd != test.cmd {
+			t.Errorf("Command #%d: got %v want %v", i, cmd, test.cmd)
+		}
+
+		var buf bytes.Buffer
+		if err := test.in.CzzEncode(&buf, pver, enc); err != nil {
+			t.Errorf("CzzEncode #%d: unexpected error %v", i, err)
+			continue
+		}
+		if uint32(buf.Len()) > test.in.MaxPayloadLength(pver) {
+			t.Errorf("CzzEncode #%d: payload %d exceeds max %d", i,
+				buf.Len(), test.in.MaxPayloadLength(pver))
+		}
+		if err := test.out.CzzDecode(&buf, pver, enc); err != nil {
+			t.Errorf("CzzDecode #%d: unexpected error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.out, test.in) {
+			t.Errorf("CzzDecode #%d: got %v want %v", i,
+				spew.Sdump(test.out), spew.Sdump(test.in))
+		}
+	}
+
+	// A chunk larger than the chunk size must be rejected both ways.
+	msg := NewMsgUtxoSnapshot(&hash, &utxoSetHash, 0, 0,
+		make([]byte, UtxoSnapshotChunkSize+1))
+	var buf bytes.Buffer
+	err := msg.CzzEncode(&buf, pver, enc)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("CzzEncode: got error %v, want MessageError", err)
+	}
+	buf.Reset()
+	writeElements(&buf, &msg.BlockHash, &msg.UtxoSetHash, msg.Size,
+		msg.Chunk)
+	WriteVarBytes(&buf, pver, msg.Data)
+	var readmsg MsgUtxoSnapshot
+	err = readmsg.CzzDecode(&buf, pver, enc)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("CzzDecode: got error %v, want MessageError", err)
+	}
+}
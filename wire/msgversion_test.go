@@ -305,10 +305,11 @@ func TestVersionWireErrors(t *testing.T) {
 		{baseVersion, baseVersionEncoded, pver, BaseEncoding, 47, io.ErrShortWrite, io.ErrUnexpectedEOF},
 		// Force error in nonce.
 		{baseVersion, baseVersionEncoded, pver, BaseEncoding, 73, io.ErrShortWrite, io.ErrUnexpectedEOF},
-		// Force error in user agent length.
-		{baseVersion, baseVersionEncoded, pver, BaseEncoding, 81, io.ErrShortWrite, io.EOF},
+		// Force error in user agent length.  The length is read, but
+		// the user agent it declares is longer than what remains.
+		{baseVersion, baseVersionEncoded, pver, BaseEncoding, 81, io.ErrShortWrite, wireErr},
 		// Force error in user agent.
-		{baseVersion, baseVersionEncoded, pver, BaseEncoding, 82, io.ErrShortWrite, io.ErrUnexpectedEOF},
+		{baseVersion, baseVersionEncoded, pver, BaseEncoding, 82, io.ErrShortWrite, wireErr},
 		// Force error in last block.
 		{baseVersion, baseVersionEncoded, pver, BaseEncoding, 98, io.ErrShortWrite, io.ErrUnexpectedEOF},
 		// Force error in relay tx - no read error should happen since
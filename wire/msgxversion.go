@@ -5,24 +5,123 @@
 package wire
 
 import (
+	"bytes"
+	"fmt"
 	"io"
+	"sort"
 )
 
+// Keys of the capabilities exchanged in xversion messages.  Values of numeric
+// capabilities are serialized as a variable length integer.
+const (
+	// XVersionKeyBlockRelay is the bit field of the block relay protocols
+	// supported by the sender, see the XVersionRelay flags.
+	XVersionKeyBlockRelay uint64 = 0x00010000
+
+	// XVersionKeyEntangleProof is the version of entangle proof checking
+	// supported by the sender, see EntangleProofVersion.  Zero or a
+	// missing key means the sender can not check entangle transactions
+	// against the foreign chains itself.
+	XVersionKeyEntangleProof uint64 = 0x00010001
+
+	// XVersionKeyMinFeeRate is the minimum fee rate, in satoshi per
+	// kilobyte, of the transactions the sender wants inventoried to it.
+	XVersionKeyMinFeeRate uint64 = 0x00010002
+
+	// XVersionKeyUtxoSnapshot is the height of the checkpoint at which the
+	// sender serves the UTXO set snapshot with getutxosnap messages.  Zero
+	// or a missing key means the sender does not serve a snapshot.
+	XVersionKeyUtxoSnapshot uint64 = 0x00010003
+)
+
+// Block relay protocols advertised under XVersionKeyBlockRelay.
+const (
+	// XVersionRelayCompactBlocks indicates compact block (BIP152) support.
+	XVersionRelayCompactBlocks uint64 = 1 << iota
+
+	// XVersionRelayGraphene indicates graphene block relay support.
+	XVersionRelayGraphene
+)
+
+// EntangleProofVersion is the current version of entangle proof checking
+// advertised under XVersionKeyEntangleProof.
+const EntangleProofVersion = 1
+
+// maxXVersionEntries is the maximum number of capabilities allowed in an
+// xversion message.  Every entry takes at least two bytes.
+const maxXVersionEntries = 100000 / 2
+
 // MsgXVersion implements the Message interface and represents a bitcoin xversion
-// message. This is a stub used to support BU clients.
+// message.  It is sent after the version handshake and carries a set of key
+// value capabilities so new protocol features can be negotiated without
+// bumping the protocol version.  Unknown keys must be ignored.
 type MsgXVersion struct {
+	XVersionMap map[uint64][]byte
 }
 
 // CzzDecode decodes r using the bitcoin protocol encoding into the receiver.
 //
 // This is part of the Message interface implementation.
 func (msg *MsgXVersion) CzzDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	msg.XVersionMap = make(map[uint64][]byte)
+
+	// Early xversion messages were empty stubs so treat a missing entry
+	// count as no capabilities.
+	count, err := ReadVarInt(r, pver)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if count > maxXVersionEntries {
+		str := fmt.Sprintf("too many xversion entries for message "+
+			"[count %v, max %v]", count, maxXVersionEntries)
+		return messageErrorCode("MsgXVersion.CzzDecode",
+			ErrTooManyItems, str)
+	}
+	err = checkRemaining(r, "MsgXVersion.CzzDecode", count, 2,
+		"xversion entries")
+	if err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < count; i++ {
+		key, err := ReadVarInt(r, pver)
+		if err != nil {
+			return err
+		}
+		value, err := ReadVarBytes(r, pver, msg.MaxPayloadLength(pver),
+			"xversion value")
+		if err != nil {
+			return err
+		}
+		msg.XVersionMap[key] = value
+	}
 	return nil
 }
 
 // CzzEncode encodes the receiver to w using the bitcoin protocol encoding.
+// The entries are written in ascending key order.
 // This is part of the Message interface implementation.
 func (msg *MsgXVersion) CzzEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	keys := make([]uint64, 0, len(msg.XVersionMap))
+	for key := range msg.XVersionMap {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	if err := WriteVarInt(w, pver, uint64(len(keys))); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := WriteVarInt(w, pver, key); err != nil {
+			return err
+		}
+		if err := WriteVarBytes(w, pver, msg.XVersionMap[key]); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -40,9 +139,34 @@ func (msg *MsgXVersion) MaxPayloadLength(pver uint32) uint32 {
 	return 100000
 }
 
+// SetUint64 sets the capability with the passed key to a numeric value.
+func (msg *MsgXVersion) SetUint64(key, value uint64) {
+	var buf bytes.Buffer
+	WriteVarInt(&buf, 0, value)
+	msg.XVersionMap[key] = buf.Bytes()
+}
+
+// Uint64 returns the numeric value of the capability with the passed key.
+// False is returned if the key is missing or its value is not a valid
+// variable length integer.
+func (msg *MsgXVersion) Uint64(key uint64) (uint64, bool) {
+	value, ok := msg.XVersionMap[key]
+	if !ok {
+		return 0, false
+	}
+	r := bytes.NewReader(value)
+	n, err := ReadVarInt(r, 0)
+	if err != nil || r.Len() != 0 {
+		return 0, false
+	}
+	return n, true
+}
+
 // NewMsgXVersion returns a new bitcoin xversion message that conforms to the
 // Message interface using the passed parameters and defaults for the remaining
 // fields.
 func NewMsgXVersion() *MsgXVersion {
-	return &MsgXVersion{}
+	return &MsgXVersion{
+		XVersionMap: make(map[uint64][]byte),
+	}
 }
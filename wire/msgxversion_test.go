@@ -38,7 +38,9 @@ func TestXVersion(t *testing.T) {
 // protocol versions.
 func TestXVersionWire(t *testing.T) {
 	msgXVersion := NewMsgXVersion()
-	msgXVersionEncoded := []byte{}
+	msgXVersionEncoded := []byte{
+		0x00, // Varint for number of entries
+	}
 
 	tests := []struct {
 		in   *MsgXVersion    // Message to encode
@@ -123,3 +125,61 @@ func TestXVersionWire(t *testing.T) {
 		}
 	}
 }
+
+// TestXVersionCapabilities tests encoding, decoding and accessing the
+// capabilities carried by MsgXVersion.
+func TestXVersionCapabilities(t *testing.T) {
+	pver := ProtocolVersion
+	enc := BaseEncoding
+
+	msg := NewMsgXVersion()
+	msg.SetUint64(XVersionKeyMinFeeRate, 1000)
+	msg.SetUint64(XVersionKeyBlockRelay, XVersionRelayCompactBlocks)
+	msg.XVersionMap[0xfd] = []byte{0x01, 0x02}
+
+	want := []byte{
+		0x03,             // Varint for number of entries
+		0xfd, 0xfd, 0x00, // Key 0xfd
+		0x02, 0x01, 0x02, // Value
+		0xfe, 0x00, 0x00, 0x01, 0x00, // XVersionKeyBlockRelay
+		0x01, 0x01, // Value 1
+		0xfe, 0x02, 0x00, 0x01, 0x00, // XVersionKeyMinFeeRate
+		0x03, 0xfd, 0xe8, 0x03, // Value 1000
+	}
+	var buf bytes.Buffer
+	if err := msg.CzzEncode(&buf, pver, enc); err != nil {
+		t.Fatalf("CzzEncode: unexpected error %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("CzzEncode: got %x, want %x", buf.Bytes(), want)
+	}
+
+	var readmsg MsgXVersion
+	if err := readmsg.CzzDecode(bytes.NewReader(want), pver, enc); err != nil {
+		t.Fatalf("CzzDecode: unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(&readmsg, msg) {
+		t.Fatalf("CzzDecode: got %s want %s", spew.Sdump(readmsg),
+			spew.Sdump(msg))
+	}
+	if rate, ok := readmsg.Uint64(XVersionKeyMinFeeRate); !ok || rate != 1000 {
+		t.Errorf("Uint64: got %d (%v), want 1000", rate, ok)
+	}
+	if _, ok := readmsg.Uint64(XVersionKeyEntangleProof); ok {
+		t.Errorf("Uint64: found missing key")
+	}
+	if _, ok := readmsg.Uint64(0xfd); ok {
+		t.Errorf("Uint64: decoded value with trailing bytes")
+	}
+
+	// The empty payload of early stub messages decodes to no
+	// capabilities.
+	readmsg = MsgXVersion{}
+	if err := readmsg.CzzDecode(bytes.NewReader(nil), pver, enc); err != nil {
+		t.Fatalf("CzzDecode: unexpected error %v", err)
+	}
+	if len(readmsg.XVersionMap) != 0 {
+		t.Errorf("CzzDecode: got %d entries, want 0",
+			len(readmsg.XVersionMap))
+	}
+}
@@ -36,6 +36,13 @@ type NetAddress struct {
 	// Port the peer is using.  This is encoded in big endian on the wire
 	// which differs from most everything else.
 	Port uint16
+
+	// Network and Addr hold the address of peers on networks that can not
+	// be expressed as an IP, such as Tor v3 and I2P.  They are only carried
+	// by the addrv2 message and are unset for IPv4, IPv6 and Tor v2
+	// addresses, which are held in IP.
+	Network NetworkID
+	Addr    []byte
 }
 
 // HasService returns whether the specified service is supported by the address.
@@ -49,6 +56,12 @@ func (na *NetAddress) AddService(service ServiceFlag) {
 	na.Services |= service
 }
 
+// AddrV1Compatible returns whether the address can be carried by the legacy
+// addr and version messages.
+func (na *NetAddress) AddrV1Compatible() bool {
+	return len(na.Addr) == 0
+}
+
 // NewNetAddressIPPort returns a new NetAddress using the provided IP, port, and
 // supported services with defaults for the remaining fields.
 func NewNetAddressIPPort(ip net.IP, port uint16, services ServiceFlag) *NetAddress {
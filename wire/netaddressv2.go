@@ -0,0 +1,225 @@
+// Copyright (c) 2019 The classzz developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// NetworkID identifies the network of an address as defined by BIP0155.
+type NetworkID uint8
+
+// These constants define the networks an address carried by the addrv2
+// message may belong to.
+const (
+	NetworkIPv4  NetworkID = 1
+	NetworkIPv6  NetworkID = 2
+	NetworkTorV2 NetworkID = 3
+	NetworkTorV3 NetworkID = 4
+	NetworkI2P   NetworkID = 5
+	NetworkCJDNS NetworkID = 6
+)
+
+// Map of network IDs back to their constant names for pretty printing.
+var networkIDStrings = map[NetworkID]string{
+	NetworkIPv4:  "IPv4",
+	NetworkIPv6:  "IPv6",
+	NetworkTorV2: "TorV2",
+	NetworkTorV3: "TorV3",
+	NetworkI2P:   "I2P",
+	NetworkCJDNS: "CJDNS",
+}
+
+// String returns the NetworkID in human-readable form.
+func (id NetworkID) String() string {
+	if s, ok := networkIDStrings[id]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown NetworkID (%d)", uint8(id))
+}
+
+const (
+	// MaxAddrV2Size is the maximum size of the raw address carried by an
+	// addrv2 entry.  Entries for unknown networks may use any size up to
+	// this limit.
+	MaxAddrV2Size = 512
+
+	// TorV2AddrSize, TorV3AddrSize and I2PAddrSize are the sizes of the raw
+	// Tor v2, Tor v3 and I2P addresses.  Tor v3 addresses are the ed25519
+	// public key of the service and I2P addresses the SHA256 of the
+	// destination.
+	TorV2AddrSize = 10
+	TorV3AddrSize = 32
+	I2PAddrSize   = 32
+)
+
+// networkAddrSizes maps the known networks to the required size of their raw
+// addresses.
+var networkAddrSizes = map[NetworkID]int{
+	NetworkIPv4:  net.IPv4len,
+	NetworkIPv6:  net.IPv6len,
+	NetworkTorV2: TorV2AddrSize,
+	NetworkTorV3: TorV3AddrSize,
+	NetworkI2P:   I2PAddrSize,
+	NetworkCJDNS: net.IPv6len,
+}
+
+// onionCatPrefix is the IPv6 prefix used to carry Tor v2 addresses in the
+// legacy addr message.
+var onionCatPrefix = []byte{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
+
+// minNetAddressV2Payload is the minimum payload size for an addrv2 entry.
+// Timestamp 4 bytes + services varint 1 byte + network 1 byte + addr varint 1
+// byte + port 2 bytes.
+const minNetAddressV2Payload = 9
+
+// maxNetAddressV2Payload returns the max payload size for an addrv2 entry.
+func maxNetAddressV2Payload() uint32 {
+	// Timestamp 4 bytes + services varint + network 1 byte + addr varint
+	// + max addr size + port 2 bytes.
+	return 4 + MaxVarIntPayload + 1 + MaxVarIntPayload + MaxAddrV2Size + 2
+}
+
+// NetworkID returns the BIP0155 network the address belongs to.
+func (na *NetAddress) NetworkID() NetworkID {
+	if !na.AddrV1Compatible() {
+		return na.Network
+	}
+	if na.IP.To4() != nil {
+		return NetworkIPv4
+	}
+	if len(na.IP) == net.IPv6len && net.IP(onionCatPrefix).Equal(
+		na.IP[:len(onionCatPrefix)]) {
+
+		return NetworkTorV2
+	}
+	return NetworkIPv6
+}
+
+// NewNetAddressV2 returns a new NetAddress for an address that can not be
+// expressed as an IP using the provided network, raw address, port, and
+// supported services with defaults for the remaining fields.
+func NewNetAddressV2(network NetworkID, addr []byte, port uint16,
+	services ServiceFlag) *NetAddress {
+
+	return &NetAddress{
+		Timestamp: time.Unix(time.Now().Unix(), 0),
+		Services:  services,
+		Network:   network,
+		Addr:      addr,
+		Port:      port,
+	}
+}
+
+// readNetAddressV2 reads an addrv2 encoded NetAddress from r.  Addresses of
+// networks which fit in an IP, including Tor v2 which uses the OnionCat
+// range, are decoded into IP so the rest of the codebase can treat them the
+// same as addresses from the legacy addr message.
+func readNetAddressV2(r io.Reader, pver uint32, na *NetAddress) error {
+	err := readElement(r, (*uint32Time)(&na.Timestamp))
+	if err != nil {
+		return err
+	}
+
+	services, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+
+	id, err := binarySerializer.Uint8(r)
+	if err != nil {
+		return err
+	}
+	network := NetworkID(id)
+
+	addr, err := ReadVarBytes(r, pver, MaxAddrV2Size, "addrv2 address")
+	if err != nil {
+		return err
+	}
+	if size, ok := networkAddrSizes[network]; ok && len(addr) != size {
+		str := fmt.Sprintf("%v address is %d bytes, want %d", network,
+			len(addr), size)
+		return messageError("readNetAddressV2", str)
+	}
+
+	// Sigh.  Bitcoin protocol mixes little and big endian.
+	port, err := binarySerializer.Uint16(r, bigEndian)
+	if err != nil {
+		return err
+	}
+
+	*na = NetAddress{
+		Timestamp: na.Timestamp,
+		Services:  ServiceFlag(services),
+		Port:      port,
+	}
+	switch network {
+	case NetworkIPv4:
+		na.IP = net.IP(addr).To16()
+	case NetworkIPv6:
+		na.IP = net.IP(addr)
+	case NetworkTorV2:
+		na.IP = net.IP(append(append([]byte{}, onionCatPrefix...),
+			addr...))
+	default:
+		na.Network = network
+		na.Addr = addr
+	}
+	return nil
+}
+
+// writeNetAddressV2 serializes a NetAddress to w using the addrv2 encoding.
+func writeNetAddressV2(w io.Writer, pver uint32, na *NetAddress) error {
+	err := writeElement(w, uint32(na.Timestamp.Unix()))
+	if err != nil {
+		return err
+	}
+
+	err = WriteVarInt(w, pver, uint64(na.Services))
+	if err != nil {
+		return err
+	}
+
+	network := na.NetworkID()
+	var addr []byte
+	switch network {
+	case NetworkIPv4:
+		addr = na.IP.To4()
+	case NetworkIPv6:
+		// Ensure to always write 16 bytes even if the ip is nil.
+		addr = make([]byte, net.IPv6len)
+		copy(addr, na.IP.To16())
+	case NetworkTorV2:
+		addr = na.IP[len(onionCatPrefix):]
+	default:
+		addr = na.Addr
+	}
+	if size, ok := networkAddrSizes[network]; ok && len(addr) != size {
+		str := fmt.Sprintf("%v address is %d bytes, want %d", network,
+			len(addr), size)
+		return messageError("writeNetAddressV2", str)
+	}
+	if len(addr) > MaxAddrV2Size {
+		str := fmt.Sprintf("address is %d bytes, max %d", len(addr),
+			MaxAddrV2Size)
+		return messageError("writeNetAddressV2", str)
+	}
+
+	err = binarySerializer.PutUint8(w, uint8(network))
+	if err != nil {
+		return err
+	}
+	err = WriteVarBytes(w, pver, addr)
+	if err != nil {
+		return err
+	}
+
+	// Sigh.  Bitcoin protocol mixes little and big endian.
+	return binary.Write(w, bigEndian, na.Port)
+}
@@ -13,7 +13,19 @@ import (
 // XXX pedro: we will probably need to bump this.
 const (
 	// ProtocolVersion is the latest protocol version this package supports.
-	ProtocolVersion uint32 = 70015
+	ProtocolVersion uint32 = 70016
+
+	// ShortIDsBlocksVersion is the protocol version which added the
+	// sendcmpct, cmpctblock, getblocktxn and blocktxn messages (BIP0152).
+	ShortIDsBlocksVersion uint32 = 70014
+
+	// XVersionVersion is the protocol version which added the xversion and
+	// xverack messages.
+	XVersionVersion uint32 = 70016
+
+	// AddrV2Version is the protocol version which added the sendaddrv2 and
+	// addrv2 messages (BIP0155).
+	AddrV2Version uint32 = 70016
 )
 
 // ServiceFlag identifies services supported by a bitcoin peer.
@@ -140,6 +152,9 @@ const (
 
 	// SimNet represents the simulation test network.
 	SimNet BitcoinNet = 0x12141c16
+
+	// SigNet represents the signed test network.
+	SigNet BitcoinNet = 0x0a03cf40
 )
 
 // bnStrings is a map of bitcoin networks back to their constant names for
@@ -149,6 +164,7 @@ var bnStrings = map[BitcoinNet]string{
 	TestNet:  "TestNet",
 	TestNet3: "TestNet3",
 	SimNet:   "SimNet",
+	SigNet:   "SigNet",
 }
 
 // String returns the BitcoinNet in human-readable form.